@@ -32,6 +32,40 @@ const (
 	// DefaultInitTimeout is the default value for MainConfig.InitTimeout if not specified.
 	DefaultInitTimeout = time.Second * 10
 
+	// DefaultShutdownTimeout is the default value for MainConfig.ShutdownTimeout if not specified.
+	DefaultShutdownTimeout = time.Second * 10
+
+	// DefaultCompressionMinSize is the default value for MainConfig.CompressionMinSize, in bytes, if not
+	// specified.
+	DefaultCompressionMinSize = 1024
+
+	// DefaultEvalCacheMaxEntries is the default value for MainConfig.EvalCacheMaxEntries if not specified.
+	DefaultEvalCacheMaxEntries = 10000
+
+	// DefaultMaxEventBodySize is the default value for MainConfig.MaxEventBodySize, in bytes, if not
+	// specified.
+	DefaultMaxEventBodySize = 10 * 1024 * 1024
+
+	// DefaultReadHeaderTimeout is the default value for MainConfig.ReadHeaderTimeout if not specified.
+	DefaultReadHeaderTimeout = time.Second * 10
+
+	// DefaultUpstreamHealthCheckInterval is the default value for MainConfig.UpstreamHealthCheckInterval
+	// if not specified.
+	DefaultUpstreamHealthCheckInterval = time.Second * 30
+
+	// DefaultStreamInitialRetryDelay is the default value for MainConfig.StreamInitialRetryDelay if not specified.
+	DefaultStreamInitialRetryDelay = time.Second
+
+	// DefaultStreamMaxRetryDelay is the default value for MainConfig.StreamMaxRetryDelay if not specified.
+	DefaultStreamMaxRetryDelay = time.Second * 30
+
+	// DefaultStreamRetryJitter is the default value for MainConfig.StreamRetryJitter if not specified.
+	DefaultStreamRetryJitter = 0.5
+
+	// DefaultStreamRetryResetInterval is the default value for MainConfig.StreamRetryResetInterval if not
+	// specified.
+	DefaultStreamRetryResetInterval = time.Minute
+
 	// DefaultEventCapacity is the default value for EventsConfig.Capacity if not specified.
 	DefaultEventCapacity = 1000
 
@@ -41,18 +75,247 @@ const (
 	// DefaultEventsFlushInterval is the default value for EventsConfig.FlushInterval if not specified.
 	DefaultEventsFlushInterval = time.Second * 5
 
+	// DefaultEventsSpoolMaxSize is the default value for EventsConfig.SpoolMaxSize, in megabytes, if not
+	// specified.
+	DefaultEventsSpoolMaxSize = 100
+
+	// DefaultEventsSpoolMaxAge is the default value for EventsConfig.SpoolMaxAge if not specified.
+	DefaultEventsSpoolMaxAge = time.Hour * 24
+
 	// DefaultDisconnectedStatusTime is the default value for MainConfig.DisconnectedStatusTime if not specified.
 	DefaultDisconnectedStatusTime = time.Minute
 
 	// DefaultDatabaseCacheTTL is the default value for the LocalTTL parameter for databases if not specified.
 	DefaultDatabaseCacheTTL = time.Second * 30
 
+	// DefaultReadReplicaPollInterval is the default value for ReadReplicaConfig.PollInterval if not
+	// specified.
+	DefaultReadReplicaPollInterval = time.Second * 5
+
 	// DefaultPrometheusPort is the default value for PrometheusConfig.Port if not specified.
 	DefaultPrometheusPort = 8031
 
 	// DefaultBigSegmentsStaleThreshold is the default value for MainConfig.BigSegmentsStaleThreshold if not specified.
 	DefaultBigSegmentsStaleThreshold = time.Minute * 5
 
+	// DefaultBigSegmentsSnapshotInterval is the default value for BigSegmentsConfig.SnapshotInterval if not
+	// specified.
+	DefaultBigSegmentsSnapshotInterval = time.Minute
+
+	// DefaultBigSegmentsS3PollInterval is the default value for BigSegmentsConfig.S3PollInterval if not
+	// specified.
+	DefaultBigSegmentsS3PollInterval = time.Minute * 5
+
+	// DefaultShadowPercentage is the default value for ShadowConfig.Percentage if not specified.
+	DefaultShadowPercentage = 100.0
+
+	// DefaultShadowTimeout is the default value for ShadowConfig.Timeout if not specified.
+	DefaultShadowTimeout = time.Second * 5
+
+	// DefaultSecretsRefreshInterval is the default value for SecretsConfig.RefreshInterval if not specified.
+	DefaultSecretsRefreshInterval = time.Minute * 5
+
+	// DefaultBigSegmentsCircuitBreakerRetryInterval is the default value for
+	// BigSegmentsConfig.CircuitBreakerRetryInterval if not specified.
+	DefaultBigSegmentsCircuitBreakerRetryInterval = time.Second * 10
+
+	// BigSegmentsCircuitBreakerFallbackNotIncluded is the value of BigSegmentsConfig.CircuitBreakerFallback
+	// that causes users to be treated as not included in any big segment while the circuit breaker is
+	// open. This is the default.
+	BigSegmentsCircuitBreakerFallbackNotIncluded = "notIncluded"
+
+	// BigSegmentsCircuitBreakerFallbackLastKnown is the value of BigSegmentsConfig.CircuitBreakerFallback
+	// that causes the last membership state that was successfully queried for a user to be used while the
+	// circuit breaker is open, if there is one.
+	BigSegmentsCircuitBreakerFallbackLastKnown = "lastKnown"
+
+	// DefaultBigSegmentsQueryQueueTimeout is the default value for BigSegmentsConfig.QueryQueueTimeout if
+	// not specified.
+	DefaultBigSegmentsQueryQueueTimeout = time.Millisecond * 500
+
+	// DefaultBigSegmentsNegativeCacheTime is the default value for BigSegmentsConfig.NegativeCacheTime if
+	// not specified.
+	DefaultBigSegmentsNegativeCacheTime = time.Second
+
+	// AutoConfigQuotaOverflowReject is the value of AutoConfigConfig.EnvQuotaOverflow that causes requests
+	// and events that exceed an environment's quotas to be rejected immediately. This is the default.
+	AutoConfigQuotaOverflowReject = "reject"
+
+	// AutoConfigQuotaOverflowQueue is the value of AutoConfigConfig.EnvQuotaOverflow that causes requests
+	// that exceed an environment's quotas to wait, for up to EnvQuotaQueueTimeout, until they are within
+	// the quotas; and causes queued events that exceed the memory budget to be delivered early.
+	AutoConfigQuotaOverflowQueue = "queue"
+
+	// DiagnosticEventsForward is the value of EventsConfig.DiagnosticEvents that causes diagnostic events
+	// from SDKs to be forwarded to LaunchDarkly unchanged. This is the default.
+	DiagnosticEventsForward = "forward"
+
+	// DiagnosticEventsBlock is the value of EventsConfig.DiagnosticEvents that causes diagnostic events
+	// from SDKs to be discarded.
+	DiagnosticEventsBlock = "block"
+
+	// DiagnosticEventsAnnotate is the value of EventsConfig.DiagnosticEvents that causes diagnostic events
+	// from SDKs to be forwarded with the Relay version and EventsConfig.DiagnosticInstanceName added to
+	// their platform information.
+	DiagnosticEventsAnnotate = "annotate"
+
+	// DefaultAutoConfigQuotaQueueTimeout is the default value for AutoConfigConfig.EnvQuotaQueueTimeout.
+	DefaultAutoConfigQuotaQueueTimeout = time.Second * 5
+
+	// StreamLimitOverflowReject is the value of StreamLimitsConfig.Overflow that causes stream connections
+	// that exceed a limit to be rejected immediately. This is the default.
+	StreamLimitOverflowReject = "reject"
+
+	// StreamLimitOverflowQueue is the value of StreamLimitsConfig.Overflow that causes stream connections
+	// that exceed a limit to wait, for up to StreamLimitsConfig.QueueTimeout, for another connection to end.
+	StreamLimitOverflowQueue = "queue"
+
+	// DefaultStreamLimitQueueTimeout is the default value for StreamLimitsConfig.QueueTimeout.
+	DefaultStreamLimitQueueTimeout = time.Second * 5
+
+	// DefaultStreamLimitRetryAfter is the default value for StreamLimitsConfig.RetryAfter.
+	DefaultStreamLimitRetryAfter = time.Second * 10
+
+	// DefaultMemoryCheckInterval is the default value for MemoryLimitsConfig.CheckInterval.
+	DefaultMemoryCheckInterval = time.Second
+
+	// DefaultMemoryLowWaterMarkPercent is the percentage of MemoryLimitsConfig.HighWaterMarkMB that is
+	// used as the low-water mark, if MemoryLimitsConfig.LowWaterMarkMB is not set.
+	DefaultMemoryLowWaterMarkPercent = 90
+
+	// DefaultMaintenanceRetryAfter is the default value for MaintenanceConfig.RetryAfter.
+	DefaultMaintenanceRetryAfter = time.Second * 60
+
+	// BigSegmentsStaleModeFailOpen is the value of EnvConfig.BigSegmentsStaleMode that causes evaluations to
+	// use stale big segment data as usual. This is the default.
+	BigSegmentsStaleModeFailOpen = "failOpen"
+
+	// BigSegmentsStaleModeFailClosed is the value of EnvConfig.BigSegmentsStaleMode that causes evaluations
+	// to treat users as not being included in, or excluded from, any big segment while the data is stale.
+	BigSegmentsStaleModeFailClosed = "failClosed"
+
+	// BigSegmentsStoreTypeMemory is the value of BigSegmentsConfig.Type for the built-in in-memory big
+	// segment store.
+	BigSegmentsStoreTypeMemory = "memory"
+
+	// BigSegmentsStoreTypeS3 is the value of BigSegmentsConfig.Type for the built-in big segment store that
+	// loads membership data from objects in an S3 bucket, instead of from LaunchDarkly.
+	BigSegmentsStoreTypeS3 = "s3"
+
+	// LeaderElectionTypeRedis is the value of LeaderElectionConfig.Type for a lock in the Redis database
+	// that is configured in RedisConfig.
+	LeaderElectionTypeRedis = "redis"
+
+	// LeaderElectionTypeKubernetes is the value of LeaderElectionConfig.Type for a Kubernetes Lease in
+	// the cluster that Relay is running in.
+	LeaderElectionTypeKubernetes = "kubernetes"
+
+	// DefaultLeaderElectionLockName is the default value for LeaderElectionConfig.LockName.
+	DefaultLeaderElectionLockName = "ld-relay-leader"
+
+	// DefaultLeaderElectionLeaseDuration is the default value for LeaderElectionConfig.LeaseDuration.
+	DefaultLeaderElectionLeaseDuration = time.Second * 15
+
+	// DefaultLeaderElectionRenewInterval is the default value for LeaderElectionConfig.RenewInterval.
+	DefaultLeaderElectionRenewInterval = time.Second * 5
+
+	// DefaultReplicationQueueSize is the default value for ReplicationConfig.QueueSize.
+	DefaultReplicationQueueSize = 1000
+
+	// DefaultReplicationRetryInterval is the default value for ReplicationConfig.RetryInterval.
+	DefaultReplicationRetryInterval = time.Second * 10
+
+	// DefaultSnapshotInterval is the default value for SnapshotsConfig.Interval if not specified.
+	DefaultSnapshotInterval = time.Hour
+
+	// SnapshotStoreTypeS3 is the value of SnapshotsConfig.Type for writing snapshots to an S3 bucket.
+	SnapshotStoreTypeS3 = "s3"
+
+	// SnapshotStoreTypeGCS is the value of SnapshotsConfig.Type for writing snapshots to a Google Cloud
+	// Storage bucket.
+	SnapshotStoreTypeGCS = "gcs"
+
+	// SnapshotStoreTypeAzure is the value of SnapshotsConfig.Type for writing snapshots to an Azure Blob
+	// Storage container.
+	SnapshotStoreTypeAzure = "azure"
+
+	// AccessLogFormatCommon is the value of AccessLogConfig.Format for the Common Log Format used by
+	// many web servers. This is the default.
+	AccessLogFormatCommon = "common"
+
+	// AccessLogFormatCombined is the value of AccessLogConfig.Format for the Combined Log Format, which
+	// adds the referer and user agent to the Common Log Format.
+	AccessLogFormatCombined = "combined"
+
+	// AccessLogFormatJSON is the value of AccessLogConfig.Format for writing each request as a JSON object.
+	AccessLogFormatJSON = "json"
+
+	// AccessLogOutputStdout is the value of AccessLogConfig.Output for writing the access log to
+	// standard output. This is the default.
+	AccessLogOutputStdout = "stdout"
+
+	// AccessLogOutputFile is the value of AccessLogConfig.Output for writing the access log to
+	// AccessLogConfig.File, which is rotated when it reaches AccessLogConfig.MaxFileSize.
+	AccessLogOutputFile = "file"
+
+	// AccessLogOutputSyslog is the value of AccessLogConfig.Output for sending the access log to syslog.
+	AccessLogOutputSyslog = "syslog"
+
+	// EventExportModeTee is the value of the Mode property of KafkaConfig, KinesisConfig, or PubSubConfig
+	// for exporting analytics events in addition to delivering them to LaunchDarkly. This is the default.
+	EventExportModeTee = "tee"
+
+	// EventExportModeRedirect is the value of the Mode property of KafkaConfig, KinesisConfig, or
+	// PubSubConfig for exporting analytics events instead of delivering them to LaunchDarkly.
+	EventExportModeRedirect = "redirect"
+
+	// EventExportFormatEvent is the value of the Format property of KafkaConfig, KinesisConfig, or
+	// PubSubConfig for exporting each event as a separate message whose content is the event's JSON
+	// object. Kafka headers or Pub/Sub attributes describe the environment; Kinesis records have no
+	// equivalent. This is the default.
+	EventExportFormatEvent = "event"
+
+	// EventExportFormatEnvelope is the value of the Format property of KafkaConfig, KinesisConfig, or
+	// PubSubConfig for exporting each event as a separate message whose content is a JSON object
+	// containing the event and a description of the environment.
+	EventExportFormatEnvelope = "envelope"
+
+	// EventExportKafka is a value for EnvConfig.EventExport that refers to the destination in KafkaConfig.
+	EventExportKafka = "kafka"
+
+	// EventExportKinesis is a value for EnvConfig.EventExport that refers to the destination in
+	// KinesisConfig.
+	EventExportKinesis = "kinesis"
+
+	// EventExportPubSub is a value for EnvConfig.EventExport that refers to the destination in
+	// PubSubConfig.
+	EventExportPubSub = "pubsub"
+
+	// EventExportNone is a value for EnvConfig.EventExport that turns off event export for the
+	// environment.
+	EventExportNone = "none"
+
+	// KafkaSASLPlain is the value of KafkaConfig.SASLMechanism for SASL/PLAIN authentication.
+	KafkaSASLPlain = "plain"
+
+	// KafkaSASLSCRAMSHA256 is the value of KafkaConfig.SASLMechanism for SASL/SCRAM authentication
+	// with SHA-256.
+	KafkaSASLSCRAMSHA256 = "scram-sha-256"
+
+	// KafkaSASLSCRAMSHA512 is the value of KafkaConfig.SASLMechanism for SASL/SCRAM authentication
+	// with SHA-512.
+	KafkaSASLSCRAMSHA512 = "scram-sha-512"
+
+	// DefaultAccessLogMaxFileSize is the default value for AccessLogConfig.MaxFileSize, in megabytes, if
+	// not specified.
+	DefaultAccessLogMaxFileSize = 100
+
+	// DefaultAccessLogMaxFiles is the default value for AccessLogConfig.MaxFiles if not specified.
+	DefaultAccessLogMaxFiles = 5
+
+	// DefaultAccessLogSyslogTag is the default value for AccessLogConfig.SyslogTag if not specified.
+	DefaultAccessLogSyslogTag = "ld-relay"
+
 	// AutoConfigEnvironmentIDPlaceholder is a string that can appear within
 	// AutoConfigConfig.EnvDataStorePrefix or AutoConfigConfig.EnvDataStoreTableName to indicate that
 	// the environment ID should be substituted at that point.
@@ -68,6 +331,7 @@ const (
 	defaultRedisHost  = "localhost"
 	defaultRedisPort  = 6379
 	defaultConsulHost = "localhost"
+	defaultEtcdHost   = "localhost:2379"
 )
 
 var (
@@ -77,6 +341,8 @@ var (
 	defaultStreamURI, _         = ct.NewOptURLAbsoluteFromString(DefaultStreamURI)               //nolint:gochecknoglobals
 	defaultEventsURI, _         = ct.NewOptURLAbsoluteFromString(DefaultEventsURI)               //nolint:gochecknoglobals
 	defaultRedisURL, _          = ct.NewOptURLAbsoluteFromString("redis://localhost:6379")       //nolint:gochecknoglobals
+	defaultPostgresURL, _       = ct.NewOptURLAbsoluteFromString("postgres://localhost:5432")    //nolint:gochecknoglobals
+	defaultMongoDBURL, _        = ct.NewOptURLAbsoluteFromString("mongodb://localhost:27017")    //nolint:gochecknoglobals
 )
 
 // DefaultLoggers is the default logging configuration used by Relay.
@@ -98,15 +364,54 @@ var DefaultLoggers = logging.MakeDefaultLoggers() //nolint:gochecknoglobals
 // variables, individual fields are not documented here; instead, see the `README.md` section on
 // configuration.
 type Config struct {
-	Main        MainConfig
-	AutoConfig  AutoConfigConfig
-	OfflineMode OfflineModeConfig
-	Events      EventsConfig
-	Redis       RedisConfig
-	Consul      ConsulConfig
-	DynamoDB    DynamoDBConfig
-	Environment map[string]*EnvConfig
-	Proxy       ProxyConfig
+	Main            MainConfig
+	ACME            ACMEConfig
+	AutoConfig      AutoConfigConfig
+	OfflineMode     OfflineModeConfig
+	ReadReplica     ReadReplicaConfig
+	LeaderElection  LeaderElectionConfig
+	Replication     ReplicationConfig
+	Events          EventsConfig
+	Redis           RedisConfig
+	Consul          ConsulConfig
+	DynamoDB        DynamoDBConfig
+	Postgres        PostgresConfig
+	MongoDB         MongoDBConfig
+	Etcd            EtcdConfig
+	BigSegments     BigSegmentsConfig
+	Secrets         SecretsConfig
+	Admin           AdminConfig
+	RateLimit       RateLimitConfig
+	StreamLimits    StreamLimitsConfig
+	MemoryLimits    MemoryLimitsConfig
+	Maintenance     MaintenanceConfig
+	InternalMetrics InternalMetricsConfig
+	AccessLog       AccessLogConfig
+	AuditLog        AuditLogConfig
+	Kafka           KafkaConfig
+	Kinesis         KinesisConfig
+	PubSub          PubSubConfig
+	Snapshots       SnapshotsConfig
+	Health          HealthConfig
+	Shadow          ShadowConfig
+	ResponseHeaders ResponseHeadersConfig
+	Environment     map[string]*EnvConfig
+	Proxy           ProxyConfig
+
+	// Named databases that environments can select with EnvConfig.Database, instead of using the
+	// database in the Redis, Consul, DynamoDB, Postgres, MongoDB, or Etcd section. These correspond to
+	// sections such as [RedisDatabase "name"] in the configuration file. Names must be unique across all
+	// six kinds.
+	RedisDatabase    map[string]*RedisConfig
+	ConsulDatabase   map[string]*ConsulConfig
+	DynamoDBDatabase map[string]*DynamoDBConfig
+	PostgresDatabase map[string]*PostgresConfig
+	MongoDBDatabase  map[string]*MongoDBConfig
+	EtcdDatabase     map[string]*EtcdConfig
+
+	// Tenants that environments can be assigned to with EnvConfig.Tenant. These correspond to sections
+	// such as [Tenant "name"] in the configuration file.
+	Tenant map[string]*TenantConfig
 
 	// Optional configuration for metrics integrations. Note that unlike the other fields in Config,
 	// MetricsConfig is not the name of a configuration file section; the actual sections are the
@@ -128,9 +433,28 @@ type MainConfig struct {
 	StreamURI                   ct.OptURLAbsolute        `conf:"STREAM_URI"`
 	BaseURI                     ct.OptURLAbsolute        `conf:"BASE_URI"`
 	ClientSideBaseURI           ct.OptURLAbsolute        `conf:"CLIENT_SIDE_BASE_URI"`
+	UpstreamRelayURI            ct.OptURLAbsolute        `conf:"UPSTREAM_RELAY_URI"`
+	StreamURIFallback           ct.OptStringList         `conf:"STREAM_URI_FALLBACKS"`
+	BaseURIFallback             ct.OptStringList         `conf:"BASE_URI_FALLBACKS"`
+	UpstreamHealthCheckInterval ct.OptDuration           `conf:"UPSTREAM_HEALTH_CHECK_INTERVAL"`
+	StreamInitialRetryDelay     ct.OptDuration           `conf:"STREAM_INITIAL_RETRY_DELAY"`
+	StreamMaxRetryDelay         ct.OptDuration           `conf:"STREAM_MAX_RETRY_DELAY"`
+	StreamRetryJitter           ct.OptFloat64            `conf:"STREAM_RETRY_JITTER"`
+	StreamRetryResetInterval    ct.OptDuration           `conf:"STREAM_RETRY_RESET_INTERVAL"`
 	Port                        ct.OptIntGreaterThanZero `conf:"PORT"`
+	GRPCPort                    ct.OptIntGreaterThanZero `conf:"GRPC_PORT"`
+	ReusePort                   bool                     `conf:"REUSE_PORT"`
+	UnixSocket                  string                   `conf:"UNIX_SOCKET"`
+	UnixSocketMode              OptFileMode              `conf:"UNIX_SOCKET_MODE"`
+	UnixSocketOnly              bool                     `conf:"UNIX_SOCKET_ONLY"`
 	InitTimeout                 ct.OptDuration           `conf:"INIT_TIMEOUT"`
+	ShutdownTimeout             ct.OptDuration           `conf:"SHUTDOWN_TIMEOUT"`
 	HeartbeatInterval           ct.OptDuration           `conf:"HEARTBEAT_INTERVAL"`
+	SSERetryDelay               ct.OptDuration           `conf:"SSE_RETRY_DELAY"`
+	TCPKeepAlive                ct.OptDuration           `conf:"TCP_KEEPALIVE"`
+	PatchCoalescingWindow       ct.OptDuration           `conf:"PATCH_COALESCING_WINDOW"`
+	EvalCacheTTL                ct.OptDuration           `conf:"EVAL_CACHE_TTL"`
+	EvalCacheMaxEntries         ct.OptIntGreaterThanZero `conf:"EVAL_CACHE_MAX_ENTRIES"`
 	MaxClientConnectionTime     ct.OptDuration           `conf:"MAX_CLIENT_CONNECTION_TIME"`
 	DisconnectedStatusTime      ct.OptDuration           `conf:"DISCONNECTED_STATUS_TIME"`
 	DisableInternalUsageMetrics bool                     `conf:"DISABLE_INTERNAL_USAGE_METRICS"`
@@ -138,27 +462,135 @@ type MainConfig struct {
 	TLSCert                     string                   `conf:"TLS_CERT"`
 	TLSKey                      string                   `conf:"TLS_KEY"`
 	TLSMinVersion               OptTLSVersion            `conf:"TLS_MIN_VERSION"`
+	TLSClientCertRequired       bool                     `conf:"TLS_CLIENT_CERT_REQUIRED"`
+	TLSClientCA                 string                   `conf:"TLS_CLIENT_CA"`
+	H2CEnabled                  bool                     `conf:"H2C_ENABLED"`
+	HTTP3Enabled                bool                     `conf:"HTTP3_ENABLED"`
 	LogLevel                    OptLogLevel              `conf:"LOG_LEVEL"`
+	LogFormat                   LogFormat                `conf:"LOG_FORMAT"`
+	LogLevelSDKs                OptLogLevel              `conf:"LOG_LEVEL_SDKS"`
+	LogLevelEvents              OptLogLevel              `conf:"LOG_LEVEL_EVENTS"`
+	LogLevelBigSegments         OptLogLevel              `conf:"LOG_LEVEL_BIG_SEGMENTS"`
+	LogLevelHTTP                OptLogLevel              `conf:"LOG_LEVEL_HTTP"`
 	BigSegmentsStaleAsDegraded  bool                     `conf:"BIG_SEGMENTS_STALE_AS_DEGRADED"`
 	BigSegmentsStaleThreshold   ct.OptDuration           `conf:"BIG_SEGMENTS_STALE_THRESHOLD"`
+	WarmCacheOnStartup          bool                     `conf:"WARM_CACHE_ON_STARTUP"`
+	LazyEnvironmentInit         bool                     `conf:"LAZY_ENV_INIT"`
+	EnvironmentIdleTimeout      ct.OptDuration           `conf:"ENV_IDLE_TIMEOUT"`
+	RedactFlagRules             bool                     `conf:"REDACT_FLAG_RULES"`
+	CompressionEnabled          bool                     `conf:"COMPRESSION_ENABLED"`
+	CompressionMinSize          ct.OptIntGreaterThanZero `conf:"COMPRESSION_MIN_SIZE"`
+	MaxEventBodySize            ct.OptIntGreaterThanZero `conf:"MAX_EVENT_BODY_SIZE"`
+	MaxRequestHeaderSize        ct.OptIntGreaterThanZero `conf:"MAX_REQUEST_HEADER_SIZE"`
+	ReadHeaderTimeout           ct.OptDuration           `conf:"READ_HEADER_TIMEOUT"`
+	ReadTimeout                 ct.OptDuration           `conf:"READ_TIMEOUT"`
+	WriteTimeout                ct.OptDuration           `conf:"WRITE_TIMEOUT"`
+	ProxyProtocolEnabled        bool                     `conf:"PROXY_PROTOCOL_ENABLED"`
+	ProxyProtocolAllowedSource  ct.OptStringList         `conf:"PROXY_PROTOCOL_ALLOWED_SOURCES"`
+}
+
+// ACMEConfig contains configuration parameters for obtaining TLS certificates automatically from an ACME
+// certificate authority such as Let's Encrypt, instead of using MainConfig.TLSCert and MainConfig.TLSKey.
+// It is only used if MainConfig.TLSEnabled is true and Domain is not empty.
+//
+// This corresponds to the [ACME] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type ACMEConfig struct {
+	Domain       ct.OptStringList         `conf:"ACME_DOMAINS"`
+	Email        string                   `conf:"ACME_EMAIL"`
+	CacheDir     string                   `conf:"ACME_CACHE_DIR"`
+	DirectoryURL ct.OptURLAbsolute        `conf:"ACME_DIRECTORY_URL"`
+	HTTPPort     ct.OptIntGreaterThanZero `conf:"ACME_HTTP_PORT"`
 }
 
 // AutoConfigConfig contains configuration parameters for the auto-configuration feature.
 type AutoConfigConfig struct {
-	Key                   AutoConfigKey    `conf:"AUTO_CONFIG_KEY"`
+	Key                   AutoConfigKey    `conf:"AUTO_CONFIG_KEY" audit:"secret"`
 	EnvDatastorePrefix    string           `conf:"ENV_DATASTORE_PREFIX"`
 	EnvDatastoreTableName string           `conf:"ENV_DATASTORE_TABLE_NAME"`
 	EnvAllowedOrigin      ct.OptStringList `conf:"ENV_ALLOWED_ORIGIN"`
 	EnvAllowedHeader      ct.OptStringList `conf:"ENV_ALLOWED_HEADER"`
+	ProjectKeys           ct.OptStringList `conf:"AUTO_CONFIG_PROJECT_KEYS"`
+	EnvironmentKeys       ct.OptStringList `conf:"AUTO_CONFIG_ENV_KEYS"`
+	EnvironmentTags       ct.OptStringList `conf:"AUTO_CONFIG_ENV_TAGS"`
+
+	EnvMaxStreamConnections ct.OptIntGreaterThanZero `conf:"AUTO_CONFIG_ENV_MAX_STREAM_CONNECTIONS"`
+	EnvMaxEventsPerSecond   ct.OptFloat64            `conf:"AUTO_CONFIG_ENV_MAX_EVENTS_PER_SECOND"`
+	EnvMemoryBudget         ct.OptIntGreaterThanZero `conf:"AUTO_CONFIG_ENV_MEMORY_BUDGET"`
+	EnvQuotaOverflow        string                   `conf:"AUTO_CONFIG_ENV_QUOTA_OVERFLOW"`
+	EnvQuotaQueueTimeout    ct.OptDuration           `conf:"AUTO_CONFIG_ENV_QUOTA_QUEUE_TIMEOUT"`
 }
 
 // OfflineModeConfig contains configuration parameters for the offline/file data source feature.
+//
+// FileDataSource can be either an archive file, or a directory; in the latter case, Relay uses the most
+// recently modified archive file in the directory.
 type OfflineModeConfig struct {
 	FileDataSource        string           `conf:"FILE_DATA_SOURCE"`
 	EnvDatastorePrefix    string           `conf:"ENV_DATASTORE_PREFIX"`
 	EnvDatastoreTableName string           `conf:"ENV_DATASTORE_TABLE_NAME"`
 	EnvAllowedOrigin      ct.OptStringList `conf:"ENV_ALLOWED_ORIGIN"`
 	EnvAllowedHeader      ct.OptStringList `conf:"ENV_ALLOWED_HEADER"`
+	NoOutboundConnections bool             `conf:"NO_OUTBOUND_CONNECTIONS"`
+}
+
+// ReadReplicaConfig contains configuration parameters for read replica mode, in which Relay never
+// connects to LaunchDarkly, and instead serves flag data that it reads from the persistent store that
+// is populated by other Relay instances.
+//
+// If Enabled is true, Relay reads each environment's data from the store every PollInterval, and
+// pushes any changes to connected SDKs. Events that are sent to Relay are discarded.
+//
+// This corresponds to the [ReadReplica] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type ReadReplicaConfig struct {
+	Enabled      bool           `conf:"READ_REPLICA"`
+	PollInterval ct.OptDuration `conf:"READ_REPLICA_POLL_INTERVAL"`
+}
+
+// LeaderElectionConfig configures optional leader election between Relay instances that share a
+// persistent store, such as an active/passive pair. If Type is set, the instances compete for a lock with
+// the name LockName, either in Redis or as a Kubernetes Lease; the one that holds it is the leader, and
+// renews it every RenewInterval, for LeaseDuration each time. Only the leader writes flag data to the
+// persistent store and forwards events; the others are on standby, and keep serving flag data, but keep
+// their updates in memory and discard events, until they become the leader.
+//
+// This corresponds to the [LeaderElection] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type LeaderElectionConfig struct {
+	Type                string         `conf:"LEADER_ELECTION_TYPE"`
+	LockName            string         `conf:"LEADER_ELECTION_LOCK_NAME"`
+	Identity            string         `conf:"LEADER_ELECTION_IDENTITY"`
+	LeaseDuration       ct.OptDuration `conf:"LEADER_ELECTION_LEASE_DURATION"`
+	RenewInterval       ct.OptDuration `conf:"LEADER_ELECTION_RENEW_INTERVAL"`
+	KubernetesNamespace string         `conf:"LEADER_ELECTION_KUBERNETES_NAMESPACE"`
+}
+
+// ReplicationConfig configures optional replication of flag and big segment data to secondary databases,
+// such as databases in other regions that are used by Relay instances in read replica mode. Each name
+// in Database refers to a named database; every write that Relay makes to an environment's own data
+// store is then made to each of those databases as well, with the same prefix or table name, in the
+// background. Up to QueueSize writes can be waiting for each database; if a write fails or the queue is
+// full, the flag data is copied in full every RetryInterval until it succeeds.
+//
+// This corresponds to the [Replication] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type ReplicationConfig struct {
+	Database      ct.OptStringList         `conf:"REPLICATION_DATABASES"`
+	QueueSize     ct.OptIntGreaterThanZero `conf:"REPLICATION_QUEUE_SIZE"`
+	RetryInterval ct.OptDuration           `conf:"REPLICATION_RETRY_INTERVAL"`
 }
 
 // EventsConfig contains configuration parameters for proxying events.
@@ -167,11 +599,19 @@ type OfflineModeConfig struct {
 // variables, individual fields are not documented here; instead, see the `README.md` section on
 // configuration.
 type EventsConfig struct {
-	EventsURI     ct.OptURLAbsolute        `conf:"EVENTS_HOST"`
-	SendEvents    bool                     `conf:"USE_EVENTS"`
-	FlushInterval ct.OptDuration           `conf:"EVENTS_FLUSH_INTERVAL"`
-	Capacity      ct.OptIntGreaterThanZero `conf:"EVENTS_CAPACITY"`
-	InlineUsers   bool                     `conf:"EVENTS_INLINE_USERS"`
+	EventsURI              ct.OptURLAbsolute        `conf:"EVENTS_HOST"`
+	EventsURIFallback      ct.OptStringList         `conf:"EVENTS_HOST_FALLBACKS"`
+	SendEvents             bool                     `conf:"USE_EVENTS"`
+	FlushInterval          ct.OptDuration           `conf:"EVENTS_FLUSH_INTERVAL"`
+	Capacity               ct.OptIntGreaterThanZero `conf:"EVENTS_CAPACITY"`
+	InlineUsers            bool                     `conf:"EVENTS_INLINE_USERS"`
+	SummaryOnly            bool                     `conf:"EVENTS_SUMMARY_ONLY"`
+	DiagnosticEvents       string                   `conf:"EVENTS_DIAGNOSTIC"`
+	DiagnosticInstanceName string                   `conf:"EVENTS_DIAGNOSTIC_INSTANCE_NAME"` // reported in DiagnosticEventsAnnotate mode; default is the host name
+	Coordinate             bool                     `conf:"EVENTS_COORDINATE"`
+	SpoolDir               string                   `conf:"EVENTS_SPOOL_DIR"`
+	SpoolMaxSize           ct.OptIntGreaterThanZero `conf:"EVENTS_SPOOL_MAX_SIZE"`
+	SpoolMaxAge            ct.OptDuration           `conf:"EVENTS_SPOOL_MAX_AGE"`
 }
 
 // RedisConfig configures the optional Redis integration.
@@ -180,6 +620,11 @@ type EventsConfig struct {
 // the other value is set to defaultRedisPort or defaultRedisHost. It is an error to set Host or
 // Port if URL is also set.
 //
+// Redis is also enabled if ClusterAddrs is set, for a Redis Cluster, or if SentinelMasterName and
+// SentinelAddrs are set, for a Sentinel-managed primary. In either case it is an error to set URL,
+// Host or Port as well; our config validation sets URL to the first of the addresses, so that it
+// can still be used to tell whether Redis is enabled.
+//
 // This corresponds to the [Redis] section in the configuration file.
 //
 // Since configuration options can be set either programmatically, or from a file, or from environment
@@ -191,7 +636,23 @@ type RedisConfig struct {
 	URL      ct.OptURLAbsolute `conf:"REDIS_URL"`
 	LocalTTL ct.OptDuration    `conf:"CACHE_TTL"`
 	TLS      bool              `conf:"REDIS_TLS"`
-	Password string            `conf:"REDIS_PASSWORD"`
+	Password string            `conf:"REDIS_PASSWORD" audit:"secret"`
+
+	CAFile     string `conf:"REDIS_TLS_CA_FILE"`
+	CertFile   string `conf:"REDIS_TLS_CERT_FILE"`
+	KeyFile    string `conf:"REDIS_TLS_KEY_FILE"`
+	ServerName string `conf:"REDIS_TLS_SERVER_NAME"`
+
+	ClusterAddrs       ct.OptStringList `conf:"REDIS_CLUSTER_ADDRS"`
+	SentinelMasterName string           `conf:"REDIS_SENTINEL_MASTER_NAME"`
+	SentinelAddrs      ct.OptStringList `conf:"REDIS_SENTINEL_ADDRS"`
+	SentinelPassword   string           `conf:"REDIS_SENTINEL_PASSWORD" audit:"secret"`
+
+	PoolSize        ct.OptIntGreaterThanZero `conf:"REDIS_POOL_SIZE"`
+	MinIdleConns    ct.OptIntGreaterThanZero `conf:"REDIS_MIN_IDLE_CONNS"`
+	MaxConnLifetime ct.OptDuration           `conf:"REDIS_MAX_CONN_LIFETIME"`
+	DialTimeout     ct.OptDuration           `conf:"REDIS_DIAL_TIMEOUT"`
+	ReadTimeout     ct.OptDuration           `conf:"REDIS_READ_TIMEOUT"`
 }
 
 // ConsulConfig configures the optional Consul integration.
@@ -206,7 +667,7 @@ type RedisConfig struct {
 type ConsulConfig struct {
 	Host      string         `conf:"CONSUL_HOST"`
 	LocalTTL  ct.OptDuration `conf:"CACHE_TTL"`
-	Token     string         `conf:"CONSUL_TOKEN"`
+	Token     string         `conf:"CONSUL_TOKEN" audit:"secret"`
 	TokenFile string         `conf:"CONSUL_TOKEN_FILE"`
 }
 
@@ -218,10 +679,466 @@ type ConsulConfig struct {
 // variables, individual fields are not documented here; instead, see the `README.md` section on
 // configuration.
 type DynamoDBConfig struct {
-	Enabled   bool              `conf:"USE_DYNAMODB"`
-	TableName string            `conf:"DYNAMODB_TABLE"`
-	URL       ct.OptURLAbsolute `conf:"DYNAMODB_URL"`
-	LocalTTL  ct.OptDuration    `conf:"CACHE_TTL"`
+	Enabled         bool              `conf:"USE_DYNAMODB"`
+	TableName       string            `conf:"DYNAMODB_TABLE"`
+	URL             ct.OptURLAbsolute `conf:"DYNAMODB_URL"`
+	LocalTTL        ct.OptDuration    `conf:"CACHE_TTL"`
+	AccessKeyID     string            `conf:"DYNAMODB_ACCESS_KEY_ID"`
+	SecretAccessKey string            `conf:"DYNAMODB_SECRET_ACCESS_KEY" audit:"secret"`
+
+	PoolSize    ct.OptIntGreaterThanZero `conf:"DYNAMODB_POOL_SIZE"`
+	DialTimeout ct.OptDuration           `conf:"DYNAMODB_DIAL_TIMEOUT"`
+	ReadTimeout ct.OptDuration           `conf:"DYNAMODB_READ_TIMEOUT"`
+
+	DAXEndpoint string `conf:"DYNAMODB_DAX_ENDPOINT"`
+}
+
+// TenantConfig describes a group of environments that is served separately from the others, so that
+// one Relay instance can give each customer of a multi-tenant platform its own endpoint. The tenant's
+// environments can be reached only on the tenant's own Port, or on the main port with one of the
+// tenant's Host names in the Host header; their credentials are not accepted for any other requests.
+//
+// If TLSCert and TLSKey are set, that certificate is used for TLS connections on the tenant's Port, and
+// for TLS connections on the main port where the client requested one of the tenant's Host names with SNI.
+//
+// HeartbeatInterval, SSERetryDelay, and TCPKeepAlive tune stream connections on the tenant's Port, for
+// clients whose networks drop idle connections sooner than the settings in MainConfig allow for.
+//
+// This corresponds to one of the [Tenant "name"] sections in the configuration file. In the
+// Config.Tenant map, each key is a tenant name and each value is a TenantConfig.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type TenantConfig struct {
+	Port              ct.OptIntGreaterThanZero `conf:"TENANT_PORT"`
+	Host              ct.OptStringList         `conf:"TENANT_HOST"`
+	TLSCert           string                   `conf:"TENANT_TLS_CERT"`
+	TLSKey            string                   `conf:"TENANT_TLS_KEY"`
+	HeartbeatInterval ct.OptDuration           `conf:"TENANT_HEARTBEAT_INTERVAL"`
+	SSERetryDelay     ct.OptDuration           `conf:"TENANT_SSE_RETRY_DELAY"`
+	TCPKeepAlive      ct.OptDuration           `conf:"TENANT_TCP_KEEPALIVE"`
+}
+
+// AdminConfig configures the optional admin API, which allows environments to be added, removed, and
+// updated while Relay is running. The admin API is served on its own port, and is only enabled if Port
+// is set.
+//
+// This corresponds to the [Admin] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type AdminConfig struct {
+	Port            ct.OptIntGreaterThanZero `conf:"ADMIN_PORT"`
+	Key             string                   `conf:"ADMIN_KEY" audit:"secret"`
+	EnableProfiling bool                     `conf:"ADMIN_ENABLE_PROFILING"`
+}
+
+// RateLimitConfig configures optional rate limiting of requests to the SDK endpoints, including
+// evaluation, streaming, and event endpoints. Each rate is a number of requests per second, and
+// applies separately to each SDK key, mobile key, or client-side ID; PerClientIP additionally limits
+// the requests from each client IP address for each credential. The server-side, mobile, and
+// client-side rates can be overridden for each environment in EnvConfig.
+//
+// This corresponds to the [RateLimit] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type RateLimitConfig struct {
+	ServerSide      ct.OptFloat64            `conf:"RATE_LIMIT_SERVER_SIDE"`
+	Mobile          ct.OptFloat64            `conf:"RATE_LIMIT_MOBILE"`
+	ClientSide      ct.OptFloat64            `conf:"RATE_LIMIT_CLIENT_SIDE"`
+	PerClientIP     ct.OptFloat64            `conf:"RATE_LIMIT_PER_CLIENT_IP"`
+	Burst           ct.OptIntGreaterThanZero `conf:"RATE_LIMIT_BURST"`
+	UseForwardedFor bool                     `conf:"RATE_LIMIT_USE_FORWARDED_FOR"`
+	TrustedProxies  ct.OptStringList         `conf:"RATE_LIMIT_TRUSTED_PROXIES"`
+}
+
+// StreamLimitsConfig configures optional limits on the number of concurrent stream connections from
+// SDKs. MaxConnections applies to all environments together, and MaxConnectionsPerEnv to each
+// environment separately; the latter can be overridden for each environment in EnvConfig. A connection
+// that would exceed a limit is rejected with a 503 status and a Retry-After header, or, if Overflow is
+// "queue", first waits for up to QueueTimeout for another connection to end.
+//
+// This corresponds to the [StreamLimits] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type StreamLimitsConfig struct {
+	MaxConnections       ct.OptIntGreaterThanZero `conf:"STREAM_LIMIT_MAX_CONNECTIONS"`
+	MaxConnectionsPerEnv ct.OptIntGreaterThanZero `conf:"STREAM_LIMIT_MAX_CONNECTIONS_PER_ENV"`
+	Overflow             string                   `conf:"STREAM_LIMIT_OVERFLOW"`
+	QueueTimeout         ct.OptDuration           `conf:"STREAM_LIMIT_QUEUE_TIMEOUT"`
+	RetryAfter           ct.OptDuration           `conf:"STREAM_LIMIT_RETRY_AFTER"`
+}
+
+// MemoryLimitsConfig configures optional load shedding based on Relay's memory use, which is checked
+// every CheckInterval. If it rises above HighWaterMarkMB, Relay rejects new stream connections, discards
+// its response caches, and forces a garbage collection, until memory use falls below LowWaterMarkMB.
+//
+// This corresponds to the [MemoryLimits] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type MemoryLimitsConfig struct {
+	HighWaterMarkMB ct.OptIntGreaterThanZero `conf:"MEMORY_HIGH_WATER_MARK_MB"`
+	LowWaterMarkMB  ct.OptIntGreaterThanZero `conf:"MEMORY_LOW_WATER_MARK_MB"`
+	CheckInterval   ct.OptDuration           `conf:"MEMORY_CHECK_INTERVAL"`
+}
+
+// MaintenanceConfig configures the responses that Relay sends while it is in maintenance mode, which is
+// turned on and off at runtime with the admin API or the SIGUSR2 signal. In maintenance mode, every new
+// request other than the status and health endpoints is rejected with a 503 status, a Retry-After header
+// of RetryAfter, and ResponseBody as the body if it is set; existing stream connections are not affected.
+//
+// This corresponds to the [Maintenance] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type MaintenanceConfig struct {
+	RetryAfter          ct.OptDuration `conf:"MAINTENANCE_RETRY_AFTER"`
+	ResponseBody        string         `conf:"MAINTENANCE_RESPONSE_BODY"`
+	ResponseContentType string         `conf:"MAINTENANCE_RESPONSE_CONTENT_TYPE"`
+}
+
+// InternalMetricsConfig configures the optional internal metrics endpoint, which provides detailed
+// Prometheus metrics about Relay's own operation, such as stream connections, forwarded events, big
+// segment store activity, and Go runtime statistics. It is served at /metrics on its own port, and is
+// only enabled if Port is set. It is independent of the Prometheus integration in PrometheusConfig.
+//
+// This corresponds to the [InternalMetrics] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type InternalMetricsConfig struct {
+	Port   ct.OptIntGreaterThanZero `conf:"INTERNAL_METRICS_PORT"`
+	Prefix string                   `conf:"INTERNAL_METRICS_PREFIX"`
+}
+
+// AccessLogConfig configures the optional access log, which records every request to Relay's
+// endpoints along with its status, latency, credential type, and environment. It is enabled if Enabled
+// is true.
+//
+// This corresponds to the [AccessLog] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type AccessLogConfig struct {
+	Enabled       bool                     `conf:"ACCESS_LOG_ENABLED"`
+	Format        string                   `conf:"ACCESS_LOG_FORMAT"`
+	Output        string                   `conf:"ACCESS_LOG_OUTPUT"`
+	File          string                   `conf:"ACCESS_LOG_FILE"`
+	MaxFileSize   ct.OptIntGreaterThanZero `conf:"ACCESS_LOG_MAX_FILE_SIZE"`
+	MaxFiles      ct.OptIntGreaterThanZero `conf:"ACCESS_LOG_MAX_FILES"`
+	SyslogAddress ct.OptURLAbsolute        `conf:"ACCESS_LOG_SYSLOG_ADDRESS"`
+	SyslogTag     string                   `conf:"ACCESS_LOG_SYSLOG_TAG"`
+}
+
+// AuditLogConfig configures the optional audit log, which records changes to Relay's environments and
+// configuration: environments being added, updated, or removed by auto-configuration, the admin API,
+// or a configuration reload; credentials being rotated or expiring; and the configuration being
+// reloaded. Each record is a JSON object, with the values of credentials and other secrets redacted.
+// It is written to File if that is set, and posted to WebhookURL if that is set.
+//
+// This corresponds to the [AuditLog] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type AuditLogConfig struct {
+	File          string            `conf:"AUDIT_LOG_FILE"`
+	WebhookURL    ct.OptURLAbsolute `conf:"AUDIT_LOG_WEBHOOK_URL"`
+	WebhookHeader ct.OptStringList  `conf:"AUDIT_LOG_WEBHOOK_HEADERS" audit:"secret"`
+}
+
+// KafkaConfig configures the optional export of analytics events to a Kafka topic. If enabled, the
+// events that Relay forwards are written to the topic, either in addition to or instead of delivering
+// them to LaunchDarkly, for every environment unless EnvConfig.EventExport says otherwise.
+//
+// Kafka export is enabled if Brokers is set.
+//
+// This corresponds to the [Kafka] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type KafkaConfig struct {
+	Brokers       ct.OptStringList `conf:"KAFKA_BROKERS"`
+	Topic         string           `conf:"KAFKA_TOPIC"`
+	Mode          string           `conf:"KAFKA_MODE"`
+	Format        string           `conf:"KAFKA_FORMAT"`
+	TLSEnabled    bool             `conf:"KAFKA_TLS_ENABLED"`
+	SASLMechanism string           `conf:"KAFKA_SASL_MECHANISM"`
+	Username      string           `conf:"KAFKA_USERNAME"`
+	Password      string           `conf:"KAFKA_PASSWORD" audit:"secret"`
+}
+
+// KinesisConfig configures the optional export of analytics events to an AWS Kinesis data stream, in
+// the same way as KafkaConfig.
+//
+// Kinesis export is enabled if StreamName is set. AWS credentials, and the region if Region is not set,
+// are obtained in the usual ways for the AWS SDK, such as environment variables.
+//
+// This corresponds to the [Kinesis] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type KinesisConfig struct {
+	StreamName string            `conf:"KINESIS_STREAM_NAME"`
+	Region     string            `conf:"KINESIS_REGION"`
+	URL        ct.OptURLAbsolute `conf:"KINESIS_URL"`
+	Mode       string            `conf:"KINESIS_MODE"`
+	Format     string            `conf:"KINESIS_FORMAT"`
+}
+
+// PubSubConfig configures the optional export of analytics events to a Google Cloud Pub/Sub topic, in
+// the same way as KafkaConfig.
+//
+// Pub/Sub export is enabled if Topic is set. Topic can be either a full topic name such as
+// "projects/my-project/topics/my-topic", or just the last part of it if ProjectID is set. Google Cloud
+// credentials are obtained from Application Default Credentials.
+//
+// This corresponds to the [PubSub] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type PubSubConfig struct {
+	ProjectID string `conf:"PUBSUB_PROJECT_ID"`
+	Topic     string `conf:"PUBSUB_TOPIC"`
+	Mode      string `conf:"PUBSUB_MODE"`
+	Format    string `conf:"PUBSUB_FORMAT"`
+}
+
+// SnapshotsConfig configures the optional periodic upload of each environment's flag data to object
+// storage, so that there is a record of what Relay was serving at any given time. Each snapshot is an
+// archive in the same format that is used by offline mode, so it can also be used to restore an
+// environment's data.
+//
+// Snapshots are enabled if Type is set. Every Interval, Relay writes one object per environment to
+// Bucket, under the name "{Prefix}{envId}/{timestamp}.tar.gz". Older snapshots are deleted if they are
+// more than MaxAge old, or if there are more than MaxCount of them; the newest one is always kept.
+//
+// For S3, AWS credentials, and the region if S3Region is not set, are obtained in the usual ways for the
+// AWS SDK. For GCS, credentials are obtained from Application Default Credentials. For Azure, Bucket is
+// the container name and AzureSASToken must be a shared access signature that allows writing, listing,
+// and deleting blobs in it.
+//
+// This corresponds to the [Snapshots] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type SnapshotsConfig struct {
+	Type     string                   `conf:"SNAPSHOT_STORE_TYPE"`
+	Bucket   string                   `conf:"SNAPSHOT_BUCKET"`
+	Prefix   string                   `conf:"SNAPSHOT_PREFIX"`
+	Interval ct.OptDuration           `conf:"SNAPSHOT_INTERVAL"`
+	MaxAge   ct.OptDuration           `conf:"SNAPSHOT_MAX_AGE"`
+	MaxCount ct.OptIntGreaterThanZero `conf:"SNAPSHOT_MAX_COUNT"`
+
+	S3Region string            `conf:"SNAPSHOT_S3_REGION"`
+	S3URL    ct.OptURLAbsolute `conf:"SNAPSHOT_S3_URL"`
+
+	AzureAccount  string            `conf:"SNAPSHOT_AZURE_ACCOUNT"`
+	AzureSASToken string            `conf:"SNAPSHOT_AZURE_SAS_TOKEN" audit:"secret"`
+	AzureURL      ct.OptURLAbsolute `conf:"SNAPSHOT_AZURE_URL"`
+}
+
+// HealthConfig configures the checks that are done by the /healthz/ready endpoint. Each check is
+// enabled by default, and can be turned off individually.
+//
+// This corresponds to the [Health] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type HealthConfig struct {
+	DisableUpstreamCheck    bool `conf:"HEALTH_DISABLE_UPSTREAM_CHECK"`
+	DisableBigSegmentsCheck bool `conf:"HEALTH_DISABLE_BIG_SEGMENTS_CHECK"`
+	DisableEventsCheck      bool `conf:"HEALTH_DISABLE_EVENTS_CHECK"`
+}
+
+// ShadowConfig configures optional traffic shadowing, in which Relay sends a copy of some of its
+// evaluation requests to another Relay instance, such as a newer version or one that uses a different
+// big segment store, and compares that instance's responses with its own. It is only enabled if URI is
+// set. Percentage is the percentage of evaluation requests that are copied.
+//
+// This corresponds to the [Shadow] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type ShadowConfig struct {
+	URI        ct.OptURLAbsolute `conf:"SHADOW_URI"`
+	Percentage ct.OptFloat64     `conf:"SHADOW_PERCENTAGE"`
+	Timeout    ct.OptDuration    `conf:"SHADOW_TIMEOUT"`
+}
+
+// ResponseHeadersConfig contains additional HTTP headers for Relay's responses. Each header is a name and
+// value separated by a colon, such as "X-Served-By: relay-1".
+//
+// Header is added to every response from an SDK or API endpoint. PollingHeader, StreamingHeader, and
+// EventsHeader are added only to responses from those groups of endpoints, and replace any header of the
+// same name that the endpoint itself sets, so they can be used to override Cache-Control and so on.
+//
+// If SecurityHeaders is true, Relay also adds headers that tell browsers not to guess content types,
+// frame the responses, or send referrers. If HSTSMaxAge is set, it adds Strict-Transport-Security.
+//
+// This corresponds to the [ResponseHeaders] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type ResponseHeadersConfig struct {
+	Header          []string       `audit:"secret"` // special handling in LoadConfigFromEnvironment
+	PollingHeader   []string       `audit:"secret"` // special handling in LoadConfigFromEnvironment
+	StreamingHeader []string       `audit:"secret"` // special handling in LoadConfigFromEnvironment
+	EventsHeader    []string       `audit:"secret"` // special handling in LoadConfigFromEnvironment
+	SecurityHeaders bool           `conf:"SECURITY_HEADERS"`
+	HSTSMaxAge      ct.OptDuration `conf:"HSTS_MAX_AGE"`
+}
+
+// SecretsConfig configures how Relay resolves secret references in other configuration properties,
+// such as "vault://secret/ld/prod#sdkKey".
+//
+// This corresponds to the [Secrets] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type SecretsConfig struct {
+	VaultURL        ct.OptURLAbsolute `conf:"SECRETS_VAULT_URL"`
+	VaultToken      string            `conf:"SECRETS_VAULT_TOKEN" audit:"secret"`
+	RefreshInterval ct.OptDuration    `conf:"SECRETS_REFRESH_INTERVAL"`
+}
+
+// PostgresConfig configures the optional PostgreSQL integration.
+//
+// PostgreSQL is enabled if URL is set. Relay creates the tables that it needs in the database specified
+// by the URL if they do not already exist.
+//
+// This corresponds to the [Postgres] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type PostgresConfig struct {
+	URL             ct.OptURLAbsolute        `conf:"POSTGRES_URL"`
+	Password        string                   `conf:"POSTGRES_PASSWORD" audit:"secret"`
+	LocalTTL        ct.OptDuration           `conf:"CACHE_TTL"`
+	TLSMode         string                   `conf:"POSTGRES_TLS_MODE"`
+	TLSRootCertFile string                   `conf:"POSTGRES_TLS_ROOT_CERT_FILE"`
+	TLSCertFile     string                   `conf:"POSTGRES_TLS_CERT_FILE"`
+	TLSKeyFile      string                   `conf:"POSTGRES_TLS_KEY_FILE"`
+	MaxOpenConns    ct.OptIntGreaterThanZero `conf:"POSTGRES_MAX_OPEN_CONNS"`
+	MaxIdleConns    ct.OptIntGreaterThanZero `conf:"POSTGRES_MAX_IDLE_CONNS"`
+	ConnMaxLifetime ct.OptDuration           `conf:"POSTGRES_CONN_MAX_LIFETIME"`
+}
+
+// MongoDBConfig configures the optional MongoDB integration.
+//
+// MongoDB is enabled if URL is set. Each environment's data is kept in its own collections, whose names
+// begin with the environment's prefix, in the database named by Database; Relay creates them if they do
+// not already exist.
+//
+// This corresponds to the [MongoDB] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type MongoDBConfig struct {
+	URL                 ct.OptURLAbsolute `conf:"MONGODB_URL"`
+	Database            string            `conf:"MONGODB_DATABASE"`
+	Password            string            `conf:"MONGODB_PASSWORD" audit:"secret"`
+	LocalTTL            ct.OptDuration    `conf:"CACHE_TTL"`
+	WriteConcern        string            `conf:"MONGODB_WRITE_CONCERN"`
+	WriteConcernJournal bool              `conf:"MONGODB_WRITE_CONCERN_JOURNAL"`
+	WriteConcernTimeout ct.OptDuration    `conf:"MONGODB_WRITE_CONCERN_TIMEOUT"`
+	TLS                 bool              `conf:"MONGODB_TLS"`
+	TLSCAFile           string            `conf:"MONGODB_TLS_CA_FILE"`
+	TLSCertFile         string            `conf:"MONGODB_TLS_CERT_FILE"`
+	TLSKeyFile          string            `conf:"MONGODB_TLS_KEY_FILE"`
+}
+
+// EtcdConfig configures the optional etcd integration.
+//
+// etcd is enabled if Endpoints is set. Each environment's data is kept under its prefix in the etcd
+// keyspace; Relay watches that prefix for changes, so the data is not cached with a TTL.
+//
+// This corresponds to the [Etcd] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type EtcdConfig struct {
+	Endpoints   ct.OptStringList `conf:"ETCD_ENDPOINTS"`
+	Username    string           `conf:"ETCD_USERNAME"`
+	Password    string           `conf:"ETCD_PASSWORD" audit:"secret"`
+	DialTimeout ct.OptDuration   `conf:"ETCD_DIAL_TIMEOUT"`
+	TLS         bool             `conf:"ETCD_TLS"`
+	TLSCAFile   string           `conf:"ETCD_TLS_CA_FILE"`
+	TLSCertFile string           `conf:"ETCD_TLS_CERT_FILE"`
+	TLSKeyFile  string           `conf:"ETCD_TLS_KEY_FILE"`
+}
+
+// BigSegmentsConfig contains configuration parameters for big segment storage.
+//
+// If Type is empty, big segments are stored in whichever database is enabled in the Redis, Consul,
+// DynamoDB, Postgres, or MongoDB configuration; etcd does not support big segments. If it is
+// BigSegmentsStoreTypeMemory, they are held in memory and, if SnapshotDir is set, periodically saved to
+// local disk. If it is BigSegmentsStoreTypeS3, they are held in memory and loaded from S3Bucket every
+// S3PollInterval. Otherwise, it must be the name of a custom big segment store implementation that has
+// been registered by the application.
+//
+// If CircuitBreakerFailures is set, Relay stops querying the big segment store after that many
+// consecutive failures, and only tries again once CircuitBreakerRetryInterval has elapsed.
+//
+// If MaxConcurrentQueries is set, each environment makes no more than that many user membership queries
+// to the store at a time; a query that would exceed it waits for up to QueryQueueTimeout, and then fails.
+//
+// If NegativeCacheSize is set, each environment remembers up to that many users who were found not to be
+// in any big segment, for NegativeCacheTime, so that it does not query the store for them again.
+//
+// If WatchInterval is set, each environment checks the store's synchronization cursor that often, and
+// if another process has changed it, discards cached memberships and tells client-side SDKs to get new
+// flag values. In read replica mode it defaults to ReadReplica.PollInterval; otherwise it is disabled.
+//
+// This corresponds to the [BigSegments] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type BigSegmentsConfig struct {
+	Type             string                   `conf:"BIG_SEGMENTS_STORE_TYPE"`
+	SnapshotDir      string                   `conf:"BIG_SEGMENTS_SNAPSHOT_DIR"`
+	SnapshotInterval ct.OptDuration           `conf:"BIG_SEGMENTS_SNAPSHOT_INTERVAL"`
+	UserCacheSize    ct.OptIntGreaterThanZero `conf:"BIG_SEGMENTS_USER_CACHE_SIZE"`
+	UserCacheTime    ct.OptDuration           `conf:"BIG_SEGMENTS_USER_CACHE_TIME"`
+
+	S3Bucket       string         `conf:"BIG_SEGMENTS_S3_BUCKET"`
+	S3Prefix       string         `conf:"BIG_SEGMENTS_S3_PREFIX"`
+	S3PollInterval ct.OptDuration `conf:"BIG_SEGMENTS_S3_POLL_INTERVAL"`
+
+	CircuitBreakerFailures      ct.OptIntGreaterThanZero `conf:"BIG_SEGMENTS_CIRCUIT_BREAKER_FAILURES"`
+	CircuitBreakerRetryInterval ct.OptDuration           `conf:"BIG_SEGMENTS_CIRCUIT_BREAKER_RETRY_INTERVAL"`
+	CircuitBreakerFallback      string                   `conf:"BIG_SEGMENTS_CIRCUIT_BREAKER_FALLBACK"`
+
+	MaxConcurrentQueries ct.OptIntGreaterThanZero `conf:"BIG_SEGMENTS_MAX_CONCURRENT_QUERIES"`
+	QueryQueueTimeout    ct.OptDuration           `conf:"BIG_SEGMENTS_QUERY_QUEUE_TIMEOUT"`
+
+	NegativeCacheSize ct.OptIntGreaterThanZero `conf:"BIG_SEGMENTS_NEGATIVE_CACHE_SIZE"`
+	NegativeCacheTime ct.OptDuration           `conf:"BIG_SEGMENTS_NEGATIVE_CACHE_TIME"`
+
+	WatchInterval ct.OptDuration `conf:"BIG_SEGMENTS_WATCH_INTERVAL"`
 }
 
 // EnvConfig describes an environment to be relayed. There may be any number of these.
@@ -236,13 +1153,75 @@ type EnvConfig struct {
 	SDKKey        SDKKey           // set from env var LD_ENV_envname
 	MobileKey     MobileKey        `conf:"LD_MOBILE_KEY_"`
 	EnvID         EnvironmentID    `conf:"LD_CLIENT_SIDE_ID_"`
-	Prefix        string           `conf:"LD_PREFIX_"`     // used only if Redis, Consul, or DynamoDB is enabled
+	Prefix        string           `conf:"LD_PREFIX_"`     // used only if a database is enabled
 	TableName     string           `conf:"LD_TABLE_NAME_"` // used only if DynamoDB is enabled
+	Database      string           `conf:"LD_DATABASE_"`   // name of a database in Config.RedisDatabase, etc.; overrides the global database
+	Tenant        string           `conf:"LD_TENANT_"`     // name of a tenant in Config.Tenant
 	AllowedOrigin ct.OptStringList `conf:"LD_ALLOWED_ORIGIN_"`
 	AllowedHeader ct.OptStringList `conf:"LD_ALLOWED_HEADER_"`
+	CORSMaxAge    ct.OptDuration   `conf:"LD_CORS_MAX_AGE_"`
 	SecureMode    bool             `conf:"LD_SECURE_MODE_"`
 	LogLevel      OptLogLevel      `conf:"LD_LOG_LEVEL_"`
 	TTL           ct.OptDuration   `conf:"LD_TTL_"`
+
+	AllFlagsTTL          ct.OptDuration `conf:"LD_ALL_FLAGS_TTL_"`          // overrides TTL for the PHP endpoint that returns all flags
+	FlagTTL              ct.OptDuration `conf:"LD_FLAG_TTL_"`               // overrides TTL for the PHP endpoint that returns a single flag
+	SegmentTTL           ct.OptDuration `conf:"LD_SEGMENT_TTL_"`            // overrides TTL for the PHP endpoint that returns a single segment
+	StaleWhileRevalidate ct.OptDuration `conf:"LD_STALE_WHILE_REVALIDATE_"` // used only for PHP endpoints that have a TTL
+
+	SDKKeyGracePeriod ct.OptDuration `conf:"LD_SDK_KEY_GRACE_PERIOD_"` // how long an old SDK key is still accepted after the key is changed
+
+	ClientCA              string           `conf:"LD_CLIENT_CA_"`                // overrides MainConfig.TLSClientCA
+	AllowedClientCertName ct.OptStringList `conf:"LD_ALLOWED_CLIENT_CERT_NAME_"` // matched against the certificate's SANs and common name
+	AllowedClientCertOU   ct.OptStringList `conf:"LD_ALLOWED_CLIENT_CERT_OU_"`   // matched against the certificate's organizational units
+
+	JWTJWKSURL      ct.OptURLAbsolute `conf:"LD_JWT_JWKS_URL_"` // if set, client-side and mobile requests must have a JWT signed by one of these keys
+	JWTIssuer       string            `conf:"LD_JWT_ISSUER_"`
+	JWTAudience     string            `conf:"LD_JWT_AUDIENCE_"`
+	JWTUserKeyClaim string            `conf:"LD_JWT_USER_KEY_CLAIM_"` // if set, evaluations are only allowed for the user key in this claim
+
+	RateLimitServerSide ct.OptFloat64 `conf:"LD_RATE_LIMIT_SERVER_SIDE_"` // overrides RateLimitConfig.ServerSide
+	RateLimitMobile     ct.OptFloat64 `conf:"LD_RATE_LIMIT_MOBILE_"`      // overrides RateLimitConfig.Mobile
+	RateLimitClientSide ct.OptFloat64 `conf:"LD_RATE_LIMIT_CLIENT_SIDE_"` // overrides RateLimitConfig.ClientSide
+
+	MaxStreamConnections ct.OptIntGreaterThanZero `conf:"LD_MAX_STREAM_CONNECTIONS_"` // overrides StreamLimitsConfig.MaxConnectionsPerEnv
+
+	BigSegmentsUserCacheSize ct.OptIntGreaterThanZero `conf:"LD_BIG_SEGMENTS_USER_CACHE_SIZE_"` // overrides BigSegmentsConfig.UserCacheSize
+	BigSegmentsUserCacheTime ct.OptDuration           `conf:"LD_BIG_SEGMENTS_USER_CACHE_TIME_"` // overrides BigSegmentsConfig.UserCacheTime
+	BigSegmentsStaleAfter    ct.OptDuration           `conf:"LD_BIG_SEGMENTS_STALE_AFTER_"`     // overrides MainConfig.BigSegmentsStaleThreshold
+	BigSegmentsStaleMode     string                   `conf:"LD_BIG_SEGMENTS_STALE_MODE_"`
+
+	BigSegmentsMaxConcurrentQueries ct.OptIntGreaterThanZero `conf:"LD_BIG_SEGMENTS_MAX_CONCURRENT_QUERIES_"` // overrides BigSegmentsConfig.MaxConcurrentQueries
+
+	StreamInitialRetryDelay  ct.OptDuration `conf:"LD_STREAM_INITIAL_RETRY_DELAY_"`  // overrides MainConfig.StreamInitialRetryDelay
+	StreamMaxRetryDelay      ct.OptDuration `conf:"LD_STREAM_MAX_RETRY_DELAY_"`      // overrides MainConfig.StreamMaxRetryDelay
+	StreamRetryJitter        ct.OptFloat64  `conf:"LD_STREAM_RETRY_JITTER_"`         // overrides MainConfig.StreamRetryJitter
+	StreamRetryResetInterval ct.OptDuration `conf:"LD_STREAM_RETRY_RESET_INTERVAL_"` // overrides MainConfig.StreamRetryResetInterval
+
+	PatchCoalescingWindow ct.OptDuration `conf:"LD_PATCH_COALESCING_WINDOW_"` // overrides MainConfig.PatchCoalescingWindow
+	EvalCacheTTL          ct.OptDuration `conf:"LD_EVAL_CACHE_TTL_"`          // overrides MainConfig.EvalCacheTTL
+
+	EventTransformers      ct.OptStringList  `conf:"LD_EVENT_TRANSFORMERS_"`        // names of functions registered with relay.RegisterEventTransformer
+	EventTransformWebhook  ct.OptURLAbsolute `conf:"LD_EVENT_TRANSFORM_WEBHOOK_"`   // called after EventTransformers
+	EventExport            ct.OptStringList  `conf:"LD_EVENT_EXPORT_"`              // "kafka", "kinesis", "pubsub", or "none"; default is all that are configured
+	PrivateAttributes      ct.OptStringList  `conf:"LD_PRIVATE_ATTRIBUTES_"`        // user attributes that are removed from analytics events before anything else sees them
+	HashPrivateAttributes  bool              `conf:"LD_HASH_PRIVATE_ATTRIBUTES_"`   // if true, PrivateAttributes are replaced with SHA-256 hashes instead of being removed
+	EventSampleRateFeature ct.OptFloat64     `conf:"LD_EVENT_SAMPLE_RATE_FEATURE_"` // proportion of users, from 0 to 1, whose full feature events are forwarded
+	EventSampleRateCustom  ct.OptFloat64     `conf:"LD_EVENT_SAMPLE_RATE_CUSTOM_"`  // proportion of users, from 0 to 1, whose custom events are forwarded
+
+	FlagChangeWebhook       ct.OptStringList `conf:"LD_FLAG_CHANGE_WEBHOOK_"`                       // URLs that are notified when a flag or segment changes
+	FlagChangeWebhookSecret string           `conf:"LD_FLAG_CHANGE_WEBHOOK_SECRET_" audit:"secret"` // if set, notifications are signed with HMAC-SHA256
+	ChangeFeedToken         string           `conf:"LD_CHANGE_FEED_TOKEN_" audit:"secret"`          // if set, enables the /changes/{envId} stream for requests with this bearer token
+	FlagOverridesFile       string           `conf:"LD_FLAG_OVERRIDES_FILE_"`                       // JSON or YAML file of flag keys and variation indices that override LaunchDarkly's data
+	KillSwitchFile          string           `conf:"LD_KILL_SWITCH_FILE_"`                          // JSON or YAML file of flag keys and variation indices that are served while the kill switch is on
+	FlagFilterInclude       ct.OptStringList `conf:"LD_FLAG_FILTER_INCLUDE_"`                       // if set, only flags matching these keys, or key prefixes ending in "*", are stored and served
+	FlagFilterExclude       ct.OptStringList `conf:"LD_FLAG_FILTER_EXCLUDE_"`                       // flags matching these are not stored or served, even if they match FlagFilterInclude
+	ClientSideFlagInclude   ct.OptStringList `conf:"LD_CLIENT_SIDE_FLAG_INCLUDE_"`                  // if set, client-side and mobile evaluation endpoints only return flags matching these patterns
+	ClientSideFlagExclude   ct.OptStringList `conf:"LD_CLIENT_SIDE_FLAG_EXCLUDE_"`                  // flags matching these are not returned by client-side and mobile evaluation endpoints
+
+	CanaryEnvironment string `conf:"LD_CANARY_ENV_"`          // name of another environment that serves requests that have CanaryHeader
+	CanaryHeader      string `conf:"LD_CANARY_HEADER_"`       // request header that selects CanaryEnvironment
+	CanaryHeaderValue string `conf:"LD_CANARY_HEADER_VALUE_"` // if set, CanaryHeader must have this value; otherwise any value
 }
 
 // ProxyConfig represents all the supported proxy options.
@@ -251,22 +1230,28 @@ type EnvConfig struct {
 // variables, individual fields are not documented here; instead, see the `README.md` section on
 // configuration.
 type ProxyConfig struct {
-	URL         ct.OptURLAbsolute `conf:"PROXY_URL"`
-	NTLMAuth    bool              `conf:"PROXY_AUTH_NTLM"`
-	User        string            `conf:"PROXY_AUTH_USER"`
-	Password    string            `conf:"PROXY_AUTH_PASSWORD"`
-	Domain      string            `conf:"PROXY_AUTH_DOMAIN"`
-	CACertFiles ct.OptStringList  `conf:"PROXY_CA_CERTS"`
+	URL            ct.OptURLAbsolute `conf:"PROXY_URL"`
+	NTLMAuth       bool              `conf:"PROXY_AUTH_NTLM"`
+	NegotiateAuth  bool              `conf:"PROXY_AUTH_NEGOTIATE"`
+	User           string            `conf:"PROXY_AUTH_USER"`
+	Password       string            `conf:"PROXY_AUTH_PASSWORD" audit:"secret"`
+	Domain         string            `conf:"PROXY_AUTH_DOMAIN"`
+	KerberosConfig string            `conf:"PROXY_AUTH_KRB5_CONFIG"`
+	KeytabFile     string            `conf:"PROXY_AUTH_KEYTAB"`
+	CACertFiles    ct.OptStringList  `conf:"PROXY_CA_CERTS"`
+	Destination    ct.OptStringList  `conf:"PROXY_DESTINATIONS"`
+	NoProxy        ct.OptStringList  `conf:"PROXY_NO_PROXY"`
 }
 
 // MetricsConfig contains configurations for optional metrics integrations.
 //
-// This corresponds to the [Datadog], [Stackdriver], and [Prometheus] sections in the configuration file.
+// This corresponds to the [Datadog], [Stackdriver], [Prometheus], and [OpenTelemetry] sections in the configuration file.
 type MetricsConfig struct {
-	Newrelic    NewrelicConfig
-	Datadog     DatadogConfig
-	Stackdriver StackdriverConfig
-	Prometheus  PrometheusConfig
+	Newrelic      NewrelicConfig
+	Datadog       DatadogConfig
+	Stackdriver   StackdriverConfig
+	Prometheus    PrometheusConfig
+	OpenTelemetry OpenTelemetryConfig
 }
 
 // DatadogConfig configures the optional Datadog integration, which is used only if Enabled is true.
@@ -294,7 +1279,7 @@ type DatadogConfig struct {
 type NewrelicConfig struct {
 	Enabled     bool   `conf:"USE_NEWRELIC"`
 	AppName     string `conf:"NEWRELIC_APPNAME"`
-	InsightsKey string `conf:"NEWRELIC_INSIGHTS_KEY"`
+	InsightsKey string `conf:"NEWRELIC_INSIGHTS_KEY" audit:"secret"`
 	Prefix      string `conf:"NEWRELIC_PREFIX"`
 	TraceURL    string `conf:"NEWRELIC_TRACE_URL"`
 	MetricsURL  string `conf:"NEWRELIC_METRICS_URL"`
@@ -326,3 +1311,19 @@ type PrometheusConfig struct {
 	Prefix  string                   `conf:"PROMETHEUS_PREFIX"`
 	Port    ct.OptIntGreaterThanZero `conf:"PROMETHEUS_PORT"`
 }
+
+// OpenTelemetryConfig configures the optional OpenTelemetry integration, which exports traces to an
+// OTLP endpoint and is used only if Enabled is true.
+//
+// This corresponds to the [OpenTelemetry] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type OpenTelemetryConfig struct {
+	Enabled       bool              `conf:"USE_OPENTELEMETRY"`
+	Endpoint      ct.OptURLAbsolute `conf:"OPENTELEMETRY_ENDPOINT"`
+	Header        ct.OptStringList  `conf:"OPENTELEMETRY_HEADERS" audit:"secret"`
+	ServiceName   string            `conf:"OPENTELEMETRY_SERVICE_NAME"`
+	SamplingRatio ct.OptFloat64     `conf:"OPENTELEMETRY_SAMPLING_RATIO"`
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+)
+
+func errUnknownDatabase(name string) error {
+	return fmt.Errorf("database %q is not defined", name)
+}
+
+// EnvironmentDatabaseConfig returns a copy of the configuration in which the Redis, Consul, DynamoDB,
+// Postgres, MongoDB, and Etcd sections describe the database that an environment should use. If the
+// environment selects a named database with EnvConfig.Database, that database replaces whichever one was
+// enabled in those sections; otherwise the configuration is returned unchanged.
+//
+// Components that connect to a database on behalf of an environment, such as the SDK data store and the
+// big segment store, should be given this copy rather than the original configuration. It returns an
+// error if there is no named database with that name; ValidateConfig also checks for this.
+func EnvironmentDatabaseConfig(c Config, ec EnvConfig) (Config, error) {
+	if ec.Database == "" {
+		return c, nil
+	}
+	ret := c
+	ret.Redis, ret.Consul, ret.DynamoDB, ret.Postgres = RedisConfig{}, ConsulConfig{}, DynamoDBConfig{}, PostgresConfig{}
+	ret.MongoDB, ret.Etcd = MongoDBConfig{}, EtcdConfig{}
+	if rc := c.RedisDatabase[ec.Database]; rc != nil {
+		ret.Redis = *rc
+	} else if cc := c.ConsulDatabase[ec.Database]; cc != nil {
+		ret.Consul = *cc
+	} else if dc := c.DynamoDBDatabase[ec.Database]; dc != nil {
+		ret.DynamoDB = *dc
+		ret.DynamoDB.Enabled = true
+	} else if pc := c.PostgresDatabase[ec.Database]; pc != nil {
+		ret.Postgres = *pc
+	} else if mc := c.MongoDBDatabase[ec.Database]; mc != nil {
+		ret.MongoDB = *mc
+	} else if tc := c.EtcdDatabase[ec.Database]; tc != nil {
+		ret.Etcd = *tc
+	} else {
+		return c, errUnknownDatabase(ec.Database)
+	}
+	return ret, nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"testing"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironmentDatabaseConfig(t *testing.T) {
+	c := Config{
+		Redis:            RedisConfig{URL: newOptURLAbsoluteMustBeValid("redis://globalhost:6379")},
+		RedisDatabase:    map[string]*RedisConfig{"r": {URL: newOptURLAbsoluteMustBeValid("redis://tenanthost:6379")}},
+		ConsulDatabase:   map[string]*ConsulConfig{"c": {Host: "consulhost"}},
+		DynamoDBDatabase: map[string]*DynamoDBConfig{"d": {TableName: "flags"}},
+		PostgresDatabase: map[string]*PostgresConfig{"p": {URL: newOptURLAbsoluteMustBeValid("postgres://pghost:5432")}},
+		MongoDBDatabase:  map[string]*MongoDBConfig{"m": {URL: newOptURLAbsoluteMustBeValid("mongodb://mongohost:27017")}},
+		EtcdDatabase:     map[string]*EtcdConfig{"e": {Endpoints: ct.NewOptStringList([]string{"etcdhost:2379"})}},
+		Events:           EventsConfig{Coordinate: true},
+	}
+
+	t.Run("environment without database uses global database", func(t *testing.T) {
+		result, err := EnvironmentDatabaseConfig(c, EnvConfig{})
+		require.NoError(t, err)
+		assert.Equal(t, c, result)
+	})
+
+	t.Run("named Redis database replaces global database", func(t *testing.T) {
+		result, err := EnvironmentDatabaseConfig(c, EnvConfig{Database: "r"})
+		require.NoError(t, err)
+		assert.Equal(t, *c.RedisDatabase["r"], result.Redis)
+		assert.Equal(t, c.Events, result.Events)
+	})
+
+	t.Run("named Consul database replaces global database", func(t *testing.T) {
+		result, err := EnvironmentDatabaseConfig(c, EnvConfig{Database: "c"})
+		require.NoError(t, err)
+		assert.Equal(t, RedisConfig{}, result.Redis)
+		assert.Equal(t, "consulhost", result.Consul.Host)
+	})
+
+	t.Run("named DynamoDB database replaces global database and is enabled", func(t *testing.T) {
+		result, err := EnvironmentDatabaseConfig(c, EnvConfig{Database: "d"})
+		require.NoError(t, err)
+		assert.Equal(t, RedisConfig{}, result.Redis)
+		assert.Equal(t, DynamoDBConfig{Enabled: true, TableName: "flags"}, result.DynamoDB)
+		assert.False(t, c.DynamoDBDatabase["d"].Enabled) // the original is not modified
+	})
+
+	t.Run("named Postgres database replaces global database", func(t *testing.T) {
+		result, err := EnvironmentDatabaseConfig(c, EnvConfig{Database: "p"})
+		require.NoError(t, err)
+		assert.Equal(t, RedisConfig{}, result.Redis)
+		assert.Equal(t, *c.PostgresDatabase["p"], result.Postgres)
+	})
+
+	t.Run("named MongoDB database replaces global database", func(t *testing.T) {
+		result, err := EnvironmentDatabaseConfig(c, EnvConfig{Database: "m"})
+		require.NoError(t, err)
+		assert.Equal(t, RedisConfig{}, result.Redis)
+		assert.Equal(t, *c.MongoDBDatabase["m"], result.MongoDB)
+	})
+
+	t.Run("named etcd database replaces global database", func(t *testing.T) {
+		result, err := EnvironmentDatabaseConfig(c, EnvConfig{Database: "e"})
+		require.NoError(t, err)
+		assert.Equal(t, RedisConfig{}, result.Redis)
+		assert.Equal(t, *c.EtcdDatabase["e"], result.Etcd)
+	})
+
+	t.Run("unknown database is an error", func(t *testing.T) {
+		_, err := EnvironmentDatabaseConfig(c, EnvConfig{Database: "x"})
+		assert.Equal(t, errUnknownDatabase("x"), err)
+	})
+}
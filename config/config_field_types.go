@@ -3,6 +3,10 @@ package config
 import (
 	"crypto/tls"
 	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
@@ -12,10 +16,18 @@ func errBadLogLevel(s string) error {
 	return fmt.Errorf("%q is not a valid log level", s)
 }
 
+func errBadLogFormat(s string) error {
+	return fmt.Errorf("%q is not a valid log format", s)
+}
+
 func errBadTLSVersion(s string) error {
 	return fmt.Errorf("%q is not a valid TLS version", s)
 }
 
+func errBadFileMode(s string) error {
+	return fmt.Errorf("%q is not a valid file mode; must be octal permissions such as 0660", s)
+}
+
 // SDKKey is a type tag to indicate when a string is used as a server-side SDK key for a LaunchDarkly
 // environment.
 type SDKKey string
@@ -137,6 +149,38 @@ func (o *OptLogLevel) UnmarshalText(data []byte) error {
 	return err
 }
 
+// MarshalText converts the value to the name of the log level, or to an empty string if there is no value.
+func (o OptLogLevel) MarshalText() ([]byte, error) {
+	if o.level == 0 {
+		return []byte(""), nil
+	}
+	return []byte(strings.ToLower(o.level.Name())), nil
+}
+
+// LogFormat is the format of Relay's log output. It must be "text", "json", or an empty string, which
+// is the same as "text".
+type LogFormat string
+
+const (
+	// LogFormatText is the default log format, with one line of plain text per message.
+	LogFormatText LogFormat = "text"
+
+	// LogFormatJSON is a log format with one JSON object per message; see logging.MakeJSONLoggers.
+	LogFormatJSON LogFormat = "json"
+)
+
+// UnmarshalText parses the value from a byte string, and returns an error if it is not a supported
+// log format.
+func (f *LogFormat) UnmarshalText(data []byte) error {
+	switch value := LogFormat(strings.ToLower(string(data))); value {
+	case "", LogFormatText, LogFormatJSON:
+		*f = value
+		return nil
+	default:
+		return errBadLogFormat(string(data))
+	}
+}
+
 // OptTLSVersion represents an optional TLS level parameter. When represented as a string, it must be
 // "1.0", "1.1", "1.2", or "1.3". This is converted into a uint16 value as defined by crypto/tls.
 type OptTLSVersion struct {
@@ -205,3 +249,102 @@ func (o OptTLSVersion) String() string {
 		return fmt.Sprintf("unknown (%d)", o.value)
 	}
 }
+
+// OptFileMode represents an optional file permissions parameter. When represented as a string, it must be
+// an octal number no greater than 0777, such as "0660". A value of zero is equivalent to undefined.
+type OptFileMode struct {
+	value os.FileMode
+}
+
+// NewOptFileMode creates an OptFileMode that wraps the permission bits of the given value.
+func NewOptFileMode(value os.FileMode) OptFileMode {
+	return OptFileMode{value & os.ModePerm}
+}
+
+// NewOptFileModeFromString creates an OptFileMode from an octal string, or an empty string.
+func NewOptFileModeFromString(s string) (OptFileMode, error) {
+	if s == "" {
+		return OptFileMode{}, nil
+	}
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > uint64(os.ModePerm) {
+		return OptFileMode{}, errBadFileMode(s)
+	}
+	return NewOptFileMode(os.FileMode(n)), nil
+}
+
+// IsDefined returns true if the instance contains a value.
+func (o OptFileMode) IsDefined() bool {
+	return o.value != 0
+}
+
+// Get returns the wrapped value, or zero if there is no value.
+func (o OptFileMode) Get() os.FileMode {
+	return o.value
+}
+
+// UnmarshalText attempts to parse the value from a byte string, using the same logic as
+// NewOptFileModeFromString.
+func (o *OptFileMode) UnmarshalText(data []byte) error {
+	opt, err := NewOptFileModeFromString(string(data))
+	if err == nil {
+		*o = opt
+	}
+	return err
+}
+
+// String returns the value as an octal string, or an empty string if there is no value.
+func (o OptFileMode) String() string {
+	if o.value == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%04o", uint32(o.value))
+}
+
+// ParseResponseHeaders converts a list of headers in the format used by ResponseHeadersConfig, such as
+// "X-Served-By: relay-1", to an http.Header. It returns an error if any item is not in that format.
+func ParseResponseHeaders(items []string) (http.Header, error) {
+	var ret http.Header
+	for _, item := range items {
+		i := strings.Index(item, ":")
+		if i < 0 {
+			return nil, errResponseHeaderInvalid
+		}
+		name, value := strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+		if name == "" || strings.ContainsAny(name, " \t\"(),/;<=>?@[\\]{}") {
+			return nil, errResponseHeaderInvalid
+		}
+		if ret == nil {
+			ret = make(http.Header)
+		}
+		ret.Add(name, value)
+	}
+	return ret, nil
+}
+
+// ProxyDestination is a rule from ProxyConfig.Destination: requests for hosts that match Host are sent
+// through the proxy at URL.
+type ProxyDestination struct {
+	Host string
+	URL  *url.URL
+}
+
+// ParseProxyDestinations converts a list of rules in the format used by ProxyConfig.Destination, such
+// as "events.launchdarkly.com=http://events-proxy:8080", to ProxyDestinations. It returns an error if
+// any item is not in that format.
+func ParseProxyDestinations(items []string) ([]ProxyDestination, error) {
+	var ret []ProxyDestination
+	for _, item := range items {
+		i := strings.Index(item, "=")
+		if i < 0 {
+			return nil, errProxyDestinationInvalid(item)
+		}
+		host, rawURL := strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+		u, err := url.Parse(rawURL)
+		if host == "" || err != nil || !u.IsAbs() || u.Host == "" {
+			return nil, errProxyDestinationInvalid(item)
+		}
+		ret = append(ret, ProxyDestination{Host: host, URL: u})
+	}
+	return ret, nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"crypto/tls"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -45,6 +46,35 @@ func TestOptLogLevel(t *testing.T) {
 		assert.Equal(t, errBadLogLevel(badString), err)
 		assert.Equal(t, OptLogLevel{}, o)
 	})
+
+	t.Run("marshal", func(t *testing.T) {
+		data, err := NewOptLogLevel(validLevel).MarshalText()
+		assert.NoError(t, err)
+		assert.Equal(t, "warn", string(data))
+
+		data, err = OptLogLevel{}.MarshalText()
+		assert.NoError(t, err)
+		assert.Equal(t, "", string(data))
+	})
+}
+
+func TestLogFormat(t *testing.T) {
+	for _, val := range []struct {
+		s string
+		f LogFormat
+	}{{"", ""}, {"text", LogFormatText}, {"JSON", LogFormatJSON}} {
+		t.Run(val.s, func(t *testing.T) {
+			var f LogFormat
+			assert.NoError(t, f.UnmarshalText([]byte(val.s)))
+			assert.Equal(t, val.f, f)
+		})
+	}
+
+	t.Run("invalid string", func(t *testing.T) {
+		f := LogFormatJSON
+		assert.Equal(t, errBadLogFormat("xml"), f.UnmarshalText([]byte("xml")))
+		assert.Equal(t, LogFormatJSON, f)
+	})
 }
 
 func TestOptTLSVersion(t *testing.T) {
@@ -91,3 +121,36 @@ func TestOptTLSVersion(t *testing.T) {
 		assert.Equal(t, "unknown (9999)", NewOptTLSVersion(9999).String())
 	})
 }
+
+func TestOptFileMode(t *testing.T) {
+	t.Run("zero value", func(t *testing.T) {
+		o := OptFileMode{}
+		assert.False(t, o.IsDefined())
+		assert.Equal(t, os.FileMode(0), o.Get())
+		assert.Equal(t, "", o.String())
+	})
+
+	t.Run("new from valid string", func(t *testing.T) {
+		for _, s := range []string{"0660", "660"} {
+			o, err := NewOptFileModeFromString(s)
+			assert.NoError(t, err)
+			assert.True(t, o.IsDefined())
+			assert.Equal(t, os.FileMode(0660), o.Get())
+			assert.Equal(t, "0660", o.String())
+		}
+	})
+
+	t.Run("new from empty string", func(t *testing.T) {
+		o, err := NewOptFileModeFromString("")
+		assert.NoError(t, err)
+		assert.Equal(t, OptFileMode{}, o)
+	})
+
+	t.Run("new from invalid string", func(t *testing.T) {
+		for _, s := range []string{"x", "0800", "1777", "-1"} {
+			o, err := NewOptFileModeFromString(s)
+			assert.Equal(t, errBadFileMode(s), err)
+			assert.Equal(t, OptFileMode{}, o)
+		}
+	})
+}
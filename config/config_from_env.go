@@ -11,8 +11,14 @@ import (
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 )
 
+const (
+	structuredConfigJSONVar = "RELAY_CONFIG_JSON"
+	structuredConfigYAMLVar = "RELAY_CONFIG_YAML"
+)
+
 var (
-	errObsoleteVariable = errors.New("this variable is no longer supported")
+	errObsoleteVariable             = errors.New("this variable is no longer supported")
+	errStructuredConfigVarsConflict = errors.New("cannot be used together with " + structuredConfigJSONVar)
 )
 
 func errObsoleteVariableWithReplacement(preferredName string) error {
@@ -37,8 +43,12 @@ func LoadConfigFromEnvironment(c *Config, loggers ldlog.Loggers) error {
 func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.ValidationResult {
 	reader := ct.NewVarReaderFromEnvironment()
 
+	readStructuredConfigFromEnvironment(c, reader)
+
 	reader.ReadStruct(&c.Main, false)
 
+	reader.ReadStruct(&c.ACME, false)
+
 	reader.ReadStruct(&c.AutoConfig, false)
 
 	reader.ReadStruct(&c.OfflineMode, false)
@@ -58,6 +68,10 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 		c.AutoConfig.EnvDatastoreTableName = ""
 	}
 
+	reader.ReadStruct(&c.ReadReplica, false)
+	reader.ReadStruct(&c.LeaderElection, false)
+	reader.ReadStruct(&c.Replication, false)
+
 	reader.ReadStruct(&c.Events, false)
 	rejectObsoleteVariableName("EVENTS_SAMPLING_INTERVAL", "", reader)
 
@@ -78,7 +92,12 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 
 	useRedis := false
 	reader.Read("USE_REDIS", &useRedis)
-	if useRedis || c.Redis.Host != "" || c.Redis.URL.IsDefined() {
+	var clusterAddrs, sentinelAddrs ct.OptStringList // read again by ReadStruct below
+	reader.Read("REDIS_CLUSTER_ADDRS", &clusterAddrs)
+	reader.Read("REDIS_SENTINEL_ADDRS", &sentinelAddrs)
+	useRedisCluster := clusterAddrs.IsDefined() || sentinelAddrs.IsDefined() ||
+		c.Redis.ClusterAddrs.IsDefined() || c.Redis.SentinelAddrs.IsDefined()
+	if useRedis || useRedisCluster || c.Redis.Host != "" || c.Redis.URL.IsDefined() {
 		portStr := ""
 		if c.Redis.Port.IsDefined() {
 			portStr = fmt.Sprintf("%d", c.Redis.Port.GetOrElse(0))
@@ -104,7 +123,7 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 				reader.Read("REDIS_PORT", &c.Redis.Port)
 			}
 		}
-		if !c.Redis.URL.IsDefined() && c.Redis.Host == "" && !c.Redis.Port.IsDefined() {
+		if !c.Redis.URL.IsDefined() && c.Redis.Host == "" && !c.Redis.Port.IsDefined() && !useRedisCluster {
 			// all they specified was USE_REDIS
 			c.Redis.URL = defaultRedisURL
 		}
@@ -123,6 +142,56 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 		reader.ReadStruct(&c.DynamoDB, false)
 	}
 
+	usePostgres := false
+	reader.Read("USE_POSTGRES", &usePostgres)
+	if usePostgres {
+		c.Postgres.URL = defaultPostgresURL
+		reader.ReadStruct(&c.Postgres, false)
+	}
+
+	useMongoDB := false
+	reader.Read("USE_MONGODB", &useMongoDB)
+	if useMongoDB {
+		c.MongoDB.URL = defaultMongoDBURL
+		reader.ReadStruct(&c.MongoDB, false)
+	}
+
+	useEtcd := false
+	reader.Read("USE_ETCD", &useEtcd)
+	if useEtcd {
+		reader.ReadStruct(&c.Etcd, false)
+		if len(c.Etcd.Endpoints.Values()) == 0 { // a list variable adds to any existing values, so set the default afterward
+			c.Etcd.Endpoints = ct.NewOptStringList([]string{defaultEtcdHost})
+		}
+	}
+
+	readNamedDatabasesFromEnvironment(c, reader)
+	readTenantsFromEnvironment(c, reader)
+
+	reader.ReadStruct(&c.BigSegments, false)
+
+	reader.ReadStruct(&c.Secrets, false)
+
+	reader.ReadStruct(&c.Admin, false)
+
+	reader.ReadStruct(&c.RateLimit, false)
+	reader.ReadStruct(&c.StreamLimits, false)
+	reader.ReadStruct(&c.MemoryLimits, false)
+	reader.ReadStruct(&c.Maintenance, false)
+
+	reader.ReadStruct(&c.InternalMetrics, false)
+
+	reader.ReadStruct(&c.AccessLog, false)
+	reader.ReadStruct(&c.AuditLog, false)
+
+	reader.ReadStruct(&c.Kafka, false)
+	reader.ReadStruct(&c.Kinesis, false)
+	reader.ReadStruct(&c.PubSub, false)
+	reader.ReadStruct(&c.Snapshots, false)
+	reader.ReadStruct(&c.Health, false)
+	reader.ReadStruct(&c.Shadow, false)
+	readResponseHeadersFromEnvironment(c, reader)
+
 	reader.ReadStruct(&c.MetricsConfig.Datadog, false)
 	if c.MetricsConfig.Datadog.Enabled {
 		for tagName, tagVal := range reader.FindPrefixedValues("DATADOG_TAG_") {
@@ -134,12 +203,32 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 
 	reader.ReadStruct(&c.MetricsConfig.Stackdriver, false)
 	reader.ReadStruct(&c.MetricsConfig.Prometheus, false)
+	reader.ReadStruct(&c.MetricsConfig.OpenTelemetry, false)
 
 	reader.ReadStruct(&c.Proxy, false)
 
 	return reader.Result()
 }
 
+// readStructuredConfigFromEnvironment applies a complete configuration from RELAY_CONFIG_JSON or
+// RELAY_CONFIG_YAML, which has the same schema as a JSON or YAML configuration file. This is done before
+// reading any of the other variables, so that they can still override individual properties.
+func readStructuredConfigFromEnvironment(c *Config, reader *ct.VarReader) {
+	jsonData, yamlData := os.Getenv(structuredConfigJSONVar), os.Getenv(structuredConfigYAMLVar)
+	switch {
+	case jsonData != "" && yamlData != "":
+		reader.AddError(ct.ValidationPath{structuredConfigYAMLVar}, errStructuredConfigVarsConflict)
+	case jsonData != "":
+		if err := applyStructuredConfigData(c, []byte(jsonData), true); err != nil {
+			reader.AddError(ct.ValidationPath{structuredConfigJSONVar}, err)
+		}
+	case yamlData != "":
+		if err := applyStructuredConfigData(c, []byte(yamlData), false); err != nil {
+			reader.AddError(ct.ValidationPath{structuredConfigYAMLVar}, err)
+		}
+	}
+}
+
 func rejectObsoleteVariableName(oldName, preferredName string, reader *ct.VarReader) {
 	// Unrecognized environment variables are normally ignored, but if someone has set a variable that
 	// used to be used in configuration and is no longer used, we want to raise an error rather than just
@@ -152,3 +241,129 @@ func rejectObsoleteVariableName(oldName, preferredName string, reader *ct.VarRea
 		}
 	}
 }
+
+// readNamedDatabasesFromEnvironment reads the named databases that environments can select with
+// LD_DATABASE_envname. A named database is defined by a variable with the database name as a suffix, such
+// as REDIS_URL_name or USE_DYNAMODB_name, and its other variables use the same suffix.
+func readNamedDatabasesFromEnvironment(c *Config, reader *ct.VarReader) {
+	for name := range findNamedDatabaseVars(reader, "REDIS_URL_", "REDIS_CLUSTER_ADDRS_", "REDIS_SENTINEL_ADDRS_") {
+		rc := c.RedisDatabase[name]
+		if rc == nil {
+			rc = &RedisConfig{}
+		}
+		reader.WithVarNameSuffix("_"+name).ReadStruct(rc, false)
+		if c.RedisDatabase == nil {
+			c.RedisDatabase = make(map[string]*RedisConfig)
+		}
+		c.RedisDatabase[name] = rc
+	}
+	for name := range findNamedDatabaseVars(reader, "CONSUL_HOST_") {
+		cc := c.ConsulDatabase[name]
+		if cc == nil {
+			cc = &ConsulConfig{}
+		}
+		reader.WithVarNameSuffix("_"+name).ReadStruct(cc, false)
+		if c.ConsulDatabase == nil {
+			c.ConsulDatabase = make(map[string]*ConsulConfig)
+		}
+		c.ConsulDatabase[name] = cc
+	}
+	for name := range findNamedDatabaseVars(reader, "USE_DYNAMODB_") {
+		dc := c.DynamoDBDatabase[name]
+		if dc == nil {
+			dc = &DynamoDBConfig{}
+		}
+		reader.WithVarNameSuffix("_"+name).ReadStruct(dc, false)
+		if !dc.Enabled {
+			continue
+		}
+		if c.DynamoDBDatabase == nil {
+			c.DynamoDBDatabase = make(map[string]*DynamoDBConfig)
+		}
+		c.DynamoDBDatabase[name] = dc
+	}
+	for name := range findNamedDatabaseVars(reader, "POSTGRES_URL_") {
+		pc := c.PostgresDatabase[name]
+		if pc == nil {
+			pc = &PostgresConfig{}
+		}
+		reader.WithVarNameSuffix("_"+name).ReadStruct(pc, false)
+		if c.PostgresDatabase == nil {
+			c.PostgresDatabase = make(map[string]*PostgresConfig)
+		}
+		c.PostgresDatabase[name] = pc
+	}
+	for name := range findNamedDatabaseVars(reader, "MONGODB_URL_") {
+		mc := c.MongoDBDatabase[name]
+		if mc == nil {
+			mc = &MongoDBConfig{}
+		}
+		reader.WithVarNameSuffix("_"+name).ReadStruct(mc, false)
+		if c.MongoDBDatabase == nil {
+			c.MongoDBDatabase = make(map[string]*MongoDBConfig)
+		}
+		c.MongoDBDatabase[name] = mc
+	}
+	for name := range findNamedDatabaseVars(reader, "ETCD_ENDPOINTS_") {
+		tc := c.EtcdDatabase[name]
+		if tc == nil {
+			tc = &EtcdConfig{}
+		}
+		reader.WithVarNameSuffix("_"+name).ReadStruct(tc, false)
+		if c.EtcdDatabase == nil {
+			c.EtcdDatabase = make(map[string]*EtcdConfig)
+		}
+		c.EtcdDatabase[name] = tc
+	}
+}
+
+// readTenantsFromEnvironment reads the tenants that environments can be assigned to with LD_TENANT_envname.
+// A tenant is defined by TENANT_PORT_name or TENANT_HOST_name.
+func readTenantsFromEnvironment(c *Config, reader *ct.VarReader) {
+	for name := range findNamedDatabaseVars(reader, "TENANT_PORT_", "TENANT_HOST_") {
+		tc := c.Tenant[name]
+		if tc == nil {
+			tc = &TenantConfig{}
+		}
+		reader.WithVarNameSuffix("_"+name).ReadStruct(tc, false)
+		if c.Tenant == nil {
+			c.Tenant = make(map[string]*TenantConfig)
+		}
+		c.Tenant[name] = tc
+	}
+}
+
+func findNamedDatabaseVars(reader *ct.VarReader, prefixes ...string) map[string]bool {
+	names := make(map[string]bool)
+	for _, prefix := range prefixes {
+		for name := range reader.FindPrefixedValues(prefix) {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// readResponseHeadersFromEnvironment reads variables like RESPONSE_HEADER_X_Served_By=relay-1, in which
+// underscores in the header name stand for hyphens, since they are not allowed in many shells.
+func readResponseHeadersFromEnvironment(c *Config, reader *ct.VarReader) {
+	reader.ReadStruct(&c.ResponseHeaders, false)
+	for _, p := range []struct {
+		prefix string
+		target *[]string
+	}{
+		{"RESPONSE_HEADER_", &c.ResponseHeaders.Header},
+		{"POLLING_RESPONSE_HEADER_", &c.ResponseHeaders.PollingHeader},
+		{"STREAMING_RESPONSE_HEADER_", &c.ResponseHeaders.StreamingHeader},
+		{"EVENTS_RESPONSE_HEADER_", &c.ResponseHeaders.EventsHeader},
+	} {
+		values := reader.FindPrefixedValues(p.prefix)
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names) // for test determinacy
+		for _, name := range names {
+			*p.target = append(*p.target, strings.ReplaceAll(name, "_", "-")+": "+values[name])
+		}
+	}
+}
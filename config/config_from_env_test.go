@@ -32,6 +32,60 @@ func TestConfigFromEnvironmentWithInvalidProperties(t *testing.T) {
 	}
 }
 
+func TestConfigFromEnvironmentWithStructuredConfigVar(t *testing.T) {
+	// Every valid configuration file in our test data should produce the same result if it is translated
+	// into JSON or YAML and passed in a single variable.
+	for _, tdc := range makeValidConfigs() {
+		if tdc.fileContent == "" {
+			continue
+		}
+		content := translateINIConfigToJSON(t, tdc.fileContent)
+		for _, varName := range []string{structuredConfigJSONVar, structuredConfigYAMLVar} {
+			t.Run(tdc.name+" "+varName, func(t *testing.T) {
+				testValidConfigVars(t, testDataValidConfig{
+					makeConfig: tdc.makeConfig,
+					envVars:    map[string]string{varName: content},
+					warnings:   tdc.warnings,
+				})
+			})
+		}
+	}
+
+	t.Run("other variables override properties in structured config", func(t *testing.T) {
+		testValidConfigVars(t, testDataValidConfig{
+			makeConfig: func(c *Config) {
+				c.Main.Port = mustOptIntGreaterThanZero(9000)
+				c.Environment = map[string]*EnvConfig{
+					"earth": {SDKKey: "new-key", Prefix: "p"},
+				}
+			},
+			envVars: map[string]string{
+				structuredConfigYAMLVar: "main:\n  port: 8333\nenvironment:\n  earth:\n    sdkKey: old-key\n    prefix: p\n",
+				"PORT":                  "9000",
+				"LD_ENV_earth":          "new-key",
+			},
+		})
+	})
+
+	t.Run("rejects invalid structured config", func(t *testing.T) {
+		testInvalidConfigVars(t,
+			map[string]string{structuredConfigJSONVar: `{"Main": {"Port": "x"}}`},
+			`RELAY_CONFIG_JSON: invalid value for section "Main", variable "Port": not a valid integer`,
+		)
+		testInvalidConfigVars(t,
+			map[string]string{structuredConfigYAMLVar: "Unknown: {}\n"},
+			`RELAY_CONFIG_YAML: unsupported or misspelled section "Unknown"`,
+		)
+	})
+
+	t.Run("rejects both JSON and YAML variables", func(t *testing.T) {
+		testInvalidConfigVars(t,
+			map[string]string{structuredConfigJSONVar: "{}", structuredConfigYAMLVar: "{}"},
+			"RELAY_CONFIG_YAML: cannot be used together with RELAY_CONFIG_JSON",
+		)
+	})
+}
+
 func TestConfigFromEnvironmentOverridesExistingSettings(t *testing.T) {
 	t.Run("can add SDK key to existing environment", func(t *testing.T) {
 		var startingConfig Config
@@ -227,6 +281,13 @@ func TestConfigFromEnvironmentFieldValidation(t *testing.T) {
 			`LOG_LEVEL: "wrong" is not a valid log level`,
 		)
 	})
+
+	t.Run("rejects invalid log format", func(t *testing.T) {
+		testInvalidConfigVars(t,
+			map[string]string{"LOG_FORMAT": "xml"},
+			`LOG_FORMAT: "xml" is not a valid log format`,
+		)
+	})
 }
 
 func testValidConfigVars(t *testing.T, tdc testDataValidConfig) { //} buildConfig func(c *Config), vars map[string]string) {
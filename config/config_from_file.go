@@ -16,9 +16,16 @@ func errLoadingConfigFile(path string, err error) error {
 
 // LoadConfigFile reads a configuration file into a Config struct and performs basic validation.
 //
+// The file is normally in an INI-like format, but if its name ends in ".yaml", ".yml", or ".json", it
+// is read as YAML or JSON instead, with the same sections and properties.
+//
 // The Config parameter should be initialized with default values first.
 func LoadConfigFile(c *Config, path string, loggers ldlog.Loggers) error {
-	if err := gcfg.ReadFileInto(c, path); err != nil {
+	if isStructuredConfigFile(path) {
+		if err := readStructuredConfigFile(c, path); err != nil {
+			return errLoadingConfigFile(path, err)
+		}
+	} else if err := gcfg.ReadFileInto(c, path); err != nil {
 		return errLoadingConfigFile(path, FilterGcfgError(err))
 	}
 
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// This file implements reading the configuration from a YAML or JSON file instead of the usual INI-style
+// file. The schema is the same: the top-level properties are the section names, and the properties
+// within each section are the same as the variable names in the INI format, except that sections with
+// names, such as Environment, are maps of names to properties. Names are case-insensitive, as they are in
+// the INI format.
+//
+// Values can be given either as strings, which are parsed exactly as they would be in an INI file, or as
+// the equivalent YAML/JSON type for booleans, numbers, and lists.
+
+var (
+	errNotObject      = errors.New("configuration must contain a set of named sections")
+	errListNotAllowed = errors.New("a list of values is not allowed for this property")
+)
+
+func errUnknownSection(section string) error {
+	return fmt.Errorf("unsupported or misspelled section %q", section)
+}
+
+func errUnknownVariable(section, name string) error {
+	return fmt.Errorf("unsupported or misspelled section %q, variable %q", section, name)
+}
+
+func errSectionNotObject(section string) error {
+	return fmt.Errorf("section %q must contain a set of named properties", section)
+}
+
+func errInvalidVariable(section, name string, err error) error {
+	return fmt.Errorf("invalid value for section %q, variable %q: %w", section, name, err)
+}
+
+func errUnsupportedValueType(value interface{}) error {
+	return fmt.Errorf("unsupported value type %T", value)
+}
+
+func errInvalidBool(value string) error {
+	return fmt.Errorf("failed to parse bool `%s`", value)
+}
+
+// isStructuredConfigFile returns true if the file extension indicates a YAML or JSON file.
+func isStructuredConfigFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+func readStructuredConfigFile(c *Config, path string) error {
+	data, err := ioutil.ReadFile(path) //nolint:gosec // the file path is provided by the administrator
+	if err != nil {
+		return err
+	}
+	return applyStructuredConfigData(c, data, strings.ToLower(filepath.Ext(path)) == ".json")
+}
+
+// applyStructuredConfigData parses a configuration in JSON (if isJSON is true) or YAML format, and
+// applies it to the Config. This is used both for files and for the RELAY_CONFIG_JSON and
+// RELAY_CONFIG_YAML environment variables.
+func applyStructuredConfigData(c *Config, data []byte, isJSON bool) error {
+	var parsed interface{}
+	var err error
+	if isJSON {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&parsed)
+	} else {
+		err = yaml.Unmarshal(data, &parsed)
+	}
+	if err != nil {
+		return err
+	}
+	if parsed == nil {
+		return nil // an empty document is equivalent to an empty configuration
+	}
+	return applyStructuredConfig(c, parsed)
+}
+
+func applyStructuredConfig(c *Config, parsed interface{}) error {
+	sections, ok := asStringMap(parsed)
+	if !ok {
+		return errNotObject
+	}
+	configValue := reflect.ValueOf(c).Elem()
+	for _, sectionName := range sortedKeys(sections) {
+		sectionData := sections[sectionName]
+		section, ok := findField(configValue, sectionName)
+		if ok && isNamedSectionMap(section) {
+			if err := applyStructuredNamedSections(section, sectionName, sectionData); err != nil {
+				return err
+			}
+			continue
+		}
+		if !ok || section.Kind() != reflect.Struct {
+			return errUnknownSection(sectionName)
+		}
+		if err := applyStructuredSection(section, sectionName, sectionData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyStructuredNamedSections handles a map of named sections, such as Config.Environment. As in the INI
+// format, properties for a name that already exists are applied to the existing section.
+func applyStructuredNamedSections(sectionMap reflect.Value, sectionName string, data interface{}) error {
+	named, ok := asStringMap(data)
+	if !ok {
+		return errSectionNotObject(sectionName)
+	}
+	if sectionMap.IsNil() {
+		sectionMap.Set(reflect.MakeMap(sectionMap.Type()))
+	}
+	for _, name := range sortedKeys(named) {
+		section := reflect.New(sectionMap.Type().Elem().Elem())
+		if existing := sectionMap.MapIndex(reflect.ValueOf(name)); existing.IsValid() && !existing.IsNil() {
+			section.Elem().Set(existing.Elem())
+		}
+		if err := applyStructuredSection(section.Elem(), fmt.Sprintf("%s %q", sectionName, name), named[name]); err != nil {
+			return err
+		}
+		sectionMap.SetMapIndex(reflect.ValueOf(name), section)
+	}
+	return nil
+}
+
+func applyStructuredSection(section reflect.Value, sectionName string, data interface{}) error {
+	if data == nil {
+		return nil // e.g. "Main:" with nothing after it in YAML
+	}
+	props, ok := asStringMap(data)
+	if !ok {
+		return errSectionNotObject(sectionName)
+	}
+	for _, name := range sortedKeys(props) {
+		field, ok := findField(section, name)
+		if !ok || field.Kind() == reflect.Struct && !isTextUnmarshaler(field) {
+			return errUnknownVariable(sectionName, name)
+		}
+		if err := setFieldFromStructuredValue(field, props[name]); err != nil {
+			return errInvalidVariable(sectionName, name, err)
+		}
+	}
+	return nil
+}
+
+func setFieldFromStructuredValue(field reflect.Value, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	if list, ok := value.([]interface{}); ok {
+		// Only list-valued properties like AllowedOrigin accept this. Each item is added to the existing
+		// values, just as it would be for repeated variables in an INI file.
+		if _, isList := field.Addr().Interface().(interface{ Values() []string }); !isList && !isStringSlice(field) {
+			return errListNotAllowed
+		}
+		for _, item := range list {
+			if err := setFieldFromStructuredValue(field, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case bool, int, int64, uint64, float64, json.Number:
+		s = fmt.Sprint(v)
+	default:
+		return errUnsupportedValueType(value)
+	}
+	if tu, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return tu.UnmarshalText([]byte(s))
+	}
+	switch field.Kind() {
+	case reflect.Bool:
+		b, err := parseConfigBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+		return nil
+	case reflect.String:
+		field.SetString(s)
+		return nil
+	case reflect.Slice:
+		if isStringSlice(field) {
+			field.Set(reflect.Append(field, reflect.ValueOf(s).Convert(field.Type().Elem())))
+			return nil
+		}
+	}
+	return errUnsupportedValueType(value) // COVERAGE: would only happen if we added a field of a new type to Config
+}
+
+// parseConfigBool accepts the same boolean values as the INI file parser.
+func parseConfigBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0", "":
+		return false, nil
+	}
+	return false, errInvalidBool(s)
+}
+
+// findField finds a struct field by case-insensitive name, including fields of embedded structs such as
+// Config.MetricsConfig.
+func findField(structValue reflect.Value, name string) (reflect.Value, bool) {
+	t := structValue.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			if found, ok := findField(structValue.Field(i), name); ok {
+				return found, true
+			}
+			continue
+		}
+		if f.PkgPath == "" && strings.EqualFold(f.Name, name) {
+			return structValue.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func isNamedSectionMap(field reflect.Value) bool {
+	t := field.Type()
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String &&
+		t.Elem().Kind() == reflect.Ptr && t.Elem().Elem().Kind() == reflect.Struct
+}
+
+func isStringSlice(field reflect.Value) bool {
+	return field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String
+}
+
+func isTextUnmarshaler(field reflect.Value) bool {
+	_, ok := field.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+// asStringMap converts a parsed object to a map with string keys. The YAML parser produces maps with
+// interface{} keys, and the JSON parser produces maps with string keys.
+func asStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		ret := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			ret[fmt.Sprint(k)] = v
+		}
+		return ret, true
+	}
+	return nil, false
+}
+
+// sortedKeys is used so that if there are several errors, we always report the same one.
+func sortedKeys(m map[string]interface{}) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
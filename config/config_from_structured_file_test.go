@@ -0,0 +1,206 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromStructuredFileWithValidProperties(t *testing.T) {
+	// Every valid INI file in our test data should produce the same result if it is translated into
+	// JSON or YAML. Since JSON is also valid YAML, we can use the same content for both.
+	for _, tdc := range makeValidConfigs() {
+		if tdc.fileContent == "" {
+			continue
+		}
+		content := translateINIConfigToJSON(t, tdc.fileContent)
+		for _, ext := range []string{".json", ".yaml"} {
+			t.Run(tdc.name+" "+ext, func(t *testing.T) {
+				testStructuredFileWithValidConfig(t, ext, content, tdc)
+			})
+		}
+	}
+}
+
+func TestConfigFromStructuredFileWithNativeValueTypes(t *testing.T) {
+	expected := testDataValidConfig{
+		makeConfig: func(c *Config) {
+			c.Main.Port = mustOptIntGreaterThanZero(8333)
+			c.Main.ExitOnError = true
+			c.Main.HeartbeatInterval = ct.NewOptDuration(90 * time.Second)
+			c.Prometheus.Enabled = true
+			c.Environment = map[string]*EnvConfig{
+				"earth": {
+					SDKKey:        "earth-sdk",
+					AllowedOrigin: ct.NewOptStringList([]string{"https://oa", "https://rann"}),
+					TTL:           ct.NewOptDuration(5 * time.Minute),
+				},
+			}
+		},
+	}
+
+	t.Run("YAML", func(t *testing.T) {
+		testStructuredFileWithValidConfig(t, ".yml", `
+main:
+  port: 8333
+  exitOnError: true
+  heartbeatInterval: 90s
+prometheus:
+  enabled: true
+environment:
+  earth:
+    sdkKey: earth-sdk
+    allowedOrigin:
+      - https://oa
+      - https://rann
+    ttl: 5m
+`, expected)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		testStructuredFileWithValidConfig(t, ".json", `{
+  "Main": {"Port": 8333, "ExitOnError": true, "HeartbeatInterval": "90s"},
+  "Prometheus": {"Enabled": true},
+  "Environment": {
+    "earth": {"SdkKey": "earth-sdk", "AllowedOrigin": ["https://oa", "https://rann"], "TTL": "5m"}
+  }
+}`, expected)
+	})
+}
+
+func TestConfigFromStructuredFileBasicValidation(t *testing.T) {
+	t.Run("raises error for unknown config section", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, ".yaml", "Unknown: {}\n",
+			`unsupported or misspelled section "Unknown"`)
+	})
+
+	t.Run("raises error for unknown config field", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, ".yaml", "Main:\n  Unknown: x\n",
+			`unsupported or misspelled section "Main", variable "Unknown"`)
+	})
+
+	t.Run("raises error for unknown environment field", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, ".json", `{"Environment": {"earth": {"SdkKey": "x", "Unknown": "y"}}}`,
+			`unsupported or misspelled section "Environment \"earth\"", variable "Unknown"`)
+	})
+
+	t.Run("raises error for section that is not an object", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, ".yaml", "Main: 3\n",
+			`section "Main" must contain a set of named properties`)
+		testStructuredFileWithInvalidConfig(t, ".json", `{"Environment": ["x"]}`,
+			`section "Environment" must contain a set of named properties`)
+	})
+
+	t.Run("raises error for file that is not an object", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, ".json", `["Main"]`, errNotObject.Error())
+	})
+
+	t.Run("raises error for invalid value, naming the section and variable", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, ".yaml", "Main:\n  Port: x\n",
+			`invalid value for section "Main", variable "Port": not a valid integer`)
+		testStructuredFileWithInvalidConfig(t, ".yaml", "Main:\n  Port: 0\n",
+			`invalid value for section "Main", variable "Port": value must be greater than zero`)
+		testStructuredFileWithInvalidConfig(t, ".yaml", "Main:\n  ExitOnError: x\n",
+			"invalid value for section \"Main\", variable \"ExitOnError\": failed to parse bool `x`")
+		testStructuredFileWithInvalidConfig(t, ".json", `{"Main": {"LogLevel": "wrong"}}`,
+			`invalid value for section "Main", variable "LogLevel": "wrong" is not a valid log level`)
+	})
+
+	t.Run("raises error for list value in a property that does not allow it", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, ".yaml", "Main:\n  Port: [1, 2]\n",
+			`invalid value for section "Main", variable "Port": `+errListNotAllowed.Error())
+	})
+
+	t.Run("raises error for malformed file", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, ".json", `{"Main": `, "unexpected EOF")
+		testStructuredFileWithInvalidConfig(t, ".yaml", "Main: [\n", "yaml")
+	})
+
+	t.Run("performs the same validation as for other file formats", func(t *testing.T) {
+		testStructuredFileWithInvalidConfig(t, ".yaml", "Environment:\n  earth:\n    MobileKey: x\n",
+			`SDK key is required for environment "earth"`)
+	})
+
+	t.Run("allows empty file", func(t *testing.T) {
+		testStructuredFileWithValidConfig(t, ".yaml", "", testDataValidConfig{makeConfig: func(c *Config) {}})
+	})
+}
+
+func testStructuredFileWithValidConfig(t *testing.T, ext, fileContent string, tdc testDataValidConfig) {
+	withTempConfigFile(t, ext, fileContent, func(filename string) {
+		var c Config
+		mockLog := ldlogtest.NewMockLog()
+		err := LoadConfigFile(&c, filename, mockLog.Loggers)
+		require.NoError(t, err)
+		tdc.assertResult(t, c, mockLog)
+	})
+}
+
+func testStructuredFileWithInvalidConfig(t *testing.T, ext, fileContent string, errMessage string) {
+	withTempConfigFile(t, ext, fileContent, func(filename string) {
+		var c Config
+		err := LoadConfigFile(&c, filename, ldlog.NewDisabledLoggers())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), errMessage)
+	})
+}
+
+func withTempConfigFile(t *testing.T, ext, fileContent string, action func(string)) {
+	dir, err := ioutil.TempDir("", "config-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "relay"+ext)
+	require.NoError(t, ioutil.WriteFile(filename, []byte(fileContent), 0600))
+	action(filename)
+}
+
+// translateINIConfigToJSON handles the limited subset of the INI format that is used in our test data.
+func translateINIConfigToJSON(t *testing.T, iniContent string) string {
+	sectionRegex := regexp.MustCompile(`^\[(\w+)(?: "(.*)")?\]$`)
+	sections := make(map[string]map[string]interface{})
+	var current map[string]interface{}
+	for _, line := range strings.Split(iniContent, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if m := sectionRegex.FindStringSubmatch(line); m != nil {
+			if sections[m[1]] == nil {
+				sections[m[1]] = make(map[string]interface{})
+			}
+			current = sections[m[1]]
+			if m[2] != "" {
+				env := make(map[string]interface{})
+				current[m[2]] = env
+				current = env
+			}
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		require.Len(t, parts, 2, "unexpected line in test data: %s", line)
+		name, value := strings.TrimSpace(parts[0]), strings.Trim(strings.TrimSpace(parts[1]), `"`)
+		switch existing := current[name].(type) {
+		case nil:
+			current[name] = value
+		case string:
+			current[name] = []interface{}{existing, value}
+		case []interface{}:
+			current[name] = append(existing, value)
+		}
+	}
+	data, err := json.Marshal(sections)
+	require.NoError(t, err)
+	return string(data)
+}
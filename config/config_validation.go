@@ -3,6 +3,11 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	ct "github.com/launchdarkly/go-configtypes"
@@ -10,23 +15,346 @@ import (
 )
 
 var (
-	errTLSEnabledWithoutCertOrKey      = errors.New("TLS cert and key are required if TLS is enabled")
-	errAutoConfPropertiesWithNoKey     = errors.New("must specify auto-configuration key if other auto-configuration properties are set")
-	errAutoConfWithEnvironments        = errors.New("cannot configure specific environments if auto-configuration is enabled")
-	errFileDataWithAutoConf            = errors.New("cannot specify both auto-configuration key and file data source")
-	errOfflineModePropertiesWithNoFile = errors.New("must specify offline mode filename if other offline mode properties are set")
-	errOfflineModeWithEnvironments     = errors.New("cannot configure specific environments if offline mode is enabled")
-	errAutoConfWithoutDBDisambig       = errors.New(`when using auto-configuration with database storage, database prefix (or,` +
+	errTLSEnabledWithoutCertOrKey       = errors.New("TLS cert and key are required if TLS is enabled, unless ACME domains are specified")
+	errACMEWithoutTLS                   = errors.New("ACME domains can only be specified if TLS is enabled")
+	errACMEWithCertOrKey                = errors.New("TLS cert and key cannot be specified if ACME domains are specified")
+	errACMEPropertiesWithoutDomain      = errors.New("must specify ACME domains if other ACME properties are set")
+	errACMEWithoutCacheDir              = errors.New("ACME cache directory is required if ACME domains are specified")
+	errStreamRetryJitterOutOfRange      = errors.New("stream retry jitter must be between 0 and 1")
+	errProxyProtocolSourcesNotEnabled   = errors.New("PROXY protocol allowed sources can only be specified if the PROXY protocol is enabled")
+	errUnixSocketOptionsWithoutPath     = errors.New("Unix socket mode and Unix-socket-only options require a Unix socket path")
+	errH2CWithTLS                       = errors.New("HTTP/2 cleartext cannot be enabled if TLS is enabled; HTTP/2 is always available with TLS")
+	errHTTP3WithoutTLS                  = errors.New("TLS must be enabled if HTTP/3 is enabled")
+	errTLSClientCertWithoutTLS          = errors.New("TLS must be enabled if client certificates are required")
+	errTLSClientCAMissing               = errors.New("a global TLS client CA is required if client certificates are required in auto-configuration mode or offline mode")
+	errGRPCPortSameAsPort               = errors.New("gRPC port must be different from the HTTP port")
+	errAdminPortWithoutKey              = errors.New("admin API key is required if the admin port is set")
+	errAdminPortSameAsOtherPort         = errors.New("admin port must be different from the HTTP port and the gRPC port")
+	errAdminWithAutoConfOrFileData      = errors.New("cannot use the admin API if auto-configuration or offline mode is enabled")
+	errAdminProfilingWithoutPort        = errors.New("admin port is required if profiling is enabled")
+	errOpenTelemetrySamplingRatio       = errors.New("OpenTelemetry sampling ratio must be between 0 and 1")
+	errOpenTelemetryBadHeader           = errors.New("OpenTelemetry headers must be in the form name=value")
+	errInternalMetricsPortSameAsOther   = errors.New("internal metrics port must be different from the HTTP, gRPC, admin, and Prometheus ports")
+	errMemoryLimitsWithoutHighWaterMark = errors.New("must specify memory high-water mark if other memory limit properties are set")
+	errMemoryLowWaterMarkNotBelowHigh   = errors.New("memory low-water mark must be less than the high-water mark")
+	errMemoryCheckIntervalNotPositive   = errors.New("memory check interval must be greater than zero")
+	errRateLimitForwardedForNoProxies   = errors.New("rate limit trusted proxies are required if X-Forwarded-For is used")
+	errShadowPropertiesWithoutURI       = errors.New("must specify shadow URI if other shadow properties are set")
+	errShadowPercentageOutOfRange       = errors.New("shadow percentage must be greater than 0 and no more than 100")
+	errResponseHeaderInvalid            = errors.New("response headers must each be a name and value separated by a colon")
+	errAccessLogFileWithoutPath         = errors.New(`access log file must be specified if the access log output is "` + AccessLogOutputFile + `"`)
+	errAccessLogSyslogBadScheme         = errors.New(`access log syslog address must be a "udp" or "tcp" URL, such as "udp://localhost:514"`)
+	errAuditLogBadWebhookHeader         = errors.New("audit log webhook headers must be in the form name=value")
+	errEventsCoordinateWithoutRedis     = errors.New("Redis must be configured if event coordination is enabled") //nolint:stylecheck
+	errKafkaPropertiesWithoutBrokers    = errors.New("must specify Kafka brokers if other Kafka properties are set")
+	errKafkaWithoutTopic                = errors.New("Kafka topic must be specified if Kafka brokers are set") //nolint:stylecheck
+	errEventExportWithoutEvents         = errors.New("events must be enabled if event export to Kafka, Kinesis, or Pub/Sub is configured")
+	errKinesisPropertiesWithoutStream   = errors.New("must specify Kinesis stream name if other Kinesis properties are set")
+	errPubSubPropertiesWithoutTopic     = errors.New("must specify Pub/Sub topic if other Pub/Sub properties are set")
+	errPubSubTopicWithoutProject        = errors.New(`Pub/Sub project ID must be specified if the Pub/Sub topic is not a full name such as "projects/my-project/topics/my-topic"`) //nolint:stylecheck
+	errKafkaCredentialsWithoutSASL      = errors.New("Kafka SASL mechanism must be specified if a Kafka username or password is set")                                              //nolint:stylecheck
+	errKafkaSASLWithoutCredentials      = errors.New("Kafka username and password must be specified if a Kafka SASL mechanism is set")                                             //nolint:stylecheck
+	errUpstreamRelayWithServiceURIs     = errors.New("cannot specify LaunchDarkly service URIs if an upstream Relay URI is set")
+	errUpstreamRelayWithAutoConf        = errors.New("cannot use auto-configuration if an upstream Relay URI is set")
+	errUpstreamRelayWithFileData        = errors.New("cannot use offline mode if an upstream Relay URI is set")
+	errAutoConfPropertiesWithNoKey      = errors.New("must specify auto-configuration key if other auto-configuration properties are set")
+	errEnvIdleTimeoutWithoutLazyInit    = errors.New("environment idle timeout can only be used if lazy environment initialization is enabled")
+	errLazyEnvInitWithFileData          = errors.New("lazy environment initialization cannot be used in offline mode")
+	errTenantsWithGRPC                  = errors.New("tenants cannot be used if a gRPC port is set")
+	errTLSClientCertWithGRPC            = errors.New("client certificates cannot be required if a gRPC port is set")
+	errLazyEnvInitWithGRPC              = errors.New("lazy environment initialization cannot be used if a gRPC port is set")
+	errAutoConfMaxEventsNotPositive     = errors.New("auto-configuration maximum events per second must be greater than zero")
+	errAutoConfWithEnvironments         = errors.New("cannot configure specific environments if auto-configuration is enabled")
+	errFileDataWithAutoConf             = errors.New("cannot specify both auto-configuration key and file data source")
+	errOfflineModePropertiesWithNoFile  = errors.New("must specify offline mode filename if other offline mode properties are set")
+	errOfflineModeWithEnvironments      = errors.New("cannot configure specific environments if offline mode is enabled")
+	errNoOutboundConnectionsWithoutFile = errors.New("outbound connections can only be disabled if offline mode is enabled")
+	errReadReplicaPropertiesWithoutMode = errors.New("read replica properties can only be used if read replica mode is enabled")
+	errReadReplicaWithoutDatabase       = errors.New("a Redis, Consul, DynamoDB, Postgres, MongoDB, or etcd data store must be configured if read replica mode is enabled")
+	errReadReplicaWithAutoConf          = errors.New("cannot use auto-configuration if read replica mode is enabled")
+	errReadReplicaWithFileData          = errors.New("cannot use offline mode if read replica mode is enabled")
+	errReadReplicaWithUpstreamRelay     = errors.New("cannot specify an upstream Relay URI if read replica mode is enabled")
+	errReadReplicaWithInfiniteCache     = errors.New("database cache TTL cannot be negative if read replica mode is enabled, since changes would never be seen")
+	errReadReplicaWithMemoryBigSegments = errors.New(`big segment store type cannot be "` + BigSegmentsStoreTypeMemory +
+		`" if read replica mode is enabled, since its data comes from LaunchDarkly`)
+	errLeaderElectionPropertiesWithoutType = errors.New("must specify leader election type if other leader election properties are set")
+	errLeaderElectionRedisWithoutRedis     = errors.New(`Redis must be configured if the leader election type is "` + LeaderElectionTypeRedis + `"`) //nolint:stylecheck
+	errLeaderElectionRenewNotBelowLease    = errors.New("leader election renew interval must be less than the lease duration")
+	errLeaderElectionWithReadReplica       = errors.New("cannot use leader election if read replica mode is enabled")
+	errLeaderElectionWithFileData          = errors.New("cannot use leader election if offline mode is enabled")
+	errReplicationWithoutDatabases         = errors.New("must specify replication databases if other replication properties are set")
+	errReplicationWithReadReplica          = errors.New("cannot use replication if read replica mode is enabled")
+	errReplicationWithFileData             = errors.New("cannot use replication if offline mode is enabled")
+	errReplicationRetryIntervalNotPositive = errors.New("replication retry interval must be greater than zero")
+	errAutoConfWithoutDBDisambig           = errors.New(`when using auto-configuration with database storage, database prefix (or,` +
 		` if using DynamoDB, table name) must be specified and must contain "` + AutoConfigEnvironmentIDPlaceholder + `"`)
-	errRedisURLWithHostAndPort = errors.New("please specify Redis URL or host/port, but not both")
-	errRedisBadHostname        = errors.New("invalid Redis hostname")
-	errConsulTokenAndTokenFile = errors.New("Consul token must be specified as either an inline value or a file, but not both") //nolint:stylecheck
+	errRedisURLWithHostAndPort               = errors.New("please specify Redis URL or host/port, but not both")
+	errRedisBadHostname                      = errors.New("invalid Redis hostname")
+	errRedisClusterAndSentinel               = errors.New("please specify Redis cluster addresses or Sentinel addresses, but not both")
+	errRedisClusterWithURL                   = errors.New("cannot specify Redis URL or host/port if Redis cluster or Sentinel addresses are specified")
+	errRedisTLSOptionsWithoutTLS             = errors.New("Redis TLS CA file, cert file, key file, and server name can only be specified if TLS is enabled") //nolint:stylecheck
+	errRedisCertWithoutKey                   = errors.New("Redis TLS cert file and key file must be specified together")                                     //nolint:stylecheck
+	errRedisSentinelWithoutMasterName        = errors.New("Redis Sentinel master name and Sentinel addresses must be specified together")                    //nolint:stylecheck
+	errRedisSentinelPasswordWithoutSentinel  = errors.New("Redis Sentinel password was specified, but no Sentinel addresses")                                //nolint:stylecheck
+	errRedisMinIdleConnsOverPoolSize         = errors.New("Redis minimum idle connections cannot be greater than the pool size")                             //nolint:stylecheck
+	errConsulTokenAndTokenFile               = errors.New("Consul token must be specified as either an inline value or a file, but not both")                //nolint:stylecheck
+	errPostgresCertWithoutKey                = errors.New("Postgres TLS cert file and key file must be specified together")                                  //nolint:stylecheck
+	errMongoDBTLSOptionsWithoutTLS           = errors.New("MongoDB TLS CA file, cert file, and key file can only be specified if TLS is enabled")            //nolint:stylecheck
+	errMongoDBCertWithoutKey                 = errors.New("MongoDB TLS cert file and key file must be specified together")                                   //nolint:stylecheck
+	errEtcdTLSOptionsWithoutTLS              = errors.New("etcd TLS CA file, cert file, and key file can only be specified if TLS is enabled")
+	errEtcdCertWithoutKey                    = errors.New("etcd TLS cert file and key file must be specified together")
+	errEtcdPasswordWithoutUsername           = errors.New("etcd password can only be specified if a username is specified")
+	errDynamoDBIncompleteCredentials         = errors.New("DynamoDB access key ID and secret access key must be specified together")
+	errSnapshotPropertiesWithoutType         = errors.New("must specify snapshot store type if other snapshot properties are set")
+	errSnapshotWithoutBucket                 = errors.New("snapshot bucket must be specified if the snapshot store type is set")
+	errSnapshotS3WithoutS3Store              = errors.New(`snapshot S3 properties can only be used if the snapshot store type is "` + SnapshotStoreTypeS3 + `"`)
+	errSnapshotAzureWithoutAzureStore        = errors.New(`snapshot Azure properties can only be used if the snapshot store type is "` + SnapshotStoreTypeAzure + `"`)
+	errSnapshotAzureWithoutAccount           = errors.New(`snapshot Azure account or URL must be specified if the snapshot store type is "` + SnapshotStoreTypeAzure + `"`)
+	errSnapshotAzureWithoutSASToken          = errors.New(`snapshot Azure SAS token must be specified if the snapshot store type is "` + SnapshotStoreTypeAzure + `"`)
+	errBigSegmentsSnapshotWithoutMemoryStore = errors.New(`big segments snapshot properties can only be used if the big segment store type is "` +
+		BigSegmentsStoreTypeMemory + `"`)
+	errBigSegmentsS3WithoutS3Store = errors.New(`big segments S3 properties can only be used if the big segment store type is "` +
+		BigSegmentsStoreTypeS3 + `"`)
+	errBigSegmentsS3WithoutBucket = errors.New(`big segments S3 bucket must be specified if the big segment store type is "` +
+		BigSegmentsStoreTypeS3 + `"`)
+	errBigSegmentsNegativeCacheTimeWithoutSize = errors.New("big segments negative cache time cannot be set unless the negative cache size is set")
 )
 
+var validPostgresTLSModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"} //nolint:gochecknoglobals
+
 func errEnvironmentWithNoSDKKey(envName string) error {
 	return fmt.Errorf("SDK key is required for environment %q", envName)
 }
 
+func errEnvBadBigSegmentsStaleMode(envName, mode string) error {
+	return fmt.Errorf("invalid big segments stale mode %q for environment %q; must be %q or %q",
+		mode, envName, BigSegmentsStaleModeFailOpen, BigSegmentsStaleModeFailClosed)
+}
+
+func errAccessLogBadFormat(format string) error {
+	return fmt.Errorf("invalid access log format %q; must be %q, %q, or %q",
+		format, AccessLogFormatCommon, AccessLogFormatCombined, AccessLogFormatJSON)
+}
+
+func errAccessLogBadOutput(output string) error {
+	return fmt.Errorf("invalid access log output %q; must be %q, %q, or %q",
+		output, AccessLogOutputStdout, AccessLogOutputFile, AccessLogOutputSyslog)
+}
+
+func errEventExportBadMode(destination, mode string) error {
+	return fmt.Errorf("invalid %s mode %q; must be %q or %q", destination, mode, EventExportModeTee, EventExportModeRedirect)
+}
+
+func errEventExportBadFormat(destination, format string) error {
+	return fmt.Errorf("invalid %s format %q; must be %q or %q", destination, format, EventExportFormatEvent, EventExportFormatEnvelope)
+}
+
+func errEnvEventExportUnknown(envName, name string) error {
+	return fmt.Errorf("invalid event export %q for environment %q; must be %q, %q, %q, or %q",
+		name, envName, EventExportKafka, EventExportKinesis, EventExportPubSub, EventExportNone)
+}
+
+func errEnvEventExportNotConfigured(envName, name string) error {
+	return fmt.Errorf("environment %q uses event export %q, but it is not configured", envName, name)
+}
+
+func errEnvEventExportNoneWithOthers(envName string) error {
+	return fmt.Errorf("event export %q cannot be combined with other values for environment %q", EventExportNone, envName)
+}
+
+func errKafkaBadSASLMechanism(mechanism string) error {
+	return fmt.Errorf("invalid Kafka SASL mechanism %q; must be %q, %q, or %q",
+		mechanism, KafkaSASLPlain, KafkaSASLSCRAMSHA256, KafkaSASLSCRAMSHA512)
+}
+
+func errBigSegmentsBadCircuitBreakerFallback(fallback string) error {
+	return fmt.Errorf("invalid big segments circuit breaker fallback %q; must be %q or %q",
+		fallback, BigSegmentsCircuitBreakerFallbackNotIncluded, BigSegmentsCircuitBreakerFallbackLastKnown)
+}
+
+func errSnapshotBadStoreType(storeType string) error {
+	return fmt.Errorf("invalid snapshot store type %q; must be %q, %q, or %q",
+		storeType, SnapshotStoreTypeS3, SnapshotStoreTypeGCS, SnapshotStoreTypeAzure)
+}
+
+func errEnvFlagChangeWebhookInvalid(envName, url string) error {
+	return fmt.Errorf("invalid flag change webhook %q for environment %q; must be an absolute http or https URL", url, envName)
+}
+
+func errEnvFlagChangeWebhookSecretWithoutWebhook(envName string) error {
+	return fmt.Errorf("flag change webhook secret was set for environment %q, but it has no flag change webhook", envName)
+}
+
+func errEnvChangeFeedWithoutEnvID(envName string) error {
+	return fmt.Errorf("change feed token was set for environment %q, but it has no client-side ID", envName)
+}
+
+func errEnvFlagOverridesWithDatabase(envName string) error {
+	return fmt.Errorf("flag overrides file was set for environment %q, but it uses a persistent data store,"+
+		" which would make other Relay instances and SDKs see the overridden flags", envName)
+}
+
+func errEnvPrivateAttributeKey(envName string) error {
+	return fmt.Errorf("private attributes for environment %q include \"key\", which cannot be private", envName)
+}
+
+func errEnvHashPrivateAttributesWithoutAttributes(envName string) error {
+	return fmt.Errorf("private attribute hashing was enabled for environment %q, but it has no private attributes", envName)
+}
+
+func errEnvEventSampleRateInvalid(envName string) error {
+	return fmt.Errorf("event sample rates for environment %q must be greater than 0 and no more than 1", envName)
+}
+
+func errEnvFlagFilterPatternInvalid(envName, pattern string) error {
+	return fmt.Errorf("flag filter pattern %q for environment %q is not valid; \"*\" can only be used at the end", pattern, envName)
+}
+
+func errEnvAllowedOriginInvalid(envName, origin string, err error) error {
+	return fmt.Errorf("allowed origin %q for environment %q is not a valid regular expression: %s", origin, envName, err)
+}
+
+func errEnvCanaryIncomplete(envName string) error {
+	return fmt.Errorf("canary environment and canary header must be specified together for environment %q", envName)
+}
+
+func errEnvUnknownCanary(envName, name string) error {
+	return fmt.Errorf("environment %q refers to canary environment %q, which is not defined", envName, name)
+}
+
+func errEnvCanaryNotAllowed(envName, name string) error {
+	return fmt.Errorf("environment %q cannot use %q as its canary environment; it must be a different environment"+
+		" in the same tenant, without a canary environment of its own", envName, name)
+}
+
+func errNoOutboundConnectionsWith(feature string) error {
+	return fmt.Errorf("%s cannot be used if outbound connections are disabled", feature)
+}
+
+func errEnvClientCAMissing(envName string) error {
+	return fmt.Errorf("environment %q must have a client CA, since client certificates are required and there is no global TLS client CA", envName)
+}
+
+func errEnvClientCertWithoutRequired(envName string) error {
+	return fmt.Errorf("client certificate properties were set for environment %q, but client certificates are not required", envName)
+}
+
+func errEnvJWTPropertiesWithoutJWKSURL(envName string) error {
+	return fmt.Errorf("JWT properties were set for environment %q, but it has no JWKS URL", envName)
+}
+
+func errNegativeDuration(name string) error {
+	return fmt.Errorf("%s cannot be negative", name)
+}
+
+func errRateLimitNotPositive(name string) error {
+	return fmt.Errorf("rate limit %s must be greater than zero", name)
+}
+
+func errRateLimitInvalidTrustedProxy(value string) error {
+	return fmt.Errorf("rate limit trusted proxy %q must be an IP address or a CIDR range", value)
+}
+
+func errLeaderElectionBadType(value string) error {
+	return fmt.Errorf("invalid leader election type %q; must be %q or %q",
+		value, LeaderElectionTypeRedis, LeaderElectionTypeKubernetes)
+}
+
+func errReplicationUnknownDatabase(name string) error {
+	return fmt.Errorf("replication refers to database %q, which is not defined", name)
+}
+
+func errEnvReplicationToOwnDatabase(envName, name string) error {
+	return fmt.Errorf("environment %q uses database %q, so it cannot also be replicated to it", envName, name)
+}
+
+func errStreamLimitBadOverflow(value string) error {
+	return fmt.Errorf("invalid stream limit overflow behavior %q; must be %q or %q",
+		value, StreamLimitOverflowReject, StreamLimitOverflowQueue)
+}
+
+func errEnvRateLimitNotPositive(envName, name string) error {
+	return fmt.Errorf("rate limit %s for environment %q must be greater than zero", name, envName)
+}
+
+func errAutoConfBadQuotaOverflow(value string) error {
+	return fmt.Errorf("invalid auto-configuration quota overflow behavior %q; must be %q or %q",
+		value, AutoConfigQuotaOverflowReject, AutoConfigQuotaOverflowQueue)
+}
+
+func errBadDiagnosticEvents(value string) error {
+	return fmt.Errorf("invalid diagnostic events behavior %q; must be %q, %q, or %q",
+		value, DiagnosticEventsForward, DiagnosticEventsBlock, DiagnosticEventsAnnotate)
+}
+
+func errPostgresBadTLSMode(mode string) error {
+	return fmt.Errorf("invalid Postgres TLS mode %q; must be one of: %s", mode, strings.Join(validPostgresTLSModes, ", "))
+}
+
+func errMongoDBBadWriteConcern(value string) error {
+	return fmt.Errorf(`invalid MongoDB write concern %q; must be "majority" or a number of servers`, value)
+}
+
+func errRedisBadAddress(addr string) error {
+	return fmt.Errorf("invalid Redis address %q: must be in the form host:port", addr)
+}
+
+func errDatabaseNameConflict(name string, kinds []string) error {
+	return fmt.Errorf("database name %q is used for more than one database (%s)", name, strings.Join(kinds, ", "))
+}
+
+func errNamedDatabaseWithoutServer(kind, name string) error {
+	return fmt.Errorf("%s database %q must specify a database URL or host", kind, name)
+}
+
+func errEnvUnknownDatabase(envName, name string) error {
+	return fmt.Errorf("environment %q refers to database %q, which is not defined", envName, name)
+}
+
+func errEnvUnknownTenant(envName, name string) error {
+	return fmt.Errorf("environment %q refers to tenant %q, which is not defined", envName, name)
+}
+
+func errTenantWithoutPortOrHost(name string) error {
+	return fmt.Errorf("tenant %q must specify a port or a host name", name)
+}
+
+func errTenantPortConflict(name string, port int) error {
+	return fmt.Errorf("tenant %q uses port %d, which is already used by another listener", name, port)
+}
+
+func errProxyProtocolSourceInvalid(source string) error {
+	return fmt.Errorf("PROXY protocol allowed source %q is not a valid IP address or CIDR range", source)
+}
+
+func errProxyDestinationInvalid(item string) error {
+	return fmt.Errorf("proxy destination %q must be a host name and an absolute proxy URL separated by \"=\"", item)
+}
+
+func errUpstreamFallbackInvalid(uri string) error {
+	return fmt.Errorf("upstream fallback URI %q is not a valid absolute URL", uri)
+}
+
+func errEnvStreamRetryJitterOutOfRange(envName string) error {
+	return fmt.Errorf("stream retry jitter for environment %q must be between 0 and 1", envName)
+}
+
+func errTenantCertWithoutKey(name string) error {
+	return fmt.Errorf("tenant %q must specify a TLS cert and key together", name)
+}
+
+func errTenantTLSWithoutTLS(name string) error {
+	return fmt.Errorf("tenant %q specifies a TLS cert, but TLS is not enabled", name)
+}
+
+func errTenantHostConflict(host string) error {
+	return fmt.Errorf("host name %q is used for more than one tenant", host)
+}
+
+func errEnvCoordinateWithoutRedis(envName string) error {
+	return fmt.Errorf("environment %q must use a Redis database if event coordination is enabled", envName)
+}
+
 func errMultipleDatabases(databases []string) error {
 	return fmt.Errorf("multiple databases are enabled (%s); only one is allowed", strings.Join(databases, ", "))
 }
@@ -57,10 +385,37 @@ func warnEnvWithoutDBDisambiguation(envName string, canUseTableName bool) string
 func ValidateConfig(c *Config, loggers ldlog.Loggers) error {
 	var result ct.ValidationResult
 
+	validateConfigUpstreamRelay(&result, c)
 	validateConfigDefaultURLs(c)
+	validateConfigUpstreamFallbacks(&result, c)
+	validateConfigStreamRetry(&result, c)
 	validateConfigTLS(&result, c)
+	validateConfigProxyProtocol(&result, c)
+	validateConfigUnixSocket(&result, c)
+	validateConfigGRPC(&result, c)
+	validateConfigAdmin(&result, c)
+	validateConfigInternalMetrics(&result, c)
+	validateConfigTenants(&result, c)
+	validateConfigAccessLog(&result, c)
+	validateConfigAuditLog(&result, c)
 	validateConfigEnvironments(&result, c)
 	validateConfigDatabases(&result, c, loggers)
+	validateConfigFlagOverrides(&result, c)
+	validateConfigBigSegments(&result, c)
+	validateConfigReadReplica(&result, c)
+	validateConfigLeaderElection(&result, c)
+	validateConfigReplication(&result, c)
+	validateConfigEvents(&result, c)
+	validateConfigEventExport(&result, c)
+	validateConfigSnapshots(&result, c.Snapshots)
+	validateConfigRateLimit(&result, c)
+	validateConfigStreamLimits(&result, c)
+	validateConfigMemoryLimits(&result, c)
+	validateConfigShadow(&result, c)
+	validateConfigResponseHeaders(&result, c)
+	validateConfigProxy(&result, c)
+	validateConfigOpenTelemetry(&result, c)
+	validateConfigNoOutboundConnections(&result, c)
 
 	return result.GetError()
 }
@@ -90,21 +445,287 @@ func validateConfigDefaultURLs(c *Config) {
 	}
 }
 
+// validateConfigUpstreamRelay makes all of the LaunchDarkly service URIs point to the upstream Relay,
+// if there is one. Since ValidateConfig can be called more than once, a URI that is already equal to
+// the upstream Relay URI is not an error.
+func validateConfigUpstreamRelay(result *ct.ValidationResult, c *Config) {
+	if !c.Main.UpstreamRelayURI.IsDefined() {
+		return
+	}
+	upstream := c.Main.UpstreamRelayURI
+	for _, u := range []ct.OptURLAbsolute{c.Main.StreamURI, c.Main.BaseURI, c.Main.ClientSideBaseURI, c.Events.EventsURI} {
+		if u.IsDefined() && u.String() != upstream.String() {
+			result.AddError(nil, errUpstreamRelayWithServiceURIs)
+			break
+		}
+	}
+	if c.AutoConfig.Key != "" {
+		result.AddError(nil, errUpstreamRelayWithAutoConf)
+	}
+	if c.OfflineMode.FileDataSource != "" {
+		result.AddError(nil, errUpstreamRelayWithFileData)
+	}
+	c.Main.StreamURI = upstream
+	c.Main.BaseURI = upstream
+	c.Main.ClientSideBaseURI = upstream
+	c.Events.EventsURI = upstream
+}
+
+func validateConfigUpstreamFallbacks(result *ct.ValidationResult, c *Config) {
+	for _, list := range []ct.OptStringList{c.Main.StreamURIFallback, c.Main.BaseURIFallback, c.Events.EventsURIFallback} {
+		for _, uri := range list.Values() {
+			if _, err := ct.NewOptURLAbsoluteFromString(uri); err != nil {
+				result.AddError(nil, errUpstreamFallbackInvalid(uri))
+			}
+		}
+	}
+}
+
+func validateConfigStreamRetry(result *ct.ValidationResult, c *Config) {
+	jitterInRange := func(j ct.OptFloat64) bool {
+		return !j.IsDefined() || (j.GetOrElse(0) >= 0 && j.GetOrElse(0) <= 1)
+	}
+	if !jitterInRange(c.Main.StreamRetryJitter) {
+		result.AddError(nil, errStreamRetryJitterOutOfRange)
+	}
+	for envName, ec := range c.Environment {
+		if ec != nil && !jitterInRange(ec.StreamRetryJitter) {
+			result.AddError(nil, errEnvStreamRetryJitterOutOfRange(envName))
+		}
+	}
+}
+
+func validateConfigProxyProtocol(result *ct.ValidationResult, c *Config) {
+	sources := c.Main.ProxyProtocolAllowedSource.Values()
+	if len(sources) != 0 && !c.Main.ProxyProtocolEnabled {
+		result.AddError(nil, errProxyProtocolSourcesNotEnabled)
+	}
+	for _, source := range sources {
+		if _, _, err := net.ParseCIDR(source); err != nil && net.ParseIP(source) == nil {
+			result.AddError(nil, errProxyProtocolSourceInvalid(source))
+		}
+	}
+}
+
+func validateConfigUnixSocket(result *ct.ValidationResult, c *Config) {
+	if c.Main.UnixSocket == "" && (c.Main.UnixSocketMode.IsDefined() || c.Main.UnixSocketOnly) {
+		result.AddError(nil, errUnixSocketOptionsWithoutPath)
+	}
+}
+
 func validateConfigTLS(result *ct.ValidationResult, c *Config) {
-	if c.Main.TLSEnabled && (c.Main.TLSCert == "" || c.Main.TLSKey == "") {
+	acme := c.ACME
+	hasACMEDomain := len(acme.Domain.Values()) != 0
+	switch {
+	case hasACMEDomain && !c.Main.TLSEnabled:
+		result.AddError(nil, errACMEWithoutTLS)
+	case hasACMEDomain && (c.Main.TLSCert != "" || c.Main.TLSKey != ""):
+		result.AddError(nil, errACMEWithCertOrKey)
+	case hasACMEDomain && acme.CacheDir == "":
+		result.AddError(nil, errACMEWithoutCacheDir)
+	case !hasACMEDomain && (acme.Email != "" || acme.CacheDir != "" || acme.DirectoryURL.IsDefined() || acme.HTTPPort.IsDefined()):
+		result.AddError(nil, errACMEPropertiesWithoutDomain)
+	case c.Main.TLSEnabled && !hasACMEDomain && (c.Main.TLSCert == "" || c.Main.TLSKey == ""):
 		result.AddError(nil, errTLSEnabledWithoutCertOrKey)
 	}
+	if c.Main.TLSEnabled && c.Main.H2CEnabled {
+		result.AddError(nil, errH2CWithTLS)
+	}
+	if c.Main.HTTP3Enabled && !c.Main.TLSEnabled {
+		result.AddError(nil, errHTTP3WithoutTLS)
+	}
+	if c.Main.TLSClientCertRequired && !c.Main.TLSEnabled {
+		result.AddError(nil, errTLSClientCertWithoutTLS)
+	}
+	if c.Main.TLSClientCertRequired && c.Main.TLSClientCA == "" &&
+		(c.AutoConfig.Key != "" || c.OfflineMode.FileDataSource != "") {
+		result.AddError(nil, errTLSClientCAMissing)
+	}
+	for envName, envConfig := range c.Environment {
+		hasClientCertProperties := envConfig.ClientCA != "" || len(envConfig.AllowedClientCertName.Values()) != 0 ||
+			len(envConfig.AllowedClientCertOU.Values()) != 0
+		switch {
+		case !c.Main.TLSClientCertRequired && hasClientCertProperties:
+			result.AddError(nil, errEnvClientCertWithoutRequired(envName))
+		case c.Main.TLSClientCertRequired && envConfig.ClientCA == "" && c.Main.TLSClientCA == "":
+			result.AddError(nil, errEnvClientCAMissing(envName))
+		}
+	}
+}
+
+func validateConfigGRPC(result *ct.ValidationResult, c *Config) {
+	if c.Main.GRPCPort.IsDefined() && c.Main.GRPCPort.GetOrElse(0) == c.Main.Port.GetOrElse(DefaultPort) {
+		result.AddError(nil, errGRPCPortSameAsPort)
+	}
+}
+
+func validateConfigAdmin(result *ct.ValidationResult, c *Config) {
+	if !c.Admin.Port.IsDefined() {
+		if c.Admin.EnableProfiling {
+			result.AddError(nil, errAdminProfilingWithoutPort)
+		}
+		return
+	}
+	if c.Admin.Key == "" {
+		result.AddError(nil, errAdminPortWithoutKey)
+	}
+	adminPort := c.Admin.Port.GetOrElse(0)
+	if adminPort == c.Main.Port.GetOrElse(DefaultPort) || adminPort == c.Main.GRPCPort.GetOrElse(0) {
+		result.AddError(nil, errAdminPortSameAsOtherPort)
+	}
+	if c.AutoConfig.Key != "" || c.OfflineMode.FileDataSource != "" {
+		result.AddError(nil, errAdminWithAutoConfOrFileData)
+	}
+}
+
+func validateConfigInternalMetrics(result *ct.ValidationResult, c *Config) {
+	if !c.InternalMetrics.Port.IsDefined() {
+		return
+	}
+	port := c.InternalMetrics.Port.GetOrElse(0)
+	if port == c.Main.Port.GetOrElse(DefaultPort) || port == c.Main.GRPCPort.GetOrElse(0) ||
+		port == c.Admin.Port.GetOrElse(0) ||
+		(c.MetricsConfig.Prometheus.Enabled && port == c.MetricsConfig.Prometheus.Port.GetOrElse(DefaultPrometheusPort)) {
+		result.AddError(nil, errInternalMetricsPortSameAsOther)
+	}
+}
+
+func validateConfigTenants(result *ct.ValidationResult, c *Config) {
+	usedPorts := map[int]bool{c.Main.Port.GetOrElse(DefaultPort): true}
+	for _, port := range []ct.OptIntGreaterThanZero{c.Main.GRPCPort, c.Admin.Port, c.InternalMetrics.Port} {
+		if port.IsDefined() {
+			usedPorts[port.GetOrElse(0)] = true
+		}
+	}
+	if c.MetricsConfig.Prometheus.Enabled {
+		usedPorts[c.MetricsConfig.Prometheus.Port.GetOrElse(DefaultPrometheusPort)] = true
+	}
+
+	if len(c.Tenant) != 0 && c.Main.GRPCPort.IsDefined() {
+		// The gRPC service accepts any environment's credentials, so it would not keep tenants separate.
+		result.AddError(nil, errTenantsWithGRPC)
+	}
+	if c.Main.TLSClientCertRequired && c.Main.GRPCPort.IsDefined() {
+		// The gRPC service does not check client certificates, so it would accept SDK keys without one.
+		result.AddError(nil, errTLSClientCertWithGRPC)
+	}
+
+	names := make([]string, 0, len(c.Tenant))
+	for name := range c.Tenant {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	usedHosts := make(map[string]bool)
+	for _, name := range names {
+		tc := c.Tenant[name]
+		if !tc.Port.IsDefined() && len(tc.Host.Values()) == 0 {
+			result.AddError(nil, errTenantWithoutPortOrHost(name))
+		}
+		if tc.Port.IsDefined() {
+			port := tc.Port.GetOrElse(0)
+			if usedPorts[port] {
+				result.AddError(nil, errTenantPortConflict(name, port))
+			}
+			usedPorts[port] = true
+		}
+		if (tc.TLSCert == "") != (tc.TLSKey == "") {
+			result.AddError(nil, errTenantCertWithoutKey(name))
+		}
+		if tc.TLSCert != "" && !c.Main.TLSEnabled {
+			result.AddError(nil, errTenantTLSWithoutTLS(name))
+		}
+		for _, host := range tc.Host.Values() {
+			host = strings.ToLower(host)
+			if usedHosts[host] {
+				result.AddError(nil, errTenantHostConflict(host))
+			}
+			usedHosts[host] = true
+		}
+	}
+
+	for envName, ec := range c.Environment {
+		if ec.Tenant != "" && c.Tenant[ec.Tenant] == nil {
+			result.AddError(nil, errEnvUnknownTenant(envName, ec.Tenant))
+		}
+	}
+}
+
+func validateConfigAccessLog(result *ct.ValidationResult, c *Config) {
+	switch c.AccessLog.Format {
+	case "", AccessLogFormatCommon, AccessLogFormatCombined, AccessLogFormatJSON:
+	default:
+		result.AddError(nil, errAccessLogBadFormat(c.AccessLog.Format))
+	}
+	switch c.AccessLog.Output {
+	case "", AccessLogOutputStdout, AccessLogOutputSyslog:
+	case AccessLogOutputFile:
+		if c.AccessLog.File == "" {
+			result.AddError(nil, errAccessLogFileWithoutPath)
+		}
+	default:
+		result.AddError(nil, errAccessLogBadOutput(c.AccessLog.Output))
+	}
+	if c.AccessLog.SyslogAddress.IsDefined() {
+		if scheme := c.AccessLog.SyslogAddress.Get().Scheme; scheme != "udp" && scheme != "tcp" {
+			result.AddError(nil, errAccessLogSyslogBadScheme)
+		}
+	}
+}
+
+func validateConfigAuditLog(result *ct.ValidationResult, c *Config) {
+	if !isValidHeaderList(c.AuditLog.WebhookHeader) {
+		result.AddError(nil, errAuditLogBadWebhookHeader)
+	}
+}
+
+// validateConfigNoOutboundConnections rejects any features that would send data to, or fetch it from,
+// somewhere other than the data stores that are part of the Relay configuration. In offline mode, Relay
+// does not otherwise connect to LaunchDarkly, and events from SDKs are discarded.
+func validateConfigNoOutboundConnections(result *ct.ValidationResult, c *Config) {
+	if !c.OfflineMode.NoOutboundConnections {
+		return
+	}
+	if c.OfflineMode.FileDataSource == "" {
+		result.AddError(nil, errNoOutboundConnectionsWithoutFile)
+		return
+	}
+	features := []struct {
+		name    string
+		enabled bool
+	}{
+		{"Kafka event export", len(c.Kafka.Brokers.Values()) != 0},
+		{"Kinesis event export", c.Kinesis.StreamName != ""},
+		{"Pub/Sub event export", c.PubSub.Topic != ""},
+		{"audit log webhook", c.AuditLog.WebhookURL.IsDefined()},
+		{"access log syslog address", c.AccessLog.SyslogAddress.IsDefined()},
+		{"Vault secrets", c.Secrets.VaultURL.IsDefined()},
+		{"S3 big segment store", c.BigSegments.Type == BigSegmentsStoreTypeS3},
+		{"flag data snapshots", c.Snapshots.Type != ""},
+		{"Datadog", c.MetricsConfig.Datadog.Enabled},
+		{"New Relic", c.MetricsConfig.Newrelic.Enabled},
+		{"Stackdriver", c.MetricsConfig.Stackdriver.Enabled},
+		{"OpenTelemetry", c.MetricsConfig.OpenTelemetry.Enabled},
+	}
+	for _, f := range features {
+		if f.enabled {
+			result.AddError(nil, errNoOutboundConnectionsWith(f.name))
+		}
+	}
 }
 
 func validateConfigEnvironments(result *ct.ValidationResult, c *Config) {
 	if c.AutoConfig.Key == "" {
 		if c.AutoConfig.EnvDatastorePrefix != "" || c.AutoConfig.EnvDatastoreTableName != "" ||
-			len(c.AutoConfig.EnvAllowedOrigin.Values()) != 0 || len(c.AutoConfig.EnvAllowedHeader.Values()) != 0 {
+			len(c.AutoConfig.EnvAllowedOrigin.Values()) != 0 || len(c.AutoConfig.EnvAllowedHeader.Values()) != 0 ||
+			len(c.AutoConfig.ProjectKeys.Values()) != 0 || len(c.AutoConfig.EnvironmentKeys.Values()) != 0 ||
+			len(c.AutoConfig.EnvironmentTags.Values()) != 0 || hasAutoConfigQuotaProperties(c.AutoConfig) {
 			result.AddError(nil, errAutoConfPropertiesWithNoKey)
 		}
 	} else if len(c.Environment) != 0 {
 		result.AddError(nil, errAutoConfWithEnvironments)
 	}
+	validateConfigAutoConfigQuotas(result, c.AutoConfig)
+	validateConfigLazyEnvironmentInit(result, c)
 	if c.OfflineMode.FileDataSource == "" {
 		if c.OfflineMode.EnvDatastorePrefix != "" || c.OfflineMode.EnvDatastoreTableName != "" ||
 			len(c.OfflineMode.EnvAllowedOrigin.Values()) != 0 || len(c.OfflineMode.EnvAllowedHeader.Values()) != 0 {
@@ -123,15 +744,100 @@ func validateConfigEnvironments(result *ct.ValidationResult, c *Config) {
 		if envConfig.SDKKey == "" {
 			result.AddError(nil, errEnvironmentWithNoSDKKey(envName))
 		}
+		if !envConfig.JWTJWKSURL.IsDefined() &&
+			(envConfig.JWTIssuer != "" || envConfig.JWTAudience != "" || envConfig.JWTUserKeyClaim != "") {
+			result.AddError(nil, errEnvJWTPropertiesWithoutJWKSURL(envName))
+		}
+		switch envConfig.BigSegmentsStaleMode {
+		case "", BigSegmentsStaleModeFailOpen, BigSegmentsStaleModeFailClosed:
+		default:
+			result.AddError(nil, errEnvBadBigSegmentsStaleMode(envName, envConfig.BigSegmentsStaleMode))
+		}
+		for _, u := range envConfig.FlagChangeWebhook.Values() {
+			if parsed, err := url.Parse(u); err != nil || parsed.Host == "" ||
+				(parsed.Scheme != "http" && parsed.Scheme != "https") {
+				result.AddError(nil, errEnvFlagChangeWebhookInvalid(envName, u))
+			}
+		}
+		if envConfig.FlagChangeWebhookSecret != "" && len(envConfig.FlagChangeWebhook.Values()) == 0 {
+			result.AddError(nil, errEnvFlagChangeWebhookSecretWithoutWebhook(envName))
+		}
+		for _, attr := range envConfig.PrivateAttributes.Values() {
+			if attr == "key" {
+				result.AddError(nil, errEnvPrivateAttributeKey(envName))
+			}
+		}
+		for _, rate := range []ct.OptFloat64{envConfig.EventSampleRateFeature, envConfig.EventSampleRateCustom} {
+			if rate.IsDefined() && (rate.GetOrElse(0) <= 0 || rate.GetOrElse(0) > 1) {
+				result.AddError(nil, errEnvEventSampleRateInvalid(envName))
+				break
+			}
+		}
+		if envConfig.HashPrivateAttributes && len(envConfig.PrivateAttributes.Values()) == 0 {
+			result.AddError(nil, errEnvHashPrivateAttributesWithoutAttributes(envName))
+		}
+		if envConfig.ChangeFeedToken != "" && envConfig.EnvID == "" {
+			result.AddError(nil, errEnvChangeFeedWithoutEnvID(envName))
+		}
+		for _, origin := range envConfig.AllowedOrigin.Values() {
+			// an allowed origin between slashes is a regular expression
+			if len(origin) >= 2 && strings.HasPrefix(origin, "/") && strings.HasSuffix(origin, "/") {
+				if _, err := regexp.Compile(origin[1 : len(origin)-1]); err != nil {
+					result.AddError(nil, errEnvAllowedOriginInvalid(envName, origin, err))
+				}
+			}
+		}
+		var patterns []string
+		for _, list := range []ct.OptStringList{envConfig.FlagFilterInclude, envConfig.FlagFilterExclude,
+			envConfig.ClientSideFlagInclude, envConfig.ClientSideFlagExclude} {
+			patterns = append(patterns, list.Values()...)
+		}
+		for _, pattern := range patterns {
+			if strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+				result.AddError(nil, errEnvFlagFilterPatternInvalid(envName, pattern))
+			}
+		}
+		validateConfigEnvCanary(result, c, envName, envConfig)
+	}
+}
+
+// validateConfigFlagOverrides must be called after validateConfigDatabases, which sets the database URLs.
+// Overridden flags are written to the data store, so they cannot be used with a persistent store that
+// might be shared with other Relay instances or with SDKs in daemon mode.
+func validateConfigFlagOverrides(result *ct.ValidationResult, c *Config) {
+	_, usesGlobalDatabase := databaseLocalTTL(*c)
+	for envName, envConfig := range c.Environment {
+		if envConfig.FlagOverridesFile != "" && (usesGlobalDatabase || envConfig.Database != "") {
+			result.AddError(nil, errEnvFlagOverridesWithDatabase(envName))
+		}
+	}
+}
+
+func validateConfigEnvCanary(result *ct.ValidationResult, c *Config, envName string, envConfig *EnvConfig) {
+	if envConfig.CanaryEnvironment == "" && envConfig.CanaryHeader == "" && envConfig.CanaryHeaderValue == "" {
+		return
+	}
+	if envConfig.CanaryEnvironment == "" || envConfig.CanaryHeader == "" {
+		result.AddError(nil, errEnvCanaryIncomplete(envName))
+		return
+	}
+	canary := c.Environment[envConfig.CanaryEnvironment]
+	switch {
+	case canary == nil:
+		result.AddError(nil, errEnvUnknownCanary(envName, envConfig.CanaryEnvironment))
+	case envConfig.CanaryEnvironment == envName, canary.CanaryEnvironment != "", canary.Tenant != envConfig.Tenant:
+		result.AddError(nil, errEnvCanaryNotAllowed(envName, envConfig.CanaryEnvironment))
 	}
 }
 
 func validateConfigDatabases(result *ct.ValidationResult, c *Config, loggers ldlog.Loggers) {
-	normalizeRedisConfig(result, c)
+	normalizeRedisConfig(result, &c.Redis)
 
 	databases := []string{}
 	if c.Redis.URL.IsDefined() {
 		databases = append(databases, "Redis")
+		validateConfigRedisTLS(result, c.Redis)
+		validateConfigRedisPool(result, c.Redis)
 	}
 	if c.Consul.Host != "" {
 		databases = append(databases, "Consul")
@@ -139,6 +845,17 @@ func validateConfigDatabases(result *ct.ValidationResult, c *Config, loggers ldl
 	if c.DynamoDB.Enabled {
 		databases = append(databases, "DynamoDB")
 	}
+	if c.Postgres.URL.IsDefined() {
+		databases = append(databases, "Postgres")
+	}
+	if c.MongoDB.URL.IsDefined() {
+		databases = append(databases, "MongoDB")
+	}
+	if len(c.Etcd.Endpoints.Values()) != 0 {
+		databases = append(databases, "etcd")
+	}
+
+	validateConfigNamedDatabases(result, c, loggers)
 
 	if len(databases) == 0 {
 		return
@@ -149,55 +866,738 @@ func validateConfigDatabases(result *ct.ValidationResult, c *Config, loggers ldl
 	}
 
 	if c.Consul.Host != "" {
-		if c.Consul.Token != "" && c.Consul.TokenFile != "" {
-			result.AddError(nil, errConsulTokenAndTokenFile)
+		validateConfigConsul(result, c.Consul)
+	}
+	if c.DynamoDB.Enabled {
+		validateConfigDynamoDB(result, c.DynamoDB)
+	}
+	if c.Postgres.URL.IsDefined() {
+		validateConfigPostgres(result, c.Postgres)
+	}
+	if c.MongoDB.URL.IsDefined() {
+		validateConfigMongoDB(result, c.MongoDB)
+	}
+	if len(c.Etcd.Endpoints.Values()) != 0 {
+		validateConfigEtcd(result, c.Etcd)
+	}
+
+	// Environments that select a named database are checked in validateConfigNamedDatabases.
+	envsUsingGlobalDatabase := make(map[string]*EnvConfig, len(c.Environment))
+	for name, e := range c.Environment {
+		if e.Database == "" {
+			envsUsingGlobalDatabase[name] = e
+		}
+	}
+	validateConfigEnvDBDisambiguation(result, envsUsingGlobalDatabase, c.DynamoDB.Enabled, loggers)
+
+	if c.AutoConfig.Key != "" {
+		// In auto-config mode we must assume that there are multiple environments.
+		if !strings.Contains(c.AutoConfig.EnvDatastorePrefix, AutoConfigEnvironmentIDPlaceholder) &&
+			!(c.DynamoDB.Enabled && strings.Contains(c.AutoConfig.EnvDatastoreTableName, AutoConfigEnvironmentIDPlaceholder)) {
+			result.AddError(nil, errAutoConfWithoutDBDisambig)
+		}
+	}
+}
+
+// validateConfigNamedDatabases checks the databases in RedisDatabase, ConsulDatabase, DynamoDBDatabase,
+// PostgresDatabase, MongoDBDatabase, and EtcdDatabase in the same way as the global database, and checks that every environment that selects
+// one of them by name refers to a database that exists.
+func validateConfigNamedDatabases(result *ct.ValidationResult, c *Config, loggers ldlog.Loggers) {
+	kinds := make(map[string][]string)
+	for name, rc := range c.RedisDatabase {
+		kinds[name] = append(kinds[name], "Redis")
+		normalizeRedisConfig(result, rc)
+		if !rc.URL.IsDefined() {
+			result.AddError(nil, errNamedDatabaseWithoutServer("Redis", name))
+			continue
+		}
+		validateConfigRedisTLS(result, *rc)
+		validateConfigRedisPool(result, *rc)
+	}
+	for name, cc := range c.ConsulDatabase {
+		kinds[name] = append(kinds[name], "Consul")
+		if cc.Host == "" {
+			result.AddError(nil, errNamedDatabaseWithoutServer("Consul", name))
+		}
+		validateConfigConsul(result, *cc)
+	}
+	for name, dc := range c.DynamoDBDatabase {
+		kinds[name] = append(kinds[name], "DynamoDB")
+		dc.Enabled = true // implied by the section, but set for consistency with the global DynamoDB section
+		validateConfigDynamoDB(result, *dc)
+	}
+	for name, pc := range c.PostgresDatabase {
+		kinds[name] = append(kinds[name], "Postgres")
+		if !pc.URL.IsDefined() {
+			result.AddError(nil, errNamedDatabaseWithoutServer("Postgres", name))
+			continue
+		}
+		validateConfigPostgres(result, *pc)
+	}
+	for name, mc := range c.MongoDBDatabase {
+		kinds[name] = append(kinds[name], "MongoDB")
+		if !mc.URL.IsDefined() {
+			result.AddError(nil, errNamedDatabaseWithoutServer("MongoDB", name))
+			continue
+		}
+		validateConfigMongoDB(result, *mc)
+	}
+	for name, tc := range c.EtcdDatabase {
+		kinds[name] = append(kinds[name], "etcd")
+		if len(tc.Endpoints.Values()) == 0 {
+			result.AddError(nil, errNamedDatabaseWithoutServer("etcd", name))
+			continue
+		}
+		validateConfigEtcd(result, *tc)
+	}
+	for name, k := range kinds {
+		if len(k) > 1 {
+			result.AddError(nil, errDatabaseNameConflict(name, k))
+		}
+	}
+
+	envsByDatabase := make(map[string]map[string]*EnvConfig)
+	for envName, e := range c.Environment {
+		if e.Database == "" {
+			continue
+		}
+		if len(kinds[e.Database]) == 0 {
+			result.AddError(nil, errEnvUnknownDatabase(envName, e.Database))
+			continue
+		}
+		if envsByDatabase[e.Database] == nil {
+			envsByDatabase[e.Database] = make(map[string]*EnvConfig)
+		}
+		envsByDatabase[e.Database][envName] = e
+	}
+	for name, envs := range envsByDatabase {
+		validateConfigEnvDBDisambiguation(result, envs, c.DynamoDBDatabase[name] != nil, loggers)
+	}
+}
+
+// validateConfigEnvDBDisambiguation checks the environments that use the same database. If there is more
+// than one, they must be distinguished by different prefixes (or, when using DynamoDB, you can use
+// different table names).
+func validateConfigEnvDBDisambiguation(
+	result *ct.ValidationResult,
+	envs map[string]*EnvConfig,
+	isDynamoDB bool,
+	loggers ldlog.Loggers,
+) {
+	for name, e := range envs {
+		if e.Prefix == "" && !(isDynamoDB && e.TableName != "") {
+			if len(envs) == 1 {
+				loggers.Warn(warnEnvWithoutDBDisambiguation(name, isDynamoDB))
+			} else {
+				result.AddError(nil, errEnvWithoutDBDisambiguation(name, isDynamoDB))
+			}
+		}
+	}
+}
+
+func validateConfigConsul(result *ct.ValidationResult, cc ConsulConfig) {
+	if cc.Token != "" && cc.TokenFile != "" {
+		result.AddError(nil, errConsulTokenAndTokenFile)
+	}
+}
+
+func validateConfigDynamoDB(result *ct.ValidationResult, dc DynamoDBConfig) {
+	if (dc.AccessKeyID == "") != (dc.SecretAccessKey == "") {
+		result.AddError(nil, errDynamoDBIncompleteCredentials)
+	}
+	validateConfigNonNegativeDurations(result, []namedDuration{
+		{"DynamoDB dial timeout", dc.DialTimeout},
+		{"DynamoDB read timeout", dc.ReadTimeout},
+	})
+}
+
+func validateConfigPostgres(result *ct.ValidationResult, pc PostgresConfig) {
+	if pc.TLSMode != "" && !isValidPostgresTLSMode(pc.TLSMode) {
+		result.AddError(nil, errPostgresBadTLSMode(pc.TLSMode))
+	}
+	if (pc.TLSCertFile == "") != (pc.TLSKeyFile == "") {
+		result.AddError(nil, errPostgresCertWithoutKey)
+	}
+}
+
+func validateConfigMongoDB(result *ct.ValidationResult, mc MongoDBConfig) {
+	if mc.WriteConcern != "" && mc.WriteConcern != "majority" {
+		if n, err := strconv.Atoi(mc.WriteConcern); err != nil || n < 0 {
+			result.AddError(nil, errMongoDBBadWriteConcern(mc.WriteConcern))
+		}
+	}
+	validateConfigNonNegativeDurations(result, []namedDuration{
+		{"MongoDB write concern timeout", mc.WriteConcernTimeout},
+	})
+	if !mc.TLS && (mc.TLSCAFile != "" || mc.TLSCertFile != "" || mc.TLSKeyFile != "") {
+		result.AddError(nil, errMongoDBTLSOptionsWithoutTLS)
+	}
+	if (mc.TLSCertFile == "") != (mc.TLSKeyFile == "") {
+		result.AddError(nil, errMongoDBCertWithoutKey)
+	}
+}
+
+func validateConfigEtcd(result *ct.ValidationResult, tc EtcdConfig) {
+	if tc.Password != "" && tc.Username == "" {
+		result.AddError(nil, errEtcdPasswordWithoutUsername)
+	}
+	validateConfigNonNegativeDurations(result, []namedDuration{
+		{"etcd dial timeout", tc.DialTimeout},
+	})
+	if !tc.TLS && (tc.TLSCAFile != "" || tc.TLSCertFile != "" || tc.TLSKeyFile != "") {
+		result.AddError(nil, errEtcdTLSOptionsWithoutTLS)
+	}
+	if (tc.TLSCertFile == "") != (tc.TLSKeyFile == "") {
+		result.AddError(nil, errEtcdCertWithoutKey)
+	}
+}
+
+// validateConfigReadReplica is called after validateConfigDatabases, which sets Redis.URL if a host or
+// port was given.
+func validateConfigReadReplica(result *ct.ValidationResult, c *Config) {
+	if !c.ReadReplica.Enabled {
+		if c.ReadReplica.PollInterval.IsDefined() {
+			result.AddError(nil, errReadReplicaPropertiesWithoutMode)
+		}
+		return
+	}
+	// Environments that select a named database read from that database instead of the global one.
+	usesGlobalDatabase := len(c.Environment) == 0
+	infiniteCache := false
+	for _, e := range c.Environment {
+		if e.Database == "" {
+			usesGlobalDatabase = true
+		} else if envConfig, err := EnvironmentDatabaseConfig(*c, *e); err == nil {
+			localTTL, _ := databaseLocalTTL(envConfig)
+			infiniteCache = infiniteCache || localTTL.GetOrElse(0) < 0
+		}
+	}
+	if usesGlobalDatabase {
+		localTTL, ok := databaseLocalTTL(*c)
+		if !ok {
+			result.AddError(nil, errReadReplicaWithoutDatabase)
 		}
+		infiniteCache = infiniteCache || localTTL.GetOrElse(0) < 0
+	}
+	if infiniteCache {
+		result.AddError(nil, errReadReplicaWithInfiniteCache)
+	}
+	if c.AutoConfig.Key != "" {
+		result.AddError(nil, errReadReplicaWithAutoConf)
+	}
+	if c.OfflineMode.FileDataSource != "" {
+		result.AddError(nil, errReadReplicaWithFileData)
+	}
+	if c.Main.UpstreamRelayURI.IsDefined() {
+		result.AddError(nil, errReadReplicaWithUpstreamRelay)
 	}
+	if c.BigSegments.Type == BigSegmentsStoreTypeMemory {
+		result.AddError(nil, errReadReplicaWithMemoryBigSegments)
+	}
+}
 
-	// When using a database, if there is more than one environment configured, they must be distinguished by
-	// different prefixes (or, when using DynamoDB, you can use different table names). In auto-config mode,
-	// we must assume that there are multiple environments.
+// databaseLocalTTL returns the cache TTL of whichever database is enabled, or false if none is.
+func databaseLocalTTL(c Config) (ct.OptDuration, bool) {
 	switch {
-	case len(c.Environment) == 1:
-		for name, e := range c.Environment {
-			if e.Prefix == "" && !(c.DynamoDB.Enabled && e.TableName != "") {
-				loggers.Warn(warnEnvWithoutDBDisambiguation(name, c.DynamoDB.Enabled))
+	case c.Redis.URL.IsDefined():
+		return c.Redis.LocalTTL, true
+	case c.Consul.Host != "":
+		return c.Consul.LocalTTL, true
+	case c.DynamoDB.Enabled:
+		return c.DynamoDB.LocalTTL, true
+	case c.Postgres.URL.IsDefined():
+		return c.Postgres.LocalTTL, true
+	case c.MongoDB.URL.IsDefined():
+		return c.MongoDB.LocalTTL, true
+	case len(c.Etcd.Endpoints.Values()) != 0:
+		return ct.OptDuration{}, true // etcd data is not cached with a TTL
+	}
+	return ct.OptDuration{}, false
+}
+
+func validateConfigBigSegments(result *ct.ValidationResult, c *Config) {
+	if c.BigSegments.Type != BigSegmentsStoreTypeMemory &&
+		(c.BigSegments.SnapshotDir != "" || c.BigSegments.SnapshotInterval.IsDefined()) {
+		result.AddError(nil, errBigSegmentsSnapshotWithoutMemoryStore)
+	}
+	if c.BigSegments.Type == BigSegmentsStoreTypeS3 {
+		if c.BigSegments.S3Bucket == "" {
+			result.AddError(nil, errBigSegmentsS3WithoutBucket)
+		}
+	} else if c.BigSegments.S3Bucket != "" || c.BigSegments.S3Prefix != "" || c.BigSegments.S3PollInterval.IsDefined() {
+		result.AddError(nil, errBigSegmentsS3WithoutS3Store)
+	}
+	switch c.BigSegments.CircuitBreakerFallback {
+	case "", BigSegmentsCircuitBreakerFallbackNotIncluded, BigSegmentsCircuitBreakerFallbackLastKnown:
+	default:
+		result.AddError(nil, errBigSegmentsBadCircuitBreakerFallback(c.BigSegments.CircuitBreakerFallback))
+	}
+	if c.BigSegments.NegativeCacheTime.IsDefined() && !c.BigSegments.NegativeCacheSize.IsDefined() {
+		result.AddError(nil, errBigSegmentsNegativeCacheTimeWithoutSize)
+	}
+}
+
+// validateConfigLeaderElection is called after validateConfigDatabases, which sets Redis.URL if a host or
+// port was given.
+func validateConfigLeaderElection(result *ct.ValidationResult, c *Config) {
+	le := c.LeaderElection
+	switch le.Type {
+	case "":
+		if le != (LeaderElectionConfig{}) {
+			result.AddError(nil, errLeaderElectionPropertiesWithoutType)
+		}
+		return
+	case LeaderElectionTypeRedis:
+		if !c.Redis.URL.IsDefined() {
+			result.AddError(nil, errLeaderElectionRedisWithoutRedis)
+		}
+	case LeaderElectionTypeKubernetes:
+	default:
+		result.AddError(nil, errLeaderElectionBadType(le.Type))
+	}
+	if le.RenewInterval.GetOrElse(DefaultLeaderElectionRenewInterval) >=
+		le.LeaseDuration.GetOrElse(DefaultLeaderElectionLeaseDuration) {
+		result.AddError(nil, errLeaderElectionRenewNotBelowLease)
+	}
+	if c.ReadReplica.Enabled {
+		result.AddError(nil, errLeaderElectionWithReadReplica)
+	}
+	if c.OfflineMode.FileDataSource != "" {
+		result.AddError(nil, errLeaderElectionWithFileData)
+	}
+}
+
+func validateConfigReplication(result *ct.ValidationResult, c *Config) {
+	rc := c.Replication
+	databases := rc.Database.Values()
+	if len(databases) == 0 {
+		if rc.QueueSize.IsDefined() || rc.RetryInterval.IsDefined() {
+			result.AddError(nil, errReplicationWithoutDatabases)
+		}
+		return
+	}
+	for _, name := range databases {
+		if _, err := EnvironmentDatabaseConfig(*c, EnvConfig{Database: name}); err != nil {
+			result.AddError(nil, errReplicationUnknownDatabase(name))
+			continue
+		}
+		for envName, e := range c.Environment {
+			if e.Database == name {
+				result.AddError(nil, errEnvReplicationToOwnDatabase(envName, name))
 			}
 		}
+	}
+	if rc.RetryInterval.IsDefined() && rc.RetryInterval.GetOrElse(0) <= 0 {
+		result.AddError(nil, errReplicationRetryIntervalNotPositive)
+	}
+	if c.ReadReplica.Enabled {
+		result.AddError(nil, errReplicationWithReadReplica)
+	}
+	if c.OfflineMode.FileDataSource != "" {
+		result.AddError(nil, errReplicationWithFileData)
+	}
+}
+
+func validateConfigEvents(result *ct.ValidationResult, c *Config) {
+	switch c.Events.DiagnosticEvents {
+	case "", DiagnosticEventsForward, DiagnosticEventsBlock, DiagnosticEventsAnnotate:
+	default:
+		result.AddError(nil, errBadDiagnosticEvents(c.Events.DiagnosticEvents))
+	}
+	if !c.Events.Coordinate {
+		return
+	}
+	// This is checked after validateConfigDatabases, which sets Redis.URL if a host or port was given.
+	// Environments that select a named database coordinate through that database, so it must be Redis.
+	usesGlobalDatabase := len(c.Environment) == 0
+	for name, e := range c.Environment {
+		if e.Database == "" {
+			usesGlobalDatabase = true
+		} else if c.RedisDatabase[e.Database] == nil {
+			result.AddError(nil, errEnvCoordinateWithoutRedis(name))
+		}
+	}
+	if usesGlobalDatabase && !c.Redis.URL.IsDefined() {
+		result.AddError(nil, errEventsCoordinateWithoutRedis)
+	}
+}
 
-	case len(c.Environment) > 1:
-		for name, e := range c.Environment {
-			if e.Prefix == "" && !(c.DynamoDB.Enabled && e.TableName != "") {
-				result.AddError(nil, errEnvWithoutDBDisambiguation(name, c.DynamoDB.Enabled))
+func validateConfigEventExport(result *ct.ValidationResult, c *Config) {
+	configured := map[string]bool{
+		EventExportKafka:   validateConfigKafka(result, c.Kafka),
+		EventExportKinesis: validateConfigKinesis(result, c.Kinesis),
+		EventExportPubSub:  validateConfigPubSub(result, c.PubSub),
+	}
+	if !c.Events.SendEvents && (configured[EventExportKafka] || configured[EventExportKinesis] || configured[EventExportPubSub]) {
+		result.AddError(nil, errEventExportWithoutEvents)
+	}
+	for envName, envConfig := range c.Environment {
+		names := envConfig.EventExport.Values()
+		for _, name := range names {
+			switch name {
+			case EventExportNone:
+				if len(names) > 1 {
+					result.AddError(nil, errEnvEventExportNoneWithOthers(envName))
+				}
+			case EventExportKafka, EventExportKinesis, EventExportPubSub:
+				if !configured[name] {
+					result.AddError(nil, errEnvEventExportNotConfigured(envName, name))
+				}
+			default:
+				result.AddError(nil, errEnvEventExportUnknown(envName, name))
 			}
 		}
+	}
+}
 
-	case c.AutoConfig.Key != "":
-		// Same as previous case, except that in auto-config mode we must assume that there are multiple environments.
-		if !strings.Contains(c.AutoConfig.EnvDatastorePrefix, AutoConfigEnvironmentIDPlaceholder) &&
-			!(c.DynamoDB.Enabled && strings.Contains(c.AutoConfig.EnvDatastoreTableName, AutoConfigEnvironmentIDPlaceholder)) {
-			result.AddError(nil, errAutoConfWithoutDBDisambig)
+// validateEventExportModeAndFormat checks the properties that all of the event export destinations have.
+func validateEventExportModeAndFormat(result *ct.ValidationResult, destination, mode, format string) {
+	switch mode {
+	case "", EventExportModeTee, EventExportModeRedirect:
+	default:
+		result.AddError(nil, errEventExportBadMode(destination, mode))
+	}
+	switch format {
+	case "", EventExportFormatEvent, EventExportFormatEnvelope:
+	default:
+		result.AddError(nil, errEventExportBadFormat(destination, format))
+	}
+}
+
+// validateConfigKafka returns true if Kafka export is enabled.
+func validateConfigKafka(result *ct.ValidationResult, k KafkaConfig) bool {
+	if len(k.Brokers.Values()) == 0 {
+		if k.Topic != "" || k.Mode != "" || k.Format != "" || k.TLSEnabled || k.SASLMechanism != "" ||
+			k.Username != "" || k.Password != "" {
+			result.AddError(nil, errKafkaPropertiesWithoutBrokers)
 		}
+		return false
 	}
+	if k.Topic == "" {
+		result.AddError(nil, errKafkaWithoutTopic)
+	}
+	validateEventExportModeAndFormat(result, "Kafka", k.Mode, k.Format)
+	switch k.SASLMechanism {
+	case "":
+		if k.Username != "" || k.Password != "" {
+			result.AddError(nil, errKafkaCredentialsWithoutSASL)
+		}
+	case KafkaSASLPlain, KafkaSASLSCRAMSHA256, KafkaSASLSCRAMSHA512:
+		if k.Username == "" || k.Password == "" {
+			result.AddError(nil, errKafkaSASLWithoutCredentials)
+		}
+	default:
+		result.AddError(nil, errKafkaBadSASLMechanism(k.SASLMechanism))
+	}
+	return true
 }
 
-func normalizeRedisConfig(result *ct.ValidationResult, c *Config) {
-	if c.Redis.URL.IsDefined() {
-		if c.Redis.Host != "" || c.Redis.Port.IsDefined() {
+// validateConfigKinesis returns true if Kinesis export is enabled.
+func validateConfigKinesis(result *ct.ValidationResult, k KinesisConfig) bool {
+	if k.StreamName == "" {
+		if k.Region != "" || k.URL.IsDefined() || k.Mode != "" || k.Format != "" {
+			result.AddError(nil, errKinesisPropertiesWithoutStream)
+		}
+		return false
+	}
+	validateEventExportModeAndFormat(result, "Kinesis", k.Mode, k.Format)
+	return true
+}
+
+// validateConfigPubSub returns true if Pub/Sub export is enabled.
+func validateConfigPubSub(result *ct.ValidationResult, p PubSubConfig) bool {
+	if p.Topic == "" {
+		if p.ProjectID != "" || p.Mode != "" || p.Format != "" {
+			result.AddError(nil, errPubSubPropertiesWithoutTopic)
+		}
+		return false
+	}
+	if p.ProjectID == "" && !strings.HasPrefix(p.Topic, "projects/") {
+		result.AddError(nil, errPubSubTopicWithoutProject)
+	}
+	validateEventExportModeAndFormat(result, "Pub/Sub", p.Mode, p.Format)
+	return true
+}
+
+func validateConfigSnapshots(result *ct.ValidationResult, sc SnapshotsConfig) {
+	hasS3Properties := sc.S3Region != "" || sc.S3URL.IsDefined()
+	hasAzureProperties := sc.AzureAccount != "" || sc.AzureSASToken != "" || sc.AzureURL.IsDefined()
+	if sc.Type == "" {
+		if sc.Bucket != "" || sc.Prefix != "" || sc.Interval.IsDefined() || sc.MaxAge.IsDefined() ||
+			sc.MaxCount.IsDefined() || hasS3Properties || hasAzureProperties {
+			result.AddError(nil, errSnapshotPropertiesWithoutType)
+		}
+		return
+	}
+	switch sc.Type {
+	case SnapshotStoreTypeS3, SnapshotStoreTypeGCS, SnapshotStoreTypeAzure:
+	default:
+		result.AddError(nil, errSnapshotBadStoreType(sc.Type))
+		return
+	}
+	if sc.Bucket == "" {
+		result.AddError(nil, errSnapshotWithoutBucket)
+	}
+	if sc.Type != SnapshotStoreTypeS3 && hasS3Properties {
+		result.AddError(nil, errSnapshotS3WithoutS3Store)
+	}
+	if sc.Type == SnapshotStoreTypeAzure {
+		if sc.AzureAccount == "" && !sc.AzureURL.IsDefined() {
+			result.AddError(nil, errSnapshotAzureWithoutAccount)
+		}
+		if sc.AzureSASToken == "" {
+			result.AddError(nil, errSnapshotAzureWithoutSASToken)
+		}
+	} else if hasAzureProperties {
+		result.AddError(nil, errSnapshotAzureWithoutAzureStore)
+	}
+}
+
+func isValidPostgresTLSMode(mode string) bool {
+	for _, m := range validPostgresTLSModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeRedisConfig(result *ct.ValidationResult, rc *RedisConfig) {
+	if rc.SentinelPassword != "" && len(rc.SentinelAddrs.Values()) == 0 {
+		result.AddError(nil, errRedisSentinelPasswordWithoutSentinel)
+	}
+	if (rc.SentinelMasterName != "") != (len(rc.SentinelAddrs.Values()) != 0) {
+		result.AddError(nil, errRedisSentinelWithoutMasterName)
+	}
+	addrs := rc.ClusterAddrs.Values()
+	if len(rc.SentinelAddrs.Values()) != 0 {
+		if len(addrs) != 0 {
+			result.AddError(nil, errRedisClusterAndSentinel)
+		}
+		addrs = rc.SentinelAddrs.Values()
+	}
+	if len(addrs) != 0 {
+		for _, addr := range addrs {
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				result.AddError(nil, errRedisBadAddress(addr))
+				return
+			}
+		}
+		// Since ValidateConfig can be called more than once, a URL that we set on a previous call is
+		// not an error.
+		firstURL, err := ct.NewOptURLAbsoluteFromString("redis://" + addrs[0])
+		if err != nil {
+			result.AddError(nil, errRedisBadAddress(addrs[0]))
+			return
+		}
+		if rc.Host != "" || rc.Port.IsDefined() ||
+			(rc.URL.IsDefined() && rc.URL.String() != firstURL.String()) {
+			result.AddError(nil, errRedisClusterWithURL)
+		}
+		rc.URL = firstURL
+		return
+	}
+	if rc.URL.IsDefined() {
+		if rc.Host != "" || rc.Port.IsDefined() {
 			result.AddError(nil, errRedisURLWithHostAndPort)
 		}
-	} else if c.Redis.Host != "" || c.Redis.Port.IsDefined() {
-		host := c.Redis.Host
+	} else if rc.Host != "" || rc.Port.IsDefined() {
+		host := rc.Host
 		if host == "" {
 			host = defaultRedisHost
 		}
-		port := c.Redis.Port.GetOrElse(defaultRedisPort)
+		port := rc.Port.GetOrElse(defaultRedisPort)
 		url, err := ct.NewOptURLAbsoluteFromString(fmt.Sprintf("redis://%s:%d", host, port))
 		if err != nil {
 			result.AddError(nil, errRedisBadHostname)
 		}
-		c.Redis.URL = url
-		c.Redis.Host = ""
-		c.Redis.Port = ct.OptIntGreaterThanZero{}
+		rc.URL = url
+		rc.Host = ""
+		rc.Port = ct.OptIntGreaterThanZero{}
+	}
+}
+
+// validateConfigRedisTLS is called after normalizeRedisConfig, so URL is always set. TLS is enabled
+// either by the TLS property or by a rediss: URL.
+func validateConfigRedisTLS(result *ct.ValidationResult, rc RedisConfig) {
+	if rc.CAFile == "" && rc.CertFile == "" && rc.KeyFile == "" && rc.ServerName == "" {
+		return
+	}
+	if !rc.TLS && rc.URL.Get().Scheme != "rediss" {
+		result.AddError(nil, errRedisTLSOptionsWithoutTLS)
+	}
+	if (rc.CertFile == "") != (rc.KeyFile == "") {
+		result.AddError(nil, errRedisCertWithoutKey)
+	}
+}
+
+func validateConfigRedisPool(result *ct.ValidationResult, rc RedisConfig) {
+	if rc.MinIdleConns.IsDefined() && rc.PoolSize.IsDefined() &&
+		rc.MinIdleConns.GetOrElse(0) > rc.PoolSize.GetOrElse(0) {
+		result.AddError(nil, errRedisMinIdleConnsOverPoolSize)
+	}
+	validateConfigNonNegativeDurations(result, []namedDuration{
+		{"Redis max connection lifetime", rc.MaxConnLifetime},
+		{"Redis dial timeout", rc.DialTimeout},
+		{"Redis read timeout", rc.ReadTimeout},
+	})
+}
+
+type namedDuration struct {
+	name     string
+	duration ct.OptDuration
+}
+
+func validateConfigNonNegativeDurations(result *ct.ValidationResult, durations []namedDuration) {
+	for _, d := range durations {
+		if d.duration.GetOrElse(0) < 0 {
+			result.AddError(nil, errNegativeDuration(d.name))
+		}
+	}
+}
+
+func validateConfigLazyEnvironmentInit(result *ct.ValidationResult, c *Config) {
+	if !c.Main.LazyEnvironmentInit {
+		if c.Main.EnvironmentIdleTimeout.IsDefined() {
+			result.AddError(nil, errEnvIdleTimeoutWithoutLazyInit)
+		}
+		return
+	}
+	if c.OfflineMode.FileDataSource != "" {
+		result.AddError(nil, errLazyEnvInitWithFileData)
+	}
+	if c.Main.GRPCPort.IsDefined() {
+		result.AddError(nil, errLazyEnvInitWithGRPC)
+	}
+	validateConfigNonNegativeDurations(result, []namedDuration{
+		{"environment idle timeout", c.Main.EnvironmentIdleTimeout},
+	})
+}
+
+func hasAutoConfigQuotaProperties(ac AutoConfigConfig) bool {
+	return ac.EnvMaxStreamConnections.IsDefined() || ac.EnvMaxEventsPerSecond.IsDefined() ||
+		ac.EnvMemoryBudget.IsDefined() || ac.EnvQuotaOverflow != "" || ac.EnvQuotaQueueTimeout.IsDefined()
+}
+
+func validateConfigAutoConfigQuotas(result *ct.ValidationResult, ac AutoConfigConfig) {
+	if ac.EnvMaxEventsPerSecond.IsDefined() && ac.EnvMaxEventsPerSecond.GetOrElse(0) <= 0 {
+		result.AddError(nil, errAutoConfMaxEventsNotPositive)
+	}
+	switch ac.EnvQuotaOverflow {
+	case "", AutoConfigQuotaOverflowReject, AutoConfigQuotaOverflowQueue:
+	default:
+		result.AddError(nil, errAutoConfBadQuotaOverflow(ac.EnvQuotaOverflow))
+	}
+	validateConfigNonNegativeDurations(result, []namedDuration{
+		{"auto-configuration quota queue timeout", ac.EnvQuotaQueueTimeout},
+	})
+}
+
+func validateConfigRateLimit(result *ct.ValidationResult, c *Config) {
+	type namedRate struct {
+		name string
+		rate ct.OptFloat64
+	}
+	for _, r := range []namedRate{
+		{"ServerSide", c.RateLimit.ServerSide},
+		{"Mobile", c.RateLimit.Mobile},
+		{"ClientSide", c.RateLimit.ClientSide},
+		{"PerClientIP", c.RateLimit.PerClientIP},
+	} {
+		if r.rate.IsDefined() && r.rate.GetOrElse(0) <= 0 {
+			result.AddError(nil, errRateLimitNotPositive(r.name))
+		}
+	}
+	for _, value := range c.RateLimit.TrustedProxies.Values() {
+		if _, _, err := net.ParseCIDR(value); err != nil && net.ParseIP(value) == nil {
+			result.AddError(nil, errRateLimitInvalidTrustedProxy(value))
+		}
+	}
+	if c.RateLimit.UseForwardedFor && len(c.RateLimit.TrustedProxies.Values()) == 0 {
+		result.AddError(nil, errRateLimitForwardedForNoProxies)
+	}
+	for envName, envConfig := range c.Environment {
+		for _, r := range []namedRate{
+			{"RateLimitServerSide", envConfig.RateLimitServerSide},
+			{"RateLimitMobile", envConfig.RateLimitMobile},
+			{"RateLimitClientSide", envConfig.RateLimitClientSide},
+		} {
+			if r.rate.IsDefined() && r.rate.GetOrElse(0) <= 0 {
+				result.AddError(nil, errEnvRateLimitNotPositive(envName, r.name))
+			}
+		}
+	}
+}
+
+func validateConfigStreamLimits(result *ct.ValidationResult, c *Config) {
+	switch c.StreamLimits.Overflow {
+	case "", StreamLimitOverflowReject, StreamLimitOverflowQueue:
+	default:
+		result.AddError(nil, errStreamLimitBadOverflow(c.StreamLimits.Overflow))
+	}
+}
+
+func validateConfigMemoryLimits(result *ct.ValidationResult, c *Config) {
+	m := c.MemoryLimits
+	if !m.HighWaterMarkMB.IsDefined() {
+		if m.LowWaterMarkMB.IsDefined() || m.CheckInterval.IsDefined() {
+			result.AddError(nil, errMemoryLimitsWithoutHighWaterMark)
+		}
+		return
+	}
+	if m.LowWaterMarkMB.GetOrElse(0) >= m.HighWaterMarkMB.GetOrElse(0) {
+		result.AddError(nil, errMemoryLowWaterMarkNotBelowHigh)
+	}
+	if m.CheckInterval.IsDefined() && m.CheckInterval.GetOrElse(0) <= 0 {
+		result.AddError(nil, errMemoryCheckIntervalNotPositive)
+	}
+}
+
+func validateConfigShadow(result *ct.ValidationResult, c *Config) {
+	if !c.Shadow.URI.IsDefined() {
+		if c.Shadow.Percentage.IsDefined() || c.Shadow.Timeout.IsDefined() {
+			result.AddError(nil, errShadowPropertiesWithoutURI)
+		}
+		return
+	}
+	if p := c.Shadow.Percentage.GetOrElse(DefaultShadowPercentage); p <= 0 || p > 100 {
+		result.AddError(nil, errShadowPercentageOutOfRange)
+	}
+}
+
+func validateConfigResponseHeaders(result *ct.ValidationResult, c *Config) {
+	rh := c.ResponseHeaders
+	for _, headers := range [][]string{rh.Header, rh.PollingHeader, rh.StreamingHeader, rh.EventsHeader} {
+		if _, err := ParseResponseHeaders(headers); err != nil {
+			result.AddError(nil, err)
+			return
+		}
+	}
+}
+
+func validateConfigProxy(result *ct.ValidationResult, c *Config) {
+	if _, err := ParseProxyDestinations(c.Proxy.Destination.Values()); err != nil {
+		result.AddError(nil, err)
+	}
+}
+
+func validateConfigOpenTelemetry(result *ct.ValidationResult, c *Config) {
+	otel := c.MetricsConfig.OpenTelemetry
+	if ratio := otel.SamplingRatio.GetOrElse(1); ratio < 0 || ratio > 1 {
+		result.AddError(nil, errOpenTelemetrySamplingRatio)
+	}
+	if !isValidHeaderList(otel.Header) {
+		result.AddError(nil, errOpenTelemetryBadHeader)
+	}
+}
+
+// isValidHeaderList returns true if every value is in the form name=value. The header values aren't
+// included in the error messages for these properties, since they may contain credentials.
+func isValidHeaderList(headers ct.OptStringList) bool {
+	for _, header := range headers.Values() {
+		if strings.TrimSpace(strings.SplitN(header, "=", 2)[0]) == "" || !strings.Contains(header, "=") {
+			return false
+		}
 	}
+	return true
 }
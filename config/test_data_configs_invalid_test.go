@@ -1,5 +1,7 @@
 package config
 
+import "regexp"
+
 type testDataInvalidConfig struct {
 	name         string
 	envVarsError string
@@ -15,9 +17,65 @@ func makeInvalidConfigs() []testDataInvalidConfig {
 		makeInvalidConfigTLSWithNoCert(),
 		makeInvalidConfigTLSWithNoKey(),
 		makeInvalidConfigTLSVersion(),
+		makeInvalidConfigH2CWithTLS(),
+		makeInvalidConfigHTTP3WithoutTLS(),
+		makeInvalidConfigACMEWithoutTLS(),
+		makeInvalidConfigACMEWithCertAndKey(),
+		makeInvalidConfigACMEWithoutCacheDir(),
+		makeInvalidConfigACMEPropertiesWithoutDomain(),
+		makeInvalidConfigClientCertWithoutTLS(),
+		makeInvalidConfigClientCertEnvWithoutCA(),
+		makeInvalidConfigClientCertAutoConfWithoutCA(),
+		makeInvalidConfigClientCertEnvPropertiesWithoutRequired(),
+		makeInvalidConfigJWTPropertiesWithoutJWKSURL(),
+		makeInvalidConfigGRPCPortSameAsPort(),
+		makeInvalidConfigAdminPortWithoutKey(),
+		makeInvalidConfigAdminPortSameAsPort(),
+		makeInvalidConfigAdminPortSameAsGRPCPort(),
+		makeInvalidConfigAdminWithAutoConf(),
+		makeInvalidConfigAdminProfilingWithoutPort(),
+		makeInvalidConfigInternalMetricsPortSameAsPort(),
+		makeInvalidConfigInternalMetricsPortSameAsPrometheusPort(),
+		makeInvalidConfigAccessLogBadFormat(),
+		makeInvalidConfigAccessLogBadOutput(),
+		makeInvalidConfigAccessLogFileWithoutPath(),
+		makeInvalidConfigAccessLogSyslogBadScheme(),
+		makeInvalidConfigAuditLogBadWebhookHeader(),
+		makeInvalidConfigRateLimitNotPositive(),
+		makeInvalidConfigRateLimitInvalidTrustedProxy(),
+		makeInvalidConfigRateLimitForwardedForWithoutTrustedProxies(),
+		makeInvalidConfigShadowPropertiesWithoutURI(),
+		makeInvalidConfigShadowPercentageOutOfRange(),
+		makeInvalidConfigResponseHeaderWithoutColon(),
+		makeInvalidConfigProxyDestinationWithoutURL(),
+		makeInvalidConfigProxyProtocolSourcesNotEnabled(),
+		makeInvalidConfigProxyProtocolSourceInvalid(),
+		makeInvalidConfigUnixSocketOnlyWithoutPath(),
+		makeInvalidConfigUnixSocketMode(),
+		makeInvalidConfigUpstreamFallbackNotAbsolute(),
+		makeInvalidConfigStreamRetryJitterOutOfRange(),
+		makeInvalidConfigEnvStreamRetryJitterOutOfRange(),
+		makeInvalidConfigEnvRateLimitNotPositive(),
+		makeInvalidConfigStreamLimitBadOverflow(),
+		makeInvalidConfigMemoryLimitsWithoutHighWaterMark(),
+		makeInvalidConfigMemoryLowWaterMarkNotBelowHigh(),
+		makeInvalidConfigMemoryCheckIntervalNotPositive(),
+		makeInvalidConfigOpenTelemetrySamplingRatio(),
+		makeInvalidConfigOpenTelemetryBadHeader(),
+		makeInvalidConfigUpstreamRelayWithStreamURI(),
+		makeInvalidConfigUpstreamRelayWithAutoConf(),
+		makeInvalidConfigUpstreamRelayWithFileData(),
 		makeInvalidConfigAutoConfKeyWithEnvironments(),
 		makeInvalidConfigAutoConfAllowedOriginWithNoKey(),
 		makeInvalidConfigAutoConfAllowedHeaderWithNoKey(),
+		makeInvalidConfigAutoConfFilterWithNoKey(),
+		makeInvalidConfigAutoConfQuotaWithNoKey(),
+		makeInvalidConfigAutoConfQuotaBadOverflow(),
+		makeInvalidConfigEventsBadDiagnosticEvents(),
+		makeInvalidConfigAutoConfQuotaMaxEventsNotPositive(),
+		makeInvalidConfigEnvIdleTimeoutWithoutLazyInit(),
+		makeInvalidConfigLazyEnvInitWithFileData(),
+		makeInvalidConfigLazyEnvInitWithGRPC(),
 		makeInvalidConfigAutoConfPrefixWithNoKey(),
 		makeInvalidConfigAutoConfTableNameWithNoKey(),
 		makeInvalidConfigFileDataWithAutoConfKey(),
@@ -26,17 +84,105 @@ func makeInvalidConfigs() []testDataInvalidConfig {
 		makeInvalidConfigOfflineModeAllowedHeaderWithNoFile(),
 		makeInvalidConfigOfflineModePrefixWithNoFile(),
 		makeInvalidConfigOfflineModeTableNameWithNoFile(),
+		makeInvalidConfigNoOutboundConnectionsWithNoFile(),
+		makeInvalidConfigNoOutboundConnectionsWithEventExport(),
+		makeInvalidConfigNoOutboundConnectionsWithMetrics(),
+		makeInvalidConfigReadReplicaPropertiesWithoutMode(),
+		makeInvalidConfigReadReplicaWithoutDatabase(),
+		makeInvalidConfigReadReplicaWithInfiniteCache(),
+		makeInvalidConfigReadReplicaWithFileData(),
+		makeInvalidConfigLeaderElectionPropertiesWithoutType(),
+		makeInvalidConfigLeaderElectionBadType(),
+		makeInvalidConfigLeaderElectionRedisWithoutRedis(),
+		makeInvalidConfigLeaderElectionRenewNotBelowLease(),
+		makeInvalidConfigLeaderElectionWithReadReplica(),
+		makeInvalidConfigLeaderElectionWithFileData(),
+		makeInvalidConfigReplicationPropertiesWithoutDatabases(),
+		makeInvalidConfigReplicationUnknownDatabase(),
+		makeInvalidConfigReplicationToOwnDatabase(),
+		makeInvalidConfigReplicationRetryIntervalNotPositive(),
+		makeInvalidConfigReplicationWithReadReplica(),
+		makeInvalidConfigReplicationWithFileData(),
+		makeInvalidConfigFlagOverridesWithDatabase(),
+		makeInvalidConfigFlagFilterPattern(),
+		makeInvalidConfigPrivateAttributeKey(),
+		makeInvalidConfigHashPrivateAttributesWithoutAttributes(),
+		makeInvalidConfigEventSampleRate(),
 		makeInvalidConfigRedisInvalidHostname(),
 		makeInvalidConfigRedisInvalidDockerPort(),
 		makeInvalidConfigRedisConflictingParams(),
+		makeInvalidConfigRedisTLSOptionsWithoutTLS(),
+		makeInvalidConfigRedisCertWithoutKey(),
+		makeInvalidConfigRedisClusterAndSentinel(),
+		makeInvalidConfigRedisClusterWithURL(),
+		makeInvalidConfigRedisSentinelWithoutMasterName(),
+		makeInvalidConfigRedisClusterBadAddress(),
 		makeInvalidConfigRedisNoPrefix(),
 		makeInvalidConfigRedisAutoConfNoPrefix(),
+		makeInvalidConfigRedisMinIdleConnsOverPoolSize(),
+		makeInvalidConfigRedisNegativeTimeout(),
+		makeInvalidConfigEventsCoordinateWithoutRedis(),
+		makeInvalidConfigKafkaPropertiesWithoutBrokers(),
+		makeInvalidConfigKafkaWithoutTopic(),
+		makeInvalidConfigKafkaWithoutEvents(),
+		makeInvalidConfigKafkaBadMode(),
+		makeInvalidConfigKafkaBadFormat(),
+		makeInvalidConfigKafkaBadSASLMechanism(),
+		makeInvalidConfigKafkaSASLWithoutCredentials(),
+		makeInvalidConfigKinesisPropertiesWithoutStream(),
+		makeInvalidConfigKinesisBadMode(),
+		makeInvalidConfigPubSubTopicWithoutProject(),
+		makeInvalidConfigPubSubBadFormat(),
+		makeInvalidConfigSnapshotsBadStoreType(),
+		makeInvalidConfigSnapshotsPropertiesWithoutType(),
+		makeInvalidConfigSnapshotsWithoutBucket(),
+		makeInvalidConfigSnapshotsS3WithoutS3Store(),
+		makeInvalidConfigSnapshotsAzureWithoutSASToken(),
+		makeInvalidConfigEnvEventExportUnknown(),
+		makeInvalidConfigEnvEventExportNotConfigured(),
+		makeInvalidConfigEnvEventExportNoneWithOthers(),
+		makeInvalidConfigEnvFlagChangeWebhookNotAbsoluteURL(),
+		makeInvalidConfigEnvFlagChangeWebhookSecretWithoutWebhook(),
+		makeInvalidConfigEnvChangeFeedWithoutEnvID(),
+		makeInvalidConfigEnvAllowedOriginInvalidRegex(),
+		makeInvalidConfigEnvCanaryWithoutHeader(),
+		makeInvalidConfigEnvUnknownCanary(),
+		makeInvalidConfigEnvCanaryIsSelf(),
 		makeInvalidConfigConsulNoPrefix(),
 		makeInvalidConfigConsulAutoConfNoPrefix(),
 		makeInvalidConfigConsulTokenAndTokenFile(),
 		makeInvalidConfigDynamoDBNoPrefixOrTableName(),
 		makeInvalidConfigDynamoDBAutoConfNoPrefixOrTableName(),
+		makeInvalidConfigDynamoDBIncompleteCredentials(),
+		makeInvalidConfigDynamoDBNegativeTimeout(),
+		makeInvalidConfigPostgresBadTLSMode(),
+		makeInvalidConfigPostgresCertWithoutKey(),
+		makeInvalidConfigMongoDBBadWriteConcern(),
+		makeInvalidConfigMongoDBTLSOptionsWithoutTLS(),
+		makeInvalidConfigMongoDBCertWithoutKey(),
+		makeInvalidConfigEtcdPasswordWithoutUsername(),
+		makeInvalidConfigEtcdTLSOptionsWithoutTLS(),
+		makeInvalidConfigEtcdCertWithoutKey(),
 		makeInvalidConfigMultipleDatabases(),
+		makeInvalidConfigEnvUnknownDatabase(),
+		makeInvalidConfigEnvUnknownTenant(),
+		makeInvalidConfigTenantWithoutPortOrHost(),
+		makeInvalidConfigTenantPortConflict(),
+		makeInvalidConfigTenantHostConflict(),
+		makeInvalidConfigTenantCertWithoutKey(),
+		makeInvalidConfigTenantCertWithoutTLS(),
+		makeInvalidConfigTenantsWithGRPC(),
+		makeInvalidConfigClientCertWithGRPC(),
+		makeInvalidConfigDatabaseNameConflict(),
+		makeInvalidConfigNamedDatabaseWithoutServer(),
+		makeInvalidConfigNamedDatabaseNoPrefix(),
+		makeInvalidConfigEventsCoordinateWithNamedNonRedisDatabase(),
+		makeInvalidConfigBigSegmentsSnapshotWithoutMemoryStore(),
+		makeInvalidConfigBigSegmentsBadStaleMode(),
+		makeInvalidConfigBigSegmentsBadCircuitBreakerFallback(),
+		makeInvalidConfigBigSegmentsS3WithoutS3Store(),
+		makeInvalidConfigBigSegmentsS3WithoutBucket(),
+		makeInvalidConfigBigSegmentsNegativeCacheTimeWithoutSize(),
 	}
 }
 
@@ -97,85 +243,137 @@ TLSMinVersion = x
 	return c
 }
 
-func makeInvalidConfigAutoConfKeyWithEnvironments() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "auto-conf key with environments"}
-	c.envVarsError = errAutoConfWithEnvironments.Error()
-	c.envVars = map[string]string{
-		"AUTO_CONFIG_KEY": "autokey",
-		"LD_ENV_envname":  "sdk-key",
-	}
+func makeInvalidConfigH2CWithTLS() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "h2c with TLS"}
+	c.envVarsError = errH2CWithTLS.Error()
+	c.envVars = map[string]string{"TLS_ENABLED": "1", "TLS_CERT": "cert", "TLS_KEY": "key", "H2C_ENABLED": "1"}
 	c.fileContent = `
-[AutoConfig]
-Key = autokey
+[Main]
+TLSEnabled = true
+TLSCert = certfile
+TLSKey = keyfile
+H2CEnabled = true
+`
+	return c
+}
 
-[Environment "envname"]
-SDKKey = sdk-key
+func makeInvalidConfigHTTP3WithoutTLS() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "HTTP/3 without TLS"}
+	c.envVarsError = errHTTP3WithoutTLS.Error()
+	c.envVars = map[string]string{"HTTP3_ENABLED": "1"}
+	c.fileContent = `
+[Main]
+HTTP3Enabled = true
 `
 	return c
 }
 
-func makeInvalidConfigAutoConfAllowedOriginWithNoKey() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "auto-conf allowed origin with no key"}
-	c.envVarsError = errAutoConfPropertiesWithNoKey.Error()
-	c.envVars = map[string]string{
-		"ENV_ALLOWED_ORIGIN": "http://origin",
-	}
+func makeInvalidConfigACMEWithoutTLS() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "ACME without TLS"}
+	c.envVarsError = errACMEWithoutTLS.Error()
+	c.envVars = map[string]string{"ACME_DOMAINS": "relay.example.com", "ACME_CACHE_DIR": "/var/lib/relay/acme"}
 	c.fileContent = `
-[AutoConfig]
-EnvAllowedOrigin = http://origin
+[ACME]
+Domain = relay.example.com
+CacheDir = /var/lib/relay/acme
 `
 	return c
 }
 
-func makeInvalidConfigAutoConfAllowedHeaderWithNoKey() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "auto-conf allowed header with no key"}
-	c.envVarsError = errAutoConfPropertiesWithNoKey.Error()
-	c.envVars = map[string]string{
-		"ENV_ALLOWED_HEADER": "Timestamp",
-	}
+func makeInvalidConfigACMEWithCertAndKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "ACME with TLS cert and key"}
+	c.envVarsError = errACMEWithCertOrKey.Error()
+	c.envVars = map[string]string{"TLS_ENABLED": "1", "TLS_CERT": "cert", "TLS_KEY": "key",
+		"ACME_DOMAINS": "relay.example.com", "ACME_CACHE_DIR": "/var/lib/relay/acme"}
 	c.fileContent = `
-[AutoConfig]
-EnvAllowedHeader = Timestamp
+[Main]
+TLSEnabled = true
+TLSCert = certfile
+TLSKey = keyfile
+
+[ACME]
+Domain = relay.example.com
+CacheDir = /var/lib/relay/acme
 `
 	return c
 }
 
-func makeInvalidConfigAutoConfPrefixWithNoKey() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "auto-conf prefix with no key"}
-	c.envVarsError = errAutoConfPropertiesWithNoKey.Error()
-	c.envVars = map[string]string{
-		"ENV_DATASTORE_PREFIX": "prefix",
-	}
+func makeInvalidConfigACMEWithoutCacheDir() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "ACME without cache directory"}
+	c.envVarsError = errACMEWithoutCacheDir.Error()
+	c.envVars = map[string]string{"TLS_ENABLED": "1", "ACME_DOMAINS": "relay.example.com"}
 	c.fileContent = `
-[AutoConfig]
-EnvDatastorePrefix = prefix
+[Main]
+TLSEnabled = true
+
+[ACME]
+Domain = relay.example.com
 `
 	return c
 }
 
-func makeInvalidConfigAutoConfTableNameWithNoKey() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "auto-conf table name with no key"}
-	c.envVarsError = errAutoConfPropertiesWithNoKey.Error()
+func makeInvalidConfigACMEPropertiesWithoutDomain() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "ACME properties without domain"}
+	c.envVarsError = errACMEPropertiesWithoutDomain.Error()
+	c.envVars = map[string]string{"ACME_CACHE_DIR": "/var/lib/relay/acme"}
+	c.fileContent = `
+[ACME]
+CacheDir = /var/lib/relay/acme
+`
+	return c
+}
+
+func makeInvalidConfigClientCertWithoutTLS() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "client certificates without TLS"}
+	c.envVarsError = errTLSClientCertWithoutTLS.Error()
+	c.envVars = map[string]string{"TLS_CLIENT_CERT_REQUIRED": "1", "TLS_CLIENT_CA": "ca"}
+	c.fileContent = `
+[Main]
+TLSClientCertRequired = true
+TLSClientCA = ca
+`
+	return c
+}
+
+func makeInvalidConfigClientCertEnvWithoutCA() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "client certificates with no CA for environment"}
+	c.envVarsError = errEnvClientCAMissing("krypton").Error()
 	c.envVars = map[string]string{
-		"ENV_DATASTORE_TABLE_NAME": "table",
+		"TLS_ENABLED":              "1",
+		"TLS_CERT":                 "cert",
+		"TLS_KEY":                  "key",
+		"TLS_CLIENT_CERT_REQUIRED": "1",
+		"LD_ENV_krypton":           "krypton-sdk",
 	}
 	c.fileContent = `
-[AutoConfig]
-EnvDatastoreTableName = table
+[Main]
+TLSEnabled = true
+TLSCert = cert
+TLSKey = key
+TLSClientCertRequired = true
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
 `
 	return c
 }
 
-func makeInvalidConfigFileDataWithAutoConfKey() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "file data source with auto-config key"}
-	c.envVarsError = errFileDataWithAutoConf.Error()
+func makeInvalidConfigClientCertAutoConfWithoutCA() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "client certificates with auto-config and no global CA"}
+	c.envVarsError = errTLSClientCAMissing.Error()
 	c.envVars = map[string]string{
-		"FILE_DATA_SOURCE": "my-file-path",
-		"AUTO_CONFIG_KEY":  "autokey",
+		"TLS_ENABLED":              "1",
+		"TLS_CERT":                 "cert",
+		"TLS_KEY":                  "key",
+		"TLS_CLIENT_CERT_REQUIRED": "1",
+		"AUTO_CONFIG_KEY":          "autokey",
 	}
 	c.fileContent = `
-[OfflineMode]
-FileDataSource = my-file-path
+[Main]
+TLSEnabled = true
+TLSCert = cert
+TLSKey = key
+TLSClientCertRequired = true
 
 [AutoConfig]
 Key = autokey
@@ -183,253 +381,2181 @@ Key = autokey
 	return c
 }
 
-func makeInvalidConfigFileDataWithEnvironments() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "file data source with environments"}
-	c.envVarsError = errOfflineModeWithEnvironments.Error()
+func makeInvalidConfigClientCertEnvPropertiesWithoutRequired() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "environment client certificate properties without client certificates required"}
+	c.envVarsError = errEnvClientCertWithoutRequired("krypton").Error()
 	c.envVars = map[string]string{
-		"FILE_DATA_SOURCE": "my-file-path",
-		"LD_ENV_envname":   "sdk-key",
+		"LD_ENV_krypton":                      "krypton-sdk",
+		"LD_ALLOWED_CLIENT_CERT_NAME_krypton": "a.example.com",
 	}
 	c.fileContent = `
-[OfflineMode]
-FileDataSource = my-file-path
-
-[Environment "envname"]
-SDKKey = sdk-key
+[Environment "krypton"]
+SdkKey = krypton-sdk
+AllowedClientCertName = a.example.com
 `
 	return c
 }
 
-func makeInvalidConfigOfflineModeAllowedOriginWithNoFile() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "offline mode allowed origin with no file"}
-	c.fileError = errOfflineModePropertiesWithNoFile.Error()
+func makeInvalidConfigJWTPropertiesWithoutJWKSURL() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "JWT properties without JWKS URL"}
+	c.envVarsError = errEnvJWTPropertiesWithoutJWKSURL("krypton").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":        "krypton-sdk",
+		"LD_JWT_ISSUER_krypton": "https://auth.example.com",
+	}
 	c.fileContent = `
-[OfflineMode]
-EnvAllowedOrigin = http://origin
+[Environment "krypton"]
+SdkKey = krypton-sdk
+JwtIssuer = https://auth.example.com
 `
 	return c
 }
 
-func makeInvalidConfigOfflineModeAllowedHeaderWithNoFile() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "offline mode allowed header with no file"}
-	c.fileError = errOfflineModePropertiesWithNoFile.Error()
+func makeInvalidConfigGRPCPortSameAsPort() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "gRPC port same as HTTP port"}
+	c.envVarsError = "gRPC port must be different from the HTTP port"
+	c.envVars = map[string]string{"GRPC_PORT": "8030"}
 	c.fileContent = `
-[OfflineMode]
-EnvAllowedHeader = Timestamp
+[Main]
+Port = 9000
+GRPCPort = 9000
 `
 	return c
 }
 
-func makeInvalidConfigOfflineModePrefixWithNoFile() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "offline mode prefix with no file"}
-	c.fileError = errOfflineModePropertiesWithNoFile.Error()
+func makeInvalidConfigAdminPortWithoutKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "admin port without admin key"}
+	c.envVarsError = errAdminPortWithoutKey.Error()
+	c.envVars = map[string]string{
+		"ADMIN_PORT": "8040",
+	}
 	c.fileContent = `
-[OfflineMode]
-EnvDatastorePrefix = prefix
+[Admin]
+Port = 8040
 `
 	return c
 }
 
-func makeInvalidConfigOfflineModeTableNameWithNoFile() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "offline mode table name with no file"}
-	c.fileError = errOfflineModePropertiesWithNoFile.Error()
+func makeInvalidConfigAdminPortSameAsPort() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "admin port same as HTTP port"}
+	c.envVarsError = errAdminPortSameAsOtherPort.Error()
+	c.envVars = map[string]string{
+		"ADMIN_PORT": "8030",
+		"ADMIN_KEY":  "admin-key",
+	}
 	c.fileContent = `
-[OfflineMode]
-EnvDatastoreTableName = table
+[Admin]
+Port = 8030
+Key = admin-key
 `
 	return c
 }
 
-func makeInvalidConfigRedisInvalidHostname() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "Redis - invalid hostname"}
-	c.envVarsError = "invalid Redis hostname"
+func makeInvalidConfigAdminPortSameAsGRPCPort() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "admin port same as gRPC port"}
+	c.envVarsError = errAdminPortSameAsOtherPort.Error()
 	c.envVars = map[string]string{
-		"USE_REDIS":  "1",
-		"REDIS_HOST": "\\",
+		"GRPC_PORT":  "8040",
+		"ADMIN_PORT": "8040",
+		"ADMIN_KEY":  "admin-key",
 	}
 	c.fileContent = `
-[Redis]
-Host = "\\"
+[Main]
+GRPCPort = 8040
+
+[Admin]
+Port = 8040
+Key = admin-key
 `
 	return c
 }
 
-func makeInvalidConfigRedisInvalidDockerPort() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "Redis - Docker port syntax with invalid port"}
-	c.envVarsError = "REDIS_PORT: not a valid integer"
+func makeInvalidConfigAdminWithAutoConf() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "admin API with auto-configuration"}
+	c.envVarsError = errAdminWithAutoConfOrFileData.Error()
 	c.envVars = map[string]string{
-		"USE_REDIS":  "1",
-		"REDIS_PORT": "tcp://redishost:xxx",
+		"AUTO_CONFIG_KEY": "autokey",
+		"ADMIN_PORT":      "8040",
+		"ADMIN_KEY":       "admin-key",
 	}
+	c.fileContent = `
+[AutoConfig]
+Key = autokey
+
+[Admin]
+Port = 8040
+Key = admin-key
+`
 	return c
 }
 
-func makeInvalidConfigRedisConflictingParams() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "Redis - conflicting parameters"}
-	c.envVarsError = "please specify Redis URL or host/port, but not both"
+func makeInvalidConfigAdminProfilingWithoutPort() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "admin profiling without admin port"}
+	c.envVarsError = errAdminProfilingWithoutPort.Error()
 	c.envVars = map[string]string{
-		"USE_REDIS":  "1",
-		"REDIS_HOST": "redishost",
-		"REDIS_URL":  "http://redishost:6400",
+		"ADMIN_ENABLE_PROFILING": "true",
 	}
 	c.fileContent = `
-[Redis]
-Host = "redishost"
-Url = "http://redishost:6400"
+[Admin]
+EnableProfiling = true
 `
 	return c
 }
 
-func makeInvalidConfigRedisNoPrefix() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "Redis - multiple environments, prefix not defined"}
-	c.envVarsError = errEnvWithoutDBDisambiguation("env2", false).Error()
+func makeInvalidConfigInternalMetricsPortSameAsPort() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "internal metrics port same as HTTP port"}
+	c.envVarsError = errInternalMetricsPortSameAsOther.Error()
 	c.envVars = map[string]string{
-		"LD_ENV_env1":    "key1",
-		"LD_PREFIX_env1": "prefix1",
-		"LD_ENV_env2":    "key2",
-		"USE_REDIS":      "1",
-		"REDIS_URL":      "redis://localhost:6379",
+		"INTERNAL_METRICS_PORT": "8030",
 	}
 	c.fileContent = `
-[Environment "env1"]
-SdkKey = key1
-Prefix = prefix1
-
-[Environment "env2"]
-SdkKey = key2
-
-[Redis]
-URL = redis://localhost:6379
+[InternalMetrics]
+Port = 8030
 `
 	return c
 }
 
-func makeInvalidConfigRedisAutoConfNoPrefix() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "Redis - auto-configuration, prefix not defined"}
-	c.envVarsError = errAutoConfWithoutDBDisambig.Error()
+func makeInvalidConfigInternalMetricsPortSameAsPrometheusPort() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "internal metrics port same as Prometheus port"}
+	c.envVarsError = errInternalMetricsPortSameAsOther.Error()
 	c.envVars = map[string]string{
-		"AUTO_CONFIG_KEY": "autokey",
-		"USE_REDIS":       "1",
-		"REDIS_URL":       "redis://localhost:6379",
+		"USE_PROMETHEUS":        "true",
+		"INTERNAL_METRICS_PORT": "8031",
 	}
 	c.fileContent = `
-[AutoConfig]
-Key = autokey
+[Prometheus]
+Enabled = true
 
-[Redis]
-URL = redis://localhost:6379
+[InternalMetrics]
+Port = 8031
 `
 	return c
 }
 
-func makeInvalidConfigConsulNoPrefix() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "Consul - multiple environments, prefix not defined"}
-	c.envVarsError = errEnvWithoutDBDisambiguation("env2", false).Error()
+func makeInvalidConfigAccessLogBadFormat() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "access log format not recognized"}
+	c.envVarsError = errAccessLogBadFormat("apache").Error()
 	c.envVars = map[string]string{
-		"LD_ENV_env1":    "key1",
-		"LD_PREFIX_env1": "prefix1",
-		"LD_ENV_env2":    "key2",
-		"USE_CONSUL":     "1",
+		"ACCESS_LOG_ENABLED": "1",
+		"ACCESS_LOG_FORMAT":  "apache",
 	}
 	c.fileContent = `
-[Environment "env1"]
-SdkKey = key1
-Prefix = prefix1
-
-[Environment "env2"]
-SdkKey = key2
-
-[Consul]
-Host = localhost
+[AccessLog]
+Enabled = true
+Format = apache
 `
 	return c
 }
 
-func makeInvalidConfigConsulAutoConfNoPrefix() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "Consul - auto-configuration, prefix not defined"}
-	c.envVarsError = errAutoConfWithoutDBDisambig.Error()
+func makeInvalidConfigAccessLogBadOutput() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "access log output not recognized"}
+	c.envVarsError = errAccessLogBadOutput("stderr").Error()
 	c.envVars = map[string]string{
-		"AUTO_CONFIG_KEY": "autokey",
-		"USE_CONSUL":      "1",
+		"ACCESS_LOG_ENABLED": "1",
+		"ACCESS_LOG_OUTPUT":  "stderr",
 	}
 	c.fileContent = `
-[AutoConfig]
-Key = autokey
-
-[Consul]
-Host = localhost
+[AccessLog]
+Enabled = true
+Output = stderr
 `
 	return c
 }
 
-func makeInvalidConfigConsulTokenAndTokenFile() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "Consul - token and token file both specified"}
-	c.envVarsError = errConsulTokenAndTokenFile.Error()
+func makeInvalidConfigAccessLogFileWithoutPath() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "access log file output without file"}
+	c.envVarsError = errAccessLogFileWithoutPath.Error()
 	c.envVars = map[string]string{
-		"USE_CONSUL":        "1",
-		"CONSUL_TOKEN":      "abc",
-		"CONSUL_TOKEN_FILE": "def",
+		"ACCESS_LOG_ENABLED": "1",
+		"ACCESS_LOG_OUTPUT":  "file",
 	}
 	c.fileContent = `
-[Consul]
-Host = localhost
-Token = abc
-TokenFile = def
+[AccessLog]
+Enabled = true
+Output = file
 `
 	return c
 }
 
-func makeInvalidConfigDynamoDBNoPrefixOrTableName() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "DynamoDB - multiple environments, prefix and table name not defined"}
-	c.envVarsError = errEnvWithoutDBDisambiguation("env2", true).Error()
+func makeInvalidConfigAccessLogSyslogBadScheme() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "access log syslog address not udp or tcp"}
+	c.envVarsError = errAccessLogSyslogBadScheme.Error()
 	c.envVars = map[string]string{
-		"LD_ENV_env1":    "key1",
-		"LD_PREFIX_env1": "prefix1",
-		"LD_ENV_env2":    "key2",
-		"USE_DYNAMODB":   "1",
+		"ACCESS_LOG_ENABLED":        "1",
+		"ACCESS_LOG_OUTPUT":         "syslog",
+		"ACCESS_LOG_SYSLOG_ADDRESS": "http://syslog:514",
 	}
 	c.fileContent = `
-[Environment "env1"]
-SdkKey = key1
-Prefix = prefix1
-
-[Environment "env2"]
-SdkKey = key2
-
-[DynamoDB]
+[AccessLog]
 Enabled = true
+Output = syslog
+SyslogAddress = "http://syslog:514"
 `
 	return c
 }
 
-func makeInvalidConfigDynamoDBAutoConfNoPrefixOrTableName() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "DynamoDB - auto-configuration, prefix and table name not defined"}
-	c.envVarsError = errAutoConfWithoutDBDisambig.Error()
+func makeInvalidConfigAuditLogBadWebhookHeader() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "audit log webhook header without a value"}
+	c.envVarsError = errAuditLogBadWebhookHeader.Error()
 	c.envVars = map[string]string{
-		"AUTO_CONFIG_KEY": "autokey",
-		"USE_DYNAMODB":    "1",
+		"AUDIT_LOG_WEBHOOK_URL":     "https://example.com/audit",
+		"AUDIT_LOG_WEBHOOK_HEADERS": "Authorization",
 	}
 	c.fileContent = `
-[AutoConfig]
-Key = autokey
+[AuditLog]
+WebhookURL = "https://example.com/audit"
+WebhookHeader = Authorization
+`
+	return c
+}
 
-[DynamoDB]
-Enabled = true
+func makeInvalidConfigRateLimitNotPositive() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "rate limit not greater than zero"}
+	c.envVarsError = errRateLimitNotPositive("Mobile").Error()
+	c.envVars = map[string]string{
+		"RATE_LIMIT_MOBILE": "0",
+	}
+	c.fileContent = `
+[RateLimit]
+Mobile = 0
 `
 	return c
 }
 
-func makeInvalidConfigMultipleDatabases() testDataInvalidConfig {
-	c := testDataInvalidConfig{name: "multiple databases are enabled"}
-	c.envVarsError = "multiple databases are enabled (Redis, Consul, DynamoDB); only one is allowed"
+func makeInvalidConfigRateLimitInvalidTrustedProxy() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "rate limit trusted proxy not an address"}
+	c.envVarsError = errRateLimitInvalidTrustedProxy("proxy.example.com").Error()
 	c.envVars = map[string]string{
-		"USE_REDIS":    "1",
-		"USE_CONSUL":   "1",
-		"USE_DYNAMODB": "1",
+		"RATE_LIMIT_TRUSTED_PROXIES": "proxy.example.com",
 	}
 	c.fileContent = `
-[Redis]
-Host = "localhost"
+[RateLimit]
+TrustedProxies = proxy.example.com
+`
+	return c
+}
+
+func makeInvalidConfigRateLimitForwardedForWithoutTrustedProxies() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "rate limit X-Forwarded-For without trusted proxies"}
+	c.envVarsError = errRateLimitForwardedForNoProxies.Error()
+	c.envVars = map[string]string{
+		"RATE_LIMIT_USE_FORWARDED_FOR": "true",
+	}
+	c.fileContent = `
+[RateLimit]
+UseForwardedFor = true
+`
+	return c
+}
+
+func makeInvalidConfigShadowPropertiesWithoutURI() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "shadow properties without URI"}
+	c.envVarsError = errShadowPropertiesWithoutURI.Error()
+	c.envVars = map[string]string{
+		"SHADOW_PERCENTAGE": "10",
+	}
+	c.fileContent = `
+[Shadow]
+Percentage = 10
+`
+	return c
+}
+
+func makeInvalidConfigShadowPercentageOutOfRange() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "shadow percentage out of range"}
+	c.envVarsError = errShadowPercentageOutOfRange.Error()
+	c.envVars = map[string]string{
+		"SHADOW_URI":        "http://shadow-relay:8030",
+		"SHADOW_PERCENTAGE": "101",
+	}
+	c.fileContent = `
+[Shadow]
+URI = "http://shadow-relay:8030"
+Percentage = 101
+`
+	return c
+}
+
+func makeInvalidConfigResponseHeaderWithoutColon() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "response header without colon"}
+	c.fileError = errResponseHeaderInvalid.Error()
+	c.fileContent = `
+[ResponseHeaders]
+PollingHeader = "Cache-Control=no-cache"
+`
+	return c
+}
+
+func makeInvalidConfigProxyDestinationWithoutURL() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "proxy destination without URL"}
+	c.envVarsError = errProxyDestinationInvalid("events.launchdarkly.com").Error()
+	c.envVars = map[string]string{
+		"PROXY_DESTINATIONS": "events.launchdarkly.com",
+	}
+	c.fileContent = `
+[Proxy]
+Destination = events.launchdarkly.com
+`
+	return c
+}
+
+func makeInvalidConfigProxyProtocolSourcesNotEnabled() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "PROXY protocol allowed sources without PROXY protocol enabled"}
+	c.envVarsError = errProxyProtocolSourcesNotEnabled.Error()
+	c.envVars = map[string]string{
+		"PROXY_PROTOCOL_ALLOWED_SOURCES": "10.0.0.0/8",
+	}
+	c.fileContent = `
+[Main]
+ProxyProtocolAllowedSource = 10.0.0.0/8
+`
+	return c
+}
+
+func makeInvalidConfigUnixSocketOnlyWithoutPath() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Unix socket only without Unix socket path"}
+	c.envVarsError = errUnixSocketOptionsWithoutPath.Error()
+	c.envVars = map[string]string{"UNIX_SOCKET_ONLY": "1"}
+	c.fileContent = `
+[Main]
+UnixSocketOnly = true
+`
+	return c
+}
+
+func makeInvalidConfigUnixSocketMode() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "bad Unix socket mode"}
+	c.envVarsError = "not a valid file mode"
+	c.envVars = map[string]string{"UNIX_SOCKET": "/tmp/relay.sock", "UNIX_SOCKET_MODE": "rw-rw----"}
+	c.fileContent = `
+[Main]
+UnixSocket = /tmp/relay.sock
+UnixSocketMode = rw-rw----
+`
+	return c
+}
+
+func makeInvalidConfigProxyProtocolSourceInvalid() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "PROXY protocol allowed source not an address"}
+	c.envVarsError = errProxyProtocolSourceInvalid("lb.example.com").Error()
+	c.envVars = map[string]string{
+		"PROXY_PROTOCOL_ENABLED":         "1",
+		"PROXY_PROTOCOL_ALLOWED_SOURCES": "lb.example.com",
+	}
+	c.fileContent = `
+[Main]
+ProxyProtocolEnabled = true
+ProxyProtocolAllowedSource = lb.example.com
+`
+	return c
+}
+
+func makeInvalidConfigUpstreamFallbackNotAbsolute() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "upstream fallback URI not absolute"}
+	c.envVarsError = errUpstreamFallbackInvalid("stream-mirror").Error()
+	c.envVars = map[string]string{
+		"STREAM_URI_FALLBACKS": "stream-mirror",
+	}
+	c.fileContent = `
+[Main]
+StreamURIFallback = stream-mirror
+`
+	return c
+}
+
+func makeInvalidConfigStreamRetryJitterOutOfRange() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "stream retry jitter out of range"}
+	c.envVarsError = errStreamRetryJitterOutOfRange.Error()
+	c.envVars = map[string]string{
+		"STREAM_RETRY_JITTER": "1.5",
+	}
+	c.fileContent = `
+[Main]
+StreamRetryJitter = 1.5
+`
+	return c
+}
+
+func makeInvalidConfigEnvStreamRetryJitterOutOfRange() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "environment stream retry jitter out of range"}
+	c.envVarsError = errEnvStreamRetryJitterOutOfRange("krypton").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                 "krypton-sdk",
+		"LD_STREAM_RETRY_JITTER_krypton": "-0.5",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+StreamRetryJitter = -0.5
+`
+	return c
+}
+
+func makeInvalidConfigStreamLimitBadOverflow() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "stream limit overflow behavior not valid"}
+	c.envVarsError = errStreamLimitBadOverflow("drop").Error()
+	c.envVars = map[string]string{
+		"STREAM_LIMIT_OVERFLOW": "drop",
+	}
+	c.fileContent = `
+[StreamLimits]
+Overflow = drop
+`
+	return c
+}
+
+func makeInvalidConfigMemoryLimitsWithoutHighWaterMark() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "memory limit properties without high-water mark"}
+	c.envVarsError = errMemoryLimitsWithoutHighWaterMark.Error()
+	c.envVars = map[string]string{
+		"MEMORY_LOW_WATER_MARK_MB": "1000",
+	}
+	c.fileContent = `
+[MemoryLimits]
+LowWaterMarkMB = 1000
+`
+	return c
+}
+
+func makeInvalidConfigMemoryLowWaterMarkNotBelowHigh() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "memory low-water mark not below high-water mark"}
+	c.envVarsError = errMemoryLowWaterMarkNotBelowHigh.Error()
+	c.envVars = map[string]string{
+		"MEMORY_HIGH_WATER_MARK_MB": "1000",
+		"MEMORY_LOW_WATER_MARK_MB":  "1000",
+	}
+	c.fileContent = `
+[MemoryLimits]
+HighWaterMarkMB = 1000
+LowWaterMarkMB = 1000
+`
+	return c
+}
+
+func makeInvalidConfigMemoryCheckIntervalNotPositive() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "memory check interval not greater than zero"}
+	c.envVarsError = errMemoryCheckIntervalNotPositive.Error()
+	c.envVars = map[string]string{
+		"MEMORY_HIGH_WATER_MARK_MB": "1000",
+		"MEMORY_CHECK_INTERVAL":     "0s",
+	}
+	c.fileContent = `
+[MemoryLimits]
+HighWaterMarkMB = 1000
+CheckInterval = 0s
+`
+	return c
+}
+
+func makeInvalidConfigEnvRateLimitNotPositive() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "environment rate limit not greater than zero"}
+	c.envVarsError = errEnvRateLimitNotPositive("krypton", "RateLimitServerSide").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                    "krypton-sdk",
+		"LD_RATE_LIMIT_SERVER_SIDE_krypton": "-1",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+RateLimitServerSide = -1
+`
+	return c
+}
+
+func makeInvalidConfigOpenTelemetrySamplingRatio() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "OpenTelemetry sampling ratio greater than 1"}
+	c.envVarsError = errOpenTelemetrySamplingRatio.Error()
+	c.envVars = map[string]string{
+		"USE_OPENTELEMETRY":            "1",
+		"OPENTELEMETRY_SAMPLING_RATIO": "1.5",
+	}
+	c.fileContent = `
+[OpenTelemetry]
+Enabled = true
+SamplingRatio = 1.5
+`
+	return c
+}
+
+func makeInvalidConfigOpenTelemetryBadHeader() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "OpenTelemetry header without a value"}
+	c.envVarsError = errOpenTelemetryBadHeader.Error()
+	c.envVars = map[string]string{
+		"USE_OPENTELEMETRY":     "1",
+		"OPENTELEMETRY_HEADERS": "Authorization",
+	}
+	c.fileContent = `
+[OpenTelemetry]
+Enabled = true
+Header = Authorization
+`
+	return c
+}
+
+func makeInvalidConfigUpstreamRelayWithStreamURI() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "upstream Relay with stream URI"}
+	c.envVarsError = errUpstreamRelayWithServiceURIs.Error()
+	c.envVars = map[string]string{
+		"UPSTREAM_RELAY_URI": "http://upstream-relay:8030",
+		"STREAM_URI":         "http://other",
+	}
+	c.fileContent = `
+[Main]
+UpstreamRelayURI = http://upstream-relay:8030
+StreamURI = http://other
+`
+	return c
+}
+
+func makeInvalidConfigUpstreamRelayWithAutoConf() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "upstream Relay with auto-configuration"}
+	c.envVarsError = errUpstreamRelayWithAutoConf.Error()
+	c.envVars = map[string]string{
+		"UPSTREAM_RELAY_URI": "http://upstream-relay:8030",
+		"AUTO_CONFIG_KEY":    "autokey",
+	}
+	c.fileContent = `
+[Main]
+UpstreamRelayURI = http://upstream-relay:8030
+
+[AutoConfig]
+Key = autokey
+`
+	return c
+}
+
+func makeInvalidConfigUpstreamRelayWithFileData() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "upstream Relay with offline mode"}
+	c.envVarsError = errUpstreamRelayWithFileData.Error()
+	c.envVars = map[string]string{
+		"UPSTREAM_RELAY_URI": "http://upstream-relay:8030",
+		"FILE_DATA_SOURCE":   "my-file-path",
+	}
+	c.fileContent = `
+[Main]
+UpstreamRelayURI = http://upstream-relay:8030
+
+[OfflineMode]
+FileDataSource = my-file-path
+`
+	return c
+}
+
+func makeInvalidConfigAutoConfKeyWithEnvironments() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "auto-conf key with environments"}
+	c.envVarsError = errAutoConfWithEnvironments.Error()
+	c.envVars = map[string]string{
+		"AUTO_CONFIG_KEY": "autokey",
+		"LD_ENV_envname":  "sdk-key",
+	}
+	c.fileContent = `
+[AutoConfig]
+Key = autokey
+
+[Environment "envname"]
+SDKKey = sdk-key
+`
+	return c
+}
+
+func makeInvalidConfigAutoConfAllowedOriginWithNoKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "auto-conf allowed origin with no key"}
+	c.envVarsError = errAutoConfPropertiesWithNoKey.Error()
+	c.envVars = map[string]string{
+		"ENV_ALLOWED_ORIGIN": "http://origin",
+	}
+	c.fileContent = `
+[AutoConfig]
+EnvAllowedOrigin = http://origin
+`
+	return c
+}
+
+func makeInvalidConfigAutoConfAllowedHeaderWithNoKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "auto-conf allowed header with no key"}
+	c.envVarsError = errAutoConfPropertiesWithNoKey.Error()
+	c.envVars = map[string]string{
+		"ENV_ALLOWED_HEADER": "Timestamp",
+	}
+	c.fileContent = `
+[AutoConfig]
+EnvAllowedHeader = Timestamp
+`
+	return c
+}
+
+func makeInvalidConfigAutoConfFilterWithNoKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "auto-conf environment filter with no key"}
+	c.envVarsError = errAutoConfPropertiesWithNoKey.Error()
+	c.envVars = map[string]string{
+		"AUTO_CONFIG_PROJECT_KEYS": "mobile-app",
+	}
+	c.fileContent = `
+[AutoConfig]
+ProjectKeys = mobile-app
+`
+	return c
+}
+
+func makeInvalidConfigAutoConfQuotaWithNoKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "auto-conf environment quota with no key"}
+	c.envVarsError = errAutoConfPropertiesWithNoKey.Error()
+	c.envVars = map[string]string{
+		"AUTO_CONFIG_ENV_MAX_STREAM_CONNECTIONS": "10",
+	}
+	c.fileContent = `
+[AutoConfig]
+EnvMaxStreamConnections = 10
+`
+	return c
+}
+
+func makeInvalidConfigAutoConfQuotaBadOverflow() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "auto-conf quota overflow behavior not recognized"}
+	c.envVarsError = errAutoConfBadQuotaOverflow("drop").Error()
+	c.envVars = map[string]string{
+		"AUTO_CONFIG_KEY":                "autokey",
+		"AUTO_CONFIG_ENV_QUOTA_OVERFLOW": "drop",
+	}
+	c.fileContent = `
+[AutoConfig]
+Key = autokey
+EnvQuotaOverflow = drop
+`
+	return c
+}
+
+func makeInvalidConfigEventsBadDiagnosticEvents() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "diagnostic events behavior not recognized"}
+	c.envVarsError = errBadDiagnosticEvents("rewrite").Error()
+	c.envVars = map[string]string{
+		"EVENTS_DIAGNOSTIC": "rewrite",
+	}
+	c.fileContent = `
+[Events]
+DiagnosticEvents = rewrite
+`
+	return c
+}
+
+func makeInvalidConfigAutoConfQuotaMaxEventsNotPositive() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "auto-conf maximum events per second not positive"}
+	c.envVarsError = errAutoConfMaxEventsNotPositive.Error()
+	c.envVars = map[string]string{
+		"AUTO_CONFIG_KEY":                       "autokey",
+		"AUTO_CONFIG_ENV_MAX_EVENTS_PER_SECOND": "0",
+	}
+	c.fileContent = `
+[AutoConfig]
+Key = autokey
+EnvMaxEventsPerSecond = 0
+`
+	return c
+}
+
+func makeInvalidConfigEnvIdleTimeoutWithoutLazyInit() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "environment idle timeout without lazy initialization"}
+	c.envVarsError = errEnvIdleTimeoutWithoutLazyInit.Error()
+	c.envVars = map[string]string{
+		"ENV_IDLE_TIMEOUT": "10m",
+	}
+	c.fileContent = `
+[Main]
+EnvironmentIdleTimeout = 10m
+`
+	return c
+}
+
+func makeInvalidConfigLazyEnvInitWithFileData() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "lazy environment initialization in offline mode"}
+	c.envVarsError = errLazyEnvInitWithFileData.Error()
+	c.envVars = map[string]string{
+		"LAZY_ENV_INIT":    "1",
+		"FILE_DATA_SOURCE": "my-file-path",
+	}
+	c.fileContent = `
+[Main]
+LazyEnvironmentInit = 1
+
+[OfflineMode]
+FileDataSource = my-file-path
+`
+	return c
+}
+
+func makeInvalidConfigLazyEnvInitWithGRPC() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "lazy environment initialization with gRPC port"}
+	c.envVarsError = errLazyEnvInitWithGRPC.Error()
+	c.envVars = map[string]string{
+		"LAZY_ENV_INIT": "1",
+		"GRPC_PORT":     "8334",
+	}
+	c.fileContent = `
+[Main]
+LazyEnvironmentInit = 1
+GRPCPort = 8334
+`
+	return c
+}
+
+func makeInvalidConfigAutoConfPrefixWithNoKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "auto-conf prefix with no key"}
+	c.envVarsError = errAutoConfPropertiesWithNoKey.Error()
+	c.envVars = map[string]string{
+		"ENV_DATASTORE_PREFIX": "prefix",
+	}
+	c.fileContent = `
+[AutoConfig]
+EnvDatastorePrefix = prefix
+`
+	return c
+}
+
+func makeInvalidConfigAutoConfTableNameWithNoKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "auto-conf table name with no key"}
+	c.envVarsError = errAutoConfPropertiesWithNoKey.Error()
+	c.envVars = map[string]string{
+		"ENV_DATASTORE_TABLE_NAME": "table",
+	}
+	c.fileContent = `
+[AutoConfig]
+EnvDatastoreTableName = table
+`
+	return c
+}
+
+func makeInvalidConfigFileDataWithAutoConfKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "file data source with auto-config key"}
+	c.envVarsError = errFileDataWithAutoConf.Error()
+	c.envVars = map[string]string{
+		"FILE_DATA_SOURCE": "my-file-path",
+		"AUTO_CONFIG_KEY":  "autokey",
+	}
+	c.fileContent = `
+[OfflineMode]
+FileDataSource = my-file-path
+
+[AutoConfig]
+Key = autokey
+`
+	return c
+}
+
+func makeInvalidConfigFileDataWithEnvironments() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "file data source with environments"}
+	c.envVarsError = errOfflineModeWithEnvironments.Error()
+	c.envVars = map[string]string{
+		"FILE_DATA_SOURCE": "my-file-path",
+		"LD_ENV_envname":   "sdk-key",
+	}
+	c.fileContent = `
+[OfflineMode]
+FileDataSource = my-file-path
+
+[Environment "envname"]
+SDKKey = sdk-key
+`
+	return c
+}
+
+func makeInvalidConfigOfflineModeAllowedOriginWithNoFile() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "offline mode allowed origin with no file"}
+	c.fileError = errOfflineModePropertiesWithNoFile.Error()
+	c.fileContent = `
+[OfflineMode]
+EnvAllowedOrigin = http://origin
+`
+	return c
+}
+
+func makeInvalidConfigOfflineModeAllowedHeaderWithNoFile() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "offline mode allowed header with no file"}
+	c.fileError = errOfflineModePropertiesWithNoFile.Error()
+	c.fileContent = `
+[OfflineMode]
+EnvAllowedHeader = Timestamp
+`
+	return c
+}
+
+func makeInvalidConfigOfflineModePrefixWithNoFile() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "offline mode prefix with no file"}
+	c.fileError = errOfflineModePropertiesWithNoFile.Error()
+	c.fileContent = `
+[OfflineMode]
+EnvDatastorePrefix = prefix
+`
+	return c
+}
+
+func makeInvalidConfigOfflineModeTableNameWithNoFile() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "offline mode table name with no file"}
+	c.fileError = errOfflineModePropertiesWithNoFile.Error()
+	c.fileContent = `
+[OfflineMode]
+EnvDatastoreTableName = table
+`
+	return c
+}
+
+func makeInvalidConfigNoOutboundConnectionsWithNoFile() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "no outbound connections with no file"}
+	c.envVarsError = errNoOutboundConnectionsWithoutFile.Error()
+	c.envVars = map[string]string{
+		"NO_OUTBOUND_CONNECTIONS": "true",
+	}
+	c.fileContent = `
+[OfflineMode]
+NoOutboundConnections = true
+`
+	return c
+}
+
+func makeInvalidConfigNoOutboundConnectionsWithEventExport() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "no outbound connections with event export"}
+	c.envVarsError = errNoOutboundConnectionsWith("Kafka event export").Error()
+	c.envVars = map[string]string{
+		"FILE_DATA_SOURCE":        "my-file-path",
+		"NO_OUTBOUND_CONNECTIONS": "true",
+		"USE_EVENTS":              "true",
+		"KAFKA_BROKERS":           "localhost:9092",
+		"KAFKA_TOPIC":             "events",
+	}
+	c.fileContent = `
+[OfflineMode]
+FileDataSource = my-file-path
+NoOutboundConnections = true
+
+[Events]
+SendEvents = true
+
+[Kafka]
+Brokers = localhost:9092
+Topic = events
+`
+	return c
+}
+
+func makeInvalidConfigNoOutboundConnectionsWithMetrics() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "no outbound connections with metrics exporter"}
+	c.envVarsError = errNoOutboundConnectionsWith("Datadog").Error()
+	c.envVars = map[string]string{
+		"FILE_DATA_SOURCE":        "my-file-path",
+		"NO_OUTBOUND_CONNECTIONS": "true",
+		"USE_DATADOG":             "true",
+	}
+	c.fileContent = `
+[OfflineMode]
+FileDataSource = my-file-path
+NoOutboundConnections = true
+
+[Datadog]
+Enabled = true
+`
+	return c
+}
+
+func makeInvalidConfigReadReplicaPropertiesWithoutMode() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "read replica properties without read replica mode"}
+	c.envVarsError = errReadReplicaPropertiesWithoutMode.Error()
+	c.envVars = map[string]string{
+		"READ_REPLICA_POLL_INTERVAL": "2s",
+	}
+	c.fileContent = `
+[ReadReplica]
+PollInterval = 2s
+`
+	return c
+}
+
+func makeInvalidConfigReadReplicaWithoutDatabase() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "read replica mode without database"}
+	c.envVarsError = errReadReplicaWithoutDatabase.Error()
+	c.envVars = map[string]string{
+		"READ_REPLICA": "1",
+	}
+	c.fileContent = `
+[ReadReplica]
+Enabled = true
+`
+	return c
+}
+
+func makeInvalidConfigReadReplicaWithInfiniteCache() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "read replica mode with infinite cache TTL"}
+	c.envVarsError = errReadReplicaWithInfiniteCache.Error()
+	c.envVars = map[string]string{
+		"READ_REPLICA": "1",
+		"USE_DYNAMODB": "1",
+		"CACHE_TTL":    "-1s",
+	}
+	c.fileContent = `
+[ReadReplica]
+Enabled = true
+
+[DynamoDB]
+Enabled = true
+LocalTTL = -1s
+`
+	return c
+}
+
+func makeInvalidConfigReadReplicaWithFileData() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "read replica mode with offline mode"}
+	c.envVarsError = errReadReplicaWithFileData.Error()
+	c.envVars = map[string]string{
+		"READ_REPLICA":     "1",
+		"USE_REDIS":        "1",
+		"FILE_DATA_SOURCE": "my-file-path",
+	}
+	c.fileContent = `
+[ReadReplica]
+Enabled = true
+
+[Redis]
+Host = "localhost"
+
+[OfflineMode]
+FileDataSource = my-file-path
+`
+	return c
+}
+
+func makeInvalidConfigLeaderElectionPropertiesWithoutType() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "leader election properties without type"}
+	c.envVarsError = errLeaderElectionPropertiesWithoutType.Error()
+	c.envVars = map[string]string{
+		"LEADER_ELECTION_LOCK_NAME": "my-lock",
+	}
+	c.fileContent = `
+[LeaderElection]
+LockName = my-lock
+`
+	return c
+}
+
+func makeInvalidConfigLeaderElectionBadType() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "leader election with bad type"}
+	c.envVarsError = errLeaderElectionBadType("etcd").Error()
+	c.envVars = map[string]string{
+		"LEADER_ELECTION_TYPE": "etcd",
+	}
+	c.fileContent = `
+[LeaderElection]
+Type = etcd
+`
+	return c
+}
+
+func makeInvalidConfigLeaderElectionRedisWithoutRedis() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "leader election with Redis lock but no Redis"}
+	c.envVarsError = errLeaderElectionRedisWithoutRedis.Error()
+	c.envVars = map[string]string{
+		"LEADER_ELECTION_TYPE": "redis",
+	}
+	c.fileContent = `
+[LeaderElection]
+Type = redis
+`
+	return c
+}
+
+func makeInvalidConfigLeaderElectionRenewNotBelowLease() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "leader election renew interval not less than lease duration"}
+	c.envVarsError = errLeaderElectionRenewNotBelowLease.Error()
+	c.envVars = map[string]string{
+		"LEADER_ELECTION_TYPE":           "kubernetes",
+		"LEADER_ELECTION_RENEW_INTERVAL": "15s",
+	}
+	c.fileContent = `
+[LeaderElection]
+Type = kubernetes
+RenewInterval = 15s
+`
+	return c
+}
+
+func makeInvalidConfigLeaderElectionWithReadReplica() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "leader election with read replica mode"}
+	c.envVarsError = errLeaderElectionWithReadReplica.Error()
+	c.envVars = map[string]string{
+		"LEADER_ELECTION_TYPE": "redis",
+		"READ_REPLICA":         "1",
+		"USE_REDIS":            "1",
+	}
+	c.fileContent = `
+[LeaderElection]
+Type = redis
+
+[ReadReplica]
+Enabled = true
+
+[Redis]
+Host = "localhost"
+`
+	return c
+}
+
+func makeInvalidConfigLeaderElectionWithFileData() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "leader election with offline mode"}
+	c.envVarsError = errLeaderElectionWithFileData.Error()
+	c.envVars = map[string]string{
+		"LEADER_ELECTION_TYPE": "kubernetes",
+		"FILE_DATA_SOURCE":     "my-file-path",
+	}
+	c.fileContent = `
+[LeaderElection]
+Type = kubernetes
+
+[OfflineMode]
+FileDataSource = my-file-path
+`
+	return c
+}
+
+func makeInvalidConfigReplicationPropertiesWithoutDatabases() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "replication properties without databases"}
+	c.envVarsError = errReplicationWithoutDatabases.Error()
+	c.envVars = map[string]string{
+		"REPLICATION_QUEUE_SIZE": "500",
+	}
+	c.fileContent = `
+[Replication]
+QueueSize = 500
+`
+	return c
+}
+
+func makeInvalidConfigReplicationUnknownDatabase() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "replication to a database that is not defined"}
+	c.envVarsError = errReplicationUnknownDatabase("euwest").Error()
+	c.envVars = map[string]string{
+		"REPLICATION_DATABASES": "euwest",
+		"USE_REDIS":             "1",
+	}
+	c.fileContent = `
+[Replication]
+Database = euwest
+
+[Redis]
+Host = "localhost"
+`
+	return c
+}
+
+func makeInvalidConfigReplicationToOwnDatabase() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "replication to the database that an environment uses"}
+	c.envVarsError = errEnvReplicationToOwnDatabase("a", "euwest").Error()
+	c.envVars = map[string]string{
+		"REPLICATION_DATABASES": "euwest",
+		"REDIS_URL_euwest":      "redis://euwest:6379",
+		"LD_ENV_a":              "key-a",
+		"LD_DATABASE_a":         "euwest",
+	}
+	c.fileContent = `
+[Replication]
+Database = euwest
+
+[RedisDatabase "euwest"]
+URL = "redis://euwest:6379"
+
+[Environment "a"]
+SDKKey = "key-a"
+Database = "euwest"
+`
+	return c
+}
+
+func makeInvalidConfigReplicationRetryIntervalNotPositive() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "replication retry interval not greater than zero"}
+	c.envVarsError = errReplicationRetryIntervalNotPositive.Error()
+	c.envVars = map[string]string{
+		"REPLICATION_DATABASES":      "euwest",
+		"REPLICATION_RETRY_INTERVAL": "0s",
+		"USE_REDIS":                  "1",
+		"REDIS_URL_euwest":           "redis://euwest:6379",
+	}
+	c.fileContent = `
+[Replication]
+Database = euwest
+RetryInterval = 0s
+
+[Redis]
+Host = "localhost"
+
+[RedisDatabase "euwest"]
+URL = "redis://euwest:6379"
+`
+	return c
+}
+
+func makeInvalidConfigReplicationWithReadReplica() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "replication with read replica mode"}
+	c.envVarsError = errReplicationWithReadReplica.Error()
+	c.envVars = map[string]string{
+		"REPLICATION_DATABASES": "euwest",
+		"READ_REPLICA":          "1",
+		"USE_REDIS":             "1",
+		"REDIS_URL_euwest":      "redis://euwest:6379",
+	}
+	c.fileContent = `
+[Replication]
+Database = euwest
+
+[ReadReplica]
+Enabled = true
+
+[Redis]
+Host = "localhost"
+
+[RedisDatabase "euwest"]
+URL = "redis://euwest:6379"
+`
+	return c
+}
+
+func makeInvalidConfigReplicationWithFileData() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "replication with offline mode"}
+	c.envVarsError = errReplicationWithFileData.Error()
+	c.envVars = map[string]string{
+		"REPLICATION_DATABASES": "euwest",
+		"FILE_DATA_SOURCE":      "my-file-path",
+		"USE_REDIS":             "1",
+		"REDIS_URL_euwest":      "redis://euwest:6379",
+	}
+	c.fileContent = `
+[Replication]
+Database = euwest
+
+[OfflineMode]
+FileDataSource = my-file-path
+
+[Redis]
+Host = "localhost"
+
+[RedisDatabase "euwest"]
+URL = "redis://euwest:6379"
+`
+	return c
+}
+
+func makeInvalidConfigFlagOverridesWithDatabase() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "flag overrides file with persistent data store"}
+	c.envVarsError = errEnvFlagOverridesWithDatabase("krypton").Error()
+	c.envVars = map[string]string{
+		"USE_REDIS":                      "1",
+		"LD_ENV_krypton":                 "krypton-sdk",
+		"LD_FLAG_OVERRIDES_FILE_krypton": "overrides.yaml",
+	}
+	c.fileContent = `
+[Redis]
+Host = "localhost"
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
+FlagOverridesFile = overrides.yaml
+`
+	return c
+}
+
+func makeInvalidConfigFlagFilterPattern() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "flag filter pattern with wildcard before the end"}
+	c.envVarsError = errEnvFlagFilterPatternInvalid("krypton", "app-*-flag").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                 "krypton-sdk",
+		"LD_FLAG_FILTER_EXCLUDE_krypton": "app-*-flag",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+FlagFilterExclude = app-*-flag
+`
+	return c
+}
+
+func makeInvalidConfigPrivateAttributeKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "private attributes include key"}
+	c.envVarsError = errEnvPrivateAttributeKey("krypton").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                "krypton-sdk",
+		"LD_PRIVATE_ATTRIBUTES_krypton": "email,key",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+PrivateAttributes = email
+PrivateAttributes = key
+`
+	return c
+}
+
+func makeInvalidConfigHashPrivateAttributesWithoutAttributes() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "private attribute hashing without private attributes"}
+	c.envVarsError = errEnvHashPrivateAttributesWithoutAttributes("krypton").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                     "krypton-sdk",
+		"LD_HASH_PRIVATE_ATTRIBUTES_krypton": "true",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+HashPrivateAttributes = true
+`
+	return c
+}
+
+func makeInvalidConfigEventSampleRate() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "event sample rate out of range"}
+	c.envVarsError = errEnvEventSampleRateInvalid("krypton").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                      "krypton-sdk",
+		"LD_EVENT_SAMPLE_RATE_CUSTOM_krypton": "1.5",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+EventSampleRateCustom = 1.5
+`
+	return c
+}
+
+func makeInvalidConfigRedisInvalidHostname() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - invalid hostname"}
+	c.envVarsError = "invalid Redis hostname"
+	c.envVars = map[string]string{
+		"USE_REDIS":  "1",
+		"REDIS_HOST": "\\",
+	}
+	c.fileContent = `
+[Redis]
+Host = "\\"
+`
+	return c
+}
+
+func makeInvalidConfigRedisInvalidDockerPort() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - Docker port syntax with invalid port"}
+	c.envVarsError = "REDIS_PORT: not a valid integer"
+	c.envVars = map[string]string{
+		"USE_REDIS":  "1",
+		"REDIS_PORT": "tcp://redishost:xxx",
+	}
+	return c
+}
+
+func makeInvalidConfigRedisConflictingParams() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - conflicting parameters"}
+	c.envVarsError = "please specify Redis URL or host/port, but not both"
+	c.envVars = map[string]string{
+		"USE_REDIS":  "1",
+		"REDIS_HOST": "redishost",
+		"REDIS_URL":  "http://redishost:6400",
+	}
+	c.fileContent = `
+[Redis]
+Host = "redishost"
+Url = "http://redishost:6400"
+`
+	return c
+}
+
+func makeInvalidConfigRedisTLSOptionsWithoutTLS() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - TLS CA file without TLS"}
+	c.envVarsError = errRedisTLSOptionsWithoutTLS.Error()
+	c.envVars = map[string]string{
+		"USE_REDIS":         "1",
+		"REDIS_URL":         "redis://redishost:6400",
+		"REDIS_TLS_CA_FILE": "ca.pem",
+	}
+	c.fileContent = `
+[Redis]
+Url = "redis://redishost:6400"
+CAFile = "ca.pem"
+`
+	return c
+}
+
+func makeInvalidConfigRedisCertWithoutKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - TLS cert file without key file"}
+	c.envVarsError = errRedisCertWithoutKey.Error()
+	c.envVars = map[string]string{
+		"USE_REDIS":           "1",
+		"REDIS_URL":           "rediss://redishost:6400",
+		"REDIS_TLS_CERT_FILE": "cert.pem",
+	}
+	c.fileContent = `
+[Redis]
+Url = "rediss://redishost:6400"
+CertFile = "cert.pem"
+`
+	return c
+}
+
+func makeInvalidConfigRedisClusterAndSentinel() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - cluster and Sentinel"}
+	c.envVarsError = errRedisClusterAndSentinel.Error()
+	c.envVars = map[string]string{
+		"REDIS_CLUSTER_ADDRS":        "node1:6379",
+		"REDIS_SENTINEL_MASTER_NAME": "mymaster",
+		"REDIS_SENTINEL_ADDRS":       "sentinel1:26379",
+	}
+	c.fileContent = `
+[Redis]
+ClusterAddrs = "node1:6379"
+SentinelMasterName = "mymaster"
+SentinelAddrs = "sentinel1:26379"
+`
+	return c
+}
+
+func makeInvalidConfigRedisClusterWithURL() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - cluster with URL"}
+	c.envVarsError = errRedisClusterWithURL.Error()
+	c.envVars = map[string]string{
+		"REDIS_CLUSTER_ADDRS": "node1:6379",
+		"REDIS_URL":           "redis://redishost:6400",
+	}
+	c.fileContent = `
+[Redis]
+ClusterAddrs = "node1:6379"
+Url = "redis://redishost:6400"
+`
+	return c
+}
+
+func makeInvalidConfigRedisSentinelWithoutMasterName() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - Sentinel addresses without master name"}
+	c.envVarsError = errRedisSentinelWithoutMasterName.Error()
+	c.envVars = map[string]string{
+		"REDIS_SENTINEL_ADDRS": "sentinel1:26379",
+	}
+	c.fileContent = `
+[Redis]
+SentinelAddrs = "sentinel1:26379"
+`
+	return c
+}
+
+func makeInvalidConfigRedisClusterBadAddress() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - cluster address without port"}
+	c.envVarsError = errRedisBadAddress("node1").Error()
+	c.envVars = map[string]string{
+		"REDIS_CLUSTER_ADDRS": "node1",
+	}
+	c.fileContent = `
+[Redis]
+ClusterAddrs = "node1"
+`
+	return c
+}
+
+func makeInvalidConfigRedisNoPrefix() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - multiple environments, prefix not defined"}
+	c.envVarsError = errEnvWithoutDBDisambiguation("env2", false).Error()
+	c.envVars = map[string]string{
+		"LD_ENV_env1":    "key1",
+		"LD_PREFIX_env1": "prefix1",
+		"LD_ENV_env2":    "key2",
+		"USE_REDIS":      "1",
+		"REDIS_URL":      "redis://localhost:6379",
+	}
+	c.fileContent = `
+[Environment "env1"]
+SdkKey = key1
+Prefix = prefix1
+
+[Environment "env2"]
+SdkKey = key2
+
+[Redis]
+URL = redis://localhost:6379
+`
+	return c
+}
+
+func makeInvalidConfigRedisAutoConfNoPrefix() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - auto-configuration, prefix not defined"}
+	c.envVarsError = errAutoConfWithoutDBDisambig.Error()
+	c.envVars = map[string]string{
+		"AUTO_CONFIG_KEY": "autokey",
+		"USE_REDIS":       "1",
+		"REDIS_URL":       "redis://localhost:6379",
+	}
+	c.fileContent = `
+[AutoConfig]
+Key = autokey
+
+[Redis]
+URL = redis://localhost:6379
+`
+	return c
+}
+
+func makeInvalidConfigRedisMinIdleConnsOverPoolSize() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - min idle connections greater than pool size"}
+	c.envVarsError = errRedisMinIdleConnsOverPoolSize.Error()
+	c.envVars = map[string]string{
+		"USE_REDIS":            "1",
+		"REDIS_URL":            "redis://redishost:6400",
+		"REDIS_POOL_SIZE":      "5",
+		"REDIS_MIN_IDLE_CONNS": "10",
+	}
+	c.fileContent = `
+[Redis]
+Url = "redis://redishost:6400"
+PoolSize = 5
+MinIdleConns = 10
+`
+	return c
+}
+
+func makeInvalidConfigRedisNegativeTimeout() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Redis - negative dial timeout"}
+	c.envVarsError = errNegativeDuration("Redis dial timeout").Error()
+	c.envVars = map[string]string{
+		"USE_REDIS":          "1",
+		"REDIS_URL":          "redis://redishost:6400",
+		"REDIS_DIAL_TIMEOUT": "-1s",
+	}
+	c.fileContent = `
+[Redis]
+Url = "redis://redishost:6400"
+DialTimeout = -1s
+`
+	return c
+}
+
+func makeInvalidConfigEventsCoordinateWithoutRedis() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "events - coordination without Redis"}
+	c.envVarsError = errEventsCoordinateWithoutRedis.Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":        "1",
+		"EVENTS_COORDINATE": "1",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+Coordinate = 1
+`
+	return c
+}
+
+func makeInvalidConfigKafkaPropertiesWithoutBrokers() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Kafka - properties without brokers"}
+	c.envVarsError = errKafkaPropertiesWithoutBrokers.Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":  "1",
+		"KAFKA_TOPIC": "ld-events",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Kafka]
+Topic = ld-events
+`
+	return c
+}
+
+func makeInvalidConfigKafkaWithoutTopic() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Kafka - brokers without topic"}
+	c.envVarsError = errKafkaWithoutTopic.Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":    "1",
+		"KAFKA_BROKERS": "kafka:9092",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Kafka]
+Brokers = kafka:9092
+`
+	return c
+}
+
+func makeInvalidConfigKafkaWithoutEvents() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Kafka - events not enabled"}
+	c.envVarsError = errEventExportWithoutEvents.Error()
+	c.envVars = map[string]string{
+		"KAFKA_BROKERS": "kafka:9092",
+		"KAFKA_TOPIC":   "ld-events",
+	}
+	c.fileContent = `
+[Kafka]
+Brokers = kafka:9092
+Topic = ld-events
+`
+	return c
+}
+
+func makeInvalidConfigKafkaBadMode() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Kafka - mode not recognized"}
+	c.envVarsError = errEventExportBadMode("Kafka", "both").Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":    "1",
+		"KAFKA_BROKERS": "kafka:9092",
+		"KAFKA_TOPIC":   "ld-events",
+		"KAFKA_MODE":    "both",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Kafka]
+Brokers = kafka:9092
+Topic = ld-events
+Mode = both
+`
+	return c
+}
+
+func makeInvalidConfigKafkaBadFormat() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Kafka - format not recognized"}
+	c.envVarsError = errEventExportBadFormat("Kafka", "avro").Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":    "1",
+		"KAFKA_BROKERS": "kafka:9092",
+		"KAFKA_TOPIC":   "ld-events",
+		"KAFKA_FORMAT":  "avro",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Kafka]
+Brokers = kafka:9092
+Topic = ld-events
+Format = avro
+`
+	return c
+}
+
+func makeInvalidConfigKafkaBadSASLMechanism() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Kafka - SASL mechanism not recognized"}
+	c.envVarsError = errKafkaBadSASLMechanism("gssapi").Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":           "1",
+		"KAFKA_BROKERS":        "kafka:9092",
+		"KAFKA_TOPIC":          "ld-events",
+		"KAFKA_SASL_MECHANISM": "gssapi",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Kafka]
+Brokers = kafka:9092
+Topic = ld-events
+SASLMechanism = gssapi
+`
+	return c
+}
+
+func makeInvalidConfigKafkaSASLWithoutCredentials() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Kafka - SASL mechanism without credentials"}
+	c.envVarsError = errKafkaSASLWithoutCredentials.Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":           "1",
+		"KAFKA_BROKERS":        "kafka:9092",
+		"KAFKA_TOPIC":          "ld-events",
+		"KAFKA_SASL_MECHANISM": "plain",
+		"KAFKA_USERNAME":       "relay",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Kafka]
+Brokers = kafka:9092
+Topic = ld-events
+SASLMechanism = plain
+Username = relay
+`
+	return c
+}
+
+func makeInvalidConfigKinesisPropertiesWithoutStream() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Kinesis - properties without stream name"}
+	c.envVarsError = errKinesisPropertiesWithoutStream.Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":     "1",
+		"KINESIS_REGION": "us-west-2",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Kinesis]
+Region = us-west-2
+`
+	return c
+}
+
+func makeInvalidConfigKinesisBadMode() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Kinesis - mode not recognized"}
+	c.envVarsError = errEventExportBadMode("Kinesis", "both").Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":          "1",
+		"KINESIS_STREAM_NAME": "ld-events",
+		"KINESIS_MODE":        "both",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Kinesis]
+StreamName = ld-events
+Mode = both
+`
+	return c
+}
+
+func makeInvalidConfigPubSubTopicWithoutProject() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Pub/Sub - short topic name without project ID"}
+	c.envVarsError = errPubSubTopicWithoutProject.Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":   "1",
+		"PUBSUB_TOPIC": "ld-events",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[PubSub]
+Topic = ld-events
+`
+	return c
+}
+
+func makeInvalidConfigPubSubBadFormat() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Pub/Sub - format not recognized"}
+	c.envVarsError = errEventExportBadFormat("Pub/Sub", "avro").Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":    "1",
+		"PUBSUB_TOPIC":  "projects/my-project/topics/ld-events",
+		"PUBSUB_FORMAT": "avro",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[PubSub]
+Topic = projects/my-project/topics/ld-events
+Format = avro
+`
+	return c
+}
+
+func makeInvalidConfigSnapshotsBadStoreType() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "snapshots - store type not recognized"}
+	c.envVarsError = errSnapshotBadStoreType("ftp").Error()
+	c.envVars = map[string]string{
+		"SNAPSHOT_STORE_TYPE": "ftp",
+		"SNAPSHOT_BUCKET":     "relay-snapshots",
+	}
+	c.fileContent = `
+[Snapshots]
+Type = ftp
+Bucket = relay-snapshots
+`
+	return c
+}
+
+func makeInvalidConfigSnapshotsPropertiesWithoutType() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "snapshots - properties without store type"}
+	c.envVarsError = errSnapshotPropertiesWithoutType.Error()
+	c.envVars = map[string]string{
+		"SNAPSHOT_BUCKET": "relay-snapshots",
+	}
+	c.fileContent = `
+[Snapshots]
+Bucket = relay-snapshots
+`
+	return c
+}
+
+func makeInvalidConfigSnapshotsWithoutBucket() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "snapshots - store type without bucket"}
+	c.envVarsError = errSnapshotWithoutBucket.Error()
+	c.envVars = map[string]string{
+		"SNAPSHOT_STORE_TYPE": "gcs",
+	}
+	c.fileContent = `
+[Snapshots]
+Type = gcs
+`
+	return c
+}
+
+func makeInvalidConfigSnapshotsS3WithoutS3Store() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "snapshots - S3 properties with other store type"}
+	c.envVarsError = errSnapshotS3WithoutS3Store.Error()
+	c.envVars = map[string]string{
+		"SNAPSHOT_STORE_TYPE": "gcs",
+		"SNAPSHOT_BUCKET":     "relay-snapshots",
+		"SNAPSHOT_S3_REGION":  "us-west-2",
+	}
+	c.fileContent = `
+[Snapshots]
+Type = gcs
+Bucket = relay-snapshots
+S3Region = us-west-2
+`
+	return c
+}
+
+func makeInvalidConfigSnapshotsAzureWithoutSASToken() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "snapshots - Azure without SAS token"}
+	c.envVarsError = errSnapshotAzureWithoutSASToken.Error()
+	c.envVars = map[string]string{
+		"SNAPSHOT_STORE_TYPE":    "azure",
+		"SNAPSHOT_BUCKET":        "relay-snapshots",
+		"SNAPSHOT_AZURE_ACCOUNT": "myaccount",
+	}
+	c.fileContent = `
+[Snapshots]
+Type = azure
+Bucket = relay-snapshots
+AzureAccount = myaccount
+`
+	return c
+}
+
+func makeInvalidConfigEnvEventExportUnknown() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "event export - unknown destination for environment"}
+	c.envVarsError = errEnvEventExportUnknown("krypton", "sqs").Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":              "1",
+		"LD_ENV_krypton":          "krypton-sdk",
+		"LD_EVENT_EXPORT_krypton": "sqs",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
+EventExport = sqs
+`
+	return c
+}
+
+func makeInvalidConfigEnvEventExportNotConfigured() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "event export - destination for environment not configured"}
+	c.envVarsError = errEnvEventExportNotConfigured("krypton", "kinesis").Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":              "1",
+		"LD_ENV_krypton":          "krypton-sdk",
+		"LD_EVENT_EXPORT_krypton": "kinesis",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
+EventExport = kinesis
+`
+	return c
+}
+
+func makeInvalidConfigEnvEventExportNoneWithOthers() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "event export - none combined with a destination"}
+	c.envVarsError = errEnvEventExportNoneWithOthers("krypton").Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":              "1",
+		"KINESIS_STREAM_NAME":     "ld-events",
+		"LD_ENV_krypton":          "krypton-sdk",
+		"LD_EVENT_EXPORT_krypton": "none,kinesis",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Kinesis]
+StreamName = ld-events
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
+EventExport = none
+EventExport = kinesis
+`
+	return c
+}
+
+func makeInvalidConfigEnvFlagChangeWebhookNotAbsoluteURL() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "flag change webhook - not an absolute URL"}
+	c.envVarsError = errEnvFlagChangeWebhookInvalid("krypton", "/hooks/flags").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                 "krypton-sdk",
+		"LD_FLAG_CHANGE_WEBHOOK_krypton": "/hooks/flags",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+FlagChangeWebhook = /hooks/flags
+`
+	return c
+}
+
+func makeInvalidConfigEnvFlagChangeWebhookSecretWithoutWebhook() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "flag change webhook - secret without webhook"}
+	c.envVarsError = errEnvFlagChangeWebhookSecretWithoutWebhook("krypton").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                        "krypton-sdk",
+		"LD_FLAG_CHANGE_WEBHOOK_SECRET_krypton": "shh",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+FlagChangeWebhookSecret = shh
+`
+	return c
+}
+
+func makeInvalidConfigEnvChangeFeedWithoutEnvID() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "change feed - no client-side ID"}
+	c.envVarsError = errEnvChangeFeedWithoutEnvID("krypton").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":               "krypton-sdk",
+		"LD_CHANGE_FEED_TOKEN_krypton": "feed-token",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+ChangeFeedToken = feed-token
+`
+	return c
+}
+
+func makeInvalidConfigEnvAllowedOriginInvalidRegex() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "allowed origin - invalid regular expression"}
+	_, regexErr := regexp.Compile("(")
+	c.envVarsError = errEnvAllowedOriginInvalid("krypton", "/(/", regexErr).Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":            "krypton-sdk",
+		"LD_ALLOWED_ORIGIN_krypton": "/(/",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+AllowedOrigin = "/(/"
+`
+	return c
+}
+
+func makeInvalidConfigEnvCanaryWithoutHeader() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "canary environment - no header"}
+	c.envVarsError = errEnvCanaryIncomplete("krypton").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":        "krypton-sdk",
+		"LD_CANARY_ENV_krypton": "krypton",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+CanaryEnvironment = krypton
+`
+	return c
+}
+
+func makeInvalidConfigEnvUnknownCanary() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "canary environment - not defined"}
+	c.envVarsError = errEnvUnknownCanary("krypton", "xenon").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":           "krypton-sdk",
+		"LD_CANARY_ENV_krypton":    "xenon",
+		"LD_CANARY_HEADER_krypton": "X-Canary",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+CanaryEnvironment = xenon
+CanaryHeader = X-Canary
+`
+	return c
+}
+
+func makeInvalidConfigEnvCanaryIsSelf() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "canary environment - same environment"}
+	c.envVarsError = errEnvCanaryNotAllowed("krypton", "krypton").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":           "krypton-sdk",
+		"LD_CANARY_ENV_krypton":    "krypton",
+		"LD_CANARY_HEADER_krypton": "X-Canary",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+CanaryEnvironment = krypton
+CanaryHeader = X-Canary
+`
+	return c
+}
+
+func makeInvalidConfigConsulNoPrefix() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Consul - multiple environments, prefix not defined"}
+	c.envVarsError = errEnvWithoutDBDisambiguation("env2", false).Error()
+	c.envVars = map[string]string{
+		"LD_ENV_env1":    "key1",
+		"LD_PREFIX_env1": "prefix1",
+		"LD_ENV_env2":    "key2",
+		"USE_CONSUL":     "1",
+	}
+	c.fileContent = `
+[Environment "env1"]
+SdkKey = key1
+Prefix = prefix1
+
+[Environment "env2"]
+SdkKey = key2
+
+[Consul]
+Host = localhost
+`
+	return c
+}
+
+func makeInvalidConfigConsulAutoConfNoPrefix() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Consul - auto-configuration, prefix not defined"}
+	c.envVarsError = errAutoConfWithoutDBDisambig.Error()
+	c.envVars = map[string]string{
+		"AUTO_CONFIG_KEY": "autokey",
+		"USE_CONSUL":      "1",
+	}
+	c.fileContent = `
+[AutoConfig]
+Key = autokey
+
+[Consul]
+Host = localhost
+`
+	return c
+}
+
+func makeInvalidConfigConsulTokenAndTokenFile() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Consul - token and token file both specified"}
+	c.envVarsError = errConsulTokenAndTokenFile.Error()
+	c.envVars = map[string]string{
+		"USE_CONSUL":        "1",
+		"CONSUL_TOKEN":      "abc",
+		"CONSUL_TOKEN_FILE": "def",
+	}
+	c.fileContent = `
+[Consul]
+Host = localhost
+Token = abc
+TokenFile = def
+`
+	return c
+}
+
+func makeInvalidConfigDynamoDBNoPrefixOrTableName() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "DynamoDB - multiple environments, prefix and table name not defined"}
+	c.envVarsError = errEnvWithoutDBDisambiguation("env2", true).Error()
+	c.envVars = map[string]string{
+		"LD_ENV_env1":    "key1",
+		"LD_PREFIX_env1": "prefix1",
+		"LD_ENV_env2":    "key2",
+		"USE_DYNAMODB":   "1",
+	}
+	c.fileContent = `
+[Environment "env1"]
+SdkKey = key1
+Prefix = prefix1
+
+[Environment "env2"]
+SdkKey = key2
+
+[DynamoDB]
+Enabled = true
+`
+	return c
+}
+
+func makeInvalidConfigDynamoDBAutoConfNoPrefixOrTableName() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "DynamoDB - auto-configuration, prefix and table name not defined"}
+	c.envVarsError = errAutoConfWithoutDBDisambig.Error()
+	c.envVars = map[string]string{
+		"AUTO_CONFIG_KEY": "autokey",
+		"USE_DYNAMODB":    "1",
+	}
+	c.fileContent = `
+[AutoConfig]
+Key = autokey
+
+[DynamoDB]
+Enabled = true
+`
+	return c
+}
+
+func makeInvalidConfigDynamoDBIncompleteCredentials() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "DynamoDB - access key ID without secret access key"}
+	c.envVarsError = errDynamoDBIncompleteCredentials.Error()
+	c.envVars = map[string]string{
+		"USE_DYNAMODB":           "1",
+		"DYNAMODB_ACCESS_KEY_ID": "key-id",
+	}
+	c.fileContent = `
+[DynamoDB]
+Enabled = true
+AccessKeyID = key-id
+`
+	return c
+}
+
+func makeInvalidConfigDynamoDBNegativeTimeout() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "DynamoDB - negative read timeout"}
+	c.envVarsError = errNegativeDuration("DynamoDB read timeout").Error()
+	c.envVars = map[string]string{
+		"USE_DYNAMODB":          "1",
+		"DYNAMODB_READ_TIMEOUT": "-1s",
+	}
+	c.fileContent = `
+[DynamoDB]
+Enabled = true
+ReadTimeout = -1s
+`
+	return c
+}
+
+func makeInvalidConfigPostgresBadTLSMode() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Postgres - invalid TLS mode"}
+	c.envVarsError = errPostgresBadTLSMode("sometimes").Error()
+	c.envVars = map[string]string{
+		"USE_POSTGRES":      "1",
+		"POSTGRES_TLS_MODE": "sometimes",
+	}
+	c.fileContent = `
+[Postgres]
+URL = "postgres://localhost:5432"
+TLSMode = sometimes
+`
+	return c
+}
+
+func makeInvalidConfigPostgresCertWithoutKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Postgres - TLS cert file without key file"}
+	c.envVarsError = errPostgresCertWithoutKey.Error()
+	c.envVars = map[string]string{
+		"USE_POSTGRES":           "1",
+		"POSTGRES_TLS_CERT_FILE": "client.crt",
+	}
+	c.fileContent = `
+[Postgres]
+URL = "postgres://localhost:5432"
+TLSCertFile = client.crt
+`
+	return c
+}
+
+func makeInvalidConfigMongoDBBadWriteConcern() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "MongoDB - invalid write concern"}
+	c.envVarsError = errMongoDBBadWriteConcern("most").Error()
+	c.envVars = map[string]string{
+		"USE_MONGODB":           "1",
+		"MONGODB_WRITE_CONCERN": "most",
+	}
+	c.fileContent = `
+[MongoDB]
+URL = "mongodb://localhost:27017"
+WriteConcern = most
+`
+	return c
+}
+
+func makeInvalidConfigMongoDBTLSOptionsWithoutTLS() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "MongoDB - TLS CA file without TLS"}
+	c.envVarsError = errMongoDBTLSOptionsWithoutTLS.Error()
+	c.envVars = map[string]string{
+		"USE_MONGODB":         "1",
+		"MONGODB_TLS_CA_FILE": "ca.crt",
+	}
+	c.fileContent = `
+[MongoDB]
+URL = "mongodb://localhost:27017"
+TLSCAFile = ca.crt
+`
+	return c
+}
+
+func makeInvalidConfigMongoDBCertWithoutKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "MongoDB - TLS cert file without key file"}
+	c.envVarsError = errMongoDBCertWithoutKey.Error()
+	c.envVars = map[string]string{
+		"USE_MONGODB":           "1",
+		"MONGODB_TLS":           "1",
+		"MONGODB_TLS_CERT_FILE": "client.crt",
+	}
+	c.fileContent = `
+[MongoDB]
+URL = "mongodb://localhost:27017"
+TLS = true
+TLSCertFile = client.crt
+`
+	return c
+}
+
+func makeInvalidConfigEtcdPasswordWithoutUsername() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "etcd - password without username"}
+	c.envVarsError = errEtcdPasswordWithoutUsername.Error()
+	c.envVars = map[string]string{
+		"USE_ETCD":      "1",
+		"ETCD_PASSWORD": "pass",
+	}
+	c.fileContent = `
+[Etcd]
+Endpoints = "localhost:2379"
+Password = "pass"
+`
+	return c
+}
+
+func makeInvalidConfigEtcdTLSOptionsWithoutTLS() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "etcd - TLS CA file without TLS"}
+	c.envVarsError = errEtcdTLSOptionsWithoutTLS.Error()
+	c.envVars = map[string]string{
+		"USE_ETCD":         "1",
+		"ETCD_TLS_CA_FILE": "ca.crt",
+	}
+	c.fileContent = `
+[Etcd]
+Endpoints = "localhost:2379"
+TLSCAFile = ca.crt
+`
+	return c
+}
+
+func makeInvalidConfigEtcdCertWithoutKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "etcd - TLS cert file without key file"}
+	c.envVarsError = errEtcdCertWithoutKey.Error()
+	c.envVars = map[string]string{
+		"USE_ETCD":           "1",
+		"ETCD_TLS":           "1",
+		"ETCD_TLS_CERT_FILE": "client.crt",
+	}
+	c.fileContent = `
+[Etcd]
+Endpoints = "localhost:2379"
+TLS = true
+TLSCertFile = client.crt
+`
+	return c
+}
+
+func makeInvalidConfigMultipleDatabases() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "multiple databases are enabled"}
+	c.envVarsError = "multiple databases are enabled (Redis, Consul, DynamoDB); only one is allowed"
+	c.envVars = map[string]string{
+		"USE_REDIS":    "1",
+		"USE_CONSUL":   "1",
+		"USE_DYNAMODB": "1",
+	}
+	c.fileContent = `
+[Redis]
+Host = "localhost"
 
 [Consul]
 Host = "consulhost"
@@ -439,3 +2565,317 @@ Enabled = true
 `
 	return c
 }
+
+func makeInvalidConfigEnvUnknownDatabase() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "environment refers to a database that is not defined"}
+	c.envVarsError = errEnvUnknownDatabase("a", "tenants").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_a":      "key-a",
+		"LD_DATABASE_a": "tenants",
+	}
+	c.fileContent = `
+[Environment "a"]
+SDKKey = "key-a"
+Database = "tenants"
+`
+	return c
+}
+
+func makeInvalidConfigEnvUnknownTenant() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "environment refers to a tenant that is not defined"}
+	c.envVarsError = errEnvUnknownTenant("a", "acme").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_a":    "key-a",
+		"LD_TENANT_a": "acme",
+	}
+	c.fileContent = `
+[Environment "a"]
+SDKKey = "key-a"
+Tenant = "acme"
+`
+	return c
+}
+
+func makeInvalidConfigTenantWithoutPortOrHost() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "tenant without port or host"}
+	c.envVarsError = errTenantWithoutPortOrHost("acme").Error()
+	c.envVars = map[string]string{
+		"TENANT_HOST_acme": "",
+	}
+	c.fileContent = `
+[Tenant "acme"]
+`
+	return c
+}
+
+func makeInvalidConfigTenantPortConflict() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "tenant port is the same as the main port"}
+	c.envVarsError = errTenantPortConflict("acme", 8030).Error()
+	c.envVars = map[string]string{
+		"TENANT_PORT_acme": "8030",
+	}
+	c.fileContent = `
+[Tenant "acme"]
+Port = 8030
+`
+	return c
+}
+
+func makeInvalidConfigTenantHostConflict() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "host name is used for more than one tenant"}
+	c.envVarsError = errTenantHostConflict("flags.example.com").Error()
+	c.envVars = map[string]string{
+		"TENANT_HOST_acme":   "flags.example.com",
+		"TENANT_HOST_globex": "FLAGS.example.com",
+	}
+	c.fileContent = `
+[Tenant "acme"]
+Host = "flags.example.com"
+
+[Tenant "globex"]
+Host = "FLAGS.example.com"
+`
+	return c
+}
+
+func makeInvalidConfigTenantCertWithoutKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "tenant TLS cert without key"}
+	c.envVarsError = errTenantCertWithoutKey("acme").Error()
+	c.envVars = map[string]string{
+		"TLS_ENABLED":          "1",
+		"TLS_CERT":             "cert",
+		"TLS_KEY":              "key",
+		"TENANT_PORT_acme":     "8031",
+		"TENANT_TLS_CERT_acme": "acme-cert",
+	}
+	c.fileContent = `
+[Main]
+TLSEnabled = true
+TLSCert = cert
+TLSKey = key
+
+[Tenant "acme"]
+Port = 8031
+TLSCert = acme-cert
+`
+	return c
+}
+
+func makeInvalidConfigTenantCertWithoutTLS() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "tenant TLS cert without TLS"}
+	c.envVarsError = errTenantTLSWithoutTLS("acme").Error()
+	c.envVars = map[string]string{
+		"TENANT_PORT_acme":     "8031",
+		"TENANT_TLS_CERT_acme": "acme-cert",
+		"TENANT_TLS_KEY_acme":  "acme-key",
+	}
+	c.fileContent = `
+[Tenant "acme"]
+Port = 8031
+TLSCert = acme-cert
+TLSKey = acme-key
+`
+	return c
+}
+
+func makeInvalidConfigTenantsWithGRPC() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "tenants with gRPC port"}
+	c.envVarsError = errTenantsWithGRPC.Error()
+	c.envVars = map[string]string{
+		"TENANT_PORT_acme": "8031",
+		"GRPC_PORT":        "8334",
+	}
+	c.fileContent = `
+[Main]
+GRPCPort = 8334
+
+[Tenant "acme"]
+Port = 8031
+`
+	return c
+}
+
+func makeInvalidConfigClientCertWithGRPC() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "client certificates with gRPC port"}
+	c.envVarsError = errTLSClientCertWithGRPC.Error()
+	c.envVars = map[string]string{
+		"TLS_ENABLED":              "1",
+		"TLS_CERT":                 "cert",
+		"TLS_KEY":                  "key",
+		"TLS_CLIENT_CERT_REQUIRED": "1",
+		"TLS_CLIENT_CA":            "ca",
+		"GRPC_PORT":                "8334",
+	}
+	c.fileContent = `
+[Main]
+TLSEnabled = true
+TLSCert = cert
+TLSKey = key
+TLSClientCertRequired = true
+TLSClientCA = ca
+GRPCPort = 8334
+`
+	return c
+}
+
+func makeInvalidConfigDatabaseNameConflict() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "database name is used for more than one kind of database"}
+	c.envVarsError = errDatabaseNameConflict("tenants", []string{"Redis", "Consul"}).Error()
+	c.envVars = map[string]string{
+		"REDIS_URL_tenants":   "redis://redishost:6379",
+		"CONSUL_HOST_tenants": "consulhost",
+	}
+	c.fileContent = `
+[RedisDatabase "tenants"]
+URL = "redis://redishost:6379"
+
+[ConsulDatabase "tenants"]
+Host = "consulhost"
+`
+	return c
+}
+
+func makeInvalidConfigNamedDatabaseWithoutServer() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "named database without URL or host"}
+	c.envVarsError = errNamedDatabaseWithoutServer("Consul", "tenants").Error()
+	c.envVars = map[string]string{
+		"CONSUL_HOST_tenants": "",
+	}
+	c.fileContent = `
+[ConsulDatabase "tenants"]
+Token = "abc"
+`
+	return c
+}
+
+func makeInvalidConfigNamedDatabaseNoPrefix() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "multiple environments using a named database without prefix"}
+	c.envVarsError = "does not have a prefix specified for database storage"
+	c.envVars = map[string]string{
+		"REDIS_URL_tenants": "redis://redishost:6379",
+		"LD_ENV_a":          "key-a",
+		"LD_DATABASE_a":     "tenants",
+		"LD_PREFIX_a":       "a",
+		"LD_ENV_b":          "key-b",
+		"LD_DATABASE_b":     "tenants",
+	}
+	c.fileContent = `
+[RedisDatabase "tenants"]
+URL = "redis://redishost:6379"
+
+[Environment "a"]
+SDKKey = "key-a"
+Database = "tenants"
+Prefix = "a"
+
+[Environment "b"]
+SDKKey = "key-b"
+Database = "tenants"
+`
+	return c
+}
+
+func makeInvalidConfigEventsCoordinateWithNamedNonRedisDatabase() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "events - coordination with a named database that is not Redis"}
+	c.envVarsError = errEnvCoordinateWithoutRedis("a").Error()
+	c.envVars = map[string]string{
+		"USE_EVENTS":        "1",
+		"EVENTS_COORDINATE": "1",
+		"USE_DYNAMODB_ddb":  "1",
+		"LD_ENV_a":          "key-a",
+		"LD_DATABASE_a":     "ddb",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+Coordinate = 1
+
+[DynamoDBDatabase "ddb"]
+TableName = "flags"
+
+[Environment "a"]
+SDKKey = "key-a"
+Database = "ddb"
+`
+	return c
+}
+
+func makeInvalidConfigBigSegmentsSnapshotWithoutMemoryStore() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "big segments - snapshot directory without memory store type"}
+	c.envVarsError = errBigSegmentsSnapshotWithoutMemoryStore.Error()
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_SNAPSHOT_DIR": "/var/lib/ld-relay",
+	}
+	c.fileContent = `
+[BigSegments]
+SnapshotDir = /var/lib/ld-relay
+`
+	return c
+}
+
+func makeInvalidConfigBigSegmentsBadStaleMode() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "big segments - invalid stale mode"}
+	c.envVarsError = errEnvBadBigSegmentsStaleMode("envname", "sometimes").Error()
+	c.envVars = map[string]string{
+		"LD_ENV_envname":                     "sdk-key",
+		"LD_BIG_SEGMENTS_STALE_MODE_envname": "sometimes",
+	}
+	c.fileContent = `
+[Environment "envname"]
+SDKKey = sdk-key
+BigSegmentsStaleMode = sometimes
+`
+	return c
+}
+
+func makeInvalidConfigBigSegmentsBadCircuitBreakerFallback() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "big segments - invalid circuit breaker fallback"}
+	c.envVarsError = errBigSegmentsBadCircuitBreakerFallback("included").Error()
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_CIRCUIT_BREAKER_FALLBACK": "included",
+	}
+	c.fileContent = `
+[BigSegments]
+CircuitBreakerFallback = included
+`
+	return c
+}
+
+func makeInvalidConfigBigSegmentsS3WithoutS3Store() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "big segments - S3 bucket without S3 store type"}
+	c.envVarsError = errBigSegmentsS3WithoutS3Store.Error()
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_S3_BUCKET": "my-bucket",
+	}
+	c.fileContent = `
+[BigSegments]
+S3Bucket = my-bucket
+`
+	return c
+}
+
+func makeInvalidConfigBigSegmentsS3WithoutBucket() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "big segments - S3 store type without bucket"}
+	c.envVarsError = errBigSegmentsS3WithoutBucket.Error()
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_STORE_TYPE": "s3",
+	}
+	c.fileContent = `
+[BigSegments]
+Type = s3
+`
+	return c
+}
+
+func makeInvalidConfigBigSegmentsNegativeCacheTimeWithoutSize() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "big segments - negative cache time without size"}
+	c.envVarsError = errBigSegmentsNegativeCacheTimeWithoutSize.Error()
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_NEGATIVE_CACHE_TIME": "2s",
+	}
+	c.fileContent = `
+[BigSegments]
+NegativeCacheTime = 2s
+`
+	return c
+}
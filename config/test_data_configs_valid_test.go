@@ -60,18 +60,47 @@ func newOptURLAbsoluteMustBeValid(urlString string) ct.OptURLAbsolute {
 func makeValidConfigs() []testDataValidConfig {
 	return []testDataValidConfig{
 		makeValidConfigAllBaseProperties(),
+		makeValidConfigLogging(),
 		makeValidConfigCustomBaseURIOnly(),
 		makeValidConfigExplicitDefaultBaseURI(),
 		makeValidConfigExplicitOldDefaultBaseURI(),
+		makeValidConfigUpstreamRelay(),
+		makeValidConfigH2C(),
+		makeValidConfigHTTP3(),
 		makeValidConfigAutoConfig(),
+		makeValidConfigAutoConfigFilters(),
+		makeValidConfigAutoConfigQuotas(),
+		makeValidConfigLazyEnvironmentInit(),
+		makeValidConfigRedactFlagRules(),
+		makeValidConfigCompression(),
+		makeValidConfigClientLimits(),
+		makeValidConfigProxyProtocol(),
+		makeValidConfigUpstreamFallbacks(),
+		makeValidConfigStreamRetry(),
+		makeValidConfigPatchCoalescing(),
+		makeValidConfigEvalCache(),
+		makeValidConfigPHPPollingCache(),
+		makeValidConfigUnixSocket(),
 		makeValidConfigAutoConfigWithDatabase(),
 		makeValidConfigFileData(),
+		makeValidConfigFileDataNoOutboundConnections(),
 		makeValidConfigRedisMinimal(),
 		makeValidConfigRedisAll(),
+		makeValidConfigReadReplica(),
+		makeValidConfigLeaderElectionRedis(),
+		makeValidConfigLeaderElectionKubernetes(),
+		makeValidConfigReplication(),
 		makeValidConfigRedisURL(),
+		makeValidConfigEventsCoordinate(),
+		makeValidConfigEventsSpool(),
+		makeValidConfigEventTransformers(),
 		makeValidConfigRedisPortOnly(),
 		makeValidConfigRedisDockerPort(),
 		makeValidConfigRedisOneEnvNoPrefix(),
+		makeValidConfigRedisTLSFiles(),
+		makeValidConfigRedisCluster(),
+		makeValidConfigRedisSentinel(),
+		makeValidConfigRedisPoolOptions(),
 		makeValidConfigConsulMinimal(),
 		makeValidConfigConsulAll(),
 		makeValidConfigConsulOneEnvNoPrefix(),
@@ -81,6 +110,27 @@ func makeValidConfigs() []testDataValidConfig {
 		makeValidConfigDynamoDBAll(),
 		makeValidConfigDynamoDBMultiEnvsWithTable(),
 		makeValidConfigDynamoDBOneEnvNoPrefixOrTable(),
+		makeValidConfigDynamoDBCredentials(),
+		makeValidConfigDynamoDBPoolOptions(),
+		makeValidConfigDynamoDBDAX(),
+		makeValidConfigPostgresMinimal(),
+		makeValidConfigPostgresAll(),
+		makeValidConfigMongoDBMinimal(),
+		makeValidConfigMongoDBAll(),
+		makeValidConfigEtcdMinimal(),
+		makeValidConfigEtcdAll(),
+		makeValidConfigNamedDatabases(),
+		makeValidConfigTenants(),
+		makeValidConfigTenantCertificates(),
+		makeValidConfigStreamTuning(),
+		makeValidConfigBigSegmentsStoreType(),
+		makeValidConfigBigSegmentsMemoryStore(),
+		makeValidConfigBigSegmentsUserCache(),
+		makeValidConfigBigSegmentsCircuitBreaker(),
+		makeValidConfigBigSegmentsConcurrencyLimit(),
+		makeValidConfigBigSegmentsNegativeCache(),
+		makeValidConfigBigSegmentsWatchInterval(),
+		makeValidConfigBigSegmentsS3Store(),
 		makeValidConfigDatadogMinimal(),
 		makeValidConfigDatadogAll(),
 		makeValidConfigNewrelicMinimal(),
@@ -89,7 +139,38 @@ func makeValidConfigs() []testDataValidConfig {
 		makeValidConfigStackdriverAll(),
 		makeValidConfigPrometheusMinimal(),
 		makeValidConfigPrometheusAll(),
+		makeValidConfigOpenTelemetryMinimal(),
+		makeValidConfigOpenTelemetryAll(),
 		makeValidConfigProxy(),
+		makeValidConfigProxyDestinationsAndNegotiate(),
+		makeValidConfigSecrets(),
+		makeValidConfigAdmin(),
+		makeValidConfigAdminProfiling(),
+		makeValidConfigRateLimit(),
+		makeValidConfigStreamLimits(),
+		makeValidConfigMemoryLimits(),
+		makeValidConfigMaintenance(),
+		makeValidConfigInternalMetrics(),
+		makeValidConfigAccessLog(),
+		makeValidConfigAuditLog(),
+		makeValidConfigHealth(),
+		makeValidConfigShadow(),
+		makeValidConfigResponseHeaders(),
+		makeValidConfigKafka(),
+		makeValidConfigKinesis(),
+		makeValidConfigPubSub(),
+		makeValidConfigEventExportPerEnvironment(),
+		makeValidConfigSnapshotsS3(),
+		makeValidConfigSnapshotsAzure(),
+		makeValidConfigFlagChangeWebhook(),
+		makeValidConfigChangeFeed(),
+		makeValidConfigFlagOverridesFile(),
+		makeValidConfigFlagFilter(),
+		makeValidConfigEventPrivacyAndSampling(),
+		makeValidConfigCanaryEnvironment(),
+		makeValidConfigClientCerts(),
+		makeValidConfigACME(),
+		makeValidConfigJWT(),
 	}
 }
 
@@ -98,12 +179,15 @@ func makeValidConfigAllBaseProperties() testDataValidConfig {
 	c.makeConfig = func(c *Config) {
 		c.Main = MainConfig{
 			Port:                        mustOptIntGreaterThanZero(8333),
+			GRPCPort:                    mustOptIntGreaterThanZero(8334),
+			ReusePort:                   true,
 			BaseURI:                     newOptURLAbsoluteMustBeValid("http://base"),
 			ClientSideBaseURI:           newOptURLAbsoluteMustBeValid("http://clientbase"),
 			StreamURI:                   newOptURLAbsoluteMustBeValid("http://stream"),
 			ExitOnError:                 true,
 			ExitAlways:                  true,
 			IgnoreConnectionErrors:      true,
+			ShutdownTimeout:             ct.NewOptDuration(20 * time.Second),
 			HeartbeatInterval:           ct.NewOptDuration(90 * time.Second),
 			MaxClientConnectionTime:     ct.NewOptDuration(30 * time.Minute),
 			DisconnectedStatusTime:      ct.NewOptDuration(3 * time.Minute),
@@ -115,13 +199,17 @@ func makeValidConfigAllBaseProperties() testDataValidConfig {
 			LogLevel:                    NewOptLogLevel(ldlog.Warn),
 			BigSegmentsStaleAsDegraded:  true,
 			BigSegmentsStaleThreshold:   ct.NewOptDuration(10 * time.Minute),
+			WarmCacheOnStartup:          true,
 		}
 		c.Events = EventsConfig{
-			SendEvents:    true,
-			EventsURI:     newOptURLAbsoluteMustBeValid("http://events"),
-			FlushInterval: ct.NewOptDuration(120 * time.Second),
-			Capacity:      mustOptIntGreaterThanZero(500),
-			InlineUsers:   true,
+			SendEvents:             true,
+			EventsURI:              newOptURLAbsoluteMustBeValid("http://events"),
+			FlushInterval:          ct.NewOptDuration(120 * time.Second),
+			Capacity:               mustOptIntGreaterThanZero(500),
+			InlineUsers:            true,
+			SummaryOnly:            true,
+			DiagnosticEvents:       DiagnosticEventsAnnotate,
+			DiagnosticInstanceName: "relay-1",
 		}
 		c.Environment = map[string]*EnvConfig{
 			"earth": {
@@ -141,59 +229,83 @@ func makeValidConfigAllBaseProperties() testDataValidConfig {
 				TableName:     "krypton-table",
 				AllowedOrigin: ct.NewOptStringList([]string{"https://oa", "https://rann"}),
 				AllowedHeader: ct.NewOptStringList([]string{"Timestamp-Valid", "Random-Id-Valid"}),
+				CORSMaxAge:    ct.NewOptDuration(10 * time.Minute),
 				TTL:           ct.NewOptDuration(5 * time.Minute),
+
+				SDKKeyGracePeriod: ct.NewOptDuration(24 * time.Hour),
+
+				BigSegmentsUserCacheSize: mustOptIntGreaterThanZero(5000),
+				BigSegmentsUserCacheTime: ct.NewOptDuration(30 * time.Second),
+				BigSegmentsStaleAfter:    ct.NewOptDuration(10 * time.Minute),
+				BigSegmentsStaleMode:     BigSegmentsStaleModeFailClosed,
 			},
 		}
 	}
 	c.envVars = map[string]string{
-		"PORT":                           "8333",
-		"BASE_URI":                       "http://base",
-		"CLIENT_SIDE_BASE_URI":           "http://clientbase",
-		"STREAM_URI":                     "http://stream",
-		"EXIT_ON_ERROR":                  "1",
-		"EXIT_ALWAYS":                    "1",
-		"IGNORE_CONNECTION_ERRORS":       "1",
-		"HEARTBEAT_INTERVAL":             "90s",
-		"MAX_CLIENT_CONNECTION_TIME":     "30m",
-		"DISCONNECTED_STATUS_TIME":       "3m",
-		"DISABLE_INTERNAL_USAGE_METRICS": "1",
-		"TLS_ENABLED":                    "1",
-		"TLS_CERT":                       "cert",
-		"TLS_KEY":                        "key",
-		"TLS_MIN_VERSION":                "1.2",
-		"LOG_LEVEL":                      "warn",
-		"BIG_SEGMENTS_STALE_AS_DEGRADED": "true",
-		"BIG_SEGMENTS_STALE_THRESHOLD":   "10m",
-		"USE_EVENTS":                     "1",
-		"EVENTS_HOST":                    "http://events",
-		"EVENTS_FLUSH_INTERVAL":          "120s",
-		"EVENTS_CAPACITY":                "500",
-		"EVENTS_INLINE_USERS":            "1",
-		"LD_ENV_earth":                   "earth-sdk",
-		"LD_MOBILE_KEY_earth":            "earth-mob",
-		"LD_CLIENT_SIDE_ID_earth":        "earth-env",
-		"LD_PREFIX_earth":                "earth-",
-		"LD_TABLE_NAME_earth":            "earth-table",
-		"LD_LOG_LEVEL_earth":             "debug",
-		"LD_ENV_krypton":                 "krypton-sdk",
-		"LD_MOBILE_KEY_krypton":          "krypton-mob",
-		"LD_CLIENT_SIDE_ID_krypton":      "krypton-env",
-		"LD_SECURE_MODE_krypton":         "1",
-		"LD_PREFIX_krypton":              "krypton-",
-		"LD_TABLE_NAME_krypton":          "krypton-table",
-		"LD_ALLOWED_ORIGIN_krypton":      "https://oa,https://rann",
-		"LD_ALLOWED_HEADER_krypton":      "Timestamp-Valid,Random-Id-Valid",
-		"LD_TTL_krypton":                 "5m",
+		"PORT":                                    "8333",
+		"GRPC_PORT":                               "8334",
+		"REUSE_PORT":                              "1",
+		"BASE_URI":                                "http://base",
+		"CLIENT_SIDE_BASE_URI":                    "http://clientbase",
+		"STREAM_URI":                              "http://stream",
+		"EXIT_ON_ERROR":                           "1",
+		"EXIT_ALWAYS":                             "1",
+		"IGNORE_CONNECTION_ERRORS":                "1",
+		"SHUTDOWN_TIMEOUT":                        "20s",
+		"HEARTBEAT_INTERVAL":                      "90s",
+		"MAX_CLIENT_CONNECTION_TIME":              "30m",
+		"DISCONNECTED_STATUS_TIME":                "3m",
+		"DISABLE_INTERNAL_USAGE_METRICS":          "1",
+		"TLS_ENABLED":                             "1",
+		"TLS_CERT":                                "cert",
+		"TLS_KEY":                                 "key",
+		"TLS_MIN_VERSION":                         "1.2",
+		"LOG_LEVEL":                               "warn",
+		"BIG_SEGMENTS_STALE_AS_DEGRADED":          "true",
+		"BIG_SEGMENTS_STALE_THRESHOLD":            "10m",
+		"WARM_CACHE_ON_STARTUP":                   "1",
+		"USE_EVENTS":                              "1",
+		"EVENTS_HOST":                             "http://events",
+		"EVENTS_FLUSH_INTERVAL":                   "120s",
+		"EVENTS_CAPACITY":                         "500",
+		"EVENTS_INLINE_USERS":                     "1",
+		"EVENTS_SUMMARY_ONLY":                     "1",
+		"EVENTS_DIAGNOSTIC":                       "annotate",
+		"EVENTS_DIAGNOSTIC_INSTANCE_NAME":         "relay-1",
+		"LD_ENV_earth":                            "earth-sdk",
+		"LD_MOBILE_KEY_earth":                     "earth-mob",
+		"LD_CLIENT_SIDE_ID_earth":                 "earth-env",
+		"LD_PREFIX_earth":                         "earth-",
+		"LD_TABLE_NAME_earth":                     "earth-table",
+		"LD_LOG_LEVEL_earth":                      "debug",
+		"LD_ENV_krypton":                          "krypton-sdk",
+		"LD_MOBILE_KEY_krypton":                   "krypton-mob",
+		"LD_CLIENT_SIDE_ID_krypton":               "krypton-env",
+		"LD_SECURE_MODE_krypton":                  "1",
+		"LD_PREFIX_krypton":                       "krypton-",
+		"LD_TABLE_NAME_krypton":                   "krypton-table",
+		"LD_ALLOWED_ORIGIN_krypton":               "https://oa,https://rann",
+		"LD_ALLOWED_HEADER_krypton":               "Timestamp-Valid,Random-Id-Valid",
+		"LD_CORS_MAX_AGE_krypton":                 "10m",
+		"LD_TTL_krypton":                          "5m",
+		"LD_SDK_KEY_GRACE_PERIOD_krypton":         "24h",
+		"LD_BIG_SEGMENTS_USER_CACHE_SIZE_krypton": "5000",
+		"LD_BIG_SEGMENTS_USER_CACHE_TIME_krypton": "30s",
+		"LD_BIG_SEGMENTS_STALE_AFTER_krypton":     "10m",
+		"LD_BIG_SEGMENTS_STALE_MODE_krypton":      "failClosed",
 	}
 	c.fileContent = `
 [Main]
 Port = 8333
+GRPCPort = 8334
+ReusePort = 1
 BaseUri = "http://base"
 ClientSideBaseUri = "http://clientbase"
 StreamUri = "http://stream"
 ExitOnError = 1
 ExitAlways = 1
 IgnoreConnectionErrors = 1
+ShutdownTimeout = 20s
 HeartbeatInterval = 90s
 MaxClientConnectionTime = 30m
 DisconnectedStatusTime = 3m
@@ -205,6 +317,7 @@ TLSMinVersion = "1.2"
 LogLevel = "warn"
 BigSegmentsStaleAsDegraded = 1
 BigSegmentsStaleThreshold = 10m
+WarmCacheOnStartup = 1
 
 [Events]
 SendEvents = 1
@@ -212,6 +325,9 @@ EventsUri = "http://events"
 FlushInterval = 120s
 Capacity = 500
 InlineUsers = 1
+SummaryOnly = 1
+DiagnosticEvents = annotate
+DiagnosticInstanceName = relay-1
 
 [Environment "earth"]
 SdkKey = "earth-sdk"
@@ -232,7 +348,71 @@ AllowedOrigin = "https://oa"
 AllowedOrigin = "https://rann"
 AllowedHeader = "Timestamp-Valid"
 AllowedHeader = "Random-Id-Valid"
+CORSMaxAge = 10m
 TTL = 5m
+SdkKeyGracePeriod = 24h
+BigSegmentsUserCacheSize = 5000
+BigSegmentsUserCacheTime = 30s
+BigSegmentsStaleAfter = 10m
+BigSegmentsStaleMode = failClosed
+`
+	return c
+}
+
+func makeValidConfigUpstreamRelay() testDataValidConfig {
+	c := testDataValidConfig{name: "upstream Relay"}
+	c.makeConfig = func(c *Config) {
+		c.Main.UpstreamRelayURI = newOptURLAbsoluteMustBeValid("http://upstream-relay:8030")
+		c.Main.StreamURI = c.Main.UpstreamRelayURI
+		c.Main.BaseURI = c.Main.UpstreamRelayURI
+		c.Main.ClientSideBaseURI = c.Main.UpstreamRelayURI
+		c.Events.EventsURI = c.Main.UpstreamRelayURI
+	}
+	c.envVars = map[string]string{
+		"UPSTREAM_RELAY_URI": "http://upstream-relay:8030",
+	}
+	c.fileContent = `
+[Main]
+UpstreamRelayURI = http://upstream-relay:8030
+`
+	return c
+}
+
+func makeValidConfigH2C() testDataValidConfig {
+	c := testDataValidConfig{name: "h2c"}
+	c.makeConfig = func(c *Config) {
+		c.Main.H2CEnabled = true
+	}
+	c.envVars = map[string]string{
+		"H2C_ENABLED": "1",
+	}
+	c.fileContent = `
+[Main]
+H2CEnabled = true
+`
+	return c
+}
+
+func makeValidConfigHTTP3() testDataValidConfig {
+	c := testDataValidConfig{name: "HTTP/3"}
+	c.makeConfig = func(c *Config) {
+		c.Main.TLSEnabled = true
+		c.Main.TLSCert = "cert"
+		c.Main.TLSKey = "key"
+		c.Main.HTTP3Enabled = true
+	}
+	c.envVars = map[string]string{
+		"TLS_ENABLED":   "1",
+		"TLS_CERT":      "cert",
+		"TLS_KEY":       "key",
+		"HTTP3_ENABLED": "1",
+	}
+	c.fileContent = `
+[Main]
+TLSEnabled = true
+TLSCert = cert
+TLSKey = key
+HTTP3Enabled = true
 `
 	return c
 }
@@ -262,6 +442,346 @@ EnvAllowedHeader = Second
 	return c
 }
 
+func makeValidConfigAutoConfigFilters() testDataValidConfig {
+	c := testDataValidConfig{name: "auto-config environment filters"}
+	c.makeConfig = func(c *Config) {
+		c.AutoConfig = AutoConfigConfig{
+			Key:             AutoConfigKey("autokey"),
+			ProjectKeys:     ct.NewOptStringList([]string{"mobile-app", "web-app"}),
+			EnvironmentKeys: ct.NewOptStringList([]string{"production"}),
+			EnvironmentTags: ct.NewOptStringList([]string{"mobile"}),
+		}
+	}
+	c.envVars = map[string]string{
+		"AUTO_CONFIG_KEY":          "autokey",
+		"AUTO_CONFIG_PROJECT_KEYS": "mobile-app,web-app",
+		"AUTO_CONFIG_ENV_KEYS":     "production",
+		"AUTO_CONFIG_ENV_TAGS":     "mobile",
+	}
+	c.fileContent = `
+[AutoConfig]
+Key = autokey
+ProjectKeys = mobile-app
+ProjectKeys = web-app
+EnvironmentKeys = production
+EnvironmentTags = mobile
+`
+	return c
+}
+
+func makeValidConfigAutoConfigQuotas() testDataValidConfig {
+	c := testDataValidConfig{name: "auto-config environment quotas"}
+	c.makeConfig = func(c *Config) {
+		c.AutoConfig = AutoConfigConfig{
+			Key:                     AutoConfigKey("autokey"),
+			EnvMaxStreamConnections: mustOptIntGreaterThanZero(100),
+			EnvMaxEventsPerSecond:   ct.NewOptFloat64(500.5),
+			EnvMemoryBudget:         mustOptIntGreaterThanZero(16),
+			EnvQuotaOverflow:        AutoConfigQuotaOverflowQueue,
+			EnvQuotaQueueTimeout:    ct.NewOptDuration(3 * time.Second),
+		}
+	}
+	c.envVars = map[string]string{
+		"AUTO_CONFIG_KEY":                        "autokey",
+		"AUTO_CONFIG_ENV_MAX_STREAM_CONNECTIONS": "100",
+		"AUTO_CONFIG_ENV_MAX_EVENTS_PER_SECOND":  "500.5",
+		"AUTO_CONFIG_ENV_MEMORY_BUDGET":          "16",
+		"AUTO_CONFIG_ENV_QUOTA_OVERFLOW":         "queue",
+		"AUTO_CONFIG_ENV_QUOTA_QUEUE_TIMEOUT":    "3s",
+	}
+	c.fileContent = `
+[AutoConfig]
+Key = autokey
+EnvMaxStreamConnections = 100
+EnvMaxEventsPerSecond = 500.5
+EnvMemoryBudget = 16
+EnvQuotaOverflow = queue
+EnvQuotaQueueTimeout = 3s
+`
+	return c
+}
+
+func makeValidConfigLazyEnvironmentInit() testDataValidConfig {
+	c := testDataValidConfig{name: "lazy environment initialization"}
+	c.makeConfig = func(c *Config) {
+		c.Main.LazyEnvironmentInit = true
+		c.Main.EnvironmentIdleTimeout = ct.NewOptDuration(30 * time.Minute)
+	}
+	c.envVars = map[string]string{
+		"LAZY_ENV_INIT":    "1",
+		"ENV_IDLE_TIMEOUT": "30m",
+	}
+	c.fileContent = `
+[Main]
+LazyEnvironmentInit = 1
+EnvironmentIdleTimeout = 30m
+`
+	return c
+}
+
+func makeValidConfigRedactFlagRules() testDataValidConfig {
+	c := testDataValidConfig{name: "redact flag rules"}
+	c.makeConfig = func(c *Config) {
+		c.Main.RedactFlagRules = true
+	}
+	c.envVars = map[string]string{
+		"REDACT_FLAG_RULES": "1",
+	}
+	c.fileContent = `
+[Main]
+RedactFlagRules = 1
+`
+	return c
+}
+
+func makeValidConfigCompression() testDataValidConfig {
+	c := testDataValidConfig{name: "response compression"}
+	c.makeConfig = func(c *Config) {
+		c.Main.CompressionEnabled = true
+		c.Main.CompressionMinSize = mustOptIntGreaterThanZero(500)
+	}
+	c.envVars = map[string]string{
+		"COMPRESSION_ENABLED":  "1",
+		"COMPRESSION_MIN_SIZE": "500",
+	}
+	c.fileContent = `
+[Main]
+CompressionEnabled = 1
+CompressionMinSize = 500
+`
+	return c
+}
+
+func makeValidConfigClientLimits() testDataValidConfig {
+	c := testDataValidConfig{name: "client limits"}
+	c.makeConfig = func(c *Config) {
+		c.Main.MaxEventBodySize = mustOptIntGreaterThanZero(1000000)
+		c.Main.MaxRequestHeaderSize = mustOptIntGreaterThanZero(8192)
+		c.Main.ReadHeaderTimeout = ct.NewOptDuration(5 * time.Second)
+		c.Main.ReadTimeout = ct.NewOptDuration(30 * time.Second)
+		c.Main.WriteTimeout = ct.NewOptDuration(time.Minute)
+	}
+	c.envVars = map[string]string{
+		"MAX_EVENT_BODY_SIZE":     "1000000",
+		"MAX_REQUEST_HEADER_SIZE": "8192",
+		"READ_HEADER_TIMEOUT":     "5s",
+		"READ_TIMEOUT":            "30s",
+		"WRITE_TIMEOUT":           "1m",
+	}
+	c.fileContent = `
+[Main]
+MaxEventBodySize = 1000000
+MaxRequestHeaderSize = 8192
+ReadHeaderTimeout = 5s
+ReadTimeout = 30s
+WriteTimeout = 1m
+`
+	return c
+}
+
+func makeValidConfigProxyProtocol() testDataValidConfig {
+	c := testDataValidConfig{name: "PROXY protocol"}
+	c.makeConfig = func(c *Config) {
+		c.Main.ProxyProtocolEnabled = true
+		c.Main.ProxyProtocolAllowedSource = ct.NewOptStringList([]string{"10.0.0.0/8", "192.168.1.1"})
+	}
+	c.envVars = map[string]string{
+		"PROXY_PROTOCOL_ENABLED":         "1",
+		"PROXY_PROTOCOL_ALLOWED_SOURCES": "10.0.0.0/8,192.168.1.1",
+	}
+	c.fileContent = `
+[Main]
+ProxyProtocolEnabled = true
+ProxyProtocolAllowedSource = 10.0.0.0/8
+ProxyProtocolAllowedSource = 192.168.1.1
+`
+	return c
+}
+
+func makeValidConfigUpstreamFallbacks() testDataValidConfig {
+	c := testDataValidConfig{name: "upstream fallbacks"}
+	c.makeConfig = func(c *Config) {
+		c.Main.StreamURIFallback = ct.NewOptStringList([]string{"https://stream-mirror", "https://stream-mirror2"})
+		c.Main.BaseURIFallback = ct.NewOptStringList([]string{"https://sdk-mirror"})
+		c.Main.UpstreamHealthCheckInterval = ct.NewOptDuration(time.Minute)
+		c.Events.EventsURIFallback = ct.NewOptStringList([]string{"https://events-mirror"})
+	}
+	c.envVars = map[string]string{
+		"STREAM_URI_FALLBACKS":           "https://stream-mirror,https://stream-mirror2",
+		"BASE_URI_FALLBACKS":             "https://sdk-mirror",
+		"UPSTREAM_HEALTH_CHECK_INTERVAL": "1m",
+		"EVENTS_HOST_FALLBACKS":          "https://events-mirror",
+	}
+	c.fileContent = `
+[Main]
+StreamURIFallback = https://stream-mirror
+StreamURIFallback = https://stream-mirror2
+BaseURIFallback = https://sdk-mirror
+UpstreamHealthCheckInterval = 1m
+
+[Events]
+EventsURIFallback = https://events-mirror
+`
+	return c
+}
+
+func makeValidConfigStreamRetry() testDataValidConfig {
+	c := testDataValidConfig{name: "stream retry"}
+	c.makeConfig = func(c *Config) {
+		c.Main.StreamInitialRetryDelay = ct.NewOptDuration(time.Second * 2)
+		c.Main.StreamMaxRetryDelay = ct.NewOptDuration(time.Minute * 5)
+		c.Main.StreamRetryJitter = ct.NewOptFloat64(0.8)
+		c.Main.StreamRetryResetInterval = ct.NewOptDuration(time.Minute * 10)
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:                   SDKKey("krypton-sdk"),
+				StreamInitialRetryDelay:  ct.NewOptDuration(time.Millisecond * 500),
+				StreamMaxRetryDelay:      ct.NewOptDuration(time.Minute),
+				StreamRetryJitter:        ct.NewOptFloat64(0),
+				StreamRetryResetInterval: ct.NewOptDuration(time.Minute * 2),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"STREAM_INITIAL_RETRY_DELAY":             "2s",
+		"STREAM_MAX_RETRY_DELAY":                 "5m",
+		"STREAM_RETRY_JITTER":                    "0.8",
+		"STREAM_RETRY_RESET_INTERVAL":            "10m",
+		"LD_ENV_krypton":                         "krypton-sdk",
+		"LD_STREAM_INITIAL_RETRY_DELAY_krypton":  "500ms",
+		"LD_STREAM_MAX_RETRY_DELAY_krypton":      "1m",
+		"LD_STREAM_RETRY_JITTER_krypton":         "0",
+		"LD_STREAM_RETRY_RESET_INTERVAL_krypton": "2m",
+	}
+	c.fileContent = `
+[Main]
+StreamInitialRetryDelay = 2s
+StreamMaxRetryDelay = 5m
+StreamRetryJitter = 0.8
+StreamRetryResetInterval = 10m
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
+StreamInitialRetryDelay = 500ms
+StreamMaxRetryDelay = 1m
+StreamRetryJitter = 0
+StreamRetryResetInterval = 2m
+`
+	return c
+}
+
+func makeValidConfigPatchCoalescing() testDataValidConfig {
+	c := testDataValidConfig{name: "patch coalescing"}
+	c.makeConfig = func(c *Config) {
+		c.Main.PatchCoalescingWindow = ct.NewOptDuration(time.Millisecond * 500)
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:                SDKKey("krypton-sdk"),
+				PatchCoalescingWindow: ct.NewOptDuration(time.Second * 2),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"PATCH_COALESCING_WINDOW":            "500ms",
+		"LD_ENV_krypton":                     "krypton-sdk",
+		"LD_PATCH_COALESCING_WINDOW_krypton": "2s",
+	}
+	c.fileContent = `
+[Main]
+PatchCoalescingWindow = 500ms
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
+PatchCoalescingWindow = 2s
+`
+	return c
+}
+
+func makeValidConfigEvalCache() testDataValidConfig {
+	c := testDataValidConfig{name: "evaluation cache"}
+	c.makeConfig = func(c *Config) {
+		c.Main.EvalCacheTTL = ct.NewOptDuration(time.Second * 5)
+		c.Main.EvalCacheMaxEntries = mustOptIntGreaterThanZero(500)
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:       SDKKey("krypton-sdk"),
+				EvalCacheTTL: ct.NewOptDuration(time.Second * 30),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"EVAL_CACHE_TTL":            "5s",
+		"EVAL_CACHE_MAX_ENTRIES":    "500",
+		"LD_ENV_krypton":            "krypton-sdk",
+		"LD_EVAL_CACHE_TTL_krypton": "30s",
+	}
+	c.fileContent = `
+[Main]
+EvalCacheTTL = 5s
+EvalCacheMaxEntries = 500
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
+EvalCacheTTL = 30s
+`
+	return c
+}
+
+func makeValidConfigPHPPollingCache() testDataValidConfig {
+	c := testDataValidConfig{name: "PHP polling cache settings"}
+	c.makeConfig = func(c *Config) {
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:               SDKKey("krypton-sdk"),
+				TTL:                  ct.NewOptDuration(time.Minute * 5),
+				AllFlagsTTL:          ct.NewOptDuration(time.Minute),
+				FlagTTL:              ct.NewOptDuration(time.Minute * 2),
+				SegmentTTL:           ct.NewOptDuration(time.Minute * 10),
+				StaleWhileRevalidate: ct.NewOptDuration(time.Second * 30),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                    "krypton-sdk",
+		"LD_TTL_krypton":                    "5m",
+		"LD_ALL_FLAGS_TTL_krypton":          "1m",
+		"LD_FLAG_TTL_krypton":               "2m",
+		"LD_SEGMENT_TTL_krypton":            "10m",
+		"LD_STALE_WHILE_REVALIDATE_krypton": "30s",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+TTL = 5m
+AllFlagsTTL = 1m
+FlagTTL = 2m
+SegmentTTL = 10m
+StaleWhileRevalidate = 30s
+`
+	return c
+}
+
+func makeValidConfigUnixSocket() testDataValidConfig {
+	c := testDataValidConfig{name: "Unix socket"}
+	c.makeConfig = func(c *Config) {
+		c.Main.UnixSocket = "/var/run/relay/relay.sock"
+		c.Main.UnixSocketMode = NewOptFileMode(0660)
+		c.Main.UnixSocketOnly = true
+	}
+	c.envVars = map[string]string{
+		"UNIX_SOCKET":      "/var/run/relay/relay.sock",
+		"UNIX_SOCKET_MODE": "0660",
+		"UNIX_SOCKET_ONLY": "true",
+	}
+	c.fileContent = `
+[Main]
+UnixSocket = /var/run/relay/relay.sock
+UnixSocketMode = 0660
+UnixSocketOnly = true
+`
+	return c
+}
+
 func makeValidConfigCustomBaseURIOnly() testDataValidConfig {
 	c := testDataValidConfig{name: "custom base URI"}
 	c.makeConfig = func(c *Config) {
@@ -355,6 +875,34 @@ FileDataSource = my-file-path
 	return c
 }
 
+func makeValidConfigFileDataNoOutboundConnections() testDataValidConfig {
+	c := testDataValidConfig{name: "file data with no outbound connections"}
+	c.makeConfig = func(c *Config) {
+		c.OfflineMode.FileDataSource = "my-dir-path"
+		c.OfflineMode.NoOutboundConnections = true
+		c.Events.SendEvents = true
+		c.Redis.URL = newOptURLAbsoluteMustBeValid("redis://localhost:6379")
+	}
+	c.envVars = map[string]string{
+		"FILE_DATA_SOURCE":        "my-dir-path",
+		"NO_OUTBOUND_CONNECTIONS": "true",
+		"USE_EVENTS":              "true",
+		"USE_REDIS":               "true",
+	}
+	c.fileContent = `
+[OfflineMode]
+FileDataSource = my-dir-path
+NoOutboundConnections = true
+
+[Events]
+SendEvents = true
+
+[Redis]
+URL = redis://localhost:6379
+`
+	return c
+}
+
 func makeValidConfigRedisMinimal() testDataValidConfig {
 	c := testDataValidConfig{name: "Redis - minimal parameters"}
 	c.makeConfig = func(c *Config) {
@@ -402,86 +950,397 @@ LocalTTL = 3s
 	return c
 }
 
-func makeValidConfigRedisURL() testDataValidConfig {
-	c := testDataValidConfig{name: "Redis - URL instead of host/port"}
+func makeValidConfigReadReplica() testDataValidConfig {
+	c := testDataValidConfig{name: "read replica mode"}
 	c.makeConfig = func(c *Config) {
+		c.ReadReplica = ReadReplicaConfig{
+			Enabled:      true,
+			PollInterval: ct.NewOptDuration(2 * time.Second),
+		}
 		c.Redis = RedisConfig{
-			URL: newOptURLAbsoluteMustBeValid("rediss://redishost:6400"),
+			URL:      newOptURLAbsoluteMustBeValid("redis://localhost:6379"),
+			LocalTTL: ct.NewOptDuration(5 * time.Second),
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_REDIS": "1",
-		"REDIS_URL": "rediss://redishost:6400",
+		"READ_REPLICA":               "1",
+		"READ_REPLICA_POLL_INTERVAL": "2s",
+		"USE_REDIS":                  "1",
+		"CACHE_TTL":                  "5s",
 	}
 	c.fileContent = `
+[ReadReplica]
+Enabled = true
+PollInterval = 2s
+
 [Redis]
-Url = "rediss://redishost:6400"
+Host = "localhost"
+Port = 6379
+LocalTTL = 5s
 `
 	return c
 }
 
-func makeValidConfigRedisPortOnly() testDataValidConfig {
-	c := testDataValidConfig{name: "Redis - URL instead of host/port"}
+func makeValidConfigLeaderElectionRedis() testDataValidConfig {
+	c := testDataValidConfig{name: "leader election - Redis"}
 	c.makeConfig = func(c *Config) {
+		c.LeaderElection = LeaderElectionConfig{
+			Type:          LeaderElectionTypeRedis,
+			LockName:      "my-lock",
+			Identity:      "relay-a",
+			LeaseDuration: ct.NewOptDuration(30 * time.Second),
+			RenewInterval: ct.NewOptDuration(10 * time.Second),
+		}
 		c.Redis = RedisConfig{
-			URL: newOptURLAbsoluteMustBeValid("redis://localhost:9999"),
+			URL: newOptURLAbsoluteMustBeValid("redis://localhost:6379"),
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_REDIS":  "1",
-		"REDIS_PORT": "9999",
+		"LEADER_ELECTION_TYPE":           "redis",
+		"LEADER_ELECTION_LOCK_NAME":      "my-lock",
+		"LEADER_ELECTION_IDENTITY":       "relay-a",
+		"LEADER_ELECTION_LEASE_DURATION": "30s",
+		"LEADER_ELECTION_RENEW_INTERVAL": "10s",
+		"USE_REDIS":                      "1",
 	}
 	c.fileContent = `
+[LeaderElection]
+Type = redis
+LockName = my-lock
+Identity = relay-a
+LeaseDuration = 30s
+RenewInterval = 10s
+
 [Redis]
-Port = 9999
+Host = "localhost"
+Port = 6379
 `
 	return c
 }
 
-func makeValidConfigRedisDockerPort() testDataValidConfig {
-	c := testDataValidConfig{name: "Redis - special Docker port syntax"}
+func makeValidConfigLeaderElectionKubernetes() testDataValidConfig {
+	c := testDataValidConfig{name: "leader election - Kubernetes"}
 	c.makeConfig = func(c *Config) {
-		c.Redis = RedisConfig{
-			URL: newOptURLAbsoluteMustBeValid("redis://redishost:6400"),
+		c.LeaderElection = LeaderElectionConfig{
+			Type:                LeaderElectionTypeKubernetes,
+			KubernetesNamespace: "my-namespace",
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_REDIS":  "1",
-		"REDIS_PORT": "tcp://redishost:6400",
+		"LEADER_ELECTION_TYPE":                 "kubernetes",
+		"LEADER_ELECTION_KUBERNETES_NAMESPACE": "my-namespace",
 	}
-	// not applicable for a config file
+	c.fileContent = `
+[LeaderElection]
+Type = kubernetes
+KubernetesNamespace = my-namespace
+`
 	return c
 }
 
-func makeValidConfigRedisOneEnvNoPrefix() testDataValidConfig {
-	c := testDataValidConfig{name: "Redis - single env, no prefix (warning)"}
+func makeValidConfigReplication() testDataValidConfig {
+	c := testDataValidConfig{name: "replication to a named database"}
 	c.makeConfig = func(c *Config) {
-		c.Redis = RedisConfig{
-			URL: newOptURLAbsoluteMustBeValid("redis://localhost:6379"),
+		c.Replication = ReplicationConfig{
+			Database:      ct.NewOptStringList([]string{"euwest"}),
+			QueueSize:     mustOptIntGreaterThanZero(500),
+			RetryInterval: ct.NewOptDuration(30 * time.Second),
 		}
-		c.Environment = map[string]*EnvConfig{
-			"env1": {SDKKey: SDKKey("key1")},
+		c.Redis = RedisConfig{URL: newOptURLAbsoluteMustBeValid("redis://localhost:6379")}
+		c.RedisDatabase = map[string]*RedisConfig{
+			"euwest": {URL: newOptURLAbsoluteMustBeValid("redis://euwest:6379")},
 		}
 	}
 	c.envVars = map[string]string{
-		"LD_ENV_env1": "key1",
-		"USE_REDIS":   "1",
+		"REPLICATION_DATABASES":      "euwest",
+		"REPLICATION_QUEUE_SIZE":     "500",
+		"REPLICATION_RETRY_INTERVAL": "30s",
+		"USE_REDIS":                  "1",
+		"REDIS_URL_euwest":           "redis://euwest:6379",
 	}
 	c.fileContent = `
-[Environment "env1"]
-SdkKey = key1
+[Replication]
+Database = euwest
+QueueSize = 500
+RetryInterval = 30s
 
 [Redis]
-Host = localhost
+URL = "redis://localhost:6379"
+
+[RedisDatabase "euwest"]
+URL = "redis://euwest:6379"
 `
-	c.warnings = []string{warnEnvWithoutDBDisambiguation("env1", false)}
 	return c
 }
 
-func makeValidConfigConsulMinimal() testDataValidConfig {
-	c := testDataValidConfig{name: "Consul - minimal parameters"}
+func makeValidConfigEventsCoordinate() testDataValidConfig {
+	c := testDataValidConfig{name: "events - coordination with Redis"}
 	c.makeConfig = func(c *Config) {
-		c.Consul = ConsulConfig{
+		c.Events.SendEvents = true
+		c.Events.Coordinate = true
+		c.Redis = RedisConfig{
+			URL: newOptURLAbsoluteMustBeValid("redis://localhost:6379"),
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_EVENTS":        "1",
+		"EVENTS_COORDINATE": "1",
+		"USE_REDIS":         "1",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+Coordinate = 1
+
+[Redis]
+Host = "localhost"
+Port = 6379
+`
+	return c
+}
+
+func makeValidConfigEventsSpool() testDataValidConfig {
+	c := testDataValidConfig{name: "events - spool"}
+	c.makeConfig = func(c *Config) {
+		c.Events.SendEvents = true
+		c.Events.SpoolDir = "/var/spool/ld-relay"
+		c.Events.SpoolMaxSize = mustOptIntGreaterThanZero(50)
+		c.Events.SpoolMaxAge = ct.NewOptDuration(time.Hour)
+	}
+	c.envVars = map[string]string{
+		"USE_EVENTS":            "1",
+		"EVENTS_SPOOL_DIR":      "/var/spool/ld-relay",
+		"EVENTS_SPOOL_MAX_SIZE": "50",
+		"EVENTS_SPOOL_MAX_AGE":  "1h",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+SpoolDir = "/var/spool/ld-relay"
+SpoolMaxSize = 50
+SpoolMaxAge = 1h
+`
+	return c
+}
+
+func makeValidConfigEventTransformers() testDataValidConfig {
+	c := testDataValidConfig{name: "events - transformers"}
+	c.makeConfig = func(c *Config) {
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:                SDKKey("krypton-sdk"),
+				EventTransformers:     ct.NewOptStringList([]string{"strip-emails", "drop-test-users"}),
+				EventTransformWebhook: newOptURLAbsoluteMustBeValid("http://transformer/events"),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                     "krypton-sdk",
+		"LD_EVENT_TRANSFORMERS_krypton":      "strip-emails,drop-test-users",
+		"LD_EVENT_TRANSFORM_WEBHOOK_krypton": "http://transformer/events",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+EventTransformers = strip-emails
+EventTransformers = drop-test-users
+EventTransformWebhook = http://transformer/events
+`
+	return c
+}
+
+func makeValidConfigRedisURL() testDataValidConfig {
+	c := testDataValidConfig{name: "Redis - URL instead of host/port"}
+	c.makeConfig = func(c *Config) {
+		c.Redis = RedisConfig{
+			URL: newOptURLAbsoluteMustBeValid("rediss://redishost:6400"),
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_REDIS": "1",
+		"REDIS_URL": "rediss://redishost:6400",
+	}
+	c.fileContent = `
+[Redis]
+Url = "rediss://redishost:6400"
+`
+	return c
+}
+
+func makeValidConfigRedisPortOnly() testDataValidConfig {
+	c := testDataValidConfig{name: "Redis - URL instead of host/port"}
+	c.makeConfig = func(c *Config) {
+		c.Redis = RedisConfig{
+			URL: newOptURLAbsoluteMustBeValid("redis://localhost:9999"),
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_REDIS":  "1",
+		"REDIS_PORT": "9999",
+	}
+	c.fileContent = `
+[Redis]
+Port = 9999
+`
+	return c
+}
+
+func makeValidConfigRedisDockerPort() testDataValidConfig {
+	c := testDataValidConfig{name: "Redis - special Docker port syntax"}
+	c.makeConfig = func(c *Config) {
+		c.Redis = RedisConfig{
+			URL: newOptURLAbsoluteMustBeValid("redis://redishost:6400"),
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_REDIS":  "1",
+		"REDIS_PORT": "tcp://redishost:6400",
+	}
+	// not applicable for a config file
+	return c
+}
+
+func makeValidConfigRedisTLSFiles() testDataValidConfig {
+	c := testDataValidConfig{name: "Redis - TLS with CA file and client certificate"}
+	c.makeConfig = func(c *Config) {
+		c.Redis = RedisConfig{
+			URL:        newOptURLAbsoluteMustBeValid("redis://redishost:6400"),
+			TLS:        true,
+			CAFile:     "ca.pem",
+			CertFile:   "cert.pem",
+			KeyFile:    "key.pem",
+			ServerName: "redis.example.com",
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_REDIS":             "1",
+		"REDIS_URL":             "redis://redishost:6400",
+		"REDIS_TLS":             "1",
+		"REDIS_TLS_CA_FILE":     "ca.pem",
+		"REDIS_TLS_CERT_FILE":   "cert.pem",
+		"REDIS_TLS_KEY_FILE":    "key.pem",
+		"REDIS_TLS_SERVER_NAME": "redis.example.com",
+	}
+	c.fileContent = `
+[Redis]
+Url = "redis://redishost:6400"
+TLS = 1
+CAFile = "ca.pem"
+CertFile = "cert.pem"
+KeyFile = "key.pem"
+ServerName = "redis.example.com"
+`
+	return c
+}
+
+func makeValidConfigRedisCluster() testDataValidConfig {
+	c := testDataValidConfig{name: "Redis - cluster"}
+	c.makeConfig = func(c *Config) {
+		c.Redis = RedisConfig{
+			URL:          newOptURLAbsoluteMustBeValid("redis://node1:6379"),
+			ClusterAddrs: ct.NewOptStringList([]string{"node1:6379", "node2:6379"}),
+			TLS:          true,
+		}
+	}
+	c.envVars = map[string]string{
+		"REDIS_CLUSTER_ADDRS": "node1:6379,node2:6379",
+		"REDIS_TLS":           "1",
+	}
+	c.fileContent = `
+[Redis]
+ClusterAddrs = "node1:6379"
+ClusterAddrs = "node2:6379"
+TLS = 1
+`
+	return c
+}
+
+func makeValidConfigRedisSentinel() testDataValidConfig {
+	c := testDataValidConfig{name: "Redis - Sentinel"}
+	c.makeConfig = func(c *Config) {
+		c.Redis = RedisConfig{
+			URL:                newOptURLAbsoluteMustBeValid("redis://sentinel1:26379"),
+			SentinelMasterName: "mymaster",
+			SentinelAddrs:      ct.NewOptStringList([]string{"sentinel1:26379", "sentinel2:26379"}),
+			SentinelPassword:   "pass",
+		}
+	}
+	c.envVars = map[string]string{
+		"REDIS_SENTINEL_MASTER_NAME": "mymaster",
+		"REDIS_SENTINEL_ADDRS":       "sentinel1:26379,sentinel2:26379",
+		"REDIS_SENTINEL_PASSWORD":    "pass",
+	}
+	c.fileContent = `
+[Redis]
+SentinelMasterName = "mymaster"
+SentinelAddrs = "sentinel1:26379,sentinel2:26379"
+SentinelPassword = "pass"
+`
+	return c
+}
+
+func makeValidConfigRedisOneEnvNoPrefix() testDataValidConfig {
+	c := testDataValidConfig{name: "Redis - single env, no prefix (warning)"}
+	c.makeConfig = func(c *Config) {
+		c.Redis = RedisConfig{
+			URL: newOptURLAbsoluteMustBeValid("redis://localhost:6379"),
+		}
+		c.Environment = map[string]*EnvConfig{
+			"env1": {SDKKey: SDKKey("key1")},
+		}
+	}
+	c.envVars = map[string]string{
+		"LD_ENV_env1": "key1",
+		"USE_REDIS":   "1",
+	}
+	c.fileContent = `
+[Environment "env1"]
+SdkKey = key1
+
+[Redis]
+Host = localhost
+`
+	c.warnings = []string{warnEnvWithoutDBDisambiguation("env1", false)}
+	return c
+}
+
+func makeValidConfigRedisPoolOptions() testDataValidConfig {
+	c := testDataValidConfig{name: "Redis - pool options"}
+	c.makeConfig = func(c *Config) {
+		c.Redis = RedisConfig{
+			URL:             newOptURLAbsoluteMustBeValid("redis://redishost:6400"),
+			PoolSize:        mustOptIntGreaterThanZero(50),
+			MinIdleConns:    mustOptIntGreaterThanZero(5),
+			MaxConnLifetime: ct.NewOptDuration(10 * time.Minute),
+			DialTimeout:     ct.NewOptDuration(2 * time.Second),
+			ReadTimeout:     ct.NewOptDuration(500 * time.Millisecond),
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_REDIS":               "1",
+		"REDIS_URL":               "redis://redishost:6400",
+		"REDIS_POOL_SIZE":         "50",
+		"REDIS_MIN_IDLE_CONNS":    "5",
+		"REDIS_MAX_CONN_LIFETIME": "10m",
+		"REDIS_DIAL_TIMEOUT":      "2s",
+		"REDIS_READ_TIMEOUT":      "500ms",
+	}
+	c.fileContent = `
+[Redis]
+Url = "redis://redishost:6400"
+PoolSize = 50
+MinIdleConns = 5
+MaxConnLifetime = 10m
+DialTimeout = 2s
+ReadTimeout = 500ms
+`
+	return c
+}
+
+func makeValidConfigConsulMinimal() testDataValidConfig {
+	c := testDataValidConfig{name: "Consul - minimal parameters"}
+	c.makeConfig = func(c *Config) {
+		c.Consul = ConsulConfig{
 			Host: defaultConsulHost,
 		}
 	}
@@ -684,217 +1543,1884 @@ Enabled = true
 	return c
 }
 
-func makeValidConfigDatadogMinimal() testDataValidConfig {
-	c := testDataValidConfig{name: "Datadog - minimal parameters"}
+func makeValidConfigDynamoDBCredentials() testDataValidConfig {
+	c := testDataValidConfig{name: "DynamoDB - explicit credentials"}
 	c.makeConfig = func(c *Config) {
-		c.Datadog = DatadogConfig{
-			Enabled: true,
+		c.DynamoDB = DynamoDBConfig{
+			Enabled:         true,
+			AccessKeyID:     "key-id",
+			SecretAccessKey: "aws-sm://relay-dynamodb#secret",
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_DATADOG": "1",
+		"USE_DYNAMODB":               "1",
+		"DYNAMODB_ACCESS_KEY_ID":     "key-id",
+		"DYNAMODB_SECRET_ACCESS_KEY": "aws-sm://relay-dynamodb#secret",
 	}
 	c.fileContent = `
-[Datadog]
+[DynamoDB]
 Enabled = true
+AccessKeyID = key-id
+SecretAccessKey = "aws-sm://relay-dynamodb#secret"
 `
 	return c
 }
 
-func makeValidConfigDatadogAll() testDataValidConfig {
-	c := testDataValidConfig{name: "Datadog - all parameters"}
+func makeValidConfigDynamoDBPoolOptions() testDataValidConfig {
+	c := testDataValidConfig{name: "DynamoDB - pool options"}
 	c.makeConfig = func(c *Config) {
-		c.Datadog = DatadogConfig{
-			Enabled:   true,
-			Prefix:    "pre-",
-			TraceAddr: "trace",
-			StatsAddr: "stats",
-			Tag:       []string{"tag1:value1", "tag2:value2"},
+		c.DynamoDB = DynamoDBConfig{
+			Enabled:     true,
+			PoolSize:    mustOptIntGreaterThanZero(100),
+			DialTimeout: ct.NewOptDuration(2 * time.Second),
+			ReadTimeout: ct.NewOptDuration(5 * time.Second),
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_DATADOG":        "1",
-		"DATADOG_PREFIX":     "pre-",
-		"DATADOG_TRACE_ADDR": "trace",
-		"DATADOG_STATS_ADDR": "stats",
-		"DATADOG_TAG_tag1":   "value1",
-		"DATADOG_TAG_tag2":   "value2",
+		"USE_DYNAMODB":          "1",
+		"DYNAMODB_POOL_SIZE":    "100",
+		"DYNAMODB_DIAL_TIMEOUT": "2s",
+		"DYNAMODB_READ_TIMEOUT": "5s",
 	}
 	c.fileContent = `
-[Datadog]
+[DynamoDB]
 Enabled = true
-Prefix = "pre-"
-TraceAddr = "trace"
-StatsAddr = "stats"
-Tag = "tag1:value1"
-Tag = "tag2:value2"
+PoolSize = 100
+DialTimeout = 2s
+ReadTimeout = 5s
 `
 	return c
 }
 
-func makeValidConfigNewrelicMinimal() testDataValidConfig {
-	c := testDataValidConfig{name: "Newrelic - minimal parameters"}
+func makeValidConfigDynamoDBDAX() testDataValidConfig {
+	c := testDataValidConfig{name: "DynamoDB - DAX endpoint"}
 	c.makeConfig = func(c *Config) {
-		c.Newrelic = NewrelicConfig{
+		c.DynamoDB = DynamoDBConfig{
 			Enabled:     true,
-			InsightsKey: "sample_insights_key",
+			TableName:   "my-table",
+			DAXEndpoint: "my-cluster.abc123.dax-clusters.us-east-1.amazonaws.com:8111",
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_NEWRELIC":          "1",
-		"NEWRELIC_INSIGHTS_KEY": "sample_insights_key",
+		"USE_DYNAMODB":          "1",
+		"DYNAMODB_TABLE":        "my-table",
+		"DYNAMODB_DAX_ENDPOINT": "my-cluster.abc123.dax-clusters.us-east-1.amazonaws.com:8111",
 	}
 	c.fileContent = `
-[Newrelic]
+[DynamoDB]
 Enabled = true
-InsightsKey= sample_insights_key
+TableName = my-table
+DAXEndpoint = my-cluster.abc123.dax-clusters.us-east-1.amazonaws.com:8111
 `
 	return c
 }
 
-func makeValidConfigNewrelicAll() testDataValidConfig {
-	c := testDataValidConfig{name: "Newrelic - all parameters"}
+func makeValidConfigPostgresMinimal() testDataValidConfig {
+	c := testDataValidConfig{name: "Postgres - minimal parameters"}
 	c.makeConfig = func(c *Config) {
-		c.Newrelic = NewrelicConfig{
-			Enabled:     true,
-			AppName:     "sample_ldrelay_namme",
-			InsightsKey: "sample_insights_key",
-			Prefix:      "pre-",
-			TraceURL:    "https://trace-api.newrelic.com/trace/v1",
-			MetricsURL:  "https://metric-api.newrelic.com/metric/v1",
-			EventsURL:   "https://insights-collector.newrelic.com",
+		c.Postgres = PostgresConfig{
+			URL: defaultPostgresURL,
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_NEWRELIC":          "1",
-		"NEWRELIC_APPNAME":      "sample_ldrelay_namme",
-		"NEWRELIC_INSIGHTS_KEY": "sample_insights_key",
-		"NEWRELIC_PREFIX":       "pre-",
-		"NEWRELIC_TRACE_URL":    "https://trace-api.newrelic.com/trace/v1",
-		"NEWRELIC_METRICS_URL":  "https://metric-api.newrelic.com/metric/v1",
-		"NEWRELIC_EVENTS_URL":   "https://insights-collector.newrelic.com",
+		"USE_POSTGRES": "1",
 	}
 	c.fileContent = `
-[Newrelic]
-Enabled= true
-AppName= "sample_ldrelay_namme"
-InsightsKey= "sample_insights_key"
-Prefix=  "pre-"
-TraceURL= "https://trace-api.newrelic.com/trace/v1"
-MetricsURL="https://metric-api.newrelic.com/metric/v1"
-EventsURL= "https://insights-collector.newrelic.com"
+[Postgres]
+URL = "postgres://localhost:5432"
 `
 	return c
 }
 
-func makeValidConfigStackdriverMinimal() testDataValidConfig {
-	c := testDataValidConfig{name: "Stackdriver - minimal parameters"}
+func makeValidConfigPostgresAll() testDataValidConfig {
+	c := testDataValidConfig{name: "Postgres - all parameters"}
 	c.makeConfig = func(c *Config) {
-		c.Stackdriver = StackdriverConfig{
-			Enabled: true,
+		c.Postgres = PostgresConfig{
+			URL:             newOptURLAbsoluteMustBeValid("postgres://pghost:5433/relay"),
+			Password:        "pass",
+			LocalTTL:        ct.NewOptDuration(3 * time.Second),
+			TLSMode:         "verify-full",
+			TLSRootCertFile: "root.crt",
+			TLSCertFile:     "client.crt",
+			TLSKeyFile:      "client.key",
+			MaxOpenConns:    mustOptIntGreaterThanZero(20),
+			MaxIdleConns:    mustOptIntGreaterThanZero(5),
+			ConnMaxLifetime: ct.NewOptDuration(time.Hour),
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_STACKDRIVER": "1",
+		"USE_POSTGRES":                "1",
+		"POSTGRES_URL":                "postgres://pghost:5433/relay",
+		"POSTGRES_PASSWORD":           "pass",
+		"CACHE_TTL":                   "3s",
+		"POSTGRES_TLS_MODE":           "verify-full",
+		"POSTGRES_TLS_ROOT_CERT_FILE": "root.crt",
+		"POSTGRES_TLS_CERT_FILE":      "client.crt",
+		"POSTGRES_TLS_KEY_FILE":       "client.key",
+		"POSTGRES_MAX_OPEN_CONNS":     "20",
+		"POSTGRES_MAX_IDLE_CONNS":     "5",
+		"POSTGRES_CONN_MAX_LIFETIME":  "1h",
 	}
 	c.fileContent = `
-[Stackdriver]
-Enabled = true
+[Postgres]
+URL = "postgres://pghost:5433/relay"
+Password = "pass"
+LocalTTL = 3s
+TLSMode = verify-full
+TLSRootCertFile = root.crt
+TLSCertFile = client.crt
+TLSKeyFile = client.key
+MaxOpenConns = 20
+MaxIdleConns = 5
+ConnMaxLifetime = 1h
 `
 	return c
 }
 
-func makeValidConfigStackdriverAll() testDataValidConfig {
-	c := testDataValidConfig{name: "Stackdriver - all parameters"}
+func makeValidConfigMongoDBMinimal() testDataValidConfig {
+	c := testDataValidConfig{name: "MongoDB - minimal parameters"}
 	c.makeConfig = func(c *Config) {
-		c.Stackdriver = StackdriverConfig{
-			Enabled:   true,
-			Prefix:    "pre-",
-			ProjectID: "proj",
+		c.MongoDB = MongoDBConfig{
+			URL: defaultMongoDBURL,
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_STACKDRIVER":        "1",
-		"STACKDRIVER_PREFIX":     "pre-",
-		"STACKDRIVER_PROJECT_ID": "proj",
+		"USE_MONGODB": "1",
 	}
 	c.fileContent = `
-[Stackdriver]
-Enabled = true
-Prefix = "pre-"
-ProjectID = "proj"
+[MongoDB]
+URL = "mongodb://localhost:27017"
 `
 	return c
 }
 
-func makeValidConfigPrometheusMinimal() testDataValidConfig {
-	c := testDataValidConfig{name: "Prometheus - minimal parameters"}
+func makeValidConfigMongoDBAll() testDataValidConfig {
+	c := testDataValidConfig{name: "MongoDB - all parameters"}
 	c.makeConfig = func(c *Config) {
-		c.Prometheus = PrometheusConfig{
-			Enabled: true,
+		c.MongoDB = MongoDBConfig{
+			URL:                 newOptURLAbsoluteMustBeValid("mongodb://mongo1:27017,mongo2:27017/?replicaSet=rs0"),
+			Database:            "relay",
+			Password:            "pass",
+			LocalTTL:            ct.NewOptDuration(3 * time.Second),
+			WriteConcern:        "majority",
+			WriteConcernJournal: true,
+			WriteConcernTimeout: ct.NewOptDuration(5 * time.Second),
+			TLS:                 true,
+			TLSCAFile:           "ca.crt",
+			TLSCertFile:         "client.crt",
+			TLSKeyFile:          "client.key",
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_PROMETHEUS": "1",
+		"USE_MONGODB":                   "1",
+		"MONGODB_URL":                   "mongodb://mongo1:27017,mongo2:27017/?replicaSet=rs0",
+		"MONGODB_DATABASE":              "relay",
+		"MONGODB_PASSWORD":              "pass",
+		"CACHE_TTL":                     "3s",
+		"MONGODB_WRITE_CONCERN":         "majority",
+		"MONGODB_WRITE_CONCERN_JOURNAL": "1",
+		"MONGODB_WRITE_CONCERN_TIMEOUT": "5s",
+		"MONGODB_TLS":                   "1",
+		"MONGODB_TLS_CA_FILE":           "ca.crt",
+		"MONGODB_TLS_CERT_FILE":         "client.crt",
+		"MONGODB_TLS_KEY_FILE":          "client.key",
 	}
 	c.fileContent = `
-[Prometheus]
-Enabled = true
+[MongoDB]
+URL = "mongodb://mongo1:27017,mongo2:27017/?replicaSet=rs0"
+Database = "relay"
+Password = "pass"
+LocalTTL = 3s
+WriteConcern = majority
+WriteConcernJournal = true
+WriteConcernTimeout = 5s
+TLS = true
+TLSCAFile = ca.crt
+TLSCertFile = client.crt
+TLSKeyFile = client.key
 `
 	return c
 }
 
-func makeValidConfigPrometheusAll() testDataValidConfig {
-	c := testDataValidConfig{name: "Prometheus - all parameters"}
+func makeValidConfigEtcdMinimal() testDataValidConfig {
+	c := testDataValidConfig{name: "etcd - minimal parameters"}
 	c.makeConfig = func(c *Config) {
-		c.Prometheus = PrometheusConfig{
-			Enabled: true,
-			Prefix:  "pre-",
-			Port:    mustOptIntGreaterThanZero(8333),
+		c.Etcd = EtcdConfig{
+			Endpoints: ct.NewOptStringList([]string{"localhost:2379"}),
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_PROMETHEUS":    "1",
-		"PROMETHEUS_PREFIX": "pre-",
-		"PROMETHEUS_PORT":   "8333",
+		"USE_ETCD": "1",
 	}
 	c.fileContent = `
-[Prometheus]
-Enabled = true
-Prefix = "pre-"
-Port = 8333
+[Etcd]
+Endpoints = "localhost:2379"
 `
 	return c
 }
 
-func makeValidConfigProxy() testDataValidConfig {
-	c := testDataValidConfig{name: "proxy"}
+func makeValidConfigEtcdAll() testDataValidConfig {
+	c := testDataValidConfig{name: "etcd - all parameters"}
 	c.makeConfig = func(c *Config) {
-		c.Proxy = ProxyConfig{
-			URL:         newOptURLAbsoluteMustBeValid("http://proxy"),
-			User:        "user",
+		c.Etcd = EtcdConfig{
+			Endpoints:   ct.NewOptStringList([]string{"etcd1:2379", "etcd2:2379"}),
+			Username:    "relay",
 			Password:    "pass",
-			Domain:      "domain",
-			NTLMAuth:    true,
-			CACertFiles: ct.NewOptStringList([]string{"cert"}),
+			DialTimeout: ct.NewOptDuration(5 * time.Second),
+			TLS:         true,
+			TLSCAFile:   "ca.crt",
+			TLSCertFile: "client.crt",
+			TLSKeyFile:  "client.key",
 		}
 	}
 	c.envVars = map[string]string{
-		"PROXY_URL":           "http://proxy",
-		"PROXY_AUTH_USER":     "user",
-		"PROXY_AUTH_PASSWORD": "pass",
-		"PROXY_AUTH_DOMAIN":   "domain",
-		"PROXY_AUTH_NTLM":     "1",
-		"PROXY_CA_CERTS":      "cert",
+		"USE_ETCD":           "1",
+		"ETCD_ENDPOINTS":     "etcd1:2379,etcd2:2379",
+		"ETCD_USERNAME":      "relay",
+		"ETCD_PASSWORD":      "pass",
+		"ETCD_DIAL_TIMEOUT":  "5s",
+		"ETCD_TLS":           "1",
+		"ETCD_TLS_CA_FILE":   "ca.crt",
+		"ETCD_TLS_CERT_FILE": "client.crt",
+		"ETCD_TLS_KEY_FILE":  "client.key",
 	}
 	c.fileContent = `
-[Proxy]
-URL = "http://proxy"
-User = "user"
+[Etcd]
+Endpoints = "etcd1:2379"
+Endpoints = "etcd2:2379"
+Username = "relay"
 Password = "pass"
-Domain = "domain"
-NTLMAuth = true
-CaCertFiles = "cert"
+DialTimeout = 5s
+TLS = true
+TLSCAFile = ca.crt
+TLSCertFile = client.crt
+TLSKeyFile = client.key
+`
+	return c
+}
+
+func makeValidConfigTenants() testDataValidConfig {
+	c := testDataValidConfig{name: "tenants"}
+	c.makeConfig = func(c *Config) {
+		c.Tenant = map[string]*TenantConfig{
+			"acme":   {Port: mustOptIntGreaterThanZero(8031)},
+			"globex": {Host: ct.NewOptStringList([]string{"globex.example.com", "flags.globex.com"})},
+		}
+		c.Environment = map[string]*EnvConfig{
+			"a": {SDKKey: "key-a", Tenant: "acme"},
+			"b": {SDKKey: "key-b", Tenant: "globex"},
+			"c": {SDKKey: "key-c"},
+		}
+	}
+	c.envVars = map[string]string{
+		"TENANT_PORT_acme":   "8031",
+		"TENANT_HOST_globex": "globex.example.com,flags.globex.com",
+		"LD_ENV_a":           "key-a",
+		"LD_TENANT_a":        "acme",
+		"LD_ENV_b":           "key-b",
+		"LD_TENANT_b":        "globex",
+		"LD_ENV_c":           "key-c",
+	}
+	c.fileContent = `
+[Tenant "acme"]
+Port = 8031
+
+[Tenant "globex"]
+Host = "globex.example.com"
+Host = "flags.globex.com"
+
+[Environment "a"]
+SDKKey = "key-a"
+Tenant = "acme"
+
+[Environment "b"]
+SDKKey = "key-b"
+Tenant = "globex"
+
+[Environment "c"]
+SDKKey = "key-c"
+`
+	return c
+}
+
+func makeValidConfigStreamTuning() testDataValidConfig {
+	c := testDataValidConfig{name: "stream tuning"}
+	c.makeConfig = func(c *Config) {
+		c.Main.SSERetryDelay = ct.NewOptDuration(2 * time.Second)
+		c.Main.TCPKeepAlive = ct.NewOptDuration(30 * time.Second)
+		c.Tenant = map[string]*TenantConfig{
+			"acme": {
+				Port:              mustOptIntGreaterThanZero(8031),
+				HeartbeatInterval: ct.NewOptDuration(20 * time.Second),
+				SSERetryDelay:     ct.NewOptDuration(500 * time.Millisecond),
+				TCPKeepAlive:      ct.NewOptDuration(10 * time.Second),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"SSE_RETRY_DELAY":                "2s",
+		"TCP_KEEPALIVE":                  "30s",
+		"TENANT_PORT_acme":               "8031",
+		"TENANT_HEARTBEAT_INTERVAL_acme": "20s",
+		"TENANT_SSE_RETRY_DELAY_acme":    "500ms",
+		"TENANT_TCP_KEEPALIVE_acme":      "10s",
+	}
+	c.fileContent = `
+[Main]
+SSERetryDelay = 2s
+TCPKeepAlive = 30s
+
+[Tenant "acme"]
+Port = 8031
+HeartbeatInterval = 20s
+SSERetryDelay = 500ms
+TCPKeepAlive = 10s
+`
+	return c
+}
+
+func makeValidConfigTenantCertificates() testDataValidConfig {
+	c := testDataValidConfig{name: "tenant certificates"}
+	c.makeConfig = func(c *Config) {
+		c.Main.TLSEnabled = true
+		c.Main.TLSCert = "cert"
+		c.Main.TLSKey = "key"
+		c.Tenant = map[string]*TenantConfig{
+			"globex": {
+				Host:    ct.NewOptStringList([]string{"globex.example.com"}),
+				TLSCert: "globex-cert",
+				TLSKey:  "globex-key",
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"TLS_ENABLED":            "1",
+		"TLS_CERT":               "cert",
+		"TLS_KEY":                "key",
+		"TENANT_HOST_globex":     "globex.example.com",
+		"TENANT_TLS_CERT_globex": "globex-cert",
+		"TENANT_TLS_KEY_globex":  "globex-key",
+	}
+	c.fileContent = `
+[Main]
+TLSEnabled = true
+TLSCert = cert
+TLSKey = key
+
+[Tenant "globex"]
+Host = "globex.example.com"
+TLSCert = globex-cert
+TLSKey = globex-key
+`
+	return c
+}
+
+func makeValidConfigNamedDatabases() testDataValidConfig {
+	c := testDataValidConfig{name: "named databases selected by environments"}
+	c.makeConfig = func(c *Config) {
+		c.Redis = RedisConfig{URL: newOptURLAbsoluteMustBeValid("redis://localhost:6379")}
+		c.RedisDatabase = map[string]*RedisConfig{
+			"tenants": {
+				URL:          newOptURLAbsoluteMustBeValid("redis://node1:6379"),
+				ClusterAddrs: ct.NewOptStringList([]string{"node1:6379", "node2:6379"}),
+				Password:     "pass",
+			},
+		}
+		c.ConsulDatabase = map[string]*ConsulConfig{
+			"cdb": {Host: "consulhost", Token: "abc"},
+		}
+		c.DynamoDBDatabase = map[string]*DynamoDBConfig{
+			"ddb": {Enabled: true, TableName: "flags", LocalTTL: ct.NewOptDuration(time.Minute)},
+		}
+		c.PostgresDatabase = map[string]*PostgresConfig{
+			"pg": {URL: newOptURLAbsoluteMustBeValid("postgres://pghost:5432/relay")},
+		}
+		c.MongoDBDatabase = map[string]*MongoDBConfig{
+			"mdb": {URL: newOptURLAbsoluteMustBeValid("mongodb://mongohost:27017"), WriteConcern: "2"},
+		}
+		c.EtcdDatabase = map[string]*EtcdConfig{
+			"edb": {Endpoints: ct.NewOptStringList([]string{"etcdhost:2379"})},
+		}
+		c.Environment = map[string]*EnvConfig{
+			"a": {SDKKey: "key-a"},
+			"b": {SDKKey: "key-b", Database: "tenants", Prefix: "{b}"},
+			"c": {SDKKey: "key-c", Database: "tenants", Prefix: "{c}"},
+			"d": {SDKKey: "key-d", Database: "ddb"},
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_REDIS":                   "1",
+		"REDIS_CLUSTER_ADDRS_tenants": "node1:6379,node2:6379",
+		"REDIS_PASSWORD_tenants":      "pass",
+		"CONSUL_HOST_cdb":             "consulhost",
+		"CONSUL_TOKEN_cdb":            "abc",
+		"USE_DYNAMODB_ddb":            "1",
+		"DYNAMODB_TABLE_ddb":          "flags",
+		"CACHE_TTL_ddb":               "1m",
+		"POSTGRES_URL_pg":             "postgres://pghost:5432/relay",
+		"MONGODB_URL_mdb":             "mongodb://mongohost:27017",
+		"MONGODB_WRITE_CONCERN_mdb":   "2",
+		"ETCD_ENDPOINTS_edb":          "etcdhost:2379",
+		"LD_ENV_a":                    "key-a",
+		"LD_ENV_b":                    "key-b",
+		"LD_DATABASE_b":               "tenants",
+		"LD_PREFIX_b":                 "{b}",
+		"LD_ENV_c":                    "key-c",
+		"LD_DATABASE_c":               "tenants",
+		"LD_PREFIX_c":                 "{c}",
+		"LD_ENV_d":                    "key-d",
+		"LD_DATABASE_d":               "ddb",
+	}
+	c.fileContent = `
+[Redis]
+URL = "redis://localhost:6379"
+
+[RedisDatabase "tenants"]
+ClusterAddrs = "node1:6379"
+ClusterAddrs = "node2:6379"
+Password = "pass"
+
+[ConsulDatabase "cdb"]
+Host = "consulhost"
+Token = "abc"
+
+[DynamoDBDatabase "ddb"]
+TableName = "flags"
+LocalTTL = 1m
+
+[PostgresDatabase "pg"]
+URL = "postgres://pghost:5432/relay"
+
+[MongoDBDatabase "mdb"]
+URL = "mongodb://mongohost:27017"
+WriteConcern = 2
+
+[EtcdDatabase "edb"]
+Endpoints = "etcdhost:2379"
+
+[Environment "a"]
+SDKKey = "key-a"
+
+[Environment "b"]
+SDKKey = "key-b"
+Database = "tenants"
+Prefix = "{b}"
+
+[Environment "c"]
+SDKKey = "key-c"
+Database = "tenants"
+Prefix = "{c}"
+
+[Environment "d"]
+SDKKey = "key-d"
+Database = "ddb"
+`
+	c.warnings = []string{
+		`environment "a" does not have a prefix specified for database storage`,
+		`environment "d" does not have a prefix or table name specified for database storage`,
+	}
+	return c
+}
+
+func makeValidConfigBigSegmentsStoreType() testDataValidConfig {
+	c := testDataValidConfig{name: "big segments - custom store type"}
+	c.makeConfig = func(c *Config) {
+		c.BigSegments = BigSegmentsConfig{
+			Type: "my-store",
+		}
+	}
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_STORE_TYPE": "my-store",
+	}
+	c.fileContent = `
+[BigSegments]
+Type = my-store
+`
+	return c
+}
+
+func makeValidConfigBigSegmentsUserCache() testDataValidConfig {
+	c := testDataValidConfig{name: "big segments - user cache"}
+	c.makeConfig = func(c *Config) {
+		c.BigSegments = BigSegmentsConfig{
+			UserCacheSize: mustOptIntGreaterThanZero(10000),
+			UserCacheTime: ct.NewOptDuration(time.Minute),
+		}
+	}
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_USER_CACHE_SIZE": "10000",
+		"BIG_SEGMENTS_USER_CACHE_TIME": "1m",
+	}
+	c.fileContent = `
+[BigSegments]
+UserCacheSize = 10000
+UserCacheTime = 1m
+`
+	return c
+}
+
+func makeValidConfigBigSegmentsCircuitBreaker() testDataValidConfig {
+	c := testDataValidConfig{name: "big segments - circuit breaker"}
+	c.makeConfig = func(c *Config) {
+		c.BigSegments = BigSegmentsConfig{
+			CircuitBreakerFailures:      mustOptIntGreaterThanZero(5),
+			CircuitBreakerRetryInterval: ct.NewOptDuration(30 * time.Second),
+			CircuitBreakerFallback:      BigSegmentsCircuitBreakerFallbackLastKnown,
+		}
+	}
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_CIRCUIT_BREAKER_FAILURES":       "5",
+		"BIG_SEGMENTS_CIRCUIT_BREAKER_RETRY_INTERVAL": "30s",
+		"BIG_SEGMENTS_CIRCUIT_BREAKER_FALLBACK":       "lastKnown",
+	}
+	c.fileContent = `
+[BigSegments]
+CircuitBreakerFailures = 5
+CircuitBreakerRetryInterval = 30s
+CircuitBreakerFallback = lastKnown
+`
+	return c
+}
+
+func makeValidConfigBigSegmentsConcurrencyLimit() testDataValidConfig {
+	c := testDataValidConfig{name: "big segments - concurrency limit"}
+	c.makeConfig = func(c *Config) {
+		c.BigSegments = BigSegmentsConfig{
+			MaxConcurrentQueries: mustOptIntGreaterThanZero(20),
+			QueryQueueTimeout:    ct.NewOptDuration(100 * time.Millisecond),
+		}
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:                          SDKKey("krypton-sdk"),
+				BigSegmentsMaxConcurrentQueries: mustOptIntGreaterThanZero(5),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_MAX_CONCURRENT_QUERIES":            "20",
+		"BIG_SEGMENTS_QUERY_QUEUE_TIMEOUT":               "100ms",
+		"LD_ENV_krypton":                                 "krypton-sdk",
+		"LD_BIG_SEGMENTS_MAX_CONCURRENT_QUERIES_krypton": "5",
+	}
+	c.fileContent = `
+[BigSegments]
+MaxConcurrentQueries = 20
+QueryQueueTimeout = 100ms
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
+BigSegmentsMaxConcurrentQueries = 5
+`
+	return c
+}
+
+func makeValidConfigBigSegmentsNegativeCache() testDataValidConfig {
+	c := testDataValidConfig{name: "big segments - negative cache"}
+	c.makeConfig = func(c *Config) {
+		c.BigSegments = BigSegmentsConfig{
+			NegativeCacheSize: mustOptIntGreaterThanZero(50000),
+			NegativeCacheTime: ct.NewOptDuration(2 * time.Second),
+		}
+	}
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_NEGATIVE_CACHE_SIZE": "50000",
+		"BIG_SEGMENTS_NEGATIVE_CACHE_TIME": "2s",
+	}
+	c.fileContent = `
+[BigSegments]
+NegativeCacheSize = 50000
+NegativeCacheTime = 2s
+`
+	return c
+}
+
+func makeValidConfigBigSegmentsWatchInterval() testDataValidConfig {
+	c := testDataValidConfig{name: "big segments - watch interval"}
+	c.makeConfig = func(c *Config) {
+		c.BigSegments = BigSegmentsConfig{
+			WatchInterval: ct.NewOptDuration(10 * time.Second),
+		}
+	}
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_WATCH_INTERVAL": "10s",
+	}
+	c.fileContent = `
+[BigSegments]
+WatchInterval = 10s
+`
+	return c
+}
+
+func makeValidConfigBigSegmentsS3Store() testDataValidConfig {
+	c := testDataValidConfig{name: "big segments - S3 store"}
+	c.makeConfig = func(c *Config) {
+		c.BigSegments = BigSegmentsConfig{
+			Type:           BigSegmentsStoreTypeS3,
+			S3Bucket:       "my-bucket",
+			S3Prefix:       "segments/",
+			S3PollInterval: ct.NewOptDuration(time.Minute),
+		}
+	}
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_STORE_TYPE":       "s3",
+		"BIG_SEGMENTS_S3_BUCKET":        "my-bucket",
+		"BIG_SEGMENTS_S3_PREFIX":        "segments/",
+		"BIG_SEGMENTS_S3_POLL_INTERVAL": "1m",
+	}
+	c.fileContent = `
+[BigSegments]
+Type = s3
+S3Bucket = my-bucket
+S3Prefix = segments/
+S3PollInterval = 1m
+`
+	return c
+}
+
+func makeValidConfigBigSegmentsMemoryStore() testDataValidConfig {
+	c := testDataValidConfig{name: "big segments - memory store with snapshots"}
+	c.makeConfig = func(c *Config) {
+		c.BigSegments = BigSegmentsConfig{
+			Type:             BigSegmentsStoreTypeMemory,
+			SnapshotDir:      "/var/lib/ld-relay",
+			SnapshotInterval: ct.NewOptDuration(5 * time.Minute),
+		}
+	}
+	c.envVars = map[string]string{
+		"BIG_SEGMENTS_STORE_TYPE":        "memory",
+		"BIG_SEGMENTS_SNAPSHOT_DIR":      "/var/lib/ld-relay",
+		"BIG_SEGMENTS_SNAPSHOT_INTERVAL": "5m",
+	}
+	c.fileContent = `
+[BigSegments]
+Type = memory
+SnapshotDir = /var/lib/ld-relay
+SnapshotInterval = 5m
+`
+	return c
+}
+
+func makeValidConfigDatadogMinimal() testDataValidConfig {
+	c := testDataValidConfig{name: "Datadog - minimal parameters"}
+	c.makeConfig = func(c *Config) {
+		c.Datadog = DatadogConfig{
+			Enabled: true,
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_DATADOG": "1",
+	}
+	c.fileContent = `
+[Datadog]
+Enabled = true
+`
+	return c
+}
+
+func makeValidConfigDatadogAll() testDataValidConfig {
+	c := testDataValidConfig{name: "Datadog - all parameters"}
+	c.makeConfig = func(c *Config) {
+		c.Datadog = DatadogConfig{
+			Enabled:   true,
+			Prefix:    "pre-",
+			TraceAddr: "trace",
+			StatsAddr: "stats",
+			Tag:       []string{"tag1:value1", "tag2:value2"},
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_DATADOG":        "1",
+		"DATADOG_PREFIX":     "pre-",
+		"DATADOG_TRACE_ADDR": "trace",
+		"DATADOG_STATS_ADDR": "stats",
+		"DATADOG_TAG_tag1":   "value1",
+		"DATADOG_TAG_tag2":   "value2",
+	}
+	c.fileContent = `
+[Datadog]
+Enabled = true
+Prefix = "pre-"
+TraceAddr = "trace"
+StatsAddr = "stats"
+Tag = "tag1:value1"
+Tag = "tag2:value2"
+`
+	return c
+}
+
+func makeValidConfigNewrelicMinimal() testDataValidConfig {
+	c := testDataValidConfig{name: "Newrelic - minimal parameters"}
+	c.makeConfig = func(c *Config) {
+		c.Newrelic = NewrelicConfig{
+			Enabled:     true,
+			InsightsKey: "sample_insights_key",
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_NEWRELIC":          "1",
+		"NEWRELIC_INSIGHTS_KEY": "sample_insights_key",
+	}
+	c.fileContent = `
+[Newrelic]
+Enabled = true
+InsightsKey= sample_insights_key
+`
+	return c
+}
+
+func makeValidConfigNewrelicAll() testDataValidConfig {
+	c := testDataValidConfig{name: "Newrelic - all parameters"}
+	c.makeConfig = func(c *Config) {
+		c.Newrelic = NewrelicConfig{
+			Enabled:     true,
+			AppName:     "sample_ldrelay_namme",
+			InsightsKey: "sample_insights_key",
+			Prefix:      "pre-",
+			TraceURL:    "https://trace-api.newrelic.com/trace/v1",
+			MetricsURL:  "https://metric-api.newrelic.com/metric/v1",
+			EventsURL:   "https://insights-collector.newrelic.com",
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_NEWRELIC":          "1",
+		"NEWRELIC_APPNAME":      "sample_ldrelay_namme",
+		"NEWRELIC_INSIGHTS_KEY": "sample_insights_key",
+		"NEWRELIC_PREFIX":       "pre-",
+		"NEWRELIC_TRACE_URL":    "https://trace-api.newrelic.com/trace/v1",
+		"NEWRELIC_METRICS_URL":  "https://metric-api.newrelic.com/metric/v1",
+		"NEWRELIC_EVENTS_URL":   "https://insights-collector.newrelic.com",
+	}
+	c.fileContent = `
+[Newrelic]
+Enabled= true
+AppName= "sample_ldrelay_namme"
+InsightsKey= "sample_insights_key"
+Prefix=  "pre-"
+TraceURL= "https://trace-api.newrelic.com/trace/v1"
+MetricsURL="https://metric-api.newrelic.com/metric/v1"
+EventsURL= "https://insights-collector.newrelic.com"
+`
+	return c
+}
+
+func makeValidConfigStackdriverMinimal() testDataValidConfig {
+	c := testDataValidConfig{name: "Stackdriver - minimal parameters"}
+	c.makeConfig = func(c *Config) {
+		c.Stackdriver = StackdriverConfig{
+			Enabled: true,
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_STACKDRIVER": "1",
+	}
+	c.fileContent = `
+[Stackdriver]
+Enabled = true
+`
+	return c
+}
+
+func makeValidConfigStackdriverAll() testDataValidConfig {
+	c := testDataValidConfig{name: "Stackdriver - all parameters"}
+	c.makeConfig = func(c *Config) {
+		c.Stackdriver = StackdriverConfig{
+			Enabled:   true,
+			Prefix:    "pre-",
+			ProjectID: "proj",
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_STACKDRIVER":        "1",
+		"STACKDRIVER_PREFIX":     "pre-",
+		"STACKDRIVER_PROJECT_ID": "proj",
+	}
+	c.fileContent = `
+[Stackdriver]
+Enabled = true
+Prefix = "pre-"
+ProjectID = "proj"
+`
+	return c
+}
+
+func makeValidConfigLogging() testDataValidConfig {
+	c := testDataValidConfig{name: "log format and component log levels"}
+	c.makeConfig = func(c *Config) {
+		c.Main.LogLevel = NewOptLogLevel(ldlog.Warn)
+		c.Main.LogFormat = LogFormatJSON
+		c.Main.LogLevelSDKs = NewOptLogLevel(ldlog.Error)
+		c.Main.LogLevelEvents = NewOptLogLevel(ldlog.Info)
+		c.Main.LogLevelBigSegments = NewOptLogLevel(ldlog.Debug)
+		c.Main.LogLevelHTTP = NewOptLogLevel(ldlog.None)
+	}
+	c.envVars = map[string]string{
+		"LOG_LEVEL":              "warn",
+		"LOG_FORMAT":             "json",
+		"LOG_LEVEL_SDKS":         "error",
+		"LOG_LEVEL_EVENTS":       "info",
+		"LOG_LEVEL_BIG_SEGMENTS": "debug",
+		"LOG_LEVEL_HTTP":         "none",
+	}
+	c.fileContent = `
+[Main]
+LogLevel = "warn"
+LogFormat = "json"
+LogLevelSDKs = "error"
+LogLevelEvents = "info"
+LogLevelBigSegments = "debug"
+LogLevelHTTP = "none"
+`
+	return c
+}
+
+func makeValidConfigAccessLog() testDataValidConfig {
+	c := testDataValidConfig{name: "access log"}
+	c.makeConfig = func(c *Config) {
+		c.AccessLog = AccessLogConfig{
+			Enabled:       true,
+			Format:        AccessLogFormatCombined,
+			Output:        AccessLogOutputFile,
+			File:          "/var/log/relay/access.log",
+			MaxFileSize:   mustOptIntGreaterThanZero(10),
+			MaxFiles:      mustOptIntGreaterThanZero(3),
+			SyslogAddress: newOptURLAbsoluteMustBeValid("udp://syslog:514"),
+			SyslogTag:     "relay",
+		}
+	}
+	c.envVars = map[string]string{
+		"ACCESS_LOG_ENABLED":        "1",
+		"ACCESS_LOG_FORMAT":         "combined",
+		"ACCESS_LOG_OUTPUT":         "file",
+		"ACCESS_LOG_FILE":           "/var/log/relay/access.log",
+		"ACCESS_LOG_MAX_FILE_SIZE":  "10",
+		"ACCESS_LOG_MAX_FILES":      "3",
+		"ACCESS_LOG_SYSLOG_ADDRESS": "udp://syslog:514",
+		"ACCESS_LOG_SYSLOG_TAG":     "relay",
+	}
+	c.fileContent = `
+[AccessLog]
+Enabled = true
+Format = "combined"
+Output = "file"
+File = "/var/log/relay/access.log"
+MaxFileSize = 10
+MaxFiles = 3
+SyslogAddress = "udp://syslog:514"
+SyslogTag = "relay"
+`
+	return c
+}
+
+func makeValidConfigHealth() testDataValidConfig {
+	c := testDataValidConfig{name: "health checks"}
+	c.makeConfig = func(c *Config) {
+		c.Health = HealthConfig{
+			DisableUpstreamCheck:    true,
+			DisableBigSegmentsCheck: true,
+			DisableEventsCheck:      true,
+		}
+	}
+	c.envVars = map[string]string{
+		"HEALTH_DISABLE_UPSTREAM_CHECK":     "1",
+		"HEALTH_DISABLE_BIG_SEGMENTS_CHECK": "1",
+		"HEALTH_DISABLE_EVENTS_CHECK":       "1",
+	}
+	c.fileContent = `
+[Health]
+DisableUpstreamCheck = true
+DisableBigSegmentsCheck = true
+DisableEventsCheck = true
+`
+	return c
+}
+
+func makeValidConfigShadow() testDataValidConfig {
+	c := testDataValidConfig{name: "traffic shadowing"}
+	c.makeConfig = func(c *Config) {
+		c.Shadow = ShadowConfig{
+			URI:        newOptURLAbsoluteMustBeValid("http://shadow-relay:8030"),
+			Percentage: ct.NewOptFloat64(12.5),
+			Timeout:    ct.NewOptDuration(2 * time.Second),
+		}
+	}
+	c.envVars = map[string]string{
+		"SHADOW_URI":        "http://shadow-relay:8030",
+		"SHADOW_PERCENTAGE": "12.5",
+		"SHADOW_TIMEOUT":    "2s",
+	}
+	c.fileContent = `
+[Shadow]
+URI = "http://shadow-relay:8030"
+Percentage = 12.5
+Timeout = 2s
+`
+	return c
+}
+
+func makeValidConfigResponseHeaders() testDataValidConfig {
+	c := testDataValidConfig{name: "response headers"}
+	c.makeConfig = func(c *Config) {
+		c.ResponseHeaders = ResponseHeadersConfig{
+			Header:          []string{"X-Served-By: relay-1"},
+			PollingHeader:   []string{"Cache-Control: max-age=30"},
+			StreamingHeader: []string{"X-Accel-Buffering: no"},
+			EventsHeader:    []string{"X-Trace-Source: relay"},
+			SecurityHeaders: true,
+			HSTSMaxAge:      ct.NewOptDuration(24 * time.Hour),
+		}
+	}
+	c.envVars = map[string]string{
+		"RESPONSE_HEADER_X_Served_By":                 "relay-1",
+		"POLLING_RESPONSE_HEADER_Cache_Control":       "max-age=30",
+		"STREAMING_RESPONSE_HEADER_X_Accel_Buffering": "no",
+		"EVENTS_RESPONSE_HEADER_X_Trace_Source":       "relay",
+		"SECURITY_HEADERS":                            "1",
+		"HSTS_MAX_AGE":                                "24h",
+	}
+	c.fileContent = `
+[ResponseHeaders]
+Header = "X-Served-By: relay-1"
+PollingHeader = "Cache-Control: max-age=30"
+StreamingHeader = "X-Accel-Buffering: no"
+EventsHeader = "X-Trace-Source: relay"
+SecurityHeaders = 1
+HSTSMaxAge = 24h
+`
+	return c
+}
+
+func makeValidConfigAuditLog() testDataValidConfig {
+	c := testDataValidConfig{name: "audit log"}
+	c.makeConfig = func(c *Config) {
+		c.AuditLog = AuditLogConfig{
+			File:          "/var/log/relay/audit.log",
+			WebhookURL:    newOptURLAbsoluteMustBeValid("https://example.com/audit"),
+			WebhookHeader: ct.NewOptStringList([]string{"Authorization=Bearer xyz", "X-Source=relay"}),
+		}
+	}
+	c.envVars = map[string]string{
+		"AUDIT_LOG_FILE":            "/var/log/relay/audit.log",
+		"AUDIT_LOG_WEBHOOK_URL":     "https://example.com/audit",
+		"AUDIT_LOG_WEBHOOK_HEADERS": "Authorization=Bearer xyz,X-Source=relay",
+	}
+	c.fileContent = `
+[AuditLog]
+File = "/var/log/relay/audit.log"
+WebhookURL = "https://example.com/audit"
+WebhookHeader = "Authorization=Bearer xyz"
+WebhookHeader = "X-Source=relay"
+`
+	return c
+}
+
+func makeValidConfigPrometheusMinimal() testDataValidConfig {
+	c := testDataValidConfig{name: "Prometheus - minimal parameters"}
+	c.makeConfig = func(c *Config) {
+		c.Prometheus = PrometheusConfig{
+			Enabled: true,
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_PROMETHEUS": "1",
+	}
+	c.fileContent = `
+[Prometheus]
+Enabled = true
+`
+	return c
+}
+
+func makeValidConfigPrometheusAll() testDataValidConfig {
+	c := testDataValidConfig{name: "Prometheus - all parameters"}
+	c.makeConfig = func(c *Config) {
+		c.Prometheus = PrometheusConfig{
+			Enabled: true,
+			Prefix:  "pre-",
+			Port:    mustOptIntGreaterThanZero(8333),
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_PROMETHEUS":    "1",
+		"PROMETHEUS_PREFIX": "pre-",
+		"PROMETHEUS_PORT":   "8333",
+	}
+	c.fileContent = `
+[Prometheus]
+Enabled = true
+Prefix = "pre-"
+Port = 8333
+`
+	return c
+}
+
+func makeValidConfigOpenTelemetryMinimal() testDataValidConfig {
+	c := testDataValidConfig{name: "OpenTelemetry - minimal parameters"}
+	c.makeConfig = func(c *Config) {
+		c.OpenTelemetry = OpenTelemetryConfig{
+			Enabled: true,
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_OPENTELEMETRY": "1",
+	}
+	c.fileContent = `
+[OpenTelemetry]
+Enabled = true
+`
+	return c
+}
+
+func makeValidConfigOpenTelemetryAll() testDataValidConfig {
+	c := testDataValidConfig{name: "OpenTelemetry - all parameters"}
+	c.makeConfig = func(c *Config) {
+		c.OpenTelemetry = OpenTelemetryConfig{
+			Enabled:       true,
+			Endpoint:      newOptURLAbsoluteMustBeValid("http://collector:4318"),
+			Header:        ct.NewOptStringList([]string{"Authorization=abc", "X-Tenant=relay"}),
+			ServiceName:   "my-relay",
+			SamplingRatio: ct.NewOptFloat64(0.25),
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_OPENTELEMETRY":            "1",
+		"OPENTELEMETRY_ENDPOINT":       "http://collector:4318",
+		"OPENTELEMETRY_HEADERS":        "Authorization=abc,X-Tenant=relay",
+		"OPENTELEMETRY_SERVICE_NAME":   "my-relay",
+		"OPENTELEMETRY_SAMPLING_RATIO": "0.25",
+	}
+	c.fileContent = `
+[OpenTelemetry]
+Enabled = true
+Endpoint = "http://collector:4318"
+Header = "Authorization=abc"
+Header = "X-Tenant=relay"
+ServiceName = "my-relay"
+SamplingRatio = 0.25
+`
+	return c
+}
+
+func makeValidConfigProxy() testDataValidConfig {
+	c := testDataValidConfig{name: "proxy"}
+	c.makeConfig = func(c *Config) {
+		c.Proxy = ProxyConfig{
+			URL:         newOptURLAbsoluteMustBeValid("http://proxy"),
+			User:        "user",
+			Password:    "pass",
+			Domain:      "domain",
+			NTLMAuth:    true,
+			CACertFiles: ct.NewOptStringList([]string{"cert"}),
+		}
+	}
+	c.envVars = map[string]string{
+		"PROXY_URL":           "http://proxy",
+		"PROXY_AUTH_USER":     "user",
+		"PROXY_AUTH_PASSWORD": "pass",
+		"PROXY_AUTH_DOMAIN":   "domain",
+		"PROXY_AUTH_NTLM":     "1",
+		"PROXY_CA_CERTS":      "cert",
+	}
+	c.fileContent = `
+[Proxy]
+URL = "http://proxy"
+User = "user"
+Password = "pass"
+Domain = "domain"
+NTLMAuth = true
+CaCertFiles = "cert"
+`
+	return c
+}
+
+func makeValidConfigProxyDestinationsAndNegotiate() testDataValidConfig {
+	c := testDataValidConfig{name: "proxy destinations and Negotiate authentication"}
+	c.makeConfig = func(c *Config) {
+		c.Proxy = ProxyConfig{
+			URL:            newOptURLAbsoluteMustBeValid("http://proxy"),
+			NegotiateAuth:  true,
+			User:           "user",
+			Domain:         "EXAMPLE.COM",
+			KerberosConfig: "krb5.conf",
+			KeytabFile:     "user.keytab",
+			Destination: ct.NewOptStringList([]string{
+				"stream.launchdarkly.com=http://stream-proxy:8080",
+				"events.launchdarkly.com=http://events-proxy:8080",
+			}),
+			NoProxy: ct.NewOptStringList([]string{"10.0.0.0/8", ".internal"}),
+		}
+	}
+	c.envVars = map[string]string{
+		"PROXY_URL":              "http://proxy",
+		"PROXY_AUTH_NEGOTIATE":   "1",
+		"PROXY_AUTH_USER":        "user",
+		"PROXY_AUTH_DOMAIN":      "EXAMPLE.COM",
+		"PROXY_AUTH_KRB5_CONFIG": "krb5.conf",
+		"PROXY_AUTH_KEYTAB":      "user.keytab",
+		"PROXY_DESTINATIONS":     "stream.launchdarkly.com=http://stream-proxy:8080,events.launchdarkly.com=http://events-proxy:8080",
+		"PROXY_NO_PROXY":         "10.0.0.0/8,.internal",
+	}
+	c.fileContent = `
+[Proxy]
+URL = "http://proxy"
+NegotiateAuth = true
+User = "user"
+Domain = "EXAMPLE.COM"
+KerberosConfig = "krb5.conf"
+KeytabFile = "user.keytab"
+Destination = "stream.launchdarkly.com=http://stream-proxy:8080"
+Destination = "events.launchdarkly.com=http://events-proxy:8080"
+NoProxy = "10.0.0.0/8"
+NoProxy = ".internal"
+`
+	return c
+}
+
+func makeValidConfigSecrets() testDataValidConfig {
+	c := testDataValidConfig{name: "secrets"}
+	c.makeConfig = func(c *Config) {
+		c.Secrets = SecretsConfig{
+			VaultURL:        newOptURLAbsoluteMustBeValid("https://vault.example.com:8200"),
+			VaultToken:      "vault-token",
+			RefreshInterval: ct.NewOptDuration(10 * time.Minute),
+		}
+		c.Environment = map[string]*EnvConfig{
+			"earth": {
+				SDKKey:    "vault://secret/relay/earth#sdkKey",
+				MobileKey: "vault://secret/relay/earth#mobileKey",
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"SECRETS_VAULT_URL":        "https://vault.example.com:8200",
+		"SECRETS_VAULT_TOKEN":      "vault-token",
+		"SECRETS_REFRESH_INTERVAL": "10m",
+		"LD_ENV_earth":             "vault://secret/relay/earth#sdkKey",
+		"LD_MOBILE_KEY_earth":      "vault://secret/relay/earth#mobileKey",
+	}
+	c.fileContent = `
+[Secrets]
+VaultURL = "https://vault.example.com:8200"
+VaultToken = vault-token
+RefreshInterval = 10m
+
+[Environment "earth"]
+SdkKey = "vault://secret/relay/earth#sdkKey"
+MobileKey = "vault://secret/relay/earth#mobileKey"
+`
+	return c
+}
+
+func makeValidConfigAdmin() testDataValidConfig {
+	c := testDataValidConfig{name: "admin API"}
+	c.makeConfig = func(c *Config) {
+		c.Admin = AdminConfig{
+			Port: mustOptIntGreaterThanZero(8040),
+			Key:  "admin-key",
+		}
+	}
+	c.envVars = map[string]string{
+		"ADMIN_PORT": "8040",
+		"ADMIN_KEY":  "admin-key",
+	}
+	c.fileContent = `
+[Admin]
+Port = 8040
+Key = admin-key
+`
+	return c
+}
+
+func makeValidConfigAdminProfiling() testDataValidConfig {
+	c := testDataValidConfig{name: "admin API with profiling"}
+	c.makeConfig = func(c *Config) {
+		c.Admin = AdminConfig{
+			Port:            mustOptIntGreaterThanZero(8040),
+			Key:             "admin-key",
+			EnableProfiling: true,
+		}
+	}
+	c.envVars = map[string]string{
+		"ADMIN_PORT":             "8040",
+		"ADMIN_KEY":              "admin-key",
+		"ADMIN_ENABLE_PROFILING": "true",
+	}
+	c.fileContent = `
+[Admin]
+Port = 8040
+Key = admin-key
+EnableProfiling = true
+`
+	return c
+}
+
+func makeValidConfigInternalMetrics() testDataValidConfig {
+	c := testDataValidConfig{name: "internal metrics"}
+	c.makeConfig = func(c *Config) {
+		c.InternalMetrics = InternalMetricsConfig{
+			Port:   mustOptIntGreaterThanZero(8050),
+			Prefix: "relay_internals",
+		}
+	}
+	c.envVars = map[string]string{
+		"INTERNAL_METRICS_PORT":   "8050",
+		"INTERNAL_METRICS_PREFIX": "relay_internals",
+	}
+	c.fileContent = `
+[InternalMetrics]
+Port = 8050
+Prefix = relay_internals
+`
+	return c
+}
+
+func makeValidConfigRateLimit() testDataValidConfig {
+	c := testDataValidConfig{name: "rate limit"}
+	c.makeConfig = func(c *Config) {
+		c.RateLimit = RateLimitConfig{
+			ServerSide:      ct.NewOptFloat64(100),
+			Mobile:          ct.NewOptFloat64(50),
+			ClientSide:      ct.NewOptFloat64(50),
+			PerClientIP:     ct.NewOptFloat64(2.5),
+			Burst:           mustOptIntGreaterThanZero(200),
+			UseForwardedFor: true,
+			TrustedProxies:  ct.NewOptStringList([]string{"10.0.0.0/8", "192.168.1.1"}),
+		}
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:              SDKKey("krypton-sdk"),
+				RateLimitServerSide: ct.NewOptFloat64(10),
+				RateLimitMobile:     ct.NewOptFloat64(5),
+				RateLimitClientSide: ct.NewOptFloat64(0.5),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"RATE_LIMIT_SERVER_SIDE":            "100",
+		"RATE_LIMIT_MOBILE":                 "50",
+		"RATE_LIMIT_CLIENT_SIDE":            "50",
+		"RATE_LIMIT_PER_CLIENT_IP":          "2.5",
+		"RATE_LIMIT_BURST":                  "200",
+		"RATE_LIMIT_USE_FORWARDED_FOR":      "true",
+		"RATE_LIMIT_TRUSTED_PROXIES":        "10.0.0.0/8,192.168.1.1",
+		"LD_ENV_krypton":                    "krypton-sdk",
+		"LD_RATE_LIMIT_SERVER_SIDE_krypton": "10",
+		"LD_RATE_LIMIT_MOBILE_krypton":      "5",
+		"LD_RATE_LIMIT_CLIENT_SIDE_krypton": "0.5",
+	}
+	c.fileContent = `
+[RateLimit]
+ServerSide = 100
+Mobile = 50
+ClientSide = 50
+PerClientIP = 2.5
+Burst = 200
+UseForwardedFor = true
+TrustedProxies = 10.0.0.0/8
+TrustedProxies = 192.168.1.1
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
+RateLimitServerSide = 10
+RateLimitMobile = 5
+RateLimitClientSide = 0.5
+`
+	return c
+}
+
+func makeValidConfigStreamLimits() testDataValidConfig {
+	c := testDataValidConfig{name: "stream limits"}
+	c.makeConfig = func(c *Config) {
+		c.StreamLimits = StreamLimitsConfig{
+			MaxConnections:       mustOptIntGreaterThanZero(10000),
+			MaxConnectionsPerEnv: mustOptIntGreaterThanZero(2000),
+			Overflow:             StreamLimitOverflowQueue,
+			QueueTimeout:         ct.NewOptDuration(time.Second * 2),
+			RetryAfter:           ct.NewOptDuration(time.Second * 30),
+		}
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:               SDKKey("krypton-sdk"),
+				MaxStreamConnections: mustOptIntGreaterThanZero(500),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"STREAM_LIMIT_MAX_CONNECTIONS":         "10000",
+		"STREAM_LIMIT_MAX_CONNECTIONS_PER_ENV": "2000",
+		"STREAM_LIMIT_OVERFLOW":                "queue",
+		"STREAM_LIMIT_QUEUE_TIMEOUT":           "2s",
+		"STREAM_LIMIT_RETRY_AFTER":             "30s",
+		"LD_ENV_krypton":                       "krypton-sdk",
+		"LD_MAX_STREAM_CONNECTIONS_krypton":    "500",
+	}
+	c.fileContent = `
+[StreamLimits]
+MaxConnections = 10000
+MaxConnectionsPerEnv = 2000
+Overflow = queue
+QueueTimeout = 2s
+RetryAfter = 30s
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
+MaxStreamConnections = 500
+`
+	return c
+}
+
+func makeValidConfigMemoryLimits() testDataValidConfig {
+	c := testDataValidConfig{name: "memory limits"}
+	c.makeConfig = func(c *Config) {
+		c.MemoryLimits = MemoryLimitsConfig{
+			HighWaterMarkMB: mustOptIntGreaterThanZero(2048),
+			LowWaterMarkMB:  mustOptIntGreaterThanZero(1536),
+			CheckInterval:   ct.NewOptDuration(time.Millisecond * 500),
+		}
+	}
+	c.envVars = map[string]string{
+		"MEMORY_HIGH_WATER_MARK_MB": "2048",
+		"MEMORY_LOW_WATER_MARK_MB":  "1536",
+		"MEMORY_CHECK_INTERVAL":     "500ms",
+	}
+	c.fileContent = `
+[MemoryLimits]
+HighWaterMarkMB = 2048
+LowWaterMarkMB = 1536
+CheckInterval = 500ms
+`
+	return c
+}
+
+func makeValidConfigMaintenance() testDataValidConfig {
+	c := testDataValidConfig{name: "maintenance"}
+	c.makeConfig = func(c *Config) {
+		c.Maintenance = MaintenanceConfig{
+			RetryAfter:          ct.NewOptDuration(time.Minute * 5),
+			ResponseBody:        "Down for maintenance; back soon",
+			ResponseContentType: "text/plain",
+		}
+	}
+	c.envVars = map[string]string{
+		"MAINTENANCE_RETRY_AFTER":           "5m",
+		"MAINTENANCE_RESPONSE_BODY":         "Down for maintenance; back soon",
+		"MAINTENANCE_RESPONSE_CONTENT_TYPE": "text/plain",
+	}
+	c.fileContent = `
+[Maintenance]
+RetryAfter = 5m
+ResponseBody = "Down for maintenance; back soon"
+ResponseContentType = text/plain
+`
+	return c
+}
+
+func makeValidConfigACME() testDataValidConfig {
+	c := testDataValidConfig{name: "ACME"}
+	c.makeConfig = func(c *Config) {
+		c.Main.TLSEnabled = true
+		c.ACME = ACMEConfig{
+			Domain:       ct.NewOptStringList([]string{"relay.example.com", "relay2.example.com"}),
+			Email:        "ops@example.com",
+			CacheDir:     "/var/lib/relay/acme",
+			DirectoryURL: newOptURLAbsoluteMustBeValid("https://acme-staging-v02.api.letsencrypt.org/directory"),
+			HTTPPort:     mustOptIntGreaterThanZero(8080),
+		}
+	}
+	c.envVars = map[string]string{
+		"TLS_ENABLED":        "1",
+		"ACME_DOMAINS":       "relay.example.com,relay2.example.com",
+		"ACME_EMAIL":         "ops@example.com",
+		"ACME_CACHE_DIR":     "/var/lib/relay/acme",
+		"ACME_DIRECTORY_URL": "https://acme-staging-v02.api.letsencrypt.org/directory",
+		"ACME_HTTP_PORT":     "8080",
+	}
+	c.fileContent = `
+[Main]
+TLSEnabled = true
+
+[ACME]
+Domain = relay.example.com
+Domain = relay2.example.com
+Email = ops@example.com
+CacheDir = /var/lib/relay/acme
+DirectoryURL = https://acme-staging-v02.api.letsencrypt.org/directory
+HTTPPort = 8080
+`
+	return c
+}
+
+func makeValidConfigClientCerts() testDataValidConfig {
+	c := testDataValidConfig{name: "client certificates"}
+	c.makeConfig = func(c *Config) {
+		c.Main.TLSEnabled = true
+		c.Main.TLSCert = "cert"
+		c.Main.TLSKey = "key"
+		c.Main.TLSClientCertRequired = true
+		c.Main.TLSClientCA = "ca"
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:                SDKKey("krypton-sdk"),
+				ClientCA:              "krypton-ca",
+				AllowedClientCertName: ct.NewOptStringList([]string{"a.example.com", "b.example.com"}),
+				AllowedClientCertOU:   ct.NewOptStringList([]string{"team-c"}),
+			},
+			"xenon": {
+				SDKKey: SDKKey("xenon-sdk"),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"TLS_ENABLED":                         "1",
+		"TLS_CERT":                            "cert",
+		"TLS_KEY":                             "key",
+		"TLS_CLIENT_CERT_REQUIRED":            "1",
+		"TLS_CLIENT_CA":                       "ca",
+		"LD_ENV_krypton":                      "krypton-sdk",
+		"LD_CLIENT_CA_krypton":                "krypton-ca",
+		"LD_ALLOWED_CLIENT_CERT_NAME_krypton": "a.example.com,b.example.com",
+		"LD_ALLOWED_CLIENT_CERT_OU_krypton":   "team-c",
+		"LD_ENV_xenon":                        "xenon-sdk",
+	}
+	c.fileContent = `
+[Main]
+TLSEnabled = true
+TLSCert = cert
+TLSKey = key
+TLSClientCertRequired = true
+TLSClientCA = ca
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
+ClientCA = krypton-ca
+AllowedClientCertName = a.example.com
+AllowedClientCertName = b.example.com
+AllowedClientCertOU = team-c
+
+[Environment "xenon"]
+SdkKey = xenon-sdk
+`
+	return c
+}
+
+func makeValidConfigJWT() testDataValidConfig {
+	c := testDataValidConfig{name: "JWT authorization"}
+	c.makeConfig = func(c *Config) {
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:          SDKKey("krypton-sdk"),
+				JWTJWKSURL:      newOptURLAbsoluteMustBeValid("https://auth.example.com/.well-known/jwks.json"),
+				JWTIssuer:       "https://auth.example.com",
+				JWTAudience:     "relay",
+				JWTUserKeyClaim: "sub",
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                "krypton-sdk",
+		"LD_JWT_JWKS_URL_krypton":       "https://auth.example.com/.well-known/jwks.json",
+		"LD_JWT_ISSUER_krypton":         "https://auth.example.com",
+		"LD_JWT_AUDIENCE_krypton":       "relay",
+		"LD_JWT_USER_KEY_CLAIM_krypton": "sub",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+JwtJwksUrl = https://auth.example.com/.well-known/jwks.json
+JwtIssuer = https://auth.example.com
+JwtAudience = relay
+JwtUserKeyClaim = sub
+`
+	return c
+}
+
+func makeValidConfigKafka() testDataValidConfig {
+	c := testDataValidConfig{name: "Kafka"}
+	c.makeConfig = func(c *Config) {
+		c.Events.SendEvents = true
+		c.Kafka.Brokers = ct.NewOptStringList([]string{"kafka1:9092", "kafka2:9092"})
+		c.Kafka.Topic = "ld-events"
+		c.Kafka.Mode = EventExportModeRedirect
+		c.Kafka.Format = EventExportFormatEnvelope
+		c.Kafka.TLSEnabled = true
+		c.Kafka.SASLMechanism = KafkaSASLSCRAMSHA512
+		c.Kafka.Username = "relay"
+		c.Kafka.Password = "hunter2"
+	}
+	c.envVars = map[string]string{
+		"USE_EVENTS":           "1",
+		"KAFKA_BROKERS":        "kafka1:9092,kafka2:9092",
+		"KAFKA_TOPIC":          "ld-events",
+		"KAFKA_MODE":           "redirect",
+		"KAFKA_FORMAT":         "envelope",
+		"KAFKA_TLS_ENABLED":    "1",
+		"KAFKA_SASL_MECHANISM": "scram-sha-512",
+		"KAFKA_USERNAME":       "relay",
+		"KAFKA_PASSWORD":       "hunter2",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Kafka]
+Brokers = kafka1:9092
+Brokers = kafka2:9092
+Topic = ld-events
+Mode = redirect
+Format = envelope
+TLSEnabled = 1
+SASLMechanism = scram-sha-512
+Username = relay
+Password = hunter2
+`
+	return c
+}
+
+func makeValidConfigKinesis() testDataValidConfig {
+	c := testDataValidConfig{name: "Kinesis"}
+	c.makeConfig = func(c *Config) {
+		c.Events.SendEvents = true
+		c.Kinesis = KinesisConfig{
+			StreamName: "ld-events",
+			Region:     "us-west-2",
+			URL:        newOptURLAbsoluteMustBeValid("http://localhost:4566"),
+			Mode:       EventExportModeTee,
+			Format:     EventExportFormatEnvelope,
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_EVENTS":          "1",
+		"KINESIS_STREAM_NAME": "ld-events",
+		"KINESIS_REGION":      "us-west-2",
+		"KINESIS_URL":         "http://localhost:4566",
+		"KINESIS_MODE":        "tee",
+		"KINESIS_FORMAT":      "envelope",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Kinesis]
+StreamName = ld-events
+Region = us-west-2
+URL = http://localhost:4566
+Mode = tee
+Format = envelope
+`
+	return c
+}
+
+func makeValidConfigPubSub() testDataValidConfig {
+	c := testDataValidConfig{name: "Pub/Sub"}
+	c.makeConfig = func(c *Config) {
+		c.Events.SendEvents = true
+		c.PubSub = PubSubConfig{
+			ProjectID: "my-project",
+			Topic:     "ld-events",
+			Mode:      EventExportModeRedirect,
+			Format:    EventExportFormatEvent,
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_EVENTS":        "1",
+		"PUBSUB_PROJECT_ID": "my-project",
+		"PUBSUB_TOPIC":      "ld-events",
+		"PUBSUB_MODE":       "redirect",
+		"PUBSUB_FORMAT":     "event",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[PubSub]
+ProjectID = my-project
+Topic = ld-events
+Mode = redirect
+Format = event
+`
+	return c
+}
+
+func makeValidConfigEventExportPerEnvironment() testDataValidConfig {
+	c := testDataValidConfig{name: "event export - per environment"}
+	c.makeConfig = func(c *Config) {
+		c.Events.SendEvents = true
+		c.Kinesis.StreamName = "ld-events"
+		c.PubSub.Topic = "projects/my-project/topics/ld-events"
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:      SDKKey("krypton-sdk"),
+				EventExport: ct.NewOptStringList([]string{"kinesis", "pubsub"}),
+			},
+			"xenon": {
+				SDKKey:      SDKKey("xenon-sdk"),
+				EventExport: ct.NewOptStringList([]string{"none"}),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_EVENTS":              "1",
+		"KINESIS_STREAM_NAME":     "ld-events",
+		"PUBSUB_TOPIC":            "projects/my-project/topics/ld-events",
+		"LD_ENV_krypton":          "krypton-sdk",
+		"LD_EVENT_EXPORT_krypton": "kinesis,pubsub",
+		"LD_ENV_xenon":            "xenon-sdk",
+		"LD_EVENT_EXPORT_xenon":   "none",
+	}
+	c.fileContent = `
+[Events]
+SendEvents = 1
+
+[Kinesis]
+StreamName = ld-events
+
+[PubSub]
+Topic = projects/my-project/topics/ld-events
+
+[Environment "krypton"]
+SdkKey = krypton-sdk
+EventExport = kinesis
+EventExport = pubsub
+
+[Environment "xenon"]
+SdkKey = xenon-sdk
+EventExport = none
+`
+	return c
+}
+
+func makeValidConfigSnapshotsS3() testDataValidConfig {
+	c := testDataValidConfig{name: "snapshots - S3"}
+	c.makeConfig = func(c *Config) {
+		c.Snapshots = SnapshotsConfig{
+			Type:     SnapshotStoreTypeS3,
+			Bucket:   "relay-snapshots",
+			Prefix:   "prod/",
+			Interval: ct.NewOptDuration(30 * time.Minute),
+			MaxAge:   ct.NewOptDuration(30 * 24 * time.Hour),
+			MaxCount: mustOptIntGreaterThanZero(100),
+			S3Region: "us-west-2",
+			S3URL:    newOptURLAbsoluteMustBeValid("http://localhost:4566"),
+		}
+	}
+	c.envVars = map[string]string{
+		"SNAPSHOT_STORE_TYPE": "s3",
+		"SNAPSHOT_BUCKET":     "relay-snapshots",
+		"SNAPSHOT_PREFIX":     "prod/",
+		"SNAPSHOT_INTERVAL":   "30m",
+		"SNAPSHOT_MAX_AGE":    "720h",
+		"SNAPSHOT_MAX_COUNT":  "100",
+		"SNAPSHOT_S3_REGION":  "us-west-2",
+		"SNAPSHOT_S3_URL":     "http://localhost:4566",
+	}
+	c.fileContent = `
+[Snapshots]
+Type = s3
+Bucket = relay-snapshots
+Prefix = prod/
+Interval = 30m
+MaxAge = 720h
+MaxCount = 100
+S3Region = us-west-2
+S3URL = http://localhost:4566
+`
+	return c
+}
+
+func makeValidConfigSnapshotsAzure() testDataValidConfig {
+	c := testDataValidConfig{name: "snapshots - Azure"}
+	c.makeConfig = func(c *Config) {
+		c.Snapshots = SnapshotsConfig{
+			Type:          SnapshotStoreTypeAzure,
+			Bucket:        "relay-snapshots",
+			AzureAccount:  "myaccount",
+			AzureSASToken: "sv=2020-08-04&sig=abc",
+		}
+	}
+	c.envVars = map[string]string{
+		"SNAPSHOT_STORE_TYPE":      "azure",
+		"SNAPSHOT_BUCKET":          "relay-snapshots",
+		"SNAPSHOT_AZURE_ACCOUNT":   "myaccount",
+		"SNAPSHOT_AZURE_SAS_TOKEN": "sv=2020-08-04&sig=abc",
+	}
+	c.fileContent = `
+[Snapshots]
+Type = azure
+Bucket = relay-snapshots
+AzureAccount = myaccount
+AzureSASToken = "sv=2020-08-04&sig=abc"
+`
+	return c
+}
+
+func makeValidConfigFlagChangeWebhook() testDataValidConfig {
+	c := testDataValidConfig{name: "flag change webhook"}
+	c.makeConfig = func(c *Config) {
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey: SDKKey("krypton-sdk"),
+				FlagChangeWebhook: ct.NewOptStringList([]string{
+					"https://ci.example.com/hooks/flags", "http://cache-invalidator:8080/"}),
+				FlagChangeWebhookSecret: "shh",
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                        "krypton-sdk",
+		"LD_FLAG_CHANGE_WEBHOOK_krypton":        "https://ci.example.com/hooks/flags,http://cache-invalidator:8080/",
+		"LD_FLAG_CHANGE_WEBHOOK_SECRET_krypton": "shh",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+FlagChangeWebhook = https://ci.example.com/hooks/flags
+FlagChangeWebhook = http://cache-invalidator:8080/
+FlagChangeWebhookSecret = shh
+`
+	return c
+}
+
+func makeValidConfigChangeFeed() testDataValidConfig {
+	c := testDataValidConfig{name: "change feed"}
+	c.makeConfig = func(c *Config) {
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:          SDKKey("krypton-sdk"),
+				EnvID:           EnvironmentID("krypton-env"),
+				ChangeFeedToken: "feed-token",
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":               "krypton-sdk",
+		"LD_CLIENT_SIDE_ID_krypton":    "krypton-env",
+		"LD_CHANGE_FEED_TOKEN_krypton": "feed-token",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+EnvId = krypton-env
+ChangeFeedToken = feed-token
+`
+	return c
+}
+
+func makeValidConfigFlagOverridesFile() testDataValidConfig {
+	c := testDataValidConfig{name: "flag overrides file"}
+	c.makeConfig = func(c *Config) {
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:            SDKKey("krypton-sdk"),
+				FlagOverridesFile: "overrides.yaml",
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                 "krypton-sdk",
+		"LD_FLAG_OVERRIDES_FILE_krypton": "overrides.yaml",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+FlagOverridesFile = overrides.yaml
+`
+	return c
+}
+
+func makeValidConfigFlagFilter() testDataValidConfig {
+	c := testDataValidConfig{name: "flag filter"}
+	c.makeConfig = func(c *Config) {
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:                SDKKey("krypton-sdk"),
+				FlagFilterInclude:     ct.NewOptStringList([]string{"app-*", "shared-flag"}),
+				FlagFilterExclude:     ct.NewOptStringList([]string{"app-internal-*"}),
+				ClientSideFlagInclude: ct.NewOptStringList([]string{"app-client-*"}),
+				ClientSideFlagExclude: ct.NewOptStringList([]string{"app-client-admin"}),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                      "krypton-sdk",
+		"LD_FLAG_FILTER_INCLUDE_krypton":      "app-*,shared-flag",
+		"LD_FLAG_FILTER_EXCLUDE_krypton":      "app-internal-*",
+		"LD_CLIENT_SIDE_FLAG_INCLUDE_krypton": "app-client-*",
+		"LD_CLIENT_SIDE_FLAG_EXCLUDE_krypton": "app-client-admin",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+FlagFilterInclude = app-*
+FlagFilterInclude = shared-flag
+FlagFilterExclude = app-internal-*
+ClientSideFlagInclude = app-client-*
+ClientSideFlagExclude = app-client-admin
+`
+	return c
+}
+
+func makeValidConfigEventPrivacyAndSampling() testDataValidConfig {
+	c := testDataValidConfig{name: "private attributes and event sampling"}
+	c.makeConfig = func(c *Config) {
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:                 SDKKey("krypton-sdk"),
+				PrivateAttributes:      ct.NewOptStringList([]string{"email", "ssn"}),
+				HashPrivateAttributes:  true,
+				EventSampleRateFeature: ct.NewOptFloat64(0.1),
+				EventSampleRateCustom:  ct.NewOptFloat64(1),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                       "krypton-sdk",
+		"LD_PRIVATE_ATTRIBUTES_krypton":        "email,ssn",
+		"LD_HASH_PRIVATE_ATTRIBUTES_krypton":   "true",
+		"LD_EVENT_SAMPLE_RATE_FEATURE_krypton": "0.1",
+		"LD_EVENT_SAMPLE_RATE_CUSTOM_krypton":  "1",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+PrivateAttributes = email
+PrivateAttributes = ssn
+HashPrivateAttributes = true
+EventSampleRateFeature = 0.1
+EventSampleRateCustom = 1
+`
+	return c
+}
+
+func makeValidConfigCanaryEnvironment() testDataValidConfig {
+	c := testDataValidConfig{name: "canary environment"}
+	c.makeConfig = func(c *Config) {
+		c.Environment = map[string]*EnvConfig{
+			"krypton": {
+				SDKKey:            SDKKey("krypton-sdk"),
+				CanaryEnvironment: "krypton-canary",
+				CanaryHeader:      "X-Canary",
+				CanaryHeaderValue: "yes",
+			},
+			"krypton-canary": {
+				SDKKey: SDKKey("krypton-canary-sdk"),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"LD_ENV_krypton":                 "krypton-sdk",
+		"LD_CANARY_ENV_krypton":          "krypton-canary",
+		"LD_CANARY_HEADER_krypton":       "X-Canary",
+		"LD_CANARY_HEADER_VALUE_krypton": "yes",
+		"LD_ENV_krypton-canary":          "krypton-canary-sdk",
+	}
+	c.fileContent = `
+[Environment "krypton"]
+SdkKey = krypton-sdk
+CanaryEnvironment = krypton-canary
+CanaryHeader = X-Canary
+CanaryHeaderValue = yes
+
+[Environment "krypton-canary"]
+SdkKey = krypton-canary-sdk
 `
 	return c
 }
@@ -7,6 +7,7 @@ require (
 	contrib.go.opencensus.io/exporter/stackdriver v0.13.6
 	github.com/DataDog/datadog-go v3.7.2+incompatible // indirect
 	github.com/DataDog/opencensus-go-exporter-datadog v0.0.0-20210527074920-9baf37265e83
+	github.com/andybalholm/brotli v1.0.5
 	github.com/antihax/optional v1.0.0
 	github.com/armon/go-metrics v0.3.9 // indirect
 	github.com/aws/aws-sdk-go v1.40.45
@@ -16,40 +17,49 @@ require (
 	github.com/go-kit/log v0.2.0 // indirect
 	github.com/go-redis/redis/v8 v8.8.0
 	github.com/gomodule/redigo v1.8.2
-	github.com/google/go-cmp v0.5.6 // indirect
 	github.com/gorilla/mux v1.8.0
-	github.com/gregjones/httpcache v0.0.0-20171119193500-2bcd89a1743f
+	github.com/gregjones/httpcache v0.0.0-20180305231024-9cad4c3443a7
 	github.com/hashicorp/consul/api v1.12.0
 	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
 	github.com/hashicorp/go-hclog v0.16.2 // indirect
 	github.com/hashicorp/go-immutable-radix v1.3.1 // indirect
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
+	github.com/jcmturner/gokrb5/v8 v8.4.2
 	github.com/kardianos/minwinsvc v0.0.0-20151122163309-cad6b2b879b0
 	github.com/kr/text v0.2.0 // indirect
 	github.com/launchdarkly/api-client-go v5.0.3+incompatible
 	github.com/launchdarkly/eventsource v1.7.1
 	github.com/launchdarkly/go-configtypes v1.1.0
+	github.com/launchdarkly/go-ntlm-proxy-auth v1.0.1
 	github.com/launchdarkly/go-server-sdk-consul v1.0.2
 	github.com/launchdarkly/go-server-sdk-dynamodb v1.1.1
 	github.com/launchdarkly/go-server-sdk-redis-redigo v1.2.1
 	github.com/launchdarkly/go-test-helpers/v2 v2.3.1
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/miekg/dns v1.1.43 // indirect
 	github.com/mitchellh/mapstructure v1.4.2 // indirect
-	github.com/newrelic/newrelic-opencensus-exporter-go v0.4.0 // indirect
-	github.com/newrelic/newrelic-telemetry-sdk-go v0.8.1 // indirect
-	github.com/onsi/gomega v1.13.0 // indirect
+	github.com/newrelic/newrelic-opencensus-exporter-go v0.4.0
+	github.com/newrelic/newrelic-telemetry-sdk-go v0.8.1
 	github.com/pborman/uuid v1.2.0
-	github.com/prometheus/client_golang v1.11.1 // indirect
+	github.com/pires/go-proxyproto v0.6.2
+	github.com/prometheus/client_golang v1.11.1
 	github.com/prometheus/common v0.30.0 // indirect
 	github.com/prometheus/procfs v0.7.3 // indirect
-	github.com/stretchr/testify v1.7.0
+	github.com/quic-go/quic-go v0.40.1
+	github.com/segmentio/kafka-go v0.4.38
+	github.com/stretchr/testify v1.8.1
+	go.etcd.io/etcd/client/v3 v3.5.9
+	go.mongodb.org/mongo-driver v1.11.9
 	go.opencensus.io v0.23.0
-	golang.org/x/crypto v0.0.0-20220411220226-7b82a4e95df4 // indirect
-	golang.org/x/net v0.0.0-20211209124913-491a49abca63 // indirect; fixes CVE-2021-44716
-	golang.org/x/sync v0.0.0-20220513210516-0976fa681c29
-	golang.org/x/sys v0.0.0-20220412211240-33da011f77ad // indirect; fixes CVE-2022-29526
-	golang.org/x/text v0.3.7 // indirect
+	go.uber.org/zap v1.17.0
+	golang.org/x/crypto v0.4.0
+	golang.org/x/net v0.10.0 // fixes CVE-2021-44716
+	golang.org/x/sync v0.2.0
+	golang.org/x/sys v0.8.0 // fixes CVE-2022-29526
+	google.golang.org/api v0.37.0
+	google.golang.org/grpc v1.41.0
+	google.golang.org/protobuf v1.28.0
 	gopkg.in/gcfg.v1 v1.2.3
 	gopkg.in/launchdarkly/go-jsonstream.v1 v1.0.1
 	gopkg.in/launchdarkly/go-sdk-common.v2 v2.4.0
@@ -57,5 +67,5 @@ require (
 	gopkg.in/launchdarkly/go-server-sdk-evaluation.v1 v1.5.0
 	gopkg.in/launchdarkly/go-server-sdk.v5 v5.9.0
 	gopkg.in/warnings.v0 v0.1.2 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/webhook"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+// Action is the kind of change that an audit Event describes.
+type Action string
+
+// Source is the part of Relay that made the change that an audit Event describes.
+type Source string
+
+const (
+	// ActionEnvironmentAdded means that an environment was added.
+	ActionEnvironmentAdded Action = "environmentAdded"
+
+	// ActionEnvironmentUpdated means that an environment's properties were changed.
+	ActionEnvironmentUpdated Action = "environmentUpdated"
+
+	// ActionEnvironmentRemoved means that an environment was removed.
+	ActionEnvironmentRemoved Action = "environmentRemoved"
+
+	// ActionCredentialRotated means that an environment's SDK key or mobile key was replaced.
+	ActionCredentialRotated Action = "credentialRotated"
+
+	// ActionCredentialExpired means that an old SDK key, which was still accepted after being replaced,
+	// is no longer accepted.
+	ActionCredentialExpired Action = "credentialExpired"
+
+	// ActionConfigReloaded means that the configuration was reloaded. The event's Before and After
+	// contain only the sections, other than environments, that changed.
+	ActionConfigReloaded Action = "configReloaded"
+
+	// ActionConfigReloadFailed means that the configuration could not be reloaded, and Relay is still
+	// using its previous configuration.
+	ActionConfigReloadFailed Action = "configReloadFailed"
+
+	// ActionKillSwitchOn means that an environment's kill switch was turned on, or turned on again to
+	// read its fallback flags again.
+	ActionKillSwitchOn Action = "killSwitchOn"
+
+	// ActionKillSwitchOff means that an environment's kill switch was turned off.
+	ActionKillSwitchOff Action = "killSwitchOff"
+
+	// ActionMaintenanceOn means that maintenance mode was turned on.
+	ActionMaintenanceOn Action = "maintenanceOn"
+
+	// ActionMaintenanceOff means that maintenance mode was turned off.
+	ActionMaintenanceOff Action = "maintenanceOff"
+
+	// SourceAutoConfig means that the change came from the auto-configuration stream.
+	SourceAutoConfig Source = "autoConfig"
+
+	// SourceConfigReload means that the change came from reloading the configuration.
+	SourceConfigReload Source = "configReload"
+
+	// SourceAdminAPI means that the change came from the admin API.
+	SourceAdminAPI Source = "adminAPI"
+
+	// SourceSignal means that the change came from a signal sent to the Relay process.
+	SourceSignal Source = "signal"
+)
+
+// Event is one record in the audit log. Before and After are the values that changed, which should
+// have been passed through Redact.
+type Event struct {
+	Action Action      `json:"action"`
+	Source Source      `json:"source"`
+	Env    string      `json:"env,omitempty"`
+	EnvID  string      `json:"envId,omitempty"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type record struct {
+	Time string `json:"time"`
+	Event
+}
+
+// Logger writes audit events to a file, a webhook, or both.
+//
+// All of its methods can be called on a nil *Logger, which does nothing, so callers do not need to
+// check whether the audit log is enabled.
+type Logger struct {
+	file        io.WriteCloser
+	webhook     *webhook.Sender
+	fileFailing bool
+	lock        sync.Mutex
+	now         func() time.Time
+	loggers     ldlog.Loggers
+}
+
+// NewLogger creates a Logger from the configuration. It returns nil, and no error, if neither a file
+// nor a webhook URL is configured.
+func NewLogger(c config.AuditLogConfig, userAgent string, loggers ldlog.Loggers) (*Logger, error) {
+	if c.File == "" && !c.WebhookURL.IsDefined() {
+		return nil, nil
+	}
+	var file io.WriteCloser
+	if c.File != "" {
+		f, err := os.OpenFile(c.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		file = f
+	}
+	var sender *webhook.Sender
+	if c.WebhookURL.IsDefined() {
+		headers := make(http.Header)
+		for _, h := range c.WebhookHeader.Values() {
+			parts := strings.SplitN(h, "=", 2)
+			headers.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+		headers.Set("User-Agent", userAgent)
+		sender = webhook.NewSender(c.WebhookURL.String(), webhook.Options{Description: "audit events", Headers: headers}, loggers)
+	}
+	return newLoggerInternal(file, sender, loggers), nil
+}
+
+func newLoggerInternal(file io.WriteCloser, sender *webhook.Sender, loggers ldlog.Loggers) *Logger {
+	return &Logger{file: file, webhook: sender, now: time.Now, loggers: loggers}
+}
+
+// Log records an event. Writing to the file happens before Log returns; the webhook is called on
+// another goroutine, so a slow webhook does not delay the change that is being recorded.
+func (l *Logger) Log(e Event) {
+	if l == nil {
+		return
+	}
+	data, err := json.Marshal(record{Time: l.now().UTC().Format(time.RFC3339Nano), Event: e})
+	if err != nil {
+		l.loggers.Errorf("Unable to serialize audit event: %s", err)
+		return
+	}
+	if l.file != nil {
+		l.lock.Lock()
+		_, err := l.file.Write(append(data, '\n'))
+		// Only the first of a series of failures is logged, as for the webhook
+		if err != nil && !l.fileFailing {
+			l.loggers.Errorf("Failed to write to audit log file: %s", err)
+		}
+		l.fileFailing = err != nil
+		l.lock.Unlock()
+	}
+	if l.webhook != nil {
+		l.webhook.Enqueue(data)
+	}
+}
+
+// Close sends any events that are waiting for the webhook, and closes the file.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	if l.webhook != nil {
+		l.webhook.Close()
+	}
+	if l.file != nil {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		return l.file.Close()
+	}
+	return nil
+}
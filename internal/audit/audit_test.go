@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readLines(t *testing.T, path string) []string {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func mustOptURL(t *testing.T, s string) configtypes.OptURLAbsolute {
+	u, err := configtypes.NewOptURLAbsoluteFromString(s)
+	require.NoError(t, err)
+	return u
+}
+
+func TestNewLoggerReturnsNilIfNotConfigured(t *testing.T) {
+	logger, err := NewLogger(config.AuditLogConfig{}, "", ldlog.NewDisabledLoggers())
+	assert.NoError(t, err)
+	assert.Nil(t, logger)
+}
+
+func TestNilLoggerDoesNothing(t *testing.T) {
+	var logger *Logger
+	logger.Log(Event{Action: ActionConfigReloaded})
+	assert.NoError(t, logger.Close())
+}
+
+func TestLoggerWritesEventsToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := NewLogger(config.AuditLogConfig{File: path}, "", ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	logger.now = func() time.Time { return time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC) }
+
+	logger.Log(Event{Action: ActionEnvironmentAdded, Source: SourceAdminAPI, Env: "a", EnvID: "env-a",
+		After: map[string]interface{}{"Prefix": "p"}})
+	logger.Log(Event{Action: ActionConfigReloadFailed, Source: SourceConfigReload, Error: "bad config"})
+	require.NoError(t, logger.Close())
+
+	lines := readLines(t, path)
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"time": "2021-06-07T08:09:10Z", "action": "environmentAdded", "source": "adminAPI",
+		"env": "a", "envId": "env-a", "after": {"Prefix": "p"}}`, lines[0])
+	assert.JSONEq(t, `{"time": "2021-06-07T08:09:10Z", "action": "configReloadFailed", "source": "configReload",
+		"error": "bad config"}`, lines[1])
+}
+
+func TestLoggerAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("{}\n"), 0600))
+	logger, err := NewLogger(config.AuditLogConfig{File: path}, "", ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	logger.Log(Event{Action: ActionConfigReloaded})
+	require.NoError(t, logger.Close())
+
+	assert.Len(t, readLines(t, path), 2)
+}
+
+func TestNewLoggerReturnsErrorIfFileCannotBeOpened(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "no-such-dir", "audit.log")
+	_, err := NewLogger(config.AuditLogConfig{File: path}, "", ldlog.NewDisabledLoggers())
+	assert.Error(t, err)
+}
+
+func TestLoggerSendsEventsToWebhook(t *testing.T) {
+	requestsCh := make(chan *http.Request, 10)
+	bodiesCh := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		requestsCh <- req
+		bodiesCh <- body
+	}))
+	defer server.Close()
+
+	logger, err := NewLogger(config.AuditLogConfig{
+		WebhookURL:    mustOptURL(t, server.URL+"/audit"),
+		WebhookHeader: configtypes.NewOptStringList([]string{"Authorization=Bearer xyz"}),
+	}, "LDRelay/1.0", ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+
+	logger.Log(Event{Action: ActionEnvironmentRemoved, Source: SourceAutoConfig, EnvID: "env-a"})
+	logger.Log(Event{Action: ActionCredentialExpired, Source: SourceAutoConfig, EnvID: "env-a"})
+	require.NoError(t, logger.Close())
+
+	for _, action := range []Action{ActionEnvironmentRemoved, ActionCredentialExpired} {
+		req := <-requestsCh
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "/audit", req.URL.Path)
+		assert.Equal(t, "Bearer xyz", req.Header.Get("Authorization"))
+		assert.Equal(t, "LDRelay/1.0", req.Header.Get("User-Agent"))
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		var e map[string]interface{}
+		require.NoError(t, json.Unmarshal(<-bodiesCh, &e))
+		assert.Equal(t, string(action), e["action"])
+	}
+}
+
+func TestWebhookFailuresAreLoggedOnce(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	statusCh := make(chan int, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(<-statusCh)
+	}))
+	defer server.Close()
+
+	mockLog := ldlogtest.NewMockLog()
+	logger, err := NewLogger(config.AuditLogConfig{
+		WebhookURL: mustOptURL(t, server.URL),
+	}, "", mockLog.Loggers)
+	require.NoError(t, err)
+
+	statusCh <- status
+	statusCh <- status
+	statusCh <- http.StatusOK
+	for i := 0; i < 3; i++ {
+		logger.Log(Event{Action: ActionConfigReloaded})
+	}
+	require.NoError(t, logger.Close())
+
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 1)
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "HTTP status 503")
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "Resumed sending audit events")
+}
@@ -0,0 +1,2 @@
+// Package audit contains the audit log, which records changes to Relay's environments and configuration.
+package audit
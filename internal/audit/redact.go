@@ -0,0 +1,203 @@
+package audit
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core"
+)
+
+const (
+	redactedValue = "[redacted]"
+
+	// secretTagName is the struct tag that marks a field, such as a password, whose value is replaced
+	// entirely in audit events: `audit:"secret"`.
+	secretTagName  = "audit"
+	secretTagValue = "secret"
+)
+
+var (
+	sdkKeyType     = reflect.TypeOf(config.SDKKey(""))    //nolint:gochecknoglobals
+	mobileKeyType  = reflect.TypeOf(config.MobileKey("")) //nolint:gochecknoglobals
+	marshalerTypes = []reflect.Type{                      //nolint:gochecknoglobals
+		reflect.TypeOf((*json.Marshaler)(nil)).Elem(),
+		reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem(),
+	}
+)
+
+// Redact returns the JSON representation of a value, such as a config.EnvConfig or config.Config, as
+// maps and slices, with the values of credentials and other secrets obscured or removed.
+//
+// Values of type config.SDKKey and config.MobileKey are obscured in the same way as in the status
+// resource, so that it is still possible to tell which key was rotated. Struct fields that have the tag
+// `audit:"secret"` are replaced entirely if they are not empty. Passwords in URLs are also removed.
+func Redact(v interface{}) interface{} {
+	return redactValue(reflect.ValueOf(v))
+}
+
+func redactValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	switch v.Type() {
+	case sdkKeyType, mobileKeyType:
+		return core.ObscureKey(v.String())
+	}
+	for _, m := range marshalerTypes {
+		if v.Type().Implements(m) || reflect.PtrTo(v.Type()).Implements(m) {
+			return redactLeaf(v) // it has its own JSON representation
+		}
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem())
+	case reflect.Struct:
+		rep := make(map[string]interface{})
+		addStructFields(rep, v)
+		return rep
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		rep := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			rep[fmt.Sprint(iter.Key().Interface())] = redactValue(iter.Value())
+		}
+		return rep
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		rep := make([]interface{}, v.Len())
+		for i := range rep {
+			rep[i] = redactValue(v.Index(i))
+		}
+		return rep
+	default:
+		return redactLeaf(v)
+	}
+}
+
+// addStructFields adds the exported fields of a struct to rep, with the same names as encoding/json would
+// use, including the fields of embedded structs.
+func addStructFields(rep map[string]interface{}, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options := field.Name, ""
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.SplitN(tag, ",", 2)
+			if parts[0] != "" {
+				name = parts[0]
+			} else if field.Anonymous {
+				name = ""
+			}
+			if len(parts) > 1 {
+				options = parts[1]
+			}
+		} else if field.Anonymous {
+			name = ""
+		}
+		fieldValue := v.Field(i)
+		if name == "" && field.Type.Kind() == reflect.Struct {
+			addStructFields(rep, fieldValue) // embedded struct without a JSON name is flattened
+			continue
+		}
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		if strings.Contains(options, "omitempty") && fieldValue.IsZero() {
+			continue
+		}
+		value := redactValue(fieldValue)
+		if field.Tag.Get(secretTagName) == secretTagValue && !isEmptyRep(value) {
+			value = redactedValue
+		}
+		rep[name] = value
+	}
+}
+
+// redactLeaf converts a value that has no properties of its own to its JSON representation, and removes
+// any passwords from URLs in it.
+func redactLeaf(v reflect.Value) interface{} {
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil
+	}
+	var rep interface{}
+	if err := json.Unmarshal(data, &rep); err != nil {
+		return nil
+	}
+	return redactURLs(rep)
+}
+
+func redactURLs(rep interface{}) interface{} {
+	switch rep := rep.(type) {
+	case string:
+		if strings.Contains(rep, "://") && strings.Contains(rep, "@") {
+			if u, err := url.Parse(rep); err == nil {
+				return u.Redacted()
+			}
+		}
+		return rep
+	case []interface{}:
+		for i, value := range rep {
+			rep[i] = redactURLs(value)
+		}
+		return rep
+	default:
+		return rep
+	}
+}
+
+func isEmptyRep(rep interface{}) bool {
+	switch rep := rep.(type) {
+	case nil:
+		return true
+	case string:
+		return rep == ""
+	case []interface{}:
+		return len(rep) == 0
+	default:
+		return false
+	}
+}
+
+// ChangedProperties compares two values that were returned by Redact for JSON objects, and returns
+// only the top-level properties that differ, from each of them. Properties in ignore are skipped.
+func ChangedProperties(before, after interface{}, ignore ...string) (map[string]interface{}, map[string]interface{}) {
+	beforeMap, _ := before.(map[string]interface{})
+	afterMap, _ := after.(map[string]interface{})
+	changedBefore, changedAfter := make(map[string]interface{}), make(map[string]interface{})
+	isIgnored := func(name string) bool {
+		for _, n := range ignore {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+	for name, value := range beforeMap {
+		if !isIgnored(name) && !reflect.DeepEqual(value, afterMap[name]) {
+			changedBefore[name] = value
+		}
+	}
+	for name, value := range afterMap {
+		if !isIgnored(name) && !reflect.DeepEqual(value, beforeMap[name]) {
+			changedAfter[name] = value
+		}
+	}
+	return changedBefore, changedAfter
+}
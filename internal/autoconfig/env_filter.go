@@ -0,0 +1,62 @@
+package autoconfig
+
+import (
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/envfactory"
+)
+
+// EnvironmentFilter determines which of the environments in the auto-configuration stream Relay should
+// use, based on the ProjectKeys, EnvironmentKeys, and EnvironmentTags options in AutoConfigConfig.
+//
+// An environment is used only if it matches every one of those options that is set: its project key
+// must be one of ProjectKeys, its environment key must be one of EnvironmentKeys, and it must have at
+// least one of EnvironmentTags. The zero value matches every environment.
+type EnvironmentFilter struct {
+	projectKeys map[string]bool
+	envKeys     map[string]bool
+	tags        map[string]bool
+}
+
+// NewEnvironmentFilter creates an EnvironmentFilter from the auto-configuration options.
+func NewEnvironmentFilter(c config.AutoConfigConfig) EnvironmentFilter {
+	return EnvironmentFilter{
+		projectKeys: makeStringSet(c.ProjectKeys.Values()),
+		envKeys:     makeStringSet(c.EnvironmentKeys.Values()),
+		tags:        makeStringSet(c.EnvironmentTags.Values()),
+	}
+}
+
+// IsEmpty returns true if the filter matches every environment.
+func (f EnvironmentFilter) IsEmpty() bool {
+	return f.projectKeys == nil && f.envKeys == nil && f.tags == nil
+}
+
+// Matches returns true if Relay should use the environment.
+func (f EnvironmentFilter) Matches(rep envfactory.EnvironmentRep) bool {
+	if f.projectKeys != nil && !f.projectKeys[rep.ProjKey] {
+		return false
+	}
+	if f.envKeys != nil && !f.envKeys[rep.EnvKey] {
+		return false
+	}
+	if f.tags != nil {
+		for _, tag := range rep.Tags {
+			if f.tags[tag] {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func makeStringSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	ret := make(map[string]bool, len(values))
+	for _, v := range values {
+		ret[v] = true
+	}
+	return ret
+}
@@ -12,6 +12,8 @@ const (
 	logMsgUpdateBadVersion    = "Ignoring out-of-order update for environment %s (%s)"
 	logMsgDeleteEnv           = "Removed environment %s (%s)"
 	logMsgDeleteBadVersion    = "Ignoring out-of-order delete for environment %s (%s)"
+	logMsgFilteredOutEnv      = "Removed environment %s (%s) because it no longer matches the environment filter"
+	logMsgEnvsFilteredOut     = "Ignoring %d environment(s) that do not match the environment filter"
 	logMsgKeyWillExpire       = "Old SDK key ending in %s for environment %s (%s) will expire at %s"
 	logMsgKeyExpired          = "Old SDK key ending in %s for environment %s (%s) has expired"
 	logMsgEnvHasWrongID       = "Ignoring environment data whose envId %q did not match key %q"
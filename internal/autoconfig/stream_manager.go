@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
 	"regexp"
 	"strings"
 	"sync"
@@ -47,6 +48,7 @@ type StreamManager struct {
 	key               config.AutoConfigKey
 	uri               string
 	handler           MessageHandler
+	filter            EnvironmentFilter
 	lastKnownEnvs     map[config.EnvironmentID]envfactory.EnvironmentRep
 	expiredKeys       chan expiredKey
 	expiryTimers      map[config.SDKKey]*time.Timer
@@ -63,10 +65,13 @@ type expiredKey struct {
 }
 
 // NewStreamManager creates a StreamManager, but does not start the connection.
+//
+// Environments that do not match the filter are treated as if they were not in the stream.
 func NewStreamManager(
 	key config.AutoConfigKey,
 	streamURI string,
 	handler MessageHandler,
+	filter EnvironmentFilter,
 	httpConfig httpconfig.HTTPConfig,
 	initialRetryDelay time.Duration,
 	loggers ldlog.Loggers,
@@ -76,6 +81,7 @@ func NewStreamManager(
 		key:               key,
 		uri:               strings.TrimSuffix(streamURI, "/") + autoConfigStreamPath,
 		handler:           handler,
+		filter:            filter,
 		lastKnownEnvs:     make(map[config.EnvironmentID]envfactory.EnvironmentRep),
 		expiredKeys:       make(chan expiredKey),
 		expiryTimers:      make(map[config.SDKKey]*time.Timer),
@@ -274,12 +280,24 @@ func (s *StreamManager) handlePut(allEnvReps map[config.EnvironmentID]envfactory
 	// UpdateEnvironment for any that have changed, and DeleteEnvironment for any that are no longer
 	// in the set.
 	s.loggers.Infof(logMsgPutEvent, len(allEnvReps))
+	if !s.filter.IsEmpty() {
+		matchingEnvReps := make(map[config.EnvironmentID]envfactory.EnvironmentRep, len(allEnvReps))
+		for id, rep := range allEnvReps {
+			if s.filter.Matches(rep) {
+				matchingEnvReps[id] = rep
+			}
+		}
+		if len(matchingEnvReps) < len(allEnvReps) {
+			s.loggers.Infof(logMsgEnvsFilteredOut, len(allEnvReps)-len(matchingEnvReps))
+		}
+		allEnvReps = matchingEnvReps
+	}
 	for id, rep := range allEnvReps {
 		if id != rep.EnvID {
 			s.loggers.Warnf(logMsgEnvHasWrongID, rep.EnvID, id)
 			continue
 		}
-		if s.lastKnownEnvs[id] == rep {
+		if reflect.DeepEqual(s.lastKnownEnvs[id], rep) {
 			// Unchanged - don't try to update because we would get a warning for the version not being higher
 			continue
 		}
@@ -310,6 +328,17 @@ func (s *StreamManager) addOrUpdate(rep envfactory.EnvironmentRep) {
 		}
 	}
 
+	if !s.filter.Matches(rep) {
+		// If the environment was changed so that it no longer matches the filter, this is equivalent to
+		// deleting it. Either way, we keep a tombstone so that the version check above still works.
+		if exists {
+			s.loggers.Infof(logMsgFilteredOutEnv, rep.EnvID, params.Identifiers.GetDisplayName())
+			s.handler.DeleteEnvironment(rep.EnvID)
+		}
+		s.lastKnownEnvs[rep.EnvID] = makeTombstone(rep.Version)
+		return
+	}
+
 	expiringKey := rep.SDKKey.Expiring.Value
 	expiryTime := rep.SDKKey.Expiring.Timestamp
 	if expiringKey != "" && expiryTime != 0 {
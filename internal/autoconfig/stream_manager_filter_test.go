@@ -0,0 +1,121 @@
+package autoconfig
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/envfactory"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironmentFilter(t *testing.T) {
+	env := envfactory.EnvironmentRep{ProjKey: "proj", EnvKey: "prod", Tags: []string{"mobile", "eu"}}
+
+	for _, tc := range []struct {
+		name    string
+		config  config.AutoConfigConfig
+		matches bool
+	}{
+		{"empty filter", config.AutoConfigConfig{}, true},
+		{"matching project", config.AutoConfigConfig{ProjectKeys: ct.NewOptStringList([]string{"other", "proj"})}, true},
+		{"non-matching project", config.AutoConfigConfig{ProjectKeys: ct.NewOptStringList([]string{"other"})}, false},
+		{"matching environment", config.AutoConfigConfig{EnvironmentKeys: ct.NewOptStringList([]string{"prod"})}, true},
+		{"non-matching environment", config.AutoConfigConfig{EnvironmentKeys: ct.NewOptStringList([]string{"test"})}, false},
+		{"matching tag", config.AutoConfigConfig{EnvironmentTags: ct.NewOptStringList([]string{"eu", "us"})}, true},
+		{"non-matching tag", config.AutoConfigConfig{EnvironmentTags: ct.NewOptStringList([]string{"server"})}, false},
+		{"all options must match", config.AutoConfigConfig{
+			ProjectKeys:     ct.NewOptStringList([]string{"proj"}),
+			EnvironmentTags: ct.NewOptStringList([]string{"server"}),
+		}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filter := NewEnvironmentFilter(tc.config)
+			assert.Equal(t, tc.matches, filter.Matches(env))
+		})
+	}
+
+	assert.True(t, NewEnvironmentFilter(config.AutoConfigConfig{}).IsEmpty())
+	assert.False(t, NewEnvironmentFilter(config.AutoConfigConfig{
+		EnvironmentTags: ct.NewOptStringList([]string{"mobile"})}).IsEmpty())
+}
+
+func TestStreamManagerIgnoresEnvironmentsThatDoNotMatchFilter(t *testing.T) {
+	filter := NewEnvironmentFilter(config.AutoConfigConfig{ProjectKeys: ct.NewOptStringList([]string{testEnv1.ProjKey})})
+
+	t.Run("put", func(t *testing.T) {
+		event := makePutEvent(testEnv1, testEnv2)
+		streamManagerTest(t, &event, func(p streamManagerTestParams) {
+			p.streamManager.filter = filter
+			p.startStream()
+
+			msg := p.requireMessage()
+			require.NotNil(t, msg.add)
+			assert.Equal(t, testEnv1.ToParams(), *msg.add)
+			p.requireReceivedAllMessage()
+			p.requireNoMoreMessages()
+
+			p.mockLog.AssertMessageMatch(t, true, ldlog.Info, "Ignoring 1 environment")
+		})
+	})
+
+	t.Run("patch", func(t *testing.T) {
+		streamManagerTest(t, nil, func(p streamManagerTestParams) {
+			p.streamManager.filter = filter
+			p.startStream()
+
+			p.stream.Enqueue(makePatchEvent(testEnv2))
+			p.requireNoMoreMessages()
+		})
+	})
+
+	t.Run("environment that is changed so it no longer matches is removed", func(t *testing.T) {
+		streamManagerTest(t, nil, func(p streamManagerTestParams) {
+			p.streamManager.filter = filter
+			p.startStream()
+			p.stream.Enqueue(makePatchEvent(testEnv1))
+			_ = p.requireMessage()
+
+			testEnv1Mod := testEnv1
+			testEnv1Mod.ProjKey = "otherproj"
+			testEnv1Mod.Version++
+			p.stream.Enqueue(makePatchEvent(testEnv1Mod))
+
+			msg := p.requireMessage()
+			require.NotNil(t, msg.delete)
+			assert.Equal(t, testEnv1.EnvID, *msg.delete)
+			p.mockLog.AssertMessageMatch(t, true, ldlog.Info, "no longer matches the environment filter")
+
+			// it can be added again if it's changed back
+			testEnv1Mod = testEnv1
+			testEnv1Mod.Version += 2
+			p.stream.Enqueue(makePatchEvent(testEnv1Mod))
+			msg = p.requireMessage()
+			require.NotNil(t, msg.add)
+		})
+	})
+
+	t.Run("put with environment that no longer matches", func(t *testing.T) {
+		event := makePutEvent(testEnv1)
+		streamManagerTest(t, &event, func(p streamManagerTestParams) {
+			p.streamManager.filter = filter
+			p.startStream()
+			_ = p.requireMessage()
+			p.requireReceivedAllMessage()
+
+			testEnv1Mod := testEnv1
+			testEnv1Mod.ProjKey = "otherproj"
+			testEnv1Mod.Version++
+			p.stream.Enqueue(makePutEvent(testEnv1Mod))
+
+			msg := p.requireMessage()
+			require.NotNil(t, msg.delete)
+			assert.Equal(t, testEnv1.EnvID, *msg.delete)
+			p.requireReceivedAllMessage()
+		})
+	})
+}
@@ -164,6 +164,7 @@ func streamManagerTestWithStreamHandler(
 			testConfigKey,
 			server.URL,
 			testMessageHandler,
+			EnvironmentFilter{},
 			httpConfig,
 			time.Millisecond,
 			mockLog.Loggers,
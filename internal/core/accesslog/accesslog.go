@@ -0,0 +1,220 @@
+package accesslog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+)
+
+const (
+	credentialTypeSDKKey       = "sdkKey"
+	credentialTypeMobileKey    = "mobileKey"
+	credentialTypeClientSideID = "clientSideId"
+)
+
+// Entry is the information that is recorded for each request.
+type Entry struct {
+	Time           time.Time
+	RemoteHost     string
+	ForwardedFor   string
+	Method         string
+	URI            string
+	Proto          string
+	Status         int
+	Bytes          uint64
+	Latency        time.Duration
+	Referer        string
+	UserAgent      string
+	CredentialType string
+	Env            string
+}
+
+// Logger writes an Entry for each request that passes through its middleware.
+type Logger struct {
+	format formatter
+	output io.WriteCloser
+	lock   sync.Mutex
+	now    func() time.Time
+}
+
+// requestInfo is attached to the request context by the middleware, so that the handlers further down
+// the chain can record which credential and environment the request was for. They run on the same
+// goroutine as the middleware, so it does not need a lock.
+type requestInfo struct {
+	credentialType string
+	env            string
+}
+
+type requestInfoKey struct{}
+
+// NewLogger creates a Logger from the configuration, opening the file or syslog connection if
+// necessary. It returns nil, with no error, if the access log is not enabled. ValidateConfig has
+// already checked the format and output.
+func NewLogger(c config.AccessLogConfig) (*Logger, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	var output io.WriteCloser
+	switch c.Output {
+	case config.AccessLogOutputFile:
+		f, err := openRotatingFile(c.File,
+			int64(c.MaxFileSize.GetOrElse(config.DefaultAccessLogMaxFileSize))*1024*1024,
+			c.MaxFiles.GetOrElse(config.DefaultAccessLogMaxFiles))
+		if err != nil {
+			return nil, err
+		}
+		output = f
+	case config.AccessLogOutputSyslog:
+		w, err := openSyslog(c)
+		if err != nil {
+			return nil, err
+		}
+		output = w
+	default:
+		output = nopCloser{os.Stdout}
+	}
+	return newLoggerInternal(c.Format, output), nil
+}
+
+func newLoggerInternal(format string, output io.WriteCloser) *Logger {
+	return &Logger{format: getFormatter(format), output: output, now: time.Now}
+}
+
+// Close closes the file or syslog connection, if any.
+func (l *Logger) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.output.Close()
+}
+
+// Middleware returns a middleware function that writes an Entry for each request when its handler
+// returns, so for a stream the Entry is written when the stream is closed and the latency is the
+// duration of the stream.
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := l.now()
+		info := &requestInfo{}
+		wrappedWriter := &responseWriter{writer: w}
+		next.ServeHTTP(wrappedWriter, req.WithContext(context.WithValue(req.Context(), requestInfoKey{}, info)))
+		end := l.now()
+
+		entry := Entry{
+			Time:           start,
+			RemoteHost:     remoteHost(req),
+			ForwardedFor:   req.Header.Get("X-Forwarded-For"),
+			Method:         req.Method,
+			URI:            req.URL.RequestURI(),
+			Proto:          req.Proto,
+			Status:         wrappedWriter.statusCode,
+			Bytes:          wrappedWriter.bytesWritten,
+			Latency:        end.Sub(start),
+			Referer:        req.Referer(),
+			UserAgent:      req.UserAgent(),
+			CredentialType: info.credentialType,
+			Env:            info.env,
+		}
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK // the handler didn't write anything, so net/http sends a 200
+		}
+		l.write(entry)
+	})
+}
+
+func (l *Logger) write(entry Entry) {
+	line := l.format(entry)
+	l.lock.Lock()
+	_, _ = l.output.Write(line)
+	l.lock.Unlock()
+}
+
+// SetCredential records the kind of credential that was used in the request. It does nothing if the
+// request did not go through a Logger's middleware.
+func SetCredential(ctx context.Context, credential config.SDKCredential) {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		switch credential.(type) {
+		case config.SDKKey:
+			info.credentialType = credentialTypeSDKKey
+		case config.MobileKey:
+			info.credentialType = credentialTypeMobileKey
+		case config.EnvironmentID:
+			info.credentialType = credentialTypeClientSideID
+		}
+	}
+}
+
+// SetEnvironment records the name of the environment that the request was for. It does nothing if
+// the request did not go through a Logger's middleware.
+func SetEnvironment(ctx context.Context, envName string) {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.env = envName
+	}
+}
+
+func remoteHost(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+type responseWriter struct {
+	writer       http.ResponseWriter
+	statusCode   int
+	bytesWritten uint64
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.writer.Header()
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	n, err := w.writer.Write(data)
+	w.bytesWritten += uint64(n)
+	return n, err
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	w.writer.WriteHeader(statusCode)
+}
+
+// As with the request logger in the logging package, the wrapped writer also has to implement
+// http.Flusher for streams, and http.Hijacker for WebSocket connections.
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.writer.(http.Hijacker); ok {
+		if w.statusCode == 0 {
+			w.statusCode = http.StatusSwitchingProtocols
+		}
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,147 @@
+package accesslog
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bufferOutput struct {
+	buf    bytes.Buffer
+	closed bool
+	lock   sync.Mutex
+}
+
+func (b *bufferOutput) Write(data []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(data)
+}
+
+func (b *bufferOutput) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (b *bufferOutput) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func makeTestLogger(format string) (*Logger, *bufferOutput) {
+	output := &bufferOutput{}
+	logger := newLoggerInternal(format, output)
+	startTime := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	calls := 0
+	logger.now = func() time.Time {
+		calls++
+		if calls%2 == 0 {
+			return startTime.Add(1500 * time.Microsecond)
+		}
+		return startTime
+	}
+	return logger, output
+}
+
+func TestNewLoggerReturnsNilIfNotEnabled(t *testing.T) {
+	logger, err := NewLogger(config.AccessLogConfig{Output: config.AccessLogOutputFile})
+	assert.NoError(t, err)
+	assert.Nil(t, logger)
+}
+
+func TestNewLoggerWithFileOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	logger, err := NewLogger(config.AccessLogConfig{Enabled: true, Output: config.AccessLogOutputFile, File: path})
+	require.NoError(t, err)
+	require.NotNil(t, logger)
+	assert.NoError(t, logger.Close())
+	assert.FileExists(t, path)
+}
+
+func TestNewLoggerReturnsErrorIfFileCannotBeOpened(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "no-such-dir", "access.log")
+	_, err := NewLogger(config.AccessLogConfig{Enabled: true, Output: config.AccessLogOutputFile, File: path})
+	assert.Error(t, err)
+}
+
+func TestMiddlewareRecordsRequest(t *testing.T) {
+	logger, output := makeTestLogger(config.AccessLogFormatCommon)
+	handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		SetCredential(req.Context(), config.MobileKey("mob-key"))
+		SetEnvironment(req.Context(), "my env")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest("REPORT", "/msdk/evalx/user?withReasons=true", nil)
+	req.RemoteAddr = "10.1.2.3:4567"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, `10.1.2.3 - - [07/Jun/2021:08:09:10 +0000] "REPORT /msdk/evalx/user?withReasons=true HTTP/1.1" `+
+		`202 5 1.500 mobileKey "my env"`+"\n", output.String())
+}
+
+func TestMiddlewareRecordsRequestWithoutEnvironment(t *testing.T) {
+	logger, output := makeTestLogger(config.AccessLogFormatJSON)
+	handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/status", nil)
+	req.RemoteAddr = "10.1.2.3:4567"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.JSONEq(t, `{"time": "2021-06-07T08:09:10Z", "remoteHost": "10.1.2.3", "method": "GET", "uri": "/status",
+		"proto": "HTTP/1.1", "status": 200, "bytes": 0, "latencyMs": 1.5}`, output.String())
+}
+
+func TestCredentialTypes(t *testing.T) {
+	for _, p := range []struct {
+		credential config.SDKCredential
+		expected   string
+	}{
+		{config.SDKKey("x"), credentialTypeSDKKey},
+		{config.MobileKey("x"), credentialTypeMobileKey},
+		{config.EnvironmentID("x"), credentialTypeClientSideID},
+	} {
+		t.Run(p.expected, func(t *testing.T) {
+			logger, output := makeTestLogger(config.AccessLogFormatJSON)
+			handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				SetCredential(req.Context(), p.credential)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			assert.Contains(t, output.String(), `"credentialType":"`+p.expected+`"`)
+		})
+	}
+}
+
+func TestSetCredentialAndEnvironmentWithoutMiddleware(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	SetCredential(req.Context(), config.SDKKey("x")) // does not panic
+	SetEnvironment(req.Context(), "x")
+}
+
+func TestMiddlewarePassesThroughFlush(t *testing.T) {
+	logger, _ := makeTestLogger(config.AccessLogFormatCommon)
+	handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("a"))
+		w.(http.Flusher).Flush()
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.True(t, rec.Flushed)
+}
+
+func TestClose(t *testing.T) {
+	logger, output := makeTestLogger(config.AccessLogFormatCommon)
+	assert.NoError(t, logger.Close())
+	assert.True(t, output.closed)
+}
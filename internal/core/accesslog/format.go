@@ -0,0 +1,144 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+)
+
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// formatter produces one line of access log output, including the trailing newline.
+type formatter func(Entry) []byte
+
+type jsonEntry struct {
+	Time           string  `json:"time"`
+	RemoteHost     string  `json:"remoteHost"`
+	ForwardedFor   string  `json:"forwardedFor,omitempty"`
+	Method         string  `json:"method"`
+	URI            string  `json:"uri"`
+	Proto          string  `json:"proto"`
+	Status         int     `json:"status"`
+	Bytes          uint64  `json:"bytes"`
+	LatencyMillis  float64 `json:"latencyMs"`
+	Referer        string  `json:"referer,omitempty"`
+	UserAgent      string  `json:"userAgent,omitempty"`
+	CredentialType string  `json:"credentialType,omitempty"`
+	Env            string  `json:"env,omitempty"`
+}
+
+func getFormatter(format string) formatter {
+	switch format {
+	case config.AccessLogFormatCombined:
+		return formatCombined
+	case config.AccessLogFormatJSON:
+		return formatJSON
+	default:
+		return formatCommon
+	}
+}
+
+// formatCommon produces the Common Log Format, followed by the latency in milliseconds, the credential
+// type, and the quoted environment name. Missing values are written as "-", as in the rest of the line.
+func formatCommon(e Entry) []byte {
+	var b strings.Builder
+	writeCommonFields(&b, e)
+	writeRelayFields(&b, e)
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+// formatCombined is the same as formatCommon, but with the referer and user agent after the Common Log
+// Format fields, as in the Combined Log Format.
+func formatCombined(e Entry) []byte {
+	var b strings.Builder
+	writeCommonFields(&b, e)
+	b.WriteByte(' ')
+	writeQuoted(&b, e.Referer)
+	b.WriteByte(' ')
+	writeQuoted(&b, e.UserAgent)
+	writeRelayFields(&b, e)
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+func formatJSON(e Entry) []byte {
+	data, _ := json.Marshal(jsonEntry{
+		Time:           e.Time.UTC().Format(time.RFC3339Nano),
+		RemoteHost:     e.RemoteHost,
+		ForwardedFor:   e.ForwardedFor,
+		Method:         e.Method,
+		URI:            e.URI,
+		Proto:          e.Proto,
+		Status:         e.Status,
+		Bytes:          e.Bytes,
+		LatencyMillis:  latencyMillis(e.Latency),
+		Referer:        e.Referer,
+		UserAgent:      e.UserAgent,
+		CredentialType: e.CredentialType,
+		Env:            e.Env,
+	})
+	return append(data, '\n')
+}
+
+func writeCommonFields(b *strings.Builder, e Entry) {
+	b.WriteString(orDash(e.RemoteHost))
+	b.WriteString(" - - [")
+	b.WriteString(e.Time.Format(clfTimeFormat))
+	b.WriteString("] ")
+	writeQuoted(b, e.Method+" "+e.URI+" "+e.Proto)
+	b.WriteByte(' ')
+	b.WriteString(strconv.Itoa(e.Status))
+	b.WriteByte(' ')
+	if e.Bytes == 0 {
+		b.WriteByte('-')
+	} else {
+		b.WriteString(strconv.FormatUint(e.Bytes, 10))
+	}
+}
+
+func writeRelayFields(b *strings.Builder, e Entry) {
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(latencyMillis(e.Latency), 'f', 3, 64))
+	b.WriteByte(' ')
+	b.WriteString(orDash(e.CredentialType))
+	b.WriteByte(' ')
+	writeQuoted(b, e.Env)
+}
+
+// writeQuoted writes a string in double quotes, escaping quotes, backslashes, and control characters
+// so that a request can't add fake lines or fields to the log. An empty string is written as "-".
+func writeQuoted(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	if s == "" {
+		b.WriteByte('-')
+	}
+	for _, ch := range s {
+		switch {
+		case ch == '"' || ch == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(ch)
+		case ch < 0x20 || ch == 0x7f:
+			b.WriteString(`\x`)
+			b.WriteString(strconv.FormatInt(int64(ch)>>4, 16))
+			b.WriteString(strconv.FormatInt(int64(ch)&0xf, 16))
+		default:
+			b.WriteRune(ch)
+		}
+	}
+	b.WriteByte('"')
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func latencyMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,71 @@
+package accesslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTestEntry() Entry {
+	return Entry{
+		Time:           time.Date(2021, 6, 7, 8, 9, 10, 0, time.FixedZone("", -7*3600)),
+		RemoteHost:     "10.1.2.3",
+		ForwardedFor:   "192.168.0.1",
+		Method:         "GET",
+		URI:            "/sdk/latest-all",
+		Proto:          "HTTP/1.1",
+		Status:         200,
+		Bytes:          1234,
+		Latency:        2500 * time.Microsecond,
+		Referer:        "https://example.com/",
+		UserAgent:      "GoClient/5.0.0",
+		CredentialType: credentialTypeSDKKey,
+		Env:            "Production",
+	}
+}
+
+func TestFormatCommon(t *testing.T) {
+	f := getFormatter(config.AccessLogFormatCommon)
+	assert.Equal(t,
+		`10.1.2.3 - - [07/Jun/2021:08:09:10 -0700] "GET /sdk/latest-all HTTP/1.1" 200 1234 2.500 sdkKey "Production"`+"\n",
+		string(f(makeTestEntry())))
+
+	assert.Equal(t,
+		`- - - [01/Jan/0001:00:00:00 +0000] "  " 0 - 0.000 - "-"`+"\n",
+		string(f(Entry{})))
+}
+
+func TestFormatCommonIsDefault(t *testing.T) {
+	assert.Equal(t, string(formatCommon(makeTestEntry())), string(getFormatter("")(makeTestEntry())))
+}
+
+func TestFormatCombined(t *testing.T) {
+	f := getFormatter(config.AccessLogFormatCombined)
+	assert.Equal(t,
+		`10.1.2.3 - - [07/Jun/2021:08:09:10 -0700] "GET /sdk/latest-all HTTP/1.1" 200 1234 `+
+			`"https://example.com/" "GoClient/5.0.0" 2.500 sdkKey "Production"`+"\n",
+		string(f(makeTestEntry())))
+}
+
+func TestFormatJSON(t *testing.T) {
+	f := getFormatter(config.AccessLogFormatJSON)
+	line := f(makeTestEntry())
+	assert.Equal(t, byte('\n'), line[len(line)-1])
+	assert.JSONEq(t, `{"time": "2021-06-07T15:09:10Z", "remoteHost": "10.1.2.3", "forwardedFor": "192.168.0.1",
+		"method": "GET", "uri": "/sdk/latest-all", "proto": "HTTP/1.1", "status": 200, "bytes": 1234,
+		"latencyMs": 2.5, "referer": "https://example.com/", "userAgent": "GoClient/5.0.0",
+		"credentialType": "sdkKey", "env": "Production"}`, string(line))
+}
+
+func TestQuotedFieldsAreEscaped(t *testing.T) {
+	e := makeTestEntry()
+	e.UserAgent = "bad\"agent\\\n"
+	e.Env = "tab\tname"
+	assert.Equal(t,
+		`10.1.2.3 - - [07/Jun/2021:08:09:10 -0700] "GET /sdk/latest-all HTTP/1.1" 200 1234 `+
+			`"https://example.com/" "bad\"agent\\\x0a" 2.500 sdkKey "tab\x09name"`+"\n",
+		string(formatCombined(e)))
+}
@@ -0,0 +1,2 @@
+// Package accesslog contains the HTTP access log, which records every request to Relay's endpoints.
+package accesslog
@@ -0,0 +1,59 @@
+package accesslog
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := openRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+	defer f.Close()
+
+	for _, line := range []string{"line1\n", "line2\n", "line3\n", "line4\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	// each file holds one line, since two would be more than 10 bytes; the oldest line was discarded
+	assert.Equal(t, "line4\n", readFile(t, path))
+	assert.Equal(t, "line3\n", readFile(t, path+".1"))
+	assert.Equal(t, "line2\n", readFile(t, path+".2"))
+	assert.NoFileExists(t, path+".3")
+}
+
+func TestRotatingFileAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("old\n"), 0600))
+
+	f, err := openRotatingFile(path, 100, 2)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("new\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, "old\nnew\n", readFile(t, path))
+}
+
+func TestRotatingFileWritesLineLargerThanMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := openRotatingFile(path, 4, 1)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("a long line\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "a long line\n", readFile(t, path))
+	assert.NoFileExists(t, path+".1")
+}
@@ -0,0 +1,26 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package accesslog
+
+import (
+	"io"
+	"log/syslog"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+)
+
+// openSyslog connects to the syslog daemon at config.AccessLogConfig.SyslogAddress, or to the local one
+// if that is not set. Each request is sent as a message at Info priority in the daemon facility.
+func openSyslog(c config.AccessLogConfig) (io.WriteCloser, error) {
+	tag := c.SyslogTag
+	if tag == "" {
+		tag = config.DefaultAccessLogSyslogTag
+	}
+	network, address := "", ""
+	if c.SyslogAddress.IsDefined() {
+		u := c.SyslogAddress.Get()
+		network, address = u.Scheme, u.Host
+	}
+	return syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+}
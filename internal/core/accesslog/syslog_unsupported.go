@@ -0,0 +1,15 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package accesslog
+
+import (
+	"errors"
+	"io"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+)
+
+func openSyslog(c config.AccessLogConfig) (io.WriteCloser, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
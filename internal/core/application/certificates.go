@@ -0,0 +1,152 @@
+package application
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+// CertificateSource provides the TLS certificate for StartHTTPServer and StartGRPCServer. It is created
+// by NewCertificateFileSource, NewACMECertificateSource, or NewSNICertificateSource.
+type CertificateSource struct {
+	getCertificate   func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	extraNextProtos  []string
+	challengeHandler http.Handler
+	closers          []func()
+}
+
+// ACMEOptions are the parameters for NewACMECertificateSource.
+type ACMEOptions struct {
+	// Domains are the host names to obtain certificates for. Requests for any other host name are
+	// refused, so that a client cannot make Relay request certificates for arbitrary names.
+	Domains []string
+	// Email is the contact address given to the certificate authority. It is optional.
+	Email string
+	// CacheDir is the directory where certificates and the account key are stored between restarts.
+	CacheDir string
+	// DirectoryURL is the certificate authority's ACME directory. If empty, Let's Encrypt is used.
+	DirectoryURL string
+}
+
+// NewCertificateFileSource creates a CertificateSource that uses a certificate and key from PEM files.
+// It returns an error if the files can't be loaded.
+//
+// The files are watched for changes, so that a renewed certificate is used for new connections without
+// restarting Relay. If the new files can't be loaded (for instance, because only one of them has been
+// updated so far), the previous certificate stays in use.
+func NewCertificateFileSource(certFile, keyFile string, loggers ldlog.Loggers) (*CertificateSource, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	var lock sync.RWMutex
+	current := &cert
+	s := &CertificateSource{
+		getCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			lock.RLock()
+			defer lock.RUnlock()
+			return current, nil
+		},
+	}
+	reload := func() {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			loggers.Warnf("Unable to reload TLS certificate, will keep using the previous one: %s", err)
+			return
+		}
+		lock.Lock()
+		current = &cert
+		lock.Unlock()
+		loggers.Info("Reloaded TLS certificate")
+	}
+	for _, path := range []string{certFile, keyFile} {
+		watcher, err := WatchConfigFile(path, reload, loggers)
+		if err != nil {
+			loggers.Warnf("Unable to watch TLS certificate file %q for changes: %s", path, err)
+			continue
+		}
+		s.closers = append(s.closers, func() { _ = watcher.Close() })
+	}
+	return s, nil
+}
+
+// NewACMECertificateSource creates a CertificateSource that obtains and renews certificates
+// automatically from an ACME certificate authority such as Let's Encrypt. Certificates are requested
+// when the first TLS connection for each domain is made.
+//
+// The certificate authority can verify the domain either with the TLS-ALPN-01 challenge, which is
+// handled on the TLS port itself, or with the HTTP-01 challenge, which requires serving the handler
+// returned by ACMEChallengeHandler on port 80.
+func NewACMECertificateSource(options ACMEOptions) *CertificateSource {
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(options.Domains...),
+		Cache:      autocert.DirCache(options.CacheDir),
+		Email:      options.Email,
+	}
+	if options.DirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: options.DirectoryURL}
+	}
+	return &CertificateSource{
+		getCertificate:   manager.GetCertificate,
+		extraNextProtos:  []string{acme.ALPNProto},
+		challengeHandler: manager.HTTPHandler(nil),
+	}
+}
+
+// NewSNICertificateSource creates a CertificateSource that chooses a certificate according to the host
+// name that the client requested with SNI (Server Name Indication). The keys of byHost are host names,
+// which are matched case-insensitively. If the client did not use SNI, or requested a host name that is
+// not in byHost, the certificate from defaultSource is used.
+//
+// ACME challenges are handled by defaultSource, if it is an ACME CertificateSource. Closing the new
+// CertificateSource also closes all of the ones it uses.
+func NewSNICertificateSource(defaultSource *CertificateSource, byHost map[string]*CertificateSource) *CertificateSource {
+	lowerByHost := make(map[string]*CertificateSource, len(byHost))
+	closers := append([]func(){}, defaultSource.closers...)
+	seen := map[*CertificateSource]bool{defaultSource: true}
+	for host, source := range byHost {
+		lowerByHost[strings.ToLower(host)] = source
+		if !seen[source] {
+			seen[source] = true
+			closers = append(closers, source.closers...)
+		}
+	}
+	return &CertificateSource{
+		getCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if source, ok := lowerByHost[strings.ToLower(hello.ServerName)]; ok {
+				return source.getCertificate(hello)
+			}
+			return defaultSource.getCertificate(hello)
+		},
+		extraNextProtos:  defaultSource.extraNextProtos,
+		challengeHandler: defaultSource.challengeHandler,
+		closers:          closers,
+	}
+}
+
+// ACMEChallengeHandler returns a handler for ACME HTTP-01 challenge requests, which redirects all other
+// requests to HTTPS. It returns nil if this is not an ACME CertificateSource.
+func (s *CertificateSource) ACMEChallengeHandler() http.Handler {
+	return s.challengeHandler
+}
+
+// Close stops watching for changes to certificate files, if applicable.
+func (s *CertificateSource) Close() {
+	for _, c := range s.closers {
+		c()
+	}
+}
+
+func (s *CertificateSource) makeTLSConfig(minVersion uint16) *tls.Config {
+	return &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: s.getCertificate,
+		NextProtos:     append([]string{"h2", "http/1.1"}, s.extraNextProtos...),
+	}
+}
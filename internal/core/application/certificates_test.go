@@ -0,0 +1,117 @@
+package application
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	helpers "github.com/launchdarkly/go-test-helpers/v2"
+	"github.com/launchdarkly/go-test-helpers/v2/httphelpers"
+	"golang.org/x/crypto/acme"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCertificateFileSourceWithInvalidFiles(t *testing.T) {
+	_, err := NewCertificateFileSource("not-a-file", "not-a-file", ldlog.NewDisabledLoggers())
+	assert.Error(t, err)
+}
+
+func TestCertificateFileSourceReloadsChangedFiles(t *testing.T) {
+	withSelfSignedCert(t, func(certFilePath, keyFilePath string, _ *x509.CertPool) {
+		certs := mustMakeCertificateFileSource(t, certFilePath, keyFilePath)
+		cert1, err := certs.getCertificate(nil)
+		require.NoError(t, err)
+		assert.Nil(t, certs.ACMEChallengeHandler())
+
+		helpers.WithTempFile(func(newCertFilePath string) {
+			helpers.WithTempFile(func(newKeyFilePath string) {
+				require.NoError(t, httphelpers.MakeSelfSignedCert(newCertFilePath, newKeyFilePath))
+				newKeyData, _ := ioutil.ReadFile(newKeyFilePath)
+				newCertData, _ := ioutil.ReadFile(newCertFilePath)
+				require.NoError(t, ioutil.WriteFile(keyFilePath, newKeyData, 0600))
+				require.NoError(t, ioutil.WriteFile(certFilePath, newCertData, 0600))
+			})
+		})
+
+		require.Eventually(t, func() bool {
+			cert2, err := certs.getCertificate(nil)
+			require.NoError(t, err)
+			return string(cert2.Certificate[0]) != string(cert1.Certificate[0])
+		}, time.Second*5, time.Millisecond*50)
+	})
+}
+
+func TestCertificateFileSourceKeepsPreviousCertificateIfFilesAreInvalid(t *testing.T) {
+	withSelfSignedCert(t, func(certFilePath, keyFilePath string, _ *x509.CertPool) {
+		mockLog := ldlogtest.NewMockLog()
+		certs, err := NewCertificateFileSource(certFilePath, keyFilePath, mockLog.Loggers)
+		require.NoError(t, err)
+		defer certs.Close()
+		cert1, _ := certs.getCertificate(nil)
+
+		require.NoError(t, ioutil.WriteFile(certFilePath, []byte("not a certificate"), 0600))
+
+		require.Eventually(t, func() bool {
+			return mockLog.HasMessageMatch(ldlog.Warn, "Unable to reload TLS certificate")
+		}, time.Second*5, time.Millisecond*50)
+		cert2, err := certs.getCertificate(nil)
+		require.NoError(t, err)
+		assert.Equal(t, cert1, cert2)
+	})
+}
+
+func TestACMECertificateSource(t *testing.T) {
+	certs := NewACMECertificateSource(ACMEOptions{
+		Domains:  []string{"relay.example.com"},
+		CacheDir: t.TempDir(),
+	})
+	assert.NotNil(t, certs.ACMEChallengeHandler())
+	assert.Contains(t, certs.makeTLSConfig(0).NextProtos, acme.ALPNProto)
+}
+
+func TestSNICertificateSource(t *testing.T) {
+	withSelfSignedCert(t, func(defaultCertFilePath, defaultKeyFilePath string, _ *x509.CertPool) {
+		withSelfSignedCert(t, func(tenantCertFilePath, tenantKeyFilePath string, _ *x509.CertPool) {
+			defaultCerts := mustMakeCertificateFileSource(t, defaultCertFilePath, defaultKeyFilePath)
+			tenantCerts := mustMakeCertificateFileSource(t, tenantCertFilePath, tenantKeyFilePath)
+			defaultCert, _ := defaultCerts.getCertificate(nil)
+			tenantCert, _ := tenantCerts.getCertificate(nil)
+
+			certs := NewSNICertificateSource(defaultCerts, map[string]*CertificateSource{"Flags.Example.com": tenantCerts})
+
+			for _, p := range []struct {
+				serverName string
+				expected   *tls.Certificate
+			}{
+				{"flags.example.com", tenantCert},
+				{"FLAGS.EXAMPLE.COM", tenantCert},
+				{"other.example.com", defaultCert},
+				{"", defaultCert},
+			} {
+				t.Run(p.serverName, func(t *testing.T) {
+					cert, err := certs.getCertificate(&tls.ClientHelloInfo{ServerName: p.serverName})
+					require.NoError(t, err)
+					assert.Equal(t, p.expected, cert)
+				})
+			}
+			assert.Nil(t, certs.ACMEChallengeHandler())
+		})
+	})
+}
+
+func TestSNICertificateSourceUsesACMEChallengeHandlerOfDefaultSource(t *testing.T) {
+	withSelfSignedCert(t, func(certFilePath, keyFilePath string, _ *x509.CertPool) {
+		acmeCerts := NewACMECertificateSource(ACMEOptions{Domains: []string{"relay.example.com"}, CacheDir: t.TempDir()})
+		certs := NewSNICertificateSource(acmeCerts, map[string]*CertificateSource{
+			"flags.example.com": mustMakeCertificateFileSource(t, certFilePath, keyFilePath),
+		})
+		assert.NotNil(t, certs.ACMEChallengeHandler())
+		assert.Contains(t, certs.makeTLSConfig(0).NextProtos, acme.ALPNProto)
+	})
+}
@@ -0,0 +1,256 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+	"github.com/launchdarkly/ld-relay/v6/internal/util"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+const (
+	sdkKeyPrefix        = "sdk-"
+	mobileKeyPrefix     = "mob-"
+	autoConfigKeyPrefix = "rel-"
+)
+
+var environmentIDPattern = regexp.MustCompile(`^[0-9a-f]{24}$`) //nolint:gochecknoglobals
+
+func errCredentialFormat(envName, kind, expected string) error {
+	return fmt.Errorf("environment %q: %s does not look valid; it should %s", envName, kind, expected)
+}
+
+func errCredentialWhitespace(envName, kind string) error {
+	return fmt.Errorf("environment %q: %s contains spaces or line breaks", envName, kind)
+}
+
+func errUpstreamUnreachable(uri string, err error) error {
+	return fmt.Errorf("unable to connect to %s: %w", uri, err)
+}
+
+func errUpstreamRejectedKey(envName string, status int) error {
+	return fmt.Errorf("environment %q: LaunchDarkly rejected the SDK key (HTTP status %d)", envName, status)
+}
+
+func errUpstreamStatus(envName string, status int) error {
+	return fmt.Errorf("environment %q: unexpected HTTP status %d from LaunchDarkly", envName, status)
+}
+
+func errRedisUnreachable(server string, err error) error {
+	return fmt.Errorf("unable to connect to Redis at %s: %w", server, err)
+}
+
+func errDynamoDBTable(table string, err error) error {
+	return fmt.Errorf("unable to read DynamoDB table %q: %w", table, err)
+}
+
+// CheckCredentials checks that the SDK keys, mobile keys, environment IDs, and auto-configuration key in
+// a configuration have the format of LaunchDarkly credentials. ValidateConfig does not check this, since
+// a credential that is wrong in any other way would not be detected either; this is only a quick way to
+// catch mistakes such as a key being pasted into the wrong property. The errors do not include the
+// credentials.
+func CheckCredentials(c config.Config) []error {
+	var errs []error
+	if key := string(c.AutoConfig.Key); key != "" {
+		errs = append(errs, checkCredential("(auto-configuration)", "auto-configuration key", key,
+			strings.HasPrefix(key, autoConfigKeyPrefix), `start with "`+autoConfigKeyPrefix+`"`)...)
+	}
+	for _, name := range sortedEnvNames(c) {
+		ec := c.Environment[name]
+		if key := string(ec.SDKKey); key != "" {
+			errs = append(errs, checkCredential(name, "SDK key", key,
+				strings.HasPrefix(key, sdkKeyPrefix), `start with "`+sdkKeyPrefix+`"`)...)
+		}
+		if key := string(ec.MobileKey); key != "" {
+			errs = append(errs, checkCredential(name, "mobile key", key,
+				strings.HasPrefix(key, mobileKeyPrefix), `start with "`+mobileKeyPrefix+`"`)...)
+		}
+		if id := string(ec.EnvID); id != "" {
+			errs = append(errs, checkCredential(name, "client-side ID", id,
+				environmentIDPattern.MatchString(id), "be 24 hexadecimal digits")...)
+		}
+	}
+	return errs
+}
+
+func checkCredential(envName, kind, value string, valid bool, expected string) []error {
+	if strings.TrimSpace(value) != value || strings.ContainsAny(value, " \t\r\n") {
+		return []error{errCredentialWhitespace(envName, kind)}
+	}
+	if !valid {
+		return []error{errCredentialFormat(envName, kind, expected)}
+	}
+	return nil
+}
+
+// CheckConnections checks that Relay can reach LaunchDarkly, and that LaunchDarkly accepts each
+// environment's SDK key, and that it can reach the Redis servers and DynamoDB tables that the
+// environments use. Each check must finish within the timeout. Other kinds of databases are not
+// checked.
+func CheckConnections(c config.Config, userAgent string, timeout time.Duration, loggers ldlog.Loggers) []error {
+	var errs []error
+	if c.OfflineMode.FileDataSource == "" && !c.OfflineMode.NoOutboundConnections && !c.ReadReplica.Enabled {
+		errs = append(errs, checkUpstream(c, userAgent, timeout, loggers)...)
+	}
+
+	// Each environment may use a different database, but most of them are likely to share one, so
+	// each Redis server and DynamoDB table is only checked once.
+	checked := make(map[string]bool)
+	checkDatabase := func(envConfig config.EnvConfig) {
+		envAllConfig, err := config.EnvironmentDatabaseConfig(c, envConfig)
+		if err != nil {
+			return // ValidateConfig has already reported this
+		}
+		if envAllConfig.Redis.URL.IsDefined() {
+			if err := checkRedis(envAllConfig.Redis, envConfig, timeout, checked); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if envAllConfig.DynamoDB.Enabled {
+			if err := checkDynamoDB(envAllConfig.DynamoDB, envConfig, timeout, checked); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(c.Environment) == 0 {
+		checkDatabase(config.EnvConfig{}) // with auto-configuration, the environments are not known yet
+	}
+	for _, name := range sortedEnvNames(c) {
+		checkDatabase(*c.Environment[name])
+	}
+	return errs
+}
+
+func checkUpstream(c config.Config, userAgent string, timeout time.Duration, loggers ldlog.Loggers) []error {
+	httpConfig, err := httpconfig.NewHTTPConfig(c.Proxy, nil, userAgent, loggers)
+	if err != nil {
+		return []error{err}
+	}
+	client := httpConfig.Client()
+	baseURI := c.Main.BaseURI.String()
+	if len(c.Environment) == 0 {
+		// Without an SDK key, we can only check that something answers at the base URI.
+		if _, err := doCheckRequest(client, baseURI, "", timeout); err != nil {
+			return []error{errUpstreamUnreachable(baseURI, err)}
+		}
+		return nil
+	}
+	var errs []error
+	for _, name := range sortedEnvNames(c) {
+		ec := c.Environment[name]
+		if ec.SDKKey == "" {
+			continue
+		}
+		status, err := doCheckRequest(client, strings.TrimSuffix(baseURI, "/")+"/sdk/latest-all",
+			ec.SDKKey.GetAuthorizationHeaderValue(), timeout)
+		switch {
+		case err != nil:
+			// The same error is likely for every environment, so there is no point in trying the others.
+			return append(errs, errUpstreamUnreachable(baseURI, err))
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			errs = append(errs, errUpstreamRejectedKey(name, status))
+		case status >= 300:
+			errs = append(errs, errUpstreamStatus(name, status))
+		}
+	}
+	return errs
+}
+
+// doCheckRequest makes a GET request and returns the status. The response body is discarded.
+func doCheckRequest(client *http.Client, uri, authorization string, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return 0, err
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+func checkRedis(
+	dbConfig config.RedisConfig,
+	envConfig config.EnvConfig,
+	timeout time.Duration,
+	checked map[string]bool,
+) error {
+	server := util.RedactURL(dbConfig.URL.String())
+	if sdks.IsRedisClusterOrSentinel(dbConfig) {
+		server = strings.Join(append(dbConfig.ClusterAddrs.Values(), dbConfig.SentinelAddrs.Values()...), ",")
+	}
+	if checked["redis "+server] {
+		return nil
+	}
+	checked["redis "+server] = true
+	client, _, err := sdks.NewRedisClient(dbConfig, envConfig)
+	if err != nil {
+		return errRedisUnreachable(server, err)
+	}
+	defer client.Close() //nolint:errcheck
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return errRedisUnreachable(server, err)
+	}
+	return nil
+}
+
+func checkDynamoDB(
+	dbConfig config.DynamoDBConfig,
+	envConfig config.EnvConfig,
+	timeout time.Duration,
+	checked map[string]bool,
+) error {
+	endpoint, table, _ := sdks.GetDynamoDBBasicProperties(dbConfig, envConfig)
+	key := "dynamodb " + aws.StringValue(endpoint) + " " + table
+	if table == "" || checked[key] {
+		return nil
+	}
+	checked[key] = true
+	awsConfig := aws.Config{Endpoint: endpoint, Credentials: sdks.GetDynamoDBCredentials(dbConfig)}
+	sess, err := session.NewSessionWithOptions(session.Options{Config: awsConfig, SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return errDynamoDBTable(table, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err := dynamodb.New(sess).DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(table),
+	}); err != nil {
+		return errDynamoDBTable(table, err)
+	}
+	return nil
+}
+
+func sortedEnvNames(c config.Config) []string {
+	names := make([]string, 0, len(c.Environment))
+	for name := range c.Environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
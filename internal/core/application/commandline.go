@@ -1,6 +1,7 @@
 package application
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -8,20 +9,63 @@ import (
 	"strings"
 )
 
-// DefaultConfigPath is the default configuration file path.
-const DefaultConfigPath = "/etc/ld-relay.conf"
+const (
+	// DefaultConfigPath is the default configuration file path.
+	DefaultConfigPath = "/etc/ld-relay.conf"
+
+	// CommandCheckConfig is the command that loads and checks the configuration, and then exits instead
+	// of starting Relay.
+	CommandCheckConfig = "check-config"
+
+	// CommandMigrateStore is the command that copies Relay's data from one persistent store to another,
+	// and then exits instead of starting Relay.
+	CommandMigrateStore = "migrate-store"
+)
 
 // Options represents all options that can be set from the command line.
 type Options struct {
+	// Command is the command that was given before any options, or "" to start Relay.
+	Command          string
 	ConfigFile       string
 	AllowMissingFile bool
 	UseEnvironment   bool
+	// CheckConnections is only used with CommandCheckConfig.
+	CheckConnections bool
+	// MigrateFrom, MigrateTo, MigratePrefixes, and Verify are only used with CommandMigrateStore, which
+	// does not use a configuration file.
+	MigrateFrom     string
+	MigrateTo       string
+	MigratePrefixes []string
+	Verify          bool
+}
+
+// stringListFlag is a flag that can be given more than once.
+type stringListFlag struct {
+	values *[]string
+}
+
+func (f stringListFlag) String() string {
+	if f.values == nil {
+		return ""
+	}
+	return strings.Join(*f.values, ",")
+}
+
+func (f stringListFlag) Set(value string) error {
+	*f.values = append(*f.values, value)
+	return nil
 }
 
 func errConfigFileNotFound(filename string) error {
 	return fmt.Errorf("configuration file %q does not exist", filename)
 }
 
+func errUnknownCommand(command string) error {
+	return fmt.Errorf("unknown command %q; must be %q or %q", command, CommandCheckConfig, CommandMigrateStore)
+}
+
+var errMigrateStoreWithoutURLs = errors.New("the migrate-store command requires both --from and --to")
+
 // DescribeConfigSource returns a human-readable phrase describing whether the configuration comes from a
 // file, from variables, or both.
 func (o Options) DescribeConfigSource() string {
@@ -46,15 +90,33 @@ func (o Options) DescribeConfigSource() string {
 // 2. If you specify --from-env, it creates a configuration from environment variables as described in README.
 // 3. If you specify both, the file is loaded first, then it applies changes from variables if any.
 // 4. Omitting all options is equivalent to explicitly specifying --config /etc/ld-relay.conf.
+//
+// The options can be preceded by a command. The "check-config" command has the same configuration options
+// and one of its own; the "migrate-store" command has only its own options.
 func ReadOptions(osArgs []string, errorOutput io.Writer) (Options, error) {
 	var o Options
 
-	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	args := osArgs[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		o.Command, args = args[0], args[1:]
+		if o.Command != CommandCheckConfig && o.Command != CommandMigrateStore {
+			return o, errUnknownCommand(o.Command)
+		}
+	}
+	if o.Command == CommandMigrateStore {
+		return readMigrateStoreOptions(o, args, errorOutput)
+	}
+
+	fs := flag.NewFlagSet(o.Command, flag.ContinueOnError)
 	fs.SetOutput(errorOutput)
 	fs.StringVar(&o.ConfigFile, "config", "", "configuration file location")
 	fs.BoolVar(&o.AllowMissingFile, "allow-missing-file", false, "suppress error if config file is not found")
 	fs.BoolVar(&o.UseEnvironment, "from-env", false, "read configuration from environment variables")
-	err := fs.Parse(osArgs[1:])
+	if o.Command == CommandCheckConfig {
+		fs.BoolVar(&o.CheckConnections, "check-connections", false,
+			"also check that LaunchDarkly and the configured databases can be reached")
+	}
+	err := fs.Parse(args)
 	if err != nil {
 		return o, err
 	}
@@ -77,6 +139,23 @@ func ReadOptions(osArgs []string, errorOutput io.Writer) (Options, error) {
 	return o, nil
 }
 
+func readMigrateStoreOptions(o Options, args []string, errorOutput io.Writer) (Options, error) {
+	fs := flag.NewFlagSet(o.Command, flag.ContinueOnError)
+	fs.SetOutput(errorOutput)
+	fs.StringVar(&o.MigrateFrom, "from", "", "URL of the store to copy data from")
+	fs.StringVar(&o.MigrateTo, "to", "", "URL of the store to copy data to")
+	fs.Var(stringListFlag{&o.MigratePrefixes}, "prefix",
+		"key prefix to copy, or FROM=TO to use a different prefix in the target store (can be repeated)")
+	fs.BoolVar(&o.Verify, "verify", false, "compare the data in the stores instead of copying it")
+	if err := fs.Parse(args); err != nil {
+		return o, err
+	}
+	if o.MigrateFrom == "" || o.MigrateTo == "" {
+		return o, errMigrateStoreWithoutURLs
+	}
+	return o, nil
+}
+
 // DescribeRelayVersion returns the same version string unless it is a prerelease build, in
 // which case it is reformatted to change "+xxx" into "(build xxx)".
 func DescribeRelayVersion(version string) string {
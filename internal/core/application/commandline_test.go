@@ -58,6 +58,48 @@ func TestReadOptions(t *testing.T) {
 		_, err := ReadOptions([]string{appName, "--unknown"}, ioutil.Discard)
 		assert.Error(t, err)
 	})
+
+	t.Run("check-config command", func(t *testing.T) {
+		helpers.WithTempFile(func(filename string) {
+			opts, err := ReadOptions([]string{appName, "check-config", "--config", filename, "--check-connections"}, ioutil.Discard)
+			require.NoError(t, err)
+			assert.Equal(t, CommandCheckConfig, opts.Command)
+			assert.Equal(t, filename, opts.ConfigFile)
+			assert.True(t, opts.CheckConnections)
+		})
+	})
+
+	t.Run("check-connections is only allowed with check-config command", func(t *testing.T) {
+		_, err := ReadOptions([]string{appName, "--from-env", "--check-connections"}, ioutil.Discard)
+		assert.Error(t, err)
+	})
+
+	t.Run("migrate-store command", func(t *testing.T) {
+		opts, err := ReadOptions([]string{appName, "migrate-store", "--from", "redis://localhost:6379",
+			"--to", "dynamodb://table", "--prefix", "a", "--prefix", "b=c", "--verify"}, ioutil.Discard)
+		require.NoError(t, err)
+		assert.Equal(t, CommandMigrateStore, opts.Command)
+		assert.Equal(t, "redis://localhost:6379", opts.MigrateFrom)
+		assert.Equal(t, "dynamodb://table", opts.MigrateTo)
+		assert.Equal(t, []string{"a", "b=c"}, opts.MigratePrefixes)
+		assert.True(t, opts.Verify)
+		assert.Equal(t, "", opts.ConfigFile)
+	})
+
+	t.Run("migrate-store requires both stores", func(t *testing.T) {
+		_, err := ReadOptions([]string{appName, "migrate-store", "--from", "redis://localhost:6379"}, ioutil.Discard)
+		assert.Equal(t, errMigrateStoreWithoutURLs, err)
+	})
+
+	t.Run("migrate-store does not have configuration options", func(t *testing.T) {
+		_, err := ReadOptions([]string{appName, "migrate-store", "--from-env"}, ioutil.Discard)
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown command", func(t *testing.T) {
+		_, err := ReadOptions([]string{appName, "check", "--from-env"}, ioutil.Discard)
+		assert.Equal(t, errUnknownCommand("check"), err)
+	})
 }
 
 func TestDescribeRelayVersion(t *testing.T) {
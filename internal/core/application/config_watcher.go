@@ -0,0 +1,96 @@
+package application
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+// Editors and deployment tools often update a file in several steps (for instance, truncating and then
+// writing it, or writing a temporary file and renaming it), so we wait for things to settle down before
+// reporting a change.
+const defaultConfigWatcherDelay = time.Millisecond * 500
+
+// ConfigFileWatcher detects changes to a configuration file. It is returned by WatchConfigFile.
+type ConfigFileWatcher struct {
+	watcher   *fsnotify.Watcher
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+// WatchConfigFile starts watching the specified file, and calls onChange on a separate goroutine whenever
+// the file has been modified, created, or replaced. Several changes in quick succession are reported only
+// once.
+//
+// The directory containing the file is watched, rather than the file itself, so that this continues to
+// work if the file is replaced by another one with the same name.
+func WatchConfigFile(filePath string, onChange func(), loggers ldlog.Loggers) (*ConfigFileWatcher, error) {
+	return watchConfigFile(filePath, onChange, defaultConfigWatcherDelay, loggers)
+}
+
+func watchConfigFile(
+	filePath string,
+	onChange func(),
+	delay time.Duration,
+	loggers ldlog.Loggers,
+) (*ConfigFileWatcher, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err // COVERAGE: can't cause this condition in unit tests
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err // COVERAGE: can't cause this condition in unit tests
+	}
+	if err := watcher.Add(filepath.Dir(absPath)); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	w := &ConfigFileWatcher{watcher: watcher, closeCh: make(chan struct{})}
+	go w.run(absPath, onChange, delay, loggers)
+	return w, nil
+}
+
+// Close stops watching the file.
+func (w *ConfigFileWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.closeCh)
+	})
+	return nil
+}
+
+func (w *ConfigFileWatcher) run(absPath string, onChange func(), delay time.Duration, loggers ldlog.Loggers) {
+	var timerCh <-chan time.Time
+	for {
+		select {
+		case <-w.closeCh:
+			_ = w.watcher.Close()
+			return
+
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return // COVERAGE: can't cause this condition in unit tests
+			}
+			if filepath.Clean(event.Name) != absPath || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			loggers.Debugf("Got config file watcher event: %+v", event)
+			if timerCh == nil {
+				timerCh = time.After(delay)
+			}
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return // COVERAGE: can't cause this condition in unit tests
+			}
+			loggers.Warnf("Error from config file watcher: %s", err)
+
+		case <-timerCh:
+			timerCh = nil
+			onChange()
+		}
+	}
+}
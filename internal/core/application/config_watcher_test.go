@@ -0,0 +1,75 @@
+package application
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withConfigFileWatcher(t *testing.T, action func(filePath string, changesCh <-chan struct{})) {
+	dir, err := ioutil.TempDir("", "config-watcher-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filePath := filepath.Join(dir, "relay.conf")
+	require.NoError(t, ioutil.WriteFile(filePath, []byte("[Main]\n"), 0600))
+
+	changesCh := make(chan struct{}, 10)
+	w, err := watchConfigFile(filePath, func() { changesCh <- struct{}{} }, time.Millisecond*50, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer w.Close()
+
+	action(filePath, changesCh)
+}
+
+func requireConfigChange(t *testing.T, changesCh <-chan struct{}) {
+	select {
+	case <-changesCh:
+	case <-time.After(time.Second * 2):
+		require.Fail(t, "timed out waiting for config file change notification")
+	}
+}
+
+func requireNoConfigChange(t *testing.T, changesCh <-chan struct{}) {
+	select {
+	case <-changesCh:
+		require.Fail(t, "got unexpected config file change notification")
+	case <-time.After(time.Millisecond * 200):
+	}
+}
+
+func TestConfigFileWatcherDetectsModifiedFile(t *testing.T) {
+	withConfigFileWatcher(t, func(filePath string, changesCh <-chan struct{}) {
+		require.NoError(t, ioutil.WriteFile(filePath, []byte("[Main]\nport = 9000\n"), 0600))
+		requireConfigChange(t, changesCh)
+		requireNoConfigChange(t, changesCh)
+	})
+}
+
+func TestConfigFileWatcherDetectsReplacedFile(t *testing.T) {
+	withConfigFileWatcher(t, func(filePath string, changesCh <-chan struct{}) {
+		tempPath := filePath + ".new"
+		require.NoError(t, ioutil.WriteFile(tempPath, []byte("[Main]\nport = 9000\n"), 0600))
+		require.NoError(t, os.Rename(tempPath, filePath))
+		requireConfigChange(t, changesCh)
+	})
+}
+
+func TestConfigFileWatcherIgnoresOtherFiles(t *testing.T) {
+	withConfigFileWatcher(t, func(filePath string, changesCh <-chan struct{}) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(filepath.Dir(filePath), "other"), []byte("x"), 0600))
+		requireNoConfigChange(t, changesCh)
+	})
+}
+
+func TestConfigFileWatcherReturnsErrorIfDirectoryDoesNotExist(t *testing.T) {
+	_, err := WatchConfigFile("/not/a/real/directory/relay.conf", func() {}, ldlog.NewDisabledLoggers())
+	assert.Error(t, err)
+}
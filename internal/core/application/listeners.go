@@ -0,0 +1,160 @@
+package application
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+// listenFDsStart is the first file descriptor used for sockets passed by systemd socket activation.
+const listenFDsStart = 3
+
+func errInheritedSocket(fd int, err error) error {
+	return fmt.Errorf("unable to use socket %d from socket activation: %w", fd, err)
+}
+
+func errUnixSocketInUse(path string) error {
+	return fmt.Errorf("socket %s is already in use by another process", path)
+}
+
+func errInheritedSocketNotTCP(fd int) error {
+	return fmt.Errorf("socket %d from socket activation is not a TCP socket", fd)
+}
+
+// ListenerSource creates the TCP listeners for Relay's servers.
+//
+// If Relay was started by systemd socket activation, the sockets that systemd passed to it are used for
+// whichever ports they are bound to, instead of opening new ones. Since systemd keeps those sockets open
+// while the service restarts, incoming connections wait in the socket's queue rather than being refused.
+//
+// Otherwise, if reusePort is true, sockets are opened with the SO_REUSEPORT option, so that a new Relay
+// process can start listening on the same ports while the old one is still shutting down.
+//
+// If the PROXY protocol is enabled, connections from a load balancer can begin with a PROXY protocol
+// header (version 1 or 2), and the client address from the header is used as the connection's remote
+// address, so it is what Relay sees as the client's IP address. Connections without a header are
+// accepted as usual.
+//
+// A nil *ListenerSource just opens listeners in the usual way.
+type ListenerSource struct {
+	reusePort   bool
+	proxyPolicy proxyproto.PolicyFunc
+	proxyUsed   bool
+	inherited   map[int]net.Listener
+	lock        sync.Mutex
+}
+
+// ProxyProtocolOptions enables the PROXY protocol for NewListenerSource.
+type ProxyProtocolOptions struct {
+	// AllowedSources are the IP addresses or CIDR ranges that may send a PROXY protocol header. A
+	// connection from any other address that sends one is closed. If AllowedSources is empty, any
+	// connection may send one.
+	AllowedSources []string
+}
+
+// NewListenerSource creates a ListenerSource, taking ownership of any sockets that were passed to this
+// process by socket activation. The PROXY protocol is enabled if proxyProtocol is not nil; it returns
+// an error if any of the allowed sources is not a valid IP address or CIDR range.
+func NewListenerSource(reusePort bool, proxyProtocol *ProxyProtocolOptions, loggers ldlog.Loggers) (*ListenerSource, error) {
+	s := &ListenerSource{reusePort: reusePort}
+	if proxyProtocol != nil {
+		s.proxyUsed = true
+		if len(proxyProtocol.AllowedSources) != 0 {
+			policy, err := proxyproto.StrictWhiteListPolicy(proxyProtocol.AllowedSources)
+			if err != nil {
+				return nil, err
+			}
+			s.proxyPolicy = policy
+		}
+		loggers.Info("PROXY protocol enabled for listeners")
+	}
+	inherited, err := inheritedListeners(listenFDsStart)
+	if err != nil {
+		return nil, err
+	}
+	for port := range inherited {
+		loggers.Infof("Using socket from socket activation for port %d", port)
+	}
+	s.inherited = inherited
+	return s, nil
+}
+
+// Listen returns a listener for the specified port. An inherited socket can only be used once.
+func (s *ListenerSource) Listen(port int) (net.Listener, error) {
+	if s == nil {
+		return net.Listen("tcp", fmt.Sprintf(":%d", port))
+	}
+	s.lock.Lock()
+	l, ok := s.inherited[port]
+	delete(s.inherited, port)
+	s.lock.Unlock()
+	if !ok {
+		var err error
+		if s.reusePort {
+			l, err = listenReusePort(port)
+		} else {
+			l, err = net.Listen("tcp", fmt.Sprintf(":%d", port))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.proxyUsed {
+		l = &proxyproto.Listener{Listener: l, Policy: s.proxyPolicy}
+	}
+	return l, nil
+}
+
+// ListenUnix returns a listener for a Unix domain socket at the specified path, setting the socket's file
+// permissions to mode if it is nonzero. If there is already a socket file at that path that nothing is
+// listening on, as there can be if a previous Relay process did not shut down cleanly, it is replaced. The
+// file is removed when the listener is closed. The PROXY protocol is never used for Unix sockets, since
+// the connections cannot come from a load balancer.
+func (s *ListenerSource) ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return nil, errUnixSocketInUse(path)
+		}
+		_ = os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			_ = l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// keepAliveListener sets the TCP keep-alive interval of each accepted connection, including connections
+// that are wrapped for the PROXY protocol.
+type keepAliveListener struct {
+	net.Listener
+	period time.Duration
+}
+
+func (l keepAliveListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tcpConn, ok := conn.(*net.TCPConn)
+	if pc, isProxied := conn.(*proxyproto.Conn); isProxied {
+		tcpConn, ok = pc.TCPConn()
+	}
+	if ok {
+		_ = tcpConn.SetKeepAlive(true)
+		_ = tcpConn.SetKeepAlivePeriod(l.period)
+	}
+	return conn, nil
+}
@@ -0,0 +1,105 @@
+package application
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// acceptAndReadLine accepts one connection, and returns its remote address and the first line that
+// was read from it, or the error from reading.
+func acceptAndReadLine(t *testing.T, l net.Listener) (net.Addr, string, error) {
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	return conn.RemoteAddr(), line, err
+}
+
+func withProxyProtocolListener(t *testing.T, options *ProxyProtocolOptions, action func(net.Listener, int)) {
+	s, err := NewListenerSource(false, options, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	port := st.GetAvailablePort(t)
+	l, err := s.Listen(port)
+	require.NoError(t, err)
+	defer l.Close()
+	action(l, port)
+}
+
+func dialAndWrite(t *testing.T, port int, data string) {
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	_, err = conn.Write([]byte(data))
+	require.NoError(t, err)
+}
+
+func TestListenerSourceWithProxyProtocolUsesAddressFromHeader(t *testing.T) {
+	withProxyProtocolListener(t, &ProxyProtocolOptions{}, func(l net.Listener, port int) {
+		dialAndWrite(t, port, "PROXY TCP4 203.0.113.9 192.0.2.1 51234 8030\r\nhello\n")
+
+		addr, line, err := acceptAndReadLine(t, l)
+		require.NoError(t, err)
+		assert.Equal(t, "hello\n", line)
+		assert.Equal(t, "203.0.113.9:51234", addr.String())
+	})
+}
+
+func TestListenerSourceWithProxyProtocolAcceptsConnectionWithoutHeader(t *testing.T) {
+	withProxyProtocolListener(t, &ProxyProtocolOptions{}, func(l net.Listener, port int) {
+		dialAndWrite(t, port, "hello\n")
+
+		addr, line, err := acceptAndReadLine(t, l)
+		require.NoError(t, err)
+		assert.Equal(t, "hello\n", line)
+		assert.Equal(t, "127.0.0.1", addr.(*net.TCPAddr).IP.String())
+	})
+}
+
+func TestListenerSourceWithProxyProtocolAllowsHeaderOnlyFromAllowedSources(t *testing.T) {
+	t.Run("allowed", func(t *testing.T) {
+		withProxyProtocolListener(t, &ProxyProtocolOptions{AllowedSources: []string{"127.0.0.0/8"}}, func(l net.Listener, port int) {
+			dialAndWrite(t, port, "PROXY TCP4 203.0.113.9 192.0.2.1 51234 8030\r\nhello\n")
+
+			addr, _, err := acceptAndReadLine(t, l)
+			require.NoError(t, err)
+			assert.Equal(t, "203.0.113.9:51234", addr.String())
+		})
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		withProxyProtocolListener(t, &ProxyProtocolOptions{AllowedSources: []string{"10.0.0.1"}}, func(l net.Listener, port int) {
+			dialAndWrite(t, port, "PROXY TCP4 203.0.113.9 192.0.2.1 51234 8030\r\nhello\n")
+
+			_, _, err := acceptAndReadLine(t, l)
+			assert.Error(t, err)
+		})
+	})
+}
+
+func TestNewListenerSourceWithInvalidProxyProtocolSource(t *testing.T) {
+	_, err := NewListenerSource(false, &ProxyProtocolOptions{AllowedSources: []string{"not-an-address"}},
+		ldlog.NewDisabledLoggers())
+	assert.Error(t, err)
+}
+
+func TestKeepAliveListenerAcceptsProxiedConnections(t *testing.T) {
+	withProxyProtocolListener(t, &ProxyProtocolOptions{}, func(l net.Listener, port int) {
+		dialAndWrite(t, port, "PROXY TCP4 203.0.113.9 192.0.2.1 51234 8030\r\nhello\n")
+
+		addr, line, err := acceptAndReadLine(t, keepAliveListener{Listener: l, period: time.Second * 10})
+		require.NoError(t, err)
+		assert.Equal(t, "hello\n", line)
+		assert.Equal(t, "203.0.113.9:51234", addr.String())
+	})
+}
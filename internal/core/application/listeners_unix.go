@@ -0,0 +1,72 @@
+//go:build !windows && !plan9 && !solaris
+// +build !windows,!plan9,!solaris
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func listenReusePort(port int) (net.Listener, error) {
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			if err := c.Control(func(fd uintptr) {
+				sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			}); err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+	return lc.Listen(context.Background(), "tcp", fmt.Sprintf(":%d", port))
+}
+
+// inheritedListeners returns the sockets that were passed to this process by socket activation, keyed
+// by port, following the same protocol as sd_listen_fds(3): LISTEN_PID must be the current process ID,
+// and LISTEN_FDS is the number of consecutive file descriptors starting at startFD.
+func inheritedListeners(startFD int) (map[int]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	// Like sd_listen_fds, unset these so that they don't apply to any child processes
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+
+	ret := make(map[int]net.Listener, count)
+	fail := func(err error) (map[int]net.Listener, error) {
+		for _, l := range ret {
+			_ = l.Close()
+		}
+		return nil, err
+	}
+	for fd := startFD; fd < startFD+count; fd++ {
+		syscall.CloseOnExec(fd)
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		l, err := net.FileListener(f) // this duplicates the file descriptor, so we close the original
+		_ = f.Close()
+		if err != nil {
+			return fail(errInheritedSocket(fd, err))
+		}
+		addr, ok := l.Addr().(*net.TCPAddr)
+		if !ok {
+			_ = l.Close()
+			return fail(errInheritedSocketNotTCP(fd))
+		}
+		ret[addr.Port] = l
+	}
+	return ret, nil
+}
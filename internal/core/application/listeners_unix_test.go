@@ -0,0 +1,192 @@
+//go:build !windows && !plan9 && !solaris
+// +build !windows,!plan9,!solaris
+
+package application
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"github.com/launchdarkly/go-test-helpers/v2/httphelpers"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setSocketActivationEnv(t *testing.T, pid, fdCount int) {
+	for name, value := range map[string]string{
+		"LISTEN_PID": strconv.Itoa(pid),
+		"LISTEN_FDS": strconv.Itoa(fdCount),
+	} {
+		require.NoError(t, os.Setenv(name, value))
+		name := name
+		t.Cleanup(func() { _ = os.Unsetenv(name) })
+	}
+}
+
+func TestNilListenerSourceOpensListener(t *testing.T) {
+	var s *ListenerSource
+	port := st.GetAvailablePort(t)
+	l, err := s.Listen(port)
+	require.NoError(t, err)
+	defer l.Close()
+	assert.Equal(t, port, l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestListenerSourceWithReusePortAllowsSecondListenerOnSamePort(t *testing.T) {
+	s, err := NewListenerSource(true, nil, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	port := st.GetAvailablePort(t)
+
+	l1, err := s.Listen(port)
+	require.NoError(t, err)
+	defer l1.Close()
+	l2, err := s.Listen(port)
+	require.NoError(t, err)
+	defer l2.Close()
+}
+
+func TestListenerSourceWithoutReusePortDoesNotAllowSecondListenerOnSamePort(t *testing.T) {
+	s, err := NewListenerSource(false, nil, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	port := st.GetAvailablePort(t)
+
+	l1, err := s.Listen(port)
+	require.NoError(t, err)
+	defer l1.Close()
+	_, err = s.Listen(port)
+	assert.Error(t, err)
+}
+
+func TestInheritedListenersUsesSocketsFromSocketActivation(t *testing.T) {
+	original, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer original.Close()
+	f, err := original.(*net.TCPListener).File()
+	require.NoError(t, err)
+	// inheritedListeners closes the file descriptor it was given, so give it one that f doesn't own
+	fd, err := syscall.Dup(int(f.Fd()))
+	require.NoError(t, err)
+	_ = f.Close()
+	port := original.Addr().(*net.TCPAddr).Port
+
+	setSocketActivationEnv(t, os.Getpid(), 1)
+	inherited, err := inheritedListeners(fd)
+	require.NoError(t, err)
+	require.Len(t, inherited, 1)
+	l := inherited[port]
+	require.NotNil(t, l)
+	defer l.Close()
+
+	go func() {
+		if conn, err := net.Dial("tcp", original.Addr().String()); err == nil {
+			_ = conn.Close()
+		}
+	}()
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	_ = conn.Close()
+
+	assert.Equal(t, "", os.Getenv("LISTEN_FDS"), "environment variables should have been unset")
+}
+
+func TestInheritedListenersIgnoresSocketsForOtherProcess(t *testing.T) {
+	setSocketActivationEnv(t, os.Getpid()+1, 1)
+	inherited, err := inheritedListeners(listenFDsStart)
+	require.NoError(t, err)
+	assert.Len(t, inherited, 0)
+}
+
+func TestListenerSourceUsesInheritedListenerOnlyOnce(t *testing.T) {
+	original, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer original.Close()
+	port := original.Addr().(*net.TCPAddr).Port
+	s := &ListenerSource{inherited: map[int]net.Listener{port: original}}
+
+	l, err := s.Listen(port)
+	require.NoError(t, err)
+	assert.Equal(t, original, l)
+	_, err = s.Listen(port)
+	assert.Error(t, err) // the port is already in use by the inherited listener
+}
+
+func makeUnixSocketPath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "relay") // t.TempDir can exceed the maximum socket path length
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return filepath.Join(dir, "relay.sock")
+}
+
+func TestListenUnixSetsModeAndRemovesSocketOnClose(t *testing.T) {
+	path := makeUnixSocketPath(t)
+	var s *ListenerSource
+	l, err := s.ListenUnix(path, 0600)
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_ = l.Close()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestListenUnixReplacesStaleSocket(t *testing.T) {
+	path := makeUnixSocketPath(t)
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = l.Close() // leaves the socket file behind, as a process that was killed would
+
+	l, err = (*ListenerSource)(nil).ListenUnix(path, 0)
+	require.NoError(t, err)
+	_ = l.Close()
+}
+
+func TestListenUnixDoesNotReplaceSocketInUse(t *testing.T) {
+	path := makeUnixSocketPath(t)
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer l.Close()
+
+	_, err = (*ListenerSource)(nil).ListenUnix(path, 0)
+	assert.Equal(t, errUnixSocketInUse(path), err)
+}
+
+func TestStartHTTPServerOnUnixSocket(t *testing.T) {
+	path := makeUnixSocketPath(t)
+	mockLog := ldlogtest.NewMockLog()
+	server, errCh := StartHTTPServerOnUnixSocket(nil, path, 0, httphelpers.HandlerWithStatus(http.StatusOK), false, 0, 0,
+		mockLog.Loggers)
+	require.NotNil(t, errCh)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	require.Eventually(t, func() bool {
+		resp, err := client.Get("http://relay/")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, time.Second, time.Millisecond*10)
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "listening on Unix socket "+path)
+}
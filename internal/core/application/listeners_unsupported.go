@@ -0,0 +1,17 @@
+//go:build windows || plan9 || solaris
+// +build windows plan9 solaris
+
+package application
+
+import (
+	"errors"
+	"net"
+)
+
+func listenReusePort(port int) (net.Listener, error) {
+	return nil, errors.New("SO_REUSEPORT is not supported on this platform")
+}
+
+func inheritedListeners(startFD int) (map[int]net.Listener, error) {
+	return nil, nil
+}
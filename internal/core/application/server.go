@@ -1,53 +1,273 @@
 package application
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/launchdarkly/ld-relay/v6/config"
 
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 )
 
+const (
+	grpcMinKeepaliveTime = 10 * time.Second
+	grpcKeepaliveTime    = time.Minute
+	grpcKeepaliveTimeout = 20 * time.Second
+)
+
+type connContextKey struct{}
+
 // StartHTTPServer starts the server, with or without TLS. It returns immediately, starting the server
 // on a separate goroutine; if the server fails to start up, it sends an error to the error channel.
+// The listener for the port is obtained from listeners, which can be nil (see ListenerSource). TLS is
+// enabled if certs is not nil.
+//
+// HTTP/2 is always available if TLS is enabled. If h2cEnabled is true and TLS is not enabled, clients
+// can also use HTTP/2 without TLS ("h2c"); HTTP/1.1 requests are still accepted as usual.
+//
+// If tlsClientCertRequired is true, TLS connections are refused unless the client presents a certificate.
+// The certificate is not verified at this point, since the CAs that are trusted can depend on the
+// environment; the handler must verify it (see clientcert.Policy).
+//
+// If readHeaderTimeout is nonzero, a connection is closed if the client does not finish sending the
+// request headers within that time, so that slow clients cannot tie up connections. If maxHeaderBytes
+// is nonzero, it overrides the net/http default limit on the size of the request headers. If tcpKeepAlive
+// is nonzero, it overrides the default interval between TCP keep-alive probes on accepted connections. The
+// underlying connection of each request is available to handlers via GetConn.
 func StartHTTPServer(
+	listeners *ListenerSource,
 	port int,
 	handler http.Handler,
-	tlsEnabled bool,
-	tlsCertFile, tlsKeyFile string,
+	certs *CertificateSource,
 	tlsMinVersion uint16,
+	tlsClientCertRequired bool,
+	h2cEnabled bool,
+	readHeaderTimeout time.Duration,
+	maxHeaderBytes int,
+	tcpKeepAlive time.Duration,
 	loggers ldlog.Loggers,
 ) (*http.Server, <-chan error) {
-	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: handler,
-	}
+	tlsEnabled := certs != nil
+	srv := makeHTTPServer(fmt.Sprintf(":%d", port), handler, h2cEnabled && !tlsEnabled, readHeaderTimeout, maxHeaderBytes)
 
-	if tlsEnabled && tlsMinVersion != 0 {
-		srv.TLSConfig = &tls.Config{
-			MinVersion: tlsMinVersion,
+	if tlsEnabled {
+		srv.TLSConfig = certs.makeTLSConfig(tlsMinVersion)
+		if tlsClientCertRequired {
+			srv.TLSConfig.ClientAuth = tls.RequireAnyClientCert
 		}
 	}
 
 	errCh := make(chan error)
 
 	go func() {
-		var err error
 		loggers.Infof("Starting server listening on port %d\n", port)
+		listener, err := listeners.Listen(port)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		if tcpKeepAlive > 0 {
+			listener = keepAliveListener{Listener: listener, period: tcpKeepAlive}
+		}
 		if tlsEnabled {
 			message := "TLS enabled for server"
 			if tlsMinVersion != 0 {
 				message += fmt.Sprintf(" (minimum TLS version: %s)", config.NewOptTLSVersion(tlsMinVersion).String())
 			}
+			if tlsClientCertRequired {
+				message += ", client certificates required"
+			}
 			loggers.Info(message)
-			err = srv.ListenAndServeTLS(tlsCertFile, tlsKeyFile)
+			err = srv.ServeTLS(listener, "", "")
 		} else {
-			err = srv.ListenAndServe()
+			if h2cEnabled {
+				loggers.Info("HTTP/2 cleartext (h2c) enabled for server")
+			}
+			err = srv.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			errCh <- err
 		}
+	}()
+
+	return srv, errCh
+}
+
+// StartHTTP3Server starts an experimental HTTP/3 server on a UDP port, which is normally the same port
+// number as the TCP port of a server started by StartHTTPServer. Like StartHTTPServer, it returns
+// immediately, and sends an error to the error channel if the server fails to start up. HTTP/3 always
+// uses TLS 1.3, so certs is required and there is no minimum TLS version option.
+//
+// Clients only try HTTP/3 if they have been told that it is available, so the TCP server's handler
+// should be wrapped with AdvertiseHTTP3. GetConn returns nil for requests that are received over HTTP/3.
+func StartHTTP3Server(
+	port int,
+	handler http.Handler,
+	certs *CertificateSource,
+	tlsClientCertRequired bool,
+	maxHeaderBytes int,
+	loggers ldlog.Loggers,
+) (*http3.Server, <-chan error) {
+	tlsConfig := certs.makeTLSConfig(tls.VersionTLS13)
+	if tlsClientCertRequired {
+		tlsConfig.ClientAuth = tls.RequireAnyClientCert
+	}
+	srv := &http3.Server{
+		Addr:           fmt.Sprintf(":%d", port),
+		Handler:        handler,
+		TLSConfig:      tlsConfig,
+		MaxHeaderBytes: maxHeaderBytes,
+	}
+	errCh := make(chan error, 1)
+
+	go func() {
+		loggers.Infof("Starting experimental HTTP/3 server listening on UDP port %d\n", port)
+		conn, err := net.ListenPacket("udp", srv.Addr)
+		if err == nil {
+			err = srv.Serve(conn)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+
+	return srv, errCh
+}
+
+// AdvertiseHTTP3 adds an Alt-Svc header to every response from handler, telling clients that they can
+// use HTTP/3 on the port of srv. The header is not added until srv has started listening.
+func AdvertiseHTTP3(srv *http3.Server, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = srv.SetQuicHeaders(w.Header())
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// StartHTTPServerOnUnixSocket is like StartHTTPServer, but listens on a Unix domain socket at the specified
+// path instead of a TCP port, for clients on the same host such as an application in the same pod. TLS is
+// not used, since the connections never leave the host. If mode is nonzero, the socket's file permissions
+// are set to it.
+func StartHTTPServerOnUnixSocket(
+	listeners *ListenerSource,
+	path string,
+	mode os.FileMode,
+	handler http.Handler,
+	h2cEnabled bool,
+	readHeaderTimeout time.Duration,
+	maxHeaderBytes int,
+	loggers ldlog.Loggers,
+) (*http.Server, <-chan error) {
+	srv := makeHTTPServer(path, handler, h2cEnabled, readHeaderTimeout, maxHeaderBytes)
+	errCh := make(chan error)
+
+	go func() {
+		loggers.Infof("Starting server listening on Unix socket %s\n", path)
+		listener, err := listeners.ListenUnix(path, mode)
 		if err != nil {
 			errCh <- err
+			return
+		}
+		if h2cEnabled {
+			loggers.Info("HTTP/2 cleartext (h2c) enabled for server")
+		}
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+
+	return srv, errCh
+}
+
+func makeHTTPServer(
+	addr string,
+	handler http.Handler,
+	h2cEnabled bool,
+	readHeaderTimeout time.Duration,
+	maxHeaderBytes int,
+) *http.Server {
+	if h2cEnabled {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connContextKey{}, c)
+		},
+	}
+}
+
+// GetConn returns the network connection that a request was received on, if the request came from a
+// server created by StartHTTPServer; otherwise it returns nil.
+func GetConn(ctx context.Context) net.Conn {
+	if c, ok := ctx.Value(connContextKey{}).(net.Conn); ok {
+		return c
+	}
+	return nil
+}
+
+// StartGRPCServer starts a gRPC server, with or without TLS, and registers the standard gRPC health
+// service along with whatever services are added by registerServices. Like StartHTTPServer, it
+// returns immediately, and sends an error to the error channel if the server fails to start up.
+func StartGRPCServer(
+	listeners *ListenerSource,
+	port int,
+	registerServices func(grpc.ServiceRegistrar),
+	certs *CertificateSource,
+	tlsMinVersion uint16,
+	loggers ldlog.Loggers,
+) (*grpc.Server, <-chan error) {
+	errCh := make(chan error, 1)
+
+	options := []grpc.ServerOption{
+		// Clients may send keepalive pings as often as this, even if they have no active calls.
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             grpcMinKeepaliveTime,
+			PermitWithoutStream: true,
+		}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    grpcKeepaliveTime,
+			Timeout: grpcKeepaliveTimeout,
+		}),
+	}
+	if certs != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(&tls.Config{
+			GetCertificate: certs.getCertificate,
+			MinVersion:     tlsMinVersion,
+		})))
+	}
+
+	srv := grpc.NewServer(options...)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(srv, healthServer)
+	registerServices(srv)
+	for name := range srv.GetServiceInfo() {
+		healthServer.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	go func() {
+		loggers.Infof("Starting gRPC server listening on port %d\n", port)
+		listener, err := listeners.Listen(port)
+		if err == nil {
+			err = srv.Serve(listener)
+		}
+		if err != nil && err != grpc.ErrServerStopped {
+			errCh <- err
 		}
 	}()
 
@@ -1,12 +1,14 @@
 package application
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -15,6 +17,11 @@ import (
 
 	helpers "github.com/launchdarkly/go-test-helpers/v2"
 	"github.com/launchdarkly/go-test-helpers/v2/httphelpers"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
 
@@ -40,10 +47,17 @@ func withSelfSignedCert(t *testing.T, action func(certFilePath, keyFilePath stri
 	})
 }
 
+func mustMakeCertificateFileSource(t *testing.T, certFilePath, keyFilePath string) *CertificateSource {
+	certs, err := NewCertificateFileSource(certFilePath, keyFilePath, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	t.Cleanup(certs.Close)
+	return certs
+}
+
 func TestStartHTTPServerInsecure(t *testing.T) {
 	port := st.GetAvailablePort(t)
 	mockLog := ldlogtest.NewMockLog()
-	server, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(http.StatusOK), false, "", "", 0, mockLog.Loggers)
+	server, errCh := StartHTTPServer(nil, port, httphelpers.HandlerWithStatus(http.StatusOK), nil, 0, false, false, 0, 0, 0, mockLog.Loggers)
 	require.NotNil(t, server)
 	require.NotNil(t, errCh)
 	require.Eventually(t, func() bool {
@@ -55,13 +69,59 @@ func TestStartHTTPServerInsecure(t *testing.T) {
 	mockLog.AssertMessageMatch(t, false, ldlog.Info, "TLS enabled")
 }
 
+func TestStartHTTPServerReadHeaderTimeout(t *testing.T) {
+	port := st.GetAvailablePort(t)
+	mockLog := ldlogtest.NewMockLog()
+	server, _ := StartHTTPServer(nil, port, httphelpers.HandlerWithStatus(http.StatusOK), nil, 0, false, false,
+		time.Millisecond*100, 0, 0, mockLog.Loggers)
+	defer server.Close()
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		var err error
+		conn, err = net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+		return err == nil
+	}, time.Second, time.Millisecond*10)
+	defer conn.Close()
+
+	_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n")) // incomplete headers
+	require.NoError(t, err)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	_, err = ioutil.ReadAll(conn) // returns when the server closes the connection
+	require.NoError(t, err)
+}
+
+func TestGetConn(t *testing.T) {
+	port := st.GetAvailablePort(t)
+	mockLog := ldlogtest.NewMockLog()
+	connCh := make(chan net.Conn, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		connCh <- GetConn(req.Context())
+	})
+	server, _ := StartHTTPServer(nil, port, handler, nil, 0, false, false, 0, 0, 0, mockLog.Loggers)
+	defer server.Close()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d", port))
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err == nil
+	}, time.Second, time.Millisecond*10)
+	conn := <-connCh
+	require.NotNil(t, conn)
+	assert.Equal(t, port, conn.LocalAddr().(*net.TCPAddr).Port)
+
+	assert.Nil(t, GetConn(context.Background()))
+}
+
 func TestStartHTTPServerSecure(t *testing.T) {
 	port := st.GetAvailablePort(t)
 	mockLog := ldlogtest.NewMockLog()
 
 	withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
-		server, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(http.StatusOK),
-			true, certFilePath, keyFilePath, 0, mockLog.Loggers)
+		server, errCh := StartHTTPServer(nil, port, httphelpers.HandlerWithStatus(http.StatusOK),
+			mustMakeCertificateFileSource(t, certFilePath, keyFilePath), 0, false, false, 0, 0, 0, mockLog.Loggers)
 		require.NotNil(t, server)
 		require.NotNil(t, errCh)
 
@@ -86,8 +146,8 @@ func TestStartHTTPServerSecureWithMinTLSVersion(t *testing.T) {
 	mockLog := ldlogtest.NewMockLog()
 
 	withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
-		server, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(http.StatusOK),
-			true, certFilePath, keyFilePath, tls.VersionTLS12, mockLog.Loggers)
+		server, errCh := StartHTTPServer(nil, port, httphelpers.HandlerWithStatus(http.StatusOK),
+			mustMakeCertificateFileSource(t, certFilePath, keyFilePath), tls.VersionTLS12, false, false, 0, 0, 0, mockLog.Loggers)
 		require.NotNil(t, server)
 		require.NotNil(t, errCh)
 
@@ -108,9 +168,100 @@ func TestStartHTTPServerSecureWithMinTLSVersion(t *testing.T) {
 	})
 }
 
+func TestStartHTTPServerSecureWithClientCertRequired(t *testing.T) {
+	port := st.GetAvailablePort(t)
+	mockLog := ldlogtest.NewMockLog()
+
+	withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
+		server, errCh := StartHTTPServer(nil, port, httphelpers.HandlerWithStatus(http.StatusOK),
+			mustMakeCertificateFileSource(t, certFilePath, keyFilePath), 0, true, false, 0, 0, 0, mockLog.Loggers)
+		require.NotNil(t, server)
+		require.NotNil(t, errCh)
+
+		clientWithoutCert := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: certPool},
+		}}
+		require.Eventually(t, func() bool {
+			_, err := clientWithoutCert.Get(fmt.Sprintf("https://127.0.0.1:%d", port))
+			return err != nil && !strings.Contains(err.Error(), "connection refused")
+		}, time.Second, time.Millisecond*10)
+
+		// The server only requires that there is a certificate; verifying it is up to the handler.
+		clientCert, err := tls.LoadX509KeyPair(certFilePath, keyFilePath)
+		require.NoError(t, err)
+		clientWithCert := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: certPool, Certificates: []tls.Certificate{clientCert}},
+		}}
+		resp, err := clientWithCert.Get(fmt.Sprintf("https://127.0.0.1:%d", port))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		mockLog.AssertMessageMatch(t, true, ldlog.Info, "TLS enabled for server, client certificates required")
+	})
+}
+
+func TestStartHTTPServerWithH2C(t *testing.T) {
+	port := st.GetAvailablePort(t)
+	mockLog := ldlogtest.NewMockLog()
+	server, errCh := StartHTTPServer(nil, port, httphelpers.HandlerWithStatus(http.StatusOK), nil, 0, false, true, 0, 0, 0, mockLog.Loggers)
+	require.NotNil(t, server)
+	require.NotNil(t, errCh)
+
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	require.Eventually(t, func() bool {
+		resp, err := h2cClient.Get(fmt.Sprintf("http://localhost:%d", port))
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode == http.StatusOK && resp.ProtoMajor == 2
+	}, time.Second, time.Millisecond*10)
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d", port))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, 1, resp.ProtoMajor) // HTTP/1.1 still works
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "HTTP/2 cleartext \\(h2c\\) enabled for server")
+}
+
+func TestStartHTTP3Server(t *testing.T) {
+	port := st.GetAvailablePort(t)
+	mockLog := ldlogtest.NewMockLog()
+
+	withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
+		server, errCh := StartHTTP3Server(port, httphelpers.HandlerWithStatus(http.StatusOK),
+			mustMakeCertificateFileSource(t, certFilePath, keyFilePath), false, 0, mockLog.Loggers)
+		require.NotNil(t, server)
+		require.NotNil(t, errCh)
+		defer server.Close()
+
+		transport := &http3.RoundTripper{TLSClientConfig: &tls.Config{RootCAs: certPool}}
+		defer transport.Close()
+		client := &http.Client{Transport: transport}
+		require.Eventually(t, func() bool {
+			resp, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d", port))
+			if err != nil {
+				return false
+			}
+			defer resp.Body.Close()
+			return resp.StatusCode == http.StatusOK && resp.ProtoMajor == 3
+		}, time.Second*5, time.Millisecond*50)
+		mockLog.AssertMessageMatch(t, true, ldlog.Info, fmt.Sprintf("HTTP/3 server listening on UDP port %d", port))
+
+		w := httptest.NewRecorder()
+		AdvertiseHTTP3(server, httphelpers.HandlerWithStatus(http.StatusOK)).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		assert.Contains(t, w.Header().Get("Alt-Svc"), fmt.Sprintf(`h3=":%d"`, port))
+	})
+}
+
 func TestStartHTTPServerPortAlreadyUsed(t *testing.T) {
 	st.WithListenerForAnyPort(t, func(l net.Listener, port int) {
-		_, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(200), false, "", "", 0, ldlog.NewDisabledLoggers())
+		_, errCh := StartHTTPServer(nil, port, httphelpers.HandlerWithStatus(200), nil, 0, false, false, 0, 0, 0, ldlog.NewDisabledLoggers())
 		require.NotNil(t, errCh)
 		select {
 		case err := <-errCh:
@@ -120,3 +271,60 @@ func TestStartHTTPServerPortAlreadyUsed(t *testing.T) {
 		}
 	})
 }
+
+func requireGRPCServing(t *testing.T, port int, dialOption grpc.DialOption) {
+	conn, err := grpc.Dial(fmt.Sprintf("127.0.0.1:%d", port), dialOption)
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	require.Eventually(t, func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancel()
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+		return err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestStartGRPCServerInsecure(t *testing.T) {
+	port := st.GetAvailablePort(t)
+	mockLog := ldlogtest.NewMockLog()
+	registered := false
+	server, errCh := StartGRPCServer(nil, port, func(grpc.ServiceRegistrar) { registered = true },
+		nil, 0, mockLog.Loggers)
+	require.NotNil(t, server)
+	require.NotNil(t, errCh)
+	defer server.Stop()
+
+	assert.True(t, registered)
+	requireGRPCServing(t, port, grpc.WithInsecure())
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, fmt.Sprintf("gRPC server listening on port %d", port))
+}
+
+func TestStartGRPCServerSecure(t *testing.T) {
+	port := st.GetAvailablePort(t)
+
+	withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
+		server, errCh := StartGRPCServer(nil, port, func(grpc.ServiceRegistrar) {},
+			mustMakeCertificateFileSource(t, certFilePath, keyFilePath), tls.VersionTLS12, ldlog.NewDisabledLoggers())
+		require.NotNil(t, server)
+		require.NotNil(t, errCh)
+		defer server.Stop()
+
+		creds := credentials.NewTLS(&tls.Config{RootCAs: certPool})
+		requireGRPCServing(t, port, grpc.WithTransportCredentials(creds))
+	})
+}
+
+func TestStartGRPCServerPortAlreadyUsed(t *testing.T) {
+	st.WithListenerForAnyPort(t, func(l net.Listener, port int) {
+		server, errCh := StartGRPCServer(nil, port, func(grpc.ServiceRegistrar) {}, nil, 0, ldlog.NewDisabledLoggers())
+		require.NotNil(t, errCh)
+		defer server.Stop()
+		select {
+		case err := <-errCh:
+			assert.Error(t, err)
+		case <-time.After(time.Second):
+			assert.Fail(t, "timed out waiting for error")
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package application
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+const (
+	systemdReady    = "READY=1\nSTATUS=Serving flag data"
+	systemdStopping = "STOPPING=1\nSTATUS=Shutting down"
+	systemdWatchdog = "WATCHDOG=1"
+
+	// systemdReadinessPollInterval is how often SystemdNotifier checks whether Relay is ready, if the
+	// watchdog is not enabled.
+	systemdReadinessPollInterval = time.Second
+)
+
+// SystemdNotifier reports Relay's state to systemd, if Relay is running as a systemd service with
+// Type=notify, following the same protocol as sd_notify(3).
+//
+// It tells systemd that the service has started only once Relay is ready to serve flag data, so that
+// units that depend on Relay are not started too soon. If the service has WatchdogSec set, it also sends
+// keep-alive notifications at half that interval, each one only after checking Relay's readiness; since
+// that check takes the same locks as request handling does, a Relay process that is deadlocked stops
+// sending them, and systemd restarts it.
+//
+// A nil *SystemdNotifier does nothing.
+type SystemdNotifier struct {
+	socketPath       string
+	watchdogInterval time.Duration
+	loggers          ldlog.Loggers
+	closeOnce        sync.Once
+	closeCh          chan struct{}
+}
+
+// NewSystemdNotifier creates a SystemdNotifier if the NOTIFY_SOCKET environment variable was set by
+// systemd, or returns nil otherwise. Like sd_notify, it unsets the variables that it uses, so that they
+// do not apply to any child processes.
+func NewSystemdNotifier(loggers ldlog.Loggers) *SystemdNotifier {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if socketPath == "" {
+		return nil
+	}
+	n := &SystemdNotifier{
+		socketPath:       socketPath,
+		watchdogInterval: systemdWatchdogInterval(),
+		loggers:          loggers,
+		closeCh:          make(chan struct{}),
+	}
+	_ = os.Unsetenv("NOTIFY_SOCKET")
+	_ = os.Unsetenv("WATCHDOG_USEC")
+	_ = os.Unsetenv("WATCHDOG_PID")
+	if n.watchdogInterval > 0 {
+		loggers.Infof("systemd watchdog enabled (interval: %s)", n.watchdogInterval)
+	}
+	return n
+}
+
+// systemdWatchdogInterval returns the watchdog interval that systemd specified with WATCHDOG_USEC, or
+// zero if the watchdog is not enabled for this process.
+func systemdWatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Start begins checking isReady on a separate goroutine, notifying systemd when it first returns true,
+// and sending watchdog notifications if the watchdog is enabled.
+func (n *SystemdNotifier) Start(isReady func() bool) {
+	if n == nil {
+		return
+	}
+	go n.run(isReady)
+}
+
+func (n *SystemdNotifier) run(isReady func() bool) {
+	interval := systemdReadinessPollInterval
+	if n.watchdogInterval > 0 {
+		interval = n.watchdogInterval / 2
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	notifiedReady := false
+	for {
+		if isReady() && !notifiedReady {
+			notifiedReady = true
+			n.loggers.Info("Notifying systemd that Relay is ready")
+			n.send(systemdReady)
+		}
+		if n.watchdogInterval > 0 {
+			n.send(systemdWatchdog)
+		} else if notifiedReady {
+			return // there is nothing more to do until shutdown
+		}
+		select {
+		case <-n.closeCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stopping tells systemd that Relay is shutting down, and stops any further notifications.
+func (n *SystemdNotifier) Stopping() {
+	if n == nil {
+		return
+	}
+	n.closeOnce.Do(func() {
+		close(n.closeCh)
+		n.send(systemdStopping)
+	})
+}
+
+func (n *SystemdNotifier) send(state string) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: n.socketPath, Net: "unixgram"})
+	if err == nil {
+		_, err = conn.Write([]byte(state))
+		_ = conn.Close()
+	}
+	if err != nil {
+		n.loggers.Warnf("Unable to send notification to systemd: %s", err)
+	}
+}
@@ -0,0 +1,131 @@
+//go:build !windows && !plan9 && !solaris
+// +build !windows,!plan9,!solaris
+
+package application
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setEnvForTest(t *testing.T, vars map[string]string) {
+	for name, value := range vars {
+		require.NoError(t, os.Setenv(name, value))
+		name := name
+		t.Cleanup(func() { _ = os.Unsetenv(name) })
+	}
+}
+
+// listenForSystemdNotifications creates a datagram socket like the one systemd provides, and returns
+// its path along with a channel of the notifications it receives.
+func listenForSystemdNotifications(t *testing.T) (string, <-chan string) {
+	path := makeUnixSocketPath(t)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	ch := make(chan string, 100)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			ch <- string(buf[:n])
+		}
+	}()
+	return path, ch
+}
+
+func expectSystemdNotification(t *testing.T, ch <-chan string, expected string) {
+	select {
+	case s := <-ch:
+		assert.Equal(t, expected, s)
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "timed out waiting for notification", expected)
+	}
+}
+
+func TestNewSystemdNotifierReturnsNilWithoutNotifySocket(t *testing.T) {
+	require.NoError(t, os.Unsetenv("NOTIFY_SOCKET"))
+	n := NewSystemdNotifier(ldlog.NewDisabledLoggers())
+	assert.Nil(t, n)
+	n.Start(func() bool { return true })
+	n.Stopping()
+}
+
+func TestNewSystemdNotifierUsesAndUnsetsEnvironmentVariables(t *testing.T) {
+	setEnvForTest(t, map[string]string{
+		"NOTIFY_SOCKET": "/run/notify",
+		"WATCHDOG_USEC": "30000000",
+		"WATCHDOG_PID":  strconv.Itoa(os.Getpid()),
+	})
+	n := NewSystemdNotifier(ldlog.NewDisabledLoggers())
+	require.NotNil(t, n)
+	assert.Equal(t, "/run/notify", n.socketPath)
+	assert.Equal(t, time.Second*30, n.watchdogInterval)
+	for _, name := range []string{"NOTIFY_SOCKET", "WATCHDOG_USEC", "WATCHDOG_PID"} {
+		assert.Equal(t, "", os.Getenv(name), name)
+	}
+}
+
+func TestSystemdWatchdogIsIgnoredIfItIsForAnotherProcess(t *testing.T) {
+	setEnvForTest(t, map[string]string{
+		"NOTIFY_SOCKET": "/run/notify",
+		"WATCHDOG_USEC": "30000000",
+		"WATCHDOG_PID":  strconv.Itoa(os.Getpid() + 1),
+	})
+	n := NewSystemdNotifier(ldlog.NewDisabledLoggers())
+	require.NotNil(t, n)
+	assert.Equal(t, time.Duration(0), n.watchdogInterval)
+}
+
+func TestSystemdNotifierSendsReadyOnlyWhenReady(t *testing.T) {
+	path, ch := listenForSystemdNotifications(t)
+	setEnvForTest(t, map[string]string{"NOTIFY_SOCKET": path})
+	n := NewSystemdNotifier(ldlog.NewDisabledLoggers())
+	require.NotNil(t, n)
+	defer n.Stopping()
+
+	var ready atomic.Value
+	ready.Store(false)
+	checked := make(chan struct{}, 100)
+	n.Start(func() bool {
+		checked <- struct{}{}
+		return ready.Load().(bool)
+	})
+	<-checked
+	assert.Len(t, ch, 0)
+
+	ready.Store(true)
+	expectSystemdNotification(t, ch, systemdReady)
+}
+
+func TestSystemdNotifierSendsWatchdogNotifications(t *testing.T) {
+	path, ch := listenForSystemdNotifications(t)
+	setEnvForTest(t, map[string]string{"NOTIFY_SOCKET": path, "WATCHDOG_USEC": "20000"})
+	n := NewSystemdNotifier(ldlog.NewDisabledLoggers())
+	require.NotNil(t, n)
+
+	n.Start(func() bool { return true })
+	expectSystemdNotification(t, ch, systemdReady)
+	expectSystemdNotification(t, ch, systemdWatchdog)
+	expectSystemdNotification(t, ch, systemdWatchdog)
+
+	n.Stopping()
+	for s := range ch {
+		if s != systemdWatchdog {
+			assert.Equal(t, systemdStopping, s)
+			break
+		}
+	}
+}
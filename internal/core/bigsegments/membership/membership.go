@@ -0,0 +1,30 @@
+package membership
+
+import (
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+type emptyMembership struct {
+	interfaces.BigSegmentMembership
+}
+
+// FromSegmentRefs creates a user's membership state from the segment references that a store query
+// returned, as ldstoreimpl.NewBigSegmentMembershipFromSegmentRefs does. If there are no references, the
+// result is also marked as empty, so that IsEmpty can recognize it without depending on how the SDK
+// represents memberships.
+func FromSegmentRefs(includedRefs, excludedRefs []string) interfaces.BigSegmentMembership {
+	m := ldstoreimpl.NewBigSegmentMembershipFromSegmentRefs(includedRefs, excludedRefs)
+	if len(includedRefs) == 0 && len(excludedRefs) == 0 {
+		return emptyMembership{m}
+	}
+	return m
+}
+
+// IsEmpty returns true if the membership state was created by FromSegmentRefs with no segment
+// references. It returns false for any other value, such as one from a custom big segment store, so a
+// user is never assumed to be in no big segments unless one of Relay's own stores said so.
+func IsEmpty(m interfaces.BigSegmentMembership) bool {
+	_, ok := m.(emptyMembership)
+	return ok
+}
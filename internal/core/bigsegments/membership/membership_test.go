@@ -0,0 +1,36 @@
+package membership
+
+import (
+	"testing"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromSegmentRefs(t *testing.T) {
+	t.Run("no refs", func(t *testing.T) {
+		m := FromSegmentRefs(nil, []string{})
+		assert.True(t, IsEmpty(m))
+		assert.Equal(t, ldvalue.OptionalBool{}, m.CheckMembership("segment.g1"))
+	})
+
+	t.Run("included and excluded refs", func(t *testing.T) {
+		for _, m := range []interfaces.BigSegmentMembership{
+			FromSegmentRefs([]string{"segment.g1"}, nil),
+			FromSegmentRefs(nil, []string{"segment.g2"}),
+		} {
+			assert.False(t, IsEmpty(m))
+		}
+		m := FromSegmentRefs([]string{"segment.g1"}, []string{"segment.g2"})
+		assert.Equal(t, ldvalue.NewOptionalBool(true), m.CheckMembership("segment.g1"))
+		assert.Equal(t, ldvalue.NewOptionalBool(false), m.CheckMembership("segment.g2"))
+	})
+}
+
+func TestIsEmptyIsFalseForMembershipsFromOtherSources(t *testing.T) {
+	assert.False(t, IsEmpty(nil))
+	assert.False(t, IsEmpty(ldstoreimpl.NewBigSegmentMembershipFromSegmentRefs(nil, nil)))
+}
@@ -0,0 +1,3 @@
+// Package membership creates the big segment membership states that Relay's big segment stores return
+// to the SDK, in a way that also records whether the user is in any big segments at all.
+package membership
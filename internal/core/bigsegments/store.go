@@ -1,32 +1,35 @@
 package bigsegments
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
 	"io"
+	"sync"
 
 	"github.com/launchdarkly/ld-relay/v6/config"
 
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
 
 	"github.com/aws/aws-sdk-go/aws"
 )
 
 // BigSegmentStore is the interface for interacting with an external big segment store. Each instance
-// is specific to one LD environment.
+// is specific to one LD environment. Custom implementations can be provided with
+// RegisterBigSegmentStoreFactory.
 type BigSegmentStore interface {
 	io.Closer
 
-	// The rest of the interface methods are non-exported because they're only relevant within
-	// this package, and no implementations of the interface are created outside of this package.
-
-	// applyPatch is used to apply updates to the store. If successful, it returns (true, nil); if
+	// ApplyPatch is used to apply updates to the store. If successful, it returns (true, nil); if
 	// the patch was not applied because its PreviousVersion did not match the current cursor, it
 	// returns (false, nil); a non-nil second value indicates a database error.
-	applyPatch(patch bigSegmentPatch) (bool, error)
-	// getCursor loads the synchronization cursor from the external store.
-	getCursor() (string, error)
-	// setSynchronizedOn stores the synchronization time in the external store
-	setSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error
+	ApplyPatch(patch BigSegmentPatch) (bool, error)
+	// GetCursor loads the synchronization cursor from the external store.
+	GetCursor() (string, error)
+	// SetSynchronizedOn stores the synchronization time in the external store
+	SetSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error
 	// GetSynchronizedOn returns the synchronization time from the external store.
 	//
 	// The synchronization time may not exist in the store. Use `IsDefined()` to
@@ -34,6 +37,40 @@ type BigSegmentStore interface {
 	GetSynchronizedOn() (ldtime.UnixMillisecondTime, error)
 }
 
+// SDKBigSegmentStoreProvider is an optional interface for a BigSegmentStore that keeps its data within
+// the Relay process rather than in a database. Relay's SDK instances cannot connect to such a store
+// independently, so they must use the factory provided here instead of the one from
+// sdks.ConfigureBigSegments.
+type SDKBigSegmentStoreProvider interface {
+	SDKBigSegmentStoreFactory() interfaces.BigSegmentStoreFactory
+}
+
+// BigSegmentMembershipCounter is an optional interface for a BigSegmentStore that can report how much
+// membership data it contains. This is only used for diagnostic purposes, so the counts may be estimates.
+type BigSegmentMembershipCounter interface {
+	GetMembershipCounts() (BigSegmentMembershipCounts, error)
+}
+
+// BigSegmentMembershipCounts is returned by BigSegmentMembershipCounter.
+type BigSegmentMembershipCounts struct {
+	// Users is the number of users who are included in or excluded from at least one big segment.
+	Users int
+	// Included is the total number of big segment inclusions, counting each user once per segment.
+	Included int
+	// Excluded is the total number of big segment exclusions, counting each user once per segment.
+	Excluded int
+}
+
+// BigSegmentMembershipExporter is an optional interface for a BigSegmentStore that can list all of its
+// membership data. This is used by the migrate-store command to copy big segments to another store.
+type BigSegmentMembershipExporter interface {
+	// ExportMemberships calls action for each user hash that is included in or excluded from any big
+	// segments, with the segment references. It may call action more than once for the same user hash,
+	// in which case the memberships should be combined. If action returns an error, ExportMemberships
+	// stops and returns that error.
+	ExportMemberships(action func(userHash string, included, excluded []string) error) error
+}
+
 // BigSegmentStoreFactory creates an implementation of BigSegmentStore, if the configuration
 // implies that we should have one; if not, it returns nil.
 type BigSegmentStoreFactory func(
@@ -42,6 +79,41 @@ type BigSegmentStoreFactory func(
 	loggers ldlog.Loggers,
 ) (BigSegmentStore, error)
 
+var (
+	registeredStoreFactories     = make(map[string]BigSegmentStoreFactory) //nolint:gochecknoglobals
+	registeredStoreFactoriesLock sync.RWMutex                              //nolint:gochecknoglobals
+)
+
+func errUnknownBigSegmentStoreType(storeType string) error {
+	return fmt.Errorf("no big segment store implementation has been registered for type %q", storeType)
+}
+
+// RegisterBigSegmentStoreFactory adds a custom BigSegmentStore implementation that will be used for
+// any environment when the BigSegments.Type configuration property is equal to storeType. This is the
+// Relay side of a custom big segment backend, which is responsible for writing the data that Relay
+// receives from LaunchDarkly; the same storeType must also be registered with
+// sdks.RegisterBigSegmentStoreProvider so that Relay's SDK instances can read the data. Application
+// code does this with relay.RegisterBigSegmentStore.
+//
+// Like eventtransform.Register, it panics if factory is nil or if storeType was already registered.
+func RegisterBigSegmentStoreFactory(storeType string, factory BigSegmentStoreFactory) {
+	registeredStoreFactoriesLock.Lock()
+	defer registeredStoreFactoriesLock.Unlock()
+	if factory == nil {
+		panic("bigsegments: RegisterBigSegmentStoreFactory factory is nil")
+	}
+	if _, exists := registeredStoreFactories[storeType]; exists {
+		panic("bigsegments: RegisterBigSegmentStoreFactory called twice for store type " + storeType)
+	}
+	registeredStoreFactories[storeType] = factory
+}
+
+func getRegisteredBigSegmentStoreFactory(storeType string) BigSegmentStoreFactory {
+	registeredStoreFactoriesLock.RLock()
+	defer registeredStoreFactoriesLock.RUnlock()
+	return registeredStoreFactories[storeType]
+}
+
 // DefaultBigSegmentStoreFactory implements our standard logic for optionally creating a
 // BigSegmentStore.
 func DefaultBigSegmentStoreFactory(
@@ -49,22 +121,39 @@ func DefaultBigSegmentStoreFactory(
 	allConfig config.Config,
 	loggers ldlog.Loggers,
 ) (BigSegmentStore, error) {
-	// Currently, the only supported store type is Redis, and if Redis is enabled then big segments
-	// are enabled.
+	// If a store type was specified explicitly, it must be either one of the built-in memory or S3
+	// stores, or one that was registered with RegisterBigSegmentStoreFactory. Otherwise, big segments
+	// are enabled if a database that supports them is enabled.
+	if storeType := allConfig.BigSegments.Type; storeType == config.BigSegmentsStoreTypeMemory {
+		return newMemoryBigSegmentStore(allConfig.BigSegments, envConfig, loggers)
+	} else if storeType == config.BigSegmentsStoreTypeS3 {
+		return newS3BigSegmentStore(allConfig.BigSegments, envConfig, aws.Config{}, loggers)
+	} else if storeType != "" {
+		factory := getRegisteredBigSegmentStoreFactory(storeType)
+		if factory == nil {
+			return nil, errUnknownBigSegmentStoreType(storeType)
+		}
+		return factory(envConfig, allConfig, loggers)
+	}
 	if allConfig.Redis.URL.IsDefined() {
 		bigSegmentRedis, err := newRedisBigSegmentStore(allConfig.Redis, envConfig, false, loggers)
 		if err != nil {
 			return nil, err
 		}
 		return bigSegmentRedis, nil
+	} else if allConfig.Consul.Host != "" {
+		return newConsulBigSegmentStore(allConfig.Consul, envConfig, loggers)
 	} else if allConfig.DynamoDB.Enabled {
 		return newDynamoDBBigSegmentStore(allConfig.DynamoDB, envConfig, aws.Config{}, loggers)
+	} else if allConfig.Postgres.URL.IsDefined() {
+		return newPostgresBigSegmentStore(allConfig.Postgres, envConfig, loggers)
+	} else if allConfig.MongoDB.URL.IsDefined() {
+		return newMongoDBBigSegmentStore(allConfig.MongoDB, envConfig, loggers)
 	}
 	return nil, nil
 }
 
-// NewNullBigSegmentStore returns a no-op stub implementation. This is used only in tests, but it is
-// exported from this package so that we can keep the interface methods private.
+// NewNullBigSegmentStore returns a no-op stub implementation. This is used only in tests.
 func NewNullBigSegmentStore() BigSegmentStore {
 	return &nullBigSegmentStore{}
 }
@@ -73,14 +162,20 @@ type nullBigSegmentStore struct{}
 
 func (s *nullBigSegmentStore) Close() error { return nil }
 
-func (s *nullBigSegmentStore) applyPatch(patch bigSegmentPatch) (bool, error) { return false, nil }
+func (s *nullBigSegmentStore) ApplyPatch(patch BigSegmentPatch) (bool, error) { return false, nil }
 
-func (s *nullBigSegmentStore) getCursor() (string, error) { return "", nil }
+func (s *nullBigSegmentStore) GetCursor() (string, error) { return "", nil }
 
-func (s *nullBigSegmentStore) setSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
+func (s *nullBigSegmentStore) SetSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
 	return nil
 }
 
 func (s *nullBigSegmentStore) GetSynchronizedOn() (ldtime.UnixMillisecondTime, error) {
 	return 0, nil
 }
+
+// HashForUserKey computes the same user hash that the SDK uses when it queries a big segment store.
+func HashForUserKey(userKey string) string {
+	hash := sha256.Sum256([]byte(userKey))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
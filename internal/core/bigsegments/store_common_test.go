@@ -1,8 +1,7 @@
-// +build big_segment_external_store_tests
-
 package bigsegments
 
 import (
+	"sort"
 	"strconv"
 	"testing"
 
@@ -33,7 +32,7 @@ func testGenericAll(
 			assert.False(t, sync1.IsDefined())
 
 			now := ldtime.UnixMillisNow()
-			err = store.setSynchronizedOn(now)
+			err = store.SetSynchronizedOn(now)
 			require.NoError(t, err)
 
 			sync2, err := store.GetSynchronizedOn()
@@ -46,14 +45,14 @@ func testGenericAll(
 		withBigSegmentStore(t, func(store BigSegmentStore, operations bigSegmentOperations) {
 			// first set synchronizedOn, so we can verify that applying a patch does *not* change that value
 			initialSyncTime := ldtime.UnixMillisecondTime(99999)
-			require.NoError(t, store.setSynchronizedOn(initialSyncTime))
+			require.NoError(t, store.SetSynchronizedOn(initialSyncTime))
 
 			// apply initial patch that adds users
-			success, err := store.applyPatch(patch1)
+			success, err := store.ApplyPatch(patch1)
 			require.NoError(t, err)
 			require.True(t, success)
 
-			cursor, err := store.getCursor()
+			cursor, err := store.GetCursor()
 			require.NoError(t, err)
 			require.Equal(t, patch1.Version, cursor)
 
@@ -66,11 +65,11 @@ func testGenericAll(
 			assert.Equal(t, true, membership)
 
 			// apply second patch in sequence that removes users
-			success, err = store.applyPatch(patch2)
+			success, err = store.ApplyPatch(patch2)
 			require.NoError(t, err)
 			require.True(t, success)
 
-			cursor, err = store.getCursor()
+			cursor, err = store.GetCursor()
 			require.NoError(t, err)
 			assert.Equal(t, patch2.Version, cursor)
 
@@ -83,12 +82,12 @@ func testGenericAll(
 			assert.Equal(t, false, membership)
 
 			// apply old patch
-			success, err = store.applyPatch(patch1)
+			success, err = store.ApplyPatch(patch1)
 			require.NoError(t, err)
 			require.False(t, success)
 
 			// verify that the stored cursor was updated
-			cursor, err = store.getCursor()
+			cursor, err = store.GetCursor()
 			require.NoError(t, err)
 			assert.Equal(t, patch2.Version, cursor)
 
@@ -99,11 +98,11 @@ func testGenericAll(
 
 			// now update the sync time and verify that that doesn't affect the cursor
 			newSyncTime := initialSyncTime + 1
-			require.NoError(t, store.setSynchronizedOn(newSyncTime))
+			require.NoError(t, store.SetSynchronizedOn(newSyncTime))
 			syncTime, err = store.GetSynchronizedOn()
 			require.NoError(t, err)
 			assert.Equal(t, newSyncTime, syncTime)
-			cursor, err = store.getCursor()
+			cursor, err = store.GetCursor()
 			require.NoError(t, err)
 			assert.Equal(t, patch2.Version, cursor)
 		})
@@ -117,19 +116,19 @@ func testGenericAll(
 				users = append(users, strconv.FormatUint(uint64(i), 10))
 			}
 
-			patch := bigSegmentPatch{
+			patch := BigSegmentPatch{
 				EnvironmentID:   "abc",
 				SegmentID:       "segment.g1",
 				Version:         "1",
 				PreviousVersion: "",
-				Changes: bigSegmentPatchChanges{
-					Included: bigSegmentPatchChangesMutations{
+				Changes: BigSegmentPatchChanges{
+					Included: BigSegmentPatchChangesMutations{
 						Add: users,
 					},
 				},
 			}
 
-			success, err := store.applyPatch(patch)
+			success, err := store.ApplyPatch(patch)
 			require.NoError(t, err)
 			require.True(t, success)
 
@@ -137,9 +136,62 @@ func testGenericAll(
 			assert.Equal(t, true, membership)
 			require.NoError(t, err)
 
-			cursor, err := store.getCursor()
+			cursor, err := store.GetCursor()
 			require.NoError(t, err)
 			assert.Equal(t, patch.Version, cursor)
 		})
 	})
+
+	t.Run("membershipCounts", func(t *testing.T) {
+		withBigSegmentStore(t, func(store BigSegmentStore, operations bigSegmentOperations) {
+			counter, ok := store.(BigSegmentMembershipCounter)
+			if !ok {
+				t.Skip("store does not implement BigSegmentMembershipCounter")
+			}
+			patch3 := newPatchBuilder("segment.g2", "3", "2").addIncludes("included2", "excluded2").build()
+			for _, patch := range []BigSegmentPatch{patch1, patch2, patch3} {
+				success, err := store.ApplyPatch(patch)
+				require.NoError(t, err)
+				require.True(t, success)
+			}
+
+			counts, err := counter.GetMembershipCounts()
+			require.NoError(t, err)
+			assert.Equal(t, BigSegmentMembershipCounts{Users: 2, Included: 3, Excluded: 1}, counts)
+		})
+	})
+
+	t.Run("exportMemberships", func(t *testing.T) {
+		withBigSegmentStore(t, func(store BigSegmentStore, operations bigSegmentOperations) {
+			exporter, ok := store.(BigSegmentMembershipExporter)
+			if !ok {
+				t.Skip("store does not implement BigSegmentMembershipExporter")
+			}
+			patch3 := newPatchBuilder("segment.g2", "3", "2").addIncludes("included2", "excluded2").build()
+			for _, patch := range []BigSegmentPatch{patch1, patch2, patch3} {
+				success, err := store.ApplyPatch(patch)
+				require.NoError(t, err)
+				require.True(t, success)
+			}
+
+			memberships := make(map[string][]string)
+			err := exporter.ExportMemberships(func(userHash string, included, excluded []string) error {
+				for _, ref := range included {
+					memberships[userHash] = append(memberships[userHash], "+"+ref)
+				}
+				for _, ref := range excluded {
+					memberships[userHash] = append(memberships[userHash], "-"+ref)
+				}
+				return nil
+			})
+			require.NoError(t, err)
+			for _, refs := range memberships {
+				sort.Strings(refs)
+			}
+			assert.Equal(t, map[string][]string{
+				"included2": {"+segment.g1", "+segment.g2"},
+				"excluded2": {"+segment.g2", "-segment.g1"},
+			}, memberships)
+		})
+	})
 }
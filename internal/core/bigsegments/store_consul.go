@@ -0,0 +1,244 @@
+package bigsegments
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+
+	consul "github.com/hashicorp/consul/api"
+)
+
+// This is the default limit on the number of operations in a Consul transaction.
+const consulTransactionMaxOps = 64
+
+func consulCursorKey(prefix string) string {
+	return prefix + "/big_segments_cursor"
+}
+
+// consulBigSegmentStore implements BigSegmentStore for Consul.
+type consulBigSegmentStore struct {
+	client  *consul.Client
+	prefix  string
+	loggers ldlog.Loggers
+	txn     func(consul.TxnOps) (bool, error) // runs a transaction; replaced in tests
+}
+
+// newConsulBigSegmentStore creates an instance of consulBigSegmentStore.
+func newConsulBigSegmentStore(
+	consulConfig config.ConsulConfig,
+	envConfig config.EnvConfig,
+	loggers ldlog.Loggers,
+) (*consulBigSegmentStore, error) {
+	clientConfig, prefix := sdks.GetConsulBasicProperties(consulConfig, envConfig)
+
+	client, err := consul.NewClient(&clientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	store := consulBigSegmentStore{
+		client:  client,
+		prefix:  prefix,
+		loggers: loggers,
+		txn: func(ops consul.TxnOps) (bool, error) {
+			ok, _, _, err := client.Txn().Txn(ops, nil)
+			return ok, err
+		},
+	}
+
+	store.loggers.SetPrefix("ConsulBigSegmentStore:")
+
+	return &store, nil
+}
+
+// ApplyPatch is used to apply updates to the store.
+//
+// Consul has no set data type, so each user's membership is read, modified, and written back with a
+// check-and-set operation. The writes are done in transactions that also verify that the cursor has
+// not changed since we read it; the cursor itself is updated in the last transaction.
+//
+// A transaction can only have 64 operations, so a large patch is not applied atomically. If one of
+// the transactions after the first fails, the memberships in the earlier ones have been written but
+// the cursor still has its previous version, so the synchronizer gets the same patch again. Applying
+// it again is safe: each membership is computed from its current value, and memberships that already
+// have the patch's changes are not written again.
+func (c *consulBigSegmentStore) ApplyPatch(patch BigSegmentPatch) (bool, error) {
+	kv := c.client.KV()
+
+	cursorPair, _, err := kv.Get(consulCursorKey(c.prefix), nil)
+	if err != nil {
+		return false, err
+	}
+	var cursor string
+	var cursorIndex uint64
+	if cursorPair != nil {
+		cursor = string(cursorPair.Value)
+		cursorIndex = cursorPair.ModifyIndex
+	}
+	if cursor != patch.PreviousVersion {
+		return false, nil
+	}
+
+	changes := make(map[string]*consulMembershipChange)
+	addChanges := func(userKeys []string, keyFn func(string, string) string, add bool) {
+		for _, userKey := range userKeys {
+			key := keyFn(c.prefix, userKey)
+			change := changes[key]
+			if change == nil {
+				change = &consulMembershipChange{}
+				changes[key] = change
+			}
+			if add {
+				change.add = append(change.add, patch.SegmentID)
+			} else {
+				change.remove = append(change.remove, patch.SegmentID)
+			}
+		}
+	}
+	addChanges(patch.Changes.Included.Add, sdks.ConsulBigSegmentsIncludeKey, true)
+	addChanges(patch.Changes.Included.Remove, sdks.ConsulBigSegmentsIncludeKey, false)
+	addChanges(patch.Changes.Excluded.Add, sdks.ConsulBigSegmentsExcludeKey, true)
+	addChanges(patch.Changes.Excluded.Remove, sdks.ConsulBigSegmentsExcludeKey, false)
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ops := make(consul.TxnOps, 0, len(keys))
+	for _, key := range keys {
+		refs, index, err := sdks.ReadConsulBigSegmentRefs(kv, key)
+		if err != nil {
+			return false, err
+		}
+		op, err := changes[key].makeOp(key, refs, index)
+		if err != nil {
+			return false, err
+		}
+		if op != nil {
+			ops = append(ops, op)
+		}
+	}
+
+	cursorCheck := &consul.TxnOp{KV: &consul.KVTxnOp{Verb: consul.KVCheckNotExists, Key: consulCursorKey(c.prefix)}}
+	if cursorPair != nil {
+		cursorCheck.KV.Verb = consul.KVCheckIndex
+		cursorCheck.KV.Index = cursorIndex
+	}
+	cursorUpdate := &consul.TxnOp{KV: &consul.KVTxnOp{
+		Verb:  consul.KVCAS,
+		Key:   consulCursorKey(c.prefix),
+		Value: []byte(patch.Version),
+		Index: cursorIndex,
+	}}
+
+	for {
+		// the cursor is only written by the last transaction, once all of the others have succeeded
+		var batch consul.TxnOps
+		if len(ops) < consulTransactionMaxOps {
+			batch = append(append(batch, ops...), cursorUpdate)
+			ops = nil
+		} else {
+			batch = append(consul.TxnOps{cursorCheck}, ops[:consulTransactionMaxOps-1]...)
+			ops = ops[consulTransactionMaxOps-1:]
+		}
+		ok, err := c.txn(batch)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			// The transaction was rolled back because the cursor or one of the memberships was
+			// modified concurrently.
+			return false, nil
+		}
+		if ops == nil {
+			return true, nil
+		}
+	}
+}
+
+type consulMembershipChange struct {
+	add    []string
+	remove []string
+}
+
+func (m *consulMembershipChange) makeOp(key string, refs []string, index uint64) (*consul.TxnOp, error) {
+	set := make(map[string]struct{}, len(refs)+len(m.add))
+	for _, ref := range refs {
+		set[ref] = struct{}{}
+	}
+	changed := false
+	for _, ref := range m.add {
+		if _, ok := set[ref]; !ok {
+			set[ref] = struct{}{}
+			changed = true
+		}
+	}
+	for _, ref := range m.remove {
+		if _, ok := set[ref]; ok {
+			delete(set, ref)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, nil // the membership already has these changes, for instance if a patch is retried
+	}
+
+	if len(set) == 0 {
+		if index == 0 {
+			return nil, nil
+		}
+		return &consul.TxnOp{KV: &consul.KVTxnOp{Verb: consul.KVDeleteCAS, Key: key, Index: index}}, nil
+	}
+
+	newRefs := make([]string, 0, len(set))
+	for ref := range set {
+		newRefs = append(newRefs, ref)
+	}
+	sort.Strings(newRefs)
+	data, err := json.Marshal(newRefs)
+	if err != nil {
+		return nil, err
+	}
+	return &consul.TxnOp{KV: &consul.KVTxnOp{Verb: consul.KVCAS, Key: key, Value: data, Index: index}}, nil
+}
+
+func (c *consulBigSegmentStore) GetCursor() (string, error) {
+	pair, _, err := c.client.KV().Get(consulCursorKey(c.prefix), nil)
+	if err != nil || pair == nil {
+		return "", err
+	}
+	return string(pair.Value), nil
+}
+
+func (c *consulBigSegmentStore) SetSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
+	unixMilliseconds := strconv.FormatUint(uint64(synchronizedOn), 10)
+	_, err := c.client.KV().Put(&consul.KVPair{
+		Key:   sdks.ConsulBigSegmentsSyncTimeKey(c.prefix),
+		Value: []byte(unixMilliseconds),
+	}, nil)
+	return err
+}
+
+func (c *consulBigSegmentStore) GetSynchronizedOn() (ldtime.UnixMillisecondTime, error) {
+	pair, _, err := c.client.KV().Get(sdks.ConsulBigSegmentsSyncTimeKey(c.prefix), nil)
+	if err != nil || pair == nil {
+		return 0, err
+	}
+	milliseconds, err := strconv.ParseUint(string(pair.Value), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return ldtime.UnixMillisecondTime(milliseconds), nil
+}
+
+func (c *consulBigSegmentStore) Close() error {
+	return nil
+}
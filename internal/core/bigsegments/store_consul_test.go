@@ -0,0 +1,110 @@
+//go:build big_segment_external_store_tests
+// +build big_segment_external_store_tests
+
+package bigsegments
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	consul "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsulGenericAll(t *testing.T) {
+	testGenericAll(t, withConsulStoreGeneric)
+}
+
+func TestConsulPatchThatFailsPartwayCanBeRetried(t *testing.T) {
+	withConsulStoreGeneric(t, func(s BigSegmentStore, operations bigSegmentOperations) {
+		store := s.(*consulBigSegmentStore)
+		userCount := consulTransactionMaxOps * 3 / 2 // so the patch needs two transactions
+		users := make([]string, 0, userCount)
+		for i := 0; i < userCount; i++ {
+			users = append(users, strconv.Itoa(i))
+		}
+		patch := newPatchBuilder("segment.g1", "1", "").addIncludes(users...).build()
+		countIncluded := func() int {
+			n := 0
+			for _, user := range users {
+				included, err := operations.isUserIncluded(patch.SegmentID, user)
+				require.NoError(t, err)
+				if included {
+					n++
+				}
+			}
+			return n
+		}
+
+		realTxn := store.txn
+		txnCount := 0
+		fakeError := errors.New("sorry")
+		store.txn = func(ops consul.TxnOps) (bool, error) {
+			txnCount++
+			if txnCount > 1 {
+				return false, fakeError
+			}
+			return realTxn(ops)
+		}
+		_, err := store.ApplyPatch(patch)
+		assert.Equal(t, fakeError, err)
+
+		cursor, err := store.GetCursor()
+		require.NoError(t, err)
+		assert.Equal(t, "", cursor)
+		assert.Equal(t, consulTransactionMaxOps-1, countIncluded()) // the first transaction was applied
+
+		store.txn = realTxn
+		success, err := store.ApplyPatch(patch)
+		require.NoError(t, err)
+		require.True(t, success)
+
+		cursor, err = store.GetCursor()
+		require.NoError(t, err)
+		assert.Equal(t, patch.Version, cursor)
+		assert.Equal(t, userCount, countIncluded())
+	})
+}
+
+func withConsulStoreGeneric(t *testing.T, action func(BigSegmentStore, bigSegmentOperations)) {
+	store, err := newConsulBigSegmentStore(
+		config.ConsulConfig{Host: "localhost:8500"},
+		config.EnvConfig{Prefix: testPrefix},
+		ldlog.NewDisabledLoggers(),
+	)
+	require.NoError(t, err)
+	_, err = store.client.KV().DeleteTree(testPrefix+"/", nil)
+	require.NoError(t, err)
+	defer store.Close()
+	action(store, consulMakeOperations(store))
+}
+
+func consulMakeOperations(store *consulBigSegmentStore) bigSegmentOperations {
+	isMember := func(key, segmentKey string) (bool, error) {
+		refs, _, err := sdks.ReadConsulBigSegmentRefs(store.client.KV(), key)
+		if err != nil {
+			return false, err
+		}
+		for _, ref := range refs {
+			if ref == segmentKey {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return bigSegmentOperations{
+		isUserIncluded: func(segmentKey string, userKey string) (bool, error) {
+			return isMember(sdks.ConsulBigSegmentsIncludeKey(testPrefix, userKey), segmentKey)
+		},
+		isUserExcluded: func(segmentKey string, userKey string) (bool, error) {
+			return isMember(sdks.ConsulBigSegmentsExcludeKey(testPrefix, userKey), segmentKey)
+		},
+	}
+}
@@ -60,17 +60,30 @@ func newDynamoDBBigSegmentStore(
 	if endpoint != nil {
 		config.Endpoint = endpoint
 	}
+	if creds := sdks.GetDynamoDBCredentials(dbConfig); creds != nil {
+		config.Credentials = creds
+	}
+	if httpClient := sdks.GetDynamoDBHTTPClient(dbConfig); httpClient != nil {
+		config.HTTPClient = httpClient
+	}
 
 	sess, err := session.NewSession(&config)
 	if err != nil {
 		return nil, err
 	}
 
+	// If there is a DAX endpoint, the writes go through DAX too, so that it does not keep serving
+	// memberships from its item cache after they have changed.
+	client, err := sdks.NewDynamoDBClient(dbConfig, sess)
+	if err != nil {
+		return nil, err
+	}
+
 	store := dynamoDBBigSegmentStore{
 		table:   table,
 		loggers: loggers,
 		prefix:  prefix,
-		client:  dynamodb.New(sess),
+		client:  client,
 	}
 
 	store.loggers.SetPrefix("DynamoDBBigSegmentStore:")
@@ -108,7 +121,7 @@ func makeCursorUpdateCondition(previousVersion string) (string, map[string]*stri
 	}
 }
 
-func (store *dynamoDBBigSegmentStore) applyPatch(patch bigSegmentPatch) (bool, error) {
+func (store *dynamoDBBigSegmentStore) ApplyPatch(patch BigSegmentPatch) (bool, error) {
 	bigSegmentsMetadataKeyWithPrefix := dynamoDBMetadataKey(store.prefix)
 
 	txConditionExpression, txExprAttrNames, txExprAttrValues := makeCursorUpdateCondition(patch.PreviousVersion)
@@ -206,7 +219,7 @@ func (store *dynamoDBBigSegmentStore) applyPatch(patch bigSegmentPatch) (bool, e
 	return false, err
 }
 
-func (store *dynamoDBBigSegmentStore) getCursor() (string, error) {
+func (store *dynamoDBBigSegmentStore) GetCursor() (string, error) {
 	metadataKey := dynamoDBMetadataKey(store.prefix)
 	result, err := store.client.GetItem(&dynamodb.GetItemInput{
 		TableName:      aws.String(store.table),
@@ -230,7 +243,7 @@ func (store *dynamoDBBigSegmentStore) getCursor() (string, error) {
 	return *item.S, nil
 }
 
-func (store *dynamoDBBigSegmentStore) setSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
+func (store *dynamoDBBigSegmentStore) SetSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
 	bigSegmentsMetadataKeyWithPrefix := dynamoDBMetadataKey(store.prefix)
 	unixMilliseconds := strconv.FormatUint(uint64(synchronizedOn), 10)
 	_, err := store.client.UpdateItem(&dynamodb.UpdateItemInput{
@@ -275,6 +288,42 @@ func (store *dynamoDBBigSegmentStore) GetSynchronizedOn() (ldtime.UnixMillisecon
 	return ldtime.UnixMillisecondTime(value), nil
 }
 
+// ExportMemberships queries all of the user items for this prefix, and calls action once for each one.
+func (store *dynamoDBBigSegmentStore) ExportMemberships(action func(userHash string, included, excluded []string) error) error {
+	var actionErr error
+	err := store.client.QueryPages(&dynamodb.QueryInput{
+		TableName:              aws.String(store.table),
+		ConsistentRead:         aws.Bool(true),
+		KeyConditionExpression: aws.String("#0 = :0"),
+		ExpressionAttributeNames: map[string]*string{
+			"#0": aws.String(tablePartitionKey),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":0": {S: aws.String(dynamoDBUserDataKey(store.prefix))},
+		},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			actionErr = action(aws.StringValue(item[tableSortKey].S),
+				dynamoDBStringSet(item[dynamoDBIncludedAttr]), dynamoDBStringSet(item[dynamoDBExcludedAttr]))
+			if actionErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return actionErr
+}
+
+func dynamoDBStringSet(value *dynamodb.AttributeValue) []string {
+	if value == nil {
+		return nil
+	}
+	return aws.StringValueSlice(value.SS)
+}
+
 func (store *dynamoDBBigSegmentStore) Close() error {
 	return nil
 }
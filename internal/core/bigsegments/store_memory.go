@@ -0,0 +1,401 @@
+package bigsegments
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments/membership"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+)
+
+// memoryBigSegmentStore implements BigSegmentStore by keeping all membership data in memory. If a
+// snapshot directory is configured, the data is periodically written to a file there, and is restored
+// from that file when the store is created.
+//
+// Since there is no database that other processes could read, Relay's SDK instances read the data
+// from the same object; see SDKBigSegmentStoreFactory.
+type memoryBigSegmentStore struct {
+	cursor         string
+	synchronizedOn ldtime.UnixMillisecondTime
+	included       map[string]map[string]struct{} // user hash -> segment references
+	excluded       map[string]map[string]struct{}
+	dirty          bool
+	lock           sync.RWMutex
+
+	snapshotPath string
+	closeCh      chan struct{}
+	doneCh       chan struct{}
+	closeOnce    sync.Once
+	loggers      ldlog.Loggers
+}
+
+// memoryBigSegmentSnapshot is the JSON representation of the snapshot file.
+type memoryBigSegmentSnapshot struct {
+	Cursor         string                     `json:"cursor"`
+	SynchronizedOn ldtime.UnixMillisecondTime `json:"synchronizedOn"`
+	Included       map[string][]string        `json:"included"`
+	Excluded       map[string][]string        `json:"excluded"`
+}
+
+// newMemoryBigSegmentStore creates an instance of memoryBigSegmentStore.
+func newMemoryBigSegmentStore(
+	bigSegmentsConfig config.BigSegmentsConfig,
+	envConfig config.EnvConfig,
+	loggers ldlog.Loggers,
+) (*memoryBigSegmentStore, error) {
+	store := memoryBigSegmentStore{
+		included: make(map[string]map[string]struct{}),
+		excluded: make(map[string]map[string]struct{}),
+		loggers:  loggers,
+	}
+
+	store.loggers.SetPrefix("MemoryBigSegmentStore:")
+
+	if bigSegmentsConfig.SnapshotDir == "" {
+		return &store, nil
+	}
+
+	if err := os.MkdirAll(bigSegmentsConfig.SnapshotDir, 0o700); err != nil {
+		return nil, err
+	}
+	store.snapshotPath = filepath.Join(bigSegmentsConfig.SnapshotDir, memorySnapshotFileName(envConfig))
+	store.restoreSnapshot()
+
+	store.closeCh = make(chan struct{})
+	store.doneCh = make(chan struct{})
+	go store.runSnapshots(bigSegmentsConfig.SnapshotInterval.GetOrElse(config.DefaultBigSegmentsSnapshotInterval))
+
+	return &store, nil
+}
+
+// memorySnapshotFileName returns the name of the snapshot file for an environment. We use the
+// environment ID if it is known; otherwise we use a hash of the SDK key, since the key itself
+// should not appear on disk.
+func memorySnapshotFileName(envConfig config.EnvConfig) string {
+	name := string(envConfig.EnvID)
+	if name == "" {
+		hash := sha256.Sum256([]byte(envConfig.SDKKey))
+		name = hex.EncodeToString(hash[:8])
+	}
+	return "big-segments-" + name + ".json"
+}
+
+// ApplyPatch is used to apply updates to the store.
+func (m *memoryBigSegmentStore) ApplyPatch(patch BigSegmentPatch) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.cursor != patch.PreviousVersion {
+		return false, nil
+	}
+
+	addRefs(m.included, patch.Changes.Included.Add, patch.SegmentID)
+	removeRefs(m.included, patch.Changes.Included.Remove, patch.SegmentID)
+	addRefs(m.excluded, patch.Changes.Excluded.Add, patch.SegmentID)
+	removeRefs(m.excluded, patch.Changes.Excluded.Remove, patch.SegmentID)
+	m.cursor = patch.Version
+	m.dirty = true
+
+	return true, nil
+}
+
+// replaceSegment replaces all of the membership data for one segment. This is used by stores whose
+// data does not come from LaunchDarkly, so there is no cursor to check.
+func (m *memoryBigSegmentStore) replaceSegment(segmentRef string, included, excluded []string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	removeAllRefs(m.included, segmentRef)
+	removeAllRefs(m.excluded, segmentRef)
+	addRefs(m.included, included, segmentRef)
+	addRefs(m.excluded, excluded, segmentRef)
+	m.dirty = true
+}
+
+// removeSegment deletes all of the membership data for one segment.
+func (m *memoryBigSegmentStore) removeSegment(segmentRef string) {
+	m.replaceSegment(segmentRef, nil, nil)
+}
+
+func addRefs(memberships map[string]map[string]struct{}, userKeys []string, segmentRef string) {
+	for _, userKey := range userKeys {
+		refs := memberships[userKey]
+		if refs == nil {
+			refs = make(map[string]struct{})
+			memberships[userKey] = refs
+		}
+		refs[segmentRef] = struct{}{}
+	}
+}
+
+func removeRefs(memberships map[string]map[string]struct{}, userKeys []string, segmentRef string) {
+	for _, userKey := range userKeys {
+		if refs := memberships[userKey]; refs != nil {
+			delete(refs, segmentRef)
+			if len(refs) == 0 {
+				delete(memberships, userKey)
+			}
+		}
+	}
+}
+
+func removeAllRefs(memberships map[string]map[string]struct{}, segmentRef string) {
+	for userKey, refs := range memberships {
+		if _, ok := refs[segmentRef]; ok {
+			delete(refs, segmentRef)
+			if len(refs) == 0 {
+				delete(memberships, userKey)
+			}
+		}
+	}
+}
+
+func (m *memoryBigSegmentStore) GetCursor() (string, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.cursor, nil
+}
+
+func (m *memoryBigSegmentStore) SetSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.synchronizedOn = synchronizedOn
+	m.dirty = true
+	return nil
+}
+
+func (m *memoryBigSegmentStore) GetSynchronizedOn() (ldtime.UnixMillisecondTime, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.synchronizedOn, nil
+}
+
+// Close stops the periodic snapshots, after writing a final snapshot if anything has changed since
+// the last one.
+func (m *memoryBigSegmentStore) Close() error {
+	if m.closeCh == nil {
+		return nil
+	}
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.closeCh)
+		<-m.doneCh
+		err = m.writeSnapshot()
+	})
+	return err
+}
+
+// GetMembershipCounts returns the exact number of users and memberships in the store.
+func (m *memoryBigSegmentStore) GetMembershipCounts() (BigSegmentMembershipCounts, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	counts := BigSegmentMembershipCounts{Users: len(m.included)}
+	for _, refs := range m.included {
+		counts.Included += len(refs)
+	}
+	for userKey, refs := range m.excluded {
+		if _, ok := m.included[userKey]; !ok {
+			counts.Users++
+		}
+		counts.Excluded += len(refs)
+	}
+	return counts, nil
+}
+
+// ExportMemberships calls action once for each user hash in the store. The store cannot be updated until
+// it returns.
+func (m *memoryBigSegmentStore) ExportMemberships(action func(userHash string, included, excluded []string) error) error {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for userHash, refs := range m.included {
+		if err := action(userHash, refsToSlice(refs), refsToSlice(m.excluded[userHash])); err != nil {
+			return err
+		}
+	}
+	for userHash, refs := range m.excluded {
+		if _, ok := m.included[userHash]; !ok {
+			if err := action(userHash, nil, refsToSlice(refs)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SDKBigSegmentStoreFactory returns a factory for an SDK-side store that reads from this store.
+func (m *memoryBigSegmentStore) SDKBigSegmentStoreFactory() interfaces.BigSegmentStoreFactory {
+	return memoryBigSegmentStoreReaderFactory{store: m}
+}
+
+func (m *memoryBigSegmentStore) getMembership(userHashKey string) interfaces.BigSegmentMembership {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return membership.FromSegmentRefs(
+		refsToSlice(m.included[userHashKey]), refsToSlice(m.excluded[userHashKey]))
+}
+
+func refsToSlice(refs map[string]struct{}) []string {
+	if len(refs) == 0 {
+		return nil
+	}
+	ret := make([]string, 0, len(refs))
+	for ref := range refs {
+		ret = append(ret, ref)
+	}
+	return ret
+}
+
+func (m *memoryBigSegmentStore) runSnapshots(interval time.Duration) {
+	defer close(m.doneCh)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.closeCh:
+			return
+		case <-ticker.C:
+			if err := m.writeSnapshot(); err != nil {
+				m.loggers.Errorf("Failed to write big segments snapshot to %s: %s", m.snapshotPath, err)
+			}
+		}
+	}
+}
+
+// writeSnapshot writes the current data to the snapshot file, if it has changed since the last
+// snapshot. The data is written to a temporary file which then replaces the previous snapshot, so
+// that a partially written snapshot is never left in place.
+func (m *memoryBigSegmentStore) writeSnapshot() error {
+	m.lock.Lock()
+	if !m.dirty {
+		m.lock.Unlock()
+		return nil
+	}
+	snapshot := memoryBigSegmentSnapshot{
+		Cursor:         m.cursor,
+		SynchronizedOn: m.synchronizedOn,
+		Included:       membershipsToSnapshot(m.included),
+		Excluded:       membershipsToSnapshot(m.excluded),
+	}
+	m.dirty = false
+	m.lock.Unlock()
+
+	err := writeFileAtomically(m.snapshotPath, snapshot)
+	if err != nil {
+		// make sure we try again next time
+		m.lock.Lock()
+		m.dirty = true
+		m.lock.Unlock()
+	}
+	return err
+}
+
+func writeFileAtomically(path string, snapshot memoryBigSegmentSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath) //nolint:errcheck // has no effect if the file was renamed
+	_, err = tempFile.Write(data)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// restoreSnapshot loads the snapshot file, if there is one. If the file can't be read, we log a
+// warning and start with no data; the synchronizer will then request all of the data again.
+func (m *memoryBigSegmentStore) restoreSnapshot() {
+	data, err := ioutil.ReadFile(m.snapshotPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			m.loggers.Warnf("Unable to read big segments snapshot from %s: %s", m.snapshotPath, err)
+		}
+		return
+	}
+	var snapshot memoryBigSegmentSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		m.loggers.Warnf("Ignoring invalid big segments snapshot in %s: %s", m.snapshotPath, err)
+		return
+	}
+	m.cursor = snapshot.Cursor
+	m.synchronizedOn = snapshot.SynchronizedOn
+	m.included = membershipsFromSnapshot(snapshot.Included)
+	m.excluded = membershipsFromSnapshot(snapshot.Excluded)
+	m.loggers.Infof("Restored big segments snapshot from %s (%d users)", m.snapshotPath,
+		len(m.included)+len(m.excluded))
+}
+
+func membershipsToSnapshot(memberships map[string]map[string]struct{}) map[string][]string {
+	ret := make(map[string][]string, len(memberships))
+	for userKey, refs := range memberships {
+		ret[userKey] = refsToSlice(refs)
+	}
+	return ret
+}
+
+func membershipsFromSnapshot(snapshot map[string][]string) map[string]map[string]struct{} {
+	ret := make(map[string]map[string]struct{}, len(snapshot))
+	for userKey, refs := range snapshot {
+		if len(refs) == 0 {
+			continue
+		}
+		set := make(map[string]struct{}, len(refs))
+		for _, ref := range refs {
+			set[ref] = struct{}{}
+		}
+		ret[userKey] = set
+	}
+	return ret
+}
+
+type memoryBigSegmentStoreReaderFactory struct {
+	store *memoryBigSegmentStore
+}
+
+func (f memoryBigSegmentStoreReaderFactory) CreateBigSegmentStore(
+	context interfaces.ClientContext,
+) (interfaces.BigSegmentStore, error) {
+	return memoryBigSegmentStoreReader{store: f.store}, nil
+}
+
+// memoryBigSegmentStoreReader is the SDK-side view of a memoryBigSegmentStore.
+type memoryBigSegmentStoreReader struct {
+	store *memoryBigSegmentStore
+}
+
+func (r memoryBigSegmentStoreReader) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	synchronizedOn, _ := r.store.GetSynchronizedOn()
+	return interfaces.BigSegmentStoreMetadata{LastUpToDate: synchronizedOn}, nil
+}
+
+func (r memoryBigSegmentStoreReader) GetUserMembership(
+	userHashKey string,
+) (interfaces.BigSegmentMembership, error) {
+	return r.store.getMembership(userHashKey), nil
+}
+
+// Close does nothing, because the data belongs to the memoryBigSegmentStore, which is closed separately.
+func (r memoryBigSegmentStoreReader) Close() error {
+	return nil
+}
@@ -0,0 +1,150 @@
+package bigsegments
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const memoryTestEnvID = config.EnvironmentID("env-id")
+
+func TestMemoryGenericAll(t *testing.T) {
+	testGenericAll(t, withMemoryStoreGeneric)
+}
+
+func withMemoryStoreGeneric(t *testing.T, action func(BigSegmentStore, bigSegmentOperations)) {
+	store, err := newMemoryBigSegmentStore(config.BigSegmentsConfig{}, config.EnvConfig{}, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer store.Close()
+	action(store, memoryMakeOperations(store))
+}
+
+func memoryMakeOperations(store *memoryBigSegmentStore) bigSegmentOperations {
+	return bigSegmentOperations{
+		isUserIncluded: func(segmentKey string, userKey string) (bool, error) {
+			_, ok := store.included[userKey][segmentKey]
+			return ok, nil
+		},
+		isUserExcluded: func(segmentKey string, userKey string) (bool, error) {
+			_, ok := store.excluded[userKey][segmentKey]
+			return ok, nil
+		},
+	}
+}
+
+func TestMemoryStoreSDKStoreReadsSameData(t *testing.T) {
+	store, err := newMemoryBigSegmentStore(config.BigSegmentsConfig{}, config.EnvConfig{}, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer store.Close()
+
+	sdkStore, err := store.SDKBigSegmentStoreFactory().CreateBigSegmentStore(nil)
+	require.NoError(t, err)
+
+	metadata, err := sdkStore.GetMetadata()
+	require.NoError(t, err)
+	assert.Equal(t, interfaces.BigSegmentStoreMetadata{}, metadata)
+
+	patch := newPatchBuilder("segment.g1", "1", "").addIncludes("user1").addExcludes("user2").build()
+	success, err := store.ApplyPatch(patch)
+	require.NoError(t, err)
+	require.True(t, success)
+	require.NoError(t, store.SetSynchronizedOn(ldtime.UnixMillisecondTime(1000)))
+
+	metadata, err = sdkStore.GetMetadata()
+	require.NoError(t, err)
+	assert.Equal(t, ldtime.UnixMillisecondTime(1000), metadata.LastUpToDate)
+
+	membership1, err := sdkStore.GetUserMembership("user1")
+	require.NoError(t, err)
+	assert.Equal(t, ldvalue.NewOptionalBool(true), membership1.CheckMembership("segment.g1"))
+
+	membership2, err := sdkStore.GetUserMembership("user2")
+	require.NoError(t, err)
+	assert.Equal(t, ldvalue.NewOptionalBool(false), membership2.CheckMembership("segment.g1"))
+
+	membership3, err := sdkStore.GetUserMembership("user3")
+	require.NoError(t, err)
+	assert.Equal(t, ldvalue.OptionalBool{}, membership3.CheckMembership("segment.g1"))
+}
+
+func TestMemoryStoreRestoresSnapshotAfterClose(t *testing.T) {
+	sharedtest.WithTempDir(func(dirPath string) {
+		bigSegmentsConfig := config.BigSegmentsConfig{Type: config.BigSegmentsStoreTypeMemory, SnapshotDir: dirPath}
+		envConfig := config.EnvConfig{EnvID: memoryTestEnvID}
+
+		store1, err := newMemoryBigSegmentStore(bigSegmentsConfig, envConfig, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		patch1 := newPatchBuilder("segment.g1", "1", "").addIncludes("user1", "user2").addExcludes("user3").build()
+		patch2 := newPatchBuilder("segment.g2", "2", "1").addIncludes("user1").build()
+		for _, patch := range []BigSegmentPatch{patch1, patch2} {
+			success, err := store1.ApplyPatch(patch)
+			require.NoError(t, err)
+			require.True(t, success)
+		}
+		require.NoError(t, store1.SetSynchronizedOn(ldtime.UnixMillisecondTime(1000)))
+		require.NoError(t, store1.Close())
+
+		assert.FileExists(t, filepath.Join(dirPath, "big-segments-env-id.json"))
+
+		store2, err := newMemoryBigSegmentStore(bigSegmentsConfig, envConfig, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		defer store2.Close()
+
+		cursor, err := store2.GetCursor()
+		require.NoError(t, err)
+		assert.Equal(t, "2", cursor)
+		syncTime, err := store2.GetSynchronizedOn()
+		require.NoError(t, err)
+		assert.Equal(t, ldtime.UnixMillisecondTime(1000), syncTime)
+		assert.Equal(t, store1.included, store2.included)
+		assert.Equal(t, store1.excluded, store2.excluded)
+	})
+}
+
+func TestMemoryStoreSnapshotFileNameWithoutEnvID(t *testing.T) {
+	name := memorySnapshotFileName(config.EnvConfig{SDKKey: config.SDKKey("sdk-key")})
+	assert.Regexp(t, `^big-segments-[0-9a-f]{16}\.json$`, name)
+	assert.NotContains(t, name, "sdk-key")
+}
+
+func TestMemoryStoreIgnoresInvalidSnapshot(t *testing.T) {
+	sharedtest.WithTempDir(func(dirPath string) {
+		envConfig := config.EnvConfig{EnvID: memoryTestEnvID}
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dirPath, memorySnapshotFileName(envConfig)),
+			[]byte("{not json"), 0600))
+
+		store, err := newMemoryBigSegmentStore(config.BigSegmentsConfig{SnapshotDir: dirPath}, envConfig,
+			ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		defer store.Close()
+
+		cursor, err := store.GetCursor()
+		require.NoError(t, err)
+		assert.Equal(t, "", cursor)
+	})
+}
+
+func TestMemoryStoreDoesNotWriteSnapshotIfUnchanged(t *testing.T) {
+	sharedtest.WithTempDir(func(dirPath string) {
+		envConfig := config.EnvConfig{EnvID: memoryTestEnvID}
+		store, err := newMemoryBigSegmentStore(config.BigSegmentsConfig{SnapshotDir: dirPath}, envConfig,
+			ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		require.NoError(t, store.Close())
+
+		files, err := ioutil.ReadDir(dirPath)
+		require.NoError(t, err)
+		assert.Len(t, files, 0)
+	})
+}
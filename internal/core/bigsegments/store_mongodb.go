@@ -0,0 +1,225 @@
+package bigsegments
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+)
+
+const (
+	mongoDBIncludedField = "included"
+	mongoDBExcludedField = "excluded"
+)
+
+// mongoDBBigSegmentStore implements BigSegmentStore for MongoDB.
+type mongoDBBigSegmentStore struct {
+	db      *mongodb.Database
+	prefix  string
+	loggers ldlog.Loggers
+}
+
+// newMongoDBBigSegmentStore creates an instance of mongoDBBigSegmentStore.
+func newMongoDBBigSegmentStore(
+	dbConfig config.MongoDBConfig,
+	envConfig config.EnvConfig,
+	loggers ldlog.Loggers,
+) (*mongoDBBigSegmentStore, error) {
+	prefix := mongodb.EffectivePrefix(envConfig.Prefix)
+	db, err := mongodb.OpenDatabase(dbConfig, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	store := mongoDBBigSegmentStore{
+		db:      db,
+		prefix:  prefix,
+		loggers: loggers,
+	}
+
+	store.loggers.SetPrefix("MongoDBBigSegmentStore:")
+
+	return &store, nil
+}
+
+// ApplyPatch is used to apply updates to the store.
+//
+// MongoDB only supports multi-document transactions on replica sets, so as with DynamoDB, the patch is
+// not applied atomically. The cursor is checked before the membership changes are written and is then
+// updated conditionally on its previous value; if another instance applied a patch in the meantime,
+// this returns false. The membership changes are idempotent, so applying the same patch again is safe.
+func (m *mongoDBBigSegmentStore) ApplyPatch(patch BigSegmentPatch) (bool, error) {
+	ctx := context.Background()
+	colls, err := m.db.Get(ctx)
+	if err != nil {
+		return false, err
+	}
+	cursor, err := m.GetCursor()
+	if err != nil || cursor != patch.PreviousVersion {
+		return false, err
+	}
+
+	var writes []mongo.WriteModel
+	add := func(userKeys []string, field string) {
+		for _, userKey := range userKeys {
+			writes = append(writes, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": userKey}).
+				SetUpdate(bson.M{"$addToSet": bson.M{field: patch.SegmentID}}).SetUpsert(true))
+		}
+	}
+	remove := func(userKeys []string, field string) {
+		for _, userKey := range userKeys {
+			writes = append(writes, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": userKey}).
+				SetUpdate(bson.M{"$pull": bson.M{field: patch.SegmentID}}))
+		}
+	}
+	add(patch.Changes.Included.Add, mongoDBIncludedField)
+	remove(patch.Changes.Included.Remove, mongoDBIncludedField)
+	add(patch.Changes.Excluded.Add, mongoDBExcludedField)
+	remove(patch.Changes.Excluded.Remove, mongoDBExcludedField)
+	if len(writes) != 0 {
+		if _, err := colls.BigSegments.BulkWrite(ctx, writes); err != nil {
+			return false, err
+		}
+	}
+
+	if patch.PreviousVersion == "" {
+		_, err = colls.Metadata.InsertOne(ctx,
+			mongodb.MetadataDocument{Key: mongodb.BigSegmentsCursorKey, Value: patch.Version})
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	result, err := colls.Metadata.UpdateOne(ctx,
+		bson.M{"_id": mongodb.BigSegmentsCursorKey, "value": patch.PreviousVersion},
+		bson.M{"$set": bson.M{"value": patch.Version}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (m *mongoDBBigSegmentStore) getMetadata(key string) (string, error) {
+	ctx := context.Background()
+	colls, err := m.db.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	var doc mongodb.MetadataDocument
+	err = colls.Metadata.FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return "", nil
+	}
+	return doc.Value, err
+}
+
+func (m *mongoDBBigSegmentStore) GetCursor() (string, error) {
+	return m.getMetadata(mongodb.BigSegmentsCursorKey)
+}
+
+func (m *mongoDBBigSegmentStore) SetSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
+	ctx := context.Background()
+	colls, err := m.db.Get(ctx)
+	if err != nil {
+		return err
+	}
+	doc := mongodb.MetadataDocument{
+		Key:   mongodb.BigSegmentsSynchronizedOnKey,
+		Value: strconv.FormatUint(uint64(synchronizedOn), 10),
+	}
+	_, err = colls.Metadata.ReplaceOne(ctx, bson.M{"_id": doc.Key}, doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (m *mongoDBBigSegmentStore) GetSynchronizedOn() (ldtime.UnixMillisecondTime, error) {
+	value, err := m.getMetadata(mongodb.BigSegmentsSynchronizedOnKey)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	milliseconds, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return ldtime.UnixMillisecondTime(milliseconds), nil
+}
+
+// GetMembershipCounts returns the number of users and memberships in the store for this environment.
+// Users whose memberships have all been removed still have a document, so they are not counted.
+func (m *mongoDBBigSegmentStore) GetMembershipCounts() (BigSegmentMembershipCounts, error) {
+	ctx := context.Background()
+	colls, err := m.db.Get(ctx)
+	if err != nil {
+		return BigSegmentMembershipCounts{}, err
+	}
+	sizeOf := func(field string) bson.M {
+		return bson.M{"$size": bson.M{"$ifNull": bson.A{"$" + field, bson.A{}}}}
+	}
+	cursor, err := colls.BigSegments.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"$or": bson.A{
+			bson.M{mongoDBIncludedField + ".0": bson.M{"$exists": true}},
+			bson.M{mongoDBExcludedField + ".0": bson.M{"$exists": true}},
+		}}},
+		bson.M{"$group": bson.M{
+			"_id":                nil,
+			"users":              bson.M{"$sum": 1},
+			mongoDBIncludedField: bson.M{"$sum": sizeOf(mongoDBIncludedField)},
+			mongoDBExcludedField: bson.M{"$sum": sizeOf(mongoDBExcludedField)},
+		}},
+	})
+	if err != nil {
+		return BigSegmentMembershipCounts{}, err
+	}
+	var results []struct {
+		Users    int `bson:"users"`
+		Included int `bson:"included"`
+		Excluded int `bson:"excluded"`
+	}
+	if err := cursor.All(ctx, &results); err != nil || len(results) == 0 {
+		return BigSegmentMembershipCounts{}, err
+	}
+	return BigSegmentMembershipCounts{
+		Users:    results[0].Users,
+		Included: results[0].Included,
+		Excluded: results[0].Excluded,
+	}, nil
+}
+
+// ExportMemberships reads all of the user documents for this environment, and calls action once for
+// each one.
+func (m *mongoDBBigSegmentStore) ExportMemberships(action func(userHash string, included, excluded []string) error) error {
+	ctx := context.Background()
+	colls, err := m.db.Get(ctx)
+	if err != nil {
+		return err
+	}
+	cursor, err := colls.BigSegments.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx) //nolint:errcheck
+	for cursor.Next(ctx) {
+		var doc struct {
+			UserHash string   `bson:"_id"`
+			Included []string `bson:"included"`
+			Excluded []string `bson:"excluded"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		if err := action(doc.UserHash, doc.Included, doc.Excluded); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (m *mongoDBBigSegmentStore) Close() error {
+	return m.db.Close()
+}
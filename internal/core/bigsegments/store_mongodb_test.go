@@ -0,0 +1,56 @@
+//go:build big_segment_external_store_tests
+// +build big_segment_external_store_tests
+
+package bigsegments
+
+import (
+	"context"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/launchdarkly/go-configtypes"
+	"go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMongoDBGenericAll(t *testing.T) {
+	testGenericAll(t, withMongoDBStoreGeneric)
+}
+
+func withMongoDBStoreGeneric(t *testing.T, action func(BigSegmentStore, bigSegmentOperations)) {
+	dbConfig := config.MongoDBConfig{Database: "launchdarkly_tests"}
+	dbConfig.URL, _ = configtypes.NewOptURLAbsoluteFromString("mongodb://localhost:27017")
+	store, err := newMongoDBBigSegmentStore(dbConfig, config.EnvConfig{Prefix: testPrefix}, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer store.Close()
+	colls, err := store.db.Get(context.Background())
+	require.NoError(t, err)
+	_, err = colls.Metadata.DeleteMany(context.Background(), bson.M{})
+	require.NoError(t, err)
+	_, err = colls.BigSegments.DeleteMany(context.Background(), bson.M{})
+	require.NoError(t, err)
+	action(store, mongoDBMakeOperations(store))
+}
+
+func mongoDBMakeOperations(store *mongoDBBigSegmentStore) bigSegmentOperations {
+	isMember := func(segmentKey, userKey, field string) (bool, error) {
+		colls, err := store.db.Get(context.Background())
+		if err != nil {
+			return false, err
+		}
+		count, err := colls.BigSegments.CountDocuments(context.Background(),
+			bson.M{"_id": userKey, field: segmentKey})
+		return count > 0, err
+	}
+	return bigSegmentOperations{
+		isUserIncluded: func(segmentKey string, userKey string) (bool, error) {
+			return isMember(segmentKey, userKey, mongoDBIncludedField)
+		},
+		isUserExcluded: func(segmentKey string, userKey string) (bool, error) {
+			return isMember(segmentKey, userKey, mongoDBExcludedField)
+		},
+	}
+}
@@ -0,0 +1,211 @@
+package bigsegments
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/postgres"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+)
+
+// postgresBigSegmentStore implements BigSegmentStore for PostgreSQL.
+type postgresBigSegmentStore struct {
+	db      *postgres.Database
+	prefix  string
+	loggers ldlog.Loggers
+}
+
+// newPostgresBigSegmentStore creates an instance of postgresBigSegmentStore.
+func newPostgresBigSegmentStore(
+	dbConfig config.PostgresConfig,
+	envConfig config.EnvConfig,
+	loggers ldlog.Loggers,
+) (*postgresBigSegmentStore, error) {
+	db, err := postgres.OpenDatabase(dbConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	store := postgresBigSegmentStore{
+		db:      db,
+		prefix:  postgres.EffectivePrefix(envConfig.Prefix),
+		loggers: loggers,
+	}
+
+	store.loggers.SetPrefix("PostgresBigSegmentStore:")
+
+	return &store, nil
+}
+
+// ApplyPatch is used to apply updates to the store.
+//
+// The whole patch is applied in one transaction. The cursor is updated first, conditionally on its
+// previous value; that also locks the cursor row, so concurrent patches for the same environment are
+// serialized.
+func (p *postgresBigSegmentStore) ApplyPatch(patch BigSegmentPatch) (bool, error) {
+	ctx := context.Background()
+	db, err := p.db.Get(ctx)
+	if err != nil {
+		return false, err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback() //nolint:errcheck // has no effect if the transaction was committed
+
+	var result sql.Result
+	if patch.PreviousVersion == "" {
+		result, err = tx.ExecContext(ctx, `INSERT INTO `+postgres.MetadataTable+` (prefix, key, value)
+			VALUES ($1, $2, $3) ON CONFLICT (prefix, key) DO NOTHING`,
+			p.prefix, postgres.BigSegmentsCursorKey, patch.Version)
+	} else {
+		result, err = tx.ExecContext(ctx, `UPDATE `+postgres.MetadataTable+` SET value = $3
+			WHERE prefix = $1 AND key = $2 AND value = $4`,
+			p.prefix, postgres.BigSegmentsCursorKey, patch.Version, patch.PreviousVersion)
+	}
+	if err != nil {
+		return false, err
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		return false, err
+	}
+
+	add, err := tx.PrepareContext(ctx, `INSERT INTO `+postgres.BigSegmentsTable+
+		` (prefix, user_hash, segment_ref, included) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return false, err
+	}
+	defer add.Close() //nolint:errcheck
+	remove, err := tx.PrepareContext(ctx, `DELETE FROM `+postgres.BigSegmentsTable+
+		` WHERE prefix = $1 AND user_hash = $2 AND segment_ref = $3 AND included = $4`)
+	if err != nil {
+		return false, err
+	}
+	defer remove.Close() //nolint:errcheck
+
+	apply := func(stmt *sql.Stmt, userKeys []string, included bool) error {
+		for _, userKey := range userKeys {
+			if _, err := stmt.ExecContext(ctx, p.prefix, userKey, patch.SegmentID, included); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := apply(add, patch.Changes.Included.Add, true); err != nil {
+		return false, err
+	}
+	if err := apply(remove, patch.Changes.Included.Remove, true); err != nil {
+		return false, err
+	}
+	if err := apply(add, patch.Changes.Excluded.Add, false); err != nil {
+		return false, err
+	}
+	if err := apply(remove, patch.Changes.Excluded.Remove, false); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *postgresBigSegmentStore) getMetadata(key string) (string, error) {
+	ctx := context.Background()
+	db, err := p.db.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	var value string
+	err = db.QueryRowContext(ctx, `SELECT value FROM `+postgres.MetadataTable+` WHERE prefix = $1 AND key = $2`,
+		p.prefix, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+func (p *postgresBigSegmentStore) GetCursor() (string, error) {
+	return p.getMetadata(postgres.BigSegmentsCursorKey)
+}
+
+func (p *postgresBigSegmentStore) SetSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
+	ctx := context.Background()
+	db, err := p.db.Get(ctx)
+	if err != nil {
+		return err
+	}
+	unixMilliseconds := strconv.FormatUint(uint64(synchronizedOn), 10)
+	_, err = db.ExecContext(ctx, `INSERT INTO `+postgres.MetadataTable+` (prefix, key, value) VALUES ($1, $2, $3)
+		ON CONFLICT (prefix, key) DO UPDATE SET value = EXCLUDED.value`,
+		p.prefix, postgres.BigSegmentsSynchronizedOnKey, unixMilliseconds)
+	return err
+}
+
+func (p *postgresBigSegmentStore) GetSynchronizedOn() (ldtime.UnixMillisecondTime, error) {
+	value, err := p.getMetadata(postgres.BigSegmentsSynchronizedOnKey)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	milliseconds, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return ldtime.UnixMillisecondTime(milliseconds), nil
+}
+
+// GetMembershipCounts returns the number of users and memberships in the store for this environment.
+func (p *postgresBigSegmentStore) GetMembershipCounts() (BigSegmentMembershipCounts, error) {
+	ctx := context.Background()
+	db, err := p.db.Get(ctx)
+	if err != nil {
+		return BigSegmentMembershipCounts{}, err
+	}
+	var counts BigSegmentMembershipCounts
+	err = db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT user_hash),
+		COUNT(*) FILTER (WHERE included), COUNT(*) FILTER (WHERE NOT included)
+		FROM `+postgres.BigSegmentsTable+` WHERE prefix = $1`, p.prefix).
+		Scan(&counts.Users, &counts.Included, &counts.Excluded)
+	return counts, err
+}
+
+// ExportMemberships reads all of the membership rows for this environment, and calls action once for
+// each row.
+func (p *postgresBigSegmentStore) ExportMemberships(action func(userHash string, included, excluded []string) error) error {
+	ctx := context.Background()
+	db, err := p.db.Get(ctx)
+	if err != nil {
+		return err
+	}
+	rows, err := db.QueryContext(ctx, `SELECT user_hash, segment_ref, included FROM `+postgres.BigSegmentsTable+
+		` WHERE prefix = $1`, p.prefix)
+	if err != nil {
+		return err
+	}
+	defer rows.Close() //nolint:errcheck
+	for rows.Next() {
+		var userHash, segmentRef string
+		var included bool
+		if err := rows.Scan(&userHash, &segmentRef, &included); err != nil {
+			return err
+		}
+		if included {
+			err = action(userHash, []string{segmentRef}, nil)
+		} else {
+			err = action(userHash, nil, []string{segmentRef})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (p *postgresBigSegmentStore) Close() error {
+	return p.db.Close()
+}
@@ -0,0 +1,58 @@
+//go:build big_segment_external_store_tests
+// +build big_segment_external_store_tests
+
+package bigsegments
+
+import (
+	"context"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/postgres"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresGenericAll(t *testing.T) {
+	testGenericAll(t, withPostgresStoreGeneric)
+}
+
+func withPostgresStoreGeneric(t *testing.T, action func(BigSegmentStore, bigSegmentOperations)) {
+	dbConfig := config.PostgresConfig{}
+	dbConfig.URL, _ = configtypes.NewOptURLAbsoluteFromString("postgres://postgres@localhost:5432/postgres?sslmode=disable")
+	store, err := newPostgresBigSegmentStore(dbConfig, config.EnvConfig{Prefix: testPrefix}, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer store.Close()
+	db, err := store.db.Get(context.Background())
+	require.NoError(t, err)
+	for _, table := range []string{postgres.MetadataTable, postgres.BigSegmentsTable} {
+		_, err := db.Exec(`DELETE FROM `+table+` WHERE prefix = $1`, testPrefix)
+		require.NoError(t, err)
+	}
+	action(store, postgresMakeOperations(store))
+}
+
+func postgresMakeOperations(store *postgresBigSegmentStore) bigSegmentOperations {
+	isMember := func(segmentKey, userKey string, included bool) (bool, error) {
+		db, err := store.db.Get(context.Background())
+		if err != nil {
+			return false, err
+		}
+		var count int
+		err = db.QueryRow(`SELECT COUNT(*) FROM `+postgres.BigSegmentsTable+
+			` WHERE prefix = $1 AND user_hash = $2 AND segment_ref = $3 AND included = $4`,
+			store.prefix, userKey, segmentKey, included).Scan(&count)
+		return count > 0, err
+	}
+	return bigSegmentOperations{
+		isUserIncluded: func(segmentKey string, userKey string) (bool, error) {
+			return isMember(segmentKey, userKey, true)
+		},
+		isUserExcluded: func(segmentKey string, userKey string) (bool, error) {
+			return isMember(segmentKey, userKey, false)
+		},
+	}
+}
@@ -2,9 +2,10 @@ package bigsegments
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
@@ -48,32 +49,13 @@ func newRedisBigSegmentStore(
 	checkOnStartup bool,
 	loggers ldlog.Loggers,
 ) (*redisBigSegmentStore, error) {
-	redisURL, prefix := sdks.GetRedisBasicProperties(redisConfig, envConfig)
-
-	opts := redis.UniversalOptions{}
-
-	// Relay's Redis configuration allows setting the server address either as a URL or as a
-	// host & port, but our config validation logic simplifies this so that it is always a URL.
-	// However, it is still possible to set the Password and TLS options separately from the
-	// URL, so we still need to check for those.
-	parsed, err := redis.ParseURL(redisURL)
+	client, prefix, err := sdks.NewRedisClient(redisConfig, envConfig)
 	if err != nil {
 		return nil, err
 	}
-	opts.DB = parsed.DB
-	opts.Addrs = []string{parsed.Addr}
-	opts.Username = parsed.Username
-	opts.Password = parsed.Password
-	opts.TLSConfig = parsed.TLSConfig
-	if redisConfig.Password != "" {
-		opts.Password = redisConfig.Password
-	}
-	if redisConfig.TLS && opts.TLSConfig == nil {
-		opts.TLSConfig = &tls.Config{ServerName: redisConfig.URL.Get().Hostname()}
-	}
 
 	store := redisBigSegmentStore{
-		client:  redis.NewUniversalClient(&opts),
+		client:  client,
 		prefix:  prefix,
 		loggers: loggers,
 	}
@@ -86,12 +68,16 @@ func newRedisBigSegmentStore(
 	}
 
 	store.loggers.SetPrefix("RedisBigSegmentStore:")
+	if len(redisConfig.ClusterAddrs.Values()) != 0 && !sdks.RedisHasHashTag(prefix) {
+		store.loggers.Warnf("Redis Cluster is enabled, but the prefix %q does not contain a hash tag such as"+
+			" {%s}; big segment updates will fail unless all of the keys are in the same hash slot", prefix, prefix)
+	}
 
 	return &store, nil
 }
 
-// applyPatch is used to apply updates to the store.
-func (r *redisBigSegmentStore) applyPatch(patch bigSegmentPatch) (bool, error) {
+// ApplyPatch is used to apply updates to the store.
+func (r *redisBigSegmentStore) ApplyPatch(patch BigSegmentPatch) (bool, error) {
 	ctx := context.Background()
 
 	updated := false
@@ -162,7 +148,7 @@ func (r *redisBigSegmentStore) applyPatch(patch bigSegmentPatch) (bool, error) {
 	return updated, err
 }
 
-func (r *redisBigSegmentStore) getCursor() (string, error) {
+func (r *redisBigSegmentStore) GetCursor() (string, error) {
 	cursor, err := r.client.Get(context.Background(), redisCursorKey(r.prefix)).Result()
 	if err == redis.Nil {
 		return "", nil
@@ -174,7 +160,7 @@ func (r *redisBigSegmentStore) getCursor() (string, error) {
 	return cursor, nil
 }
 
-func (r *redisBigSegmentStore) setSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
+func (r *redisBigSegmentStore) SetSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
 	unixMilliseconds := strconv.FormatUint(uint64(synchronizedOn), 10)
 	return r.client.Set(context.Background(), redisSynchronizedKey(r.prefix), unixMilliseconds, 0).Err()
 }
@@ -194,6 +180,48 @@ func (r *redisBigSegmentStore) GetSynchronizedOn() (ldtime.UnixMillisecondTime,
 	return ldtime.UnixMillisecondTime(milliseconds), nil
 }
 
+// ExportMemberships scans for the inclusion and exclusion keys, so it calls action separately for a
+// user's inclusions and exclusions. With Redis Cluster, it scans each master node.
+func (r *redisBigSegmentStore) ExportMemberships(action func(userHash string, included, excluded []string) error) error {
+	var lock sync.Mutex // ForEachMaster scans the nodes concurrently
+	exportFrom := func(ctx context.Context, client redis.Cmdable) error {
+		for _, isIncluded := range []bool{true, false} {
+			keyPrefix := redisExcludeKey(r.prefix, "")
+			if isIncluded {
+				keyPrefix = redisIncludeKey(r.prefix, "")
+			}
+			iter := client.Scan(ctx, 0, keyPrefix+"*", 1000).Iterator()
+			for iter.Next(ctx) {
+				refs, err := client.SMembers(ctx, iter.Val()).Result()
+				if err != nil {
+					return err
+				}
+				userHash := strings.TrimPrefix(iter.Val(), keyPrefix)
+				lock.Lock()
+				if isIncluded {
+					err = action(userHash, refs, nil)
+				} else {
+					err = action(userHash, nil, refs)
+				}
+				lock.Unlock()
+				if err != nil {
+					return err
+				}
+			}
+			if err := iter.Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(context.Background(), func(ctx context.Context, client *redis.Client) error {
+			return exportFrom(ctx, client)
+		})
+	}
+	return exportFrom(context.Background(), r.client)
+}
+
 func (r *redisBigSegmentStore) Close() error {
 	return r.client.Close()
 }
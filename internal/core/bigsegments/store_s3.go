@@ -0,0 +1,305 @@
+package bigsegments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+const s3SegmentObjectSuffix = ".json"
+
+var errS3StoreWithoutEnvKey = errors.New( //nolint:gochecknoglobals
+	"S3 big segment store requires either an environment ID or a prefix for each environment")
+
+// s3BigSegmentStore implements BigSegmentStore by loading membership data from objects in an S3
+// bucket into memory. There is one object per segment, under a key prefix that is specific to the
+// environment; see s3SegmentObject for the object format.
+//
+// The data does not come from LaunchDarkly, so ApplyPatch is never called by the synchronizer that
+// this store provides. Everything else, including the SDK-side reader, is inherited from
+// memoryBigSegmentStore.
+type s3BigSegmentStore struct {
+	*memoryBigSegmentStore
+	client       s3iface.S3API
+	bucket       string
+	prefix       string
+	pollInterval time.Duration
+}
+
+// s3SegmentObject is the JSON representation of one segment in S3. The user keys are plain keys as
+// provided to the SDK; they are hashed when they are loaded.
+type s3SegmentObject struct {
+	SegmentKey string   `json:"segmentKey"`
+	Generation int      `json:"generation"`
+	Included   []string `json:"included"`
+	Excluded   []string `json:"excluded"`
+}
+
+// newS3BigSegmentStore creates an instance of s3BigSegmentStore.
+func newS3BigSegmentStore(
+	bigSegmentsConfig config.BigSegmentsConfig,
+	envConfig config.EnvConfig,
+	awsConfig aws.Config,
+	loggers ldlog.Loggers,
+) (*s3BigSegmentStore, error) {
+	sess, err := session.NewSession(&awsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newS3BigSegmentStoreWithClient(bigSegmentsConfig, envConfig, s3.New(sess), loggers)
+}
+
+func newS3BigSegmentStoreWithClient(
+	bigSegmentsConfig config.BigSegmentsConfig,
+	envConfig config.EnvConfig,
+	client s3iface.S3API,
+	loggers ldlog.Loggers,
+) (*s3BigSegmentStore, error) {
+	envKey := envConfig.Prefix
+	if envKey == "" {
+		envKey = string(envConfig.EnvID)
+	}
+	if envKey == "" {
+		return nil, errS3StoreWithoutEnvKey
+	}
+
+	memoryStore, err := newMemoryBigSegmentStore(bigSegmentsConfig, envConfig, loggers)
+	if err != nil {
+		return nil, err
+	}
+
+	store := s3BigSegmentStore{
+		memoryBigSegmentStore: memoryStore,
+		client:                client,
+		bucket:                bigSegmentsConfig.S3Bucket,
+		prefix:                bigSegmentsConfig.S3Prefix + envKey + "/",
+		pollInterval:          bigSegmentsConfig.S3PollInterval.GetOrElse(config.DefaultBigSegmentsS3PollInterval),
+	}
+
+	store.loggers.SetPrefix("S3BigSegmentStore:")
+	store.loggers.Infof("Using S3 bucket %s with prefix %s", store.bucket, store.prefix)
+
+	return &store, nil
+}
+
+// NewBigSegmentSynchronizer returns a synchronizer that polls the S3 bucket.
+func (s *s3BigSegmentStore) NewBigSegmentSynchronizer(loggers ldlog.Loggers, logPrefix string) BigSegmentSynchronizer {
+	return newS3BigSegmentSynchronizer(s, loggers, logPrefix)
+}
+
+func hashUserKeys(userKeys []string) []string {
+	if len(userKeys) == 0 {
+		return nil
+	}
+	ret := make([]string, 0, len(userKeys))
+	for _, userKey := range userKeys {
+		ret = append(ret, HashForUserKey(userKey))
+	}
+	return ret
+}
+
+// s3BigSegmentSynchronizer is the BigSegmentSynchronizer for s3BigSegmentStore. It lists the
+// environment's objects on every poll, and only downloads the ones whose ETag has changed.
+type s3BigSegmentSynchronizer struct {
+	store              *s3BigSegmentStore
+	objects            map[string]s3ObjectState // object key -> state as of the last load
+	segmentUpdatesChan chan UpdatesSummary
+	hasSynced          bool
+	syncedLock         sync.RWMutex
+	startOnce          sync.Once
+	closeChan          chan struct{}
+	closeOnce          sync.Once
+	loggers            ldlog.Loggers
+}
+
+type s3ObjectState struct {
+	etag       string
+	segmentRef string
+}
+
+func newS3BigSegmentSynchronizer(
+	store *s3BigSegmentStore,
+	loggers ldlog.Loggers,
+	logPrefix string,
+) *s3BigSegmentSynchronizer {
+	s := s3BigSegmentSynchronizer{
+		store:              store,
+		objects:            make(map[string]s3ObjectState),
+		segmentUpdatesChan: make(chan UpdatesSummary, segmentUpdatesChannelBufferSize),
+		closeChan:          make(chan struct{}),
+		loggers:            loggers,
+	}
+
+	if logPrefix != "" {
+		logPrefix += " "
+	}
+	logPrefix += "S3BigSegmentSynchronizer:"
+	s.loggers.SetPrefix(logPrefix)
+
+	return &s
+}
+
+func (s *s3BigSegmentSynchronizer) Start() {
+	s.startOnce.Do(func() {
+		go s.run()
+	})
+}
+
+func (s *s3BigSegmentSynchronizer) HasSynced() bool {
+	s.syncedLock.RLock()
+	ret := s.hasSynced
+	s.syncedLock.RUnlock()
+	return ret
+}
+
+func (s *s3BigSegmentSynchronizer) SegmentUpdatesCh() <-chan UpdatesSummary {
+	return s.segmentUpdatesChan
+}
+
+func (s *s3BigSegmentSynchronizer) Close() {
+	// See defaultBigSegmentSynchronizer.Close
+	s.startOnce.Do(func() {
+		close(s.segmentUpdatesChan)
+	})
+	s.closeOnce.Do(func() {
+		close(s.closeChan)
+	})
+}
+
+func (s *s3BigSegmentSynchronizer) run() {
+	defer close(s.segmentUpdatesChan)
+	ticker := time.NewTicker(s.store.pollInterval)
+	defer ticker.Stop()
+	for {
+		updated, err := s.poll()
+		if err != nil {
+			s.loggers.Errorf("Loading big segments from S3 failed: %s", err)
+		} else {
+			s.setSynced()
+			if !s.notifySegmentsUpdated(updated) {
+				return
+			}
+		}
+		select {
+		case <-s.closeChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll loads any segment objects that have been added or changed since the last poll, and removes
+// the data for any that have been deleted. If there is an error, it stops without marking the store
+// as synchronized, so that the data will be reported as stale if the errors continue.
+func (s *s3BigSegmentSynchronizer) poll() (segmentChangesSummary, error) {
+	s.loggers.Debug("Polling for big segment updates")
+	updated := make(segmentChangesSummary)
+
+	current := make(map[string]string)
+	err := s.store.client.ListObjectsV2Pages(
+		&s3.ListObjectsV2Input{Bucket: aws.String(s.store.bucket), Prefix: aws.String(s.store.prefix)},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, object := range page.Contents {
+				if key := aws.StringValue(object.Key); strings.HasSuffix(key, s3SegmentObjectSuffix) {
+					current[key] = aws.StringValue(object.ETag)
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for key, etag := range current {
+		previous, found := s.objects[key]
+		if found && previous.etag == etag {
+			continue
+		}
+		segment, err := s.getSegmentObject(key)
+		if err != nil {
+			return nil, err
+		}
+		segmentRef := fmt.Sprintf("%s.g%d", segment.SegmentKey, segment.Generation)
+		if found && previous.segmentRef != segmentRef {
+			s.store.removeSegment(previous.segmentRef)
+		}
+		s.store.replaceSegment(segmentRef, hashUserKeys(segment.Included), hashUserKeys(segment.Excluded))
+		s.objects[key] = s3ObjectState{etag: etag, segmentRef: segmentRef}
+		updated.addSegmentID(segmentRef)
+		s.loggers.Debugf("Loaded segment %q from %s", segmentRef, key)
+	}
+
+	for key, previous := range s.objects {
+		if _, ok := current[key]; !ok {
+			s.store.removeSegment(previous.segmentRef)
+			delete(s.objects, key)
+			updated.addSegmentID(previous.segmentRef)
+			s.loggers.Debugf("Removed segment %q since %s no longer exists", previous.segmentRef, key)
+		}
+	}
+
+	if len(updated) > 0 {
+		s.loggers.Infof("Loaded updates for %d big segment(s)", len(updated))
+	}
+	return updated, nil
+}
+
+func (s *s3BigSegmentSynchronizer) getSegmentObject(key string) (s3SegmentObject, error) {
+	output, err := s.store.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.store.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return s3SegmentObject{}, err
+	}
+	defer output.Body.Close() //nolint:errcheck
+
+	data, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return s3SegmentObject{}, err
+	}
+	var segment s3SegmentObject
+	if err := json.Unmarshal(data, &segment); err != nil {
+		return s3SegmentObject{}, fmt.Errorf("invalid segment data in %s: %w", key, err)
+	}
+	if segment.SegmentKey == "" {
+		return s3SegmentObject{}, fmt.Errorf("invalid segment data in %s: segmentKey is missing", key)
+	}
+	return segment, nil
+}
+
+func (s *s3BigSegmentSynchronizer) setSynced() {
+	_ = s.store.SetSynchronizedOn(ldtime.UnixMillisNow()) // the in-memory store never returns an error
+	s.syncedLock.Lock()
+	s.hasSynced = true
+	s.syncedLock.Unlock()
+}
+
+// notifySegmentsUpdated returns false if the synchronizer was closed while it was waiting to post
+// the notification.
+func (s *s3BigSegmentSynchronizer) notifySegmentsUpdated(segmentsUpdated segmentChangesSummary) bool {
+	keys := segmentsUpdated.getUpdatedSegmentKeys()
+	if len(keys) == 0 {
+		return true
+	}
+	select {
+	case s.segmentUpdatesChan <- UpdatesSummary{SegmentKeysUpdated: keys}:
+		return true
+	case <-s.closeChan:
+		return false
+	}
+}
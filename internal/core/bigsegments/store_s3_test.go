@@ -0,0 +1,217 @@
+package bigsegments
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	s3TestBucket = "my-bucket"
+	s3TestPrefix = "segments/env-id/"
+)
+
+// fakeS3Client implements only the S3 operations that s3BigSegmentStore uses. The ETag of each
+// object is just its content.
+type fakeS3Client struct {
+	s3iface.S3API
+	objects map[string]string
+	err     error
+	lock    sync.Mutex
+}
+
+func newFakeS3Client() *fakeS3Client {
+	return &fakeS3Client{objects: make(map[string]string)}
+}
+
+func (c *fakeS3Client) put(key, data string) {
+	c.lock.Lock()
+	c.objects[key] = data
+	c.lock.Unlock()
+}
+
+func (c *fakeS3Client) delete(key string) {
+	c.lock.Lock()
+	delete(c.objects, key)
+	c.lock.Unlock()
+}
+
+func (c *fakeS3Client) ListObjectsV2Pages(
+	input *s3.ListObjectsV2Input,
+	fn func(*s3.ListObjectsV2Output, bool) bool,
+) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	if aws.StringValue(input.Bucket) != s3TestBucket {
+		return errors.New("no such bucket")
+	}
+	var page s3.ListObjectsV2Output
+	for key, data := range c.objects {
+		if strings.HasPrefix(key, aws.StringValue(input.Prefix)) {
+			page.Contents = append(page.Contents, &s3.Object{Key: aws.String(key), ETag: aws.String(data)})
+		}
+	}
+	fn(&page, true)
+	return nil
+}
+
+func (c *fakeS3Client) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	data, ok := c.objects[aws.StringValue(input.Key)]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewBufferString(data))}, nil
+}
+
+func makeS3TestStore(t *testing.T, client *fakeS3Client) *s3BigSegmentStore {
+	bigSegmentsConfig := config.BigSegmentsConfig{
+		Type:     config.BigSegmentsStoreTypeS3,
+		S3Bucket: s3TestBucket,
+		S3Prefix: "segments/",
+	}
+	store, err := newS3BigSegmentStoreWithClient(bigSegmentsConfig, config.EnvConfig{EnvID: "env-id"}, client,
+		ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	return store
+}
+
+func s3TestMembership(store *s3BigSegmentStore, userKey, segmentRef string) ldvalue.OptionalBool {
+	return store.getMembership(HashForUserKey(userKey)).CheckMembership(segmentRef)
+}
+
+func TestS3StoreRequiresEnvironmentKey(t *testing.T) {
+	_, err := newS3BigSegmentStoreWithClient(config.BigSegmentsConfig{S3Bucket: s3TestBucket}, config.EnvConfig{},
+		newFakeS3Client(), ldlog.NewDisabledLoggers())
+	assert.Equal(t, errS3StoreWithoutEnvKey, err)
+}
+
+func TestS3StorePrefersEnvironmentPrefix(t *testing.T) {
+	store, err := newS3BigSegmentStoreWithClient(config.BigSegmentsConfig{S3Bucket: s3TestBucket},
+		config.EnvConfig{EnvID: "env-id", Prefix: "env-prefix"}, newFakeS3Client(), ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	assert.Equal(t, "env-prefix/", store.prefix)
+}
+
+func TestS3SynchronizerLoadsSegmentsOnStart(t *testing.T) {
+	client := newFakeS3Client()
+	client.put(s3TestPrefix+"segment1.json",
+		`{"segmentKey":"segment1","generation":1,"included":["user1"],"excluded":["user2"]}`)
+	client.put(s3TestPrefix+"segment2.json", `{"segmentKey":"segment2","generation":3,"included":["user2"]}`)
+	client.put(s3TestPrefix+"README.txt", `not a segment`)
+	client.put("segments/other-env/segment3.json", `{"segmentKey":"segment3","generation":1,"included":["user1"]}`)
+	store := makeS3TestStore(t, client)
+	defer store.Close()
+
+	sync := store.NewBigSegmentSynchronizer(ldlog.NewDisabledLoggers(), "")
+	defer sync.Close()
+	assert.False(t, sync.HasSynced())
+	sync.Start()
+
+	select {
+	case updates := <-sync.SegmentUpdatesCh():
+		sort.Strings(updates.SegmentKeysUpdated)
+		assert.Equal(t, []string{"segment1", "segment2"}, updates.SegmentKeysUpdated)
+	case <-time.After(time.Second):
+		require.Fail(t, "timed out waiting for segment updates")
+	}
+	assert.True(t, sync.HasSynced())
+
+	assert.Equal(t, ldvalue.NewOptionalBool(true), s3TestMembership(store, "user1", "segment1.g1"))
+	assert.Equal(t, ldvalue.NewOptionalBool(false), s3TestMembership(store, "user2", "segment1.g1"))
+	assert.Equal(t, ldvalue.NewOptionalBool(true), s3TestMembership(store, "user2", "segment2.g3"))
+	assert.Equal(t, ldvalue.OptionalBool{}, s3TestMembership(store, "user1", "segment3.g1"))
+
+	syncTime, err := store.GetSynchronizedOn()
+	require.NoError(t, err)
+	assert.True(t, syncTime.IsDefined())
+}
+
+func TestS3SynchronizerAppliesChangesAndRemovals(t *testing.T) {
+	client := newFakeS3Client()
+	client.put(s3TestPrefix+"segment1.json", `{"segmentKey":"segment1","generation":1,"included":["user1","user2"]}`)
+	client.put(s3TestPrefix+"segment2.json", `{"segmentKey":"segment2","generation":1,"included":["user1"]}`)
+	store := makeS3TestStore(t, client)
+	defer store.Close()
+	sync := newS3BigSegmentSynchronizer(store, ldlog.NewDisabledLoggers(), "")
+
+	_, err := sync.poll()
+	require.NoError(t, err)
+
+	updated, err := sync.poll()
+	require.NoError(t, err)
+	assert.Len(t, updated, 0)
+
+	client.put(s3TestPrefix+"segment1.json", `{"segmentKey":"segment1","generation":2,"included":["user2"]}`)
+	client.delete(s3TestPrefix + "segment2.json")
+
+	updated, err = sync.poll()
+	require.NoError(t, err)
+	keys := updated.getUpdatedSegmentKeys()
+	sort.Strings(keys)
+	assert.Equal(t, []string{"segment1", "segment2"}, keys)
+
+	assert.Equal(t, ldvalue.OptionalBool{}, s3TestMembership(store, "user1", "segment1.g1"))
+	assert.Equal(t, ldvalue.OptionalBool{}, s3TestMembership(store, "user1", "segment1.g2"))
+	assert.Equal(t, ldvalue.NewOptionalBool(true), s3TestMembership(store, "user2", "segment1.g2"))
+	assert.Equal(t, ldvalue.OptionalBool{}, s3TestMembership(store, "user1", "segment2.g1"))
+
+	counts, err := store.GetMembershipCounts()
+	require.NoError(t, err)
+	assert.Equal(t, BigSegmentMembershipCounts{Users: 1, Included: 1}, counts)
+}
+
+func TestS3SynchronizerKeepsDataAfterError(t *testing.T) {
+	client := newFakeS3Client()
+	client.put(s3TestPrefix+"segment1.json", `{"segmentKey":"segment1","generation":1,"included":["user1"]}`)
+	store := makeS3TestStore(t, client)
+	defer store.Close()
+	sync := newS3BigSegmentSynchronizer(store, ldlog.NewDisabledLoggers(), "")
+
+	_, err := sync.poll()
+	require.NoError(t, err)
+
+	client.put(s3TestPrefix+"segment2.json", `{not json`)
+	_, err = sync.poll()
+	assert.Error(t, err)
+
+	client.lock.Lock()
+	client.err = errors.New("sorry")
+	client.lock.Unlock()
+	_, err = sync.poll()
+	assert.Equal(t, client.err, err)
+
+	assert.Equal(t, ldvalue.NewOptionalBool(true), s3TestMembership(store, "user1", "segment1.g1"))
+}
+
+func TestS3SynchronizerCloseBeforeStart(t *testing.T) {
+	store := makeS3TestStore(t, newFakeS3Client())
+	defer store.Close()
+	sync := store.NewBigSegmentSynchronizer(ldlog.NewDisabledLoggers(), "")
+	sync.Close()
+	sync.Start()
+
+	_, ok := <-sync.SegmentUpdatesCh()
+	assert.False(t, ok)
+}
@@ -0,0 +1,120 @@
+package bigsegments
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/mongodb"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/postgres"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultBigSegmentStoreFactoryWithNoDatabase(t *testing.T) {
+	store, err := DefaultBigSegmentStoreFactory(config.EnvConfig{}, config.Config{}, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	assert.Nil(t, store)
+}
+
+func TestDefaultBigSegmentStoreFactoryWithRegisteredType(t *testing.T) {
+	storeType := "custom-type"
+	allConfig := config.Config{BigSegments: config.BigSegmentsConfig{Type: storeType}}
+	envConfig := config.EnvConfig{Prefix: "abc"}
+	customStore := newBigSegmentStoreMock()
+
+	var receivedEnvConfig config.EnvConfig
+	RegisterBigSegmentStoreFactory(storeType, func(
+		envConfig config.EnvConfig, allConfig config.Config, loggers ldlog.Loggers,
+	) (BigSegmentStore, error) {
+		receivedEnvConfig = envConfig
+		return customStore, nil
+	})
+	defer unregisterBigSegmentStoreFactory(storeType)
+
+	store, err := DefaultBigSegmentStoreFactory(envConfig, allConfig, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	assert.Equal(t, customStore, store)
+	assert.Equal(t, envConfig, receivedEnvConfig)
+}
+
+func unregisterBigSegmentStoreFactory(storeType string) {
+	registeredStoreFactoriesLock.Lock()
+	delete(registeredStoreFactories, storeType)
+	registeredStoreFactoriesLock.Unlock()
+}
+
+func TestRegisterBigSegmentStoreFactoryPanicsForDuplicateOrNil(t *testing.T) {
+	storeType := "duplicate-type"
+	factory := func(config.EnvConfig, config.Config, ldlog.Loggers) (BigSegmentStore, error) { return nil, nil }
+	RegisterBigSegmentStoreFactory(storeType, factory)
+	defer unregisterBigSegmentStoreFactory(storeType)
+	assert.Panics(t, func() { RegisterBigSegmentStoreFactory(storeType, factory) })
+	assert.Panics(t, func() { RegisterBigSegmentStoreFactory("other-type", nil) })
+}
+
+func TestDefaultBigSegmentStoreFactoryWithUnregisteredType(t *testing.T) {
+	storeType := "unknown-type"
+	allConfig := config.Config{BigSegments: config.BigSegmentsConfig{Type: storeType}}
+
+	store, err := DefaultBigSegmentStoreFactory(config.EnvConfig{}, allConfig, ldlog.NewDisabledLoggers())
+	assert.Nil(t, store)
+	assert.Equal(t, errUnknownBigSegmentStoreType(storeType), err)
+}
+
+func TestDefaultBigSegmentStoreFactoryWithConsul(t *testing.T) {
+	allConfig := config.Config{Consul: config.ConsulConfig{Host: "consulhost:8500"}}
+
+	store, err := DefaultBigSegmentStoreFactory(config.EnvConfig{Prefix: "abc"}, allConfig, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	require.IsType(t, &consulBigSegmentStore{}, store)
+	assert.Equal(t, "abc", store.(*consulBigSegmentStore).prefix)
+}
+
+func TestDefaultBigSegmentStoreFactoryWithPostgres(t *testing.T) {
+	allConfig := config.Config{}
+	allConfig.Postgres.URL, _ = configtypes.NewOptURLAbsoluteFromString("postgres://pghost:5432/relay")
+
+	store, err := DefaultBigSegmentStoreFactory(config.EnvConfig{}, allConfig, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	require.IsType(t, &postgresBigSegmentStore{}, store)
+	defer store.Close()
+	assert.Equal(t, postgres.DefaultPrefix, store.(*postgresBigSegmentStore).prefix)
+}
+
+func TestDefaultBigSegmentStoreFactoryWithMongoDB(t *testing.T) {
+	allConfig := config.Config{}
+	allConfig.MongoDB.URL, _ = configtypes.NewOptURLAbsoluteFromString("mongodb://mongohost:27017")
+
+	store, err := DefaultBigSegmentStoreFactory(config.EnvConfig{}, allConfig, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	require.IsType(t, &mongoDBBigSegmentStore{}, store)
+	defer store.Close()
+	assert.Equal(t, mongodb.DefaultPrefix, store.(*mongoDBBigSegmentStore).prefix)
+}
+
+func TestDefaultBigSegmentStoreFactoryWithMemoryStore(t *testing.T) {
+	allConfig := config.Config{BigSegments: config.BigSegmentsConfig{Type: config.BigSegmentsStoreTypeMemory}}
+
+	store, err := DefaultBigSegmentStoreFactory(config.EnvConfig{}, allConfig, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	require.IsType(t, &memoryBigSegmentStore{}, store)
+	defer store.Close()
+	assert.Implements(t, (*SDKBigSegmentStoreProvider)(nil), store)
+}
+
+func TestDefaultBigSegmentStoreFactoryWithS3Store(t *testing.T) {
+	allConfig := config.Config{BigSegments: config.BigSegmentsConfig{
+		Type: config.BigSegmentsStoreTypeS3, S3Bucket: "my-bucket", S3Prefix: "segments/"}}
+
+	store, err := DefaultBigSegmentStoreFactory(config.EnvConfig{EnvID: "env-id"}, allConfig, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	require.IsType(t, &s3BigSegmentStore{}, store)
+	defer store.Close()
+	assert.Equal(t, "segments/env-id/", store.(*s3BigSegmentStore).prefix)
+	assert.Implements(t, (*SDKBigSegmentStoreProvider)(nil), store)
+	assert.Implements(t, (*BigSegmentSynchronizerProvider)(nil), store)
+}
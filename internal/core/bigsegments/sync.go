@@ -25,6 +25,11 @@ const (
 	synchronizedOnInterval     = 30 * time.Second
 
 	segmentUpdatesChannelBufferSize = 20
+
+	// maxUpdatedUserHashes is the largest number of user hash keys that are reported in an UpdatesSummary.
+	// Updates that affect more users than this, such as the initial upload of a segment, are reported as
+	// possibly affecting any user, since it is then cheaper to notify everyone than to keep track of them.
+	maxUpdatedUserHashes = 1000
 )
 
 // BigSegmentSynchronizer synchronizes big segment state for a given environment.
@@ -63,6 +68,10 @@ type UpdatesSummary struct {
 	// SegmentKeysUpdated is a slice of segment keys (plain keys as used by the SDK-- not segment
 	// IDs, i.e. there is no generation suffix).
 	SegmentKeysUpdated []string
+
+	// UserHashesUpdated is a slice of the hash keys (see HashForUserKey) of the users whose memberships
+	// were changed by the updates. If it is nil, the updates may have affected any user.
+	UserHashesUpdated []string
 }
 
 // BigSegmentSynchronizerFactory creates an implementation of BigSegmentSynchronizer. We
@@ -79,6 +88,13 @@ type BigSegmentSynchronizerFactory func(
 	logPrefix string,
 ) BigSegmentSynchronizer
 
+// BigSegmentSynchronizerProvider is an optional interface for a BigSegmentStore that obtains its data
+// from somewhere other than LaunchDarkly. Relay uses the synchronizer that it provides instead of the
+// one from BigSegmentSynchronizerFactory.
+type BigSegmentSynchronizerProvider interface {
+	NewBigSegmentSynchronizer(loggers ldlog.Loggers, logPrefix string) BigSegmentSynchronizer
+}
+
 // defaultBigSegmentSynchronizer is the standard implementation of BigSegmentSynchronizer.
 type defaultBigSegmentSynchronizer struct {
 	httpConfig          httpconfig.HTTPConfig
@@ -99,10 +115,18 @@ type defaultBigSegmentSynchronizer struct {
 
 type segmentChangesSummary map[string]struct{}
 
+// userChangesSummary is the set of user hash keys that appear in a batch of updates, or allUsers if
+// there were more than maxUpdatedUserHashes of them.
+type userChangesSummary struct {
+	userHashes map[string]struct{}
+	allUsers   bool
+}
+
 type applyPatchesResult struct {
 	totalPatchesCount   int
 	patchesAppliedCount int
 	segmentsUpdated     segmentChangesSummary
+	usersUpdated        userChangesSummary
 }
 
 // DefaultBigSegmentSynchronizerFactory creates the default implementation of BigSegmentSynchronizer.
@@ -217,6 +241,7 @@ func (s *defaultBigSegmentSynchronizer) syncSupervisor() {
 func (s *defaultBigSegmentSynchronizer) sync(isRetry bool) error {
 	s.loggers.Debug("Polling for big segment updates")
 	segmentsUpdated := make(segmentChangesSummary)
+	var usersUpdated userChangesSummary
 	for {
 	SyncLoop:
 		for {
@@ -232,7 +257,8 @@ func (s *defaultBigSegmentSynchronizer) sync(isRetry bool) error {
 					s.loggers.Warn("Re-established connection")
 					isRetry = false
 				}
-				segmentsUpdated.addAll(updates)
+				segmentsUpdated.addAll(updates.segmentsUpdated)
+				usersUpdated.addAll(updates.usersUpdated)
 				if done {
 					break SyncLoop
 				}
@@ -249,7 +275,8 @@ func (s *defaultBigSegmentSynchronizer) sync(isRetry bool) error {
 		if err != nil {
 			return err
 		}
-		segmentsUpdated.addAll(updates)
+		segmentsUpdated.addAll(updates.segmentsUpdated)
+		usersUpdated.addAll(updates.usersUpdated)
 		if !done {
 			continue
 		}
@@ -261,14 +288,14 @@ func (s *defaultBigSegmentSynchronizer) sync(isRetry bool) error {
 			return err
 		}
 
-		s.notifySegmentsUpdated(segmentsUpdated)
+		s.notifySegmentsUpdated(segmentsUpdated, usersUpdated)
 
 		return s.consumeStream(stream)
 	}
 }
 
 func (s *defaultBigSegmentSynchronizer) setSynced() error {
-	err := s.store.setSynchronizedOn(ldtime.UnixMillisNow())
+	err := s.store.SetSynchronizedOn(ldtime.UnixMillisNow())
 	if err != nil {
 		return err
 	}
@@ -297,19 +324,19 @@ func isHTTPErrorRecoverable(statusCode int) bool {
 	return true
 }
 
-func (s *defaultBigSegmentSynchronizer) poll() (bool, segmentChangesSummary, error) {
+func (s *defaultBigSegmentSynchronizer) poll() (bool, applyPatchesResult, error) {
 	client := s.httpConfig.Client()
 
 	request, err := http.NewRequest("GET", s.pollURI, nil)
 	if err != nil {
-		return false, nil, err
+		return false, applyPatchesResult{}, err
 	}
 
 	request.Header.Set("Authorization", string(s.sdkKey))
 
-	cursor, err := s.store.getCursor()
+	cursor, err := s.store.GetCursor()
 	if err != nil {
-		return false, segmentChangesSummary{}, err
+		return false, applyPatchesResult{}, err
 	}
 
 	if cursor != "" {
@@ -321,22 +348,22 @@ func (s *defaultBigSegmentSynchronizer) poll() (bool, segmentChangesSummary, err
 	s.loggers.Debugf("Polling %s", request.URL)
 	response, err := client.Do(request)
 	if err != nil {
-		return false, segmentChangesSummary{}, err
+		return false, applyPatchesResult{}, err
 	}
 	defer response.Body.Close() //nolint:errcheck
 
 	if response.StatusCode != 200 {
-		return false, segmentChangesSummary{}, &httpStatusError{response.StatusCode}
+		return false, applyPatchesResult{}, &httpStatusError{response.StatusCode}
 	}
 
 	responseBody, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return false, segmentChangesSummary{}, err
+		return false, applyPatchesResult{}, err
 	}
 
 	applyPatchResult, err := s.applyPatches(responseBody)
 
-	return applyPatchResult.totalPatchesCount == 0, applyPatchResult.segmentsUpdated, err
+	return applyPatchResult.totalPatchesCount == 0, applyPatchResult, err
 }
 
 func (s *defaultBigSegmentSynchronizer) connectStream() (*es.Stream, error) {
@@ -386,7 +413,7 @@ func (s *defaultBigSegmentSynchronizer) consumeStream(stream *es.Stream) error {
 			if err != nil {
 				return err
 			}
-			s.notifySegmentsUpdated(applyPatchResult.segmentsUpdated)
+			s.notifySegmentsUpdated(applyPatchResult.segmentsUpdated, applyPatchResult.usersUpdated)
 			if applyPatchResult.patchesAppliedCount < applyPatchResult.totalPatchesCount {
 				return nil // forces a restart if we got an out-of-order patch
 			}
@@ -408,7 +435,7 @@ func (s *defaultBigSegmentSynchronizer) consumeStream(stream *es.Stream) error {
 
 // Returns total number of patches, number of patches applied, raw segment IDs, error
 func (s *defaultBigSegmentSynchronizer) applyPatches(jsonData []byte) (applyPatchesResult, error) {
-	var patches []bigSegmentPatch
+	var patches []BigSegmentPatch
 	err := json.Unmarshal(jsonData, &patches)
 	if err != nil {
 		return applyPatchesResult{}, err
@@ -424,7 +451,7 @@ func (s *defaultBigSegmentSynchronizer) applyPatches(jsonData []byte) (applyPatc
 		} else {
 			s.loggers.Debugf("Received patch for version %q (from previous version %q)", patch.Version, patch.PreviousVersion)
 		}
-		success, err := s.store.applyPatch(patch)
+		success, err := s.store.ApplyPatch(patch)
 		if err != nil {
 			return ret, err
 		}
@@ -434,6 +461,7 @@ func (s *defaultBigSegmentSynchronizer) applyPatches(jsonData []byte) (applyPatc
 		}
 		ret.patchesAppliedCount++
 		ret.segmentsUpdated.addSegmentID(patch.SegmentID)
+		ret.usersUpdated.addPatch(patch)
 	}
 	if ret.patchesAppliedCount > 0 {
 		updatesDesc := "updates"
@@ -445,10 +473,16 @@ func (s *defaultBigSegmentSynchronizer) applyPatches(jsonData []byte) (applyPatc
 	return ret, nil
 }
 
-func (s *defaultBigSegmentSynchronizer) notifySegmentsUpdated(segmentsUpdated segmentChangesSummary) {
+func (s *defaultBigSegmentSynchronizer) notifySegmentsUpdated(
+	segmentsUpdated segmentChangesSummary,
+	usersUpdated userChangesSummary,
+) {
 	keys := segmentsUpdated.getUpdatedSegmentKeys()
 	if len(keys) != 0 {
-		s.segmentUpdatesChan <- UpdatesSummary{SegmentKeysUpdated: keys}
+		s.segmentUpdatesChan <- UpdatesSummary{
+			SegmentKeysUpdated: keys,
+			UserHashesUpdated:  usersUpdated.getUpdatedUserHashes(),
+		}
 	}
 }
 
@@ -479,3 +513,51 @@ func (s *segmentChangesSummary) getUpdatedSegmentKeys() []string {
 	}
 	return ret
 }
+
+func (u *userChangesSummary) addPatch(patch BigSegmentPatch) {
+	for _, userHashes := range [][]string{
+		patch.Changes.Included.Add, patch.Changes.Included.Remove,
+		patch.Changes.Excluded.Add, patch.Changes.Excluded.Remove,
+	} {
+		for _, userHash := range userHashes {
+			u.addUserHash(userHash)
+		}
+	}
+}
+
+func (u *userChangesSummary) addUserHash(userHash string) {
+	if u.allUsers {
+		return
+	}
+	if u.userHashes == nil {
+		u.userHashes = make(map[string]struct{})
+	}
+	u.userHashes[userHash] = struct{}{}
+	if len(u.userHashes) > maxUpdatedUserHashes {
+		u.allUsers = true
+		u.userHashes = nil
+	}
+}
+
+func (u *userChangesSummary) addAll(other userChangesSummary) {
+	if other.allUsers {
+		u.allUsers = true
+		u.userHashes = nil
+		return
+	}
+	for userHash := range other.userHashes {
+		u.addUserHash(userHash)
+	}
+}
+
+// getUpdatedUserHashes returns nil if any user may have been affected.
+func (u *userChangesSummary) getUpdatedUserHashes() []string {
+	if u.allUsers {
+		return nil
+	}
+	ret := make([]string, 0, len(u.userHashes))
+	for userHash := range u.userHashes {
+		ret = append(ret, userHash)
+	}
+	return ret
+}
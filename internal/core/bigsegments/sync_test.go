@@ -3,6 +3,7 @@ package bigsegments
 import (
 	"net/http/httptest"
 	"sort"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -22,11 +23,11 @@ import (
 type bigSegmentStoreMock struct {
 	cursor     string
 	lock       sync.Mutex
-	patchCh    chan bigSegmentPatch
+	patchCh    chan BigSegmentPatch
 	syncTimeCh chan ldtime.UnixMillisecondTime
 }
 
-func (s *bigSegmentStoreMock) applyPatch(patch bigSegmentPatch) (bool, error) {
+func (s *bigSegmentStoreMock) ApplyPatch(patch BigSegmentPatch) (bool, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
@@ -40,14 +41,14 @@ func (s *bigSegmentStoreMock) applyPatch(patch bigSegmentPatch) (bool, error) {
 	return true, nil
 }
 
-func (s *bigSegmentStoreMock) getCursor() (string, error) {
+func (s *bigSegmentStoreMock) GetCursor() (string, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	return s.cursor, nil
 }
 
-func (s *bigSegmentStoreMock) setSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
+func (s *bigSegmentStoreMock) SetSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
 	s.syncTimeCh <- synchronizedOn
 
 	return nil
@@ -63,7 +64,7 @@ func (s *bigSegmentStoreMock) Close() error {
 
 func newBigSegmentStoreMock() *bigSegmentStoreMock {
 	return &bigSegmentStoreMock{
-		patchCh:    make(chan bigSegmentPatch, 100),
+		patchCh:    make(chan BigSegmentPatch, 100),
 		syncTimeCh: make(chan ldtime.UnixMillisecondTime, 100),
 	}
 }
@@ -83,7 +84,7 @@ func assertStreamRequest(t *testing.T, req httphelpers.HTTPRequestInfo) {
 	assert.Equal(t, unboundedStreamPath, req.Request.URL.Path)
 }
 
-func requirePatch(t *testing.T, s *bigSegmentStoreMock, expectedPatch bigSegmentPatch) {
+func requirePatch(t *testing.T, s *bigSegmentStoreMock, expectedPatch BigSegmentPatch) {
 	select {
 	case patch := <-s.patchCh:
 		require.Equal(t, expectedPatch, patch)
@@ -94,7 +95,7 @@ func requirePatch(t *testing.T, s *bigSegmentStoreMock, expectedPatch bigSegment
 
 func requireNoMorePatches(t *testing.T, s *bigSegmentStoreMock) {
 	if len(s.patchCh) > 0 {
-		var patches []bigSegmentPatch
+		var patches []BigSegmentPatch
 		for len(s.patchCh) > 0 {
 			patches = append(patches, <-s.patchCh)
 		}
@@ -102,14 +103,17 @@ func requireNoMorePatches(t *testing.T, s *bigSegmentStoreMock) {
 	}
 }
 
-func requireUpdates(t *testing.T, ch <-chan UpdatesSummary, expectedKeys []string) {
+func requireUpdates(t *testing.T, ch <-chan UpdatesSummary, expectedKeys []string) UpdatesSummary {
 	select {
 	case u := <-ch:
 		sort.Strings(u.SegmentKeysUpdated)
 		sort.Strings(expectedKeys)
 		require.Equal(t, expectedKeys, u.SegmentKeysUpdated)
+		sort.Strings(u.UserHashesUpdated)
+		return u
 	case <-time.After(time.Second):
 		require.Fail(t, "timed out waiting for updates")
+		return UpdatesSummary{}
 	}
 }
 
@@ -125,8 +129,8 @@ func TestBasicSync(t *testing.T) {
 
 	pollHandler, requestsCh := httphelpers.RecordingHandler(
 		httphelpers.SequentialHandler(
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{patch1}, nil),
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{patch1}, nil),
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{}, nil),
 		),
 	)
 
@@ -202,13 +206,13 @@ func TestSyncSendsUpdates(t *testing.T) {
 	poll2Patch2 := newPatchBuilder("segment2.g3", "3", "2").
 		addIncludes("included1", "included2").addExcludes("excluded1", "excluded2").build()
 	streamPatch := newPatchBuilder("segment2.g3", "4", "3").
-		addIncludes("included1", "included2").addExcludes("excluded1", "excluded2").build()
+		addIncludes("included3").removeExcludes("excluded1").build()
 
 	pollHandler, requestsCh := httphelpers.RecordingHandler(
 		httphelpers.SequentialHandler(
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{poll1Patch1}, nil),
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{poll2Patch1, poll2Patch2}, nil),
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{poll1Patch1}, nil),
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{poll2Patch1, poll2Patch2}, nil),
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{}, nil),
 		),
 	)
 
@@ -245,7 +249,8 @@ func TestSyncSendsUpdates(t *testing.T) {
 
 			requireNoMorePatches(t, storeMock)
 
-			requireUpdates(t, updatesCh, []string{"segment1", "segment2"})
+			updates := requireUpdates(t, updatesCh, []string{"segment1", "segment2"})
+			assert.Equal(t, []string{"excluded1", "excluded2", "included1", "included2"}, updates.UserHashesUpdated)
 
 			sharedtest.ExpectNoTestRequests(t, requestsCh, time.Millisecond*50)
 
@@ -255,11 +260,33 @@ func TestSyncSendsUpdates(t *testing.T) {
 
 			sharedtest.ExpectNoTestRequests(t, streamRequestsCh, time.Millisecond*50)
 
-			requireUpdates(t, updatesCh, []string{"segment2"})
+			updates = requireUpdates(t, updatesCh, []string{"segment2"})
+			assert.Equal(t, []string{"excluded1", "included3"}, updates.UserHashesUpdated)
 		})
 	})
 }
 
+func TestUserChangesSummaryReportsAllUsersIfThereAreTooMany(t *testing.T) {
+	var u userChangesSummary
+	for i := 0; i < maxUpdatedUserHashes; i++ {
+		u.addUserHash(strconv.Itoa(i))
+	}
+	assert.Len(t, u.getUpdatedUserHashes(), maxUpdatedUserHashes)
+
+	var u2 userChangesSummary
+	u2.addPatch(newPatchBuilder("segment1.g1", "1", "").addIncludes("another").build())
+	u2.addAll(u)
+	assert.Nil(t, u2.getUpdatedUserHashes())
+
+	var u3 userChangesSummary
+	u3.addAll(u2)
+	u3.addUserHash("x")
+	assert.Nil(t, u3.getUpdatedUserHashes())
+
+	var empty userChangesSummary
+	assert.Equal(t, []string{}, empty.getUpdatedUserHashes())
+}
+
 func TestSyncSkipsOutOfOrderUpdateFromPoll(t *testing.T) {
 	// Scenario:
 	// - Poll returns 3 patches: first patch is valid, second patch is non-matching, third is matching
@@ -281,9 +308,9 @@ func TestSyncSkipsOutOfOrderUpdateFromPoll(t *testing.T) {
 
 	pollHandler, requestsCh := httphelpers.RecordingHandler(
 		httphelpers.SequentialHandler(
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{patch1, patch1x, patch1y}, nil),
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{patch1, patch1x, patch1y}, nil),
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{}, nil),
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{}, nil),
 		),
 	)
 
@@ -349,9 +376,9 @@ func TestSyncSkipsOutOfOrderUpdateFromStreamAndRestartsStream(t *testing.T) {
 
 	pollHandler, requestsCh := httphelpers.RecordingHandler(
 		httphelpers.SequentialHandler(
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{patch1}, nil),
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{patch1}, nil),
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{}, nil),
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{}, nil),
 		),
 	)
 
@@ -431,12 +458,12 @@ func TestSyncRetryIfStreamFails(t *testing.T) {
 
 	pollHandler, requestsCh := httphelpers.RecordingHandler(
 		httphelpers.SequentialHandler(
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{patch1}, nil), // poll 1: initial connection
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),       // poll 2: completion of poll 1
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),       // poll 3: done in conjunction with stream 1
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{patch3}, nil), // poll 4: retry after stream fails
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),       // poll 5: completion of poll 4
-			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),       // poll 6: done in conjunction with stream 2
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{patch1}, nil), // poll 1: initial connection
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{}, nil),       // poll 2: completion of poll 1
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{}, nil),       // poll 3: done in conjunction with stream 1
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{patch3}, nil), // poll 4: retry after stream fails
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{}, nil),       // poll 5: completion of poll 4
+			httphelpers.HandlerWithJSONResponse([]BigSegmentPatch{}, nil),       // poll 6: done in conjunction with stream 2
 		),
 	)
 
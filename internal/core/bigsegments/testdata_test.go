@@ -9,7 +9,7 @@ import (
 const testSDKKey = "sdk-key"
 const testEnvironmentID = "abc"
 
-func makePatchEvent(patches ...bigSegmentPatch) *httphelpers.SSEEvent {
+func makePatchEvent(patches ...BigSegmentPatch) *httphelpers.SSEEvent {
 	bytes, err := json.Marshal(patches)
 	if err != nil {
 		panic(err)
@@ -18,12 +18,12 @@ func makePatchEvent(patches ...bigSegmentPatch) *httphelpers.SSEEvent {
 }
 
 type patchBuilder struct {
-	patch bigSegmentPatch
+	patch BigSegmentPatch
 }
 
 func newPatchBuilder(segmentID, version, previous string) *patchBuilder {
 	return &patchBuilder{
-		patch: bigSegmentPatch{
+		patch: BigSegmentPatch{
 			EnvironmentID:   testEnvironmentID,
 			SegmentID:       segmentID,
 			Version:         version,
@@ -52,6 +52,6 @@ func (b *patchBuilder) removeExcludes(keys ...string) *patchBuilder {
 	return b
 }
 
-func (b *patchBuilder) build() bigSegmentPatch {
+func (b *patchBuilder) build() BigSegmentPatch {
 	return b.patch
 }
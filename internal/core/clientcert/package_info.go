@@ -0,0 +1,2 @@
+// Package clientcert contains the logic for authenticating SDK requests with TLS client certificates.
+package clientcert
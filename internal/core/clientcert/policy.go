@@ -0,0 +1,125 @@
+package clientcert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+)
+
+var (
+	errNoClientCert  = errors.New("no client certificate was provided")
+	errNotAllowed    = errors.New("client certificate is not allowed for this environment")
+	errNoCertsInFile = errors.New("file does not contain any PEM-encoded certificates")
+)
+
+func errCannotLoadCA(path string, err error) error {
+	return fmt.Errorf("unable to load client CA bundle %q: %w", path, err)
+}
+
+// Policy determines whether a TLS client certificate is acceptable for an environment.
+//
+// Relay's HTTP server requires clients to present a certificate if client certificates are enabled, but it
+// does not verify the certificate; that is done by Policy after the request's environment is known, since
+// each environment can have its own CA bundle and its own restrictions on which certificates it accepts.
+type Policy struct {
+	roots        *x509.CertPool
+	allowedNames map[string]bool
+	allowedOUs   map[string]bool
+}
+
+// NewPolicy creates the Policy for an environment. It returns nil, and no error, if client certificates
+// are not required.
+//
+// The environment's ClientCA, if any, is used instead of the global TLSClientCA.
+func NewPolicy(mainConfig config.MainConfig, envConfig config.EnvConfig) (*Policy, error) {
+	if !mainConfig.TLSClientCertRequired {
+		return nil, nil
+	}
+	caPath := envConfig.ClientCA
+	if caPath == "" {
+		caPath = mainConfig.TLSClientCA
+	}
+	roots, err := loadCertPool(caPath)
+	if err != nil {
+		return nil, errCannotLoadCA(caPath, err)
+	}
+	return &Policy{
+		roots:        roots,
+		allowedNames: makeSet(envConfig.AllowedClientCertName.Values()),
+		allowedOUs:   makeSet(envConfig.AllowedClientCertOU.Values()),
+	}, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path) //nolint:gosec // the path comes from the configuration
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errNoCertsInFile
+	}
+	return pool, nil
+}
+
+func makeSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	ret := make(map[string]bool, len(values))
+	for _, v := range values {
+		ret[v] = true
+	}
+	return ret
+}
+
+// Verify returns nil if the client certificate of a TLS connection is signed by one of the environment's
+// CAs and, if the environment restricts which certificates it accepts, matches one of the allowed names
+// or organizational units. Otherwise it returns an error describing the problem.
+func (p *Policy) Verify(state *tls.ConnectionState) error {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return errNoClientCert
+	}
+	cert := state.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, c := range state.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         p.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return err
+	}
+	if p.allowedNames == nil && p.allowedOUs == nil {
+		return nil
+	}
+	for _, name := range getCertNames(cert) {
+		if p.allowedNames[name] {
+			return nil
+		}
+	}
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		if p.allowedOUs[ou] {
+			return nil
+		}
+	}
+	return errNotAllowed
+}
+
+func getCertNames(cert *x509.Certificate) []string {
+	names := append([]string(nil), cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	return names
+}
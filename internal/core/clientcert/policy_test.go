@@ -0,0 +1,153 @@
+package clientcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	helpers "github.com/launchdarkly/go-test-helpers/v2"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func makeTestCA(t *testing.T, name string) testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return testCA{cert: cert, key: key}
+}
+
+func (ca testCA) issue(t *testing.T, template x509.Certificate) *tls.ConnectionState {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.SerialNumber = big.NewInt(2)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	der, err := x509.CreateCertificate(rand.Reader, &template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+}
+
+func (ca testCA) withFile(t *testing.T, action func(path string)) {
+	helpers.WithTempFile(func(path string) {
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+		require.NoError(t, ioutil.WriteFile(path, data, 0600))
+		action(path)
+	})
+}
+
+func TestNewPolicyReturnsNilIfClientCertsAreNotRequired(t *testing.T) {
+	p, err := NewPolicy(config.MainConfig{}, config.EnvConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+}
+
+func TestNewPolicyReturnsErrorForBadCAFile(t *testing.T) {
+	_, err := NewPolicy(config.MainConfig{TLSClientCertRequired: true, TLSClientCA: "/no/such/file"}, config.EnvConfig{})
+	assert.Error(t, err)
+
+	helpers.WithTempFile(func(path string) {
+		require.NoError(t, ioutil.WriteFile(path, []byte("not a certificate"), 0600))
+		_, err := NewPolicy(config.MainConfig{TLSClientCertRequired: true, TLSClientCA: path}, config.EnvConfig{})
+		assert.Error(t, err)
+	})
+}
+
+func TestPolicyVerifiesCertificateIsSignedByCA(t *testing.T) {
+	globalCA, envCA := makeTestCA(t, "global"), makeTestCA(t, "env")
+	globalCA.withFile(t, func(globalPath string) {
+		envCA.withFile(t, func(envPath string) {
+			mainConfig := config.MainConfig{TLSClientCertRequired: true, TLSClientCA: globalPath}
+
+			t.Run("global CA", func(t *testing.T) {
+				p, err := NewPolicy(mainConfig, config.EnvConfig{})
+				require.NoError(t, err)
+				assert.NoError(t, p.Verify(globalCA.issue(t, x509.Certificate{})))
+				assert.Error(t, p.Verify(envCA.issue(t, x509.Certificate{})))
+			})
+
+			t.Run("environment CA overrides global CA", func(t *testing.T) {
+				p, err := NewPolicy(mainConfig, config.EnvConfig{ClientCA: envPath})
+				require.NoError(t, err)
+				assert.NoError(t, p.Verify(envCA.issue(t, x509.Certificate{})))
+				assert.Error(t, p.Verify(globalCA.issue(t, x509.Certificate{})))
+			})
+
+			t.Run("no certificate", func(t *testing.T) {
+				p, err := NewPolicy(mainConfig, config.EnvConfig{})
+				require.NoError(t, err)
+				assert.Equal(t, errNoClientCert, p.Verify(nil))
+				assert.Equal(t, errNoClientCert, p.Verify(&tls.ConnectionState{}))
+			})
+		})
+	})
+}
+
+func TestPolicyChecksAllowedNamesAndOUs(t *testing.T) {
+	ca := makeTestCA(t, "ca")
+	ca.withFile(t, func(path string) {
+		mainConfig := config.MainConfig{TLSClientCertRequired: true, TLSClientCA: path}
+		envConfig := config.EnvConfig{
+			AllowedClientCertName: ct.NewOptStringList([]string{"good.example.com", "spiffe://example.com/good", "good-cn"}),
+			AllowedClientCertOU:   ct.NewOptStringList([]string{"good-team"}),
+		}
+		p, err := NewPolicy(mainConfig, envConfig)
+		require.NoError(t, err)
+
+		spiffeURL, _ := url.Parse("spiffe://example.com/good")
+		for _, allowed := range []struct {
+			name     string
+			template x509.Certificate
+		}{
+			{"DNS name", x509.Certificate{DNSNames: []string{"other.example.com", "good.example.com"}}},
+			{"URI", x509.Certificate{URIs: []*url.URL{spiffeURL}}},
+			{"common name", x509.Certificate{Subject: pkix.Name{CommonName: "good-cn"}}},
+			{"OU", x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"good-team"}}}},
+		} {
+			t.Run("allowed "+allowed.name, func(t *testing.T) {
+				assert.NoError(t, p.Verify(ca.issue(t, allowed.template)))
+			})
+		}
+
+		t.Run("not allowed", func(t *testing.T) {
+			state := ca.issue(t, x509.Certificate{
+				DNSNames: []string{"bad.example.com"},
+				Subject:  pkix.Name{CommonName: "bad-cn", OrganizationalUnit: []string{"bad-team"}},
+			})
+			assert.Equal(t, errNotAllowed, p.Verify(state))
+		})
+	})
+}
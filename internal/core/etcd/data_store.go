@@ -0,0 +1,397 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	// maxTxnOps is the default limit of the etcd server on the number of operations in a transaction.
+	maxTxnOps = 128
+
+	reloadDelay = time.Second
+)
+
+var errUpsertConflict = errors.New("etcd item kept being modified by another writer until Upsert timed out") //nolint:gochecknoglobals
+
+// DataStoreFactory is a factory for the etcd implementation of the SDK's PersistentDataStore. It should
+// be wrapped with ldcomponents.PersistentDataStore(); since the store is updated by watching etcd, it
+// does not need a cache, so its CacheTime should be zero.
+type DataStoreFactory struct {
+	dbConfig config.EtcdConfig
+	prefix   string
+}
+
+// DataStore returns a DataStoreFactory for the given configuration. If prefix is empty, DefaultPrefix
+// is used.
+func DataStore(dbConfig config.EtcdConfig, prefix string) DataStoreFactory {
+	return DataStoreFactory{dbConfig: dbConfig, prefix: EffectivePrefix(prefix)}
+}
+
+// CreatePersistentDataStore is called by the SDK to create the data store implementation object. It
+// does not wait for etcd to be available; the watch is started in the background.
+func (f DataStoreFactory) CreatePersistentDataStore(
+	context interfaces.ClientContext,
+) (interfaces.PersistentDataStore, error) {
+	clientConfig, err := MakeClientConfig(f.dbConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := clientv3.New(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	loggers := context.GetLogging().GetLoggers()
+	loggers.SetPrefix("EtcdDataStore:")
+	return newDataStoreImpl(client, keyspace(f.prefix), loggers), nil
+}
+
+// DescribeConfiguration is used internally by the SDK to inspect the configuration.
+func (f DataStoreFactory) DescribeConfiguration() ldvalue.Value {
+	return ldvalue.String("Etcd")
+}
+
+type dataStoreImpl struct {
+	client   *clientv3.Client
+	keys     keyspace
+	loggers  ldlog.Loggers
+	items    map[string]map[string]ldstoretypes.SerializedItemDescriptor
+	inited   bool
+	loaded   bool
+	revision int64
+	advanced chan struct{} // closed and replaced whenever revision changes
+	lock     sync.RWMutex
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func newDataStoreImpl(client *clientv3.Client, keys keyspace, loggers ldlog.Loggers) *dataStoreImpl {
+	ctx, cancel := context.WithCancel(context.Background())
+	store := &dataStoreImpl{
+		client:   client,
+		keys:     keys,
+		loggers:  loggers,
+		advanced: make(chan struct{}),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go store.run(ctx)
+	return store
+}
+
+// run keeps the store's copy of the data up to date until the context is cancelled. It loads all of
+// the environment's keys and then watches for changes after that revision; if the watch fails, for
+// instance because the revision has been compacted, it starts over.
+func (store *dataStoreImpl) run(ctx context.Context) {
+	defer close(store.done)
+	for ctx.Err() == nil {
+		revision, err := store.load(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				store.loggers.Warnf("Unable to load data from etcd, will retry: %s", err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(reloadDelay):
+			}
+			continue
+		}
+		if err := store.watch(ctx, revision); err != nil && ctx.Err() == nil {
+			store.loggers.Warnf("etcd watch failed, will reload data: %s", err)
+		}
+	}
+}
+
+func (store *dataStoreImpl) load(ctx context.Context) (int64, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	resp, err := store.client.Get(reqCtx, store.keys.all(), clientv3.WithPrefix())
+	if err != nil {
+		return 0, err
+	}
+	items := make(map[string]map[string]ldstoretypes.SerializedItemDescriptor)
+	inited := false
+	for _, kv := range resp.Kvs {
+		if string(kv.Key) == store.keys.inited() {
+			inited = true
+		} else if kind, key, ok := store.keys.parse(string(kv.Key)); ok {
+			store.setItem(items, kind, key, kv.Value)
+		}
+	}
+
+	store.lock.Lock()
+	store.items, store.inited, store.loaded = items, inited, true
+	store.advance(resp.Header.Revision)
+	store.lock.Unlock()
+	return resp.Header.Revision, nil
+}
+
+func (store *dataStoreImpl) watch(ctx context.Context, revision int64) error {
+	watchCh := store.client.Watch(clientv3.WithRequireLeader(ctx), store.keys.all(),
+		clientv3.WithPrefix(), clientv3.WithRev(revision+1))
+	for resp := range watchCh {
+		if err := resp.Err(); err != nil {
+			return err
+		}
+		store.lock.Lock()
+		for _, event := range resp.Events {
+			etcdKey := string(event.Kv.Key)
+			put := event.Type == clientv3.EventTypePut
+			if etcdKey == store.keys.inited() {
+				store.inited = put
+			} else if kind, key, ok := store.keys.parse(etcdKey); ok {
+				if put {
+					store.setItem(store.items, kind, key, event.Kv.Value)
+				} else {
+					delete(store.items[kind], key)
+				}
+			}
+		}
+		store.advance(resp.Header.Revision)
+		store.lock.Unlock()
+	}
+	return nil
+}
+
+// setItem adds an item to the store's copy of the data, or to a new copy that is being loaded. The
+// caller must hold the lock if it is the store's copy.
+func (store *dataStoreImpl) setItem(
+	items map[string]map[string]ldstoretypes.SerializedItemDescriptor,
+	kind, key string,
+	value []byte,
+) {
+	item, err := decodeItem(value)
+	if err != nil {
+		store.loggers.Errorf("Ignoring invalid data for %q in %q: %s", key, kind, err)
+		return
+	}
+	if items[kind] == nil {
+		items[kind] = make(map[string]ldstoretypes.SerializedItemDescriptor)
+	}
+	items[kind][key] = item
+}
+
+// advance records that the store's copy of the data reflects the given revision. The caller must hold
+// the lock.
+func (store *dataStoreImpl) advance(revision int64) {
+	if revision > store.revision {
+		store.revision = revision
+		close(store.advanced)
+		store.advanced = make(chan struct{})
+	}
+}
+
+// waitForRevision waits until the store's copy of the data reflects a write that we made, so that a
+// read immediately after the write will see it. If that takes too long, it logs a warning and returns
+// anyway, since the write itself has succeeded.
+func (store *dataStoreImpl) waitForRevision(revision int64) {
+	timer := time.NewTimer(requestTimeout)
+	defer timer.Stop()
+	for {
+		store.lock.RLock()
+		current, advanced := store.revision, store.advanced
+		store.lock.RUnlock()
+		if current >= revision {
+			return
+		}
+		select {
+		case <-advanced:
+		case <-timer.C:
+			store.loggers.Warnf("Timed out waiting for etcd revision %d to be watched", revision)
+			return
+		}
+	}
+}
+
+// Init writes all of the items, deletes any existing items of the same kinds that were not in the new
+// data, and then sets the $inited marker. etcd limits the size of a transaction, so this is done in
+// batches and is not atomic; if it fails partway through, the SDK will retry it.
+func (store *dataStoreImpl) Init(allData []ldstoretypes.SerializedCollection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	var ops []clientv3.Op
+	numItems := 0
+	for _, coll := range allData {
+		kind := coll.Kind.GetName()
+		newKeys := make(map[string]bool, len(coll.Items))
+		for _, item := range coll.Items {
+			etcdKey := store.keys.item(kind, item.Key)
+			ops = append(ops, clientv3.OpPut(etcdKey, encodeItem(item.Item)))
+			newKeys[etcdKey] = true
+		}
+		numItems += len(coll.Items)
+
+		resp, err := store.client.Get(ctx, store.keys.kind(kind), clientv3.WithPrefix(), clientv3.WithKeysOnly())
+		if err != nil {
+			return err
+		}
+		for _, kv := range resp.Kvs {
+			if !newKeys[string(kv.Key)] {
+				ops = append(ops, clientv3.OpDelete(string(kv.Key)))
+			}
+		}
+	}
+	ops = append(ops, clientv3.OpPut(store.keys.inited(), ""))
+
+	var revision int64
+	for len(ops) > 0 {
+		batch := ops
+		if len(batch) > maxTxnOps {
+			batch = batch[:maxTxnOps]
+		}
+		ops = ops[len(batch):]
+		resp, err := store.client.Txn(ctx).Then(batch...).Commit()
+		if err != nil {
+			return err
+		}
+		revision = resp.Header.Revision
+	}
+	store.waitForRevision(revision)
+
+	store.loggers.Infof("Initialized with %d items", numItems)
+	return nil
+}
+
+func (store *dataStoreImpl) Get(
+	kind ldstoretypes.DataKind,
+	key string,
+) (ldstoretypes.SerializedItemDescriptor, error) {
+	store.lock.RLock()
+	if store.loaded {
+		item, ok := store.items[kind.GetName()][key]
+		store.lock.RUnlock()
+		if !ok {
+			return ldstoretypes.SerializedItemDescriptor{}.NotFound(), nil
+		}
+		return item, nil
+	}
+	store.lock.RUnlock()
+
+	// The data has not been loaded yet, so query etcd directly.
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	resp, err := store.client.Get(ctx, store.keys.item(kind.GetName(), key))
+	if err != nil || len(resp.Kvs) == 0 {
+		return ldstoretypes.SerializedItemDescriptor{}.NotFound(), err
+	}
+	return decodeItem(resp.Kvs[0].Value)
+}
+
+func (store *dataStoreImpl) GetAll(
+	kind ldstoretypes.DataKind,
+) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	store.lock.RLock()
+	if store.loaded {
+		items := store.items[kind.GetName()]
+		results := make([]ldstoretypes.KeyedSerializedItemDescriptor, 0, len(items))
+		for key, item := range items {
+			results = append(results, ldstoretypes.KeyedSerializedItemDescriptor{Key: key, Item: item})
+		}
+		store.lock.RUnlock()
+		return results, nil
+	}
+	store.lock.RUnlock()
+
+	// The data has not been loaded yet, so query etcd directly.
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	resp, err := store.client.Get(ctx, store.keys.kind(kind.GetName()), clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ldstoretypes.KeyedSerializedItemDescriptor, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		if _, key, ok := store.keys.parse(string(kv.Key)); ok {
+			item, err := decodeItem(kv.Value)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, ldstoretypes.KeyedSerializedItemDescriptor{Key: key, Item: item})
+		}
+	}
+	return results, nil
+}
+
+// Upsert reads the item from etcd rather than from the store's copy of the data, and then writes it
+// in a transaction that only succeeds if the item has not been modified since it was read. If it was,
+// the version comparison has to be done again.
+func (store *dataStoreImpl) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	newItem ldstoretypes.SerializedItemDescriptor,
+) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	etcdKey := store.keys.item(kind.GetName(), key)
+	for ctx.Err() == nil {
+		resp, err := store.client.Get(ctx, etcdKey)
+		if err != nil {
+			return false, err
+		}
+		var modRevision int64 // zero if the key does not exist
+		if len(resp.Kvs) != 0 {
+			modRevision = resp.Kvs[0].ModRevision
+			if oldItem, err := decodeItem(resp.Kvs[0].Value); err == nil && oldItem.Version >= newItem.Version {
+				if store.loggers.IsDebugEnabled() {
+					store.loggers.Debugf(`Attempted to update key: %s in "%s" with a version that is the same or older: %d`,
+						key, kind, newItem.Version)
+				}
+				return false, nil
+			}
+		}
+		txnResp, err := store.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(etcdKey), "=", modRevision)).
+			Then(clientv3.OpPut(etcdKey, encodeItem(newItem))).
+			Commit()
+		if err != nil {
+			return false, err
+		}
+		if txnResp.Succeeded {
+			store.waitForRevision(txnResp.Header.Revision)
+			return true, nil
+		}
+	}
+	return false, errUpsertConflict
+}
+
+// IsInitialized only trusts the store's copy of the data if it says the store is initialized, since
+// another instance may have just initialized it and the watch may not have caught up yet. The SDK
+// stops calling this once it returns true.
+func (store *dataStoreImpl) IsInitialized() bool {
+	store.lock.RLock()
+	inited := store.inited
+	store.lock.RUnlock()
+	if inited {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	resp, err := store.client.Get(ctx, store.keys.inited(), clientv3.WithCountOnly())
+	return err == nil && resp.Count > 0
+}
+
+func (store *dataStoreImpl) IsStoreAvailable() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_, err := store.client.Get(ctx, store.keys.inited(), clientv3.WithCountOnly())
+	return err == nil
+}
+
+func (store *dataStoreImpl) Close() error {
+	store.cancel()
+	err := store.client.Close()
+	<-store.done
+	return err
+}
@@ -0,0 +1,150 @@
+package etcd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// Implementation notes:
+//
+// - Each environment's keys begin with the environment's prefix, which defaults to DefaultPrefix,
+// followed by a slash.
+// - Feature flags, segments, and any other kind of entity the SDK may wish to store have the key
+// "prefix/kind/key". The value is a JSON object containing the version number, the deleted flag, and the
+// serialized item, so that Upsert can check the version without parsing the item.
+// - Deleted items are kept as tombstones, with deleted set to true.
+// - The "$inited" marker is the key "prefix/$inited".
+// - Rather than reading from etcd for every query, the data store keeps a copy of all of the
+// environment's keys, which it updates by watching the prefix. So there is no need for a local cache
+// with a TTL: changes made by other Relay instances are seen as soon as etcd reports them.
+
+const (
+	// DefaultPrefix is the prefix that is used if an environment does not specify one.
+	DefaultPrefix = "launchdarkly"
+
+	initedKey = "$inited"
+
+	// requestTimeout is the maximum time for any single request to etcd, and also how long a write
+	// will wait to be reflected in the watched copy of the data before returning anyway.
+	requestTimeout = 10 * time.Second
+)
+
+var errNoCertsInCAFile = errors.New("etcd TLS CA file does not contain any PEM certificates") //nolint:gochecknoglobals
+
+// EffectivePrefix returns the prefix that will be used for an environment, which is DefaultPrefix if
+// the environment did not specify one.
+func EffectivePrefix(prefix string) string {
+	if prefix == "" {
+		return DefaultPrefix
+	}
+	return prefix
+}
+
+// MakeClientConfig transforms the configuration properties into a configuration for the etcd client.
+func MakeClientConfig(dbConfig config.EtcdConfig) (clientv3.Config, error) {
+	clientConfig := clientv3.Config{
+		Endpoints:   dbConfig.Endpoints.Values(),
+		Username:    dbConfig.Username,
+		Password:    dbConfig.Password,
+		DialTimeout: dbConfig.DialTimeout.GetOrElse(0),
+		Logger:      zap.NewNop(),
+	}
+	if dbConfig.TLS {
+		tlsConfig, err := makeTLSConfig(dbConfig)
+		if err != nil {
+			return clientv3.Config{}, err
+		}
+		clientConfig.TLS = tlsConfig
+	}
+	return clientConfig, nil
+}
+
+func makeTLSConfig(dbConfig config.EtcdConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{} //nolint:gosec // the minimum version is the Go default
+	if dbConfig.TLSCAFile != "" {
+		data, err := ioutil.ReadFile(dbConfig.TLSCAFile) //nolint:gosec // the path comes from the configuration
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			return nil, errNoCertsInCAFile
+		}
+	}
+	if dbConfig.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(dbConfig.TLSCertFile, dbConfig.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+type itemValue struct {
+	Version int    `json:"version"`
+	Deleted bool   `json:"deleted,omitempty"`
+	Data    string `json:"data,omitempty"`
+}
+
+func encodeItem(item ldstoretypes.SerializedItemDescriptor) string {
+	data, _ := json.Marshal(itemValue{Version: item.Version, Deleted: item.Deleted, Data: string(item.SerializedItem)})
+	return string(data)
+}
+
+func decodeItem(value []byte) (ldstoretypes.SerializedItemDescriptor, error) {
+	var v itemValue
+	if err := json.Unmarshal(value, &v); err != nil {
+		return ldstoretypes.SerializedItemDescriptor{}, err
+	}
+	item := ldstoretypes.SerializedItemDescriptor{Version: v.Version, Deleted: v.Deleted}
+	if v.Data != "" {
+		item.SerializedItem = []byte(v.Data)
+	}
+	return item, nil
+}
+
+// keyspace builds and parses the etcd keys for one environment.
+type keyspace string
+
+func (k keyspace) all() string {
+	return string(k) + "/"
+}
+
+func (k keyspace) kind(kind string) string {
+	return string(k) + "/" + kind + "/"
+}
+
+func (k keyspace) item(kind, key string) string {
+	return k.kind(kind) + key
+}
+
+func (k keyspace) inited() string {
+	return string(k) + "/" + initedKey
+}
+
+// parse returns the kind and item key that an etcd key refers to, or ok = false if it is not an item
+// key. The $inited marker is not an item key.
+func (k keyspace) parse(etcdKey string) (kind, key string, ok bool) {
+	rest := strings.TrimPrefix(etcdKey, k.all())
+	if len(rest) == len(etcdKey) {
+		return "", "", false
+	}
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		return "", "", false
+	}
+	return rest[:i], rest[i+1:], true
+}
@@ -0,0 +1,84 @@
+package etcd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectivePrefix(t *testing.T) {
+	assert.Equal(t, DefaultPrefix, EffectivePrefix(""))
+	assert.Equal(t, "abc", EffectivePrefix("abc"))
+}
+
+func TestMakeClientConfig(t *testing.T) {
+	t.Run("basic properties", func(t *testing.T) {
+		c := config.EtcdConfig{
+			Endpoints:   ct.NewOptStringList([]string{"etcd1:2379", "etcd2:2379"}),
+			Username:    "me",
+			Password:    "secret",
+			DialTimeout: ct.NewOptDuration(5 * time.Second),
+		}
+		clientConfig, err := MakeClientConfig(c)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"etcd1:2379", "etcd2:2379"}, clientConfig.Endpoints)
+		assert.Equal(t, "me", clientConfig.Username)
+		assert.Equal(t, "secret", clientConfig.Password)
+		assert.Equal(t, 5*time.Second, clientConfig.DialTimeout)
+		assert.Nil(t, clientConfig.TLS)
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		clientConfig, err := MakeClientConfig(config.EtcdConfig{TLS: true})
+		require.NoError(t, err)
+		assert.NotNil(t, clientConfig.TLS)
+	})
+
+	t.Run("TLS CA file with no certificates", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.crt")
+		require.NoError(t, ioutil.WriteFile(caFile, []byte("not a certificate"), 0600))
+		_, err := MakeClientConfig(config.EtcdConfig{TLS: true, TLSCAFile: caFile})
+		assert.Equal(t, errNoCertsInCAFile, err)
+	})
+}
+
+func TestEncodeAndDecodeItem(t *testing.T) {
+	item := ldstoretypes.SerializedItemDescriptor{Version: 2, SerializedItem: []byte(`{"key":"a"}`)}
+	decoded, err := decodeItem([]byte(encodeItem(item)))
+	require.NoError(t, err)
+	assert.Equal(t, item, decoded)
+
+	deleted := ldstoretypes.SerializedItemDescriptor{Version: 3, Deleted: true}
+	assert.Equal(t, `{"version":3,"deleted":true}`, encodeItem(deleted))
+	decoded, err = decodeItem([]byte(encodeItem(deleted)))
+	require.NoError(t, err)
+	assert.Equal(t, deleted, decoded)
+
+	_, err = decodeItem([]byte("x"))
+	assert.Error(t, err)
+}
+
+func TestKeyspace(t *testing.T) {
+	keys := keyspace("env")
+	assert.Equal(t, "env/features/flag/a", keys.item("features", "flag/a"))
+	assert.Equal(t, "env/$inited", keys.inited())
+
+	kind, key, ok := keys.parse("env/features/flag/a")
+	assert.True(t, ok)
+	assert.Equal(t, "features", kind)
+	assert.Equal(t, "flag/a", key)
+
+	_, _, ok = keys.parse(keys.inited())
+	assert.False(t, ok)
+	_, _, ok = keys.parse("other/features/a")
+	assert.False(t, ok)
+}
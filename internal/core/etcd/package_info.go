@@ -0,0 +1,4 @@
+// Package etcd contains Relay's etcd integration, a persistent data store for Relay's SDK instances.
+// There is no etcd integration in the Go SDK, so this is implemented within Relay. There is no etcd
+// big segment store.
+package etcd
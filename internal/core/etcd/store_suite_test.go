@@ -0,0 +1,81 @@
+//go:build etcd_tests
+// +build etcd_tests
+
+package etcd
+
+// These tests require an etcd server on localhost:2379 that accepts connections without authentication.
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/testhelpers/storetest"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeTestConfig() config.EtcdConfig {
+	return config.EtcdConfig{Endpoints: ct.NewOptStringList([]string{"localhost:2379"})}
+}
+
+func withTestClient(action func(*clientv3.Client) error) error {
+	clientConfig, err := MakeClientConfig(makeTestConfig())
+	if err != nil {
+		return err
+	}
+	client, err := clientv3.New(clientConfig)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return action(client)
+}
+
+func clearTestData(prefix string) error {
+	return withTestClient(func(client *clientv3.Client) error {
+		_, err := client.Delete(context.Background(), keyspace(EffectivePrefix(prefix)).all(), clientv3.WithPrefix())
+		return err
+	})
+}
+
+func TestEtcdDataStore(t *testing.T) {
+	storetest.NewPersistentDataStoreTestSuite(
+		func(prefix string) interfaces.PersistentDataStoreFactory {
+			return DataStore(makeTestConfig(), prefix)
+		},
+		clearTestData,
+	).Run(t)
+}
+
+func TestEtcdDataStoreSeesChangesFromAnotherWriter(t *testing.T) {
+	prefix := "watchtest"
+	require.NoError(t, clearTestData(prefix))
+	clientConfig, err := MakeClientConfig(makeTestConfig())
+	require.NoError(t, err)
+	client, err := clientv3.New(clientConfig)
+	require.NoError(t, err)
+	store := newDataStoreImpl(client, keyspace(prefix), ldlog.NewDisabledLoggers())
+	defer store.Close()
+
+	item := ldstoretypes.SerializedItemDescriptor{Version: 1, SerializedItem: []byte(`{"key":"a"}`)}
+	require.NoError(t, withTestClient(func(other *clientv3.Client) error {
+		_, err := other.Put(context.Background(), keyspace(prefix).item("features", "a"), encodeItem(item))
+		return err
+	}))
+
+	assert.Eventually(t, func() bool {
+		store.lock.RLock()
+		defer store.lock.RUnlock()
+		return store.loaded && store.items["features"]["a"].Version == 1
+	}, time.Second*5, time.Millisecond*10)
+}
@@ -0,0 +1,160 @@
+package evalcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/streams"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/lduser"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+)
+
+// Cache holds recent evaluation responses for an environment, for a fixed TTL.
+//
+// All cached responses are discarded whenever a flag or segment changes (see WrapUpdates), so the TTL
+// only limits how long a response can be reused if something else that affects evaluations changes,
+// such as a user's Big Segment memberships when Relay is not notified of the change.
+//
+// All of its methods can be called on a nil *Cache, which caches nothing.
+type Cache struct {
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]entry
+	generation uint64
+	now        func() time.Time
+	lock       sync.Mutex
+}
+
+type entry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+type invalidatingUpdates struct {
+	target streams.EnvStreamUpdates
+	cache  *Cache
+}
+
+// NewCache creates a Cache, or returns nil if ttl is not greater than zero. If there are already
+// maxEntries unexpired responses in the cache, new ones are not added until some have expired.
+func NewCache(ttl time.Duration, maxEntries int) *Cache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &Cache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]entry),
+		now:        time.Now,
+	}
+}
+
+// Key computes the cache key for an evaluation request: the user's properties, and the options that
+// determine what the response includes. Users with the same properties have the same key regardless of
+// the order of the properties in the request.
+func Key(user lduser.User, sdkKind basictypes.SDKKind, valueOnly, withReasons bool) string {
+	data, _ := json.Marshal(user)
+	var canonical interface{}
+	if json.Unmarshal(data, &canonical) == nil {
+		data, _ = json.Marshal(canonical) // encoding/json sorts object properties
+	}
+	sum := sha256.Sum256(data)
+	return string(sdkKind) + ":" + strconv.FormatBool(valueOnly) + ":" + strconv.FormatBool(withReasons) + ":" +
+		hex.EncodeToString(sum[:])
+}
+
+// Get returns a cached response, if there is an unexpired one for the key.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(e.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.data, true
+}
+
+// Generation returns a number that changes whenever the cache is cleared. It should be called before
+// evaluating, and passed to Set, so that a response that was computed from data that has since changed
+// is not cached.
+func (c *Cache) Generation() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.generation
+}
+
+// Set caches a response, unless the cache has been cleared since generation was obtained, or the cache
+// is full.
+func (c *Cache) Set(key string, data []byte, generation uint64) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if generation != c.generation {
+		return
+	}
+	now := c.now()
+	if len(c.entries) >= c.maxEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			return
+		}
+	}
+	c.entries[key] = entry{data: data, expiresAt: now.Add(c.ttl)}
+}
+
+// Clear discards all cached responses.
+func (c *Cache) Clear() {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	c.entries = make(map[string]entry)
+	c.generation++
+	c.lock.Unlock()
+}
+
+// WrapUpdates returns an EnvStreamUpdates that clears the cache and then passes all updates on to
+// target. If c is nil, it returns target unchanged.
+func (c *Cache) WrapUpdates(target streams.EnvStreamUpdates) streams.EnvStreamUpdates {
+	if c == nil {
+		return target
+	}
+	return &invalidatingUpdates{target: target, cache: c}
+}
+
+func (u *invalidatingUpdates) SendAllDataUpdate(allData []ldstoretypes.Collection) {
+	u.cache.Clear()
+	u.target.SendAllDataUpdate(allData)
+}
+
+func (u *invalidatingUpdates) SendSingleItemUpdate(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
+	u.cache.Clear()
+	u.target.SendSingleItemUpdate(kind, key, item)
+}
+
+func (u *invalidatingUpdates) InvalidateClientSideState() {
+	u.cache.Clear()
+	u.target.InvalidateClientSideState()
+}
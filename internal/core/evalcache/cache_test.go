@@ -0,0 +1,103 @@
+package evalcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/lduser"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nullUpdates struct{}
+
+func (nullUpdates) SendAllDataUpdate([]ldstoretypes.Collection) {}
+
+func (nullUpdates) SendSingleItemUpdate(ldstoretypes.DataKind, string, ldstoretypes.ItemDescriptor) {}
+
+func (nullUpdates) InvalidateClientSideState() {}
+
+func makeTestCache(maxEntries int) (*Cache, *time.Time) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewCache(time.Second, maxEntries)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestNewCacheReturnsNilIfTTLIsNotSet(t *testing.T) {
+	c := NewCache(0, 10)
+	assert.Nil(t, c)
+	c.Set("a", []byte("x"), c.Generation())
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	c.Clear()
+	target := nullUpdates{}
+	assert.Equal(t, target, c.WrapUpdates(target))
+}
+
+func TestCacheReturnsResponseUntilTTLExpires(t *testing.T) {
+	c, now := makeTestCache(10)
+	c.Set("a", []byte("x"), c.Generation())
+
+	data, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "x", string(data))
+
+	*now = now.Add(time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestCacheIsClearedByUpdates(t *testing.T) {
+	c, _ := makeTestCache(10)
+	updates := c.WrapUpdates(nullUpdates{})
+
+	c.Set("a", []byte("x"), c.Generation())
+	updates.SendSingleItemUpdate(ldstoreimpl.Features(), "flag", ldstoretypes.ItemDescriptor{Version: 1})
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	c.Set("a", []byte("x"), c.Generation())
+	updates.SendAllDataUpdate(nil)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestCacheDoesNotStoreResponseFromBeforeClear(t *testing.T) {
+	c, _ := makeTestCache(10)
+	generation := c.Generation()
+	c.Clear()
+	c.Set("a", []byte("x"), generation)
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestCacheDoesNotExceedMaxEntries(t *testing.T) {
+	c, now := makeTestCache(2)
+	c.Set("a", []byte("x"), c.Generation())
+	c.Set("b", []byte("x"), c.Generation())
+	c.Set("c", []byte("x"), c.Generation())
+	_, ok := c.Get("c")
+	assert.False(t, ok)
+
+	*now = now.Add(time.Second) // expired entries are removed to make room
+	c.Set("c", []byte("x"), c.Generation())
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+}
+
+func TestKey(t *testing.T) {
+	user1 := lduser.NewUserBuilder("me").Custom("a", ldvalue.String("1")).Custom("b", ldvalue.String("2")).Build()
+	user2 := lduser.NewUserBuilder("me").Custom("b", ldvalue.String("2")).Custom("a", ldvalue.String("1")).Build()
+	assert.Equal(t, Key(user1, basictypes.JSClientSDK, false, false), Key(user2, basictypes.JSClientSDK, false, false))
+	assert.NotEqual(t, Key(user1, basictypes.JSClientSDK, false, false), Key(user1, basictypes.MobileSDK, false, false))
+	assert.NotEqual(t, Key(user1, basictypes.JSClientSDK, false, false), Key(user1, basictypes.JSClientSDK, true, false))
+	assert.NotEqual(t, Key(user1, basictypes.JSClientSDK, false, false), Key(user1, basictypes.JSClientSDK, false, true))
+	assert.NotEqual(t, Key(user1, basictypes.JSClientSDK, false, false),
+		Key(lduser.NewUser("you"), basictypes.JSClientSDK, false, false))
+}
@@ -0,0 +1,4 @@
+// Package evalcache contains the optional cache of client-side and mobile evaluation responses, which
+// lets Relay answer identical evaluation requests that arrive within a short time without evaluating
+// every flag again.
+package evalcache
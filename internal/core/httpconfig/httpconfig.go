@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/tracing"
 
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
@@ -15,8 +16,10 @@ import (
 )
 
 var (
-	errNTLMProxyAuthWithoutCredentials = errors.New("NTLM proxy authentication requires username and password")
-	errProxyAuthWithoutProxyURL        = errors.New("cannot specify proxy authentication without a proxy URL")
+	errNTLMProxyAuthWithoutCredentials      = errors.New("NTLM proxy authentication requires username and password")
+	errNegotiateProxyAuthWithoutCredentials = errors.New("proxy authentication with Negotiate requires username and either password or keytab file")
+	errProxyAuthWithoutProxyURL             = errors.New("cannot specify proxy authentication without a proxy URL")
+	errProxyAuthSchemeConflict              = errors.New("cannot enable both NTLM and Negotiate proxy authentication")
 )
 
 // HTTPConfig encapsulates ProxyConfig plus any other HTTP options we may support in the future (currently none).
@@ -38,16 +41,57 @@ func NewHTTPConfig(proxyConfig config.ProxyConfig, authKey config.SDKCredential,
 		authKeyStr = authKey.GetAuthorizationHeaderValue()
 	}
 
-	if !proxyConfig.URL.IsDefined() && proxyConfig.NTLMAuth {
+	destinations, err := config.ParseProxyDestinations(proxyConfig.Destination.Values())
+	if err != nil {
+		return ret, err
+	}
+	if !proxyConfig.URL.IsDefined() && len(destinations) == 0 && (proxyConfig.NTLMAuth || proxyConfig.NegotiateAuth) {
 		return ret, errProxyAuthWithoutProxyURL
 	}
+	if proxyConfig.NTLMAuth && proxyConfig.NegotiateAuth {
+		return ret, errProxyAuthSchemeConflict
+	}
 	if proxyConfig.URL.IsDefined() {
 		loggers.Infof("Using proxy server at %s", proxyConfig.URL)
 	}
+	for _, d := range destinations {
+		loggers.Infof("Using proxy server at %s for %s", d.URL.Redacted(), d.Host)
+	}
 
 	caCertFiles := proxyConfig.CACertFiles.Values()
 
-	if proxyConfig.NTLMAuth {
+	if len(destinations) != 0 || len(proxyConfig.NoProxy.Values()) != 0 || proxyConfig.NegotiateAuth {
+		if proxyConfig.NTLMAuth && (proxyConfig.User == "" || proxyConfig.Password == "") {
+			return ret, errNTLMProxyAuthWithoutCredentials
+		}
+		if proxyConfig.NegotiateAuth && (proxyConfig.User == "" || (proxyConfig.Password == "" && proxyConfig.KeytabFile == "")) {
+			return ret, errNegotiateProxyAuthWithoutCredentials
+		}
+		transportOpts := []ldhttp.TransportOption{
+			ldhttp.ConnectTimeoutOption(ldcomponents.DefaultConnectTimeout),
+		}
+		for _, filePath := range caCertFiles {
+			if filePath != "" {
+				transportOpts = append(transportOpts, ldhttp.CACertFileOption(filePath))
+			}
+		}
+		selector := proxySelector{
+			defaultURL:   proxyConfig.URL.Get(),
+			destinations: destinations,
+			noProxy:      proxyConfig.NoProxy.Values(),
+		}
+		factory, err := newProxyRoutingHTTPClientFactory(proxyConfig, selector, transportOpts)
+		if err != nil {
+			return ret, err
+		}
+		configBuilder.HTTPClientFactory(factory)
+		if proxyConfig.NTLMAuth {
+			loggers.Info("NTLM proxy authentication enabled")
+		}
+		if proxyConfig.NegotiateAuth {
+			loggers.Info("Negotiate (Kerberos) proxy authentication enabled")
+		}
+	} else if proxyConfig.NTLMAuth {
 		if proxyConfig.User == "" || proxyConfig.Password == "" {
 			return ret, errNTLMProxyAuthWithoutCredentials
 		}
@@ -77,9 +121,8 @@ func NewHTTPConfig(proxyConfig config.ProxyConfig, authKey config.SDKCredential,
 		}
 	}
 
-	var err error
-	ret.SDKHTTPConfigFactory = configBuilder
-	ret.SDKHTTPConfig, err = configBuilder.CreateHTTPConfiguration(interfaces.BasicConfiguration{SDKKey: authKeyStr})
+	ret.SDKHTTPConfigFactory = tracingHTTPConfigurationFactory{configBuilder}
+	ret.SDKHTTPConfig, err = ret.SDKHTTPConfigFactory.CreateHTTPConfiguration(interfaces.BasicConfiguration{SDKKey: authKeyStr})
 	return ret, err
 }
 
@@ -87,3 +130,30 @@ func NewHTTPConfig(proxyConfig config.ProxyConfig, authKey config.SDKCredential,
 func (c HTTPConfig) Client() *http.Client {
 	return c.SDKHTTPConfig.CreateHTTPClient()
 }
+
+// tracingHTTPConfigurationFactory wraps the SDK's HTTP configuration so that all of the HTTP clients
+// it creates, both for the SDK's connections to LaunchDarkly and for Relay's own requests, trace their
+// requests if OpenTelemetry is enabled.
+type tracingHTTPConfigurationFactory struct {
+	wrapped interfaces.HTTPConfigurationFactory
+}
+
+type tracingHTTPConfiguration struct {
+	interfaces.HTTPConfiguration
+}
+
+func (f tracingHTTPConfigurationFactory) CreateHTTPConfiguration(
+	basicConfig interfaces.BasicConfiguration,
+) (interfaces.HTTPConfiguration, error) {
+	c, err := f.wrapped.CreateHTTPConfiguration(basicConfig)
+	if err != nil {
+		return nil, err
+	}
+	return tracingHTTPConfiguration{c}, nil
+}
+
+func (c tracingHTTPConfiguration) CreateHTTPClient() *http.Client {
+	client := c.HTTPConfiguration.CreateHTTPClient()
+	client.Transport = tracing.Transport(client.Transport)
+	return client
+}
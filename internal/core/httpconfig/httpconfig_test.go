@@ -137,3 +137,102 @@ func TestNTLMProxyInvalidConfigs(t *testing.T) {
 		}
 	})
 }
+
+func TestNegotiateProxyInvalidConfigs(t *testing.T) {
+	proxyConfig1 := config.ProxyConfig{NegotiateAuth: true}
+	_, err := NewHTTPConfig(proxyConfig1, nil, "", ldlog.NewDisabledLoggers())
+	assert.Equal(t, errProxyAuthWithoutProxyURL, err)
+
+	proxyConfig2 := proxyConfig1
+	proxyConfig2.URL, _ = configtypes.NewOptURLAbsoluteFromString("http://fake-proxy")
+	_, err = NewHTTPConfig(proxyConfig2, nil, "", ldlog.NewDisabledLoggers())
+	assert.Equal(t, errNegotiateProxyAuthWithoutCredentials, err)
+
+	proxyConfig3 := proxyConfig2
+	proxyConfig3.User = "user"
+	proxyConfig3.KeytabFile = "not-a-file"
+	proxyConfig3.KerberosConfig = "not-a-file"
+	_, err = NewHTTPConfig(proxyConfig3, nil, "", ldlog.NewDisabledLoggers())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unable to load Kerberos configuration")
+	}
+
+	proxyConfig4 := proxyConfig3
+	proxyConfig4.NTLMAuth = true
+	_, err = NewHTTPConfig(proxyConfig4, nil, "", ldlog.NewDisabledLoggers())
+	assert.Equal(t, errProxyAuthSchemeConflict, err)
+}
+
+func TestProxyDestinations(t *testing.T) {
+	defaultHandler, defaultRequestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(http.StatusOK))
+	eventsHandler, eventsRequestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(http.StatusAccepted))
+
+	httphelpers.WithServer(defaultHandler, func(defaultProxy *httptest.Server) {
+		httphelpers.WithServer(eventsHandler, func(eventsProxy *httptest.Server) {
+			proxyConfig := config.ProxyConfig{}
+			proxyConfig.URL, _ = configtypes.NewOptURLAbsoluteFromString(defaultProxy.URL)
+			proxyConfig.Destination = configtypes.NewOptStringList([]string{"events.fake-url=" + eventsProxy.URL})
+			mockLog := ldlogtest.NewMockLog()
+			hc, err := NewHTTPConfig(proxyConfig, nil, "", mockLog.Loggers)
+			require.NoError(t, err)
+
+			mockLog.AssertMessageMatch(t, true, ldlog.Info, "Using proxy server at "+eventsProxy.URL+" for events.fake-url")
+
+			client := hc.Client()
+			resp, err := client.Get("http://events.fake-url/bulk")
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+			assert.Equal(t, "http://events.fake-url/bulk", (<-eventsRequestsCh).Request.URL.String())
+
+			resp, err = client.Get("http://stream.fake-url/all")
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "http://stream.fake-url/all", (<-defaultRequestsCh).Request.URL.String())
+		})
+	})
+}
+
+func TestNoProxy(t *testing.T) {
+	proxyHandler, proxyRequestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(http.StatusOK))
+	targetHandler, targetRequestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(http.StatusAccepted))
+
+	httphelpers.WithServer(proxyHandler, func(proxy *httptest.Server) {
+		httphelpers.WithServer(targetHandler, func(target *httptest.Server) {
+			proxyConfig := config.ProxyConfig{}
+			proxyConfig.URL, _ = configtypes.NewOptURLAbsoluteFromString(proxy.URL)
+			proxyConfig.NoProxy = configtypes.NewOptStringList([]string{"127.0.0.0/8"})
+			hc, err := NewHTTPConfig(proxyConfig, nil, "", ldlog.NewDisabledLoggers())
+			require.NoError(t, err)
+
+			resp, err := hc.Client().Get(target.URL)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+			<-targetRequestsCh
+			assert.Len(t, proxyRequestsCh, 0)
+		})
+	})
+}
+
+func TestHostMatches(t *testing.T) {
+	for _, p := range []struct {
+		pattern, host string
+		expected      bool
+	}{
+		{"*", "example.com", true},
+		{"example.com", "example.com", true},
+		{"example.com", "stream.Example.com", true},
+		{"example.com", "notexample.com", false},
+		{".example.com", "example.com", false},
+		{".example.com", "stream.example.com", true},
+		{"*.example.com", "stream.example.com", true},
+		{"10.0.0.0/8", "10.1.2.3", true},
+		{"10.0.0.0/8", "192.168.0.1", false},
+		{"10.0.0.0/8", "example.com", false},
+		{"192.168.0.1", "192.168.0.1", true},
+		{"192.168.0.1", "192.168.0.2", false},
+	} {
+		t.Run(p.pattern+" "+p.host, func(t *testing.T) {
+			assert.Equal(t, p.expected, hostMatches(p.pattern, p.host))
+		})
+	}
+}
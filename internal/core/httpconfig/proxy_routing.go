@@ -0,0 +1,222 @@
+package httpconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	krbclient "github.com/jcmturner/gokrb5/v8/client"
+	krbconfig "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	ntlm "github.com/launchdarkly/go-ntlm-proxy-auth"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldhttp"
+)
+
+const defaultKerberosConfigFile = "/etc/krb5.conf"
+
+// proxySelector chooses the proxy for each outbound connection, according to the URL, Destination, and
+// NoProxy options of ProxyConfig.
+type proxySelector struct {
+	defaultURL   *url.URL
+	destinations []config.ProxyDestination
+	noProxy      []string
+}
+
+// proxyFor returns the proxy to use for the specified host, or nil to connect directly. If the second
+// return value is false, none of the options apply to this host, so the standard HTTP_PROXY, HTTPS_PROXY,
+// and NO_PROXY environment variables should be used instead.
+func (s proxySelector) proxyFor(host string) (*url.URL, bool) {
+	for _, pattern := range s.noProxy {
+		if hostMatches(pattern, host) {
+			return nil, true
+		}
+	}
+	for _, d := range s.destinations {
+		if hostMatches(d.Host, host) {
+			return d.URL, true
+		}
+	}
+	if s.defaultURL != nil {
+		return s.defaultURL, true
+	}
+	return nil, false
+}
+
+func (s proxySelector) proxyFunc(req *http.Request) (*url.URL, error) {
+	if u, ok := s.proxyFor(req.URL.Hostname()); ok {
+		return u, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+func (s proxySelector) allProxies() []*url.URL {
+	var ret []*url.URL
+	if s.defaultURL != nil {
+		ret = append(ret, s.defaultURL)
+	}
+	for _, d := range s.destinations {
+		ret = append(ret, d.URL)
+	}
+	return ret
+}
+
+// hostMatches tests whether a host name or IP address matches a pattern, using the same rules as the
+// standard NO_PROXY variable: "*" matches everything; "example.com" matches that domain and all of its
+// subdomains; ".example.com" or "*.example.com" matches only the subdomains; and an IP address or CIDR
+// range matches IP addresses.
+func hostMatches(pattern, host string) bool {
+	pattern, host = strings.ToLower(strings.TrimSpace(pattern)), strings.ToLower(host)
+	if pattern == "*" {
+		return true
+	}
+	if _, ipNet, err := net.ParseCIDR(pattern); err == nil {
+		ip := net.ParseIP(host)
+		return ip != nil && ipNet.Contains(ip)
+	}
+	if ip := net.ParseIP(pattern); ip != nil {
+		return ip.Equal(net.ParseIP(host))
+	}
+	pattern = strings.TrimPrefix(pattern, "*")
+	if strings.HasPrefix(pattern, ".") {
+		return strings.HasSuffix(host, pattern)
+	}
+	return host == pattern || strings.HasSuffix(host, "."+pattern)
+}
+
+// negotiateAuth creates Proxy-Authorization headers for the Negotiate scheme, using Kerberos.
+type negotiateAuth struct {
+	client *krbclient.Client
+}
+
+func newNegotiateAuth(proxyConfig config.ProxyConfig) (*negotiateAuth, error) {
+	confPath := proxyConfig.KerberosConfig
+	if confPath == "" {
+		confPath = defaultKerberosConfigFile
+	}
+	krbConf, err := krbconfig.Load(confPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load Kerberos configuration from %q: %w", confPath, err)
+	}
+	realm := proxyConfig.Domain
+	if realm == "" {
+		realm = krbConf.LibDefaults.DefaultRealm
+	}
+	var client *krbclient.Client
+	if proxyConfig.KeytabFile != "" {
+		kt, err := keytab.Load(proxyConfig.KeytabFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load Kerberos keytab from %q: %w", proxyConfig.KeytabFile, err)
+		}
+		client = krbclient.NewWithKeytab(proxyConfig.User, realm, kt, krbConf, krbclient.DisablePAFXFAST(true))
+	} else {
+		client = krbclient.NewWithPassword(proxyConfig.User, realm, proxyConfig.Password, krbConf,
+			krbclient.DisablePAFXFAST(true))
+	}
+	return &negotiateAuth{client: client}, nil
+}
+
+// header returns a Proxy-Authorization value for the proxy, whose service principal is assumed to be
+// HTTP/ followed by its host name, as is usual for HTTP services.
+func (a *negotiateAuth) header(proxyURL *url.URL) (string, error) {
+	s := spnego.SPNEGOClient(a.client, "HTTP/"+proxyURL.Hostname())
+	if err := s.AcquireCred(); err != nil {
+		return "", fmt.Errorf("unable to get Kerberos credentials for proxy: %w", err)
+	}
+	token, err := s.InitSecContext()
+	if err != nil {
+		return "", fmt.Errorf("unable to get Kerberos ticket for proxy %s: %w", proxyURL.Host, err)
+	}
+	data, err := token.Marshal()
+	if err != nil {
+		return "", err
+	}
+	return "Negotiate " + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// negotiateProxyTransport adds a Proxy-Authorization header to plain HTTP requests that are sent through
+// a proxy. HTTPS requests are authenticated when the tunnel is opened, with GetProxyConnectHeader.
+type negotiateProxyTransport struct {
+	*http.Transport
+	auth *negotiateAuth
+}
+
+func (t negotiateProxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		if proxyURL, err := t.Proxy(req); err == nil && proxyURL != nil {
+			value, err := t.auth.header(proxyURL)
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set("Proxy-Authorization", value)
+		}
+	}
+	return t.Transport.RoundTrip(req)
+}
+
+// newProxyRoutingHTTPClientFactory creates HTTP clients that can use a different proxy for each
+// destination, and authenticate to proxies with either NTLM or Negotiate.
+func newProxyRoutingHTTPClientFactory(
+	proxyConfig config.ProxyConfig,
+	selector proxySelector,
+	transportOpts []ldhttp.TransportOption,
+) (func() *http.Client, error) {
+	// Try creating a transport with these options just to make sure it's valid before we get any farther
+	if _, _, err := ldhttp.NewHTTPTransport(transportOpts...); err != nil {
+		return nil, err
+	}
+	var auth *negotiateAuth
+	if proxyConfig.NegotiateAuth {
+		var err error
+		if auth, err = newNegotiateAuth(proxyConfig); err != nil {
+			return nil, err
+		}
+	}
+	return func() *http.Client {
+		client := *http.DefaultClient
+		transport, dialer, err := ldhttp.NewHTTPTransport(transportOpts...)
+		if err != nil {
+			return &client
+		}
+		switch {
+		case proxyConfig.NTLMAuth:
+			// NTLM authenticates the connection rather than the request, so the proxy is chosen when
+			// dialing, and each request is sent through a tunnel.
+			ntlmDialers := make(map[string]ntlm.DialContext)
+			for _, u := range selector.allProxies() {
+				ntlmDialers[u.String()] = ntlm.NewNTLMProxyDialContext(dialer, *u,
+					proxyConfig.User, proxyConfig.Password, proxyConfig.Domain, transport.TLSClientConfig)
+			}
+			transport.Proxy = nil
+			transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				host, _, _ := net.SplitHostPort(addr)
+				if u, _ := selector.proxyFor(host); u != nil {
+					return ntlmDialers[u.String()](ctx, network, addr)
+				}
+				return dialer.DialContext(ctx, network, addr)
+			}
+			client.Transport = transport
+		case auth != nil:
+			transport.Proxy = selector.proxyFunc
+			transport.GetProxyConnectHeader = func(ctx context.Context, proxyURL *url.URL, target string) (http.Header, error) {
+				value, err := auth.header(proxyURL)
+				if err != nil {
+					return nil, err
+				}
+				return http.Header{"Proxy-Authorization": {value}}, nil
+			}
+			client.Transport = negotiateProxyTransport{Transport: transport, auth: auth}
+		default:
+			transport.Proxy = selector.proxyFunc
+			client.Transport = transport
+		}
+		return &client
+	}, nil
+}
@@ -0,0 +1,253 @@
+package httpconfig
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+)
+
+// failuresBeforeFailover is the number of consecutive failed requests to an upstream URI that make
+// UpstreamFailover switch to the next one. A single failure is often just a dropped connection.
+const failuresBeforeFailover = 3
+
+// UpstreamFailover sends requests that are meant for one of Relay's upstream URIs (the streaming, polling,
+// and events URIs) to a fallback URI instead, if requests to the configured URI have been failing.
+//
+// While a fallback is in use, the primary URI is checked periodically, and requests go back to it once it
+// responds again. Connections that are already open to a fallback, such as streams, are not interrupted;
+// they use the primary URI the next time they reconnect.
+type UpstreamFailover struct {
+	groups  []*failoverGroup
+	client  *http.Client
+	closeCh chan struct{}
+	loggers ldlog.Loggers
+}
+
+type failoverGroup struct {
+	uris     []*url.URL // the first one is the primary URI
+	active   int
+	failures int
+	lock     sync.Mutex
+}
+
+// NewUpstreamFailover creates an UpstreamFailover for the fallback URIs in the configuration, and starts
+// checking the health of the primary URIs. It returns nil if no fallback URIs are configured.
+//
+// The client is used for the health checks. It should not itself be using the UpstreamFailover.
+func NewUpstreamFailover(c config.Config, client *http.Client, loggers ldlog.Loggers) *UpstreamFailover {
+	var groups []*failoverGroup
+	for _, p := range []struct {
+		primary   ct.OptURLAbsolute
+		fallbacks []string
+	}{
+		{c.Main.StreamURI, c.Main.StreamURIFallback.Values()},
+		{c.Main.BaseURI, c.Main.BaseURIFallback.Values()},
+		{c.Events.EventsURI, c.Events.EventsURIFallback.Values()},
+	} {
+		if !p.primary.IsDefined() || len(p.fallbacks) == 0 {
+			continue
+		}
+		g := &failoverGroup{uris: []*url.URL{p.primary.Get()}}
+		for _, f := range p.fallbacks {
+			if u, err := url.Parse(f); err == nil { // config.ValidateConfig has ensured that this is valid
+				g.uris = append(g.uris, u)
+			}
+		}
+		groups = append(groups, g)
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+	f := &UpstreamFailover{
+		groups:  groups,
+		client:  client,
+		closeCh: make(chan struct{}),
+		loggers: loggers,
+	}
+	go f.checkPrimaries(c.Main.UpstreamHealthCheckInterval.GetOrElse(config.DefaultUpstreamHealthCheckInterval))
+	return f
+}
+
+// Close stops the health checks.
+func (f *UpstreamFailover) Close() {
+	close(f.closeCh)
+}
+
+// Transport returns an http.RoundTripper that sends requests through the wrapped RoundTripper, replacing
+// the primary URI of each upstream service with whichever URI is currently in use for that service.
+func (f *UpstreamFailover) Transport(wrapped http.RoundTripper) http.RoundTripper {
+	if wrapped == nil {
+		wrapped = http.DefaultTransport
+	}
+	return failoverTransport{failover: f, wrapped: wrapped}
+}
+
+func (f *UpstreamFailover) checkPrimaries(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.closeCh:
+			return
+		case <-ticker.C:
+		}
+		for _, g := range f.groups {
+			g.lock.Lock()
+			active := g.active
+			g.lock.Unlock()
+			if active == 0 {
+				continue
+			}
+			primary := g.uris[0]
+			if !f.isAvailable(primary) {
+				continue
+			}
+			g.lock.Lock()
+			if g.active != 0 {
+				f.loggers.Infof("Upstream %s is available again; switching back to it from %s", primary, g.uris[g.active])
+				g.active = 0
+				g.failures = 0
+			}
+			g.lock.Unlock()
+		}
+	}
+}
+
+// isAvailable tests whether a server responds at all. Any status other than a 5xx error means that it
+// is up, since we are not making a request that it is expected to handle.
+func (f *UpstreamFailover) isAvailable(u *url.URL) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return false
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return false
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode < 500
+}
+
+// find returns the group whose primary URI is the base of the specified URL, if any, and the index of the
+// URI that is currently in use for that group.
+func (f *UpstreamFailover) find(u *url.URL) (*failoverGroup, int) {
+	for _, g := range f.groups {
+		if hasBaseURI(u, g.uris[0]) {
+			g.lock.Lock()
+			active := g.active
+			g.lock.Unlock()
+			return g, active
+		}
+	}
+	return nil, 0
+}
+
+func (f *UpstreamFailover) recordResult(g *failoverGroup, index int, failed bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if index != g.active {
+		return // another request has already caused a switch
+	}
+	if !failed {
+		g.failures = 0
+		return
+	}
+	g.failures++
+	if g.failures < failuresBeforeFailover {
+		return
+	}
+	g.active = (g.active + 1) % len(g.uris)
+	g.failures = 0
+	f.loggers.Warnf("Upstream %s appears to be unavailable; switching to %s", g.uris[index], g.uris[g.active])
+}
+
+func hasBaseURI(u, base *url.URL) bool {
+	if !strings.EqualFold(u.Scheme, base.Scheme) || !strings.EqualFold(u.Host, base.Host) {
+		return false
+	}
+	basePath := strings.TrimSuffix(base.Path, "/")
+	return u.Path == basePath || strings.HasPrefix(u.Path, basePath+"/")
+}
+
+func replaceBaseURI(u, oldBase, newBase *url.URL) *url.URL {
+	ret := *u
+	ret.Scheme = newBase.Scheme
+	ret.Host = newBase.Host
+	ret.Path = strings.TrimSuffix(newBase.Path, "/") + strings.TrimPrefix(u.Path, strings.TrimSuffix(oldBase.Path, "/"))
+	ret.RawPath = ""
+	if newBase.User != nil {
+		ret.User = newBase.User
+	}
+	return &ret
+}
+
+type failoverTransport struct {
+	failover *UpstreamFailover
+	wrapped  http.RoundTripper
+}
+
+func (t failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	g, index := t.failover.find(req.URL)
+	if g == nil {
+		return t.wrapped.RoundTrip(req)
+	}
+	if index != 0 {
+		req = req.Clone(req.Context())
+		req.URL = replaceBaseURI(req.URL, g.uris[0], g.uris[index])
+		req.Host = ""
+	}
+	resp, err := t.wrapped.RoundTrip(req)
+	if err != nil && req.Context().Err() != nil {
+		return resp, err // the caller gave up, which says nothing about the server
+	}
+	t.failover.recordResult(g, index, err != nil || resp.StatusCode >= 500)
+	return resp, err
+}
+
+type failoverHTTPConfigurationFactory struct {
+	wrapped  interfaces.HTTPConfigurationFactory
+	failover *UpstreamFailover
+}
+
+type failoverHTTPConfiguration struct {
+	interfaces.HTTPConfiguration
+	failover *UpstreamFailover
+}
+
+func (f failoverHTTPConfigurationFactory) CreateHTTPConfiguration(
+	basicConfig interfaces.BasicConfiguration,
+) (interfaces.HTTPConfiguration, error) {
+	c, err := f.wrapped.CreateHTTPConfiguration(basicConfig)
+	if err != nil {
+		return nil, err
+	}
+	return failoverHTTPConfiguration{c, f.failover}, nil
+}
+
+func (c failoverHTTPConfiguration) CreateHTTPClient() *http.Client {
+	client := c.HTTPConfiguration.CreateHTTPClient()
+	client.Transport = c.failover.Transport(client.Transport)
+	return client
+}
+
+// WithUpstreamFailover returns a copy of the HTTPConfig whose clients use the UpstreamFailover. If the
+// UpstreamFailover is nil, the HTTPConfig is returned unchanged.
+func (c HTTPConfig) WithUpstreamFailover(f *UpstreamFailover) HTTPConfig {
+	if f == nil {
+		return c
+	}
+	c.SDKHTTPConfigFactory = failoverHTTPConfigurationFactory{wrapped: c.SDKHTTPConfigFactory, failover: f}
+	c.SDKHTTPConfig = failoverHTTPConfiguration{HTTPConfiguration: c.SDKHTTPConfig, failover: f}
+	return c
+}
@@ -0,0 +1,136 @@
+package httpconfig
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-test-helpers/v2/httphelpers"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// switchableHandler returns a 503 error while it is down, and otherwise records the request.
+func switchableHandler(down *int32) (http.Handler, <-chan httphelpers.HTTPRequestInfo) {
+	recorder, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(http.StatusOK))
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.LoadInt32(down) != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		recorder.ServeHTTP(w, req)
+	}), requestsCh
+}
+
+func withUpstreamFailover(t *testing.T, primaryURL, fallbackURL string, interval time.Duration,
+	action func(*UpstreamFailover, *http.Client, *ldlogtest.MockLog)) {
+	var c config.Config
+	c.Main.StreamURI, _ = ct.NewOptURLAbsoluteFromString(primaryURL)
+	c.Main.StreamURIFallback = ct.NewOptStringList([]string{fallbackURL})
+	c.Main.UpstreamHealthCheckInterval = ct.NewOptDuration(interval)
+	mockLog := ldlogtest.NewMockLog()
+	f := NewUpstreamFailover(c, http.DefaultClient, mockLog.Loggers)
+	require.NotNil(t, f)
+	defer f.Close()
+
+	hc, err := NewHTTPConfig(config.ProxyConfig{}, nil, "", ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	action(f, hc.WithUpstreamFailover(f).Client(), mockLog)
+}
+
+func TestNewUpstreamFailoverReturnsNilWithoutFallbacks(t *testing.T) {
+	var c config.Config
+	c.Main.StreamURI, _ = ct.NewOptURLAbsoluteFromString("http://primary")
+	assert.Nil(t, NewUpstreamFailover(c, http.DefaultClient, ldlog.NewDisabledLoggers()))
+}
+
+func TestUpstreamFailoverSwitchesToFallbackAndBack(t *testing.T) {
+	var primaryDown int32 = 1
+	primaryHandler, primaryRequestsCh := switchableHandler(&primaryDown)
+	fallbackHandler, fallbackRequestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(http.StatusOK))
+
+	httphelpers.WithServer(primaryHandler, func(primary *httptest.Server) {
+		httphelpers.WithServer(fallbackHandler, func(fallback *httptest.Server) {
+			withUpstreamFailover(t, primary.URL, fallback.URL+"/mirror", time.Millisecond*50,
+				func(f *UpstreamFailover, client *http.Client, mockLog *ldlogtest.MockLog) {
+					for i := 0; i < failuresBeforeFailover; i++ {
+						resp, err := client.Get(primary.URL + "/all")
+						require.NoError(t, err)
+						assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+					}
+
+					resp, err := client.Get(primary.URL + "/all?filter=x")
+					require.NoError(t, err)
+					assert.Equal(t, http.StatusOK, resp.StatusCode)
+					req := <-fallbackRequestsCh
+					assert.Equal(t, "/mirror/all", req.Request.URL.Path)
+					assert.Equal(t, "filter=x", req.Request.URL.RawQuery)
+					mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Upstream "+primary.URL+" appears to be unavailable")
+
+					atomic.StoreInt32(&primaryDown, 0)
+					require.Eventually(t, func() bool {
+						return mockLog.HasMessageMatch(ldlog.Info, "Upstream "+primary.URL+" is available again")
+					}, time.Second*5, time.Millisecond*10)
+					for len(primaryRequestsCh) > 0 {
+						<-primaryRequestsCh // discard the health check requests
+					}
+
+					resp, err = client.Get(primary.URL + "/all")
+					require.NoError(t, err)
+					assert.Equal(t, http.StatusOK, resp.StatusCode)
+					assert.Equal(t, "/all", (<-primaryRequestsCh).Request.URL.Path)
+				})
+		})
+	})
+}
+
+func TestUpstreamFailoverDoesNotSwitchAfterOccasionalFailures(t *testing.T) {
+	var primaryDown int32 = 1
+	primaryHandler, _ := switchableHandler(&primaryDown)
+
+	httphelpers.WithServer(primaryHandler, func(primary *httptest.Server) {
+		withUpstreamFailover(t, primary.URL, "http://fallback", time.Hour,
+			func(f *UpstreamFailover, client *http.Client, mockLog *ldlogtest.MockLog) {
+				for i := 0; i < failuresBeforeFailover*2; i++ {
+					atomic.StoreInt32(&primaryDown, int32(i%2))
+					resp, err := client.Get(primary.URL + "/all")
+					require.NoError(t, err)
+					resp.Body.Close()
+				}
+				g, index := f.find(mustParseURL(primary.URL + "/all"))
+				require.NotNil(t, g)
+				assert.Equal(t, 0, index)
+			})
+	})
+}
+
+func TestUpstreamFailoverIgnoresOtherURLs(t *testing.T) {
+	withUpstreamFailover(t, "http://primary/base", "http://fallback", time.Hour,
+		func(f *UpstreamFailover, client *http.Client, mockLog *ldlogtest.MockLog) {
+			for _, u := range []string{"http://primary/other", "http://primary/basement", "https://primary/base", "http://other/base"} {
+				g, _ := f.find(mustParseURL(u))
+				assert.Nil(t, g, u)
+			}
+			for _, u := range []string{"http://primary/base", "http://PRIMARY/base/all"} {
+				g, _ := f.find(mustParseURL(u))
+				assert.NotNil(t, g, u)
+			}
+		})
+}
+
+func mustParseURL(s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -3,21 +3,27 @@ package browser
 import (
 	"context"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/events"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/jwtauth"
 )
 
 const (
 	// DefaultAllowedOrigin is the default origin string to use in CORS response headers.
 	DefaultAllowedOrigin = "*"
+
+	// DefaultMaxAge is the default value of the CORS header Access-Control-Max-Age.
+	DefaultMaxAge = time.Minute * 5
 )
 
 type corsContextKeyType string
 
 const (
 	corsContextKey corsContextKeyType = "context"
-	maxAge         string             = "300"
 )
 
 // DefaultAllowedHeaders is the default value of the CORS header Access-Control-Allow-Headers.
@@ -31,13 +37,78 @@ var DefaultAllowedHeaders = strings.Join([]string{ //nolint:gochecknoglobals
 	"X-LaunchDarkly-Wrapper",
 	events.EventSchemaHeader,
 	events.TagsHeader,
+	jwtauth.TokenHeader,
 }, ",")
 
 // CORSContext represents a scope that has a specific set of allowed origins for CORS requests. This
 // can be attached to a request context with WithCORSContext().
 type CORSContext interface {
+	// AllowedOrigins returns the configured origins, which may include patterns (see OriginMatcher).
 	AllowedOrigins() []string
+	// AllowsOrigin returns true if the origin matches any of the AllowedOrigins.
+	AllowsOrigin(origin string) bool
 	AllowedHeaders() []string
+	// MaxAge returns the value for Access-Control-Max-Age, or zero to use DefaultMaxAge.
+	MaxAge() time.Duration
+}
+
+// OriginMatcher determines whether a request origin is one of a list of allowed origins. Each of those
+// can be an exact origin such as "https://example.com"; a pattern in which "*" matches any characters,
+// such as "https://*.example.com"; or a regular expression between slashes, such as
+// "/^https://(app|www)\.example\.com$/".
+//
+// The zero value does not match any origin.
+type OriginMatcher struct {
+	exact    map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// NewOriginMatcher creates an OriginMatcher for a list of allowed origins. It returns an error if any
+// of them is an invalid regular expression.
+func NewOriginMatcher(origins []string) (OriginMatcher, error) {
+	var m OriginMatcher
+	for _, o := range origins {
+		switch {
+		case isRegexOrigin(o):
+			r, err := regexp.Compile(o[1 : len(o)-1])
+			if err != nil {
+				return OriginMatcher{}, err
+			}
+			m.patterns = append(m.patterns, r)
+		case strings.Contains(o, "*"):
+			expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(o), `\*`, ".*") + "$"
+			m.patterns = append(m.patterns, regexp.MustCompile(expr))
+		default:
+			if m.exact == nil {
+				m.exact = make(map[string]bool)
+			}
+			m.exact[o] = true
+		}
+	}
+	return m, nil
+}
+
+// Matches returns true if the origin is allowed.
+func (m OriginMatcher) Matches(origin string) bool {
+	if m.exact[origin] {
+		return true
+	}
+	for _, r := range m.patterns {
+		if r.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExactOrigin returns true if a configured allowed origin is an actual origin, rather than a pattern
+// or a regular expression, so that it can be used as the value of Access-Control-Allow-Origin.
+func IsExactOrigin(origin string) bool {
+	return !isRegexOrigin(origin) && !strings.Contains(origin, "*")
+}
+
+func isRegexOrigin(origin string) bool {
+	return len(origin) >= 2 && strings.HasPrefix(origin, "/") && strings.HasSuffix(origin, "/")
 }
 
 // GetCORSContext returns the CORSContext that has been attached to this Context with WithCORSContext(),
@@ -59,10 +130,18 @@ func WithCORSContext(parent context.Context, cc CORSContext) context.Context {
 
 // SetCORSHeaders sets a standard set of CORS headers on an HTTP response. This is meant to be the same
 // behavior that the LaunchDarkly service endpoints uses for client-side JS requests.
-func SetCORSHeaders(w http.ResponseWriter, origin string, extraAllowedHeaders []string) {
-	w.Header().Set("Access-Control-Allow-Origin", origin)
+//
+// If origin is empty, Access-Control-Allow-Origin is omitted, so that browsers will not allow the request.
+// If maxAge is zero, DefaultMaxAge is used.
+func SetCORSHeaders(w http.ResponseWriter, origin string, extraAllowedHeaders []string, maxAge time.Duration) {
+	if origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if maxAge == 0 {
+		maxAge = DefaultMaxAge
+	}
 	w.Header().Set("Access-Control-Allow-Credentials", "false")
-	w.Header().Set("Access-Control-Max-Age", maxAge)
+	w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
 	allAllowedHeaders := DefaultAllowedHeaders
 	if len(extraAllowedHeaders) > 0 {
 		allAllowedHeaders = allAllowedHeaders + "," + strings.Join(extraAllowedHeaders, ",")
@@ -5,8 +5,10 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockCORSContext struct{}
@@ -15,10 +17,18 @@ func (m mockCORSContext) AllowedOrigins() []string {
 	return nil
 }
 
+func (m mockCORSContext) AllowsOrigin(string) bool {
+	return false
+}
+
 func (m mockCORSContext) AllowedHeaders() []string {
 	return nil
 }
 
+func (m mockCORSContext) MaxAge() time.Duration {
+	return 0
+}
+
 func TestCORSContext(t *testing.T) {
 	t.Run("GetCORSContext when there is no RequestContext returns nil", func(t *testing.T) {
 		assert.Nil(t, GetCORSContext(context.Background()))
@@ -38,10 +48,10 @@ func TestCORSContext(t *testing.T) {
 	t.Run("SetCORSHeaders", func(t *testing.T) {
 		origin := "http://good.cat"
 		rr := httptest.ResponseRecorder{}
-		SetCORSHeaders(&rr, origin, nil)
+		SetCORSHeaders(&rr, origin, nil, 0)
 		assert.Equal(t, origin, rr.Header().Get("Access-Control-Allow-Origin"))
 		assert.Equal(t, "false", rr.Header().Get("Access-Control-Allow-Credentials"))
-		assert.Equal(t, maxAge, rr.Header().Get("Access-Control-Max-Age"))
+		assert.Equal(t, "300", rr.Header().Get("Access-Control-Max-Age"))
 		assert.Equal(t, DefaultAllowedHeaders, rr.Header().Get("Access-Control-Allow-Headers"))
 		assert.Equal(t, "Date", rr.Header().Get("Access-Control-Expose-Headers"))
 	})
@@ -51,11 +61,60 @@ func TestCORSContext(t *testing.T) {
 		rr := httptest.ResponseRecorder{}
 		extraHeaders := []string{"Toast", "Bread"}
 		expectedHeaders := strings.Join([]string{DefaultAllowedHeaders, "Toast,Bread"}, ",")
-		SetCORSHeaders(&rr, origin, extraHeaders)
+		SetCORSHeaders(&rr, origin, extraHeaders, 0)
 		assert.Equal(t, origin, rr.Header().Get("Access-Control-Allow-Origin"))
 		assert.Equal(t, "false", rr.Header().Get("Access-Control-Allow-Credentials"))
-		assert.Equal(t, maxAge, rr.Header().Get("Access-Control-Max-Age"))
+		assert.Equal(t, "300", rr.Header().Get("Access-Control-Max-Age"))
 		assert.Equal(t, expectedHeaders, rr.Header().Get("Access-Control-Allow-Headers"))
 		assert.Equal(t, "Date", rr.Header().Get("Access-Control-Expose-Headers"))
 	})
+
+	t.Run("SetCORSHeaders with max age and no origin", func(t *testing.T) {
+		rr := httptest.ResponseRecorder{}
+		SetCORSHeaders(&rr, "", nil, time.Hour)
+		assert.Equal(t, "", rr.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "3600", rr.Header().Get("Access-Control-Max-Age"))
+	})
+}
+
+func TestOriginMatcher(t *testing.T) {
+	m, err := NewOriginMatcher([]string{
+		"https://exact.com",
+		"https://*.wildcard.com",
+		`/^https://(app|www)\.regex\.com$/`,
+	})
+	require.NoError(t, err)
+
+	for _, origin := range []string{
+		"https://exact.com",
+		"https://a.wildcard.com",
+		"https://a.b.wildcard.com",
+		"https://app.regex.com",
+		"https://www.regex.com",
+	} {
+		assert.True(t, m.Matches(origin), origin)
+	}
+	for _, origin := range []string{
+		"http://exact.com",
+		"https://exact.com.evil.com",
+		"https://wildcard.com",
+		"https://a.wildcard.com.evil.com",
+		"https://appxregex.com",
+		"https://other.regex.com",
+	} {
+		assert.False(t, m.Matches(origin), origin)
+	}
+
+	assert.False(t, OriginMatcher{}.Matches("https://exact.com"))
+}
+
+func TestOriginMatcherRejectsInvalidRegex(t *testing.T) {
+	_, err := NewOriginMatcher([]string{"/(/"})
+	assert.Error(t, err)
+}
+
+func TestIsExactOrigin(t *testing.T) {
+	assert.True(t, IsExactOrigin("https://exact.com"))
+	assert.False(t, IsExactOrigin("https://*.wildcard.com"))
+	assert.False(t, IsExactOrigin("/^https://regex$/"))
 }
@@ -0,0 +1,352 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents"
+)
+
+// Event coordination lets several Relay instances that forward events for the same environment share
+// the work of summarizing them. Without it, LaunchDarkly receives a summary event from every instance at
+// every flush interval, and an index event for a user from every instance that sees that user.
+//
+// Every analytics payload that an instance is about to deliver passes through a coordinatedEventSender,
+// which merges the payload's summary events into a shared summary in the EventCoordinationStore, and
+// drops index events for users that some instance has already indexed recently. At each flush interval,
+// whichever instance acquires a short-lived lease takes the shared summary and delivers it as a single
+// summary event. If the store can't be reached, payloads are delivered unchanged.
+
+const (
+	summaryEventKind = "summary"
+	indexEventKind   = "index"
+
+	// If no instance delivers a shared summary (for instance, because all of them have been shut down),
+	// it expires after this many flush intervals.
+	sharedSummaryTTLMultiplier = 10
+)
+
+// EventCoordinationStore is the shared storage used for event coordination. The only implementation is
+// the Redis one created by NewRedisEventCoordinationStore; it is an interface so that the coordination
+// logic can be tested without a database.
+type EventCoordinationStore interface {
+	// Close releases any resources held by the store.
+	Close() error
+
+	// addSummary merges a summary event into the shared summary for a key and set of tags.
+	addSummary(key, tags string, summary summaryEvent, ttl time.Duration) error
+	// takeSummary removes the shared summary for a key and set of tags, and returns it. It returns an
+	// empty summaryEvent if there was none.
+	takeSummary(key, tags string) (summaryEvent, error)
+	// summaryTags returns every set of tags that currently has a shared summary for a key.
+	summaryTags(key string) ([]string, error)
+	// setIfAbsent sets a key that expires after ttl, and returns true, if the key did not already exist.
+	setIfAbsent(key string, ttl time.Duration) (bool, error)
+}
+
+// summaryEvent is the JSON representation of a summary event that is produced by the SDKs and by
+// go-sdk-events, in the schema versions that Relay forwards (3 and later).
+type summaryEvent struct {
+	Kind      string                     `json:"kind"`
+	StartDate ldtime.UnixMillisecondTime `json:"startDate"`
+	EndDate   ldtime.UnixMillisecondTime `json:"endDate"`
+	Features  map[string]*summaryFlag    `json:"features"`
+}
+
+type summaryFlag struct {
+	Default  ldvalue.Value    `json:"default"`
+	Counters []summaryCounter `json:"counters"`
+}
+
+type summaryCounter struct {
+	Value     ldvalue.Value `json:"value"`
+	Variation *int          `json:"variation,omitempty"`
+	Version   *int          `json:"version,omitempty"`
+	Unknown   bool          `json:"unknown,omitempty"`
+	Count     int           `json:"count"`
+}
+
+func (s summaryEvent) isEmpty() bool {
+	return len(s.Features) == 0
+}
+
+// addCounter adds a counter to the summary, or adds its count to an existing counter for the same flag,
+// variation, and version.
+func (s *summaryEvent) addCounter(flagKey string, defaultValue ldvalue.Value, counter summaryCounter) {
+	if s.Features == nil {
+		s.Features = make(map[string]*summaryFlag)
+	}
+	flag := s.Features[flagKey]
+	if flag == nil {
+		flag = &summaryFlag{}
+		s.Features[flagKey] = flag
+	}
+	flag.Default = defaultValue
+	for i, existing := range flag.Counters {
+		if existing.Unknown == counter.Unknown && equalIntPtrs(existing.Variation, counter.Variation) &&
+			equalIntPtrs(existing.Version, counter.Version) {
+			flag.Counters[i].Count += counter.Count
+			return
+		}
+	}
+	flag.Counters = append(flag.Counters, counter)
+}
+
+// addDates extends the summary's time range to include another one.
+func (s *summaryEvent) addDates(startDate, endDate ldtime.UnixMillisecondTime) {
+	if s.StartDate == 0 || (startDate != 0 && startDate < s.StartDate) {
+		s.StartDate = startDate
+	}
+	if endDate > s.EndDate {
+		s.EndDate = endDate
+	}
+}
+
+func equalIntPtrs(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// eventCoordinator does event coordination for one analytics endpoint of an environment. It wraps the
+// EventSenders of the endpoint's relays, and runs a goroutine that delivers the shared summary.
+type eventCoordinator struct {
+	store         EventCoordinationStore
+	summaryKey    string
+	leaseKey      string
+	flushInterval time.Duration
+	userKeysTTL   time.Duration
+	httpClient    *http.Client
+	eventsURI     string
+	baseHeaders   http.Header
+	authKey       c.SDKCredential
+	loggers       ldlog.Loggers
+	spool         *eventSpoolEndpoint
+	exporter      *eventExportEndpoint
+	storeFailing  bool
+	lock          sync.Mutex
+	closer        chan struct{}
+	done          chan struct{}
+	closeOnce     sync.Once
+}
+
+// coordinatedEventSender is the EventSender used by the relays of an endpoint when event coordination
+// is enabled.
+type coordinatedEventSender struct {
+	wrapped     ldevents.EventSender
+	coordinator *eventCoordinator
+	tags        string
+}
+
+func newEventCoordinator(
+	store EventCoordinationStore,
+	authKey c.SDKCredential,
+	config c.EventsConfig,
+	httpConfig httpconfig.HTTPConfig,
+	loggers ldlog.Loggers,
+	remotePath string,
+	spool *eventSpoolEndpoint,
+	exporter *eventExportEndpoint,
+) *eventCoordinator {
+	baseHeaders := httpConfig.SDKHTTPConfig.GetDefaultHeaders()
+	baseHeaders.Del("Authorization") // we'll set this in makeEventSender()
+	ec := &eventCoordinator{
+		store:         store,
+		summaryKey:    "events:summary:" + remotePath,
+		leaseKey:      "events:flush_lease:" + remotePath,
+		flushInterval: config.FlushInterval.GetOrElse(c.DefaultEventsFlushInterval),
+		userKeysTTL:   ldcomponents.DefaultUserKeysFlushInterval,
+		httpClient:    httpConfig.Client(),
+		eventsURI:     strings.TrimRight(getEventsURI(config), "/") + remotePath,
+		baseHeaders:   baseHeaders,
+		authKey:       authKey,
+		loggers:       loggers,
+		spool:         spool,
+		exporter:      exporter,
+		closer:        make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go ec.run()
+	return ec
+}
+
+// wrapSender returns an EventSender that applies event coordination to analytics payloads before they
+// are delivered by sender. If ec is nil, it returns sender unchanged.
+func (ec *eventCoordinator) wrapSender(sender ldevents.EventSender, metadata EventPayloadMetadata) ldevents.EventSender {
+	if ec == nil {
+		return sender
+	}
+	return &coordinatedEventSender{wrapped: sender, coordinator: ec, tags: metadata.Tags}
+}
+
+func (ec *eventCoordinator) replaceCredential(newCredential c.SDKCredential) {
+	ec.lock.Lock()
+	ec.authKey = newCredential
+	ec.lock.Unlock()
+}
+
+// close stops the coordinator, after delivering whatever is in the shared summary. This is done without
+// acquiring the lease, so that events that this instance just added to the summary are not lost if there
+// are no other instances.
+func (ec *eventCoordinator) close() {
+	if ec == nil {
+		return
+	}
+	ec.closeOnce.Do(func() {
+		close(ec.closer)
+		<-ec.done
+	})
+}
+
+func (ec *eventCoordinator) run() {
+	defer close(ec.done)
+	ticker := time.NewTicker(ec.flushInterval)
+	defer ticker.Stop()
+	// The lease expires a little before the next flush, so that the same instance can acquire it again if
+	// its timer fires first, but no other instance can deliver the summary in between.
+	leaseTTL := ec.flushInterval - ec.flushInterval/10
+	for {
+		select {
+		case <-ticker.C:
+			acquired, err := ec.store.setIfAbsent(ec.leaseKey, leaseTTL)
+			ec.handleStoreResult(err)
+			if acquired {
+				ec.deliverSharedSummaries()
+			}
+		case <-ec.closer:
+			ec.deliverSharedSummaries()
+			return
+		}
+	}
+}
+
+func (ec *eventCoordinator) deliverSharedSummaries() {
+	tagsList, err := ec.store.summaryTags(ec.summaryKey)
+	ec.handleStoreResult(err)
+	ec.lock.Lock()
+	authKey := ec.authKey
+	ec.lock.Unlock()
+	for _, tags := range tagsList {
+		summary, err := ec.store.takeSummary(ec.summaryKey, tags)
+		ec.handleStoreResult(err)
+		if err != nil || summary.isEmpty() {
+			continue
+		}
+		summary.Kind = summaryEventKind
+		payload, err := json.Marshal([]summaryEvent{summary})
+		if err != nil { // COVERAGE: can't happen in unit tests
+			ec.loggers.Errorf("Unexpected error marshalling summary event: %s", err)
+			continue
+		}
+		metadata := EventPayloadMetadata{Tags: tags}
+		sender := ec.spool.wrapSender(ec.exporter.wrapSender(
+			makeEventSender(ec.httpClient, ec.eventsURI, ec.baseHeaders, authKey, metadata, ec.loggers), metadata), metadata)
+		_ = sendTracedEventData(sender, ldevents.AnalyticsEventDataKind, payload, 1)
+	}
+}
+
+// filterPayload takes a JSON array of output events, moves its summary events into the shared summary,
+// and removes any index events for users that have already been indexed. It returns the remaining
+// events, or ok = false if there are none.
+func (ec *eventCoordinator) filterPayload(tags string, data []byte, count int) (filtered []byte, filteredCount int, ok bool) {
+	var events []json.RawMessage
+	if err := json.Unmarshal(data, &events); err != nil {
+		return data, count, true // let LaunchDarkly decide what to do with it
+	}
+	kept := make([]json.RawMessage, 0, len(events))
+	for _, event := range events {
+		var fields struct {
+			Kind string `json:"kind"`
+			User *struct {
+				Key string `json:"key"`
+			} `json:"user"`
+		}
+		if err := json.Unmarshal(event, &fields); err == nil {
+			switch fields.Kind {
+			case summaryEventKind:
+				if ec.addToSharedSummary(tags, event) {
+					continue
+				}
+			case indexEventKind:
+				if fields.User != nil && !ec.isNewUser(fields.User.Key) {
+					continue
+				}
+			}
+		}
+		kept = append(kept, event)
+	}
+	if len(kept) == len(events) {
+		return data, count, true
+	}
+	if len(kept) == 0 {
+		return nil, 0, false
+	}
+	filtered, err := json.Marshal(kept)
+	if err != nil { // COVERAGE: can't happen in unit tests
+		return data, count, true
+	}
+	return filtered, len(kept), true
+}
+
+// addToSharedSummary returns true if the summary event was added to the shared summary, or false if it
+// should be delivered as usual.
+func (ec *eventCoordinator) addToSharedSummary(tags string, event json.RawMessage) bool {
+	var summary summaryEvent
+	if err := json.Unmarshal(event, &summary); err != nil {
+		return false
+	}
+	err := ec.store.addSummary(ec.summaryKey, tags, summary, ec.flushInterval*sharedSummaryTTLMultiplier)
+	ec.handleStoreResult(err)
+	return err == nil
+}
+
+// isNewUser returns true if no instance has indexed this user within the user keys flush interval,
+// which is how long an SDK or a single Relay instance would remember it for.
+func (ec *eventCoordinator) isNewUser(userKey string) bool {
+	isNew, err := ec.store.setIfAbsent("events:user:"+userKey, ec.userKeysTTL)
+	ec.handleStoreResult(err)
+	return isNew || err != nil
+}
+
+// handleStoreResult logs the first of a series of store errors, and the recovery after it, so that an
+// unavailable store doesn't cause a warning for every payload.
+func (ec *eventCoordinator) handleStoreResult(err error) {
+	ec.lock.Lock()
+	defer ec.lock.Unlock()
+	if err != nil {
+		if !ec.storeFailing {
+			ec.loggers.Warnf("Event coordination store is unavailable; events will be delivered without coordination: %s", err)
+			ec.storeFailing = true
+		}
+		return
+	}
+	if ec.storeFailing {
+		ec.loggers.Info("Event coordination store is available again")
+		ec.storeFailing = false
+	}
+}
+
+func (s *coordinatedEventSender) SendEventData(
+	kind ldevents.EventDataKind,
+	data []byte,
+	count int,
+) ldevents.EventSenderResult {
+	if kind == ldevents.AnalyticsEventDataKind {
+		var ok bool
+		if data, count, ok = s.coordinator.filterPayload(s.tags, data, count); !ok {
+			return ldevents.EventSenderResult{Success: true}
+		}
+	}
+	return s.wrapped.SendEventData(kind, data, count)
+}
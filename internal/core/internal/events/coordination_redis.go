@@ -0,0 +1,151 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+
+	"github.com/go-redis/redis/v8"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+)
+
+// A shared summary is stored as three hashes and a sorted set, which can all be updated atomically
+// without reading them first:
+//
+//   - counters: the count for each counter, keyed by the JSON representation of redisCounterKey
+//   - values: the flag value for each counter, with the same keys
+//   - defaults: the default value of each flag, keyed by flag key
+//   - dates: the start and end dates of the merged summaries, which are the lowest and highest scores
+//
+// The set of tags that have a shared summary is stored as a set. All of the keys for one endpoint
+// include the same Redis hash tag, so that the transactions work in a Redis cluster.
+
+type redisEventCoordinationStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+type redisCounterKey struct {
+	FlagKey   string `json:"f"`
+	Variation *int   `json:"x,omitempty"`
+	Version   *int   `json:"v,omitempty"`
+	Unknown   bool   `json:"u,omitempty"`
+}
+
+// NewRedisEventCoordinationStore creates an EventCoordinationStore that uses the Redis database from
+// the Relay configuration, with the environment's key prefix.
+func NewRedisEventCoordinationStore(
+	redisConfig config.RedisConfig,
+	envConfig config.EnvConfig,
+) (EventCoordinationStore, error) {
+	client, prefix, err := sdks.NewRedisClient(redisConfig, envConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &redisEventCoordinationStore{client: client, prefix: prefix}, nil
+}
+
+func (r *redisEventCoordinationStore) tagsSetKey(key string) string {
+	return fmt.Sprintf("%s:{%s}:tags", r.prefix, key)
+}
+
+func (r *redisEventCoordinationStore) summaryKeys(key, tags string) (counters, values, defaults, dates string) {
+	base := fmt.Sprintf("%s:{%s}:%s", r.prefix, key, tags)
+	return base + ":counters", base + ":values", base + ":defaults", base + ":dates"
+}
+
+func (r *redisEventCoordinationStore) Close() error {
+	return r.client.Close()
+}
+
+func (r *redisEventCoordinationStore) addSummary(key, tags string, summary summaryEvent, ttl time.Duration) error {
+	ctx := context.Background()
+	countersKey, valuesKey, defaultsKey, datesKey := r.summaryKeys(key, tags)
+	tagsKey := r.tagsSetKey(key)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for flagKey, flag := range summary.Features {
+			pipe.HSet(ctx, defaultsKey, flagKey, flag.Default.JSONString())
+			for _, counter := range flag.Counters {
+				field, err := json.Marshal(redisCounterKey{
+					FlagKey:   flagKey,
+					Variation: counter.Variation,
+					Version:   counter.Version,
+					Unknown:   counter.Unknown,
+				})
+				if err != nil { // COVERAGE: can't happen in unit tests
+					return err
+				}
+				pipe.HIncrBy(ctx, countersKey, string(field), int64(counter.Count))
+				pipe.HSet(ctx, valuesKey, string(field), counter.Value.JSONString())
+			}
+		}
+		pipe.ZAdd(ctx, datesKey,
+			&redis.Z{Score: float64(summary.StartDate), Member: strconv.FormatUint(uint64(summary.StartDate), 10)},
+			&redis.Z{Score: float64(summary.EndDate), Member: strconv.FormatUint(uint64(summary.EndDate), 10)},
+		)
+		pipe.SAdd(ctx, tagsKey, tags)
+		for _, k := range []string{countersKey, valuesKey, defaultsKey, datesKey, tagsKey} {
+			pipe.PExpire(ctx, k, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *redisEventCoordinationStore) takeSummary(key, tags string) (summaryEvent, error) {
+	ctx := context.Background()
+	countersKey, valuesKey, defaultsKey, datesKey := r.summaryKeys(key, tags)
+	var countersCmd, valuesCmd, defaultsCmd *redis.StringStringMapCmd
+	var startCmd, endCmd *redis.ZSliceCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		countersCmd = pipe.HGetAll(ctx, countersKey)
+		valuesCmd = pipe.HGetAll(ctx, valuesKey)
+		defaultsCmd = pipe.HGetAll(ctx, defaultsKey)
+		startCmd = pipe.ZRangeWithScores(ctx, datesKey, 0, 0)
+		endCmd = pipe.ZRangeWithScores(ctx, datesKey, -1, -1)
+		pipe.Del(ctx, countersKey, valuesKey, defaultsKey, datesKey)
+		pipe.SRem(ctx, r.tagsSetKey(key), tags)
+		return nil
+	})
+	if err != nil {
+		return summaryEvent{}, err
+	}
+
+	var summary summaryEvent
+	values, defaults := valuesCmd.Val(), defaultsCmd.Val()
+	for field, countString := range countersCmd.Val() {
+		var counterKey redisCounterKey
+		if err := json.Unmarshal([]byte(field), &counterKey); err != nil {
+			continue // COVERAGE: can't happen in unit tests unless the data is modified by something else
+		}
+		count, err := strconv.Atoi(countString)
+		if err != nil {
+			continue // COVERAGE: see above
+		}
+		summary.addCounter(counterKey.FlagKey, ldvalue.Parse([]byte(defaults[counterKey.FlagKey])), summaryCounter{
+			Value:     ldvalue.Parse([]byte(values[field])),
+			Variation: counterKey.Variation,
+			Version:   counterKey.Version,
+			Unknown:   counterKey.Unknown,
+			Count:     count,
+		})
+	}
+	if start, end := startCmd.Val(), endCmd.Val(); len(start) != 0 && len(end) != 0 {
+		summary.addDates(ldtime.UnixMillisecondTime(start[0].Score), ldtime.UnixMillisecondTime(end[0].Score))
+	}
+	return summary, nil
+}
+
+func (r *redisEventCoordinationStore) summaryTags(key string) ([]string, error) {
+	return r.client.SMembers(context.Background(), r.tagsSetKey(key)).Result()
+}
+
+func (r *redisEventCoordinationStore) setIfAbsent(key string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(context.Background(), fmt.Sprintf("%s:%s", r.prefix, key), "", ttl).Result()
+}
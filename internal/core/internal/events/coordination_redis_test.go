@@ -0,0 +1,79 @@
+//go:build redis_unit_tests
+// +build redis_unit_tests
+
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeRedisCoordinationStore(t *testing.T) *redisEventCoordinationStore {
+	redisConfig := config.RedisConfig{}
+	redisConfig.URL, _ = configtypes.NewOptURLAbsoluteFromString("redis://127.0.0.1:6379")
+	store, err := NewRedisEventCoordinationStore(redisConfig, config.EnvConfig{Prefix: "coordination-test"})
+	require.NoError(t, err)
+	r := store.(*redisEventCoordinationStore)
+	require.NoError(t, r.client.FlushAll(context.Background()).Err())
+	return r
+}
+
+func TestRedisCoordinationStoreMergesSummaries(t *testing.T) {
+	store := makeRedisCoordinationStore(t)
+	defer store.Close()
+
+	variation, version := 1, 2
+	makeSummary := func(startDate, endDate, count int) summaryEvent {
+		var s summaryEvent
+		s.addCounter("flag1", ldvalue.Bool(false),
+			summaryCounter{Value: ldvalue.Bool(true), Variation: &variation, Version: &version, Count: count})
+		s.addDates(ldtime.UnixMillisecondTime(startDate), ldtime.UnixMillisecondTime(endDate))
+		return s
+	}
+	require.NoError(t, store.addSummary("events:summary:/bulk", "tags-a", makeSummary(2000, 3000, 2), time.Minute))
+	require.NoError(t, store.addSummary("events:summary:/bulk", "tags-a", makeSummary(1000, 2500, 3), time.Minute))
+	require.NoError(t, store.addSummary("events:summary:/bulk", "tags-b", makeSummary(1000, 2000, 1), time.Minute))
+
+	tags, err := store.summaryTags("events:summary:/bulk")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"tags-a", "tags-b"}, tags)
+
+	summary, err := store.takeSummary("events:summary:/bulk", "tags-a")
+	require.NoError(t, err)
+	assert.Equal(t, makeSummary(1000, 3000, 5), summary)
+
+	tags, err = store.summaryTags("events:summary:/bulk")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tags-b"}, tags)
+
+	summary, err = store.takeSummary("events:summary:/bulk", "tags-a")
+	require.NoError(t, err)
+	assert.True(t, summary.isEmpty())
+}
+
+func TestRedisCoordinationStoreSetIfAbsent(t *testing.T) {
+	store := makeRedisCoordinationStore(t)
+	defer store.Close()
+
+	set, err := store.setIfAbsent("events:user:a", time.Millisecond*100)
+	require.NoError(t, err)
+	assert.True(t, set)
+	set, err = store.setIfAbsent("events:user:a", time.Millisecond*100)
+	require.NoError(t, err)
+	assert.False(t, set)
+
+	time.Sleep(time.Millisecond * 200)
+	set, err = store.setIfAbsent("events:user:a", time.Millisecond*100)
+	require.NoError(t, err)
+	assert.True(t, set)
+}
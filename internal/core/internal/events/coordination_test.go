@@ -0,0 +1,308 @@
+package events
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-test-helpers/v2/httphelpers"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryCoordinationStore is an in-memory EventCoordinationStore that behaves like the Redis one,
+// except that expiration is only checked for keys set with setIfAbsent.
+type memoryCoordinationStore struct {
+	summaries map[string]map[string]summaryEvent
+	keys      map[string]time.Time
+	acquired  map[string][]time.Time
+	err       error
+	lock      sync.Mutex
+}
+
+func newMemoryCoordinationStore() *memoryCoordinationStore {
+	return &memoryCoordinationStore{
+		summaries: make(map[string]map[string]summaryEvent),
+		keys:      make(map[string]time.Time),
+		acquired:  make(map[string][]time.Time),
+	}
+}
+
+func (s *memoryCoordinationStore) setError(err error) {
+	s.lock.Lock()
+	s.err = err
+	s.lock.Unlock()
+}
+
+func (s *memoryCoordinationStore) getAcquisitionTimes(key string) []time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]time.Time(nil), s.acquired[key]...)
+}
+
+func (s *memoryCoordinationStore) Close() error { return nil }
+
+func (s *memoryCoordinationStore) addSummary(key, tags string, summary summaryEvent, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.summaries[key] == nil {
+		s.summaries[key] = make(map[string]summaryEvent)
+	}
+	shared := s.summaries[key][tags]
+	for flagKey, flag := range summary.Features {
+		for _, counter := range flag.Counters {
+			shared.addCounter(flagKey, flag.Default, counter)
+		}
+	}
+	shared.addDates(summary.StartDate, summary.EndDate)
+	s.summaries[key][tags] = shared
+	return nil
+}
+
+func (s *memoryCoordinationStore) takeSummary(key, tags string) (summaryEvent, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return summaryEvent{}, s.err
+	}
+	summary := s.summaries[key][tags]
+	delete(s.summaries[key], tags)
+	return summary, nil
+}
+
+func (s *memoryCoordinationStore) summaryTags(key string) ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	var ret []string
+	for tags := range s.summaries[key] {
+		ret = append(ret, tags)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+func (s *memoryCoordinationStore) setIfAbsent(key string, ttl time.Duration) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	if expiry, ok := s.keys[key]; ok && time.Now().Before(expiry) {
+		return false, nil
+	}
+	s.keys[key] = time.Now().Add(ttl)
+	s.acquired[key] = append(s.acquired[key], time.Now())
+	return true, nil
+}
+
+type recordedPayload struct {
+	kind  ldevents.EventDataKind
+	data  string
+	count int
+}
+
+type recordingEventSender struct {
+	payloads []recordedPayload
+}
+
+func (r *recordingEventSender) SendEventData(kind ldevents.EventDataKind, data []byte, count int) ldevents.EventSenderResult {
+	r.payloads = append(r.payloads, recordedPayload{kind, string(data), count})
+	return ldevents.EventSenderResult{Success: true}
+}
+
+type coordinationTestParams struct {
+	store          *memoryCoordinationStore
+	requestsCh     <-chan httphelpers.HTTPRequestInfo
+	newCoordinator func(flushInterval time.Duration) *eventCoordinator
+	mockLog        *ldlogtest.MockLog
+}
+
+func coordinationTest(t *testing.T, fn func(coordinationTestParams)) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	httpConfig, _ := httpconfig.NewHTTPConfig(config.ProxyConfig{}, nil, "", mockLog.Loggers)
+	store := newMemoryCoordinationStore()
+
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		var coordinators []*eventCoordinator
+		newCoordinator := func(flushInterval time.Duration) *eventCoordinator {
+			eventsConfig := config.EventsConfig{FlushInterval: configtypes.NewOptDuration(flushInterval)}
+			eventsConfig.EventsURI, _ = configtypes.NewOptURLAbsoluteFromString(server.URL)
+			ec := newEventCoordinator(store, config.SDKKey("my-sdk-key"), eventsConfig, httpConfig, mockLog.Loggers, "/bulk", nil, nil)
+			coordinators = append(coordinators, ec)
+			return ec
+		}
+		defer func() {
+			for _, ec := range coordinators {
+				ec.close()
+			}
+		}()
+		fn(coordinationTestParams{store: store, requestsCh: requestsCh, newCoordinator: newCoordinator, mockLog: mockLog})
+	})
+}
+
+func makeSummaryEventJSON(startDate, endDate int, variation, count int) string {
+	return `{"kind":"summary","startDate":` + strconv.Itoa(startDate) + `,"endDate":` + strconv.Itoa(endDate) +
+		`,"features":{"flag1":{"default":false,"counters":[{"value":true,"variation":` + strconv.Itoa(variation) +
+		`,"version":2,"count":` + strconv.Itoa(count) + `}]}}}`
+}
+
+func TestSummaryEventAddCounterMergesMatchingCounters(t *testing.T) {
+	var s summaryEvent
+	variation0, variation1, version := 0, 1, 2
+	s.addCounter("flag1", ldvalue.Bool(false), summaryCounter{Value: ldvalue.Bool(true), Variation: &variation1, Version: &version, Count: 1})
+	s.addCounter("flag1", ldvalue.Bool(false), summaryCounter{Value: ldvalue.Bool(false), Variation: &variation0, Version: &version, Count: 2})
+	s.addCounter("flag1", ldvalue.Bool(false), summaryCounter{Value: ldvalue.Bool(true), Variation: &variation1, Version: &version, Count: 3})
+	s.addCounter("flag2", ldvalue.Null(), summaryCounter{Value: ldvalue.Null(), Unknown: true, Count: 1})
+	s.addDates(2000, 3000)
+	s.addDates(1000, 2500)
+
+	require.Len(t, s.Features["flag1"].Counters, 2)
+	assert.Equal(t, 4, s.Features["flag1"].Counters[0].Count)
+	assert.Equal(t, 2, s.Features["flag1"].Counters[1].Count)
+	require.Len(t, s.Features["flag2"].Counters, 1)
+	assert.Equal(t, 1000, int(s.StartDate))
+	assert.Equal(t, 3000, int(s.EndDate))
+}
+
+func TestCoordinatedSenderMergesSummariesFromAllInstances(t *testing.T) {
+	coordinationTest(t, func(p coordinationTestParams) {
+		ec1, ec2 := p.newCoordinator(time.Hour), p.newCoordinator(time.Hour)
+		sender1, sender2 := &recordingEventSender{}, &recordingEventSender{}
+		metadata := EventPayloadMetadata{Tags: "application-id/x"}
+
+		feature := `{"kind":"feature","key":"flag1"}`
+		ec1.wrapSender(sender1, metadata).SendEventData(ldevents.AnalyticsEventDataKind,
+			[]byte(`[`+feature+`,`+makeSummaryEventJSON(1000, 2000, 1, 2)+`]`), 2)
+		ec2.wrapSender(sender2, metadata).SendEventData(ldevents.AnalyticsEventDataKind,
+			[]byte(`[`+makeSummaryEventJSON(1500, 3000, 1, 3)+`]`), 1)
+
+		assert.Equal(t, []recordedPayload{{ldevents.AnalyticsEventDataKind, `[` + feature + `]`, 1}}, sender1.payloads)
+		assert.Len(t, sender2.payloads, 0) // nothing was left to send
+
+		ec1.close()
+		r := st.ExpectTestRequest(t, p.requestsCh, time.Second)
+		assert.Equal(t, "/bulk", r.Request.URL.Path)
+		assert.Equal(t, "my-sdk-key", r.Request.Header.Get("Authorization"))
+		assert.Equal(t, "application-id/x", r.Request.Header.Get(TagsHeader))
+		assert.JSONEq(t, `[`+makeSummaryEventJSON(1000, 3000, 1, 5)+`]`, string(r.Body))
+
+		ec2.close()
+		st.ExpectNoTestRequests(t, p.requestsCh, time.Millisecond*50)
+	})
+}
+
+func TestCoordinatedSenderDropsIndexEventsForKnownUsers(t *testing.T) {
+	coordinationTest(t, func(p coordinationTestParams) {
+		ec1, ec2 := p.newCoordinator(time.Hour), p.newCoordinator(time.Hour)
+		sender1, sender2 := &recordingEventSender{}, &recordingEventSender{}
+
+		index := `{"kind":"index","user":{"key":"user-a"}}`
+		custom := `{"kind":"custom","key":"e"}`
+		payload := []byte(`[` + index + `,` + custom + `]`)
+		ec1.wrapSender(sender1, EventPayloadMetadata{}).SendEventData(ldevents.AnalyticsEventDataKind, payload, 2)
+		ec2.wrapSender(sender2, EventPayloadMetadata{}).SendEventData(ldevents.AnalyticsEventDataKind, payload, 2)
+
+		assert.Equal(t, []recordedPayload{{ldevents.AnalyticsEventDataKind, string(payload), 2}}, sender1.payloads)
+		assert.Equal(t, []recordedPayload{{ldevents.AnalyticsEventDataKind, `[` + custom + `]`, 1}}, sender2.payloads)
+	})
+}
+
+func TestCoordinatedSenderDoesNotChangeDiagnosticEvents(t *testing.T) {
+	coordinationTest(t, func(p coordinationTestParams) {
+		sender := &recordingEventSender{}
+		payload := makeSummaryEventJSON(1000, 2000, 1, 2)
+		p.newCoordinator(time.Hour).wrapSender(sender, EventPayloadMetadata{}).
+			SendEventData(ldevents.DiagnosticEventDataKind, []byte(payload), 1)
+
+		assert.Equal(t, []recordedPayload{{ldevents.DiagnosticEventDataKind, payload, 1}}, sender.payloads)
+	})
+}
+
+func TestCoordinatedSenderDeliversEventsUnchangedIfStoreFails(t *testing.T) {
+	coordinationTest(t, func(p coordinationTestParams) {
+		ec := p.newCoordinator(time.Hour)
+		sender := &recordingEventSender{}
+		payload := `[{"kind":"index","user":{"key":"user-a"}},` + makeSummaryEventJSON(1000, 2000, 1, 2) + `]`
+
+		p.store.setError(errors.New("sorry"))
+		for i := 0; i < 2; i++ {
+			ec.wrapSender(sender, EventPayloadMetadata{}).SendEventData(ldevents.AnalyticsEventDataKind, []byte(payload), 2)
+		}
+		assert.Equal(t, []recordedPayload{
+			{ldevents.AnalyticsEventDataKind, payload, 2},
+			{ldevents.AnalyticsEventDataKind, payload, 2},
+		}, sender.payloads)
+		assert.Len(t, p.mockLog.GetOutput(ldlog.Warn), 1)
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Event coordination store is unavailable.*sorry")
+
+		p.store.setError(nil)
+		ec.wrapSender(sender, EventPayloadMetadata{}).SendEventData(ldevents.AnalyticsEventDataKind, []byte(payload), 2)
+		require.Len(t, sender.payloads, 3)
+		assert.Equal(t, `[{"kind":"index","user":{"key":"user-a"}}]`, sender.payloads[2].data)
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Info, "Event coordination store is available again")
+	})
+}
+
+func TestCoordinatorDeliversSharedSummaryFromOneInstancePerFlushInterval(t *testing.T) {
+	coordinationTest(t, func(p coordinationTestParams) {
+		flushInterval := time.Millisecond * 100
+		ec1, _ := p.newCoordinator(flushInterval), p.newCoordinator(flushInterval)
+
+		ec1.wrapSender(&recordingEventSender{}, EventPayloadMetadata{}).SendEventData(ldevents.AnalyticsEventDataKind,
+			[]byte(`[`+makeSummaryEventJSON(1000, 2000, 1, 2)+`]`), 1)
+
+		r := st.ExpectTestRequest(t, p.requestsCh, time.Second)
+		assert.JSONEq(t, `[`+makeSummaryEventJSON(1000, 2000, 1, 2)+`]`, string(r.Body))
+		st.ExpectNoTestRequests(t, p.requestsCh, flushInterval*3)
+
+		times := p.store.getAcquisitionTimes("events:flush_lease:/bulk")
+		require.GreaterOrEqual(t, len(times), 3)
+		for i := 1; i < len(times); i++ {
+			assert.Greater(t, int64(times[i].Sub(times[i-1])), int64(flushInterval/2))
+		}
+	})
+}
+
+func TestEventDispatcherCoordinatesSummaryEvents(t *testing.T) {
+	opts := eventRelayTestOptions{coordinationStore: newMemoryCoordinationStore()}
+	eventRelayTestWithOptions(t, st.EnvMain, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+		feature := `{"kind":"feature","key":"flag1"}`
+		summary := makeSummaryEventJSON(1000, 2000, 1, 2)
+		req := st.BuildRequest("POST", "/", []byte(`[`+feature+`,`+summary+`]`),
+			headersWithEventSchema(SummaryEventsSchemaVersion))
+		handler := p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)
+		handler(httptest.NewRecorder(), req)
+
+		p.dispatcher.flush()
+		r := st.ExpectTestRequest(t, p.requestsCh, time.Second)
+		assert.Equal(t, `[`+feature+`]`, string(r.Body))
+
+		p.dispatcher.Close()
+		r = st.ExpectTestRequest(t, p.requestsCh, time.Second)
+		assert.Equal(t, "/bulk", r.Request.URL.Path)
+		assert.JSONEq(t, `[`+summary+`]`, string(r.Body))
+	})
+}
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"sync"
+
+	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
+)
+
+// deliveryStatus records whether the most recent attempt to deliver a payload of analytics events from
+// an endpoint to LaunchDarkly succeeded, for the /healthz/ready endpoint. It is updated by wrapping the
+// EventSender that does the actual HTTP delivery, so payloads that are only written to the spool or to
+// an export destination do not affect it.
+type deliveryStatus struct {
+	failed bool
+	lock   sync.Mutex
+}
+
+type statusTrackingEventSender struct {
+	wrapped ldevents.EventSender
+	status  *deliveryStatus
+}
+
+func (s *deliveryStatus) wrapSender(sender ldevents.EventSender) ldevents.EventSender {
+	if s == nil {
+		return sender
+	}
+	return &statusTrackingEventSender{wrapped: sender, status: s}
+}
+
+func (s *deliveryStatus) isOperational() bool {
+	if s == nil {
+		return true
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return !s.failed
+}
+
+// reset clears a failure when the credential changes, since a delivery that failed because the old
+// credential was no longer valid says nothing about the new one.
+func (s *deliveryStatus) reset() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.failed = false
+	s.lock.Unlock()
+}
+
+func (t *statusTrackingEventSender) SendEventData(
+	kind ldevents.EventDataKind,
+	data []byte,
+	count int,
+) ldevents.EventSenderResult {
+	result := t.wrapped.SendEventData(kind, data, count)
+	t.status.lock.Lock()
+	t.status.failed = !result.Success
+	t.status.lock.Unlock()
+	return result
+}
@@ -0,0 +1,42 @@
+package events
+
+import (
+	"encoding/json"
+
+	"github.com/launchdarkly/ld-relay/v6/relay/version"
+)
+
+const (
+	diagnosticPlatformProperty      = "platform"
+	diagnosticRelayVersionProperty  = "relayProxyVersion"
+	diagnosticRelayInstanceProperty = "relayProxyInstance"
+)
+
+// annotateDiagnosticEvent adds the Relay version and instance name to the "platform" object of a
+// diagnostic event, so that it is visible in LaunchDarkly which Relay instance the SDK was connected to.
+// The SDK's own platform properties are kept. If the event cannot be parsed, it is returned unchanged.
+func annotateDiagnosticEvent(body []byte, instanceName string) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return body
+	}
+	var platform map[string]json.RawMessage
+	if fields[diagnosticPlatformProperty] != nil {
+		if err := json.Unmarshal(fields[diagnosticPlatformProperty], &platform); err != nil {
+			return body
+		}
+	}
+	if platform == nil {
+		platform = make(map[string]json.RawMessage)
+	}
+	platform[diagnosticRelayVersionProperty], _ = json.Marshal(version.Version)
+	if instanceName != "" {
+		platform[diagnosticRelayInstanceProperty], _ = json.Marshal(instanceName)
+	}
+	fields[diagnosticPlatformProperty], _ = json.Marshal(platform)
+	data, err := json.Marshal(fields)
+	if err != nil { // COVERAGE: can't happen in unit tests
+		return body
+	}
+	return data
+}
@@ -0,0 +1,29 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/relay/version"
+
+	m "github.com/launchdarkly/go-test-helpers/v2/matchers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotateDiagnosticEventAddsPlatformObjectIfMissing(t *testing.T) {
+	result := annotateDiagnosticEvent([]byte(`{"kind":"diagnostic-init"}`), "relay-1")
+	m.In(t).Assert(result, m.JSONStrEqual(
+		`{"kind":"diagnostic-init","platform":{"relayProxyVersion":"`+version.Version+`","relayProxyInstance":"relay-1"}}`))
+}
+
+func TestAnnotateDiagnosticEventOmitsEmptyInstanceName(t *testing.T) {
+	result := annotateDiagnosticEvent([]byte(`{"kind":"diagnostic","platform":{"name":"Go"}}`), "")
+	m.In(t).Assert(result, m.JSONStrEqual(
+		`{"kind":"diagnostic","platform":{"name":"Go","relayProxyVersion":"`+version.Version+`"}}`))
+}
+
+func TestAnnotateDiagnosticEventLeavesMalformedEventUnchanged(t *testing.T) {
+	for _, body := range []string{`not JSON`, `null`, `{"platform":"not an object"}`} {
+		assert.Equal(t, body, string(annotateDiagnosticEvent([]byte(body), "relay-1")))
+	}
+}
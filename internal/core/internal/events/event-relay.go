@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"reflect"
 	"strings"
 	"sync"
@@ -43,6 +44,7 @@ const (
 type EventDispatcher struct {
 	analyticsEndpoints  map[basictypes.SDKKind]*analyticsEventEndpointDispatcher
 	diagnosticEndpoints map[basictypes.SDKKind]*diagnosticEventEndpointDispatcher
+	coordinationStore   EventCoordinationStore
 }
 
 type analyticsEventEndpointDispatcher struct {
@@ -53,8 +55,16 @@ type analyticsEventEndpointDispatcher struct {
 	remotePath                string
 	verbatimRelay             *eventVerbatimRelay
 	summarizingRelay          *eventSummarizingRelay
+	coordinator               *eventCoordinator
+	spool                     *eventSpoolEndpoint
+	exporter                  *eventExportEndpoint
+	status                    *deliveryStatus
+	memoryBudget              *eventMemoryBudget
 	storeAdapter              *store.SSERelayDataStoreAdapter
 	eventQueueCleanupInterval time.Duration
+	onEventsForwarded         func(count int)
+	sampler                   *eventSampler
+	transform                 func(evts []json.RawMessage) []json.RawMessage
 	loggers                   ldlog.Loggers
 	mu                        sync.Mutex
 }
@@ -63,6 +73,8 @@ type diagnosticEventEndpointDispatcher struct {
 	httpClient        *http.Client
 	httpConfig        httpconfig.HTTPConfig
 	remoteEndpointURI string
+	mode              string
+	instanceName      string
 	loggers           ldlog.Loggers
 }
 
@@ -92,6 +104,26 @@ func (r *analyticsEventEndpointDispatcher) dispatch(w http.ResponseWriter, req *
 		metadata := GetEventPayloadMetadata(req)
 
 		r.loggers.Debugf("Received %d events (v%d) to be proxied to %s", len(evts), metadata.SchemaVersion, r.remotePath)
+		if r.onEventsForwarded != nil {
+			r.onEventsForwarded(len(evts))
+		}
+		// Events from newer SDKs are filtered before anything else sees them; events from older SDKs are
+		// filtered after they are summarized, so that they are all counted in the summaries.
+		if metadata.SchemaVersion >= SummaryEventsSchemaVersion {
+			if r.config.SummaryOnly {
+				evts = onlySummaryEvents(evts)
+			} else {
+				evts = r.sampler.apply(evts)
+			}
+			if len(evts) == 0 {
+				return
+			}
+		}
+		if r.transform != nil {
+			if evts = r.transform(evts); len(evts) == 0 {
+				return
+			}
+		}
 		if metadata.SchemaVersion >= SummaryEventsSchemaVersion {
 			// New-style events that have already gone through summarization - deliver them as-is
 			r.getVerbatimRelay().enqueue(metadata, evts)
@@ -106,12 +138,19 @@ func (r *analyticsEventEndpointDispatcher) replaceCredential(newCredential c.SDK
 	defer r.mu.Unlock()
 	if reflect.TypeOf(r.authKey) == reflect.TypeOf(newCredential) {
 		r.authKey = newCredential
+		r.status.reset()
 		if r.summarizingRelay != nil {
 			r.summarizingRelay.replaceCredential(newCredential)
 		}
 		if r.verbatimRelay != nil {
 			r.verbatimRelay.publisher.ReplaceCredential(newCredential)
 		}
+		if r.coordinator != nil {
+			r.coordinator.replaceCredential(newCredential)
+		}
+		if r.spool != nil {
+			r.spool.replaceCredential(newCredential)
+		}
 	}
 }
 
@@ -124,20 +163,32 @@ func (r *analyticsEventEndpointDispatcher) close() {
 	if r.verbatimRelay != nil {
 		r.verbatimRelay.close()
 	}
+	// The coordinator is closed after the relays, so that it can deliver the summaries that the relays
+	// added to the shared summary when they were closed; and the spool is closed last, since any of them
+	// can add to it.
+	r.coordinator.close()
+	r.spool.close()
 }
 
 func (d *diagnosticEventEndpointDispatcher) dispatch(w http.ResponseWriter, req *http.Request) {
 	consumeEvents(w, req, d.loggers, func(body []byte) {
-		// We are just operating as a reverse proxy and passing the request on verbatim to LD; we do not
-		// need to parse the JSON.
+		if d.mode == c.DiagnosticEventsBlock {
+			d.loggers.Debug("Discarding diagnostic event because diagnostic events are blocked")
+			return
+		}
+		// Unless we are annotating the event, we are just operating as a reverse proxy and passing the
+		// request on verbatim to LD; we do not need to parse the JSON.
 		d.loggers.Debugf("Received diagnostic event to be proxied to %s", d.remoteEndpointURI)
+		if d.mode == c.DiagnosticEventsAnnotate {
+			body = annotateDiagnosticEvent(body, d.instanceName)
+		}
 
 		// We use the default EventSender from ldevents, which provides the standard retry logic and logging.
 		// Since we don't want to use a fixed set of headers, but instead pass along the same headers we got
 		// from the request, we're creating a new EventSender each time; that's a little inefficient, but
 		// diagnostic events are relatively infrequent.
 		sender := ldevents.NewDefaultEventSender(d.httpClient, "", d.remoteEndpointURI, req.Header, d.loggers)
-		_ = sender.SendEventData(ldevents.DiagnosticEventDataKind, body, 1)
+		_ = sendTracedEventData(sender, ldevents.DiagnosticEventDataKind, body, 1)
 	})
 }
 
@@ -172,7 +223,8 @@ func (r *analyticsEventEndpointDispatcher) getVerbatimRelay() *eventVerbatimRela
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if r.verbatimRelay == nil {
-		r.verbatimRelay = newEventVerbatimRelay(r.authKey, r.config, r.httpConfig, r.loggers, r.remotePath)
+		r.verbatimRelay = newEventVerbatimRelay(r.authKey, r.config, r.httpConfig, r.loggers, r.remotePath, r.coordinator,
+			r.spool, r.exporter, r.status, r.memoryBudget)
 	}
 	return r.verbatimRelay
 }
@@ -182,7 +234,7 @@ func (r *analyticsEventEndpointDispatcher) getSummarizingRelay() *eventSummarizi
 	defer r.mu.Unlock()
 	if r.summarizingRelay == nil {
 		r.summarizingRelay = newEventSummarizingRelay(r.config, r.httpConfig, r.authKey, r.storeAdapter,
-			r.loggers, r.remotePath, r.eventQueueCleanupInterval)
+			r.loggers, r.remotePath, r.eventQueueCleanupInterval, r.coordinator, r.spool, r.exporter, r.status, r.sampler)
 	}
 	return r.summarizingRelay
 }
@@ -198,7 +250,29 @@ func (r *analyticsEventEndpointDispatcher) flush() { //nolint:unused // used onl
 	}
 }
 
-// NewEventDispatcher creates a handler for relaying events to LaunchDarkly for an environment
+// NewEventDispatcher creates a handler for relaying events to LaunchDarkly for an environment.
+//
+// If onEventsForwarded is non-nil, it is called with the number of analytics events in each payload
+// that is received from an SDK.
+//
+// If coordinationStore is non-nil, summary and index events are coordinated with other Relay instances
+// that use the same store, and the EventDispatcher closes the store when it is closed.
+//
+// If spool is non-nil, analytics event payloads that can't be delivered are saved in it, and delivered
+// later.
+//
+// If transform is non-nil, it is called with each payload of analytics events that is received from an
+// SDK, and returns the events that should be forwarded.
+//
+// If exporter is non-nil, the analytics events that are forwarded are also written to the environment's
+// export destinations (Kafka, Kinesis, or Pub/Sub), or are written only to those destinations if any of
+// them is in redirect mode.
+//
+// If memoryBudget has a nonzero MaxBytes, the total size of the queued analytics events for all of the
+// environment's endpoints is limited to that amount.
+//
+// If sampling has a rate for any kind of event, only that proportion of users' events of that kind are
+// forwarded.
 func NewEventDispatcher(
 	sdkKey c.SDKKey,
 	mobileKey c.MobileKey,
@@ -208,27 +282,58 @@ func NewEventDispatcher(
 	httpConfig httpconfig.HTTPConfig,
 	storeAdapter *store.SSERelayDataStoreAdapter,
 	eventQueueCleanupInterval time.Duration, // normally zero to use the default; overridden in tests
+	onEventsForwarded func(sdkKind basictypes.SDKKind, count int),
+	coordinationStore EventCoordinationStore,
+	spool *EventSpool,
+	transform func(sdkKind basictypes.SDKKind, evts []json.RawMessage) []json.RawMessage,
+	exporter *EnvironmentEventExporter,
+	memoryBudget EventMemoryBudget,
+	sampling EventSampling,
 ) *EventDispatcher {
 	ep := &EventDispatcher{
 		analyticsEndpoints: map[basictypes.SDKKind]*analyticsEventEndpointDispatcher{
 			basictypes.ServerSDK: newAnalyticsEventEndpointDispatcher(sdkKey,
-				config, httpConfig, storeAdapter, loggers, "/bulk", eventQueueCleanupInterval),
+				config, httpConfig, storeAdapter, loggers, "/bulk", eventQueueCleanupInterval, coordinationStore, spool,
+				exporter.newEndpoint(basictypes.ServerSDK)),
 		},
 		diagnosticEndpoints: map[basictypes.SDKKind]*diagnosticEventEndpointDispatcher{
 			basictypes.ServerSDK: newDiagnosticEventEndpointDispatcher(config, httpConfig, loggers, "/diagnostic"),
 		},
+		coordinationStore: coordinationStore,
 	}
 	if mobileKey != "" {
 		ep.analyticsEndpoints[basictypes.MobileSDK] = newAnalyticsEventEndpointDispatcher(mobileKey,
-			config, httpConfig, storeAdapter, loggers, "/mobile", eventQueueCleanupInterval)
+			config, httpConfig, storeAdapter, loggers, "/mobile", eventQueueCleanupInterval, coordinationStore, spool,
+			exporter.newEndpoint(basictypes.MobileSDK))
 		ep.diagnosticEndpoints[basictypes.MobileSDK] = newDiagnosticEventEndpointDispatcher(config, httpConfig, loggers, "/mobile/events/diagnostic")
 	}
 	if envID != "" {
 		ep.analyticsEndpoints[basictypes.JSClientSDK] = newAnalyticsEventEndpointDispatcher(envID, config, httpConfig, storeAdapter, loggers,
-			"/events/bulk/"+string(envID), eventQueueCleanupInterval)
+			"/events/bulk/"+string(envID), eventQueueCleanupInterval, coordinationStore, spool,
+			exporter.newEndpoint(basictypes.JSClientSDK))
 		ep.diagnosticEndpoints[basictypes.JSClientSDK] = newDiagnosticEventEndpointDispatcher(config, httpConfig, loggers,
 			"/events/diagnostic/"+string(envID))
 	}
+	if onEventsForwarded != nil {
+		for sdkKind, d := range ep.analyticsEndpoints {
+			sdkKind := sdkKind
+			d.onEventsForwarded = func(count int) { onEventsForwarded(sdkKind, count) }
+		}
+	}
+	if transform != nil {
+		for sdkKind, d := range ep.analyticsEndpoints {
+			sdkKind := sdkKind
+			d.transform = func(evts []json.RawMessage) []json.RawMessage { return transform(sdkKind, evts) }
+		}
+	}
+	for sdkKind, d := range ep.analyticsEndpoints {
+		d.sampler = newEventSampler(sampling, sdkKind)
+	}
+	if budget := newEventMemoryBudget(memoryBudget); budget != nil {
+		for _, d := range ep.analyticsEndpoints {
+			d.memoryBudget = budget
+		}
+	}
 	return ep
 }
 
@@ -237,10 +342,25 @@ func (r *EventDispatcher) Close() {
 	for _, e := range r.analyticsEndpoints {
 		e.close()
 	}
+	if r.coordinationStore != nil {
+		_ = r.coordinationStore.Close()
+	}
 	// diagnosticEventEndpointDispatcher doesn't currently need to be closed, because it doesn't maintain any
 	// goroutines or channels
 }
 
+// IsOperational returns false if the most recent attempt to deliver analytics events to LaunchDarkly
+// from any of this environment's endpoints failed, including if delivery was stopped because the
+// credential was rejected. Endpoints that have not delivered anything yet are considered operational.
+func (r *EventDispatcher) IsOperational() bool {
+	for _, e := range r.analyticsEndpoints {
+		if !e.status.isOperational() {
+			return false
+		}
+	}
+	return true
+}
+
 func (r *EventDispatcher) flush() { //nolint:unused // used only in tests
 	for _, e := range r.analyticsEndpoints {
 		e.flush()
@@ -265,12 +385,18 @@ func newDiagnosticEventEndpointDispatcher(
 	remotePath string,
 ) *diagnosticEventEndpointDispatcher {
 	eventsURI := getEventsURI(config)
-	return &diagnosticEventEndpointDispatcher{
+	d := &diagnosticEventEndpointDispatcher{
 		httpClient:        httpConfig.Client(),
 		httpConfig:        httpConfig,
 		remoteEndpointURI: strings.TrimRight(eventsURI, "/") + remotePath,
+		mode:              config.DiagnosticEvents,
+		instanceName:      config.DiagnosticInstanceName,
 		loggers:           loggers,
 	}
+	if d.mode == c.DiagnosticEventsAnnotate && d.instanceName == "" {
+		d.instanceName, _ = os.Hostname()
+	}
+	return d
 }
 
 func newAnalyticsEventEndpointDispatcher(
@@ -281,8 +407,21 @@ func newAnalyticsEventEndpointDispatcher(
 	loggers ldlog.Loggers,
 	remotePath string,
 	eventQueueCleanupInterval time.Duration,
+	coordinationStore EventCoordinationStore,
+	spool *EventSpool,
+	exporter *eventExportEndpoint,
 ) *analyticsEventEndpointDispatcher {
+	spoolEndpoint := spool.newEndpoint(authKey, config, httpConfig, loggers, remotePath)
+	var coordinator *eventCoordinator
+	if coordinationStore != nil {
+		coordinator = newEventCoordinator(coordinationStore, authKey, config, httpConfig, loggers, remotePath,
+			spoolEndpoint, exporter)
+	}
 	return &analyticsEventEndpointDispatcher{
+		coordinator:               coordinator,
+		status:                    &deliveryStatus{},
+		spool:                     spoolEndpoint,
+		exporter:                  exporter,
 		authKey:                   authKey,
 		config:                    config,
 		httpClient:                httpConfig.Client(),
@@ -300,11 +439,21 @@ func newEventVerbatimRelay(
 	httpConfig httpconfig.HTTPConfig,
 	loggers ldlog.Loggers,
 	remotePath string,
+	coordinator *eventCoordinator,
+	spool *eventSpoolEndpoint,
+	exporter *eventExportEndpoint,
+	status *deliveryStatus,
+	memoryBudget *eventMemoryBudget,
 ) *eventVerbatimRelay {
 	eventsURI := getEventsURI(config)
 	opts := []OptionType{
 		OptionCapacity(config.Capacity.GetOrElse(c.DefaultEventCapacity)),
 		OptionEndpointURI(strings.TrimRight(eventsURI, "/") + remotePath),
+		optionCoordinator{coordinator},
+		optionSpool{spool},
+		optionEventExport{exporter},
+		optionDeliveryStatus{status},
+		optionMemoryBudget{memoryBudget},
 	}
 
 	opts = append(opts, OptionFlushInterval(config.FlushInterval.GetOrElse(c.DefaultEventsFlushInterval)))
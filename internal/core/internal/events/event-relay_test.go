@@ -1,6 +1,8 @@
 package events
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -11,6 +13,7 @@ import (
 	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
 	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/relay/version"
 
 	"github.com/launchdarkly/go-configtypes"
 	"github.com/launchdarkly/go-test-helpers/v2/httphelpers"
@@ -62,6 +65,14 @@ var allTestEndpoints = []testEndpointInfo{testServerEndpointInfo, testMobileEndp
 
 type eventRelayTestOptions struct {
 	eventQueueCleanupInterval time.Duration
+	onEventsForwarded         func(basictypes.SDKKind, int)
+	coordinationStore         EventCoordinationStore
+	spool                     *EventSpool
+	transform                 func(basictypes.SDKKind, []json.RawMessage) []json.RawMessage
+	exporter                  *EnvironmentEventExporter
+	memoryBudget              EventMemoryBudget
+	sampling                  EventSampling
+	serverHandler             http.Handler
 }
 
 type eventRelayTestParams struct {
@@ -95,7 +106,11 @@ func eventRelayTestWithOptions(
 
 	store := st.NewInMemoryStore()
 
-	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	serverHandler := opts.serverHandler
+	if serverHandler == nil {
+		serverHandler = httphelpers.HandlerWithStatus(202)
+	}
+	handler, requestsCh := httphelpers.RecordingHandler(serverHandler)
 	httphelpers.WithServer(handler, func(server *httptest.Server) {
 		eventsConfig.SendEvents = true
 		if !eventsConfig.FlushInterval.IsDefined() {
@@ -113,6 +128,13 @@ func eventRelayTestWithOptions(
 			httpConfig,
 			makeStoreAdapterWithExistingStore(store),
 			opts.eventQueueCleanupInterval,
+			opts.onEventsForwarded,
+			opts.coordinationStore,
+			opts.spool,
+			opts.transform,
+			opts.exporter,
+			opts.memoryBudget,
+			opts.sampling,
 		)
 		defer dispatcher.Close()
 
@@ -178,6 +200,54 @@ func TestVerbatimEventHandlers(t *testing.T) {
 	}
 }
 
+func TestEventDispatcherReportsForwardedEvents(t *testing.T) {
+	for _, e := range allTestEndpoints {
+		t.Run(string(e.sdkKind), func(t *testing.T) {
+			forwarded := make(map[basictypes.SDKKind]int)
+			opts := eventRelayTestOptions{
+				onEventsForwarded: func(sdkKind basictypes.SDKKind, count int) { forwarded[sdkKind] += count },
+			}
+			eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+				req := st.BuildRequest("POST", "/", []byte(eventPayloadForVerbatimOnly),
+					headersWithEventSchema(SummaryEventsSchemaVersion))
+				handler := p.dispatcher.GetHandler(e.sdkKind, ldevents.AnalyticsEventDataKind)
+				require.NotNil(t, handler)
+				handler(httptest.NewRecorder(), req)
+
+				assert.Equal(t, map[basictypes.SDKKind]int{e.sdkKind: 3}, forwarded)
+			})
+		})
+	}
+}
+
+func TestEventDispatcherTransformsEvents(t *testing.T) {
+	for _, e := range allTestEndpoints {
+		t.Run(string(e.sdkKind), func(t *testing.T) {
+			opts := eventRelayTestOptions{
+				transform: func(sdkKind basictypes.SDKKind, evts []json.RawMessage) []json.RawMessage {
+					if string(evts[0]) == `"drop-all"` {
+						return nil
+					}
+					return []json.RawMessage{json.RawMessage(`"transformed-` + string(sdkKind) + `"`)}
+				},
+			}
+			eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+				handler := p.dispatcher.GetHandler(e.sdkKind, ldevents.AnalyticsEventDataKind)
+				require.NotNil(t, handler)
+				handler(httptest.NewRecorder(), st.BuildRequest("POST", "/", []byte(`["drop-all"]`),
+					headersWithEventSchema(SummaryEventsSchemaVersion)))
+				handler(httptest.NewRecorder(), st.BuildRequest("POST", "/", []byte(eventPayloadForVerbatimOnly),
+					headersWithEventSchema(SummaryEventsSchemaVersion)))
+
+				p.dispatcher.flush()
+
+				r := st.ExpectTestRequest(t, p.requestsCh, time.Second)
+				assert.Equal(t, `["transformed-`+string(e.sdkKind)+`"]`, string(r.Body))
+			})
+		})
+	}
+}
+
 func TestSummarizingEventHandlers(t *testing.T) {
 	// The summarizing relay logic is tested in more detail in summarizing-relay_test.go. The test here
 	// just verifies that we are indeed using the summarizing relay for these endpoints.
@@ -204,6 +274,30 @@ func TestSummarizingEventHandlers(t *testing.T) {
 	}
 }
 
+func TestEventDispatcherDeliversQueuedEventsOnClose(t *testing.T) {
+	for _, schemaVersion := range []int{0, SummaryEventsSchemaVersion} {
+		// events with an old schema version go through the summarizing relay; others are forwarded verbatim
+		t.Run(fmt.Sprintf("schema version %d", schemaVersion), func(t *testing.T) {
+			eventRelayTest(t, st.EnvWithAllCredentials, config.EventsConfig{}, func(p eventRelayTestParams) {
+				body := eventPayloadForVerbatimOnly
+				if schemaVersion == 0 {
+					body = summarizableFeatureEvents
+				}
+				req := st.BuildRequest("POST", "/", []byte(body), headersWithEventSchema(schemaVersion))
+				handler := p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)
+				require.NotNil(t, handler)
+				handler(httptest.NewRecorder(), req)
+
+				p.dispatcher.Close()
+
+				require.Len(t, p.requestsCh, 1, "expected Close to wait until events were delivered")
+				r := <-p.requestsCh
+				assert.Equal(t, strconv.Itoa(SummaryEventsSchemaVersion), r.Request.Header.Get(EventSchemaHeader))
+			})
+		})
+	}
+}
+
 func TestDiagnosticEventForwarding(t *testing.T) {
 	for _, e := range allTestEndpoints {
 		t.Run(string(e.sdkKind), func(t *testing.T) {
@@ -228,6 +322,37 @@ func TestDiagnosticEventForwarding(t *testing.T) {
 	}
 }
 
+func TestDiagnosticEventsCanBeBlocked(t *testing.T) {
+	eventsConfig := config.EventsConfig{DiagnosticEvents: config.DiagnosticEventsBlock}
+	eventRelayTest(t, st.EnvWithAllCredentials, eventsConfig, func(p eventRelayTestParams) {
+		req := st.BuildRequest("POST", "/", []byte(`{"kind":"diagnostic"}`), headersWithEventSchema(0))
+		handler := p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.DiagnosticEventDataKind)
+		require.NotNil(t, handler)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		assert.Equal(t, http.StatusAccepted, w.Result().StatusCode)
+
+		st.ExpectNoTestRequests(t, p.requestsCh, time.Millisecond*100)
+	})
+}
+
+func TestDiagnosticEventsCanBeAnnotated(t *testing.T) {
+	eventsConfig := config.EventsConfig{DiagnosticEvents: config.DiagnosticEventsAnnotate, DiagnosticInstanceName: "relay-1"}
+	eventRelayTest(t, st.EnvWithAllCredentials, eventsConfig, func(p eventRelayTestParams) {
+		body := `{"kind":"diagnostic","platform":{"name":"Go"}}`
+		req := st.BuildRequest("POST", "/", []byte(body), headersWithEventSchema(0))
+		handler := p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.DiagnosticEventDataKind)
+		require.NotNil(t, handler)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		assert.Equal(t, http.StatusAccepted, w.Result().StatusCode)
+
+		r := st.ExpectTestRequest(t, p.requestsCh, time.Second)
+		m.In(t).Assert(r.Body, m.JSONStrEqual(
+			`{"kind":"diagnostic","platform":{"name":"Go","relayProxyVersion":"`+version.Version+`","relayProxyInstance":"relay-1"}}`))
+	})
+}
+
 func TestEventDispatcherReplaceCredential(t *testing.T) {
 	eventRelayTest(t, st.EnvWithAllCredentials, config.EventsConfig{}, func(p eventRelayTestParams) {
 		// First, just post some events to all the dispatchers to make sure they've been lazily created.
@@ -275,6 +400,34 @@ func TestEventDispatcherReplaceCredential(t *testing.T) {
 	})
 }
 
+func TestEventDispatcherIsOperationalUnlessDeliveryFailed(t *testing.T) {
+	for _, schemaVersion := range []int{0, SummaryEventsSchemaVersion} {
+		t.Run(fmt.Sprintf("schema version %d", schemaVersion), func(t *testing.T) {
+			// a 401 error is not retried, and stops any further deliveries with the same credential
+			opts := eventRelayTestOptions{serverHandler: httphelpers.HandlerWithStatus(401)}
+			eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+				assert.True(t, p.dispatcher.IsOperational())
+
+				body := eventPayloadForVerbatimOnly
+				if schemaVersion == 0 {
+					body = summarizableFeatureEvents
+				}
+				req := st.BuildRequest("POST", "/", []byte(body), headersWithEventSchema(schemaVersion))
+				handler := p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)
+				require.NotNil(t, handler)
+				handler(httptest.NewRecorder(), req)
+				p.dispatcher.flush()
+
+				_ = st.ExpectTestRequest(t, p.requestsCh, time.Second)
+				require.Eventually(t, func() bool { return !p.dispatcher.IsOperational() }, time.Second, time.Millisecond*10)
+
+				p.dispatcher.ReplaceCredential(testServerEndpointInfo.newCredential)
+				assert.True(t, p.dispatcher.IsOperational())
+			})
+		})
+	}
+}
+
 func TestEventHandlersRejectMalformedJSON(t *testing.T) {
 	malformedInput := `[{"no`
 	eventRelayTest(t, st.EnvWithAllCredentials, config.EventsConfig{}, func(p eventRelayTestParams) {
@@ -1,7 +1,9 @@
 package events
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/url"
 	"reflect"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/launchdarkly/ld-relay/v6/config"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/tracing"
 
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
@@ -26,6 +29,9 @@ const (
 
 var (
 	defaultEventsEndpointURI, _ = url.Parse("https://events.launchdarkly.com/bulk") //nolint:gochecknoglobals
+
+	// The EventSender logs the details of a delivery failure itself, so the span only needs to show that it failed
+	errEventsNotDelivered = errors.New("events could not be delivered")
 )
 
 // EventPublisher is the interface for the component that buffers events and delivers them to LaunchDarkly.
@@ -53,7 +59,8 @@ type EventPublisher interface {
 	// credential was of the same type.
 	ReplaceCredential(config.SDKCredential)
 
-	// Close releases all resources used by this object.
+	// Close delivers any queued events, waits for deliveries to finish, and releases all resources used
+	// by this object.
 	Close()
 }
 
@@ -90,11 +97,18 @@ type HTTPEventPublisher struct {
 	closeOnce   sync.Once
 	wg          sync.WaitGroup
 	inputQueue  chan interface{}
-
-	queues     map[EventPayloadMetadata]*publisherQueue
-	capacity   int
-	overflowed bool
-	lock       sync.RWMutex
+	coordinator *eventCoordinator
+	spool       *eventSpoolEndpoint
+	exporter    *eventExportEndpoint
+	status      *deliveryStatus
+	budget      *eventMemoryBudget
+
+	queues      map[EventPayloadMetadata]*publisherQueue
+	capacity    int
+	overflowed  bool
+	queuedBytes int
+	overBudget  bool
+	lock        sync.RWMutex
 }
 
 type eventBatch struct {
@@ -150,6 +164,57 @@ func (o OptionCapacity) apply(p *HTTPEventPublisher) error {
 	return nil
 }
 
+// optionCoordinator applies event coordination to the publisher's payloads; see eventCoordinator.
+type optionCoordinator struct {
+	coordinator *eventCoordinator
+}
+
+func (o optionCoordinator) apply(p *HTTPEventPublisher) error {
+	p.coordinator = o.coordinator
+	return nil
+}
+
+// optionSpool saves the publisher's payloads to a spool if they can't be delivered; see EventSpool.
+type optionSpool struct {
+	spool *eventSpoolEndpoint
+}
+
+func (o optionSpool) apply(p *HTTPEventPublisher) error {
+	p.spool = o.spool
+	return nil
+}
+
+// optionEventExport writes the publisher's payloads to the configured export destinations; see
+// EventExporter.
+type optionEventExport struct {
+	exporter *eventExportEndpoint
+}
+
+func (o optionEventExport) apply(p *HTTPEventPublisher) error {
+	p.exporter = o.exporter
+	return nil
+}
+
+// optionDeliveryStatus records the results of the publisher's deliveries; see deliveryStatus.
+type optionDeliveryStatus struct {
+	status *deliveryStatus
+}
+
+func (o optionDeliveryStatus) apply(p *HTTPEventPublisher) error {
+	p.status = o.status
+	return nil
+}
+
+// optionMemoryBudget limits the total size of the publisher's queued events; see EventMemoryBudget.
+type optionMemoryBudget struct {
+	budget *eventMemoryBudget
+}
+
+func (o optionMemoryBudget) apply(p *HTTPEventPublisher) error {
+	p.budget = o.budget
+	return nil
+}
+
 // NewHTTPEventPublisher creates a new HTTPEventPublisher.
 func NewHTTPEventPublisher(authKey config.SDKCredential, httpConfig httpconfig.HTTPConfig, loggers ldlog.Loggers, options ...OptionType) (*HTTPEventPublisher, error) {
 	closer := make(chan struct{})
@@ -207,6 +272,7 @@ func NewHTTPEventPublisher(authKey config.SDKCredential, httpConfig httpconfig.H
 				case <-ticker.C:
 					p.flush()
 				case <-closer:
+					p.flushRemaining()
 					break EventLoop
 				}
 			}
@@ -220,14 +286,36 @@ func NewHTTPEventPublisher(authKey config.SDKCredential, httpConfig httpconfig.H
 }
 
 func (p *HTTPEventPublisher) append(batch eventBatch) {
-	queue := p.queues[batch.metadata]
+	events := batch.events
+	for {
+		taken := p.takeWithinMemoryBudget(events)
+		p.appendToQueue(batch.metadata, events[:taken])
+		events = events[taken:]
+		if len(events) == 0 {
+			p.overBudget = false
+			return
+		}
+		if !p.budget.flushWhenFull || p.queuedBytes == 0 {
+			if !p.overBudget {
+				p.loggers.Warnf("Exceeded memory budget of %d bytes for queued events; dropping events", p.budget.maxBytes)
+				p.overBudget = true
+			}
+			return
+		}
+		// The budget is configured to deliver the queued events early to make room, rather than dropping any.
+		p.flush()
+	}
+}
+
+func (p *HTTPEventPublisher) appendToQueue(metadata EventPayloadMetadata, events []json.RawMessage) {
+	queue := p.queues[metadata]
 	if queue == nil {
 		queue = &publisherQueue{events: make([]json.RawMessage, 0, p.capacity)}
-		p.queues[batch.metadata] = queue
+		p.queues[metadata] = queue
 	}
 	available := p.capacity - len(queue.events)
-	taken := len(batch.events)
-	if available < len(batch.events) {
+	taken := len(events)
+	if available < len(events) {
 		if !p.overflowed {
 			p.loggers.Warnf("Exceeded event queue capacity of %d. Increase capacity to avoid dropping events.", p.capacity)
 			p.overflowed = true
@@ -236,7 +324,34 @@ func (p *HTTPEventPublisher) append(batch eventBatch) {
 	} else {
 		p.overflowed = false
 	}
-	queue.events = append(queue.events, batch.events[:taken]...)
+	p.releaseMemory(events[taken:])
+	queue.events = append(queue.events, events[:taken]...)
+}
+
+// takeWithinMemoryBudget returns how many of the events, starting from the first, fit within the memory
+// budget, and counts them toward it. Without a budget, they all fit.
+func (p *HTTPEventPublisher) takeWithinMemoryBudget(events []json.RawMessage) int {
+	if p.budget == nil {
+		return len(events)
+	}
+	taken := 0
+	for taken < len(events) && p.budget.reserve(len(events[taken])) {
+		p.queuedBytes += len(events[taken])
+		taken++
+	}
+	return taken
+}
+
+func (p *HTTPEventPublisher) releaseMemory(events []json.RawMessage) {
+	if p.budget == nil || len(events) == 0 {
+		return
+	}
+	size := 0
+	for _, e := range events {
+		size += len(e)
+	}
+	p.budget.release(size)
+	p.queuedBytes -= size
 }
 
 func (p *HTTPEventPublisher) ReplaceCredential(newCredential config.SDKCredential) { //nolint:golint // method is already documented in interface
@@ -268,6 +383,8 @@ func (p *HTTPEventPublisher) flush() {
 	if len(p.queues) == 0 {
 		return
 	}
+	p.budget.release(p.queuedBytes)
+	p.queuedBytes = 0
 	queues := p.queues
 	discardingUnusedBuffers := false
 	if len(p.queues) > 1 {
@@ -297,20 +414,20 @@ func (p *HTTPEventPublisher) flush() {
 		}
 		p.wg.Add(1)
 
-		sender := makeEventSender(
+		sender := p.coordinator.wrapSender(p.spool.wrapSender(p.exporter.wrapSender(p.status.wrapSender(makeEventSender(
 			p.client,
 			p.eventsURI.String(),
 			p.baseHeaders,
 			authKey,
 			metadata,
 			p.loggers,
-		)
+		)), metadata), metadata), metadata)
 
 		go func() {
 			// The EventSender created by ldevents.NewDefaultEventSender implements the standard retry behavior,
 			// and error logging, in its SendEventData method. Retries could cause this call to block for a while,
 			// so it's run on a separate goroutine.
-			result := sender.SendEventData(ldevents.AnalyticsEventDataKind, payload, count)
+			result := sendTracedEventData(sender, ldevents.AnalyticsEventDataKind, payload, count)
 			p.wg.Done()
 			if result.MustShutDown {
 				p.Close()
@@ -319,6 +436,23 @@ func (p *HTTPEventPublisher) flush() {
 	}
 }
 
+// flushRemaining is called when the publisher is closed. It takes any events that were published but not
+// yet read from the input queue, and starts delivering everything that is queued, so that events are not
+// lost on shutdown; Close waits for those deliveries to finish.
+func (p *HTTPEventPublisher) flushRemaining() {
+	for {
+		select {
+		case e := <-p.inputQueue:
+			if e, ok := e.(eventBatch); ok {
+				p.append(e)
+			}
+		default:
+			p.flush()
+			return
+		}
+	}
+}
+
 func (p *HTTPEventPublisher) Close() { //nolint:golint // method is already documented in interface
 	p.closeOnce.Do(func() {
 		close(p.closer)
@@ -326,6 +460,26 @@ func (p *HTTPEventPublisher) Close() { //nolint:golint // method is already docu
 	})
 }
 
+// sendTracedEventData delivers a payload with an EventSender, tracing the whole delivery (including
+// any retries) as one span.
+func sendTracedEventData(
+	sender ldevents.EventSender,
+	kind ldevents.EventDataKind,
+	data []byte,
+	count int,
+) ldevents.EventSenderResult {
+	_, span := tracing.StartSpan(context.Background(), "forward events", tracing.SpanKindClient,
+		tracing.String("ld.events.kind", string(kind)),
+		tracing.Int("ld.events.count", count),
+	)
+	defer span.End()
+	result := sender.SendEventData(kind, data, count)
+	if !result.Success {
+		span.SetError(errEventsNotDelivered)
+	}
+	return result
+}
+
 // makeEventSender creates a new instance of the EventSender component that is provided by go-sdk-events,
 // configuring it to have the appropriate HTTP request headers.
 //
@@ -18,6 +18,7 @@ import (
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const testSDKKey = config.SDKKey("my-key")
@@ -105,6 +106,21 @@ func TestHTTPEventPublisherClosesImmediatelyAndOnlyOnce(t *testing.T) {
 	assert.Len(t, timeout, 0, "expected timeout to not have triggered but it did")
 }
 
+func TestHTTPEventPublisherDeliversQueuedEventsOnClose(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		publisher, _ := NewHTTPEventPublisher(testSDKKey, defaultHTTPConfig(), mockLog.Loggers, OptionURI(server.URL))
+		publisher.Publish(EventPayloadMetadata{}, json.RawMessage(`"hello"`))
+		publisher.Publish(EventPayloadMetadata{}, json.RawMessage(`"hello again"`))
+		publisher.Close()
+		require.Len(t, requestsCh, 1, "expected Close to wait until events were delivered")
+		r := <-requestsCh
+		m.In(t).Assert(r.Body, m.JSONStrEqual(`["hello", "hello again"]`))
+	})
+}
+
 func TestHTTPPublisherAutomaticFlush(t *testing.T) {
 	mockLog := ldlogtest.NewMockLog()
 	defer mockLog.DumpIfTestFailed(t)
@@ -152,6 +168,51 @@ func TestHTTPEventPublisherCapacity(t *testing.T) {
 	})
 }
 
+func TestHTTPEventPublisherMemoryBudget(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		budget := newEventMemoryBudget(EventMemoryBudget{MaxBytes: 16})
+		publisher, _ := NewHTTPEventPublisher(config.SDKKey("my-key"), defaultHTTPConfig(), mockLog.Loggers,
+			OptionURI(server.URL), optionMemoryBudget{budget})
+		defer publisher.Close()
+		publisher.Publish(EventPayloadMetadata{}, json.RawMessage(`"hello"`), json.RawMessage(`"goodbye"`))
+		publisher.Publish(EventPayloadMetadata{}, json.RawMessage(`"again"`))
+		publisher.Flush()
+		r := st.ExpectTestRequest(t, requestsCh, time.Second)
+		m.In(t).Assert(r.Body, m.JSONStrEqual(`["hello","goodbye"]`))
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Exceeded memory budget of 16 bytes")
+
+		// the flush released the memory that was used, so events can be queued again
+		publisher.Publish(EventPayloadMetadata{}, json.RawMessage(`"again"`))
+		publisher.Flush()
+		r = st.ExpectTestRequest(t, requestsCh, time.Second)
+		m.In(t).Assert(r.Body, m.JSONStrEqual(`["again"]`))
+	})
+}
+
+func TestHTTPEventPublisherMemoryBudgetWithFlushWhenFull(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		budget := newEventMemoryBudget(EventMemoryBudget{MaxBytes: 16, FlushWhenFull: true})
+		publisher, _ := NewHTTPEventPublisher(config.SDKKey("my-key"), defaultHTTPConfig(), mockLog.Loggers,
+			OptionURI(server.URL), optionMemoryBudget{budget})
+		defer publisher.Close()
+		publisher.Publish(EventPayloadMetadata{}, json.RawMessage(`"hello"`), json.RawMessage(`"goodbye"`),
+			json.RawMessage(`"again"`))
+		r := st.ExpectTestRequest(t, requestsCh, time.Second)
+		m.In(t).Assert(r.Body, m.JSONStrEqual(`["hello","goodbye"]`))
+
+		publisher.Flush()
+		r = st.ExpectTestRequest(t, requestsCh, time.Second)
+		m.In(t).Assert(r.Body, m.JSONStrEqual(`["again"]`))
+		assert.Len(t, mockLog.GetOutput(ldlog.Warn), 0)
+	})
+}
+
 func TestHTTPEventPublisherErrorRetry(t *testing.T) {
 	testRecoverableError := func(t *testing.T, errorHandler http.Handler) {
 		mockLog := ldlogtest.NewMockLog()
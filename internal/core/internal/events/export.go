@@ -0,0 +1,382 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
+)
+
+const (
+	// This is the number of payloads that can be waiting to be exported to each destination. If the
+	// destination can't keep up, further payloads are dropped rather than using unbounded memory.
+	exportQueueCapacity = 100
+
+	// Destinations that don't have their own retry logic use these values for retrying failed writes.
+	exportMaxAttempts = 3
+	exportRetryDelay  = time.Second
+)
+
+// Attributes that are added to each message in the "event" format, as Kafka headers or Pub/Sub
+// attributes.
+const (
+	exportEnvNameAttribute   = "ld-env-name"
+	exportEnvIDAttribute     = "ld-env-id"
+	exportSDKKindAttribute   = "ld-sdk-kind"
+	exportEventKindAttribute = "ld-event-kind"
+	exportTagsAttribute      = "ld-tags"
+)
+
+// EventExporter writes the analytics events that Relay forwards to the export destinations that are
+// configured: Kafka, Kinesis, and Pub/Sub. There is one EventExporter for all environments.
+//
+// The events that are exported are the output events that would be delivered to LaunchDarkly, after any
+// summarization, event coordination, and transformation, so each feature or custom event appears once no
+// matter how many Relay instances there are. Each event is a separate message, keyed by user key if the
+// event has one, so that the events for a user stay in order.
+//
+// Each destination has a goroutine that writes to it, so a slow or unavailable destination does not hold
+// up delivery to LaunchDarkly. If a destination is in redirect mode, events are not delivered to
+// LaunchDarkly, and they are considered to have been delivered as soon as they are queued for export;
+// failed writes are retried, but they are not saved in the event spool.
+type EventExporter struct {
+	sinks []*eventExportSink
+}
+
+// EnvironmentEventExporter is the part of an EventExporter that is used by one environment. It includes
+// only the destinations that the environment exports to.
+type EnvironmentEventExporter struct {
+	sinks   []*eventExportSink
+	envName string
+	envID   string
+}
+
+// eventExportEndpoint is the part of an EventExporter that is used by one analytics endpoint of an
+// environment.
+type eventExportEndpoint struct {
+	sinks    []*eventExportSink
+	envName  string
+	envID    string
+	sdkKind  basictypes.SDKKind
+	redirect bool
+}
+
+type exportingEventSender struct {
+	wrapped  ldevents.EventSender
+	endpoint *eventExportEndpoint
+	metadata EventPayloadMetadata
+}
+
+// exportMessage is one event to be written to a destination.
+type exportMessage struct {
+	key        string // the user key, or "" if the event doesn't have one
+	value      []byte
+	attributes []exportAttribute // describes the environment, if the format is "event"
+}
+
+type exportAttribute struct {
+	key   string
+	value string
+}
+
+// exportPublisher writes messages to one kind of destination. Its publish method is called from a
+// single goroutine, and blocks until the messages have been written or it has given up.
+type exportPublisher interface {
+	publish(messages []exportMessage) error
+	Close() error
+}
+
+// eventExportSink is one export destination and the goroutine that writes to it.
+type eventExportSink struct {
+	name      string // as used in EnvConfig.EventExport
+	display   string // as used in log messages
+	publisher exportPublisher
+	redirect  bool
+	envelope  bool
+	queue     chan []exportMessage
+	done      chan struct{}
+	loggers   ldlog.Loggers
+	failing   bool
+	dropping  bool
+	lock      sync.Mutex
+	closeOnce sync.Once
+}
+
+// exportEnvelope is the content of each message in the "envelope" format.
+type exportEnvelope struct {
+	EnvName string          `json:"envName"`
+	EnvID   string          `json:"envId,omitempty"`
+	SDKKind string          `json:"sdkKind"`
+	Tags    string          `json:"tags,omitempty"`
+	Event   json.RawMessage `json:"event"`
+}
+
+// exportEventFields is the part of an output event that we look at to choose its message key.
+type exportEventFields struct {
+	Kind    string `json:"kind"`
+	UserKey string `json:"userKey"`
+	User    *struct {
+		Key string `json:"key"`
+	} `json:"user"`
+}
+
+// NewEventExporter creates an EventExporter for the destinations in the configuration, or returns nil if
+// none are configured.
+func NewEventExporter(config c.Config, loggers ldlog.Loggers) (*EventExporter, error) {
+	x := &EventExporter{}
+	if len(config.Kafka.Brokers.Values()) != 0 {
+		publisher, err := newKafkaPublisher(config.Kafka)
+		if err != nil {
+			return nil, err
+		}
+		x.addSink(c.EventExportKafka, "Kafka", publisher, config.Kafka.Mode, config.Kafka.Format, loggers)
+		loggers.Infof("Exporting analytics events to Kafka topic %q", config.Kafka.Topic)
+	}
+	if config.Kinesis.StreamName != "" {
+		publisher, err := newKinesisPublisher(config.Kinesis)
+		if err != nil {
+			_ = x.Close()
+			return nil, err
+		}
+		x.addSink(c.EventExportKinesis, "Kinesis", publisher, config.Kinesis.Mode, config.Kinesis.Format, loggers)
+		loggers.Infof("Exporting analytics events to Kinesis stream %q", config.Kinesis.StreamName)
+	}
+	if config.PubSub.Topic != "" {
+		publisher, err := newPubSubPublisher(config.PubSub)
+		if err != nil {
+			_ = x.Close()
+			return nil, err
+		}
+		x.addSink(c.EventExportPubSub, "Pub/Sub", publisher, config.PubSub.Mode, config.PubSub.Format, loggers)
+		loggers.Infof("Exporting analytics events to Pub/Sub topic %q", publisher.topic)
+	}
+	if len(x.sinks) == 0 {
+		return nil, nil
+	}
+	return x, nil
+}
+
+func (x *EventExporter) addSink(name, display string, publisher exportPublisher, mode, format string, loggers ldlog.Loggers) {
+	x.sinks = append(x.sinks, newEventExportSink(name, display, publisher, mode, format, loggers))
+}
+
+func newEventExportSink(name, display string, publisher exportPublisher, mode, format string, loggers ldlog.Loggers) *eventExportSink {
+	s := &eventExportSink{
+		name:      name,
+		display:   display,
+		publisher: publisher,
+		redirect:  mode == c.EventExportModeRedirect,
+		envelope:  format == c.EventExportFormatEnvelope,
+		queue:     make(chan []exportMessage, exportQueueCapacity),
+		done:      make(chan struct{}),
+		loggers:   loggers,
+	}
+	go s.run()
+	return s
+}
+
+// Close waits for any events that have not yet been exported, and then closes the connections to the
+// destinations. It should be called after all of the environments have been closed.
+func (x *EventExporter) Close() error {
+	var firstErr error
+	for _, s := range x.sinks {
+		if err := s.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ForEnvironment returns the EnvironmentEventExporter for an environment, or nil if the environment does
+// not export events to any destination. If x is nil, it returns nil.
+func (x *EventExporter) ForEnvironment(envName string, envConfig c.EnvConfig) *EnvironmentEventExporter {
+	if x == nil {
+		return nil
+	}
+	sinks := x.sinks
+	if names := envConfig.EventExport.Values(); len(names) != 0 {
+		sinks = nil
+		for _, s := range x.sinks {
+			for _, name := range names {
+				if name == s.name {
+					sinks = append(sinks, s)
+				}
+			}
+		}
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+	return &EnvironmentEventExporter{sinks: sinks, envName: envName, envID: string(envConfig.EnvID)}
+}
+
+// newEndpoint creates the eventExportEndpoint for an analytics endpoint. If e is nil, it returns nil.
+func (e *EnvironmentEventExporter) newEndpoint(sdkKind basictypes.SDKKind) *eventExportEndpoint {
+	if e == nil {
+		return nil
+	}
+	ret := &eventExportEndpoint{sinks: e.sinks, envName: e.envName, envID: e.envID, sdkKind: sdkKind}
+	for _, s := range e.sinks {
+		ret.redirect = ret.redirect || s.redirect
+	}
+	return ret
+}
+
+// wrapSender returns an EventSender that exports analytics payloads, and then, unless any of the
+// destinations is in redirect mode, delivers them with sender. If e is nil, it returns sender unchanged.
+func (e *eventExportEndpoint) wrapSender(sender ldevents.EventSender, metadata EventPayloadMetadata) ldevents.EventSender {
+	if e == nil {
+		return sender
+	}
+	return &exportingEventSender{wrapped: sender, endpoint: e, metadata: metadata}
+}
+
+func (e *eventExportEndpoint) export(metadata EventPayloadMetadata, data []byte) {
+	var events []json.RawMessage
+	if err := json.Unmarshal(data, &events); err != nil { // COVERAGE: can't happen in unit tests
+		e.sinks[0].loggers.Errorf("Unexpected error parsing events to export: %s", err)
+		return
+	}
+	for _, s := range e.sinks {
+		messages := make([]exportMessage, 0, len(events))
+		for _, event := range events {
+			msg, err := e.makeMessage(s.envelope, metadata, event)
+			if err != nil { // COVERAGE: can't happen in unit tests
+				s.loggers.Errorf("Unexpected error preparing event to export to %s: %s", s.display, err)
+				continue
+			}
+			messages = append(messages, msg)
+		}
+		if len(messages) != 0 {
+			s.enqueue(messages)
+		}
+	}
+}
+
+func (e *eventExportEndpoint) makeMessage(envelope bool, metadata EventPayloadMetadata, event json.RawMessage) (exportMessage, error) {
+	var fields exportEventFields
+	_ = json.Unmarshal(event, &fields)
+	msg := exportMessage{key: fields.UserKey}
+	if msg.key == "" && fields.User != nil {
+		msg.key = fields.User.Key
+	}
+	if envelope {
+		value, err := json.Marshal(exportEnvelope{
+			EnvName: e.envName,
+			EnvID:   e.envID,
+			SDKKind: string(e.sdkKind),
+			Tags:    metadata.Tags,
+			Event:   event,
+		})
+		if err != nil {
+			return msg, err
+		}
+		msg.value = value
+		return msg, nil
+	}
+	msg.value = event
+	msg.attributes = []exportAttribute{
+		{exportEnvNameAttribute, e.envName},
+		{exportSDKKindAttribute, string(e.sdkKind)},
+		{exportEventKindAttribute, fields.Kind},
+	}
+	if e.envID != "" {
+		msg.attributes = append(msg.attributes, exportAttribute{exportEnvIDAttribute, e.envID})
+	}
+	if metadata.Tags != "" {
+		msg.attributes = append(msg.attributes, exportAttribute{exportTagsAttribute, metadata.Tags})
+	}
+	return msg, nil
+}
+
+func (s *exportingEventSender) SendEventData(kind ldevents.EventDataKind, data []byte, count int) ldevents.EventSenderResult {
+	if kind == ldevents.AnalyticsEventDataKind {
+		s.endpoint.export(s.metadata, data)
+		if s.endpoint.redirect {
+			return ldevents.EventSenderResult{Success: true}
+		}
+	}
+	return s.wrapped.SendEventData(kind, data, count)
+}
+
+func (s *eventExportSink) enqueue(messages []exportMessage) {
+	select {
+	case s.queue <- messages:
+		s.setDropping(false, 0)
+	default:
+		s.setDropping(true, len(messages))
+	}
+}
+
+func (s *eventExportSink) run() {
+	defer close(s.done)
+	for messages := range s.queue {
+		s.handleWriteResult(len(messages), s.publisher.publish(messages))
+	}
+}
+
+func (s *eventExportSink) close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.queue)
+		<-s.done
+		err = s.publisher.Close()
+	})
+	return err
+}
+
+// handleWriteResult logs the first of a series of failed writes, and the recovery after it, so that an
+// unavailable destination doesn't cause an error for every payload.
+func (s *eventExportSink) handleWriteResult(count int, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err != nil {
+		if !s.failing {
+			s.loggers.Errorf("Failed to export %d event(s) to %s; will log again when an export succeeds: %s",
+				count, s.display, err)
+			s.failing = true
+		}
+		return
+	}
+	if s.failing {
+		s.loggers.Infof("Resumed exporting events to %s", s.display)
+		s.failing = false
+	}
+}
+
+func (s *eventExportSink) setDropping(dropping bool, count int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if dropping && !s.dropping {
+		s.loggers.Warnf("Export to %s is not keeping up; discarding %d event(s), and any others until it catches up",
+			s.display, count)
+	}
+	s.dropping = dropping
+}
+
+// chunkExportMessages splits messages into batches that are within the limits of a destination's API.
+func chunkExportMessages(messages []exportMessage, maxCount, maxBytes int) [][]exportMessage {
+	var ret [][]exportMessage
+	start, size := 0, 0
+	for i, m := range messages {
+		if i > start && (i-start >= maxCount || size+len(m.value)+len(m.key) > maxBytes) {
+			ret = append(ret, messages[start:i])
+			start, size = i, 0
+		}
+		size += len(m.value) + len(m.key)
+	}
+	if start < len(messages) {
+		ret = append(ret, messages[start:])
+	}
+	return ret
+}
+
+func errExportRecordsFailed(count int, firstError string) error {
+	return fmt.Errorf("%d record(s) were not accepted: %s", count, firstError)
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Each payload is written with a single call, so there is no benefit in waiting for more messages to
+// fill up a batch.
+const kafkaBatchTimeout = time.Millisecond * 10
+
+// kafkaPublisher is the exportPublisher for a Kafka topic. The kafka.Writer retries failed writes.
+type kafkaPublisher struct {
+	writer kafkaWriter
+}
+
+// kafkaWriter is the subset of the kafka.Writer API that we use; it is an interface so that tests can
+// substitute a fake.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+func newKafkaPublisher(config c.KafkaConfig) (*kafkaPublisher, error) {
+	transport := &kafka.Transport{}
+	if config.TLSEnabled {
+		transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	mechanism, err := makeKafkaSASLMechanism(config)
+	if err != nil {
+		return nil, err
+	}
+	transport.SASL = mechanism
+	return &kafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(config.Brokers.Values()...),
+			Topic:        config.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: kafkaBatchTimeout,
+			Transport:    transport,
+		},
+	}, nil
+}
+
+func makeKafkaSASLMechanism(config c.KafkaConfig) (sasl.Mechanism, error) {
+	switch config.SASLMechanism {
+	case c.KafkaSASLPlain:
+		return plain.Mechanism{Username: config.Username, Password: config.Password}, nil
+	case c.KafkaSASLSCRAMSHA256:
+		return scram.Mechanism(scram.SHA256, config.Username, config.Password)
+	case c.KafkaSASLSCRAMSHA512:
+		return scram.Mechanism(scram.SHA512, config.Username, config.Password)
+	default: // config.ValidateConfig has ensured that there are no other values
+		return nil, nil
+	}
+}
+
+func (p *kafkaPublisher) publish(messages []exportMessage) error {
+	kafkaMessages := make([]kafka.Message, 0, len(messages))
+	for _, m := range messages {
+		km := kafka.Message{Value: m.value}
+		if m.key != "" {
+			km.Key = []byte(m.key)
+		}
+		for _, a := range m.attributes {
+			km.Headers = append(km.Headers, kafka.Header{Key: a.key, Value: []byte(a.value)})
+		}
+		kafkaMessages = append(kafkaMessages, km)
+	}
+	return p.writer.WriteMessages(context.Background(), kafkaMessages...)
+}
+
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/launchdarkly/go-configtypes"
+	"github.com/segmentio/kafka-go"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKafkaWriter struct {
+	writes [][]kafka.Message
+	closed bool
+}
+
+func (w *fakeKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.writes = append(w.writes, msgs)
+	return nil
+}
+
+func (w *fakeKafkaWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestNewKafkaPublisher(t *testing.T) {
+	p, err := newKafkaPublisher(config.KafkaConfig{
+		Brokers:       configtypes.NewOptStringList([]string{"broker1:9092", "broker2:9092"}),
+		Topic:         "ld-events",
+		TLSEnabled:    true,
+		SASLMechanism: config.KafkaSASLSCRAMSHA512,
+		Username:      "user",
+		Password:      "pass",
+	})
+	require.NoError(t, err)
+	writer := p.writer.(*kafka.Writer)
+	assert.Equal(t, "ld-events", writer.Topic)
+	assert.Equal(t, "broker1:9092,broker2:9092", writer.Addr.String())
+	transport := writer.Transport.(*kafka.Transport)
+	assert.NotNil(t, transport.TLS)
+	require.NotNil(t, transport.SASL)
+	assert.Equal(t, "SCRAM-SHA-512", transport.SASL.Name())
+}
+
+func TestKafkaPublisherWritesMessagesWithKeysAndHeaders(t *testing.T) {
+	writer := &fakeKafkaWriter{}
+	p := &kafkaPublisher{writer: writer}
+
+	require.NoError(t, p.publish([]exportMessage{
+		{key: "a", value: []byte(`{"kind":"custom"}`), attributes: []exportAttribute{{exportEnvNameAttribute, "my-env"}}},
+		{value: []byte(`{"kind":"summary"}`)},
+	}))
+	require.Len(t, writer.writes, 1)
+	msgs := writer.writes[0]
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "a", string(msgs[0].Key))
+	assert.Equal(t, `{"kind":"custom"}`, string(msgs[0].Value))
+	assert.Equal(t, []kafka.Header{{Key: exportEnvNameAttribute, Value: []byte("my-env")}}, msgs[0].Headers)
+	assert.Nil(t, msgs[1].Key)
+	assert.Len(t, msgs[1].Headers, 0)
+
+	assert.NoError(t, p.Close())
+	assert.True(t, writer.closed)
+}
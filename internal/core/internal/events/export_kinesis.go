@@ -0,0 +1,114 @@
+package events
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+)
+
+// These are the limits of the Kinesis PutRecords API.
+const (
+	kinesisMaxRecordsPerRequest = 500
+	kinesisMaxBytesPerRequest   = 5 * 1024 * 1024
+	kinesisMaxPartitionKeyChars = 256
+)
+
+// kinesisPublisher is the exportPublisher for a Kinesis data stream.
+type kinesisPublisher struct {
+	client     kinesisiface.KinesisAPI
+	streamName string
+	retryDelay time.Duration
+	seq        uint64
+}
+
+func newKinesisPublisher(config c.KinesisConfig) (*kinesisPublisher, error) {
+	awsConfig := aws.Config{}
+	if config.Region != "" {
+		awsConfig.Region = aws.String(config.Region)
+	}
+	if config.URL.IsDefined() {
+		awsConfig.Endpoint = aws.String(config.URL.String())
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{Config: awsConfig, SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, err
+	}
+	return newKinesisPublisherWithClient(kinesis.New(sess), config.StreamName), nil
+}
+
+func newKinesisPublisherWithClient(client kinesisiface.KinesisAPI, streamName string) *kinesisPublisher {
+	return &kinesisPublisher{client: client, streamName: streamName, retryDelay: exportRetryDelay}
+}
+
+// publish writes the messages with PutRecords. Kinesis can reject some of the records in a request while
+// accepting others, for instance if a shard is over its throughput limit, so only the rejected records
+// are retried.
+func (p *kinesisPublisher) publish(messages []exportMessage) error {
+	var firstErr error
+	for _, chunk := range chunkExportMessages(messages, kinesisMaxRecordsPerRequest, kinesisMaxBytesPerRequest) {
+		entries := make([]*kinesis.PutRecordsRequestEntry, 0, len(chunk))
+		for _, m := range chunk {
+			entries = append(entries, &kinesis.PutRecordsRequestEntry{
+				Data:         m.value,
+				PartitionKey: aws.String(p.partitionKey(m.key)),
+			})
+		}
+		if err := p.putRecords(entries); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (p *kinesisPublisher) putRecords(entries []*kinesis.PutRecordsRequestEntry) error {
+	var err error
+	for attempt := 0; attempt < exportMaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(p.retryDelay)
+		}
+		var out *kinesis.PutRecordsOutput
+		out, err = p.client.PutRecords(&kinesis.PutRecordsInput{StreamName: aws.String(p.streamName), Records: entries})
+		if err != nil {
+			continue
+		}
+		if aws.Int64Value(out.FailedRecordCount) == 0 {
+			return nil
+		}
+		var failed []*kinesis.PutRecordsRequestEntry
+		firstError := ""
+		for i, result := range out.Records {
+			if result.ErrorCode != nil && i < len(entries) {
+				failed = append(failed, entries[i])
+				if firstError == "" {
+					firstError = aws.StringValue(result.ErrorCode) + ": " + aws.StringValue(result.ErrorMessage)
+				}
+			}
+		}
+		entries = failed
+		err = errExportRecordsFailed(len(failed), firstError)
+	}
+	return err
+}
+
+// partitionKey returns the user key, truncated to the maximum length that Kinesis allows, or a sequence
+// number if there is no user key so that those events are spread across shards.
+func (p *kinesisPublisher) partitionKey(key string) string {
+	if key == "" {
+		return strconv.FormatUint(atomic.AddUint64(&p.seq, 1), 10)
+	}
+	if runes := []rune(key); len(runes) > kinesisMaxPartitionKeyChars {
+		return string(runes[:kinesisMaxPartitionKeyChars])
+	}
+	return key
+}
+
+func (p *kinesisPublisher) Close() error {
+	return nil
+}
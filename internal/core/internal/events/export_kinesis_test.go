@@ -0,0 +1,111 @@
+package events
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKinesisClient struct {
+	kinesisiface.KinesisAPI
+	requests [][]*kinesis.PutRecordsRequestEntry
+	results  []func(entries []*kinesis.PutRecordsRequestEntry) (*kinesis.PutRecordsOutput, error)
+}
+
+func (c *fakeKinesisClient) PutRecords(input *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+	c.requests = append(c.requests, input.Records)
+	if len(c.results) != 0 {
+		result := c.results[0]
+		c.results = c.results[1:]
+		return result(input.Records)
+	}
+	return &kinesis.PutRecordsOutput{FailedRecordCount: aws.Int64(0)}, nil
+}
+
+func failKinesisRecords(failedIndexes ...int) func([]*kinesis.PutRecordsRequestEntry) (*kinesis.PutRecordsOutput, error) {
+	return func(entries []*kinesis.PutRecordsRequestEntry) (*kinesis.PutRecordsOutput, error) {
+		out := &kinesis.PutRecordsOutput{FailedRecordCount: aws.Int64(int64(len(failedIndexes)))}
+		for range entries {
+			out.Records = append(out.Records, &kinesis.PutRecordsResultEntry{SequenceNumber: aws.String("1")})
+		}
+		for _, i := range failedIndexes {
+			out.Records[i] = &kinesis.PutRecordsResultEntry{
+				ErrorCode:    aws.String(kinesis.ErrCodeProvisionedThroughputExceededException),
+				ErrorMessage: aws.String("slow down"),
+			}
+		}
+		return out, nil
+	}
+}
+
+func makeTestKinesisPublisher(client *fakeKinesisClient) *kinesisPublisher {
+	p := newKinesisPublisherWithClient(client, "ld-events")
+	p.retryDelay = 0
+	return p
+}
+
+func TestKinesisPublisherWritesRecords(t *testing.T) {
+	client := &fakeKinesisClient{}
+	p := makeTestKinesisPublisher(client)
+
+	require.NoError(t, p.publish([]exportMessage{
+		{key: "a", value: []byte(`{"kind":"custom"}`)},
+		{value: []byte(`{"kind":"summary"}`)},
+	}))
+	require.Len(t, client.requests, 1)
+	records := client.requests[0]
+	require.Len(t, records, 2)
+	assert.Equal(t, "a", aws.StringValue(records[0].PartitionKey))
+	assert.Equal(t, `{"kind":"custom"}`, string(records[0].Data))
+	assert.Equal(t, "1", aws.StringValue(records[1].PartitionKey))
+}
+
+func TestKinesisPublisherRetriesOnlyFailedRecords(t *testing.T) {
+	client := &fakeKinesisClient{results: []func([]*kinesis.PutRecordsRequestEntry) (*kinesis.PutRecordsOutput, error){
+		failKinesisRecords(1),
+	}}
+	p := makeTestKinesisPublisher(client)
+
+	require.NoError(t, p.publish([]exportMessage{
+		{key: "a", value: []byte(`1`)},
+		{key: "b", value: []byte(`2`)},
+		{key: "c", value: []byte(`3`)},
+	}))
+	require.Len(t, client.requests, 2)
+	require.Len(t, client.requests[1], 1)
+	assert.Equal(t, "b", aws.StringValue(client.requests[1][0].PartitionKey))
+}
+
+func TestKinesisPublisherGivesUpAfterMaxAttempts(t *testing.T) {
+	fail := func([]*kinesis.PutRecordsRequestEntry) (*kinesis.PutRecordsOutput, error) {
+		return nil, errors.New("stream not found")
+	}
+	client := &fakeKinesisClient{results: []func([]*kinesis.PutRecordsRequestEntry) (*kinesis.PutRecordsOutput, error){
+		failKinesisRecords(0), fail, fail, fail,
+	}}
+	p := makeTestKinesisPublisher(client)
+
+	err := p.publish([]exportMessage{{key: "a", value: []byte(`1`)}, {key: "b", value: []byte(`2`)}})
+	assert.EqualError(t, err, "stream not found")
+	assert.Len(t, client.requests, exportMaxAttempts)
+
+	client.results = []func([]*kinesis.PutRecordsRequestEntry) (*kinesis.PutRecordsOutput, error){
+		failKinesisRecords(0), failKinesisRecords(0), failKinesisRecords(0),
+	}
+	err = p.publish([]exportMessage{{key: "a", value: []byte(`1`)}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 record(s) were not accepted: ProvisionedThroughputExceededException: slow down")
+}
+
+func TestKinesisPartitionKeyIsTruncated(t *testing.T) {
+	p := makeTestKinesisPublisher(&fakeKinesisClient{})
+	assert.Equal(t, strings.Repeat("é", kinesisMaxPartitionKeyChars), p.partitionKey(strings.Repeat("é", 300)))
+	assert.NotEqual(t, p.partitionKey(""), p.partitionKey(""))
+}
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+// These are the limits of the Pub/Sub publish API, with some allowance for the base64 encoding of data.
+const (
+	pubSubMaxMessagesPerRequest = 1000
+	pubSubMaxBytesPerRequest    = 7 * 1024 * 1024
+	pubSubRequestTimeout        = time.Second * 30
+)
+
+// pubSubPublisher is the exportPublisher for a Pub/Sub topic.
+type pubSubPublisher struct {
+	service    *pubsub.Service
+	topic      string
+	retryDelay time.Duration
+}
+
+func newPubSubPublisher(config c.PubSubConfig, opts ...option.ClientOption) (*pubSubPublisher, error) {
+	service, err := pubsub.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	topic := config.Topic
+	if config.ProjectID != "" && !strings.HasPrefix(topic, "projects/") {
+		topic = "projects/" + config.ProjectID + "/topics/" + topic
+	}
+	return &pubSubPublisher{service: service, topic: topic, retryDelay: exportRetryDelay}, nil
+}
+
+// publish writes the messages to the topic, using the user key as the ordering key, so that subscriptions
+// with message ordering enabled receive each user's events in order.
+func (p *pubSubPublisher) publish(messages []exportMessage) error {
+	var firstErr error
+	for _, chunk := range chunkExportMessages(messages, pubSubMaxMessagesPerRequest, pubSubMaxBytesPerRequest) {
+		req := &pubsub.PublishRequest{Messages: make([]*pubsub.PubsubMessage, 0, len(chunk))}
+		for _, m := range chunk {
+			pm := &pubsub.PubsubMessage{
+				Data:        base64.StdEncoding.EncodeToString(m.value),
+				OrderingKey: m.key,
+			}
+			if len(m.attributes) != 0 {
+				pm.Attributes = make(map[string]string, len(m.attributes))
+				for _, a := range m.attributes {
+					pm.Attributes[a.key] = a.value
+				}
+			}
+			req.Messages = append(req.Messages, pm)
+		}
+		if err := p.publishRequest(req); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (p *pubSubPublisher) publishRequest(req *pubsub.PublishRequest) error {
+	var err error
+	for attempt := 0; attempt < exportMaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(p.retryDelay)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), pubSubRequestTimeout)
+		_, err = p.service.Projects.Topics.Publish(p.topic, req).Context(ctx).Do()
+		cancel()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (p *pubSubPublisher) Close() error {
+	return nil
+}
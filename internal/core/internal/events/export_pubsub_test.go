@@ -0,0 +1,95 @@
+package events
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pubSubTestRequest struct {
+	path string
+	body pubsub.PublishRequest
+}
+
+func withTestPubSubServer(t *testing.T, config config.PubSubConfig, statuses []int, action func(*pubSubPublisher, <-chan pubSubTestRequest)) {
+	requestsCh := make(chan pubSubTestRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req pubSubTestRequest
+		req.path = r.URL.Path
+		data, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &req.body)
+		requestsCh <- req
+		if len(statuses) != 0 {
+			status := statuses[0]
+			statuses = statuses[1:]
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"messageIds":["1"]}`))
+	}))
+	defer server.Close()
+	p, err := newPubSubPublisher(config, option.WithEndpoint(server.URL), option.WithoutAuthentication(),
+		option.WithHTTPClient(server.Client()))
+	require.NoError(t, err)
+	p.retryDelay = 0
+	action(p, requestsCh)
+}
+
+func TestPubSubPublisherTopicName(t *testing.T) {
+	withTestPubSubServer(t, config.PubSubConfig{ProjectID: "my-project", Topic: "ld-events"}, nil,
+		func(p *pubSubPublisher, _ <-chan pubSubTestRequest) {
+			assert.Equal(t, "projects/my-project/topics/ld-events", p.topic)
+		})
+	withTestPubSubServer(t, config.PubSubConfig{ProjectID: "my-project", Topic: "projects/other/topics/ld-events"}, nil,
+		func(p *pubSubPublisher, _ <-chan pubSubTestRequest) {
+			assert.Equal(t, "projects/other/topics/ld-events", p.topic)
+		})
+}
+
+func TestPubSubPublisherPublishesMessages(t *testing.T) {
+	withTestPubSubServer(t, config.PubSubConfig{ProjectID: "my-project", Topic: "ld-events"}, nil,
+		func(p *pubSubPublisher, requestsCh <-chan pubSubTestRequest) {
+			require.NoError(t, p.publish([]exportMessage{
+				{key: "a", value: []byte(`{"kind":"custom"}`), attributes: []exportAttribute{{exportEnvNameAttribute, "my-env"}}},
+				{value: []byte(`{"kind":"summary"}`)},
+			}))
+			require.Len(t, requestsCh, 1)
+			r := <-requestsCh
+			assert.Equal(t, "/v1/projects/my-project/topics/ld-events:publish", r.path)
+			require.Len(t, r.body.Messages, 2)
+			assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(`{"kind":"custom"}`)), r.body.Messages[0].Data)
+			assert.Equal(t, "a", r.body.Messages[0].OrderingKey)
+			assert.Equal(t, map[string]string{exportEnvNameAttribute: "my-env"}, r.body.Messages[0].Attributes)
+			assert.Equal(t, "", r.body.Messages[1].OrderingKey)
+			assert.Nil(t, r.body.Messages[1].Attributes)
+		})
+}
+
+func TestPubSubPublisherRetries(t *testing.T) {
+	withTestPubSubServer(t, config.PubSubConfig{ProjectID: "my-project", Topic: "ld-events"},
+		[]int{http.StatusServiceUnavailable, http.StatusOK},
+		func(p *pubSubPublisher, requestsCh <-chan pubSubTestRequest) {
+			require.NoError(t, p.publish([]exportMessage{{value: []byte(`{}`)}}))
+			assert.Len(t, requestsCh, 2)
+		})
+	withTestPubSubServer(t, config.PubSubConfig{ProjectID: "my-project", Topic: "ld-events"},
+		[]int{http.StatusForbidden, http.StatusForbidden, http.StatusForbidden},
+		func(p *pubSubPublisher, requestsCh <-chan pubSubTestRequest) {
+			assert.Error(t, p.publish([]exportMessage{{value: []byte(`{}`)}}))
+			assert.Len(t, requestsCh, exportMaxAttempts)
+		})
+}
@@ -0,0 +1,284 @@
+package events
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeExportPublisher struct {
+	publishCh chan []exportMessage
+	closed    bool
+}
+
+func newFakeExportPublisher() *fakeExportPublisher {
+	return &fakeExportPublisher{publishCh: make(chan []exportMessage, 10)}
+}
+
+func (p *fakeExportPublisher) publish(messages []exportMessage) error {
+	p.publishCh <- messages
+	return nil
+}
+
+func (p *fakeExportPublisher) Close() error {
+	p.closed = true
+	return nil
+}
+
+type countingEventSender struct {
+	count int
+}
+
+func (s *countingEventSender) SendEventData(ldevents.EventDataKind, []byte, int) ldevents.EventSenderResult {
+	s.count++
+	return ldevents.EventSenderResult{Success: true}
+}
+
+func makeTestEventExporter(sinks ...*eventExportSink) *EventExporter {
+	return &EventExporter{sinks: sinks}
+}
+
+func expectExport(t *testing.T, publisher *fakeExportPublisher) []exportMessage {
+	select {
+	case msgs := <-publisher.publishCh:
+		return msgs
+	case <-time.After(time.Second):
+		require.Fail(t, "timed out waiting for event export")
+		return nil
+	}
+}
+
+func expectNoExport(t *testing.T, publisher *fakeExportPublisher) {
+	select {
+	case msgs := <-publisher.publishCh:
+		require.Fail(t, "unexpected event export", "%+v", msgs)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+func exportAttributes(msg exportMessage) map[string]string {
+	ret := make(map[string]string)
+	for _, a := range msg.attributes {
+		ret[a.key] = a.value
+	}
+	return ret
+}
+
+func TestNewEventExporterReturnsNilIfNotConfigured(t *testing.T) {
+	x, err := NewEventExporter(config.Config{}, ldlog.NewDisabledLoggers())
+	assert.NoError(t, err)
+	assert.Nil(t, x)
+	assert.Nil(t, x.ForEnvironment("my-env", config.EnvConfig{}))
+	assert.Nil(t, x.ForEnvironment("my-env", config.EnvConfig{}).newEndpoint(basictypes.ServerSDK))
+}
+
+func TestNewEventExporterCreatesConfiguredDestinations(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	x, err := NewEventExporter(config.Config{
+		Kafka:   config.KafkaConfig{Brokers: configtypes.NewOptStringList([]string{"localhost:9092"}), Topic: "ld-events"},
+		Kinesis: config.KinesisConfig{StreamName: "ld-events", Region: "us-east-1"},
+	}, mockLog.Loggers)
+	require.NoError(t, err)
+	require.NotNil(t, x)
+	defer x.Close()
+
+	require.Len(t, x.sinks, 2)
+	assert.Equal(t, config.EventExportKafka, x.sinks[0].name)
+	assert.Equal(t, config.EventExportKinesis, x.sinks[1].name)
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, `Exporting analytics events to Kafka topic "ld-events"`)
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, `Exporting analytics events to Kinesis stream "ld-events"`)
+}
+
+func TestEventExporterTeeModeExportsEventsAndDelivers(t *testing.T) {
+	publisher := newFakeExportPublisher()
+	x := makeTestEventExporter(
+		newEventExportSink("kafka", "Kafka", publisher, "", "", ldlog.NewDisabledLoggers()))
+	defer x.Close()
+	endpoint := x.ForEnvironment("my-env", config.EnvConfig{EnvID: "env-id"}).newEndpoint(basictypes.ServerSDK)
+	wrapped := &countingEventSender{}
+	sender := endpoint.wrapSender(wrapped, EventPayloadMetadata{Tags: "application-id/app"})
+
+	payload := `[{"kind":"feature","userKey":"a"},{"kind":"index","user":{"key":"b"}},{"kind":"summary"}]`
+	result := sender.SendEventData(ldevents.AnalyticsEventDataKind, []byte(payload), 3)
+	assert.True(t, result.Success)
+	assert.Equal(t, 1, wrapped.count)
+
+	msgs := expectExport(t, publisher)
+	require.Len(t, msgs, 3)
+	assert.Equal(t, `{"kind":"feature","userKey":"a"}`, string(msgs[0].value))
+	assert.Equal(t, "a", msgs[0].key)
+	assert.Equal(t, "b", msgs[1].key)
+	assert.Equal(t, "", msgs[2].key)
+	assert.Equal(t, map[string]string{
+		exportEnvNameAttribute:   "my-env",
+		exportEnvIDAttribute:     "env-id",
+		exportSDKKindAttribute:   "server",
+		exportEventKindAttribute: "summary",
+		exportTagsAttribute:      "application-id/app",
+	}, exportAttributes(msgs[2]))
+}
+
+func TestEventExporterDoesNotExportDiagnosticEvents(t *testing.T) {
+	publisher := newFakeExportPublisher()
+	x := makeTestEventExporter(
+		newEventExportSink("kafka", "Kafka", publisher, config.EventExportModeRedirect, "", ldlog.NewDisabledLoggers()))
+	defer x.Close()
+	endpoint := x.ForEnvironment("my-env", config.EnvConfig{}).newEndpoint(basictypes.ServerSDK)
+	wrapped := &countingEventSender{}
+	sender := endpoint.wrapSender(wrapped, EventPayloadMetadata{})
+
+	_ = sender.SendEventData(ldevents.DiagnosticEventDataKind, []byte(`{"kind":"diagnostic"}`), 1)
+	assert.Equal(t, 1, wrapped.count)
+	expectNoExport(t, publisher)
+}
+
+func TestEventExporterRedirectModeDoesNotDeliver(t *testing.T) {
+	publisher := newFakeExportPublisher()
+	x := makeTestEventExporter(
+		newEventExportSink("kinesis", "Kinesis", publisher, config.EventExportModeRedirect, "", ldlog.NewDisabledLoggers()))
+	defer x.Close()
+	endpoint := x.ForEnvironment("my-env", config.EnvConfig{}).newEndpoint(basictypes.MobileSDK)
+	sender := endpoint.wrapSender(failingEventSender, EventPayloadMetadata{})
+
+	result := sender.SendEventData(ldevents.AnalyticsEventDataKind, []byte(`[{"kind":"custom"}]`), 1)
+	assert.True(t, result.Success)
+
+	msgs := expectExport(t, publisher)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, map[string]string{
+		exportEnvNameAttribute:   "my-env",
+		exportSDKKindAttribute:   "mobile",
+		exportEventKindAttribute: "custom",
+	}, exportAttributes(msgs[0]))
+}
+
+func TestEventExporterEnvelopeFormat(t *testing.T) {
+	publisher := newFakeExportPublisher()
+	x := makeTestEventExporter(
+		newEventExportSink("pubsub", "Pub/Sub", publisher, "", config.EventExportFormatEnvelope, ldlog.NewDisabledLoggers()))
+	defer x.Close()
+	endpoint := x.ForEnvironment("my-env", config.EnvConfig{EnvID: "env-id"}).newEndpoint(basictypes.JSClientSDK)
+	sender := endpoint.wrapSender(succeedingEventSender, EventPayloadMetadata{Tags: "application-id/app"})
+
+	_ = sender.SendEventData(ldevents.AnalyticsEventDataKind, []byte(`[{"kind":"custom","userKey":"a"}]`), 1)
+
+	msgs := expectExport(t, publisher)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "a", msgs[0].key)
+	assert.Len(t, msgs[0].attributes, 0)
+	assert.JSONEq(t, `{"envName":"my-env","envId":"env-id","sdkKind":"js","tags":"application-id/app",`+
+		`"event":{"kind":"custom","userKey":"a"}}`, string(msgs[0].value))
+}
+
+func TestEventExporterForEnvironmentSelectsDestinations(t *testing.T) {
+	kafkaPublisher, kinesisPublisher := newFakeExportPublisher(), newFakeExportPublisher()
+	x := makeTestEventExporter(
+		newEventExportSink("kafka", "Kafka", kafkaPublisher, "", "", ldlog.NewDisabledLoggers()),
+		newEventExportSink("kinesis", "Kinesis", kinesisPublisher, config.EventExportModeRedirect, "", ldlog.NewDisabledLoggers()))
+	defer x.Close()
+
+	t.Run("all destinations by default", func(t *testing.T) {
+		e := x.ForEnvironment("my-env", config.EnvConfig{})
+		require.NotNil(t, e)
+		assert.Len(t, e.sinks, 2)
+		assert.True(t, e.newEndpoint(basictypes.ServerSDK).redirect)
+	})
+
+	t.Run("selected destinations", func(t *testing.T) {
+		e := x.ForEnvironment("my-env", config.EnvConfig{EventExport: configtypes.NewOptStringList([]string{"kafka"})})
+		require.NotNil(t, e)
+		require.Len(t, e.sinks, 1)
+		endpoint := e.newEndpoint(basictypes.ServerSDK)
+		assert.False(t, endpoint.redirect)
+
+		wrapped := &countingEventSender{}
+		_ = endpoint.wrapSender(wrapped, EventPayloadMetadata{}).SendEventData(ldevents.AnalyticsEventDataKind,
+			[]byte(`[{"kind":"custom"}]`), 1)
+		assert.Equal(t, 1, wrapped.count)
+		assert.Len(t, expectExport(t, kafkaPublisher), 1)
+		expectNoExport(t, kinesisPublisher)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		assert.Nil(t, x.ForEnvironment("my-env", config.EnvConfig{
+			EventExport: configtypes.NewOptStringList([]string{config.EventExportNone})}))
+	})
+}
+
+func TestEventExporterLogsFailuresOnce(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	publisher := newFakeExportPublisher()
+	s := newEventExportSink("kafka", "Kafka", publisher, "", "", mockLog.Loggers)
+
+	s.handleWriteResult(2, errors.New("broker unavailable"))
+	s.handleWriteResult(2, errors.New("broker unavailable"))
+	assert.Len(t, mockLog.GetOutput(ldlog.Error), 1)
+	mockLog.AssertMessageMatch(t, true, ldlog.Error, "Failed to export 2 event\\(s\\) to Kafka.*broker unavailable")
+
+	s.handleWriteResult(1, nil)
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "Resumed exporting events to Kafka")
+
+	assert.NoError(t, s.close())
+	assert.True(t, publisher.closed)
+}
+
+func TestEventExporterCloseWaitsForQueuedEvents(t *testing.T) {
+	publisher := newFakeExportPublisher()
+	s := newEventExportSink("kafka", "Kafka", publisher, "", "", ldlog.NewDisabledLoggers())
+	s.enqueue([]exportMessage{{value: []byte(`{}`)}})
+	s.enqueue([]exportMessage{{value: []byte(`{}`)}})
+
+	assert.NoError(t, s.close())
+	assert.Len(t, publisher.publishCh, 2)
+	assert.NoError(t, s.close())
+}
+
+func TestChunkExportMessages(t *testing.T) {
+	msg := func(size int) exportMessage { return exportMessage{value: make([]byte, size)} }
+	messages := []exportMessage{msg(10), msg(10), msg(10), msg(50), msg(10)}
+
+	assert.Equal(t, [][]exportMessage{messages}, chunkExportMessages(messages, 10, 1000))
+	assert.Equal(t, [][]exportMessage{messages[0:2], messages[2:4], messages[4:]}, chunkExportMessages(messages, 2, 1000))
+	assert.Equal(t, [][]exportMessage{messages[0:3], messages[3:4], messages[4:]}, chunkExportMessages(messages, 10, 40))
+	assert.Nil(t, chunkExportMessages(nil, 10, 40))
+}
+
+func TestEventDispatcherExportsEvents(t *testing.T) {
+	for _, e := range allTestEndpoints {
+		t.Run(string(e.sdkKind), func(t *testing.T) {
+			publisher := newFakeExportPublisher()
+			x := makeTestEventExporter(
+				newEventExportSink("kafka", "Kafka", publisher, "", "", ldlog.NewDisabledLoggers()))
+			defer x.Close()
+			opts := eventRelayTestOptions{exporter: x.ForEnvironment("my-env", st.EnvWithAllCredentials.Config)}
+			eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+				handler := p.dispatcher.GetHandler(e.sdkKind, ldevents.AnalyticsEventDataKind)
+				require.NotNil(t, handler)
+				handler(httptest.NewRecorder(), st.BuildRequest("POST", "/", []byte(eventPayloadForVerbatimOnly),
+					headersWithEventSchema(SummaryEventsSchemaVersion)))
+
+				p.dispatcher.flush()
+
+				r := st.ExpectTestRequest(t, p.requestsCh, time.Second)
+				assert.Equal(t, eventPayloadForVerbatimOnly, string(r.Body))
+				msgs := expectExport(t, publisher)
+				require.Len(t, msgs, 3)
+				assert.Equal(t, `"fake-event-1"`, string(msgs[0].value))
+				assert.Equal(t, string(e.sdkKind), exportAttributes(msgs[0])[exportSDKKindAttribute])
+			})
+		})
+	}
+}
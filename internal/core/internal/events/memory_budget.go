@@ -0,0 +1,61 @@
+package events
+
+import (
+	"sync"
+)
+
+// EventMemoryBudget limits the total size of the analytics events that an EventDispatcher holds in
+// memory while it is waiting to deliver them, across all of its endpoints. The zero value means there
+// is no limit.
+//
+// This applies only to events that are forwarded as they were received. Events from older SDKs, which
+// Relay summarizes itself, are held by the summarizing event processor instead, and are limited only
+// by the event capacity.
+type EventMemoryBudget struct {
+	// MaxBytes is the maximum total size of the queued events, in bytes.
+	MaxBytes int
+
+	// FlushWhenFull causes an endpoint that has used up the budget to deliver its queued events right
+	// away, instead of dropping the events that do not fit.
+	FlushWhenFull bool
+}
+
+// eventMemoryBudget is the shared state for an EventMemoryBudget. Each HTTPEventPublisher reserves
+// space in it for the events it queues, and releases that space when it flushes them. A nil
+// *eventMemoryBudget has no limit.
+type eventMemoryBudget struct {
+	maxBytes      int
+	flushWhenFull bool
+	used          int
+	lock          sync.Mutex
+}
+
+func newEventMemoryBudget(b EventMemoryBudget) *eventMemoryBudget {
+	if b.MaxBytes <= 0 {
+		return nil
+	}
+	return &eventMemoryBudget{maxBytes: b.MaxBytes, flushWhenFull: b.FlushWhenFull}
+}
+
+// reserve returns true and counts n bytes toward the budget if they fit, or false if not.
+func (b *eventMemoryBudget) reserve(n int) bool {
+	if b == nil {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.used+n > b.maxBytes {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+func (b *eventMemoryBudget) release(n int) {
+	if b == nil || n == 0 {
+		return
+	}
+	b.lock.Lock()
+	b.used -= n
+	b.lock.Unlock()
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"crypto/sha1" //nolint:gosec // used only to spread user keys evenly, not for security
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+
+	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
+)
+
+const debugEventKind = "debug"
+
+// EventSampling configures the sampling of an environment's analytics events, so that only a
+// proportion of them are forwarded. Each rate is from 0 to 1; a rate of 0 means the events of that kind
+// are not sampled, that is, all of them are forwarded.
+//
+// Whether an event is forwarded depends only on its user key, so for each kind of event, either all or
+// none of a user's events are forwarded, on every Relay instance. Summary events, and events that have
+// no user key, are always forwarded.
+type EventSampling struct {
+	// FeatureEventRate is the proportion of users whose full feature events and debug events are
+	// forwarded. These events are only sent for flags that have event tracking or debugging enabled;
+	// sampling them does not affect the evaluation counts in summary events.
+	FeatureEventRate float64
+
+	// CustomEventRate is the proportion of users whose custom events are forwarded.
+	CustomEventRate float64
+
+	// OnSampledOut, if non-nil, is called with the number of events that were not forwarded because
+	// of sampling.
+	OnSampledOut func(sdkKind basictypes.SDKKind, count int)
+}
+
+// eventSampler applies an EventSampling to the events for one endpoint. A nil *eventSampler forwards
+// all events.
+//
+// Events from newer SDKs are sampled as soon as they are received. Events from older SDKs go through
+// eventSummarizingRelay first, so that all of the feature events are counted in the summaries, and its
+// output is sampled by a filteringEventSender.
+type eventSampler struct {
+	featureEventRate float64
+	customEventRate  float64
+	onSampledOut     func(count int)
+}
+
+func newEventSampler(s EventSampling, sdkKind basictypes.SDKKind) *eventSampler {
+	if !isSampled(s.FeatureEventRate) && !isSampled(s.CustomEventRate) {
+		return nil
+	}
+	ret := &eventSampler{featureEventRate: s.FeatureEventRate, customEventRate: s.CustomEventRate}
+	if s.OnSampledOut != nil {
+		ret.onSampledOut = func(count int) { s.OnSampledOut(sdkKind, count) }
+	}
+	return ret
+}
+
+func isSampled(rate float64) bool {
+	return rate > 0 && rate < 1
+}
+
+// apply returns the events that should be forwarded.
+func (s *eventSampler) apply(evts []json.RawMessage) []json.RawMessage {
+	if s == nil {
+		return evts
+	}
+	ret := make([]json.RawMessage, 0, len(evts))
+	for _, evt := range evts {
+		if s.shouldForward(evt) {
+			ret = append(ret, evt)
+		}
+	}
+	if dropped := len(evts) - len(ret); dropped > 0 && s.onSampledOut != nil {
+		s.onSampledOut(dropped)
+	}
+	return ret
+}
+
+func (s *eventSampler) shouldForward(evt json.RawMessage) bool {
+	var fields struct {
+		Kind    string `json:"kind"`
+		UserKey string `json:"userKey"`
+		User    struct {
+			Key string `json:"key"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(evt, &fields); err != nil {
+		return true // let the rest of the pipeline deal with it
+	}
+	var rate float64
+	switch fields.Kind {
+	case ldevents.FeatureRequestEventKind, debugEventKind:
+		rate = s.featureEventRate
+	case ldevents.CustomEventKind:
+		rate = s.customEventRate
+	}
+	if !isSampled(rate) {
+		return true
+	}
+	userKey := fields.UserKey
+	if userKey == "" {
+		userKey = fields.User.Key
+	}
+	if userKey == "" {
+		return true
+	}
+	return userSamplePoint(userKey) < rate
+}
+
+// userSamplePoint maps a user key to a number from 0 to 1 that is the same for every event and every
+// Relay instance.
+func userSamplePoint(userKey string) float64 {
+	sum := sha1.Sum([]byte(userKey)) //nolint:gosec // see import
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / float64(uint64(1)<<53)
+}
@@ -0,0 +1,119 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventSamplerIsNilIfNothingIsSampled(t *testing.T) {
+	assert.Nil(t, newEventSampler(EventSampling{}, basictypes.ServerSDK))
+	assert.Nil(t, newEventSampler(EventSampling{FeatureEventRate: 1, CustomEventRate: 1}, basictypes.ServerSDK))
+}
+
+func TestEventSamplerForwardsApproximatelyTheConfiguredProportionOfUsers(t *testing.T) {
+	s := newEventSampler(EventSampling{FeatureEventRate: 0.1, CustomEventRate: 0.5}, basictypes.ServerSDK)
+	var features, customs []json.RawMessage
+	for i := 0; i < 10000; i++ {
+		features = append(features, json.RawMessage(fmt.Sprintf(`{"kind":"feature","userKey":"user%d"}`, i)))
+		customs = append(customs, json.RawMessage(fmt.Sprintf(`{"kind":"custom","user":{"key":"user%d"}}`, i)))
+	}
+	assert.InDelta(t, 1000, len(s.apply(features)), 200)
+	assert.InDelta(t, 5000, len(s.apply(customs)), 300)
+}
+
+func TestEventSamplerIsConsistentForEachUser(t *testing.T) {
+	s := newEventSampler(EventSampling{FeatureEventRate: 0.5, CustomEventRate: 0.5}, basictypes.ServerSDK)
+	for i := 0; i < 100; i++ {
+		feature := json.RawMessage(fmt.Sprintf(`{"kind":"feature","userKey":"user%d"}`, i))
+		debug := json.RawMessage(fmt.Sprintf(`{"kind":"debug","user":{"key":"user%d"}}`, i))
+		custom := json.RawMessage(fmt.Sprintf(`{"kind":"custom","userKey":"user%d"}`, i))
+		result := s.apply([]json.RawMessage{feature, debug, custom})
+		assert.True(t, len(result) == 0 || len(result) == 3, "events for user%d were sampled inconsistently", i)
+	}
+}
+
+func TestEventSamplerAlwaysForwardsOtherEvents(t *testing.T) {
+	var sampledOut []int
+	s := newEventSampler(EventSampling{
+		FeatureEventRate: 0.000001,
+		OnSampledOut:     func(sdkKind basictypes.SDKKind, count int) { sampledOut = append(sampledOut, count) },
+	}, basictypes.ServerSDK)
+	evts := []json.RawMessage{
+		json.RawMessage(`{"kind":"summary","features":{}}`),
+		json.RawMessage(`{"kind":"index","user":{"key":"user1"}}`),
+		json.RawMessage(`{"kind":"identify","user":{"key":"user1"}}`),
+		json.RawMessage(`{"kind":"custom","userKey":"user1"}`),
+		json.RawMessage(`{"kind":"feature"}`),
+		json.RawMessage(`not json`),
+	}
+	assert.Equal(t, evts, s.apply(append(evts, json.RawMessage(`{"kind":"feature","userKey":"user1"}`))))
+	assert.Equal(t, []int{1}, sampledOut)
+}
+
+func TestEventDispatcherSamplesEventsFromNewerSDKs(t *testing.T) {
+	sampledOut := make(chan int, 10)
+	opts := eventRelayTestOptions{
+		sampling: EventSampling{
+			FeatureEventRate: 0.5,
+			OnSampledOut: func(sdkKind basictypes.SDKKind, count int) {
+				assert.Equal(t, basictypes.MobileSDK, sdkKind)
+				sampledOut <- count
+			},
+		},
+	}
+	eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+		var payload []json.RawMessage
+		expected := 0
+		for i := 0; i < 20; i++ {
+			evt := json.RawMessage(fmt.Sprintf(`{"kind":"feature","userKey":"user%d"}`, i))
+			payload = append(payload, evt)
+			if userSamplePoint(fmt.Sprintf("user%d", i)) < 0.5 {
+				expected++
+			}
+		}
+		body, _ := json.Marshal(payload)
+		handler := p.dispatcher.GetHandler(basictypes.MobileSDK, ldevents.AnalyticsEventDataKind)
+		handler(httptest.NewRecorder(), st.BuildRequest("POST", "/", body, headersWithEventSchema(SummaryEventsSchemaVersion)))
+		p.dispatcher.flush()
+
+		r := st.ExpectTestRequest(t, p.requestsCh, time.Second)
+		var received []json.RawMessage
+		require.NoError(t, json.Unmarshal(r.Body, &received))
+		assert.Len(t, received, expected)
+		assert.Equal(t, 20-expected, <-sampledOut)
+	})
+}
+
+func TestEventDispatcherSamplesEventsFromOlderSDKsAfterSummarizing(t *testing.T) {
+	opts := eventRelayTestOptions{sampling: EventSampling{CustomEventRate: 0.000001}}
+	eventRelayTestWithOptions(t, st.EnvMain, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+		flag := makeTestFlag(true, 0)
+		_, _ = st.UpsertFlag(p.dataStore, flag)
+
+		handler := p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)
+		handler(httptest.NewRecorder(), st.BuildRequest("POST", "/", []byte(summarizableCustomEvents), headersWithEventSchema(0)))
+		handler(httptest.NewRecorder(), st.BuildRequest("POST", "/", []byte(summarizableFeatureEvents), headersWithEventSchema(0)))
+		p.dispatcher.flush()
+
+		payload := expectSummarizedPayload(t, p.requestsCh)
+		var kinds []string
+		var received []struct{ Kind string }
+		require.NoError(t, json.Unmarshal([]byte(payload), &received))
+		for _, e := range received {
+			kinds = append(kinds, e.Kind)
+		}
+		assert.Equal(t, []string{"index", "feature", "feature", "summary"}, kinds)
+	})
+}
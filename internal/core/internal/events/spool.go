@@ -0,0 +1,314 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
+)
+
+const (
+	spoolFileSuffix = ".json"
+	spoolTempSuffix = ".tmp"
+
+	// Spooled payloads are retried at this interval, and also immediately after any successful delivery
+	// of new events, since that means LaunchDarkly is reachable again.
+	defaultSpoolReplayInterval = time.Second * 30
+)
+
+// EventSpool is a directory where analytics event payloads for an environment are saved if they can't
+// be delivered to LaunchDarkly, so that they can be delivered later, even if Relay is restarted in the
+// meantime. Each analytics endpoint has its own subdirectory. Each payload is a separate file, whose name
+// starts with the time it was saved, so that the oldest payloads are delivered first, and discarded first
+// if the spool exceeds its maximum size.
+type EventSpool struct {
+	dir            string
+	maxSize        int64
+	maxAge         time.Duration
+	replayInterval time.Duration
+	loggers        ldlog.Loggers
+	seq            uint64
+	lock           sync.Mutex
+}
+
+// spooledPayload is the content of a spool file.
+type spooledPayload struct {
+	Kind     ldevents.EventDataKind `json:"kind"`
+	Count    int                    `json:"count"`
+	Metadata EventPayloadMetadata   `json:"metadata"`
+	Data     json.RawMessage        `json:"data"`
+}
+
+// eventSpoolEndpoint is the part of an EventSpool that is used by one analytics endpoint. It wraps the
+// EventSenders of the endpoint so that failed deliveries are saved, and runs a goroutine that delivers
+// the saved payloads.
+type eventSpoolEndpoint struct {
+	spool       *EventSpool
+	dir         string
+	httpClient  *http.Client
+	eventsURI   string
+	baseHeaders http.Header
+	authKey     c.SDKCredential
+	loggers     ldlog.Loggers
+	lock        sync.Mutex
+	replayCh    chan struct{}
+	startupDone chan struct{} // closed after the first replay; used in tests
+	closer      chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+type spoolingEventSender struct {
+	wrapped  ldevents.EventSender
+	endpoint *eventSpoolEndpoint
+	metadata EventPayloadMetadata
+}
+
+// NewEventSpool creates an EventSpool in the specified directory, creating the directory if necessary.
+// Any payloads that were left in the directory by a previous Relay process will be delivered once the
+// event endpoints are created.
+func NewEventSpool(dir string, config c.EventsConfig, loggers ldlog.Loggers) (*EventSpool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &EventSpool{
+		dir:            dir,
+		maxSize:        int64(config.SpoolMaxSize.GetOrElse(c.DefaultEventsSpoolMaxSize)) * 1024 * 1024,
+		maxAge:         config.SpoolMaxAge.GetOrElse(c.DefaultEventsSpoolMaxAge),
+		replayInterval: defaultSpoolReplayInterval,
+		loggers:        loggers,
+	}, nil
+}
+
+// newEndpoint creates the eventSpoolEndpoint for an analytics endpoint. If s is nil, it returns nil.
+func (s *EventSpool) newEndpoint(
+	authKey c.SDKCredential,
+	config c.EventsConfig,
+	httpConfig httpconfig.HTTPConfig,
+	loggers ldlog.Loggers,
+	remotePath string,
+) *eventSpoolEndpoint {
+	if s == nil {
+		return nil
+	}
+	baseHeaders := httpConfig.SDKHTTPConfig.GetDefaultHeaders()
+	baseHeaders.Del("Authorization") // we'll set this in makeEventSender()
+	e := &eventSpoolEndpoint{
+		spool:       s,
+		dir:         filepath.Join(s.dir, strings.ReplaceAll(strings.Trim(remotePath, "/"), "/", "-")),
+		httpClient:  httpConfig.Client(),
+		eventsURI:   strings.TrimRight(getEventsURI(config), "/") + remotePath,
+		baseHeaders: baseHeaders,
+		authKey:     authKey,
+		loggers:     loggers,
+		replayCh:    make(chan struct{}, 1),
+		startupDone: make(chan struct{}),
+		closer:      make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// trim deletes the oldest files in the spool, across all endpoints, until it is no larger than its
+// maximum size.
+func (s *EventSpool) trim() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	paths, _ := filepath.Glob(filepath.Join(s.dir, "*", "*"+spoolFileSuffix))
+	sort.Slice(paths, func(i, j int) bool { return filepath.Base(paths[i]) < filepath.Base(paths[j]) })
+	sizes := make([]int64, len(paths))
+	var total int64
+	for i, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+	dropped := 0
+	for i := 0; i < len(paths) && total > s.maxSize; i++ {
+		if err := os.Remove(paths[i]); err == nil || os.IsNotExist(err) {
+			total -= sizes[i]
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		s.loggers.Warnf("Event spool exceeded its maximum size; discarded %d oldest event payload(s)", dropped)
+	}
+}
+
+func (s *EventSpool) nextFileName() string {
+	s.lock.Lock()
+	s.seq++
+	seq := s.seq
+	s.lock.Unlock()
+	return fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), seq%1000000, spoolFileSuffix)
+}
+
+// wrapSender returns an EventSender that saves payloads to the spool if sender fails to deliver them.
+// If e is nil, it returns sender unchanged.
+func (e *eventSpoolEndpoint) wrapSender(sender ldevents.EventSender, metadata EventPayloadMetadata) ldevents.EventSender {
+	if e == nil {
+		return sender
+	}
+	return &spoolingEventSender{wrapped: sender, endpoint: e, metadata: metadata}
+}
+
+func (e *eventSpoolEndpoint) replaceCredential(newCredential c.SDKCredential) {
+	e.lock.Lock()
+	e.authKey = newCredential
+	e.lock.Unlock()
+}
+
+// close stops the goroutine that delivers spooled payloads. Anything that is still in the spool stays
+// there, to be delivered the next time Relay starts.
+func (e *eventSpoolEndpoint) close() {
+	if e == nil {
+		return
+	}
+	e.closeOnce.Do(func() {
+		close(e.closer)
+		<-e.done
+	})
+}
+
+func (e *eventSpoolEndpoint) triggerReplay() {
+	select {
+	case e.replayCh <- struct{}{}:
+	default: // a replay is already pending
+	}
+}
+
+func (e *eventSpoolEndpoint) run() {
+	defer close(e.done)
+	e.replay() // deliver anything that was left by a previous process
+	close(e.startupDone)
+	ticker := time.NewTicker(e.spool.replayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.replay()
+		case <-e.replayCh:
+			e.replay()
+		case <-e.closer:
+			return
+		}
+	}
+}
+
+func (e *eventSpoolEndpoint) save(payload spooledPayload) {
+	data, err := json.Marshal(payload)
+	if err != nil { // COVERAGE: can't happen in unit tests
+		e.loggers.Errorf("Unexpected error marshalling event payload for spool: %s", err)
+		return
+	}
+	path := filepath.Join(e.dir, e.spool.nextFileName())
+	tempPath := strings.TrimSuffix(path, spoolFileSuffix) + spoolTempSuffix
+	if err := os.MkdirAll(e.dir, 0700); err != nil {
+		e.loggers.Errorf("Unable to save undelivered events to spool; %d event(s) will be lost: %s", payload.Count, err)
+		return
+	}
+	if err := ioutil.WriteFile(tempPath, data, 0600); err != nil {
+		e.loggers.Errorf("Unable to save undelivered events to spool; %d event(s) will be lost: %s", payload.Count, err)
+		_ = os.Remove(tempPath)
+		return
+	}
+	// Renaming the file means that a replay will never see a partially written one
+	if err := os.Rename(tempPath, path); err != nil { // COVERAGE: can't happen in unit tests
+		e.loggers.Errorf("Unable to save undelivered events to spool; %d event(s) will be lost: %s", payload.Count, err)
+		_ = os.Remove(tempPath)
+		return
+	}
+	e.loggers.Infof("Saved %d undelivered event(s) to spool; they will be delivered when possible", payload.Count)
+	e.spool.trim()
+}
+
+// replay delivers the endpoint's spooled payloads in order, stopping at the first one that can't be
+// delivered, and discards any that are older than the spool's maximum age.
+func (e *eventSpoolEndpoint) replay() {
+	paths, _ := filepath.Glob(filepath.Join(e.dir, "*"+spoolFileSuffix))
+	sort.Strings(paths)
+	delivered, expired := 0, 0
+	defer func() {
+		if delivered > 0 {
+			e.loggers.Infof("Delivered %d spooled event payload(s)", delivered)
+		}
+		if expired > 0 {
+			e.loggers.Warnf("Discarded %d spooled event payload(s) that were older than the maximum age", expired)
+		}
+	}()
+	for _, path := range paths {
+		if spoolFileTime(path).Before(time.Now().Add(-e.spool.maxAge)) {
+			if os.Remove(path) == nil {
+				expired++
+			}
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) { // it could have been removed by trim() in the meantime
+				e.loggers.Errorf("Unable to read spooled events from %s: %s", path, err)
+			}
+			continue
+		}
+		var payload spooledPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			e.loggers.Errorf("Discarding invalid spool file %s: %s", path, err)
+			_ = os.Remove(path)
+			continue
+		}
+		e.lock.Lock()
+		authKey := e.authKey
+		e.lock.Unlock()
+		sender := makeEventSender(e.httpClient, e.eventsURI, e.baseHeaders, authKey, payload.Metadata, e.loggers)
+		result := sendTracedEventData(sender, payload.Kind, payload.Data, payload.Count)
+		if !result.Success && !result.MustShutDown {
+			return // we'll try again later
+		}
+		// If MustShutDown is set, LaunchDarkly rejected the credential, so the payload can never be delivered
+		_ = os.Remove(path)
+		if result.Success {
+			delivered++
+		}
+	}
+}
+
+// spoolFileTime returns the time when a spool file was saved, based on its name.
+func spoolFileTime(path string) time.Time {
+	name := filepath.Base(path)
+	if i := strings.Index(name, "-"); i > 0 {
+		if nanos, err := strconv.ParseInt(name[:i], 10, 64); err == nil {
+			return time.Unix(0, nanos)
+		}
+	}
+	return time.Time{}
+}
+
+func (s *spoolingEventSender) SendEventData(
+	kind ldevents.EventDataKind,
+	data []byte,
+	count int,
+) ldevents.EventSenderResult {
+	result := s.wrapped.SendEventData(kind, data, count)
+	switch {
+	case result.Success:
+		s.endpoint.triggerReplay()
+	case !result.MustShutDown:
+		s.endpoint.save(spooledPayload{Kind: kind, Count: count, Metadata: s.metadata, Data: data})
+	}
+	return result
+}
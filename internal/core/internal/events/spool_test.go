@@ -0,0 +1,191 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-test-helpers/v2/httphelpers"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type resultEventSender struct {
+	result ldevents.EventSenderResult
+}
+
+func (r resultEventSender) SendEventData(ldevents.EventDataKind, []byte, int) ldevents.EventSenderResult {
+	return r.result
+}
+
+var (
+	failingEventSender    = resultEventSender{ldevents.EventSenderResult{}}                   //nolint:gochecknoglobals
+	rejectingEventSender  = resultEventSender{ldevents.EventSenderResult{MustShutDown: true}} //nolint:gochecknoglobals
+	succeedingEventSender = resultEventSender{ldevents.EventSenderResult{Success: true}}      //nolint:gochecknoglobals
+)
+
+type spoolTestParams struct {
+	spool       *EventSpool
+	requestsCh  <-chan httphelpers.HTTPRequestInfo
+	newEndpoint func() *eventSpoolEndpoint
+	mockLog     *ldlogtest.MockLog
+}
+
+func spoolTest(t *testing.T, fn func(spoolTestParams)) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	httpConfig, _ := httpconfig.NewHTTPConfig(config.ProxyConfig{}, nil, "", mockLog.Loggers)
+
+	spool, err := NewEventSpool(filepath.Join(t.TempDir(), "env"), config.EventsConfig{}, mockLog.Loggers)
+	require.NoError(t, err)
+	spool.replayInterval = time.Hour // tests will trigger replays explicitly
+
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		var endpoints []*eventSpoolEndpoint
+		newEndpoint := func() *eventSpoolEndpoint {
+			eventsConfig := config.EventsConfig{}
+			eventsConfig.EventsURI, _ = configtypes.NewOptURLAbsoluteFromString(server.URL)
+			e := spool.newEndpoint(config.SDKKey("my-sdk-key"), eventsConfig, httpConfig, mockLog.Loggers, "/bulk")
+			endpoints = append(endpoints, e)
+			<-e.startupDone
+			return e
+		}
+		defer func() {
+			for _, e := range endpoints {
+				e.close()
+			}
+		}()
+		fn(spoolTestParams{spool: spool, requestsCh: requestsCh, newEndpoint: newEndpoint, mockLog: mockLog})
+	})
+}
+
+func spoolFiles(t *testing.T, spool *EventSpool) []string {
+	paths, err := filepath.Glob(filepath.Join(spool.dir, "*", "*"+spoolFileSuffix))
+	require.NoError(t, err)
+	return paths
+}
+
+func TestSpoolSavesUndeliveredPayloadAndDeliversItLater(t *testing.T) {
+	spoolTest(t, func(p spoolTestParams) {
+		e := p.newEndpoint()
+		metadata := EventPayloadMetadata{SchemaVersion: 4, Tags: "application-id/x"}
+		payload := `[{"kind":"custom","key":"e"}]`
+
+		result := e.wrapSender(failingEventSender, metadata).SendEventData(ldevents.AnalyticsEventDataKind, []byte(payload), 1)
+		assert.False(t, result.Success)
+		require.Len(t, spoolFiles(t, p.spool), 1)
+		assert.Equal(t, filepath.Join(p.spool.dir, "bulk"), filepath.Dir(spoolFiles(t, p.spool)[0]))
+		st.ExpectNoTestRequests(t, p.requestsCh, time.Millisecond*50)
+
+		e.replaceCredential(config.SDKKey("my-new-sdk-key"))
+		e.wrapSender(succeedingEventSender, metadata).SendEventData(ldevents.AnalyticsEventDataKind, []byte(`[]`), 0)
+
+		r := st.ExpectTestRequest(t, p.requestsCh, time.Second)
+		assert.Equal(t, "/bulk", r.Request.URL.Path)
+		assert.Equal(t, "my-new-sdk-key", r.Request.Header.Get("Authorization"))
+		assert.Equal(t, "application-id/x", r.Request.Header.Get(TagsHeader))
+		assert.Equal(t, payload, string(r.Body))
+		require.Eventually(t, func() bool { return len(spoolFiles(t, p.spool)) == 0 }, time.Second, time.Millisecond*10)
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Info, "Delivered 1 spooled event payload")
+	})
+}
+
+func TestSpoolDoesNotSavePayloadThatWasRejected(t *testing.T) {
+	spoolTest(t, func(p spoolTestParams) {
+		e := p.newEndpoint()
+		e.wrapSender(rejectingEventSender, EventPayloadMetadata{}).SendEventData(ldevents.AnalyticsEventDataKind, []byte(`[]`), 0)
+		assert.Len(t, spoolFiles(t, p.spool), 0)
+	})
+}
+
+func TestSpoolDeliversPayloadsLeftByPreviousProcess(t *testing.T) {
+	spoolTest(t, func(p spoolTestParams) {
+		e := p.newEndpoint()
+		for i := 0; i < 2; i++ {
+			e.wrapSender(failingEventSender, EventPayloadMetadata{}).SendEventData(ldevents.AnalyticsEventDataKind,
+				[]byte(`[{"kind":"custom","key":"e`+strconv.Itoa(i)+`"}]`), 1)
+		}
+		e.close()
+
+		_ = p.newEndpoint()
+		r1 := st.ExpectTestRequest(t, p.requestsCh, time.Second)
+		r2 := st.ExpectTestRequest(t, p.requestsCh, time.Second)
+		assert.Equal(t, `[{"kind":"custom","key":"e0"}]`, string(r1.Body))
+		assert.Equal(t, `[{"kind":"custom","key":"e1"}]`, string(r2.Body))
+	})
+}
+
+func TestSpoolDiscardsOldestPayloadsWhenFull(t *testing.T) {
+	spoolTest(t, func(p spoolTestParams) {
+		e := p.newEndpoint()
+		var bodies []string
+		for i := 0; i < 3; i++ {
+			body := `[{"kind":"custom","key":"e` + strconv.Itoa(i) + `"}]`
+			bodies = append(bodies, body)
+			e.wrapSender(failingEventSender, EventPayloadMetadata{}).SendEventData(ldevents.AnalyticsEventDataKind,
+				[]byte(body), 1)
+			if i == 0 {
+				info, err := os.Stat(spoolFiles(t, p.spool)[0])
+				require.NoError(t, err)
+				p.spool.maxSize = info.Size()*2 + 1
+			}
+		}
+
+		paths := spoolFiles(t, p.spool)
+		require.Len(t, paths, 2)
+		for i, path := range paths {
+			data, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			var payload spooledPayload
+			require.NoError(t, json.Unmarshal(data, &payload))
+			assert.Equal(t, bodies[i+1], string(payload.Data))
+		}
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Warn, "discarded 1 oldest event payload")
+	})
+}
+
+func TestSpoolDiscardsExpiredPayloads(t *testing.T) {
+	spoolTest(t, func(p spoolTestParams) {
+		require.NoError(t, os.MkdirAll(filepath.Join(p.spool.dir, "bulk"), 0700))
+		oldName := filepath.Join(p.spool.dir, "bulk",
+			fmt.Sprintf("%020d-000001%s", time.Now().Add(-p.spool.maxAge-time.Minute).UnixNano(), spoolFileSuffix))
+		data, _ := json.Marshal(spooledPayload{Kind: ldevents.AnalyticsEventDataKind, Count: 1, Data: []byte(`[]`)})
+		require.NoError(t, ioutil.WriteFile(oldName, data, 0600))
+
+		_ = p.newEndpoint()
+		require.Eventually(t, func() bool { return len(spoolFiles(t, p.spool)) == 0 }, time.Second, time.Millisecond*10)
+		st.ExpectNoTestRequests(t, p.requestsCh, time.Millisecond*50)
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Discarded 1 spooled event payload")
+	})
+}
+
+func TestEventDispatcherUsesSpoolSubdirectoryForEachEndpoint(t *testing.T) {
+	spool, err := NewEventSpool(t.TempDir(), config.EventsConfig{}, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	spool.replayInterval = time.Hour
+	opts := eventRelayTestOptions{spool: spool}
+	eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+		names := make(map[string]bool)
+		for _, e := range p.dispatcher.analyticsEndpoints {
+			names[filepath.Base(e.spool.dir)] = true
+		}
+		assert.Equal(t, map[string]bool{"bulk": true, "mobile": true,
+			"events-bulk-" + string(st.EnvWithAllCredentials.Config.EnvID): true}, names)
+	})
+}
@@ -50,8 +50,15 @@ type eventSummarizingRelay struct {
 	storeAdapter *store.SSERelayDataStoreAdapter
 	eventsConfig ldevents.EventsConfiguration
 	eventsURI    string
+	summaryOnly  bool
+	sampler      *eventSampler
 	loggers      ldlog.Loggers
+	coordinator  *eventCoordinator
+	spool        *eventSpoolEndpoint
+	exporter     *eventExportEndpoint
+	status       *deliveryStatus
 	closer       chan struct{}
+	closed       chan struct{}
 	lock         sync.Mutex
 	closeOnce    sync.Once
 }
@@ -139,6 +146,11 @@ func newEventSummarizingRelay(
 	loggers ldlog.Loggers,
 	remotePath string,
 	eventQueueCleanupInterval time.Duration,
+	coordinator *eventCoordinator,
+	spool *eventSpoolEndpoint,
+	exporter *eventExportEndpoint,
+	status *deliveryStatus,
+	sampler *eventSampler,
 ) *eventSummarizingRelay {
 	eventsConfig := ldevents.EventsConfiguration{
 		Capacity:              config.Capacity.GetOrElse(c.DefaultEventCapacity),
@@ -158,8 +170,15 @@ func newEventSummarizingRelay(
 		storeAdapter: storeAdapter,
 		eventsConfig: eventsConfig,
 		eventsURI:    strings.TrimRight(getEventsURI(config), "/") + remotePath,
+		summaryOnly:  config.SummaryOnly,
+		sampler:      sampler,
 		loggers:      loggers,
+		coordinator:  coordinator,
+		spool:        spool,
+		exporter:     exporter,
+		status:       status,
 		closer:       make(chan struct{}),
+		closed:       make(chan struct{}),
 	}
 	go er.runPeriodicCleanupTaskUntilClosed(eventQueueCleanupInterval)
 	return er
@@ -175,7 +194,7 @@ func (er *eventSummarizingRelay) enqueue(metadata EventPayloadMetadata, rawEvent
 	queue := er.queues[metadata]
 	if queue == nil {
 		sender := &delegatingEventSender{
-			wrapped: makeEventSender(er.httpClient, er.eventsURI, er.baseHeaders, er.authKey, metadata, er.loggers),
+			wrapped: er.makeEventSender(er.authKey, metadata),
 		}
 		eventsConfig := er.eventsConfig
 		eventsConfig.EventSender = sender
@@ -196,6 +215,16 @@ func (er *eventSummarizingRelay) enqueue(metadata EventPayloadMetadata, rawEvent
 			continue
 		}
 		if evt != nil {
+			if er.summaryOnly {
+				// Only feature events contribute to summaries, and there's no need for the EventProcessor
+				// to produce full feature events, since summaryOnlySender would discard them.
+				if e, ok := evt.(ldevents.FeatureRequestEvent); ok {
+					e.TrackEvents = false
+					e.DebugEventsUntilDate = 0
+					queue.eventProcessor.RecordFeatureRequestEvent(e)
+				}
+				continue
+			}
 			switch e := evt.(type) {
 			case ldevents.FeatureRequestEvent:
 				queue.eventProcessor.RecordFeatureRequestEvent(e)
@@ -229,13 +258,26 @@ func (er *eventSummarizingRelay) replaceCredential(newCredential c.SDKCredential
 		er.authKey = newCredential
 		for metadata, queue := range er.queues {
 			// See comment on makeEventSender() about why we create a new one in this situation.
-			sender := makeEventSender(er.httpClient, er.eventsURI, er.baseHeaders, newCredential, metadata, er.loggers)
-			queue.eventSender.setWrapped(sender)
+			queue.eventSender.setWrapped(er.makeEventSender(newCredential, metadata))
 		}
 	}
 	er.lock.Unlock()
 }
 
+// makeEventSender creates the EventSender for one of the relay's queues, applying event coordination
+// spooling, and event export if they are enabled, and sampling and summary-only mode.
+func (er *eventSummarizingRelay) makeEventSender(authKey c.SDKCredential, metadata EventPayloadMetadata) ldevents.EventSender {
+	sender := er.status.wrapSender(makeEventSender(er.httpClient, er.eventsURI, er.baseHeaders, authKey, metadata, er.loggers))
+	sender = er.coordinator.wrapSender(er.spool.wrapSender(er.exporter.wrapSender(sender, metadata), metadata), metadata)
+	if er.sampler != nil {
+		sender = filteringEventSender{wrapped: sender, filter: er.sampler.apply}
+	}
+	if er.summaryOnly {
+		sender = filteringEventSender{wrapped: sender, filter: onlySummaryEvents}
+	}
+	return sender
+}
+
 func (er *eventSummarizingRelay) translateEvent(rawEvent json.RawMessage, schemaVersion int) (interface{}, error) {
 	var kindFieldOnly struct {
 		Kind string
@@ -390,6 +432,7 @@ func (er *eventSummarizingRelay) runPeriodicCleanupTaskUntilClosed(cleanupInterv
 			for _, queue := range queues {
 				_ = queue.eventProcessor.Close()
 			}
+			close(er.closed)
 			return
 
 		case <-ticker.C:
@@ -421,9 +464,12 @@ func (er *eventSummarizingRelay) runPeriodicCleanupTaskUntilClosed(cleanupInterv
 	}
 }
 
+// close shuts down all of the EventProcessor instances, and waits until they have delivered their
+// remaining events.
 func (er *eventSummarizingRelay) close() {
 	er.closeOnce.Do(func() {
 		er.closer <- struct{}{}
+		<-er.closed
 	})
 }
 
@@ -431,7 +477,7 @@ func (d *delegatingEventSender) SendEventData(kind ldevents.EventDataKind, data
 	d.lock.Lock()
 	sender := d.wrapped
 	d.lock.Unlock()
-	return sender.SendEventData(kind, data, count)
+	return sendTracedEventData(sender, kind, data, count)
 }
 
 func (d *delegatingEventSender) setWrapped(newWrappedSender ldevents.EventSender) {
@@ -0,0 +1,59 @@
+package events
+
+import (
+	"encoding/json"
+
+	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
+)
+
+// In summary-only mode (EventsConfig.SummaryOnly), Relay forwards only summary events, which count the
+// evaluations of each flag by variation without identifying any users, and discards everything else.
+//
+// Newer SDKs already send summary events, so their payloads are filtered as soon as they are received,
+// by onlySummaryEvents. Events from older SDKs go through eventSummarizingRelay, which computes the
+// summaries; its output is filtered by a filteringEventSender, since the EventProcessor also produces
+// index events for each user.
+
+// filteringEventSender applies a filter to each payload of analytics events before it is delivered by
+// the wrapped sender. If the filter removes all of the events, nothing is delivered.
+type filteringEventSender struct {
+	wrapped ldevents.EventSender
+	filter  func([]json.RawMessage) []json.RawMessage
+}
+
+// onlySummaryEvents returns the summary events in a payload. Events that can't be parsed are discarded.
+func onlySummaryEvents(evts []json.RawMessage) []json.RawMessage {
+	ret := make([]json.RawMessage, 0, 1)
+	for _, evt := range evts {
+		var kindFieldOnly struct {
+			Kind string `json:"kind"`
+		}
+		if err := json.Unmarshal(evt, &kindFieldOnly); err == nil && kindFieldOnly.Kind == summaryEventKind {
+			ret = append(ret, evt)
+		}
+	}
+	return ret
+}
+
+func (s filteringEventSender) SendEventData(
+	kind ldevents.EventDataKind,
+	data []byte,
+	count int,
+) ldevents.EventSenderResult {
+	if kind != ldevents.AnalyticsEventDataKind {
+		return s.wrapped.SendEventData(kind, data, count)
+	}
+	var evts []json.RawMessage
+	if err := json.Unmarshal(data, &evts); err != nil {
+		return ldevents.EventSenderResult{Success: true} // COVERAGE: EventProcessor always produces valid JSON
+	}
+	evts = s.filter(evts)
+	if len(evts) == 0 {
+		return ldevents.EventSenderResult{Success: true}
+	}
+	filtered, err := json.Marshal(evts)
+	if err != nil { // COVERAGE: can't happen in unit tests
+		return ldevents.EventSenderResult{Success: true}
+	}
+	return s.wrapped.SendEventData(kind, filtered, len(evts))
+}
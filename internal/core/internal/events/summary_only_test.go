@@ -0,0 +1,70 @@
+package events
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	m "github.com/launchdarkly/go-test-helpers/v2/matchers"
+	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const expectedSummaryOnlyOutput = `
+[
+	{
+		"kind": "summary", "startDate": 1000, "endDate": 1001,
+		"features": {
+			"flagkey": {
+				"default": "c",
+				"counters": [ { "variation": 1, "version": 11, "value": "b", "count": 2 } ]
+			}
+		}
+	}
+]`
+
+func TestSummaryOnlyModeForwardsOnlySummaryEventsFromNewerSDKs(t *testing.T) {
+	eventRelayTest(t, st.EnvMain, config.EventsConfig{SummaryOnly: true}, func(p eventRelayTestParams) {
+		handler := p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)
+		handler(httptest.NewRecorder(), st.BuildRequest("POST", "/",
+			[]byte(`[{"kind":"identify","user":{"key":"userkey"}}]`), headersWithEventSchema(SummaryEventsSchemaVersion)))
+		handler(httptest.NewRecorder(), st.BuildRequest("POST", "/",
+			[]byte(expectedSummarizedFeatureEventsOutputTrackEvents), headersWithEventSchema(SummaryEventsSchemaVersion)))
+		p.dispatcher.flush()
+
+		r := st.ExpectTestRequest(t, p.requestsCh, time.Second)
+		m.In(t).Assert(r.Body, m.JSONStrEqual(expectedSummaryOnlyOutput))
+		st.ExpectNoTestRequests(t, p.requestsCh, time.Millisecond*100)
+	})
+}
+
+func TestSummaryOnlyModeForwardsOnlySummaryEventsFromOlderSDKs(t *testing.T) {
+	eventRelayTest(t, st.EnvMain, config.EventsConfig{SummaryOnly: true}, func(p eventRelayTestParams) {
+		flag := makeTestFlag(true, 0)
+		_, _ = st.UpsertFlag(p.dataStore, flag)
+
+		handler := p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)
+		handler(httptest.NewRecorder(), st.BuildRequest("POST", "/", []byte(summarizableCustomEvents), headersWithEventSchema(0)))
+		handler(httptest.NewRecorder(), st.BuildRequest("POST", "/", []byte(summarizableFeatureEvents), headersWithEventSchema(0)))
+		p.dispatcher.flush()
+
+		payload := expectSummarizedPayload(t, p.requestsCh)
+		m.In(t).Assert(payload, m.JSONStrEqual(expectedSummaryOnlyOutput))
+	})
+}
+
+func TestSummaryOnlyModeSendsNothingIfThereAreNoSummaries(t *testing.T) {
+	eventRelayTest(t, st.EnvMain, config.EventsConfig{SummaryOnly: true}, func(p eventRelayTestParams) {
+		handler := p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)
+		handler(httptest.NewRecorder(), st.BuildRequest("POST", "/", []byte(summarizableCustomEvents), headersWithEventSchema(0)))
+		p.dispatcher.flush()
+
+		st.ExpectNoTestRequests(t, p.requestsCh, time.Millisecond*100)
+		assert.True(t, p.dispatcher.IsOperational())
+	})
+}
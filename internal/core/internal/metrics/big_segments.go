@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+const (
+	// BigSegmentStoreGetMetadata is the operation name for a big segment store metadata query, for
+	// RecordBigSegmentStoreQuery.
+	BigSegmentStoreGetMetadata = "getMetadata"
+
+	// BigSegmentStoreGetUserMembership is the operation name for a big segment store membership query,
+	// for RecordBigSegmentStoreQuery.
+	BigSegmentStoreGetUserMembership = "getUserMembership"
+
+	// BigSegmentStoreGetUserMemberships is the operation name for a big segment store query of the
+	// memberships of many users at once, for RecordBigSegmentStoreQuery.
+	BigSegmentStoreGetUserMemberships = "getUserMemberships"
+)
+
+// RecordBigSegmentStoreQuery records the duration of a query to an environment's big segment store,
+// and whether it failed. The context should be the environment's OpenCensus context.
+func RecordBigSegmentStoreQuery(ctx context.Context, operation string, duration time.Duration, err error) {
+	tags := []tag.Mutator{tag.Upsert(operationTagKey, operation)}
+	_ = stats.RecordWithTags(ctx, tags, bigSegmentStoreLatencyMeasure.M(float64(duration)/float64(time.Millisecond)))
+	if err != nil {
+		_ = stats.RecordWithTags(ctx, tags, bigSegmentStoreErrorsMeasure.M(1))
+	}
+	recordInternalBigSegmentStoreQuery(ctx, operation, duration, err)
+}
+
+// RecordBigSegmentLookup records a lookup of a user's big segment membership during an evaluation.
+// Lookups that are answered from the membership cache do not cause a store query, so the cache hit
+// rate is the proportion of lookups that did not result in a getUserMembership query.
+func RecordBigSegmentLookup(ctx context.Context) {
+	stats.Record(ctx, bigSegmentLookupsMeasure.M(1))
+	recordInternalBigSegmentLookup(ctx)
+}
+
+// RecordBigSegmentQueryRejected records a user membership query that was not sent to an environment's
+// big segment store, because the environment already had the maximum number of concurrent queries and
+// none of them finished in time.
+func RecordBigSegmentQueryRejected(ctx context.Context) {
+	stats.Record(ctx, bigSegmentQueriesRejectedMeasure.M(1))
+	recordInternalBigSegmentQueryRejected(ctx)
+}
+
+// RecordBigSegmentStoreStaleness records how long it has been since an environment's big segment
+// data was last known to be up to date.
+func RecordBigSegmentStoreStaleness(ctx context.Context, staleness time.Duration) {
+	stats.Record(ctx, bigSegmentStoreStalenessMeasure.M(staleness.Milliseconds()))
+	recordInternalBigSegmentStoreStaleness(ctx, staleness)
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+)
+
+func TestRecordBigSegmentStoreQuery(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		expectedTags := map[string]string{"env": p.envName, "operation": BigSegmentStoreGetUserMembership}
+
+		RecordBigSegmentStoreQuery(p.env.GetOpenCensusContext(), BigSegmentStoreGetUserMembership, time.Millisecond, nil)
+		RecordBigSegmentStoreQuery(p.env.GetOpenCensusContext(), BigSegmentStoreGetUserMembership, time.Millisecond,
+			errors.New("sorry"))
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(bigSegmentStoreLatencyView.Name, st.TestMetricsRow{Tags: expectedTags, Count: 2}) &&
+				d.HasRow(bigSegmentStoreErrorsView.Name, st.TestMetricsRow{Tags: expectedTags, Count: 1})
+		})
+	})
+}
+
+func TestRecordBigSegmentLookup(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordBigSegmentLookup(p.env.GetOpenCensusContext())
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(bigSegmentLookupsView.Name, st.TestMetricsRow{
+				Tags:  map[string]string{"env": p.envName},
+				Count: 1,
+			})
+		})
+	})
+}
+
+func TestRecordBigSegmentQueryRejected(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordBigSegmentQueryRejected(p.env.GetOpenCensusContext())
+		RecordBigSegmentQueryRejected(p.env.GetOpenCensusContext())
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(bigSegmentQueriesRejectedView.Name, st.TestMetricsRow{
+				Tags:  map[string]string{"env": p.envName},
+				Count: 2,
+			})
+		})
+	})
+}
+
+func TestRecordBigSegmentStoreStaleness(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordBigSegmentStoreStaleness(p.env.GetOpenCensusContext(), time.Minute)
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(bigSegmentStoreStalenessView.Name, st.TestMetricsRow{
+				Tags: map[string]string{"env": p.envName},
+				Sum:  float64(time.Minute.Milliseconds()),
+			})
+		})
+	})
+}
@@ -21,6 +21,32 @@ const (
 
 	requestMeasureName = "requests"
 
+	bigSegmentStoreLatencyMeasureName    = "big_segment_store_latency"
+	bigSegmentStoreErrorsMeasureName     = "big_segment_store_errors"
+	bigSegmentLookupsMeasureName         = "big_segment_lookups"
+	bigSegmentStoreStalenessMeasureName  = "big_segment_store_staleness"
+	bigSegmentQueriesRejectedMeasureName = "big_segment_queries_rejected"
+
+	throttledRequestsMeasureName = "throttled_requests"
+
+	shadowComparisonsMeasureName = "shadow_comparisons"
+
+	upstreamStreamReconnectsMeasureName = "upstream_stream_reconnects"
+
+	rejectedStreamsMeasureName = "rejected_stream_connections"
+	queuedStreamsMeasureName   = "queued_stream_connections"
+
+	memorySheddingEventsMeasureName = "memory_shedding_events"
+
+	replicationLagMeasureName    = "replication_lag"
+	replicationErrorsMeasureName = "replication_errors"
+
+	credentialLimitTagValue = "credential"
+	clientIPLimitTagValue   = "clientIP"
+	envLimitTagValue        = "environment"
+	globalLimitTagValue     = "global"
+	memoryLimitTagValue     = "memory"
+
 	defaultFlushInterval = time.Minute
 )
 
@@ -31,7 +57,12 @@ var (
 	routeTagKey, _            = tag.NewKey("route")            //nolint:gochecknoglobals
 	methodTagKey, _           = tag.NewKey("method")           //nolint:gochecknoglobals
 	envNameTagKey, _          = tag.NewKey("env")              //nolint:gochecknoglobals
+	tenantTagKey, _           = tag.NewKey("tenant")           //nolint:gochecknoglobals
+	operationTagKey, _        = tag.NewKey("operation")        //nolint:gochecknoglobals
+	limitTagKey, _            = tag.NewKey("limit")            //nolint:gochecknoglobals
+	resultTagKey, _           = tag.NewKey("result")           //nolint:gochecknoglobals
+	databaseTagKey, _         = tag.NewKey("database")         //nolint:gochecknoglobals
 
-	publicTags  = []tag.Key{platformCategoryTagKey, userAgentTagKey, envNameTagKey}                //nolint:gochecknoglobals
+	publicTags  = []tag.Key{platformCategoryTagKey, userAgentTagKey, envNameTagKey, tenantTagKey}  //nolint:gochecknoglobals
 	privateTags = []tag.Key{platformCategoryTagKey, userAgentTagKey, relayIDTagKey, envNameTagKey} //nolint:gochecknoglobals
 )
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const poolLabel = "pool"
+
+// DatabasePoolStats is a snapshot of the utilization of one of an environment's database connection
+// pools, for SetDatabasePoolStatsProvider.
+type DatabasePoolStats struct {
+	Pool         string
+	InUse        int
+	Idle         int
+	MaxOpen      int
+	WaitCount    int64
+	WaitDuration time.Duration
+}
+
+// SetDatabasePoolStatsProvider registers a function that returns the current stats for an environment's
+// database connection pools. It is called whenever the internal metrics endpoint is scraped, rather
+// than recording values as they change, because the pools only keep track of their current state. The
+// context should be the environment's OpenCensus context. If the internal metrics endpoint is not
+// enabled, this does nothing.
+func SetDatabasePoolStatsProvider(ctx context.Context, provider func() []DatabasePoolStats) {
+	if im, envName := getInternalMetrics(ctx); im != nil {
+		im.databasePools.setProvider(envName, provider)
+	}
+}
+
+// databasePoolCollector is a Prometheus collector that reports the database pool stats for each
+// environment that has called SetDatabasePoolStatsProvider.
+type databasePoolCollector struct {
+	inUse        *prometheus.Desc
+	idle         *prometheus.Desc
+	maxOpen      *prometheus.Desc
+	waits        *prometheus.Desc
+	waitDuration *prometheus.Desc
+	providers    map[string]func() []DatabasePoolStats
+	lock         sync.Mutex
+}
+
+func newDatabasePoolCollector(prefix string) *databasePoolCollector {
+	labels := []string{envLabel, poolLabel}
+	return &databasePoolCollector{
+		inUse: prometheus.NewDesc(prefix+"_database_pool_connections_in_use",
+			"Current number of database connections in use.", labels, nil),
+		idle: prometheus.NewDesc(prefix+"_database_pool_connections_idle",
+			"Current number of idle database connections.", labels, nil),
+		maxOpen: prometheus.NewDesc(prefix+"_database_pool_max_connections",
+			"Maximum number of database connections in the pool.", labels, nil),
+		waits: prometheus.NewDesc(prefix+"_database_pool_waits_total",
+			"Number of times a database connection had to be waited for because the pool was full.", labels, nil),
+		waitDuration: prometheus.NewDesc(prefix+"_database_pool_wait_seconds_total",
+			"Total time spent waiting for database connections because the pool was full.", labels, nil),
+		providers: make(map[string]func() []DatabasePoolStats),
+	}
+}
+
+func (c *databasePoolCollector) setProvider(envName string, provider func() []DatabasePoolStats) {
+	c.lock.Lock()
+	c.providers[envName] = provider
+	c.lock.Unlock()
+}
+
+func (c *databasePoolCollector) removeEnvironment(envName string) {
+	c.lock.Lock()
+	delete(c.providers, envName)
+	c.lock.Unlock()
+}
+
+func (c *databasePoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.maxOpen
+	ch <- c.waits
+	ch <- c.waitDuration
+}
+
+func (c *databasePoolCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	envNames := make([]string, 0, len(c.providers))
+	for envName := range c.providers {
+		envNames = append(envNames, envName)
+	}
+	sort.Strings(envNames)
+	providers := make([]func() []DatabasePoolStats, 0, len(envNames))
+	for _, envName := range envNames {
+		providers = append(providers, c.providers[envName])
+	}
+	c.lock.Unlock()
+
+	for i, provider := range providers {
+		for _, stats := range provider() {
+			labels := []string{envNames[i], stats.Pool}
+			ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse), labels...)
+			ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle), labels...)
+			if stats.MaxOpen > 0 {
+				ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpen), labels...)
+			}
+			ch <- prometheus.MustNewConstMetric(c.waits, prometheus.CounterValue, float64(stats.WaitCount), labels...)
+			ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue,
+				stats.WaitDuration.Seconds(), labels...)
+		}
+	}
+}
@@ -55,10 +55,14 @@ func TestOpenCensusEventsExporter(t *testing.T) {
 			Aggregation: view.Sum(),
 			TagKeys:     []tag.Key{relayIDTagKey, platformCategoryTagKey, userAgentTagKey},
 		}
-		require.NoError(t, view.Register(privateConnMetricView))
-		defer view.Unregister(privateConnMetricView)
-		require.NoError(t, view.Register(privateNewConnMetricView))
-		defer view.Unregister(privateNewConnMetricView)
+		// If another test has already created a Manager, the private views with these names are already
+		// registered, and unregistering them would break any later tests that depend on them.
+		for _, v := range []*view.View{privateConnMetricView, privateNewConnMetricView} {
+			if view.Find(v.Measure.Name()) == nil {
+				require.NoError(t, view.Register(v))
+				defer view.Unregister(v)
+			}
+		}
 		f(ctx, exporter, relayId)
 	}
 
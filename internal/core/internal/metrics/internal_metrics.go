@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opencensus.io/tag"
+)
+
+const (
+	// InternalMetricsPath is the path of the internal metrics endpoint on the internal metrics port.
+	InternalMetricsPath = "/metrics"
+
+	envLabel              = "env"
+	platformCategoryLabel = "platformCategory"
+	operationLabel        = "operation"
+)
+
+// internalMetrics holds the Prometheus collectors for the internal metrics endpoint that is configured
+// in config.InternalMetricsConfig. Unlike the OpenCensus views, these are not shared with any of the
+// metrics integrations; they are only served by InternalMetricsHandler, so they can include details
+// that we wouldn't want to send to a third-party service.
+//
+// The internalMetrics instance is stored in the Manager's OpenCensus context, so that the same Record
+// functions that are used for OpenCensus can also update these collectors without needing another
+// parameter. If the internal metrics endpoint is not enabled, there is no instance in the context and
+// nothing is recorded.
+type internalMetrics struct {
+	registry                  *prometheus.Registry
+	streamConnections         *prometheus.GaugeVec
+	eventsForwarded           *prometheus.CounterVec
+	eventsSampledOut          *prometheus.CounterVec
+	bigSegmentStoreLatency    *prometheus.HistogramVec
+	bigSegmentStoreErrors     *prometheus.CounterVec
+	bigSegmentLookups         *prometheus.CounterVec
+	bigSegmentCacheMisses     *prometheus.CounterVec
+	bigSegmentStoreStaleness  *prometheus.GaugeVec
+	bigSegmentQueriesRejected *prometheus.CounterVec
+	memoryShedding            prometheus.Gauge
+	memorySheddingEvents      prometheus.Counter
+	databasePools             *databasePoolCollector
+}
+
+type internalMetricsContextKey struct{}
+
+func newInternalMetrics(c config.InternalMetricsConfig) *internalMetrics {
+	prefix := c.Prefix
+	if prefix == "" {
+		prefix = defaultMetricsPrefix
+	}
+	im := &internalMetrics{
+		registry: prometheus.NewRegistry(),
+		streamConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: prefix,
+			Name:      "stream_connections",
+			Help:      "Current number of active stream connections from SDKs.",
+		}, []string{envLabel, platformCategoryLabel}),
+		eventsForwarded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Name:      "events_forwarded_total",
+			Help:      "Number of analytics events received from SDKs to be forwarded to LaunchDarkly.",
+		}, []string{envLabel, platformCategoryLabel}),
+		eventsSampledOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Name:      "events_sampled_out_total",
+			Help:      "Number of analytics events that were not forwarded because of event sampling.",
+		}, []string{envLabel, platformCategoryLabel}),
+		bigSegmentStoreLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: prefix,
+			Name:      "big_segment_store_latency_seconds",
+			Help:      "Time taken by big segment store queries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{envLabel, operationLabel}),
+		bigSegmentStoreErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Name:      "big_segment_store_errors_total",
+			Help:      "Number of failed big segment store queries.",
+		}, []string{envLabel, operationLabel}),
+		bigSegmentLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Name:      "big_segment_cache_lookups_total",
+			Help:      "Number of big segment membership lookups during evaluations.",
+		}, []string{envLabel}),
+		bigSegmentCacheMisses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Name:      "big_segment_cache_misses_total",
+			Help:      "Number of big segment membership lookups that were not answered from the membership cache.",
+		}, []string{envLabel}),
+		bigSegmentStoreStaleness: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: prefix,
+			Name:      "big_segment_store_staleness_seconds",
+			Help:      "Time since the big segment data was last known to be up to date.",
+		}, []string{envLabel}),
+		bigSegmentQueriesRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Name:      "big_segment_queries_rejected_total",
+			Help:      "Number of big segment membership queries that were not made because of the concurrency limit.",
+		}, []string{envLabel}),
+		memoryShedding: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: prefix,
+			Name:      "memory_shedding",
+			Help:      "1 if Relay is shedding load because its memory use is above the high-water mark, otherwise 0.",
+		}),
+		memorySheddingEvents: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: prefix,
+			Name:      "memory_shedding_events_total",
+			Help:      "Number of times that Relay started shedding load because its memory use rose above the high-water mark.",
+		}),
+		databasePools: newDatabasePoolCollector(prefix),
+	}
+	im.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		im.streamConnections,
+		im.eventsForwarded,
+		im.eventsSampledOut,
+		im.bigSegmentStoreLatency,
+		im.bigSegmentStoreErrors,
+		im.bigSegmentLookups,
+		im.bigSegmentCacheMisses,
+		im.bigSegmentStoreStaleness,
+		im.bigSegmentQueriesRejected,
+		im.memoryShedding,
+		im.memorySheddingEvents,
+		im.databasePools,
+	)
+	return im
+}
+
+func (im *internalMetrics) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(InternalMetricsPath, promhttp.HandlerFor(im.registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+// removeEnvironment deletes the series for an environment, so that an environment that has been
+// removed does not continue to be reported with its last values. The stream connection gauge is left
+// alone, because connections that are still being closed will decrement it afterward.
+func (im *internalMetrics) removeEnvironment(envName string) {
+	for _, platformCategory := range []string{browserTagValue, mobileTagValue, serverTagValue} {
+		im.eventsForwarded.DeleteLabelValues(envName, platformCategory)
+		im.eventsSampledOut.DeleteLabelValues(envName, platformCategory)
+	}
+	for _, operation := range []string{
+		BigSegmentStoreGetMetadata, BigSegmentStoreGetUserMembership, BigSegmentStoreGetUserMemberships,
+	} {
+		im.bigSegmentStoreLatency.DeleteLabelValues(envName, operation)
+		im.bigSegmentStoreErrors.DeleteLabelValues(envName, operation)
+	}
+	im.bigSegmentLookups.DeleteLabelValues(envName)
+	im.bigSegmentCacheMisses.DeleteLabelValues(envName)
+	im.bigSegmentStoreStaleness.DeleteLabelValues(envName)
+	im.bigSegmentQueriesRejected.DeleteLabelValues(envName)
+	im.databasePools.removeEnvironment(envName)
+}
+
+// getInternalMetrics returns the internalMetrics instance from an environment's OpenCensus context,
+// along with the environment name, or nil if the internal metrics endpoint is not enabled.
+func getInternalMetrics(ctx context.Context) (*internalMetrics, string) {
+	im, _ := ctx.Value(internalMetricsContextKey{}).(*internalMetrics)
+	if im == nil {
+		return nil, ""
+	}
+	envName, _ := tag.FromContext(ctx).Value(envNameTagKey)
+	return im, envName
+}
+
+// RecordEventsForwarded records analytics events that were received from an SDK and will be forwarded
+// to LaunchDarkly. These are reported only by the internal metrics endpoint. The context should be the
+// environment's OpenCensus context.
+func RecordEventsForwarded(ctx context.Context, sdkKind basictypes.SDKKind, count int) {
+	if im, envName := getInternalMetrics(ctx); im != nil {
+		im.eventsForwarded.WithLabelValues(envName, platformCategoryForSDKKind(sdkKind)).Add(float64(count))
+	}
+}
+
+// RecordEventsSampledOut records analytics events that were received from an SDK but will not be
+// forwarded because of the environment's event sampling. These are reported only by the internal
+// metrics endpoint. The context should be the environment's OpenCensus context.
+func RecordEventsSampledOut(ctx context.Context, sdkKind basictypes.SDKKind, count int) {
+	if im, envName := getInternalMetrics(ctx); im != nil {
+		im.eventsSampledOut.WithLabelValues(envName, platformCategoryForSDKKind(sdkKind)).Add(float64(count))
+	}
+}
+
+func platformCategoryForSDKKind(sdkKind basictypes.SDKKind) string {
+	switch sdkKind {
+	case basictypes.MobileSDK:
+		return mobileTagValue
+	case basictypes.JSClientSDK:
+		return browserTagValue
+	default:
+		return serverTagValue
+	}
+}
+
+func recordInternalStreamConnection(ctx context.Context, delta float64) {
+	if im, envName := getInternalMetrics(ctx); im != nil {
+		platformCategory, _ := tag.FromContext(ctx).Value(platformCategoryTagKey)
+		im.streamConnections.WithLabelValues(envName, platformCategory).Add(delta)
+	}
+}
+
+func recordInternalBigSegmentStoreQuery(ctx context.Context, operation string, duration time.Duration, err error) {
+	if im, envName := getInternalMetrics(ctx); im != nil {
+		im.bigSegmentStoreLatency.WithLabelValues(envName, operation).Observe(duration.Seconds())
+		if err != nil {
+			im.bigSegmentStoreErrors.WithLabelValues(envName, operation).Inc()
+		}
+		if operation == BigSegmentStoreGetUserMembership {
+			// A membership query is only done if the membership cache didn't have the user
+			im.bigSegmentCacheMisses.WithLabelValues(envName).Inc()
+		}
+	}
+}
+
+func recordInternalBigSegmentLookup(ctx context.Context) {
+	if im, envName := getInternalMetrics(ctx); im != nil {
+		im.bigSegmentLookups.WithLabelValues(envName).Inc()
+	}
+}
+
+func recordInternalBigSegmentStoreStaleness(ctx context.Context, staleness time.Duration) {
+	if im, envName := getInternalMetrics(ctx); im != nil {
+		im.bigSegmentStoreStaleness.WithLabelValues(envName).Set(staleness.Seconds())
+	}
+}
+
+func recordInternalBigSegmentQueryRejected(ctx context.Context) {
+	if im, envName := getInternalMetrics(ctx); im != nil {
+		im.bigSegmentQueriesRejected.WithLabelValues(envName).Inc()
+	}
+}
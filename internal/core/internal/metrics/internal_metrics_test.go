@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeInternalMetricsConfig(prefix string) config.InternalMetricsConfig {
+	port, _ := configtypes.NewOptIntGreaterThanZero(8050)
+	return config.InternalMetricsConfig{Port: port, Prefix: prefix}
+}
+
+func scrapeInternalMetrics(t *testing.T, m *Manager) string {
+	handler := m.InternalMetricsHandler()
+	require.NotNil(t, handler)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", InternalMetricsPath, nil))
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	return w.Body.String()
+}
+
+func TestInternalMetricsHandlerIsNilIfNotEnabled(t *testing.T) {
+	manager, err := NewManager(config.MetricsConfig{}, config.InternalMetricsConfig{}, 0, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer manager.Close()
+
+	assert.Nil(t, manager.InternalMetricsHandler())
+}
+
+func TestInternalMetricsIncludeGoRuntimeMetrics(t *testing.T) {
+	manager, err := NewManager(config.MetricsConfig{}, makeInternalMetricsConfig(""), 0, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer manager.Close()
+
+	assert.Contains(t, scrapeInternalMetrics(t, manager), "go_goroutines ")
+}
+
+func TestInternalMetricsForEnvironment(t *testing.T) {
+	manager, err := NewManager(config.MetricsConfig{}, makeInternalMetricsConfig("test"), 0, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer manager.Close()
+
+	env, err := manager.AddEnvironment("my-env", "", nil)
+	require.NoError(t, err)
+	ctx := env.GetOpenCensusContext()
+
+	RecordEventsForwarded(ctx, basictypes.MobileSDK, 3)
+	RecordEventsSampledOut(ctx, basictypes.MobileSDK, 2)
+	RecordBigSegmentLookup(ctx)
+	RecordBigSegmentLookup(ctx)
+	RecordBigSegmentStoreQuery(ctx, BigSegmentStoreGetUserMembership, time.Millisecond, errors.New("sorry"))
+	RecordBigSegmentStoreStaleness(ctx, time.Minute)
+	RecordBigSegmentQueryRejected(ctx)
+
+	WithGauge(ctx, userAgentValue, func() {
+		body := scrapeInternalMetrics(t, manager)
+		assert.Contains(t, body, `test_stream_connections{env="my-env",platformCategory="server"} 1`)
+		assert.Contains(t, body, `test_events_forwarded_total{env="my-env",platformCategory="mobile"} 3`)
+		assert.Contains(t, body, `test_events_sampled_out_total{env="my-env",platformCategory="mobile"} 2`)
+		assert.Contains(t, body, `test_big_segment_cache_lookups_total{env="my-env"} 2`)
+		assert.Contains(t, body, `test_big_segment_cache_misses_total{env="my-env"} 1`)
+		assert.Contains(t, body, `test_big_segment_store_errors_total{env="my-env",operation="getUserMembership"} 1`)
+		assert.Contains(t, body, `test_big_segment_store_latency_seconds_count{env="my-env",operation="getUserMembership"} 1`)
+		assert.Contains(t, body, `test_big_segment_store_staleness_seconds{env="my-env"} 60`)
+		assert.Contains(t, body, `test_big_segment_queries_rejected_total{env="my-env"} 1`)
+	}, ServerConns)
+
+	assert.Contains(t, scrapeInternalMetrics(t, manager), `test_stream_connections{env="my-env",platformCategory="server"} 0`)
+
+	manager.RemoveEnvironment(env)
+	assert.NotContains(t, scrapeInternalMetrics(t, manager), `test_events_forwarded_total{env="my-env"`)
+}
+
+func TestInternalMetricsForDatabasePools(t *testing.T) {
+	manager, err := NewManager(config.MetricsConfig{}, makeInternalMetricsConfig("test"), 0, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer manager.Close()
+
+	env, err := manager.AddEnvironment("my-env", "", nil)
+	require.NoError(t, err)
+
+	SetDatabasePoolStatsProvider(env.GetOpenCensusContext(), func() []DatabasePoolStats {
+		return []DatabasePoolStats{
+			{Pool: "dataStore", InUse: 3, Idle: 2, MaxOpen: 16, WaitCount: 4, WaitDuration: 2 * time.Second},
+			{Pool: "bigSegments", InUse: 1},
+		}
+	})
+
+	body := scrapeInternalMetrics(t, manager)
+	assert.Contains(t, body, `test_database_pool_connections_in_use{env="my-env",pool="dataStore"} 3`)
+	assert.Contains(t, body, `test_database_pool_connections_idle{env="my-env",pool="dataStore"} 2`)
+	assert.Contains(t, body, `test_database_pool_max_connections{env="my-env",pool="dataStore"} 16`)
+	assert.Contains(t, body, `test_database_pool_waits_total{env="my-env",pool="dataStore"} 4`)
+	assert.Contains(t, body, `test_database_pool_wait_seconds_total{env="my-env",pool="dataStore"} 2`)
+	assert.Contains(t, body, `test_database_pool_connections_in_use{env="my-env",pool="bigSegments"} 1`)
+	assert.NotContains(t, body, `test_database_pool_max_connections{env="my-env",pool="bigSegments"}`)
+
+	manager.RemoveEnvironment(env)
+	assert.NotContains(t, scrapeInternalMetrics(t, manager), `test_database_pool_connections_in_use{env="my-env"`)
+}
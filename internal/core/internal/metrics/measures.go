@@ -23,6 +23,39 @@ var (
 	newConnMeasure = stats.Int64(newConnMeasureName, "total number of connections", stats.UnitDimensionless)
 	requestMeasure = stats.Int64(requestMeasureName, "Number of hits to a route", stats.UnitDimensionless)
 
+	bigSegmentStoreLatencyMeasure = stats.Float64(bigSegmentStoreLatencyMeasureName,
+		"time taken by big segment store queries", stats.UnitMilliseconds)
+	bigSegmentStoreErrorsMeasure = stats.Int64(bigSegmentStoreErrorsMeasureName,
+		"number of failed big segment store queries", stats.UnitDimensionless)
+	bigSegmentLookupsMeasure = stats.Int64(bigSegmentLookupsMeasureName,
+		"number of big segment membership lookups during evaluations", stats.UnitDimensionless)
+	bigSegmentStoreStalenessMeasure = stats.Int64(bigSegmentStoreStalenessMeasureName,
+		"time since the big segment data was last known to be up to date", stats.UnitMilliseconds)
+	bigSegmentQueriesRejectedMeasure = stats.Int64(bigSegmentQueriesRejectedMeasureName,
+		"number of big segment membership queries rejected by the concurrency limit", stats.UnitDimensionless)
+
+	throttledRequestsMeasure = stats.Int64(throttledRequestsMeasureName,
+		"number of requests rejected by rate limiting", stats.UnitDimensionless)
+
+	shadowComparisonsMeasure = stats.Int64(shadowComparisonsMeasureName,
+		"number of responses compared with the shadow Relay instance", stats.UnitDimensionless)
+
+	upstreamStreamReconnectsMeasure = stats.Int64(upstreamStreamReconnectsMeasureName,
+		"number of times the stream connection to LaunchDarkly was retried after a failure", stats.UnitDimensionless)
+
+	rejectedStreamsMeasure = stats.Int64(rejectedStreamsMeasureName,
+		"number of stream connections rejected by connection limits", stats.UnitDimensionless)
+	queuedStreamsMeasure = stats.Int64(queuedStreamsMeasureName,
+		"number of stream connections that waited for a slot under connection limits", stats.UnitDimensionless)
+
+	memorySheddingEventsMeasure = stats.Int64(memorySheddingEventsMeasureName,
+		"number of times that memory use rose above the high-water mark", stats.UnitDimensionless)
+
+	replicationLagMeasure = stats.Float64(replicationLagMeasureName,
+		"time from a write to the data store until it was replicated to a secondary database", stats.UnitMilliseconds)
+	replicationErrorsMeasure = stats.Int64(replicationErrorsMeasureName,
+		"number of writes that could not be replicated to a secondary database", stats.UnitDimensionless)
+
 	// For internal event exporter
 	privateConnMeasure    = stats.Int64(privateConnMeasureName, "current number of connections", stats.UnitDimensionless)
 	privateNewConnMeasure = stats.Int64(privateNewConnMeasureName, "total number of connections", stats.UnitDimensionless)
@@ -85,6 +118,9 @@ func WithGauge(ctx context.Context, userAgent string, f func(), measure Measure)
 			stats.Record(ctx, m.M(1))
 			defer stats.Record(ctx, m.M(-1))
 		}
+		ctx, _ := tag.New(ctx, measure.tags...)
+		recordInternalStreamConnection(ctx, 1)
+		defer recordInternalStreamConnection(ctx, -1)
 	}
 	f()
 }
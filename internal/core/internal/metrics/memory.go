@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+)
+
+// RecordMemorySheddingStarted records that Relay's memory use rose above the high-water mark in
+// MemoryLimitsConfig, so it has started shedding load. The context should be the Manager's OpenCensus
+// context.
+func RecordMemorySheddingStarted(ctx context.Context) {
+	stats.Record(ctx, memorySheddingEventsMeasure.M(1))
+	if im, _ := getInternalMetrics(ctx); im != nil {
+		im.memorySheddingEvents.Inc()
+		im.memoryShedding.Set(1)
+	}
+}
+
+// RecordMemorySheddingEnded records that Relay's memory use fell below the low-water mark, so it has
+// stopped shedding load. The context should be the Manager's OpenCensus context.
+func RecordMemorySheddingEnded(ctx context.Context) {
+	if im, _ := getInternalMetrics(ctx); im != nil {
+		im.memoryShedding.Set(0)
+	}
+}
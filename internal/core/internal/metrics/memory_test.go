@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInternalMetricsForMemoryShedding(t *testing.T) {
+	manager, err := NewManager(config.MetricsConfig{}, makeInternalMetricsConfig("test"), 0, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer manager.Close()
+	ctx := manager.GetOpenCensusContext()
+
+	body := scrapeInternalMetrics(t, manager)
+	assert.Contains(t, body, "test_memory_shedding 0")
+	assert.Contains(t, body, "test_memory_shedding_events_total 0")
+
+	RecordMemorySheddingStarted(ctx)
+	body = scrapeInternalMetrics(t, manager)
+	assert.Contains(t, body, "test_memory_shedding 1")
+	assert.Contains(t, body, "test_memory_shedding_events_total 1")
+
+	RecordMemorySheddingEnded(ctx)
+	RecordMemorySheddingStarted(ctx)
+	RecordMemorySheddingEnded(ctx)
+	body = scrapeInternalMetrics(t, manager)
+	assert.Contains(t, body, "test_memory_shedding 0")
+	assert.Contains(t, body, "test_memory_shedding_events_total 2")
+}
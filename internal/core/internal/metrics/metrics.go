@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -32,6 +33,7 @@ type Manager struct {
 	openCensusCtx  context.Context
 	metricsRelayID string
 	exporters      exportersSet
+	internals      *internalMetrics
 	environments   []*EnvironmentManager
 	flushInterval  time.Duration
 	loggers        ldlog.Loggers
@@ -48,8 +50,12 @@ type EnvironmentManager struct {
 }
 
 // NewManager creates a Manager instance.
+//
+// If internalMetricsConfig.Port is set, the Manager also collects the metrics that are served by
+// InternalMetricsHandler.
 func NewManager(
 	metricsConfig config.MetricsConfig,
+	internalMetricsConfig config.InternalMetricsConfig,
 	flushInterval time.Duration,
 	loggers ldlog.Loggers,
 ) (*Manager, error) {
@@ -75,10 +81,17 @@ func NewManager(
 
 	ctx, _ := tag.New(context.Background(), tag.Insert(relayIDTagKey, metricsRelayID))
 
+	var internals *internalMetrics
+	if internalMetricsConfig.Port.IsDefined() {
+		internals = newInternalMetrics(internalMetricsConfig)
+		ctx = context.WithValue(ctx, internalMetricsContextKey{}, internals)
+	}
+
 	m := &Manager{
 		openCensusCtx:  ctx,
 		metricsRelayID: metricsRelayID,
 		exporters:      exporters,
+		internals:      internals,
 		flushInterval:  flushInterval,
 		loggers:        loggers,
 	}
@@ -108,15 +121,20 @@ func (m *Manager) Close() {
 }
 
 // AddEnvironment creates a new EnvironmentManager with its own OpenCensus context that includes
-// a tag for the environment name, and registers its exporter.
-func (m *Manager) AddEnvironment(envName string, publisher events.EventPublisher) (*EnvironmentManager, error) {
+// a tag for the environment name, and for the tenant if the environment belongs to one, and registers
+// its exporter.
+func (m *Manager) AddEnvironment(envName, tenant string, publisher events.EventPublisher) (*EnvironmentManager, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 	if m.closed {
 		return nil, errAddEnvironmentAfterClosed
 	}
 
-	ctx, _ := tag.New(m.openCensusCtx, tag.Insert(envNameTagKey, sanitizeTagValue(envName)))
+	mutators := []tag.Mutator{tag.Insert(envNameTagKey, sanitizeTagValue(envName))}
+	if tenant != "" {
+		mutators = append(mutators, tag.Insert(tenantTagKey, sanitizeTagValue(tenant)))
+	}
+	ctx, _ := tag.New(m.openCensusCtx, mutators...)
 
 	var eventsExporter *openCensusEventsExporter
 	if publisher != nil {
@@ -147,9 +165,29 @@ func (m *Manager) RemoveEnvironment(em *EnvironmentManager) {
 
 	if found {
 		em.close()
+		if m.internals != nil {
+			envName, _ := tag.FromContext(em.openCensusCtx).Value(envNameTagKey)
+			m.internals.removeEnvironment(envName)
+		}
 	}
 }
 
+// InternalMetricsHandler returns the HTTP handler for the internal metrics endpoint, which serves
+// Prometheus metrics at InternalMetricsPath. It returns nil if the internal metrics endpoint was not
+// enabled in the configuration.
+func (m *Manager) InternalMetricsHandler() http.Handler {
+	if m.internals == nil {
+		return nil
+	}
+	return m.internals.handler()
+}
+
+// GetOpenCensusContext returns the Context for OpenCensus operations that are not specific to any
+// environment.
+func (m *Manager) GetOpenCensusContext() context.Context {
+	return m.openCensusCtx
+}
+
 // GetOpenCensusContext returns the Context for this EnvironmentManager's OpenCensus operations.
 func (em *EnvironmentManager) GetOpenCensusContext() context.Context {
 	return em.openCensusCtx
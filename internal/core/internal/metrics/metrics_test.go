@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 	"go.opencensus.io/trace"
 )
 
@@ -34,11 +35,11 @@ func (m measureAndPlatform) getExpectedTagsMap(relayID string, envName string, u
 }
 
 func TestAddEnvironmentWithoutEventPublisher(t *testing.T) {
-	manager, err := NewManager(config.MetricsConfig{}, 0, ldlog.NewDisabledLoggers())
+	manager, err := NewManager(config.MetricsConfig{}, config.InternalMetricsConfig{}, 0, ldlog.NewDisabledLoggers())
 	require.NoError(t, err)
 	defer manager.Close()
 
-	env, err := manager.AddEnvironment("name", nil)
+	env, err := manager.AddEnvironment("name", "", nil)
 
 	assert.NoError(t, err)
 	require.NotNil(t, env)
@@ -50,11 +51,11 @@ func TestAddEnvironmentWithEventPublisher(t *testing.T) {
 	view.SetReportingPeriod(testReportingPeriod)
 	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
 
-	manager, err := NewManager(config.MetricsConfig{}, 0, ldlog.NewDisabledLoggers())
+	manager, err := NewManager(config.MetricsConfig{}, config.InternalMetricsConfig{}, 0, ldlog.NewDisabledLoggers())
 	require.NoError(t, err)
 	defer manager.Close()
 
-	env, err := manager.AddEnvironment("name", publisher)
+	env, err := manager.AddEnvironment("name", "", publisher)
 
 	assert.NoError(t, err)
 	require.NotNil(t, env)
@@ -73,21 +74,38 @@ func TestAddEnvironmentWithEventPublisher(t *testing.T) {
 	}, time.Second, time.Millisecond*10)
 }
 
+func TestAddEnvironmentWithTenant(t *testing.T) {
+	manager, err := NewManager(config.MetricsConfig{}, config.InternalMetricsConfig{}, 0, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer manager.Close()
+
+	env, err := manager.AddEnvironment("name", "acme", nil)
+	require.NoError(t, err)
+	tenant, ok := tag.FromContext(env.GetOpenCensusContext()).Value(tenantTagKey)
+	assert.True(t, ok)
+	assert.Equal(t, "acme", tenant)
+
+	env, err = manager.AddEnvironment("name", "", nil)
+	require.NoError(t, err)
+	_, ok = tag.FromContext(env.GetOpenCensusContext()).Value(tenantTagKey)
+	assert.False(t, ok)
+}
+
 func TestAddEnvironmentAfterManagerClosed(t *testing.T) {
-	manager, err := NewManager(config.MetricsConfig{}, 0, ldlog.NewDisabledLoggers())
+	manager, err := NewManager(config.MetricsConfig{}, config.InternalMetricsConfig{}, 0, ldlog.NewDisabledLoggers())
 	require.NoError(t, err)
 	manager.Close()
-	env, err := manager.AddEnvironment("name", nil)
+	env, err := manager.AddEnvironment("name", "", nil)
 	assert.Nil(t, env)
 	assert.Error(t, err)
 }
 
 func TestRemoveEnvironment(t *testing.T) {
-	manager, err := NewManager(config.MetricsConfig{}, 0, ldlog.NewDisabledLoggers())
+	manager, err := NewManager(config.MetricsConfig{}, config.InternalMetricsConfig{}, 0, ldlog.NewDisabledLoggers())
 	require.NoError(t, err)
 	defer manager.Close()
 
-	env, err := manager.AddEnvironment("name", nil)
+	env, err := manager.AddEnvironment("name", "", nil)
 	require.NoError(t, err)
 	require.NotNil(t, env)
 
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/tracing"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+var openTelemetryExporterType exporterType = openTelemetryExporterTypeImpl{} //nolint:gochecknoglobals
+
+type openTelemetryExporterTypeImpl struct{}
+
+// openTelemetryExporterImpl is not an OpenCensus exporter, since the OpenTelemetry spans are separate
+// from the OpenCensus ones; but it has the same lifecycle, so it is managed in the same way.
+type openTelemetryExporterImpl struct {
+	tracer *tracing.Tracer
+}
+
+func (o openTelemetryExporterTypeImpl) getName() string {
+	return "OpenTelemetry"
+}
+
+func (o openTelemetryExporterTypeImpl) createExporterIfEnabled(
+	mc config.MetricsConfig,
+	loggers ldlog.Loggers,
+) (exporter, error) {
+	if !mc.OpenTelemetry.Enabled {
+		return nil, nil
+	}
+	return &openTelemetryExporterImpl{tracer: tracing.NewTracer(mc.OpenTelemetry, loggers)}, nil
+}
+
+func (o *openTelemetryExporterImpl) register() error {
+	tracing.SetTracer(o.tracer)
+	return nil
+}
+
+func (o *openTelemetryExporterImpl) close() error {
+	tracing.SetTracer(nil)
+	o.tracer.Close()
+	return nil
+}
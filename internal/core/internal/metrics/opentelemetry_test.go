@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/tracing"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenTelemetryExporterType(t *testing.T) {
+	exporterType := openTelemetryExporterType
+
+	t.Run("name", func(t *testing.T) {
+		assert.Equal(t, "OpenTelemetry", exporterType.getName())
+	})
+
+	t.Run("included in allExporterTypes", func(t *testing.T) {
+		assert.Contains(t, allExporterTypes(), exporterType)
+	})
+
+	t.Run("does not create exporter if OpenTelemetry is disabled", func(t *testing.T) {
+		var mc config.MetricsConfig
+		e, err := exporterType.createExporterIfEnabled(mc, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		assert.Nil(t, e)
+	})
+
+	t.Run("installs tracer while registered", func(t *testing.T) {
+		var mc config.MetricsConfig
+		mc.OpenTelemetry.Enabled = true
+		e, err := exporterType.createExporterIfEnabled(mc, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		require.NotNil(t, e)
+
+		require.NoError(t, e.register())
+		_, span := tracing.StartSpan(context.Background(), "test", tracing.SpanKindInternal)
+		assert.NotNil(t, span)
+
+		require.NoError(t, e.close())
+		_, span = tracing.StartSpan(context.Background(), "test", tracing.SpanKindInternal)
+		assert.Nil(t, span)
+	})
+}
@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// RecordThrottledRequest records a request that was rejected by rate limiting. The measure is the one
+// that would have been used to count the request (such as ServerRequests), which determines the
+// platformCategory tag; byClientIP indicates whether it was the per-client-IP limit, rather than the
+// per-credential limit, that was exceeded. The context should be the environment's OpenCensus context.
+func RecordThrottledRequest(ctx context.Context, measure Measure, byClientIP bool) {
+	limit := credentialLimitTagValue
+	if byClientIP {
+		limit = clientIPLimitTagValue
+	}
+	tags := append([]tag.Mutator{tag.Upsert(limitTagKey, limit)}, measure.tags...)
+	_ = stats.RecordWithTags(ctx, tags, throttledRequestsMeasure.M(1))
+}
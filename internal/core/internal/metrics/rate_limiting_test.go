@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+)
+
+func TestRecordThrottledRequest(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordThrottledRequest(p.env.GetOpenCensusContext(), ServerRequests, false)
+		RecordThrottledRequest(p.env.GetOpenCensusContext(), ServerRequests, false)
+		RecordThrottledRequest(p.env.GetOpenCensusContext(), MobileRequests, true)
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(throttledRequestsView.Name, st.TestMetricsRow{
+				Tags:  map[string]string{"env": p.envName, "platformCategory": serverTagValue, "limit": credentialLimitTagValue},
+				Count: 2,
+			}) && d.HasRow(throttledRequestsView.Name, st.TestMetricsRow{
+				Tags:  map[string]string{"env": p.envName, "platformCategory": mobileTagValue, "limit": clientIPLimitTagValue},
+				Count: 1,
+			})
+		})
+	})
+}
@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// RecordReplication records a write that was replicated to a secondary database, or that failed to be.
+// The lag is the time since the write was made to the environment's own data store, and is only recorded
+// if the write succeeded. The context should be the environment's OpenCensus context.
+func RecordReplication(ctx context.Context, database string, lag time.Duration, err error) {
+	tags := []tag.Mutator{tag.Upsert(databaseTagKey, sanitizeTagValue(database))}
+	if err != nil {
+		_ = stats.RecordWithTags(ctx, tags, replicationErrorsMeasure.M(1))
+		return
+	}
+	_ = stats.RecordWithTags(ctx, tags, replicationLagMeasure.M(float64(lag)/float64(time.Millisecond)))
+}
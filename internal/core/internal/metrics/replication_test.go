@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+)
+
+func TestRecordReplication(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		expectedTags := map[string]string{"env": p.envName, "database": "euwest"}
+
+		RecordReplication(p.env.GetOpenCensusContext(), "euwest", time.Millisecond*20, nil)
+		RecordReplication(p.env.GetOpenCensusContext(), "euwest", time.Millisecond*30, nil)
+		RecordReplication(p.env.GetOpenCensusContext(), "euwest", 0, errors.New("sorry"))
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(replicationLagView.Name, st.TestMetricsRow{Tags: expectedTags, Count: 2}) &&
+				d.HasRow(replicationErrorsView.Name, st.TestMetricsRow{Tags: expectedTags, Count: 1})
+		})
+	})
+}
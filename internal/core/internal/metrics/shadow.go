@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// ShadowResult is the outcome of comparing a response with the one from the shadow Relay instance.
+type ShadowResult string
+
+const (
+	// ShadowMatch means the shadow instance returned the same status and an equivalent body.
+	ShadowMatch ShadowResult = "match"
+
+	// ShadowMismatch means the shadow instance returned a different status or body.
+	ShadowMismatch ShadowResult = "mismatch"
+
+	// ShadowError means the request to the shadow instance failed.
+	ShadowError ShadowResult = "error"
+)
+
+// RecordShadowComparison records the result of comparing a response with the one from the shadow Relay
+// instance. The context should be the environment's OpenCensus context.
+func RecordShadowComparison(ctx context.Context, result ShadowResult) {
+	_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(resultTagKey, string(result))},
+		shadowComparisonsMeasure.M(1))
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+)
+
+func TestRecordShadowComparison(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordShadowComparison(p.env.GetOpenCensusContext(), ShadowMatch)
+		RecordShadowComparison(p.env.GetOpenCensusContext(), ShadowMatch)
+		RecordShadowComparison(p.env.GetOpenCensusContext(), ShadowMismatch)
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(shadowComparisonsView.Name, st.TestMetricsRow{
+				Tags:  map[string]string{"env": p.envName, "result": string(ShadowMatch)},
+				Count: 2,
+			}) && d.HasRow(shadowComparisonsView.Name, st.TestMetricsRow{
+				Tags:  map[string]string{"env": p.envName, "result": string(ShadowMismatch)},
+				Count: 1,
+			})
+		})
+	})
+}
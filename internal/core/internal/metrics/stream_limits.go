@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// RecordRejectedStream records a stream connection that was rejected because of a limit on concurrent
+// stream connections. The measure is the one that would have been used to count the request (such as
+// ServerRequests), which determines the platformCategory tag; global indicates whether it was the limit
+// for all environments, rather than the per-environment limit, that was reached. The context should be
+// the environment's OpenCensus context.
+func RecordRejectedStream(ctx context.Context, measure Measure, global bool) {
+	recordStreamLimit(ctx, rejectedStreamsMeasure, measure, global)
+}
+
+// RecordMemoryRejectedStream records a stream connection that was rejected because Relay's memory use
+// was above the high-water mark in MemoryLimitsConfig. The parameters are the same as for
+// RecordRejectedStream, except that there is no distinction between global and per-environment limits.
+func RecordMemoryRejectedStream(ctx context.Context, measure Measure) {
+	tags := append([]tag.Mutator{tag.Upsert(limitTagKey, memoryLimitTagValue)}, measure.tags...)
+	_ = stats.RecordWithTags(ctx, tags, rejectedStreamsMeasure.M(1))
+}
+
+// RecordQueuedStream records a stream connection that had to wait for another one to end, because of a
+// limit on concurrent stream connections. The parameters are the same as for RecordRejectedStream.
+func RecordQueuedStream(ctx context.Context, measure Measure, global bool) {
+	recordStreamLimit(ctx, queuedStreamsMeasure, measure, global)
+}
+
+func recordStreamLimit(ctx context.Context, m *stats.Int64Measure, measure Measure, global bool) {
+	limit := envLimitTagValue
+	if global {
+		limit = globalLimitTagValue
+	}
+	tags := append([]tag.Mutator{tag.Upsert(limitTagKey, limit)}, measure.tags...)
+	_ = stats.RecordWithTags(ctx, tags, m.M(1))
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+)
+
+func TestRecordRejectedStream(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordRejectedStream(p.env.GetOpenCensusContext(), ServerRequests, false)
+		RecordRejectedStream(p.env.GetOpenCensusContext(), ServerRequests, false)
+		RecordRejectedStream(p.env.GetOpenCensusContext(), BrowserRequests, true)
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(rejectedStreamsView.Name, st.TestMetricsRow{
+				Tags:  map[string]string{"env": p.envName, "platformCategory": serverTagValue, "limit": envLimitTagValue},
+				Count: 2,
+			}) && d.HasRow(rejectedStreamsView.Name, st.TestMetricsRow{
+				Tags:  map[string]string{"env": p.envName, "platformCategory": browserTagValue, "limit": globalLimitTagValue},
+				Count: 1,
+			})
+		})
+	})
+}
+
+func TestRecordQueuedStream(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordQueuedStream(p.env.GetOpenCensusContext(), MobileRequests, true)
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(queuedStreamsView.Name, st.TestMetricsRow{
+				Tags:  map[string]string{"env": p.envName, "platformCategory": mobileTagValue, "limit": globalLimitTagValue},
+				Count: 1,
+			})
+		})
+	})
+}
+
+func TestRecordMemoryRejectedStream(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordMemoryRejectedStream(p.env.GetOpenCensusContext(), ServerRequests)
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(rejectedStreamsView.Name, st.TestMetricsRow{
+				Tags:  map[string]string{"env": p.envName, "platformCategory": serverTagValue, "limit": memoryLimitTagValue},
+				Count: 1,
+			})
+		})
+	})
+}
@@ -33,7 +33,7 @@ func testWithExporter(t *testing.T, action func(testWithExporterParams)) {
 	mockLog := ldlogtest.NewMockLog()
 	defer mockLog.DumpIfTestFailed(t)
 
-	manager, err := NewManager(config.MetricsConfig{}, time.Millisecond*10, mockLog.Loggers)
+	manager, err := NewManager(config.MetricsConfig{}, config.InternalMetricsConfig{}, time.Millisecond*10, mockLog.Loggers)
 	require.NoError(t, err)
 	defer manager.Close()
 
@@ -41,7 +41,7 @@ func testWithExporter(t *testing.T, action func(testWithExporterParams)) {
 	// environment name to isolate the data from this particular test.
 	envName := "env-" + uuid.New()
 
-	env, err := manager.AddEnvironment(envName, nil)
+	env, err := manager.AddEnvironment(envName, "", nil)
 	require.NoError(t, err)
 
 	exporter := st.NewTestMetricsExporter()
@@ -0,0 +1,13 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+)
+
+// RecordUpstreamStreamReconnect records that the stream connection to LaunchDarkly failed and will be
+// retried. The context should be the environment's OpenCensus context.
+func RecordUpstreamStreamReconnect(ctx context.Context) {
+	stats.Record(ctx, upstreamStreamReconnectsMeasure.M(1))
+}
@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+)
+
+func TestRecordUpstreamStreamReconnect(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordUpstreamStreamReconnect(p.env.GetOpenCensusContext())
+		RecordUpstreamStreamReconnect(p.env.GetOpenCensusContext())
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(upstreamStreamReconnectsView.Name, st.TestMetricsRow{
+				Tags:  map[string]string{"env": p.envName},
+				Count: 2,
+			})
+		})
+	})
+}
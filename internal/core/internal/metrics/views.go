@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 )
 
 var (
@@ -22,6 +23,70 @@ var (
 		Aggregation: view.Count(),
 		TagKeys:     append(publicTags, routeTagKey, methodTagKey),
 	}
+	bigSegmentStoreLatencyView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     bigSegmentStoreLatencyMeasure,
+		Aggregation: view.Distribution(1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000),
+		TagKeys:     []tag.Key{envNameTagKey, operationTagKey},
+	}
+	bigSegmentStoreErrorsView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     bigSegmentStoreErrorsMeasure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{envNameTagKey, operationTagKey},
+	}
+	bigSegmentLookupsView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     bigSegmentLookupsMeasure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{envNameTagKey},
+	}
+	bigSegmentStoreStalenessView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     bigSegmentStoreStalenessMeasure,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{envNameTagKey},
+	}
+	bigSegmentQueriesRejectedView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     bigSegmentQueriesRejectedMeasure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{envNameTagKey},
+	}
+	throttledRequestsView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     throttledRequestsMeasure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{envNameTagKey, platformCategoryTagKey, limitTagKey},
+	}
+	shadowComparisonsView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     shadowComparisonsMeasure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{envNameTagKey, resultTagKey},
+	}
+	upstreamStreamReconnectsView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     upstreamStreamReconnectsMeasure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{envNameTagKey},
+	}
+	rejectedStreamsView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     rejectedStreamsMeasure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{envNameTagKey, platformCategoryTagKey, limitTagKey},
+	}
+	queuedStreamsView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     queuedStreamsMeasure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{envNameTagKey, platformCategoryTagKey, limitTagKey},
+	}
+	memorySheddingEventsView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     memorySheddingEventsMeasure,
+		Aggregation: view.Count(),
+	}
+	replicationLagView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     replicationLagMeasure,
+		Aggregation: view.Distribution(1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000),
+		TagKeys:     []tag.Key{envNameTagKey, databaseTagKey},
+	}
+	replicationErrorsView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     replicationErrorsMeasure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{envNameTagKey, databaseTagKey},
+	}
 	privateConnView *view.View = &view.View{ //nolint:gochecknoglobals
 		Measure:     privateConnMeasure,
 		Aggregation: view.Sum(),
@@ -38,7 +103,12 @@ var (
 )
 
 func getPublicViews() []*view.View {
-	return []*view.View{publicConnView, publicNewConnView, requestView}
+	return []*view.View{publicConnView, publicNewConnView, requestView,
+		bigSegmentStoreLatencyView, bigSegmentStoreErrorsView, bigSegmentLookupsView, bigSegmentStoreStalenessView,
+		bigSegmentQueriesRejectedView,
+		throttledRequestsView, shadowComparisonsView, upstreamStreamReconnectsView,
+		rejectedStreamsView, queuedStreamsView, memorySheddingEventsView,
+		replicationLagView, replicationErrorsView}
 }
 
 func getPrivateViews() []*view.View {
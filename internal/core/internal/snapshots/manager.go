@@ -0,0 +1,246 @@
+package snapshots
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	"github.com/launchdarkly/ld-relay/v6/internal/filedata"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+const (
+	// snapshotTimeFormat is the format of the timestamp in each object name. It sorts in time order and
+	// contains only characters that are allowed in object names by all of the storage services.
+	snapshotTimeFormat = "20060102T150405Z"
+
+	snapshotFileSuffix  = ".tar.gz"
+	snapshotContentType = filedata.ArchiveContentType
+
+	requestTimeout = time.Minute
+
+	// defaultCheckInterval is how often the Manager checks whether any environment is due for a snapshot,
+	// which also determines how soon the first snapshot is written after an environment is initialized.
+	defaultCheckInterval = time.Second * 10
+
+	logMsgSnapshotWritten     = "Wrote flag data snapshot %s"
+	logMsgSnapshotFailed      = "Failed to write flag data snapshot for environment %q: %s"
+	logMsgSnapshotListFailed  = "Failed to list flag data snapshots for environment %q: %s"
+	logMsgSnapshotNoEnvID     = "Not writing flag data snapshots for environment %q because it has no environment ID"
+	logMsgSnapshotPruned      = "Deleted old flag data snapshot %s"
+	logMsgSnapshotPruneFailed = "Failed to delete old flag data snapshot %s: %s"
+)
+
+// Manager periodically writes a snapshot of each environment's flag data to object storage, and deletes
+// snapshots that are older than the retention policy allows.
+type Manager struct {
+	store           objectStore
+	prefix          string
+	interval        time.Duration
+	maxAge          time.Duration
+	maxCount        int
+	getEnvironments func() []relayenv.EnvContext
+	lastWritten     map[config.EnvironmentID]time.Time
+	warnedNoEnvID   map[relayenv.EnvContext]bool
+	loggers         ldlog.Loggers
+	closeCh         chan struct{}
+	doneCh          chan struct{}
+	closeOnce       sync.Once
+}
+
+type snapshotObject struct {
+	key  string
+	time time.Time
+}
+
+// NewManager creates a Manager and starts its background task, or returns nil if snapshots are not
+// enabled. The getEnvironments function is called each time the Manager checks for environments that
+// are due for a snapshot, so environments that are added later are included.
+func NewManager(
+	sc config.SnapshotsConfig,
+	httpClient *http.Client,
+	getEnvironments func() []relayenv.EnvContext,
+	loggers ldlog.Loggers,
+) (*Manager, error) {
+	if sc.Type == "" {
+		return nil, nil
+	}
+	store, err := newObjectStore(sc, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	m := newManagerWithStore(sc, store, getEnvironments, loggers)
+	go m.run(defaultCheckInterval)
+	loggers.Infof("Writing flag data snapshots to %s bucket %q every %s", sc.Type, sc.Bucket, m.interval)
+	return m, nil
+}
+
+func newManagerWithStore(
+	sc config.SnapshotsConfig,
+	store objectStore,
+	getEnvironments func() []relayenv.EnvContext,
+	loggers ldlog.Loggers,
+) *Manager {
+	return &Manager{
+		store:           store,
+		prefix:          sc.Prefix,
+		interval:        sc.Interval.GetOrElse(config.DefaultSnapshotInterval),
+		maxAge:          sc.MaxAge.GetOrElse(0),
+		maxCount:        sc.MaxCount.GetOrElse(0),
+		getEnvironments: getEnvironments,
+		lastWritten:     make(map[config.EnvironmentID]time.Time),
+		warnedNoEnvID:   make(map[relayenv.EnvContext]bool),
+		loggers:         loggers,
+		closeCh:         make(chan struct{}),
+		doneCh:          make(chan struct{}),
+	}
+}
+
+// Close stops the background task, waiting for any snapshot that is being written to finish.
+func (m *Manager) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.closeCh)
+	})
+	<-m.doneCh
+	return nil
+}
+
+func (m *Manager) run(checkInterval time.Duration) {
+	defer close(m.doneCh)
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		m.writeDueSnapshots(time.Now())
+		select {
+		case <-m.closeCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) writeDueSnapshots(now time.Time) {
+	for _, env := range m.getEnvironments() {
+		select {
+		case <-m.closeCh:
+			return
+		default:
+		}
+		envName := env.GetIdentifiers().GetDisplayName()
+		envID := getEnvironmentID(env)
+		if envID == "" {
+			if !m.warnedNoEnvID[env] {
+				m.loggers.Warnf(logMsgSnapshotNoEnvID, envName)
+				m.warnedNoEnvID[env] = true
+			}
+			continue
+		}
+		last, known := m.lastWritten[envID]
+		if !known {
+			// After a restart, continue the existing schedule rather than writing a new snapshot right away.
+			if objects, err := m.listSnapshots(envID); err != nil {
+				m.loggers.Warnf(logMsgSnapshotListFailed, envName, err)
+			} else if len(objects) != 0 {
+				last = objects[0].time
+				m.lastWritten[envID] = last
+			}
+		}
+		if !last.IsZero() && now.Sub(last) < m.interval {
+			continue
+		}
+		snapshot, err := filedata.MakeEnvironmentSnapshot(env)
+		if err == filedata.ErrSnapshotNotInitialized {
+			continue // we'll try again next time
+		}
+		if err == nil {
+			err = m.write(envID, snapshot, now)
+		}
+		if err != nil {
+			m.loggers.Warnf(logMsgSnapshotFailed, envName, err)
+			continue
+		}
+		m.lastWritten[envID] = now
+		m.prune(envID, now)
+	}
+}
+
+func (m *Manager) write(envID config.EnvironmentID, snapshot filedata.EnvironmentSnapshot, now time.Time) error {
+	var buf bytes.Buffer
+	if err := filedata.WriteArchive(&buf, []filedata.EnvironmentSnapshot{snapshot}); err != nil {
+		return err
+	}
+	key := m.envPrefix(envID) + now.UTC().Format(snapshotTimeFormat) + snapshotFileSuffix
+	if err := m.store.put(key, buf.Bytes()); err != nil {
+		return err
+	}
+	m.loggers.Infof(logMsgSnapshotWritten, key)
+	return nil
+}
+
+// prune deletes the snapshots that are too old or too many, always keeping the newest one so that there
+// is something to recover from even if Relay has not been able to write snapshots for a while.
+func (m *Manager) prune(envID config.EnvironmentID, now time.Time) {
+	if m.maxAge <= 0 && m.maxCount <= 0 {
+		return
+	}
+	objects, err := m.listSnapshots(envID)
+	if err != nil {
+		m.loggers.Warnf(logMsgSnapshotListFailed, envID, err)
+		return
+	}
+	for i, obj := range objects {
+		if i == 0 {
+			continue
+		}
+		if (m.maxCount > 0 && i >= m.maxCount) || (m.maxAge > 0 && now.Sub(obj.time) > m.maxAge) {
+			if err := m.store.delete(obj.key); err != nil {
+				m.loggers.Warnf(logMsgSnapshotPruneFailed, obj.key, err)
+			} else {
+				m.loggers.Debugf(logMsgSnapshotPruned, obj.key)
+			}
+		}
+	}
+}
+
+// listSnapshots returns the environment's snapshots, newest first. Objects whose names are not in the
+// format that we write are ignored, so that they are never deleted.
+func (m *Manager) listSnapshots(envID config.EnvironmentID) ([]snapshotObject, error) {
+	prefix := m.envPrefix(envID)
+	keys, err := m.store.list(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var objects []snapshotObject
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, prefix)
+		if !strings.HasSuffix(name, snapshotFileSuffix) {
+			continue
+		}
+		t, err := time.Parse(snapshotTimeFormat, strings.TrimSuffix(name, snapshotFileSuffix))
+		if err != nil {
+			continue
+		}
+		objects = append(objects, snapshotObject{key: key, time: t})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].time.After(objects[j].time) })
+	return objects, nil
+}
+
+func (m *Manager) envPrefix(envID config.EnvironmentID) string {
+	return m.prefix + string(envID) + "/"
+}
+
+func getEnvironmentID(env relayenv.EnvContext) config.EnvironmentID {
+	for _, c := range env.GetCredentials() {
+		if id, ok := c.(config.EnvironmentID); ok {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,210 @@
+package snapshots
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTime = time.Date(2021, time.October, 14, 12, 30, 0, 0, time.UTC) //nolint:gochecknoglobals
+
+type fakeObjectStore struct {
+	objects map[string][]byte
+	putErr  error
+	lock    sync.Mutex
+}
+
+func newFakeObjectStore(keys ...string) *fakeObjectStore {
+	s := &fakeObjectStore{objects: make(map[string][]byte)}
+	for _, key := range keys {
+		s.objects[key] = []byte("old")
+	}
+	return s
+}
+
+func (s *fakeObjectStore) put(key string, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.putErr != nil {
+		return s.putErr
+	}
+	s.objects[key] = data
+	return nil
+}
+
+func (s *fakeObjectStore) list(prefix string) ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *fakeObjectStore) delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *fakeObjectStore) keys() []string {
+	keys, _ := s.list("")
+	sort.Strings(keys)
+	return keys
+}
+
+func makeTestEnv(t *testing.T, envID config.EnvironmentID, initialized bool) relayenv.EnvContext {
+	readyCh := make(chan relayenv.EnvContext, 1)
+	env, err := relayenv.NewEnvContext(relayenv.EnvContextImplParams{
+		Identifiers:      relayenv.EnvIdentifiers{ConfiguredName: "env-" + string(envID)},
+		EnvConfig:        config.EnvConfig{SDKKey: config.SDKKey("sdk-key"), EnvID: envID},
+		ClientFactory:    testclient.FakeLDClientFactory(true),
+		DataStoreFactory: sharedtest.ExistingDataStoreFactory{Instance: sharedtest.MakeStoreWithData(initialized)},
+		Loggers:          ldlog.NewDisabledLoggers(),
+	}, readyCh)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = env.Close() })
+	<-readyCh
+	return env
+}
+
+func makeTestManager(sc config.SnapshotsConfig, store objectStore, mockLog *ldlogtest.MockLog, envs ...relayenv.EnvContext) *Manager {
+	return newManagerWithStore(sc, store, func() []relayenv.EnvContext { return envs }, mockLog.Loggers)
+}
+
+func snapshotKey(prefix string, envID config.EnvironmentID, t time.Time) string {
+	return prefix + string(envID) + "/" + t.UTC().Format(snapshotTimeFormat) + snapshotFileSuffix
+}
+
+func TestManagerWritesSnapshotOfEachEnvironment(t *testing.T) {
+	store := newFakeObjectStore()
+	mockLog := ldlogtest.NewMockLog()
+	m := makeTestManager(config.SnapshotsConfig{Prefix: "relay/"}, store, mockLog,
+		makeTestEnv(t, "env1", true), makeTestEnv(t, "env2", true))
+
+	m.writeDueSnapshots(testTime)
+
+	assert.Equal(t, []string{snapshotKey("relay/", "env1", testTime), snapshotKey("relay/", "env2", testTime)}, store.keys())
+	data := store.objects[snapshotKey("relay/", "env1", testTime)]
+	require.True(t, len(data) > 2)
+	assert.Equal(t, []byte{0x1f, 0x8b}, data[:2]) // gzip header
+}
+
+func TestManagerSkipsEnvironmentWithoutEnvIDOrData(t *testing.T) {
+	store := newFakeObjectStore()
+	mockLog := ldlogtest.NewMockLog()
+	m := makeTestManager(config.SnapshotsConfig{}, store, mockLog,
+		makeTestEnv(t, "", true), makeTestEnv(t, "env2", false))
+
+	m.writeDueSnapshots(testTime)
+	m.writeDueSnapshots(testTime.Add(time.Hour))
+
+	assert.Len(t, store.keys(), 0)
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 1)
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "has no environment ID")
+}
+
+func TestManagerWritesSnapshotsAtInterval(t *testing.T) {
+	store := newFakeObjectStore()
+	mockLog := ldlogtest.NewMockLog()
+	m := makeTestManager(config.SnapshotsConfig{Interval: ct.NewOptDuration(time.Minute * 30)}, store, mockLog,
+		makeTestEnv(t, "env1", true))
+
+	m.writeDueSnapshots(testTime)
+	m.writeDueSnapshots(testTime.Add(time.Minute * 29))
+	m.writeDueSnapshots(testTime.Add(time.Minute * 30))
+
+	assert.Equal(t, []string{
+		snapshotKey("", "env1", testTime),
+		snapshotKey("", "env1", testTime.Add(time.Minute*30)),
+	}, store.keys())
+}
+
+func TestManagerContinuesScheduleOfExistingSnapshots(t *testing.T) {
+	existing := snapshotKey("", "env1", testTime.Add(-time.Minute*10))
+	store := newFakeObjectStore(existing)
+	mockLog := ldlogtest.NewMockLog()
+	m := makeTestManager(config.SnapshotsConfig{}, store, mockLog, makeTestEnv(t, "env1", true))
+
+	m.writeDueSnapshots(testTime)
+	assert.Equal(t, []string{existing}, store.keys())
+
+	m.writeDueSnapshots(testTime.Add(time.Minute * 50))
+	assert.Equal(t, []string{existing, snapshotKey("", "env1", testTime.Add(time.Minute*50))}, store.keys())
+}
+
+func TestManagerDeletesSnapshotsBeyondMaxCount(t *testing.T) {
+	old1, old2, old3 := snapshotKey("", "env1", testTime.Add(-time.Hour*3)), snapshotKey("", "env1", testTime.Add(-time.Hour*2)),
+		snapshotKey("", "env1", testTime.Add(-time.Hour))
+	unrelated := "env1/notes.txt"
+	store := newFakeObjectStore(old1, old2, old3, unrelated)
+	mockLog := ldlogtest.NewMockLog()
+	maxCount, _ := ct.NewOptIntGreaterThanZero(2)
+	m := makeTestManager(config.SnapshotsConfig{MaxCount: maxCount}, store, mockLog,
+		makeTestEnv(t, "env1", true))
+
+	m.writeDueSnapshots(testTime)
+
+	assert.Equal(t, []string{old3, snapshotKey("", "env1", testTime), unrelated}, store.keys())
+}
+
+func TestManagerDeletesSnapshotsBeyondMaxAge(t *testing.T) {
+	old1, old2 := snapshotKey("", "env1", testTime.Add(-time.Hour*25)), snapshotKey("", "env1", testTime.Add(-time.Hour*2))
+	store := newFakeObjectStore(old1, old2)
+	mockLog := ldlogtest.NewMockLog()
+	m := makeTestManager(config.SnapshotsConfig{MaxAge: ct.NewOptDuration(time.Hour * 24)}, store, mockLog,
+		makeTestEnv(t, "env1", true))
+
+	m.writeDueSnapshots(testTime)
+
+	assert.Equal(t, []string{old2, snapshotKey("", "env1", testTime)}, store.keys())
+}
+
+func TestManagerKeepsNewestSnapshotEvenIfTooOld(t *testing.T) {
+	old1, old2 := snapshotKey("", "env1", testTime.Add(-time.Hour*50)), snapshotKey("", "env1", testTime.Add(-time.Hour*49))
+	store := newFakeObjectStore(old1, old2)
+	store.putErr = errors.New("sorry")
+	mockLog := ldlogtest.NewMockLog()
+	m := makeTestManager(config.SnapshotsConfig{MaxAge: ct.NewOptDuration(time.Hour * 24)}, store, mockLog,
+		makeTestEnv(t, "env1", true))
+
+	m.writeDueSnapshots(testTime)
+
+	assert.Equal(t, []string{old1, old2}, store.keys())
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Failed to write flag data snapshot.*sorry")
+
+	store.putErr = nil
+	m.writeDueSnapshots(testTime.Add(time.Minute))
+
+	assert.Equal(t, []string{snapshotKey("", "env1", testTime.Add(time.Minute))}, store.keys())
+}
+
+func TestManagerCloseStopsBackgroundTask(t *testing.T) {
+	store := newFakeObjectStore()
+	mockLog := ldlogtest.NewMockLog()
+	m := makeTestManager(config.SnapshotsConfig{}, store, mockLog, makeTestEnv(t, "env1", true))
+	go m.run(time.Millisecond)
+
+	require.Eventually(t, func() bool { return len(store.keys()) == 1 }, time.Second, time.Millisecond)
+	assert.NoError(t, m.Close())
+	assert.NoError(t, m.Close())
+}
@@ -0,0 +1,6 @@
+// Package snapshots contains the internal implementation of periodic flag data snapshots, which are
+// written to object storage in the same archive format that is used by offline mode.
+//
+// This is an internal package; application code for specific Relay distributions should not need to
+// reference it directly, only the core code.
+package snapshots
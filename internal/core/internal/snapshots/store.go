@@ -0,0 +1,31 @@
+package snapshots
+
+import (
+	"net/http"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+)
+
+// objectStore is the abstraction of an object storage bucket that snapshots are written to. Each
+// implementation is responsible for its own request timeouts.
+type objectStore interface {
+	// put writes an object, replacing any existing object with the same key.
+	put(key string, data []byte) error
+
+	// list returns the keys of all objects that start with the prefix, in no particular order.
+	list(prefix string) ([]string, error)
+
+	// delete removes an object. It is not an error if the object does not exist.
+	delete(key string) error
+}
+
+func newObjectStore(sc config.SnapshotsConfig, httpClient *http.Client) (objectStore, error) {
+	switch sc.Type {
+	case config.SnapshotStoreTypeS3:
+		return newS3ObjectStore(sc)
+	case config.SnapshotStoreTypeGCS:
+		return newGCSObjectStore(sc)
+	default: // config.ValidateConfig has ensured that there are no other values
+		return newAzureObjectStore(sc, httpClient), nil
+	}
+}
@@ -0,0 +1,162 @@
+package snapshots
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+)
+
+// azureAPIVersion is the version of the Blob Storage REST API that we use. It must be supported by the
+// service version that the SAS token was issued for.
+const azureAPIVersion = "2020-04-08"
+
+// azureObjectStore uses the Blob Storage REST API directly, authenticating with a shared access
+// signature, since that is all we need and it avoids depending on the Azure SDK.
+type azureObjectStore struct {
+	httpClient   *http.Client
+	containerURL string
+	sasToken     string
+}
+
+// azureBlobList is the part of the response to a List Blobs request that we use.
+type azureBlobList struct {
+	Blobs struct {
+		Blob []struct {
+			Name string `xml:"Name"`
+		} `xml:"Blob"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func errAzureRequestFailed(method, blobURL string, status int) error {
+	return fmt.Errorf("%s request to %s failed with status %d", method, blobURL, status)
+}
+
+func newAzureObjectStore(sc config.SnapshotsConfig, httpClient *http.Client) *azureObjectStore {
+	baseURL := "https://" + sc.AzureAccount + ".blob.core.windows.net"
+	if sc.AzureURL.IsDefined() {
+		baseURL = strings.TrimSuffix(sc.AzureURL.String(), "/")
+	}
+	return &azureObjectStore{
+		httpClient:   httpClient,
+		containerURL: baseURL + "/" + url.PathEscape(sc.Bucket),
+		sasToken:     strings.TrimPrefix(sc.AzureSASToken, "?"),
+	}
+}
+
+func (s *azureObjectStore) put(key string, data []byte) error {
+	resp, err := s.do(http.MethodPut, s.blobURL(key), bytes.NewReader(data), func(req *http.Request) {
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+		req.Header.Set("Content-Type", snapshotContentType)
+	})
+	if err != nil {
+		return err
+	}
+	if resp.status != http.StatusCreated {
+		return errAzureRequestFailed(http.MethodPut, s.blobURL(key), resp.status)
+	}
+	return nil
+}
+
+func (s *azureObjectStore) list(prefix string) ([]string, error) {
+	var keys []string
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		listURL := s.containerURL + "?" + query.Encode()
+		resp, err := s.do(http.MethodGet, listURL, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.status != http.StatusOK {
+			return nil, errAzureRequestFailed(http.MethodGet, s.containerURL, resp.status)
+		}
+		var result azureBlobList
+		if err := xml.Unmarshal(resp.body, &result); err != nil {
+			return nil, err
+		}
+		for _, b := range result.Blobs.Blob {
+			keys = append(keys, b.Name)
+		}
+		if result.NextMarker == "" {
+			return keys, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+func (s *azureObjectStore) delete(key string) error {
+	resp, err := s.do(http.MethodDelete, s.blobURL(key), nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.status != http.StatusAccepted && resp.status != http.StatusNotFound {
+		return errAzureRequestFailed(http.MethodDelete, s.blobURL(key), resp.status)
+	}
+	return nil
+}
+
+func (s *azureObjectStore) blobURL(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return s.containerURL + "/" + strings.Join(parts, "/")
+}
+
+type azureResponse struct {
+	status int
+	body   []byte
+}
+
+// do sends a request with the SAS token added to the query string. The URL without the token is what
+// appears in errors, so that the token is not logged.
+func (s *azureObjectStore) do(method, rawURL string, body io.Reader, prepare func(*http.Request)) (azureResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	fullURL := rawURL
+	if s.sasToken != "" {
+		if strings.Contains(rawURL, "?") {
+			fullURL += "&" + s.sasToken
+		} else {
+			fullURL += "?" + s.sasToken
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fullURL, body)
+	if err != nil {
+		return azureResponse{}, err
+	}
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	if prepare != nil {
+		prepare(req)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		// The error from the HTTP client includes the full URL, so replace it.
+		return azureResponse{}, fmt.Errorf("%s request to %s failed: %w", method, rawURL, unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return azureResponse{}, err
+	}
+	return azureResponse{status: resp.StatusCode, body: data}, nil
+}
+
+func unwrapURLError(err error) error {
+	if e, ok := err.(*url.Error); ok {
+		return e.Err
+	}
+	return err
+}
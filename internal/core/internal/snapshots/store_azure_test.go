@@ -0,0 +1,129 @@
+package snapshots
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAzureHandler implements the small part of the Blob Storage REST API that we use. It returns one
+// blob per page when listing, to verify that all of the pages are read.
+type fakeAzureHandler struct {
+	blobs    map[string][]byte
+	sasQuery []string
+	lock     sync.Mutex
+}
+
+func (h *fakeAzureHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.sasQuery = append(h.sasQuery, r.URL.Query().Get("sig"))
+	if r.Header.Get("x-ms-version") == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/my-container/")
+	switch {
+	case r.Method == http.MethodPut && r.Header.Get("x-ms-blob-type") == "BlockBlob":
+		data, _ := ioutil.ReadAll(r.Body)
+		h.blobs[name] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet && r.URL.Path == "/my-container" && r.URL.Query().Get("comp") == "list":
+		var names []string
+		for n := range h.blobs {
+			if strings.HasPrefix(n, r.URL.Query().Get("prefix")) && n > r.URL.Query().Get("marker") {
+				names = append(names, n)
+			}
+		}
+		sort.Strings(names)
+		var result azureBlobList
+		if len(names) != 0 {
+			result.Blobs.Blob = append(result.Blobs.Blob, struct {
+				Name string `xml:"Name"`
+			}{names[0]})
+		}
+		if len(names) > 1 {
+			result.NextMarker = names[0]
+		}
+		data, _ := xml.Marshal(struct {
+			XMLName xml.Name `xml:"EnumerationResults"`
+			azureBlobList
+		}{azureBlobList: result})
+		_, _ = w.Write(data)
+	case r.Method == http.MethodDelete:
+		if _, ok := h.blobs[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(h.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestAzureObjectStore(t *testing.T) {
+	handler := &fakeAzureHandler{blobs: make(map[string][]byte)}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	azureURL, _ := ct.NewOptURLAbsoluteFromString(server.URL)
+	store := newAzureObjectStore(config.SnapshotsConfig{Bucket: "my-container", AzureURL: azureURL,
+		AzureSASToken: "?sv=2020-08-04&sig=xyz"}, server.Client())
+
+	require.NoError(t, store.put("env1/a.tar.gz", []byte("a")))
+	require.NoError(t, store.put("env1/b.tar.gz", []byte("b")))
+	require.NoError(t, store.put("env2/c.tar.gz", []byte("c")))
+	assert.Equal(t, []byte("a"), handler.blobs["env1/a.tar.gz"])
+
+	keys, err := store.list("env1/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"env1/a.tar.gz", "env1/b.tar.gz"}, keys)
+
+	require.NoError(t, store.delete("env1/a.tar.gz"))
+	require.NoError(t, store.delete("env1/nonexistent.tar.gz"))
+	keys, err = store.list("env1/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"env1/b.tar.gz"}, keys)
+
+	for _, sig := range handler.sasQuery {
+		assert.Equal(t, "xyz", sig)
+	}
+}
+
+func TestAzureObjectStoreErrorDoesNotIncludeSASToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	azureURL, _ := ct.NewOptURLAbsoluteFromString(server.URL)
+	store := newAzureObjectStore(config.SnapshotsConfig{Bucket: "my-container", AzureURL: azureURL,
+		AzureSASToken: "sig=xyz"}, server.Client())
+
+	err := store.put("env1/a.tar.gz", []byte("a"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+	assert.NotContains(t, err.Error(), "xyz")
+
+	server.Close()
+	_, err = store.list("env1/")
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "xyz")
+}
+
+func TestAzureObjectStoreDefaultURL(t *testing.T) {
+	store := newAzureObjectStore(config.SnapshotsConfig{Bucket: "my-container", AzureAccount: "myaccount"}, http.DefaultClient)
+	assert.Equal(t, "https://myaccount.blob.core.windows.net/my-container", store.containerURL)
+	assert.Equal(t, "https://myaccount.blob.core.windows.net/my-container/env%201/a.tar.gz", store.blobURL("env 1/a.tar.gz"))
+}
@@ -0,0 +1,58 @@
+package snapshots
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+type gcsObjectStore struct {
+	service *storage.Service
+	bucket  string
+}
+
+func newGCSObjectStore(sc config.SnapshotsConfig, opts ...option.ClientOption) (*gcsObjectStore, error) {
+	service, err := storage.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &gcsObjectStore{service: service, bucket: sc.Bucket}, nil
+}
+
+func (s *gcsObjectStore) put(key string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_, err := s.service.Objects.Insert(s.bucket, &storage.Object{Name: key, ContentType: snapshotContentType}).
+		Media(bytes.NewReader(data)).Context(ctx).Do()
+	return err
+}
+
+func (s *gcsObjectStore) list(prefix string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	var keys []string
+	err := s.service.Objects.List(s.bucket).Prefix(prefix).Fields("items/name", "nextPageToken").
+		Pages(ctx, func(objects *storage.Objects) error {
+			for _, obj := range objects.Items {
+				keys = append(keys, obj.Name)
+			}
+			return nil
+		})
+	return keys, err
+}
+
+func (s *gcsObjectStore) delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	err := s.service.Objects.Delete(s.bucket, key).Context(ctx).Do()
+	if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
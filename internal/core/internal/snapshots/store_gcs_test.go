@@ -0,0 +1,101 @@
+package snapshots
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"google.golang.org/api/option"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGCSHandler implements the small part of the Cloud Storage JSON API that we use. It returns one
+// object per page when listing, to verify that all of the pages are read.
+type fakeGCSHandler struct {
+	objects map[string][]byte
+	lock    sync.Mutex
+}
+
+func (h *fakeGCSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/my-bucket/o":
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		metadataPart, _ := mr.NextPart()
+		var metadata struct {
+			Name string `json:"name"`
+		}
+		_ = json.NewDecoder(metadataPart).Decode(&metadata)
+		mediaPart, _ := mr.NextPart()
+		data, _ := ioutil.ReadAll(mediaPart)
+		h.objects[metadata.Name] = data
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"name": metadata.Name})
+	case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/my-bucket/o":
+		var names []string
+		for name := range h.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) && name > r.URL.Query().Get("pageToken") {
+				names = append(names, name)
+			}
+		}
+		resp := map[string]interface{}{}
+		if len(names) != 0 {
+			first := names[0]
+			for _, n := range names {
+				if n < first {
+					first = n
+				}
+			}
+			resp["items"] = []map[string]string{{"name": first}}
+			resp["nextPageToken"] = first
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/storage/v1/b/my-bucket/o/"):
+		name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/my-bucket/o/")
+		if _, ok := h.objects[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(h.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestGCSObjectStore(t *testing.T) {
+	handler := &fakeGCSHandler{objects: make(map[string][]byte)}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	store, err := newGCSObjectStore(config.SnapshotsConfig{Bucket: "my-bucket"}, option.WithEndpoint(server.URL+"/storage/v1/"),
+		option.WithoutAuthentication(), option.WithHTTPClient(server.Client()))
+	require.NoError(t, err)
+
+	require.NoError(t, store.put("env1/a.tar.gz", []byte("a")))
+	require.NoError(t, store.put("env1/b.tar.gz", []byte("b")))
+	require.NoError(t, store.put("env2/c.tar.gz", []byte("c")))
+	assert.Equal(t, []byte("a"), handler.objects["env1/a.tar.gz"])
+
+	keys, err := store.list("env1/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"env1/a.tar.gz", "env1/b.tar.gz"}, keys)
+
+	require.NoError(t, store.delete("env1/a.tar.gz"))
+	require.NoError(t, store.delete("env1/nonexistent.tar.gz"))
+	keys, err = store.list("env1/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"env1/b.tar.gz"}, keys)
+}
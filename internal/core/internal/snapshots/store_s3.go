@@ -0,0 +1,69 @@
+package snapshots
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+type s3ObjectStore struct {
+	client s3iface.S3API
+	bucket string
+}
+
+func newS3ObjectStore(sc config.SnapshotsConfig) (*s3ObjectStore, error) {
+	awsConfig := aws.Config{}
+	if sc.S3Region != "" {
+		awsConfig.Region = aws.String(sc.S3Region)
+	}
+	if sc.S3URL.IsDefined() {
+		awsConfig.Endpoint = aws.String(sc.S3URL.String())
+		awsConfig.S3ForcePathStyle = aws.Bool(true) // local test services don't have a DNS name for each bucket
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{Config: awsConfig, SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, err
+	}
+	return &s3ObjectStore{client: s3.New(sess), bucket: sc.Bucket}, nil
+}
+
+func (s *s3ObjectStore) put(key string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(snapshotContentType),
+	})
+	return err
+}
+
+func (s *s3ObjectStore) list(prefix string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	var keys []string
+	err := s.client.ListObjectsV2PagesWithContext(ctx,
+		&s3.ListObjectsV2Input{Bucket: aws.String(s.bucket), Prefix: aws.String(prefix)},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, obj := range page.Contents {
+				keys = append(keys, aws.StringValue(obj.Key))
+			}
+			return true
+		})
+	return keys, err
+}
+
+func (s *s3ObjectStore) delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	// S3 does not report an error for deleting an object that does not exist.
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	return err
+}
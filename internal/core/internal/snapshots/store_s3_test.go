@@ -0,0 +1,77 @@
+package snapshots
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeS3Client struct {
+	s3iface.S3API
+	objects map[string][]byte
+	pages   int
+}
+
+func (c *fakeS3Client) PutObjectWithContext(_ aws.Context, input *s3.PutObjectInput, _ ...request.Option) (*s3.PutObjectOutput, error) {
+	data, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.objects[aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (c *fakeS3Client) ListObjectsV2PagesWithContext(
+	_ aws.Context,
+	input *s3.ListObjectsV2Input,
+	fn func(*s3.ListObjectsV2Output, bool) bool,
+	_ ...request.Option,
+) error {
+	// Return each object on its own page, to verify that all of the pages are read.
+	c.pages = 0
+	prefix := aws.StringValue(input.Bucket) + "/" + aws.StringValue(input.Prefix)
+	for fullKey := range c.objects {
+		if strings.HasPrefix(fullKey, prefix) {
+			c.pages++
+			key := fullKey[len(aws.StringValue(input.Bucket))+1:]
+			if !fn(&s3.ListObjectsV2Output{Contents: []*s3.Object{{Key: aws.String(key)}}}, false) {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (c *fakeS3Client) DeleteObjectWithContext(_ aws.Context, input *s3.DeleteObjectInput, _ ...request.Option) (*s3.DeleteObjectOutput, error) {
+	delete(c.objects, aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestS3ObjectStore(t *testing.T) {
+	client := &fakeS3Client{objects: make(map[string][]byte)}
+	store := &s3ObjectStore{client: client, bucket: "my-bucket"}
+
+	require.NoError(t, store.put("env1/a.tar.gz", []byte("a")))
+	require.NoError(t, store.put("env1/b.tar.gz", []byte("b")))
+	require.NoError(t, store.put("env2/c.tar.gz", []byte("c")))
+	assert.Equal(t, []byte("a"), client.objects["my-bucket/env1/a.tar.gz"])
+
+	keys, err := store.list("env1/")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"env1/a.tar.gz", "env1/b.tar.gz"}, keys)
+	assert.Equal(t, 2, client.pages)
+
+	require.NoError(t, store.delete("env1/a.tar.gz"))
+	require.NoError(t, store.delete("env1/nonexistent.tar.gz"))
+	keys, err = store.list("env1/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"env1/b.tar.gz"}, keys)
+}
@@ -0,0 +1,169 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// keySetMaxAge is how long a key set is used before it is requested again.
+	keySetMaxAge = time.Hour
+
+	// keySetMinRefreshInterval is the minimum time between requests for the key set. If a token has a key
+	// ID that is not in the key set, we request it again in case the keys have been rotated, but not more
+	// often than this, so that tokens with bogus key IDs cannot cause a flood of requests.
+	keySetMinRefreshInterval = time.Minute
+)
+
+var errKeyNotFound = errors.New("no matching key in the JWKS")
+
+func errJWKSResponse(status int) error {
+	return fmt.Errorf("JWKS request returned HTTP status %d", status)
+}
+
+// jsonWebKey is the subset of the JWK format (RFC 7517) that we use.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type keySet struct {
+	url         string
+	client      *http.Client
+	keys        map[string]crypto.PublicKey
+	lastFetched time.Time
+	now         func() time.Time
+	lock        sync.Mutex
+}
+
+func newKeySet(url string, client *http.Client) *keySet {
+	return &keySet{url: url, client: client, now: time.Now}
+}
+
+// getKey returns the public key with the specified key ID. An empty key ID matches the only key in the
+// set, if there is exactly one.
+func (s *keySet) getKey(kid string) (crypto.PublicKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	if s.keys == nil || now.Sub(s.lastFetched) >= keySetMaxAge {
+		if err := s.fetch(now); err != nil && s.keys == nil {
+			return nil, err
+		}
+	}
+	if key := s.findKey(kid); key != nil {
+		return key, nil
+	}
+	if now.Sub(s.lastFetched) >= keySetMinRefreshInterval {
+		if err := s.fetch(now); err != nil {
+			return nil, err
+		}
+		if key := s.findKey(kid); key != nil {
+			return key, nil
+		}
+	}
+	return nil, errKeyNotFound
+}
+
+func (s *keySet) findKey(kid string) crypto.PublicKey {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key
+		}
+	}
+	return s.keys[kid]
+}
+
+// fetch requests the key set. If it fails, the previous keys are kept.
+func (s *keySet) fetch(now time.Time) error {
+	s.lastFetched = now
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return errJWKSResponse(resp.StatusCode)
+	}
+	var body struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+	keys := make(map[string]crypto.PublicKey, len(body.Keys))
+	for _, jwk := range body.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of types we don't support are ignored, so that a key set can contain other keys too.
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	s.keys = keys
+	return nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,2 @@
+// Package jwtauth contains the logic for authorizing client-side and mobile SDK requests with signed JWTs.
+package jwtauth
@@ -0,0 +1,242 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+)
+
+const (
+	// TokenHeader is the request header that can contain the token.
+	TokenHeader = "X-Relay-Token"
+
+	// TokenQueryParam is the URL query parameter that can contain the token, for clients such as browser
+	// EventSource streams that cannot set request headers.
+	TokenQueryParam = "relayToken"
+
+	// clockSkewLeeway is how far the "exp" and "nbf" times can be off while still accepting the token.
+	clockSkewLeeway = time.Minute
+)
+
+var (
+	errNoToken          = errors.New("no token was provided")
+	errMalformedToken   = errors.New("token is not a valid JWT")
+	errBadSignature     = errors.New("token signature is not valid")
+	errNoExpiration     = errors.New("token has no expiration time")
+	errExpired          = errors.New("token has expired")
+	errNotYetValid      = errors.New("token is not valid yet")
+	errWrongIssuer      = errors.New("token has the wrong issuer")
+	errWrongAudience    = errors.New("token has the wrong audience")
+	errUserKeyMismatch  = errors.New("user key does not match the token")
+	errUserKeyClaimType = errors.New("user key claim in token is not a string")
+)
+
+func errUnsupportedAlgorithm(alg string) error {
+	return fmt.Errorf("unsupported token algorithm %q", alg)
+}
+
+// Claims are the claims from a token that has been verified.
+type Claims map[string]interface{}
+
+// Verifier checks the tokens in client-side and mobile SDK requests for an environment.
+type Verifier struct {
+	keys         *keySet
+	issuer       string
+	audience     string
+	userKeyClaim string
+	now          func() time.Time
+}
+
+// NewVerifier creates the Verifier for an environment. It returns nil if the environment does not
+// use JWT authorization, which is the case if it does not have a JWKS URL.
+//
+// The client is used to request the key set from the JWKS URL.
+func NewVerifier(envConfig config.EnvConfig, client *http.Client) *Verifier {
+	if !envConfig.JWTJWKSURL.IsDefined() {
+		return nil
+	}
+	return &Verifier{
+		keys:         newKeySet(envConfig.JWTJWKSURL.String(), client),
+		issuer:       envConfig.JWTIssuer,
+		audience:     envConfig.JWTAudience,
+		userKeyClaim: envConfig.JWTUserKeyClaim,
+		now:          time.Now,
+	}
+}
+
+// VerifyRequest verifies the token in a request, which can be in the TokenHeader header, optionally
+// preceded by "Bearer ", or in the TokenQueryParam query parameter. It returns the token's claims if
+// it is valid.
+func (v *Verifier) VerifyRequest(req *http.Request) (Claims, error) {
+	token := strings.TrimPrefix(req.Header.Get(TokenHeader), "Bearer ")
+	if token == "" {
+		token = req.URL.Query().Get(TokenQueryParam)
+	}
+	if token == "" {
+		return nil, errNoToken
+	}
+	return v.Verify(token)
+}
+
+// Verify checks the signature of a token, its "exp" and "nbf" times, and, if configured, its "iss"
+// and "aud" claims. It returns the token's claims if it is valid.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errMalformedToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errMalformedToken
+	}
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errMalformedToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errMalformedToken
+	}
+	key, err := v.keys.getKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// CheckUserKey returns an error if the environment requires the user key to match a claim in the token,
+// and it does not.
+func (v *Verifier) CheckUserKey(claims Claims, userKey string) error {
+	if v.userKeyClaim == "" {
+		return nil
+	}
+	value, ok := claims[v.userKeyClaim].(string)
+	if !ok {
+		return errUserKeyClaimType
+	}
+	if value != userKey {
+		return errUserKeyMismatch
+	}
+	return nil
+}
+
+func (v *Verifier) checkClaims(claims Claims) error {
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errNoExpiration
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkewLeeway)) {
+		return errExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkewLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return errNotYetValid
+	}
+	if v.issuer != "" && claims["iss"] != v.issuer {
+		return errWrongIssuer
+	}
+	if v.audience != "" && !hasAudience(claims["aud"], v.audience) {
+		return errWrongAudience
+	}
+	return nil
+}
+
+// hasAudience checks the "aud" claim, which can be either a single string or an array of strings.
+func hasAudience(aud interface{}, audience string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == audience
+	case []interface{}:
+		for _, value := range a {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	if len(alg) != 5 {
+		return errUnsupportedAlgorithm(alg)
+	}
+	var hashFunc crypto.Hash
+	var newHash func() hash.Hash
+	switch alg[2:] {
+	case "256":
+		hashFunc, newHash = crypto.SHA256, sha256.New
+	case "384":
+		hashFunc, newHash = crypto.SHA384, sha512.New384
+	case "512":
+		hashFunc, newHash = crypto.SHA512, sha512.New
+	default:
+		return errUnsupportedAlgorithm(alg)
+	}
+	h := newHash()
+	_, _ = h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	// The algorithm must match the kind of key, so that a token cannot choose a weaker way of checking
+	// the signature than the key is meant for.
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		var err error
+		switch alg[:2] {
+		case "RS":
+			err = rsa.VerifyPKCS1v15(k, hashFunc, digest, signature)
+		case "PS":
+			err = rsa.VerifyPSS(k, hashFunc, digest, signature, nil)
+		default:
+			return errUnsupportedAlgorithm(alg)
+		}
+		if err != nil {
+			return errBadSignature
+		}
+	case *ecdsa.PublicKey:
+		if alg[:2] != "ES" {
+			return errUnsupportedAlgorithm(alg)
+		}
+		// A JWS ECDSA signature is the two integers r and s, each padded to the size of the curve.
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errBadSignature
+		}
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errBadSignature
+		}
+	default:
+		return errUnsupportedAlgorithm(alg) // COVERAGE: the key set only contains RSA and EC keys
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testKeys struct {
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func makeTestKeys(t *testing.T) testKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return testKeys{rsaKey: rsaKey, ecKey: ecKey}
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func (k testKeys) jwks() []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"keys": []jsonWebKey{
+			{Kty: "RSA", Kid: "rsa-key", Use: "sig", N: encodeBigInt(k.rsaKey.N), E: encodeBigInt(big.NewInt(int64(k.rsaKey.E)))},
+			{Kty: "EC", Kid: "ec-key", Crv: "P-256", X: encodeBigInt(k.ecKey.X), Y: encodeBigInt(k.ecKey.Y)},
+			{Kty: "oct", Kid: "ignored"},
+		},
+	})
+	return data
+}
+
+func encodeSegment(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (k testKeys) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	signingInput := encodeSegment(map[string]string{"alg": alg, "kid": kid}) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsaKey, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, k.ecKey, digest[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss": "https://issuer",
+		"aud": []string{"other", "relay"},
+		"sub": "user-key",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func withTestVerifier(t *testing.T, keys testKeys, action func(v *Verifier, requestCount func() int)) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		count++
+		_, _ = w.Write(keys.jwks())
+	}))
+	defer server.Close()
+	jwksURL, err := ct.NewOptURLAbsoluteFromString(server.URL)
+	require.NoError(t, err)
+	v := NewVerifier(config.EnvConfig{
+		JWTJWKSURL:      jwksURL,
+		JWTIssuer:       "https://issuer",
+		JWTAudience:     "relay",
+		JWTUserKeyClaim: "sub",
+	}, http.DefaultClient)
+	require.NotNil(t, v)
+	action(v, func() int { return count })
+}
+
+func TestNewVerifierReturnsNilWithoutJWKSURL(t *testing.T) {
+	assert.Nil(t, NewVerifier(config.EnvConfig{JWTIssuer: "x"}, http.DefaultClient))
+}
+
+func TestVerifyValidTokens(t *testing.T) {
+	keys := makeTestKeys(t)
+	withTestVerifier(t, keys, func(v *Verifier, requestCount func() int) {
+		for _, alg := range []struct{ alg, kid string }{{"RS256", "rsa-key"}, {"ES256", "ec-key"}} {
+			t.Run(alg.alg, func(t *testing.T) {
+				claims, err := v.Verify(keys.sign(t, alg.alg, alg.kid, validClaims()))
+				require.NoError(t, err)
+				assert.Equal(t, "user-key", claims["sub"])
+			})
+		}
+		assert.Equal(t, 1, requestCount()) // the key set is cached
+	})
+}
+
+func TestVerifyInvalidTokens(t *testing.T) {
+	keys := makeTestKeys(t)
+	otherKeys := makeTestKeys(t)
+	withClaim := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	withTestVerifier(t, keys, func(v *Verifier, _ func() int) {
+		for _, p := range []struct {
+			name     string
+			token    string
+			expected error
+		}{
+			{"malformed", "not.a-token", errMalformedToken},
+			{"wrong key", otherKeys.sign(t, "RS256", "rsa-key", validClaims()), errBadSignature},
+			{"algorithm does not match key", keys.sign(t, "ES256", "rsa-key", validClaims()), errUnsupportedAlgorithm("ES256")},
+			{"unsupported algorithm", keys.sign(t, "none", "rsa-key", validClaims()), errUnsupportedAlgorithm("none")},
+			{"no expiration", keys.sign(t, "RS256", "rsa-key", withClaim("exp", nil)), errNoExpiration},
+			{"expired", keys.sign(t, "RS256", "rsa-key", withClaim("exp", time.Now().Add(-time.Hour).Unix())), errExpired},
+			{"not yet valid", keys.sign(t, "RS256", "rsa-key", withClaim("nbf", time.Now().Add(time.Hour).Unix())), errNotYetValid},
+			{"wrong issuer", keys.sign(t, "RS256", "rsa-key", withClaim("iss", "https://other")), errWrongIssuer},
+			{"wrong audience", keys.sign(t, "RS256", "rsa-key", withClaim("aud", "other")), errWrongAudience},
+		} {
+			t.Run(p.name, func(t *testing.T) {
+				_, err := v.Verify(p.token)
+				assert.Equal(t, p.expected, err)
+			})
+		}
+	})
+}
+
+func TestVerifyRefreshesKeySetForUnknownKeyID(t *testing.T) {
+	keys := makeTestKeys(t)
+	withTestVerifier(t, keys, func(v *Verifier, requestCount func() int) {
+		now := time.Now()
+		v.keys.now = func() time.Time { return now }
+
+		_, err := v.Verify(keys.sign(t, "RS256", "rsa-key", validClaims()))
+		require.NoError(t, err)
+		require.Equal(t, 1, requestCount())
+
+		// an unknown key ID doesn't cause another request until the minimum interval has passed
+		_, err = v.Verify(keys.sign(t, "RS256", "new-key", validClaims()))
+		assert.Equal(t, errKeyNotFound, err)
+		assert.Equal(t, 1, requestCount())
+
+		now = now.Add(keySetMinRefreshInterval)
+		_, err = v.Verify(keys.sign(t, "RS256", "new-key", validClaims()))
+		assert.Equal(t, errKeyNotFound, err)
+		assert.Equal(t, 2, requestCount())
+	})
+}
+
+func TestVerifyRequestGetsTokenFromHeaderOrQuery(t *testing.T) {
+	keys := makeTestKeys(t)
+	withTestVerifier(t, keys, func(v *Verifier, _ func() int) {
+		token := keys.sign(t, "RS256", "rsa-key", validClaims())
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(TokenHeader, "Bearer "+token)
+		_, err := v.VerifyRequest(req)
+		assert.NoError(t, err)
+
+		req = httptest.NewRequest("GET", "/?"+TokenQueryParam+"="+token, nil)
+		_, err = v.VerifyRequest(req)
+		assert.NoError(t, err)
+
+		_, err = v.VerifyRequest(httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, errNoToken, err)
+	})
+}
+
+func TestCheckUserKey(t *testing.T) {
+	keys := makeTestKeys(t)
+	withTestVerifier(t, keys, func(v *Verifier, _ func() int) {
+		claims := Claims{"sub": "user-key", "num": float64(1)}
+		assert.NoError(t, v.CheckUserKey(claims, "user-key"))
+		assert.Equal(t, errUserKeyMismatch, v.CheckUserKey(claims, "other-key"))
+		assert.Equal(t, errUserKeyClaimType, v.CheckUserKey(Claims{}, "user-key"))
+
+		v.userKeyClaim = ""
+		assert.NoError(t, v.CheckUserKey(claims, "any-key"))
+	})
+}
+
+func TestVerifyDoesNotAcceptTokenWithExtraSegments(t *testing.T) {
+	keys := makeTestKeys(t)
+	withTestVerifier(t, keys, func(v *Verifier, _ func() int) {
+		token := keys.sign(t, "RS256", "rsa-key", validClaims())
+		_, err := v.Verify(strings.Join([]string{token, "extra"}, "."))
+		assert.Equal(t, errMalformedToken, err)
+	})
+}
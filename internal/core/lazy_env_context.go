@@ -0,0 +1,655 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/clientcert"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/evalcache"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/events"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/jwtauth"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/pollcache"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/ratelimit"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/streams"
+	"github.com/launchdarkly/ld-relay/v6/internal/flagchange"
+	"github.com/launchdarkly/ld-relay/v6/internal/flagfilter"
+	"github.com/launchdarkly/ld-relay/v6/internal/flagoverrides"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	ldeval "gopkg.in/launchdarkly/go-server-sdk-evaluation.v1"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+)
+
+// lazyEnvMakerFunc creates the real EnvContext for a lazily initialized environment. It has the same
+// semantics as relayenv.NewEnvContext.
+type lazyEnvMakerFunc func(
+	identifiers relayenv.EnvIdentifiers,
+	envConfig config.EnvConfig,
+	readyCh chan<- relayenv.EnvContext,
+) (relayenv.EnvContext, error)
+
+// lazyEnvContext is the EnvContext that RelayCore uses for an environment when
+// MainConfig.LazyEnvironmentInit is enabled. It starts out dormant, knowing only the environment's
+// credentials and settings; the SDK client, data store, big segment synchronizer, and event forwarding
+// are created by activate(), when RelayCore receives the first request for the environment. If
+// MainConfig.EnvironmentIdleTimeout is set, it goes back to being dormant once it has had no requests
+// and no open streams for that long. A request that is using the environment holds it (see Hold), so
+// that it is not shut down while the request is still in progress.
+//
+// While the environment is dormant, the methods that would return one of those components return nil,
+// the same as for an environment that has not finished initializing.
+type lazyEnvContext struct {
+	makeEnv       lazyEnvMakerFunc
+	credentials   map[config.SDKCredential]bool // true if not deprecated
+	identifiers   relayenv.EnvIdentifiers
+	envConfig     config.EnvConfig
+	canaryRoute   *relayenv.CanaryRoute
+	jsContext     relayenv.JSClientContext
+	dataStoreInfo sdks.DataStoreEnvironmentInfo
+	loggers       ldlog.Loggers
+	idleTimeout   time.Duration
+	initTimeout   time.Duration
+	creationTime  time.Time
+	active        relayenv.EnvContext
+	readyCh       chan struct{}
+	initializing  chan struct{} // non-nil while initialize() is creating the environment
+	initErr       error
+	lastUsed      time.Time
+	inUse         int // number of requests, including streams, that are holding the environment
+	killSwitch    bool
+	idleTimer     *time.Timer
+	closed        bool
+	lock          sync.Mutex
+}
+
+func newLazyEnvContext(
+	identifiers relayenv.EnvIdentifiers,
+	envConfig config.EnvConfig,
+	allConfig config.Config,
+	jsContext relayenv.JSClientContext,
+	dataStoreInfo sdks.DataStoreEnvironmentInfo,
+	loggers ldlog.Loggers,
+	makeEnv lazyEnvMakerFunc,
+) *lazyEnvContext {
+	credentials := map[config.SDKCredential]bool{envConfig.SDKKey: true}
+	if envConfig.MobileKey != "" {
+		credentials[envConfig.MobileKey] = true
+	}
+	if envConfig.EnvID != "" {
+		credentials[envConfig.EnvID] = true
+	}
+	return &lazyEnvContext{
+		makeEnv:       makeEnv,
+		credentials:   credentials,
+		identifiers:   identifiers,
+		envConfig:     envConfig,
+		canaryRoute:   relayenv.NewCanaryRoute(envConfig, allConfig),
+		jsContext:     jsContext,
+		dataStoreInfo: dataStoreInfo,
+		loggers:       loggers,
+		idleTimeout:   allConfig.Main.EnvironmentIdleTimeout.GetOrElse(0),
+		initTimeout:   allConfig.Main.InitTimeout.GetOrElse(config.DefaultInitTimeout),
+		creationTime:  time.Now(),
+	}
+}
+
+// activate initializes the environment if it is dormant, and waits for the SDK client to be created
+// (or for the initialization timeout to elapse). It also counts as activity for the idle timer.
+func (l *lazyEnvContext) activate() {
+	l.lock.Lock()
+	if l.closed {
+		l.lock.Unlock()
+		return
+	}
+	l.lastUsed = time.Now()
+	if l.active == nil {
+		if initializing := l.initializing; initializing != nil {
+			// another request is already creating the environment
+			l.lock.Unlock()
+			<-initializing
+			l.lock.Lock()
+		} else {
+			l.initialize()
+		}
+	}
+	readyCh := l.readyCh
+	l.lock.Unlock()
+
+	if readyCh == nil {
+		return
+	}
+	timer := time.NewTimer(l.initTimeout)
+	defer timer.Stop()
+	select {
+	case <-readyCh:
+	case <-timer.C:
+	}
+}
+
+// initialize must be called while holding the lock. It releases the lock while the environment is being
+// created, so that other methods are not blocked by that; any other request that needs the environment in
+// the meantime waits on l.initializing instead.
+func (l *lazyEnvContext) initialize() {
+	identifiers, envConfig := l.identifiers, l.envConfig
+	initializing := make(chan struct{})
+	l.initializing = initializing
+	l.lock.Unlock()
+
+	l.loggers.Infof("Initializing environment %q on first request", identifiers.GetDisplayName())
+	innerReadyCh := make(chan relayenv.EnvContext, 1)
+	env, err := l.makeEnv(identifiers, envConfig, innerReadyCh)
+
+	l.lock.Lock()
+	l.initializing = nil
+	close(initializing)
+	if err != nil {
+		l.initErr = err
+		l.loggers.Errorf("Unable to initialize environment %q: %s", identifiers.GetDisplayName(), err)
+		return
+	}
+	if l.closed {
+		l.lock.Unlock()
+		_ = env.Close()
+		l.lock.Lock()
+		return
+	}
+
+	// Credentials and settings may have changed while we were not holding the lock; also, the
+	// environment was created only with the current credentials, not any deprecated ones.
+	l.syncActiveCredentials(env)
+	if l.envConfig.TTL != envConfig.TTL {
+		env.SetTTL(l.envConfig.TTL.GetOrElse(0))
+	}
+	if l.envConfig.SecureMode != envConfig.SecureMode {
+		env.SetSecureMode(l.envConfig.SecureMode)
+	}
+	if l.envConfig.LogLevel != envConfig.LogLevel {
+		env.SetLogLevel(l.envConfig.LogLevel)
+	}
+	if l.killSwitch {
+		if err := env.SetKillSwitch(true); err != nil {
+			l.loggers.Errorf("Unable to turn on kill switch for environment %q: %s", identifiers.GetDisplayName(), err)
+		}
+	}
+
+	readyCh := make(chan struct{})
+	go func() {
+		<-innerReadyCh
+		close(readyCh)
+	}()
+	l.active, l.readyCh, l.initErr = env, readyCh, nil
+
+	if l.idleTimeout > 0 {
+		l.idleTimer = time.AfterFunc(l.idleTimeout, l.checkIdle)
+	}
+}
+
+// syncActiveCredentials makes a newly created environment's credentials match l.credentials. It must be
+// called while holding the lock.
+func (l *lazyEnvContext) syncActiveCredentials(env relayenv.EnvContext) {
+	current := make(map[config.SDKCredential]bool)
+	for _, c := range env.GetCredentials() {
+		current[c] = true
+	}
+	for _, c := range env.GetDeprecatedCredentials() {
+		current[c] = false
+	}
+	for c, nonDeprecated := range l.credentials {
+		currentNonDeprecated, found := current[c]
+		if !found {
+			env.AddCredential(c)
+			currentNonDeprecated = true
+		}
+		if currentNonDeprecated && !nonDeprecated {
+			env.DeprecateCredential(c)
+		}
+	}
+	for c := range current {
+		if _, found := l.credentials[c]; !found {
+			env.RemoveCredential(c)
+		}
+	}
+}
+
+// checkIdle is called by the idle timer. It puts the environment back into the dormant state if it has
+// not been used for the idle timeout; otherwise it sets the timer to check again later.
+func (l *lazyEnvContext) checkIdle() {
+	l.lock.Lock()
+	if l.closed || l.active == nil {
+		l.lock.Unlock()
+		return
+	}
+	idleFor := time.Since(l.lastUsed)
+	if l.inUse > 0 || idleFor < l.idleTimeout {
+		wait := l.idleTimeout - idleFor
+		if l.inUse > 0 {
+			wait = l.idleTimeout
+		}
+		l.idleTimer = time.AfterFunc(wait, l.checkIdle)
+		l.lock.Unlock()
+		return
+	}
+	env := l.active
+	l.active, l.readyCh, l.idleTimer = nil, nil, nil
+	l.lock.Unlock()
+
+	l.loggers.Infof("Shutting down environment %q after being idle for %s", l.identifiers.GetDisplayName(), idleFor)
+	if err := env.Close(); err != nil {
+		l.loggers.Warnf("unexpected error when closing environment: %s", err)
+	}
+}
+
+// isDormant returns true if the environment has not been initialized, or has been shut down after
+// being idle.
+func (l *lazyEnvContext) isDormant() bool {
+	return l.getActive() == nil
+}
+
+func (l *lazyEnvContext) getActive() relayenv.EnvContext {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.active
+}
+
+// Hold activates the environment like activate, and also keeps it from being shut down for being idle
+// until the returned function is called. The request middleware calls this for each request, so that
+// the environment that a request is using stays open until the request is done; the count is incremented
+// before activating, so there is no point after activation at which checkIdle could close it.
+func (l *lazyEnvContext) Hold() func() {
+	l.lock.Lock()
+	l.inUse++
+	l.lock.Unlock()
+	l.activate()
+	return func() {
+		l.lock.Lock()
+		l.inUse--
+		l.lastUsed = time.Now()
+		l.lock.Unlock()
+	}
+}
+
+// serveWithActiveEnv returns a handler that holds the environment while it is serving a request, and
+// only then gets the real handler from the active environment, so that a stream cannot be handed an
+// environment that is being shut down. If the environment is dormant, it returns a 503 error.
+func (l *lazyEnvContext) serveWithActiveEnv(getHandler func(relayenv.EnvContext) http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		release := l.Hold()
+		defer release()
+		var h http.Handler
+		if env := l.getActive(); env != nil {
+			h = getHandler(env)
+		}
+		if h == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+func (l *lazyEnvContext) GetIdentifiers() relayenv.EnvIdentifiers {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.identifiers
+}
+
+func (l *lazyEnvContext) SetIdentifiers(ei relayenv.EnvIdentifiers) {
+	l.lock.Lock()
+	l.identifiers = ei
+	env := l.active
+	l.lock.Unlock()
+	if env != nil {
+		env.SetIdentifiers(ei)
+	}
+}
+
+func (l *lazyEnvContext) GetTenant() string {
+	return l.envConfig.Tenant
+}
+
+func (l *lazyEnvContext) GetCredentials() []config.SDKCredential {
+	return l.getCredentialsInternal(true)
+}
+
+func (l *lazyEnvContext) GetDeprecatedCredentials() []config.SDKCredential {
+	return l.getCredentialsInternal(false)
+}
+
+func (l *lazyEnvContext) getCredentialsInternal(preferred bool) []config.SDKCredential {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	ret := make([]config.SDKCredential, 0, len(l.credentials))
+	for c, nonDeprecated := range l.credentials {
+		if nonDeprecated == preferred {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+func (l *lazyEnvContext) AddCredential(newCredential config.SDKCredential) {
+	l.lock.Lock()
+	l.credentials[newCredential] = true
+	switch c := newCredential.(type) {
+	case config.SDKKey:
+		l.envConfig.SDKKey = c
+	case config.MobileKey:
+		l.envConfig.MobileKey = c
+	case config.EnvironmentID:
+		l.envConfig.EnvID = c
+	}
+	env := l.active
+	l.lock.Unlock()
+	if env != nil {
+		env.AddCredential(newCredential)
+	}
+}
+
+func (l *lazyEnvContext) RemoveCredential(oldCredential config.SDKCredential) {
+	l.lock.Lock()
+	delete(l.credentials, oldCredential)
+	env := l.active
+	l.lock.Unlock()
+	if env != nil {
+		env.RemoveCredential(oldCredential)
+	}
+}
+
+func (l *lazyEnvContext) DeprecateCredential(credential config.SDKCredential) {
+	l.lock.Lock()
+	if _, found := l.credentials[credential]; found {
+		l.credentials[credential] = false
+	}
+	env := l.active
+	l.lock.Unlock()
+	if env != nil {
+		env.DeprecateCredential(credential)
+	}
+}
+
+func (l *lazyEnvContext) GetClient() sdks.LDClientContext {
+	if env := l.getActive(); env != nil {
+		return env.GetClient()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) GetStore() interfaces.DataStore {
+	if env := l.getActive(); env != nil {
+		return env.GetStore()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) GetEvaluator() ldeval.Evaluator {
+	if env := l.getActive(); env != nil {
+		return env.GetEvaluator()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) GetBigSegmentProvider() ldeval.BigSegmentProvider {
+	if env := l.getActive(); env != nil {
+		return env.GetBigSegmentProvider()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) GetBigSegmentStore() bigsegments.BigSegmentStore {
+	if env := l.getActive(); env != nil {
+		return env.GetBigSegmentStore()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) PrefetchBigSegmentMemberships(userKeys []string) func() {
+	if env := l.getActive(); env != nil {
+		return env.PrefetchBigSegmentMemberships(userKeys)
+	}
+	return func() {}
+}
+
+func (l *lazyEnvContext) GetBigSegmentsStaleThreshold() time.Duration {
+	if env := l.getActive(); env != nil {
+		return env.GetBigSegmentsStaleThreshold()
+	}
+	return 0
+}
+
+func (l *lazyEnvContext) GetLoggers() ldlog.Loggers {
+	if env := l.getActive(); env != nil {
+		return env.GetLoggers()
+	}
+	return l.loggers
+}
+
+func (l *lazyEnvContext) GetStreamHandler(streamProvider streams.StreamProvider, credential config.SDKCredential) http.Handler {
+	return l.serveWithActiveEnv(func(env relayenv.EnvContext) http.Handler {
+		return env.GetStreamHandler(streamProvider, credential)
+	})
+}
+
+func (l *lazyEnvContext) GetEventDispatcher() *events.EventDispatcher {
+	if env := l.getActive(); env != nil {
+		return env.GetEventDispatcher()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) GetJSClientContext() relayenv.JSClientContext {
+	return l.jsContext
+}
+
+func (l *lazyEnvContext) GetMetricsContext() context.Context {
+	if env := l.getActive(); env != nil {
+		return env.GetMetricsContext()
+	}
+	return context.Background()
+}
+
+func (l *lazyEnvContext) GetClientCertPolicy() *clientcert.Policy {
+	if env := l.getActive(); env != nil {
+		return env.GetClientCertPolicy()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) GetClientSideFlagFilter() flagfilter.Filter {
+	if env := l.getActive(); env != nil {
+		return env.GetClientSideFlagFilter()
+	}
+	return flagfilter.Filter{}
+}
+
+func (l *lazyEnvContext) GetEvalCache() *evalcache.Cache {
+	if env := l.getActive(); env != nil {
+		return env.GetEvalCache()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) GetPollingCache() *pollcache.Cache {
+	if env := l.getActive(); env != nil {
+		return env.GetPollingCache()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) GetJWTVerifier() *jwtauth.Verifier {
+	if env := l.getActive(); env != nil {
+		return env.GetJWTVerifier()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) GetCanaryRoute() *relayenv.CanaryRoute {
+	return l.canaryRoute // this is known from the configuration, even while the environment is dormant
+}
+
+// GetChangeFeedHandler returns a handler even while the environment is dormant, if it has a change feed
+// token. The token is checked before the environment is activated, so that requests without it cannot
+// initialize the environment.
+func (l *lazyEnvContext) GetChangeFeedHandler(streamMiddleware func(http.Handler) http.Handler) http.Handler {
+	if l.envConfig.ChangeFeedToken == "" {
+		return nil
+	}
+	return flagchange.RequireFeedToken(l.envConfig.ChangeFeedToken,
+		l.serveWithActiveEnv(func(env relayenv.EnvContext) http.Handler {
+			return env.GetChangeFeedHandler(streamMiddleware)
+		}))
+}
+
+func (l *lazyEnvContext) GetRateLimiter() *ratelimit.Limiter {
+	if env := l.getActive(); env != nil {
+		return env.GetRateLimiter()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) GetQuota() *ratelimit.Quota {
+	if env := l.getActive(); env != nil {
+		return env.GetQuota()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) GetStreamLimiter() *ratelimit.StreamLimiter {
+	if env := l.getActive(); env != nil {
+		return env.GetStreamLimiter()
+	}
+	return nil
+}
+
+func (l *lazyEnvContext) GetTTL() time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.envConfig.TTL.GetOrElse(0)
+}
+
+func (l *lazyEnvContext) SetTTL(newTTL time.Duration) {
+	l.lock.Lock()
+	l.envConfig.TTL = ct.NewOptDuration(newTTL)
+	env := l.active
+	l.lock.Unlock()
+	if env != nil {
+		env.SetTTL(newTTL)
+	}
+}
+
+func (l *lazyEnvContext) GetInitError() error {
+	l.lock.Lock()
+	env, initErr := l.active, l.initErr
+	l.lock.Unlock()
+	if env != nil {
+		return env.GetInitError()
+	}
+	return initErr
+}
+
+func (l *lazyEnvContext) IsSecureMode() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.envConfig.SecureMode
+}
+
+func (l *lazyEnvContext) SetSecureMode(secureMode bool) {
+	l.lock.Lock()
+	l.envConfig.SecureMode = secureMode
+	env := l.active
+	l.lock.Unlock()
+	if env != nil {
+		env.SetSecureMode(secureMode)
+	}
+}
+
+func (l *lazyEnvContext) SetLogLevel(level config.OptLogLevel) {
+	l.lock.Lock()
+	l.envConfig.LogLevel = level
+	env := l.active
+	l.lock.Unlock()
+	if env != nil {
+		env.SetLogLevel(level)
+	}
+}
+
+func (l *lazyEnvContext) IsKillSwitchOn() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.killSwitch
+}
+
+func (l *lazyEnvContext) GetKillSwitchGeneration() uint64 {
+	if env := l.getActive(); env != nil {
+		return env.GetKillSwitchGeneration()
+	}
+	return 0 // no flags are served while the environment is dormant
+}
+
+// SetKillSwitch remembers the kill switch setting, so that it is applied again whenever the environment
+// is activated. While the environment is dormant, the kill switch file is read only to check that it is
+// valid.
+func (l *lazyEnvContext) SetKillSwitch(on bool) error {
+	l.lock.Lock()
+	env, filePath := l.active, l.envConfig.KillSwitchFile
+	l.lock.Unlock()
+	if env != nil {
+		if err := env.SetKillSwitch(on); err != nil {
+			return err
+		}
+	} else if on && filePath != "" {
+		if _, err := flagoverrides.ReadOverridesFile(filePath); err != nil {
+			return err
+		}
+	}
+	l.lock.Lock()
+	l.killSwitch = on
+	l.lock.Unlock()
+	return nil
+}
+
+func (l *lazyEnvContext) GetCreationTime() time.Time {
+	return l.creationTime
+}
+
+func (l *lazyEnvContext) IsReady() bool {
+	if env := l.getActive(); env != nil {
+		return env.IsReady()
+	}
+	return false
+}
+
+func (l *lazyEnvContext) GetDataStoreInfo() sdks.DataStoreEnvironmentInfo {
+	return l.dataStoreInfo
+}
+
+func (l *lazyEnvContext) FlushMetricsEvents() {
+	if env := l.getActive(); env != nil {
+		env.FlushMetricsEvents()
+	}
+}
+
+func (l *lazyEnvContext) Close() error {
+	l.lock.Lock()
+	if l.closed {
+		l.lock.Unlock()
+		return nil
+	}
+	l.closed = true
+	if l.idleTimer != nil {
+		l.idleTimer.Stop()
+	}
+	env := l.active
+	l.active, l.readyCh, l.idleTimer = nil, nil, nil
+	l.lock.Unlock()
+	if env != nil {
+		return env.Close()
+	}
+	return nil
+}
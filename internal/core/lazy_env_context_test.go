@@ -0,0 +1,302 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	ld "gopkg.in/launchdarkly/go-server-sdk.v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingClientFactory struct {
+	created []c.SDKKey
+	lock    sync.Mutex
+}
+
+func (f *countingClientFactory) factory() sdks.ClientFactoryFunc {
+	base := testclient.FakeLDClientFactory(true)
+	return func(sdkKey c.SDKKey, config ld.Config, timeout time.Duration) (sdks.LDClientContext, error) {
+		f.lock.Lock()
+		f.created = append(f.created, sdkKey)
+		f.lock.Unlock()
+		return base(sdkKey, config, timeout)
+	}
+}
+
+func (f *countingClientFactory) getCreated() []c.SDKKey {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]c.SDKKey(nil), f.created...)
+}
+
+func makeLazyCore(t *testing.T, idleTimeout time.Duration) (*RelayCore, *countingClientFactory) {
+	config := c.Config{
+		Main:        c.MainConfig{LazyEnvironmentInit: true},
+		Environment: st.MakeEnvConfigs(st.EnvMain, st.EnvMobile),
+	}
+	if idleTimeout > 0 {
+		config.Main.EnvironmentIdleTimeout = ct.NewOptDuration(idleTimeout)
+	}
+	clients := &countingClientFactory{}
+	core, err := NewRelayCore(config, ldlog.NewDisabledLoggers(), clients.factory(), "", "", false)
+	require.NoError(t, err)
+	return core, clients
+}
+
+func TestLazyEnvironmentIsNotInitializedUntilFirstRequest(t *testing.T) {
+	core, clients := makeLazyCore(t, 0)
+	defer core.Close()
+
+	require.NoError(t, core.WaitForAllClients(time.Second))
+	assert.Len(t, clients.getCreated(), 0)
+
+	env, _ := core.FindEnvironment(st.EnvMobile.Config.SDKKey)
+	require.NotNil(t, env)
+	assert.Nil(t, env.GetClient())
+	assert.Equal(t, st.EnvMobile.Name, env.GetIdentifiers().ConfiguredName)
+
+	env1, _ := core.GetEnvironment(st.EnvMobile.Config.SDKKey)
+	assert.Equal(t, env, env1)
+	assert.NotNil(t, env.GetClient())
+	assert.Equal(t, []c.SDKKey{st.EnvMobile.Config.SDKKey}, clients.getCreated())
+
+	// a second request does not create another client
+	_, _ = core.GetEnvironment(st.EnvMobile.Config.MobileKey)
+	assert.Len(t, clients.getCreated(), 1)
+}
+
+func TestLazyEnvironmentIsShutDownAfterIdleTimeout(t *testing.T) {
+	idleTimeout := time.Millisecond * 50
+	core, clients := makeLazyCore(t, idleTimeout)
+	defer core.Close()
+
+	env, _ := core.GetEnvironment(st.EnvMobile.Config.SDKKey)
+	require.NotNil(t, env.GetClient())
+
+	require.Eventually(t, func() bool { return env.GetClient() == nil }, time.Second, time.Millisecond*10)
+	assert.True(t, isEnvDormant(env))
+
+	// the next request initializes it again
+	_, _ = core.GetEnvironment(st.EnvMobile.Config.SDKKey)
+	assert.NotNil(t, env.GetClient())
+	assert.Len(t, clients.getCreated(), 2)
+}
+
+func TestLazyEnvironmentIsNotShutDownWhileStreamIsOpen(t *testing.T) {
+	idleTimeout := time.Millisecond * 50
+	core, _ := makeLazyCore(t, idleTimeout)
+	defer core.Close()
+
+	env, _ := core.GetEnvironment(st.EnvMobile.Config.SDKKey)
+	lazyEnv := env.(*lazyEnvContext)
+
+	inHandler, releaseHandler := make(chan struct{}), make(chan struct{})
+	handler := lazyEnv.serveWithActiveEnv(func(relayenv.EnvContext) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(inHandler)
+			<-releaseHandler
+		})
+	})
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-inHandler
+
+	time.Sleep(idleTimeout * 3)
+	assert.False(t, lazyEnv.isDormant())
+
+	close(releaseHandler)
+	require.Eventually(t, lazyEnv.isDormant, time.Second, time.Millisecond*10)
+}
+
+func TestLazyEnvironmentIsNotShutDownWhileRequestIsHoldingIt(t *testing.T) {
+	idleTimeout := time.Millisecond * 50
+	core, clients := makeLazyCore(t, idleTimeout)
+	defer core.Close()
+
+	env, _ := core.FindEnvironment(st.EnvMobile.Config.SDKKey)
+	lazyEnv := env.(*lazyEnvContext)
+
+	release := lazyEnv.Hold()
+	active := lazyEnv.getActive()
+	require.NotNil(t, active)
+
+	time.Sleep(idleTimeout * 3)
+	assert.Equal(t, active, lazyEnv.getActive())
+	assert.NotNil(t, active.GetClient())
+
+	release()
+	require.Eventually(t, lazyEnv.isDormant, time.Second, time.Millisecond*10)
+	assert.Len(t, clients.getCreated(), 1)
+}
+
+func TestSelectEnvironmentHoldsLazyEnvironmentUntilRequestIsDone(t *testing.T) {
+	idleTimeout := time.Millisecond * 50
+	core, _ := makeLazyCore(t, idleTimeout)
+	defer core.Close()
+
+	env, _ := core.FindEnvironment(st.EnvMobile.Config.SDKKey)
+	lazyEnv := env.(*lazyEnvContext)
+
+	inHandler, releaseHandler := make(chan struct{}), make(chan struct{})
+	handler := middleware.SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, core)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(inHandler)
+			<-releaseHandler
+		}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", string(st.EnvMobile.Config.SDKKey))
+	go handler.ServeHTTP(httptest.NewRecorder(), req)
+	<-inHandler
+
+	time.Sleep(idleTimeout * 3)
+	assert.False(t, lazyEnv.isDormant())
+
+	close(releaseHandler)
+	require.Eventually(t, lazyEnv.isDormant, time.Second, time.Millisecond*10)
+}
+
+func TestChangeFeedRequestWithoutTokenDoesNotActivateLazyEnvironment(t *testing.T) {
+	testEnv := st.EnvMain
+	testEnv.Config.EnvID = c.EnvironmentID("feed-env-id")
+	testEnv.Config.ChangeFeedToken = "feed-token"
+	config := c.Config{
+		Main:        c.MainConfig{LazyEnvironmentInit: true},
+		Environment: st.MakeEnvConfigs(testEnv),
+	}
+	clients := &countingClientFactory{}
+	core, err := NewRelayCore(config, ldlog.NewDisabledLoggers(), clients.factory(), "", "", false)
+	require.NoError(t, err)
+	defer core.Close()
+	router := core.MakeRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, st.BuildRequest("GET", "/changes/"+string(testEnv.Config.EnvID), nil, nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	assert.Len(t, clients.getCreated(), 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := st.BuildRequest("GET", "/changes/"+string(testEnv.Config.EnvID), nil,
+		http.Header{"Authorization": {"Bearer feed-token"}}).WithContext(ctx)
+	go router.ServeHTTP(httptest.NewRecorder(), req)
+	require.Eventually(t, func() bool { return len(clients.getCreated()) == 1 }, time.Second, time.Millisecond*10)
+}
+
+func TestLazyEnvironmentKeepsCredentialChangesWhileDormant(t *testing.T) {
+	core, clients := makeLazyCore(t, 0)
+	defer core.Close()
+
+	env, _ := core.FindEnvironment(st.EnvMobile.Config.SDKKey)
+	newKey := c.SDKKey("new-key")
+	env.AddCredential(newKey)
+	env.DeprecateCredential(st.EnvMobile.Config.SDKKey)
+	core.AddedEnvironmentCredential(env, newKey)
+
+	assert.ElementsMatch(t, []c.SDKCredential{newKey, st.EnvMobile.Config.MobileKey}, env.GetCredentials())
+	assert.ElementsMatch(t, []c.SDKCredential{st.EnvMobile.Config.SDKKey}, env.GetDeprecatedCredentials())
+
+	_, _ = core.GetEnvironment(newKey)
+	require.Eventually(t, func() bool { return len(clients.getCreated()) == 2 }, time.Second, time.Millisecond*10)
+	assert.ElementsMatch(t, []c.SDKKey{newKey, st.EnvMobile.Config.SDKKey}, clients.getCreated())
+	assert.ElementsMatch(t, []c.SDKCredential{newKey, st.EnvMobile.Config.MobileKey}, env.GetCredentials())
+	assert.ElementsMatch(t, []c.SDKCredential{st.EnvMobile.Config.SDKKey}, env.GetDeprecatedCredentials())
+}
+
+func TestLazyEnvironmentKeepsKillSwitchWhileDormant(t *testing.T) {
+	core, _ := makeLazyCore(t, 0)
+	defer core.Close()
+
+	env, _ := core.FindEnvironment(st.EnvMobile.Config.SDKKey)
+	require.NoError(t, env.SetKillSwitch(true))
+	assert.True(t, env.IsKillSwitchOn())
+
+	_, _ = core.GetEnvironment(st.EnvMobile.Config.SDKKey)
+	active := env.(*lazyEnvContext).getActive()
+	require.NotNil(t, active)
+	assert.True(t, active.IsKillSwitchOn())
+
+	require.NoError(t, env.SetKillSwitch(false))
+	assert.False(t, env.IsKillSwitchOn())
+	assert.False(t, active.IsKillSwitchOn())
+}
+
+func TestDormantEnvironmentIsReportedInStatusAndCountsAsReady(t *testing.T) {
+	core, _ := makeLazyCore(t, 0)
+	defer core.Close()
+
+	w := httptest.NewRecorder()
+	readinessHandler(core).ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	statusHandler(core).ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	assert.Contains(t, w.Body.String(), `"status":"dormant"`)
+	assert.Contains(t, w.Body.String(), `"status":"healthy"`)
+}
+
+func TestLazyEnvironmentIsNotLockedWhileInitializing(t *testing.T) {
+	inMakeEnv, releaseMakeEnv := make(chan struct{}), make(chan struct{})
+	makeCount := 0
+	makeEnv := func(
+		identifiers relayenv.EnvIdentifiers,
+		envConfig c.EnvConfig,
+		readyCh chan<- relayenv.EnvContext,
+	) (relayenv.EnvContext, error) {
+		makeCount++
+		close(inMakeEnv)
+		<-releaseMakeEnv
+		return relayenv.NewEnvContext(relayenv.EnvContextImplParams{
+			Identifiers:   identifiers,
+			EnvConfig:     envConfig,
+			ClientFactory: testclient.FakeLDClientFactory(true),
+			Loggers:       ldlog.NewDisabledLoggers(),
+		}, readyCh)
+	}
+	env := newLazyEnvContext(relayenv.EnvIdentifiers{ConfiguredName: st.EnvMain.Name}, st.EnvMain.Config,
+		c.Config{}, relayenv.JSClientContext{}, sdks.DataStoreEnvironmentInfo{}, ldlog.NewDisabledLoggers(), makeEnv)
+	defer env.Close()
+
+	activated := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			env.activate()
+			activated <- struct{}{}
+		}()
+	}
+	<-inMakeEnv
+
+	// other methods do not wait for the environment to be created
+	assert.Equal(t, st.EnvMain.Name, env.GetIdentifiers().ConfiguredName)
+	newKey := c.SDKKey("new-key")
+	env.AddCredential(newKey)
+	env.DeprecateCredential(st.EnvMain.Config.SDKKey)
+	assert.Nil(t, env.GetClient())
+
+	close(releaseMakeEnv)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-activated:
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for activation")
+		}
+	}
+	assert.Equal(t, 1, makeCount)
+	assert.NotNil(t, env.GetClient())
+	assert.ElementsMatch(t, []c.SDKCredential{newKey}, env.getActive().GetCredentials())
+	assert.ElementsMatch(t, []c.SDKCredential{st.EnvMain.Config.SDKKey}, env.getActive().GetDeprecatedCredentials())
+}
@@ -0,0 +1,254 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+// Lock is a lock that is shared by all of the Relay instances that take part in leader election. The
+// implementations are the Redis lock and the Kubernetes Lease lock; it is an interface so that the
+// election logic can be tested without either of them.
+type Lock interface {
+	// Acquire tries to acquire the lock for this instance, or to extend it if this instance already
+	// holds it, and returns true if this instance holds it afterward.
+	Acquire(ctx context.Context) (bool, error)
+	// Release gives up the lock if this instance holds it, so that another instance can acquire it
+	// without waiting for it to expire.
+	Release(ctx context.Context) error
+	// Close releases any resources held by the Lock.
+	Close() error
+}
+
+// Elector periodically tries to acquire or renew the leader lock, and keeps track of whether this
+// instance is the leader. It steps down on its own if it has not been able to renew the lock for long
+// enough that another instance might have acquired it, so there is never a time when it believes it is
+// the leader after its lease has expired.
+//
+// All of its methods can be called on a nil *Elector, which is what NewElector returns if leader election
+// is not enabled; a nil Elector is always the leader.
+type Elector struct {
+	leaderLock     Lock
+	identity       string
+	leaseDuration  time.Duration
+	renewInterval  time.Duration
+	loggers        ldlog.Loggers
+	now            func() time.Time
+	isLeader       bool
+	lastAcquired   time.Time
+	failing        bool
+	listeners      map[int]func(bool)
+	nextListenerID int
+	started        bool
+	lock           sync.Mutex
+	startOnce      sync.Once
+	closeOnce      sync.Once
+	closeCh        chan struct{}
+	done           chan struct{}
+}
+
+var errNoIdentity = errors.New("leader election identity was not set, and the hostname could not be determined")
+
+// NewElector creates an Elector from the configuration, or returns nil if leader election is not
+// enabled. The Redis configuration is only used if the lock type is config.LeaderElectionTypeRedis. The
+// Elector does nothing until Start is called.
+func NewElector(c config.LeaderElectionConfig, redisConfig config.RedisConfig, loggers ldlog.Loggers) (*Elector, error) {
+	if c.Type == "" {
+		return nil, nil
+	}
+	identity := c.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil || hostname == "" {
+			return nil, errNoIdentity
+		}
+		identity = hostname
+	}
+	lockName := c.LockName
+	if lockName == "" {
+		lockName = config.DefaultLeaderElectionLockName
+	}
+	leaseDuration := c.LeaseDuration.GetOrElse(config.DefaultLeaderElectionLeaseDuration)
+
+	var lock Lock
+	var err error
+	switch c.Type {
+	case config.LeaderElectionTypeRedis:
+		lock, err = newRedisLock(redisConfig, lockName, identity, leaseDuration)
+	case config.LeaderElectionTypeKubernetes:
+		lock, err = newKubernetesLockInCluster(c.KubernetesNamespace, lockName, identity, leaseDuration)
+	default:
+		err = fmt.Errorf("unknown leader election type %q", c.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newElector(lock, identity, leaseDuration,
+		c.RenewInterval.GetOrElse(config.DefaultLeaderElectionRenewInterval), loggers), nil
+}
+
+// NewElectorForLock creates an Elector that uses an existing Lock, instead of the one that NewElector
+// would create for c.Type. The lease duration and renew interval are taken from c.
+func NewElectorForLock(lock Lock, c config.LeaderElectionConfig, loggers ldlog.Loggers) *Elector {
+	return newElector(lock, c.Identity, c.LeaseDuration.GetOrElse(config.DefaultLeaderElectionLeaseDuration),
+		c.RenewInterval.GetOrElse(config.DefaultLeaderElectionRenewInterval), loggers)
+}
+
+func newElector(lock Lock, identity string, leaseDuration, renewInterval time.Duration, loggers ldlog.Loggers) *Elector {
+	return &Elector{
+		leaderLock:    lock,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		renewInterval: renewInterval,
+		loggers:       loggers,
+		now:           time.Now,
+		listeners:     make(map[int]func(bool)),
+		closeCh:       make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start makes the first attempt to acquire the lock, so that IsLeader has a meaningful value as soon as
+// it returns, and then keeps renewing or retrying on a separate goroutine until Close is called.
+func (e *Elector) Start() {
+	if e == nil {
+		return
+	}
+	e.startOnce.Do(func() {
+		e.loggers.Infof("Leader election enabled (identity: %q)", e.identity)
+		e.check()
+		e.lock.Lock()
+		e.started = true
+		e.lock.Unlock()
+		go e.run()
+	})
+}
+
+// IsLeader returns true if this instance currently holds the leader lock.
+func (e *Elector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.isLeader
+}
+
+// OnChange registers a function to be called, with the new value of IsLeader, whenever this instance
+// becomes the leader or stops being the leader. It is called on the Elector's goroutine, and the next
+// attempt to renew the lock waits until it returns. The returned function unregisters it.
+func (e *Elector) OnChange(fn func(isLeader bool)) (remove func()) {
+	if e == nil {
+		return func() {}
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	id := e.nextListenerID
+	e.nextListenerID++
+	e.listeners[id] = fn
+	return func() {
+		e.lock.Lock()
+		delete(e.listeners, id)
+		e.lock.Unlock()
+	}
+}
+
+// Close stops renewing the lock, and releases it if this instance is the leader so that another
+// instance can take over right away.
+func (e *Elector) Close() {
+	if e == nil {
+		return
+	}
+	e.closeOnce.Do(func() {
+		close(e.closeCh)
+		e.lock.Lock()
+		started := e.started
+		e.lock.Unlock()
+		if started {
+			<-e.done
+		}
+		if e.IsLeader() {
+			ctx, cancel := context.WithTimeout(context.Background(), e.renewInterval)
+			if err := e.leaderLock.Release(ctx); err != nil {
+				e.loggers.Warnf("Unable to release leader lock: %s", err)
+			}
+			cancel()
+			e.lock.Lock()
+			e.isLeader = false
+			e.lock.Unlock()
+		}
+		_ = e.leaderLock.Close()
+	})
+}
+
+func (e *Elector) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.renewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.closeCh:
+			return
+		case <-ticker.C:
+			e.check()
+		}
+	}
+}
+
+// check is only called from Start and from the renewal goroutine, so there are never two at once.
+func (e *Elector) check() {
+	startTime := e.now()
+	ctx, cancel := context.WithTimeout(context.Background(), e.renewInterval)
+	acquired, err := e.leaderLock.Acquire(ctx)
+	cancel()
+
+	e.lock.Lock()
+	wasLeader := e.isLeader
+	if err != nil {
+		if !e.failing {
+			e.loggers.Warnf("Unable to acquire or renew leader lock: %s", err)
+			e.failing = true
+		}
+		// The lease that we last acquired is still ours until it expires, but we must step down before
+		// then, since we might not get another chance to check before it does.
+		if e.isLeader && e.now().Add(e.renewInterval).Sub(e.lastAcquired) >= e.leaseDuration {
+			e.isLeader = false
+		}
+	} else {
+		if e.failing {
+			e.loggers.Info("Leader lock is available again")
+			e.failing = false
+		}
+		if acquired {
+			e.lastAcquired = startTime
+		}
+		e.isLeader = acquired
+	}
+	isLeader := e.isLeader
+	var listeners []func(bool)
+	if isLeader != wasLeader {
+		for _, fn := range e.listeners {
+			listeners = append(listeners, fn)
+		}
+	}
+	e.lock.Unlock()
+
+	if isLeader == wasLeader {
+		return
+	}
+	if isLeader {
+		e.loggers.Info("This Relay instance is now the leader")
+	} else {
+		e.loggers.Warn("This Relay instance is no longer the leader; standing by")
+	}
+	for _, fn := range listeners {
+		fn(isLeader)
+	}
+}
@@ -0,0 +1,162 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLock struct {
+	acquired bool
+	err      error
+	released bool
+	closed   bool
+	lock     sync.Mutex
+}
+
+func (f *fakeLock) Acquire(ctx context.Context) (bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.acquired, f.err
+}
+
+func (f *fakeLock) Release(ctx context.Context) error {
+	f.lock.Lock()
+	f.released = true
+	f.lock.Unlock()
+	return nil
+}
+
+func (f *fakeLock) Close() error {
+	f.lock.Lock()
+	f.closed = true
+	f.lock.Unlock()
+	return nil
+}
+
+func (f *fakeLock) set(acquired bool, err error) {
+	f.lock.Lock()
+	f.acquired, f.err = acquired, err
+	f.lock.Unlock()
+}
+
+func TestNewElectorReturnsNilIfNotEnabled(t *testing.T) {
+	e, err := NewElector(config.LeaderElectionConfig{}, config.RedisConfig{}, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	assert.Nil(t, e)
+}
+
+func TestNewElectorKubernetesRequiresCluster(t *testing.T) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		t.Skip("test is running in Kubernetes")
+	}
+	c := config.LeaderElectionConfig{Type: config.LeaderElectionTypeKubernetes}
+	_, err := NewElector(c, config.RedisConfig{}, ldlog.NewDisabledLoggers())
+	assert.Equal(t, errNotInKubernetes, err)
+}
+
+func TestNilElectorIsAlwaysLeader(t *testing.T) {
+	var e *Elector
+	e.Start()
+	assert.True(t, e.IsLeader())
+	e.OnChange(func(bool) {})()
+	e.Close()
+}
+
+func TestElectorBecomesLeaderAndStandsBy(t *testing.T) {
+	lock := &fakeLock{}
+	e := newElector(lock, "a", time.Second*15, time.Second*5, ldlog.NewDisabledLoggers())
+	var changes []bool
+	remove := e.OnChange(func(isLeader bool) { changes = append(changes, isLeader) })
+
+	e.check()
+	assert.False(t, e.IsLeader())
+
+	lock.set(true, nil)
+	e.check()
+	e.check()
+	assert.True(t, e.IsLeader())
+
+	lock.set(false, nil)
+	e.check()
+	assert.False(t, e.IsLeader())
+	assert.Equal(t, []bool{true, false}, changes)
+
+	remove()
+	lock.set(true, nil)
+	e.check()
+	assert.True(t, e.IsLeader())
+	assert.Len(t, changes, 2)
+}
+
+func TestElectorStaysLeaderAfterErrorUntilLeaseMightExpire(t *testing.T) {
+	lock := &fakeLock{acquired: true}
+	mockLog := ldlogtest.NewMockLog()
+	e := newElector(lock, "a", time.Second*15, time.Second*5, mockLog.Loggers)
+	now := time.Now()
+	e.now = func() time.Time { return now }
+
+	e.check()
+	require.True(t, e.IsLeader())
+
+	lock.set(false, errors.New("sorry"))
+	now = now.Add(time.Second * 5)
+	e.check()
+	assert.True(t, e.IsLeader())
+
+	now = now.Add(time.Second * 5) // the next check would be when the lease expires
+	e.check()
+	assert.False(t, e.IsLeader())
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 2) // the error is only logged once, and then stepping down
+
+	lock.set(true, nil)
+	e.check()
+	assert.True(t, e.IsLeader())
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "Leader lock is available again")
+}
+
+func TestElectorStartAndClose(t *testing.T) {
+	lock := &fakeLock{acquired: true}
+	e := newElector(lock, "a", time.Millisecond*100, time.Millisecond*10, ldlog.NewDisabledLoggers())
+	changedCh := make(chan bool, 10)
+	e.OnChange(func(isLeader bool) { changedCh <- isLeader })
+
+	e.Start()
+	assert.True(t, e.IsLeader()) // the first check is done before Start returns
+	assert.True(t, <-changedCh)
+
+	lock.set(false, nil)
+	select {
+	case isLeader := <-changedCh:
+		assert.False(t, isLeader)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timed out waiting for change")
+	}
+
+	lock.set(true, nil)
+	<-changedCh
+	e.Close()
+	e.Close()
+	assert.False(t, e.IsLeader())
+	assert.True(t, lock.released)
+	assert.True(t, lock.closed)
+}
+
+func TestElectorCloseWithoutStart(t *testing.T) {
+	lock := &fakeLock{}
+	e := newElector(lock, "a", time.Second*15, time.Second*5, ldlog.NewDisabledLoggers())
+	e.Close()
+	assert.False(t, lock.released)
+	assert.True(t, lock.closed)
+}
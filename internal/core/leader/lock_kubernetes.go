@@ -0,0 +1,253 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The Kubernetes lock is a Lease object in the coordination.k8s.io API group, used the same way as by
+// Kubernetes' own leader election: the instance whose identity is in holderIdentity holds the lock until
+// leaseDurationSeconds after renewTime. Updates include the resourceVersion that was read, so if two
+// instances try to take over an expired Lease at the same time, the API server rejects one of them.
+//
+// Relay calls the API server's REST API directly, using the pod's service account, so that it does not
+// need the Kubernetes client libraries.
+
+const (
+	kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubernetesMicroTimeFormat   = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+var (
+	errNotInKubernetes        = errors.New("Kubernetes leader election requires Relay to be running in a Kubernetes pod") //nolint:stylecheck
+	errNoKubernetesCACerts    = errors.New("no certificates found in the Kubernetes service account's CA file")
+	errKubernetesLeaseMissing = errors.New("Kubernetes Lease does not exist") //nolint:stylecheck
+)
+
+type kubernetesLease struct {
+	APIVersion string                  `json:"apiVersion"`
+	Kind       string                  `json:"kind"`
+	Metadata   kubernetesLeaseMetadata `json:"metadata"`
+	Spec       kubernetesLeaseSpec     `json:"spec"`
+}
+
+type kubernetesLeaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type kubernetesLeaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+type kubernetesLock struct {
+	httpClient    *http.Client
+	leasesURL     string
+	tokenPath     string
+	name          string
+	namespace     string
+	identity      string
+	leaseDuration time.Duration
+	now           func() time.Time
+}
+
+func newKubernetesLockInCluster(namespace, name, identity string, leaseDuration time.Duration) (*kubernetesLock, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errNotInKubernetes
+	}
+	if namespace == "" {
+		data, err := ioutil.ReadFile(filepath.Join(kubernetesServiceAccountDir, "namespace"))
+		if err != nil {
+			return nil, err
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	caData, err := ioutil.ReadFile(filepath.Join(kubernetesServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{RootCAs: x509.NewCertPool(), MinVersion: tls.VersionTLS12}
+	if !tlsConfig.RootCAs.AppendCertsFromPEM(caData) {
+		return nil, errNoKubernetesCACerts
+	}
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	return newKubernetesLock(httpClient, "https://"+net.JoinHostPort(host, port),
+		filepath.Join(kubernetesServiceAccountDir, "token"), namespace, name, identity, leaseDuration), nil
+}
+
+// newKubernetesLock creates a lock that uses the API server at baseURL. The service account token is read
+// from tokenPath before every request, since Kubernetes rotates it; if tokenPath is empty, requests are
+// not authenticated.
+func newKubernetesLock(
+	httpClient *http.Client,
+	baseURL, tokenPath, namespace, name, identity string,
+	leaseDuration time.Duration,
+) *kubernetesLock {
+	return &kubernetesLock{
+		httpClient: httpClient,
+		leasesURL: strings.TrimRight(baseURL, "/") + "/apis/coordination.k8s.io/v1/namespaces/" +
+			url.PathEscape(namespace) + "/leases",
+		tokenPath:     tokenPath,
+		name:          name,
+		namespace:     namespace,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		now:           time.Now,
+	}
+}
+
+func (k *kubernetesLock) Acquire(ctx context.Context) (bool, error) {
+	now := k.now()
+	lease, err := k.getLease(ctx)
+	if err == errKubernetesLeaseMissing {
+		lease = kubernetesLease{
+			Metadata: kubernetesLeaseMetadata{Name: k.name, Namespace: k.namespace},
+			Spec:     kubernetesLeaseSpec{AcquireTime: formatKubernetesMicroTime(now)},
+		}
+		k.setHolder(&lease, now)
+		return k.writeLease(ctx, "POST", k.leasesURL, lease)
+	}
+	if err != nil {
+		return false, err
+	}
+	holder := lease.Spec.HolderIdentity
+	if holder != k.identity && holder != "" && !isKubernetesLeaseExpired(lease, now) {
+		return false, nil
+	}
+	if holder != k.identity {
+		lease.Spec.AcquireTime = formatKubernetesMicroTime(now)
+		lease.Spec.LeaseTransitions++
+	}
+	k.setHolder(&lease, now)
+	return k.writeLease(ctx, "PUT", k.leaseURL(), lease)
+}
+
+func (k *kubernetesLock) Release(ctx context.Context) error {
+	lease, err := k.getLease(ctx)
+	if err == errKubernetesLeaseMissing {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity != k.identity {
+		return nil
+	}
+	lease.Spec.HolderIdentity = ""
+	lease.Spec.LeaseDurationSeconds = 1
+	lease.Spec.RenewTime = formatKubernetesMicroTime(k.now())
+	_, err = k.writeLease(ctx, "PUT", k.leaseURL(), lease)
+	return err
+}
+
+func (k *kubernetesLock) Close() error {
+	k.httpClient.CloseIdleConnections()
+	return nil
+}
+
+func (k *kubernetesLock) leaseURL() string {
+	return k.leasesURL + "/" + url.PathEscape(k.name)
+}
+
+func (k *kubernetesLock) setHolder(lease *kubernetesLease, now time.Time) {
+	lease.APIVersion = "coordination.k8s.io/v1"
+	lease.Kind = "Lease"
+	lease.Spec.HolderIdentity = k.identity
+	lease.Spec.LeaseDurationSeconds = int(math.Ceil(k.leaseDuration.Seconds()))
+	lease.Spec.RenewTime = formatKubernetesMicroTime(now)
+}
+
+func (k *kubernetesLock) getLease(ctx context.Context) (kubernetesLease, error) {
+	var lease kubernetesLease
+	resp, err := k.doRequest(ctx, "GET", k.leaseURL(), nil)
+	if err != nil {
+		return lease, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		err = json.NewDecoder(resp.Body).Decode(&lease)
+		return lease, err
+	case http.StatusNotFound:
+		return lease, errKubernetesLeaseMissing
+	default:
+		return lease, errKubernetesStatus("GET", resp.StatusCode)
+	}
+}
+
+// writeLease creates or updates the Lease, and returns false without an error if the API server rejects
+// it because another instance created or updated it first.
+func (k *kubernetesLock) writeLease(ctx context.Context, method, target string, lease kubernetesLease) (bool, error) {
+	data, err := json.Marshal(lease)
+	if err != nil { // COVERAGE: can't happen in unit tests
+		return false, err
+	}
+	resp, err := k.doRequest(ctx, method, target, data)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return true, nil
+	case http.StatusConflict:
+		return false, nil
+	default:
+		return false, errKubernetesStatus(method, resp.StatusCode)
+	}
+}
+
+func (k *kubernetesLock) doRequest(ctx context.Context, method, target string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if k.tokenPath != "" {
+		token, err := ioutil.ReadFile(k.tokenPath)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return k.httpClient.Do(req)
+}
+
+func errKubernetesStatus(method string, status int) error {
+	return fmt.Errorf("Kubernetes API returned HTTP status %d for %s of Lease", status, method) //nolint:stylecheck
+}
+
+func isKubernetesLeaseExpired(lease kubernetesLease, now time.Time) bool {
+	renewTime, err := time.Parse(time.RFC3339Nano, lease.Spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return !now.Before(renewTime.Add(time.Duration(lease.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+func formatKubernetesMicroTime(t time.Time) string {
+	return t.UTC().Format(kubernetesMicroTimeFormat)
+}
@@ -0,0 +1,199 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeLeasePath = "/apis/coordination.k8s.io/v1/namespaces/ns/leases"
+
+// fakeKubernetesAPI stores one Lease, and rejects updates whose resourceVersion is out of date.
+type fakeKubernetesAPI struct {
+	lease   *kubernetesLease
+	version int
+	tokens  []string
+	lock    sync.Mutex
+}
+
+func (f *fakeKubernetesAPI) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.tokens = append(f.tokens, req.Header.Get("Authorization"))
+	switch {
+	case req.Method == "GET" && req.URL.Path == fakeLeasePath+"/lock":
+		if f.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f.lease)
+	case req.Method == "POST" && req.URL.Path == fakeLeasePath:
+		if f.lease != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.store(w, req, http.StatusCreated)
+	case req.Method == "PUT" && req.URL.Path == fakeLeasePath+"/lock":
+		var lease kubernetesLease
+		_ = json.NewDecoder(req.Body).Decode(&lease)
+		if f.lease == nil || lease.Metadata.ResourceVersion != f.lease.Metadata.ResourceVersion {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.lease = &lease
+		f.bumpVersion()
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (f *fakeKubernetesAPI) store(w http.ResponseWriter, req *http.Request, status int) {
+	var lease kubernetesLease
+	_ = json.NewDecoder(req.Body).Decode(&lease)
+	f.lease = &lease
+	f.bumpVersion()
+	w.WriteHeader(status)
+}
+
+func (f *fakeKubernetesAPI) bumpVersion() {
+	f.version++
+	f.lease.Metadata.ResourceVersion = strconv.Itoa(f.version)
+}
+
+func (f *fakeKubernetesAPI) getLease() kubernetesLease {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return *f.lease
+}
+
+func withFakeKubernetesAPI(t *testing.T, action func(api *fakeKubernetesAPI, makeLock func(identity string) *kubernetesLock)) {
+	api := &fakeKubernetesAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	action(api, func(identity string) *kubernetesLock {
+		return newKubernetesLock(http.DefaultClient, server.URL, "", "ns", "lock", identity, time.Second*15)
+	})
+}
+
+func TestKubernetesLockCreatesLease(t *testing.T) {
+	withFakeKubernetesAPI(t, func(api *fakeKubernetesAPI, makeLock func(string) *kubernetesLock) {
+		acquired, err := makeLock("a").Acquire(context.Background())
+		require.NoError(t, err)
+		assert.True(t, acquired)
+
+		lease := api.getLease()
+		assert.Equal(t, "Lease", lease.Kind)
+		assert.Equal(t, "lock", lease.Metadata.Name)
+		assert.Equal(t, "a", lease.Spec.HolderIdentity)
+		assert.Equal(t, 15, lease.Spec.LeaseDurationSeconds)
+		assert.Equal(t, lease.Spec.AcquireTime, lease.Spec.RenewTime)
+	})
+}
+
+func TestKubernetesLockIsHeldByOneInstance(t *testing.T) {
+	withFakeKubernetesAPI(t, func(api *fakeKubernetesAPI, makeLock func(string) *kubernetesLock) {
+		lockA, lockB := makeLock("a"), makeLock("b")
+		now := time.Now()
+		lockA.now = func() time.Time { return now }
+		lockB.now = lockA.now
+
+		acquired, err := lockA.Acquire(context.Background())
+		require.NoError(t, err)
+		assert.True(t, acquired)
+		acquired, err = lockB.Acquire(context.Background())
+		require.NoError(t, err)
+		assert.False(t, acquired)
+
+		now = now.Add(time.Second * 10)
+		acquired, err = lockA.Acquire(context.Background())
+		require.NoError(t, err)
+		assert.True(t, acquired)
+		assert.Equal(t, formatKubernetesMicroTime(now), api.getLease().Spec.RenewTime)
+
+		// the lease was renewed, so it has not expired yet
+		now = now.Add(time.Second * 10)
+		acquired, err = lockB.Acquire(context.Background())
+		require.NoError(t, err)
+		assert.False(t, acquired)
+
+		now = now.Add(time.Second * 5)
+		acquired, err = lockB.Acquire(context.Background())
+		require.NoError(t, err)
+		assert.True(t, acquired)
+		lease := api.getLease()
+		assert.Equal(t, "b", lease.Spec.HolderIdentity)
+		assert.Equal(t, 1, lease.Spec.LeaseTransitions)
+		assert.Equal(t, formatKubernetesMicroTime(now), lease.Spec.AcquireTime)
+
+		acquired, err = lockA.Acquire(context.Background())
+		require.NoError(t, err)
+		assert.False(t, acquired)
+	})
+}
+
+func TestKubernetesLockRelease(t *testing.T) {
+	withFakeKubernetesAPI(t, func(api *fakeKubernetesAPI, makeLock func(string) *kubernetesLock) {
+		lockA, lockB := makeLock("a"), makeLock("b")
+		require.NoError(t, lockA.Release(context.Background())) // no Lease yet
+
+		_, err := lockA.Acquire(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, lockB.Release(context.Background())) // not the holder, so does nothing
+		assert.Equal(t, "a", api.getLease().Spec.HolderIdentity)
+
+		require.NoError(t, lockA.Release(context.Background()))
+		assert.Equal(t, "", api.getLease().Spec.HolderIdentity)
+
+		acquired, err := lockB.Acquire(context.Background())
+		require.NoError(t, err)
+		assert.True(t, acquired)
+	})
+}
+
+func TestKubernetesLockSendsTokenFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubernetes-lock-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tokenPath := filepath.Join(dir, "token")
+
+	withFakeKubernetesAPI(t, func(api *fakeKubernetesAPI, makeLock func(string) *kubernetesLock) {
+		lock := makeLock("a")
+		lock.tokenPath = tokenPath
+
+		_, err := lock.Acquire(context.Background())
+		assert.Error(t, err)
+
+		require.NoError(t, ioutil.WriteFile(tokenPath, []byte("token1\n"), 0600))
+		_, err = lock.Acquire(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(tokenPath, []byte("token2\n"), 0600))
+		_, err = lock.Acquire(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"Bearer token1", "Bearer token1", "Bearer token2", "Bearer token2"}, api.tokens)
+	})
+}
+
+func TestKubernetesLockReturnsErrorForUnexpectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	lock := newKubernetesLock(http.DefaultClient, server.URL, "", "ns", "lock", "a", time.Second*15)
+
+	acquired, err := lock.Acquire(context.Background())
+	assert.Equal(t, errKubernetesStatus("GET", http.StatusForbidden), err)
+	assert.False(t, acquired)
+}
@@ -0,0 +1,68 @@
+package leader
+
+import (
+	"context"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// The Redis lock is a single key whose value is the identity of the instance that holds it, and which
+// expires after the lease duration. Acquiring and releasing it are done in scripts, so that an instance
+// can never extend or delete the key after another instance has acquired it.
+
+//nolint:gochecknoglobals
+var (
+	redisAcquireScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0
+`)
+	redisReleaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+)
+
+type redisLock struct {
+	client        redis.UniversalClient
+	key           string
+	identity      string
+	leaseDuration time.Duration
+}
+
+func newRedisLock(redisConfig config.RedisConfig, lockName, identity string, leaseDuration time.Duration) (*redisLock, error) {
+	client, prefix, err := sdks.NewRedisClient(redisConfig, config.EnvConfig{})
+	if err != nil {
+		return nil, err
+	}
+	return &redisLock{
+		client:        client,
+		key:           prefix + ":" + lockName,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+	}, nil
+}
+
+func (r *redisLock) Acquire(ctx context.Context) (bool, error) {
+	result, err := redisAcquireScript.Run(ctx, r.client, []string{r.key}, r.identity, r.leaseDuration.Milliseconds()).Int()
+	return result == 1, err
+}
+
+func (r *redisLock) Release(ctx context.Context) error {
+	return redisReleaseScript.Run(ctx, r.client, []string{r.key}, r.identity).Err()
+}
+
+func (r *redisLock) Close() error {
+	return r.client.Close()
+}
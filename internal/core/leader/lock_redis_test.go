@@ -0,0 +1,70 @@
+//go:build redis_unit_tests
+// +build redis_unit_tests
+
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeRedisLock(t *testing.T, identity string) *redisLock {
+	redisConfig := config.RedisConfig{}
+	redisConfig.URL, _ = configtypes.NewOptURLAbsoluteFromString("redis://127.0.0.1:6379")
+	lock, err := newRedisLock(redisConfig, "leader-test", identity, time.Second)
+	require.NoError(t, err)
+	return lock
+}
+
+func TestRedisLockIsHeldByOneInstance(t *testing.T) {
+	lockA, lockB := makeRedisLock(t, "a"), makeRedisLock(t, "b")
+	defer lockA.Close()
+	defer lockB.Close()
+	ctx := context.Background()
+	require.NoError(t, lockA.client.Del(ctx, lockA.key).Err())
+
+	acquired, err := lockA.Acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	acquired, err = lockA.Acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	acquired, err = lockB.Acquire(ctx)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	require.NoError(t, lockB.Release(ctx)) // not the holder, so does nothing
+	acquired, err = lockB.Acquire(ctx)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	require.NoError(t, lockA.Release(ctx))
+	acquired, err = lockB.Acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+func TestRedisLockExpires(t *testing.T) {
+	lockA, lockB := makeRedisLock(t, "a"), makeRedisLock(t, "b")
+	defer lockA.Close()
+	defer lockB.Close()
+	ctx := context.Background()
+	require.NoError(t, lockA.client.Del(ctx, lockA.key).Err())
+
+	acquired, err := lockA.Acquire(ctx)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	time.Sleep(time.Millisecond * 1100)
+	acquired, err = lockB.Acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
@@ -0,0 +1,3 @@
+// Package leader contains the optional leader election between Relay instances that share a persistent
+// store, which lets one of them be the only one that writes to the store and forwards events.
+package leader
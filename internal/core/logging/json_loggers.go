@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+// Component identifies a Relay subsystem whose log level can be configured separately.
+type Component string
+
+const (
+	// ComponentSDKs is the Go SDK instances that Relay uses to connect to LaunchDarkly.
+	ComponentSDKs Component = "sdks"
+
+	// ComponentEvents is the forwarding of analytics and diagnostic events.
+	ComponentEvents Component = "events"
+
+	// ComponentBigSegments is the big segment stores and the synchronization of big segment data.
+	ComponentBigSegments Component = "bigsegments"
+
+	// ComponentHTTP is the handling of requests to Relay's endpoints, including stream connections.
+	ComponentHTTP Component = "http"
+)
+
+// Fields are the properties that a Loggers instance created by MakeJSONLoggers adds to every message.
+type Fields struct {
+	// Component is the subsystem that produced the message, if any.
+	Component Component
+
+	// Env is the name of the environment that the message is about, if any.
+	Env string
+
+	// Extra contains any other properties. They are written to the "fields" property.
+	Extra map[string]string
+}
+
+// jsonMessage is the format of each line of JSON log output.
+type jsonMessage struct {
+	Timestamp string            `json:"timestamp"`
+	Level     string            `json:"level"`
+	Component Component         `json:"component,omitempty"`
+	Env       string            `json:"env,omitempty"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+// jsonOutput is shared by every jsonLogger that writes to the same destination, so that messages
+// from different goroutines are never interleaved.
+type jsonOutput struct {
+	writer io.Writer
+	lock   sync.Mutex
+}
+
+var (
+	jsonStdout = &jsonOutput{writer: os.Stdout} //nolint:gochecknoglobals
+	jsonStderr = &jsonOutput{writer: os.Stderr} //nolint:gochecknoglobals
+)
+
+type jsonLogger struct {
+	output *jsonOutput
+	fields Fields
+	now    func() time.Time
+}
+
+// MakeJSONLoggers returns a Loggers instance that writes each message as a single line of JSON, with
+// the properties "timestamp", "level", "component", "env", "message", and "fields". The last three are
+// omitted if they are empty. As with MakeDefaultLoggers, output goes to stdout, except Error level
+// which goes to stderr, and Debug level is disabled.
+func MakeJSONLoggers(fields Fields) ldlog.Loggers {
+	loggers := ldlog.NewDefaultLoggers()
+	loggers.SetBaseLogger(newJSONLogger(jsonStdout, fields))
+	loggers.SetBaseLoggerForLevel(ldlog.Error, newJSONLogger(jsonStderr, fields))
+	loggers.SetMinLevel(ldlog.Info)
+	return loggers
+}
+
+func newJSONLogger(output *jsonOutput, fields Fields) *jsonLogger {
+	return &jsonLogger{output: output, fields: fields, now: time.Now}
+}
+
+func (l *jsonLogger) Println(values ...interface{}) {
+	l.write(strings.TrimSuffix(fmt.Sprintln(values...), "\n"))
+}
+
+func (l *jsonLogger) Printf(format string, values ...interface{}) {
+	l.write(fmt.Sprintf(format, values...))
+}
+
+// write parses the "LEVEL:" prefix that ldlog.Loggers adds to each message, so that the level can be
+// a separate property.
+func (l *jsonLogger) write(text string) {
+	level := ""
+	if p := strings.Index(text, ":"); p > 0 {
+		switch name := text[:p]; name {
+		case "DEBUG", "INFO", "WARN", "ERROR":
+			level = strings.ToLower(name)
+			text = strings.TrimPrefix(text[p+1:], " ")
+		}
+	}
+	data, err := json.Marshal(jsonMessage{
+		Timestamp: l.now().UTC().Format(time.RFC3339Nano),
+		Level:     level,
+		Component: l.fields.Component,
+		Env:       l.fields.Env,
+		Message:   text,
+		Fields:    l.fields.Extra,
+	})
+	if err != nil {
+		return // COVERAGE: can't happen, all of the properties are strings
+	}
+	l.output.lock.Lock()
+	_, _ = l.output.writer.Write(append(data, '\n'))
+	l.output.lock.Unlock()
+}
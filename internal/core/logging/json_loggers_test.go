@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTestJSONLoggers(fields Fields) (ldlog.Loggers, *bytes.Buffer) {
+	buf := bytes.NewBuffer(nil)
+	logger := newJSONLogger(&jsonOutput{writer: buf}, fields)
+	logger.now = func() time.Time { return time.Date(2021, 6, 7, 8, 9, 10, 11000000, time.FixedZone("", 3600)) }
+	loggers := ldlog.NewDefaultLoggers()
+	loggers.SetBaseLogger(logger)
+	loggers.SetMinLevel(ldlog.Debug)
+	return loggers, buf
+}
+
+func TestJSONLoggers(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		loggers, buf := makeTestJSONLoggers(Fields{
+			Component: ComponentBigSegments,
+			Env:       "my env",
+			Extra:     map[string]string{"key": "...abcd"},
+		})
+		loggers.Warnf("store is %s", "stale")
+		assert.JSONEq(t, `{"timestamp": "2021-06-07T07:09:10.011Z", "level": "warn", "component": "bigsegments",
+			"env": "my env", "message": "store is stale", "fields": {"key": "...abcd"}}`, buf.String())
+	})
+
+	t.Run("no fields", func(t *testing.T) {
+		loggers, buf := makeTestJSONLoggers(Fields{})
+		loggers.Debug("a", "b")
+		assert.JSONEq(t, `{"timestamp": "2021-06-07T07:09:10.011Z", "level": "debug", "message": "a b"}`,
+			buf.String())
+	})
+
+	t.Run("prefix is part of message", func(t *testing.T) {
+		loggers, buf := makeTestJSONLoggers(Fields{})
+		loggers.SetPrefix("S3BigSegmentStore:")
+		loggers.Info("hello")
+		assert.JSONEq(t, `{"timestamp": "2021-06-07T07:09:10.011Z", "level": "info",
+			"message": "S3BigSegmentStore: hello"}`, buf.String())
+	})
+
+	t.Run("one line per message", func(t *testing.T) {
+		loggers, buf := makeTestJSONLoggers(Fields{})
+		loggers.Error("first\nline")
+		loggers.Error("second")
+		assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("\n")))
+	})
+}
+
+func TestMakeJSONLoggers(t *testing.T) {
+	loggers := MakeJSONLoggers(Fields{})
+	assert.Equal(t, ldlog.Info, loggers.GetMinLevel())
+}
@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+var allLogLevels = []ldlog.LogLevel{ldlog.Debug, ldlog.Info, ldlog.Warn, ldlog.Error} //nolint:gochecknoglobals
+
+// LevelSwitch is a minimum log level that can be changed while Relay is running.
+//
+// ldlog.Loggers is copied by value, and each copy has its own minimum level, so changing the level of
+// one copy has no effect on the copies that components such as streams and data stores are already
+// holding. Loggers that are returned by Apply check the LevelSwitch for each message instead, so they
+// all follow it.
+//
+// A LevelSwitch that was created with Child has the same level as its parent until SetLevel is called
+// on it, and again after Inherit is called.
+type LevelSwitch struct {
+	level  int32
+	parent *LevelSwitch
+}
+
+type switchedLogger struct {
+	level  ldlog.LogLevel
+	prefix string
+	dest   ldlog.BaseLogger
+	sw     *LevelSwitch
+}
+
+// NewLevelSwitch creates a LevelSwitch with the specified level.
+func NewLevelSwitch(level ldlog.LogLevel) *LevelSwitch {
+	return &LevelSwitch{level: int32(level)}
+}
+
+// Child creates a LevelSwitch that has this LevelSwitch's level unless it is given its own.
+func (s *LevelSwitch) Child() *LevelSwitch {
+	return &LevelSwitch{parent: s}
+}
+
+// GetLevel returns the current level.
+func (s *LevelSwitch) GetLevel() ldlog.LogLevel {
+	if level := atomic.LoadInt32(&s.level); level != 0 || s.parent == nil {
+		return ldlog.LogLevel(level)
+	}
+	return s.parent.GetLevel()
+}
+
+// SetLevel changes the level.
+func (s *LevelSwitch) SetLevel(level ldlog.LogLevel) {
+	atomic.StoreInt32(&s.level, int32(level))
+}
+
+// Inherit makes a LevelSwitch that was created with Child use its parent's level again. It has no
+// effect on a LevelSwitch that has no parent.
+func (s *LevelSwitch) Inherit() {
+	if s.parent != nil {
+		atomic.StoreInt32(&s.level, 0)
+	}
+}
+
+// Apply returns a copy of loggers whose minimum level is the LevelSwitch's level at the time that each
+// message is logged. Calling SetPrefix on the copy works as it does for any Loggers, but calling
+// SetMinLevel on it makes it use that level instead.
+//
+// Since Debug output may be turned on at any time, IsDebugEnabled is always true for the copy.
+func (s *LevelSwitch) Apply(loggers ldlog.Loggers) ldlog.Loggers {
+	dest := loggers
+	dest.SetPrefix("")
+	dest.SetMinLevel(ldlog.Debug)
+	ret := loggers
+	ret.SetMinLevel(ldlog.Debug)
+	for _, level := range allLogLevels {
+		ret.SetBaseLoggerForLevel(level, switchedLogger{
+			level:  level,
+			prefix: strings.ToUpper(level.Name()) + ":",
+			dest:   dest.ForLevel(level),
+			sw:     s,
+		})
+	}
+	return ret
+}
+
+// Println and Printf receive the output of the copy's own level logger, which starts with the level
+// name; that is removed, since dest adds it again along with the rest of the original Loggers'
+// configuration.
+
+func (l switchedLogger) Println(values ...interface{}) {
+	if l.level < l.sw.GetLevel() {
+		return
+	}
+	if len(values) != 0 {
+		if s, ok := values[0].(string); ok && strings.HasPrefix(s, l.prefix) {
+			if rest := strings.TrimPrefix(strings.TrimPrefix(s, l.prefix), " "); rest != "" {
+				values[0] = rest
+			} else {
+				values = values[1:]
+			}
+		}
+	}
+	l.dest.Println(values...)
+}
+
+func (l switchedLogger) Printf(format string, values ...interface{}) {
+	if l.level < l.sw.GetLevel() {
+		return
+	}
+	l.dest.Printf(strings.TrimPrefix(format, l.prefix+" "), values...)
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTestTextLoggers() (ldlog.Loggers, *bytes.Buffer) {
+	buf := bytes.NewBuffer(nil)
+	loggers := ldlog.NewDefaultLoggers()
+	loggers.SetBaseLogger(log.New(buf, "", 0))
+	return loggers, buf
+}
+
+func TestLevelSwitch(t *testing.T) {
+	t.Run("level can be changed after copies are made", func(t *testing.T) {
+		base, buf := makeTestTextLoggers()
+		sw := NewLevelSwitch(ldlog.Info)
+		loggers := sw.Apply(base)
+		loggersCopy := loggers
+
+		loggersCopy.Debug("a")
+		sw.SetLevel(ldlog.Debug)
+		loggersCopy.Debug("b")
+		sw.SetLevel(ldlog.Error)
+		loggersCopy.Warn("c")
+		loggersCopy.Error("d")
+		assert.Equal(t, "DEBUG: b\nERROR: d\n", buf.String())
+	})
+
+	t.Run("output is the same as the original Loggers", func(t *testing.T) {
+		base, buf := makeTestTextLoggers()
+		base.SetPrefix("[env: x]")
+		loggers := NewLevelSwitch(ldlog.Info).Apply(base)
+		loggers.Info("hello")
+		loggers.Infof("hello %s", "there")
+		loggers.Warn("a", "b")
+		loggers.SetPrefix("Store:")
+		loggers.Error("failed")
+		loggers.Errorf("failed %d", 2)
+		assert.Equal(t, "INFO: [env: x] hello\nINFO: [env: x] hello there\nWARN: [env: x] a b\n"+
+			"ERROR: Store: failed\nERROR: Store: failed 2\n", buf.String())
+	})
+
+	t.Run("output without a prefix", func(t *testing.T) {
+		base, buf := makeTestTextLoggers()
+		loggers := NewLevelSwitch(ldlog.Info).Apply(base)
+		loggers.Info("hello")
+		loggers.Infof("hello %s", "there")
+		assert.Equal(t, "INFO: hello\nINFO: hello there\n", buf.String())
+	})
+
+	t.Run("child inherits level until it has its own", func(t *testing.T) {
+		parent := NewLevelSwitch(ldlog.Info)
+		child := parent.Child()
+		assert.Equal(t, ldlog.Info, child.GetLevel())
+		parent.SetLevel(ldlog.Warn)
+		assert.Equal(t, ldlog.Warn, child.GetLevel())
+		child.SetLevel(ldlog.Debug)
+		parent.SetLevel(ldlog.Error)
+		assert.Equal(t, ldlog.Debug, child.GetLevel())
+		child.Inherit()
+		assert.Equal(t, ldlog.Error, child.GetLevel())
+	})
+}
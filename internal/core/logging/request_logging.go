@@ -1,6 +1,9 @@
 package logging
 
 import (
+	"bufio"
+	"errors"
+	"net"
 	"net/http"
 	"strings"
 
@@ -93,3 +96,13 @@ func (w *loggingHTTPResponseWriter) Flush() {
 		f.Flush()
 	}
 }
+
+// It also implements http.Hijacker if the underlying writer does, so that streams can be upgraded to
+// WebSocket connections.
+
+func (w *loggingHTTPResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.writer.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
@@ -0,0 +1,137 @@
+package memguard
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+const bytesPerMB = 1024 * 1024
+
+// Guard periodically checks how much memory Relay is using. When that is above the high-water mark, it
+// is "shedding": IsShedding returns true, so that new stream connections can be rejected, and on each
+// check it calls the shrink function to discard cached data and then forces a garbage collection. It stops
+// shedding once memory use is below the low-water mark; the gap between the two keeps it from switching
+// back and forth on every check.
+//
+// Memory use is measured as the memory that the Go runtime has obtained from the operating system and
+// not released, which is close to what the operating system counts against the process.
+//
+// All of its methods can be called on a nil *Guard, which never sheds load.
+type Guard struct {
+	highWaterMark uint64
+	lowWaterMark  uint64
+	interval      time.Duration
+	shrink        func()
+	metricsCtx    context.Context
+	loggers       ldlog.Loggers
+	readMemory    func() uint64
+	freeMemory    func()
+	shedding      int32
+	closeOnce     sync.Once
+	closeCh       chan struct{}
+}
+
+// NewGuard creates a Guard from the configuration, or returns nil if there is no high-water mark. Shedding
+// events are recorded in metrics using metricsCtx, which should be the metrics Manager's OpenCensus context.
+// The Guard does nothing until Start is called.
+func NewGuard(
+	c config.MemoryLimitsConfig,
+	shrink func(),
+	metricsCtx context.Context,
+	loggers ldlog.Loggers,
+) *Guard {
+	if !c.HighWaterMarkMB.IsDefined() {
+		return nil
+	}
+	highWaterMark := uint64(c.HighWaterMarkMB.GetOrElse(0)) * bytesPerMB
+	lowWaterMark := uint64(c.LowWaterMarkMB.GetOrElse(0)) * bytesPerMB
+	if lowWaterMark == 0 {
+		lowWaterMark = highWaterMark / 100 * config.DefaultMemoryLowWaterMarkPercent
+	}
+	return &Guard{
+		highWaterMark: highWaterMark,
+		lowWaterMark:  lowWaterMark,
+		interval:      c.CheckInterval.GetOrElse(config.DefaultMemoryCheckInterval),
+		shrink:        shrink,
+		metricsCtx:    metricsCtx,
+		loggers:       loggers,
+		readMemory:    readMemoryInUse,
+		freeMemory:    debug.FreeOSMemory,
+		closeCh:       make(chan struct{}),
+	}
+}
+
+func readMemoryInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.Sys - stats.HeapReleased
+}
+
+// Start begins checking memory use on a separate goroutine, until Close is called.
+func (g *Guard) Start() {
+	if g == nil {
+		return
+	}
+	g.loggers.Infof("Memory guardrails enabled (high-water mark: %dMB, low-water mark: %dMB)",
+		g.highWaterMark/bytesPerMB, g.lowWaterMark/bytesPerMB)
+	go func() {
+		ticker := time.NewTicker(g.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-g.closeCh:
+				return
+			case <-ticker.C:
+				g.check()
+			}
+		}
+	}()
+}
+
+// IsShedding returns true if memory use is currently too high.
+func (g *Guard) IsShedding() bool {
+	return g != nil && atomic.LoadInt32(&g.shedding) != 0
+}
+
+// Close stops checking memory use.
+func (g *Guard) Close() {
+	if g == nil {
+		return
+	}
+	g.closeOnce.Do(func() {
+		close(g.closeCh)
+	})
+}
+
+func (g *Guard) check() {
+	inUse := g.readMemory()
+	switch {
+	case inUse >= g.highWaterMark:
+		if atomic.CompareAndSwapInt32(&g.shedding, 0, 1) {
+			g.loggers.Warnf("Memory use (%dMB) is above the high-water mark; rejecting new stream connections and discarding caches",
+				inUse/bytesPerMB)
+			metrics.RecordMemorySheddingStarted(g.metricsCtx)
+		}
+		// Caches can fill up again while we are shedding, so we keep shrinking them until memory use is
+		// back to normal.
+		if g.shrink != nil {
+			g.shrink()
+		}
+		g.freeMemory()
+	case inUse < g.lowWaterMark:
+		if atomic.CompareAndSwapInt32(&g.shedding, 1, 0) {
+			g.loggers.Infof("Memory use (%dMB) is below the low-water mark; accepting new stream connections again",
+				inUse/bytesPerMB)
+			metrics.RecordMemorySheddingEnded(g.metricsCtx)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package memguard
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustOptIntGreaterThanZero(n int) ct.OptIntGreaterThanZero {
+	o, err := ct.NewOptIntGreaterThanZero(n)
+	if err != nil {
+		panic(err)
+	}
+	return o
+}
+
+type testGuard struct {
+	*Guard
+	inUse   uint64
+	shrinks int
+	frees   int
+}
+
+func makeTestGuard(c config.MemoryLimitsConfig) *testGuard {
+	tg := &testGuard{}
+	tg.Guard = NewGuard(c, func() { tg.shrinks++ }, context.Background(), ldlog.NewDisabledLoggers())
+	tg.readMemory = func() uint64 { return tg.inUse }
+	tg.freeMemory = func() { tg.frees++ }
+	return tg
+}
+
+func TestNewGuardReturnsNilWithoutHighWaterMark(t *testing.T) {
+	g := NewGuard(config.MemoryLimitsConfig{}, nil, context.Background(), ldlog.NewDisabledLoggers())
+	assert.Nil(t, g)
+	g.Start()
+	assert.False(t, g.IsShedding())
+	g.Close()
+}
+
+func TestNewGuardDefaults(t *testing.T) {
+	g := NewGuard(config.MemoryLimitsConfig{HighWaterMarkMB: mustOptIntGreaterThanZero(1000)}, nil,
+		context.Background(), ldlog.NewDisabledLoggers())
+	require.NotNil(t, g)
+	assert.Equal(t, uint64(1000*bytesPerMB), g.highWaterMark)
+	assert.Equal(t, uint64(900*bytesPerMB), g.lowWaterMark)
+	assert.Equal(t, config.DefaultMemoryCheckInterval, g.interval)
+}
+
+func TestGuardShedsLoadAboveHighWaterMarkUntilBelowLowWaterMark(t *testing.T) {
+	g := makeTestGuard(config.MemoryLimitsConfig{
+		HighWaterMarkMB: mustOptIntGreaterThanZero(100),
+		LowWaterMarkMB:  mustOptIntGreaterThanZero(80),
+	})
+
+	g.inUse = 99 * bytesPerMB
+	g.check()
+	assert.False(t, g.IsShedding())
+	assert.Equal(t, 0, g.shrinks)
+
+	g.inUse = 100 * bytesPerMB
+	g.check()
+	assert.True(t, g.IsShedding())
+	assert.Equal(t, 1, g.shrinks)
+	assert.Equal(t, 1, g.frees)
+
+	g.inUse = 120 * bytesPerMB
+	g.check()
+	assert.True(t, g.IsShedding())
+	assert.Equal(t, 2, g.shrinks)
+	assert.Equal(t, 2, g.frees)
+
+	// Between the two marks, it keeps shedding, but does not need to discard anything more.
+	g.inUse = 90 * bytesPerMB
+	g.check()
+	assert.True(t, g.IsShedding())
+	assert.Equal(t, 2, g.shrinks)
+
+	g.inUse = 79 * bytesPerMB
+	g.check()
+	assert.False(t, g.IsShedding())
+
+	g.inUse = 90 * bytesPerMB
+	g.check()
+	assert.False(t, g.IsShedding())
+	assert.Equal(t, 2, g.shrinks)
+}
+
+func TestGuardChecksMemoryPeriodically(t *testing.T) {
+	g := NewGuard(config.MemoryLimitsConfig{
+		HighWaterMarkMB: mustOptIntGreaterThanZero(1),
+		CheckInterval:   ct.NewOptDuration(time.Millisecond * 10),
+	}, nil, context.Background(), ldlog.NewDisabledLoggers())
+	shrunk := make(chan struct{}, 100)
+	g.shrink = func() { shrunk <- struct{}{} }
+	g.readMemory = func() uint64 { return 2 * bytesPerMB }
+	g.freeMemory = func() {}
+
+	g.Start()
+	defer g.Close()
+	select {
+	case <-shrunk:
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "timed out waiting for memory check")
+	}
+	assert.True(t, g.IsShedding())
+}
@@ -0,0 +1,3 @@
+// Package memguard contains the optional memory guardrails, which make Relay shed load when its memory
+// use is too high, instead of continuing to grow until the process is killed.
+package memguard
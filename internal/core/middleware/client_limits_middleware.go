@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/application"
+	"github.com/launchdarkly/ld-relay/v6/internal/util"
+
+	"github.com/gorilla/mux"
+)
+
+// LimitRequestBody creates a middleware function that rejects a request with a 413 status if its body
+// is larger than maxBytes.
+//
+// The body is read in full before the next handler is called, so that an oversized body is rejected
+// before any of it is processed; the next handler receives a copy of it. At most maxBytes+1 bytes are
+// ever read, so a client cannot make the Relay Proxy buffer an arbitrarily large payload.
+func LimitRequestBody(maxBytes int) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Body == nil || req.Body == http.NoBody {
+				next.ServeHTTP(w, req)
+				return
+			}
+			if req.ContentLength > int64(maxBytes) {
+				writeBodyTooLarge(w)
+				return
+			}
+			body, err := ioutil.ReadAll(io.LimitReader(req.Body, int64(maxBytes)+1))
+			_ = req.Body.Close()
+			if err == nil && len(body) > maxBytes {
+				writeBodyTooLarge(w)
+				return
+			}
+			// If there was an I/O error, we pass along what we got so the next handler can report an
+			// error in the usual way.
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+func writeBodyTooLarge(w http.ResponseWriter) {
+	// Since we are not reading the rest of the body, the connection can't be reused.
+	w.Header().Set("Connection", "close")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	_, _ = w.Write(util.ErrorJSONMsg("request body too large"))
+}
+
+// ConnTimeouts creates a middleware function that protects against slow clients by setting deadlines
+// on the underlying connection. It only has an effect on HTTP/1.x requests received by a server that
+// was created with application.StartHTTPServer.
+//
+// If readTimeout is nonzero, the client must send the entire request body within that time. Requests
+// without a body are not affected, so a client that holds a stream open is not disconnected.
+//
+// If writeTimeout is nonzero, each write to the client must complete within that time. The deadline
+// is renewed for every write, rather than applying to the whole response, so that streams can stay
+// open indefinitely as long as the client keeps reading them.
+func ConnTimeouts(readTimeout, writeTimeout time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if readTimeout == 0 && writeTimeout == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			conn := application.GetConn(req.Context())
+			if conn == nil || req.ProtoMajor != 1 {
+				next.ServeHTTP(w, req)
+				return
+			}
+			if readTimeout > 0 && req.Body != nil && req.Body != http.NoBody {
+				_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+				req.Body = &deadlineClearingBody{ReadCloser: req.Body, conn: conn}
+			}
+			if writeTimeout > 0 {
+				dw := &deadlineWriter{writer: w, conn: conn, timeout: writeTimeout}
+				dw.renewDeadline()
+				w = dw
+				// Renew it once more for whatever net/http writes after the handler returns.
+				defer func() {
+					if !dw.hijacked {
+						dw.renewDeadline()
+					}
+				}()
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// deadlineClearingBody removes the read deadline once the body has been fully read, since net/http
+// keeps reading from the connection afterward to detect when the client goes away.
+type deadlineClearingBody struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (b *deadlineClearingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		_ = b.conn.SetReadDeadline(time.Time{})
+	}
+	return n, err
+}
+
+type deadlineWriter struct {
+	writer   http.ResponseWriter
+	conn     net.Conn
+	timeout  time.Duration
+	hijacked bool
+}
+
+func (w *deadlineWriter) renewDeadline() {
+	_ = w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+}
+
+func (w *deadlineWriter) Header() http.Header {
+	return w.writer.Header()
+}
+
+func (w *deadlineWriter) Write(data []byte) (int, error) {
+	w.renewDeadline()
+	return w.writer.Write(data)
+}
+
+func (w *deadlineWriter) WriteHeader(statusCode int) {
+	w.renewDeadline()
+	w.writer.WriteHeader(statusCode)
+}
+
+// The wrapped writer also has to implement http.Flusher for streams, and http.Hijacker for WebSocket
+// connections.
+
+func (w *deadlineWriter) Flush() {
+	w.renewDeadline()
+	if f, ok := w.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *deadlineWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.writer.(http.Hijacker); ok {
+		// Once the connection is hijacked, its deadlines are up to the new owner.
+		w.hijacked = true
+		_ = w.conn.SetWriteDeadline(time.Time{})
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
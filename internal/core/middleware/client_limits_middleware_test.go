@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/application"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func bodyEchoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		_, _ = w.Write(body)
+	})
+}
+
+func TestLimitRequestBody(t *testing.T) {
+	handler := LimitRequestBody(10)(bodyEchoHandler())
+
+	t.Run("body within limit is passed along", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("0123456789"))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.Equal(t, "0123456789", w.Body.String())
+	})
+
+	t.Run("body with Content-Length over limit is rejected", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("0123456789a"))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Result().StatusCode)
+	})
+
+	t.Run("body without Content-Length over limit is rejected", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", ioutil.NopCloser(strings.NewReader("0123456789a")))
+		req.ContentLength = -1
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Result().StatusCode)
+	})
+}
+
+func startConnTimeoutsServer(t *testing.T, readTimeout, writeTimeout time.Duration, handler http.Handler) (int, func()) {
+	port := st.GetAvailablePort(t)
+	server, _ := application.StartHTTPServer(nil, port, ConnTimeouts(readTimeout, writeTimeout)(handler),
+		nil, 0, false, false, 0, 0, 0, ldlog.NewDisabledLoggers())
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err == nil
+	}, time.Second, time.Millisecond*10)
+	return port, func() { _ = server.Close() }
+}
+
+func TestConnTimeoutsClosesConnectionIfBodyIsTooSlow(t *testing.T) {
+	bodyErrCh := make(chan error, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, err := ioutil.ReadAll(req.Body)
+		bodyErrCh <- err
+	})
+	port, closer := startConnTimeoutsServer(t, time.Millisecond*100, 0, handler)
+	defer closer()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 10\r\n\r\n01234"))
+	require.NoError(t, err)
+
+	select {
+	case err := <-bodyErrCh:
+		assert.Error(t, err)
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "timed out waiting for handler")
+	}
+}
+
+func TestConnTimeoutsDoesNotCloseStreamWithoutBody(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for i := 0; i < 3; i++ {
+			_, _ = w.Write([]byte("data\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(time.Millisecond * 100)
+		}
+	})
+	port, closer := startConnTimeoutsServer(t, time.Millisecond*50, time.Second, handler)
+	defer closer()
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d", port))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte("data\n"), 3), body)
+}
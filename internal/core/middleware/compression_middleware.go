@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/mux"
+)
+
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// Compress creates a middleware function that compresses response bodies with brotli or gzip, if the
+// request's Accept-Encoding header allows it; brotli is preferred if both are allowed. Only successful
+// responses of at least minSize bytes are compressed, since smaller ones would barely get any smaller.
+//
+// This buffers up to minSize bytes of the response before deciding whether to compress it, so it should
+// not be used for streaming endpoints.
+func Compress(minSize int) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := selectEncoding(req.Header.Get("Accept-Encoding"))
+			if encoding == "" {
+				next.ServeHTTP(w, req)
+				return
+			}
+			cw := &compressingWriter{writer: w, encoding: encoding, minSize: minSize}
+			if inm := req.Header.Get("If-None-Match"); strings.Contains(inm, etagEncodingSuffix(encoding)+`"`) {
+				req.Header.Set("If-None-Match", strings.ReplaceAll(inm, etagEncodingSuffix(encoding)+`"`, `"`))
+				cw.cachedCompressed = true
+			}
+			next.ServeHTTP(cw, req)
+			cw.finish()
+		})
+	}
+}
+
+// etagEncodingSuffix is added to a strong ETag when the response is compressed, since a strong ETag
+// identifies the exact bytes of a response. It is removed from If-None-Match before the handler sees it,
+// so the handler can compare it to the ETag of the uncompressed response.
+func etagEncodingSuffix(encoding string) string {
+	return "-" + encoding
+}
+
+// selectEncoding returns the content encoding to use for an Accept-Encoding header, or "" if the response
+// should not be compressed.
+func selectEncoding(acceptEncoding string) string {
+	var brotliAllowed, gzipAllowed bool
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params := part, ""
+		if i := strings.Index(part, ";"); i >= 0 {
+			name, params = part[:i], part[i+1:]
+		}
+		if !qualityIsNonZero(params) {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case encodingBrotli:
+			brotliAllowed = true
+		case encodingGzip:
+			gzipAllowed = true
+		}
+	}
+	switch {
+	case brotliAllowed:
+		return encodingBrotli
+	case gzipAllowed:
+		return encodingGzip
+	default:
+		return ""
+	}
+}
+
+// qualityIsNonZero returns false if the parameters of an Accept-Encoding entry are "q=0", which means the
+// client does not accept that encoding.
+func qualityIsNonZero(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			q, err := strconv.ParseFloat(param[2:], 64)
+			return err == nil && q > 0
+		}
+	}
+	return true
+}
+
+// compressingWriter holds on to the response until it has at least minSize bytes, at which point it
+// starts compressing it; if the handler finishes before then, or sets an error status, the response is
+// passed through as is.
+type compressingWriter struct {
+	writer           http.ResponseWriter
+	encoding         string
+	minSize          int
+	statusCode       int
+	buffered         []byte
+	compressor       io.WriteCloser
+	passThrough      bool
+	cachedCompressed bool // true if If-None-Match had the ETag of a compressed response
+}
+
+func (w *compressingWriter) Header() http.Header {
+	return w.writer.Header()
+}
+
+func (w *compressingWriter) Write(data []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	switch {
+	case w.compressor != nil:
+		return w.compressor.Write(data)
+	case w.passThrough:
+		return w.writer.Write(data)
+	}
+	w.buffered = append(w.buffered, data...)
+	if len(w.buffered) >= w.minSize {
+		if err := w.flushBuffered(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressingWriter) WriteHeader(statusCode int) {
+	if w.statusCode != 0 {
+		return
+	}
+	w.statusCode = statusCode
+	if statusCode != http.StatusOK {
+		if statusCode == http.StatusNotModified && w.cachedCompressed {
+			w.addETagEncodingSuffix() // the ETag must be the one for the compressed response that was cached
+		}
+		w.passThrough = true
+		w.writer.WriteHeader(statusCode)
+	}
+}
+
+// flushBuffered writes the response header and the buffered part of the body, compressing it and the
+// rest of the body if compress is true and the handler did not already encode the body itself.
+func (w *compressingWriter) flushBuffered(compress bool) error {
+	header := w.writer.Header()
+	if compress && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.addETagEncodingSuffix()
+		w.writer.WriteHeader(http.StatusOK)
+		if w.encoding == encodingBrotli {
+			w.compressor = brotli.NewWriter(w.writer)
+		} else {
+			w.compressor = gzip.NewWriter(w.writer)
+		}
+		_, err := w.compressor.Write(w.buffered)
+		w.buffered = nil
+		return err
+	}
+	w.passThrough = true
+	w.writer.WriteHeader(http.StatusOK)
+	_, err := w.writer.Write(w.buffered)
+	w.buffered = nil
+	return err
+}
+
+func (w *compressingWriter) addETagEncodingSuffix() {
+	header := w.writer.Header()
+	if etag := header.Get("Etag"); len(etag) >= 2 && strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`) {
+		header.Set("Etag", strings.TrimSuffix(etag, `"`)+etagEncodingSuffix(w.encoding)+`"`)
+	}
+}
+
+func (w *compressingWriter) finish() {
+	switch {
+	case w.compressor != nil:
+		_ = w.compressor.Close()
+	case !w.passThrough && w.statusCode != 0:
+		_ = w.flushBuffered(false)
+	}
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compressionTestHandler(status int, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+		}
+		// write in two parts to make sure that buffering works
+		_, _ = w.Write([]byte(body[:len(body)/2]))
+		_, _ = w.Write([]byte(body[len(body)/2:]))
+	})
+}
+
+func doCompressionRequest(handler http.Handler, acceptEncoding string) *http.Response {
+	req, _ := http.NewRequest("GET", "", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func TestCompressUsesGzip(t *testing.T) {
+	body := strings.Repeat("x", 200)
+	resp := doCompressionRequest(Compress(100)(compressionTestHandler(http.StatusOK, body)), "gzip, deflate")
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	gr, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	decoded, err := ioutil.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+}
+
+func TestCompressPrefersBrotli(t *testing.T) {
+	body := strings.Repeat("x", 200)
+	resp := doCompressionRequest(Compress(100)(compressionTestHandler(http.StatusOK, body)), "gzip, br")
+
+	assert.Equal(t, "br", resp.Header.Get("Content-Encoding"))
+	decoded, err := ioutil.ReadAll(brotli.NewReader(resp.Body))
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+}
+
+func TestCompressIgnoresEncodingWithZeroQuality(t *testing.T) {
+	body := strings.Repeat("x", 200)
+	resp := doCompressionRequest(Compress(100)(compressionTestHandler(http.StatusOK, body)), "br;q=0, gzip;q=0.5")
+
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+}
+
+func TestCompressDoesNotCompressIfNotAccepted(t *testing.T) {
+	body := strings.Repeat("x", 200)
+	for _, acceptEncoding := range []string{"", "identity", "deflate"} {
+		t.Run(acceptEncoding, func(t *testing.T) {
+			resp := doCompressionRequest(Compress(100)(compressionTestHandler(http.StatusOK, body)), acceptEncoding)
+
+			assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+			data, _ := ioutil.ReadAll(resp.Body)
+			assert.Equal(t, body, string(data))
+		})
+	}
+}
+
+func TestCompressDoesNotCompressResponseSmallerThanMinSize(t *testing.T) {
+	body := strings.Repeat("x", 99)
+	resp := doCompressionRequest(Compress(100)(compressionTestHandler(http.StatusOK, body)), "gzip")
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+	data, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, body, string(data))
+}
+
+func TestCompressDoesNotCompressErrorResponse(t *testing.T) {
+	body := strings.Repeat("x", 200)
+	resp := doCompressionRequest(Compress(100)(compressionTestHandler(http.StatusInternalServerError, body)), "gzip")
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+	data, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, body, string(data))
+}
+
+func TestCompressPassesThroughEmptyResponse(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+	resp := doCompressionRequest(Compress(100)(handler), "gzip")
+
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+}
+
+func etagTestHandler(etag, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Etag", etag)
+		if req.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	})
+}
+
+func TestCompressAddsEncodingToStrongETag(t *testing.T) {
+	body := strings.Repeat("x", 200)
+	resp := doCompressionRequest(Compress(100)(etagTestHandler(`"relay-1"`, body)), "gzip")
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, `"relay-1-gzip"`, resp.Header.Get("Etag"))
+
+	resp = doCompressionRequest(Compress(100)(etagTestHandler(`"relay-1"`, "x")), "gzip")
+	assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, `"relay-1"`, resp.Header.Get("Etag"))
+}
+
+func TestCompressMatchesIfNoneMatchForCompressedResponse(t *testing.T) {
+	handler := Compress(100)(etagTestHandler(`"relay-1"`, strings.Repeat("x", 200)))
+	for _, etag := range []string{`"relay-1-gzip"`, `"relay-1"`} {
+		req, _ := http.NewRequest("GET", "", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("If-None-Match", etag)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Equal(t, etag, w.Header().Get("Etag"))
+	}
+}
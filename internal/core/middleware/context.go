@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/jwtauth"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
 )
 
@@ -19,6 +20,14 @@ type EnvContextInfo struct {
 
 	// Credential is the SDK key, mobile key, or environment ID that was used in the request.
 	Credential config.SDKCredential
+
+	// TokenClaims are the claims from the request's JWT, if the environment requires one (see
+	// relayenv.EnvContext.GetJWTVerifier); otherwise nil.
+	TokenClaims jwtauth.Claims
+
+	// CanaryOf is the environment that the credential belongs to, if the request was routed to Env
+	// because it had that environment's canary header (see relayenv.CanaryRoute); otherwise nil.
+	CanaryOf relayenv.EnvContext
 }
 
 // GetEnvContextInfo returns the EnvContextInfo that is attached to the specified Context (normally
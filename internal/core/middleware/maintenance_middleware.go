@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/gorilla/mux"
+)
+
+// Maintenance is a middleware function that rejects every request while isInMaintenance returns true,
+// except for requests that matched one of the exempt routes, such as the status and health endpoints.
+// They are rejected with a 503 status, Retry-After set to MaintenanceConfig.RetryAfter, and the body and
+// content type from MaintenanceConfig if any, so that load balancers stop sending traffic to this
+// instance; stream connections that were already open are not affected. It must be applied with
+// Router.Use, so that the matched route is known.
+func Maintenance(isInMaintenance func() bool, c config.MaintenanceConfig, exempt ...*mux.Route) mux.MiddlewareFunc {
+	retryAfter := strconv.Itoa(int(math.Ceil(c.RetryAfter.GetOrElse(config.DefaultMaintenanceRetryAfter).Seconds())))
+	body := c.ResponseBody
+	if body == "" {
+		body = httpStatusMessageMaintenance
+	}
+	contentType := c.ResponseContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !isInMaintenance() || isExemptRoute(req, exempt) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			w.Header().Set("Retry-After", retryAfter)
+			w.Header().Set("Content-Type", contentType)
+			// Closing the connection means that a client using keep-alive will open a new one for its next
+			// request, which the load balancer can send to another instance.
+			w.Header().Set("Connection", "close")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(body))
+		})
+	}
+}
+
+func isExemptRoute(req *http.Request, exempt []*mux.Route) bool {
+	if route := mux.CurrentRoute(req); route != nil {
+		for _, r := range exempt {
+			if route == r {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/gorilla/mux"
+	"github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeMaintenanceTestRouter(inMaintenance bool, c config.MaintenanceConfig) *mux.Router {
+	router := mux.NewRouter()
+	exempt := router.Handle("/status", nullHandler())
+	router.Handle("/sdk/latest-all", nullHandler())
+	router.Use(Maintenance(func() bool { return inMaintenance }, c, exempt))
+	return router
+}
+
+func TestMaintenanceAllowsRequestsIfNotInMaintenance(t *testing.T) {
+	router := makeMaintenanceTestRouter(false, config.MaintenanceConfig{})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sdk/latest-all", nil))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestMaintenanceRejectsRequestsWithDefaultResponse(t *testing.T) {
+	router := makeMaintenanceTestRouter(true, config.MaintenanceConfig{})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sdk/latest-all", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+	assert.Equal(t, "60", w.Result().Header.Get("Retry-After"))
+	assert.Equal(t, "close", w.Result().Header.Get("Connection"))
+	assert.Equal(t, "text/plain; charset=utf-8", w.Result().Header.Get("Content-Type"))
+	assert.Equal(t, httpStatusMessageMaintenance, w.Body.String())
+}
+
+func TestMaintenanceRejectsRequestsWithConfiguredResponse(t *testing.T) {
+	router := makeMaintenanceTestRouter(true, config.MaintenanceConfig{
+		RetryAfter:          configtypes.NewOptDuration(time.Millisecond * 1500),
+		ResponseBody:        `{"message":"down for maintenance"}`,
+		ResponseContentType: "application/json",
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sdk/latest-all", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+	assert.Equal(t, "2", w.Result().Header.Get("Retry-After"))
+	assert.Equal(t, "application/json", w.Result().Header.Get("Content-Type"))
+	assert.Equal(t, `{"message":"down for maintenance"}`, w.Body.String())
+}
+
+func TestMaintenanceAllowsExemptRoutes(t *testing.T) {
+	router := makeMaintenanceTestRouter(true, config.MaintenanceConfig{})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// MemoryShedding is a middleware function that rejects new stream connections while isShedding returns
+// true, meaning that Relay's memory use is above the high-water mark in MemoryLimitsConfig. They are
+// rejected with a 503 status and Retry-After set to retryAfter, so that SDKs reconnect later, possibly to
+// another Relay instance; existing connections are not affected. Rejected connections are counted in
+// metrics, using the platform category of the specified measure. It must be applied after
+// SelectEnvironmentByAuthorizationKey.
+func MemoryShedding(isShedding func() bool, retryAfter time.Duration, measure metrics.Measure) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !isShedding() {
+				next.ServeHTTP(w, req)
+				return
+			}
+			env := GetEnvContextInfo(req.Context()).Env
+			metrics.RecordMemoryRejectedStream(env.GetMetricsContext(), measure)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(httpStatusMessageMemoryShedding))
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testenv"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemorySheddingAllowsConnectionsIfNotShedding(t *testing.T) {
+	env := testenv.NewTestEnvContext("env", true, nil)
+	defer env.Close()
+
+	handler := MemoryShedding(func() bool { return false }, time.Second, metrics.ServerRequests)
+	w := httptest.NewRecorder()
+	handler(nullHandler()).ServeHTTP(w, buildPreRoutedRequest("GET", nil, nil, nil, env))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestMemorySheddingRejectsConnectionsWhileShedding(t *testing.T) {
+	env := testenv.NewTestEnvContext("env", true, nil)
+	defer env.Close()
+
+	handler := MemoryShedding(func() bool { return true }, time.Second*10, metrics.ServerRequests)
+	w := httptest.NewRecorder()
+	handler(nullHandler()).ServeHTTP(w, buildPreRoutedRequest("GET", nil, nil, nil, env))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+	assert.Equal(t, "10", w.Result().Header.Get("Retry-After"))
+	assert.Equal(t, httpStatusMessageMemoryShedding, w.Body.String())
+}
@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/launchdarkly/ld-relay/v6/config"
 	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/accesslog"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/browser"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/jwtauth"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
 
@@ -26,6 +29,15 @@ const (
 	httpStatusMessageNotFullyConfigured   = "Relay Proxy is not yet fully initialized, does not have list of environments yet"
 	httpStatusMessageMissingEnvURLParam   = "URL did not contain an environment ID"
 	httpStatusMessageSDKClientNotInited   = "client was not initialized"
+	httpStatusMessageClientCertRejected   = "Relay Proxy did not accept the client certificate for this environment"
+	httpStatusMessageInvalidToken         = "Relay Proxy requires a valid token for this environment (missing or invalid X-Relay-Token header)"
+	httpStatusMessageRateLimited          = "Relay Proxy rate limit exceeded for this client credential"
+	httpStatusMessageStreamQuotaExceeded  = "Relay Proxy stream connection limit exceeded for this environment"
+	httpStatusMessageEventQuotaExceeded   = "Relay Proxy event throughput limit exceeded for this environment"
+	httpStatusMessageEnvStreamLimit       = "Relay Proxy has too many stream connections for this environment"
+	httpStatusMessageGlobalStreamLimit    = "Relay Proxy has too many stream connections"
+	httpStatusMessageMemoryShedding       = "Relay Proxy is not accepting new stream connections because its memory use is too high"
+	httpStatusMessageMaintenance          = "Relay Proxy is in maintenance mode"
 )
 
 var (
@@ -41,6 +53,13 @@ type RelayEnvironments interface {
 	GetAllEnvironments() []relayenv.EnvContext
 }
 
+// heldEnvironment is implemented by an environment that could otherwise be shut down while a request is
+// still using it, as a lazily initialized environment is when MainConfig.EnvironmentIdleTimeout is set.
+// Hold keeps it open until the returned function is called.
+type heldEnvironment interface {
+	Hold() func()
+}
+
 // getUserAgent returns the X-LaunchDarkly-User-Agent if available, falling back to the normal "User-Agent" header
 func getUserAgent(req *http.Request) string {
 	if agent := req.Header.Get(ldUserAgentHeader); agent != "" {
@@ -63,6 +82,18 @@ func Chain(middlewares ...mux.MiddlewareFunc) mux.MiddlewareFunc {
 // SelectEnvironmentByAuthorizationKey creates a middleware function that attempts to authenticate the request
 // using the appropriate kind of credential for the basictypes.SDKKind. If successful, it updates the request context
 // so GetEnvContextInfo will return environment information. If not successful, it returns an error response.
+//
+// Credentials are only accepted for an environment that belongs to the same tenant as the request (see
+// SelectTenantByHost), or for an environment that does not belong to a tenant if the request does not.
+//
+// If the environment requires TLS client certificates, the request is also rejected unless its certificate
+// is acceptable according to the environment's clientcert.Policy. Similarly, client-side and mobile
+// requests are rejected if the environment requires a JWT and the request does not have a valid one.
+//
+// If the environment has a canary environment (see relayenv.CanaryRoute) and the request has the canary
+// header, the canary environment is selected instead.
+//
+// An environment that can be shut down for being idle is held until the request is done.
 func SelectEnvironmentByAuthorizationKey(sdkKind basictypes.SDKKind, envs RelayEnvironments) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -71,8 +102,17 @@ func SelectEnvironmentByAuthorizationKey(sdkKind basictypes.SDKKind, envs RelayE
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
+			accesslog.SetCredential(req.Context(), credential)
 
 			clientCtx, isConfigured := envs.GetEnvironment(credential)
+			if clientCtx != nil && clientCtx.GetTenant() != GetTenant(req.Context()) {
+				// The environment belongs to a different tenant (or to none), so as far as this request
+				// is concerned it does not exist.
+				clientCtx = nil
+			}
+			if held, ok := clientCtx.(heldEnvironment); ok {
+				defer held.Hold()()
+			}
 
 			if !isConfigured {
 				w.WriteHeader(http.StatusServiceUnavailable)
@@ -93,6 +133,48 @@ func SelectEnvironmentByAuthorizationKey(sdkKind basictypes.SDKKind, envs RelayE
 				return
 			}
 
+			accesslog.SetEnvironment(req.Context(), clientCtx.GetIdentifiers().GetDisplayName())
+
+			if policy := clientCtx.GetClientCertPolicy(); policy != nil {
+				if err := policy.Verify(req.TLS); err != nil {
+					clientCtx.GetLoggers().Debugf("Rejected client certificate: %s", err)
+					w.WriteHeader(http.StatusForbidden)
+					_, _ = w.Write([]byte(httpStatusMessageClientCertRejected))
+					return
+				}
+			}
+
+			var tokenClaims jwtauth.Claims
+			if verifier := clientCtx.GetJWTVerifier(); verifier != nil && sdkKind != basictypes.ServerSDK &&
+				req.Method != "OPTIONS" { // browsers do not send custom headers in CORS preflight requests
+				claims, err := verifier.VerifyRequest(req)
+				if err != nil {
+					clientCtx.GetLoggers().Debugf("Rejected token: %s", err)
+					w.WriteHeader(http.StatusUnauthorized)
+					_, _ = w.Write([]byte(httpStatusMessageInvalidToken))
+					return
+				}
+				tokenClaims = claims
+			}
+
+			// A request that has the environment's canary header is served by the canary environment, as if
+			// it had used the canary's credential. It has already been authorized for this environment, so
+			// the canary's own client certificate and JWT requirements do not apply.
+			var canaryOf relayenv.EnvContext
+			if route := clientCtx.GetCanaryRoute(); route != nil {
+				w.Header().Add("Vary", route.GetHeader()) // so a cache does not mix up the two environments
+				if canaryCredential := route.GetCredential(sdkKind); canaryCredential != nil && route.Matches(req) {
+					if canaryCtx, _ := envs.GetEnvironment(canaryCredential); canaryCtx != nil {
+						if held, ok := canaryCtx.(heldEnvironment); ok {
+							defer held.Hold()()
+						}
+						canaryOf = clientCtx
+						clientCtx, credential = canaryCtx, canaryCredential
+						accesslog.SetEnvironment(req.Context(), clientCtx.GetIdentifiers().GetDisplayName())
+					}
+				}
+			}
+
 			if clientCtx.GetClient() == nil {
 				w.WriteHeader(http.StatusServiceUnavailable)
 				_, _ = w.Write([]byte(httpStatusMessageSDKClientNotInited))
@@ -100,8 +182,10 @@ func SelectEnvironmentByAuthorizationKey(sdkKind basictypes.SDKKind, envs RelayE
 			}
 
 			contextInfo := EnvContextInfo{
-				Env:        clientCtx,
-				Credential: credential,
+				Env:         clientCtx,
+				Credential:  credential,
+				TokenClaims: tokenClaims,
+				CanaryOf:    canaryOf,
 			}
 			req = req.WithContext(WithEnvContextInfo(req.Context(), contextInfo))
 			if sdkKind == basictypes.JSClientSDK {
@@ -122,27 +206,33 @@ func SelectEnvironmentByAuthorizationKey(sdkKind basictypes.SDKKind, envs RelayE
 // gorilla/mux CORS middleware before this one.
 func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		corsContext := browser.GetCORSContext(r.Context())
 		var domains []string
 		var headers []string
-		if corsContext := browser.GetCORSContext(r.Context()); corsContext != nil {
+		var maxAge time.Duration
+		if corsContext != nil {
 			domains = corsContext.AllowedOrigins()
 			headers = corsContext.AllowedHeaders()
+			maxAge = corsContext.MaxAge()
 		}
 		if len(domains) > 0 {
-			domain := domains[0]
-			for _, d := range domains {
-				if r.Header.Get("Origin") == d {
-					domain = d
-					break
+			w.Header().Add("Vary", "Origin")
+			origin := r.Header.Get("Origin")
+			if origin == "" || !corsContext.AllowsOrigin(origin) {
+				// If the origin isn't allowed, we return the first configured origin, which the browser
+				// will reject; or, if that is a pattern, no origin at all.
+				origin = ""
+				if browser.IsExactOrigin(domains[0]) {
+					origin = domains[0]
 				}
 			}
-			browser.SetCORSHeaders(w, domain, headers)
+			browser.SetCORSHeaders(w, origin, headers, maxAge)
 		} else {
 			origin := browser.DefaultAllowedOrigin
 			if r.Header.Get("Origin") != "" {
 				origin = r.Header.Get("Origin")
 			}
-			browser.SetCORSHeaders(w, origin, headers)
+			browser.SetCORSHeaders(w, origin, headers, maxAge)
 		}
 		if r.Method != "OPTIONS" {
 			next.ServeHTTP(w, r)
@@ -3,19 +3,27 @@ package middleware
 import (
 	"encoding/base64"
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/launchdarkly/ld-relay/v6/config"
 	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/accesslog"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/browser"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
 	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testenv"
 
+	ct "github.com/launchdarkly/go-configtypes"
+	helpers "github.com/launchdarkly/go-test-helpers/v2"
+	"github.com/launchdarkly/go-test-helpers/v2/httphelpers"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/lduser"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
 
@@ -70,10 +78,16 @@ func (t testEnvironments) GetAllEnvironments() []relayenv.EnvContext {
 type testCORSContext struct {
 	origins []string
 	headers []string
+	maxAge  time.Duration
 }
 
 func (c testCORSContext) AllowedOrigins() []string { return c.origins }
 func (c testCORSContext) AllowedHeaders() []string { return c.headers }
+func (c testCORSContext) MaxAge() time.Duration    { return c.maxAge }
+func (c testCORSContext) AllowsOrigin(origin string) bool {
+	m, _ := browser.NewOriginMatcher(c.origins)
+	return m.Matches(origin)
+}
 
 func nullHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
@@ -142,6 +156,27 @@ func TestSelectEnvironmentByAuthorizationKey(t *testing.T) {
 		assert.Equal(t, env1, <-envCh)
 	})
 
+	t.Run("records credential type and environment in access log", func(t *testing.T) {
+		envs := testEnvironments{
+			envs: map[config.SDKCredential]relayenv.EnvContext{
+				st.EnvMain.Config.SDKKey: env1,
+			},
+		}
+		path := filepath.Join(t.TempDir(), "access.log")
+		accessLog, err := accesslog.NewLogger(config.AccessLogConfig{
+			Enabled: true, Format: config.AccessLogFormatJSON, Output: config.AccessLogOutputFile, File: path})
+		require.NoError(t, err)
+		selector := SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, envs)
+
+		req := buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey)
+		_, _ = st.DoRequest(req, accessLog.Middleware(selector(nullHandler())))
+		require.NoError(t, accessLog.Close())
+
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"credentialType":"sdkKey","env":"env1"`)
+	})
+
 	t.Run("finds by mobile key", func(t *testing.T) {
 		envs := testEnvironments{
 			envs: map[config.SDKCredential]relayenv.EnvContext{
@@ -256,6 +291,100 @@ func TestSelectEnvironmentByAuthorizationKey(t *testing.T) {
 		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
 	})
 
+	t.Run("rejects request without client certificate if environment requires one", func(t *testing.T) {
+		helpers.WithTempFile(func(certFilePath string) {
+			helpers.WithTempFile(func(keyFilePath string) {
+				require.NoError(t, httphelpers.MakeSelfSignedCert(certFilePath, keyFilePath))
+				allConfig := config.Config{}
+				allConfig.Main.TLSClientCertRequired = true
+				allConfig.Main.TLSClientCA = certFilePath
+				certEnv, err := relayenv.NewEnvContext(relayenv.EnvContextImplParams{
+					EnvConfig:     st.EnvMain.Config,
+					AllConfig:     allConfig,
+					ClientFactory: testclient.FakeLDClientFactory(true),
+					Loggers:       ldlog.NewDisabledLoggers(),
+				}, nil)
+				require.NoError(t, err)
+				defer certEnv.Close()
+				envs := testEnvironments{
+					envs: map[config.SDKCredential]relayenv.EnvContext{st.EnvMain.Config.SDKKey: certEnv},
+				}
+				selector := SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, envs)
+
+				req := buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey)
+				resp, _ := st.DoRequest(req, selector(nullHandler()))
+
+				assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+			})
+		})
+	})
+
+	t.Run("rejects mobile request without token if environment requires one", func(t *testing.T) {
+		envConfig := st.EnvMobile.Config
+		envConfig.JWTJWKSURL, _ = ct.NewOptURLAbsoluteFromString("http://localhost/jwks")
+		tokenEnv, err := relayenv.NewEnvContext(relayenv.EnvContextImplParams{
+			EnvConfig:     envConfig,
+			ClientFactory: testclient.FakeLDClientFactory(true),
+			Loggers:       ldlog.NewDisabledLoggers(),
+		}, nil)
+		require.NoError(t, err)
+		defer tokenEnv.Close()
+		envs := testEnvironments{
+			envs: map[config.SDKCredential]relayenv.EnvContext{envConfig.MobileKey: tokenEnv},
+		}
+		selector := SelectEnvironmentByAuthorizationKey(basictypes.MobileSDK, envs)
+
+		req := buildPreRoutedRequestWithAuth(envConfig.MobileKey)
+		resp, _ := st.DoRequest(req, selector(nullHandler()))
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("routes request with canary header to canary environment", func(t *testing.T) {
+		envConfig := st.EnvMain.Config
+		envConfig.CanaryEnvironment = "canary"
+		envConfig.CanaryHeader = "X-Canary"
+		allConfig := config.Config{Environment: map[string]*config.EnvConfig{"canary": &st.EnvMobile.Config}}
+		readyCh := make(chan relayenv.EnvContext, 1)
+		primaryEnv, err := relayenv.NewEnvContext(relayenv.EnvContextImplParams{
+			EnvConfig:     envConfig,
+			AllConfig:     allConfig,
+			ClientFactory: testclient.FakeLDClientFactory(true),
+			Loggers:       ldlog.NewDisabledLoggers(),
+		}, readyCh)
+		require.NoError(t, err)
+		defer primaryEnv.Close()
+		<-readyCh
+		envs := testEnvironments{
+			envs: map[config.SDKCredential]relayenv.EnvContext{
+				envConfig.SDKKey:           primaryEnv,
+				st.EnvMobile.Config.SDKKey: env2,
+			},
+		}
+		selector := SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, envs)
+		infoCh := make(chan EnvContextInfo, 1)
+		handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			infoCh <- GetEnvContextInfo(req.Context())
+		})
+
+		req := buildPreRoutedRequestWithAuth(envConfig.SDKKey)
+		req.Header.Set("X-Canary", "1")
+		resp, _ := st.DoRequest(req, selector(handler))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "X-Canary", resp.Header.Get("Vary"))
+		info := <-infoCh
+		assert.Equal(t, env2, info.Env)
+		assert.Equal(t, st.EnvMobile.Config.SDKKey, info.Credential)
+		assert.Equal(t, primaryEnv, info.CanaryOf)
+
+		req = buildPreRoutedRequestWithAuth(envConfig.SDKKey)
+		resp, _ = st.DoRequest(req, selector(handler))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		info = <-infoCh
+		assert.Equal(t, primaryEnv, info.Env)
+		assert.Nil(t, info.CanaryOf)
+	})
+
 	t.Run("returns 503 if Relay has not been initialized", func(t *testing.T) {
 		envs := testEnvironments{notInited: true}
 		selector := SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, envs)
@@ -317,6 +446,44 @@ func TestCORSMiddlewareSetsAllowedOriginFromContextWhenOriginDoesNotMatch(t *tes
 	assert.Equal(t, "abc", resp.Result().Header.Get("Access-Control-Allow-Origin"))
 }
 
+func TestCORSMiddlewareSetsAllowedOriginFromContextWhenOriginMatchesPattern(t *testing.T) {
+	headers := make(http.Header)
+	headers.Set("Origin", "https://app.example.com")
+	cc := testCORSContext{origins: []string{"abc", "https://*.example.com"}}
+	req := buildPreRoutedRequest("GET", nil, headers, nil, nil)
+	req = req.WithContext(browser.WithCORSContext(req.Context(), cc))
+	resp := httptest.NewRecorder()
+
+	CORS(nullHandler()).ServeHTTP(resp, req)
+
+	assert.Equal(t, "https://app.example.com", resp.Result().Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", resp.Result().Header.Get("Vary"))
+}
+
+func TestCORSMiddlewareOmitsAllowedOriginWhenOriginDoesNotMatchAndFirstOriginIsPattern(t *testing.T) {
+	headers := make(http.Header)
+	headers.Set("Origin", "blah")
+	cc := testCORSContext{origins: []string{"/^https://.*$/", "abc"}}
+	req := buildPreRoutedRequest("GET", nil, headers, nil, nil)
+	req = req.WithContext(browser.WithCORSContext(req.Context(), cc))
+	resp := httptest.NewRecorder()
+
+	CORS(nullHandler()).ServeHTTP(resp, req)
+
+	assert.NotContains(t, resp.Result().Header, "Access-Control-Allow-Origin")
+}
+
+func TestCORSMiddlewareSetsMaxAgeFromContext(t *testing.T) {
+	cc := testCORSContext{maxAge: time.Hour}
+	req := buildPreRoutedRequest("GET", nil, nil, nil, nil)
+	req = req.WithContext(browser.WithCORSContext(req.Context(), cc))
+	resp := httptest.NewRecorder()
+
+	CORS(nullHandler()).ServeHTTP(resp, req)
+
+	assert.Equal(t, "3600", resp.Result().Header.Get("Access-Control-Max-Age"))
+}
+
 func TestCORSMiddlewareSetsAllowedHeaderFromContext(t *testing.T) {
 	cc := testCORSContext{headers: []string{"ghi", "jkl"}}
 	req := buildPreRoutedRequest("GET", nil, nil, nil, nil)
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// StreamQuota is a middleware function that rejects a streaming request with a 503 status if the
+// environment already has as many stream connections as its quota allows, and otherwise holds one of
+// the environment's stream connection slots until the request ends. It must be applied after
+// SelectEnvironmentByAuthorizationKey.
+func StreamQuota(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		quota := GetEnvContextInfo(req.Context()).Env.GetQuota()
+		if quota == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if !quota.AcquireStream(req.Context()) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(httpStatusMessageStreamQuotaExceeded))
+			return
+		}
+		defer quota.ReleaseStream()
+		next.ServeHTTP(w, req)
+	})
+}
+
+// EventQuota is a middleware function that rejects a payload of analytics events with a 429 status if
+// it would exceed the environment's event throughput quota, setting Retry-After to the number of seconds
+// until it would be allowed. It must be applied after SelectEnvironmentByAuthorizationKey.
+//
+// Since this has to count the events before the request is passed along, it reads the whole body; the
+// next handler receives a copy of it. A body that is not a JSON array is passed along without being
+// counted, so that the event handler can report the error in the usual way.
+func EventQuota(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		quota := GetEnvContextInfo(req.Context()).Env.GetQuota()
+		if quota == nil || req.Body == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+		body, err := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+		var evts []json.RawMessage
+		if json.Unmarshal(body, &evts) == nil {
+			decision := quota.AllowEvents(req.Context(), len(evts))
+			if !decision.Allowed {
+				retryAfterSeconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte(httpStatusMessageEventQuotaExceeded))
+				return
+			}
+		}
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testenv"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeEnvWithQuotas(t *testing.T, autoConfig config.AutoConfigConfig) relayenv.EnvContext {
+	autoConfig.Key = "autokey"
+	env, err := relayenv.NewEnvContext(relayenv.EnvContextImplParams{
+		EnvConfig:     st.EnvMain.Config,
+		AllConfig:     config.Config{AutoConfig: autoConfig},
+		ClientFactory: testclient.FakeLDClientFactory(true),
+		Loggers:       ldlog.NewDisabledLoggers(),
+	}, nil)
+	require.NoError(t, err)
+	return env
+}
+
+func TestQuotaMiddlewareDoesNothingIfEnvironmentHasNoQuotas(t *testing.T) {
+	env := testenv.NewTestEnvContext("env", true, nil)
+	defer env.Close()
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		StreamQuota(nullHandler()).ServeHTTP(w, buildPreRoutedRequest("GET", nil, nil, nil, env))
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		w = httptest.NewRecorder()
+		EventQuota(nullHandler()).ServeHTTP(w, buildPreRoutedRequest("POST", []byte(`[{},{}]`), nil, nil, env))
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	}
+}
+
+func TestStreamQuotaRejectsConnectionsOverLimit(t *testing.T) {
+	maxStreams, _ := ct.NewOptIntGreaterThanZero(1)
+	env := makeEnvWithQuotas(t, config.AutoConfigConfig{EnvMaxStreamConnections: maxStreams})
+	defer env.Close()
+
+	inHandler, releaseHandler := make(chan struct{}), make(chan struct{})
+	handler := StreamQuota(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inHandler <- struct{}{}
+		<-releaseHandler
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), buildPreRoutedRequest("GET", nil, nil, nil, env))
+		close(done)
+	}()
+	<-inHandler
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, buildPreRoutedRequest("GET", nil, nil, nil, env))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+	assert.Equal(t, httpStatusMessageStreamQuotaExceeded, w.Body.String())
+
+	// once the first connection ends, its slot can be used again
+	close(releaseHandler)
+	<-done
+	go func() { <-inHandler }()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, buildPreRoutedRequest("GET", nil, nil, nil, env))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestEventQuotaRejectsEventsOverLimit(t *testing.T) {
+	env := makeEnvWithQuotas(t, config.AutoConfigConfig{EnvMaxEventsPerSecond: ct.NewOptFloat64(2)})
+	defer env.Close()
+
+	var receivedBody []byte
+	handler := EventQuota(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody, _ = ioutil.ReadAll(r.Body)
+	}))
+
+	w1 := httptest.NewRecorder()
+	handler.ServeHTTP(w1, buildPreRoutedRequest("POST", []byte(`[{},{}]`), nil, nil, env))
+	assert.Equal(t, http.StatusOK, w1.Result().StatusCode)
+	assert.Equal(t, `[{},{}]`, string(receivedBody))
+
+	w2 := httptest.NewRecorder()
+	handler.ServeHTTP(w2, buildPreRoutedRequest("POST", []byte(`[{}]`), nil, nil, env))
+	assert.Equal(t, http.StatusTooManyRequests, w2.Result().StatusCode)
+	assert.Equal(t, "1", w2.Result().Header.Get("Retry-After"))
+	assert.Equal(t, httpStatusMessageEventQuotaExceeded, w2.Body.String())
+}
+
+func TestEventQuotaPassesAlongBodyThatIsNotAnArray(t *testing.T) {
+	env := makeEnvWithQuotas(t, config.AutoConfigConfig{EnvMaxEventsPerSecond: ct.NewOptFloat64(1)})
+	defer env.Close()
+
+	var receivedBody []byte
+	handler := EventQuota(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody, _ = ioutil.ReadAll(r.Body)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, buildPreRoutedRequest("POST", []byte(`not JSON`), nil, nil, env))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, `not JSON`, string(receivedBody))
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// RateLimit is a middleware function that rejects requests with a 429 status if they exceed the
+// environment's rate limits, setting Retry-After to the number of seconds until a request would be
+// allowed. Rejected requests are counted in the throttled requests metric, using the platform category
+// of the specified measure. It must be applied after SelectEnvironmentByAuthorizationKey.
+func RateLimit(measure metrics.Measure) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := GetEnvContextInfo(req.Context())
+			limiter := ctx.Env.GetRateLimiter()
+			if limiter == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+			decision := limiter.AllowRequest(ctx.Credential, req)
+			if !decision.Allowed {
+				metrics.RecordThrottledRequest(ctx.Env.GetMetricsContext(), measure, decision.ByClientIP)
+				retryAfterSeconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte(httpStatusMessageRateLimited))
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testenv"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitDoesNothingIfEnvironmentHasNoLimits(t *testing.T) {
+	env := testenv.NewTestEnvContext("env", true, nil)
+	defer env.Close()
+	req := buildPreRoutedRequest("GET", nil, nil, nil, nil)
+	req = req.WithContext(WithEnvContextInfo(req.Context(), EnvContextInfo{Env: env, Credential: st.EnvMain.Config.SDKKey}))
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		RateLimit(metrics.ServerRequests)(nullHandler()).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	}
+}
+
+func TestRateLimitRejectsRequestsOverLimit(t *testing.T) {
+	allConfig := config.Config{}
+	allConfig.RateLimit.ServerSide = ct.NewOptFloat64(0.5)
+	env, err := relayenv.NewEnvContext(relayenv.EnvContextImplParams{
+		EnvConfig:     st.EnvMain.Config,
+		AllConfig:     allConfig,
+		ClientFactory: testclient.FakeLDClientFactory(true),
+		Loggers:       ldlog.NewDisabledLoggers(),
+	}, nil)
+	require.NoError(t, err)
+	defer env.Close()
+
+	req := buildPreRoutedRequest("GET", nil, nil, nil, nil)
+	req = req.WithContext(WithEnvContextInfo(req.Context(), EnvContextInfo{Env: env, Credential: st.EnvMain.Config.SDKKey}))
+	handler := RateLimit(metrics.ServerRequests)(nullHandler())
+
+	w1 := httptest.NewRecorder()
+	handler.ServeHTTP(w1, req)
+	assert.Equal(t, http.StatusOK, w1.Result().StatusCode)
+
+	w2 := httptest.NewRecorder()
+	handler.ServeHTTP(w2, req)
+	assert.Equal(t, http.StatusTooManyRequests, w2.Result().StatusCode)
+	assert.Equal(t, "2", w2.Result().Header.Get("Retry-After"))
+	assert.Equal(t, httpStatusMessageRateLimited, w2.Body.String())
+}
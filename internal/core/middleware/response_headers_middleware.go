@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// AddResponseHeaders creates a middleware function that adds the specified headers to every response.
+// They are set before the next handler is called, so the handler can still change them.
+func AddResponseHeaders(headers http.Header) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if len(headers) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			for name, values := range headers {
+				w.Header()[name] = append([]string(nil), values...)
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// OverrideResponseHeaders creates a middleware function that sets the specified headers on every
+// response, replacing any headers of the same names that the next handler or AddResponseHeaders set.
+// They are set when the handler starts writing the response.
+func OverrideResponseHeaders(headers http.Header) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if len(headers) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			hw := &headerOverridingWriter{writer: w, headers: headers}
+			next.ServeHTTP(hw, req)
+			hw.setHeaders() // in case the handler didn't write anything, so net/http will send an empty 200 response
+		})
+	}
+}
+
+type headerOverridingWriter struct {
+	writer     http.ResponseWriter
+	headers    http.Header
+	headersSet bool
+}
+
+func (w *headerOverridingWriter) setHeaders() {
+	if w.headersSet {
+		return
+	}
+	w.headersSet = true
+	for name, values := range w.headers {
+		w.writer.Header()[name] = append([]string(nil), values...)
+	}
+}
+
+func (w *headerOverridingWriter) Header() http.Header {
+	return w.writer.Header()
+}
+
+func (w *headerOverridingWriter) Write(data []byte) (int, error) {
+	w.setHeaders()
+	return w.writer.Write(data)
+}
+
+func (w *headerOverridingWriter) WriteHeader(statusCode int) {
+	w.setHeaders()
+	w.writer.WriteHeader(statusCode)
+}
+
+// The wrapped writer also has to implement http.Flusher for streams, and http.Hijacker for WebSocket
+// connections.
+
+func (w *headerOverridingWriter) Flush() {
+	w.setHeaders()
+	if f, ok := w.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *headerOverridingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.writer.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
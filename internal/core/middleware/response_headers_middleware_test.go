@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func doResponseHeadersRequest(handler http.Handler) *http.Response {
+	req, _ := http.NewRequest("GET", "", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func TestAddResponseHeaders(t *testing.T) {
+	headers := http.Header{"X-Served-By": {"relay-1"}, "Cache-Control": {"no-store"}}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.WriteHeader(http.StatusOK)
+	})
+	resp := doResponseHeadersRequest(AddResponseHeaders(headers)(handler))
+
+	assert.Equal(t, "relay-1", resp.Header.Get("X-Served-By"))
+	assert.Equal(t, "max-age=60", resp.Header.Get("Cache-Control")) // handler's value wins
+}
+
+func TestOverrideResponseHeaders(t *testing.T) {
+	headers := http.Header{"Cache-Control": {"no-store"}}
+
+	t.Run("replaces header set by handler", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = w.Write([]byte("{}"))
+		})
+		resp := doResponseHeadersRequest(OverrideResponseHeaders(headers)(handler))
+
+		assert.Equal(t, []string{"no-store"}, resp.Header.Values("Cache-Control"))
+	})
+
+	t.Run("applies to error responses", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+		resp := doResponseHeadersRequest(OverrideResponseHeaders(headers)(handler))
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	})
+
+	t.Run("applies if handler writes nothing", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+		resp := doResponseHeadersRequest(OverrideResponseHeaders(headers)(handler))
+
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	})
+
+	t.Run("preserves flusher", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, ok := w.(http.Flusher)
+			assert.True(t, ok)
+			w.(http.Flusher).Flush()
+		})
+		resp := doResponseHeadersRequest(OverrideResponseHeaders(headers)(handler))
+
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	})
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/ratelimit"
+
+	"github.com/gorilla/mux"
+)
+
+// StreamLimits is a middleware function that applies the limits on concurrent stream connections from
+// StreamLimitsConfig: first the environment's own limit, then the global limit, which is shared by all
+// environments. If a connection is over either limit, it is rejected with a 503 status and Retry-After
+// set to retryAfter, unless the limiter is configured to queue it until another connection ends.
+// Rejected and queued connections are counted in metrics, using the platform category of the specified
+// measure. It must be applied after SelectEnvironmentByAuthorizationKey.
+func StreamLimits(global *ratelimit.StreamLimiter, retryAfter time.Duration, measure metrics.Measure) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			env := GetEnvContextInfo(req.Context()).Env
+			envLimiter := env.GetStreamLimiter()
+			if envLimiter == nil && global == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+			reject := func(isGlobal bool, message string) {
+				metrics.RecordRejectedStream(env.GetMetricsContext(), measure, isGlobal)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte(message))
+			}
+			allowed, queued := envLimiter.Acquire(req.Context())
+			if queued {
+				metrics.RecordQueuedStream(env.GetMetricsContext(), measure, false)
+			}
+			if !allowed {
+				reject(false, httpStatusMessageEnvStreamLimit)
+				return
+			}
+			defer envLimiter.Release()
+			allowed, queued = global.Acquire(req.Context())
+			if queued {
+				metrics.RecordQueuedStream(env.GetMetricsContext(), measure, true)
+			}
+			if !allowed {
+				reject(true, httpStatusMessageGlobalStreamLimit)
+				return
+			}
+			defer global.Release()
+			next.ServeHTTP(w, req)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/ratelimit"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testenv"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeEnvWithStreamLimits(t *testing.T, streamLimits config.StreamLimitsConfig) relayenv.EnvContext {
+	env, err := relayenv.NewEnvContext(relayenv.EnvContextImplParams{
+		EnvConfig:     st.EnvMain.Config,
+		AllConfig:     config.Config{StreamLimits: streamLimits},
+		ClientFactory: testclient.FakeLDClientFactory(true),
+		Loggers:       ldlog.NewDisabledLoggers(),
+	}, nil)
+	require.NoError(t, err)
+	return env
+}
+
+// startBlockingStream starts a request that holds its stream connection slots until the returned
+// function is called.
+func startBlockingStream(handler func(http.Handler) http.Handler, env relayenv.EnvContext) func() {
+	inHandler, releaseHandler, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	h := handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(inHandler)
+		<-releaseHandler
+	}))
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), buildPreRoutedRequest("GET", nil, nil, nil, env))
+		close(done)
+	}()
+	<-inHandler
+	return func() {
+		close(releaseHandler)
+		<-done
+	}
+}
+
+func TestStreamLimitsDoNothingIfThereAreNoLimits(t *testing.T) {
+	env := testenv.NewTestEnvContext("env", true, nil)
+	defer env.Close()
+
+	handler := StreamLimits(nil, time.Second, metrics.ServerRequests)
+	end := startBlockingStream(handler, env)
+	defer end()
+
+	w := httptest.NewRecorder()
+	handler(nullHandler()).ServeHTTP(w, buildPreRoutedRequest("GET", nil, nil, nil, env))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestStreamLimitsRejectConnectionsOverEnvironmentLimit(t *testing.T) {
+	maxStreams, _ := ct.NewOptIntGreaterThanZero(1)
+	env := makeEnvWithStreamLimits(t, config.StreamLimitsConfig{MaxConnectionsPerEnv: maxStreams})
+	defer env.Close()
+
+	handler := StreamLimits(nil, time.Second*10, metrics.ServerRequests)
+	end := startBlockingStream(handler, env)
+
+	w := httptest.NewRecorder()
+	handler(nullHandler()).ServeHTTP(w, buildPreRoutedRequest("GET", nil, nil, nil, env))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+	assert.Equal(t, "10", w.Result().Header.Get("Retry-After"))
+	assert.Equal(t, httpStatusMessageEnvStreamLimit, w.Body.String())
+
+	// once the first connection ends, its slot can be used again
+	end()
+	w = httptest.NewRecorder()
+	handler(nullHandler()).ServeHTTP(w, buildPreRoutedRequest("GET", nil, nil, nil, env))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestStreamLimitsRejectConnectionsOverGlobalLimit(t *testing.T) {
+	env1 := testenv.NewTestEnvContext("env1", true, nil)
+	defer env1.Close()
+	env2 := testenv.NewTestEnvContext("env2", true, nil)
+	defer env2.Close()
+
+	handler := StreamLimits(ratelimit.NewStreamLimiter(1, false, 0), time.Second, metrics.ServerRequests)
+	end := startBlockingStream(handler, env1)
+	defer end()
+
+	w := httptest.NewRecorder()
+	handler(nullHandler()).ServeHTTP(w, buildPreRoutedRequest("GET", nil, nil, nil, env2))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+	assert.Equal(t, "1", w.Result().Header.Get("Retry-After"))
+	assert.Equal(t, httpStatusMessageGlobalStreamLimit, w.Body.String())
+}
+
+func TestStreamLimitsReleaseEnvironmentSlotIfGlobalLimitIsReached(t *testing.T) {
+	maxStreams, _ := ct.NewOptIntGreaterThanZero(1)
+	env := makeEnvWithStreamLimits(t, config.StreamLimitsConfig{MaxConnectionsPerEnv: maxStreams})
+	defer env.Close()
+	otherEnv := testenv.NewTestEnvContext("other", true, nil)
+	defer otherEnv.Close()
+
+	handler := StreamLimits(ratelimit.NewStreamLimiter(1, false, 0), time.Second, metrics.ServerRequests)
+	end := startBlockingStream(handler, otherEnv)
+
+	w := httptest.NewRecorder()
+	handler(nullHandler()).ServeHTTP(w, buildPreRoutedRequest("GET", nil, nil, nil, env))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+
+	end()
+	w = httptest.NewRecorder()
+	handler(nullHandler()).ServeHTTP(w, buildPreRoutedRequest("GET", nil, nil, nil, env))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestStreamLimitsQueueConnectionsOverLimit(t *testing.T) {
+	maxStreams, _ := ct.NewOptIntGreaterThanZero(1)
+	env := makeEnvWithStreamLimits(t, config.StreamLimitsConfig{
+		MaxConnectionsPerEnv: maxStreams,
+		Overflow:             config.StreamLimitOverflowQueue,
+		QueueTimeout:         ct.NewOptDuration(time.Second * 5),
+	})
+	defer env.Close()
+
+	handler := StreamLimits(nil, time.Second, metrics.ServerRequests)
+	end := startBlockingStream(handler, env)
+
+	resultCh := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		handler(nullHandler()).ServeHTTP(w, buildPreRoutedRequest("GET", nil, nil, nil, env))
+		resultCh <- w.Result().StatusCode
+	}()
+
+	select {
+	case <-resultCh:
+		require.Fail(t, "connection should have been queued")
+	case <-time.After(time.Millisecond * 100):
+	}
+	end()
+	select {
+	case status := <-resultCh:
+		assert.Equal(t, http.StatusOK, status)
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "timed out waiting for queued connection")
+	}
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const streamTuningContextKey contextKeyType = "streamTuning"
+
+// StreamTuning creates a middleware function that adjusts server-sent event (SSE) streams for the
+// listener that they were received on. If retryDelay is nonzero, each stream begins with a "retry" field
+// telling the client how long to wait before reconnecting. If heartbeatInterval is nonzero, a comment is
+// sent on each stream whenever nothing else has been sent on it for that long, in addition to the
+// environment's regular heartbeats. Responses that are not SSE streams are unchanged.
+//
+// Only the outermost StreamTuning applies to a request, so a listener-specific one can be wrapped
+// around a handler that already has one for the main listener.
+func StreamTuning(heartbeatInterval, retryDelay time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Context().Value(streamTuningContextKey) != nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+			req = req.WithContext(context.WithValue(req.Context(), streamTuningContextKey, true))
+			if heartbeatInterval <= 0 && retryDelay <= 0 {
+				next.ServeHTTP(w, req)
+				return
+			}
+			tw := &streamTuningWriter{writer: w, heartbeatInterval: heartbeatInterval, retryDelay: retryDelay}
+			next.ServeHTTP(tw, req)
+			tw.stop()
+		})
+	}
+}
+
+// streamTuningWriter only sends a heartbeat comment after the handler has flushed its output, since
+// eventsource.Server always flushes after each complete event or comment; that way, a heartbeat is
+// never inserted into the middle of an event.
+type streamTuningWriter struct {
+	writer            http.ResponseWriter
+	heartbeatInterval time.Duration
+	retryDelay        time.Duration
+	isStream          bool
+	atBoundary        bool
+	stopped           bool
+	lastWrite         time.Time
+	doneCh            chan struct{}
+	heartbeatsDone    sync.WaitGroup
+	lock              sync.Mutex
+}
+
+func (w *streamTuningWriter) Header() http.Header {
+	return w.writer.Header()
+}
+
+func (w *streamTuningWriter) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.atBoundary = false
+	w.lastWrite = time.Now()
+	return w.writer.Write(data)
+}
+
+func (w *streamTuningWriter) WriteHeader(statusCode int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.writer.WriteHeader(statusCode)
+	if statusCode != http.StatusOK || w.isStream ||
+		!strings.HasPrefix(w.writer.Header().Get("Content-Type"), "text/event-stream") {
+		return
+	}
+	w.isStream = true
+	w.atBoundary = true
+	w.lastWrite = time.Now()
+	if w.retryDelay > 0 {
+		_, _ = fmt.Fprintf(w.writer, "retry: %d\n\n", w.retryDelay.Milliseconds())
+	}
+	if w.heartbeatInterval > 0 {
+		w.doneCh = make(chan struct{})
+		w.heartbeatsDone.Add(1)
+		go w.sendHeartbeats()
+	}
+}
+
+func (w *streamTuningWriter) sendHeartbeats() {
+	defer w.heartbeatsDone.Done()
+	ticker := time.NewTicker(w.heartbeatInterval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.doneCh:
+			return
+		case <-ticker.C:
+		}
+		w.lock.Lock()
+		if w.stopped {
+			w.lock.Unlock()
+			return
+		}
+		if w.atBoundary && time.Since(w.lastWrite) >= w.heartbeatInterval {
+			_, _ = w.writer.Write([]byte(":\n"))
+			if f, ok := w.writer.(http.Flusher); ok {
+				f.Flush()
+			}
+			w.lastWrite = time.Now()
+		}
+		w.lock.Unlock()
+	}
+}
+
+// stop ends the heartbeats, which must not write to the response after the handler has returned.
+func (w *streamTuningWriter) stop() {
+	w.lock.Lock()
+	w.stopped = true
+	if w.doneCh != nil {
+		close(w.doneCh)
+	}
+	w.lock.Unlock()
+	w.heartbeatsDone.Wait()
+}
+
+// The wrapped writer also has to implement http.Flusher for streams, and http.Hijacker for WebSocket
+// connections.
+
+func (w *streamTuningWriter) Flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.atBoundary = true
+	if f, ok := w.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *streamTuningWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.writer.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
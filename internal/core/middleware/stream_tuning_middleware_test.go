@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sseTestHandler(duration time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte("event: put\ndata: {}\n\n"))
+		w.(http.Flusher).Flush()
+		time.Sleep(duration)
+	})
+}
+
+func TestStreamTuningDoesNotChangeOtherResponses(t *testing.T) {
+	handler := StreamTuning(time.Millisecond, time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+		time.Sleep(time.Millisecond * 20)
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, `{}`, w.Body.String())
+}
+
+func TestStreamTuningSendsRetryDelay(t *testing.T) {
+	handler := StreamTuning(0, time.Millisecond*1500)(sseTestHandler(0))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, "retry: 1500\n\nevent: put\ndata: {}\n\n", w.Body.String())
+}
+
+func TestStreamTuningSendsHeartbeatsWhenStreamIsIdle(t *testing.T) {
+	handler := StreamTuning(time.Millisecond*20, 0)(sseTestHandler(time.Millisecond * 200))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	heartbeats := strings.TrimPrefix(w.Body.String(), "event: put\ndata: {}\n\n")
+	assert.NotEmpty(t, heartbeats)
+	assert.Equal(t, strings.Repeat(":\n", len(heartbeats)/2), heartbeats)
+}
+
+func TestStreamTuningUsesOutermostSettings(t *testing.T) {
+	handler := StreamTuning(0, time.Second)(StreamTuning(0, time.Second*2)(sseTestHandler(0)))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, "retry: 1000\n\nevent: put\ndata: {}\n\n", w.Body.String())
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const tenantContextKey contextKeyType = "tenant"
+
+// ForTenant returns a handler that treats every request as being for the specified tenant (see
+// config.TenantConfig). This is used for the listener on a tenant's own port.
+func ForTenant(tenant string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), tenantContextKey, tenant)))
+	})
+}
+
+// SelectTenantByHost creates a middleware function that determines which tenant a request is for from
+// its Host header, using a map of lowercase host names to tenant names, unless ForTenant has already
+// determined the tenant. A request whose host is not in the map is not for any tenant.
+func SelectTenantByHost(tenantsByHost map[string]string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if _, ok := req.Context().Value(tenantContextKey).(string); ok || len(tenantsByHost) == 0 {
+				next.ServeHTTP(w, req)
+				return
+			}
+			host := req.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			tenant := tenantsByHost[strings.ToLower(host)]
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), tenantContextKey, tenant)))
+		})
+	}
+}
+
+// GetTenant returns the name of the tenant that the request is for, or "" if it is not for a tenant.
+func GetTenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey).(string)
+	return tenant
+}
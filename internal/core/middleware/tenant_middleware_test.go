@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectTenantByHost(t *testing.T) {
+	tenantsByHost := map[string]string{"flags.acme.com": "acme"}
+
+	for _, tc := range []struct {
+		host   string
+		tenant string
+	}{
+		{"flags.acme.com", "acme"},
+		{"FLAGS.acme.com:8030", "acme"},
+		{"flags.globex.com", ""},
+	} {
+		t.Run(tc.host, func(t *testing.T) {
+			var tenant string
+			handler := SelectTenantByHost(tenantsByHost)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tenant = GetTenant(r.Context())
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Host = tc.host
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tc.tenant, tenant)
+		})
+	}
+}
+
+func TestForTenantTakesPrecedenceOverHost(t *testing.T) {
+	var tenant string
+	handler := ForTenant("globex", SelectTenantByHost(map[string]string{"flags.acme.com": "acme"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant = GetTenant(r.Context())
+		})))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "flags.acme.com"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "globex", tenant)
+}
@@ -0,0 +1,480 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	ld "gopkg.in/launchdarkly/go-server-sdk.v5"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+// bigSegmentPatchSize is the maximum number of users in each patch that is applied to the target store.
+// The stores split large patches into smaller transactions anyway, but this limits how much work is
+// repeated if a patch fails and the migration is run again.
+const bigSegmentPatchSize = 1000
+
+// inProgressCursorPrefix is added to the big segment cursor in the target store while memberships are
+// being copied, so that the SDKs and Relay's synchronizer will not treat the data as complete. If the
+// migration fails partway through, running it again continues from there.
+const inProgressCursorPrefix = "migrating:"
+
+var (
+	errSourceNotInitialized    = errors.New("the source store does not contain any flag data for this prefix")
+	errTargetNotInitialized    = errors.New("the target store does not contain any flag data for this prefix")
+	errTargetHasNoBigSegments  = errors.New("the source store contains big segment data, but the target store does not support big segments")
+	errBigSegmentTargetChanged = errors.New("the big segment data in the target store was changed by another process during the migration")
+)
+
+func errMigratePrefix(from, to string, err error) error {
+	return fmt.Errorf("prefix %s to %s: %w", describePrefix(from), describePrefix(to), err)
+}
+
+func errBigSegmentsNotExportable(dbType string) error {
+	return fmt.Errorf("big segment data cannot be read from a %s store", dbType)
+}
+
+func errTargetHasBigSegments(cursor string) error {
+	return fmt.Errorf("the target store already contains other big segment data (cursor %q)", cursor)
+}
+
+func errItemMissing(kind ldstoretypes.DataKind, key string) error {
+	return fmt.Errorf("%s %q is missing from the target store", describeKind(kind), key)
+}
+
+func errItemUnexpected(kind ldstoretypes.DataKind, key string) error {
+	return fmt.Errorf("%s %q is in the target store but not in the source store", describeKind(kind), key)
+}
+
+func errItemVersion(kind ldstoretypes.DataKind, key string, sourceVersion, targetVersion int) error {
+	return fmt.Errorf("%s %q has version %d in the source store but version %d in the target store",
+		describeKind(kind), key, sourceVersion, targetVersion)
+}
+
+func errBigSegmentCursor(sourceCursor, targetCursor string) error {
+	return fmt.Errorf("the big segment cursor is %q in the source store but %q in the target store", sourceCursor, targetCursor)
+}
+
+func errBigSegmentMemberships(missing, unexpected int) error {
+	return fmt.Errorf("%d big segment memberships are missing from the target store, and %d are only in the target store",
+		missing, unexpected)
+}
+
+// PrefixMapping is the key prefix of one environment's data in the source store, and the prefix to use for
+// it in the target store. For DynamoDB, the prefix is within the table given in the store URL. An empty
+// prefix means the database's default, as when an environment does not set the Prefix property.
+type PrefixMapping struct {
+	From string
+	To   string
+}
+
+// ParsePrefixMapping parses a prefix mapping in the form "FROM=TO", or a single prefix that is the same in
+// both stores.
+func ParsePrefixMapping(s string) PrefixMapping {
+	if i := strings.Index(s, "="); i >= 0 {
+		return PrefixMapping{From: s[:i], To: s[i+1:]}
+	}
+	return PrefixMapping{From: s, To: s}
+}
+
+// storeInstance is the data store and big segment store for one prefix in a database.
+type storeInstance struct {
+	data        interfaces.DataStore
+	bigSegments bigsegments.BigSegmentStore // nil if the database does not support big segments
+	dbType      string
+}
+
+func openStore(c config.Config, prefix string, loggers ldlog.Loggers) (*storeInstance, error) {
+	envConfig := config.EnvConfig{Prefix: prefix}
+	factory, storeInfo, err := sdks.ConfigureDataStore(c, envConfig, loggers)
+	if err != nil {
+		return nil, err
+	}
+	sdkContext := sdks.NewSimpleClientContext("", ld.Config{
+		Logging: ldcomponents.Logging().Loggers(loggers),
+	})
+	s := &storeInstance{dbType: storeInfo.DBType}
+	if s.data, err = factory.CreateDataStore(sdkContext, noOpDataStoreUpdates{}); err != nil {
+		return nil, err
+	}
+	if s.bigSegments, err = bigsegments.DefaultBigSegmentStoreFactory(envConfig, c, loggers); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *storeInstance) close() {
+	_ = s.data.Close()
+	if s.bigSegments != nil {
+		_ = s.bigSegments.Close()
+	}
+}
+
+// Migrate copies the data for each prefix from one store to another. The flags and segments in the target
+// store are replaced. Big segment data is copied only if the target store does not have any yet, or has
+// the same data from an earlier migration that did not finish.
+//
+// Relay can keep running with the source store meanwhile. Any changes that it makes after the migration
+// will be made again when Relay starts using the target store, since it will then receive the latest flag
+// data from LaunchDarkly and will request any big segment changes after the cursor that was copied.
+func Migrate(from, to config.Config, prefixes []PrefixMapping, loggers ldlog.Loggers) error {
+	for _, p := range prefixes {
+		source, err := openStore(from, p.From, loggers)
+		if err != nil {
+			return errMigratePrefix(p.From, p.To, err)
+		}
+		target, err := openStore(to, p.To, loggers)
+		if err != nil {
+			source.close()
+			return errMigratePrefix(p.From, p.To, err)
+		}
+		err = migrateStore(source, target, loggers)
+		source.close()
+		target.close()
+		if err != nil {
+			return errMigratePrefix(p.From, p.To, err)
+		}
+		loggers.Infof("Copied prefix %s to %s", describePrefix(p.From), describePrefix(p.To))
+	}
+	return nil
+}
+
+func migrateStore(source, target *storeInstance, loggers ldlog.Loggers) error {
+	initialized, err := isInitialized(source.data)
+	if err != nil {
+		return err
+	}
+	if !initialized {
+		return errSourceNotInitialized
+	}
+	allData, err := getAllData(source.data)
+	if err != nil {
+		return err
+	}
+	if err := target.data.Init(allData); err != nil {
+		return err
+	}
+	loggers.Infof("Copied %d flags and %d segments", len(allData[0].Items), len(allData[1].Items))
+	return migrateBigSegments(source, target, loggers)
+}
+
+func migrateBigSegments(source, target *storeInstance, loggers ldlog.Loggers) error {
+	if source.bigSegments == nil {
+		return nil
+	}
+	// The cursor is read before the memberships, so if Relay applies another patch to the source store
+	// meanwhile, the synchronizer will apply it again to the target store. That is safe because the
+	// membership changes in a patch are idempotent.
+	cursor, err := source.bigSegments.GetCursor()
+	if err != nil || cursor == "" {
+		return err
+	}
+	if target.bigSegments == nil {
+		return errTargetHasNoBigSegments
+	}
+	exporter, ok := source.bigSegments.(bigsegments.BigSegmentMembershipExporter)
+	if !ok {
+		// The target store is left without a cursor, so Relay will request all of the big segment data
+		// from LaunchDarkly when it starts using that store.
+		loggers.Warnf("Big segment data cannot be read from a %s store, so it was not copied;"+
+			" Relay will synchronize it again from LaunchDarkly", source.dbType)
+		return nil
+	}
+	targetCursor, err := target.bigSegments.GetCursor()
+	if err != nil {
+		return err
+	}
+	if targetCursor == cursor {
+		loggers.Info("The target store already has the same big segment data")
+		return nil
+	}
+	inProgressCursor := inProgressCursorPrefix + cursor
+	if targetCursor != "" && targetCursor != inProgressCursor {
+		return errTargetHasBigSegments(targetCursor)
+	}
+	synchronizedOn, err := source.bigSegments.GetSynchronizedOn()
+	if err != nil {
+		return err
+	}
+
+	included, excluded := make(map[string][]string), make(map[string][]string)
+	err = exporter.ExportMemberships(func(userHash string, includedRefs, excludedRefs []string) error {
+		for _, ref := range includedRefs {
+			included[ref] = append(included[ref], userHash)
+		}
+		for _, ref := range excludedRefs {
+			excluded[ref] = append(excluded[ref], userHash)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	previousCursor := targetCursor
+	applyPatch := func(patch bigsegments.BigSegmentPatch) error {
+		patch.PreviousVersion = previousCursor
+		applied, err := target.bigSegments.ApplyPatch(patch)
+		if err != nil {
+			return err
+		}
+		if !applied {
+			return errBigSegmentTargetChanged
+		}
+		previousCursor = patch.Version
+		return nil
+	}
+	count := 0
+	for _, memberships := range []struct {
+		users    map[string][]string
+		included bool
+	}{{included, true}, {excluded, false}} {
+		for _, ref := range sortedKeys(memberships.users) {
+			userHashes := memberships.users[ref]
+			for len(userHashes) != 0 {
+				n := len(userHashes)
+				if n > bigSegmentPatchSize {
+					n = bigSegmentPatchSize
+				}
+				patch := bigsegments.BigSegmentPatch{SegmentID: ref, Version: inProgressCursor}
+				if memberships.included {
+					patch.Changes.Included.Add = userHashes[:n]
+				} else {
+					patch.Changes.Excluded.Add = userHashes[:n]
+				}
+				if err := applyPatch(patch); err != nil {
+					return err
+				}
+				userHashes = userHashes[n:]
+				count += n
+			}
+		}
+	}
+	// Setting the final cursor with an empty patch marks the data as complete.
+	if err := applyPatch(bigsegments.BigSegmentPatch{Version: cursor}); err != nil {
+		return err
+	}
+	if synchronizedOn.IsDefined() {
+		if err := target.bigSegments.SetSynchronizedOn(synchronizedOn); err != nil {
+			return err
+		}
+	}
+	loggers.Infof("Copied %d big segment memberships", count)
+	return nil
+}
+
+// Verify compares the data for each prefix in two stores, and returns an error for each difference. The
+// big segment memberships are only compared if the cursors are the same.
+func Verify(from, to config.Config, prefixes []PrefixMapping, loggers ldlog.Loggers) []error {
+	var errs []error
+	for _, p := range prefixes {
+		source, err := openStore(from, p.From, loggers)
+		if err != nil {
+			errs = append(errs, errMigratePrefix(p.From, p.To, err))
+			continue
+		}
+		target, err := openStore(to, p.To, loggers)
+		if err != nil {
+			source.close()
+			errs = append(errs, errMigratePrefix(p.From, p.To, err))
+			continue
+		}
+		for _, err := range verifyStore(source, target) {
+			errs = append(errs, errMigratePrefix(p.From, p.To, err))
+		}
+		source.close()
+		target.close()
+	}
+	return errs
+}
+
+func verifyStore(source, target *storeInstance) []error {
+	for _, s := range []struct {
+		store          interfaces.DataStore
+		notInitialized error
+	}{{source.data, errSourceNotInitialized}, {target.data, errTargetNotInitialized}} {
+		initialized, err := isInitialized(s.store)
+		if err != nil {
+			return []error{err}
+		}
+		if !initialized {
+			return []error{s.notInitialized}
+		}
+	}
+	var errs []error
+	for _, kind := range ldstoreimpl.AllKinds() {
+		sourceVersions, err := getVersions(source.data, kind)
+		if err != nil {
+			return append(errs, err)
+		}
+		targetVersions, err := getVersions(target.data, kind)
+		if err != nil {
+			return append(errs, err)
+		}
+		for _, key := range sortedVersionKeys(sourceVersions) {
+			if targetVersion, ok := targetVersions[key]; !ok {
+				errs = append(errs, errItemMissing(kind, key))
+			} else if targetVersion != sourceVersions[key] {
+				errs = append(errs, errItemVersion(kind, key, sourceVersions[key], targetVersion))
+			}
+		}
+		for _, key := range sortedVersionKeys(targetVersions) {
+			if _, ok := sourceVersions[key]; !ok {
+				errs = append(errs, errItemUnexpected(kind, key))
+			}
+		}
+	}
+	if err := verifyBigSegments(source, target); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func verifyBigSegments(source, target *storeInstance) error {
+	if source.bigSegments == nil {
+		return nil
+	}
+	cursor, err := source.bigSegments.GetCursor()
+	if err != nil || cursor == "" {
+		return err
+	}
+	if target.bigSegments == nil {
+		return errTargetHasNoBigSegments
+	}
+	targetCursor, err := target.bigSegments.GetCursor()
+	if err != nil {
+		return err
+	}
+	if targetCursor != cursor {
+		return errBigSegmentCursor(cursor, targetCursor)
+	}
+	sourceMemberships, err := getMemberships(source)
+	if err != nil {
+		return err
+	}
+	targetMemberships, err := getMemberships(target)
+	if err != nil {
+		return err
+	}
+	missing, unexpected := 0, 0
+	for m := range sourceMemberships {
+		if _, ok := targetMemberships[m]; !ok {
+			missing++
+		}
+	}
+	for m := range targetMemberships {
+		if _, ok := sourceMemberships[m]; !ok {
+			unexpected++
+		}
+	}
+	if missing != 0 || unexpected != 0 {
+		return errBigSegmentMemberships(missing, unexpected)
+	}
+	return nil
+}
+
+// bigSegmentMembership is one user's inclusion in or exclusion from one big segment.
+type bigSegmentMembership struct {
+	userHash   string
+	segmentRef string
+	included   bool
+}
+
+func getMemberships(s *storeInstance) (map[bigSegmentMembership]struct{}, error) {
+	exporter, ok := s.bigSegments.(bigsegments.BigSegmentMembershipExporter)
+	if !ok {
+		return nil, errBigSegmentsNotExportable(s.dbType)
+	}
+	ret := make(map[bigSegmentMembership]struct{})
+	err := exporter.ExportMemberships(func(userHash string, included, excluded []string) error {
+		for _, ref := range included {
+			ret[bigSegmentMembership{userHash, ref, true}] = struct{}{}
+		}
+		for _, ref := range excluded {
+			ret[bigSegmentMembership{userHash, ref, false}] = struct{}{}
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// isInitialized is like DataStore.IsInitialized, except that it returns an error if the database cannot be
+// read, rather than just returning false.
+func isInitialized(store interfaces.DataStore) (bool, error) {
+	if _, err := store.GetAll(ldstoreimpl.Features()); err != nil {
+		return false, err
+	}
+	return store.IsInitialized(), nil
+}
+
+// getAllData returns the flags and then the segments, including deleted item placeholders.
+func getAllData(store interfaces.DataStore) ([]ldstoretypes.Collection, error) {
+	var allData []ldstoretypes.Collection
+	for _, kind := range ldstoreimpl.AllKinds() {
+		items, err := store.GetAll(kind)
+		if err != nil {
+			return nil, err
+		}
+		allData = append(allData, ldstoretypes.Collection{Kind: kind, Items: items})
+	}
+	return allData, nil
+}
+
+func getVersions(store interfaces.DataStore, kind ldstoretypes.DataKind) (map[string]int, error) {
+	items, err := store.GetAll(kind)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]int, len(items))
+	for _, item := range items {
+		ret[item.Key] = item.Item.Version
+	}
+	return ret, nil
+}
+
+func sortedKeys(m map[string][]string) []string {
+	ret := make([]string, 0, len(m))
+	for key := range m {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func sortedVersionKeys(m map[string]int) []string {
+	ret := make([]string, 0, len(m))
+	for key := range m {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func describeKind(kind ldstoretypes.DataKind) string {
+	if kind == ldstoreimpl.Features() {
+		return "flag"
+	}
+	return "segment"
+}
+
+func describePrefix(prefix string) string {
+	if prefix == "" {
+		return "(default)"
+	}
+	return fmt.Sprintf("%q", prefix)
+}
+
+// noOpDataStoreUpdates is required by the SDK's persistent data store wrapper.
+type noOpDataStoreUpdates struct{}
+
+func (noOpDataStoreUpdates) UpdateStatus(interfaces.DataStoreStatus) {}
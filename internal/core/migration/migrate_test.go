@@ -0,0 +1,150 @@
+package migration
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exportlessBigSegmentStore hides the BigSegmentMembershipExporter implementation of the wrapped store.
+type exportlessBigSegmentStore struct {
+	bigsegments.BigSegmentStore
+}
+
+func makeMemoryBigSegmentStore(t *testing.T) bigsegments.BigSegmentStore {
+	var c config.Config
+	c.BigSegments.Type = config.BigSegmentsStoreTypeMemory
+	store, err := bigsegments.DefaultBigSegmentStoreFactory(config.EnvConfig{}, c, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	return store
+}
+
+func makeSourceStore(t *testing.T) *storeInstance {
+	s := &storeInstance{data: st.MakeStoreWithData(true), bigSegments: makeMemoryBigSegmentStore(t), dbType: "memory"}
+	patch := bigsegments.BigSegmentPatch{SegmentID: "segment.g1", Version: "2"}
+	patch.Changes.Included.Add = []string{"user1", "user2"}
+	patch.Changes.Excluded.Add = []string{"user3"}
+	applied, err := s.bigSegments.ApplyPatch(patch)
+	require.NoError(t, err)
+	require.True(t, applied)
+	require.NoError(t, s.bigSegments.SetSynchronizedOn(ldtime.UnixMillisecondTime(1000)))
+	return s
+}
+
+func makeTargetStore(t *testing.T) *storeInstance {
+	return &storeInstance{data: st.NewInMemoryStore(), bigSegments: makeMemoryBigSegmentStore(t), dbType: "memory"}
+}
+
+func TestParsePrefixMapping(t *testing.T) {
+	assert.Equal(t, PrefixMapping{From: "a", To: "b"}, ParsePrefixMapping("a=b"))
+	assert.Equal(t, PrefixMapping{From: "a", To: "a"}, ParsePrefixMapping("a"))
+	assert.Equal(t, PrefixMapping{From: "", To: "b"}, ParsePrefixMapping("=b"))
+}
+
+func TestMigrateStoreCopiesAllData(t *testing.T) {
+	source, target := makeSourceStore(t), makeTargetStore(t)
+	require.NoError(t, migrateStore(source, target, ldlog.NewDisabledLoggers()))
+
+	assert.True(t, target.data.IsInitialized())
+	flags, err := target.data.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, flags, len(st.AllFlags))
+	cursor, err := target.bigSegments.GetCursor()
+	require.NoError(t, err)
+	assert.Equal(t, "2", cursor)
+	synchronizedOn, err := target.bigSegments.GetSynchronizedOn()
+	require.NoError(t, err)
+	assert.Equal(t, ldtime.UnixMillisecondTime(1000), synchronizedOn)
+
+	assert.Len(t, verifyStore(source, target), 0)
+}
+
+func TestMigrateStoreRequiresInitializedSource(t *testing.T) {
+	source, target := makeSourceStore(t), makeTargetStore(t)
+	source.data = st.NewInMemoryStore()
+	assert.Equal(t, errSourceNotInitialized, migrateStore(source, target, ldlog.NewDisabledLoggers()))
+}
+
+func TestMigrateStoreResumesBigSegmentsAfterFailure(t *testing.T) {
+	source, target := makeSourceStore(t), makeTargetStore(t)
+	patch := bigsegments.BigSegmentPatch{SegmentID: "segment.g1", Version: inProgressCursorPrefix + "2"}
+	patch.Changes.Included.Add = []string{"user1"}
+	_, err := target.bigSegments.ApplyPatch(patch)
+	require.NoError(t, err)
+
+	require.NoError(t, migrateStore(source, target, ldlog.NewDisabledLoggers()))
+	assert.Len(t, verifyStore(source, target), 0)
+}
+
+func TestMigrateStoreDoesNotOverwriteOtherBigSegmentData(t *testing.T) {
+	source, target := makeSourceStore(t), makeTargetStore(t)
+	_, err := target.bigSegments.ApplyPatch(bigsegments.BigSegmentPatch{SegmentID: "segment.g2", Version: "1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, errTargetHasBigSegments("1"), migrateStore(source, target, ldlog.NewDisabledLoggers()))
+}
+
+func TestMigrateStoreWithoutBigSegmentsInTarget(t *testing.T) {
+	source, target := makeSourceStore(t), makeTargetStore(t)
+	target.bigSegments = nil
+	assert.Equal(t, errTargetHasNoBigSegments, migrateStore(source, target, ldlog.NewDisabledLoggers()))
+}
+
+func TestMigrateStoreSkipsBigSegmentsThatCannotBeExported(t *testing.T) {
+	source, target := makeSourceStore(t), makeTargetStore(t)
+	source.bigSegments = exportlessBigSegmentStore{source.bigSegments}
+	mockLog := ldlogtest.NewMockLog()
+	require.NoError(t, migrateStore(source, target, mockLog.Loggers))
+
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Big segment data cannot be read from a memory store")
+	cursor, err := target.bigSegments.GetCursor()
+	require.NoError(t, err)
+	assert.Equal(t, "", cursor)
+}
+
+func TestVerifyStoreReportsDifferences(t *testing.T) {
+	source, target := makeSourceStore(t), makeTargetStore(t)
+	require.NoError(t, migrateStore(source, target, ldlog.NewDisabledLoggers()))
+
+	flag1 := st.Flag1ServerSide.Flag
+	flag1.Version++
+	_, err := st.UpsertFlag(target.data, flag1)
+	require.NoError(t, err)
+	_, err = st.UpsertFlag(target.data, ldbuilders.NewFlagBuilder("extra-flag").Version(1).Build())
+	require.NoError(t, err)
+	patch := bigsegments.BigSegmentPatch{SegmentID: "segment.g1", Version: "2", PreviousVersion: "2"}
+	patch.Changes.Included.Remove = []string{"user1"}
+	_, err = target.bigSegments.ApplyPatch(patch)
+	require.NoError(t, err)
+
+	assert.Equal(t, []error{
+		errItemVersion(ldstoreimpl.Features(), flag1.Key, flag1.Version-1, flag1.Version),
+		errItemUnexpected(ldstoreimpl.Features(), "extra-flag"),
+		errBigSegmentMemberships(1, 0),
+	}, verifyStore(source, target))
+}
+
+func TestVerifyStoreReportsBigSegmentCursor(t *testing.T) {
+	source, target := makeSourceStore(t), makeTargetStore(t)
+	require.NoError(t, migrateStore(source, target, ldlog.NewDisabledLoggers()))
+	_, err := source.bigSegments.ApplyPatch(bigsegments.BigSegmentPatch{SegmentID: "segment.g1", Version: "3", PreviousVersion: "2"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []error{errBigSegmentCursor("3", "2")}, verifyStore(source, target))
+}
+
+func TestVerifyStoreRequiresInitializedTarget(t *testing.T) {
+	source, target := makeSourceStore(t), makeTargetStore(t)
+	assert.Equal(t, []error{errTargetNotInitialized}, verifyStore(source, target))
+}
@@ -0,0 +1,4 @@
+// Package migration implements the migrate-store command, which copies the flag, segment, and big segment
+// data that Relay keeps in one persistent store to another, so that Relay can be moved to a different
+// database without downtime.
+package migration
@@ -0,0 +1,80 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+)
+
+func errInvalidStoreURL(err error) error {
+	return fmt.Errorf("invalid store URL: %w", err)
+}
+
+func errStoreURLWithoutHost(scheme string) error {
+	if scheme == "dynamodb" {
+		return errors.New("invalid store URL: a DynamoDB URL must specify the table name")
+	}
+	return fmt.Errorf("invalid store URL: a %s URL must specify the host", scheme)
+}
+
+func errUnsupportedStoreURL(scheme string) error {
+	return fmt.Errorf("unsupported store URL scheme %q; must be redis, rediss, dynamodb, consul, postgres, mongodb, or etcd", scheme)
+}
+
+// ConfigForStoreURL returns a Relay configuration that uses the database described by a URL, as given to
+// the migrate-store command. Redis, Postgres, and MongoDB URLs are used as they are. For the other
+// databases, the URL only identifies the database:
+//
+//	dynamodb://TABLE_NAME
+//	dynamodb://TABLE_NAME?endpoint=http://localhost:8000
+//	consul://HOST:PORT
+//	etcd://HOST:PORT
+//
+// Caching is turned off, so that all reads and writes go to the database.
+func ConfigForStoreURL(storeURL string) (config.Config, error) {
+	var c config.Config
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return c, errInvalidStoreURL(err)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if u.Host == "" && (scheme == "dynamodb" || scheme == "consul" || scheme == "etcd") {
+		return c, errStoreURLWithoutHost(scheme)
+	}
+	noCache := ct.NewOptDuration(0)
+	switch scheme {
+	case "redis", "rediss":
+		c.Redis.URL, err = ct.NewOptURLAbsoluteFromString(storeURL)
+		c.Redis.LocalTTL = noCache
+	case "dynamodb":
+		c.DynamoDB.Enabled = true
+		c.DynamoDB.TableName = u.Host
+		if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+			c.DynamoDB.URL, err = ct.NewOptURLAbsoluteFromString(endpoint)
+		}
+		c.DynamoDB.LocalTTL = noCache
+	case "consul":
+		c.Consul.Host = u.Host
+		c.Consul.LocalTTL = noCache
+	case "postgres", "postgresql":
+		c.Postgres.URL, err = ct.NewOptURLAbsoluteFromString(storeURL)
+		c.Postgres.LocalTTL = noCache
+	case "mongodb", "mongodb+srv":
+		c.MongoDB.URL, err = ct.NewOptURLAbsoluteFromString(storeURL)
+		c.MongoDB.Database = strings.TrimPrefix(u.Path, "/")
+		c.MongoDB.LocalTTL = noCache
+	case "etcd":
+		c.Etcd.Endpoints = ct.NewOptStringList([]string{u.Host})
+	default:
+		return c, errUnsupportedStoreURL(u.Scheme)
+	}
+	if err != nil {
+		return c, errInvalidStoreURL(err)
+	}
+	return c, nil
+}
@@ -0,0 +1,87 @@
+package mongodb
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments/membership"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+)
+
+// BigSegmentStoreFactory is a factory for the MongoDB implementation of the SDK's BigSegmentStore,
+// which reads the membership data that Relay's big segment synchronizer writes.
+type BigSegmentStoreFactory struct {
+	dbConfig config.MongoDBConfig
+	prefix   string
+}
+
+// BigSegmentStore returns a BigSegmentStoreFactory for the given configuration. If prefix is empty,
+// DefaultPrefix is used.
+func BigSegmentStore(dbConfig config.MongoDBConfig, prefix string) BigSegmentStoreFactory {
+	return BigSegmentStoreFactory{dbConfig: dbConfig, prefix: EffectivePrefix(prefix)}
+}
+
+// CreateBigSegmentStore is called by the SDK to create the big segment store implementation object.
+func (f BigSegmentStoreFactory) CreateBigSegmentStore(
+	context interfaces.ClientContext,
+) (interfaces.BigSegmentStore, error) {
+	db, err := OpenDatabase(f.dbConfig, f.prefix)
+	if err != nil {
+		return nil, err
+	}
+	loggers := context.GetLogging().GetLoggers()
+	loggers.SetPrefix("MongoDBBigSegmentStore:")
+	return &bigSegmentStoreImpl{db: db, loggers: loggers}, nil
+}
+
+type bigSegmentStoreImpl struct {
+	db      *Database
+	loggers ldlog.Loggers
+}
+
+func (store *bigSegmentStoreImpl) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	ctx := context.Background()
+	colls, err := store.db.Get(ctx)
+	if err != nil {
+		return interfaces.BigSegmentStoreMetadata{}, err
+	}
+	var doc MetadataDocument
+	err = colls.Metadata.FindOne(ctx, bson.M{"_id": BigSegmentsSynchronizedOnKey}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return interfaces.BigSegmentStoreMetadata{}, nil
+	}
+	if err != nil {
+		return interfaces.BigSegmentStoreMetadata{}, err
+	}
+	millis, err := strconv.ParseUint(doc.Value, 10, 64)
+	if err != nil {
+		return interfaces.BigSegmentStoreMetadata{}, err
+	}
+	return interfaces.BigSegmentStoreMetadata{LastUpToDate: ldtime.UnixMillisecondTime(millis)}, nil
+}
+
+func (store *bigSegmentStoreImpl) GetUserMembership(
+	userHashKey string,
+) (interfaces.BigSegmentMembership, error) {
+	ctx := context.Background()
+	colls, err := store.db.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var doc BigSegmentDocument
+	err = colls.BigSegments.FindOne(ctx, bson.M{"_id": userHashKey}).Decode(&doc)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	return membership.FromSegmentRefs(doc.Included, doc.Excluded), nil
+}
+
+func (store *bigSegmentStoreImpl) Close() error {
+	return store.db.Close()
+}
@@ -0,0 +1,214 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+)
+
+// DataStoreFactory is a factory for the MongoDB implementation of the SDK's PersistentDataStore. It
+// should be wrapped with ldcomponents.PersistentDataStore() to configure caching.
+type DataStoreFactory struct {
+	dbConfig config.MongoDBConfig
+	prefix   string
+}
+
+// DataStore returns a DataStoreFactory for the given configuration. If prefix is empty, DefaultPrefix
+// is used.
+func DataStore(dbConfig config.MongoDBConfig, prefix string) DataStoreFactory {
+	return DataStoreFactory{dbConfig: dbConfig, prefix: EffectivePrefix(prefix)}
+}
+
+// CreatePersistentDataStore is called by the SDK to create the data store implementation object.
+func (f DataStoreFactory) CreatePersistentDataStore(
+	context interfaces.ClientContext,
+) (interfaces.PersistentDataStore, error) {
+	db, err := OpenDatabase(f.dbConfig, f.prefix)
+	if err != nil {
+		return nil, err
+	}
+	loggers := context.GetLogging().GetLoggers()
+	loggers.SetPrefix("MongoDBDataStore:")
+	return &dataStoreImpl{db: db, loggers: loggers}, nil
+}
+
+// DescribeConfiguration is used internally by the SDK to inspect the configuration.
+func (f DataStoreFactory) DescribeConfiguration() ldvalue.Value {
+	return ldvalue.String("MongoDB")
+}
+
+type itemDocument struct {
+	ID      string `bson:"_id"`
+	Kind    string `bson:"kind"`
+	Key     string `bson:"key"`
+	Version int    `bson:"version"`
+	Deleted bool   `bson:"deleted"`
+	Data    string `bson:"data,omitempty"`
+}
+
+func makeItemDocument(kind ldstoretypes.DataKind, key string, item ldstoretypes.SerializedItemDescriptor) itemDocument {
+	return itemDocument{
+		ID:      itemID(kind, key),
+		Kind:    kind.GetName(),
+		Key:     key,
+		Version: item.Version,
+		Deleted: item.Deleted,
+		Data:    string(item.SerializedItem),
+	}
+}
+
+func (d itemDocument) descriptor() ldstoretypes.SerializedItemDescriptor {
+	item := ldstoretypes.SerializedItemDescriptor{Version: d.Version, Deleted: d.Deleted}
+	if d.Data != "" {
+		item.SerializedItem = []byte(d.Data)
+	}
+	return item
+}
+
+func itemID(kind ldstoretypes.DataKind, key string) string {
+	return kind.GetName() + ":" + key
+}
+
+type dataStoreImpl struct {
+	db      *Database
+	loggers ldlog.Loggers
+}
+
+// Init replaces all of the items and then deletes any that were not in the new data. Unlike the
+// PostgreSQL store, this is not done in a transaction, since MongoDB only supports multi-document
+// transactions on replica sets; if it fails partway through, the SDK will retry it.
+func (store *dataStoreImpl) Init(allData []ldstoretypes.SerializedCollection) error {
+	ctx := context.Background()
+	colls, err := store.db.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	var writes []mongo.WriteModel
+	ids := []string{}
+	for _, coll := range allData {
+		for _, item := range coll.Items {
+			doc := makeItemDocument(coll.Kind, item.Key, item.Item)
+			writes = append(writes, mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"_id": doc.ID}).SetReplacement(doc).SetUpsert(true))
+			ids = append(ids, doc.ID)
+		}
+	}
+	if len(writes) != 0 {
+		if _, err := colls.Items.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return err
+		}
+	}
+	if _, err := colls.Items.DeleteMany(ctx, bson.M{"_id": bson.M{"$nin": ids}}); err != nil {
+		return err
+	}
+
+	if _, err := colls.Metadata.ReplaceOne(ctx, bson.M{"_id": initedKey}, MetadataDocument{Key: initedKey},
+		options.Replace().SetUpsert(true)); err != nil {
+		return err
+	}
+
+	store.loggers.Infof("Initialized with %d items", len(ids))
+	return nil
+}
+
+func (store *dataStoreImpl) Get(
+	kind ldstoretypes.DataKind,
+	key string,
+) (ldstoretypes.SerializedItemDescriptor, error) {
+	ctx := context.Background()
+	colls, err := store.db.Get(ctx)
+	if err != nil {
+		return ldstoretypes.SerializedItemDescriptor{}.NotFound(), err
+	}
+	var doc itemDocument
+	err = colls.Items.FindOne(ctx, bson.M{"_id": itemID(kind, key)}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return ldstoretypes.SerializedItemDescriptor{}.NotFound(), nil
+	}
+	if err != nil {
+		return ldstoretypes.SerializedItemDescriptor{}.NotFound(), err
+	}
+	return doc.descriptor(), nil
+}
+
+func (store *dataStoreImpl) GetAll(
+	kind ldstoretypes.DataKind,
+) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	ctx := context.Background()
+	colls, err := store.db.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := colls.Items.Find(ctx, bson.M{"kind": kind.GetName()})
+	if err != nil {
+		return nil, err
+	}
+	var docs []itemDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	results := make([]ldstoretypes.KeyedSerializedItemDescriptor, 0, len(docs))
+	for _, doc := range docs {
+		results = append(results, ldstoretypes.KeyedSerializedItemDescriptor{Key: doc.Key, Item: doc.descriptor()})
+	}
+	return results, nil
+}
+
+func (store *dataStoreImpl) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	newItem ldstoretypes.SerializedItemDescriptor,
+) (bool, error) {
+	ctx := context.Background()
+	colls, err := store.db.Get(ctx)
+	if err != nil {
+		return false, err
+	}
+	// The replacement only matches if the existing version is lower. If the item exists with the same
+	// or a higher version, the upsert tries to insert a new document with the same _id, which fails
+	// with a duplicate key error. That can also happen if another Upsert inserted the item first, so
+	// in that case we try once more without inserting.
+	doc := makeItemDocument(kind, key, newItem)
+	filter := bson.M{"_id": doc.ID, "version": bson.M{"$lt": newItem.Version}}
+	result, err := colls.Items.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		result, err = colls.Items.ReplaceOne(ctx, filter, doc)
+	}
+	if err != nil {
+		return false, err
+	}
+	updated := result.MatchedCount > 0 || result.UpsertedCount > 0
+	if !updated && store.loggers.IsDebugEnabled() {
+		store.loggers.Debugf(`Attempted to update key: %s in "%s" with a version that is the same or older: %d`,
+			key, kind, newItem.Version)
+	}
+	return updated, nil
+}
+
+func (store *dataStoreImpl) IsInitialized() bool {
+	ctx := context.Background()
+	colls, err := store.db.Get(ctx)
+	if err != nil {
+		return false
+	}
+	var doc MetadataDocument
+	return colls.Metadata.FindOne(ctx, bson.M{"_id": initedKey}).Decode(&doc) == nil
+}
+
+func (store *dataStoreImpl) IsStoreAvailable() bool {
+	return store.db.Ping(context.Background()) == nil
+}
+
+func (store *dataStoreImpl) Close() error {
+	return store.db.Close()
+}
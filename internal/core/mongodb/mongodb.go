@@ -0,0 +1,220 @@
+package mongodb
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"strconv"
+	"sync"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// Implementation notes:
+//
+// - Each environment has its own three collections, whose names begin with the environment's prefix,
+// which defaults to DefaultPrefix. All of them are in the same database, which defaults to
+// DefaultDatabase.
+// - Feature flags, segments, and any other kind of entity the SDK may wish to store are documents in the
+// items collection, with an _id of "kind:key". The version number is stored in its own field so that
+// Upsert can be done as a single conditional replacement.
+// - Items collection documents for deleted items are kept as tombstones, with deleted set to true.
+// - The metadata collection holds single values such as the "$inited" marker and the big segments cursor,
+// with the key as the _id.
+// - The big segments collection has one document for each user hash, with arrays of the segment references
+// that the user is included in and excluded from.
+
+const (
+	// DefaultPrefix is the prefix that is used if an environment does not specify one.
+	DefaultPrefix = "launchdarkly"
+
+	// DefaultDatabase is the database that is used if the configuration does not specify one.
+	DefaultDatabase = "launchdarkly"
+
+	// BigSegmentsCursorKey is the metadata collection key for the version of the last big segment patch.
+	BigSegmentsCursorKey = "big_segments_cursor"
+
+	// BigSegmentsSynchronizedOnKey is the metadata collection key for the big segments synchronization time.
+	BigSegmentsSynchronizedOnKey = "big_segments_synchronized_on"
+
+	itemsCollectionSuffix       = "_items"
+	metadataCollectionSuffix    = "_metadata"
+	bigSegmentsCollectionSuffix = "_big_segments"
+	initedKey                   = "$inited"
+)
+
+var errNoCertsInCAFile = errors.New("MongoDB TLS CA file does not contain any PEM certificates") //nolint:gochecknoglobals
+
+// MetadataDocument is a document in the metadata collection.
+type MetadataDocument struct {
+	Key   string `bson:"_id"`
+	Value string `bson:"value"`
+}
+
+// BigSegmentDocument is a document in the big segments collection.
+type BigSegmentDocument struct {
+	UserHash string   `bson:"_id"`
+	Included []string `bson:"included,omitempty"`
+	Excluded []string `bson:"excluded,omitempty"`
+}
+
+// EffectivePrefix returns the prefix that will be used for an environment, which is DefaultPrefix if
+// the environment did not specify one.
+func EffectivePrefix(prefix string) string {
+	if prefix == "" {
+		return DefaultPrefix
+	}
+	return prefix
+}
+
+// EffectiveDatabase returns the name of the database that will be used, which is DefaultDatabase if
+// the configuration did not specify one.
+func EffectiveDatabase(dbConfig config.MongoDBConfig) string {
+	if dbConfig.Database == "" {
+		return DefaultDatabase
+	}
+	return dbConfig.Database
+}
+
+// MakeClientOptions transforms the configuration properties into options for the MongoDB driver. The
+// password, write concern, and TLS properties override any equivalent settings in the URL.
+func MakeClientOptions(dbConfig config.MongoDBConfig) (*options.ClientOptions, error) {
+	opts := options.Client().ApplyURI(dbConfig.URL.String())
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if dbConfig.Password != "" {
+		var credential options.Credential
+		if opts.Auth != nil {
+			credential = *opts.Auth
+		}
+		credential.Password, credential.PasswordSet = dbConfig.Password, true
+		opts.SetAuth(credential)
+	}
+	var writeConcernOptions []writeconcern.Option
+	switch dbConfig.WriteConcern {
+	case "":
+	case "majority":
+		writeConcernOptions = append(writeConcernOptions, writeconcern.WMajority())
+	default:
+		n, err := strconv.Atoi(dbConfig.WriteConcern)
+		if err != nil {
+			return nil, err
+		}
+		writeConcernOptions = append(writeConcernOptions, writeconcern.W(n))
+	}
+	if dbConfig.WriteConcernJournal {
+		writeConcernOptions = append(writeConcernOptions, writeconcern.J(true))
+	}
+	if dbConfig.WriteConcernTimeout.IsDefined() {
+		writeConcernOptions = append(writeConcernOptions,
+			writeconcern.WTimeout(dbConfig.WriteConcernTimeout.GetOrElse(0)))
+	}
+	if len(writeConcernOptions) != 0 {
+		opts.SetWriteConcern(writeconcern.New(writeConcernOptions...))
+	}
+	if dbConfig.TLS {
+		tlsConfig, err := makeTLSConfig(dbConfig)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+	return opts, nil
+}
+
+func makeTLSConfig(dbConfig config.MongoDBConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{} //nolint:gosec // the minimum version is the Go default
+	if dbConfig.TLSCAFile != "" {
+		data, err := ioutil.ReadFile(dbConfig.TLSCAFile) //nolint:gosec // the path comes from the configuration
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			return nil, errNoCertsInCAFile
+		}
+	}
+	if dbConfig.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(dbConfig.TLSCertFile, dbConfig.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Collections are the MongoDB collections that hold an environment's data.
+type Collections struct {
+	Items       *mongo.Collection
+	Metadata    *mongo.Collection
+	BigSegments *mongo.Collection
+}
+
+// Database is a MongoDB client for one environment's collections. The collections' indexes are created
+// the first time the database is successfully accessed, so that the data store can be created even if
+// the database is not yet available.
+type Database struct {
+	client         *mongo.Client
+	collections    Collections
+	indexesCreated bool
+	lock           sync.Mutex
+}
+
+// OpenDatabase creates a client with the configured options, for the collections with the given prefix.
+// It does not wait for the database to be available.
+func OpenDatabase(dbConfig config.MongoDBConfig, prefix string) (*Database, error) {
+	opts, err := MakeClientOptions(dbConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := mongo.Connect(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	db := client.Database(EffectiveDatabase(dbConfig))
+	prefix = EffectivePrefix(prefix)
+	return &Database{
+		client: client,
+		collections: Collections{
+			Items:       db.Collection(prefix + itemsCollectionSuffix),
+			Metadata:    db.Collection(prefix + metadataCollectionSuffix),
+			BigSegments: db.Collection(prefix + bigSegmentsCollectionSuffix),
+		},
+	}, nil
+}
+
+// Get returns the collections, after creating their indexes if that has not already been done.
+func (d *Database) Get(ctx context.Context) (Collections, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.indexesCreated {
+		// GetAll queries the items collection by kind; the other queries are all by _id.
+		if _, err := d.collections.Items.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "kind", Value: 1}},
+		}); err != nil {
+			return Collections{}, err
+		}
+		d.indexesCreated = true
+	}
+	return d.collections, nil
+}
+
+// Ping checks whether the database is available.
+func (d *Database) Ping(ctx context.Context) error {
+	return d.client.Ping(ctx, readpref.Primary())
+}
+
+// Close disconnects the client.
+func (d *Database) Close() error {
+	return d.client.Disconnect(context.Background())
+}
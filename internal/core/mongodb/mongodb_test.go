@@ -0,0 +1,107 @@
+package mongodb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectivePrefix(t *testing.T) {
+	assert.Equal(t, DefaultPrefix, EffectivePrefix(""))
+	assert.Equal(t, "abc", EffectivePrefix("abc"))
+}
+
+func TestEffectiveDatabase(t *testing.T) {
+	assert.Equal(t, DefaultDatabase, EffectiveDatabase(config.MongoDBConfig{}))
+	assert.Equal(t, "relay", EffectiveDatabase(config.MongoDBConfig{Database: "relay"}))
+}
+
+func TestMakeClientOptions(t *testing.T) {
+	makeConfig := func(url string) config.MongoDBConfig {
+		u, _ := ct.NewOptURLAbsoluteFromString(url)
+		return config.MongoDBConfig{URL: u}
+	}
+
+	t.Run("URL only", func(t *testing.T) {
+		opts, err := MakeClientOptions(makeConfig("mongodb://mongo1:27017,mongo2:27017/?replicaSet=rs0&w=2"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"mongo1:27017", "mongo2:27017"}, opts.Hosts)
+		assert.Equal(t, "rs0", *opts.ReplicaSet)
+		assert.Equal(t, 2, opts.WriteConcern.GetW())
+		assert.Nil(t, opts.Auth)
+		assert.Nil(t, opts.TLSConfig)
+	})
+
+	t.Run("password with user in URL", func(t *testing.T) {
+		c := makeConfig("mongodb://me@mongohost:27017/?authSource=admin")
+		c.Password = "secret"
+		opts, err := MakeClientOptions(c)
+		require.NoError(t, err)
+		assert.Equal(t, "me", opts.Auth.Username)
+		assert.Equal(t, "secret", opts.Auth.Password)
+		assert.Equal(t, "admin", opts.Auth.AuthSource)
+	})
+
+	t.Run("write concern", func(t *testing.T) {
+		c := makeConfig("mongodb://mongohost:27017/?w=1")
+		c.WriteConcern = "majority"
+		c.WriteConcernJournal = true
+		c.WriteConcernTimeout = ct.NewOptDuration(5 * time.Second)
+		opts, err := MakeClientOptions(c)
+		require.NoError(t, err)
+		assert.Equal(t, "majority", opts.WriteConcern.GetW())
+		assert.True(t, opts.WriteConcern.GetJ())
+		assert.Equal(t, 5*time.Second, opts.WriteConcern.GetWTimeout())
+
+		c.WriteConcern = "3"
+		opts, err = MakeClientOptions(c)
+		require.NoError(t, err)
+		assert.Equal(t, 3, opts.WriteConcern.GetW())
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		c := makeConfig("mongodb://mongohost:27017")
+		c.TLS = true
+		opts, err := MakeClientOptions(c)
+		require.NoError(t, err)
+		assert.NotNil(t, opts.TLSConfig)
+	})
+
+	t.Run("TLS CA file with no certificates", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.crt")
+		require.NoError(t, ioutil.WriteFile(caFile, []byte("not a certificate"), 0600))
+		c := makeConfig("mongodb://mongohost:27017")
+		c.TLS = true
+		c.TLSCAFile = caFile
+		_, err := MakeClientOptions(c)
+		assert.Equal(t, errNoCertsInCAFile, err)
+	})
+
+	t.Run("TLS CA file not found", func(t *testing.T) {
+		c := makeConfig("mongodb://mongohost:27017")
+		c.TLS = true
+		c.TLSCAFile = filepath.Join(t.TempDir(), "missing.crt")
+		_, err := MakeClientOptions(c)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestOpenDatabaseUsesCollectionsForPrefix(t *testing.T) {
+	u, _ := ct.NewOptURLAbsoluteFromString("mongodb://mongohost:27017")
+	db, err := OpenDatabase(config.MongoDBConfig{URL: u, Database: "relay"}, "abc")
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, "relay", db.collections.Items.Database().Name())
+	assert.Equal(t, "abc_items", db.collections.Items.Name())
+	assert.Equal(t, "abc_metadata", db.collections.Metadata.Name())
+	assert.Equal(t, "abc_big_segments", db.collections.BigSegments.Name())
+}
@@ -0,0 +1,5 @@
+// Package mongodb contains Relay's MongoDB integration: a persistent data store and a big segment store
+// for Relay's SDK instances, along with the connection handling that is shared with the Relay side of
+// the big segment store. There is no MongoDB integration in the Go SDK, so unlike most of the other
+// databases, this is implemented within Relay.
+package mongodb
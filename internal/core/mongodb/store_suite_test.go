@@ -0,0 +1,92 @@
+//go:build mongodb_tests
+// +build mongodb_tests
+
+package mongodb
+
+// These tests require a MongoDB server on localhost that accepts connections without authentication.
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/testhelpers/storetest"
+)
+
+const testURL = "mongodb://localhost:27017"
+
+func makeTestConfig() config.MongoDBConfig {
+	u, _ := ct.NewOptURLAbsoluteFromString(testURL)
+	return config.MongoDBConfig{URL: u, Database: "launchdarkly_tests"}
+}
+
+func withTestCollections(prefix string, action func(Collections) error) error {
+	db, err := OpenDatabase(makeTestConfig(), prefix)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	colls, err := db.Get(context.Background())
+	if err != nil {
+		return err
+	}
+	return action(colls)
+}
+
+func clearTestData(prefix string) error {
+	return withTestCollections(prefix, func(colls Collections) error {
+		ctx := context.Background()
+		for _, coll := range []*mongo.Collection{colls.Items, colls.Metadata, colls.BigSegments} {
+			if _, err := coll.DeleteMany(ctx, bson.M{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func TestMongoDBDataStore(t *testing.T) {
+	storetest.NewPersistentDataStoreTestSuite(
+		func(prefix string) interfaces.PersistentDataStoreFactory {
+			return DataStore(makeTestConfig(), prefix)
+		},
+		clearTestData,
+	).Run(t)
+}
+
+func TestMongoDBBigSegmentStore(t *testing.T) {
+	setMetadata := func(prefix string, metadata interfaces.BigSegmentStoreMetadata) error {
+		return withTestCollections(prefix, func(colls Collections) error {
+			doc := MetadataDocument{
+				Key:   BigSegmentsSynchronizedOnKey,
+				Value: strconv.FormatUint(uint64(metadata.LastUpToDate), 10),
+			}
+			_, err := colls.Metadata.ReplaceOne(context.Background(), bson.M{"_id": doc.Key}, doc,
+				options.Replace().SetUpsert(true))
+			return err
+		})
+	}
+	setSegments := func(prefix, userHashKey string, included, excluded []string) error {
+		return withTestCollections(prefix, func(colls Collections) error {
+			doc := BigSegmentDocument{UserHash: userHashKey, Included: included, Excluded: excluded}
+			_, err := colls.BigSegments.ReplaceOne(context.Background(), bson.M{"_id": userHashKey}, doc,
+				options.Replace().SetUpsert(true))
+			return err
+		})
+	}
+	storetest.NewBigSegmentStoreTestSuite(
+		func(prefix string) interfaces.BigSegmentStoreFactory {
+			return BigSegmentStore(makeTestConfig(), prefix)
+		},
+		clearTestData,
+		setMetadata,
+		setSegments,
+	).Run(t)
+}
@@ -0,0 +1,198 @@
+package pollcache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/streams"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+)
+
+// Endpoint identifies one of the PHP SDK polling endpoints.
+type Endpoint string
+
+const (
+	// AllFlags is the endpoint that returns all flags.
+	AllFlags Endpoint = "flags"
+	// Flag is the endpoint that returns a single flag.
+	Flag Endpoint = "flag"
+	// Segment is the endpoint that returns a single segment.
+	Segment Endpoint = "segment"
+)
+
+// Policy describes how long a polling endpoint's responses can be reused.
+type Policy struct {
+	// TTL is how long a response is fresh. If it is zero, responses must always be revalidated.
+	TTL time.Duration
+	// StaleWhileRevalidate is how much longer than the TTL a response can still be used while a newer
+	// one is being fetched.
+	StaleWhileRevalidate time.Duration
+}
+
+// Response is a polling endpoint's response body, and the value that its ETag is based on.
+type Response struct {
+	Data []byte
+	ETag string
+}
+
+// Cache holds an environment's polling endpoint settings, and its recent polling responses.
+//
+// Responses are only cached if the endpoint has both a TTL and a stale-while-revalidate time. A cached
+// response is returned as it is until the TTL has passed; after that, for up to the stale-while-revalidate
+// time, it is still returned, but the first such request also starts reading a new response from the data
+// store in the background. This means that, however many requests arrive, each response is read from the
+// store no more than once per TTL, and none of the requests has to wait for the store. All cached responses
+// are discarded whenever a flag or segment changes (see WrapUpdates).
+//
+// All of its methods can be called on a nil *Cache, which uses the environment's default TTL for every
+// endpoint and caches nothing.
+type Cache struct {
+	ttls                 map[Endpoint]ct.OptDuration
+	staleWhileRevalidate time.Duration
+	entries              map[string]*entry
+	generation           uint64
+	now                  func() time.Time
+	lock                 sync.Mutex
+}
+
+type entry struct {
+	response   Response
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+type invalidatingUpdates struct {
+	target streams.EnvStreamUpdates
+	cache  *Cache
+}
+
+// NewCache creates a Cache. The TTLs override the environment's default TTL for specific endpoints. If
+// there are no overrides and staleWhileRevalidate is not greater than zero, it returns nil.
+func NewCache(ttls map[Endpoint]ct.OptDuration, staleWhileRevalidate time.Duration) *Cache {
+	overrides := make(map[Endpoint]ct.OptDuration)
+	for endpoint, ttl := range ttls {
+		if ttl.IsDefined() {
+			overrides[endpoint] = ttl
+		}
+	}
+	if len(overrides) == 0 && staleWhileRevalidate <= 0 {
+		return nil
+	}
+	return &Cache{
+		ttls:                 overrides,
+		staleWhileRevalidate: staleWhileRevalidate,
+		entries:              make(map[string]*entry),
+		now:                  time.Now,
+	}
+}
+
+// Policy returns the caching policy for an endpoint, given the environment's default TTL.
+func (c *Cache) Policy(endpoint Endpoint, defaultTTL time.Duration) Policy {
+	if c == nil {
+		return Policy{TTL: defaultTTL}
+	}
+	p := Policy{TTL: c.ttls[endpoint].GetOrElse(defaultTTL)}
+	if p.TTL > 0 {
+		p.StaleWhileRevalidate = c.staleWhileRevalidate
+	}
+	return p
+}
+
+// Get returns the response for an endpoint and item key, from the cache if possible, or else by calling
+// fetch. A response with nil Data, meaning that the item was not found, is not cached; neither is an error.
+func (c *Cache) Get(endpoint Endpoint, key string, policy Policy, fetch func() (Response, error)) (Response, error) {
+	if c == nil || policy.TTL <= 0 || policy.StaleWhileRevalidate <= 0 {
+		return fetch()
+	}
+	cacheKey := string(endpoint) + "/" + key
+	c.lock.Lock()
+	if e, ok := c.entries[cacheKey]; ok {
+		age := c.now().Sub(e.fetchedAt)
+		if age < policy.TTL {
+			c.lock.Unlock()
+			return e.response, nil
+		}
+		if age < policy.TTL+policy.StaleWhileRevalidate {
+			if !e.refreshing {
+				e.refreshing = true
+				go c.refresh(cacheKey, c.generation, fetch)
+			}
+			c.lock.Unlock()
+			return e.response, nil
+		}
+	}
+	generation := c.generation
+	c.lock.Unlock()
+
+	resp, err := fetch()
+	if err == nil {
+		c.store(cacheKey, resp, generation)
+	}
+	return resp, err
+}
+
+func (c *Cache) refresh(cacheKey string, generation uint64, fetch func() (Response, error)) {
+	resp, err := fetch()
+	if err != nil {
+		// Keep using the stale response; once it is too old, the next request will get the error.
+		c.lock.Lock()
+		if e, ok := c.entries[cacheKey]; ok {
+			e.refreshing = false
+		}
+		c.lock.Unlock()
+		return
+	}
+	c.store(cacheKey, resp, generation)
+}
+
+// store caches a response, unless the cache has been cleared since generation was obtained, since the
+// response might have been read before the change that caused that.
+func (c *Cache) store(cacheKey string, resp Response, generation uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if resp.Data == nil {
+		delete(c.entries, cacheKey)
+		return
+	}
+	c.entries[cacheKey] = &entry{response: resp, fetchedAt: c.now()}
+}
+
+// Clear discards all cached responses.
+func (c *Cache) Clear() {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	c.entries = make(map[string]*entry)
+	c.generation++
+	c.lock.Unlock()
+}
+
+// WrapUpdates returns an EnvStreamUpdates that clears the cache and then passes all updates on to
+// target. If c is nil, it returns target unchanged.
+func (c *Cache) WrapUpdates(target streams.EnvStreamUpdates) streams.EnvStreamUpdates {
+	if c == nil {
+		return target
+	}
+	return &invalidatingUpdates{target: target, cache: c}
+}
+
+func (u *invalidatingUpdates) SendAllDataUpdate(allData []ldstoretypes.Collection) {
+	u.cache.Clear()
+	u.target.SendAllDataUpdate(allData)
+}
+
+func (u *invalidatingUpdates) SendSingleItemUpdate(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
+	u.cache.Clear()
+	u.target.SendSingleItemUpdate(kind, key, item)
+}
+
+// InvalidateClientSideState does not affect the polling responses, which contain only flag and segment data.
+func (u *invalidatingUpdates) InvalidateClientSideState() {
+	u.target.InvalidateClientSideState()
+}
@@ -0,0 +1,215 @@
+package pollcache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nullUpdates struct{}
+
+func (nullUpdates) SendAllDataUpdate([]ldstoretypes.Collection) {}
+
+func (nullUpdates) SendSingleItemUpdate(ldstoretypes.DataKind, string, ldstoretypes.ItemDescriptor) {}
+
+func (nullUpdates) InvalidateClientSideState() {}
+
+var testPolicy = Policy{TTL: time.Minute, StaleWhileRevalidate: time.Minute} //nolint:gochecknoglobals
+
+func makeTestCache() (*Cache, *time.Time) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewCache(nil, time.Minute)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+// fetcher returns a fetch function whose responses have the ETag values in sequence, and reports each
+// call on a channel.
+func fetcher(etags ...string) (func() (Response, error), <-chan string) {
+	ch := make(chan string, len(etags))
+	i := 0
+	return func() (Response, error) {
+		etag := etags[i]
+		i++
+		ch <- etag
+		if etag == "error" {
+			return Response{}, errors.New("sorry")
+		}
+		return Response{Data: []byte("data-" + etag), ETag: etag}, nil
+	}, ch
+}
+
+func expectFetch(t *testing.T, ch <-chan string, expected string) {
+	select {
+	case etag := <-ch:
+		assert.Equal(t, expected, etag)
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "timed out waiting for fetch")
+	}
+}
+
+func TestNewCacheReturnsNilIfNothingIsConfigured(t *testing.T) {
+	c := NewCache(map[Endpoint]ct.OptDuration{AllFlags: {}}, 0)
+	assert.Nil(t, c)
+	assert.Equal(t, Policy{TTL: time.Hour}, c.Policy(AllFlags, time.Hour))
+
+	fetch, ch := fetcher("a", "b")
+	resp, err := c.Get(AllFlags, "", testPolicy, fetch)
+	require.NoError(t, err)
+	assert.Equal(t, "a", resp.ETag)
+	resp, _ = c.Get(AllFlags, "", testPolicy, fetch)
+	assert.Equal(t, "b", resp.ETag)
+	assert.Len(t, ch, 2)
+	c.Clear()
+	target := nullUpdates{}
+	assert.Equal(t, target, c.WrapUpdates(target))
+}
+
+func TestPolicyUsesEndpointTTLOrDefault(t *testing.T) {
+	c := NewCache(map[Endpoint]ct.OptDuration{Flag: ct.NewOptDuration(time.Second * 10)}, 0)
+	assert.Equal(t, Policy{TTL: time.Second * 10}, c.Policy(Flag, time.Minute))
+	assert.Equal(t, Policy{TTL: time.Minute}, c.Policy(AllFlags, time.Minute))
+	assert.Equal(t, Policy{}, c.Policy(Segment, 0))
+}
+
+func TestPolicyIncludesStaleWhileRevalidateOnlyIfThereIsATTL(t *testing.T) {
+	c := NewCache(map[Endpoint]ct.OptDuration{Segment: ct.NewOptDuration(0)}, time.Second*30)
+	assert.Equal(t, Policy{TTL: time.Minute, StaleWhileRevalidate: time.Second * 30}, c.Policy(Flag, time.Minute))
+	assert.Equal(t, Policy{}, c.Policy(Segment, time.Minute))
+}
+
+func TestGetReturnsCachedResponseUntilTTLExpires(t *testing.T) {
+	c, now := makeTestCache()
+	fetch, ch := fetcher("a", "b")
+
+	resp, err := c.Get(Flag, "key", testPolicy, fetch)
+	require.NoError(t, err)
+	assert.Equal(t, Response{Data: []byte("data-a"), ETag: "a"}, resp)
+	expectFetch(t, ch, "a")
+
+	*now = now.Add(time.Second * 59)
+	resp, _ = c.Get(Flag, "key", testPolicy, fetch)
+	assert.Equal(t, "a", resp.ETag)
+	assert.Len(t, ch, 0)
+}
+
+func TestGetReturnsStaleResponseWhileRefreshing(t *testing.T) {
+	c, now := makeTestCache()
+	fetch, ch := fetcher("a", "b")
+	_, _ = c.Get(Flag, "key", testPolicy, fetch)
+	expectFetch(t, ch, "a")
+
+	*now = now.Add(time.Second * 90)
+	resp, _ := c.Get(Flag, "key", testPolicy, fetch)
+	assert.Equal(t, "a", resp.ETag)
+	expectFetch(t, ch, "b")
+
+	require.Eventually(t, func() bool {
+		resp, _ := c.Get(Flag, "key", testPolicy, fetch)
+		return resp.ETag == "b"
+	}, time.Second*5, time.Millisecond*10)
+	assert.Len(t, ch, 0)
+}
+
+func TestGetKeepsStaleResponseIfRefreshFails(t *testing.T) {
+	c, now := makeTestCache()
+	fetch, ch := fetcher("a", "error", "error")
+	_, _ = c.Get(Flag, "key", testPolicy, fetch)
+	expectFetch(t, ch, "a")
+
+	*now = now.Add(time.Second * 90)
+	resp, _ := c.Get(Flag, "key", testPolicy, fetch)
+	assert.Equal(t, "a", resp.ETag)
+	expectFetch(t, ch, "error")
+
+	*now = now.Add(time.Minute)
+	_, err := c.Get(Flag, "key", testPolicy, fetch)
+	assert.Error(t, err)
+	expectFetch(t, ch, "error")
+}
+
+func TestGetFetchesAgainAfterStaleTimeExpires(t *testing.T) {
+	c, now := makeTestCache()
+	fetch, ch := fetcher("a", "b")
+	_, _ = c.Get(Flag, "key", testPolicy, fetch)
+	expectFetch(t, ch, "a")
+
+	*now = now.Add(time.Minute * 2)
+	resp, _ := c.Get(Flag, "key", testPolicy, fetch)
+	assert.Equal(t, "b", resp.ETag)
+	expectFetch(t, ch, "b")
+}
+
+func TestGetDoesNotCacheNotFoundResponses(t *testing.T) {
+	c, _ := makeTestCache()
+	calls := 0
+	fetch := func() (Response, error) {
+		calls++
+		return Response{}, nil
+	}
+	_, _ = c.Get(Flag, "key", testPolicy, fetch)
+	_, _ = c.Get(Flag, "key", testPolicy, fetch)
+	assert.Equal(t, 2, calls)
+}
+
+func TestGetDoesNotCacheWithoutStaleWhileRevalidate(t *testing.T) {
+	c, _ := makeTestCache()
+	fetch, ch := fetcher("a", "b")
+	policy := Policy{TTL: time.Minute}
+	_, _ = c.Get(Flag, "key", policy, fetch)
+	resp, _ := c.Get(Flag, "key", policy, fetch)
+	assert.Equal(t, "b", resp.ETag)
+	assert.Len(t, ch, 2)
+}
+
+func TestEndpointsAndKeysAreCachedSeparately(t *testing.T) {
+	c, _ := makeTestCache()
+	fetch, _ := fetcher("a", "b", "c")
+	r1, _ := c.Get(Flag, "x", testPolicy, fetch)
+	r2, _ := c.Get(Flag, "y", testPolicy, fetch)
+	r3, _ := c.Get(Segment, "x", testPolicy, fetch)
+	assert.Equal(t, []string{"a", "b", "c"}, []string{r1.ETag, r2.ETag, r3.ETag})
+}
+
+func TestCacheIsClearedByDataUpdates(t *testing.T) {
+	c, _ := makeTestCache()
+	updates := c.WrapUpdates(nullUpdates{})
+	fetch, _ := fetcher("a", "b", "c", "d")
+
+	_, _ = c.Get(AllFlags, "", testPolicy, fetch)
+	updates.SendSingleItemUpdate(ldstoreimpl.Features(), "flag", ldstoretypes.ItemDescriptor{})
+	resp, _ := c.Get(AllFlags, "", testPolicy, fetch)
+	assert.Equal(t, "b", resp.ETag)
+
+	updates.SendAllDataUpdate(nil)
+	resp, _ = c.Get(AllFlags, "", testPolicy, fetch)
+	assert.Equal(t, "c", resp.ETag)
+
+	updates.InvalidateClientSideState()
+	resp, _ = c.Get(AllFlags, "", testPolicy, fetch)
+	assert.Equal(t, "c", resp.ETag)
+}
+
+func TestResponseFetchedBeforeClearIsNotCached(t *testing.T) {
+	c, _ := makeTestCache()
+	var fetch func() (Response, error)
+	first := true
+	fetch = func() (Response, error) {
+		if first {
+			first = false
+			c.Clear() // simulates an update arriving while the store is being read
+			return Response{Data: []byte("old"), ETag: "old"}, nil
+		}
+		return Response{Data: []byte("new"), ETag: "new"}, nil
+	}
+	_, _ = c.Get(AllFlags, "", testPolicy, fetch)
+	resp, _ := c.Get(AllFlags, "", testPolicy, fetch)
+	assert.Equal(t, "new", resp.ETag)
+}
@@ -0,0 +1,4 @@
+// Package pollcache contains the caching policy for the PHP SDK polling endpoints, and the optional
+// cache that lets Relay keep serving a recent response from those endpoints while it reads a new one
+// from the data store in the background.
+package pollcache
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments/membership"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+)
+
+// BigSegmentStoreFactory is a factory for the PostgreSQL implementation of the SDK's BigSegmentStore,
+// which reads the membership data that Relay's big segment synchronizer writes.
+type BigSegmentStoreFactory struct {
+	dbConfig config.PostgresConfig
+	prefix   string
+}
+
+// BigSegmentStore returns a BigSegmentStoreFactory for the given configuration. If prefix is empty,
+// DefaultPrefix is used.
+func BigSegmentStore(dbConfig config.PostgresConfig, prefix string) BigSegmentStoreFactory {
+	return BigSegmentStoreFactory{dbConfig: dbConfig, prefix: EffectivePrefix(prefix)}
+}
+
+// CreateBigSegmentStore is called by the SDK to create the big segment store implementation object.
+func (f BigSegmentStoreFactory) CreateBigSegmentStore(
+	context interfaces.ClientContext,
+) (interfaces.BigSegmentStore, error) {
+	db, err := OpenDatabase(f.dbConfig)
+	if err != nil {
+		return nil, err
+	}
+	loggers := context.GetLogging().GetLoggers()
+	loggers.SetPrefix("PostgresBigSegmentStore:")
+	return &bigSegmentStoreImpl{db: db, prefix: f.prefix, loggers: loggers}, nil
+}
+
+type bigSegmentStoreImpl struct {
+	db      *Database
+	prefix  string
+	loggers ldlog.Loggers
+}
+
+func (store *bigSegmentStoreImpl) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	ctx := context.Background()
+	db, err := store.db.Get(ctx)
+	if err != nil {
+		return interfaces.BigSegmentStoreMetadata{}, err
+	}
+	var value string
+	err = db.QueryRowContext(ctx, `SELECT value FROM `+MetadataTable+` WHERE prefix = $1 AND key = $2`,
+		store.prefix, BigSegmentsSynchronizedOnKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return interfaces.BigSegmentStoreMetadata{}, nil
+	}
+	if err != nil {
+		return interfaces.BigSegmentStoreMetadata{}, err
+	}
+	millis, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return interfaces.BigSegmentStoreMetadata{}, err
+	}
+	return interfaces.BigSegmentStoreMetadata{LastUpToDate: ldtime.UnixMillisecondTime(millis)}, nil
+}
+
+func (store *bigSegmentStoreImpl) GetUserMembership(
+	userHashKey string,
+) (interfaces.BigSegmentMembership, error) {
+	ctx := context.Background()
+	db, err := store.db.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, `SELECT segment_ref, included FROM `+BigSegmentsTable+
+		` WHERE prefix = $1 AND user_hash = $2`, store.prefix, userHashKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var includedRefs, excludedRefs []string
+	for rows.Next() {
+		var ref string
+		var included bool
+		if err := rows.Scan(&ref, &included); err != nil {
+			return nil, err
+		}
+		if included {
+			includedRefs = append(includedRefs, ref)
+		} else {
+			excludedRefs = append(excludedRefs, ref)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return membership.FromSegmentRefs(includedRefs, excludedRefs), nil
+}
+
+func (store *bigSegmentStoreImpl) Close() error {
+	return store.db.Close()
+}
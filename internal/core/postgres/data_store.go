@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+)
+
+// DataStoreFactory is a factory for the PostgreSQL implementation of the SDK's PersistentDataStore. It
+// should be wrapped with ldcomponents.PersistentDataStore() to configure caching.
+type DataStoreFactory struct {
+	dbConfig config.PostgresConfig
+	prefix   string
+}
+
+// DataStore returns a DataStoreFactory for the given configuration. If prefix is empty, DefaultPrefix
+// is used.
+func DataStore(dbConfig config.PostgresConfig, prefix string) DataStoreFactory {
+	return DataStoreFactory{dbConfig: dbConfig, prefix: EffectivePrefix(prefix)}
+}
+
+// CreatePersistentDataStore is called by the SDK to create the data store implementation object.
+func (f DataStoreFactory) CreatePersistentDataStore(
+	context interfaces.ClientContext,
+) (interfaces.PersistentDataStore, error) {
+	db, err := OpenDatabase(f.dbConfig)
+	if err != nil {
+		return nil, err
+	}
+	loggers := context.GetLogging().GetLoggers()
+	loggers.SetPrefix("PostgresDataStore:")
+	return &dataStoreImpl{db: db, prefix: f.prefix, loggers: loggers}, nil
+}
+
+// DescribeConfiguration is used internally by the SDK to inspect the configuration.
+func (f DataStoreFactory) DescribeConfiguration() ldvalue.Value {
+	return ldvalue.String("Postgres")
+}
+
+type dataStoreImpl struct {
+	db      *Database
+	prefix  string
+	loggers ldlog.Loggers
+}
+
+func (store *dataStoreImpl) Init(allData []ldstoretypes.SerializedCollection) error {
+	ctx := context.Background()
+	db, err := store.db.Get(ctx)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // has no effect if the transaction was committed
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM `+ItemsTable+` WHERE prefix = $1`, store.prefix); err != nil {
+		return err
+	}
+	insert, err := tx.PrepareContext(ctx, `INSERT INTO `+ItemsTable+
+		` (prefix, kind, key, version, deleted, data) VALUES ($1, $2, $3, $4, $5, $6)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close() //nolint:errcheck
+
+	totalCount := 0
+	for _, coll := range allData {
+		for _, item := range coll.Items {
+			if _, err := insert.ExecContext(ctx, store.prefix, coll.Kind.GetName(), item.Key,
+				item.Item.Version, item.Item.Deleted, serializedData(item.Item.SerializedItem)); err != nil {
+				return err
+			}
+			totalCount++
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO `+MetadataTable+` (prefix, key, value) VALUES ($1, $2, '')
+		ON CONFLICT (prefix, key) DO NOTHING`, store.prefix, initedKey); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	store.loggers.Infof("Initialized with %d items", totalCount)
+	return nil
+}
+
+func (store *dataStoreImpl) Get(
+	kind ldstoretypes.DataKind,
+	key string,
+) (ldstoretypes.SerializedItemDescriptor, error) {
+	ctx := context.Background()
+	db, err := store.db.Get(ctx)
+	if err != nil {
+		return ldstoretypes.SerializedItemDescriptor{}.NotFound(), err
+	}
+	var item ldstoretypes.SerializedItemDescriptor
+	var data sql.NullString
+	err = db.QueryRowContext(ctx, `SELECT version, deleted, data FROM `+ItemsTable+
+		` WHERE prefix = $1 AND kind = $2 AND key = $3`, store.prefix, kind.GetName(), key).
+		Scan(&item.Version, &item.Deleted, &data)
+	if err == sql.ErrNoRows {
+		return ldstoretypes.SerializedItemDescriptor{}.NotFound(), nil
+	}
+	if err != nil {
+		return ldstoretypes.SerializedItemDescriptor{}.NotFound(), err
+	}
+	if data.Valid {
+		item.SerializedItem = []byte(data.String)
+	}
+	return item, nil
+}
+
+func (store *dataStoreImpl) GetAll(
+	kind ldstoretypes.DataKind,
+) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	ctx := context.Background()
+	db, err := store.db.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, `SELECT key, version, deleted, data FROM `+ItemsTable+
+		` WHERE prefix = $1 AND kind = $2`, store.prefix, kind.GetName())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var results []ldstoretypes.KeyedSerializedItemDescriptor
+	for rows.Next() {
+		var keyedItem ldstoretypes.KeyedSerializedItemDescriptor
+		var data sql.NullString
+		if err := rows.Scan(&keyedItem.Key, &keyedItem.Item.Version, &keyedItem.Item.Deleted, &data); err != nil {
+			return nil, err
+		}
+		if data.Valid {
+			keyedItem.Item.SerializedItem = []byte(data.String)
+		}
+		results = append(results, keyedItem)
+	}
+	return results, rows.Err()
+}
+
+func (store *dataStoreImpl) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	newItem ldstoretypes.SerializedItemDescriptor,
+) (bool, error) {
+	ctx := context.Background()
+	db, err := store.db.Get(ctx)
+	if err != nil {
+		return false, err
+	}
+	// The update only happens if the existing version is lower, so no rows are affected if the new
+	// item is stale.
+	result, err := db.ExecContext(ctx, `INSERT INTO `+ItemsTable+` AS t
+		(prefix, kind, key, version, deleted, data) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (prefix, kind, key) DO UPDATE
+		SET version = EXCLUDED.version, deleted = EXCLUDED.deleted, data = EXCLUDED.data
+		WHERE t.version < EXCLUDED.version`,
+		store.prefix, kind.GetName(), key, newItem.Version, newItem.Deleted, serializedData(newItem.SerializedItem))
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if count == 0 && store.loggers.IsDebugEnabled() {
+		store.loggers.Debugf(`Attempted to update key: %s in "%s" with a version that is the same or older: %d`,
+			key, kind, newItem.Version)
+	}
+	return count > 0, nil
+}
+
+func (store *dataStoreImpl) IsInitialized() bool {
+	ctx := context.Background()
+	db, err := store.db.Get(ctx)
+	if err != nil {
+		return false
+	}
+	var value string
+	err = db.QueryRowContext(ctx, `SELECT value FROM `+MetadataTable+` WHERE prefix = $1 AND key = $2`,
+		store.prefix, initedKey).Scan(&value)
+	return err == nil
+}
+
+func (store *dataStoreImpl) IsStoreAvailable() bool {
+	ctx := context.Background()
+	db, err := store.db.Get(ctx)
+	if err != nil {
+		return false
+	}
+	return db.PingContext(ctx) == nil
+}
+
+func (store *dataStoreImpl) Close() error {
+	return store.db.Close()
+}
+
+// serializedData converts a serialized item to a column value. A deleted item may not have a serialized
+// form, in which case the column is NULL.
+func serializedData(data []byte) interface{} {
+	if data == nil {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,5 @@
+// Package postgres contains Relay's PostgreSQL integration: a persistent data store and a big segment
+// store for Relay's SDK instances, along with the connection handling that is shared with the Relay
+// side of the big segment store. There is no PostgreSQL integration in the Go SDK, so unlike the other
+// databases, this is implemented within Relay.
+package postgres
@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"sync"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	_ "github.com/lib/pq" // registers the "postgres" driver for database/sql
+)
+
+// Implementation notes:
+//
+// - All environments that use the same database share the same three tables; rows are distinguished by
+// the environment's prefix, which defaults to DefaultPrefix.
+// - Feature flags, segments, and any other kind of entity the SDK may wish to store are rows in ItemsTable,
+// keyed by (prefix, kind, key). The version number is stored in its own column so that Upsert can be done
+// as a single conditional statement.
+// - ItemsTable rows for deleted items are kept as tombstones, with deleted set to true.
+// - MetadataTable holds single values such as the "$inited" marker and the big segments cursor.
+// - BigSegmentsTable has one row for each (user hash, segment reference) pair, with included set to true
+// or false for inclusions and exclusions respectively.
+
+const (
+	// DefaultPrefix is the prefix that is used if an environment does not specify one.
+	DefaultPrefix = "launchdarkly"
+
+	// ItemsTable is the name of the table containing flag and segment data.
+	ItemsTable = "launchdarkly_items"
+
+	// MetadataTable is the name of the table containing single values for each prefix.
+	MetadataTable = "launchdarkly_metadata"
+
+	// BigSegmentsTable is the name of the table containing big segment membership.
+	BigSegmentsTable = "launchdarkly_big_segments"
+
+	// BigSegmentsCursorKey is the MetadataTable key for the version of the last big segment patch.
+	BigSegmentsCursorKey = "big_segments_cursor"
+
+	// BigSegmentsSynchronizedOnKey is the MetadataTable key for the big segments synchronization time.
+	BigSegmentsSynchronizedOnKey = "big_segments_synchronized_on"
+
+	initedKey = "$inited"
+)
+
+var schemaStatements = []string{ //nolint:gochecknoglobals
+	`CREATE TABLE IF NOT EXISTS ` + ItemsTable + ` (
+		prefix TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		key TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		deleted BOOLEAN NOT NULL,
+		data TEXT,
+		PRIMARY KEY (prefix, kind, key)
+	)`,
+	`CREATE TABLE IF NOT EXISTS ` + MetadataTable + ` (
+		prefix TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (prefix, key)
+	)`,
+	`CREATE TABLE IF NOT EXISTS ` + BigSegmentsTable + ` (
+		prefix TEXT NOT NULL,
+		user_hash TEXT NOT NULL,
+		segment_ref TEXT NOT NULL,
+		included BOOLEAN NOT NULL,
+		PRIMARY KEY (prefix, user_hash, segment_ref, included)
+	)`,
+}
+
+// EffectivePrefix returns the prefix that will be used for an environment, which is DefaultPrefix if
+// the environment did not specify one.
+func EffectivePrefix(prefix string) string {
+	if prefix == "" {
+		return DefaultPrefix
+	}
+	return prefix
+}
+
+// MakeConnectionString transforms the configuration properties into a connection URL for the
+// PostgreSQL driver, adding the password and TLS parameters if they were specified separately.
+func MakeConnectionString(dbConfig config.PostgresConfig) string {
+	if !dbConfig.URL.IsDefined() {
+		return ""
+	}
+	u := *dbConfig.URL.Get()
+	if dbConfig.Password != "" {
+		username := ""
+		if u.User != nil {
+			username = u.User.Username()
+		}
+		u.User = url.UserPassword(username, dbConfig.Password)
+	}
+	query := u.Query()
+	setIfNotEmpty := func(name, value string) {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	setIfNotEmpty("sslmode", dbConfig.TLSMode)
+	setIfNotEmpty("sslrootcert", dbConfig.TLSRootCertFile)
+	setIfNotEmpty("sslcert", dbConfig.TLSCertFile)
+	setIfNotEmpty("sslkey", dbConfig.TLSKeyFile)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Database is a connection pool for the configured PostgreSQL database. Relay's tables are created the
+// first time the database is successfully accessed, so that the data store can be created even if the
+// database is not yet available.
+type Database struct {
+	db            *sql.DB
+	schemaCreated bool
+	lock          sync.Mutex
+}
+
+// OpenDatabase creates a connection pool with the configured pool parameters. It does not connect to
+// the database until it is used.
+func OpenDatabase(dbConfig config.PostgresConfig) (*Database, error) {
+	db, err := sql.Open("postgres", MakeConnectionString(dbConfig))
+	if err != nil {
+		return nil, err
+	}
+	if dbConfig.MaxOpenConns.IsDefined() {
+		db.SetMaxOpenConns(dbConfig.MaxOpenConns.GetOrElse(0))
+	}
+	if dbConfig.MaxIdleConns.IsDefined() {
+		db.SetMaxIdleConns(dbConfig.MaxIdleConns.GetOrElse(0))
+	}
+	if dbConfig.ConnMaxLifetime.IsDefined() {
+		db.SetConnMaxLifetime(dbConfig.ConnMaxLifetime.GetOrElse(0))
+	}
+	return &Database{db: db}, nil
+}
+
+// Get returns the underlying connection pool, after creating Relay's tables if that has not already
+// been done.
+func (d *Database) Get(ctx context.Context) (*sql.DB, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.schemaCreated {
+		for _, statement := range schemaStatements {
+			if _, err := d.db.ExecContext(ctx, statement); err != nil {
+				return nil, err
+			}
+		}
+		d.schemaCreated = true
+	}
+	return d.db, nil
+}
+
+// Close closes the connection pool.
+func (d *Database) Close() error {
+	return d.db.Close()
+}
@@ -0,0 +1,124 @@
+//go:build postgres_tests
+// +build postgres_tests
+
+package postgres
+
+// These tests require a PostgreSQL server on localhost that accepts connections for the "postgres"
+// user without a password.
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/testhelpers/storetest"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testURL = "postgres://postgres@localhost:5432/postgres?sslmode=disable"
+
+func makeTestConfig() config.PostgresConfig {
+	u, _ := ct.NewOptURLAbsoluteFromString(testURL)
+	return config.PostgresConfig{URL: u}
+}
+
+func withTestDatabase(action func(*Database) error) error {
+	db, err := OpenDatabase(makeTestConfig())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return action(db)
+}
+
+func clearTestData(prefix string) error {
+	return withTestDatabase(func(d *Database) error {
+		db, err := d.Get(context.Background())
+		if err != nil {
+			return err
+		}
+		for _, table := range []string{ItemsTable, MetadataTable, BigSegmentsTable} {
+			if _, err := db.Exec(`DELETE FROM `+table+` WHERE prefix = $1`, EffectivePrefix(prefix)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func TestPostgresDataStore(t *testing.T) {
+	storetest.NewPersistentDataStoreTestSuite(
+		func(prefix string) interfaces.PersistentDataStoreFactory {
+			return DataStore(makeTestConfig(), prefix)
+		},
+		clearTestData,
+	).Run(t)
+}
+
+func TestPostgresBigSegmentStore(t *testing.T) {
+	setMetadata := func(prefix string, metadata interfaces.BigSegmentStoreMetadata) error {
+		return withTestDatabase(func(d *Database) error {
+			db, err := d.Get(context.Background())
+			if err != nil {
+				return err
+			}
+			_, err = db.Exec(`INSERT INTO `+MetadataTable+` (prefix, key, value) VALUES ($1, $2, $3)
+				ON CONFLICT (prefix, key) DO UPDATE SET value = EXCLUDED.value`,
+				EffectivePrefix(prefix), BigSegmentsSynchronizedOnKey,
+				strconv.FormatUint(uint64(metadata.LastUpToDate), 10))
+			return err
+		})
+	}
+	setSegments := func(prefix, userHashKey string, included, excluded []string) error {
+		return withTestDatabase(func(d *Database) error {
+			db, err := d.Get(context.Background())
+			if err != nil {
+				return err
+			}
+			insert := func(refs []string, isIncluded bool) error {
+				for _, ref := range refs {
+					if _, err := db.Exec(`INSERT INTO `+BigSegmentsTable+
+						` (prefix, user_hash, segment_ref, included) VALUES ($1, $2, $3, $4)`,
+						EffectivePrefix(prefix), userHashKey, ref, isIncluded); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			if err := insert(included, true); err != nil {
+				return err
+			}
+			return insert(excluded, false)
+		})
+	}
+	storetest.NewBigSegmentStoreTestSuite(
+		func(prefix string) interfaces.BigSegmentStoreFactory {
+			return BigSegmentStore(makeTestConfig(), prefix)
+		},
+		clearTestData,
+		setMetadata,
+		setSegments,
+	).Run(t)
+}
+
+func TestPostgresDatabaseCreatesTables(t *testing.T) {
+	require.NoError(t, withTestDatabase(func(d *Database) error {
+		db, err := d.Get(context.Background())
+		if err != nil {
+			return err
+		}
+		for _, table := range []string{ItemsTable, MetadataTable, BigSegmentsTable} {
+			var exists bool
+			if err := db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+				return err
+			}
+			require.True(t, exists, table)
+		}
+		return nil
+	}))
+}
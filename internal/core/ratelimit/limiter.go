@@ -0,0 +1,257 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+)
+
+// idleBucketCleanupInterval is how often the Limiter discards per-client-IP buckets that have not been
+// used recently. A bucket that has filled up again is indistinguishable from a new one, so discarding it
+// does not change the behavior; this just keeps the number of buckets from growing without limit.
+const idleBucketCleanupInterval = time.Minute
+
+// Limits are the rate limits for one kind of credential. A rate of zero means there is no limit.
+type Limits struct {
+	// PerCredential is the maximum number of requests per second from all clients using a credential.
+	PerCredential float64
+
+	// PerClientIP is the maximum number of requests per second from each client IP address using a
+	// credential.
+	PerClientIP float64
+}
+
+func (l Limits) isEnabled() bool {
+	return l.PerCredential > 0 || l.PerClientIP > 0
+}
+
+// Decision is the result of Limiter.Allow.
+type Decision struct {
+	// Allowed is true if the request can proceed.
+	Allowed bool
+
+	// RetryAfter is how long the client should wait before trying again, if Allowed is false.
+	RetryAfter time.Duration
+
+	// ByClientIP is true if the request was rejected because of the per-client-IP limit, rather than
+	// the per-credential limit.
+	ByClientIP bool
+}
+
+type clientIPKey struct {
+	credential config.SDKCredential
+	clientIP   string
+}
+
+// Limiter enforces the rate limits for one environment. It is safe for concurrent use.
+type Limiter struct {
+	serverSide, mobile, clientSide Limits
+	burst                          int
+	useForwardedFor                bool
+	trustedProxies                 []*net.IPNet
+	credentialBuckets              map[config.SDKCredential]*tokenBucket
+	clientIPBuckets                map[clientIPKey]*tokenBucket
+	lastCleanup                    time.Time
+	now                            func() time.Time
+	lock                           sync.Mutex
+}
+
+// NewLimiter creates a Limiter for an environment, using the global rate limit configuration and any
+// overrides in the environment's configuration. It returns nil if there are no limits for any kind of
+// credential.
+func NewLimiter(rateLimitConfig config.RateLimitConfig, envConfig config.EnvConfig) *Limiter {
+	perClientIP := rateLimitConfig.PerClientIP.GetOrElse(0)
+	makeLimits := func(globalRate, envRate ct.OptFloat64) Limits {
+		return Limits{PerCredential: envRate.GetOrElse(globalRate.GetOrElse(0)), PerClientIP: perClientIP}
+	}
+	serverSide := makeLimits(rateLimitConfig.ServerSide, envConfig.RateLimitServerSide)
+	mobile := makeLimits(rateLimitConfig.Mobile, envConfig.RateLimitMobile)
+	clientSide := makeLimits(rateLimitConfig.ClientSide, envConfig.RateLimitClientSide)
+	if !serverSide.isEnabled() && !mobile.isEnabled() && !clientSide.isEnabled() {
+		return nil
+	}
+	l := newLimiterInternal(serverSide, mobile, clientSide, rateLimitConfig.Burst.GetOrElse(0),
+		rateLimitConfig.UseForwardedFor, time.Now)
+	l.trustedProxies = parseTrustedProxies(rateLimitConfig.TrustedProxies.Values())
+	return l
+}
+
+// parseTrustedProxies converts IP addresses and CIDR ranges to networks. Invalid values, which the
+// configuration validator would already have rejected, are skipped.
+func parseTrustedProxies(values []string) []*net.IPNet {
+	var ret []*net.IPNet
+	for _, value := range values {
+		if _, network, err := net.ParseCIDR(value); err == nil {
+			ret = append(ret, network)
+		} else if ip := net.ParseIP(value); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			ret = append(ret, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+	}
+	return ret
+}
+
+func newLimiterInternal(
+	serverSide, mobile, clientSide Limits,
+	burst int,
+	useForwardedFor bool,
+	now func() time.Time,
+) *Limiter {
+	return &Limiter{
+		serverSide:        serverSide,
+		mobile:            mobile,
+		clientSide:        clientSide,
+		burst:             burst,
+		useForwardedFor:   useForwardedFor,
+		credentialBuckets: make(map[config.SDKCredential]*tokenBucket),
+		clientIPBuckets:   make(map[clientIPKey]*tokenBucket),
+		lastCleanup:       now(),
+		now:               now,
+	}
+}
+
+// AllowRequest decides whether an HTTP request that was authorized with the specified credential is
+// within the rate limits, and if so, counts it toward the limits.
+func (l *Limiter) AllowRequest(credential config.SDKCredential, req *http.Request) Decision {
+	return l.Allow(credential, l.getClientIP(req))
+}
+
+// Allow decides whether a request from the specified client IP address, using the specified credential,
+// is within the rate limits, and if so, counts it toward the limits. A request that is not allowed does
+// not count toward the limits.
+func (l *Limiter) Allow(credential config.SDKCredential, clientIP string) Decision {
+	limits := l.getLimits(credential)
+	if !limits.isEnabled() {
+		return Decision{Allowed: true}
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	l.discardIdleBuckets(now)
+
+	var credentialBucket, clientIPBucket *tokenBucket
+	if limits.PerCredential > 0 {
+		credentialBucket = l.credentialBuckets[credential]
+		if credentialBucket == nil {
+			credentialBucket = newTokenBucket(limits.PerCredential, l.getBurst(limits.PerCredential), now)
+			l.credentialBuckets[credential] = credentialBucket
+		}
+		credentialBucket.refill(now)
+	}
+	if limits.PerClientIP > 0 && clientIP != "" {
+		key := clientIPKey{credential, clientIP}
+		clientIPBucket = l.clientIPBuckets[key]
+		if clientIPBucket == nil {
+			clientIPBucket = newTokenBucket(limits.PerClientIP, l.getBurst(limits.PerClientIP), now)
+			l.clientIPBuckets[key] = clientIPBucket
+		}
+		clientIPBucket.refill(now)
+	}
+
+	// We check both limits before taking a token from either, so that a rejected request does not use
+	// up any of the allowance.
+	if clientIPBucket != nil {
+		if wait := clientIPBucket.waitTime(); wait > 0 {
+			return Decision{RetryAfter: wait, ByClientIP: true}
+		}
+	}
+	if credentialBucket != nil {
+		if wait := credentialBucket.waitTime(); wait > 0 {
+			return Decision{RetryAfter: wait}
+		}
+		credentialBucket.take()
+	}
+	if clientIPBucket != nil {
+		clientIPBucket.take()
+	}
+	return Decision{Allowed: true}
+}
+
+func (l *Limiter) getLimits(credential config.SDKCredential) Limits {
+	switch credential.(type) {
+	case config.SDKKey:
+		return l.serverSide
+	case config.MobileKey:
+		return l.mobile
+	case config.EnvironmentID:
+		return l.clientSide
+	default:
+		return Limits{}
+	}
+}
+
+func (l *Limiter) getBurst(rate float64) int {
+	if l.burst > 0 {
+		return l.burst
+	}
+	return defaultBurst(rate)
+}
+
+func (l *Limiter) discardIdleBuckets(now time.Time) {
+	if now.Sub(l.lastCleanup) < idleBucketCleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+	for key, b := range l.clientIPBuckets {
+		b.refill(now)
+		if b.isFull() {
+			delete(l.clientIPBuckets, key)
+		}
+	}
+}
+
+// getClientIP returns the address that a request came from. X-Forwarded-For is only used if the request
+// was received from one of the trusted proxies, since anyone else could have set it to anything. Each
+// proxy appends the address it received the request from, so we go back from the last address through
+// the ones that belong to trusted proxies; the first one that does not is the client, and anything before
+// that could have been sent by the client.
+func (l *Limiter) getClientIP(req *http.Request) string {
+	remoteIP := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		remoteIP = host
+	}
+	if !l.useForwardedFor || !l.isTrustedProxy(remoteIP) {
+		return remoteIP
+	}
+	// The header can be sent on several lines, which mean the same as one comma-separated list.
+	var addrs []string
+	for _, value := range req.Header.Values("X-Forwarded-For") {
+		addrs = append(addrs, strings.Split(value, ",")...)
+	}
+	clientIP := remoteIP
+	for i := len(addrs) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(addrs[i])
+		if addr == "" {
+			continue
+		}
+		clientIP = addr
+		if !l.isTrustedProxy(addr) {
+			break
+		}
+	}
+	return clientIP
+}
+
+func (l *Limiter) isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range l.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
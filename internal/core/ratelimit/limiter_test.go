@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSDKKey    = config.SDKKey("sdk-key")
+	testMobileKey = config.MobileKey("mob-key")
+	testEnvID     = config.EnvironmentID("env-id")
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) getNow() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestNewLimiterReturnsNilIfThereAreNoLimits(t *testing.T) {
+	assert.Nil(t, NewLimiter(config.RateLimitConfig{}, config.EnvConfig{}))
+}
+
+func TestNewLimiterUsesEnvironmentOverrides(t *testing.T) {
+	rateLimitConfig := config.RateLimitConfig{
+		ServerSide:  ct.NewOptFloat64(10),
+		Mobile:      ct.NewOptFloat64(20),
+		PerClientIP: ct.NewOptFloat64(5),
+	}
+	envConfig := config.EnvConfig{RateLimitMobile: ct.NewOptFloat64(2), RateLimitClientSide: ct.NewOptFloat64(3)}
+	l := NewLimiter(rateLimitConfig, envConfig)
+	require.NotNil(t, l)
+	assert.Equal(t, Limits{PerCredential: 10, PerClientIP: 5}, l.serverSide)
+	assert.Equal(t, Limits{PerCredential: 2, PerClientIP: 5}, l.mobile)
+	assert.Equal(t, Limits{PerCredential: 3, PerClientIP: 5}, l.clientSide)
+}
+
+func TestPerCredentialLimit(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiterInternal(Limits{PerCredential: 2}, Limits{}, Limits{}, 0, false, clock.getNow)
+
+	assert.True(t, l.Allow(testSDKKey, "1.1.1.1").Allowed)
+	assert.True(t, l.Allow(testSDKKey, "2.2.2.2").Allowed)
+	d := l.Allow(testSDKKey, "3.3.3.3")
+	assert.False(t, d.Allowed)
+	assert.False(t, d.ByClientIP)
+	assert.Equal(t, time.Millisecond*500, d.RetryAfter)
+
+	// other kinds of credentials have no limit here
+	assert.True(t, l.Allow(testMobileKey, "1.1.1.1").Allowed)
+	assert.True(t, l.Allow(testEnvID, "1.1.1.1").Allowed)
+
+	clock.advance(time.Millisecond * 500)
+	assert.True(t, l.Allow(testSDKKey, "1.1.1.1").Allowed)
+	assert.False(t, l.Allow(testSDKKey, "1.1.1.1").Allowed)
+}
+
+func TestPerClientIPLimit(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiterInternal(Limits{}, Limits{PerCredential: 10, PerClientIP: 1}, Limits{}, 0, false, clock.getNow)
+
+	assert.True(t, l.Allow(testMobileKey, "1.1.1.1").Allowed)
+	d := l.Allow(testMobileKey, "1.1.1.1")
+	assert.False(t, d.Allowed)
+	assert.True(t, d.ByClientIP)
+	assert.Equal(t, time.Second, d.RetryAfter)
+
+	assert.True(t, l.Allow(testMobileKey, "2.2.2.2").Allowed)
+	assert.True(t, l.Allow(config.MobileKey("other-key"), "1.1.1.1").Allowed)
+}
+
+func TestRejectedRequestDoesNotCountTowardLimits(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiterInternal(Limits{PerCredential: 2, PerClientIP: 1}, Limits{}, Limits{}, 0, false, clock.getNow)
+
+	assert.True(t, l.Allow(testSDKKey, "1.1.1.1").Allowed)
+	for i := 0; i < 5; i++ {
+		assert.False(t, l.Allow(testSDKKey, "1.1.1.1").Allowed)
+	}
+	// the rejections from 1.1.1.1 did not use up the per-credential allowance
+	assert.True(t, l.Allow(testSDKKey, "2.2.2.2").Allowed)
+}
+
+func TestBurst(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiterInternal(Limits{}, Limits{}, Limits{PerCredential: 1}, 3, false, clock.getNow)
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.Allow(testEnvID, "").Allowed)
+	}
+	assert.False(t, l.Allow(testEnvID, "").Allowed)
+
+	clock.advance(time.Second)
+	assert.True(t, l.Allow(testEnvID, "").Allowed)
+	assert.False(t, l.Allow(testEnvID, "").Allowed)
+}
+
+func TestIdleClientIPBucketsAreDiscarded(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiterInternal(Limits{PerClientIP: 1}, Limits{}, Limits{}, 0, false, clock.getNow)
+
+	l.Allow(testSDKKey, "1.1.1.1")
+	l.Allow(testSDKKey, "2.2.2.2")
+	assert.Len(t, l.clientIPBuckets, 2)
+
+	clock.advance(idleBucketCleanupInterval)
+	l.Allow(testSDKKey, "3.3.3.3")
+	assert.Len(t, l.clientIPBuckets, 1)
+}
+
+func TestAllowRequestGetsClientIP(t *testing.T) {
+	for _, p := range []struct {
+		name            string
+		useForwardedFor bool
+		trustedProxies  []string
+		forwardedFor    []string
+		expected        string
+	}{
+		{"remote address", false, nil, nil, "1.1.1.1"},
+		{"X-Forwarded-For ignored by default", false, []string{"1.1.1.1"}, []string{"2.2.2.2"}, "1.1.1.1"},
+		{"X-Forwarded-For ignored if remote address is not a trusted proxy", true, []string{"10.0.0.0/8"},
+			[]string{"2.2.2.2"}, "1.1.1.1"},
+		{"X-Forwarded-For", true, []string{"1.1.1.1"}, []string{"2.2.2.2"}, "2.2.2.2"},
+		{"X-Forwarded-For uses last address", true, []string{"1.1.1.1"}, []string{"2.2.2.2, 3.3.3.3"}, "3.3.3.3"},
+		{"X-Forwarded-For skips trusted proxies", true, []string{"1.1.1.1", "10.0.0.0/8", "4.4.4.4"},
+			[]string{"2.2.2.2, 3.3.3.3, 10.1.2.3, 4.4.4.4"}, "3.3.3.3"},
+		{"X-Forwarded-For all trusted proxies", true, []string{"1.1.1.1", "10.0.0.0/8"},
+			[]string{"10.0.0.1, 10.0.0.2"}, "10.0.0.1"},
+		{"X-Forwarded-For on several lines", true, []string{"1.1.1.1"},
+			[]string{"2.2.2.2, 3.3.3.3", "4.4.4.4"}, "4.4.4.4"},
+		{"X-Forwarded-For on several lines skips trusted proxies", true, []string{"1.1.1.1", "4.4.4.4"},
+			[]string{"2.2.2.2, 3.3.3.3", "4.4.4.4"}, "3.3.3.3"},
+		{"X-Forwarded-For not present", true, []string{"1.1.1.1"}, nil, "1.1.1.1"},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			l := newLimiterInternal(Limits{PerClientIP: 1}, Limits{}, Limits{}, 0, p.useForwardedFor, time.Now)
+			l.trustedProxies = parseTrustedProxies(p.trustedProxies)
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = "1.1.1.1:5000"
+			for _, value := range p.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			assert.True(t, l.AllowRequest(testSDKKey, req).Allowed)
+			assert.Contains(t, l.clientIPBuckets, clientIPKey{testSDKKey, p.expected})
+		})
+	}
+}
@@ -0,0 +1,4 @@
+// Package ratelimit contains the token-bucket rate limiting logic for Relay's SDK endpoints, the limits
+// on concurrent stream connections, and the per-environment resource quotas for auto-configured
+// environments.
+package ratelimit
@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+)
+
+// Quota enforces the per-environment resource quotas that can be set for auto-configured environments
+// in AutoConfigConfig, so that one busy environment cannot use up resources that the others need. It
+// limits the number of concurrent streaming connections, and the rate at which analytics events are
+// accepted; the memory budget for queued events is enforced by the event forwarder. It is safe for
+// concurrent use.
+type Quota struct {
+	streams      *StreamLimiter
+	eventBucket  *tokenBucket
+	queue        bool
+	queueTimeout time.Duration
+	now          func() time.Time
+	lock         sync.Mutex
+}
+
+// NewQuota creates a Quota for an auto-configured environment. It returns nil if auto-configuration is
+// not enabled, or if neither a stream connection limit nor an event throughput limit is set.
+func NewQuota(c config.AutoConfigConfig) *Quota {
+	if c.Key == "" || (!c.EnvMaxStreamConnections.IsDefined() && !c.EnvMaxEventsPerSecond.IsDefined()) {
+		return nil
+	}
+	return newQuotaInternal(
+		c.EnvMaxStreamConnections.GetOrElse(0),
+		c.EnvMaxEventsPerSecond.GetOrElse(0),
+		c.EnvQuotaOverflow == config.AutoConfigQuotaOverflowQueue,
+		c.EnvQuotaQueueTimeout.GetOrElse(config.DefaultAutoConfigQuotaQueueTimeout),
+		time.Now,
+	)
+}
+
+func newQuotaInternal(
+	maxStreams int,
+	maxEventsPerSecond float64,
+	queue bool,
+	queueTimeout time.Duration,
+	now func() time.Time,
+) *Quota {
+	q := &Quota{queue: queue, queueTimeout: queueTimeout, now: now}
+	q.streams = NewStreamLimiter(maxStreams, queue, queueTimeout)
+	if maxEventsPerSecond > 0 {
+		q.eventBucket = newTokenBucket(maxEventsPerSecond, defaultBurst(maxEventsPerSecond), now())
+	}
+	return q
+}
+
+// AcquireStream reserves one of the environment's streaming connection slots. If it returns true, the
+// caller must call ReleaseStream when the connection ends. If every slot is in use, it returns false
+// immediately; or, if the overflow behavior is "queue", it first waits for a slot to become free, until
+// the queue timeout elapses or the context is cancelled.
+func (q *Quota) AcquireStream(ctx context.Context) bool {
+	if q == nil {
+		return true
+	}
+	allowed, _ := q.streams.Acquire(ctx)
+	return allowed
+}
+
+// ReleaseStream frees a slot that was reserved by AcquireStream.
+func (q *Quota) ReleaseStream() {
+	if q == nil {
+		return
+	}
+	q.streams.Release()
+}
+
+// AllowEvents decides whether a payload of the specified number of analytics events is within the
+// environment's event throughput quota, and if so, counts it toward the quota. A payload is allowed
+// whenever there is any allowance left, even if it contains more events than that; the events after it
+// then have to wait correspondingly longer. If the overflow behavior is "queue", a payload that is not
+// within the quota waits until it is, unless that would take longer than the queue timeout or the
+// context is cancelled.
+func (q *Quota) AllowEvents(ctx context.Context, count int) Decision {
+	if q == nil || q.eventBucket == nil || count <= 0 {
+		return Decision{Allowed: true}
+	}
+	deadline := q.now().Add(q.queueTimeout)
+	for {
+		wait := q.tryTakeEvents(count)
+		if wait == 0 {
+			return Decision{Allowed: true}
+		}
+		if !q.queue || q.now().Add(wait).After(deadline) {
+			return Decision{RetryAfter: wait}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return Decision{RetryAfter: wait}
+		}
+	}
+}
+
+func (q *Quota) tryTakeEvents(count int) time.Duration {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.eventBucket.refill(q.now())
+	if wait := q.eventBucket.waitTime(); wait > 0 {
+		return wait
+	}
+	q.eventBucket.takeN(count)
+	return 0
+}
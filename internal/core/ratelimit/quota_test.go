@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewQuotaReturnsNilIfThereAreNoLimits(t *testing.T) {
+	assert.Nil(t, NewQuota(config.AutoConfigConfig{Key: "autokey"}))
+	assert.Nil(t, NewQuota(config.AutoConfigConfig{EnvMaxEventsPerSecond: ct.NewOptFloat64(10)}))
+
+	q := NewQuota(config.AutoConfigConfig{Key: "autokey", EnvMaxEventsPerSecond: ct.NewOptFloat64(10)})
+	require.NotNil(t, q)
+	assert.Nil(t, q.streams)
+	assert.False(t, q.queue)
+	assert.Equal(t, config.DefaultAutoConfigQuotaQueueTimeout, q.queueTimeout)
+}
+
+func TestNilQuotaAllowsEverything(t *testing.T) {
+	var q *Quota
+	assert.True(t, q.AcquireStream(context.Background()))
+	q.ReleaseStream()
+	assert.True(t, q.AllowEvents(context.Background(), 1000).Allowed)
+}
+
+func TestStreamQuotaWithRejectBehavior(t *testing.T) {
+	q := newQuotaInternal(2, 0, false, time.Second, time.Now)
+
+	assert.True(t, q.AcquireStream(context.Background()))
+	assert.True(t, q.AcquireStream(context.Background()))
+	assert.False(t, q.AcquireStream(context.Background()))
+
+	q.ReleaseStream()
+	assert.True(t, q.AcquireStream(context.Background()))
+}
+
+func TestStreamQuotaWithQueueBehavior(t *testing.T) {
+	t.Run("waits for a slot", func(t *testing.T) {
+		q := newQuotaInternal(1, 0, true, time.Second, time.Now)
+		require.True(t, q.AcquireStream(context.Background()))
+
+		go func() {
+			time.Sleep(time.Millisecond * 20)
+			q.ReleaseStream()
+		}()
+		assert.True(t, q.AcquireStream(context.Background()))
+	})
+
+	t.Run("gives up after timeout", func(t *testing.T) {
+		q := newQuotaInternal(1, 0, true, time.Millisecond*20, time.Now)
+		require.True(t, q.AcquireStream(context.Background()))
+		assert.False(t, q.AcquireStream(context.Background()))
+	})
+
+	t.Run("gives up if context is cancelled", func(t *testing.T) {
+		q := newQuotaInternal(1, 0, true, time.Hour, time.Now)
+		require.True(t, q.AcquireStream(context.Background()))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		defer cancel()
+		assert.False(t, q.AcquireStream(ctx))
+	})
+}
+
+func TestEventQuotaWithRejectBehavior(t *testing.T) {
+	clock := newFakeClock()
+	q := newQuotaInternal(0, 10, false, time.Second, clock.getNow)
+
+	assert.True(t, q.AllowEvents(context.Background(), 4).Allowed)
+	assert.True(t, q.AllowEvents(context.Background(), 6).Allowed)
+
+	decision := q.AllowEvents(context.Background(), 1)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, time.Millisecond*100, decision.RetryAfter)
+
+	clock.advance(time.Millisecond * 100)
+	assert.True(t, q.AllowEvents(context.Background(), 1).Allowed)
+}
+
+func TestEventQuotaAllowsPayloadLargerThanBurst(t *testing.T) {
+	clock := newFakeClock()
+	q := newQuotaInternal(0, 10, false, time.Second, clock.getNow)
+
+	assert.True(t, q.AllowEvents(context.Background(), 30).Allowed)
+
+	// that used two seconds' worth of allowance beyond the burst, so the next payload has to wait for it
+	decision := q.AllowEvents(context.Background(), 1)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, time.Millisecond*2100, decision.RetryAfter)
+}
+
+func TestEventQuotaWithQueueBehavior(t *testing.T) {
+	t.Run("waits for allowance", func(t *testing.T) {
+		q := newQuotaInternal(0, 50, true, time.Second, time.Now)
+		require.True(t, q.AllowEvents(context.Background(), 50).Allowed)
+
+		start := time.Now()
+		assert.True(t, q.AllowEvents(context.Background(), 1).Allowed)
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(time.Millisecond*10))
+	})
+
+	t.Run("rejects if wait would exceed timeout", func(t *testing.T) {
+		q := newQuotaInternal(0, 1, true, time.Millisecond*100, time.Now)
+		require.True(t, q.AllowEvents(context.Background(), 1).Allowed)
+
+		start := time.Now()
+		decision := q.AllowEvents(context.Background(), 1)
+		assert.False(t, decision.Allowed)
+		assert.Greater(t, int64(decision.RetryAfter), int64(time.Millisecond*100))
+		assert.Less(t, int64(time.Since(start)), int64(time.Millisecond*100))
+	})
+}
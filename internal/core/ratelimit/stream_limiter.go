@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+)
+
+// StreamLimiter limits the number of concurrent stream connections. It is safe for concurrent use, and
+// a nil StreamLimiter allows any number of connections.
+type StreamLimiter struct {
+	slots        chan struct{}
+	queue        bool
+	queueTimeout time.Duration
+}
+
+// NewStreamLimiter creates a StreamLimiter that allows up to maxStreams connections at a time. If queue
+// is true, a connection that is over the limit waits for up to queueTimeout for another one to end. It
+// returns nil if maxStreams is not greater than zero.
+func NewStreamLimiter(maxStreams int, queue bool, queueTimeout time.Duration) *StreamLimiter {
+	if maxStreams <= 0 {
+		return nil
+	}
+	return &StreamLimiter{slots: make(chan struct{}, maxStreams), queue: queue, queueTimeout: queueTimeout}
+}
+
+// NewGlobalStreamLimiter creates the StreamLimiter for StreamLimitsConfig.MaxConnections, which applies
+// to all environments together. It returns nil if there is no such limit.
+func NewGlobalStreamLimiter(c config.StreamLimitsConfig) *StreamLimiter {
+	return newStreamLimiterFromConfig(c, c.MaxConnections.GetOrElse(0))
+}
+
+// NewEnvStreamLimiter creates the StreamLimiter for one environment, using EnvConfig.MaxStreamConnections
+// or else StreamLimitsConfig.MaxConnectionsPerEnv. It returns nil if there is no such limit.
+func NewEnvStreamLimiter(c config.StreamLimitsConfig, envConfig config.EnvConfig) *StreamLimiter {
+	maxStreams := envConfig.MaxStreamConnections.GetOrElse(c.MaxConnectionsPerEnv.GetOrElse(0))
+	return newStreamLimiterFromConfig(c, maxStreams)
+}
+
+func newStreamLimiterFromConfig(c config.StreamLimitsConfig, maxStreams int) *StreamLimiter {
+	return NewStreamLimiter(maxStreams, c.Overflow == config.StreamLimitOverflowQueue,
+		c.QueueTimeout.GetOrElse(config.DefaultStreamLimitQueueTimeout))
+}
+
+// Acquire reserves one of the connection slots. If allowed is true, the caller must call Release when
+// the connection ends; queued is true if it had to wait for a slot. If every slot is in use, it returns
+// false immediately; or, if queueing is enabled, it first waits for a slot to become free, until the
+// queue timeout elapses or the context is cancelled.
+func (s *StreamLimiter) Acquire(ctx context.Context) (allowed, queued bool) {
+	if s == nil {
+		return true, false
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return true, false
+	default:
+	}
+	if !s.queue || s.queueTimeout <= 0 {
+		return false, false
+	}
+	timer := time.NewTimer(s.queueTimeout)
+	defer timer.Stop()
+	select {
+	case s.slots <- struct{}{}:
+		return true, true
+	case <-timer.C:
+		return false, true
+	case <-ctx.Done():
+		return false, true
+	}
+}
+
+// Release frees a slot that was reserved by Acquire.
+func (s *StreamLimiter) Release() {
+	if s == nil {
+		return
+	}
+	<-s.slots
+}
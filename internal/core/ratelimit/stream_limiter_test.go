@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStreamLimiterFromConfig(t *testing.T) {
+	assert.Nil(t, NewGlobalStreamLimiter(config.StreamLimitsConfig{}))
+	assert.Nil(t, NewEnvStreamLimiter(config.StreamLimitsConfig{}, config.EnvConfig{}))
+
+	c := config.StreamLimitsConfig{Overflow: config.StreamLimitOverflowQueue}
+	c.MaxConnections, _ = ct.NewOptIntGreaterThanZero(100)
+	c.MaxConnectionsPerEnv, _ = ct.NewOptIntGreaterThanZero(10)
+	global := NewGlobalStreamLimiter(c)
+	require.NotNil(t, global)
+	assert.Equal(t, 100, cap(global.slots))
+	assert.True(t, global.queue)
+	assert.Equal(t, config.DefaultStreamLimitQueueTimeout, global.queueTimeout)
+
+	env := NewEnvStreamLimiter(c, config.EnvConfig{})
+	require.NotNil(t, env)
+	assert.Equal(t, 10, cap(env.slots))
+
+	var envConfig config.EnvConfig
+	envConfig.MaxStreamConnections, _ = ct.NewOptIntGreaterThanZero(5)
+	env = NewEnvStreamLimiter(c, envConfig)
+	require.NotNil(t, env)
+	assert.Equal(t, 5, cap(env.slots))
+}
+
+func TestNilStreamLimiterAllowsEverything(t *testing.T) {
+	var s *StreamLimiter
+	allowed, queued := s.Acquire(context.Background())
+	assert.True(t, allowed)
+	assert.False(t, queued)
+	s.Release()
+}
+
+func TestStreamLimiterWithRejectBehavior(t *testing.T) {
+	s := NewStreamLimiter(1, false, time.Second)
+
+	allowed, _ := s.Acquire(context.Background())
+	assert.True(t, allowed)
+	allowed, queued := s.Acquire(context.Background())
+	assert.False(t, allowed)
+	assert.False(t, queued)
+
+	s.Release()
+	allowed, _ = s.Acquire(context.Background())
+	assert.True(t, allowed)
+}
+
+func TestStreamLimiterWithQueueBehavior(t *testing.T) {
+	t.Run("waits for a slot", func(t *testing.T) {
+		s := NewStreamLimiter(1, true, time.Second)
+		allowed, _ := s.Acquire(context.Background())
+		require.True(t, allowed)
+
+		go func() {
+			time.Sleep(time.Millisecond * 20)
+			s.Release()
+		}()
+		allowed, queued := s.Acquire(context.Background())
+		assert.True(t, allowed)
+		assert.True(t, queued)
+	})
+
+	t.Run("gives up after timeout", func(t *testing.T) {
+		s := NewStreamLimiter(1, true, time.Millisecond*20)
+		allowed, _ := s.Acquire(context.Background())
+		require.True(t, allowed)
+		allowed, queued := s.Acquire(context.Background())
+		assert.False(t, allowed)
+		assert.True(t, queued)
+	})
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// tokenBucket is a basic token bucket: it holds up to burst tokens, which are replenished at rate
+// tokens per second, and each request uses one token (or, for a Quota, one token per event). It is not
+// safe for concurrent use; Limiter and Quota provide the locking.
+type tokenBucket struct {
+	rate       float64
+	burst      float64
+	tokens     float64
+	lastUpdate time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), lastUpdate: now}
+}
+
+// defaultBurst is the burst size if none was specified: enough for one second's worth of requests.
+func defaultBurst(rate float64) int {
+	return int(math.Max(1, math.Ceil(rate)))
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if now.After(b.lastUpdate) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.lastUpdate).Seconds()*b.rate)
+		b.lastUpdate = now
+	}
+}
+
+// waitTime returns zero if a token is available, or otherwise how long it will be until one is.
+// The bucket must have been refilled first.
+func (b *tokenBucket) waitTime() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take() {
+	b.tokens--
+}
+
+// takeN uses n tokens at once. This can leave the bucket with a negative number of tokens, so that a
+// request for more tokens than the burst size can still proceed once the bucket is full, and the
+// requests after it have to wait correspondingly longer.
+func (b *tokenBucket) takeN(n int) {
+	b.tokens -= float64(n)
+}
+
+func (b *tokenBucket) isFull() bool {
+	return b.tokens >= b.burst
+}
@@ -13,9 +13,20 @@ import (
 
 	"github.com/launchdarkly/ld-relay/v6/config"
 	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/accesslog"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/browser"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/events"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/snapshots"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/leader"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/logging"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/memguard"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/ratelimit"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/shadow"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/streams"
 	"github.com/launchdarkly/ld-relay/v6/internal/util"
 
@@ -23,6 +34,7 @@ import (
 	ld "gopkg.in/launchdarkly/go-server-sdk.v5"
 
 	"github.com/gregjones/httpcache"
+	"google.golang.org/grpc"
 )
 
 var (
@@ -35,6 +47,22 @@ func errNewClientContextFailed(envName string, err error) error {
 	return fmt.Errorf(`unable to create client context for "%s": %w`, envName, err)
 }
 
+func errNewAccessLogFailed(err error) error {
+	return fmt.Errorf("unable to open access log: %w", err)
+}
+
+func errNewEventExporterFailed(err error) error {
+	return fmt.Errorf("unable to configure event export: %w", err)
+}
+
+func errNewSnapshotManagerFailed(err error) error {
+	return fmt.Errorf("unable to configure flag data snapshots: %w", err)
+}
+
+func errNewLeaderElectorFailed(err error) error {
+	return fmt.Errorf("unable to start leader election: %w", err)
+}
+
 func errNewMetricsManagerFailed(err error) error {
 	return fmt.Errorf("unable to create metrics manager: %w", err)
 }
@@ -44,19 +72,33 @@ type RelayCore struct {
 	allEnvironments               []relayenv.EnvContext
 	envsByCredential              map[config.SDKCredential]relayenv.EnvContext
 	metricsManager                *metrics.Manager
+	accessLog                     *accesslog.Logger
+	shadower                      *shadow.Shadower
+	eventExporter                 *events.EventExporter
+	snapshotManager               *snapshots.Manager
+	upstreamFailover              *httpconfig.UpstreamFailover
+	streamLimiter                 *ratelimit.StreamLimiter
+	memoryGuard                   *memguard.Guard
+	leaderElector                 *leader.Elector
 	clientFactory                 sdks.ClientFactoryFunc
 	serverSideStreamProvider      streams.StreamProvider
 	serverSideFlagsStreamProvider streams.StreamProvider
 	mobileStreamProvider          streams.StreamProvider
 	jsClientStreamProvider        streams.StreamProvider
+	grpcStreamProvider            *streams.GRPCStreamProvider
 	clientInitCh                  chan relayenv.EnvContext
 	fullyConfigured               bool
+	maintenanceMode               bool
 	config                        config.Config
 	clientSideSDKBaseURL          url.URL
 	Version                       string
 	userAgent                     string
 	envLogNameMode                relayenv.LogNameMode
+	tenantsByHost                 map[string]string
 	Loggers                       ldlog.Loggers
+	baseLoggers                   ldlog.Loggers
+	logLevel                      *logging.LevelSwitch
+	httpLoggers                   ldlog.Loggers
 	closed                        bool
 	lock                          sync.RWMutex
 }
@@ -85,13 +127,57 @@ func NewRelayCore(
 	if c.Main.LogLevel.IsDefined() {
 		loggers.SetMinLevel(c.Main.LogLevel.GetOrElse(ldlog.Info))
 	}
-
-	metricsManager, err := metrics.NewManager(c.MetricsConfig, 0, loggers)
+	// Relay's own Loggers, and those of environments that do not have their own level, follow logLevel,
+	// so that SetLogLevel can change it without recreating anything. Environments are given baseLoggers,
+	// since they may have a lower level than this.
+	logLevel := logging.NewLevelSwitch(loggers.GetMinLevel())
+	baseLoggers := loggers
+	loggers = logLevel.Apply(baseLoggers)
+
+	metricsManager, err := metrics.NewManager(c.MetricsConfig, c.InternalMetrics, 0, loggers)
 	if err != nil {
 		return nil, errNewMetricsManagerFailed(err)
 	}
 	thingsToCleanUp.AddFunc(metricsManager.Close)
 
+	accessLog, err := accesslog.NewLogger(c.AccessLog)
+	if err != nil {
+		return nil, errNewAccessLogFailed(err)
+	}
+	if accessLog != nil {
+		thingsToCleanUp.AddCloser(accessLog)
+	}
+
+	eventExporter, err := events.NewEventExporter(c, loggers)
+	if err != nil {
+		return nil, errNewEventExporterFailed(err)
+	}
+	if eventExporter != nil {
+		thingsToCleanUp.AddCloser(eventExporter)
+	}
+
+	var upstreamFailover *httpconfig.UpstreamFailover
+	if len(c.Main.StreamURIFallback.Values())+len(c.Main.BaseURIFallback.Values())+len(c.Events.EventsURIFallback.Values()) != 0 {
+		httpConfig, err := httpconfig.NewHTTPConfig(c.Proxy, nil, userAgent, loggers)
+		if err != nil {
+			return nil, err
+		}
+		if upstreamFailover = httpconfig.NewUpstreamFailover(c, httpConfig.Client(), loggers); upstreamFailover != nil {
+			thingsToCleanUp.AddFunc(upstreamFailover.Close)
+		}
+	}
+
+	// The first leader election is done before the environments are created, so that their data stores
+	// know right away whether to write to the persistent store.
+	leaderElector, err := leader.NewElector(c.LeaderElection, c.Redis, loggers)
+	if err != nil {
+		return nil, errNewLeaderElectorFailed(err)
+	}
+	leaderElector.Start()
+	if leaderElector != nil {
+		thingsToCleanUp.AddFunc(leaderElector.Close)
+	}
+
 	clientInitCh := make(chan relayenv.EnvContext, len(c.Environment))
 
 	maxConnTime := c.Main.MaxClientConnectionTime.GetOrElse(0)
@@ -103,6 +189,12 @@ func NewRelayCore(
 		mobileStreamProvider:          streams.NewStreamProvider(basictypes.MobilePingStream, maxConnTime),
 		jsClientStreamProvider:        streams.NewStreamProvider(basictypes.JSClientPingStream, maxConnTime),
 		metricsManager:                metricsManager,
+		accessLog:                     accessLog,
+		shadower:                      shadow.NewShadower(c.Shadow, loggers),
+		eventExporter:                 eventExporter,
+		upstreamFailover:              upstreamFailover,
+		leaderElector:                 leaderElector,
+		streamLimiter:                 ratelimit.NewGlobalStreamLimiter(c.StreamLimits),
 		clientFactory:                 clientFactory,
 		clientInitCh:                  clientInitCh,
 		config:                        c,
@@ -110,10 +202,26 @@ func NewRelayCore(
 		userAgent:                     userAgent,
 		envLogNameMode:                envLogNameMode,
 		Loggers:                       loggers,
+		baseLoggers:                   baseLoggers,
+		logLevel:                      logLevel,
+		httpLoggers:                   makeHTTPLoggers(c.Main, baseLoggers),
+	}
+
+	for name, tc := range c.Tenant {
+		for _, host := range tc.Host.Values() {
+			if r.tenantsByHost == nil {
+				r.tenantsByHost = make(map[string]string)
+			}
+			r.tenantsByHost[strings.ToLower(host)] = name
+		}
 	}
 
 	r.clientSideSDKBaseURL = *c.Main.ClientSideBaseURI.Get() // config.ValidateConfig has ensured that this has a value
 
+	if c.Main.GRPCPort.IsDefined() {
+		r.grpcStreamProvider = streams.NewGRPCStreamProvider(maxConnTime, r.admitGRPCStream)
+	}
+
 	for envName, envConfig := range c.Environment {
 		env, resultCh, err := r.AddEnvironment(relayenv.EnvIdentifiers{ConfiguredName: envName}, *envConfig, nil)
 		if err != nil {
@@ -126,20 +234,55 @@ func NewRelayCore(
 		}()
 	}
 
-	if len(c.Environment) > 0 || c.OfflineMode.FileDataSource != "" {
+	if c.Snapshots.Type != "" {
+		httpConfig, err := httpconfig.NewHTTPConfig(c.Proxy, nil, userAgent, loggers)
+		if err != nil {
+			return nil, errNewSnapshotManagerFailed(err)
+		}
+		r.snapshotManager, err = snapshots.NewManager(c.Snapshots, httpConfig.Client(), r.GetAllEnvironments, loggers)
+		if err != nil {
+			return nil, errNewSnapshotManagerFailed(err)
+		}
+		thingsToCleanUp.AddCloser(r.snapshotManager)
+	}
+
+	if len(c.Environment) > 0 || c.OfflineMode.FileDataSource != "" || c.Admin.Port.IsDefined() {
 		r.fullyConfigured = true // it's only in auto-config mode that we have any interval of not knowing what the environments are
 	}
 
+	r.memoryGuard = memguard.NewGuard(c.MemoryLimits, r.discardCaches, metricsManager.GetOpenCensusContext(), loggers)
+	r.memoryGuard.Start()
+
 	thingsToCleanUp.Clear() // we've succeeded so we do not want to throw away these things
 
 	return &r, nil
 }
 
+// UpstreamFailover returns the UpstreamFailover that Relay uses for connections to LaunchDarkly, or nil
+// if no fallback URIs are configured.
+func (r *RelayCore) UpstreamFailover() *httpconfig.UpstreamFailover {
+	return r.upstreamFailover
+}
+
 // GetEnvironment returns the environment object corresponding to the given credential, or nil
 // if not found. The credential can be an SDK key, a mobile key, or an environment ID. The second
 // return value is normally true, but is false if Relay does not yet have a valid configuration
 // (which affects our error handling).
+//
+// If MainConfig.LazyEnvironmentInit is enabled, this initializes the environment if it was not already
+// initialized. Code that is only looking up an environment in order to reconfigure it should use
+// FindEnvironment instead.
 func (r *RelayCore) GetEnvironment(credential config.SDKCredential) (relayenv.EnvContext, bool) {
+	env, fullyConfigured := r.FindEnvironment(credential)
+	if lazyEnv, ok := env.(*lazyEnvContext); ok {
+		lazyEnv.activate()
+	}
+	return env, fullyConfigured
+}
+
+// FindEnvironment is the same as GetEnvironment, except that it does not initialize an environment
+// that is waiting for its first request when MainConfig.LazyEnvironmentInit is enabled.
+func (r *RelayCore) FindEnvironment(credential config.SDKCredential) (relayenv.EnvContext, bool) {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
 
@@ -149,6 +292,39 @@ func (r *RelayCore) GetEnvironment(credential config.SDKCredential) (relayenv.En
 	return nil, false
 }
 
+// getEnvironmentsForRequest returns all currently configured environments if the request is not for a
+// tenant, or only the tenant's environments if it is. This is used by the status endpoints, so that a
+// tenant sees only its own environments.
+func (r *RelayCore) getEnvironmentsForRequest(req *http.Request) []relayenv.EnvContext {
+	envs := r.GetAllEnvironments()
+	tenant := middleware.GetTenant(req.Context())
+	if tenant == "" {
+		return envs
+	}
+	ret := make([]relayenv.EnvContext, 0, len(envs))
+	for _, env := range envs {
+		if env.GetTenant() == tenant {
+			ret = append(ret, env)
+		}
+	}
+	return ret
+}
+
+// TenantHandler returns a handler that serves the specified tenant's environments (see
+// config.TenantConfig) by passing requests to the handler from MakeRouter. The caller is responsible
+// for serving it on the tenant's port. Streams on that port use the tenant's stream tuning options, if
+// any, instead of the ones in MainConfig.
+func (r *RelayCore) TenantHandler(tenant string, handler http.Handler) http.Handler {
+	handler = middleware.ForTenant(tenant, handler)
+	if tc := r.config.Tenant[tenant]; tc != nil && (tc.HeartbeatInterval.IsDefined() || tc.SSERetryDelay.IsDefined()) {
+		handler = middleware.StreamTuning(
+			tc.HeartbeatInterval.GetOrElse(0),
+			tc.SSERetryDelay.GetOrElse(r.config.Main.SSERetryDelay.GetOrElse(0)),
+		)(handler)
+	}
+	return handler
+}
+
 // GetAllEnvironments returns all currently configured environments.
 func (r *RelayCore) GetAllEnvironments() []relayenv.EnvContext {
 	r.lock.RLock()
@@ -173,7 +349,14 @@ func (r *RelayCore) AddEnvironment(
 		return nil, nil, errAlreadyClosed
 	}
 
-	dataStoreFactory, dataStoreInfo, err := sdks.ConfigureDataStore(r.config, envConfig, r.Loggers)
+	// If the environment selects a named database, this copy of the configuration has that database in
+	// place of the global one; everything else that the environment uses a database for gets it from here.
+	envAllConfig, err := config.EnvironmentDatabaseConfig(r.config, envConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataStoreFactory, dataStoreInfo, err := sdks.ConfigureDataStore(envAllConfig, envConfig, r.Loggers)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -184,7 +367,12 @@ func (r *RelayCore) AddEnvironment(
 
 	if envConfig.EnvID != "" {
 		jsClientContext.Origins = envConfig.AllowedOrigin.Values()
+		jsClientContext.OriginMatcher, err = browser.NewOriginMatcher(jsClientContext.Origins)
+		if err != nil {
+			return nil, nil, err
+		}
 		jsClientContext.Headers = envConfig.AllowedHeader.Values()
+		jsClientContext.CORSMaxAge = envConfig.CORSMaxAge.GetOrElse(0)
 
 		cachingTransport := httpcache.NewMemoryCacheTransport()
 		jsClientContext.Proxy = &httputil.ReverseProxy{
@@ -214,22 +402,47 @@ func (r *RelayCore) AddEnvironment(
 		return r.clientFactory(sdkKey, config, timeout)
 	}
 
-	clientContext, err := relayenv.NewEnvContext(relayenv.EnvContextImplParams{
-		Identifiers:      identifiers,
-		EnvConfig:        envConfig,
-		AllConfig:        r.config,
-		ClientFactory:    wrappedClientFactory,
-		DataStoreFactory: dataStoreFactory,
-		DataStoreInfo:    dataStoreInfo,
-		StreamProviders:  r.allStreamProviders(),
-		JSClientContext:  jsClientContext,
-		MetricsManager:   r.metricsManager,
-		UserAgent:        r.userAgent,
-		LogNameMode:      r.envLogNameMode,
-		Loggers:          r.Loggers,
-	}, resultCh)
-	if err != nil {
-		return nil, nil, errNewClientContextFailed(identifiers.GetDisplayName(), err)
+	makeEnv := func(
+		identifiers relayenv.EnvIdentifiers,
+		envConfig config.EnvConfig,
+		readyCh chan<- relayenv.EnvContext,
+	) (relayenv.EnvContext, error) {
+		env, err := relayenv.NewEnvContext(relayenv.EnvContextImplParams{
+			Identifiers:      identifiers,
+			EnvConfig:        envConfig,
+			AllConfig:        envAllConfig,
+			ClientFactory:    wrappedClientFactory,
+			DataStoreFactory: dataStoreFactory,
+			DataStoreInfo:    dataStoreInfo,
+			StreamProviders:  r.allStreamProviders(),
+			JSClientContext:  jsClientContext,
+			MetricsManager:   r.metricsManager,
+			EventExporter:    r.eventExporter,
+			UpstreamFailover: r.upstreamFailover,
+			LeaderElector:    r.leaderElector,
+			UserAgent:        r.userAgent,
+			LogNameMode:      r.envLogNameMode,
+			Loggers:          r.baseLoggers,
+			GlobalLogLevel:   r.logLevel,
+		}, readyCh)
+		if err != nil {
+			return nil, errNewClientContextFailed(identifiers.GetDisplayName(), err)
+		}
+		return env, nil
+	}
+
+	var clientContext relayenv.EnvContext
+	if r.config.Main.LazyEnvironmentInit {
+		// Nothing is started until the first request for this environment, so as far as anyone waiting
+		// for the result is concerned, it is done.
+		clientContext = newLazyEnvContext(identifiers, envConfig, envAllConfig, jsClientContext, dataStoreInfo,
+			r.Loggers, makeEnv)
+		resultCh <- clientContext
+	} else {
+		clientContext, err = makeEnv(identifiers, envConfig, resultCh)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	r.allEnvironments = append(r.allEnvironments, clientContext)
@@ -310,6 +523,63 @@ func (r *RelayCore) SetFullyConfigured(fullyConfigured bool) {
 	r.lock.Unlock()
 }
 
+// SetMaintenanceMode turns maintenance mode on or off, and returns false if it was already in that
+// state. While it is on, new requests other than the status and health endpoints are rejected as
+// described for config.MaintenanceConfig, and Relay reports that it is not ready, so that load
+// balancers stop sending traffic to it. Existing stream connections are not affected.
+func (r *RelayCore) SetMaintenanceMode(on bool) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	changed := r.maintenanceMode != on
+	r.maintenanceMode = on
+	return changed
+}
+
+// IsInMaintenanceMode returns true if maintenance mode is on; see SetMaintenanceMode.
+func (r *RelayCore) IsInMaintenanceMode() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.maintenanceMode
+}
+
+// IsReady returns true if every environment is ready to serve flag data, by the same standard as the
+// readiness endpoint. It is always false in maintenance mode.
+func (r *RelayCore) IsReady() bool {
+	r.lock.Lock()
+	fullyConfigured := r.fullyConfigured && !r.maintenanceMode
+	r.lock.Unlock()
+	if !fullyConfigured {
+		return false
+	}
+	for _, clientCtx := range r.GetAllEnvironments() {
+		if !clientCtx.IsReady() && !isEnvDormant(clientCtx) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSheddingLoad returns true if Relay is rejecting new stream connections because its memory use is
+// above the high-water mark in config.MemoryLimitsConfig.
+func (r *RelayCore) IsSheddingLoad() bool {
+	return r.memoryGuard.IsShedding()
+}
+
+// IsLeader returns true if this instance is the leader, or if leader election is not enabled; see
+// config.LeaderElectionConfig. Only the leader forwards events.
+func (r *RelayCore) IsLeader() bool {
+	return r.leaderElector.IsLeader()
+}
+
+// discardCaches empties every environment's response caches, to free memory when memory use is too
+// high. They are filled again by later requests.
+func (r *RelayCore) discardCaches() {
+	for _, env := range r.GetAllEnvironments() {
+		env.GetEvalCache().Clear()
+		env.GetPollingCache().Clear()
+	}
+}
+
 // WaitForAllClients blocks until all environments that were in the initial configuration have
 // reported back as either successfully connected or failed, or until the specified timeout (if the
 // timeout is non-zero).
@@ -370,12 +640,39 @@ func (r *RelayCore) Close() {
 
 	r.lock.Unlock()
 
+	// The snapshot manager is closed before the environments, so that it is not in the middle of reading
+	// their data when they are closed.
+	if r.snapshotManager != nil {
+		_ = r.snapshotManager.Close()
+	}
+	r.memoryGuard.Close()
 	r.metricsManager.Close()
+	if r.upstreamFailover != nil {
+		r.upstreamFailover.Close()
+	}
+	if r.accessLog != nil {
+		if err := r.accessLog.Close(); err != nil {
+			r.Loggers.Warnf("unexpected error when closing access log: %s", err)
+		}
+	}
+	if r.shadower != nil {
+		r.shadower.Close()
+	}
 	for _, env := range envs {
 		if err := env.Close(); err != nil {
 			r.Loggers.Warnf("unexpected error when closing environment: %s", err)
 		}
 	}
+	// The leader lock is released after the environments are closed, so that no other instance becomes
+	// the leader while they might still be writing to the persistent store.
+	r.leaderElector.Close()
+	// The event exporter is closed after the environments, so that it can write the events that they
+	// flushed when they were closed.
+	if r.eventExporter != nil {
+		if err := r.eventExporter.Close(); err != nil {
+			r.Loggers.Warnf("unexpected error when closing event exporter: %s", err)
+		}
+	}
 
 	for _, sp := range r.allStreamProviders() {
 		sp.Close()
@@ -383,10 +680,46 @@ func (r *RelayCore) Close() {
 }
 
 func (r *RelayCore) allStreamProviders() []streams.StreamProvider {
-	return []streams.StreamProvider{
+	ret := []streams.StreamProvider{
 		r.serverSideStreamProvider,
 		r.serverSideFlagsStreamProvider,
 		r.mobileStreamProvider,
 		r.jsClientStreamProvider,
 	}
+	if r.grpcStreamProvider != nil {
+		ret = append(ret, r.grpcStreamProvider)
+	}
+	return ret
+}
+
+// InternalMetricsHandler returns the HTTP handler for the internal metrics endpoint, or nil if
+// config.InternalMetricsConfig.Port is not set.
+func (r *RelayCore) InternalMetricsHandler() http.Handler {
+	return r.metricsManager.InternalMetricsHandler()
+}
+
+// SetLogLevel changes Relay's global log level, which is also the level of every environment that does
+// not have its own LogLevel. If the level is undefined, Info is used. The levels of HTTP request logging
+// and of the other subsystems that have their own level settings are not changed.
+func (r *RelayCore) SetLogLevel(level config.OptLogLevel) {
+	r.logLevel.SetLevel(level.GetOrElse(ldlog.Info))
+}
+
+// RegisterGRPCServices adds Relay's gRPC services to a gRPC server. This does nothing unless
+// config.MainConfig.GRPCPort is set.
+func (r *RelayCore) RegisterGRPCServices(registrar grpc.ServiceRegistrar) {
+	if r.grpcStreamProvider != nil {
+		r.grpcStreamProvider.RegisterService(registrar)
+	}
+}
+
+// makeHTTPLoggers returns the Loggers for logging requests to Relay's endpoints, which have their own
+// level if config.MainConfig.LogLevelHTTP is set.
+func makeHTTPLoggers(c config.MainConfig, loggers ldlog.Loggers) ldlog.Loggers {
+	httpLoggers := loggers
+	if c.LogFormat == config.LogFormatJSON {
+		httpLoggers = logging.MakeJSONLoggers(logging.Fields{Component: logging.ComponentHTTP})
+	}
+	httpLoggers.SetMinLevel(c.LogLevelHTTP.GetOrElse(loggers.GetMinLevel()))
+	return httpLoggers
 }
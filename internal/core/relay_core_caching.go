@@ -0,0 +1,111 @@
+package core
+
+import (
+	"crypto/sha1" //nolint:gosec // we're not using SHA1 for encryption, just for generating an insecure hash
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/pollcache"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	"github.com/launchdarkly/ld-relay/v6/internal/surrogatekeys"
+)
+
+const (
+	// etagPrefix makes it extra clear that these are Relay-specific ETags.
+	etagPrefix = "relay-"
+
+	// cacheControlRevalidate allows shared caches, such as a CDN, to store a response even though the
+	// request was authorized, but requires them to check with Relay, using If-None-Match, before reusing
+	// it. Vary: Authorization keeps responses for different credentials apart.
+	cacheControlRevalidate = "public, no-cache"
+
+	// cacheControlWithTTL allows shared caches to store a response until the time in the Expires header.
+	cacheControlWithTTL = "public"
+)
+
+// writeCacheableJSONResponse writes a response for the PHP SDK polling endpoints. If the endpoint has a
+// TTL, the response can be reused by caches until it expires, and also for the stale-while-revalidate time
+// after that if there is one; otherwise, it must be revalidated.
+func writeCacheableJSONResponse(w http.ResponseWriter, req *http.Request, policy pollcache.Policy,
+	bytes []byte, etagValue string, surrogateKeys ...string) {
+	if policy.TTL > 0 {
+		expiresAt := time.Now().UTC().Add(policy.TTL)
+		w.Header().Set("Expires", expiresAt.Format(http.TimeFormat))
+		// We're setting "Expires:" instead of "Cache-Control:max-age=" so that if someone puts an
+		// HTTP cache in front of ld-relay, multiple clients hitting the cache at different times
+		// will all see the same expiration time.
+		cacheControl := cacheControlWithTTL
+		if policy.StaleWhileRevalidate > 0 {
+			cacheControl += fmt.Sprintf(", stale-while-revalidate=%d", int64(policy.StaleWhileRevalidate/time.Second))
+		}
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	writeETaggedJSONResponse(w, req, bytes, etagValue, surrogateKeys...)
+}
+
+// writeETaggedJSONResponse writes a JSON response with a strong ETag based on etagValue, and the
+// specified surrogate keys, or a 304 response if the request's If-None-Match header matches the ETag.
+// The ETag, Cache-Control, and surrogate keys are included either way, as a cache expects them to be.
+func writeETaggedJSONResponse(w http.ResponseWriter, req *http.Request, bytes []byte, etagValue string,
+	surrogateKeys ...string) {
+	etag := `"` + etagPrefix + etagValue + `"`
+	h := w.Header()
+	h.Set("Etag", etag)
+	if h.Get("Cache-Control") == "" {
+		h.Set("Cache-Control", cacheControlRevalidate)
+	}
+	h.Add("Vary", "Authorization")
+	if len(surrogateKeys) > 0 {
+		h.Set(surrogatekeys.HeaderName, strings.Join(surrogateKeys, " "))
+	}
+	if etagMatches(req.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(bytes)
+}
+
+// etagMatches checks an If-None-Match header value, which can be "*" or a list of ETags, against our
+// ETag. As the HTTP specification requires for If-None-Match, this ignores the weak indicator "W/".
+// Older versions of Relay did not put quotes around their ETags, so an unquoted one also matches.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	unquoted := strings.Trim(etag, `"`)
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag || candidate == unquoted {
+			return true
+		}
+	}
+	return false
+}
+
+// contentETagValue computes an ETag value from the response body itself, for responses that are not
+// simply determined by flag versions.
+func contentETagValue(bytes []byte) string {
+	sum := sha1.Sum(bytes) //nolint:gosec // just used for insecure hashing
+	return hex.EncodeToString(sum[:])[:15]
+}
+
+// surrogateEnvKey returns the part of the surrogate keys that identifies the environment.
+func surrogateEnvKey(env relayenv.EnvContext) string {
+	var envID config.EnvironmentID
+	var sdkKey config.SDKKey
+	for _, c := range env.GetCredentials() {
+		switch c := c.(type) {
+		case config.EnvironmentID:
+			envID = c
+		case config.SDKKey:
+			sdkKey = c
+		}
+	}
+	return surrogatekeys.EnvKey(envID, sdkKey)
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETagMatches(t *testing.T) {
+	etag := `"relay-abc"`
+	for _, header := range []string{`"relay-abc"`, `W/"relay-abc"`, `"relay-x", "relay-abc"`, `*`, `relay-abc`} {
+		assert.True(t, etagMatches(header, etag), header)
+	}
+	for _, header := range []string{``, `"relay-x"`, `"relay-abcd"`} {
+		assert.False(t, etagMatches(header, etag), header)
+	}
+}
+
+func TestWriteETaggedJSONResponse(t *testing.T) {
+	body := []byte(`{"a":1}`)
+
+	t.Run("full response", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		writeETaggedJSONResponse(w, httptest.NewRequest("GET", "/", nil), body, "abc", "ld-env", "ld-env-eval")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, string(body), w.Body.String())
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, `"relay-abc"`, w.Header().Get("Etag"))
+		assert.Equal(t, "public, no-cache", w.Header().Get("Cache-Control"))
+		assert.Equal(t, "Authorization", w.Header().Get("Vary"))
+		assert.Equal(t, "ld-env ld-env-eval", w.Header().Get("Surrogate-Key"))
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-None-Match", `"relay-abc"`)
+		w := httptest.NewRecorder()
+		writeETaggedJSONResponse(w, req, body, "abc", "ld-env")
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Equal(t, "", w.Body.String())
+		assert.Equal(t, `"relay-abc"`, w.Header().Get("Etag"))
+		assert.Equal(t, "ld-env", w.Header().Get("Surrogate-Key"))
+	})
+}
+
+func TestSurrogateEnvKeyUsesEnvironmentID(t *testing.T) {
+	env := makeEnvForEvaluationAPI(t, true)
+	defer env.Close()
+	assert.Equal(t, string(st.EnvClientSide.Config.EnvID), surrogateEnvKey(env))
+}
+
+func TestPollingETagsChangeWhenKillSwitchIsTurnedOnOrOff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kill-switch-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	killSwitchFile := filepath.Join(dir, "kill-switch.yaml")
+	require.NoError(t, ioutil.WriteFile(killSwitchFile, []byte("flag1: 1\n"), 0600))
+
+	envConfig := st.EnvMain.Config
+	envConfig.KillSwitchFile = killSwitchFile
+	core, err := makeBasicCore(c.Config{Environment: map[string]*c.EnvConfig{st.EnvMain.Name: &envConfig}})
+	require.NoError(t, err)
+	defer core.Close()
+	require.NoError(t, core.WaitForAllClients(time.Second))
+	env, _ := core.GetEnvironment(envConfig.SDKKey)
+	require.NotNil(t, env)
+	flag := ldbuilders.NewFlagBuilder("flag1").Version(1).Variations(ldvalue.Bool(false), ldvalue.Bool(true)).
+		OffVariation(0).Build()
+	require.NoError(t, env.GetStore().Init([]ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedItemDescriptor{{Key: flag.Key, Item: st.FlagDesc(flag)}}},
+		{Kind: ldstoreimpl.Segments()},
+	}))
+	router := core.MakeRouter()
+
+	for _, url := range []string{"http://localhost/sdk/flags", "http://localhost/sdk/flags/flag1"} {
+		t.Run(url, func(t *testing.T) {
+			get := func(ifNoneMatch string) (*http.Response, []byte) {
+				req := st.BuildRequestWithAuth("GET", url, envConfig.SDKKey, nil)
+				if ifNoneMatch != "" {
+					req.Header.Set("If-None-Match", ifNoneMatch)
+				}
+				return st.DoRequest(req, router)
+			}
+			resp, originalBody := get("")
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			require.NoError(t, env.SetKillSwitch(true))
+			resp, fallbackBody := get(resp.Header.Get("Etag"))
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.NotEqual(t, string(originalBody), string(fallbackBody))
+
+			require.NoError(t, env.SetKillSwitch(false))
+			resp, body := get(resp.Header.Get("Etag"))
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, string(originalBody), string(body))
+		})
+	}
+}
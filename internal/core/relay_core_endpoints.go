@@ -10,13 +10,18 @@ import (
 	"net/http"
 	"sort"
 	"strconv"
-	"time"
 
 	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/evalcache"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/logging"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/pollcache"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/streams"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/tracing"
+	"github.com/launchdarkly/ld-relay/v6/internal/flagfilter"
+	"github.com/launchdarkly/ld-relay/v6/internal/surrogatekeys"
 	"github.com/launchdarkly/ld-relay/v6/internal/util"
 
 	"gopkg.in/launchdarkly/go-jsonstream.v1/jwriter"
@@ -57,6 +62,16 @@ func getClientSideUserProperties(
 		return user, false
 	}
 
+	if verifier := clientCtx.GetJWTVerifier(); verifier != nil && sdkKind != basictypes.ServerSDK {
+		claims := middleware.GetEnvContextInfo(req.Context()).TokenClaims
+		if err := verifier.CheckUserKey(claims, user.GetKey()); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write(util.ErrorJSONMsg(err.Error()))
+			return user, false
+		}
+	}
+
 	if clientCtx.IsSecureMode() && sdkKind == basictypes.JSClientSDK {
 		hash := req.URL.Query().Get("h")
 		valid := false
@@ -86,13 +101,15 @@ func pingStreamHandler(streamProvider streams.StreamProvider) http.Handler {
 }
 
 // This handler is used for client-side streaming endpoints that require user properties. Currently it is
-// implemented the same as the ping stream once we have validated the user.
+// implemented the same as the ping stream once we have validated the user, except that the stream also
+// receives a ping when that user's big segment memberships change.
 func pingStreamHandlerWithUser(sdkKind basictypes.SDKKind, streamProvider streams.StreamProvider) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		clientCtx := middleware.GetEnvContextInfo(req.Context())
 		clientCtx.Env.GetLoggers().Debug("Application requested client-side ping stream")
 
-		if _, ok := getClientSideUserProperties(clientCtx.Env, sdkKind, req, w); ok {
+		if user, ok := getClientSideUserProperties(clientCtx.Env, sdkKind, req, w); ok {
+			req = req.WithContext(streams.WithUserHashKey(req.Context(), bigsegments.HashForUserKey(user.GetKey())))
 			clientCtx.Env.GetStreamHandler(streamProvider, clientCtx.Credential).ServeHTTP(w, req)
 		}
 	})
@@ -111,21 +128,33 @@ func streamHandler(streamProvider streams.StreamProvider, logMessage string) htt
 // PHP SDK polling endpoint for all flags: app.ld.com/sdk/flags
 func pollAllFlagsHandler(w http.ResponseWriter, req *http.Request) {
 	clientCtx := middleware.GetEnvContextInfo(req.Context())
-	data, err := clientCtx.Env.GetStore().GetAll(ldstoreimpl.Features())
+	cache := clientCtx.Env.GetPollingCache()
+	policy := cache.Policy(pollcache.AllFlags, clientCtx.Env.GetTTL())
+	resp, err := cache.Get(pollcache.AllFlags, "", policy, func() (pollcache.Response, error) {
+		data, err := clientCtx.Env.GetStore().GetAll(ldstoreimpl.Features())
+		if err != nil {
+			return pollcache.Response{}, err
+		}
+		respData := serializeFlagsAsMap(data)
+		// Compute an overall Etag for the data set by hashing flag keys and versions
+		hash := sha1.New()                                                         // nolint:gas // just used for insecure hashing
+		sort.Slice(data, func(i, j int) bool { return data[i].Key < data[j].Key }) // makes the hash deterministic
+		for _, item := range data {
+			_, _ = io.WriteString(hash, fmt.Sprintf("%s:%d", item.Key, item.Item.Version))
+		}
+		if generation := clientCtx.Env.GetKillSwitchGeneration(); generation != 0 {
+			_, _ = io.WriteString(hash, fmt.Sprintf("killswitch:%d", generation))
+		}
+		return pollcache.Response{Data: respData, ETag: hex.EncodeToString(hash.Sum(nil))[:15]}, nil
+	})
 	if err != nil {
 		clientCtx.Env.GetLoggers().Errorf("Error reading feature store: %s", err)
 		w.WriteHeader(500)
 		return
 	}
-	respData := serializeFlagsAsMap(data)
-	// Compute an overall Etag for the data set by hashing flag keys and versions
-	hash := sha1.New()                                                         // nolint:gas // just used for insecure hashing
-	sort.Slice(data, func(i, j int) bool { return data[i].Key < data[j].Key }) // makes the hash deterministic
-	for _, item := range data {
-		_, _ = io.WriteString(hash, fmt.Sprintf("%s:%d", item.Key, item.Item.Version))
-	}
-	etag := hex.EncodeToString(hash.Sum(nil))[:15]
-	writeCacheableJSONResponse(w, req, clientCtx.Env, respData, etag)
+	envKey := surrogateEnvKey(clientCtx.Env)
+	writeCacheableJSONResponse(w, req, policy, resp.Data, resp.ETag,
+		surrogatekeys.Environment(envKey), surrogatekeys.AllFlags(envKey))
 }
 
 // PHP SDK polling endpoint for a flag: app.ld.com/sdk/flags/{key}
@@ -145,9 +174,11 @@ func pollSegmentHandler(w http.ResponseWriter, req *http.Request) {
 // events.ld.com/mobile/events/diagnostic (mobile diagnostic)
 // events.ld.com/events/bulk/{envId} (JS)
 // events.ld.com/events/diagnostic/{envId} (JS)
-func bulkEventHandler(sdkKind basictypes.SDKKind, eventsKind ldevents.EventDataKind, offline bool) http.Handler {
+func bulkEventHandler(sdkKind basictypes.SDKKind, eventsKind ldevents.EventDataKind, discard func() bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		if offline {
+		// While the environment's kill switch is on, events are discarded as well, since they would
+		// describe evaluations of the fallback flags rather than the real ones.
+		if discard() || middleware.GetEnvContextInfo(req.Context()).Env.IsKillSwitchOn() {
 			w.WriteHeader(http.StatusAccepted)
 			if req.Body != nil {
 				_ = req.Body.Close()
@@ -204,6 +235,13 @@ func evaluateAllShared(w http.ResponseWriter, req *http.Request, valueOnly bool,
 	store := clientCtx.Env.GetStore()
 	loggers := clientCtx.Env.GetLoggers()
 
+	_, span := tracing.StartSpan(tracing.ContextWithRemoteParent(req.Context(), req.Header), "evaluate all flags",
+		tracing.SpanKindServer,
+		tracing.String("ld.env", clientCtx.Env.GetIdentifiers().GetDisplayName()),
+		tracing.String("ld.sdk_kind", string(sdkKind)),
+	)
+	defer span.End()
+
 	user, ok := getClientSideUserProperties(clientCtx.Env, sdkKind, req, w)
 	if !ok {
 		return
@@ -232,16 +270,42 @@ func evaluateAllShared(w http.ResponseWriter, req *http.Request, valueOnly bool,
 
 	loggers.Debugf("Application requested client-side flags (%s) for user: %s", sdkKind, user.GetKey())
 
+	// The results depend on the user as well as the flags, so the ETag is computed from the response itself.
+	envKey := surrogateEnvKey(clientCtx.Env)
+	writeResult := func(result []byte) {
+		writeETaggedJSONResponse(w, req, result, contentETagValue(result),
+			surrogatekeys.Environment(envKey), surrogatekeys.Evaluations(envKey))
+	}
+
+	var cache *evalcache.Cache // server-side evaluation requests are not cached
+	var cacheKey string
+	if sdkKind != basictypes.ServerSDK {
+		cache = clientCtx.Env.GetEvalCache()
+		cacheKey = evalcache.Key(user, sdkKind, valueOnly, withReasons)
+	}
+	if result, ok := cache.Get(cacheKey); ok {
+		span.SetAttributes(tracing.Bool("ld.eval_cache_hit", true))
+		writeResult(result)
+		return
+	}
+	cacheGeneration := cache.Generation()
+
 	items, err := store.GetAll(ldstoreimpl.Features())
 	if err != nil {
 		loggers.Warnf("Unable to fetch flags from feature store. Returning nil map. Error: %s", err)
+		span.SetError(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		_, _ = w.Write(util.ErrorJSONMsgf("Error fetching flags from feature store: %s", err))
 		return
 	}
 
 	evaluator := clientCtx.Env.GetEvaluator()
+	var filter flagfilter.Filter // server-side evaluation requests are not filtered
+	if sdkKind != basictypes.ServerSDK {
+		filter = clientCtx.Env.GetClientSideFlagFilter()
+	}
 
+	evaluated := 0
 	responseWriter := jwriter.NewWriter()
 	responseObj := responseWriter.Object()
 	for _, item := range items {
@@ -256,7 +320,11 @@ func evaluateAllShared(w http.ResponseWriter, req *http.Request, valueOnly bool,
 					continue
 				}
 			}
+			if !filter.Matches(flag.Key) {
+				continue
+			}
 			detail := evaluator.Evaluate(flag, user, nil)
+			evaluated++
 			if valueOnly {
 				detail.Value.WriteToJSONWriter(responseObj.Name(flag.Key))
 			} else {
@@ -278,58 +346,47 @@ func evaluateAllShared(w http.ResponseWriter, req *http.Request, valueOnly bool,
 	}
 	responseObj.End()
 	result := responseWriter.Bytes()
+	span.SetAttributes(tracing.Int("ld.flag_count", evaluated))
 
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(result)
+	cache.Set(cacheKey, result, cacheGeneration)
+	writeResult(result)
 }
 
 func pollFlagOrSegment(clientContext relayenv.EnvContext, kind ldstoretypes.DataKind) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 		key := mux.Vars(req)["key"]
-		item, err := clientContext.GetStore().Get(kind, key)
-		if err != nil {
-			clientContext.GetLoggers().Errorf("Error reading feature store: %s", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+		envKey := surrogateEnvKey(clientContext)
+		endpoint, itemKey := pollcache.Flag, surrogatekeys.Flag(envKey, key)
+		if kind == ldstoreimpl.Segments() {
+			endpoint, itemKey = pollcache.Segment, surrogatekeys.Segment(envKey, key)
 		}
-		if item.Item == nil {
-			w.WriteHeader(http.StatusNotFound)
-		} else {
+		cache := clientContext.GetPollingCache()
+		policy := cache.Policy(endpoint, clientContext.GetTTL())
+		resp, err := cache.Get(endpoint, key, policy, func() (pollcache.Response, error) {
+			item, err := clientContext.GetStore().Get(kind, key)
+			if err != nil || item.Item == nil {
+				return pollcache.Response{}, err
+			}
 			bytes, err := json.Marshal(item.Item)
-			if err == nil {
-				writeCacheableJSONResponse(w, req, clientContext, bytes, strconv.Itoa(item.Version))
-			} else {
-				clientContext.GetLoggers().Errorf("Error marshaling JSON: %s", err)
-				w.WriteHeader(http.StatusInternalServerError)
+			etag := strconv.Itoa(item.Version)
+			if generation := clientContext.GetKillSwitchGeneration(); generation != 0 && kind == ldstoreimpl.Features() {
+				etag += "-killswitch-" + strconv.FormatUint(generation, 10)
 			}
+			return pollcache.Response{Data: bytes, ETag: etag}, err
+		})
+		switch {
+		case err != nil:
+			clientContext.GetLoggers().Errorf("Error reading feature store: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		case resp.Data == nil:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			writeCacheableJSONResponse(w, req, policy, resp.Data, resp.ETag,
+				surrogatekeys.Environment(envKey), itemKey)
 		}
 	}
 }
 
-func writeCacheableJSONResponse(w http.ResponseWriter, req *http.Request, clientContext relayenv.EnvContext,
-	bytes []byte, etagValue string) {
-	etag := fmt.Sprintf("relay-%s", etagValue) // just to make it extra clear that these are relay-specific etags
-	if cachedEtag := req.Header.Get("If-None-Match"); cachedEtag != "" {
-		if cachedEtag == etag {
-			w.WriteHeader(http.StatusNotModified)
-			return
-		}
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Etag", etag)
-	ttl := clientContext.GetTTL()
-	if ttl > 0 {
-		w.Header().Set("Vary", "Authorization")
-		expiresAt := time.Now().UTC().Add(ttl)
-		w.Header().Set("Expires", expiresAt.Format(http.TimeFormat))
-		// We're setting "Expires:" instead of "Cache-Control:max-age=" so that if someone puts an
-		// HTTP cache in front of ld-relay, multiple clients hitting the cache at different times
-		// will all see the same expiration time.
-	}
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(bytes)
-}
-
 func serializeFlagsAsMap(coll []ldstoretypes.KeyedItemDescriptor) []byte {
 	w := jwriter.NewWriter()
 	obj := w.Object()
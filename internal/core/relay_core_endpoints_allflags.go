@@ -0,0 +1,95 @@
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+	"github.com/launchdarkly/ld-relay/v6/internal/util"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/lduser"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/flagstate"
+)
+
+// AllFlagsStateRequestRep is the JSON representation of a request to the all flags state API. The
+// options correspond to the options of the SDK's AllFlagsState method.
+//
+// This is exported for use in integration test code.
+type AllFlagsStateRequestRep struct {
+	User                       lduser.User `json:"user"`
+	ClientSideOnly             bool        `json:"clientSideOnly,omitempty"`
+	WithReasons                bool        `json:"withReasons,omitempty"`
+	DetailsOnlyForTrackedFlags bool        `json:"detailsOnlyForTrackedFlags,omitempty"`
+}
+
+// All flags state API endpoint: /api/eval/{envId}/allflags (POST)
+//
+// This returns the same JSON representation as marshaling the result of the SDK's AllFlagsState method,
+// which can be used to bootstrap the JavaScript SDK, so that services that do not run an SDK can get
+// all flag values for a user in one request. Big Segment memberships are checked in the environment's
+// Big Segments store, as they would be by an SDK. Like the evaluation API, it does not generate analytics
+// events, and it has the same authentication and error responses.
+func allFlagsStateHandler(w http.ResponseWriter, req *http.Request) {
+	clientCtx := middleware.GetEnvContextInfo(req.Context())
+	if !envIDMatchesEnvironment(req, clientCtx) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if req.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		_, _ = w.Write(util.ErrorJSONMsg("Content-Type must be application/json."))
+		return
+	}
+	var stateReq AllFlagsStateRequestRep
+	body, _ := ioutil.ReadAll(req.Body)
+	if err := json.Unmarshal(body, &stateReq); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write(util.ErrorJSONMsg(err.Error()))
+		return
+	}
+	if stateReq.User.GetKey() == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write(util.ErrorJSONMsg("User must have a 'key' attribute"))
+		return
+	}
+
+	flags, ok := getFlagsForEvaluationAPI(w, clientCtx.Env, nil)
+	if !ok {
+		return
+	}
+
+	clientCtx.Env.GetLoggers().Debugf("Application requested all flags state for user: %s", stateReq.User.GetKey())
+
+	var options []flagstate.Option
+	if stateReq.WithReasons {
+		options = append(options, flagstate.OptionWithReasons())
+	}
+	if stateReq.DetailsOnlyForTrackedFlags {
+		options = append(options, flagstate.OptionDetailsOnlyForTrackedFlags())
+	}
+	evaluator := clientCtx.Env.GetEvaluator()
+	state := flagstate.NewAllFlagsBuilder(options...)
+	for _, flag := range flags.found {
+		if stateReq.ClientSideOnly && !flag.ClientSideAvailability.UsingEnvironmentID {
+			continue
+		}
+		detail := evaluator.Evaluate(flag, stateReq.User, nil)
+		// This is the same logic that the SDK uses to decide whether the evaluation involved an experiment.
+		isExperiment := flag.IsExperimentationEnabled(detail.Reason)
+		state.AddFlag(flag.Key, flagstate.FlagState{
+			Value:                detail.Value,
+			Variation:            detail.VariationIndex,
+			Reason:               detail.Reason,
+			Version:              flag.Version,
+			TrackEvents:          flag.TrackEvents || isExperiment,
+			TrackReason:          isExperiment,
+			DebugEventsUntilDate: flag.DebugEventsUntilDate,
+		})
+	}
+	data, _ := json.Marshal(state.Build())
+	_, _ = w.Write(data)
+}
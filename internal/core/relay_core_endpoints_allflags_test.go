@@ -0,0 +1,94 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type allFlagsStateTestRep struct {
+	Valid      bool                              `json:"$valid"`
+	FlagsState map[string]map[string]interface{} `json:"$flagsState"`
+}
+
+func assertSameJSON(t *testing.T, expected, actual interface{}, msg string) {
+	expectedJSON, _ := json.Marshal(expected)
+	actualJSON, _ := json.Marshal(actual)
+	assert.JSONEq(t, string(expectedJSON), string(actualJSON), msg)
+}
+
+func TestAllFlagsStateHandler(t *testing.T) {
+	env := makeEnvForEvaluationAPI(t, true)
+	defer env.Close()
+	envID := string(st.EnvClientSide.Config.EnvID)
+
+	call := func(envID, body string) *httptest.ResponseRecorder {
+		headers := make(http.Header)
+		headers.Set("Content-Type", "application/json")
+		req := buildPreRoutedRequest("POST", []byte(body), headers, map[string]string{"envId": envID}, env)
+		w := httptest.NewRecorder()
+		allFlagsStateHandler(w, req)
+		return w
+	}
+
+	t.Run("all flags", func(t *testing.T) {
+		w := call(envID, `{"user":{"key":"me"}}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var values map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &values))
+		var rep allFlagsStateTestRep
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rep))
+		assert.True(t, rep.Valid)
+		assert.Len(t, rep.FlagsState, len(st.AllFlags))
+		for _, f := range st.AllFlags {
+			assertSameJSON(t, f.ExpectedValue, values[f.Flag.Key], f.Flag.Key)
+			assert.Equal(t, float64(f.Flag.Version), rep.FlagsState[f.Flag.Key]["version"], f.Flag.Key)
+		}
+		assert.Nil(t, rep.FlagsState[st.Flag2ServerSide.Flag.Key]["reason"])
+		assert.Equal(t, map[string]interface{}{"kind": "FALLTHROUGH"}, rep.FlagsState[st.Flag5ClientSide.Flag.Key]["reason"],
+			"reason is always included for an experiment")
+	})
+
+	t.Run("client-side only, with reasons", func(t *testing.T) {
+		w := call(envID, `{"user":{"key":"me"},"clientSideOnly":true,"withReasons":true}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var rep allFlagsStateTestRep
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rep))
+		assert.Len(t, rep.FlagsState, len(st.ClientSideFlags))
+		for _, f := range st.ClientSideFlags {
+			assertSameJSON(t, f.ExpectedReason, rep.FlagsState[f.Flag.Key]["reason"], f.Flag.Key)
+		}
+	})
+
+	t.Run("no user key", func(t *testing.T) {
+		w := call(envID, `{"user":{}}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("wrong environment ID", func(t *testing.T) {
+		w := call(string(st.UndefinedEnvID), `{"user":{"key":"me"}}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestAllFlagsStateHandlerReturns503IfNotInitialized(t *testing.T) {
+	env := makeEnvForEvaluationAPI(t, false)
+	defer env.Close()
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/json")
+	req := buildPreRoutedRequest("POST", []byte(`{"user":{"key":"me"}}`), headers,
+		map[string]string{"envId": string(st.EnvClientSide.Config.EnvID)}, env)
+	w := httptest.NewRecorder()
+	allFlagsStateHandler(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+	"github.com/launchdarkly/ld-relay/v6/internal/filedata"
+	"github.com/launchdarkly/ld-relay/v6/internal/util"
+)
+
+// Archive export endpoint: /archive
+//
+// This is authenticated with the SDK key, and returns the environment's current flag and segment data
+// in the offline mode archive format, so that another Relay instance can use it as a file data source.
+func archiveHandler(w http.ResponseWriter, req *http.Request) {
+	clientCtx := middleware.GetEnvContextInfo(req.Context())
+	snapshot, err := filedata.MakeEnvironmentSnapshot(clientCtx.Env)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case errors.Is(err, filedata.ErrSnapshotWithoutEnvID):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, filedata.ErrSnapshotNotInitialized):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write(util.ErrorJSONMsg(err.Error()))
+		return
+	}
+	// Write the whole archive before sending anything, so that an error doesn't produce a truncated file
+	// with a success status.
+	var buf bytes.Buffer
+	if err := filedata.WriteArchive(&buf, []filedata.EnvironmentSnapshot{snapshot}); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write(util.ErrorJSONMsgf("Unable to write archive: %s", err))
+		return
+	}
+	clientCtx.Env.GetLoggers().Debugf("Application requested archive of environment %s", snapshot.Rep.EnvID)
+	w.Header().Set("Content-Type", filedata.ArchiveContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.tar.gz"`, snapshot.Rep.EnvID))
+	_, _ = w.Write(buf.Bytes())
+}
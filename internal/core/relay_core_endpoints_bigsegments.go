@@ -0,0 +1,88 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+
+	"github.com/gorilla/mux"
+)
+
+// BigSegmentsEnvironmentStatusRep is the JSON representation returned by the big segments status
+// endpoint.
+//
+// This is exported for use in integration test code.
+type BigSegmentsEnvironmentStatusRep struct {
+	EnvID string `json:"envId"`
+	BigSegmentStatusRep
+	Cursor      string                         `json:"cursor,omitempty"`
+	Memberships *BigSegmentMembershipCountsRep `json:"memberships,omitempty"`
+}
+
+// BigSegmentMembershipCountsRep is the JSON representation of the amount of big segment data in the
+// store. The counts are estimates, and are omitted if the store cannot provide them.
+//
+// This is exported for use in integration test code.
+type BigSegmentMembershipCountsRep struct {
+	Users    int `json:"users"`
+	Included int `json:"included"`
+	Excluded int `json:"excluded"`
+}
+
+// Big segments status endpoint: /bigsegments/status/{envId}
+//
+// This is authenticated with the SDK key, and the environment ID in the path must belong to the same
+// environment.
+func bigSegmentsStatusHandler(w http.ResponseWriter, req *http.Request) {
+	clientCtx := middleware.GetEnvContextInfo(req.Context())
+	if !envIDMatchesEnvironment(req, clientCtx) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	store := clientCtx.Env.GetBigSegmentStore()
+	if store == nil {
+		// either no big segment store is configured, or the environment does not use big segments
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	envID := config.EnvironmentID(mux.Vars(req)["envId"])
+	rep := makeBigSegmentsEnvironmentStatusRep(envID, store, clientCtx.Env.GetBigSegmentsStaleThreshold(),
+		ldtime.UnixMillisNow())
+	data, _ := json.Marshal(rep)
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+func makeBigSegmentsEnvironmentStatusRep(
+	envID config.EnvironmentID,
+	store bigsegments.BigSegmentStore,
+	stalenessThreshold time.Duration,
+	now ldtime.UnixMillisecondTime,
+) BigSegmentsEnvironmentStatusRep {
+	rep := BigSegmentsEnvironmentStatusRep{
+		EnvID:               string(envID),
+		BigSegmentStatusRep: makeBigSegmentStatusRep(store, stalenessThreshold, now),
+	}
+	if !rep.Available {
+		return rep
+	}
+	rep.Cursor, _ = store.GetCursor()
+	if counter, ok := store.(bigsegments.BigSegmentMembershipCounter); ok {
+		if counts, err := counter.GetMembershipCounts(); err == nil {
+			rep.Memberships = &BigSegmentMembershipCountsRep{
+				Users:    counts.Users,
+				Included: counts.Included,
+				Excluded: counts.Excluded,
+			}
+		}
+	}
+	return rep
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBigSegmentsEnvironmentStatusRep(t *testing.T) {
+	threshold := time.Minute
+	now := ldtime.UnixMillisecondTime(1000000)
+	envID := config.EnvironmentID("env-id")
+
+	t.Run("store error", func(t *testing.T) {
+		rep := makeBigSegmentsEnvironmentStatusRep(envID, fakeBigSegmentStoreForStatus{err: errors.New("sorry")},
+			threshold, now)
+		assert.Equal(t, BigSegmentsEnvironmentStatusRep{
+			EnvID:               string(envID),
+			BigSegmentStatusRep: BigSegmentStatusRep{Status: statusEnvDisconnected},
+		}, rep)
+	})
+
+	t.Run("store with membership counts", func(t *testing.T) {
+		store, err := bigsegments.DefaultBigSegmentStoreFactory(config.EnvConfig{},
+			config.Config{BigSegments: config.BigSegmentsConfig{Type: config.BigSegmentsStoreTypeMemory}},
+			ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		defer store.Close()
+		_, err = store.ApplyPatch(bigsegments.BigSegmentPatch{
+			SegmentID: "segment.g1",
+			Version:   "1",
+			Changes: bigsegments.BigSegmentPatchChanges{
+				Included: bigsegments.BigSegmentPatchChangesMutations{Add: []string{"user1", "user2"}},
+				Excluded: bigsegments.BigSegmentPatchChangesMutations{Add: []string{"user3"}},
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, store.SetSynchronizedOn(now-1000))
+
+		rep := makeBigSegmentsEnvironmentStatusRep(envID, store, threshold, now)
+		assert.Equal(t, statusEnvConnected, rep.Status)
+		assert.Equal(t, now-1000, rep.LastSynchronizedOn)
+		assert.Equal(t, "1", rep.Cursor)
+		assert.Equal(t, &BigSegmentMembershipCountsRep{Users: 3, Included: 2, Excluded: 1}, rep.Memberships)
+
+		data, err := json.Marshal(rep)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"envId":"env-id","status":"connected","available":true,"potentiallyStale":false,
+			"lastSynchronizedOn":999000,"stalenessMillis":1000,"cursor":"1",
+			"memberships":{"users":3,"included":2,"excluded":1}}`, string(data))
+	})
+
+	t.Run("store without membership counts", func(t *testing.T) {
+		rep := makeBigSegmentsEnvironmentStatusRep(envID,
+			fakeBigSegmentStoreForStatus{BigSegmentStore: bigsegments.NewNullBigSegmentStore(), synchronizedOn: now},
+			threshold, now)
+		assert.Equal(t, statusEnvConnected, rep.Status)
+		assert.Nil(t, rep.Memberships)
+	})
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// Change feed endpoint: /changes/{envId}
+//
+// This is not authenticated with any of the SDK credentials; instead, the environment must have a
+// change feed token, which the flagchange package checks for in the Authorization header. The events
+// only describe which flag or segment changed, not its new configuration.
+//
+// The environment is looked up with FindEnvironment, so that a lazily initialized environment is only
+// activated once the request's token has been checked. Like the SDK streams, the feed is subject to
+// streamLimits, which is applied after the token check.
+func changeFeedHandler(r *RelayCore, streamLimits mux.MiddlewareFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		envID := config.EnvironmentID(mux.Vars(req)["envId"])
+		env, isConfigured := r.FindEnvironment(envID)
+		if !isConfigured {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if env == nil || env.GetTenant() != middleware.GetTenant(req.Context()) {
+			// an environment that belongs to a different tenant (or to none) does not exist as far as this
+			// request is concerned
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		handler := env.GetChangeFeedHandler(streamLimits)
+		if handler == nil {
+			// the feed is not enabled for this environment
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// the stream limits get the environment from the request context, as they do for SDK requests
+		ctx := middleware.WithEnvContextInfo(req.Context(), middleware.EnvContextInfo{Env: env})
+		handler.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	"github.com/launchdarkly/ld-relay/v6/internal/util"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldreason"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/lduser"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	ldeval "gopkg.in/launchdarkly/go-server-sdk-evaluation.v1"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/gorilla/mux"
+)
+
+// EvaluationRequestRep is the JSON representation of a request to the evaluation API. If FlagKeys is
+// empty, all flags are evaluated.
+//
+// This is exported for use in integration test code.
+type EvaluationRequestRep struct {
+	User     lduser.User `json:"user"`
+	FlagKeys []string    `json:"flagKeys,omitempty"`
+}
+
+// EvaluationResponseRep is the JSON representation returned by the evaluation API.
+//
+// This is exported for use in integration test code.
+type EvaluationResponseRep struct {
+	Flags map[string]EvaluationResultRep `json:"flags"`
+}
+
+// EvaluationResultRep is the result of evaluating one flag in EvaluationResponseRep. Version is omitted
+// if the flag does not exist.
+//
+// This is exported for use in integration test code.
+type EvaluationResultRep struct {
+	Value     ldvalue.Value             `json:"value"`
+	Variation ldvalue.OptionalInt       `json:"variation"`
+	Version   int                       `json:"version,omitempty"`
+	Reason    ldreason.EvaluationReason `json:"reason"`
+}
+
+// Evaluation API endpoint: /api/eval/{envId} (POST)
+//
+// This is authenticated with the SDK key, and the environment ID in the path must belong to the same
+// environment. Unlike the SDK evaluation endpoints, it evaluates only the requested flags, always
+// includes the reason, and does not generate analytics events.
+func evaluationAPIHandler(w http.ResponseWriter, req *http.Request) {
+	clientCtx := middleware.GetEnvContextInfo(req.Context())
+	if !envIDMatchesEnvironment(req, clientCtx) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if req.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		_, _ = w.Write(util.ErrorJSONMsg("Content-Type must be application/json."))
+		return
+	}
+	var evalReq EvaluationRequestRep
+	body, _ := ioutil.ReadAll(req.Body)
+	if err := json.Unmarshal(body, &evalReq); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write(util.ErrorJSONMsg(err.Error()))
+		return
+	}
+	if evalReq.User.GetKey() == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write(util.ErrorJSONMsg("User must have a 'key' attribute"))
+		return
+	}
+
+	flags, ok := getFlagsForEvaluationAPI(w, clientCtx.Env, evalReq.FlagKeys)
+	if !ok {
+		return
+	}
+
+	clientCtx.Env.GetLoggers().Debugf("Application requested evaluation of %d flags for user: %s",
+		len(flags.found)+len(flags.missingKeys), evalReq.User.GetKey())
+
+	resp := EvaluationResponseRep{Flags: flags.evaluate(clientCtx.Env.GetEvaluator(), evalReq.User)}
+	data, _ := json.Marshal(resp)
+	_, _ = w.Write(data)
+}
+
+// envIDMatchesEnvironment returns true if the environment ID in the path belongs to the request's
+// environment, or, if the request was routed to a canary environment, to the environment whose
+// credential it used.
+func envIDMatchesEnvironment(req *http.Request, clientCtx middleware.EnvContextInfo) bool {
+	envID := config.EnvironmentID(mux.Vars(req)["envId"])
+	for _, env := range []relayenv.EnvContext{clientCtx.Env, clientCtx.CanaryOf} {
+		if env == nil {
+			continue
+		}
+		for _, c := range env.GetCredentials() {
+			if c == envID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type flagsForEvaluationAPI struct {
+	found       []*ldmodel.FeatureFlag
+	missingKeys []string
+}
+
+// getFlagsForEvaluationAPI queries the store for the requested flags, or all flags if keys is empty. If
+// the store is not available, it writes an error response and returns false.
+func getFlagsForEvaluationAPI(
+	w http.ResponseWriter,
+	env relayenv.EnvContext,
+	keys []string,
+) (flagsForEvaluationAPI, bool) {
+	var ret flagsForEvaluationAPI
+	loggers := env.GetLoggers()
+	store := env.GetStore()
+	if !env.GetClient().Initialized() && !store.IsInitialized() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		loggers.Warn("Called before client initialization. Feature store not available")
+		_, _ = w.Write(util.ErrorJSONMsg("Service not initialized"))
+		return ret, false
+	}
+
+	if len(keys) == 0 {
+		items, err := store.GetAll(ldstoreimpl.Features())
+		if err != nil {
+			loggers.Warnf("Unable to fetch flags from feature store. Error: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write(util.ErrorJSONMsgf("Error fetching flags from feature store: %s", err))
+			return ret, false
+		}
+		for _, item := range items {
+			if flag, ok := item.Item.Item.(*ldmodel.FeatureFlag); ok {
+				ret.found = append(ret.found, flag)
+			}
+		}
+		return ret, true
+	}
+	for _, key := range keys {
+		item, err := store.Get(ldstoreimpl.Features(), key)
+		if err != nil {
+			loggers.Warnf("Unable to fetch flag from feature store. Error: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write(util.ErrorJSONMsgf("Error fetching flags from feature store: %s", err))
+			return ret, false
+		}
+		if flag, ok := item.Item.(*ldmodel.FeatureFlag); ok {
+			ret.found = append(ret.found, flag)
+		} else {
+			ret.missingKeys = append(ret.missingKeys, key) // unknown or deleted
+		}
+	}
+	return ret, true
+}
+
+func (f flagsForEvaluationAPI) evaluate(evaluator ldeval.Evaluator, user lduser.User) map[string]EvaluationResultRep {
+	results := make(map[string]EvaluationResultRep, len(f.found)+len(f.missingKeys))
+	for _, flag := range f.found {
+		detail := evaluator.Evaluate(flag, user, nil)
+		results[flag.Key] = EvaluationResultRep{
+			Value:     detail.Value,
+			Variation: detail.VariationIndex,
+			Version:   flag.Version,
+			Reason:    detail.Reason,
+		}
+	}
+	for _, key := range f.missingKeys {
+		results[key] = EvaluationResultRep{Reason: ldreason.NewEvalReasonError(ldreason.EvalErrorFlagNotFound)}
+	}
+	return results
+}
@@ -0,0 +1,214 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	"github.com/launchdarkly/ld-relay/v6/internal/util"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/lduser"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+
+	// bulkEvaluationWorkers is the number of users that are evaluated at once, so that big segment
+	// lookups for different users can proceed in parallel.
+	bulkEvaluationWorkers = 8
+
+	// bulkEvaluationBatchSize is the number of lines of a newline-delimited request that are read before
+	// their results are written and flushed.
+	bulkEvaluationBatchSize = 100
+
+	// bulkEvaluationMaxLineSize is the longest line that is allowed in a newline-delimited request.
+	bulkEvaluationMaxLineSize = 1024 * 1024
+)
+
+var errBulkEvaluationNoUserKey = errors.New("user must have a 'key' attribute")
+
+// BulkEvaluationRequestRep is the JSON representation of a request to the bulk evaluation API. If
+// FlagKeys is empty, all flags are evaluated.
+//
+// This is exported for use in integration test code.
+type BulkEvaluationRequestRep struct {
+	Users    []lduser.User `json:"users"`
+	FlagKeys []string      `json:"flagKeys,omitempty"`
+}
+
+// BulkEvaluationResponseRep is the JSON representation returned by the bulk evaluation API when the
+// request was a JSON object. The results are in the same order as the users in the request.
+//
+// This is exported for use in integration test code.
+type BulkEvaluationResponseRep struct {
+	Results []BulkEvaluationResultRep `json:"results"`
+}
+
+// BulkEvaluationResultRep is the result of evaluating flags for one user in the bulk evaluation API.
+// If that user could not be evaluated, Error describes the problem and Flags is omitted.
+//
+// This is exported for use in integration test code.
+type BulkEvaluationResultRep struct {
+	UserKey string                         `json:"userKey"`
+	Flags   map[string]EvaluationResultRep `json:"flags,omitempty"`
+	Error   string                         `json:"error,omitempty"`
+}
+
+// bulkEvaluationRequestBody is how we parse BulkEvaluationRequestRep, so that an invalid user causes an
+// error only for that user.
+type bulkEvaluationRequestBody struct {
+	Users    []json.RawMessage `json:"users"`
+	FlagKeys []string          `json:"flagKeys"`
+}
+
+type bulkEvaluationInput struct {
+	user lduser.User
+	err  error
+}
+
+// Bulk evaluation API endpoint: /api/eval/{envId}/bulk (POST)
+//
+// This is the same as the evaluation API, but for many users at once. The body is either a JSON object
+// (BulkEvaluationRequestRep), or, if the Content-Type is application/x-ndjson, one user JSON object per
+// line with the flag keys in "flagKey" query parameters; in the latter case, the response is also one
+// JSON object per line, and is written as the request is read so that the body can be of any size.
+func bulkEvaluationAPIHandler(w http.ResponseWriter, req *http.Request) {
+	clientCtx := middleware.GetEnvContextInfo(req.Context())
+	if !envIDMatchesEnvironment(req, clientCtx) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	contentType := req.Header.Get("Content-Type")
+	if contentType != "application/json" && contentType != ndjsonContentType {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		_, _ = w.Write(util.ErrorJSONMsg("Content-Type must be application/json or " + ndjsonContentType + "."))
+		return
+	}
+
+	if contentType == ndjsonContentType {
+		bulkEvaluateNewlineDelimited(w, req)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	var evalReq bulkEvaluationRequestBody
+	if err := json.NewDecoder(req.Body).Decode(&evalReq); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write(util.ErrorJSONMsg(err.Error()))
+		return
+	}
+	flags, ok := getFlagsForEvaluationAPI(w, clientCtx.Env, evalReq.FlagKeys)
+	if !ok {
+		return
+	}
+	clientCtx.Env.GetLoggers().Debugf("Application requested evaluation of %d flags for %d users",
+		len(flags.found)+len(flags.missingKeys), len(evalReq.Users))
+
+	inputs := make([]bulkEvaluationInput, 0, len(evalReq.Users))
+	for _, u := range evalReq.Users {
+		var input bulkEvaluationInput
+		input.err = json.Unmarshal(u, &input.user)
+		inputs = append(inputs, input)
+	}
+	resp := BulkEvaluationResponseRep{Results: flags.evaluateAll(clientCtx.Env, inputs)}
+	data, _ := json.Marshal(resp)
+	_, _ = w.Write(data)
+}
+
+func bulkEvaluateNewlineDelimited(w http.ResponseWriter, req *http.Request) {
+	clientCtx := middleware.GetEnvContextInfo(req.Context())
+	w.Header().Set("Content-Type", "application/json")
+	flags, ok := getFlagsForEvaluationAPI(w, clientCtx.Env, req.URL.Query()["flagKey"])
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", ndjsonContentType)
+	flusher, _ := w.(http.Flusher)
+
+	writeBatch := func(batch []bulkEvaluationInput) {
+		for _, r := range flags.evaluateAll(clientCtx.Env, batch) {
+			data, _ := json.Marshal(r)
+			_, _ = w.Write(append(data, '\n'))
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	scanner := bufio.NewScanner(req.Body)
+	scanner.Buffer(nil, bulkEvaluationMaxLineSize)
+	batch := make([]bulkEvaluationInput, 0, bulkEvaluationBatchSize)
+	count := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var input bulkEvaluationInput
+		input.err = json.Unmarshal(line, &input.user)
+		batch = append(batch, input)
+		count++
+		if len(batch) == bulkEvaluationBatchSize {
+			writeBatch(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		// The response has already started, so the best we can do is to report it in the last line.
+		batch = append(batch, bulkEvaluationInput{err: err})
+	}
+	writeBatch(batch)
+
+	clientCtx.Env.GetLoggers().Debugf("Application requested evaluation of %d flags for %d users",
+		len(flags.found)+len(flags.missingKeys), count)
+}
+
+// evaluateAll evaluates the flags for each of the users, in parallel, and returns the results in the
+// same order. The users' big segment memberships are queried in one batch beforehand if possible.
+func (f flagsForEvaluationAPI) evaluateAll(
+	env relayenv.EnvContext,
+	inputs []bulkEvaluationInput,
+) []BulkEvaluationResultRep {
+	userKeys := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		if input.err == nil && input.user.GetKey() != "" {
+			userKeys = append(userKeys, input.user.GetKey())
+		}
+	}
+	defer env.PrefetchBigSegmentMemberships(userKeys)()
+
+	evaluator := env.GetEvaluator()
+	results := make([]BulkEvaluationResultRep, len(inputs))
+	indexCh := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < bulkEvaluationWorkers && i < len(inputs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexCh {
+				input := inputs[i]
+				results[i].UserKey = input.user.GetKey()
+				if input.err == nil && input.user.GetKey() == "" {
+					input.err = errBulkEvaluationNoUserKey
+				}
+				if input.err != nil {
+					results[i].Error = input.err.Error()
+					continue
+				}
+				results[i].Flags = f.evaluate(evaluator, input.user)
+			}
+		}()
+	}
+	for i := range inputs {
+		indexCh <- i
+	}
+	close(indexCh)
+	wg.Wait()
+	return results
+}
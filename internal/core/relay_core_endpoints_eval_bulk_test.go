@@ -0,0 +1,101 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldreason"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func callBulkEvaluationAPI(t *testing.T, contentType, query, body string) *httptest.ResponseRecorder {
+	env := makeEnvForEvaluationAPI(t, true)
+	defer env.Close()
+	headers := make(http.Header)
+	headers.Set("Content-Type", contentType)
+	req := buildPreRoutedRequest("POST", []byte(body), headers,
+		map[string]string{"envId": string(st.EnvClientSide.Config.EnvID)}, env)
+	req.URL.RawQuery = query
+	w := httptest.NewRecorder()
+	bulkEvaluationAPIHandler(w, req)
+	return w
+}
+
+func TestBulkEvaluationAPIWithJSONBody(t *testing.T) {
+	w := callBulkEvaluationAPI(t, "application/json", "",
+		`{"users":[{"key":"a"},{"name":"no-key"},{"key":"b"}],"flagKeys":["`+st.Flag2ServerSide.Flag.Key+`"]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	expectedFlags := map[string]EvaluationResultRep{
+		st.Flag2ServerSide.Flag.Key: {
+			Value:     ldvalue.Int(3),
+			Variation: ldvalue.NewOptionalInt(0),
+			Version:   st.Flag2ServerSide.Flag.Version,
+			Reason:    ldreason.NewEvalReasonFallthrough(),
+		},
+	}
+	var resp BulkEvaluationResponseRep
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, BulkEvaluationResultRep{UserKey: "a", Flags: expectedFlags}, resp.Results[0])
+	assert.NotEqual(t, "", resp.Results[1].Error)
+	assert.Nil(t, resp.Results[1].Flags)
+	assert.Equal(t, BulkEvaluationResultRep{UserKey: "b", Flags: expectedFlags}, resp.Results[2])
+}
+
+func TestBulkEvaluationAPIWithNewlineDelimitedBody(t *testing.T) {
+	userCount := bulkEvaluationBatchSize*2 + 3
+	var body strings.Builder
+	for i := 0; i < userCount; i++ {
+		fmt.Fprintf(&body, `{"key":"user%d"}`+"\n", i)
+		if i == 1 {
+			body.WriteString("\nnot JSON\n")
+		}
+	}
+	w := callBulkEvaluationAPI(t, ndjsonContentType,
+		"flagKey="+st.Flag1ServerSide.Flag.Key+"&flagKey=unknown", body.String())
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ndjsonContentType, w.Header().Get("Content-Type"))
+
+	var results []BulkEvaluationResultRep
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var r BulkEvaluationResultRep
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		results = append(results, r)
+	}
+	require.Len(t, results, userCount+1)
+
+	assert.Equal(t, "user1", results[1].UserKey)
+	assert.NotEqual(t, "", results[2].Error)
+	assert.Nil(t, results[2].Flags)
+	for i, r := range append(results[:2:2], results[3:]...) {
+		assert.Equal(t, fmt.Sprintf("user%d", i), r.UserKey)
+		assert.Equal(t, "", r.Error)
+		assert.Equal(t, ldvalue.Bool(true), r.Flags[st.Flag1ServerSide.Flag.Key].Value)
+		assert.Equal(t, ldreason.EvalErrorFlagNotFound, r.Flags["unknown"].Reason.GetErrorKind())
+	}
+}
+
+func TestBulkEvaluationAPIRejectsInvalidRequests(t *testing.T) {
+	t.Run("malformed JSON", func(t *testing.T) {
+		w := callBulkEvaluationAPI(t, "application/json", "", `{"users":`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("wrong content type", func(t *testing.T) {
+		w := callBulkEvaluationAPI(t, "text/plain", "", `{"users":[]}`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+}
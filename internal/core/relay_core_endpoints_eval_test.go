@@ -0,0 +1,133 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldreason"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeEnvForEvaluationAPI(t *testing.T, storeInitialized bool) relayenv.EnvContext {
+	return makeEnvForAPIWithStore(t, st.MakeStoreWithData(storeInitialized), storeInitialized)
+}
+
+func makeEnvForAPIWithStore(t *testing.T, store interfaces.DataStore, initialized bool) relayenv.EnvContext {
+	readyCh := make(chan relayenv.EnvContext, 1)
+	env, err := relayenv.NewEnvContext(relayenv.EnvContextImplParams{
+		EnvConfig:        st.EnvClientSide.Config,
+		ClientFactory:    testclient.FakeLDClientFactory(initialized),
+		DataStoreFactory: st.ExistingDataStoreFactory{Instance: store},
+		Loggers:          ldlog.NewDisabledLoggers(),
+	}, readyCh)
+	require.NoError(t, err)
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		require.Fail(t, "timed out waiting for client initialization")
+	}
+	return env
+}
+
+func callEvaluationAPI(env relayenv.EnvContext, envID string, body string) *httptest.ResponseRecorder {
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/json")
+	req := buildPreRoutedRequest("POST", []byte(body), headers, map[string]string{"envId": envID}, env)
+	w := httptest.NewRecorder()
+	evaluationAPIHandler(w, req)
+	return w
+}
+
+func TestEvaluationAPIEvaluatesRequestedFlags(t *testing.T) {
+	env := makeEnvForEvaluationAPI(t, true)
+	defer env.Close()
+
+	w := callEvaluationAPI(env, string(st.EnvClientSide.Config.EnvID),
+		`{"user":{"key":"me"},"flagKeys":["`+st.Flag1ServerSide.Flag.Key+`","`+st.Flag2ServerSide.Flag.Key+`","unknown"]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var resp EvaluationResponseRep
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, map[string]EvaluationResultRep{
+		st.Flag1ServerSide.Flag.Key: {
+			Value:     ldvalue.Bool(true),
+			Variation: ldvalue.NewOptionalInt(0),
+			Version:   st.Flag1ServerSide.Flag.Version,
+			Reason:    ldreason.NewEvalReasonOff(),
+		},
+		st.Flag2ServerSide.Flag.Key: {
+			Value:     ldvalue.Int(3),
+			Variation: ldvalue.NewOptionalInt(0),
+			Version:   st.Flag2ServerSide.Flag.Version,
+			Reason:    ldreason.NewEvalReasonFallthrough(),
+		},
+		"unknown": {Reason: ldreason.NewEvalReasonError(ldreason.EvalErrorFlagNotFound)},
+	}, resp.Flags)
+}
+
+func TestEvaluationAPIEvaluatesAllFlagsIfNoKeysAreGiven(t *testing.T) {
+	env := makeEnvForEvaluationAPI(t, true)
+	defer env.Close()
+
+	w := callEvaluationAPI(env, string(st.EnvClientSide.Config.EnvID), `{"user":{"key":"me"}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp EvaluationResponseRep
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Flags, len(st.AllFlags))
+	for _, f := range st.AllFlags {
+		assert.Equal(t, f.Flag.Version, resp.Flags[f.Flag.Key].Version, f.Flag.Key)
+	}
+}
+
+func TestEvaluationAPIReturns404ForWrongEnvironmentID(t *testing.T) {
+	env := makeEnvForEvaluationAPI(t, true)
+	defer env.Close()
+
+	w := callEvaluationAPI(env, string(st.UndefinedEnvID), `{"user":{"key":"me"}}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestEvaluationAPIRejectsInvalidRequests(t *testing.T) {
+	env := makeEnvForEvaluationAPI(t, true)
+	defer env.Close()
+	envID := string(st.EnvClientSide.Config.EnvID)
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		w := callEvaluationAPI(env, envID, `{"user":`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("no user key", func(t *testing.T) {
+		w := callEvaluationAPI(env, envID, `{"flagKeys":["x"]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("wrong content type", func(t *testing.T) {
+		req := buildPreRoutedRequest("POST", []byte(`{"user":{"key":"me"}}`), nil, map[string]string{"envId": envID}, env)
+		w := httptest.NewRecorder()
+		evaluationAPIHandler(w, req)
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+}
+
+func TestEvaluationAPIReturns503IfNotInitialized(t *testing.T) {
+	env := makeEnvForEvaluationAPI(t, false)
+	defer env.Close()
+
+	w := callEvaluationAPI(env, string(st.EnvClientSide.Config.EnvID), `{"user":{"key":"me"}}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
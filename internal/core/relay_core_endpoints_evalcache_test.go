@@ -0,0 +1,77 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientSideEvalResponsesAreCachedUntilDataChanges(t *testing.T) {
+	store := st.MakeStoreWithData(true)
+	envConfig := st.EnvClientSide.Config
+	envConfig.EvalCacheTTL = ct.NewOptDuration(time.Hour)
+	readyCh := make(chan relayenv.EnvContext, 1)
+	env, err := relayenv.NewEnvContext(relayenv.EnvContextImplParams{
+		EnvConfig:        envConfig,
+		ClientFactory:    testclient.FakeLDClientFactory(true),
+		DataStoreFactory: st.ExistingDataStoreFactory{Instance: store},
+		Loggers:          ldlog.NewDisabledLoggers(),
+	}, readyCh)
+	require.NoError(t, err)
+	defer env.Close()
+	<-readyCh
+	require.NotNil(t, env.GetEvalCache())
+
+	evaluate := func(userJSON string) string {
+		headers := make(http.Header)
+		headers.Set("Content-Type", "application/json")
+		req := buildPreRoutedRequest("REPORT", []byte(userJSON), headers, nil, env)
+		w := httptest.NewRecorder()
+		evaluateAllFeatureFlagsValueOnly(basictypes.JSClientSDK)(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	flagKey := st.Flag5ClientSide.Flag.Key
+	original := evaluate(`{"key":"me","name":"x"}`)
+	assert.Contains(t, original, `"`+flagKey+`":3`)
+
+	// Changing the underlying store directly does not notify Relay, so the cached response is still used,
+	// even if the user's properties are in a different order.
+	flagV2 := ldbuilders.NewFlagBuilder(flagKey).On(true).FallthroughVariation(0).Variations(ldvalue.Int(4)).
+		ClientSideUsingEnvironmentID(true).Version(2).Build()
+	_, err = store.Upsert(ldstoreimpl.Features(), flagKey, st.FlagDesc(flagV2))
+	require.NoError(t, err)
+	assert.Equal(t, original, evaluate(`{"name":"x","key":"me"}`))
+
+	// A different user is not in the cache.
+	assert.Contains(t, evaluate(`{"key":"you"}`), `"`+flagKey+`":4`)
+
+	// An update received by Relay discards the cached responses.
+	flagV3 := ldbuilders.NewFlagBuilder(flagKey).On(true).FallthroughVariation(0).Variations(ldvalue.Int(5)).
+		ClientSideUsingEnvironmentID(true).Version(3).Build()
+	_, err = env.GetStore().Upsert(ldstoreimpl.Features(), flagKey, st.FlagDesc(flagV3))
+	require.NoError(t, err)
+	assert.Contains(t, evaluate(`{"key":"me","name":"x"}`), `"`+flagKey+`":5`)
+}
+
+func TestEvalCacheIsNotEnabledByDefault(t *testing.T) {
+	env := makeEnvForEvaluationAPI(t, true)
+	defer env.Close()
+	assert.Nil(t, env.GetEvalCache())
+}
@@ -0,0 +1,289 @@
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	"github.com/launchdarkly/ld-relay/v6/internal/util"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldreason"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/lduser"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	ldeval "gopkg.in/launchdarkly/go-server-sdk-evaluation.v1"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+// Kinds of steps in EvaluationExplanationRep.
+const (
+	explainStepOff          = "off"
+	explainStepPrerequisite = "prerequisite"
+	explainStepTarget       = "target"
+	explainStepRule         = "rule"
+	explainStepFallthrough  = "fallthrough"
+)
+
+// EvaluationExplainRequestRep is the JSON representation of a request to the evaluation explain API.
+//
+// This is exported for use in integration test code.
+type EvaluationExplainRequestRep struct {
+	User    lduser.User `json:"user"`
+	FlagKey string      `json:"flagKey"`
+}
+
+// EvaluationExplanationRep is the JSON representation returned by the evaluation explain API. Steps are
+// the parts of the flag that were checked, in the order that the evaluator checks them; the last one
+// is the one that determined the result.
+//
+// This is exported for use in integration test code.
+type EvaluationExplanationRep struct {
+	FlagKey string              `json:"flagKey"`
+	Result  EvaluationResultRep `json:"result"`
+	Steps   []ExplainStepRep    `json:"steps"`
+}
+
+// ExplainStepRep is one step in EvaluationExplanationRep. Which of the optional properties are set
+// depends on Kind.
+//
+// This is exported for use in integration test code.
+type ExplainStepRep struct {
+	Kind    string `json:"kind"`
+	Matched bool   `json:"matched"`
+
+	// For "rule" and "target" steps, the index of the rule or the matching target
+	Index *int `json:"index,omitempty"`
+	// For "rule" steps
+	RuleID string `json:"ruleId,omitempty"`
+	// For "prerequisite" steps
+	PrerequisiteKey    string               `json:"prerequisiteKey,omitempty"`
+	RequiredVariation  *int                 `json:"requiredVariation,omitempty"`
+	PrerequisiteResult *EvaluationResultRep `json:"prerequisiteResult,omitempty"`
+	// For "target" and "fallthrough" steps
+	Variation *int `json:"variation,omitempty"`
+
+	SegmentLookups []SegmentLookupRep `json:"segmentLookups,omitempty"`
+}
+
+// SegmentLookupRep describes a segment that was looked up during one step of EvaluationExplanationRep.
+// For a big segment, Included is the user's membership (nil if the user is neither included nor
+// excluded) and BigSegmentsStatus is the state of the big segment store when it was queried.
+//
+// This is exported for use in integration test code.
+type SegmentLookupRep struct {
+	SegmentKey        string                     `json:"segmentKey"`
+	Found             bool                       `json:"found"`
+	BigSegment        bool                       `json:"bigSegment,omitempty"`
+	Included          *bool                      `json:"included,omitempty"`
+	BigSegmentsStatus ldreason.BigSegmentsStatus `json:"bigSegmentsStatus,omitempty"`
+}
+
+// Evaluation explain endpoint: /api/eval/{envId}/explain (POST)
+//
+// This is authenticated with the SDK key, like the evaluation API. It evaluates one flag for one user and
+// describes how the result was reached, for diagnosing why a user got a particular value.
+func evaluationExplainHandler(w http.ResponseWriter, req *http.Request) {
+	clientCtx := middleware.GetEnvContextInfo(req.Context())
+	if !envIDMatchesEnvironment(req, clientCtx) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if req.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		_, _ = w.Write(util.ErrorJSONMsg("Content-Type must be application/json."))
+		return
+	}
+	var explainReq EvaluationExplainRequestRep
+	body, _ := ioutil.ReadAll(req.Body)
+	if err := json.Unmarshal(body, &explainReq); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write(util.ErrorJSONMsg(err.Error()))
+		return
+	}
+	if explainReq.FlagKey == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write(util.ErrorJSONMsg("Request must have a 'flagKey' property"))
+		return
+	}
+
+	flags, ok := getFlagsForEvaluationAPI(w, clientCtx.Env, []string{explainReq.FlagKey})
+	if !ok {
+		return
+	}
+	if len(flags.found) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write(util.ErrorJSONMsg("Unknown flag key"))
+		return
+	}
+
+	rep := explainEvaluation(clientCtx.Env, flags.found[0], explainReq.User)
+	data, _ := json.Marshal(rep)
+	_, _ = w.Write(data)
+}
+
+// explainEvaluation retraces the evaluator's steps for one flag. The evaluator itself does not report
+// anything but the result, so we evaluate each prerequisite and each rule separately, with a data
+// provider and big segment provider that record the segment lookups.
+func explainEvaluation(env relayenv.EnvContext, flag *ldmodel.FeatureFlag, user lduser.User) EvaluationExplanationRep {
+	recorder := &explainRecorder{
+		dataProvider:       ldstoreimpl.NewDataStoreEvaluatorDataProvider(env.GetStore(), env.GetLoggers()),
+		bigSegmentProvider: env.GetBigSegmentProvider(),
+	}
+	return explainEvaluationWithRecorder(recorder, flag, user)
+}
+
+func explainEvaluationWithRecorder(
+	recorder *explainRecorder,
+	flag *ldmodel.FeatureFlag,
+	user lduser.User,
+) EvaluationExplanationRep {
+	var options []ldeval.EvaluatorOption
+	if recorder.bigSegmentProvider != nil {
+		options = append(options, ldeval.EvaluatorOptionBigSegmentProvider(recorder))
+	}
+	evaluator := ldeval.NewEvaluatorWithOptions(recorder, options...)
+
+	rep := EvaluationExplanationRep{FlagKey: flag.Key}
+	detail := evaluator.Evaluate(flag, user, nil)
+	recorder.takeLookups() // we only want the lookups done for each step below
+	rep.Result = EvaluationResultRep{
+		Value:     detail.Value,
+		Variation: detail.VariationIndex,
+		Version:   flag.Version,
+		Reason:    detail.Reason,
+	}
+
+	if !flag.On {
+		rep.Steps = append(rep.Steps, ExplainStepRep{Kind: explainStepOff, Matched: true})
+		return rep
+	}
+
+	for _, prereq := range flag.Prerequisites {
+		step := ExplainStepRep{
+			Kind:              explainStepPrerequisite,
+			PrerequisiteKey:   prereq.Key,
+			RequiredVariation: intPtr(prereq.Variation),
+		}
+		if prereqFlag := recorder.GetFeatureFlag(prereq.Key); prereqFlag != nil {
+			prereqDetail := evaluator.Evaluate(prereqFlag, user, nil)
+			step.PrerequisiteResult = &EvaluationResultRep{
+				Value:     prereqDetail.Value,
+				Variation: prereqDetail.VariationIndex,
+				Version:   prereqFlag.Version,
+				Reason:    prereqDetail.Reason,
+			}
+			step.Matched = prereqFlag.On && prereqDetail.VariationIndex == ldvalue.NewOptionalInt(prereq.Variation)
+		}
+		step.SegmentLookups = recorder.takeLookups()
+		rep.Steps = append(rep.Steps, step)
+		if !step.Matched {
+			return rep
+		}
+	}
+
+	for i, target := range flag.Targets {
+		for _, key := range target.Values {
+			if key == user.GetKey() {
+				rep.Steps = append(rep.Steps, ExplainStepRep{
+					Kind:      explainStepTarget,
+					Matched:   true,
+					Index:     intPtr(i),
+					Variation: intPtr(target.Variation),
+				})
+				return rep
+			}
+		}
+	}
+	if len(flag.Targets) > 0 {
+		rep.Steps = append(rep.Steps, ExplainStepRep{Kind: explainStepTarget})
+	}
+
+	for i, rule := range flag.Rules {
+		singleRuleFlag := *flag
+		singleRuleFlag.Prerequisites = nil
+		singleRuleFlag.Targets = nil
+		singleRuleFlag.Rules = []ldmodel.FlagRule{rule}
+		ruleDetail := evaluator.Evaluate(&singleRuleFlag, user, nil)
+		step := ExplainStepRep{
+			Kind:           explainStepRule,
+			Matched:        ruleDetail.Reason.GetKind() == ldreason.EvalReasonRuleMatch,
+			Index:          intPtr(i),
+			RuleID:         rule.ID,
+			SegmentLookups: recorder.takeLookups(),
+		}
+		rep.Steps = append(rep.Steps, step)
+		if step.Matched {
+			return rep
+		}
+	}
+
+	step := ExplainStepRep{Kind: explainStepFallthrough, Matched: true}
+	if v, ok := flag.Fallthrough.Variation.Get(); ok {
+		step.Variation = intPtr(v)
+	}
+	rep.Steps = append(rep.Steps, step)
+	return rep
+}
+
+// explainRecorder wraps the environment's data provider and big segment provider, keeping track of
+// which segments the evaluator looked up. It is only used from one goroutine.
+type explainRecorder struct {
+	dataProvider       ldeval.DataProvider
+	bigSegmentProvider ldeval.BigSegmentProvider
+	lookups            []SegmentLookupRep
+}
+
+type explainRecorderMembership struct {
+	recorder   *explainRecorder
+	membership ldeval.BigSegmentMembership
+	status     ldreason.BigSegmentsStatus
+}
+
+func (r *explainRecorder) GetFeatureFlag(key string) *ldmodel.FeatureFlag {
+	return r.dataProvider.GetFeatureFlag(key)
+}
+
+func (r *explainRecorder) GetSegment(key string) *ldmodel.Segment {
+	segment := r.dataProvider.GetSegment(key)
+	r.lookups = append(r.lookups, SegmentLookupRep{
+		SegmentKey: key,
+		Found:      segment != nil,
+		BigSegment: segment != nil && segment.Unbounded,
+	})
+	return segment
+}
+
+func (r *explainRecorder) GetUserMembership(userKey string) (ldeval.BigSegmentMembership, ldreason.BigSegmentsStatus) {
+	membership, status := r.bigSegmentProvider.GetUserMembership(userKey)
+	return explainRecorderMembership{recorder: r, membership: membership, status: status}, status
+}
+
+func (r *explainRecorder) takeLookups() []SegmentLookupRep {
+	ret := r.lookups
+	r.lookups = nil
+	return ret
+}
+
+func (m explainRecorderMembership) CheckMembership(segmentRef string) ldvalue.OptionalBool {
+	var ret ldvalue.OptionalBool
+	if m.membership != nil {
+		ret = m.membership.CheckMembership(segmentRef)
+	}
+	// The evaluator checks the membership right after getting the segment, so it is the last lookup.
+	if n := len(m.recorder.lookups); n > 0 {
+		if included, ok := ret.Get(); ok {
+			m.recorder.lookups[n-1].Included = &included
+		}
+		m.recorder.lookups[n-1].BigSegmentsStatus = m.status
+	}
+	return ret
+}
+
+func intPtr(n int) *int {
+	return &n
+}
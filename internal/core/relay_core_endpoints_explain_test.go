@@ -0,0 +1,171 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldreason"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/lduser"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	ldeval "gopkg.in/launchdarkly/go-server-sdk-evaluation.v1"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type explainTestDataProvider struct {
+	flags    map[string]*ldmodel.FeatureFlag
+	segments map[string]*ldmodel.Segment
+}
+
+func (p explainTestDataProvider) GetFeatureFlag(key string) *ldmodel.FeatureFlag { return p.flags[key] }
+func (p explainTestDataProvider) GetSegment(key string) *ldmodel.Segment         { return p.segments[key] }
+
+type explainTestBigSegmentProvider map[string]bool
+
+func (p explainTestBigSegmentProvider) GetUserMembership(string) (ldeval.BigSegmentMembership, ldreason.BigSegmentsStatus) {
+	return p, ldreason.BigSegmentsHealthy
+}
+
+func (p explainTestBigSegmentProvider) CheckMembership(segmentRef string) ldvalue.OptionalBool {
+	if included, ok := p[segmentRef]; ok {
+		return ldvalue.NewOptionalBool(included)
+	}
+	return ldvalue.OptionalBool{}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestExplainEvaluation(t *testing.T) {
+	user := lduser.NewUser("user-key")
+	bigSegment := ldbuilders.NewSegmentBuilder("big").Unbounded(true).Generation(1).Build()
+	prereqOn := ldbuilders.NewFlagBuilder("prereq-on").On(true).FallthroughVariation(1).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).Build()
+	prereqOff := ldbuilders.NewFlagBuilder("prereq-off").On(false).OffVariation(0).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).Build()
+	data := explainTestDataProvider{
+		flags:    map[string]*ldmodel.FeatureFlag{prereqOn.Key: &prereqOn, prereqOff.Key: &prereqOff},
+		segments: map[string]*ldmodel.Segment{bigSegment.Key: &bigSegment},
+	}
+	explain := func(flag ldmodel.FeatureFlag) EvaluationExplanationRep {
+		recorder := &explainRecorder{
+			dataProvider:       data,
+			bigSegmentProvider: explainTestBigSegmentProvider{"big.g1": true},
+		}
+		return explainEvaluationWithRecorder(recorder, &flag, user)
+	}
+	variations := []ldvalue.Value{ldvalue.String("a"), ldvalue.String("b"), ldvalue.String("c")}
+
+	t.Run("off", func(t *testing.T) {
+		rep := explain(ldbuilders.NewFlagBuilder("f").Version(3).On(false).OffVariation(2).Variations(variations...).Build())
+		assert.Equal(t, EvaluationResultRep{
+			Value:     ldvalue.String("c"),
+			Variation: ldvalue.NewOptionalInt(2),
+			Version:   3,
+			Reason:    ldreason.NewEvalReasonOff(),
+		}, rep.Result)
+		assert.Equal(t, []ExplainStepRep{{Kind: explainStepOff, Matched: true}}, rep.Steps)
+	})
+
+	t.Run("prerequisite failed", func(t *testing.T) {
+		rep := explain(ldbuilders.NewFlagBuilder("f").On(true).OffVariation(0).Variations(variations...).
+			AddPrerequisite(prereqOn.Key, 1).AddPrerequisite(prereqOff.Key, 1).Build())
+		assert.Equal(t, ldreason.NewEvalReasonPrerequisiteFailed(prereqOff.Key), rep.Result.Reason)
+		require.Len(t, rep.Steps, 2)
+		assert.True(t, rep.Steps[0].Matched)
+		assert.Equal(t, prereqOn.Key, rep.Steps[0].PrerequisiteKey)
+		assert.Equal(t, ldvalue.NewOptionalInt(1), rep.Steps[0].PrerequisiteResult.Variation)
+		assert.False(t, rep.Steps[1].Matched)
+		assert.Equal(t, prereqOff.Key, rep.Steps[1].PrerequisiteKey)
+		assert.Equal(t, intPtr(1), rep.Steps[1].RequiredVariation)
+	})
+
+	t.Run("target", func(t *testing.T) {
+		rep := explain(ldbuilders.NewFlagBuilder("f").On(true).Variations(variations...).
+			AddTarget(0, "someone-else").AddTarget(1, user.GetKey()).FallthroughVariation(2).Build())
+		assert.Equal(t, ldreason.NewEvalReasonTargetMatch(), rep.Result.Reason)
+		assert.Equal(t, []ExplainStepRep{
+			{Kind: explainStepTarget, Matched: true, Index: intPtr(1), Variation: intPtr(1)},
+		}, rep.Steps)
+	})
+
+	t.Run("rule with big segment", func(t *testing.T) {
+		rep := explain(ldbuilders.NewFlagBuilder("f").On(true).Variations(variations...).FallthroughVariation(2).
+			AddTarget(0, "someone-else").
+			AddRule(ldbuilders.NewRuleBuilder().ID("r0").Variation(0).
+				Clauses(ldbuilders.Clause(lduser.KeyAttribute, ldmodel.OperatorIn, ldvalue.String("no")))).
+			AddRule(ldbuilders.NewRuleBuilder().ID("r1").Variation(1).
+				Clauses(ldbuilders.SegmentMatchClause("missing", bigSegment.Key))).
+			Build())
+		assert.Equal(t, ldvalue.String("b"), rep.Result.Value)
+		assert.Equal(t, ldreason.EvalReasonRuleMatch, rep.Result.Reason.GetKind())
+		assert.Equal(t, ldreason.BigSegmentsHealthy, rep.Result.Reason.GetBigSegmentsStatus())
+		assert.Equal(t, []ExplainStepRep{
+			{Kind: explainStepTarget},
+			{Kind: explainStepRule, Index: intPtr(0), RuleID: "r0"},
+			{Kind: explainStepRule, Matched: true, Index: intPtr(1), RuleID: "r1", SegmentLookups: []SegmentLookupRep{
+				{SegmentKey: "missing"},
+				{SegmentKey: bigSegment.Key, Found: true, BigSegment: true, Included: boolPtr(true),
+					BigSegmentsStatus: ldreason.BigSegmentsHealthy},
+			}},
+		}, rep.Steps)
+	})
+
+	t.Run("fallthrough", func(t *testing.T) {
+		rep := explain(ldbuilders.NewFlagBuilder("f").On(true).Variations(variations...).FallthroughVariation(2).
+			AddRule(ldbuilders.NewRuleBuilder().ID("r0").Variation(0).
+				Clauses(ldbuilders.Clause(lduser.KeyAttribute, ldmodel.OperatorIn, ldvalue.String("no")))).
+			Build())
+		assert.Equal(t, ldreason.NewEvalReasonFallthrough(), rep.Result.Reason)
+		assert.Equal(t, []ExplainStepRep{
+			{Kind: explainStepRule, Index: intPtr(0), RuleID: "r0"},
+			{Kind: explainStepFallthrough, Matched: true, Variation: intPtr(2)},
+		}, rep.Steps)
+	})
+}
+
+func TestEvaluationExplainHandler(t *testing.T) {
+	env := makeEnvForEvaluationAPI(t, true)
+	defer env.Close()
+	envID := string(st.EnvClientSide.Config.EnvID)
+
+	call := func(envID, body string) *httptest.ResponseRecorder {
+		headers := make(http.Header)
+		headers.Set("Content-Type", "application/json")
+		req := buildPreRoutedRequest("POST", []byte(body), headers, map[string]string{"envId": envID}, env)
+		w := httptest.NewRecorder()
+		evaluationExplainHandler(w, req)
+		return w
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := call(envID, `{"user":{"key":"me"},"flagKey":"`+st.Flag2ServerSide.Flag.Key+`"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		var rep EvaluationExplanationRep
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rep))
+		assert.Equal(t, st.Flag2ServerSide.Flag.Key, rep.FlagKey)
+		assert.Equal(t, ldvalue.Int(3), rep.Result.Value)
+		assert.Equal(t, []ExplainStepRep{{Kind: explainStepFallthrough, Matched: true, Variation: intPtr(0)}}, rep.Steps)
+	})
+
+	t.Run("unknown flag", func(t *testing.T) {
+		w := call(envID, `{"user":{"key":"me"},"flagKey":"unknown"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("no flag key", func(t *testing.T) {
+		w := call(envID, `{"user":{"key":"me"}}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("wrong environment ID", func(t *testing.T) {
+		w := call(string(st.UndefinedEnvID), `{"user":{"key":"me"},"flagKey":"x"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+	"github.com/launchdarkly/ld-relay/v6/internal/util"
+
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+// FlagsMetadataRep is the JSON representation returned by the flag metadata endpoint.
+//
+// This is exported for use in integration test code.
+type FlagsMetadataRep struct {
+	Flags []FlagMetadataRep `json:"flags"`
+}
+
+// FlagMetadataRep describes one flag in FlagsMetadataRep. Targets and Rules are in the same format as
+// in the SDK data, and are omitted if Config.Main.RedactFlagRules is set.
+//
+// This is exported for use in integration test code.
+type FlagMetadataRep struct {
+	Key           string          `json:"key"`
+	Version       int             `json:"version"`
+	On            bool            `json:"on"`
+	Prerequisites []string        `json:"prerequisites"`
+	BigSegments   []string        `json:"bigSegments"`
+	Targets       json.RawMessage `json:"targets,omitempty"`
+	Rules         json.RawMessage `json:"rules,omitempty"`
+}
+
+// Flag metadata endpoint: /api/flags/{envId} (GET)
+//
+// This is authenticated with the SDK key, and the environment ID in the path must belong to the same
+// environment. It lists every flag, sorted by key, for inventory and drift-detection tools.
+func flagMetadataHandler(redactRules bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		clientCtx := middleware.GetEnvContextInfo(req.Context())
+		if !envIDMatchesEnvironment(req, clientCtx) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		store := clientCtx.Env.GetStore()
+		if !clientCtx.Env.GetClient().Initialized() && !store.IsInitialized() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write(util.ErrorJSONMsg("Service not initialized"))
+			return
+		}
+		items, err := store.GetAll(ldstoreimpl.Features())
+		if err != nil {
+			clientCtx.Env.GetLoggers().Warnf("Unable to fetch flags from feature store. Error: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write(util.ErrorJSONMsgf("Error fetching flags from feature store: %s", err))
+			return
+		}
+
+		resp := FlagsMetadataRep{Flags: make([]FlagMetadataRep, 0, len(items))}
+		for _, item := range items {
+			if flag, ok := item.Item.Item.(*ldmodel.FeatureFlag); ok {
+				resp.Flags = append(resp.Flags, makeFlagMetadataRep(flag, store, redactRules))
+			}
+		}
+		sort.Slice(resp.Flags, func(i, j int) bool { return resp.Flags[i].Key < resp.Flags[j].Key })
+
+		data, _ := json.Marshal(resp)
+		_, _ = w.Write(data)
+	}
+}
+
+func makeFlagMetadataRep(flag *ldmodel.FeatureFlag, store interfaces.DataStore, redactRules bool) FlagMetadataRep {
+	rep := FlagMetadataRep{
+		Key:           flag.Key,
+		Version:       flag.Version,
+		On:            flag.On,
+		Prerequisites: make([]string, 0, len(flag.Prerequisites)),
+		BigSegments:   getBigSegmentsReferencedByFlag(flag, store),
+	}
+	for _, p := range flag.Prerequisites {
+		rep.Prerequisites = append(rep.Prerequisites, p.Key)
+	}
+	if !redactRules {
+		// The rules don't have a JSON representation of their own, so we take them from the whole flag.
+		data, _ := ldmodel.NewJSONDataModelSerialization().MarshalFeatureFlag(*flag)
+		var props struct {
+			Targets json.RawMessage `json:"targets"`
+			Rules   json.RawMessage `json:"rules"`
+		}
+		if json.Unmarshal(data, &props) == nil {
+			rep.Targets, rep.Rules = props.Targets, props.Rules
+		}
+	}
+	return rep
+}
+
+// getBigSegmentsReferencedByFlag returns the keys of the big segments that the flag's rules refer to,
+// sorted and without duplicates. Segments that do not exist in the store are not included.
+func getBigSegmentsReferencedByFlag(flag *ldmodel.FeatureFlag, store interfaces.DataStore) []string {
+	ret := []string{}
+	seen := make(map[string]bool)
+	for _, rule := range flag.Rules {
+		for _, clause := range rule.Clauses {
+			if clause.Op != ldmodel.OperatorSegmentMatch {
+				continue
+			}
+			for _, v := range clause.Values {
+				key := v.StringValue()
+				if key == "" || seen[key] {
+					continue
+				}
+				seen[key] = true
+				item, err := store.Get(ldstoreimpl.Segments(), key)
+				if err != nil {
+					continue
+				}
+				if segment, ok := item.Item.(*ldmodel.Segment); ok && segment.Unbounded {
+					ret = append(ret, key)
+				}
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/lduser"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getFlagMetadata(t *testing.T, redactRules bool, envID string) (*httptest.ResponseRecorder, FlagsMetadataRep) {
+	store := st.MakeStoreWithData(true)
+	bigSegment := ldbuilders.NewSegmentBuilder("big-segment").Version(1).Unbounded(true).Build()
+	smallSegment := ldbuilders.NewSegmentBuilder("small-segment").Version(1).Build()
+	flag := ldbuilders.NewFlagBuilder("flag-with-segments").Version(4).On(true).
+		AddPrerequisite(st.Flag1ServerSide.Flag.Key, 0).
+		AddTarget(0, "user-a").
+		AddRule(ldbuilders.NewRuleBuilder().ID("rule1").Variation(0).Clauses(
+			ldbuilders.SegmentMatchClause(bigSegment.Key, smallSegment.Key, "missing-segment"),
+			ldbuilders.Clause(lduser.KeyAttribute, ldmodel.OperatorIn, ldvalue.String("x")))).
+		AddRule(ldbuilders.NewRuleBuilder().ID("rule2").Variation(0).Clauses(
+			ldbuilders.SegmentMatchClause(bigSegment.Key))).
+		Variations(ldvalue.Bool(true)).Build()
+	_, _ = st.UpsertSegment(store, bigSegment)
+	_, _ = st.UpsertSegment(store, smallSegment)
+	_, _ = st.UpsertFlag(store, flag)
+
+	env := makeEnvForAPIWithStore(t, store, true)
+	defer env.Close()
+
+	req := buildPreRoutedRequest("GET", nil, nil, map[string]string{"envId": envID}, env)
+	w := httptest.NewRecorder()
+	flagMetadataHandler(redactRules)(w, req)
+
+	var resp FlagsMetadataRep
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	}
+	return w, resp
+}
+
+func TestFlagMetadataEndpoint(t *testing.T) {
+	w, resp := getFlagMetadata(t, false, string(st.EnvClientSide.Config.EnvID))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, resp.Flags, len(st.AllFlags)+1)
+	for i := 1; i < len(resp.Flags); i++ {
+		assert.Less(t, resp.Flags[i-1].Key, resp.Flags[i].Key)
+	}
+
+	var found *FlagMetadataRep
+	for i, f := range resp.Flags {
+		if f.Key == "flag-with-segments" {
+			found = &resp.Flags[i]
+		} else {
+			assert.Equal(t, []string{}, f.Prerequisites, f.Key)
+			assert.Equal(t, []string{}, f.BigSegments, f.Key)
+		}
+	}
+	require.NotNil(t, found)
+	assert.Equal(t, 4, found.Version)
+	assert.True(t, found.On)
+	assert.Equal(t, []string{st.Flag1ServerSide.Flag.Key}, found.Prerequisites)
+	assert.Equal(t, []string{"big-segment"}, found.BigSegments)
+	assert.JSONEq(t, `[{"variation":0,"values":["user-a"]}]`, string(found.Targets))
+	assert.Contains(t, string(found.Rules), `"id":"rule1"`)
+}
+
+func TestFlagMetadataEndpointWithRedactedRules(t *testing.T) {
+	w, resp := getFlagMetadata(t, true, string(st.EnvClientSide.Config.EnvID))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"rules"`)
+	assert.NotContains(t, w.Body.String(), `"targets"`)
+	for _, f := range resp.Flags {
+		if f.Key == "flag-with-segments" {
+			assert.Equal(t, []string{"big-segment"}, f.BigSegments)
+		}
+	}
+}
+
+func TestFlagMetadataEndpointReturns404ForWrongEnvironmentID(t *testing.T) {
+	w, _ := getFlagMetadata(t, false, string(st.UndefinedEnvID))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+)
+
+const (
+	healthCheckStarted     = "started"
+	healthCheckUpstream    = "upstream"
+	healthCheckBigSegments = "bigSegments"
+	healthCheckEvents      = "events"
+)
+
+// LivenessRep is the JSON representation returned by the /healthz/live endpoint.
+//
+// This is exported for use in integration test code.
+type LivenessRep struct {
+	Live bool `json:"live"`
+}
+
+// HealthRep is the JSON representation returned by the /healthz/ready endpoint.
+//
+// This is exported for use in integration test code.
+type HealthRep struct {
+	Ready        bool                            `json:"ready"`
+	Maintenance  bool                            `json:"maintenance,omitempty"`
+	Environments map[string]EnvironmentHealthRep `json:"environments"`
+}
+
+// EnvironmentHealthRep is the per-environment JSON representation returned by the /healthz/ready
+// endpoint. Checks contains the result of each check that applies to the environment; checks that are
+// disabled in the configuration, or that do not apply in the current mode, are omitted.
+//
+// This is exported for use in integration test code.
+type EnvironmentHealthRep struct {
+	Ready  bool            `json:"ready"`
+	Checks map[string]bool `json:"checks"`
+}
+
+// livenessHandler always returns 200, as long as Relay is able to handle requests at all. It is meant
+// for a Kubernetes liveness probe, so it deliberately does not depend on anything outside of the
+// process; restarting Relay would not fix a problem with LaunchDarkly or a database.
+func livenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		data, _ := json.Marshal(LivenessRep{Live: true})
+		_, _ = w.Write(data)
+	})
+}
+
+// healthReadinessHandler returns 200 if every environment passes all of the enabled checks, or 503 if
+// not. It is stricter than the /ready endpoint, which only reports whether Relay has finished starting
+// up: this also requires that Relay is still connected to LaunchDarkly (or, in read replica mode, that
+// the data store has been populated), that the big segment store is reachable, and that the most recent
+// delivery of analytics events succeeded. Like the /ready endpoint, it returns 503 in maintenance mode.
+func healthReadinessHandler(core *RelayCore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		core.lock.RLock()
+		fullyConfigured, maintenanceMode := core.fullyConfigured, core.maintenanceMode
+		core.lock.RUnlock()
+
+		resp := HealthRep{Ready: fullyConfigured && !maintenanceMode, Maintenance: maintenanceMode,
+			Environments: make(map[string]EnvironmentHealthRep)}
+		for _, clientCtx := range core.getEnvironmentsForRequest(req) {
+			envHealth := core.checkEnvironmentHealth(clientCtx)
+			if !envHealth.Ready {
+				resp.Ready = false
+			}
+			resp.Environments[core.getEnvStatusName(clientCtx)] = envHealth
+		}
+
+		if !resp.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		data, _ := json.Marshal(resp)
+		_, _ = w.Write(data)
+	})
+}
+
+func (r *RelayCore) checkEnvironmentHealth(clientCtx relayenv.EnvContext) EnvironmentHealthRep {
+	if isEnvDormant(clientCtx) {
+		// None of the checks apply until the environment has been initialized.
+		return EnvironmentHealthRep{Ready: true, Checks: map[string]bool{}}
+	}
+
+	healthConfig := r.config.Health
+	offline := r.config.OfflineMode.FileDataSource != ""
+	readReplica := r.config.ReadReplica.Enabled
+
+	checks := map[string]bool{
+		healthCheckStarted: clientCtx.IsReady(),
+	}
+
+	if !healthConfig.DisableUpstreamCheck && !offline {
+		if readReplica {
+			// Relay does not connect to LaunchDarkly in this mode, so the best we can do is to make sure
+			// that the data store has been populated by another instance.
+			store := clientCtx.GetStore()
+			checks[healthCheckUpstream] = store != nil && store.IsInitialized()
+		} else {
+			client := clientCtx.GetClient()
+			checks[healthCheckUpstream] = client != nil && client.Initialized() &&
+				isDataSourceConnected(client.GetDataSourceStatus(),
+					r.config.Main.DisconnectedStatusTime.GetOrElse(config.DefaultDisconnectedStatusTime))
+		}
+	}
+
+	if bigSegmentStore := clientCtx.GetBigSegmentStore(); bigSegmentStore != nil && !healthConfig.DisableBigSegmentsCheck {
+		_, err := bigSegmentStore.GetSynchronizedOn()
+		checks[healthCheckBigSegments] = err == nil
+	}
+
+	// Events are discarded in offline mode and read replica mode, so there is no forwarder to check.
+	if dispatcher := clientCtx.GetEventDispatcher(); dispatcher != nil && !healthConfig.DisableEventsCheck &&
+		!offline && !readReplica {
+		checks[healthCheckEvents] = dispatcher.IsOperational()
+	}
+
+	ready := true
+	for _, ok := range checks {
+		ready = ready && ok
+	}
+	return EnvironmentHealthRep{Ready: ready, Checks: checks}
+}
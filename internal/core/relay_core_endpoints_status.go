@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"regexp"
+	"sort"
 	"time"
 
 	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
 
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
@@ -17,8 +19,11 @@ import (
 const (
 	statusEnvConnected    = "connected"
 	statusEnvDisconnected = "disconnected"
+	statusEnvDormant      = "dormant"
 	statusRelayHealthy    = "healthy"
 	statusRelayDegraded   = "degraded"
+	statusRoleLeader      = "leader"
+	statusRoleStandby     = "standby"
 )
 
 // StatusRep is the JSON representation returned by the status endpoint.
@@ -29,6 +34,8 @@ type StatusRep struct {
 	Status        string                          `json:"status"`
 	Version       string                          `json:"version"`
 	ClientVersion string                          `json:"clientVersion"`
+	Maintenance   bool                            `json:"maintenance,omitempty"`
+	Role          string                          `json:"role,omitempty"`
 }
 
 // EnvironmentStatusRep is the per-environment JSON representation returned by the status endpoint.
@@ -53,9 +60,11 @@ type EnvironmentStatusRep struct {
 //
 // This is exported for use in integration test code.
 type BigSegmentStatusRep struct {
+	Status             string                     `json:"status"`
 	Available          bool                       `json:"available"`
 	PotentiallyStale   bool                       `json:"potentiallyStale"`
 	LastSynchronizedOn ldtime.UnixMillisecondTime `json:"lastSynchronizedOn"`
+	StalenessMillis    *int64                     `json:"stalenessMillis,omitempty"`
 }
 
 // ConnectionStatusRep is the data source status representation returned by the status endpoint.
@@ -114,10 +123,18 @@ func statusHandler(core *RelayCore) http.Handler {
 
 		core.lock.Lock()
 		fullyConfigured := core.fullyConfigured
+		resp.Maintenance = core.maintenanceMode
 		core.lock.Unlock()
 
+		if core.leaderElector != nil {
+			resp.Role = statusRoleStandby
+			if core.IsLeader() {
+				resp.Role = statusRoleLeader
+			}
+		}
+
 		healthy := fullyConfigured
-		for _, clientCtx := range core.GetAllEnvironments() {
+		for _, clientCtx := range core.getEnvironmentsForRequest(req) {
 			identifiers := clientCtx.GetIdentifiers()
 
 			status := EnvironmentStatusRep{
@@ -145,7 +162,14 @@ func statusHandler(core *RelayCore) http.Handler {
 			}
 
 			client := clientCtx.GetClient()
-			if client == nil {
+			if isEnvDormant(clientCtx) {
+				// With MainConfig.LazyEnvironmentInit, an environment that has not had any requests yet is
+				// not connected to LaunchDarkly, but there is nothing wrong with it.
+				status.Status = statusEnvDormant
+				status.ConnectionStatus.State = interfaces.DataSourceStateInitializing
+				status.ConnectionStatus.StateSince = ldtime.UnixMillisFromTime(clientCtx.GetCreationTime())
+				status.DataStoreStatus.State = "INITIALIZING"
+			} else if client == nil {
 				status.Status = statusEnvDisconnected
 				status.ConnectionStatus.State = interfaces.DataSourceStateInitializing
 				status.ConnectionStatus.StateSince = ldtime.UnixMillisFromTime(clientCtx.GetCreationTime())
@@ -165,9 +189,8 @@ func statusHandler(core *RelayCore) http.Handler {
 						Time: ldtime.UnixMillisFromTime(sourceStatus.LastError.Time),
 					}
 				}
-				if sourceStatus.State != interfaces.DataSourceStateValid &&
-					time.Since(sourceStatus.StateSince) >=
-						core.config.Main.DisconnectedStatusTime.GetOrElse(config.DefaultDisconnectedStatusTime) {
+				if !isDataSourceConnected(sourceStatus,
+					core.config.Main.DisconnectedStatusTime.GetOrElse(config.DefaultDisconnectedStatusTime)) {
 					connected = false
 				}
 
@@ -188,21 +211,10 @@ func statusHandler(core *RelayCore) http.Handler {
 
 			bigSegmentStore := clientCtx.GetBigSegmentStore()
 			if bigSegmentStore != nil {
-				bigSegmentStatus := BigSegmentStatusRep{}
-				synchronizedOn, err := bigSegmentStore.GetSynchronizedOn()
-				if err != nil {
-					bigSegmentStatus.Available = false
-				} else {
-					bigSegmentStatus.Available = true
-					bigSegmentStatus.LastSynchronizedOn = synchronizedOn
-					now := ldtime.UnixMillisNow()
-					stalenessThreshold := core.config.Main.BigSegmentsStaleThreshold.GetOrElse(config.DefaultBigSegmentsStaleThreshold)
-					if !synchronizedOn.IsDefined() || now > (synchronizedOn+ldtime.UnixMillisecondTime(stalenessThreshold.Milliseconds())) {
-						bigSegmentStatus.PotentiallyStale = true
-						if core.config.Main.BigSegmentsStaleAsDegraded {
-							healthy = false
-						}
-					}
+				bigSegmentStatus := makeBigSegmentStatusRep(bigSegmentStore, clientCtx.GetBigSegmentsStaleThreshold(),
+					ldtime.UnixMillisNow())
+				if bigSegmentStatus.PotentiallyStale && core.config.Main.BigSegmentsStaleAsDegraded {
+					healthy = false
 				}
 				status.BigSegmentStatus = &bigSegmentStatus
 			}
@@ -233,3 +245,99 @@ func statusHandler(core *RelayCore) http.Handler {
 		_, _ = w.Write(data)
 	})
 }
+
+// ReadinessRep is the JSON representation returned by the readiness endpoint.
+//
+// This is exported for use in integration test code.
+type ReadinessRep struct {
+	Ready                bool     `json:"ready"`
+	Maintenance          bool     `json:"maintenance,omitempty"`
+	EnvironmentsNotReady []string `json:"environmentsNotReady,omitempty"`
+}
+
+// readinessHandler returns 200 if every environment is ready to serve flag data, or 503 if not. Unlike
+// the status endpoint, it does not consider whether Relay is still connected to LaunchDarkly, since an
+// instance that has lost its connection can still serve the last known data; it is meant for a
+// Kubernetes readiness probe or a load balancer, to keep new instances from receiving traffic until
+// they have finished starting up. It also returns 503 in maintenance mode, so that the load balancer
+// stops sending traffic to this instance.
+func readinessHandler(core *RelayCore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		core.lock.Lock()
+		fullyConfigured, maintenanceMode := core.fullyConfigured, core.maintenanceMode
+		core.lock.Unlock()
+
+		resp := ReadinessRep{Ready: fullyConfigured && !maintenanceMode, Maintenance: maintenanceMode}
+		for _, clientCtx := range core.getEnvironmentsForRequest(req) {
+			if !clientCtx.IsReady() && !isEnvDormant(clientCtx) {
+				resp.Ready = false
+				resp.EnvironmentsNotReady = append(resp.EnvironmentsNotReady, core.getEnvStatusName(clientCtx))
+			}
+		}
+		sort.Strings(resp.EnvironmentsNotReady)
+
+		if !resp.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		data, _ := json.Marshal(resp)
+		_, _ = w.Write(data)
+	})
+}
+
+// getEnvStatusName returns the name that identifies an environment in the readiness and health
+// endpoints, which is the same as its key in the status endpoint.
+func (r *RelayCore) getEnvStatusName(clientCtx relayenv.EnvContext) string {
+	if r.envLogNameMode == relayenv.LogNameIsEnvID {
+		for _, c := range clientCtx.GetCredentials() {
+			if envID, ok := c.(config.EnvironmentID); ok {
+				return string(envID)
+			}
+		}
+	}
+	return clientCtx.GetIdentifiers().GetDisplayName()
+}
+
+// isEnvDormant returns true if the environment is waiting for its first request before initializing,
+// because MainConfig.LazyEnvironmentInit is enabled.
+func isEnvDormant(clientCtx relayenv.EnvContext) bool {
+	lazyEnv, ok := clientCtx.(*lazyEnvContext)
+	return ok && lazyEnv.isDormant()
+}
+
+// isDataSourceConnected returns false if the data source has been in a state other than VALID for at
+// least disconnectedStatusTime; shorter interruptions are not reported, since the SDK will retry.
+func isDataSourceConnected(sourceStatus interfaces.DataSourceStatus, disconnectedStatusTime time.Duration) bool {
+	return sourceStatus.State == interfaces.DataSourceStateValid ||
+		time.Since(sourceStatus.StateSince) < disconnectedStatusTime
+}
+
+func makeBigSegmentStatusRep(
+	store bigsegments.BigSegmentStore,
+	stalenessThreshold time.Duration,
+	now ldtime.UnixMillisecondTime,
+) BigSegmentStatusRep {
+	synchronizedOn, err := store.GetSynchronizedOn()
+	if err != nil {
+		return BigSegmentStatusRep{Status: statusEnvDisconnected}
+	}
+	rep := BigSegmentStatusRep{
+		Status:             statusEnvConnected,
+		Available:          true,
+		LastSynchronizedOn: synchronizedOn,
+	}
+	if !synchronizedOn.IsDefined() {
+		rep.PotentiallyStale = true
+		return rep
+	}
+	var staleness int64
+	if now > synchronizedOn {
+		staleness = int64(now - synchronizedOn)
+	}
+	rep.StalenessMillis = &staleness
+	if staleness > stalenessThreshold.Milliseconds() {
+		rep.PotentiallyStale = true
+	}
+	return rep
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBigSegmentStoreForStatus struct {
+	bigsegments.BigSegmentStore
+	synchronizedOn ldtime.UnixMillisecondTime
+	err            error
+}
+
+func (s fakeBigSegmentStoreForStatus) GetSynchronizedOn() (ldtime.UnixMillisecondTime, error) {
+	return s.synchronizedOn, s.err
+}
+
+func TestBigSegmentStatusRep(t *testing.T) {
+	threshold := time.Minute
+	now := ldtime.UnixMillisecondTime(1000000)
+
+	t.Run("store error", func(t *testing.T) {
+		rep := makeBigSegmentStatusRep(fakeBigSegmentStoreForStatus{err: errors.New("sorry")}, threshold, now)
+		assert.Equal(t, BigSegmentStatusRep{Status: statusEnvDisconnected}, rep)
+	})
+
+	t.Run("never synchronized", func(t *testing.T) {
+		rep := makeBigSegmentStatusRep(fakeBigSegmentStoreForStatus{}, threshold, now)
+		assert.Equal(t, BigSegmentStatusRep{Status: statusEnvConnected, Available: true, PotentiallyStale: true}, rep)
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		synchronizedOn := now - 1000
+		rep := makeBigSegmentStatusRep(fakeBigSegmentStoreForStatus{synchronizedOn: synchronizedOn}, threshold, now)
+		assert.Equal(t, statusEnvConnected, rep.Status)
+		assert.True(t, rep.Available)
+		assert.False(t, rep.PotentiallyStale)
+		assert.Equal(t, synchronizedOn, rep.LastSynchronizedOn)
+		require.NotNil(t, rep.StalenessMillis)
+		assert.Equal(t, int64(1000), *rep.StalenessMillis)
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		synchronizedOn := now - ldtime.UnixMillisecondTime(threshold.Milliseconds()) - 1
+		rep := makeBigSegmentStatusRep(fakeBigSegmentStoreForStatus{synchronizedOn: synchronizedOn}, threshold, now)
+		assert.Equal(t, statusEnvConnected, rep.Status)
+		assert.True(t, rep.PotentiallyStale)
+		require.NotNil(t, rep.StalenessMillis)
+		assert.Equal(t, threshold.Milliseconds()+1, *rep.StalenessMillis)
+	})
+}
+
+func TestReadinessEndpointWhenEnvironmentIsNotReady(t *testing.T) {
+	config := c.Config{Environment: st.MakeEnvConfigs(st.EnvMain, st.EnvMobile)}
+	core, err := NewRelayCore(config, ldlog.NewDisabledLoggers(), testclient.FakeLDClientFactory(false), "", "", false)
+	require.NoError(t, err)
+	defer core.Close()
+	require.NoError(t, core.WaitForAllClients(time.Second))
+
+	req, _ := http.NewRequest("GET", "http://localhost/ready", nil)
+	result, body := st.DoRequest(req, core.MakeRouter())
+	assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+	assert.JSONEq(t, `{"ready": false, "environmentsNotReady": ["`+st.EnvMobile.Name+`", "`+st.EnvMain.Name+`"]}`,
+		string(body))
+}
+
+func TestHealthReadinessEndpointWhenEnvironmentIsNotConnected(t *testing.T) {
+	config := c.Config{Environment: st.MakeEnvConfigs(st.EnvMain)}
+	core, err := NewRelayCore(config, ldlog.NewDisabledLoggers(), testclient.FakeLDClientFactory(false), "", "", false)
+	require.NoError(t, err)
+	defer core.Close()
+	require.NoError(t, core.WaitForAllClients(time.Second))
+
+	req, _ := http.NewRequest("GET", "http://localhost/healthz/ready", nil)
+	result, body := st.DoRequest(req, core.MakeRouter())
+	assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+	assert.JSONEq(t, `{"ready": false, "environments": {"`+st.EnvMain.Name+
+		`": {"ready": false, "checks": {"started": false, "upstream": false}}}}`, string(body))
+
+	// the liveness endpoint doesn't depend on the environments
+	req, _ = http.NewRequest("GET", "http://localhost/healthz/live", nil)
+	result, _ = st.DoRequest(req, core.MakeRouter())
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+}
+
+func TestHealthReadinessEndpointOmitsDisabledChecks(t *testing.T) {
+	config := c.Config{Environment: st.MakeEnvConfigs(st.EnvMain)}
+	config.Health.DisableUpstreamCheck = true
+	core, err := NewRelayCore(config, ldlog.NewDisabledLoggers(), testclient.FakeLDClientFactory(true), "", "", false)
+	require.NoError(t, err)
+	defer core.Close()
+	require.NoError(t, core.WaitForAllClients(time.Second))
+
+	req, _ := http.NewRequest("GET", "http://localhost/healthz/ready", nil)
+	result, body := st.DoRequest(req, core.MakeRouter())
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.JSONEq(t, `{"ready": true, "environments": {"`+st.EnvMain.Name+
+		`": {"ready": true, "checks": {"started": true}}}}`, string(body))
+}
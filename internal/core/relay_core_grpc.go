@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"net"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+//nolint:gochecknoglobals
+var (
+	errGRPCEnvNotFound       = status.Error(codes.Unauthenticated, "SDK key is missing or not recognized")
+	errGRPCMaintenance       = status.Error(codes.Unavailable, "Relay Proxy is in maintenance mode")
+	errGRPCRateLimited       = status.Error(codes.ResourceExhausted, "Relay Proxy rate limit exceeded for this client credential")
+	errGRPCMemoryShedding    = status.Error(codes.Unavailable, "Relay Proxy is not accepting new stream connections because its memory use is too high")
+	errGRPCEnvStreamLimit    = status.Error(codes.ResourceExhausted, "Relay Proxy has too many stream connections for this environment")
+	errGRPCGlobalStreamLimit = status.Error(codes.ResourceExhausted, "Relay Proxy has too many stream connections")
+)
+
+// admitGRPCStream is the streams.GRPCAdmission for the gRPC stream service. It applies the same limits
+// to gRPC streams that the streamLimits middleware applies to the server-side HTTP streams, including
+// memory-based load shedding, and the rate limits that the RateLimit middleware applies to all SDK
+// requests; it also rejects them in maintenance mode as the Maintenance middleware does.
+func (r *RelayCore) admitGRPCStream(ctx context.Context, sdkKey config.SDKKey) (func(), error) {
+	if r.IsInMaintenanceMode() {
+		return nil, errGRPCMaintenance
+	}
+	env, _ := r.GetEnvironment(sdkKey)
+	if env == nil {
+		return nil, errGRPCEnvNotFound // the environment was removed after the stream provider found it
+	}
+	measure := metrics.ServerRequests
+
+	if limiter := env.GetRateLimiter(); limiter != nil {
+		if decision := limiter.Allow(sdkKey, grpcClientIP(ctx)); !decision.Allowed {
+			metrics.RecordThrottledRequest(env.GetMetricsContext(), measure, decision.ByClientIP)
+			return nil, errGRPCRateLimited
+		}
+	}
+
+	if r.IsSheddingLoad() {
+		metrics.RecordMemoryRejectedStream(env.GetMetricsContext(), measure)
+		return nil, errGRPCMemoryShedding
+	}
+
+	envLimiter := env.GetStreamLimiter()
+	allowed, queued := envLimiter.Acquire(ctx)
+	if queued {
+		metrics.RecordQueuedStream(env.GetMetricsContext(), measure, false)
+	}
+	if !allowed {
+		metrics.RecordRejectedStream(env.GetMetricsContext(), measure, false)
+		return nil, errGRPCEnvStreamLimit
+	}
+	allowed, queued = r.streamLimiter.Acquire(ctx)
+	if queued {
+		metrics.RecordQueuedStream(env.GetMetricsContext(), measure, true)
+	}
+	if !allowed {
+		envLimiter.Release()
+		metrics.RecordRejectedStream(env.GetMetricsContext(), measure, true)
+		return nil, errGRPCGlobalStreamLimit
+	}
+	return func() {
+		r.streamLimiter.Release()
+		envLimiter.Release()
+	}, nil
+}
+
+// grpcClientIP returns the address that a gRPC call came from. Unlike an HTTP request, it has no
+// X-Forwarded-For header, so this is always the address of the immediate peer.
+func grpcClientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/streams/streampb"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withGRPCCore(t *testing.T, config c.Config, action func(*RelayCore, func() (context.CancelFunc, error))) {
+	config.Main.GRPCPort = mustOptIntGreaterThanZero(8032)
+	core, err := makeBasicCore(config)
+	require.NoError(t, err)
+	defer core.Close()
+	require.NoError(t, core.WaitForAllClients(time.Second))
+	env, _ := core.GetEnvironment(st.EnvMain.Config.SDKKey)
+	require.NotNil(t, env)
+	require.NoError(t, env.GetStore().Init([]ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features()}, {Kind: ldstoreimpl.Segments()},
+	}))
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	core.RegisterGRPCServices(server)
+	go server.Serve(listener) //nolint:errcheck
+	defer server.Stop()
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := streampb.NewFlagStreamClient(conn)
+
+	// subscribe returns once the stream has received its first event, or else with the error that ended
+	// it; the returned function ends the stream
+	subscribe := func() (context.CancelFunc, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		t.Cleanup(cancel)
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", string(st.EnvMain.Config.SDKKey))
+		stream, err := client.Subscribe(ctx)
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		return cancel, err
+	}
+	action(core, subscribe)
+}
+
+func TestGRPCStreamsAreSubjectToStreamLimits(t *testing.T) {
+	config := c.Config{
+		StreamLimits: c.StreamLimitsConfig{MaxConnections: mustOptIntGreaterThanZero(1)},
+		Environment:  st.MakeEnvConfigs(st.EnvMain),
+	}
+	withGRPCCore(t, config, func(core *RelayCore, subscribe func() (context.CancelFunc, error)) {
+		endStream, err := subscribe()
+		require.NoError(t, err)
+
+		_, err = subscribe()
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		endStream()
+		assert.Eventually(t, func() bool {
+			endStream, err := subscribe()
+			endStream()
+			return err == nil
+		}, time.Second, time.Millisecond*10)
+	})
+}
+
+func TestGRPCStreamsAreRejectedWhileSheddingLoad(t *testing.T) {
+	config := c.Config{
+		MemoryLimits: c.MemoryLimitsConfig{
+			HighWaterMarkMB: mustOptIntGreaterThanZero(1), // any running process is using more than 1MB
+			CheckInterval:   ct.NewOptDuration(time.Millisecond * 10),
+		},
+		Environment: st.MakeEnvConfigs(st.EnvMain),
+	}
+	withGRPCCore(t, config, func(core *RelayCore, subscribe func() (context.CancelFunc, error)) {
+		require.Eventually(t, core.IsSheddingLoad, time.Second*5, time.Millisecond*10)
+		_, err := subscribe()
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+func TestGRPCStreamsAreRejectedInMaintenanceMode(t *testing.T) {
+	config := c.Config{Environment: st.MakeEnvConfigs(st.EnvMain)}
+	withGRPCCore(t, config, func(core *RelayCore, subscribe func() (context.CancelFunc, error)) {
+		core.SetMaintenanceMode(true)
+		_, err := subscribe()
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+
+		core.SetMaintenanceMode(false)
+		endStream, err := subscribe()
+		assert.NoError(t, err)
+		endStream()
+	})
+}
+
+func TestGRPCStreamsAreSubjectToRateLimits(t *testing.T) {
+	config := c.Config{
+		RateLimit:   c.RateLimitConfig{ServerSide: ct.NewOptFloat64(0.001)}, // allows one request at first
+		Environment: st.MakeEnvConfigs(st.EnvMain),
+	}
+	withGRPCCore(t, config, func(core *RelayCore, subscribe func() (context.CancelFunc, error)) {
+		endStream, err := subscribe()
+		require.NoError(t, err)
+		defer endStream()
+
+		_, err = subscribe()
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+}
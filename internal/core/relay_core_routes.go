@@ -1,14 +1,16 @@
 package core
 
 import (
+	"fmt"
 	"net/http"
 
+	"github.com/launchdarkly/ld-relay/v6/config"
 	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/logging"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/streams"
 
-	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
 
 	"github.com/gorilla/mux"
@@ -28,16 +30,79 @@ const (
 // docs/endpoints.md.
 func (r *RelayCore) MakeRouter() *mux.Router {
 	router := mux.NewRouter()
+	if r.accessLog != nil {
+		// Middleware added with Use only runs for requests that match a route, so requests that don't
+		// match one need their own handlers in order to be logged
+		router.Use(r.accessLog.Middleware)
+		router.NotFoundHandler = r.accessLog.Middleware(http.NotFoundHandler())
+		router.MethodNotAllowedHandler = r.accessLog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}))
+	}
 	router.Use(logging.GlobalContextLoggersMiddleware(r.Loggers))
-	if r.Loggers.GetMinLevel() == ldlog.Debug {
-		router.Use(logging.RequestLoggerMiddleware(r.Loggers))
+	if r.httpLoggers.IsDebugEnabled() {
+		router.Use(logging.RequestLoggerMiddleware(r.httpLoggers))
 	}
-	router.Handle("/status", statusHandler(r)).Methods("GET")
+	router.Use(middleware.SelectTenantByHost(r.tenantsByHost))
+	router.Use(middleware.AddResponseHeaders(makeGlobalResponseHeaders(r.config.ResponseHeaders)))
+	router.Use(middleware.ConnTimeouts(r.config.Main.ReadTimeout.GetOrElse(0), r.config.Main.WriteTimeout.GetOrElse(0)))
+	// In maintenance mode, every other request is rejected, but these still work so that load balancers and
+	// monitoring can see the state of this instance.
+	router.Use(middleware.Maintenance(r.IsInMaintenanceMode, r.config.Maintenance,
+		router.Handle("/status", statusHandler(r)).Methods("GET"),
+		router.Handle("/ready", readinessHandler(r)).Methods("GET"),
+		router.Handle("/healthz/live", livenessHandler()).Methods("GET"),
+		router.Handle("/healthz/ready", healthReadinessHandler(r)).Methods("GET"),
+	))
 
 	sdkKeySelector := middleware.SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, r)
 	mobileKeySelector := middleware.SelectEnvironmentByAuthorizationKey(basictypes.MobileSDK, r)
 	jsClientSelector := middleware.SelectEnvironmentByAuthorizationKey(basictypes.JSClientSDK, r)
-	offlineMode := r.config.OfflineMode.FileDataSource != ""
+	// In offline mode and read replica mode, Relay does not connect to LaunchDarkly, so events are discarded.
+	// With leader election, only the leader forwards events, and the standby instances discard them.
+	offlineOrReplica := r.config.OfflineMode.FileDataSource != "" || r.config.ReadReplica.Enabled
+	discardEvents := func() bool {
+		return offlineOrReplica || !r.IsLeader()
+	}
+
+	// Evaluation requests are copied to the shadow Relay instance, if traffic shadowing is enabled.
+	shadowed := func(handler http.HandlerFunc) http.Handler {
+		if r.shadower == nil {
+			return handler
+		}
+		return r.shadower.Middleware(handler)
+	}
+
+	// Polling and all-flags evaluation responses are compressed, if enabled, since they can be large.
+	pollingHeaders, _ := config.ParseResponseHeaders(r.config.ResponseHeaders.PollingHeader) // config.ValidateConfig has ensured that these are valid
+	polling := func(handler http.Handler) http.Handler {
+		if r.config.Main.CompressionEnabled {
+			handler = middleware.Compress(r.config.Main.CompressionMinSize.GetOrElse(config.DefaultCompressionMinSize))(handler)
+		}
+		return middleware.OverrideResponseHeaders(pollingHeaders)(handler)
+	}
+	streamingHeaders, _ := config.ParseResponseHeaders(r.config.ResponseHeaders.StreamingHeader)
+	streaming := middleware.Chain(
+		middleware.Streaming,
+		middleware.OverrideResponseHeaders(streamingHeaders),
+		// the main listener's regular heartbeats are sent by each environment, at HeartbeatInterval
+		middleware.StreamTuning(0, r.config.Main.SSERetryDelay.GetOrElse(0)),
+	)
+	// Stream connections are rejected while memory use is too high, and are subject to the environment's
+	// quota, if any, and to the connection limits.
+	streamRetryAfter := r.config.StreamLimits.RetryAfter.GetOrElse(config.DefaultStreamLimitRetryAfter)
+	streamLimits := func(measure metrics.Measure) mux.MiddlewareFunc {
+		return middleware.Chain(
+			middleware.MemoryShedding(r.IsSheddingLoad, streamRetryAfter, measure),
+			middleware.StreamQuota,
+			middleware.StreamLimits(r.streamLimiter, streamRetryAfter, measure),
+		)
+	}
+	eventsHeaders, _ := config.ParseResponseHeaders(r.config.ResponseHeaders.EventsHeader)
+	events := middleware.Chain(
+		middleware.OverrideResponseHeaders(eventsHeaders),
+		middleware.LimitRequestBody(r.config.Main.MaxEventBodySize.GetOrElse(config.DefaultMaxEventBodySize)),
+	)
 
 	// Client-side evaluation (for JS, not mobile)
 	jsClientSideMiddlewareStack := func(subrouter *mux.Router) mux.MiddlewareFunc {
@@ -45,6 +110,8 @@ func (r *RelayCore) MakeRouter() *mux.Router {
 			mux.CORSMethodMiddleware(subrouter),
 			jsClientSelector, // selects an environment based on the client-side ID in the URL
 			middleware.CORS,  // must apply this after jsClientSelector because the CORS headers can be environment-specific
+			// rate limiting is applied after CORS so that a browser can still read a 429 response
+			middleware.RateLimit(metrics.BrowserRequests),
 			middleware.RequestCount(metrics.BrowserRequests),
 		)
 	}
@@ -55,16 +122,17 @@ func (r *RelayCore) MakeRouter() *mux.Router {
 
 	clientSideSdkEvalRouter := router.PathPrefix("/sdk/eval/{envId}/").Subrouter()
 	clientSideSdkEvalRouter.Use(jsClientSideMiddlewareStack(clientSideSdkEvalRouter))
-	clientSideSdkEvalRouter.HandleFunc("/users/{user}", evaluateAllFeatureFlagsValueOnly(basictypes.JSClientSDK)).Methods("GET", "OPTIONS")
-	clientSideSdkEvalRouter.HandleFunc("/user", evaluateAllFeatureFlagsValueOnly(basictypes.JSClientSDK)).Methods("REPORT", "OPTIONS")
+	clientSideSdkEvalRouter.Handle("/users/{user}", polling(shadowed(evaluateAllFeatureFlagsValueOnly(basictypes.JSClientSDK)))).Methods("GET", "OPTIONS")
+	clientSideSdkEvalRouter.Handle("/user", polling(shadowed(evaluateAllFeatureFlagsValueOnly(basictypes.JSClientSDK)))).Methods("REPORT", "OPTIONS")
 
 	clientSideSdkEvalXRouter := router.PathPrefix("/sdk/evalx/{envId}/").Subrouter()
 	clientSideSdkEvalXRouter.Use(jsClientSideMiddlewareStack(clientSideSdkEvalXRouter))
-	clientSideSdkEvalXRouter.HandleFunc("/users/{user}", evaluateAllFeatureFlags(basictypes.JSClientSDK)).Methods("GET", "OPTIONS")
-	clientSideSdkEvalXRouter.HandleFunc("/user", evaluateAllFeatureFlags(basictypes.JSClientSDK)).Methods("REPORT", "OPTIONS")
+	clientSideSdkEvalXRouter.Handle("/users/{user}", polling(shadowed(evaluateAllFeatureFlags(basictypes.JSClientSDK)))).Methods("GET", "OPTIONS")
+	clientSideSdkEvalXRouter.Handle("/user", polling(shadowed(evaluateAllFeatureFlags(basictypes.JSClientSDK)))).Methods("REPORT", "OPTIONS")
 
 	serverSideMiddlewareStack := middleware.Chain(
 		sdkKeySelector,
+		middleware.RateLimit(metrics.ServerRequests),
 		middleware.RequestCount(metrics.ServerRequests))
 
 	serverSideSdkRouter := router.PathPrefix("/sdk/").Subrouter()
@@ -73,85 +141,126 @@ func (r *RelayCore) MakeRouter() *mux.Router {
 	// serverSideSdkRouter.Use(serverSideMiddlewareStack)
 
 	serverSideEvalRouter := serverSideSdkRouter.PathPrefix("/eval/").Subrouter()
-	serverSideEvalRouter.Handle("/users/{user}", serverSideMiddlewareStack(http.HandlerFunc(evaluateAllFeatureFlagsValueOnly(basictypes.ServerSDK)))).Methods("GET")
-	serverSideEvalRouter.Handle("/user", serverSideMiddlewareStack(http.HandlerFunc(evaluateAllFeatureFlagsValueOnly(basictypes.ServerSDK)))).Methods("REPORT")
+	serverSideEvalRouter.Handle("/users/{user}", serverSideMiddlewareStack(polling(shadowed(evaluateAllFeatureFlagsValueOnly(basictypes.ServerSDK))))).Methods("GET")
+	serverSideEvalRouter.Handle("/user", serverSideMiddlewareStack(polling(shadowed(evaluateAllFeatureFlagsValueOnly(basictypes.ServerSDK))))).Methods("REPORT")
 
 	serverSideEvalXRouter := serverSideSdkRouter.PathPrefix("/evalx/").Subrouter()
-	serverSideEvalXRouter.Handle("/users/{user}", serverSideMiddlewareStack(http.HandlerFunc(evaluateAllFeatureFlags(basictypes.ServerSDK)))).Methods("GET")
-	serverSideEvalXRouter.Handle("/user", serverSideMiddlewareStack(http.HandlerFunc(evaluateAllFeatureFlags(basictypes.ServerSDK)))).Methods("REPORT")
+	serverSideEvalXRouter.Handle("/users/{user}", serverSideMiddlewareStack(polling(shadowed(evaluateAllFeatureFlags(basictypes.ServerSDK))))).Methods("GET")
+	serverSideEvalXRouter.Handle("/user", serverSideMiddlewareStack(polling(shadowed(evaluateAllFeatureFlags(basictypes.ServerSDK))))).Methods("REPORT")
 
 	// PHP SDK endpoints
-	serverSideSdkRouter.Handle("/flags", serverSideMiddlewareStack(http.HandlerFunc(pollAllFlagsHandler))).Methods("GET")
-	serverSideSdkRouter.Handle("/flags/{key}", serverSideMiddlewareStack(http.HandlerFunc(pollFlagHandler))).Methods("GET")
-	serverSideSdkRouter.Handle("/segments/{key}", serverSideMiddlewareStack(http.HandlerFunc(pollSegmentHandler))).Methods("GET")
+	serverSideSdkRouter.Handle("/flags", serverSideMiddlewareStack(polling(http.HandlerFunc(pollAllFlagsHandler)))).Methods("GET")
+	serverSideSdkRouter.Handle("/flags/{key}", serverSideMiddlewareStack(polling(http.HandlerFunc(pollFlagHandler)))).Methods("GET")
+	serverSideSdkRouter.Handle("/segments/{key}", serverSideMiddlewareStack(polling(http.HandlerFunc(pollSegmentHandler)))).Methods("GET")
+
+	// Big segments diagnostics
+	router.Handle("/bigsegments/status/{envId}", serverSideMiddlewareStack(http.HandlerFunc(bigSegmentsStatusHandler))).Methods("GET")
+
+	// Evaluation API for services that do not use an SDK
+	router.Handle("/api/eval/{envId}", serverSideMiddlewareStack(shadowed(evaluationAPIHandler))).Methods("POST")
+	router.Handle("/api/eval/{envId}/bulk", serverSideMiddlewareStack(http.HandlerFunc(bulkEvaluationAPIHandler))).Methods("POST")
+	router.Handle("/api/eval/{envId}/explain", serverSideMiddlewareStack(http.HandlerFunc(evaluationExplainHandler))).Methods("POST")
+	router.Handle("/api/eval/{envId}/allflags", serverSideMiddlewareStack(http.HandlerFunc(allFlagsStateHandler))).Methods("POST")
+
+	// Flag metadata for inventory tools
+	router.Handle("/api/flags/{envId}", serverSideMiddlewareStack(flagMetadataHandler(r.config.Main.RedactFlagRules))).Methods("GET")
+
+	// Offline mode archive export
+	router.Handle("/archive", serverSideMiddlewareStack(http.HandlerFunc(archiveHandler))).Methods("GET")
+
+	// Change feed
+	router.Handle("/changes/{envId}", streaming(changeFeedHandler(r, streamLimits(metrics.ServerRequests)))).Methods("GET")
 
 	// Mobile evaluation
 	mobileMiddlewareStack := middleware.Chain(
 		mobileKeySelector,
+		middleware.RateLimit(metrics.MobileRequests),
 		middleware.RequestCount(metrics.MobileRequests))
 
 	msdkRouter := router.PathPrefix("/msdk/").Subrouter()
 	msdkRouter.Use(mobileMiddlewareStack)
 
 	msdkEvalRouter := msdkRouter.PathPrefix("/eval/").Subrouter()
-	msdkEvalRouter.HandleFunc("/users/{user}", evaluateAllFeatureFlagsValueOnly(basictypes.MobileSDK)).Methods("GET")
-	msdkEvalRouter.HandleFunc("/user", evaluateAllFeatureFlagsValueOnly(basictypes.MobileSDK)).Methods("REPORT")
+	msdkEvalRouter.Handle("/users/{user}", polling(shadowed(evaluateAllFeatureFlagsValueOnly(basictypes.MobileSDK)))).Methods("GET")
+	msdkEvalRouter.Handle("/user", polling(shadowed(evaluateAllFeatureFlagsValueOnly(basictypes.MobileSDK)))).Methods("REPORT")
 
 	msdkEvalXRouter := msdkRouter.PathPrefix("/evalx/").Subrouter()
-	msdkEvalXRouter.HandleFunc("/users/{user}", evaluateAllFeatureFlags(basictypes.MobileSDK)).Methods("GET")
-	msdkEvalXRouter.HandleFunc("/user", evaluateAllFeatureFlags(basictypes.MobileSDK)).Methods("REPORT")
+	msdkEvalXRouter.Handle("/users/{user}", polling(shadowed(evaluateAllFeatureFlags(basictypes.MobileSDK)))).Methods("GET")
+	msdkEvalXRouter.Handle("/user", polling(shadowed(evaluateAllFeatureFlags(basictypes.MobileSDK)))).Methods("REPORT")
 
 	mobileStreamRouter := router.PathPrefix("/meval").Subrouter()
-	mobileStreamRouter.Use(mobileMiddlewareStack, middleware.Streaming)
+	mobileStreamRouter.Use(mobileMiddlewareStack, streaming, streamLimits(metrics.MobileRequests))
 	mobilePingWithUser := pingStreamHandlerWithUser(basictypes.MobileSDK, r.mobileStreamProvider)
 	mobileStreamRouter.Handle("", middleware.CountMobileConns(mobilePingWithUser)).Methods("REPORT")
 	mobileStreamRouter.Handle("/{user}", middleware.CountMobileConns(mobilePingWithUser)).Methods("GET")
 
-	router.Handle("/mping", mobileKeySelector(
-		middleware.CountMobileConns(middleware.Streaming(pingStreamHandler(r.mobileStreamProvider))))).Methods("GET")
+	router.Handle("/mping", mobileKeySelector(middleware.RateLimit(metrics.MobileRequests)(
+		middleware.CountMobileConns(streaming(streamLimits(metrics.MobileRequests)(pingStreamHandler(r.mobileStreamProvider))))))).Methods("GET")
 
-	jsPing := pingStreamHandler(r.jsClientStreamProvider)
-	jsPingWithUser := pingStreamHandlerWithUser(basictypes.JSClientSDK, r.jsClientStreamProvider)
+	// The JS client-side streams can also be requested with "Upgrade: websocket", for clients whose network
+	// does not handle SSE well.
+	jsPing := streams.WithWebSocketTransport(pingStreamHandler(r.jsClientStreamProvider))
+	jsPingWithUser := streams.WithWebSocketTransport(pingStreamHandlerWithUser(basictypes.JSClientSDK, r.jsClientStreamProvider))
 
 	clientSidePingRouter := router.PathPrefix("/ping/{envId}").Subrouter()
-	clientSidePingRouter.Use(jsClientSideMiddlewareStack(clientSidePingRouter), middleware.Streaming)
+	clientSidePingRouter.Use(jsClientSideMiddlewareStack(clientSidePingRouter), streaming, streamLimits(metrics.BrowserRequests))
 	clientSidePingRouter.Handle("", middleware.CountBrowserConns(jsPing)).Methods("GET", "OPTIONS")
 
 	clientSideStreamEvalRouter := router.PathPrefix("/eval/{envId}").Subrouter()
-	clientSideStreamEvalRouter.Use(jsClientSideMiddlewareStack(clientSideStreamEvalRouter), middleware.Streaming)
+	clientSideStreamEvalRouter.Use(jsClientSideMiddlewareStack(clientSideStreamEvalRouter), streaming, streamLimits(metrics.BrowserRequests))
 	// For now we implement eval as simply ping
 	clientSideStreamEvalRouter.Handle("/{user}", middleware.CountBrowserConns(jsPingWithUser)).Methods("GET", "OPTIONS")
 	clientSideStreamEvalRouter.Handle("", middleware.CountBrowserConns(jsPingWithUser)).Methods("REPORT", "OPTIONS")
 
 	mobileEventsRouter := router.PathPrefix("/mobile").Subrouter()
 	mobileEventsRouter.Use(mobileMiddlewareStack)
-	mobileEventsRouter.Handle("/events/bulk", bulkEventHandler(basictypes.MobileSDK, ldevents.AnalyticsEventDataKind, offlineMode)).Methods("POST")
-	mobileEventsRouter.Handle("/events", bulkEventHandler(basictypes.MobileSDK, ldevents.AnalyticsEventDataKind, offlineMode)).Methods("POST")
-	mobileEventsRouter.Handle("", bulkEventHandler(basictypes.MobileSDK, ldevents.AnalyticsEventDataKind, offlineMode)).Methods("POST")
-	mobileEventsRouter.Handle("/events/diagnostic", bulkEventHandler(basictypes.MobileSDK, ldevents.DiagnosticEventDataKind, offlineMode)).Methods("POST")
+	mobileEventsRouter.Handle("/events/bulk", events(middleware.EventQuota(bulkEventHandler(basictypes.MobileSDK, ldevents.AnalyticsEventDataKind, discardEvents)))).Methods("POST")
+	mobileEventsRouter.Handle("/events", events(middleware.EventQuota(bulkEventHandler(basictypes.MobileSDK, ldevents.AnalyticsEventDataKind, discardEvents)))).Methods("POST")
+	mobileEventsRouter.Handle("", events(middleware.EventQuota(bulkEventHandler(basictypes.MobileSDK, ldevents.AnalyticsEventDataKind, discardEvents)))).Methods("POST")
+	mobileEventsRouter.Handle("/events/diagnostic", events(bulkEventHandler(basictypes.MobileSDK, ldevents.DiagnosticEventDataKind, discardEvents))).Methods("POST")
 
 	clientSideBulkEventsRouter := router.PathPrefix("/events/bulk/{envId}").Subrouter()
 	clientSideBulkEventsRouter.Use(jsClientSideMiddlewareStack(clientSideBulkEventsRouter))
-	clientSideBulkEventsRouter.Handle("", bulkEventHandler(basictypes.JSClientSDK, ldevents.AnalyticsEventDataKind, offlineMode)).Methods("POST", "OPTIONS")
+	clientSideBulkEventsRouter.Handle("", events(middleware.EventQuota(bulkEventHandler(basictypes.JSClientSDK, ldevents.AnalyticsEventDataKind, discardEvents)))).Methods("POST", "OPTIONS")
 
 	clientSideDiagnosticEventsRouter := router.PathPrefix("/events/diagnostic/{envId}").Subrouter()
 	clientSideDiagnosticEventsRouter.Use(jsClientSideMiddlewareStack(clientSideBulkEventsRouter))
-	clientSideDiagnosticEventsRouter.Handle("", bulkEventHandler(basictypes.JSClientSDK, ldevents.DiagnosticEventDataKind, offlineMode)).Methods("POST", "OPTIONS")
+	clientSideDiagnosticEventsRouter.Handle("", events(bulkEventHandler(basictypes.JSClientSDK, ldevents.DiagnosticEventDataKind, discardEvents))).Methods("POST", "OPTIONS")
 
 	clientSideImageEventsRouter := router.PathPrefix("/a/{envId}.gif").Subrouter()
 	clientSideImageEventsRouter.Use(jsClientSideMiddlewareStack(clientSideImageEventsRouter))
-	clientSideImageEventsRouter.HandleFunc("", getEventsImage).Methods("GET", "OPTIONS")
+	clientSideImageEventsRouter.Handle("", events(getEventsImage(discardEvents))).Methods("GET", "OPTIONS")
 
 	serverSideRouter := router.PathPrefix("").Subrouter()
 	serverSideRouter.Use(serverSideMiddlewareStack)
-	serverSideRouter.Handle("/bulk", bulkEventHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind, offlineMode)).Methods("POST")
-	serverSideRouter.Handle("/diagnostic", bulkEventHandler(basictypes.ServerSDK, ldevents.DiagnosticEventDataKind, offlineMode)).Methods("POST")
-	serverSideRouter.Handle("/all", middleware.CountServerConns(middleware.Streaming(
+	serverSideRouter.Handle("/bulk", events(middleware.EventQuota(bulkEventHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind, discardEvents)))).Methods("POST")
+	serverSideRouter.Handle("/diagnostic", events(bulkEventHandler(basictypes.ServerSDK, ldevents.DiagnosticEventDataKind, discardEvents))).Methods("POST")
+	serverSideRouter.Handle("/all", middleware.CountServerConns(streaming(streamLimits(metrics.ServerRequests)(
 		streamHandler(r.serverSideStreamProvider, serverSideStreamLogMessage),
-	))).Methods("GET")
-	serverSideRouter.Handle("/flags", middleware.CountServerConns(middleware.Streaming(
+	)))).Methods("GET")
+	serverSideRouter.Handle("/flags", middleware.CountServerConns(streaming(streamLimits(metrics.ServerRequests)(
 		streamHandler(r.serverSideFlagsStreamProvider, serverSideFlagsOnlyStreamLogMessage),
-	))).Methods("GET")
+	)))).Methods("GET")
 
 	return router
 }
+
+// makeGlobalResponseHeaders returns the headers that are added to every response, according to the
+// ResponseHeaders configuration. Headers that are configured explicitly take precedence over the
+// security headers.
+func makeGlobalResponseHeaders(c config.ResponseHeadersConfig) http.Header {
+	headers := make(http.Header)
+	if c.SecurityHeaders {
+		headers.Set("X-Content-Type-Options", "nosniff")
+		headers.Set("X-Frame-Options", "DENY")
+		headers.Set("Referrer-Policy", "no-referrer")
+	}
+	if c.HSTSMaxAge.IsDefined() {
+		headers.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d", int(c.HSTSMaxAge.GetOrElse(0).Seconds())))
+	}
+	configured, _ := config.ParseResponseHeaders(c.Header) // config.ValidateConfig has ensured that these are valid
+	for name, values := range configured {
+		headers[name] = values
+	}
+	return headers
+}
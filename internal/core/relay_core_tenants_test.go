@@ -0,0 +1,119 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantEnvironmentsAreOnlyAvailableForTheirTenant(t *testing.T) {
+	acmeEnv, globexEnv, sharedEnv := st.EnvMain, st.EnvMobile, st.EnvClientSide
+	acmeEnv.Config.Tenant = "acme"
+	globexEnv.Config.Tenant = "globex"
+	config := c.Config{
+		Tenant: map[string]*c.TenantConfig{
+			"acme":   {Port: mustOptIntGreaterThanZero(8031)},
+			"globex": {Host: ct.NewOptStringList([]string{"flags.globex.com"})},
+		},
+		Environment: st.MakeEnvConfigs(acmeEnv, globexEnv, sharedEnv),
+	}
+	core, err := makeBasicCore(config)
+	require.NoError(t, err)
+	defer core.Close()
+	require.NoError(t, core.WaitForAllClients(time.Second))
+
+	router := core.MakeRouter()
+	mainHandler, acmeHandler := router, core.TenantHandler("acme", router)
+
+	request := func(handler http.Handler, host string, env st.TestEnv) int {
+		req := st.BuildRequest("GET", "/sdk/flags", nil, http.Header{
+			"Authorization": []string{env.Config.SDKKey.GetAuthorizationHeaderValue()},
+		})
+		req.Host = host
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result().StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, request(acmeHandler, "localhost", acmeEnv))
+	assert.Equal(t, http.StatusUnauthorized, request(acmeHandler, "localhost", globexEnv))
+	assert.Equal(t, http.StatusUnauthorized, request(acmeHandler, "localhost", sharedEnv))
+
+	assert.Equal(t, http.StatusOK, request(mainHandler, "flags.globex.com", globexEnv))
+	assert.Equal(t, http.StatusUnauthorized, request(mainHandler, "flags.globex.com", acmeEnv))
+	assert.Equal(t, http.StatusUnauthorized, request(mainHandler, "flags.globex.com", sharedEnv))
+
+	assert.Equal(t, http.StatusOK, request(mainHandler, "localhost", sharedEnv))
+	assert.Equal(t, http.StatusUnauthorized, request(mainHandler, "localhost", acmeEnv))
+	assert.Equal(t, http.StatusUnauthorized, request(mainHandler, "localhost", globexEnv))
+}
+
+func TestStatusEndpointForTenantShowsOnlyItsEnvironments(t *testing.T) {
+	acmeEnv := st.EnvMain
+	acmeEnv.Config.Tenant = "acme"
+	config := c.Config{
+		Tenant:      map[string]*c.TenantConfig{"acme": {Port: mustOptIntGreaterThanZero(8031)}},
+		Environment: st.MakeEnvConfigs(acmeEnv, st.EnvMobile),
+	}
+	core, err := makeBasicCore(config)
+	require.NoError(t, err)
+	defer core.Close()
+
+	getStatus := func(handler http.Handler) StatusRep {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, st.BuildRequest("GET", "/status", nil, nil))
+		var status StatusRep
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		return status
+	}
+
+	router := core.MakeRouter()
+	assert.Len(t, getStatus(router).Environments, 2)
+	tenantStatus := getStatus(core.TenantHandler("acme", router))
+	assert.Len(t, tenantStatus.Environments, 1)
+	assert.Contains(t, tenantStatus.Environments, acmeEnv.Name)
+}
+
+func TestChangeFeedForTenantEnvironmentIsOnlyAvailableForItsTenant(t *testing.T) {
+	acmeEnv := st.EnvMain
+	acmeEnv.Config.Tenant = "acme"
+	acmeEnv.Config.EnvID = c.EnvironmentID("acme-env-id")
+	acmeEnv.Config.ChangeFeedToken = "feed-token"
+	config := c.Config{
+		Tenant:      map[string]*c.TenantConfig{"acme": {Port: mustOptIntGreaterThanZero(8031)}},
+		Environment: st.MakeEnvConfigs(acmeEnv),
+	}
+	core, err := makeBasicCore(config)
+	require.NoError(t, err)
+	defer core.Close()
+	require.NoError(t, core.WaitForAllClients(time.Second))
+
+	request := func(handler http.Handler) int {
+		// no token, so if the request reaches the change feed it is rejected as unauthorized
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, st.BuildRequest("GET", "/changes/"+string(acmeEnv.Config.EnvID), nil, nil))
+		return w.Result().StatusCode
+	}
+
+	router := core.MakeRouter()
+	assert.Equal(t, http.StatusUnauthorized, request(core.TenantHandler("acme", router)))
+	assert.Equal(t, http.StatusNotFound, request(router))
+}
+
+func mustOptIntGreaterThanZero(n int) ct.OptIntGreaterThanZero {
+	o, err := ct.NewOptIntGreaterThanZero(n)
+	if err != nil {
+		panic(err)
+	}
+	return o
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,7 @@ import (
 
 	c "github.com/launchdarkly/ld-relay/v6/config"
 	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/leader"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
 	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
@@ -16,6 +18,7 @@ import (
 	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testenv"
 
 	"github.com/launchdarkly/eventsource"
+	ct "github.com/launchdarkly/go-configtypes"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	ld "gopkg.in/launchdarkly/go-server-sdk.v5"
 
@@ -136,6 +139,23 @@ func TestRelayCoreAddEnvironmentAfterClosed(t *testing.T) {
 	assert.Nil(t, resultCh)
 }
 
+func TestRelayCoreAddEnvironmentWithUnknownDatabase(t *testing.T) {
+	config := c.Config{
+		Environment: st.MakeEnvConfigs(st.EnvMain),
+	}
+	core, err := makeBasicCore(config)
+	require.NoError(t, err)
+	defer core.Close()
+
+	envConfig := st.EnvMobile.Config
+	envConfig.Database = "undefined"
+	env, resultCh, err := core.AddEnvironment(relayenv.EnvIdentifiers{ConfiguredName: st.EnvMobile.Name}, envConfig, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `database "undefined" is not defined`)
+	assert.Nil(t, env)
+	assert.Nil(t, resultCh)
+}
+
 func TestRelayCoreRemoveEnvironment(t *testing.T) {
 	config := c.Config{
 		Environment: st.MakeEnvConfigs(st.EnvMain, st.EnvMobile),
@@ -235,6 +255,183 @@ func TestRelayCoreWaitForAllEnvironments(t *testing.T) {
 	})
 }
 
+func TestRelayCoreIsReady(t *testing.T) {
+	config := c.Config{
+		Environment: st.MakeEnvConfigs(st.EnvMain, st.EnvMobile),
+	}
+
+	t.Run("ready when fully configured and all environments are initialized", func(t *testing.T) {
+		core, err := makeBasicCore(config)
+		require.NoError(t, err)
+		defer core.Close()
+		require.NoError(t, core.WaitForAllClients(time.Second))
+
+		assert.True(t, core.IsReady())
+	})
+
+	t.Run("not ready when auto-configuration has not been received", func(t *testing.T) {
+		core, err := makeBasicCore(config)
+		require.NoError(t, err)
+		defer core.Close()
+		require.NoError(t, core.WaitForAllClients(time.Second))
+
+		core.SetFullyConfigured(false)
+		assert.False(t, core.IsReady())
+	})
+
+	t.Run("not ready while an environment is initializing", func(t *testing.T) {
+		gateCh := make(chan struct{})
+		defer close(gateCh)
+		core, err := NewRelayCore(config, ldlog.NewDisabledLoggers(),
+			oneEnvFails(st.EnvMobile.Config.SDKKey, true, gateCh), "", "", false)
+		require.NoError(t, err)
+		defer core.Close()
+
+		assert.False(t, core.IsReady())
+	})
+
+	t.Run("not ready in maintenance mode", func(t *testing.T) {
+		core, err := makeBasicCore(config)
+		require.NoError(t, err)
+		defer core.Close()
+		require.NoError(t, core.WaitForAllClients(time.Second))
+
+		core.SetMaintenanceMode(true)
+		assert.False(t, core.IsReady())
+		core.SetMaintenanceMode(false)
+		assert.True(t, core.IsReady())
+	})
+}
+
+func TestRelayCoreMaintenanceMode(t *testing.T) {
+	config := c.Config{Environment: st.MakeEnvConfigs(st.EnvMain)}
+	core, err := makeBasicCore(config)
+	require.NoError(t, err)
+	defer core.Close()
+	require.NoError(t, core.WaitForAllClients(time.Second))
+	router := core.MakeRouter()
+	doRequest := func(url string) (*http.Response, []byte) {
+		return st.DoRequest(st.BuildRequestWithAuth("GET", url, st.EnvMain.Config.SDKKey, nil), router)
+	}
+
+	assert.False(t, core.IsInMaintenanceMode())
+	assert.True(t, core.SetMaintenanceMode(true))
+	assert.False(t, core.SetMaintenanceMode(true))
+	assert.True(t, core.IsInMaintenanceMode())
+
+	resp, body := doRequest("http://localhost/sdk/flags")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+	assert.Equal(t, "Relay Proxy is in maintenance mode", string(body))
+
+	resp, body = doRequest("http://localhost/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.JSONEq(t, `{"ready": false, "maintenance": true}`, string(body))
+	resp, _ = doRequest("http://localhost/healthz/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp, _ = doRequest("http://localhost/healthz/live")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, body = doRequest("http://localhost/status")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), `"maintenance":true`)
+
+	assert.True(t, core.SetMaintenanceMode(false))
+	resp, _ = doRequest("http://localhost/sdk/flags")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = doRequest("http://localhost/ready")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+type fakeLeaderLock struct {
+	acquired bool
+}
+
+func (f *fakeLeaderLock) Acquire(context.Context) (bool, error) { return f.acquired, nil }
+func (f *fakeLeaderLock) Release(context.Context) error         { return nil }
+func (f *fakeLeaderLock) Close() error                          { return nil }
+
+func TestRelayCoreDiscardsEventsOnStandby(t *testing.T) {
+	config := c.Config{Environment: st.MakeEnvConfigs(st.EnvMain)}
+	core, err := makeBasicCore(config)
+	require.NoError(t, err)
+	defer core.Close()
+	require.NoError(t, core.WaitForAllClients(time.Second))
+	assert.True(t, core.IsLeader()) // leader election is not enabled
+
+	lock := &fakeLeaderLock{}
+	core.leaderElector = leader.NewElectorForLock(lock, c.LeaderElectionConfig{}, ldlog.NewDisabledLoggers())
+	core.leaderElector.Start()
+	router := core.MakeRouter()
+	postEvents := func() *http.Response {
+		resp, _ := st.DoRequest(st.BuildRequestWithAuth("POST", "http://localhost/bulk", st.EnvMain.Config.SDKKey,
+			[]byte("[]")), router)
+		return resp
+	}
+
+	// Events are not enabled, so a leader would reject them; a standby accepts and discards them.
+	assert.False(t, core.IsLeader())
+	assert.Equal(t, http.StatusAccepted, postEvents().StatusCode)
+	_, body := st.DoRequest(st.BuildRequest("GET", "http://localhost/status", nil, nil), router)
+	assert.Contains(t, string(body), `"role":"standby"`)
+	resp, _ := st.DoRequest(st.BuildRequest("GET", "http://localhost/ready", nil, nil), router)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	core.leaderElector.Close()
+	lock.acquired = true
+	core.leaderElector = leader.NewElectorForLock(lock, c.LeaderElectionConfig{}, ldlog.NewDisabledLoggers())
+	core.leaderElector.Start()
+	assert.True(t, core.IsLeader())
+	assert.Equal(t, http.StatusServiceUnavailable, postEvents().StatusCode)
+	_, body = st.DoRequest(st.BuildRequest("GET", "http://localhost/status", nil, nil), router)
+	assert.Contains(t, string(body), `"role":"leader"`)
+}
+
+func TestRelayCoreShedsLoadWhenMemoryUseIsTooHigh(t *testing.T) {
+	envConfig := st.EnvMain.Config
+	envConfig.EvalCacheTTL = ct.NewOptDuration(time.Hour)
+	envConfig.EnvID = c.EnvironmentID("feed-env-id")
+	envConfig.ChangeFeedToken = "feed-token"
+	highWaterMark, _ := ct.NewOptIntGreaterThanZero(1) // any running process is using more than 1MB
+	config := c.Config{
+		MemoryLimits: c.MemoryLimitsConfig{
+			HighWaterMarkMB: highWaterMark,
+			CheckInterval:   ct.NewOptDuration(time.Millisecond * 10),
+		},
+		Environment: map[string]*c.EnvConfig{st.EnvMain.Name: &envConfig},
+	}
+	core, err := makeBasicCore(config)
+	require.NoError(t, err)
+	defer core.Close()
+	require.NoError(t, core.WaitForAllClients(time.Second))
+	env, _ := core.GetEnvironment(st.EnvMain.Config.SDKKey)
+	require.NotNil(t, env)
+
+	require.Eventually(t, core.IsSheddingLoad, time.Second*5, time.Millisecond*10)
+
+	cache := env.GetEvalCache()
+	require.NotNil(t, cache)
+	cache.Set("key", []byte("data"), cache.Generation())
+	require.Eventually(t, func() bool {
+		_, found := cache.Get("key")
+		return !found
+	}, time.Second*5, time.Millisecond*10)
+
+	req := st.BuildRequestWithAuth("GET", "http://localhost/all", st.EnvMain.Config.SDKKey, nil)
+	resp, _ := st.DoRequest(req, core.MakeRouter())
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "10", resp.Header.Get("Retry-After"))
+
+	// the change feed checks its token before it is subject to load shedding
+	req = st.BuildRequest("GET", "http://localhost/changes/feed-env-id", nil, nil)
+	resp, _ = st.DoRequest(req, core.MakeRouter())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	req = st.BuildRequest("GET", "http://localhost/changes/feed-env-id", nil,
+		http.Header{"Authorization": {"Bearer feed-token"}})
+	resp, _ = st.DoRequest(req, core.MakeRouter())
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "10", resp.Header.Get("Retry-After"))
+}
+
 func TestRelayCoreUninitializedEnvironment(t *testing.T) {
 	config := c.Config{
 		Environment: st.MakeEnvConfigs(st.EnvMain, st.EnvMobile),
@@ -0,0 +1,137 @@
+package relayenv
+
+import (
+	"sync"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+)
+
+// getUserMemberships queries the store for the memberships of several users, in a single batch if the
+// store supports that (see sdks.BigSegmentBatchStore), or otherwise one user at a time.
+func getUserMemberships(
+	store interfaces.BigSegmentStore,
+	userHashKeys []string,
+) ([]interfaces.BigSegmentMembership, error) {
+	if batchStore, ok := store.(sdks.BigSegmentBatchStore); ok {
+		return batchStore.GetUserMemberships(userHashKeys)
+	}
+	ret := make([]interfaces.BigSegmentMembership, 0, len(userHashKeys))
+	for _, userHashKey := range userHashKeys {
+		membership, err := store.GetUserMembership(userHashKey)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, membership)
+	}
+	return ret, nil
+}
+
+// prefetchBigSegmentStore wraps the big segment store used by the SDK, so that when Relay evaluates
+// flags for many users at once, it can query the memberships of all of those users in one batch
+// instead of letting the SDK query them one at a time.
+//
+// Prefetch queries the batch and holds on to the results until the function that it returns is
+// called; until then, the SDK's queries for those users are answered from the results, and the SDK
+// caches them as usual. If the underlying store cannot query in batches, Prefetch does nothing, since
+// the SDK's own queries for separate users can at least run in parallel. If the batch query fails,
+// the SDK queries each user as it would have otherwise.
+//
+// All of its methods except the BigSegmentStore methods can be called on a nil pointer.
+type prefetchBigSegmentStore struct {
+	store      interfaces.BigSegmentStore
+	batching   bool
+	prefetched map[string]*prefetchedBigSegmentMembership
+	lock       sync.Mutex
+}
+
+type prefetchedBigSegmentMembership struct {
+	membership interfaces.BigSegmentMembership
+	refs       int
+}
+
+func newPrefetchBigSegmentStore(store interfaces.BigSegmentStore, batching bool) *prefetchBigSegmentStore {
+	return &prefetchBigSegmentStore{
+		store:      store,
+		batching:   batching,
+		prefetched: make(map[string]*prefetchedBigSegmentMembership),
+	}
+}
+
+func (p *prefetchBigSegmentStore) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	return p.store.GetMetadata()
+}
+
+func (p *prefetchBigSegmentStore) GetUserMembership(userHashKey string) (interfaces.BigSegmentMembership, error) {
+	p.lock.Lock()
+	entry := p.prefetched[userHashKey]
+	p.lock.Unlock()
+	if entry != nil {
+		return entry.membership, nil
+	}
+	return p.store.GetUserMembership(userHashKey)
+}
+
+func (p *prefetchBigSegmentStore) Close() error {
+	return p.store.Close()
+}
+
+// Prefetch queries the memberships of the users with the specified keys. The caller must call the
+// returned function when it has finished evaluating flags for them.
+func (p *prefetchBigSegmentStore) Prefetch(userKeys []string) func() {
+	if p == nil || !p.batching || len(userKeys) == 0 {
+		return func() {}
+	}
+	userHashKeys := make([]string, 0, len(userKeys))
+	seen := make(map[string]bool, len(userKeys))
+	for _, userKey := range userKeys {
+		userHashKey := bigsegments.HashForUserKey(userKey)
+		if !seen[userHashKey] {
+			seen[userHashKey] = true
+			userHashKeys = append(userHashKeys, userHashKey)
+		}
+	}
+	memberships, err := getUserMemberships(p.store, userHashKeys)
+	if err != nil {
+		return func() {}
+	}
+
+	entries := make([]*prefetchedBigSegmentMembership, len(userHashKeys))
+	p.lock.Lock()
+	for i, userHashKey := range userHashKeys {
+		entry := p.prefetched[userHashKey]
+		if entry == nil {
+			entry = &prefetchedBigSegmentMembership{}
+			p.prefetched[userHashKey] = entry
+		}
+		entry.membership = memberships[i]
+		entry.refs++
+		entries[i] = entry
+	}
+	p.lock.Unlock()
+
+	return func() {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		for i, userHashKey := range userHashKeys {
+			entries[i].refs--
+			// If Clear was called in the meantime, the map may no longer contain this entry
+			if entries[i].refs == 0 && p.prefetched[userHashKey] == entries[i] {
+				delete(p.prefetched, userHashKey)
+			}
+		}
+	}
+}
+
+// Clear discards all prefetched memberships. This is called when the big segment data changes, at the
+// same time as the SDK's membership cache is cleared.
+func (p *prefetchBigSegmentStore) Clear() {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	p.prefetched = make(map[string]*prefetchedBigSegmentMembership)
+	p.lock.Unlock()
+}
@@ -0,0 +1,205 @@
+package relayenv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments/membership"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBatchBigSegmentStore is a big segment store that supports batch queries. Each user hash key in
+// included is in the segment "segment.g1".
+type fakeBatchBigSegmentStore struct {
+	included     map[string]bool
+	err          error
+	queries      int
+	batchQueries [][]string
+}
+
+func (s *fakeBatchBigSegmentStore) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	return interfaces.BigSegmentStoreMetadata{}, nil
+}
+
+func (s *fakeBatchBigSegmentStore) GetUserMembership(userHashKey string) (interfaces.BigSegmentMembership, error) {
+	s.queries++
+	return s.membership(userHashKey), s.err
+}
+
+func (s *fakeBatchBigSegmentStore) GetUserMemberships(userHashKeys []string) ([]interfaces.BigSegmentMembership, error) {
+	s.batchQueries = append(s.batchQueries, userHashKeys)
+	if s.err != nil {
+		return nil, s.err
+	}
+	ret := make([]interfaces.BigSegmentMembership, 0, len(userHashKeys))
+	for _, userHashKey := range userHashKeys {
+		ret = append(ret, s.membership(userHashKey))
+	}
+	return ret, nil
+}
+
+func (s *fakeBatchBigSegmentStore) membership(userHashKey string) interfaces.BigSegmentMembership {
+	if s.included[userHashKey] {
+		return membership.FromSegmentRefs([]string{"segment.g1"}, nil)
+	}
+	return membership.FromSegmentRefs(nil, nil)
+}
+
+func (s *fakeBatchBigSegmentStore) Close() error { return nil }
+
+func TestGetUserMembershipsFallsBackToSequentialQueries(t *testing.T) {
+	store := &fakeFailingBigSegmentStore{}
+	memberships, err := getUserMemberships(store, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Len(t, memberships, 2)
+	assert.Equal(t, 2, store.queries)
+
+	store.err = errors.New("sorry")
+	_, err = getUserMemberships(store, []string{"a", "b"})
+	assert.Equal(t, store.err, err)
+}
+
+func TestPrefetchAnswersQueriesUntilReleased(t *testing.T) {
+	user1Hash, user2Hash := bigsegments.HashForUserKey("user1"), bigsegments.HashForUserKey("user2")
+	store := &fakeBatchBigSegmentStore{included: map[string]bool{user1Hash: true}}
+	p := newPrefetchBigSegmentStore(store, true)
+
+	release := p.Prefetch([]string{"user1", "user2", "user1"})
+	assert.Equal(t, [][]string{{user1Hash, user2Hash}}, store.batchQueries)
+
+	m, err := p.GetUserMembership(user1Hash)
+	require.NoError(t, err)
+	assert.Equal(t, ldvalue.NewOptionalBool(true), m.CheckMembership("segment.g1"))
+	m, err = p.GetUserMembership(user2Hash)
+	require.NoError(t, err)
+	assert.Equal(t, ldvalue.OptionalBool{}, m.CheckMembership("segment.g1"))
+	assert.Equal(t, 0, store.queries)
+
+	release()
+	_, _ = p.GetUserMembership(user1Hash)
+	assert.Equal(t, 1, store.queries)
+}
+
+func TestPrefetchKeepsMembershipsUntilEveryBatchIsReleased(t *testing.T) {
+	userHash := bigsegments.HashForUserKey("user1")
+	store := &fakeBatchBigSegmentStore{}
+	p := newPrefetchBigSegmentStore(store, true)
+
+	release1 := p.Prefetch([]string{"user1"})
+	release2 := p.Prefetch([]string{"user1"})
+	release1()
+	_, _ = p.GetUserMembership(userHash)
+	assert.Equal(t, 0, store.queries)
+
+	release2()
+	_, _ = p.GetUserMembership(userHash)
+	assert.Equal(t, 1, store.queries)
+}
+
+func TestPrefetchDoesNothingIfStoreCannotBatch(t *testing.T) {
+	store := &fakeBatchBigSegmentStore{}
+	p := newPrefetchBigSegmentStore(store, false)
+
+	release := p.Prefetch([]string{"user1"})
+	defer release()
+	assert.Len(t, store.batchQueries, 0)
+	_, _ = p.GetUserMembership(bigsegments.HashForUserKey("user1"))
+	assert.Equal(t, 1, store.queries)
+}
+
+func TestPrefetchFailureLeavesQueriesToTheStore(t *testing.T) {
+	store := &fakeBatchBigSegmentStore{err: errors.New("sorry")}
+	p := newPrefetchBigSegmentStore(store, true)
+
+	release := p.Prefetch([]string{"user1"})
+	defer release()
+	_, err := p.GetUserMembership(bigsegments.HashForUserKey("user1"))
+	assert.Equal(t, store.err, err)
+	assert.Equal(t, 1, store.queries)
+}
+
+func TestPrefetchClear(t *testing.T) {
+	userHash := bigsegments.HashForUserKey("user1")
+	store := &fakeBatchBigSegmentStore{}
+	p := newPrefetchBigSegmentStore(store, true)
+
+	release1 := p.Prefetch([]string{"user1"})
+	p.Clear()
+	_, _ = p.GetUserMembership(userHash)
+	assert.Equal(t, 1, store.queries)
+
+	release2 := p.Prefetch([]string{"user1"})
+	release1() // does not affect the memberships that were prefetched after Clear
+	_, _ = p.GetUserMembership(userHash)
+	assert.Equal(t, 1, store.queries)
+	release2()
+}
+
+func TestNilPrefetchBigSegmentStore(t *testing.T) {
+	var p *prefetchBigSegmentStore
+	p.Prefetch([]string{"user1"})()
+	p.Clear()
+}
+
+func TestBatchQueriesPassThroughWrappers(t *testing.T) {
+	store := &fakeBatchBigSegmentStore{}
+	bigSegmentsConfig := makeCircuitBreakerConfig("", time.Hour)
+	bigSegmentsConfig.MaxConcurrentQueries = makeConcurrencyLimitConfig(1, 0).MaxConcurrentQueries
+	wrapped := newConcurrencyLimitBigSegmentStore(
+		newCircuitBreakerBigSegmentStore(
+			metricsBigSegmentStore{store: store, metricsCtx: context.Background()},
+			bigSegmentsConfig,
+			ldlog.NewDisabledLoggers(),
+		),
+		bigSegmentsConfig,
+		config.EnvConfig{},
+		context.Background(),
+	)
+
+	memberships, err := getUserMemberships(wrapped, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Len(t, memberships, 2)
+	assert.Equal(t, [][]string{{"a", "b"}}, store.batchQueries)
+	assert.Equal(t, 0, store.queries)
+}
+
+func TestCircuitBreakerBatchQueries(t *testing.T) {
+	store := &fakeBatchBigSegmentStore{err: errors.New("sorry")}
+	c := newCircuitBreakerBigSegmentStore(store, makeCircuitBreakerConfig(config.BigSegmentsCircuitBreakerFallbackLastKnown,
+		time.Hour), ldlog.NewDisabledLoggers())
+
+	for i := 0; i < 2; i++ {
+		_, err := getUserMemberships(c, []string{"a"})
+		assert.Equal(t, store.err, err)
+	}
+	_, err := getUserMemberships(c, []string{"a"})
+	assert.Equal(t, errBigSegmentStoreCircuitOpen, err)
+	assert.Len(t, store.batchQueries, 2)
+}
+
+func TestCircuitBreakerBatchQueriesUpdateLastKnownMemberships(t *testing.T) {
+	store := &fakeBatchBigSegmentStore{included: map[string]bool{"a": true}}
+	c := newCircuitBreakerBigSegmentStore(store, makeCircuitBreakerConfig(config.BigSegmentsCircuitBreakerFallbackLastKnown,
+		time.Hour), ldlog.NewDisabledLoggers())
+
+	_, err := getUserMemberships(c, []string{"a"})
+	require.NoError(t, err)
+	store.err = errors.New("sorry")
+	for i := 0; i < 2; i++ {
+		_, _ = c.GetUserMembership("b")
+	}
+
+	m, err := c.GetUserMembership("a")
+	require.NoError(t, err)
+	assert.Equal(t, ldvalue.NewOptionalBool(true), m.CheckMembership("segment.g1"))
+}
@@ -0,0 +1,163 @@
+package relayenv
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents"
+)
+
+var errBigSegmentStoreCircuitOpen = errors.New("big segment store queries are suspended after repeated failures") //nolint:gochecknoglobals
+
+// circuitBreakerBigSegmentStore wraps the big segment store used by the SDK, so that if the store
+// fails repeatedly we stop sending it queries that are likely to time out.
+//
+// After maxFailures consecutive failures, the circuit is open: queries fail immediately without
+// calling the store, except that once every retryInterval a single query is allowed through as a
+// probe. If the probe succeeds, the circuit is closed again.
+//
+// While the circuit is open, a user membership query returns an error, which the evaluator treats
+// as the user not being included in any big segment; or, if the fallback is
+// BigSegmentsCircuitBreakerFallbackLastKnown, it returns the last membership state that was
+// successfully queried for that user, if any.
+type circuitBreakerBigSegmentStore struct {
+	store         interfaces.BigSegmentStore
+	maxFailures   int
+	retryInterval time.Duration
+	lastKnown     map[string]interfaces.BigSegmentMembership // nil unless the fallback is lastKnown
+	lastKnownMax  int
+	failures      int
+	openedAt      time.Time
+	probing       bool
+	loggers       ldlog.Loggers
+	lock          sync.Mutex
+}
+
+// newCircuitBreakerBigSegmentStore wraps the store in a circuit breaker if that is enabled in the
+// configuration; otherwise it returns the store unchanged.
+func newCircuitBreakerBigSegmentStore(
+	store interfaces.BigSegmentStore,
+	bigSegmentsConfig config.BigSegmentsConfig,
+	loggers ldlog.Loggers,
+) interfaces.BigSegmentStore {
+	if !bigSegmentsConfig.CircuitBreakerFailures.IsDefined() {
+		return store
+	}
+	c := &circuitBreakerBigSegmentStore{
+		store:         store,
+		maxFailures:   bigSegmentsConfig.CircuitBreakerFailures.GetOrElse(0),
+		retryInterval: bigSegmentsConfig.CircuitBreakerRetryInterval.GetOrElse(config.DefaultBigSegmentsCircuitBreakerRetryInterval),
+		loggers:       loggers,
+	}
+	if bigSegmentsConfig.CircuitBreakerFallback == config.BigSegmentsCircuitBreakerFallbackLastKnown {
+		c.lastKnown = make(map[string]interfaces.BigSegmentMembership)
+		c.lastKnownMax = bigSegmentsConfig.UserCacheSize.GetOrElse(ldcomponents.DefaultBigSegmentsUserCacheSize)
+	}
+	return c
+}
+
+func (c *circuitBreakerBigSegmentStore) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	if !c.beforeQuery() {
+		return interfaces.BigSegmentStoreMetadata{}, errBigSegmentStoreCircuitOpen
+	}
+	metadata, err := c.store.GetMetadata()
+	c.afterQuery(err)
+	return metadata, err
+}
+
+func (c *circuitBreakerBigSegmentStore) GetUserMembership(userHashKey string) (interfaces.BigSegmentMembership, error) {
+	if !c.beforeQuery() {
+		c.lock.Lock()
+		membership, ok := c.lastKnown[userHashKey]
+		c.lock.Unlock()
+		if ok {
+			return membership, nil
+		}
+		return nil, errBigSegmentStoreCircuitOpen
+	}
+	membership, err := c.store.GetUserMembership(userHashKey)
+	c.afterQuery(err)
+	if err == nil {
+		c.rememberMembership(userHashKey, membership)
+	}
+	return membership, err
+}
+
+// GetUserMemberships implements sdks.BigSegmentBatchStore. While the circuit is open, it returns an
+// error without using the last known memberships; the SDK then queries each user separately, which
+// does use them.
+func (c *circuitBreakerBigSegmentStore) GetUserMemberships(
+	userHashKeys []string,
+) ([]interfaces.BigSegmentMembership, error) {
+	if !c.beforeQuery() {
+		return nil, errBigSegmentStoreCircuitOpen
+	}
+	memberships, err := getUserMemberships(c.store, userHashKeys)
+	c.afterQuery(err)
+	if err == nil {
+		for i, userHashKey := range userHashKeys {
+			c.rememberMembership(userHashKey, memberships[i])
+		}
+	}
+	return memberships, err
+}
+
+func (c *circuitBreakerBigSegmentStore) Close() error {
+	return c.store.Close()
+}
+
+func (c *circuitBreakerBigSegmentStore) rememberMembership(userHashKey string, membership interfaces.BigSegmentMembership) {
+	if c.lastKnown == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.lastKnown[userHashKey]; !ok && len(c.lastKnown) >= c.lastKnownMax {
+		for key := range c.lastKnown { // discard an arbitrary entry to make room
+			delete(c.lastKnown, key)
+			break
+		}
+	}
+	c.lastKnown[userHashKey] = membership
+}
+
+// beforeQuery returns true if the store should be queried.
+func (c *circuitBreakerBigSegmentStore) beforeQuery() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.failures < c.maxFailures {
+		return true
+	}
+	if !c.probing && time.Since(c.openedAt) >= c.retryInterval {
+		c.probing = true
+		return true
+	}
+	return false
+}
+
+func (c *circuitBreakerBigSegmentStore) afterQuery(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	wasProbing := c.probing
+	c.probing = false
+	if err == nil {
+		if c.failures >= c.maxFailures {
+			c.loggers.Info("Big segment store is available again; resuming queries")
+		}
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures == c.maxFailures {
+		c.loggers.Warnf("Big segment store query failed %d times in a row; suspending queries for %s",
+			c.failures, c.retryInterval)
+	}
+	if c.failures == c.maxFailures || wasProbing {
+		c.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,127 @@
+package relayenv
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFailingBigSegmentStore struct {
+	err     error
+	queries int
+}
+
+func (s *fakeFailingBigSegmentStore) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	s.queries++
+	return interfaces.BigSegmentStoreMetadata{}, s.err
+}
+
+func (s *fakeFailingBigSegmentStore) GetUserMembership(string) (interfaces.BigSegmentMembership, error) {
+	s.queries++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return ldstoreimpl.NewBigSegmentMembershipFromSegmentRefs([]string{"segment.g1"}, nil), nil
+}
+
+func (s *fakeFailingBigSegmentStore) Close() error { return nil }
+
+func makeCircuitBreakerConfig(fallback string, retryInterval time.Duration) config.BigSegmentsConfig {
+	c := config.BigSegmentsConfig{
+		CircuitBreakerRetryInterval: configtypes.NewOptDuration(retryInterval),
+		CircuitBreakerFallback:      fallback,
+	}
+	c.CircuitBreakerFailures, _ = configtypes.NewOptIntGreaterThanZero(2)
+	return c
+}
+
+func TestCircuitBreakerIsNotUsedIfNotConfigured(t *testing.T) {
+	store := &fakeFailingBigSegmentStore{}
+	assert.Equal(t, store, newCircuitBreakerBigSegmentStore(store, config.BigSegmentsConfig{}, ldlog.NewDisabledLoggers()))
+}
+
+func TestCircuitBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	store := &fakeFailingBigSegmentStore{err: errors.New("sorry")}
+	c := newCircuitBreakerBigSegmentStore(store, makeCircuitBreakerConfig("", time.Hour), ldlog.NewDisabledLoggers())
+
+	for i := 0; i < 2; i++ {
+		_, err := c.GetUserMembership("user")
+		assert.Equal(t, store.err, err)
+	}
+	assert.Equal(t, 2, store.queries)
+
+	_, err := c.GetUserMembership("user")
+	assert.Equal(t, errBigSegmentStoreCircuitOpen, err)
+	_, err = c.GetMetadata()
+	assert.Equal(t, errBigSegmentStoreCircuitOpen, err)
+	assert.Equal(t, 2, store.queries)
+}
+
+func TestCircuitBreakerSuccessResetsFailureCount(t *testing.T) {
+	store := &fakeFailingBigSegmentStore{err: errors.New("sorry")}
+	c := newCircuitBreakerBigSegmentStore(store, makeCircuitBreakerConfig("", time.Hour), ldlog.NewDisabledLoggers())
+
+	_, _ = c.GetUserMembership("user")
+	store.err = nil
+	_, _ = c.GetUserMembership("user")
+	store.err = errors.New("sorry")
+	_, err := c.GetUserMembership("user")
+	assert.Equal(t, store.err, err)
+	assert.Equal(t, 3, store.queries)
+}
+
+func TestCircuitBreakerProbesForRecovery(t *testing.T) {
+	retryInterval := time.Millisecond * 10
+	store := &fakeFailingBigSegmentStore{err: errors.New("sorry")}
+	c := newCircuitBreakerBigSegmentStore(store, makeCircuitBreakerConfig("", retryInterval), ldlog.NewDisabledLoggers())
+
+	_, _ = c.GetMetadata()
+	_, _ = c.GetMetadata()
+
+	// the probe fails, so the circuit stays open for another interval
+	time.Sleep(retryInterval)
+	_, err := c.GetMetadata()
+	assert.Equal(t, store.err, err)
+	assert.Equal(t, 3, store.queries)
+	_, err = c.GetMetadata()
+	assert.Equal(t, errBigSegmentStoreCircuitOpen, err)
+
+	// the probe succeeds, so the circuit is closed
+	time.Sleep(retryInterval)
+	store.err = nil
+	_, err = c.GetMetadata()
+	assert.NoError(t, err)
+	_, err = c.GetUserMembership("user")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, store.queries)
+}
+
+func TestCircuitBreakerFallbackLastKnown(t *testing.T) {
+	store := &fakeFailingBigSegmentStore{}
+	c := newCircuitBreakerBigSegmentStore(store,
+		makeCircuitBreakerConfig(config.BigSegmentsCircuitBreakerFallbackLastKnown, time.Hour), ldlog.NewDisabledLoggers())
+
+	expected, err := c.GetUserMembership("user1")
+	require.NoError(t, err)
+
+	store.err = errors.New("sorry")
+	_, _ = c.GetMetadata()
+	_, _ = c.GetMetadata()
+
+	membership, err := c.GetUserMembership("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, membership)
+
+	_, err = c.GetUserMembership("user2")
+	assert.Equal(t, errBigSegmentStoreCircuitOpen, err)
+}
@@ -0,0 +1,97 @@
+package relayenv
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+)
+
+var errBigSegmentQueryLimit = errors.New("too many concurrent big segment store queries") //nolint:gochecknoglobals
+
+// concurrencyLimitBigSegmentStore wraps the big segment store used by the SDK, so that an environment
+// makes no more than a fixed number of user membership queries at a time. Without this, a burst of
+// evaluations for users who are not in the membership cache could use up the database connection pool
+// that is shared with flag data reads.
+//
+// A query that is over the limit waits for up to queueTimeout for another one to finish. If none does, it
+// returns an error without querying the store, which the evaluator treats as the user not being included
+// in any big segment. Metadata queries are not limited, since the SDK only makes them periodically.
+type concurrencyLimitBigSegmentStore struct {
+	store        interfaces.BigSegmentStore
+	slots        chan struct{}
+	queueTimeout time.Duration
+	metricsCtx   context.Context
+}
+
+// newConcurrencyLimitBigSegmentStore wraps the store in a concurrency limit if one is set in the
+// configuration; otherwise it returns the store unchanged.
+func newConcurrencyLimitBigSegmentStore(
+	store interfaces.BigSegmentStore,
+	bigSegmentsConfig config.BigSegmentsConfig,
+	envConfig config.EnvConfig,
+	metricsCtx context.Context,
+) interfaces.BigSegmentStore {
+	maxQueries := envConfig.BigSegmentsMaxConcurrentQueries.GetOrElse(bigSegmentsConfig.MaxConcurrentQueries.GetOrElse(0))
+	if maxQueries <= 0 {
+		return store
+	}
+	return &concurrencyLimitBigSegmentStore{
+		store:        store,
+		slots:        make(chan struct{}, maxQueries),
+		queueTimeout: bigSegmentsConfig.QueryQueueTimeout.GetOrElse(config.DefaultBigSegmentsQueryQueueTimeout),
+		metricsCtx:   metricsCtx,
+	}
+}
+
+func (c *concurrencyLimitBigSegmentStore) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	return c.store.GetMetadata()
+}
+
+func (c *concurrencyLimitBigSegmentStore) GetUserMembership(userHashKey string) (interfaces.BigSegmentMembership, error) {
+	if !c.acquire() {
+		metrics.RecordBigSegmentQueryRejected(c.metricsCtx)
+		return nil, errBigSegmentQueryLimit
+	}
+	defer func() { <-c.slots }()
+	return c.store.GetUserMembership(userHashKey)
+}
+
+// GetUserMemberships implements sdks.BigSegmentBatchStore. A batch query uses a single slot.
+func (c *concurrencyLimitBigSegmentStore) GetUserMemberships(
+	userHashKeys []string,
+) ([]interfaces.BigSegmentMembership, error) {
+	if !c.acquire() {
+		metrics.RecordBigSegmentQueryRejected(c.metricsCtx)
+		return nil, errBigSegmentQueryLimit
+	}
+	defer func() { <-c.slots }()
+	return getUserMemberships(c.store, userHashKeys)
+}
+
+func (c *concurrencyLimitBigSegmentStore) Close() error {
+	return c.store.Close()
+}
+
+func (c *concurrencyLimitBigSegmentStore) acquire() bool {
+	select {
+	case c.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if c.queueTimeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(c.queueTimeout)
+	defer timer.Stop()
+	select {
+	case c.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
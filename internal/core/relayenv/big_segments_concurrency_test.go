@@ -0,0 +1,137 @@
+package relayenv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingBigSegmentStore is a big segment store whose membership queries do not return until a value
+// is sent on the release channel.
+type blockingBigSegmentStore struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingBigSegmentStore() *blockingBigSegmentStore {
+	return &blockingBigSegmentStore{started: make(chan struct{}, 10), release: make(chan struct{}, 10)}
+}
+
+func (s *blockingBigSegmentStore) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	return interfaces.BigSegmentStoreMetadata{}, nil
+}
+
+func (s *blockingBigSegmentStore) GetUserMembership(string) (interfaces.BigSegmentMembership, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return ldstoreimpl.NewBigSegmentMembershipFromSegmentRefs([]string{"segment.g1"}, nil), nil
+}
+
+func (s *blockingBigSegmentStore) Close() error { return nil }
+
+func makeConcurrencyLimitConfig(maxQueries int, queueTimeout time.Duration) config.BigSegmentsConfig {
+	c := config.BigSegmentsConfig{QueryQueueTimeout: configtypes.NewOptDuration(queueTimeout)}
+	c.MaxConcurrentQueries, _ = configtypes.NewOptIntGreaterThanZero(maxQueries)
+	return c
+}
+
+// startBlockedQuery starts a membership query on another goroutine, waits until it has reached the
+// store, and returns a channel that receives its error when it finishes.
+func startBlockedQuery(t *testing.T, c interfaces.BigSegmentStore, store *blockingBigSegmentStore) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := c.GetUserMembership("user")
+		errCh <- err
+	}()
+	select {
+	case <-store.started:
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "timed out waiting for query to start")
+	}
+	return errCh
+}
+
+func TestConcurrencyLimitIsNotUsedIfNotConfigured(t *testing.T) {
+	store := &fakeFailingBigSegmentStore{}
+	assert.Equal(t, store, newConcurrencyLimitBigSegmentStore(store, config.BigSegmentsConfig{}, config.EnvConfig{},
+		context.Background()))
+}
+
+func TestConcurrencyLimitRejectsQueryOverLimitAfterQueueTimeout(t *testing.T) {
+	store := newBlockingBigSegmentStore()
+	c := newConcurrencyLimitBigSegmentStore(store, makeConcurrencyLimitConfig(1, time.Millisecond*10),
+		config.EnvConfig{}, context.Background())
+
+	firstErrCh := startBlockedQuery(t, c, store)
+
+	membership, err := c.GetUserMembership("user2")
+	assert.Equal(t, errBigSegmentQueryLimit, err)
+	assert.Nil(t, membership)
+	assert.Len(t, store.started, 0)
+
+	store.release <- struct{}{}
+	assert.NoError(t, <-firstErrCh)
+}
+
+func TestConcurrencyLimitQueuedQueryProceedsWhenAnotherFinishes(t *testing.T) {
+	store := newBlockingBigSegmentStore()
+	c := newConcurrencyLimitBigSegmentStore(store, makeConcurrencyLimitConfig(1, time.Minute),
+		config.EnvConfig{}, context.Background())
+
+	firstErrCh := startBlockedQuery(t, c, store)
+	secondErrCh := make(chan error, 1)
+	go func() {
+		_, err := c.GetUserMembership("user2")
+		secondErrCh <- err
+	}()
+	assert.Len(t, store.started, 0)
+
+	store.release <- struct{}{}
+	assert.NoError(t, <-firstErrCh)
+	select {
+	case <-store.started:
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "timed out waiting for queued query to start")
+	}
+	store.release <- struct{}{}
+	assert.NoError(t, <-secondErrCh)
+}
+
+func TestConcurrencyLimitDoesNotApplyToMetadataQueries(t *testing.T) {
+	store := newBlockingBigSegmentStore()
+	c := newConcurrencyLimitBigSegmentStore(store, makeConcurrencyLimitConfig(1, 0),
+		config.EnvConfig{}, context.Background())
+
+	firstErrCh := startBlockedQuery(t, c, store)
+	_, err := c.GetMetadata()
+	assert.NoError(t, err)
+
+	store.release <- struct{}{}
+	assert.NoError(t, <-firstErrCh)
+}
+
+func TestConcurrencyLimitCanBeSetForEnvironment(t *testing.T) {
+	store := newBlockingBigSegmentStore()
+	envConfig := config.EnvConfig{}
+	envConfig.BigSegmentsMaxConcurrentQueries, _ = configtypes.NewOptIntGreaterThanZero(2)
+	c := newConcurrencyLimitBigSegmentStore(store, makeConcurrencyLimitConfig(1, 0), envConfig, context.Background())
+
+	errCh1 := startBlockedQuery(t, c, store)
+	errCh2 := startBlockedQuery(t, c, store)
+	_, err := c.GetUserMembership("user3")
+	assert.Equal(t, errBigSegmentQueryLimit, err)
+
+	store.release <- struct{}{}
+	store.release <- struct{}{}
+	assert.NoError(t, <-errCh1)
+	assert.NoError(t, <-errCh2)
+}
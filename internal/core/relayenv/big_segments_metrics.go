@@ -0,0 +1,80 @@
+package relayenv
+
+import (
+	"context"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/tracing"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldreason"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	ldeval "gopkg.in/launchdarkly/go-server-sdk-evaluation.v1"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+)
+
+// metricsBigSegmentStore wraps the big segment store used by the SDK, recording the latency and
+// errors of each query, and the staleness of the data whenever the metadata is queried. Each query is
+// also traced; since the SDK does not pass a context to the store, these spans have no parent.
+type metricsBigSegmentStore struct {
+	store      interfaces.BigSegmentStore
+	metricsCtx context.Context
+}
+
+func (m metricsBigSegmentStore) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	_, span := m.startSpan(metrics.BigSegmentStoreGetMetadata)
+	defer span.End()
+	startTime := time.Now()
+	metadata, err := m.store.GetMetadata()
+	span.SetError(err)
+	metrics.RecordBigSegmentStoreQuery(m.metricsCtx, metrics.BigSegmentStoreGetMetadata, time.Since(startTime), err)
+	if err == nil && metadata.LastUpToDate.IsDefined() {
+		metrics.RecordBigSegmentStoreStaleness(m.metricsCtx, time.Duration(ldtime.UnixMillisNow()-metadata.LastUpToDate)*time.Millisecond)
+	}
+	return metadata, err
+}
+
+func (m metricsBigSegmentStore) GetUserMembership(userHashKey string) (interfaces.BigSegmentMembership, error) {
+	_, span := m.startSpan(metrics.BigSegmentStoreGetUserMembership)
+	defer span.End()
+	startTime := time.Now()
+	membership, err := m.store.GetUserMembership(userHashKey)
+	span.SetError(err)
+	metrics.RecordBigSegmentStoreQuery(m.metricsCtx, metrics.BigSegmentStoreGetUserMembership, time.Since(startTime), err)
+	return membership, err
+}
+
+// GetUserMemberships implements sdks.BigSegmentBatchStore.
+func (m metricsBigSegmentStore) GetUserMemberships(userHashKeys []string) ([]interfaces.BigSegmentMembership, error) {
+	_, span := m.startSpan(metrics.BigSegmentStoreGetUserMemberships)
+	defer span.End()
+	span.SetAttributes(tracing.Int("ld.big_segment_store.users", len(userHashKeys)))
+	startTime := time.Now()
+	memberships, err := getUserMemberships(m.store, userHashKeys)
+	span.SetError(err)
+	metrics.RecordBigSegmentStoreQuery(m.metricsCtx, metrics.BigSegmentStoreGetUserMemberships, time.Since(startTime), err)
+	return memberships, err
+}
+
+func (m metricsBigSegmentStore) startSpan(operation string) (context.Context, *tracing.Span) {
+	return tracing.StartSpan(context.Background(), "big segment store "+operation, tracing.SpanKindClient,
+		tracing.String("ld.big_segment_store.operation", operation))
+}
+
+func (m metricsBigSegmentStore) Close() error {
+	return m.store.Close()
+}
+
+// metricsBigSegmentProvider wraps the big segment provider used by the evaluator, counting the
+// membership lookups so that they can be compared with the number of store queries.
+type metricsBigSegmentProvider struct {
+	provider   ldeval.BigSegmentProvider
+	metricsCtx context.Context
+}
+
+func (m metricsBigSegmentProvider) GetUserMembership(
+	userKey string,
+) (ldeval.BigSegmentMembership, ldreason.BigSegmentsStatus) {
+	metrics.RecordBigSegmentLookup(m.metricsCtx)
+	return m.provider.GetUserMembership(userKey)
+}
@@ -0,0 +1,140 @@
+package relayenv
+
+import (
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments/membership"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+)
+
+// negativeCacheBigSegmentStore wraps the big segment store used by the SDK, so that users who were
+// found not to be in any big segment are not queried again for a while.
+//
+// Most users are in no big segments, and the SDK's own membership cache holds entries of every kind
+// for the same length of time and in the same limited space. This cache only holds the hash keys of
+// users with no memberships, so it can be much larger; and it is cleared, along with the SDK's cache,
+// whenever Relay receives a big segment update, so its expiry time only needs to cover the case where
+// some other Relay instance applies updates to a shared store.
+//
+// Whether a user is in no big segments is decided by membership.IsEmpty, from the segment references
+// that the store's query returned. Custom stores do not create their results that way, so their users
+// are never remembered.
+//
+// Clear can be called on a nil pointer.
+type negativeCacheBigSegmentStore struct {
+	store   interfaces.BigSegmentStore
+	maxSize int
+	ttl     time.Duration
+	expiry  map[string]time.Time
+	now     func() time.Time
+	lock    sync.Mutex
+}
+
+// newNegativeCacheBigSegmentStore wraps the store in a negative cache if one is set in the
+// configuration; otherwise it returns nil.
+func newNegativeCacheBigSegmentStore(
+	store interfaces.BigSegmentStore,
+	bigSegmentsConfig config.BigSegmentsConfig,
+) *negativeCacheBigSegmentStore {
+	if !bigSegmentsConfig.NegativeCacheSize.IsDefined() {
+		return nil
+	}
+	return &negativeCacheBigSegmentStore{
+		store:   store,
+		maxSize: bigSegmentsConfig.NegativeCacheSize.GetOrElse(0),
+		ttl:     bigSegmentsConfig.NegativeCacheTime.GetOrElse(config.DefaultBigSegmentsNegativeCacheTime),
+		expiry:  make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+func (n *negativeCacheBigSegmentStore) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	return n.store.GetMetadata()
+}
+
+func (n *negativeCacheBigSegmentStore) GetUserMembership(userHashKey string) (interfaces.BigSegmentMembership, error) {
+	if n.isKnownNegative(userHashKey) {
+		return membership.FromSegmentRefs(nil, nil), nil
+	}
+	m, err := n.store.GetUserMembership(userHashKey)
+	if err == nil && membership.IsEmpty(m) {
+		n.rememberNegative(userHashKey)
+	}
+	return m, err
+}
+
+// GetUserMemberships implements sdks.BigSegmentBatchStore. Only the users who are not known to be in
+// no big segments are queried.
+func (n *negativeCacheBigSegmentStore) GetUserMemberships(
+	userHashKeys []string,
+) ([]interfaces.BigSegmentMembership, error) {
+	ret := make([]interfaces.BigSegmentMembership, len(userHashKeys))
+	var queryIndexes []int
+	var queryHashKeys []string
+	for i, userHashKey := range userHashKeys {
+		if n.isKnownNegative(userHashKey) {
+			ret[i] = membership.FromSegmentRefs(nil, nil)
+		} else {
+			queryIndexes = append(queryIndexes, i)
+			queryHashKeys = append(queryHashKeys, userHashKey)
+		}
+	}
+	if len(queryHashKeys) == 0 {
+		return ret, nil
+	}
+	memberships, err := getUserMemberships(n.store, queryHashKeys)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range memberships {
+		ret[queryIndexes[i]] = m
+		if membership.IsEmpty(m) {
+			n.rememberNegative(queryHashKeys[i])
+		}
+	}
+	return ret, nil
+}
+
+func (n *negativeCacheBigSegmentStore) Close() error {
+	return n.store.Close()
+}
+
+// Clear discards all cached results. This is called when the big segment data changes, at the same time
+// as the SDK's membership cache is cleared.
+func (n *negativeCacheBigSegmentStore) Clear() {
+	if n == nil {
+		return
+	}
+	n.lock.Lock()
+	n.expiry = make(map[string]time.Time)
+	n.lock.Unlock()
+}
+
+func (n *negativeCacheBigSegmentStore) isKnownNegative(userHashKey string) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	expiry, ok := n.expiry[userHashKey]
+	if !ok {
+		return false
+	}
+	if n.now().Before(expiry) {
+		return true
+	}
+	delete(n.expiry, userHashKey)
+	return false
+}
+
+func (n *negativeCacheBigSegmentStore) rememberNegative(userHashKey string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if _, ok := n.expiry[userHashKey]; !ok && len(n.expiry) >= n.maxSize {
+		for key := range n.expiry { // discard an arbitrary entry to make room
+			delete(n.expiry, key)
+			break
+		}
+	}
+	n.expiry[userHashKey] = n.now().Add(n.ttl)
+}
@@ -0,0 +1,131 @@
+package relayenv
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeNegativeCacheConfig(size int, ttl time.Duration) config.BigSegmentsConfig {
+	c := config.BigSegmentsConfig{NegativeCacheTime: configtypes.NewOptDuration(ttl)}
+	c.NegativeCacheSize, _ = configtypes.NewOptIntGreaterThanZero(size)
+	return c
+}
+
+func TestNegativeCacheIsNotCreatedByDefault(t *testing.T) {
+	assert.Nil(t, newNegativeCacheBigSegmentStore(&fakeBatchBigSegmentStore{}, config.BigSegmentsConfig{}))
+}
+
+func TestNegativeCacheRemembersUsersInNoSegments(t *testing.T) {
+	store := &fakeBatchBigSegmentStore{included: map[string]bool{"a": true}}
+	n := newNegativeCacheBigSegmentStore(store, makeNegativeCacheConfig(10, time.Hour))
+
+	for i := 0; i < 2; i++ {
+		m, err := n.GetUserMembership("a")
+		require.NoError(t, err)
+		assert.Equal(t, ldvalue.NewOptionalBool(true), m.CheckMembership("segment.g1"))
+		m, err = n.GetUserMembership("b")
+		require.NoError(t, err)
+		assert.Equal(t, ldvalue.OptionalBool{}, m.CheckMembership("segment.g1"))
+	}
+	assert.Equal(t, 3, store.queries) // "a" twice, "b" once
+}
+
+func TestNegativeCacheEntriesExpire(t *testing.T) {
+	store := &fakeBatchBigSegmentStore{}
+	n := newNegativeCacheBigSegmentStore(store, makeNegativeCacheConfig(10, time.Second))
+	now := time.Now()
+	n.now = func() time.Time { return now }
+
+	_, _ = n.GetUserMembership("a")
+	_, _ = n.GetUserMembership("a")
+	assert.Equal(t, 1, store.queries)
+
+	now = now.Add(time.Second)
+	_, _ = n.GetUserMembership("a")
+	assert.Equal(t, 2, store.queries)
+}
+
+func TestNegativeCacheDoesNotRememberErrors(t *testing.T) {
+	store := &fakeBatchBigSegmentStore{err: errors.New("sorry")}
+	n := newNegativeCacheBigSegmentStore(store, makeNegativeCacheConfig(10, time.Hour))
+
+	for i := 0; i < 2; i++ {
+		_, err := n.GetUserMembership("a")
+		assert.Equal(t, store.err, err)
+	}
+	assert.Equal(t, 2, store.queries)
+}
+
+func TestNegativeCacheSizeIsLimited(t *testing.T) {
+	store := &fakeBatchBigSegmentStore{}
+	n := newNegativeCacheBigSegmentStore(store, makeNegativeCacheConfig(2, time.Hour))
+
+	for _, userHashKey := range []string{"a", "b", "c"} {
+		_, _ = n.GetUserMembership(userHashKey)
+	}
+	assert.Len(t, n.expiry, 2)
+}
+
+func TestNegativeCacheClear(t *testing.T) {
+	store := &fakeBatchBigSegmentStore{}
+	n := newNegativeCacheBigSegmentStore(store, makeNegativeCacheConfig(10, time.Hour))
+
+	_, _ = n.GetUserMembership("a")
+	n.Clear()
+	_, _ = n.GetUserMembership("a")
+	assert.Equal(t, 2, store.queries)
+
+	var nilCache *negativeCacheBigSegmentStore
+	nilCache.Clear()
+}
+
+func TestNegativeCacheBatchQueriesOnlyUnknownUsers(t *testing.T) {
+	store := &fakeBatchBigSegmentStore{included: map[string]bool{"a": true}}
+	n := newNegativeCacheBigSegmentStore(store, makeNegativeCacheConfig(10, time.Hour))
+	var _ sdks.BigSegmentBatchStore = n
+
+	_, _ = n.GetUserMembership("b")
+	memberships, err := getUserMemberships(n, []string{"a", "b", "c"})
+	require.NoError(t, err)
+	require.Len(t, memberships, 3)
+	assert.Equal(t, ldvalue.NewOptionalBool(true), memberships[0].CheckMembership("segment.g1"))
+	assert.Equal(t, ldvalue.OptionalBool{}, memberships[1].CheckMembership("segment.g1"))
+	assert.Equal(t, ldvalue.OptionalBool{}, memberships[2].CheckMembership("segment.g1"))
+	assert.Equal(t, [][]string{{"a", "c"}}, store.batchQueries)
+
+	_, err = getUserMemberships(n, []string{"b", "c"})
+	require.NoError(t, err)
+	assert.Len(t, store.batchQueries, 1)
+}
+
+// customBigSegmentStore returns memberships that were created by the SDK rather than by
+// membership.FromSegmentRefs, as a custom big segment store would.
+type customBigSegmentStore struct {
+	fakeFailingBigSegmentStore
+}
+
+func (s *customBigSegmentStore) GetUserMembership(string) (interfaces.BigSegmentMembership, error) {
+	s.queries++
+	return ldstoreimpl.NewBigSegmentMembershipFromSegmentRefs(nil, nil), nil
+}
+
+func TestNegativeCacheDoesNotRememberUsersFromOtherStores(t *testing.T) {
+	store := &customBigSegmentStore{}
+	n := newNegativeCacheBigSegmentStore(store, makeNegativeCacheConfig(10, time.Hour))
+
+	_, _ = n.GetUserMembership("a")
+	_, _ = n.GetUserMembership("a")
+	assert.Equal(t, 2, store.queries)
+}
@@ -0,0 +1,40 @@
+package relayenv
+
+import (
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldreason"
+	ldeval "gopkg.in/launchdarkly/go-server-sdk-evaluation.v1"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+)
+
+// failClosedBigSegmentProvider wraps the big segment provider used by the evaluator when the
+// environment's big segments stale mode is "failClosed". While the data is stale, it discards the
+// membership data so that users are not treated as included in or excluded from any big segment;
+// the evaluation reason still reports the STALE status.
+type failClosedBigSegmentProvider struct {
+	provider ldeval.BigSegmentProvider
+}
+
+func (f failClosedBigSegmentProvider) GetUserMembership(
+	userKey string,
+) (ldeval.BigSegmentMembership, ldreason.BigSegmentsStatus) {
+	membership, status := f.provider.GetUserMembership(userKey)
+	if status == ldreason.BigSegmentsStale {
+		return nil, status
+	}
+	return membership, status
+}
+
+// makeBigSegmentsStatusLogger returns a function to be called by the SDK's big segment store wrapper
+// whenever the store status changes, which logs a warning when the data becomes stale.
+func makeBigSegmentsStatusLogger(loggers ldlog.Loggers) func(interfaces.BigSegmentStoreStatus) {
+	wasStale := false
+	return func(status interfaces.BigSegmentStoreStatus) {
+		if status.Stale && !wasStale {
+			loggers.Warn("Big segment data is stale; it has not been updated within the configured stale-after time")
+		} else if !status.Stale && wasStale {
+			loggers.Info("Big segment data is no longer stale")
+		}
+		wasStale = status.Stale
+	}
+}
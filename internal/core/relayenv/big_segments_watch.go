@@ -0,0 +1,107 @@
+package relayenv
+
+import (
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+// bigSegmentCursorWatcher periodically reads the synchronization cursor from the big segment store, and
+// calls onChange whenever it differs from the last value that was read. The cursor changes whenever any
+// Relay instance applies a big segment update to the store, so this is how an instance that is not
+// synchronizing the store itself (such as in read replica mode) finds out that the data has changed.
+//
+// Start and Close can be called on a nil pointer.
+type bigSegmentCursorWatcher struct {
+	store     bigsegments.BigSegmentStore
+	interval  time.Duration
+	onChange  func()
+	loggers   ldlog.Loggers
+	cursor    string
+	hasCursor bool
+	failing   bool
+	startOnce sync.Once
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+// newBigSegmentCursorWatcher returns a watcher if BigSegments.WatchInterval is set, or if it is read
+// replica mode; otherwise it returns nil.
+func newBigSegmentCursorWatcher(
+	store bigsegments.BigSegmentStore,
+	allConfig config.Config,
+	onChange func(),
+	loggers ldlog.Loggers,
+) *bigSegmentCursorWatcher {
+	interval := allConfig.BigSegments.WatchInterval.GetOrElse(0)
+	if !allConfig.BigSegments.WatchInterval.IsDefined() && allConfig.ReadReplica.Enabled {
+		interval = allConfig.ReadReplica.PollInterval.GetOrElse(config.DefaultReadReplicaPollInterval)
+	}
+	if interval <= 0 {
+		return nil
+	}
+	return &bigSegmentCursorWatcher{
+		store:    store,
+		interval: interval,
+		onChange: onChange,
+		loggers:  loggers,
+		closeCh:  make(chan struct{}),
+	}
+}
+
+// Start begins polling, if it has not already begun. Like the big segment synchronizer, this is not
+// done until the environment is known to have a big segment.
+func (w *bigSegmentCursorWatcher) Start() {
+	if w == nil {
+		return
+	}
+	w.startOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(w.interval)
+			defer ticker.Stop()
+			for {
+				w.poll()
+				select {
+				case <-w.closeCh:
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	})
+}
+
+func (w *bigSegmentCursorWatcher) Close() {
+	if w == nil {
+		return
+	}
+	w.closeOnce.Do(func() {
+		close(w.closeCh)
+	})
+}
+
+// poll is only called from the polling goroutine, so it does not need a lock.
+func (w *bigSegmentCursorWatcher) poll() {
+	cursor, err := w.store.GetCursor()
+	if err != nil {
+		if !w.failing {
+			w.loggers.Warnf("Unable to read big segment synchronization cursor from the store: %s", err)
+			w.failing = true
+		}
+		return
+	}
+	if w.failing {
+		w.loggers.Info("Big segment synchronization cursor is available again from the store")
+		w.failing = false
+	}
+	changed := w.hasCursor && cursor != w.cursor
+	w.cursor, w.hasCursor = cursor, true
+	if changed {
+		w.loggers.Debugf("Big segment synchronization cursor changed to %q", cursor)
+		w.onChange()
+	}
+}
@@ -0,0 +1,121 @@
+package relayenv
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cursorBigSegmentStore struct {
+	bigsegments.BigSegmentStore
+	cursor string
+	err    error
+	lock   sync.Mutex
+}
+
+func (s *cursorBigSegmentStore) GetCursor() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.cursor, s.err
+}
+
+func (s *cursorBigSegmentStore) setCursor(cursor string) {
+	s.lock.Lock()
+	s.cursor = cursor
+	s.lock.Unlock()
+}
+
+func TestBigSegmentCursorWatcherInterval(t *testing.T) {
+	store := &cursorBigSegmentStore{}
+
+	assert.Nil(t, newBigSegmentCursorWatcher(store, config.Config{}, func() {}, ldlog.NewDisabledLoggers()))
+
+	var c config.Config
+	c.BigSegments.WatchInterval = configtypes.NewOptDuration(time.Second * 2)
+	w := newBigSegmentCursorWatcher(store, c, func() {}, ldlog.NewDisabledLoggers())
+	require.NotNil(t, w)
+	assert.Equal(t, time.Second*2, w.interval)
+
+	c = config.Config{ReadReplica: config.ReadReplicaConfig{Enabled: true}}
+	w = newBigSegmentCursorWatcher(store, c, func() {}, ldlog.NewDisabledLoggers())
+	require.NotNil(t, w)
+	assert.Equal(t, config.DefaultReadReplicaPollInterval, w.interval)
+
+	c.ReadReplica.PollInterval = configtypes.NewOptDuration(time.Second * 3)
+	w = newBigSegmentCursorWatcher(store, c, func() {}, ldlog.NewDisabledLoggers())
+	require.NotNil(t, w)
+	assert.Equal(t, time.Second*3, w.interval)
+}
+
+func TestBigSegmentCursorWatcherCallsOnChangeWhenCursorChanges(t *testing.T) {
+	store := &cursorBigSegmentStore{cursor: "a"}
+	changes := 0
+	var c config.Config
+	c.BigSegments.WatchInterval = configtypes.NewOptDuration(time.Hour)
+	w := newBigSegmentCursorWatcher(store, c, func() { changes++ }, ldlog.NewDisabledLoggers())
+
+	w.poll() // the first value read is not a change
+	w.poll()
+	assert.Equal(t, 0, changes)
+
+	store.cursor = "b"
+	w.poll()
+	assert.Equal(t, 1, changes)
+	w.poll()
+	assert.Equal(t, 1, changes)
+}
+
+func TestBigSegmentCursorWatcherIgnoresErrors(t *testing.T) {
+	store := &cursorBigSegmentStore{cursor: "a"}
+	changes := 0
+	mockLog := ldlogtest.NewMockLog()
+	var c config.Config
+	c.BigSegments.WatchInterval = configtypes.NewOptDuration(time.Hour)
+	w := newBigSegmentCursorWatcher(store, c, func() { changes++ }, mockLog.Loggers)
+
+	w.poll()
+	store.err = errors.New("sorry")
+	w.poll()
+	w.poll()
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 1)
+
+	store.err = nil
+	w.poll()
+	assert.Equal(t, 0, changes)
+	assert.Len(t, mockLog.GetOutput(ldlog.Info), 1)
+}
+
+func TestBigSegmentCursorWatcherStartAndClose(t *testing.T) {
+	store := &cursorBigSegmentStore{cursor: "a"}
+	changedCh := make(chan struct{}, 10)
+	var c config.Config
+	c.BigSegments.WatchInterval = configtypes.NewOptDuration(time.Millisecond * 10)
+	w := newBigSegmentCursorWatcher(store, c, func() { changedCh <- struct{}{} }, ldlog.NewDisabledLoggers())
+
+	w.Start()
+	w.Start() // has no effect if already started
+	time.Sleep(time.Millisecond * 50)
+	store.setCursor("b")
+	select {
+	case <-changedCh:
+	case <-time.After(time.Second):
+		assert.Fail(t, "timed out waiting for change")
+	}
+	w.Close()
+	w.Close()
+
+	var nilWatcher *bigSegmentCursorWatcher
+	nilWatcher.Start()
+	nilWatcher.Close()
+}
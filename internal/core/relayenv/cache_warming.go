@@ -0,0 +1,41 @@
+package relayenv
+
+import (
+	"time"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+// warmDataStoreCache reads all of the environment's flags and segments from the persistent data store
+// before the environment is reported as ready, so that they are already in the store's in-memory
+// cache when requests start to arrive, rather than every request on a new instance going to the
+// database at once. If there are big segments, it also reads the big segment store's status, which
+// opens the connection; memberships are cached per user, so they can only be loaded as users are
+// evaluated.
+//
+// Errors are logged but do not prevent the environment from becoming ready, since the same reads will
+// be retried when the data is requested.
+func (c *envContextImpl) warmDataStoreCache() {
+	store := c.storeAdapter.GetStore()
+	if store == nil {
+		return
+	}
+	startTime := time.Now()
+	var counts []int
+	for _, kind := range []ldstoretypes.DataKind{ldstoreimpl.Features(), ldstoreimpl.Segments()} {
+		items, err := store.GetAll(kind)
+		if err != nil {
+			c.loggers.Warnf("Unable to load %s from the data store while warming the cache: %s", kind, err)
+			return
+		}
+		counts = append(counts, len(items))
+	}
+	if c.sdkBigSegments != nil {
+		if status := c.sdkBigSegments.GetStatus(); !status.Available {
+			c.loggers.Warn("Big segment store was not available while warming the cache")
+		}
+	}
+	c.loggers.Infof("Warmed the data store cache with %d flags and %d segments in %s",
+		counts[0], counts[1], time.Since(startTime))
+}
@@ -0,0 +1,121 @@
+package relayenv
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingPersistentStore is a PersistentDataStore that has one flag and counts how many times
+// GetAll is called for each kind.
+type countingPersistentStore struct {
+	getAllCalls map[string]int
+}
+
+func (s *countingPersistentStore) CreatePersistentDataStore(interfaces.ClientContext) (interfaces.PersistentDataStore, error) {
+	return s, nil
+}
+
+func (s *countingPersistentStore) Init([]ldstoretypes.SerializedCollection) error { return nil }
+
+func (s *countingPersistentStore) Get(ldstoretypes.DataKind, string) (ldstoretypes.SerializedItemDescriptor, error) {
+	return ldstoretypes.SerializedItemDescriptor{}.NotFound(), nil
+}
+
+func (s *countingPersistentStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	s.getAllCalls[kind.GetName()]++
+	if kind == ldstoreimpl.Features() {
+		return []ldstoretypes.KeyedSerializedItemDescriptor{
+			{Key: "flag1", Item: ldstoretypes.SerializedItemDescriptor{Version: 1, SerializedItem: []byte(`{"key":"flag1","version":1}`)}},
+		}, nil
+	}
+	return nil, nil
+}
+
+func (s *countingPersistentStore) Upsert(ldstoretypes.DataKind, string, ldstoretypes.SerializedItemDescriptor) (bool, error) {
+	return false, nil
+}
+
+func (s *countingPersistentStore) IsInitialized() bool    { return true }
+func (s *countingPersistentStore) IsStoreAvailable() bool { return true }
+func (s *countingPersistentStore) Close() error           { return nil }
+
+// persistentDataStoreFactory creates a persistent data store wrapper for a fake SDK client, which does not
+// provide the DataStoreUpdates that the wrapper needs for reporting its status.
+type persistentDataStoreFactory struct {
+	factory interfaces.DataStoreFactory
+}
+
+func (f persistentDataStoreFactory) CreateDataStore(
+	context interfaces.ClientContext,
+	_ interfaces.DataStoreUpdates,
+) (interfaces.DataStore, error) {
+	return f.factory.CreateDataStore(context, noOpDataStoreUpdates{})
+}
+
+type noOpDataStoreUpdates struct{}
+
+func (noOpDataStoreUpdates) UpdateStatus(interfaces.DataStoreStatus) {}
+
+func makeEnvWithCountingStore(t *testing.T, warmCache bool, loggers ldlog.Loggers) (EnvContext, *countingPersistentStore) {
+	persistentStore := &countingPersistentStore{getAllCalls: make(map[string]int)}
+	var allConfig config.Config
+	allConfig.Main.WarmCacheOnStartup = warmCache
+	readyCh := make(chan EnvContext, 1)
+	env, err := NewEnvContext(EnvContextImplParams{
+		Identifiers:      EnvIdentifiers{ConfiguredName: envName},
+		EnvConfig:        st.EnvMain.Config,
+		AllConfig:        allConfig,
+		ClientFactory:    testclient.FakeLDClientFactory(false),
+		DataStoreFactory: persistentDataStoreFactory{ldcomponents.PersistentDataStore(persistentStore).CacheForever()},
+		DataStoreInfo:    sdks.DataStoreEnvironmentInfo{DBType: "test"},
+		Loggers:          loggers,
+	}, readyCh)
+	require.NoError(t, err)
+	requireEnvReady(t, readyCh)
+	return env, persistentStore
+}
+
+func TestCacheIsWarmedBeforeEnvironmentIsReady(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+
+	env, persistentStore := makeEnvWithCountingStore(t, true, mockLog.Loggers)
+	defer env.Close()
+
+	assert.Equal(t, map[string]int{"features": 1, "segments": 1}, persistentStore.getAllCalls)
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "Warmed the data store cache with 1 flags and 0 segments")
+
+	// the client was not initialized, but the persistent store was
+	assert.True(t, env.IsReady())
+
+	// subsequent reads come from the cache
+	items, err := env.GetStore().GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, 1, persistentStore.getAllCalls["features"])
+}
+
+func TestCacheIsNotWarmedIfNotEnabled(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+
+	env, persistentStore := makeEnvWithCountingStore(t, false, mockLog.Loggers)
+	defer env.Close()
+
+	assert.Len(t, persistentStore.getAllCalls, 0)
+	assert.True(t, env.IsReady())
+}
@@ -0,0 +1,59 @@
+package relayenv
+
+import (
+	"net/http"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+)
+
+// CanaryRoute describes the canary environment that serves an environment's requests when they have
+// the configured header (see config.EnvConfig.CanaryEnvironment). The canary environment is identified
+// by its credentials, since that is how RelayCore looks up environments.
+type CanaryRoute struct {
+	header      string
+	value       string
+	credentials map[basictypes.SDKKind]config.SDKCredential
+}
+
+// NewCanaryRoute returns the CanaryRoute for an environment, or nil if it does not have a canary
+// environment. ValidateConfig has already checked that the canary environment exists.
+func NewCanaryRoute(envConfig config.EnvConfig, allConfig config.Config) *CanaryRoute {
+	canary := allConfig.Environment[envConfig.CanaryEnvironment]
+	if envConfig.CanaryEnvironment == "" || envConfig.CanaryHeader == "" || canary == nil {
+		return nil
+	}
+	credentials := map[basictypes.SDKKind]config.SDKCredential{basictypes.ServerSDK: canary.SDKKey}
+	if canary.MobileKey != "" {
+		credentials[basictypes.MobileSDK] = canary.MobileKey
+	}
+	if canary.EnvID != "" {
+		credentials[basictypes.JSClientSDK] = canary.EnvID
+	}
+	return &CanaryRoute{
+		header:      envConfig.CanaryHeader,
+		value:       envConfig.CanaryHeaderValue,
+		credentials: credentials,
+	}
+}
+
+// GetHeader returns the name of the header that selects the canary environment.
+func (r *CanaryRoute) GetHeader() string {
+	return r.header
+}
+
+// Matches returns true if the request should be served by the canary environment: that is, if it has
+// the header with the configured value, or with any value if none was configured.
+func (r *CanaryRoute) Matches(req *http.Request) bool {
+	value := req.Header.Get(r.header)
+	if r.value == "" {
+		return value != ""
+	}
+	return value == r.value
+}
+
+// GetCredential returns the canary environment's credential of the kind that the specified kind of SDK
+// uses, or nil if the canary environment does not have one.
+func (r *CanaryRoute) GetCredential(sdkKind basictypes.SDKKind) config.SDKCredential {
+	return r.credentials[sdkKind]
+}
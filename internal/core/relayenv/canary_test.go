@@ -0,0 +1,57 @@
+package relayenv
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCanaryRoute(t *testing.T) {
+	canaryConfig := config.EnvConfig{SDKKey: "canary-sdk-key", EnvID: "canary-env-id"}
+	allConfig := config.Config{Environment: map[string]*config.EnvConfig{"canary": &canaryConfig}}
+
+	t.Run("no canary environment", func(t *testing.T) {
+		assert.Nil(t, NewCanaryRoute(config.EnvConfig{}, allConfig))
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		route := NewCanaryRoute(config.EnvConfig{CanaryEnvironment: "canary", CanaryHeader: "X-Canary"}, allConfig)
+		require.NotNil(t, route)
+		assert.Equal(t, "X-Canary", route.GetHeader())
+		assert.Equal(t, canaryConfig.SDKKey, route.GetCredential(basictypes.ServerSDK))
+		assert.Equal(t, canaryConfig.EnvID, route.GetCredential(basictypes.JSClientSDK))
+		assert.Nil(t, route.GetCredential(basictypes.MobileSDK))
+	})
+}
+
+func TestCanaryRouteMatches(t *testing.T) {
+	canaryConfig := config.EnvConfig{SDKKey: "canary-sdk-key"}
+	allConfig := config.Config{Environment: map[string]*config.EnvConfig{"canary": &canaryConfig}}
+	requestWithHeader := func(value string) *http.Request {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if value != "" {
+			req.Header.Set("X-Canary", value)
+		}
+		return req
+	}
+
+	t.Run("any value", func(t *testing.T) {
+		route := NewCanaryRoute(config.EnvConfig{CanaryEnvironment: "canary", CanaryHeader: "X-Canary"}, allConfig)
+		assert.True(t, route.Matches(requestWithHeader("yes")))
+		assert.True(t, route.Matches(requestWithHeader("no")))
+		assert.False(t, route.Matches(requestWithHeader("")))
+	})
+
+	t.Run("specific value", func(t *testing.T) {
+		route := NewCanaryRoute(config.EnvConfig{CanaryEnvironment: "canary", CanaryHeader: "X-Canary",
+			CanaryHeaderValue: "yes"}, allConfig)
+		assert.True(t, route.Matches(requestWithHeader("yes")))
+		assert.False(t, route.Matches(requestWithHeader("no")))
+		assert.False(t, route.Matches(requestWithHeader("")))
+	})
+}
@@ -0,0 +1,26 @@
+package relayenv
+
+import (
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+)
+
+// databasePoolStatsProvider adapts the environment's database pools to the metrics package, which
+// reads their stats each time the internal metrics endpoint is scraped.
+func databasePoolStatsProvider(pools *sdks.DatabasePools) func() []metrics.DatabasePoolStats {
+	return func() []metrics.DatabasePoolStats {
+		poolStats := pools.Stats()
+		ret := make([]metrics.DatabasePoolStats, 0, len(poolStats))
+		for _, s := range poolStats {
+			ret = append(ret, metrics.DatabasePoolStats{
+				Pool:         s.Pool,
+				InUse:        s.InUse,
+				Idle:         s.Idle,
+				MaxOpen:      s.MaxOpen,
+				WaitCount:    s.WaitCount,
+				WaitDuration: s.WaitDuration,
+			})
+		}
+		return ret
+	}
+}
@@ -9,9 +9,15 @@ import (
 
 	"github.com/launchdarkly/ld-relay/v6/config"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/clientcert"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/evalcache"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/events"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/jwtauth"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/pollcache"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/ratelimit"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/streams"
+	"github.com/launchdarkly/ld-relay/v6/internal/flagfilter"
 
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	ldeval "gopkg.in/launchdarkly/go-server-sdk-evaluation.v1"
@@ -33,6 +39,10 @@ type EnvContext interface {
 	// SetIdentifiers updates the environment and project names and keys.
 	SetIdentifiers(EnvIdentifiers)
 
+	// GetTenant returns the name of the tenant that this environment belongs to (see config.TenantConfig),
+	// or "" if none.
+	GetTenant() string
+
 	// GetCredentials returns all currently enabled and non-deprecated credentials for the environment.
 	GetCredentials() []config.SDKCredential
 
@@ -42,7 +52,8 @@ type EnvContext interface {
 	// AddCredential adds a new credential for the environment.
 	//
 	// If the credential is an SDK key, then a new SDK client is started with that SDK key, and event forwarding
-	// to server-side endpoints is switched to use the new key.
+	// to server-side endpoints is switched to use the new key. If the credential already exists but was
+	// deprecated, it becomes a preferred credential again.
 	AddCredential(config.SDKCredential)
 
 	// RemoveCredential removes a credential from the environment. Any active stream connections using that
@@ -69,10 +80,24 @@ type EnvContext interface {
 	// This is nil if initialization is not yet complete.
 	GetEvaluator() ldeval.Evaluator
 
+	// GetBigSegmentProvider returns the source of big segment memberships that the evaluator uses. This is
+	// nil if big segments are not configured or if initialization is not yet complete.
+	GetBigSegmentProvider() ldeval.BigSegmentProvider
+
 	// GetBigSegmentStore returns the big segment data store instance for this environment. If a big
 	// segment store is not configured this returns nil.
 	GetBigSegmentStore() bigsegments.BigSegmentStore
 
+	// PrefetchBigSegmentMemberships queries the big segment memberships of many users at once, before
+	// flags are evaluated for them, if the big segment store supports batch queries; otherwise it does
+	// nothing, and the memberships are queried separately during evaluation. The caller must call the
+	// returned function when it has finished evaluating flags for those users.
+	PrefetchBigSegmentMemberships(userKeys []string) func()
+
+	// GetBigSegmentsStaleThreshold returns the length of time after which this environment's big segment
+	// data is considered stale if it has not been updated.
+	GetBigSegmentsStaleThreshold() time.Duration
+
 	// GetLoggers returns a Loggers instance that is specific to this environment. We configure each of these to
 	// have its own prefix string and, optionally, its own log level.
 	GetLoggers() ldlog.Loggers
@@ -91,6 +116,48 @@ type EnvContext interface {
 	// environment.
 	GetMetricsContext() context.Context
 
+	// GetClientCertPolicy returns the policy for verifying TLS client certificates in SDK requests to this
+	// environment. This is nil if client certificates are not required.
+	GetClientCertPolicy() *clientcert.Policy
+
+	// GetJWTVerifier returns the verifier for the JWTs that client-side and mobile SDK requests to this
+	// environment must have. This is nil if the environment does not use JWT authorization.
+	GetJWTVerifier() *jwtauth.Verifier
+
+	// GetClientSideFlagFilter returns the filter that determines which flags the client-side and mobile
+	// evaluation endpoints return for this environment, in addition to the flags' own client-side
+	// availability settings. The zero value returns every flag.
+	GetClientSideFlagFilter() flagfilter.Filter
+
+	// GetEvalCache returns the cache for the environment's client-side and mobile evaluation responses.
+	// This is nil if caching is not enabled for the environment.
+	GetEvalCache() *evalcache.Cache
+
+	// GetPollingCache returns the environment's caching settings and cached responses for the PHP SDK
+	// polling endpoints. This is nil if the environment only uses its default TTL.
+	GetPollingCache() *pollcache.Cache
+
+	// GetCanaryRoute returns the canary environment that serves this environment's requests when they
+	// have a particular header. This is nil if the environment has no canary environment.
+	GetCanaryRoute() *CanaryRoute
+
+	// GetChangeFeedHandler returns the HTTP handler for the environment's change feed stream. This is nil if
+	// the environment does not have a change feed token. If streamMiddleware is not nil, it is applied to
+	// the stream after the request's token has been checked.
+	GetChangeFeedHandler(streamMiddleware func(http.Handler) http.Handler) http.Handler
+
+	// GetRateLimiter returns the rate limiter for SDK requests to this environment. This is nil if there
+	// are no rate limits.
+	GetRateLimiter() *ratelimit.Limiter
+
+	// GetQuota returns the resource quotas for this environment, if it is an auto-configured environment
+	// and quotas are set in AutoConfigConfig. Otherwise it is nil; a nil Quota allows everything.
+	GetQuota() *ratelimit.Quota
+
+	// GetStreamLimiter returns the limit on concurrent stream connections to this environment. This is nil
+	// if there is no limit.
+	GetStreamLimiter() *ratelimit.StreamLimiter
+
 	// GetTTL returns the configured cache TTL for PHP SDK endpoints for this environment.
 	GetTTL() time.Duration
 
@@ -107,9 +174,35 @@ type EnvContext interface {
 	// SetSecureMode changes the secure mode setting.
 	SetSecureMode(bool)
 
+	// SetLogLevel changes the environment's log level, which applies to all of its messages other than
+	// those from subsystems that have their own level in the configuration. If the level is undefined,
+	// the environment uses Relay's global log level.
+	SetLogLevel(config.OptLogLevel)
+
+	// IsKillSwitchOn returns true if the environment's kill switch is on. While it is on, Relay serves only
+	// the fallback flags from the environment's kill switch file, if any, instead of the flags that it has
+	// received from LaunchDarkly, and discards analytics events.
+	IsKillSwitchOn() bool
+
+	// SetKillSwitch turns the environment's kill switch on or off, and sends the resulting flag data to
+	// every connected SDK. Turning it on reads the kill switch file, so turning it on again applies any
+	// changes to the file; it returns an error, and changes nothing, if the file cannot be read.
+	SetKillSwitch(bool) error
+
+	// GetKillSwitchGeneration returns zero if the kill switch is off, or otherwise a number that is
+	// different each time the kill switch is turned on. The flags that are served while it is on keep
+	// their versions, so this is part of the ETags of flag data; otherwise, a client or CDN that already
+	// had the same versions would keep the flags from before the change.
+	GetKillSwitchGeneration() uint64
+
 	// GetCreationTime returns the time that this EnvContext was created.
 	GetCreationTime() time.Time
 
+	// IsReady returns true if the environment has finished starting up and has flag data to serve: the
+	// SDK client has been created, the cache has been warmed if MainConfig.WarmCacheOnStartup is set, and
+	// either the client has received data from LaunchDarkly or the data store was already initialized.
+	IsReady() bool
+
 	// GetDataStoreInfo returns information about the environment's data store.
 	GetDataStoreInfo() sdks.DataStoreEnvironmentInfo
 
@@ -2,21 +2,38 @@ package relayenv
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/clientcert"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/evalcache"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/events"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/store"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/jwtauth"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/leader"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/logging"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/pollcache"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/ratelimit"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/replication"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/streams"
+	"github.com/launchdarkly/ld-relay/v6/internal/eventtransform"
+	"github.com/launchdarkly/ld-relay/v6/internal/flagchange"
+	"github.com/launchdarkly/ld-relay/v6/internal/flagfilter"
+	"github.com/launchdarkly/ld-relay/v6/internal/flagoverrides"
 	"github.com/launchdarkly/ld-relay/v6/internal/util"
 
+	ct "github.com/launchdarkly/go-configtypes"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	ldeval "gopkg.in/launchdarkly/go-server-sdk-evaluation.v1"
 	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
@@ -46,10 +63,26 @@ func errInitPublisher(err error) error {
 	return fmt.Errorf("failed to initialize event publisher: %w", err)
 }
 
+func errInitEventSpool(err error) error {
+	return fmt.Errorf("failed to initialize event spool: %w", err)
+}
+
+func errInitEventTransform(err error) error {
+	return fmt.Errorf("failed to initialize event transformers: %w", err)
+}
+
+func errInitEventCoordination(err error) error {
+	return fmt.Errorf("failed to initialize event coordination: %w", err)
+}
+
 func errInitMetrics(err error) error {
 	return fmt.Errorf("failed to initialize metrics for environment: %w", err)
 }
 
+func errInitFlagOverrides(err error) error {
+	return fmt.Errorf("failed to initialize flag overrides: %w", err)
+}
+
 // EnvContextImplParams contains the constructor parameters for NewEnvContextImpl. These have their
 // own type because there are a lot of them, and many are irrelevant in tests.
 type EnvContextImplParams struct {
@@ -62,43 +95,93 @@ type EnvContextImplParams struct {
 	StreamProviders               []streams.StreamProvider
 	JSClientContext               JSClientContext
 	MetricsManager                *metrics.Manager
+	EventExporter                 *events.EventExporter
 	BigSegmentStoreFactory        bigsegments.BigSegmentStoreFactory
 	BigSegmentSynchronizerFactory bigsegments.BigSegmentSynchronizerFactory
 	SDKBigSegmentsConfigFactory   interfaces.BigSegmentsConfigurationFactory // set only in tests
+	UpstreamFailover              *httpconfig.UpstreamFailover
+	LeaderElector                 *leader.Elector
 	UserAgent                     string
 	LogNameMode                   LogNameMode
 	Loggers                       ldlog.Loggers
+	GlobalLogLevel                *logging.LevelSwitch // if set, Loggers follow it, and so does the environment unless it has its own LogLevel
 }
 
 type envContextImpl struct {
-	mu               sync.RWMutex
-	clients          map[config.SDKKey]sdks.LDClientContext
-	storeAdapter     *store.SSERelayDataStoreAdapter
-	loggers          ldlog.Loggers
-	credentials      map[config.SDKCredential]bool // true if not deprecated
-	identifiers      EnvIdentifiers
-	secureMode       bool
-	envStreams       *streams.EnvStreams
-	streamProviders  []streams.StreamProvider
-	handlers         map[streams.StreamProvider]map[config.SDKCredential]http.Handler
-	jsContext        JSClientContext
-	evaluator        ldeval.Evaluator
-	eventDispatcher  *events.EventDispatcher
-	bigSegmentSync   bigsegments.BigSegmentSynchronizer
-	bigSegmentStore  bigsegments.BigSegmentStore
-	bigSegmentsExist bool
-	sdkBigSegments   *ldstoreimpl.BigSegmentStoreWrapper
-	sdkConfig        ld.Config
-	sdkClientFactory sdks.ClientFactoryFunc
-	sdkInitTimeout   time.Duration
-	metricsManager   *metrics.Manager
-	metricsEnv       *metrics.EnvironmentManager
-	metricsEventPub  events.EventPublisher
-	dataStoreInfo    sdks.DataStoreEnvironmentInfo
-	globalLoggers    ldlog.Loggers
-	ttl              time.Duration
-	initErr          error
-	creationTime     time.Time
+	mu                 sync.RWMutex
+	clients            map[config.SDKKey]sdks.LDClientContext
+	storeAdapter       *store.SSERelayDataStoreAdapter
+	loggers            ldlog.Loggers
+	credentials        map[config.SDKCredential]bool // true if not deprecated
+	identifiers        EnvIdentifiers
+	tenant             string
+	secureMode         bool
+	envStreams         *streams.EnvStreams
+	patchCoalescer     *streams.CoalescingEnvStreamUpdates
+	streamProviders    []streams.StreamProvider
+	handlers           map[streams.StreamProvider]map[config.SDKCredential]http.Handler
+	jsContext          JSClientContext
+	evaluator          ldeval.Evaluator
+	bigSegmentProvider ldeval.BigSegmentProvider
+	eventDispatcher    *events.EventDispatcher
+	bigSegmentSync     bigsegments.BigSegmentSynchronizer
+	bigSegmentWatch    *bigSegmentCursorWatcher
+	bigSegmentStore    bigsegments.BigSegmentStore
+	bigSegmentsExist   bool
+	sdkBigSegments     *ldstoreimpl.BigSegmentStoreWrapper
+	bigSegmentPrefetch *prefetchBigSegmentStore
+	bigSegmentNegative *negativeCacheBigSegmentStore
+	bigSegmentsStale   time.Duration
+	bigSegmentsMode    string
+	sdkConfig          ld.Config
+	sdkClientFactory   sdks.ClientFactoryFunc
+	sdkInitTimeout     time.Duration
+	metricsManager     *metrics.Manager
+	metricsEnv         *metrics.EnvironmentManager
+	metricsEventPub    events.EventPublisher
+	rateLimiter        *ratelimit.Limiter
+	quota              *ratelimit.Quota
+	streamLimiter      *ratelimit.StreamLimiter
+	clientCertPolicy   *clientcert.Policy
+	jwtVerifier        *jwtauth.Verifier
+	canaryRoute        *CanaryRoute
+	clientSideFilter   flagfilter.Filter
+	evalCache          *evalcache.Cache
+	pollingCache       *pollcache.Cache
+	flagChanges        *flagchange.Notifier
+	flagOverrides      *flagoverrides.StoreFactory
+	killSwitch         *killSwitch
+	replicator         *replication.Replicator
+	dataStoreInfo      sdks.DataStoreEnvironmentInfo
+	globalLoggers      ldlog.Loggers
+	logLevel           *logging.LevelSwitch
+	ttl                time.Duration
+	initErr            error
+	creationTime       time.Time
+	warmCache          bool
+	startupDone        bool
+}
+
+// eventSpoolDirName returns the name of the environment's subdirectory in the event spool directory.
+// This needs to stay the same when Relay is restarted, and when keys are rotated, so that events that
+// were saved before then can still be delivered.
+func eventSpoolDirName(params EnvContextImplParams) string {
+	if params.EnvConfig.EnvID != "" {
+		return string(params.EnvConfig.EnvID)
+	}
+	return url.PathEscape(params.Identifiers.GetDisplayName())
+}
+
+// makeEventMemoryBudget returns the memory budget for queued events that is set for auto-configured
+// environments, which is in megabytes; non-auto-configured environments have no budget.
+func makeEventMemoryBudget(autoConfig config.AutoConfigConfig) events.EventMemoryBudget {
+	if autoConfig.Key == "" {
+		return events.EventMemoryBudget{}
+	}
+	return events.EventMemoryBudget{
+		MaxBytes:      autoConfig.EnvMemoryBudget.GetOrElse(0) * 1024 * 1024,
+		FlushWhenFull: autoConfig.EnvQuotaOverflow == config.AutoConfigQuotaOverflowQueue,
+	}
 }
 
 // Implementation of the DataStoreQueries interface that the streams package uses as an abstraction of
@@ -132,19 +215,28 @@ func NewEnvContext(
 	defer thingsToCleanUp.Run()
 
 	offlineMode := params.AllConfig.OfflineMode.FileDataSource != ""
+	readReplica := params.AllConfig.ReadReplica.Enabled
 	envConfig := params.EnvConfig
 	allConfig := params.AllConfig
 
-	envLoggers := params.Loggers
-	logPrefix := makeLogPrefix(params.LogNameMode, envConfig.SDKKey, envConfig.EnvID)
-	envLoggers.SetPrefix(logPrefix)
-	envLoggers.SetMinLevel(
-		envConfig.LogLevel.GetOrElse(
-			allConfig.Main.LogLevel.GetOrElse(ldlog.Info),
-		),
-	)
+	allLoggers := newEnvLoggers(params)
+	globalLoggers := params.Loggers
+	if params.GlobalLogLevel != nil {
+		globalLoggers = params.GlobalLogLevel.Apply(params.Loggers)
+	}
+	logPrefix := allLoggers.prefix()
+	envLoggers := allLoggers.forEnvironment()
+	sdkLoggers := allLoggers.forComponent(logging.ComponentSDKs, "")
+	bigSegmentLoggers := allLoggers.forComponent(logging.ComponentBigSegments, "")
+	httpLoggers := allLoggers.forComponent(logging.ComponentHTTP, "")
+
+	httpConfig, err := httpconfig.NewHTTPConfig(allConfig.Proxy, envConfig.SDKKey, params.UserAgent, globalLoggers)
+	if err != nil {
+		return nil, err
+	}
+	httpConfig = httpConfig.WithUpstreamFailover(params.UpstreamFailover)
 
-	httpConfig, err := httpconfig.NewHTTPConfig(allConfig.Proxy, envConfig.SDKKey, params.UserAgent, params.Loggers)
+	clientCertPolicy, err := clientcert.NewPolicy(allConfig.Main, envConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -158,8 +250,11 @@ func NewEnvContext(
 		credentials[envConfig.EnvID] = true
 	}
 
+	bigSegmentsStaleThreshold := allConfig.Main.BigSegmentsStaleThreshold.GetOrElse(config.DefaultBigSegmentsStaleThreshold)
+
 	envContext := &envContextImpl{
 		identifiers:      params.Identifiers,
+		tenant:           envConfig.Tenant,
 		clients:          make(map[config.SDKKey]sdks.LDClientContext),
 		credentials:      credentials,
 		loggers:          envLoggers,
@@ -170,17 +265,35 @@ func NewEnvContext(
 		sdkClientFactory: params.ClientFactory,
 		sdkInitTimeout:   allConfig.Main.InitTimeout.GetOrElse(config.DefaultInitTimeout),
 		metricsManager:   params.MetricsManager,
-		globalLoggers:    params.Loggers,
+		globalLoggers:    globalLoggers,
+		logLevel:         allLoggers.level,
 		ttl:              envConfig.TTL.GetOrElse(0),
+		bigSegmentsStale: envConfig.BigSegmentsStaleAfter.GetOrElse(bigSegmentsStaleThreshold),
+		bigSegmentsMode:  envConfig.BigSegmentsStaleMode,
+		killSwitch:       &killSwitch{filePath: envConfig.KillSwitchFile},
 		dataStoreInfo:    params.DataStoreInfo,
+		rateLimiter:      ratelimit.NewLimiter(allConfig.RateLimit, envConfig),
+		quota:            ratelimit.NewQuota(allConfig.AutoConfig),
+		streamLimiter:    ratelimit.NewEnvStreamLimiter(allConfig.StreamLimits, envConfig),
+		clientCertPolicy: clientCertPolicy,
+		jwtVerifier:      jwtauth.NewVerifier(envConfig, httpConfig.Client()),
+		canaryRoute:      NewCanaryRoute(envConfig, allConfig),
+		clientSideFilter: flagfilter.NewFilter(envConfig.ClientSideFlagInclude.Values(), envConfig.ClientSideFlagExclude.Values()),
 		creationTime:     time.Now(),
+		warmCache:        allConfig.Main.WarmCacheOnStartup && params.DataStoreInfo.DBType != "",
 	}
 
 	bigSegmentStoreFactory := params.BigSegmentStoreFactory
 	if bigSegmentStoreFactory == nil {
 		bigSegmentStoreFactory = bigsegments.DefaultBigSegmentStoreFactory
 	}
-	bigSegmentStore, err := bigSegmentStoreFactory(envConfig, allConfig, envLoggers)
+	envContext.replicator, err = replication.NewReplicator(envConfig, allConfig, bigSegmentStoreFactory,
+		envContext.GetMetricsContext, envLoggers)
+	if err != nil {
+		return nil, err
+	}
+	thingsToCleanUp.AddFunc(envContext.replicator.Close)
+	bigSegmentStore, err := bigSegmentStoreFactory(envConfig, allConfig, bigSegmentLoggers)
 	if err != nil {
 		return nil, err
 	}
@@ -189,34 +302,40 @@ func NewEnvContext(
 		envContext.bigSegmentStore = bigSegmentStore
 
 		factory := params.BigSegmentSynchronizerFactory
-		if factory == nil {
-			factory = bigsegments.DefaultBigSegmentSynchronizerFactory
+		provider, isProvider := bigSegmentStore.(bigsegments.BigSegmentSynchronizerProvider)
+		if factory == nil && isProvider {
+			// the store gets its data from somewhere other than LaunchDarkly
+			envContext.bigSegmentSync = provider.NewBigSegmentSynchronizer(bigSegmentLoggers, logPrefix)
+		} else if !readReplica { // in read replica mode, another Relay instance synchronizes the store
+			if factory == nil {
+				factory = bigsegments.DefaultBigSegmentSynchronizerFactory
+			}
+			envContext.bigSegmentSync = factory(
+				httpConfig, envContext.replicator.WrapBigSegmentStore(bigSegmentStore), allConfig.Main.BaseURI.String(), allConfig.Main.StreamURI.String(),
+				envConfig.EnvID, envConfig.SDKKey, bigSegmentLoggers, logPrefix)
+		}
+		var segmentUpdateCh <-chan bigsegments.UpdatesSummary
+		if envContext.bigSegmentSync != nil {
+			thingsToCleanUp.AddFunc(envContext.bigSegmentSync.Close)
+			segmentUpdateCh = envContext.bigSegmentSync.SegmentUpdatesCh()
 		}
-		envContext.bigSegmentSync = factory(
-			httpConfig, bigSegmentStore, allConfig.Main.BaseURI.String(), allConfig.Main.StreamURI.String(),
-			envConfig.EnvID, envConfig.SDKKey, envLoggers, logPrefix)
-		thingsToCleanUp.AddFunc(envContext.bigSegmentSync.Close)
-		segmentUpdateCh := envContext.bigSegmentSync.SegmentUpdatesCh()
 		if segmentUpdateCh != nil {
 			go func() {
-				for range segmentUpdateCh {
+				for updates := range segmentUpdateCh {
 					// BigSegmentSynchronizer sends to this channel after processing a batch of
-					// big segment updates. The value it sends is a list of segment keys, but in
-					// the current implementation, we don't care what those keys are because we'll
-					// just be broadcasting a "ping" to all connected client-side SDKs. In the future
-					// if we have real evaluation streams, we'll need to determine which flags should
-					// be re-evaluated based on the segments.
-					if envContext.sdkBigSegments != nil {
-						envContext.sdkBigSegments.ClearCache()
-					}
-					if envContext.envStreams != nil {
-						envContext.envStreams.InvalidateClientSideState()
-					}
+					// big segment updates. The value it sends is a list of segment keys, which we
+					// don't care about because client-side SDKs will re-evaluate all flags anyway,
+					// and the user hashes whose memberships changed, which tell us which client-side
+					// streams to send a "ping" to.
+					envContext.onBigSegmentsChanged(updates.UserHashesUpdated)
 					// If we shut down the environment, the BigSegmentSynchronizer will be closed which
 					// will also cause this channel to be closed, exiting this goroutine.
 				}
 			}()
 		}
+		envContext.bigSegmentWatch = newBigSegmentCursorWatcher(bigSegmentStore, allConfig,
+			func() { envContext.onBigSegmentsChanged(nil) }, bigSegmentLoggers)
+		thingsToCleanUp.AddFunc(envContext.bigSegmentWatch.Close)
 		// We deliberate do not call bigSegmentSync.Start() here because we don't want the synchronizer to
 		// start until we know that at least one big segment exists. That's implemented by the
 		// envContextStreamUpdates methods.
@@ -226,14 +345,45 @@ func NewEnvContext(
 		params.StreamProviders,
 		envContextStoreQueries{envContext},
 		allConfig.Main.HeartbeatInterval.GetOrElse(config.DefaultHeartbeatInterval),
-		envLoggers,
+		httpLoggers,
 	)
 	envContext.envStreams = envStreams
 	thingsToCleanUp.AddCloser(envStreams)
 
-	envStreamUpdates := &envContextStreamUpdates{
+	var envStreamUpdates streams.EnvStreamUpdates = &envContextStreamUpdates{
 		context: envContext,
 	}
+	if allConfig.Main.UpstreamRelayURI.IsDefined() || readReplica {
+		// The upstream Relay will send us a full data set every time we reconnect to it, and in read replica
+		// mode we read the full data set from the store every time we poll it; see
+		// streams.NewDeltaEnvStreamUpdates.
+		envStreamUpdates = streams.NewDeltaEnvStreamUpdates(envStreamUpdates)
+	}
+	if window := envConfig.PatchCoalescingWindow.GetOrElse(allConfig.Main.PatchCoalescingWindow.GetOrElse(0)); window > 0 {
+		envContext.patchCoalescer = streams.NewCoalescingEnvStreamUpdates(envStreamUpdates, window)
+		thingsToCleanUp.AddFunc(envContext.patchCoalescer.Close)
+		envStreamUpdates = envContext.patchCoalescer
+	}
+	envContext.flagChanges = flagchange.NewNotifier(envConfig, params.Identifiers.GetDisplayName(), params.UserAgent,
+		httpConfig, allConfig.Main.HeartbeatInterval.GetOrElse(config.DefaultHeartbeatInterval), envLoggers)
+	thingsToCleanUp.AddFunc(envContext.flagChanges.Close)
+	envStreamUpdates = envContext.flagChanges.WrapUpdates(envStreamUpdates)
+	// Cached evaluation responses are discarded as soon as the data changes, even if patches are being
+	// coalesced, so that the evaluation endpoints are never further behind than the store.
+	envContext.evalCache = evalcache.NewCache(
+		envConfig.EvalCacheTTL.GetOrElse(allConfig.Main.EvalCacheTTL.GetOrElse(0)),
+		allConfig.Main.EvalCacheMaxEntries.GetOrElse(config.DefaultEvalCacheMaxEntries),
+	)
+	envStreamUpdates = envContext.evalCache.WrapUpdates(envStreamUpdates)
+	envContext.pollingCache = pollcache.NewCache(
+		map[pollcache.Endpoint]ct.OptDuration{
+			pollcache.AllFlags: envConfig.AllFlagsTTL,
+			pollcache.Flag:     envConfig.FlagTTL,
+			pollcache.Segment:  envConfig.SegmentTTL,
+		},
+		envConfig.StaleWhileRevalidate.GetOrElse(0),
+	)
+	envStreamUpdates = envContext.pollingCache.WrapUpdates(envStreamUpdates)
 
 	for c := range credentials {
 		envStreams.AddCredential(c)
@@ -253,26 +403,92 @@ func NewEnvContext(
 	if dataStoreFactory == nil {
 		dataStoreFactory = ldcomponents.InMemoryDataStore()
 	}
+	// Only what is written to this instance's own data store is replicated, so with leader election
+	// enabled, only the leader replicates anything.
+	dataStoreFactory = envContext.replicator.WrapDataStoreFactory(dataStoreFactory)
+	if params.LeaderElector != nil && params.DataStoreInfo.DBType != "" {
+		dataStoreFactory = leaderDataStoreFactory{wrapped: dataStoreFactory, elector: params.LeaderElector, loggers: envLoggers}
+	}
 	storeAdapter := store.NewSSERelayDataStoreAdapter(dataStoreFactory, envStreamUpdates)
 	envContext.storeAdapter = storeAdapter
 
+	// If there is a flag overrides file, the SDK writes to the store adapter through the overrides store,
+	// so that the overridden flags are what gets stored and broadcast to SDKs.
+	var sdkDataStore interfaces.DataStoreFactory = storeAdapter
+	if envConfig.FlagOverridesFile != "" {
+		overrides, err := flagoverrides.NewStoreFactory(storeAdapter, envConfig.FlagOverridesFile, envLoggers)
+		if err != nil {
+			return nil, errInitFlagOverrides(err)
+		}
+		thingsToCleanUp.AddCloser(overrides)
+		envContext.flagOverrides = overrides
+		sdkDataStore = overrides
+	}
+	// The flag filter is applied before anything else, so that flags that are filtered out are never
+	// stored or broadcast.
+	if filter := flagfilter.NewFilter(envConfig.FlagFilterInclude.Values(), envConfig.FlagFilterExclude.Values()); !filter.IsEmpty() {
+		sdkDataStore = flagfilter.NewStoreFactory(sdkDataStore, filter)
+	}
+
 	var eventDispatcher *events.EventDispatcher
 	if allConfig.Events.SendEvents {
 		if offlineMode {
 			envLoggers.Info("Events will be accepted for this environment, but will be discarded, since offline mode is enabled")
+		} else if readReplica {
+			envLoggers.Info("Events will be accepted for this environment, but will be discarded, since read replica mode is enabled")
 		} else {
 			envLoggers.Info("Proxying events for this environment")
-			eventLoggers := envLoggers
-			eventLoggers.SetPrefix(logPrefix + " (event proxy)")
+			var spool *events.EventSpool
+			if allConfig.Events.SpoolDir != "" {
+				spool, err = events.NewEventSpool(filepath.Join(allConfig.Events.SpoolDir, eventSpoolDirName(params)),
+					allConfig.Events, allLoggers.forComponent(logging.ComponentEvents, " (event spool)"))
+				if err != nil {
+					return nil, errInitEventSpool(err)
+				}
+			}
+			eventTransform, err := eventtransform.NewPipeline(envConfig, params.Identifiers.GetDisplayName(), httpConfig,
+				allLoggers.forComponent(logging.ComponentEvents, " (event transformers)"))
+			if err != nil {
+				return nil, errInitEventTransform(err)
+			}
+			var transform func(basictypes.SDKKind, []json.RawMessage) []json.RawMessage
+			if eventTransform != nil {
+				transform = func(sdkKind basictypes.SDKKind, evts []json.RawMessage) []json.RawMessage {
+					return eventTransform.Apply(string(sdkKind), evts)
+				}
+			}
+			var coordinationStore events.EventCoordinationStore
+			if allConfig.Events.Coordinate {
+				envLoggers.Info("Coordinating summary events with other Relay instances through Redis")
+				coordinationStore, err = events.NewRedisEventCoordinationStore(allConfig.Redis, envConfig)
+				if err != nil {
+					return nil, errInitEventCoordination(err)
+				}
+			}
 			eventDispatcher = events.NewEventDispatcher(
 				envConfig.SDKKey,
 				envConfig.MobileKey,
 				envConfig.EnvID,
-				envLoggers,
+				allLoggers.forComponent(logging.ComponentEvents, " (event proxy)"),
 				allConfig.Events,
 				httpConfig,
 				storeAdapter,
 				0, // 0 here means "use the default interval for any periodic cleanup task you may need to run"
+				func(sdkKind basictypes.SDKKind, count int) {
+					metrics.RecordEventsForwarded(envContext.GetMetricsContext(), sdkKind, count)
+				},
+				coordinationStore,
+				spool,
+				transform,
+				params.EventExporter.ForEnvironment(params.Identifiers.GetDisplayName(), envConfig),
+				makeEventMemoryBudget(allConfig.AutoConfig),
+				events.EventSampling{
+					FeatureEventRate: envConfig.EventSampleRateFeature.GetOrElse(0),
+					CustomEventRate:  envConfig.EventSampleRateCustom.GetOrElse(0),
+					OnSampledOut: func(sdkKind basictypes.SDKKind, count int) {
+						metrics.RecordEventsSampledOut(envContext.GetMetricsContext(), sdkKind, count)
+					},
+				},
 			)
 		}
 	}
@@ -281,12 +497,11 @@ func NewEnvContext(
 	streamURI := allConfig.Main.StreamURI.String()   // config.ValidateConfig has ensured that this has a value
 	eventsURI := allConfig.Events.EventsURI.String() // ditto
 
-	enableDiagnostics := !allConfig.Main.DisableInternalUsageMetrics && !offlineMode
+	enableDiagnostics := !allConfig.Main.DisableInternalUsageMetrics && !offlineMode && !readReplica
 	var em *metrics.EnvironmentManager
 	if params.MetricsManager != nil {
 		if enableDiagnostics {
-			pubLoggers := envLoggers
-			pubLoggers.SetPrefix(logPrefix + " (usage metrics)")
+			pubLoggers := allLoggers.forComponent(logging.ComponentEvents, " (usage metrics)")
 			eventsPublisher, err := events.NewHTTPEventPublisher(envConfig.SDKKey, httpConfig, pubLoggers,
 				events.OptionURI(eventsURI))
 			if err != nil {
@@ -296,30 +511,49 @@ func NewEnvContext(
 			envContext.metricsEventPub = eventsPublisher
 		}
 
-		em, err = params.MetricsManager.AddEnvironment(params.Identifiers.GetDisplayName(), envContext.metricsEventPub)
+		em, err = params.MetricsManager.AddEnvironment(params.Identifiers.GetDisplayName(), envConfig.Tenant,
+			envContext.metricsEventPub)
 		if err != nil {
 			return nil, errInitMetrics(err)
 		}
 		thingsToCleanUp.AddFunc(func() { params.MetricsManager.RemoveEnvironment(em) })
+		if pools := params.DataStoreInfo.Pools; pools != nil {
+			metrics.SetDatabasePoolStatsProvider(em.GetOpenCensusContext(), databasePoolStatsProvider(pools))
+		}
 	}
 	envContext.metricsEnv = em
 
 	disconnectedStatusTime := allConfig.Main.DisconnectedStatusTime.GetOrElse(config.DefaultDisconnectedStatusTime)
 
 	envContext.sdkConfig = ld.Config{
-		DataSource:       ldcomponents.StreamingDataSource(),
-		DataStore:        storeAdapter,
+		DataSource: upstreamStreamDataSourceFactory{
+			streamURI: streamURI,
+			options:   makeUpstreamStreamOptions(allConfig.Main, envConfig),
+			onReconnect: func() {
+				metrics.RecordUpstreamStreamReconnect(envContext.GetMetricsContext())
+			},
+		},
+		DataStore:        sdkDataStore,
 		DiagnosticOptOut: !enableDiagnostics,
 		Events:           ldcomponents.SendEvents(),
 		HTTP:             httpConfig.SDKHTTPConfigFactory,
 		Logging: ldcomponents.Logging().
-			Loggers(envLoggers).
+			Loggers(sdkLoggers).
 			LogDataSourceOutageAsErrorAfter(disconnectedStatusTime),
 		ServiceEndpoints: interfaces.ServiceEndpoints{
 			Streaming: streamURI,
 			Events:    eventsURI,
 		},
 	}
+	if readReplica {
+		envContext.sdkConfig.DataSource = readReplicaDataSourceFactory{
+			getStore:      storeAdapter.GetStore,
+			streamUpdates: envStreamUpdates,
+			pollInterval:  allConfig.ReadReplica.PollInterval.GetOrElse(config.DefaultReadReplicaPollInterval),
+			loggers:       envLoggers,
+		}
+		envContext.sdkConfig.Events = ldcomponents.NoEvents()
+	}
 
 	// If appropriate, create the SDK subcomponent that will be used for flag evaluations. We're
 	// creating and managing it separately from the full SDK instance that we'll be creating (in
@@ -330,9 +564,14 @@ func NewEnvContext(
 	if bigSegmentStore != nil {
 		configFactory := params.SDKBigSegmentsConfigFactory
 		if configFactory == nil {
-			configFactory, err = sdks.ConfigureBigSegments(allConfig, envConfig, params.Loggers)
-			if err != nil {
-				return nil, err
+			if provider, ok := bigSegmentStore.(bigsegments.SDKBigSegmentStoreProvider); ok {
+				// The big segment data is held by Relay itself, so the SDK reads it from the same store.
+				configFactory = sdks.MakeBigSegmentsConfiguration(allConfig, envConfig, provider.SDKBigSegmentStoreFactory())
+			} else {
+				configFactory, err = sdks.ConfigureBigSegments(allConfig, envConfig, params.DataStoreInfo.Pools, globalLoggers)
+				if err != nil {
+					return nil, err
+				}
 			}
 		}
 		bigSegConfig, err := configFactory.CreateBigSegmentsConfiguration(
@@ -341,17 +580,36 @@ func NewEnvContext(
 			return nil, err
 		}
 		if bigSegConfig != nil {
+			// The concurrency limit is applied outside of the circuit breaker, so that queries rejected by the
+			// limit do not count as store failures. Prefetched memberships and the negative cache are outside
+			// of both, since they do not need a query.
+			_, batching := bigSegConfig.GetStore().(sdks.BigSegmentBatchStore)
+			sdkBigSegmentStore := newConcurrencyLimitBigSegmentStore(
+				newCircuitBreakerBigSegmentStore(
+					metricsBigSegmentStore{store: bigSegConfig.GetStore(), metricsCtx: envContext.GetMetricsContext()},
+					allConfig.BigSegments,
+					bigSegmentLoggers,
+				),
+				allConfig.BigSegments,
+				envConfig,
+				envContext.GetMetricsContext(),
+			)
+			envContext.bigSegmentNegative = newNegativeCacheBigSegmentStore(sdkBigSegmentStore, allConfig.BigSegments)
+			if envContext.bigSegmentNegative != nil {
+				sdkBigSegmentStore = envContext.bigSegmentNegative
+			}
+			envContext.bigSegmentPrefetch = newPrefetchBigSegmentStore(sdkBigSegmentStore, batching)
 			envContext.sdkBigSegments = ldstoreimpl.NewBigSegmentStoreWrapperWithConfig(
 				ldstoreimpl.BigSegmentsConfigurationProperties{
-					Store:              bigSegConfig.GetStore(),
+					Store:              envContext.bigSegmentPrefetch,
 					StatusPollInterval: bigSegConfig.GetStatusPollInterval(),
 					StaleAfter:         bigSegConfig.GetStaleAfter(),
 					UserCacheSize:      bigSegConfig.GetUserCacheSize(),
 					UserCacheTime:      bigSegConfig.GetUserCacheTime(),
 					StartPolling:       false, // we will start it later if we see a big segment
 				},
-				nil,
-				envLoggers,
+				makeBigSegmentsStatusLogger(bigSegmentLoggers),
+				bigSegmentLoggers,
 			)
 			thingsToCleanUp.AddFunc(envContext.sdkBigSegments.Close)
 		}
@@ -375,15 +633,21 @@ func (c *envContextImpl) startSDKClient(sdkKey config.SDKKey, readyCh chan<- Env
 		// The data store instance is created by the SDK when it creates the client. Now that
 		// we have a data store, we can finish setting up the Evaluator that we'll use for this
 		// environment.
-		store := c.storeAdapter.GetStore()
-		dataProvider := ldstoreimpl.NewDataStoreEvaluatorDataProvider(store, c.loggers)
+		// The evaluator reads through the kill switch, like the endpoints do.
+		dataProvider := ldstoreimpl.NewDataStoreEvaluatorDataProvider(c.GetStore(), c.loggers)
 		var evalOptions []ldeval.EvaluatorOption
 		if c.sdkBigSegments != nil {
-			evalOptions = append(evalOptions, ldeval.EvaluatorOptionBigSegmentProvider(c.sdkBigSegments))
+			var bigSegmentProvider ldeval.BigSegmentProvider = c.sdkBigSegments
+			if c.bigSegmentsMode == config.BigSegmentsStaleModeFailClosed {
+				bigSegmentProvider = failClosedBigSegmentProvider{provider: bigSegmentProvider}
+			}
+			c.bigSegmentProvider = metricsBigSegmentProvider{provider: bigSegmentProvider, metricsCtx: c.GetMetricsContext()}
+			evalOptions = append(evalOptions, ldeval.EvaluatorOptionBigSegmentProvider(c.bigSegmentProvider))
 		}
 		c.evaluator = ldeval.NewEvaluatorWithOptions(dataProvider, evalOptions...)
 	}
 	c.initErr = err
+	firstClient := !c.startupDone
 	c.mu.Unlock()
 
 	if err != nil {
@@ -393,14 +657,20 @@ func (c *envContextImpl) startSDKClient(sdkKey config.SDKKey, readyCh chan<- Env
 		} else {
 			c.globalLoggers.Errorf("Error initializing LaunchDarkly client for %q: %+v",
 				name, err)
-			if readyCh != nil {
-				readyCh <- c
-			}
-			return
 		}
 	} else {
 		c.globalLoggers.Infof("Initialized LaunchDarkly client for %q", name)
 	}
+
+	// The SDK client may have been created even if it returned an error, for instance if it timed out
+	// waiting for LaunchDarkly; in that case we can still serve whatever is in the persistent store.
+	if client != nil && firstClient && c.warmCache {
+		c.warmDataStoreCache()
+	}
+	c.mu.Lock()
+	c.startupDone = true
+	c.mu.Unlock()
+
 	if readyCh != nil {
 		readyCh <- c
 	}
@@ -420,6 +690,10 @@ func (c *envContextImpl) SetIdentifiers(ei EnvIdentifiers) {
 	c.identifiers = ei
 }
 
+func (c *envContextImpl) GetTenant() string {
+	return c.tenant
+}
+
 func (c *envContextImpl) GetCredentials() []config.SDKCredential {
 	return c.getCredentialsInternal(true)
 }
@@ -444,23 +718,30 @@ func (c *envContextImpl) getCredentialsInternal(preferred bool) []config.SDKCred
 func (c *envContextImpl) AddCredential(newCredential config.SDKCredential) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if _, found := c.credentials[newCredential]; found {
+	nonDeprecated, found := c.credentials[newCredential]
+	if found && nonDeprecated {
 		return
 	}
 	c.credentials[newCredential] = true
-	c.envStreams.AddCredential(newCredential)
-	for streamProvider, handlers := range c.handlers {
-		if h := streamProvider.Handler(newCredential); h != nil {
-			handlers[newCredential] = h
+	if !found {
+		c.envStreams.AddCredential(newCredential)
+		for streamProvider, handlers := range c.handlers {
+			if h := streamProvider.Handler(newCredential); h != nil {
+				handlers[newCredential] = h
+			}
 		}
 	}
 
 	// A new SDK key means 1. we should start a new SDK client, 2. we should tell all event forwarding
 	// components that use an SDK key to use the new one. A new mobile key does not require starting a
 	// new SDK client, but does requiring updating any event forwarding components that use a mobile key.
+	// If the credential was deprecated, it becomes the preferred one again, and its SDK client is still
+	// running.
 	switch key := newCredential.(type) {
 	case config.SDKKey:
-		go c.startSDKClient(key, nil, false)
+		if !found {
+			go c.startSDKClient(key, nil, false)
+		}
 		if c.metricsEventPub != nil { // metrics event publisher always uses SDK key
 			c.metricsEventPub.ReplaceCredential(key)
 		}
@@ -515,7 +796,11 @@ func (c *envContextImpl) GetClient() sdks.LDClientContext {
 }
 
 func (c *envContextImpl) GetStore() interfaces.DataStore {
-	return c.storeAdapter.GetStore()
+	store := c.storeAdapter.GetStore()
+	if store == nil {
+		return nil
+	}
+	return killSwitchDataStore{DataStore: store, killSwitch: c.killSwitch}
 }
 
 func (c *envContextImpl) GetEvaluator() ldeval.Evaluator {
@@ -525,6 +810,13 @@ func (c *envContextImpl) GetEvaluator() ldeval.Evaluator {
 	return ret
 }
 
+func (c *envContextImpl) GetBigSegmentProvider() ldeval.BigSegmentProvider {
+	c.mu.RLock()
+	ret := c.bigSegmentProvider
+	c.mu.RUnlock()
+	return ret
+}
+
 func (c *envContextImpl) GetBigSegmentStore() bigsegments.BigSegmentStore {
 	c.mu.RLock()
 	enabled := c.bigSegmentsExist
@@ -536,6 +828,21 @@ func (c *envContextImpl) GetBigSegmentStore() bigsegments.BigSegmentStore {
 	return nil
 }
 
+func (c *envContextImpl) PrefetchBigSegmentMemberships(userKeys []string) func() {
+	c.mu.RLock()
+	enabled := c.bigSegmentsExist
+	c.mu.RUnlock()
+
+	if !enabled { // no flags refer to big segments, so the evaluator will not need any memberships
+		return func() {}
+	}
+	return c.bigSegmentPrefetch.Prefetch(userKeys)
+}
+
+func (c *envContextImpl) GetBigSegmentsStaleThreshold() time.Duration {
+	return c.bigSegmentsStale
+}
+
 func (c *envContextImpl) GetLoggers() ldlog.Loggers {
 	return c.loggers
 }
@@ -569,6 +876,46 @@ func (c *envContextImpl) GetMetricsContext() context.Context {
 	return c.metricsEnv.GetOpenCensusContext()
 }
 
+func (c *envContextImpl) GetClientSideFlagFilter() flagfilter.Filter {
+	return c.clientSideFilter
+}
+
+func (c *envContextImpl) GetEvalCache() *evalcache.Cache {
+	return c.evalCache
+}
+
+func (c *envContextImpl) GetPollingCache() *pollcache.Cache {
+	return c.pollingCache
+}
+
+func (c *envContextImpl) GetClientCertPolicy() *clientcert.Policy {
+	return c.clientCertPolicy
+}
+
+func (c *envContextImpl) GetJWTVerifier() *jwtauth.Verifier {
+	return c.jwtVerifier
+}
+
+func (c *envContextImpl) GetCanaryRoute() *CanaryRoute {
+	return c.canaryRoute
+}
+
+func (c *envContextImpl) GetChangeFeedHandler(streamMiddleware func(http.Handler) http.Handler) http.Handler {
+	return c.flagChanges.ChangeFeedHandler(streamMiddleware)
+}
+
+func (c *envContextImpl) GetRateLimiter() *ratelimit.Limiter {
+	return c.rateLimiter
+}
+
+func (c *envContextImpl) GetQuota() *ratelimit.Quota {
+	return c.quota
+}
+
+func (c *envContextImpl) GetStreamLimiter() *ratelimit.StreamLimiter {
+	return c.streamLimiter
+}
+
 func (c *envContextImpl) GetTTL() time.Duration {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -604,10 +951,81 @@ func (c *envContextImpl) SetSecureMode(secureMode bool) {
 	c.secureMode = secureMode
 }
 
+func (c *envContextImpl) SetLogLevel(level config.OptLogLevel) {
+	if level.IsDefined() {
+		c.logLevel.SetLevel(level.GetOrElse(ldlog.Info))
+	} else {
+		c.logLevel.Inherit()
+	}
+}
+
+func (c *envContextImpl) IsReady() bool {
+	c.mu.RLock()
+	startupDone := c.startupDone
+	c.mu.RUnlock()
+	if !startupDone {
+		return false
+	}
+	client := c.GetClient()
+	if client == nil {
+		return false
+	}
+	if client.Initialized() {
+		return true
+	}
+	store := c.storeAdapter.GetStore()
+	return store != nil && store.IsInitialized()
+}
+
 func (c *envContextImpl) GetDataStoreInfo() sdks.DataStoreEnvironmentInfo {
 	return c.dataStoreInfo
 }
 
+func (c *envContextImpl) IsKillSwitchOn() bool {
+	return c.killSwitch.isOn()
+}
+
+func (c *envContextImpl) GetKillSwitchGeneration() uint64 {
+	return c.killSwitch.getGeneration()
+}
+
+func (c *envContextImpl) SetKillSwitch(on bool) error {
+	if on {
+		if err := c.killSwitch.turnOn(); err != nil {
+			return err
+		}
+		c.loggers.Warn("Kill switch is on: serving fallback flag values and discarding events")
+	} else {
+		if !c.killSwitch.turnOff() {
+			return nil
+		}
+		c.loggers.Info("Kill switch is off: serving flag values from LaunchDarkly")
+	}
+	c.evalCache.Clear()
+	c.pollingCache.Clear()
+	c.sendAllDataToStreams()
+	return nil
+}
+
+// sendAllDataToStreams sends the data that SDKs should now have, with or without the kill switch, to
+// every stream as if it were a new full data set.
+func (c *envContextImpl) sendAllDataToStreams() {
+	store := c.GetStore()
+	if store == nil || !store.IsInitialized() {
+		return // streams will get the right data when there is some
+	}
+	allData := make([]ldstoretypes.Collection, 0, 2)
+	for _, kind := range []ldstoretypes.DataKind{ldstoreimpl.Features(), ldstoreimpl.Segments()} {
+		items, err := store.GetAll(kind)
+		if err != nil {
+			c.loggers.Errorf("Unable to read %s from data store to update streams: %s", kind, err)
+			return
+		}
+		allData = append(allData, ldstoretypes.Collection{Kind: kind, Items: items})
+	}
+	c.envStreams.SendAllDataUpdate(allData)
+}
+
 func (c *envContextImpl) GetCreationTime() time.Time {
 	return c.creationTime
 }
@@ -626,6 +1044,9 @@ func (c *envContextImpl) Close() error {
 	}
 	c.clients = make(map[config.SDKKey]sdks.LDClientContext)
 	c.mu.Unlock()
+	if c.patchCoalescer != nil {
+		c.patchCoalescer.Close()
+	}
 	_ = c.envStreams.Close()
 	if c.metricsManager != nil && c.metricsEnv != nil {
 		c.metricsManager.RemoveEnvironment(c.metricsEnv)
@@ -639,12 +1060,18 @@ func (c *envContextImpl) Close() error {
 	if c.bigSegmentSync != nil {
 		c.bigSegmentSync.Close()
 	}
+	c.bigSegmentWatch.Close()
 	if c.bigSegmentStore != nil {
 		_ = c.bigSegmentStore.Close()
 	}
+	c.replicator.Close()
 	if c.sdkBigSegments != nil {
 		c.sdkBigSegments.Close()
 	}
+	c.flagChanges.Close()
+	if c.flagOverrides != nil {
+		_ = c.flagOverrides.Close()
+	}
 	return nil
 }
 
@@ -654,12 +1081,40 @@ func (c *envContextImpl) setBigSegmentsExist() {
 	c.bigSegmentsExist = true
 	c.mu.Unlock()
 
-	if !alreadyExisted && c.bigSegmentSync != nil {
+	if alreadyExisted {
+		return
+	}
+	if c.bigSegmentSync != nil {
 		c.bigSegmentSync.Start()
+	}
+	c.bigSegmentWatch.Start()
+	if c.sdkBigSegments != nil {
 		c.sdkBigSegments.SetPollingActive(true) // has no effect if already active
 	}
 }
 
+// onBigSegmentsChanged is called when big segment data in the store has changed, either because our
+// own BigSegmentSynchronizer applied updates or because the bigSegmentCursorWatcher saw that another
+// Relay instance did. If userHashKeys is nil, any user's memberships may have changed; otherwise,
+// only the client-side streams for those users need to be told to get new flag values.
+func (c *envContextImpl) onBigSegmentsChanged(userHashKeys []string) {
+	if c.sdkBigSegments != nil {
+		c.sdkBigSegments.ClearCache()
+		c.bigSegmentPrefetch.Clear()
+		c.bigSegmentNegative.Clear()
+	}
+	// The evaluation cache is normally cleared by the EnvStreamUpdates wrapper, but we are sending
+	// these notifications directly to envStreams.
+	c.evalCache.Clear()
+	if c.envStreams != nil {
+		if userHashKeys == nil {
+			c.envStreams.InvalidateClientSideState()
+		} else {
+			c.envStreams.InvalidateClientSideStateForUsers(userHashKeys)
+		}
+	}
+}
+
 func (q envContextStoreQueries) IsInitialized() bool {
 	if s := q.context.storeAdapter.GetStore(); s != nil {
 		return s.IsInitialized()
@@ -668,7 +1123,7 @@ func (q envContextStoreQueries) IsInitialized() bool {
 }
 
 func (q envContextStoreQueries) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedItemDescriptor, error) {
-	if s := q.context.storeAdapter.GetStore(); s != nil {
+	if s := q.context.GetStore(); s != nil {
 		return s.GetAll(kind)
 	}
 	return nil, nil
@@ -676,9 +1131,13 @@ func (q envContextStoreQueries) GetAll(kind ldstoretypes.DataKind) ([]ldstoretyp
 
 func (u *envContextStreamUpdates) SendAllDataUpdate(allData []ldstoretypes.Collection) {
 	// We use this delegator, rather than sending updates directory to context.envStreams, so that we
-	// can detect the presence of a big segment and turn on the big segment synchronizer as needed.
-	u.context.envStreams.SendAllDataUpdate(allData)
-	if u.context.bigSegmentSync == nil {
+	// can detect the presence of a big segment and turn on the big segment synchronizer as needed. Also,
+	// while the kill switch is on, SDKs only get the fallback flags, which these updates do not change;
+	// they get the current data when it is turned off.
+	if !u.context.killSwitch.isOn() {
+		u.context.envStreams.SendAllDataUpdate(allData)
+	}
+	if u.context.bigSegmentSync == nil && u.context.sdkBigSegments == nil {
 		return
 	}
 
@@ -700,8 +1159,10 @@ func (u *envContextStreamUpdates) SendAllDataUpdate(allData []ldstoretypes.Colle
 
 func (u *envContextStreamUpdates) SendSingleItemUpdate(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
 	// See comments in SendAllDataUpdate.
-	u.context.envStreams.SendSingleItemUpdate(kind, key, item)
-	if u.context.bigSegmentSync == nil {
+	if !u.context.killSwitch.isOn() {
+		u.context.envStreams.SendSingleItemUpdate(kind, key, item)
+	}
+	if u.context.bigSegmentSync == nil && u.context.sdkBigSegments == nil {
 		return
 	}
 	hasBigSegment := false
@@ -719,7 +1180,7 @@ func (u *envContextStreamUpdates) InvalidateClientSideState() {
 	u.context.envStreams.InvalidateClientSideState()
 }
 
-func makeLogPrefix(logNameMode LogNameMode, sdkKey config.SDKKey, envID config.EnvironmentID) string {
+func makeLogName(logNameMode LogNameMode, sdkKey config.SDKKey, envID config.EnvironmentID) string {
 	name := string(sdkKey)
 	if logNameMode == LogNameIsEnvID && envID != "" {
 		name = string(envID)
@@ -727,5 +1188,5 @@ func makeLogPrefix(logNameMode LogNameMode, sdkKey config.SDKKey, envID config.E
 	if len(name) > 4 { // real keys are always longer than this
 		name = "..." + name[len(name)-4:]
 	}
-	return fmt.Sprintf("[env: %s]", name)
+	return name
 }
@@ -16,6 +16,7 @@ import (
 	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/logging"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
 	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
@@ -26,8 +27,10 @@ import (
 	"github.com/launchdarkly/go-test-helpers/v2/httphelpers"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldreason"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
 	ldevents "gopkg.in/launchdarkly/go-sdk-events.v1"
+	ldeval "gopkg.in/launchdarkly/go-server-sdk-evaluation.v1"
 	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
 	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
 	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
@@ -172,6 +175,60 @@ func TestLogPrefix(t *testing.T) {
 	testPrefix("impossibly short env ID", LogNameIsEnvID, config.SDKKey("1234567890"), config.EnvironmentID("hij"), "[env: hij]")
 }
 
+func TestComponentLoggers(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	allConfig := config.Config{}
+	allConfig.Main.LogLevel = config.NewOptLogLevel(ldlog.Warn)
+	allConfig.Main.LogLevelBigSegments = config.NewOptLogLevel(ldlog.Debug)
+	loggers := newEnvLoggers(EnvContextImplParams{
+		EnvConfig: config.EnvConfig{SDKKey: config.SDKKey("1234567890")},
+		AllConfig: allConfig,
+		Loggers:   mockLog.Loggers,
+	})
+
+	t.Run("component with its own level", func(t *testing.T) {
+		bigSegmentLoggers := loggers.forComponent(logging.ComponentBigSegments, "")
+		assert.True(t, bigSegmentLoggers.IsDebugEnabled())
+		bigSegmentLoggers.Debug("synced")
+		mockLog.AssertMessageMatch(t, true, ldlog.Debug, `^\[env: \.\.\.7890\] synced`)
+	})
+
+	t.Run("component without its own level", func(t *testing.T) {
+		eventLoggers := loggers.forComponent(logging.ComponentEvents, " (event proxy)")
+		eventLoggers.Info("queued")
+		eventLoggers.Warn("flushed")
+		mockLog.AssertMessageMatch(t, false, ldlog.Info, "queued")
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, `^\[env: \.\.\.7890\] \(event proxy\) flushed`)
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		assert.Equal(t, ldlog.Warn, loggers.level.GetLevel())
+		assert.Equal(t, "[env: ...7890]", loggers.prefix())
+	})
+
+	t.Run("environment level can be changed", func(t *testing.T) {
+		envLoggers := loggers.forEnvironment()
+		eventLoggers := loggers.forComponent(logging.ComponentEvents, "")
+		bigSegmentLoggers := loggers.forComponent(logging.ComponentBigSegments, "")
+		loggers.level.SetLevel(ldlog.Error)
+		defer loggers.level.Inherit()
+		envLoggers.Warn("env warning")
+		eventLoggers.Warn("event warning")
+		bigSegmentLoggers.Debug("big segment debug")
+		mockLog.AssertMessageMatch(t, false, ldlog.Warn, "env warning")
+		mockLog.AssertMessageMatch(t, false, ldlog.Warn, "event warning")
+		mockLog.AssertMessageMatch(t, true, ldlog.Debug, "big segment debug")
+	})
+
+	t.Run("JSON format has no prefix", func(t *testing.T) {
+		jsonConfig := allConfig
+		jsonConfig.Main.LogFormat = config.LogFormatJSON
+		jsonLoggers := newEnvLoggers(EnvContextImplParams{AllConfig: jsonConfig, Loggers: mockLog.Loggers})
+		assert.Equal(t, "", jsonLoggers.prefix())
+		assert.True(t, jsonLoggers.forComponent(logging.ComponentBigSegments, "").IsDebugEnabled())
+	})
+}
+
 func TestAddRemoveCredential(t *testing.T) {
 	envConfig := st.EnvMain.Config
 
@@ -268,6 +325,42 @@ func TestChangeSDKKey(t *testing.T) {
 	client1.AwaitClose(t, time.Millisecond*20)
 }
 
+func TestAddingDeprecatedSDKKeyMakesItPreferredAgain(t *testing.T) {
+	envConfig := st.EnvMain.Config
+	readyCh := make(chan EnvContext, 1)
+	newKey := config.SDKKey("new-key")
+
+	clientCh := make(chan *testclient.FakeLDClient, 2)
+	clientFactory := testclient.FakeLDClientFactoryWithChannel(true, clientCh)
+
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+
+	env := makeBasicEnv(t, envConfig, clientFactory, mockLog.Loggers, readyCh)
+	defer env.Close()
+
+	assert.Equal(t, env, requireEnvReady(t, readyCh))
+	client1 := requireClientReady(t, clientCh)
+
+	env.AddCredential(newKey)
+	env.DeprecateCredential(envConfig.SDKKey)
+	client2 := requireClientReady(t, clientCh)
+	assert.Equal(t, env.GetClient(), client2)
+
+	env.AddCredential(envConfig.SDKKey)
+	env.DeprecateCredential(newKey)
+
+	assert.Equal(t, []config.SDKCredential{envConfig.SDKKey}, env.GetCredentials())
+	assert.Equal(t, []config.SDKCredential{newKey}, env.GetDeprecatedCredentials())
+	assert.Equal(t, env.GetClient(), client1) // the existing client is reused
+	select {
+	case <-clientCh:
+		require.Fail(t, "should not have started another client")
+	case <-time.After(time.Millisecond * 20):
+		break
+	}
+}
+
 func TestSDKClientCreationFails(t *testing.T) {
 	envConfig := st.EnvWithAllCredentials.Config
 	envConfig.TTL = configtypes.NewOptDuration(time.Hour)
@@ -287,6 +380,33 @@ func TestSDKClientCreationFails(t *testing.T) {
 	assert.Nil(t, env.GetStore())
 }
 
+func TestIsReady(t *testing.T) {
+	t.Run("client is initialized", func(t *testing.T) {
+		readyCh := make(chan EnvContext, 1)
+		env := makeBasicEnv(t, st.EnvMain.Config, testclient.FakeLDClientFactory(true), ldlog.NewDisabledLoggers(), readyCh)
+		defer env.Close()
+		requireEnvReady(t, readyCh)
+		assert.True(t, env.IsReady())
+	})
+
+	t.Run("client and store are not initialized", func(t *testing.T) {
+		readyCh := make(chan EnvContext, 1)
+		env := makeBasicEnv(t, st.EnvMain.Config, testclient.FakeLDClientFactory(false), ldlog.NewDisabledLoggers(), readyCh)
+		defer env.Close()
+		requireEnvReady(t, readyCh)
+		assert.False(t, env.IsReady())
+	})
+
+	t.Run("client creation fails", func(t *testing.T) {
+		readyCh := make(chan EnvContext, 1)
+		env := makeBasicEnv(t, st.EnvMain.Config, testclient.ClientFactoryThatFails(errors.New("sorry")),
+			ldlog.NewDisabledLoggers(), readyCh)
+		defer env.Close()
+		requireEnvReady(t, readyCh)
+		assert.False(t, env.IsReady())
+	})
+}
+
 func TestDisplayName(t *testing.T) {
 	ei1 := EnvIdentifiers{ProjName: "a", EnvName: "b", ConfiguredName: "thing"}
 	assert.Equal(t, "thing", ei1.GetDisplayName())
@@ -308,7 +428,7 @@ func TestMetricsAreExportedForEnvironment(t *testing.T) {
 	httphelpers.WithServer(handler, func(server *httptest.Server) {
 		var allConfig config.Config
 		allConfig.Events.EventsURI, _ = configtypes.NewOptURLAbsoluteFromString(server.URL)
-		metricsManager, err := metrics.NewManager(config.MetricsConfig{}, time.Minute, mockLog.Loggers)
+		metricsManager, err := metrics.NewManager(config.MetricsConfig{}, config.InternalMetricsConfig{}, time.Minute, mockLog.Loggers)
 		require.NoError(t, err)
 		env, err := NewEnvContext(EnvContextImplParams{
 			Identifiers:    EnvIdentifiers{ConfiguredName: envName},
@@ -368,7 +488,7 @@ func testMetricsDisabled(t *testing.T, allConfig config.Config) {
 	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
 	httphelpers.WithServer(handler, func(server *httptest.Server) {
 		allConfig.Events.EventsURI, _ = configtypes.NewOptURLAbsoluteFromString(server.URL)
-		metricsManager, err := metrics.NewManager(config.MetricsConfig{}, time.Minute, mockLog.Loggers)
+		metricsManager, err := metrics.NewManager(config.MetricsConfig{}, config.InternalMetricsConfig{}, time.Minute, mockLog.Loggers)
 		require.NoError(t, err)
 		env, err := NewEnvContext(EnvContextImplParams{
 			Identifiers:    EnvIdentifiers{ConfiguredName: envName},
@@ -506,6 +626,32 @@ func TestBigSegmentsSynchronizerIsCreatedIfBigSegmentStoreExists(t *testing.T) {
 	assert.True(t, fakeSynchronizerFactory.synchronizer.isClosed())
 }
 
+func TestBigSegmentsMemoryStoreIsSharedWithSDK(t *testing.T) {
+	envConfig := st.EnvMain.Config
+	allConfig := config.Config{
+		BigSegments: config.BigSegmentsConfig{Type: config.BigSegmentsStoreTypeMemory},
+	}
+	fakeSynchronizerFactory := &mockBigSegmentSynchronizerFactory{}
+
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+
+	// There is no SDK big segment store provider registered for the memory store type, so this would
+	// fail if the SDK's store were not obtained from the Relay-side store.
+	env, err := NewEnvContext(EnvContextImplParams{
+		Identifiers:                   EnvIdentifiers{ConfiguredName: st.EnvMain.Name},
+		EnvConfig:                     envConfig,
+		AllConfig:                     allConfig,
+		BigSegmentSynchronizerFactory: fakeSynchronizerFactory.create,
+		ClientFactory:                 testclient.FakeLDClientFactory(true),
+		Loggers:                       mockLog.Loggers,
+	}, nil)
+	require.NoError(t, err)
+	defer env.Close()
+
+	assert.NotNil(t, env.(*envContextImpl).sdkBigSegments)
+}
+
 func TestBigSegmentsSynchronizerIsStartedByFullDataUpdateWithBigSegment(t *testing.T) {
 	envConfig := st.EnvMain.Config
 	allConfig := config.Config{}
@@ -675,6 +821,67 @@ func TestReceivingBigSegmentsUpdateCausesClientSideInvalidationEvent(t *testing.
 	})
 }
 
+func TestReceivingBigSegmentsUpdateCausesInvalidationEventOnlyForAffectedUsers(t *testing.T) {
+	envConfig := st.EnvClientSide.Config
+	allConfig := config.Config{}
+
+	fakeBigSegmentStoreFactory := func(config.EnvConfig, config.Config, ldlog.Loggers) (bigsegments.BigSegmentStore, error) {
+		return bigsegments.NewNullBigSegmentStore(), nil
+	}
+	fakeSynchronizerFactory := &mockBigSegmentSynchronizerFactory{}
+
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+
+	jsClientStreams := streams.NewStreamProvider(basictypes.JSClientPingStream, time.Hour)
+	sdkStartedCh := make(chan EnvContext)
+	env, err := NewEnvContext(EnvContextImplParams{
+		Identifiers:                   EnvIdentifiers{ConfiguredName: st.EnvMain.Name},
+		EnvConfig:                     envConfig,
+		AllConfig:                     allConfig,
+		BigSegmentStoreFactory:        fakeBigSegmentStoreFactory,
+		BigSegmentSynchronizerFactory: fakeSynchronizerFactory.create,
+		ClientFactory:                 testclient.FakeLDClientFactory(true),
+		SDKBigSegmentsConfigFactory: ldcomponents.BigSegments(
+			mockSDKBigSegmentStoreFactory{&st.NoOpSDKBigSegmentStore{}},
+		),
+		StreamProviders: []streams.StreamProvider{jsClientStreams},
+		Loggers:         mockLog.Loggers,
+	}, sdkStartedCh)
+	require.NoError(t, err)
+	defer env.Close()
+
+	synchronizer := fakeSynchronizerFactory.synchronizer
+	require.NotNil(t, synchronizer)
+
+	streamHandler := env.GetStreamHandler(jsClientStreams, envConfig.EnvID)
+
+	<-sdkStartedCh
+	_ = env.GetStore().Init(nil)
+
+	userStreamHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		streamHandler.ServeHTTP(w, req.WithContext(streams.WithUserHashKey(req.Context(), "user1hash")))
+	})
+	req, _ := http.NewRequest("GET", "", nil)
+	sharedtest.WithStreamRequest(t, req, userStreamHandler, func(eventCh <-chan eventsource.Event) {
+		initEvent := sharedtest.ExpectStreamChEvent(t, eventCh, time.Minute)
+		assert.Equal(t, "ping", initEvent.Event())
+
+		synchronizer.updateCh <- bigsegments.UpdatesSummary{
+			SegmentKeysUpdated: []string{"fake-segment-key"},
+			UserHashesUpdated:  []string{"user2hash"},
+		}
+		sharedtest.ExpectNoStreamChEvent(t, eventCh, time.Millisecond*100)
+
+		synchronizer.updateCh <- bigsegments.UpdatesSummary{
+			SegmentKeysUpdated: []string{"fake-segment-key"},
+			UserHashesUpdated:  []string{"user2hash", "user1hash"},
+		}
+		pingEvent := sharedtest.ExpectStreamChEvent(t, eventCh, time.Second)
+		assert.Equal(t, "ping", pingEvent.Event())
+	})
+}
+
 // This method forces the metrics events exporter to post an event to the event publisher, and then triggers a
 // flush of the event publisher. Because both of those actions are asynchronous, it may be necessary to call it
 // more than once to ensure that the newly posted event is included in the flush.
@@ -749,3 +956,87 @@ type mockSDKBigSegmentStoreFactory struct {
 func (m mockSDKBigSegmentStoreFactory) CreateBigSegmentStore(c interfaces.ClientContext) (interfaces.BigSegmentStore, error) {
 	return m.store, nil
 }
+
+func TestBigSegmentsStaleThreshold(t *testing.T) {
+	makeEnv := func(t *testing.T, allConfig config.Config, envConfig config.EnvConfig) EnvContext {
+		env, err := NewEnvContext(EnvContextImplParams{
+			Identifiers:   EnvIdentifiers{ConfiguredName: st.EnvMain.Name},
+			EnvConfig:     envConfig,
+			AllConfig:     allConfig,
+			ClientFactory: testclient.FakeLDClientFactory(true),
+			Loggers:       ldlog.NewDisabledLoggers(),
+		}, nil)
+		require.NoError(t, err)
+		return env
+	}
+
+	t.Run("default", func(t *testing.T) {
+		env := makeEnv(t, config.Config{}, st.EnvMain.Config)
+		defer env.Close()
+		assert.Equal(t, config.DefaultBigSegmentsStaleThreshold, env.GetBigSegmentsStaleThreshold())
+	})
+
+	t.Run("global property", func(t *testing.T) {
+		allConfig := config.Config{}
+		allConfig.Main.BigSegmentsStaleThreshold = configtypes.NewOptDuration(time.Minute)
+		env := makeEnv(t, allConfig, st.EnvMain.Config)
+		defer env.Close()
+		assert.Equal(t, time.Minute, env.GetBigSegmentsStaleThreshold())
+	})
+
+	t.Run("environment property overrides global property", func(t *testing.T) {
+		allConfig := config.Config{}
+		allConfig.Main.BigSegmentsStaleThreshold = configtypes.NewOptDuration(time.Minute)
+		envConfig := st.EnvMain.Config
+		envConfig.BigSegmentsStaleAfter = configtypes.NewOptDuration(time.Hour)
+		env := makeEnv(t, allConfig, envConfig)
+		defer env.Close()
+		assert.Equal(t, time.Hour, env.GetBigSegmentsStaleThreshold())
+	})
+}
+
+type fakeBigSegmentProvider struct {
+	membership ldeval.BigSegmentMembership
+	status     ldreason.BigSegmentsStatus
+}
+
+func (f fakeBigSegmentProvider) GetUserMembership(string) (ldeval.BigSegmentMembership, ldreason.BigSegmentsStatus) {
+	return f.membership, f.status
+}
+
+type fakeBigSegmentMembership struct{}
+
+func (m fakeBigSegmentMembership) CheckMembership(string) ldvalue.OptionalBool {
+	return ldvalue.NewOptionalBool(true)
+}
+
+func TestFailClosedBigSegmentProvider(t *testing.T) {
+	t.Run("data is used if not stale", func(t *testing.T) {
+		p := failClosedBigSegmentProvider{provider: fakeBigSegmentProvider{fakeBigSegmentMembership{}, ldreason.BigSegmentsHealthy}}
+		membership, status := p.GetUserMembership("user")
+		assert.Equal(t, fakeBigSegmentMembership{}, membership)
+		assert.Equal(t, ldreason.BigSegmentsHealthy, status)
+	})
+
+	t.Run("data is discarded if stale", func(t *testing.T) {
+		p := failClosedBigSegmentProvider{provider: fakeBigSegmentProvider{fakeBigSegmentMembership{}, ldreason.BigSegmentsStale}}
+		membership, status := p.GetUserMembership("user")
+		assert.Nil(t, membership)
+		assert.Equal(t, ldreason.BigSegmentsStale, status)
+	})
+}
+
+func TestBigSegmentsStatusLogger(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	logStatus := makeBigSegmentsStatusLogger(mockLog.Loggers)
+
+	logStatus(interfaces.BigSegmentStoreStatus{Available: true, Stale: false})
+	assert.Len(t, mockLog.GetAllOutput(), 0)
+
+	logStatus(interfaces.BigSegmentStoreStatus{Available: true, Stale: true})
+	logStatus(interfaces.BigSegmentStoreStatus{Available: true, Stale: true})
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 1)
+
+	logStatus(interfaces.BigSegmentStoreStatus{Available: true, Stale: false})
+	assert.Len(t, mockLog.GetOutput(ldlog.Info), 1)
+}
@@ -0,0 +1,102 @@
+package relayenv
+
+import (
+	"fmt"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/logging"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+// envLoggers creates the Loggers for an environment and for each of its subsystems.
+//
+// In the default text format, these are copies of the Loggers that were passed to NewEnvContext, with
+// a prefix identifying the environment. In JSON format, the environment and subsystem are properties
+// of each message instead, so these are new Loggers from logging.MakeJSONLoggers.
+//
+// The environment's level is a logging.LevelSwitch, so that it can be changed by a configuration reload
+// without recreating the environment. Subsystems that have their own level in the configuration keep
+// that level.
+type envLoggers struct {
+	base       ldlog.Loggers
+	mainConfig config.MainConfig
+	level      *logging.LevelSwitch
+	logName    string
+	envName    string
+}
+
+func newEnvLoggers(params EnvContextImplParams) envLoggers {
+	envConfig, allConfig := params.EnvConfig, params.AllConfig
+	globalLevel := params.GlobalLogLevel
+	if globalLevel == nil {
+		globalLevel = logging.NewLevelSwitch(allConfig.Main.LogLevel.GetOrElse(ldlog.Info))
+	}
+	level := globalLevel.Child()
+	if envConfig.LogLevel.IsDefined() {
+		level.SetLevel(envConfig.LogLevel.GetOrElse(ldlog.Info))
+	}
+	return envLoggers{
+		base:       params.Loggers,
+		mainConfig: allConfig.Main,
+		level:      level,
+		logName:    makeLogName(params.LogNameMode, envConfig.SDKKey, envConfig.EnvID),
+		envName:    params.Identifiers.GetDisplayName(),
+	}
+}
+
+// prefix returns the prefix for all of the environment's messages, for components that add their own
+// prefix after it. It is empty in JSON format.
+func (e envLoggers) prefix() string {
+	if e.mainConfig.LogFormat == config.LogFormatJSON {
+		return ""
+	}
+	return fmt.Sprintf("[env: %s]", e.logName)
+}
+
+// forEnvironment returns the Loggers for messages that aren't from any particular subsystem.
+func (e envLoggers) forEnvironment() ldlog.Loggers {
+	return e.level.Apply(e.make("", ""))
+}
+
+// forComponent returns the Loggers for one of the environment's subsystems. Its level is the one that
+// was configured for that subsystem, if any, or else the environment's level. The suffix is added to
+// the prefix in text format.
+func (e envLoggers) forComponent(component logging.Component, suffix string) ldlog.Loggers {
+	loggers := e.make(component, suffix)
+	if level := componentLogLevel(e.mainConfig, component); level.IsDefined() {
+		loggers.SetMinLevel(level.GetOrElse(ldlog.Info))
+		return loggers
+	}
+	return e.level.Apply(loggers)
+}
+
+func (e envLoggers) make(component logging.Component, suffix string) ldlog.Loggers {
+	var loggers ldlog.Loggers
+	if e.mainConfig.LogFormat == config.LogFormatJSON {
+		loggers = logging.MakeJSONLoggers(logging.Fields{
+			Component: component,
+			Env:       e.envName,
+			Extra:     map[string]string{"key": e.logName},
+		})
+	} else {
+		loggers = e.base
+		loggers.SetPrefix(e.prefix() + suffix)
+	}
+	return loggers
+}
+
+func componentLogLevel(c config.MainConfig, component logging.Component) config.OptLogLevel {
+	switch component {
+	case logging.ComponentSDKs:
+		return c.LogLevelSDKs
+	case logging.ComponentEvents:
+		return c.LogLevelEvents
+	case logging.ComponentBigSegments:
+		return c.LogLevelBigSegments
+	case logging.ComponentHTTP:
+		return c.LogLevelHTTP
+	default:
+		return config.OptLogLevel{}
+	}
+}
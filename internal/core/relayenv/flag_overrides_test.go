@@ -0,0 +1,94 @@
+package relayenv
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/eventsource"
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/streams"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	ld "gopkg.in/launchdarkly/go-server-sdk.v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlagOverrideChangeIsBroadcastWhenUpstreamIsAnotherRelay(t *testing.T) {
+	// When the upstream is another Relay instance, full data updates are reduced to the items whose
+	// versions have changed, so changing an override must change the version of the flag.
+	dir, err := ioutil.TempDir("", "flag-overrides-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	overridesPath := filepath.Join(dir, "overrides.yaml")
+	require.NoError(t, ioutil.WriteFile(overridesPath, []byte("flag1: 1\n"), 0600))
+
+	envConfig := st.EnvMain.Config
+	envConfig.FlagOverridesFile = overridesPath
+	var allConfig config.Config
+	allConfig.Main.UpstreamRelayURI, _ = configtypes.NewOptURLAbsoluteFromString("http://upstream-relay")
+
+	flag := ldbuilders.NewFlagBuilder("flag1").Version(1).On(true).FallthroughVariation(0).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).Build()
+	storeCh := make(chan interfaces.DataStore, 1)
+	clientFactory := func(sdkKey config.SDKKey, sdkConfig ld.Config, timeout time.Duration) (sdks.LDClientContext, error) {
+		store, err := sdkConfig.DataStore.CreateDataStore(st.SDKContextImpl{}, nil)
+		if err != nil {
+			return nil, err
+		}
+		storeCh <- store
+		sdkConfig.DataStore = nil
+		return testclient.FakeLDClientFactory(true)(sdkKey, sdkConfig, timeout)
+	}
+
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	serverSideStreams := streams.NewStreamProvider(basictypes.ServerSideStream, time.Hour)
+	readyCh := make(chan EnvContext, 1)
+	env, err := NewEnvContext(EnvContextImplParams{
+		Identifiers:     EnvIdentifiers{ConfiguredName: st.EnvMain.Name},
+		EnvConfig:       envConfig,
+		AllConfig:       allConfig,
+		ClientFactory:   clientFactory,
+		StreamProviders: []streams.StreamProvider{serverSideStreams},
+		Loggers:         mockLog.Loggers,
+	}, readyCh)
+	require.NoError(t, err)
+	defer env.Close()
+	requireEnvReady(t, readyCh)
+
+	store := <-storeCh
+	require.NoError(t, store.Init([]ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedItemDescriptor{{Key: flag.Key, Item: st.FlagDesc(flag)}}},
+		{Kind: ldstoreimpl.Segments(), Items: nil},
+	}))
+
+	streamHandler := env.GetStreamHandler(serverSideStreams, envConfig.SDKKey)
+	req, _ := http.NewRequest("GET", "", nil)
+	st.WithStreamRequest(t, req, streamHandler, func(eventCh <-chan eventsource.Event) {
+		initEvent := st.ExpectStreamChEvent(t, eventCh, time.Second)
+		assert.Equal(t, "put", initEvent.Event())
+
+		require.NoError(t, ioutil.WriteFile(overridesPath, []byte("flag1: 0\n"), 0600))
+
+		patchEvent := st.ExpectStreamChEvent(t, eventCh, time.Second*3)
+		assert.Equal(t, "patch", patchEvent.Event())
+		assert.Contains(t, patchEvent.Data(), `"version":2`)
+	})
+}
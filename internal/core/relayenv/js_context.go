@@ -1,6 +1,11 @@
 package relayenv
 
-import "net/http/httputil"
+import (
+	"net/http/httputil"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/browser"
+)
 
 // JSClientContext contains additional environment properties that are only relevant if this
 // environment supports JavaScript clients (i.e. we know its environment ID).
@@ -8,9 +13,16 @@ type JSClientContext struct {
 	// Origins is the configured list of allowed origins for CORS requests.
 	Origins []string
 
+	// OriginMatcher is created from Origins, to support patterns and regular expressions.
+	OriginMatcher browser.OriginMatcher
+
 	// Headers is the configured list of allowed headers for CORS requests.
 	Headers []string
 
+	// CORSMaxAge is how long browsers may cache the result of a CORS preflight request; zero means
+	// browser.DefaultMaxAge.
+	CORSMaxAge time.Duration
+
 	// Proxy is a ReverseProxy that we create for requests that are to be directly proxied to a
 	// LaunchDarkly endpoint. Despite its name, the Relay Proxy does not normally use direct
 	// proxying, but in the case of the goals resource for JS clients it is the simplest way.
@@ -22,7 +34,17 @@ func (c JSClientContext) AllowedOrigins() []string {
 	return c.Origins
 }
 
+// AllowsOrigin implements the internal interface for checking CORS allowed origins.
+func (c JSClientContext) AllowsOrigin(origin string) bool {
+	return c.OriginMatcher.Matches(origin)
+}
+
 // AllowedHeaders implements the internal interface for getting additional CORS allowed headers.
 func (c JSClientContext) AllowedHeaders() []string {
 	return c.Headers
 }
+
+// MaxAge implements the internal interface for getting the CORS preflight cache time.
+func (c JSClientContext) MaxAge() time.Duration {
+	return c.CORSMaxAge
+}
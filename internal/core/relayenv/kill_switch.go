@@ -0,0 +1,125 @@
+package relayenv
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/flagoverrides"
+
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+// killSwitch holds the state of an environment's kill switch. While it is on, Relay serves a fallback
+// set of flags instead of the flags that it has received from LaunchDarkly: the flags in the kill switch
+// file, if any, each fixed to the variation given in the file, and no other flags, so SDKs use their
+// application's default value for every other flag.
+type killSwitch struct {
+	filePath   string
+	fallback   flagoverrides.Overrides // nil if the kill switch is off
+	generation uint64                  // zero if the kill switch is off
+	lock       sync.RWMutex
+}
+
+// killSwitchGenerations is shared by all environments, so that a generation number is never reused even
+// if an environment is recreated.
+var killSwitchGenerations uint64 //nolint:gochecknoglobals
+
+// turnOn reads the kill switch file, if there is one, and turns on the kill switch; if it is already on,
+// the new contents of the file replace the previous fallback flags. If the file cannot be read, the
+// kill switch is unchanged.
+func (k *killSwitch) turnOn() error {
+	fallback := flagoverrides.Overrides{}
+	if k.filePath != "" {
+		overrides, err := flagoverrides.ReadOverridesFile(k.filePath)
+		if err != nil {
+			return err
+		}
+		if overrides != nil {
+			fallback = overrides
+		}
+	}
+	k.lock.Lock()
+	k.fallback = fallback
+	k.generation = atomic.AddUint64(&killSwitchGenerations, 1)
+	k.lock.Unlock()
+	return nil
+}
+
+// turnOff turns off the kill switch, and returns false if it was already off.
+func (k *killSwitch) turnOff() bool {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	wasOn := k.fallback != nil
+	k.fallback = nil
+	k.generation = 0
+	return wasOn
+}
+
+func (k *killSwitch) isOn() bool {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return k.fallback != nil
+}
+
+func (k *killSwitch) getGeneration() uint64 {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return k.generation
+}
+
+func (k *killSwitch) getFallback() flagoverrides.Overrides {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return k.fallback
+}
+
+// killSwitchDataStore is the view of the environment's data store that Relay's endpoints, streams, and
+// evaluator read from. While the kill switch is off, it is the same as the store; while it is on, only
+// the fallback flags are visible. The SDK writes directly to the store, so the data that Relay has
+// received is still up to date when the kill switch is turned off.
+type killSwitchDataStore struct {
+	interfaces.DataStore
+	killSwitch *killSwitch
+}
+
+func (s killSwitchDataStore) Get(kind ldstoretypes.DataKind, key string) (ldstoretypes.ItemDescriptor, error) {
+	item, err := s.DataStore.Get(kind, key)
+	fallback := s.killSwitch.getFallback()
+	if err != nil || fallback == nil || kind != ldstoreimpl.Features() || item.Item == nil {
+		return item, err
+	}
+	if flag, ok := applyKillSwitch(fallback, item); ok {
+		return flag, nil
+	}
+	return ldstoretypes.ItemDescriptor{}.NotFound(), nil
+}
+
+func (s killSwitchDataStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedItemDescriptor, error) {
+	items, err := s.DataStore.GetAll(kind)
+	fallback := s.killSwitch.getFallback()
+	if err != nil || fallback == nil || kind != ldstoreimpl.Features() {
+		return items, err
+	}
+	ret := make([]ldstoretypes.KeyedItemDescriptor, 0, len(fallback))
+	for _, keyedItem := range items {
+		if flag, ok := applyKillSwitch(fallback, keyedItem.Item); ok {
+			ret = append(ret, ldstoretypes.KeyedItemDescriptor{Key: keyedItem.Key, Item: flag})
+		}
+	}
+	return ret, nil
+}
+
+func applyKillSwitch(fallback flagoverrides.Overrides, item ldstoretypes.ItemDescriptor) (ldstoretypes.ItemDescriptor, bool) {
+	flag, ok := item.Item.(*ldmodel.FeatureFlag)
+	if !ok {
+		return item, false
+	}
+	newFlag, ok := fallback.Apply(flag)
+	if !ok {
+		return item, false
+	}
+	return ldstoretypes.ItemDescriptor{Version: item.Version, Item: newFlag}, true
+}
@@ -0,0 +1,182 @@
+package relayenv
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/eventsource"
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/streams"
+	"github.com/launchdarkly/ld-relay/v6/internal/flagoverrides"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:gochecknoglobals
+var (
+	killSwitchTestFlag1 = ldbuilders.NewFlagBuilder("flag1").Version(1).Variations(ldvalue.Bool(false), ldvalue.Bool(true)).Build()
+	killSwitchTestFlag2 = ldbuilders.NewFlagBuilder("flag2").Version(1).Variations(ldvalue.String("a")).Build()
+)
+
+func makeKillSwitchTestData() []ldstoretypes.Collection {
+	return []ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedItemDescriptor{
+			{Key: killSwitchTestFlag1.Key, Item: st.FlagDesc(killSwitchTestFlag1)},
+			{Key: killSwitchTestFlag2.Key, Item: st.FlagDesc(killSwitchTestFlag2)},
+		}},
+		{Kind: ldstoreimpl.Segments(), Items: []ldstoretypes.KeyedItemDescriptor{
+			{Key: st.Segment1.Key, Item: st.SegmentDesc(st.Segment1)},
+		}},
+	}
+}
+
+func withKillSwitchFile(t *testing.T, content string, action func(path string)) {
+	dir, err := ioutil.TempDir("", "kill-switch-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "kill-switch.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	action(path)
+}
+
+func TestKillSwitchDataStore(t *testing.T) {
+	store, err := ldcomponents.InMemoryDataStore().CreateDataStore(st.SDKContextImpl{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.Init(makeKillSwitchTestData()))
+	k := &killSwitch{}
+	s := killSwitchDataStore{DataStore: store, killSwitch: k}
+
+	t.Run("off", func(t *testing.T) {
+		flags, err := s.GetAll(ldstoreimpl.Features())
+		require.NoError(t, err)
+		assert.Len(t, flags, 2)
+	})
+
+	t.Run("on with no fallback flags", func(t *testing.T) {
+		k.fallback = flagoverrides.Overrides{}
+		defer func() { k.fallback = nil }()
+
+		flags, err := s.GetAll(ldstoreimpl.Features())
+		require.NoError(t, err)
+		assert.Len(t, flags, 0)
+		item, err := s.Get(ldstoreimpl.Features(), killSwitchTestFlag1.Key)
+		require.NoError(t, err)
+		assert.Nil(t, item.Item)
+
+		segments, err := s.GetAll(ldstoreimpl.Segments())
+		require.NoError(t, err)
+		assert.Len(t, segments, 1)
+	})
+
+	t.Run("on with fallback flags", func(t *testing.T) {
+		k.fallback = flagoverrides.Overrides{killSwitchTestFlag1.Key: 1, "unknown-flag": 0}
+		defer func() { k.fallback = nil }()
+
+		flags, err := s.GetAll(ldstoreimpl.Features())
+		require.NoError(t, err)
+		require.Len(t, flags, 1)
+		assert.Equal(t, killSwitchTestFlag1.Key, flags[0].Key)
+		assert.Equal(t, killSwitchTestFlag1.Version, flags[0].Item.Version)
+
+		item, err := s.Get(ldstoreimpl.Features(), killSwitchTestFlag1.Key)
+		require.NoError(t, err)
+		require.NotNil(t, item.Item)
+		assert.Equal(t, ldvalue.NewOptionalInt(1), item.Item.(*ldmodel.FeatureFlag).Fallthrough.Variation)
+		item, err = s.Get(ldstoreimpl.Features(), killSwitchTestFlag2.Key)
+		require.NoError(t, err)
+		assert.Nil(t, item.Item)
+	})
+}
+
+func TestKillSwitchTurnOnReadsFile(t *testing.T) {
+	withKillSwitchFile(t, "flag1: 1\n", func(path string) {
+		k := &killSwitch{filePath: path}
+		require.NoError(t, k.turnOn())
+		assert.True(t, k.isOn())
+		assert.Equal(t, flagoverrides.Overrides{"flag1": 1}, k.getFallback())
+
+		require.NoError(t, ioutil.WriteFile(path, []byte("flag1: -1\n"), 0600))
+		assert.Error(t, k.turnOn())
+		assert.Equal(t, flagoverrides.Overrides{"flag1": 1}, k.getFallback())
+
+		assert.True(t, k.turnOff())
+		assert.False(t, k.isOn())
+		assert.False(t, k.turnOff())
+	})
+
+	k := &killSwitch{filePath: "/not/a/real/file"}
+	assert.Error(t, k.turnOn())
+	assert.False(t, k.isOn())
+}
+
+func TestSetKillSwitchSendsFallbackDataToStreams(t *testing.T) {
+	withKillSwitchFile(t, "flag1: 1\n", func(path string) {
+		envConfig := st.EnvMain.Config
+		envConfig.KillSwitchFile = path
+		mockLog := ldlogtest.NewMockLog()
+		defer mockLog.DumpIfTestFailed(t)
+		serverSideStreams := streams.NewStreamProvider(basictypes.ServerSideStream, time.Hour)
+		readyCh := make(chan EnvContext, 1)
+		env, err := NewEnvContext(EnvContextImplParams{
+			Identifiers:     EnvIdentifiers{ConfiguredName: st.EnvMain.Name},
+			EnvConfig:       envConfig,
+			AllConfig:       config.Config{},
+			ClientFactory:   testclient.FakeLDClientFactory(true),
+			StreamProviders: []streams.StreamProvider{serverSideStreams},
+			Loggers:         mockLog.Loggers,
+		}, readyCh)
+		require.NoError(t, err)
+		defer env.Close()
+		requireEnvReady(t, readyCh)
+		require.NoError(t, env.GetStore().Init(makeKillSwitchTestData()))
+
+		streamHandler := env.GetStreamHandler(serverSideStreams, envConfig.SDKKey)
+		req, _ := http.NewRequest("GET", "", nil)
+		st.WithStreamRequest(t, req, streamHandler, func(eventCh <-chan eventsource.Event) {
+			initEvent := st.ExpectStreamChEvent(t, eventCh, time.Second)
+			assert.Equal(t, "put", initEvent.Event())
+			assert.Contains(t, initEvent.Data(), `"flag2"`)
+
+			require.NoError(t, env.SetKillSwitch(true))
+			assert.True(t, env.IsKillSwitchOn())
+			putEvent := st.ExpectStreamChEvent(t, eventCh, time.Second)
+			assert.Equal(t, "put", putEvent.Event())
+			assert.Contains(t, putEvent.Data(), `"flag1"`)
+			assert.NotContains(t, putEvent.Data(), `"flag2"`)
+
+			// updates from LaunchDarkly are not sent while the kill switch is on
+			flag2v2 := killSwitchTestFlag2
+			flag2v2.Version = 2
+			_, err := env.GetStore().Upsert(ldstoreimpl.Features(), flag2v2.Key, st.FlagDesc(flag2v2))
+			require.NoError(t, err)
+			st.ExpectNoStreamChEvent(t, eventCh, time.Millisecond*100)
+
+			require.NoError(t, env.SetKillSwitch(false))
+			assert.False(t, env.IsKillSwitchOn())
+			putEvent = st.ExpectStreamChEvent(t, eventCh, time.Second)
+			assert.Equal(t, "put", putEvent.Event())
+			assert.Contains(t, putEvent.Data(), `"flag2"`)
+			assert.Contains(t, putEvent.Data(), `"version":2`)
+
+			// turning it off again does nothing
+			require.NoError(t, env.SetKillSwitch(false))
+			st.ExpectNoStreamChEvent(t, eventCh, time.Millisecond*100)
+		})
+	})
+}
@@ -0,0 +1,150 @@
+package relayenv
+
+import (
+	"sync"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/leader"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+// leaderDataStoreFactory wraps the persistent data store factory when leader election is enabled, so
+// that only the leader writes to the persistent store. A standby instance still receives flag data from
+// LaunchDarkly and serves it, but keeps it in memory.
+type leaderDataStoreFactory struct {
+	wrapped interfaces.DataStoreFactory
+	elector *leader.Elector
+	loggers ldlog.Loggers
+}
+
+// leaderDataStore sends all reads and writes to the persistent store while this instance is the leader,
+// and to an in-memory store while it is on standby. When it becomes the leader, it writes everything that
+// it received while on standby to the persistent store, since the previous leader may not have written
+// the latest data; when it stops being the leader, it starts over from what is in the persistent store.
+type leaderDataStore struct {
+	persistent     interfaces.DataStore
+	memory         interfaces.DataStore
+	context        interfaces.ClientContext
+	isLeader       bool
+	removeListener func()
+	loggers        ldlog.Loggers
+	lock           sync.RWMutex
+}
+
+func (f leaderDataStoreFactory) CreateDataStore(
+	context interfaces.ClientContext,
+	dataStoreUpdates interfaces.DataStoreUpdates,
+) (interfaces.DataStore, error) {
+	persistent, err := f.wrapped.CreateDataStore(context, dataStoreUpdates)
+	if err != nil {
+		return nil, err
+	}
+	memory, _ := ldcomponents.InMemoryDataStore().CreateDataStore(context, nil)
+	s := &leaderDataStore{
+		persistent: persistent,
+		memory:     memory,
+		context:    context,
+		isLeader:   f.elector.IsLeader(),
+		loggers:    f.loggers,
+	}
+	s.removeListener = f.elector.OnChange(s.setLeader)
+	s.setLeader(f.elector.IsLeader()) // in case it changed before the listener was added
+	return s, nil
+}
+
+func (s *leaderDataStore) setLeader(isLeader bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if isLeader == s.isLeader {
+		return
+	}
+	s.isLeader = isLeader
+	if isLeader {
+		if s.memory.IsInitialized() {
+			if err := copyAllData(s.memory, s.persistent); err != nil {
+				s.loggers.Errorf("Unable to write flag data to the persistent store after becoming the leader: %s", err)
+			}
+		}
+		// The in-memory data won't be used again unless this instance goes back on standby, and then it
+		// will be replaced anyway.
+		s.memory, _ = ldcomponents.InMemoryDataStore().CreateDataStore(s.context, nil)
+		return
+	}
+	if s.persistent.IsInitialized() {
+		if err := copyAllData(s.persistent, s.memory); err != nil {
+			s.loggers.Errorf("Unable to read flag data from the persistent store after standing by: %s", err)
+		}
+	}
+}
+
+func copyAllData(from, to interfaces.DataStore) error {
+	var allData []ldstoretypes.Collection
+	for _, kind := range ldstoreimpl.AllKinds() {
+		items, err := from.GetAll(kind)
+		if err != nil {
+			return err
+		}
+		allData = append(allData, ldstoretypes.Collection{Kind: kind, Items: items})
+	}
+	return to.Init(allData)
+}
+
+// current returns the store that is in use. The caller must hold the lock.
+func (s *leaderDataStore) current() interfaces.DataStore {
+	if s.isLeader {
+		return s.persistent
+	}
+	return s.memory
+}
+
+func (s *leaderDataStore) Init(allData []ldstoretypes.Collection) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.current().Init(allData)
+}
+
+func (s *leaderDataStore) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.ItemDescriptor,
+) (bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.current().Upsert(kind, key, item)
+}
+
+func (s *leaderDataStore) Get(kind ldstoretypes.DataKind, key string) (ldstoretypes.ItemDescriptor, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.current().Get(kind, key)
+}
+
+func (s *leaderDataStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedItemDescriptor, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.current().GetAll(kind)
+}
+
+func (s *leaderDataStore) IsInitialized() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.current().IsInitialized()
+}
+
+// IsStatusMonitoringEnabled refers to the persistent store even on standby, since the SDK only checks it
+// once, when it is starting.
+func (s *leaderDataStore) IsStatusMonitoringEnabled() bool {
+	return s.persistent.IsStatusMonitoringEnabled()
+}
+
+func (s *leaderDataStore) Close() error {
+	s.removeListener()
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	_ = s.memory.Close()
+	return s.persistent.Close()
+}
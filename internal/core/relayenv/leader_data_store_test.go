@@ -0,0 +1,71 @@
+package relayenv
+
+import (
+	"testing"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderDataStoreWritesToPersistentStoreOnlyAsLeader(t *testing.T) {
+	persistent := st.NewInMemoryStore()
+	// a nil Elector is always the leader, so we change the state by calling setLeader directly
+	f := leaderDataStoreFactory{wrapped: st.ExistingDataStoreFactory{Instance: persistent}, loggers: ldlog.NewDisabledLoggers()}
+	store, err := f.CreateDataStore(st.SDKContextImpl{}, nil)
+	require.NoError(t, err)
+	s := store.(*leaderDataStore)
+
+	require.NoError(t, s.Init(makeKillSwitchTestData()))
+	assert.True(t, persistent.IsInitialized())
+
+	s.setLeader(false)
+	assert.True(t, s.IsInitialized())
+	flags, err := s.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, flags, 2)
+
+	flag1v2 := killSwitchTestFlag1
+	flag1v2.Version = 2
+	_, err = s.Upsert(ldstoreimpl.Features(), flag1v2.Key, st.FlagDesc(flag1v2))
+	require.NoError(t, err)
+	item, err := s.Get(ldstoreimpl.Features(), flag1v2.Key)
+	require.NoError(t, err)
+	assert.Equal(t, 2, item.Version)
+	item, err = persistent.Get(ldstoreimpl.Features(), flag1v2.Key)
+	require.NoError(t, err)
+	assert.Equal(t, 1, item.Version)
+
+	s.setLeader(true)
+	item, err = persistent.Get(ldstoreimpl.Features(), flag1v2.Key)
+	require.NoError(t, err)
+	assert.Equal(t, 2, item.Version)
+	segments, err := persistent.GetAll(ldstoreimpl.Segments())
+	require.NoError(t, err)
+	assert.Len(t, segments, 1)
+
+	require.NoError(t, s.Close())
+}
+
+func TestLeaderDataStoreStandbyBeforeInitialized(t *testing.T) {
+	persistent := st.NewInMemoryStore()
+	f := leaderDataStoreFactory{wrapped: st.ExistingDataStoreFactory{Instance: persistent}, loggers: ldlog.NewDisabledLoggers()}
+	store, err := f.CreateDataStore(st.SDKContextImpl{}, nil)
+	require.NoError(t, err)
+	s := store.(*leaderDataStore)
+
+	s.setLeader(false)
+	assert.False(t, s.IsInitialized())
+	require.NoError(t, s.Init([]ldstoretypes.Collection{}))
+	assert.True(t, s.IsInitialized())
+	assert.False(t, persistent.IsInitialized())
+	assert.Equal(t, persistent.IsStatusMonitoringEnabled(), s.IsStatusMonitoringEnabled())
+
+	s.setLeader(true)
+	assert.True(t, persistent.IsInitialized())
+}
@@ -0,0 +1,132 @@
+package relayenv
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/streams"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+var errReadReplicaStoreNotInitialized = errors.New("the data store has not been populated by another Relay instance yet")
+
+// readReplicaDataSourceFactory creates the SDK data source that is used in read replica mode. Instead of
+// connecting to LaunchDarkly, it periodically reads all of the environment's data from the persistent
+// store, which is kept up to date by other Relay instances, and passes it to the stream updater; since
+// the stream updater is wrapped with streams.NewDeltaEnvStreamUpdates, connected SDKs receive only the
+// items that have changed.
+//
+// It never writes to the store. Its only use of the SDK's DataSourceUpdates is to report its status:
+// the status is INTERRUPTED, with a STORE_ERROR, whenever the last read failed or the store had no
+// data, so the environment is reported as disconnected once that has lasted for DisconnectedStatusTime.
+type readReplicaDataSourceFactory struct {
+	getStore      func() interfaces.DataStore
+	streamUpdates streams.EnvStreamUpdates
+	pollInterval  time.Duration
+	loggers       ldlog.Loggers
+}
+
+type readReplicaDataSource struct {
+	readReplicaDataSourceFactory
+	dataSourceUpdates interfaces.DataSourceUpdates
+	initialized       bool
+	failing           bool
+	closeCh           chan struct{}
+	closeOnce         sync.Once
+	lock              sync.Mutex
+}
+
+func (f readReplicaDataSourceFactory) CreateDataSource(
+	context interfaces.ClientContext,
+	dataSourceUpdates interfaces.DataSourceUpdates,
+) (interfaces.DataSource, error) {
+	return &readReplicaDataSource{
+		readReplicaDataSourceFactory: f,
+		dataSourceUpdates:            dataSourceUpdates,
+		closeCh:                      make(chan struct{}),
+	}, nil
+}
+
+func (d *readReplicaDataSource) IsInitialized() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.initialized
+}
+
+func (d *readReplicaDataSource) Start(closeWhenReady chan<- struct{}) {
+	go func() {
+		ticker := time.NewTicker(d.pollInterval)
+		defer ticker.Stop()
+		readyCh := closeWhenReady
+		for {
+			if d.poll() && readyCh != nil {
+				close(readyCh)
+				readyCh = nil
+			}
+			select {
+			case <-d.closeCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (d *readReplicaDataSource) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.closeCh)
+	})
+	return nil
+}
+
+// poll reads the data from the store and returns true if successful.
+func (d *readReplicaDataSource) poll() bool {
+	store := d.getStore()
+	if store == nil {
+		return false // COVERAGE: the SDK always creates the store before the data source
+	}
+	var allData []ldstoretypes.Collection
+	err := errReadReplicaStoreNotInitialized
+	if store.IsInitialized() {
+		err = nil
+		for _, kind := range []ldstoretypes.DataKind{ldstoreimpl.Features(), ldstoreimpl.Segments()} {
+			items, getErr := store.GetAll(kind)
+			if getErr != nil {
+				err = getErr
+				break
+			}
+			allData = append(allData, ldstoretypes.Collection{Kind: kind, Items: items})
+		}
+	}
+
+	d.lock.Lock()
+	wasFailing := d.failing
+	d.failing = err != nil
+	if err == nil {
+		d.initialized = true
+	}
+	d.lock.Unlock()
+
+	if err != nil {
+		if !wasFailing {
+			d.loggers.Warnf("Unable to read flag data from the data store in read replica mode: %s", err)
+		}
+		d.dataSourceUpdates.UpdateStatus(interfaces.DataSourceStateInterrupted, interfaces.DataSourceErrorInfo{
+			Kind:    interfaces.DataSourceErrorKindStoreError,
+			Message: err.Error(),
+			Time:    time.Now(),
+		})
+		return false
+	}
+	if wasFailing {
+		d.loggers.Info("Flag data is available again from the data store in read replica mode")
+	}
+	d.streamUpdates.SendAllDataUpdate(allData)
+	d.dataSourceUpdates.UpdateStatus(interfaces.DataSourceStateValid, interfaces.DataSourceErrorInfo{})
+	return true
+}
@@ -0,0 +1,140 @@
+package relayenv
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type readReplicaTestUpdates struct {
+	statuses []interfaces.DataSourceState
+	lock     sync.Mutex
+}
+
+func (u *readReplicaTestUpdates) Init(allData []ldstoretypes.Collection) bool { return true }
+
+func (u *readReplicaTestUpdates) Upsert(ldstoretypes.DataKind, string, ldstoretypes.ItemDescriptor) bool {
+	return true
+}
+
+func (u *readReplicaTestUpdates) UpdateStatus(newState interfaces.DataSourceState, newError interfaces.DataSourceErrorInfo) {
+	u.lock.Lock()
+	u.statuses = append(u.statuses, newState)
+	u.lock.Unlock()
+}
+
+func (u *readReplicaTestUpdates) GetDataStoreStatusProvider() interfaces.DataStoreStatusProvider {
+	return nil
+}
+
+func (u *readReplicaTestUpdates) getStatuses() []interfaces.DataSourceState {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return append([]interfaces.DataSourceState(nil), u.statuses...)
+}
+
+type readReplicaTestStreamUpdates struct {
+	allDataUpdates [][]ldstoretypes.Collection
+	lock           sync.Mutex
+}
+
+func (r *readReplicaTestStreamUpdates) SendAllDataUpdate(allData []ldstoretypes.Collection) {
+	r.lock.Lock()
+	r.allDataUpdates = append(r.allDataUpdates, allData)
+	r.lock.Unlock()
+}
+
+func (r *readReplicaTestStreamUpdates) SendSingleItemUpdate(ldstoretypes.DataKind, string, ldstoretypes.ItemDescriptor) {
+}
+
+func (r *readReplicaTestStreamUpdates) InvalidateClientSideState() {}
+
+func (r *readReplicaTestStreamUpdates) count() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.allDataUpdates)
+}
+
+func makeReadReplicaDataSource(
+	t *testing.T,
+	store interfaces.DataStore,
+	loggers ldlog.Loggers,
+) (*readReplicaDataSource, *readReplicaTestUpdates, *readReplicaTestStreamUpdates) {
+	updates := &readReplicaTestUpdates{}
+	streamUpdates := &readReplicaTestStreamUpdates{}
+	factory := readReplicaDataSourceFactory{
+		getStore:      func() interfaces.DataStore { return store },
+		streamUpdates: streamUpdates,
+		pollInterval:  time.Millisecond * 10,
+		loggers:       loggers,
+	}
+	ds, err := factory.CreateDataSource(nil, updates)
+	require.NoError(t, err)
+	return ds.(*readReplicaDataSource), updates, streamUpdates
+}
+
+func TestReadReplicaPollSendsAllDataFromStore(t *testing.T) {
+	ds, updates, streamUpdates := makeReadReplicaDataSource(t, sharedtest.MakeStoreWithData(true), ldlog.NewDisabledLoggers())
+
+	assert.True(t, ds.poll())
+	assert.True(t, ds.IsInitialized())
+	assert.Equal(t, []interfaces.DataSourceState{interfaces.DataSourceStateValid}, updates.getStatuses())
+	require.Equal(t, 1, streamUpdates.count())
+	assert.Len(t, streamUpdates.allDataUpdates[0], 2)
+}
+
+func TestReadReplicaPollReportsInterruptedIfStoreIsNotInitialized(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	store := sharedtest.MakeStoreWithData(false)
+	ds, updates, streamUpdates := makeReadReplicaDataSource(t, store, mockLog.Loggers)
+
+	assert.False(t, ds.poll())
+	assert.False(t, ds.poll())
+	assert.False(t, ds.IsInitialized())
+	assert.Equal(t, []interfaces.DataSourceState{interfaces.DataSourceStateInterrupted, interfaces.DataSourceStateInterrupted},
+		updates.getStatuses())
+	assert.Equal(t, 0, streamUpdates.count())
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 1)
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "has not been populated")
+
+	require.NoError(t, store.Init(sharedtest.AllData))
+	assert.True(t, ds.poll())
+	assert.True(t, ds.IsInitialized())
+	assert.Equal(t, interfaces.DataSourceStateValid, updates.getStatuses()[2])
+	assert.Equal(t, 1, streamUpdates.count())
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "available again")
+}
+
+func TestReadReplicaStartClosesReadyChannelAfterFirstSuccessfulPoll(t *testing.T) {
+	store := sharedtest.MakeStoreWithData(false)
+	ds, _, streamUpdates := makeReadReplicaDataSource(t, store, ldlog.NewDisabledLoggers())
+	defer ds.Close()
+
+	readyCh := make(chan struct{})
+	ds.Start(readyCh)
+
+	select {
+	case <-readyCh:
+		require.Fail(t, "data source should not be ready before the store is initialized")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	require.NoError(t, store.Init(sharedtest.AllData))
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		require.Fail(t, "timed out waiting for data source to be ready")
+	}
+	assert.GreaterOrEqual(t, streamUpdates.count(), 1)
+}
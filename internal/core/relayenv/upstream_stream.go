@@ -0,0 +1,403 @@
+package relayenv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	es "github.com/launchdarkly/eventsource"
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+const (
+	upstreamStreamPath        = "/all"
+	upstreamStreamReadTimeout = 5 * time.Minute // the LaunchDarkly stream should send a heartbeat comment every 3 minutes
+)
+
+var errUpstreamStreamMissingData = errors.New("event had no data property")
+
+// upstreamStreamOptions are the reconnection parameters of an environment's stream connection to
+// LaunchDarkly. After a failure, the first retry is after initialRetryDelay, and the delay doubles for
+// each further retry up to maxRetryDelay; jitter is the proportion of each delay that is randomized.
+// Once a connection has stayed up for retryResetInterval, the next failure starts again from
+// initialRetryDelay.
+type upstreamStreamOptions struct {
+	initialRetryDelay  time.Duration
+	maxRetryDelay      time.Duration
+	jitter             float64
+	retryResetInterval time.Duration
+}
+
+func makeUpstreamStreamOptions(mainConfig config.MainConfig, envConfig config.EnvConfig) upstreamStreamOptions {
+	orElse := func(envValue, mainValue ct.OptDuration, defaultValue time.Duration) time.Duration {
+		if envValue.IsDefined() {
+			return envValue.GetOrElse(0)
+		}
+		return mainValue.GetOrElse(defaultValue)
+	}
+	jitter := mainConfig.StreamRetryJitter.GetOrElse(config.DefaultStreamRetryJitter)
+	if envConfig.StreamRetryJitter.IsDefined() {
+		jitter = envConfig.StreamRetryJitter.GetOrElse(0)
+	}
+	return upstreamStreamOptions{
+		initialRetryDelay: orElse(envConfig.StreamInitialRetryDelay, mainConfig.StreamInitialRetryDelay,
+			config.DefaultStreamInitialRetryDelay),
+		maxRetryDelay: orElse(envConfig.StreamMaxRetryDelay, mainConfig.StreamMaxRetryDelay,
+			config.DefaultStreamMaxRetryDelay),
+		jitter: jitter,
+		retryResetInterval: orElse(envConfig.StreamRetryResetInterval, mainConfig.StreamRetryResetInterval,
+			config.DefaultStreamRetryResetInterval),
+	}
+}
+
+// upstreamStreamDataSourceFactory creates the SDK data source that connects to the LaunchDarkly stream.
+// It does the same thing as the SDK's own streaming data source, except that the reconnection behavior
+// is configurable, and onReconnect is called whenever the stream fails and is going to be retried, so
+// that the environment can record it as a metric.
+type upstreamStreamDataSourceFactory struct {
+	streamURI   string
+	options     upstreamStreamOptions
+	onReconnect func()
+}
+
+type upstreamStreamDataSource struct {
+	upstreamStreamDataSourceFactory
+	dataSourceUpdates interfaces.DataSourceUpdates
+	client            *http.Client
+	headers           http.Header
+	loggers           ldlog.Loggers
+	storeStatusCh     <-chan interfaces.DataStoreStatus
+	initialized       bool
+	closeCh           chan struct{}
+	readyOnce         sync.Once
+	closeOnce         sync.Once
+	lock              sync.Mutex
+}
+
+type upstreamPutData struct {
+	Data map[string]map[string]json.RawMessage `json:"data"`
+}
+
+type upstreamPatchData struct {
+	Path string          `json:"path"`
+	Data json.RawMessage `json:"data"`
+}
+
+type upstreamDeleteData struct {
+	Path    string `json:"path"`
+	Version int    `json:"version"`
+}
+
+func (f upstreamStreamDataSourceFactory) CreateDataSource(
+	context interfaces.ClientContext,
+	dataSourceUpdates interfaces.DataSourceUpdates,
+) (interfaces.DataSource, error) {
+	client := context.GetHTTP().CreateHTTPClient()
+	// Client.Timeout must be zeroed out for stream connections, since it's not just a connect timeout
+	// but a timeout for the entire response
+	client.Timeout = 0
+	return &upstreamStreamDataSource{
+		upstreamStreamDataSourceFactory: f,
+		dataSourceUpdates:               dataSourceUpdates,
+		client:                          client,
+		headers:                         context.GetHTTP().GetDefaultHeaders(),
+		loggers:                         context.GetLogging().GetLoggers(),
+		closeCh:                         make(chan struct{}),
+	}, nil
+}
+
+// DescribeConfigurationContext is used by the SDK to describe the data source in diagnostic events, in
+// the same way as for its own streaming data source.
+func (f upstreamStreamDataSourceFactory) DescribeConfigurationContext(interfaces.ClientContext) ldvalue.Value {
+	return ldvalue.ObjectBuild().
+		Set("streamingDisabled", ldvalue.Bool(false)).
+		Set("customStreamURI", ldvalue.Bool(strings.TrimSuffix(f.streamURI, "/") != config.DefaultStreamURI)).
+		Set("reconnectTimeMillis", ldvalue.Float64(float64(f.options.initialRetryDelay/time.Millisecond))).
+		Set("usingRelayDaemon", ldvalue.Bool(false)).
+		Build()
+}
+
+func (d *upstreamStreamDataSource) IsInitialized() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.initialized
+}
+
+func (d *upstreamStreamDataSource) Start(closeWhenReady chan<- struct{}) {
+	d.loggers.Info("Starting LaunchDarkly streaming connection")
+	if p := d.dataSourceUpdates.GetDataStoreStatusProvider(); p != nil && p.IsStatusMonitoringEnabled() {
+		d.storeStatusCh = p.AddStatusListener()
+	}
+	go d.subscribe(closeWhenReady)
+}
+
+func (d *upstreamStreamDataSource) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.closeCh)
+		if d.storeStatusCh != nil {
+			d.dataSourceUpdates.GetDataStoreStatusProvider().RemoveStatusListener(d.storeStatusCh)
+		}
+		d.dataSourceUpdates.UpdateStatus(interfaces.DataSourceStateOff, interfaces.DataSourceErrorInfo{})
+	})
+	return nil
+}
+
+func (d *upstreamStreamDataSource) isClosed() bool {
+	select {
+	case <-d.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *upstreamStreamDataSource) setReady(closeWhenReady chan<- struct{}) {
+	d.readyOnce.Do(func() { close(closeWhenReady) })
+}
+
+func (d *upstreamStreamDataSource) subscribe(closeWhenReady chan<- struct{}) {
+	req, _ := http.NewRequest("GET", strings.TrimSuffix(d.streamURI, "/")+upstreamStreamPath, nil)
+	for k, vv := range d.headers {
+		req.Header[k] = vv
+	}
+	d.loggers.Info("Connecting to LaunchDarkly stream")
+
+	errorHandler := func(err error) es.StreamErrorHandlerResult {
+		if d.isClosed() {
+			return es.StreamErrorHandlerResult{CloseNow: true}
+		}
+		if se, ok := err.(es.SubscriptionError); ok {
+			errorInfo := interfaces.DataSourceErrorInfo{
+				Kind:       interfaces.DataSourceErrorKindErrorResponse,
+				StatusCode: se.Code,
+				Time:       time.Now(),
+			}
+			if !isHTTPErrorRecoverable(se.Code) {
+				d.loggers.Errorf("Error in stream connection (giving up permanently): HTTP error %d", se.Code)
+				d.dataSourceUpdates.UpdateStatus(interfaces.DataSourceStateOff, errorInfo)
+				d.setReady(closeWhenReady)
+				return es.StreamErrorHandlerResult{CloseNow: true}
+			}
+			d.loggers.Warnf("Error in stream connection (will retry): HTTP error %d", se.Code)
+			d.dataSourceUpdates.UpdateStatus(interfaces.DataSourceStateInterrupted, errorInfo)
+		} else {
+			d.loggers.Warnf("Error in stream connection (will retry): %s", err)
+			d.dataSourceUpdates.UpdateStatus(interfaces.DataSourceStateInterrupted, interfaces.DataSourceErrorInfo{
+				Kind:    interfaces.DataSourceErrorKindNetworkError,
+				Message: err.Error(),
+				Time:    time.Now(),
+			})
+		}
+		if d.onReconnect != nil {
+			d.onReconnect()
+		}
+		return es.StreamErrorHandlerResult{CloseNow: false}
+	}
+
+	streamOptions := []es.StreamOption{
+		es.StreamOptionHTTPClient(d.client),
+		es.StreamOptionReadTimeout(upstreamStreamReadTimeout),
+		es.StreamOptionInitialRetry(d.options.initialRetryDelay),
+		es.StreamOptionRetryResetInterval(d.options.retryResetInterval),
+		es.StreamOptionErrorHandler(errorHandler),
+		es.StreamOptionCanRetryFirstConnection(-1),
+		es.StreamOptionLogger(d.loggers.ForLevel(ldlog.Info)),
+	}
+	if d.options.maxRetryDelay > 0 {
+		streamOptions = append(streamOptions, es.StreamOptionUseBackoff(d.options.maxRetryDelay))
+	}
+	if d.options.jitter > 0 {
+		streamOptions = append(streamOptions, es.StreamOptionUseJitter(d.options.jitter))
+	}
+	stream, err := es.SubscribeWithRequestAndOptions(req, streamOptions...)
+	if err != nil {
+		d.setReady(closeWhenReady)
+		return
+	}
+
+	d.consumeStream(stream, closeWhenReady)
+}
+
+func (d *upstreamStreamDataSource) consumeStream(stream *es.Stream, closeWhenReady chan<- struct{}) {
+	// Consume remaining Events and Errors so we can garbage collect
+	defer func() {
+		for range stream.Events {
+		} // COVERAGE: no way to cause this condition in unit tests
+		if stream.Errors != nil {
+			for range stream.Errors { // COVERAGE: no way to cause this condition in unit tests
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-stream.Events:
+			if !ok {
+				return // COVERAGE: only happens if the stream was closed, in which case we've already returned
+			}
+			if err := d.handleEvent(event, closeWhenReady); err != nil {
+				if _, isStoreError := err.(upstreamStoreError); isStoreError {
+					if d.storeStatusCh != nil {
+						// The store will tell us when it has recovered; see below.
+						d.loggers.Errorf("%s; will try again once data store is working", err)
+						continue
+					}
+					d.loggers.Errorf("%s; will restart stream until successful", err)
+				} else {
+					d.loggers.Errorf("Received streaming %q event with malformed data (%s); will restart stream",
+						event.Event(), err)
+					d.dataSourceUpdates.UpdateStatus(interfaces.DataSourceStateInterrupted, interfaces.DataSourceErrorInfo{
+						Kind:    interfaces.DataSourceErrorKindInvalidData,
+						Message: err.Error(),
+						Time:    time.Now(),
+					})
+				}
+				stream.Restart()
+				continue
+			}
+			d.dataSourceUpdates.UpdateStatus(interfaces.DataSourceStateValid, interfaces.DataSourceErrorInfo{})
+
+		case newStoreStatus := <-d.storeStatusCh:
+			if newStoreStatus.Available {
+				if newStoreStatus.NeedsRefresh {
+					d.loggers.Warn("Restarting stream to refresh data after data store outage")
+					stream.Restart()
+				}
+				d.setInitialized(closeWhenReady)
+			}
+
+		case <-d.closeCh:
+			stream.Close()
+			return
+		}
+	}
+}
+
+type upstreamStoreError string
+
+func (e upstreamStoreError) Error() string { return string(e) }
+
+func (d *upstreamStreamDataSource) handleEvent(event es.Event, closeWhenReady chan<- struct{}) error {
+	switch event.Event() {
+	case "put":
+		var put upstreamPutData
+		if err := json.Unmarshal([]byte(event.Data()), &put); err != nil {
+			return err
+		}
+		if put.Data == nil {
+			return errUpstreamStreamMissingData
+		}
+		allData := make([]ldstoretypes.Collection, 0, len(put.Data))
+		for kindName, items := range put.Data {
+			kind := upstreamDataKind(kindName)
+			if kind == nil {
+				continue
+			}
+			coll := ldstoretypes.Collection{Kind: kind, Items: make([]ldstoretypes.KeyedItemDescriptor, 0, len(items))}
+			for key, itemJSON := range items {
+				item, err := kind.Deserialize(itemJSON)
+				if err != nil {
+					return fmt.Errorf("invalid %s %q: %w", kindName, key, err)
+				}
+				coll.Items = append(coll.Items, ldstoretypes.KeyedItemDescriptor{Key: key, Item: item})
+			}
+			allData = append(allData, coll)
+		}
+		if !d.dataSourceUpdates.Init(allData) {
+			return upstreamStoreError("Failed to store initial streaming data in data store")
+		}
+		d.setInitialized(closeWhenReady)
+
+	case "patch":
+		var patch upstreamPatchData
+		if err := json.Unmarshal([]byte(event.Data()), &patch); err != nil {
+			return err
+		}
+		kind, key := parseUpstreamPath(patch.Path)
+		if kind == nil {
+			return nil // ignore unrecognized item type
+		}
+		if patch.Data == nil {
+			return errUpstreamStreamMissingData
+		}
+		item, err := kind.Deserialize(patch.Data)
+		if err != nil {
+			return err
+		}
+		if !d.dataSourceUpdates.Upsert(kind, key, item) {
+			return upstreamStoreError("Failed to store streaming update of " + key + " in data store")
+		}
+
+	case "delete":
+		var del upstreamDeleteData
+		if err := json.Unmarshal([]byte(event.Data()), &del); err != nil {
+			return err
+		}
+		kind, key := parseUpstreamPath(del.Path)
+		if kind == nil {
+			return nil // ignore unrecognized item type
+		}
+		if !d.dataSourceUpdates.Upsert(kind, key, ldstoretypes.ItemDescriptor{Version: del.Version}) {
+			return upstreamStoreError("Failed to store streaming deletion of " + key + " in data store")
+		}
+
+	default:
+		d.loggers.Infof("Unexpected event found in stream: %s", event.Event())
+	}
+	return nil
+}
+
+func (d *upstreamStreamDataSource) setInitialized(closeWhenReady chan<- struct{}) {
+	d.lock.Lock()
+	wasInitialized := d.initialized
+	d.initialized = true
+	d.lock.Unlock()
+	if !wasInitialized {
+		d.loggers.Info("LaunchDarkly streaming is active")
+	}
+	d.setReady(closeWhenReady)
+}
+
+func upstreamDataKind(name string) ldstoretypes.DataKind {
+	switch name {
+	case "flags":
+		return ldstoreimpl.Features()
+	case "segments":
+		return ldstoreimpl.Segments()
+	default:
+		return nil
+	}
+}
+
+func parseUpstreamPath(path string) (ldstoretypes.DataKind, string) {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if len(parts) != 2 {
+		return nil, ""
+	}
+	return upstreamDataKind(parts[0]), parts[1]
+}
+
+// isHTTPErrorRecoverable tests whether an HTTP error status might go away if we retry. As in the SDK, all
+// 4xx errors other than 400, 408, and 429 are considered permanent, since they mean the SDK key is invalid.
+func isHTTPErrorRecoverable(statusCode int) bool {
+	if statusCode >= 400 && statusCode < 500 {
+		switch statusCode {
+		case 400, 408, 429:
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+package relayenv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-test-helpers/v2/httphelpers"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/testhelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type upstreamStreamTestUpdates struct {
+	inits    [][]ldstoretypes.Collection
+	upserts  []string
+	statuses []interfaces.DataSourceState
+	lock     sync.Mutex
+}
+
+func (u *upstreamStreamTestUpdates) Init(allData []ldstoretypes.Collection) bool {
+	u.lock.Lock()
+	u.inits = append(u.inits, allData)
+	u.lock.Unlock()
+	return true
+}
+
+func (u *upstreamStreamTestUpdates) Upsert(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) bool {
+	u.lock.Lock()
+	u.upserts = append(u.upserts, kind.GetName()+"/"+key)
+	u.lock.Unlock()
+	return true
+}
+
+func (u *upstreamStreamTestUpdates) UpdateStatus(newState interfaces.DataSourceState, newError interfaces.DataSourceErrorInfo) {
+	u.lock.Lock()
+	u.statuses = append(u.statuses, newState)
+	u.lock.Unlock()
+}
+
+func (u *upstreamStreamTestUpdates) GetDataStoreStatusProvider() interfaces.DataStoreStatusProvider {
+	return nil
+}
+
+func (u *upstreamStreamTestUpdates) getUpserts() []string {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return append([]string(nil), u.upserts...)
+}
+
+func (u *upstreamStreamTestUpdates) getStatuses() []interfaces.DataSourceState {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return append([]interfaces.DataSourceState(nil), u.statuses...)
+}
+
+func startUpstreamStreamDataSource(
+	t *testing.T,
+	handler http.Handler,
+	options upstreamStreamOptions,
+	onReconnect func(),
+) (*upstreamStreamDataSource, *upstreamStreamTestUpdates, <-chan struct{}, func()) {
+	server := httptest.NewServer(handler)
+	factory := upstreamStreamDataSourceFactory{streamURI: server.URL, options: options, onReconnect: onReconnect}
+	updates := &upstreamStreamTestUpdates{}
+	ds, err := factory.CreateDataSource(testhelpers.NewSimpleClientContext("sdk-key"), updates)
+	require.NoError(t, err)
+	readyCh := make(chan struct{})
+	ds.Start(readyCh)
+	return ds.(*upstreamStreamDataSource), updates, readyCh, func() {
+		_ = ds.Close()
+		server.Close()
+	}
+}
+
+func TestMakeUpstreamStreamOptions(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		assert.Equal(t, upstreamStreamOptions{
+			initialRetryDelay:  config.DefaultStreamInitialRetryDelay,
+			maxRetryDelay:      config.DefaultStreamMaxRetryDelay,
+			jitter:             config.DefaultStreamRetryJitter,
+			retryResetInterval: config.DefaultStreamRetryResetInterval,
+		}, makeUpstreamStreamOptions(config.MainConfig{}, config.EnvConfig{}))
+	})
+
+	t.Run("environment overrides main configuration", func(t *testing.T) {
+		mainConfig := config.MainConfig{
+			StreamInitialRetryDelay:  ct.NewOptDuration(time.Second * 2),
+			StreamMaxRetryDelay:      ct.NewOptDuration(time.Minute * 5),
+			StreamRetryJitter:        ct.NewOptFloat64(0.8),
+			StreamRetryResetInterval: ct.NewOptDuration(time.Minute * 10),
+		}
+		envConfig := config.EnvConfig{
+			StreamMaxRetryDelay: ct.NewOptDuration(time.Minute),
+			StreamRetryJitter:   ct.NewOptFloat64(0),
+		}
+		assert.Equal(t, upstreamStreamOptions{
+			initialRetryDelay:  time.Second * 2,
+			maxRetryDelay:      time.Minute,
+			jitter:             0,
+			retryResetInterval: time.Minute * 10,
+		}, makeUpstreamStreamOptions(mainConfig, envConfig))
+	})
+}
+
+func TestUpstreamStreamDataSourceReceivesData(t *testing.T) {
+	handler, stream := httphelpers.SSEHandler(&httphelpers.SSEEvent{
+		Event: "put",
+		Data:  `{"path":"/","data":{"flags":{"flag1":{"key":"flag1","version":1}},"segments":{}}}`,
+	})
+	defer stream.Close()
+	requestsHandler, requestsCh := httphelpers.RecordingHandler(handler)
+	ds, updates, readyCh, closer := startUpstreamStreamDataSource(t, requestsHandler,
+		makeUpstreamStreamOptions(config.MainConfig{}, config.EnvConfig{}), nil)
+	defer closer()
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "timed out waiting for data source to be ready")
+	}
+	assert.True(t, ds.IsInitialized())
+	req := <-requestsCh
+	assert.Equal(t, "/all", req.Request.URL.Path)
+	assert.Equal(t, "sdk-key", req.Request.Header.Get("Authorization"))
+
+	stream.Send(httphelpers.SSEEvent{Event: "patch", Data: `{"path":"/flags/flag2","data":{"key":"flag2","version":1}}`})
+	stream.Send(httphelpers.SSEEvent{Event: "delete", Data: `{"path":"/segments/segment1","version":2}`})
+	require.Eventually(t, func() bool { return len(updates.getUpserts()) == 2 }, time.Second*5, time.Millisecond*10)
+	assert.Equal(t, []string{"features/flag2", "segments/segment1"}, updates.getUpserts())
+	assert.Contains(t, updates.getStatuses(), interfaces.DataSourceStateValid)
+}
+
+func TestUpstreamStreamDataSourceRetriesWithConfiguredDelay(t *testing.T) {
+	handler, stream := httphelpers.SSEHandler(&httphelpers.SSEEvent{Event: "put", Data: `{"data":{}}`})
+	defer stream.Close()
+	requestsHandler, requestsCh := httphelpers.RecordingHandler(
+		httphelpers.SequentialHandler(httphelpers.HandlerWithStatus(503), httphelpers.HandlerWithStatus(503), handler))
+	var reconnects int
+	var lock sync.Mutex
+	options := upstreamStreamOptions{
+		initialRetryDelay:  time.Millisecond * 10,
+		maxRetryDelay:      time.Millisecond * 20,
+		retryResetInterval: time.Minute,
+	}
+	ds, updates, readyCh, closer := startUpstreamStreamDataSource(t, requestsHandler, options, func() {
+		lock.Lock()
+		reconnects++
+		lock.Unlock()
+	})
+	defer closer()
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "timed out waiting for data source to be ready")
+	}
+	assert.True(t, ds.IsInitialized())
+	assert.Len(t, requestsCh, 3)
+	lock.Lock()
+	assert.Equal(t, 2, reconnects)
+	lock.Unlock()
+	assert.Contains(t, updates.getStatuses(), interfaces.DataSourceStateInterrupted)
+}
+
+func TestUpstreamStreamDataSourceGivesUpOnUnrecoverableError(t *testing.T) {
+	var reconnects int
+	ds, updates, readyCh, closer := startUpstreamStreamDataSource(t, httphelpers.HandlerWithStatus(401),
+		makeUpstreamStreamOptions(config.MainConfig{}, config.EnvConfig{}), func() { reconnects++ })
+	defer closer()
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "timed out waiting for data source to be ready")
+	}
+	assert.False(t, ds.IsInitialized())
+	assert.Equal(t, []interfaces.DataSourceState{interfaces.DataSourceStateOff}, updates.getStatuses())
+	assert.Equal(t, 0, reconnects)
+}
@@ -0,0 +1,4 @@
+// Package replication copies the flag and big segment data that Relay writes to an environment's data
+// store into secondary databases, such as databases in other regions that are read by Relay instances in
+// read replica mode.
+package replication
@@ -0,0 +1,314 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	ld "gopkg.in/launchdarkly/go-server-sdk.v5"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+// errBigSegmentCursorMismatch means that a big segment patch could not be applied to a secondary
+// database, because that database did not have the same big segment data as the primary one.
+var errBigSegmentCursorMismatch = errors.New("big segment data does not match the primary data store")
+
+// Replicator copies an environment's writes to each of the secondary databases in ReplicationConfig.
+//
+// Each database has its own queue of writes and its own goroutine, so a database that is slow or
+// unavailable does not hold up the others, or Relay itself. Flag data is easy to recover: if a write
+// fails, or the queue is full, the Replicator copies all of the flag data from the primary store on the
+// next retry. Big segment data can only be updated incrementally, so a big segment write that fails is
+// retried until it succeeds, and if a secondary database turns out not to have the same big segment data
+// as the primary one, the Replicator stops replicating big segments to that database.
+type Replicator struct {
+	targets        []*target
+	primary        interfaces.DataStore
+	retryInterval  time.Duration
+	metricsContext func() context.Context
+	loggers        ldlog.Loggers
+	closeCh        chan struct{}
+	closeOnce      sync.Once
+	workers        sync.WaitGroup
+	lock           sync.RWMutex
+}
+
+// target is one of the secondary databases. Except for needsResync, its state is only used by the
+// target's goroutine.
+type target struct {
+	name                 string
+	store                interfaces.DataStore
+	bigSegmentStore      bigsegments.BigSegmentStore
+	queue                chan write
+	needsResync          bool
+	pending              *write
+	failing              bool
+	bigSegmentsOutOfSync bool
+	lock                 sync.Mutex
+}
+
+// write is a change that was made to the primary store, and that will be made to a target.
+type write struct {
+	time        time.Time
+	bigSegments bool
+	apply       func(t *target) error
+}
+
+// NewReplicator creates a Replicator for an environment, with a target for each database named in
+// allConfig.Replication; it returns nil if there are none. The databases use the same prefix or table
+// name as the environment's own data store.
+//
+// Big segments are only replicated if their data is kept in the database, rather than in memory or in
+// S3; bigSegmentStoreFactory is used to create the big segment store for each database.
+func NewReplicator(
+	envConfig config.EnvConfig,
+	allConfig config.Config,
+	bigSegmentStoreFactory bigsegments.BigSegmentStoreFactory,
+	metricsContext func() context.Context,
+	loggers ldlog.Loggers,
+) (*Replicator, error) {
+	names := allConfig.Replication.Database.Values()
+	if len(names) == 0 {
+		return nil, nil
+	}
+	sdkContext := sdks.NewSimpleClientContext(string(envConfig.SDKKey), ld.Config{
+		Logging: ldcomponents.Logging().Loggers(loggers),
+	})
+	var targets []*target
+	closeTargets := func() {
+		for _, t := range targets {
+			t.close()
+		}
+	}
+	for _, name := range names {
+		targetEnvConfig := envConfig
+		targetEnvConfig.Database = name
+		targetConfig, err := config.EnvironmentDatabaseConfig(allConfig, targetEnvConfig)
+		if err != nil {
+			closeTargets()
+			return nil, err
+		}
+		factory, _, err := sdks.ConfigureDataStore(targetConfig, targetEnvConfig, loggers)
+		if err != nil {
+			closeTargets()
+			return nil, err
+		}
+		t := &target{name: name}
+		t.store, err = factory.CreateDataStore(sdkContext, noOpDataStoreUpdates{})
+		if err != nil {
+			closeTargets()
+			return nil, err
+		}
+		targets = append(targets, t)
+		if allConfig.BigSegments.Type == "" {
+			t.bigSegmentStore, err = bigSegmentStoreFactory(targetEnvConfig, targetConfig, loggers)
+			if err != nil {
+				closeTargets()
+				return nil, err
+			}
+		}
+	}
+	return newReplicator(
+		targets,
+		allConfig.Replication.QueueSize.GetOrElse(config.DefaultReplicationQueueSize),
+		allConfig.Replication.RetryInterval.GetOrElse(config.DefaultReplicationRetryInterval),
+		metricsContext,
+		loggers,
+	), nil
+}
+
+func newReplicator(
+	targets []*target,
+	queueSize int,
+	retryInterval time.Duration,
+	metricsContext func() context.Context,
+	loggers ldlog.Loggers,
+) *Replicator {
+	r := &Replicator{
+		targets:        targets,
+		retryInterval:  retryInterval,
+		metricsContext: metricsContext,
+		loggers:        loggers,
+		closeCh:        make(chan struct{}),
+	}
+	for _, t := range targets {
+		t.queue = make(chan write, queueSize)
+		r.workers.Add(1)
+		go r.run(t)
+	}
+	return r
+}
+
+// WrapDataStoreFactory returns a DataStoreFactory whose stores replicate every successful write.
+func (r *Replicator) WrapDataStoreFactory(factory interfaces.DataStoreFactory) interfaces.DataStoreFactory {
+	if r == nil {
+		return factory
+	}
+	return replicatingDataStoreFactory{wrapped: factory, replicator: r}
+}
+
+// WrapBigSegmentStore returns a BigSegmentStore that replicates every successful write, if any of the
+// secondary databases have a big segment store; otherwise it returns the same store.
+func (r *Replicator) WrapBigSegmentStore(store bigsegments.BigSegmentStore) bigsegments.BigSegmentStore {
+	if r == nil {
+		return store
+	}
+	for _, t := range r.targets {
+		if t.bigSegmentStore != nil {
+			return replicatingBigSegmentStore{BigSegmentStore: store, replicator: r}
+		}
+	}
+	return store
+}
+
+// Close stops replication and closes the secondary databases. Writes that are still queued are
+// discarded. It is safe to call Close more than once.
+func (r *Replicator) Close() {
+	if r == nil {
+		return
+	}
+	r.closeOnce.Do(func() {
+		close(r.closeCh)
+		r.workers.Wait()
+		for _, t := range r.targets {
+			t.close()
+		}
+	})
+}
+
+func (r *Replicator) setPrimary(store interfaces.DataStore) {
+	r.lock.Lock()
+	r.primary = store
+	r.lock.Unlock()
+}
+
+func (r *Replicator) enqueue(w write) {
+	for _, t := range r.targets {
+		if w.bigSegments && t.bigSegmentStore == nil {
+			continue
+		}
+		select {
+		case t.queue <- w:
+		default:
+			t.lock.Lock()
+			if !t.needsResync {
+				r.loggers.Warnf("Replication queue for database %q is full; flag data will be copied in full when it catches up",
+					t.name)
+			}
+			t.needsResync = true
+			t.lock.Unlock()
+		}
+	}
+}
+
+func (r *Replicator) run(t *target) {
+	defer r.workers.Done()
+	ticker := time.NewTicker(r.retryInterval)
+	defer ticker.Stop()
+	for {
+		// A big segment write that failed must be retried before any later ones are applied.
+		queue := t.queue
+		if t.pending != nil {
+			queue = nil
+		}
+		select {
+		case <-r.closeCh:
+			return
+		case w := <-queue:
+			r.apply(t, w)
+		case <-ticker.C:
+			r.retry(t)
+		}
+	}
+}
+
+func (r *Replicator) retry(t *target) {
+	if t.pending != nil {
+		w := *t.pending
+		t.pending = nil
+		r.apply(t, w)
+	}
+	t.lock.Lock()
+	needsResync := t.needsResync
+	t.needsResync = false
+	t.lock.Unlock()
+	if !needsResync {
+		return
+	}
+	r.lock.RLock()
+	primary := r.primary
+	r.lock.RUnlock()
+	if primary == nil || !primary.IsInitialized() {
+		return // there will be an Init when the data arrives
+	}
+	var allData []ldstoretypes.Collection
+	for _, kind := range ldstoreimpl.AllKinds() {
+		items, err := primary.GetAll(kind)
+		if err != nil {
+			r.loggers.Warnf("Unable to read flag data to replicate to database %q: %s", t.name, err)
+			t.lock.Lock()
+			t.needsResync = true
+			t.lock.Unlock()
+			return
+		}
+		allData = append(allData, ldstoretypes.Collection{Kind: kind, Items: items})
+	}
+	r.apply(t, makeInitWrite(allData))
+}
+
+func (r *Replicator) apply(t *target, w write) {
+	if w.bigSegments && t.bigSegmentsOutOfSync {
+		return
+	}
+	err := w.apply(t)
+	metrics.RecordReplication(r.metricsContext(), t.name, time.Since(w.time), err)
+	switch {
+	case err == errBigSegmentCursorMismatch:
+		r.loggers.Errorf("Stopped replicating big segments to database %q: %s", t.name, err)
+		t.bigSegmentsOutOfSync = true
+	case err != nil:
+		if !t.failing {
+			r.loggers.Warnf("Unable to replicate to database %q (will retry every %s): %s", t.name, r.retryInterval, err)
+			t.failing = true
+		}
+		if w.bigSegments {
+			t.pending = &w
+		} else {
+			t.lock.Lock()
+			t.needsResync = true
+			t.lock.Unlock()
+		}
+	case t.failing:
+		r.loggers.Infof("Replication to database %q has resumed", t.name)
+		t.failing = false
+	}
+}
+
+func (t *target) close() {
+	_ = t.store.Close()
+	if t.bigSegmentStore != nil {
+		_ = t.bigSegmentStore.Close()
+	}
+}
+
+func makeInitWrite(allData []ldstoretypes.Collection) write {
+	return write{time: time.Now(), apply: func(t *target) error {
+		return t.store.Init(allData)
+	}}
+}
+
+// noOpDataStoreUpdates is required by the SDK's persistent data store wrapper. Relay does not report the
+// status of the secondary databases, other than by logging errors.
+type noOpDataStoreUpdates struct{}
+
+func (noOpDataStoreUpdates) UpdateStatus(interfaces.DataStoreStatus) {}
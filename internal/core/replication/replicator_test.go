@@ -0,0 +1,304 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRetryInterval = time.Millisecond * 10
+
+// failingDataStore returns an error from every write while err is set.
+type failingDataStore struct {
+	interfaces.DataStore
+	err  error
+	lock sync.Mutex
+}
+
+func (s *failingDataStore) setError(err error) {
+	s.lock.Lock()
+	s.err = err
+	s.lock.Unlock()
+}
+
+func (s *failingDataStore) getError() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+func (s *failingDataStore) Init(allData []ldstoretypes.Collection) error {
+	if err := s.getError(); err != nil {
+		return err
+	}
+	return s.DataStore.Init(allData)
+}
+
+func (s *failingDataStore) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.ItemDescriptor,
+) (bool, error) {
+	if err := s.getError(); err != nil {
+		return false, err
+	}
+	return s.DataStore.Upsert(kind, key, item)
+}
+
+// fakeBigSegmentStore keeps only the cursor and the IDs of the segments it was given patches for.
+type fakeBigSegmentStore struct {
+	cursor         string
+	segmentIDs     []string
+	synchronizedOn ldtime.UnixMillisecondTime
+	err            error
+	lock           sync.Mutex
+}
+
+func (s *fakeBigSegmentStore) ApplyPatch(patch bigsegments.BigSegmentPatch) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	if patch.PreviousVersion != s.cursor {
+		return false, nil
+	}
+	s.cursor = patch.Version
+	s.segmentIDs = append(s.segmentIDs, patch.SegmentID)
+	return true, nil
+}
+
+func (s *fakeBigSegmentStore) GetCursor() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.cursor, nil
+}
+
+func (s *fakeBigSegmentStore) SetSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.synchronizedOn = synchronizedOn
+	return nil
+}
+
+func (s *fakeBigSegmentStore) GetSynchronizedOn() (ldtime.UnixMillisecondTime, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.synchronizedOn, nil
+}
+
+func (s *fakeBigSegmentStore) Close() error { return nil }
+
+func (s *fakeBigSegmentStore) setError(err error) {
+	s.lock.Lock()
+	s.err = err
+	s.lock.Unlock()
+}
+
+func (s *fakeBigSegmentStore) getSegmentIDs() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.segmentIDs...)
+}
+
+func makePatch(segmentID, previousVersion, version string) bigsegments.BigSegmentPatch {
+	return bigsegments.BigSegmentPatch{SegmentID: segmentID, PreviousVersion: previousVersion, Version: version}
+}
+
+func makeTestReplicator(loggers ldlog.Loggers, targets ...*target) *Replicator {
+	return newReplicator(targets, 100, testRetryInterval, context.Background, loggers)
+}
+
+func getFlagVersion(t *testing.T, store interfaces.DataStore, key string) int {
+	item, err := store.Get(ldstoreimpl.Features(), key)
+	require.NoError(t, err)
+	return item.Version
+}
+
+func TestNewReplicatorReturnsNilIfNoDatabases(t *testing.T) {
+	r, err := NewReplicator(config.EnvConfig{}, config.Config{}, bigsegments.DefaultBigSegmentStoreFactory,
+		context.Background, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	assert.Nil(t, r)
+
+	factory := st.ExistingDataStoreFactory{Instance: st.NewInMemoryStore()}
+	assert.Equal(t, factory, r.WrapDataStoreFactory(factory))
+	bigSegmentStore := &fakeBigSegmentStore{}
+	assert.Equal(t, bigSegmentStore, r.WrapBigSegmentStore(bigSegmentStore))
+	r.Close()
+}
+
+func TestReplicatesFlagWrites(t *testing.T) {
+	target1, target2 := &target{name: "a", store: st.NewInMemoryStore()}, &target{name: "b", store: st.NewInMemoryStore()}
+	r := makeTestReplicator(ldlog.NewDisabledLoggers(), target1, target2)
+	defer r.Close()
+	primary, err := r.WrapDataStoreFactory(st.ExistingDataStoreFactory{Instance: st.NewInMemoryStore()}).
+		CreateDataStore(st.SDKContextImpl{}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, primary.Init(st.AllData))
+	flag := st.Flag1ServerSide.Flag
+	flag.Version++
+	updated, err := st.UpsertFlag(primary, flag)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	for _, tt := range []*target{target1, target2} {
+		require.Eventually(t, func() bool {
+			return tt.store.IsInitialized() && getFlagVersion(t, tt.store, flag.Key) == flag.Version
+		}, time.Second, time.Millisecond)
+		segments, err := tt.store.GetAll(ldstoreimpl.Segments())
+		require.NoError(t, err)
+		assert.Len(t, segments, 1)
+	}
+}
+
+func TestFailedFlagWriteIsRecoveredByCopyingAllData(t *testing.T) {
+	store := &failingDataStore{DataStore: st.NewInMemoryStore()}
+	mockLog := ldlogtest.NewMockLog()
+	r := makeTestReplicator(mockLog.Loggers, &target{name: "a", store: store})
+	defer r.Close()
+	primary, err := r.WrapDataStoreFactory(st.ExistingDataStoreFactory{Instance: st.NewInMemoryStore()}).
+		CreateDataStore(st.SDKContextImpl{}, nil)
+	require.NoError(t, err)
+
+	store.setError(errors.New("sorry"))
+	require.NoError(t, primary.Init(st.AllData))
+	flag := st.Flag1ServerSide.Flag
+	flag.Version++
+	_, err = st.UpsertFlag(primary, flag)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(mockLog.GetOutput(ldlog.Warn)) > 0
+	}, time.Second, time.Millisecond)
+	assert.False(t, store.IsInitialized())
+
+	store.setError(nil)
+	require.Eventually(t, func() bool {
+		return store.IsInitialized() && getFlagVersion(t, store, flag.Key) == flag.Version
+	}, time.Second, time.Millisecond)
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, `Unable to replicate to database "a"`)
+	require.Eventually(t, func() bool {
+		return mockLog.HasMessageMatch(ldlog.Info, `Replication to database "a" has resumed`)
+	}, time.Second, time.Millisecond)
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 1)
+}
+
+func TestFullQueueCausesAllDataToBeCopied(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	tt := &target{name: "a", store: st.NewInMemoryStore(), queue: make(chan write, 1)}
+	r := &Replicator{targets: []*target{tt}, loggers: mockLog.Loggers} // no workers, so nothing is dequeued
+
+	r.enqueue(makeInitWrite(st.AllData))
+	assert.False(t, tt.needsResync)
+	r.enqueue(makeInitWrite(st.AllData))
+	r.enqueue(makeInitWrite(st.AllData))
+	assert.True(t, tt.needsResync)
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 1)
+}
+
+func TestReplicatesBigSegmentWrites(t *testing.T) {
+	targetStore := &fakeBigSegmentStore{}
+	r := makeTestReplicator(ldlog.NewDisabledLoggers(),
+		&target{name: "a", store: st.NewInMemoryStore(), bigSegmentStore: targetStore},
+		&target{name: "b", store: st.NewInMemoryStore()}, // big segments are not kept in this one
+	)
+	defer r.Close()
+	primary := r.WrapBigSegmentStore(&fakeBigSegmentStore{})
+
+	applied, err := primary.ApplyPatch(makePatch("s1", "", "1"))
+	require.NoError(t, err)
+	require.True(t, applied)
+	applied, err = primary.ApplyPatch(makePatch("s2", "0", "2")) // not applied to the primary store
+	require.NoError(t, err)
+	require.False(t, applied)
+	_, err = primary.ApplyPatch(makePatch("s3", "1", "2"))
+	require.NoError(t, err)
+	require.NoError(t, primary.SetSynchronizedOn(ldtime.UnixMillisecondTime(1000)))
+
+	require.Eventually(t, func() bool {
+		synchronizedOn, _ := targetStore.GetSynchronizedOn()
+		return synchronizedOn == ldtime.UnixMillisecondTime(1000)
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"s1", "s3"}, targetStore.getSegmentIDs())
+}
+
+func TestWrapBigSegmentStoreReturnsSameStoreIfNoTargetHasBigSegments(t *testing.T) {
+	r := makeTestReplicator(ldlog.NewDisabledLoggers(), &target{name: "a", store: st.NewInMemoryStore()})
+	defer r.Close()
+	store := &fakeBigSegmentStore{}
+	assert.Equal(t, store, r.WrapBigSegmentStore(store))
+}
+
+func TestFailedBigSegmentWriteIsRetriedBeforeLaterOnes(t *testing.T) {
+	targetStore := &fakeBigSegmentStore{}
+	r := makeTestReplicator(ldlog.NewDisabledLoggers(),
+		&target{name: "a", store: st.NewInMemoryStore(), bigSegmentStore: targetStore})
+	defer r.Close()
+	primary := r.WrapBigSegmentStore(&fakeBigSegmentStore{})
+
+	targetStore.setError(errors.New("sorry"))
+	_, err := primary.ApplyPatch(makePatch("s1", "", "1"))
+	require.NoError(t, err)
+	_, err = primary.ApplyPatch(makePatch("s2", "1", "2"))
+	require.NoError(t, err)
+	time.Sleep(testRetryInterval * 3)
+	assert.Len(t, targetStore.getSegmentIDs(), 0)
+
+	targetStore.setError(nil)
+	require.Eventually(t, func() bool {
+		return len(targetStore.getSegmentIDs()) == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"s1", "s2"}, targetStore.getSegmentIDs())
+}
+
+func TestBigSegmentCursorMismatchStopsBigSegmentReplication(t *testing.T) {
+	targetStore := &fakeBigSegmentStore{cursor: "other"}
+	mockLog := ldlogtest.NewMockLog()
+	tt := &target{name: "a", store: st.NewInMemoryStore(), bigSegmentStore: targetStore}
+	r := makeTestReplicator(mockLog.Loggers, tt)
+	defer r.Close()
+	primary := r.WrapBigSegmentStore(&fakeBigSegmentStore{})
+
+	_, err := primary.ApplyPatch(makePatch("s1", "", "1"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return mockLog.HasMessageMatch(ldlog.Error, `Stopped replicating big segments to database "a"`)
+	}, time.Second, time.Millisecond)
+
+	// flag data is still replicated
+	flagStore, err := r.WrapDataStoreFactory(st.ExistingDataStoreFactory{Instance: st.NewInMemoryStore()}).
+		CreateDataStore(st.SDKContextImpl{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, flagStore.Init(st.AllData))
+	require.Eventually(t, tt.store.IsInitialized, time.Second, time.Millisecond)
+
+	targetStore.lock.Lock()
+	targetStore.cursor = "1"
+	targetStore.lock.Unlock()
+	_, err = primary.ApplyPatch(makePatch("s2", "1", "2"))
+	require.NoError(t, err)
+	time.Sleep(testRetryInterval * 3)
+	assert.Len(t, targetStore.getSegmentIDs(), 0)
+	assert.Len(t, mockLog.GetOutput(ldlog.Error), 1)
+}
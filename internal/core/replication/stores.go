@@ -0,0 +1,88 @@
+package replication
+
+import (
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+)
+
+type replicatingDataStoreFactory struct {
+	wrapped    interfaces.DataStoreFactory
+	replicator *Replicator
+}
+
+// replicatingDataStore queues every write that succeeds for the secondary databases. Reads only use the
+// primary store.
+type replicatingDataStore struct {
+	interfaces.DataStore
+	replicator *Replicator
+}
+
+// replicatingBigSegmentStore queues every big segment write that succeeds for the secondary databases.
+type replicatingBigSegmentStore struct {
+	bigsegments.BigSegmentStore
+	replicator *Replicator
+}
+
+func (f replicatingDataStoreFactory) CreateDataStore(
+	context interfaces.ClientContext,
+	dataStoreUpdates interfaces.DataStoreUpdates,
+) (interfaces.DataStore, error) {
+	store, err := f.wrapped.CreateDataStore(context, dataStoreUpdates)
+	if err != nil {
+		return nil, err
+	}
+	f.replicator.setPrimary(store)
+	return replicatingDataStore{DataStore: store, replicator: f.replicator}, nil
+}
+
+func (s replicatingDataStore) Init(allData []ldstoretypes.Collection) error {
+	if err := s.DataStore.Init(allData); err != nil {
+		return err
+	}
+	s.replicator.enqueue(makeInitWrite(allData))
+	return nil
+}
+
+func (s replicatingDataStore) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.ItemDescriptor,
+) (bool, error) {
+	updated, err := s.DataStore.Upsert(kind, key, item)
+	if err == nil && updated {
+		s.replicator.enqueue(write{time: time.Now(), apply: func(t *target) error {
+			_, err := t.store.Upsert(kind, key, item)
+			return err
+		}})
+	}
+	return updated, err
+}
+
+func (s replicatingBigSegmentStore) ApplyPatch(patch bigsegments.BigSegmentPatch) (bool, error) {
+	applied, err := s.BigSegmentStore.ApplyPatch(patch)
+	if err == nil && applied {
+		s.replicator.enqueue(write{time: time.Now(), bigSegments: true, apply: func(t *target) error {
+			applied, err := t.bigSegmentStore.ApplyPatch(patch)
+			if err == nil && !applied {
+				return errBigSegmentCursorMismatch
+			}
+			return err
+		}})
+	}
+	return applied, err
+}
+
+func (s replicatingBigSegmentStore) SetSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
+	if err := s.BigSegmentStore.SetSynchronizedOn(synchronizedOn); err != nil {
+		return err
+	}
+	s.replicator.enqueue(write{time: time.Now(), bigSegments: true, apply: func(t *target) error {
+		return t.bigSegmentStore.SetSynchronizedOn(synchronizedOn)
+	}})
+	return nil
+}
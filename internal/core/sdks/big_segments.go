@@ -1,39 +1,166 @@
 package sdks
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/mongodb"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/postgres"
+	"github.com/launchdarkly/ld-relay/v6/internal/util"
 
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
 	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents"
 )
 
+// BigSegmentStoreProvider is a function that creates the Go SDK big segment store for a custom big
+// segment backend, based on the Relay configuration. It is registered with RegisterBigSegmentStoreProvider.
+type BigSegmentStoreProvider func(
+	allConfig config.Config,
+	envConfig config.EnvConfig,
+	loggers ldlog.Loggers,
+) (interfaces.BigSegmentStoreFactory, error)
+
+// BigSegmentBatchStore is an optional interface for a Go SDK big segment store that can query the
+// memberships of many users at once, with fewer round trips than a GetUserMembership query for each.
+// Relay uses this when it evaluates flags for many users in one request.
+type BigSegmentBatchStore interface {
+	// GetUserMemberships queries the membership state for each of the user hash keys, returning the
+	// results in the same order. It returns an error if any of the queries failed.
+	GetUserMemberships(userHashKeys []string) ([]interfaces.BigSegmentMembership, error)
+}
+
+var (
+	registeredBigSegmentProviders     = make(map[string]BigSegmentStoreProvider) //nolint:gochecknoglobals
+	registeredBigSegmentProvidersLock sync.RWMutex                               //nolint:gochecknoglobals
+)
+
+func errUnknownBigSegmentStoreType(storeType string) error {
+	return fmt.Errorf("no big segment store provider has been registered for type %q", storeType)
+}
+
+// RegisterBigSegmentStoreProvider adds a custom big segment store implementation that will be used
+// for any environment when the BigSegments.Type configuration property is equal to storeType. This is
+// the SDK side of a custom big segment backend, which is responsible for reading membership data during
+// evaluations; the same storeType must also be registered with bigsegments.RegisterBigSegmentStoreFactory
+// so that Relay can write the data. Application code does this with relay.RegisterBigSegmentStore.
+//
+// Like eventtransform.Register, it panics if provider is nil or if storeType was already registered.
+func RegisterBigSegmentStoreProvider(storeType string, provider BigSegmentStoreProvider) {
+	registeredBigSegmentProvidersLock.Lock()
+	defer registeredBigSegmentProvidersLock.Unlock()
+	if provider == nil {
+		panic("sdks: RegisterBigSegmentStoreProvider provider is nil")
+	}
+	if _, exists := registeredBigSegmentProviders[storeType]; exists {
+		panic("sdks: RegisterBigSegmentStoreProvider called twice for store type " + storeType)
+	}
+	registeredBigSegmentProviders[storeType] = provider
+}
+
+func getRegisteredBigSegmentStoreProvider(storeType string) BigSegmentStoreProvider {
+	registeredBigSegmentProvidersLock.RLock()
+	defer registeredBigSegmentProvidersLock.RUnlock()
+	return registeredBigSegmentProviders[storeType]
+}
+
 // ConfigureBigSegments provides the appropriate Go SDK big segments configuration based on the Relay
 // configuration, or nil if big segments are not enabled. The big segments stores in Relay's SDK
 // instances are used for client-side evaluations; server-side SDKs will read from the same database
 // via their own big segments stores, which will need to be configured similarly to what's here.
+//
+// If pools is not nil, the big segment store's Redis or DynamoDB connections are tracked in it; this
+// is normally the DatabasePools from the environment's DataStoreEnvironmentInfo.
 func ConfigureBigSegments(
 	allConfig config.Config,
 	envConfig config.EnvConfig,
+	pools *DatabasePools,
 	loggers ldlog.Loggers,
 ) (interfaces.BigSegmentsConfigurationFactory, error) {
 	var storeFactory interfaces.BigSegmentStoreFactory
 
-	if allConfig.Redis.URL.IsDefined() {
-		redisBuilder, redisURL := makeRedisDataStoreBuilder(allConfig, envConfig)
+	if storeType := allConfig.BigSegments.Type; storeType != "" {
+		provider := getRegisteredBigSegmentStoreProvider(storeType)
+		if provider == nil {
+			return nil, errUnknownBigSegmentStoreType(storeType)
+		}
+		factory, err := provider(allConfig, envConfig, loggers)
+		if err != nil {
+			return nil, err
+		}
+		loggers.Infof("Using custom big segment store: %s with prefix: %s", storeType, envConfig.Prefix)
+		storeFactory = factory
+	} else if allConfig.Redis.URL.IsDefined() && IsRedisClusterOrSentinel(allConfig.Redis) {
+		loggers.Infof("Using Redis big segment store: %s with prefix: %s",
+			describeRedisClusterOrSentinel(allConfig.Redis), envConfig.Prefix)
+		storeFactory = redisUniversalStoreFactory{
+			dbConfig:  allConfig.Redis,
+			envConfig: envConfig,
+			pools:     pools,
+			poolKind:  DatabasePoolBigSegments,
+		}
+	} else if allConfig.Redis.URL.IsDefined() {
+		redisBuilder, redisURL, err := makeRedisDataStoreBuilder(allConfig, envConfig, pools, DatabasePoolBigSegments)
+		if err != nil {
+			return nil, err
+		}
 		loggers.Infof("Using Redis big segment store: %s with prefix: %s", redisURL, envConfig.Prefix)
 		storeFactory = redisBuilder
+	} else if allConfig.Consul.Host != "" {
+		consulFactory := makeConsulBigSegmentStoreFactory(allConfig, envConfig)
+		loggers.Infof("Using Consul big segment store: %s with prefix: %s", allConfig.Consul.Host, consulFactory.prefix)
+		storeFactory = consulFactory
 	} else if allConfig.DynamoDB.Enabled {
-		dynamoDBBuilder, tableName, err := makeDynamoDBDataStoreBuilder(allConfig, envConfig)
+		dynamoDBBuilder, tableName, err := makeDynamoDBDataStoreBuilder(allConfig, envConfig, pools, DatabasePoolBigSegments)
 		if err != nil {
 			return nil, err
 		}
 		loggers.Infof("Using DynamoDB big segment store: %s with prefix: %s", tableName, envConfig.Prefix)
-		storeFactory = dynamoDBBuilder
+		endpoint, _, _ := GetDynamoDBBasicProperties(allConfig.DynamoDB, envConfig)
+		sessionOptions, _ := makeDynamoDBSessionOptions(allConfig.DynamoDB, endpoint, pools, DatabasePoolBigSegments)
+		storeFactory = dynamoDBBigSegmentStoreFactory{
+			builder:        dynamoDBBuilder,
+			dbConfig:       allConfig.DynamoDB,
+			sessionOptions: sessionOptions,
+			table:          tableName,
+			prefix:         envConfig.Prefix,
+		}
+	} else if allConfig.Postgres.URL.IsDefined() {
+		redactedURL := util.RedactURL(allConfig.Postgres.URL.String())
+		prefix := postgres.EffectivePrefix(envConfig.Prefix)
+		loggers.Infof("Using Postgres big segment store: %s with prefix: %s", redactedURL, prefix)
+		storeFactory = postgres.BigSegmentStore(allConfig.Postgres, prefix)
+	} else if allConfig.MongoDB.URL.IsDefined() {
+		redactedURL := util.RedactURL(allConfig.MongoDB.URL.String())
+		prefix := mongodb.EffectivePrefix(envConfig.Prefix)
+		loggers.Infof("Using MongoDB big segment store: %s with prefix: %s", redactedURL, prefix)
+		storeFactory = mongodb.BigSegmentStore(allConfig.MongoDB, prefix)
 	}
 
 	if storeFactory != nil {
-		return ldcomponents.BigSegments(storeFactory), nil
+		return MakeBigSegmentsConfiguration(allConfig, envConfig, storeFactory), nil
 	}
 	return nil, nil
 }
+
+// MakeBigSegmentsConfiguration returns the Go SDK big segments configuration for the specified store,
+// with the user cache and staleness properties from the Relay configuration. The per-environment
+// properties, if any, take precedence over the ones in the BigSegments section.
+func MakeBigSegmentsConfiguration(
+	allConfig config.Config,
+	envConfig config.EnvConfig,
+	storeFactory interfaces.BigSegmentStoreFactory,
+) interfaces.BigSegmentsConfigurationFactory {
+	userCacheSize := envConfig.BigSegmentsUserCacheSize.GetOrElse(
+		allConfig.BigSegments.UserCacheSize.GetOrElse(ldcomponents.DefaultBigSegmentsUserCacheSize))
+	userCacheTime := envConfig.BigSegmentsUserCacheTime.GetOrElse(
+		allConfig.BigSegments.UserCacheTime.GetOrElse(ldcomponents.DefaultBigSegmentsUserCacheTime))
+	builder := ldcomponents.BigSegments(storeFactory).
+		UserCacheSize(userCacheSize).
+		UserCacheTime(userCacheTime)
+	if envConfig.BigSegmentsStaleAfter.IsDefined() {
+		builder.StaleAfter(envConfig.BigSegmentsStaleAfter.GetOrElse(0))
+	}
+	return builder
+}
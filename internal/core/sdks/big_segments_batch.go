@@ -0,0 +1,236 @@
+package sdks
+
+import (
+	"errors"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments/membership"
+
+	lddynamodb "github.com/launchdarkly/go-server-sdk-dynamodb"
+	ldredis "github.com/launchdarkly/go-server-sdk-redis-redigo"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// The SDK's Redis and DynamoDB big segment stores only query one user at a time. The types here wrap
+// them to add BigSegmentBatchStore, reading the same data layout that they do.
+
+const (
+	// dynamoDBBatchGetMaxItems is the largest number of items that DynamoDB allows in a BatchGetItem
+	// request.
+	dynamoDBBatchGetMaxItems = 100
+
+	// dynamoDBBatchGetMaxAttempts is how many times we send a BatchGetItem request while DynamoDB
+	// returns some of the keys as unprocessed, which it does if the request exceeds the table's
+	// provisioned throughput.
+	dynamoDBBatchGetMaxAttempts = 5
+
+	dynamoDBBatchGetRetryDelay = 50 * time.Millisecond
+
+	dynamoDBPartitionKey            = "namespace"
+	dynamoDBSortKey                 = "key"
+	dynamoDBBigSegmentsUserDataKey  = "big_segments_user"
+	dynamoDBBigSegmentsIncludedAttr = "included"
+	dynamoDBBigSegmentsExcludedAttr = "excluded"
+)
+
+var errDynamoDBBatchGetUnprocessedKeys = errors.New("DynamoDB did not return all of the requested big segment memberships") //nolint:gochecknoglobals
+
+// redigoBigSegmentStore is the SDK big segment store for a single Redis server, with batch queries.
+type redigoBigSegmentStore struct {
+	store  interfaces.BigSegmentStore
+	pool   ldredis.Pool
+	prefix string
+}
+
+func (s *redigoBigSegmentStore) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	return s.store.GetMetadata()
+}
+
+// GetUserMembership uses the same query as GetUserMemberships, rather than the SDK store's, so that
+// the result is created by membership.FromSegmentRefs.
+func (s *redigoBigSegmentStore) GetUserMembership(userHashKey string) (interfaces.BigSegmentMembership, error) {
+	memberships, err := s.GetUserMemberships([]string{userHashKey})
+	if err != nil {
+		return nil, err
+	}
+	return memberships[0], nil
+}
+
+// GetUserMemberships implements BigSegmentBatchStore. The membership data is stored in sets, which MGET
+// cannot read, so the SMEMBERS queries for all of the users are pipelined on one connection instead.
+func (s *redigoBigSegmentStore) GetUserMemberships(
+	userHashKeys []string,
+) ([]interfaces.BigSegmentMembership, error) {
+	conn := s.pool.Get()
+	defer conn.Close() //nolint:errcheck
+
+	for _, userHashKey := range userHashKeys {
+		if err := conn.Send("SMEMBERS", redisBigSegmentsIncludeKey(s.prefix, userHashKey)); err != nil {
+			return nil, err
+		}
+		if err := conn.Send("SMEMBERS", redisBigSegmentsExcludeKey(s.prefix, userHashKey)); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	ret := make([]interfaces.BigSegmentMembership, 0, len(userHashKeys))
+	for range userHashKeys {
+		includedRefs, err := redigo.Strings(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+		excludedRefs, err := redigo.Strings(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, membership.FromSegmentRefs(includedRefs, excludedRefs))
+	}
+	return ret, nil
+}
+
+func (s *redigoBigSegmentStore) Close() error {
+	return s.store.Close()
+}
+
+// dynamoDBBigSegmentStoreFactory creates the SDK's DynamoDB big segment store, using a DynamoDB client
+// that it creates itself so that it can also use that client for batch queries. If the configuration
+// has a DAX endpoint, this is a DAX client, so membership queries are served from the DAX cache.
+type dynamoDBBigSegmentStoreFactory struct {
+	builder        *lddynamodb.DataStoreBuilder
+	dbConfig       config.DynamoDBConfig
+	sessionOptions session.Options
+	table          string
+	prefix         string
+}
+
+func (f dynamoDBBigSegmentStoreFactory) CreateBigSegmentStore(
+	context interfaces.ClientContext,
+) (interfaces.BigSegmentStore, error) {
+	sess, err := session.NewSessionWithOptions(f.sessionOptions)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewDynamoDBClient(f.dbConfig, sess)
+	if err != nil {
+		return nil, err
+	}
+	b := *f.builder
+	store, err := b.DynamoClient(client).CreateBigSegmentStore(context)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamoDBBigSegmentStore{store: store, client: client, table: f.table, prefix: f.prefix}, nil
+}
+
+// DescribeConfiguration is used internally by the SDK to inspect the configuration.
+func (f dynamoDBBigSegmentStoreFactory) DescribeConfiguration() ldvalue.Value {
+	return f.builder.DescribeConfiguration()
+}
+
+// dynamoDBBigSegmentStore is the SDK big segment store for DynamoDB, with batch queries.
+type dynamoDBBigSegmentStore struct {
+	store  interfaces.BigSegmentStore
+	client dynamodbiface.DynamoDBAPI
+	table  string
+	prefix string
+}
+
+func (s *dynamoDBBigSegmentStore) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	return s.store.GetMetadata()
+}
+
+// GetUserMembership uses the same query as GetUserMemberships, rather than the SDK store's, so that
+// the result is created by membership.FromSegmentRefs.
+func (s *dynamoDBBigSegmentStore) GetUserMembership(userHashKey string) (interfaces.BigSegmentMembership, error) {
+	memberships, err := s.GetUserMemberships([]string{userHashKey})
+	if err != nil {
+		return nil, err
+	}
+	return memberships[0], nil
+}
+
+// GetUserMemberships implements BigSegmentBatchStore, using BatchGetItem for up to 100 users at a time.
+func (s *dynamoDBBigSegmentStore) GetUserMemberships(
+	userHashKeys []string,
+) ([]interfaces.BigSegmentMembership, error) {
+	namespace := dynamoDBBigSegmentsUserDataKey
+	if s.prefix != "" {
+		namespace = s.prefix + ":" + namespace
+	}
+	items := make(map[string]map[string]*dynamodb.AttributeValue, len(userHashKeys))
+	requested := make(map[string]bool, len(userHashKeys))
+	keys := make([]map[string]*dynamodb.AttributeValue, 0, dynamoDBBatchGetMaxItems)
+	for i, userHashKey := range userHashKeys {
+		if !requested[userHashKey] { // BatchGetItem does not allow the same key twice
+			requested[userHashKey] = true
+			keys = append(keys, map[string]*dynamodb.AttributeValue{
+				dynamoDBPartitionKey: {S: aws.String(namespace)},
+				dynamoDBSortKey:      {S: aws.String(userHashKey)},
+			})
+		}
+		if len(keys) == dynamoDBBatchGetMaxItems || (i == len(userHashKeys)-1 && len(keys) > 0) {
+			if err := s.batchGetItems(keys, items); err != nil {
+				return nil, err
+			}
+			keys = keys[:0]
+		}
+	}
+	ret := make([]interfaces.BigSegmentMembership, 0, len(userHashKeys))
+	for _, userHashKey := range userHashKeys {
+		item := items[userHashKey] // a user with no item is in no big segments
+		ret = append(ret, membership.FromSegmentRefs(
+			getDynamoDBStringSet(item[dynamoDBBigSegmentsIncludedAttr]),
+			getDynamoDBStringSet(item[dynamoDBBigSegmentsExcludedAttr]),
+		))
+	}
+	return ret, nil
+}
+
+func (s *dynamoDBBigSegmentStore) batchGetItems(
+	keys []map[string]*dynamodb.AttributeValue,
+	into map[string]map[string]*dynamodb.AttributeValue,
+) error {
+	requestItems := map[string]*dynamodb.KeysAndAttributes{
+		s.table: {Keys: keys, ConsistentRead: aws.Bool(true)},
+	}
+	for attempt := 1; ; attempt++ {
+		out, err := s.client.BatchGetItem(&dynamodb.BatchGetItemInput{RequestItems: requestItems})
+		if err != nil {
+			return err
+		}
+		for _, item := range out.Responses[s.table] {
+			if key := item[dynamoDBSortKey]; key != nil && key.S != nil {
+				into[*key.S] = item
+			}
+		}
+		if len(out.UnprocessedKeys) == 0 {
+			return nil
+		}
+		if attempt == dynamoDBBatchGetMaxAttempts {
+			return errDynamoDBBatchGetUnprocessedKeys
+		}
+		requestItems = out.UnprocessedKeys
+		time.Sleep(dynamoDBBatchGetRetryDelay * time.Duration(attempt))
+	}
+}
+
+func (s *dynamoDBBigSegmentStore) Close() error {
+	return s.store.Close()
+}
+
+func getDynamoDBStringSet(value *dynamodb.AttributeValue) []string {
+	if value == nil {
+		return nil
+	}
+	return aws.StringValueSlice(value.SS)
+}
@@ -0,0 +1,121 @@
+package sdks
+
+import (
+	"strconv"
+	"testing"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDynamoDBBatchGetClient simulates BatchGetItem for a table of big segment user items. If
+// unprocessedOnce is set, the first request for each key returns that key as unprocessed.
+type fakeDynamoDBBatchGetClient struct {
+	dynamodbiface.DynamoDBAPI
+	items           map[string]map[string]*dynamodb.AttributeValue
+	unprocessedOnce bool
+	requestSizes    []int
+	seen            map[string]bool
+}
+
+func (c *fakeDynamoDBBatchGetClient) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	out := &dynamodb.BatchGetItemOutput{Responses: make(map[string][]map[string]*dynamodb.AttributeValue)}
+	for table, ka := range input.RequestItems {
+		c.requestSizes = append(c.requestSizes, len(ka.Keys))
+		var unprocessed []map[string]*dynamodb.AttributeValue
+		for _, key := range ka.Keys {
+			id := *key[dynamoDBPartitionKey].S + "/" + *key[dynamoDBSortKey].S
+			if c.unprocessedOnce && !c.seen[id] {
+				c.seen[id] = true
+				unprocessed = append(unprocessed, key)
+				continue
+			}
+			if item, ok := c.items[id]; ok {
+				out.Responses[table] = append(out.Responses[table], item)
+			}
+		}
+		if len(unprocessed) > 0 {
+			out.UnprocessedKeys = map[string]*dynamodb.KeysAndAttributes{table: {Keys: unprocessed}}
+		}
+	}
+	return out, nil
+}
+
+func makeFakeDynamoDBUserItem(namespace, userHashKey string, included, excluded []string) map[string]*dynamodb.AttributeValue {
+	item := map[string]*dynamodb.AttributeValue{
+		dynamoDBPartitionKey: {S: aws.String(namespace)},
+		dynamoDBSortKey:      {S: aws.String(userHashKey)},
+	}
+	if included != nil {
+		item[dynamoDBBigSegmentsIncludedAttr] = &dynamodb.AttributeValue{SS: aws.StringSlice(included)}
+	}
+	if excluded != nil {
+		item[dynamoDBBigSegmentsExcludedAttr] = &dynamodb.AttributeValue{SS: aws.StringSlice(excluded)}
+	}
+	return item
+}
+
+func assertMembership(t *testing.T, m interfaces.BigSegmentMembership, included, excluded []string) {
+	for _, ref := range included {
+		assert.Equal(t, ldvalue.NewOptionalBool(true), m.CheckMembership(ref), ref)
+	}
+	for _, ref := range excluded {
+		assert.Equal(t, ldvalue.NewOptionalBool(false), m.CheckMembership(ref), ref)
+	}
+}
+
+func TestDynamoDBBigSegmentStoreGetUserMemberships(t *testing.T) {
+	namespace := "abc:big_segments_user"
+	client := &fakeDynamoDBBatchGetClient{items: map[string]map[string]*dynamodb.AttributeValue{
+		namespace + "/u1": makeFakeDynamoDBUserItem(namespace, "u1", []string{"seg1"}, nil),
+		namespace + "/u2": makeFakeDynamoDBUserItem(namespace, "u2", nil, []string{"seg2"}),
+	}}
+	store := &dynamoDBBigSegmentStore{client: client, table: "my-table", prefix: "abc"}
+
+	memberships, err := store.GetUserMemberships([]string{"u1", "u2", "u3", "u1"})
+	require.NoError(t, err)
+	require.Len(t, memberships, 4)
+	assertMembership(t, memberships[0], []string{"seg1"}, nil)
+	assertMembership(t, memberships[1], nil, []string{"seg2"})
+	assert.Equal(t, ldvalue.OptionalBool{}, memberships[2].CheckMembership("seg1"))
+	assertMembership(t, memberships[3], []string{"seg1"}, nil)
+	assert.Equal(t, []int{3}, client.requestSizes) // the duplicate key is only requested once
+}
+
+func TestDynamoDBBigSegmentStoreGetUserMembershipsSplitsLargeBatches(t *testing.T) {
+	client := &fakeDynamoDBBatchGetClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	store := &dynamoDBBigSegmentStore{client: client, table: "my-table"}
+	var userHashKeys []string
+	for i := 0; i < dynamoDBBatchGetMaxItems*2+1; i++ {
+		userHashKeys = append(userHashKeys, strconv.Itoa(i))
+	}
+
+	memberships, err := store.GetUserMemberships(userHashKeys)
+	require.NoError(t, err)
+	assert.Len(t, memberships, len(userHashKeys))
+	assert.Equal(t, []int{dynamoDBBatchGetMaxItems, dynamoDBBatchGetMaxItems, 1}, client.requestSizes)
+}
+
+func TestDynamoDBBigSegmentStoreGetUserMembershipsRetriesUnprocessedKeys(t *testing.T) {
+	namespace := "big_segments_user"
+	client := &fakeDynamoDBBatchGetClient{
+		items: map[string]map[string]*dynamodb.AttributeValue{
+			namespace + "/u1": makeFakeDynamoDBUserItem(namespace, "u1", []string{"seg1"}, nil),
+		},
+		unprocessedOnce: true,
+		seen:            make(map[string]bool),
+	}
+	store := &dynamoDBBigSegmentStore{client: client, table: "my-table"}
+
+	memberships, err := store.GetUserMemberships([]string{"u1", "u2"})
+	require.NoError(t, err)
+	require.Len(t, memberships, 2)
+	assertMembership(t, memberships[0], []string{"seg1"}, nil)
+	assert.Equal(t, []int{2, 2}, client.requestSizes)
+}
@@ -0,0 +1,144 @@
+package sdks
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments/membership"
+
+	ldconsul "github.com/launchdarkly/go-server-sdk-consul"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+
+	consul "github.com/hashicorp/consul/api"
+)
+
+// The Consul integration in the Go SDK does not support big segments, so Relay provides its own
+// implementation of the SDK-side store. The data layout is analogous to the one used for Redis: the
+// included and excluded segment references for each user are stored as a JSON array of strings
+// under a key derived from the user hash.
+
+// ConsulBigSegmentsSyncTimeKey returns the Consul key for the big segments synchronization time.
+func ConsulBigSegmentsSyncTimeKey(prefix string) string {
+	return prefix + "/big_segments_synchronized_on"
+}
+
+// ConsulBigSegmentsIncludeKey returns the Consul key for the segments that a user is included in.
+func ConsulBigSegmentsIncludeKey(prefix, userHashKey string) string {
+	return prefix + "/big_segment_include/" + userHashKey
+}
+
+// ConsulBigSegmentsExcludeKey returns the Consul key for the segments that a user is excluded from.
+func ConsulBigSegmentsExcludeKey(prefix, userHashKey string) string {
+	return prefix + "/big_segment_exclude/" + userHashKey
+}
+
+// GetConsulBasicProperties transforms the configuration properties to the standard parameters
+// used for Consul. This function is exported to ensure consistency between the SDK
+// configuration and the internal big segment store for Consul.
+func GetConsulBasicProperties(
+	dbConfig config.ConsulConfig,
+	envConfig config.EnvConfig,
+) (consulConfig consul.Config, prefix string) {
+	if dbConfig.Token != "" {
+		consulConfig.Token = dbConfig.Token
+	} else if dbConfig.TokenFile != "" {
+		consulConfig.TokenFile = dbConfig.TokenFile
+	}
+	consulConfig.Address = dbConfig.Host
+
+	prefix = envConfig.Prefix
+	if prefix == "" {
+		prefix = ldconsul.DefaultPrefix
+	}
+
+	return
+}
+
+// ReadConsulBigSegmentRefs reads a JSON array of segment references from a Consul key, returning
+// nil if the key does not exist.
+func ReadConsulBigSegmentRefs(kv *consul.KV, key string) ([]string, uint64, error) {
+	pair, _, err := kv.Get(key, nil)
+	if err != nil || pair == nil {
+		return nil, 0, err
+	}
+	var refs []string
+	if len(pair.Value) > 0 {
+		if err := json.Unmarshal(pair.Value, &refs); err != nil {
+			return nil, 0, err
+		}
+	}
+	return refs, pair.ModifyIndex, nil
+}
+
+type consulBigSegmentStoreFactory struct {
+	consulConfig consul.Config
+	prefix       string
+}
+
+func makeConsulBigSegmentStoreFactory(
+	allConfig config.Config,
+	envConfig config.EnvConfig,
+) consulBigSegmentStoreFactory {
+	consulConfig, prefix := GetConsulBasicProperties(allConfig.Consul, envConfig)
+	return consulBigSegmentStoreFactory{consulConfig: consulConfig, prefix: prefix}
+}
+
+func (f consulBigSegmentStoreFactory) CreateBigSegmentStore(
+	context interfaces.ClientContext,
+) (interfaces.BigSegmentStore, error) {
+	consulConfig := f.consulConfig
+	client, err := consul.NewClient(&consulConfig)
+	if err != nil {
+		return nil, err
+	}
+	loggers := context.GetLogging().GetLoggers()
+	loggers.SetPrefix("ConsulBigSegmentStore:")
+	return &consulBigSegmentStoreImpl{
+		client:  client,
+		prefix:  f.prefix,
+		loggers: loggers,
+	}, nil
+}
+
+// consulBigSegmentStoreImpl is the SDK-side implementation of the BigSegmentStore interface for Consul.
+type consulBigSegmentStoreImpl struct {
+	client  *consul.Client
+	prefix  string
+	loggers ldlog.Loggers
+}
+
+func (store *consulBigSegmentStoreImpl) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	pair, _, err := store.client.KV().Get(ConsulBigSegmentsSyncTimeKey(store.prefix), nil)
+	if err != nil || pair == nil {
+		return interfaces.BigSegmentStoreMetadata{}, err
+	}
+	value, err := strconv.ParseUint(string(pair.Value), 10, 64)
+	if err != nil {
+		return interfaces.BigSegmentStoreMetadata{}, err
+	}
+	return interfaces.BigSegmentStoreMetadata{
+		LastUpToDate: ldtime.UnixMillisecondTime(value),
+	}, nil
+}
+
+func (store *consulBigSegmentStoreImpl) GetUserMembership(
+	userHashKey string,
+) (interfaces.BigSegmentMembership, error) {
+	kv := store.client.KV()
+	includedRefs, _, err := ReadConsulBigSegmentRefs(kv, ConsulBigSegmentsIncludeKey(store.prefix, userHashKey))
+	if err != nil {
+		return nil, err
+	}
+	excludedRefs, _, err := ReadConsulBigSegmentRefs(kv, ConsulBigSegmentsExcludeKey(store.prefix, userHashKey))
+	if err != nil {
+		return nil, err
+	}
+	return membership.FromSegmentRefs(includedRefs, excludedRefs), nil
+}
+
+func (store *consulBigSegmentStoreImpl) Close() error {
+	return nil
+}
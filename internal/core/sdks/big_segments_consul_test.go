@@ -0,0 +1,86 @@
+package sdks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/launchdarkly/go-test-helpers/v2/httphelpers"
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/testhelpers"
+
+	consul "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsulKVHandler simulates the subset of the Consul KV API that is used by the SDK-side store.
+func fakeConsulKVHandler(values map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		value, ok := values[key]
+		if r.Method != "GET" || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, _ := json.Marshal([]consul.KVPair{{Key: key, Value: []byte(value), ModifyIndex: 1}})
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
+
+func withConsulBigSegmentStore(t *testing.T, values map[string]string, action func(*consulBigSegmentStoreImpl)) {
+	httphelpers.WithServer(fakeConsulKVHandler(values), func(server *httptest.Server) {
+		factory := makeConsulBigSegmentStoreFactory(
+			config.Config{Consul: config.ConsulConfig{Host: strings.TrimPrefix(server.URL, "http://")}},
+			config.EnvConfig{Prefix: "abc"},
+		)
+		store, err := factory.CreateBigSegmentStore(testhelpers.NewSimpleClientContext(""))
+		require.NoError(t, err)
+		defer store.Close()
+		action(store.(*consulBigSegmentStoreImpl))
+	})
+}
+
+func TestConsulBigSegmentStoreGetMetadata(t *testing.T) {
+	t.Run("synchronized", func(t *testing.T) {
+		values := map[string]string{ConsulBigSegmentsSyncTimeKey("abc"): "1000"}
+		withConsulBigSegmentStore(t, values, func(store *consulBigSegmentStoreImpl) {
+			metadata, err := store.GetMetadata()
+			require.NoError(t, err)
+			assert.Equal(t, ldtime.UnixMillisecondTime(1000), metadata.LastUpToDate)
+		})
+	})
+
+	t.Run("never synchronized", func(t *testing.T) {
+		withConsulBigSegmentStore(t, map[string]string{}, func(store *consulBigSegmentStoreImpl) {
+			metadata, err := store.GetMetadata()
+			require.NoError(t, err)
+			assert.False(t, metadata.LastUpToDate.IsDefined())
+		})
+	})
+}
+
+func TestConsulBigSegmentStoreGetUserMembership(t *testing.T) {
+	values := map[string]string{
+		ConsulBigSegmentsIncludeKey("abc", "userhash"): `["seg1.g1","seg2.g1"]`,
+		ConsulBigSegmentsExcludeKey("abc", "userhash"): `["seg3.g1"]`,
+	}
+	withConsulBigSegmentStore(t, values, func(store *consulBigSegmentStoreImpl) {
+		membership, err := store.GetUserMembership("userhash")
+		require.NoError(t, err)
+		assert.Equal(t, ldvalue.NewOptionalBool(true), membership.CheckMembership("seg1.g1"))
+		assert.Equal(t, ldvalue.NewOptionalBool(true), membership.CheckMembership("seg2.g1"))
+		assert.Equal(t, ldvalue.NewOptionalBool(false), membership.CheckMembership("seg3.g1"))
+		assert.Equal(t, ldvalue.OptionalBool{}, membership.CheckMembership("seg4.g1"))
+
+		membership, err = store.GetUserMembership("otheruser")
+		require.NoError(t, err)
+		assert.Equal(t, ldvalue.OptionalBool{}, membership.CheckMembership("seg1.g1"))
+	})
+}
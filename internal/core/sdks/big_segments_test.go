@@ -2,9 +2,13 @@ package sdks
 
 import (
 	"testing"
+	"time"
 
+	ldconsul "github.com/launchdarkly/go-server-sdk-consul"
 	lddynamodb "github.com/launchdarkly/go-server-sdk-dynamodb"
 	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/mongodb"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/postgres"
 
 	"github.com/launchdarkly/go-configtypes"
 	ldredis "github.com/launchdarkly/go-server-sdk-redis-redigo"
@@ -13,6 +17,7 @@ import (
 	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
 	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents"
 
+	consul "github.com/hashicorp/consul/api"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -24,7 +29,7 @@ func assertBigSegmentsConfigured(
 	ec config.EnvConfig,
 ) *ldlogtest.MockLog {
 	mockLog := ldlogtest.NewMockLog()
-	factory, err := ConfigureBigSegments(c, ec, mockLog.Loggers)
+	factory, err := ConfigureBigSegments(c, ec, nil, mockLog.Loggers)
 	require.NoError(t, err)
 	assert.Equal(t, expected, factory)
 	return mockLog
@@ -76,6 +81,81 @@ func TestBigSegmentsRedis(t *testing.T) {
 	})
 }
 
+func TestBigSegmentsRedisCluster(t *testing.T) {
+	optRedisURL, _ := configtypes.NewOptURLAbsoluteFromString("redis://node1:6379")
+	c := config.Config{
+		Redis: config.RedisConfig{
+			URL:          optRedisURL,
+			ClusterAddrs: configtypes.NewOptStringList([]string{"node1:6379"}),
+		},
+	}
+	ec := config.EnvConfig{Prefix: "{abc}"}
+	expected := ldcomponents.BigSegments(redisUniversalStoreFactory{dbConfig: c.Redis, envConfig: ec, poolKind: DatabasePoolBigSegments})
+	log := assertBigSegmentsConfigured(t, expected, c, ec)
+	log.AssertMessageMatch(t, true, ldlog.Info, "Using Redis big segment store: cluster node1:6379")
+}
+
+func TestBigSegmentsConsul(t *testing.T) {
+	host := "consulhost:8500"
+
+	t.Run("basic properties", func(t *testing.T) {
+		c := config.Config{
+			Consul: config.ConsulConfig{
+				Host: host,
+			},
+		}
+		expected := ldcomponents.BigSegments(consulBigSegmentStoreFactory{
+			consulConfig: consul.Config{Address: host},
+			prefix:       ldconsul.DefaultPrefix,
+		})
+		log := assertBigSegmentsConfigured(t, expected, c, config.EnvConfig{})
+		log.AssertMessageMatch(t, true, ldlog.Info, "Using Consul big segment store: "+host)
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		c := config.Config{
+			Consul: config.ConsulConfig{
+				Host: host,
+			},
+		}
+		ec := config.EnvConfig{Prefix: "abc"}
+		expected := ldcomponents.BigSegments(consulBigSegmentStoreFactory{
+			consulConfig: consul.Config{Address: host},
+			prefix:       "abc",
+		})
+		log := assertBigSegmentsConfigured(t, expected, c, ec)
+		log.AssertMessageMatch(t, true, ldlog.Info, "Using Consul big segment store: "+host+" with prefix: abc")
+	})
+
+	t.Run("token", func(t *testing.T) {
+		c := config.Config{
+			Consul: config.ConsulConfig{
+				Host:  host,
+				Token: "xyz",
+			},
+		}
+		expected := ldcomponents.BigSegments(consulBigSegmentStoreFactory{
+			consulConfig: consul.Config{Address: host, Token: "xyz"},
+			prefix:       ldconsul.DefaultPrefix,
+		})
+		assertBigSegmentsConfigured(t, expected, c, config.EnvConfig{})
+	})
+
+	t.Run("token file", func(t *testing.T) {
+		c := config.Config{
+			Consul: config.ConsulConfig{
+				Host:      host,
+				TokenFile: "xyz",
+			},
+		}
+		expected := ldcomponents.BigSegments(consulBigSegmentStoreFactory{
+			consulConfig: consul.Config{Address: host, TokenFile: "xyz"},
+			prefix:       ldconsul.DefaultPrefix,
+		})
+		assertBigSegmentsConfigured(t, expected, c, config.EnvConfig{})
+	})
+}
+
 func TestBigSegmentsDynamoDB(t *testing.T) {
 	table := "my-table"
 
@@ -86,7 +166,11 @@ func TestBigSegmentsDynamoDB(t *testing.T) {
 				TableName: table,
 			},
 		}
-		expected := ldcomponents.BigSegments(lddynamodb.DataStore(table))
+		expected := ldcomponents.BigSegments(dynamoDBBigSegmentStoreFactory{
+			builder:  lddynamodb.DataStore(table),
+			dbConfig: c.DynamoDB,
+			table:    table,
+		})
 		log := assertBigSegmentsConfigured(t, expected, c, config.EnvConfig{})
 		log.AssertMessageMatch(t, true, ldlog.Info, "Using DynamoDB big segment store: "+table)
 	})
@@ -98,7 +182,11 @@ func TestBigSegmentsDynamoDB(t *testing.T) {
 			},
 		}
 		ec := config.EnvConfig{TableName: table}
-		expected := ldcomponents.BigSegments(lddynamodb.DataStore(table))
+		expected := ldcomponents.BigSegments(dynamoDBBigSegmentStoreFactory{
+			builder:  lddynamodb.DataStore(table),
+			dbConfig: c.DynamoDB,
+			table:    table,
+		})
 		log := assertBigSegmentsConfigured(t, expected, c, ec)
 		log.AssertMessageMatch(t, true, ldlog.Info, "Using DynamoDB big segment store: "+table)
 	})
@@ -111,8 +199,175 @@ func TestBigSegmentsDynamoDB(t *testing.T) {
 			},
 		}
 		ec := config.EnvConfig{Prefix: "abc"}
-		expected := ldcomponents.BigSegments(lddynamodb.DataStore(table).Prefix("abc"))
+		expected := ldcomponents.BigSegments(dynamoDBBigSegmentStoreFactory{
+			builder:  lddynamodb.DataStore(table).Prefix("abc"),
+			dbConfig: c.DynamoDB,
+			table:    table,
+			prefix:   "abc",
+		})
 		log := assertBigSegmentsConfigured(t, expected, c, ec)
 		log.AssertMessageMatch(t, true, ldlog.Info, "Using DynamoDB big segment store: "+table+" with prefix: abc")
 	})
 }
+
+func TestBigSegmentsPostgres(t *testing.T) {
+	pgURL := "postgres://pghost:5432/relay"
+	optPgURL, _ := configtypes.NewOptURLAbsoluteFromString(pgURL)
+
+	t.Run("basic properties", func(t *testing.T) {
+		c := config.Config{
+			Postgres: config.PostgresConfig{
+				URL: optPgURL,
+			},
+		}
+		expected := ldcomponents.BigSegments(postgres.BigSegmentStore(c.Postgres, ""))
+		log := assertBigSegmentsConfigured(t, expected, c, config.EnvConfig{})
+		log.AssertMessageMatch(t, true, ldlog.Info, "Using Postgres big segment store: "+pgURL)
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		c := config.Config{
+			Postgres: config.PostgresConfig{
+				URL: optPgURL,
+			},
+		}
+		ec := config.EnvConfig{Prefix: "abc"}
+		expected := ldcomponents.BigSegments(postgres.BigSegmentStore(c.Postgres, "abc"))
+		log := assertBigSegmentsConfigured(t, expected, c, ec)
+		log.AssertMessageMatch(t, true, ldlog.Info, "Using Postgres big segment store: "+pgURL+" with prefix: abc")
+	})
+}
+
+func TestBigSegmentsMongoDB(t *testing.T) {
+	mongoURL := "mongodb://mongohost:27017"
+	optMongoURL, _ := configtypes.NewOptURLAbsoluteFromString(mongoURL)
+
+	t.Run("basic properties", func(t *testing.T) {
+		c := config.Config{
+			MongoDB: config.MongoDBConfig{
+				URL: optMongoURL,
+			},
+		}
+		expected := ldcomponents.BigSegments(mongodb.BigSegmentStore(c.MongoDB, ""))
+		log := assertBigSegmentsConfigured(t, expected, c, config.EnvConfig{})
+		log.AssertMessageMatch(t, true, ldlog.Info, "Using MongoDB big segment store: "+mongoURL)
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		c := config.Config{
+			MongoDB: config.MongoDBConfig{
+				URL: optMongoURL,
+			},
+		}
+		ec := config.EnvConfig{Prefix: "abc"}
+		expected := ldcomponents.BigSegments(mongodb.BigSegmentStore(c.MongoDB, "abc"))
+		log := assertBigSegmentsConfigured(t, expected, c, ec)
+		log.AssertMessageMatch(t, true, ldlog.Info, "Using MongoDB big segment store: "+mongoURL+" with prefix: abc")
+	})
+}
+
+func unregisterBigSegmentStoreProvider(storeType string) {
+	registeredBigSegmentProvidersLock.Lock()
+	delete(registeredBigSegmentProviders, storeType)
+	registeredBigSegmentProvidersLock.Unlock()
+}
+
+func TestBigSegmentsCustomType(t *testing.T) {
+	storeType := "custom-type"
+	c := config.Config{
+		BigSegments: config.BigSegmentsConfig{Type: storeType},
+	}
+
+	t.Run("registered provider", func(t *testing.T) {
+		customStore := ldredis.DataStore().Prefix("custom")
+		var receivedEnvConfig config.EnvConfig
+		RegisterBigSegmentStoreProvider(storeType, func(
+			allConfig config.Config, envConfig config.EnvConfig, loggers ldlog.Loggers,
+		) (interfaces.BigSegmentStoreFactory, error) {
+			receivedEnvConfig = envConfig
+			return customStore, nil
+		})
+		defer unregisterBigSegmentStoreProvider(storeType)
+
+		ec := config.EnvConfig{Prefix: "abc"}
+		expected := ldcomponents.BigSegments(customStore)
+		log := assertBigSegmentsConfigured(t, expected, c, ec)
+		assert.Equal(t, ec, receivedEnvConfig)
+		log.AssertMessageMatch(t, true, ldlog.Info, "Using custom big segment store: "+storeType+" with prefix: abc")
+	})
+
+	t.Run("custom type takes precedence over database", func(t *testing.T) {
+		customStore := ldredis.DataStore().Prefix("custom")
+		RegisterBigSegmentStoreProvider(storeType, func(
+			allConfig config.Config, envConfig config.EnvConfig, loggers ldlog.Loggers,
+		) (interfaces.BigSegmentStoreFactory, error) {
+			return customStore, nil
+		})
+		defer unregisterBigSegmentStoreProvider(storeType)
+
+		c1 := c
+		c1.Redis.URL, _ = configtypes.NewOptURLAbsoluteFromString("redis://redishost:3000")
+		expected := ldcomponents.BigSegments(customStore)
+		assertBigSegmentsConfigured(t, expected, c1, config.EnvConfig{})
+	})
+
+	t.Run("duplicate or nil provider", func(t *testing.T) {
+		provider := func(config.Config, config.EnvConfig, ldlog.Loggers) (interfaces.BigSegmentStoreFactory, error) {
+			return nil, nil
+		}
+		RegisterBigSegmentStoreProvider(storeType, provider)
+		defer unregisterBigSegmentStoreProvider(storeType)
+		assert.Panics(t, func() { RegisterBigSegmentStoreProvider(storeType, provider) })
+		assert.Panics(t, func() { RegisterBigSegmentStoreProvider("other-type", nil) })
+	})
+
+	t.Run("unregistered provider", func(t *testing.T) {
+		factory, err := ConfigureBigSegments(c, config.EnvConfig{}, nil, ldlog.NewDisabledLoggers())
+		assert.Nil(t, factory)
+		assert.Equal(t, errUnknownBigSegmentStoreType(storeType), err)
+	})
+}
+
+func TestBigSegmentsUserCache(t *testing.T) {
+	redisURL := "redis://redishost:3000"
+	optRedisURL, _ := configtypes.NewOptURLAbsoluteFromString(redisURL)
+
+	t.Run("global properties", func(t *testing.T) {
+		c := config.Config{
+			Redis: config.RedisConfig{URL: optRedisURL},
+			BigSegments: config.BigSegmentsConfig{
+				UserCacheTime: configtypes.NewOptDuration(time.Minute),
+			},
+		}
+		c.BigSegments.UserCacheSize, _ = configtypes.NewOptIntGreaterThanZero(10000)
+		expected := ldcomponents.BigSegments(ldredis.DataStore().URL(redisURL)).
+			UserCacheSize(10000).UserCacheTime(time.Minute)
+		assertBigSegmentsConfigured(t, expected, c, config.EnvConfig{})
+	})
+
+	t.Run("environment properties override global properties", func(t *testing.T) {
+		c := config.Config{
+			Redis: config.RedisConfig{URL: optRedisURL},
+			BigSegments: config.BigSegmentsConfig{
+				UserCacheTime: configtypes.NewOptDuration(time.Minute),
+			},
+		}
+		c.BigSegments.UserCacheSize, _ = configtypes.NewOptIntGreaterThanZero(10000)
+		ec := config.EnvConfig{
+			BigSegmentsUserCacheTime: configtypes.NewOptDuration(time.Second),
+		}
+		ec.BigSegmentsUserCacheSize, _ = configtypes.NewOptIntGreaterThanZero(500)
+		expected := ldcomponents.BigSegments(ldredis.DataStore().URL(redisURL)).
+			UserCacheSize(500).UserCacheTime(time.Second)
+		assertBigSegmentsConfigured(t, expected, c, ec)
+	})
+}
+
+func TestBigSegmentsStaleAfter(t *testing.T) {
+	redisURL := "redis://redishost:3000"
+	optRedisURL, _ := configtypes.NewOptURLAbsoluteFromString(redisURL)
+	c := config.Config{Redis: config.RedisConfig{URL: optRedisURL}}
+	ec := config.EnvConfig{BigSegmentsStaleAfter: configtypes.NewOptDuration(10 * time.Minute)}
+	expected := ldcomponents.BigSegments(ldredis.DataStore().URL(redisURL)).StaleAfter(10 * time.Minute)
+	assertBigSegmentsConfigured(t, expected, c, ec)
+}
@@ -1,10 +1,16 @@
 package sdks
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"io/ioutil"
 	"strings"
 
 	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/etcd"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/mongodb"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/postgres"
 	"github.com/launchdarkly/ld-relay/v6/internal/util"
 
 	ldconsul "github.com/launchdarkly/go-server-sdk-consul"
@@ -15,13 +21,16 @@ import (
 	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/go-redis/redis/v8"
 	redigo "github.com/gomodule/redigo/redis"
 	consul "github.com/hashicorp/consul/api"
 )
 
 var (
 	errDynamoDBWithNoTableName = errors.New("TableName property must be specified for DynamoDB, either globally or per environment")
+	errRedisNoCertsInCAFile    = errors.New("Redis TLS CA file does not contain any PEM certificates")
 )
 
 // DataStoreEnvironmentInfo encapsulates database-related configuration details that we will expose in the
@@ -36,12 +45,16 @@ type DataStoreEnvironmentInfo struct {
 	DBServer string
 
 	// DBPrefix is the key prefix used for this environment to distinguish it from data that might be in
-	// the same database for other environments. This is required for Redis and Consul but optional for
-	// DynamoDB.
+	// the same database for other environments. This is required for Redis, Consul, Postgres,
+	// MongoDB, and etcd but optional for DynamoDB.
 	DBPrefix string
 
 	// DBTable is the table name for this environment if using DynamoDB, or "" otherwise.
 	DBTable string
+
+	// Pools tracks the Redis or DynamoDB connection pools for this environment, if the internal metrics
+	// endpoint is enabled; otherwise it is nil.
+	Pools *DatabasePools
 }
 
 // ConfigureDataStore provides the appropriate Go SDK data store factory (in-memory, Redis, etc.) based on
@@ -52,10 +65,42 @@ func ConfigureDataStore(
 	envConfig config.EnvConfig,
 	loggers ldlog.Loggers,
 ) (interfaces.DataStoreFactory, DataStoreEnvironmentInfo, error) {
+	var pools *DatabasePools
+	if allConfig.InternalMetrics.Port.IsDefined() {
+		pools = newDatabasePools()
+	}
+
+	if allConfig.Redis.URL.IsDefined() && IsRedisClusterOrSentinel(allConfig.Redis) {
+		prefix := envConfig.Prefix
+		if prefix == "" {
+			prefix = ldredis.DefaultPrefix
+		}
+		description := describeRedisClusterOrSentinel(allConfig.Redis)
+		loggers.Infof("Using Redis data store: %s with prefix: %s", description, prefix)
+
+		storeInfo := DataStoreEnvironmentInfo{
+			DBType:   "redis",
+			DBServer: description,
+			DBPrefix: prefix,
+			Pools:    pools,
+		}
+		factory := redisUniversalStoreFactory{
+			dbConfig:  allConfig.Redis,
+			envConfig: envConfig,
+			pools:     pools,
+			poolKind:  DatabasePoolDataStore,
+		}
+		return ldcomponents.PersistentDataStore(factory).
+			CacheTime(allConfig.Redis.LocalTTL.GetOrElse(config.DefaultDatabaseCacheTTL)), storeInfo, nil
+	}
+
 	if allConfig.Redis.URL.IsDefined() {
 		// Our config validation already takes care of normalizing the Redis parameters so that if a
 		// host & port were specified, they are transformed into a URL.
-		redisBuilder, redisURL := makeRedisDataStoreBuilder(allConfig, envConfig)
+		redisBuilder, redisURL, err := makeRedisDataStoreBuilder(allConfig, envConfig, pools, DatabasePoolDataStore)
+		if err != nil {
+			return nil, DataStoreEnvironmentInfo{}, err
+		}
 		redactedURL := util.RedactURL(redisURL)
 
 		loggers.Infof("Using Redis data store: %s with prefix: %s", redactedURL, envConfig.Prefix)
@@ -64,6 +109,7 @@ func ConfigureDataStore(
 			DBType:   "redis",
 			DBServer: redactedURL,
 			DBPrefix: envConfig.Prefix,
+			Pools:    pools,
 		}
 		if storeInfo.DBPrefix == "" {
 			storeInfo.DBPrefix = ldredis.DefaultPrefix
@@ -100,7 +146,7 @@ func ConfigureDataStore(
 	}
 
 	if allConfig.DynamoDB.Enabled {
-		builder, tableName, err := makeDynamoDBDataStoreBuilder(allConfig, envConfig)
+		builder, tableName, err := makeDynamoDBDataStoreBuilder(allConfig, envConfig, pools, DatabasePoolDataStore)
 		if err != nil {
 			return nil, DataStoreEnvironmentInfo{}, err
 		}
@@ -112,12 +158,72 @@ func ConfigureDataStore(
 			DBServer: allConfig.DynamoDB.URL.String(),
 			DBPrefix: envConfig.Prefix,
 			DBTable:  tableName,
+			Pools:    pools,
 		}
 
-		return ldcomponents.PersistentDataStore(builder).
+		var factory interfaces.PersistentDataStoreFactory = builder
+		if allConfig.DynamoDB.DAXEndpoint != "" {
+			loggers.Infof("Using DynamoDB Accelerator (DAX) endpoint: %s", allConfig.DynamoDB.DAXEndpoint)
+			endpoint, _, _ := GetDynamoDBBasicProperties(allConfig.DynamoDB, envConfig)
+			sessionOptions, _ := makeDynamoDBSessionOptions(allConfig.DynamoDB, endpoint, pools, DatabasePoolDataStore)
+			factory = daxDataStoreFactory{builder: builder, dbConfig: allConfig.DynamoDB, sessionOptions: sessionOptions}
+		}
+
+		return ldcomponents.PersistentDataStore(factory).
 			CacheTime(allConfig.DynamoDB.LocalTTL.GetOrElse(config.DefaultDatabaseCacheTTL)), storeInfo, nil
 	}
 
+	if allConfig.Postgres.URL.IsDefined() {
+		dbConfig := allConfig.Postgres
+		redactedURL := util.RedactURL(dbConfig.URL.String())
+		prefix := postgres.EffectivePrefix(envConfig.Prefix)
+
+		loggers.Infof("Using Postgres data store: %s with prefix: %s", redactedURL, prefix)
+
+		storeInfo := DataStoreEnvironmentInfo{
+			DBType:   "postgres",
+			DBServer: redactedURL,
+			DBPrefix: prefix,
+		}
+
+		return ldcomponents.PersistentDataStore(postgres.DataStore(dbConfig, prefix)).
+			CacheTime(dbConfig.LocalTTL.GetOrElse(config.DefaultDatabaseCacheTTL)), storeInfo, nil
+	}
+
+	if allConfig.MongoDB.URL.IsDefined() {
+		dbConfig := allConfig.MongoDB
+		redactedURL := util.RedactURL(dbConfig.URL.String())
+		prefix := mongodb.EffectivePrefix(envConfig.Prefix)
+
+		loggers.Infof("Using MongoDB data store: %s with prefix: %s", redactedURL, prefix)
+
+		storeInfo := DataStoreEnvironmentInfo{
+			DBType:   "mongodb",
+			DBServer: redactedURL,
+			DBPrefix: prefix,
+		}
+
+		return ldcomponents.PersistentDataStore(mongodb.DataStore(dbConfig, prefix)).
+			CacheTime(dbConfig.LocalTTL.GetOrElse(config.DefaultDatabaseCacheTTL)), storeInfo, nil
+	}
+
+	if len(allConfig.Etcd.Endpoints.Values()) != 0 {
+		dbConfig := allConfig.Etcd
+		endpoints := strings.Join(dbConfig.Endpoints.Values(), ",")
+		prefix := etcd.EffectivePrefix(envConfig.Prefix)
+
+		loggers.Infof("Using etcd data store: %s with prefix: %s", endpoints, prefix)
+
+		storeInfo := DataStoreEnvironmentInfo{
+			DBType:   "etcd",
+			DBServer: endpoints,
+			DBPrefix: prefix,
+		}
+
+		// The etcd data store watches for changes, so it always has the latest data and does not need a cache.
+		return ldcomponents.PersistentDataStore(etcd.DataStore(dbConfig, prefix)).CacheTime(0), storeInfo, nil
+	}
+
 	return ldcomponents.InMemoryDataStore(), DataStoreEnvironmentInfo{}, nil
 }
 
@@ -145,22 +251,145 @@ func GetRedisBasicProperties(
 	return
 }
 
+// GetRedisClientOptions returns the options for creating a go-redis client with the same connection
+// parameters as the SDK's Redis data store, or for the configured Redis Cluster or Sentinel servers,
+// along with the key prefix for the environment. Use NewRedisClient to create the client.
+func GetRedisClientOptions(
+	dbConfig config.RedisConfig,
+	envConfig config.EnvConfig,
+) (opts *redis.UniversalOptions, prefix string, err error) {
+	redisURL, prefix := GetRedisBasicProperties(dbConfig, envConfig)
+
+	if IsRedisClusterOrSentinel(dbConfig) {
+		opts = &redis.UniversalOptions{
+			Addrs:            dbConfig.ClusterAddrs.Values(),
+			Password:         dbConfig.Password,
+			MasterName:       dbConfig.SentinelMasterName,
+			SentinelPassword: dbConfig.SentinelPassword,
+		}
+		if opts.MasterName != "" {
+			opts.Addrs = dbConfig.SentinelAddrs.Values()
+		}
+		if dbConfig.TLS {
+			// Since there is more than one server, we don't set a default ServerName; the hostname of
+			// each address is used instead.
+			if opts.TLSConfig, err = makeRedisTLSConfig(dbConfig); err != nil {
+				return nil, "", err
+			}
+		}
+		applyRedisPoolOptions(opts, dbConfig)
+		return opts, prefix, nil
+	}
+
+	// Relay's Redis configuration allows setting the server address either as a URL or as a
+	// host & port, but our config validation logic simplifies this so that it is always a URL.
+	// However, it is still possible to set the Password and TLS options separately from the
+	// URL, so we still need to check for those.
+	parsed, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, "", err
+	}
+	opts = &redis.UniversalOptions{
+		DB:        parsed.DB,
+		Addrs:     []string{parsed.Addr},
+		Username:  parsed.Username,
+		Password:  parsed.Password,
+		TLSConfig: parsed.TLSConfig,
+	}
+	if dbConfig.Password != "" {
+		opts.Password = dbConfig.Password
+	}
+	if dbConfig.TLS || opts.TLSConfig != nil {
+		if opts.TLSConfig, err = makeRedisTLSConfig(dbConfig); err != nil {
+			return nil, "", err
+		}
+		if opts.TLSConfig.ServerName == "" {
+			opts.TLSConfig.ServerName = dbConfig.URL.Get().Hostname()
+		}
+	}
+	applyRedisPoolOptions(opts, dbConfig)
+	return opts, prefix, nil
+}
+
+// makeRedisTLSConfig returns the TLS configuration for Redis connections, with the CA certificates,
+// client certificate, and server name from the configuration, if any. If ServerName is not set, the
+// caller or the Redis client library uses the server's hostname.
+func makeRedisTLSConfig(dbConfig config.RedisConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: dbConfig.ServerName}
+	if dbConfig.CAFile != "" {
+		data, err := ioutil.ReadFile(dbConfig.CAFile) //nolint:gosec // the path comes from the configuration
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			return nil, errRedisNoCertsInCAFile
+		}
+	}
+	if dbConfig.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(dbConfig.CertFile, dbConfig.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func hasRedisTLSOptions(dbConfig config.RedisConfig) bool {
+	return dbConfig.CAFile != "" || dbConfig.CertFile != "" || dbConfig.ServerName != ""
+}
+
+// redisStoreFactory is the SDK data store factory and big segment store factory for a single Redis
+// server. The data store factory should be wrapped with ldcomponents.PersistentDataStore() to
+// configure caching.
+type redisStoreFactory interface {
+	interfaces.PersistentDataStoreFactory
+	interfaces.BigSegmentStoreFactory
+}
+
+// makeRedisDataStoreBuilder returns the SDK's Redis data store builder, unless the configuration has
+// pool options or the pool needs to be tracked in pools, in which case it returns a factory that gives
+// each store a Redigo pool created by Relay.
 func makeRedisDataStoreBuilder(
 	allConfig config.Config,
 	envConfig config.EnvConfig,
-) (builder *ldredis.DataStoreBuilder, url string) {
+	pools *DatabasePools,
+	poolKind string,
+) (builder redisStoreFactory, url string, err error) {
 	redisURL, prefix := GetRedisBasicProperties(allConfig.Redis, envConfig)
 
 	var dialOptions []redigo.DialOption
 	if allConfig.Redis.Password != "" {
 		dialOptions = append(dialOptions, redigo.DialPassword(allConfig.Redis.Password))
 	}
+	if hasRedisTLSOptions(allConfig.Redis) {
+		// Redigo decides whether to use TLS based on the URL scheme, which GetRedisBasicProperties has
+		// already changed to rediss: if the TLS option is set.
+		tlsConfig, err := makeRedisTLSConfig(allConfig.Redis)
+		if err != nil {
+			return nil, "", err
+		}
+		dialOptions = append(dialOptions, redigo.DialTLSConfig(tlsConfig))
+	}
+	dialOptions = append(dialOptions, makeRedisTimeoutDialOptions(allConfig.Redis)...)
 
 	b := ldredis.DataStore().
 		URL(redisURL).
 		Prefix(prefix).
 		DialOptions(dialOptions...)
-	return b, redisURL
+	if hasRedisPoolOptions(allConfig.Redis) || pools != nil {
+		return trackedRedigoStoreFactory{
+			builder:     b,
+			redisURL:    redisURL,
+			dialOptions: dialOptions,
+			dbConfig:    allConfig.Redis,
+			pools:       pools,
+			poolKind:    poolKind,
+			prefix:      prefix,
+		}, redisURL, nil
+	}
+	return b, redisURL, nil
 }
 
 // GetDynamoDBBasicProperties transforms the configuration properties to the standard parameters
@@ -187,9 +416,21 @@ func GetDynamoDBBasicProperties(
 	return
 }
 
+// GetDynamoDBCredentials returns the AWS credentials specified in the configuration, or nil if none were
+// specified, in which case the AWS SDK uses its default credentials chain. Like GetDynamoDBBasicProperties,
+// this is used for both the SDK configuration and the internal big segment store.
+func GetDynamoDBCredentials(dbConfig config.DynamoDBConfig) *credentials.Credentials {
+	if dbConfig.AccessKeyID == "" {
+		return nil
+	}
+	return credentials.NewStaticCredentials(dbConfig.AccessKeyID, dbConfig.SecretAccessKey, "")
+}
+
 func makeDynamoDBDataStoreBuilder(
 	allConfig config.Config,
 	envConfig config.EnvConfig,
+	pools *DatabasePools,
+	poolKind string,
 ) (*lddynamodb.DataStoreBuilder, string, error) {
 	endpoint, tableName, prefix := GetDynamoDBBasicProperties(allConfig.DynamoDB, envConfig)
 	if tableName == "" {
@@ -197,8 +438,26 @@ func makeDynamoDBDataStoreBuilder(
 	}
 	builder := lddynamodb.DataStore(tableName).
 		Prefix(prefix)
-	if endpoint != nil {
-		builder.SessionOptions(session.Options{Config: aws.Config{Endpoint: endpoint}})
+	if options, ok := makeDynamoDBSessionOptions(allConfig.DynamoDB, endpoint, pools, poolKind); ok {
+		builder.SessionOptions(options)
 	}
 	return builder, tableName, nil
 }
+
+// makeDynamoDBSessionOptions returns the AWS session options for the DynamoDB configuration, or false
+// if there are no options that differ from the AWS SDK's defaults.
+func makeDynamoDBSessionOptions(
+	dbConfig config.DynamoDBConfig,
+	endpoint *string,
+	pools *DatabasePools,
+	poolKind string,
+) (session.Options, bool) {
+	creds := GetDynamoDBCredentials(dbConfig)
+	httpClient := makeTrackedDynamoDBHTTPClient(dbConfig, pools, poolKind)
+	if endpoint == nil && creds == nil && httpClient == nil {
+		return session.Options{}, false
+	}
+	return session.Options{
+		Config: aws.Config{Endpoint: endpoint, Credentials: creds, HTTPClient: httpClient},
+	}, true
+}
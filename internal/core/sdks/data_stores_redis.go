@@ -0,0 +1,341 @@
+package sdks
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments/membership"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldtime"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// The Redis integration in the Go SDK, which is based on Redigo, can only connect to a single Redis
+// server. For a Redis Cluster or a Sentinel-managed primary, Relay provides its own implementations of
+// the SDK-side data store and big segment store, based on go-redis, which supports both. They use the
+// same data layout as the SDK's Redis integration: each kind of data is a hash whose fields are the
+// item keys, and the big segment references for each user are stored as sets.
+
+const redisInitedKey = "$inited"
+
+// IsRedisClusterOrSentinel returns true if the configuration is for a Redis Cluster or for Redis
+// Sentinel, rather than for a single Redis server.
+func IsRedisClusterOrSentinel(dbConfig config.RedisConfig) bool {
+	return len(dbConfig.ClusterAddrs.Values()) != 0 || len(dbConfig.SentinelAddrs.Values()) != 0
+}
+
+// RedisHasHashTag returns true if a key prefix contains a Redis hash tag, meaning that all keys with
+// that prefix are in the same hash slot of a Redis Cluster.
+func RedisHasHashTag(prefix string) bool {
+	start := strings.Index(prefix, "{")
+	if start < 0 {
+		return false
+	}
+	end := strings.Index(prefix[start+1:], "}")
+	return end > 0
+}
+
+// NewRedisClient creates a go-redis client with the same connection parameters as the SDK's Redis data
+// store, or for the configured Redis Cluster or Sentinel servers, along with the key prefix for the
+// environment. This is used by components other than the SDK's data store that keep their own state in
+// Redis. The Sentinel client asks the Sentinel servers for the current primary whenever it reconnects,
+// so it follows failovers.
+func NewRedisClient(
+	dbConfig config.RedisConfig,
+	envConfig config.EnvConfig,
+) (client redis.UniversalClient, prefix string, err error) {
+	opts, prefix, err := GetRedisClientOptions(dbConfig, envConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	switch {
+	case len(dbConfig.ClusterAddrs.Values()) != 0:
+		// We don't use redis.NewUniversalClient for this, because it would treat a cluster that has
+		// only one configured address as a single server.
+		return redis.NewClusterClient(opts.Cluster()), prefix, nil
+	case opts.MasterName != "":
+		return redis.NewFailoverClient(opts.Failover()), prefix, nil
+	default:
+		return redis.NewClient(opts.Simple()), prefix, nil
+	}
+}
+
+func describeRedisClusterOrSentinel(dbConfig config.RedisConfig) string {
+	if addrs := dbConfig.ClusterAddrs.Values(); len(addrs) != 0 {
+		return "cluster " + strings.Join(addrs, ",")
+	}
+	return "Sentinel " + dbConfig.SentinelMasterName + " at " + strings.Join(dbConfig.SentinelAddrs.Values(), ",")
+}
+
+// redisUniversalStoreFactory is the SDK data store factory and big segment store factory for a Redis
+// Cluster or a Sentinel-managed primary. The data store factory should be wrapped with
+// ldcomponents.PersistentDataStore() to configure caching.
+type redisUniversalStoreFactory struct {
+	dbConfig  config.RedisConfig
+	envConfig config.EnvConfig
+	pools     *DatabasePools
+	poolKind  string
+}
+
+func (f redisUniversalStoreFactory) CreatePersistentDataStore(
+	context interfaces.ClientContext,
+) (interfaces.PersistentDataStore, error) {
+	client, prefix, err := NewRedisClient(f.dbConfig, f.envConfig)
+	if err != nil {
+		return nil, err
+	}
+	loggers := context.GetLogging().GetLoggers()
+	loggers.SetPrefix("RedisDataStore:")
+	return &redisUniversalDataStoreImpl{
+		client:  client,
+		prefix:  prefix,
+		loggers: loggers,
+		untrack: trackGoRedisClient(client, f.dbConfig, f.pools, f.poolKind),
+	}, nil
+}
+
+func (f redisUniversalStoreFactory) CreateBigSegmentStore(
+	context interfaces.ClientContext,
+) (interfaces.BigSegmentStore, error) {
+	client, prefix, err := NewRedisClient(f.dbConfig, f.envConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &redisUniversalBigSegmentStoreImpl{
+		client:  client,
+		prefix:  prefix,
+		untrack: trackGoRedisClient(client, f.dbConfig, f.pools, f.poolKind),
+	}, nil
+}
+
+// DescribeConfiguration is used internally by the SDK to inspect the configuration.
+func (f redisUniversalStoreFactory) DescribeConfiguration() ldvalue.Value {
+	return ldvalue.String("Redis")
+}
+
+// redisUniversalDataStoreImpl is the SDK-side implementation of the PersistentDataStore interface for
+// a Redis Cluster or a Sentinel-managed primary.
+type redisUniversalDataStoreImpl struct {
+	client  redis.UniversalClient
+	prefix  string
+	loggers ldlog.Loggers
+	untrack func()
+}
+
+func (store *redisUniversalDataStoreImpl) Init(allData []ldstoretypes.SerializedCollection) error {
+	ctx := context.Background()
+	totalCount := 0
+	// In a cluster, go-redis runs a separate transaction for each hash slot, so this is only atomic if
+	// the prefix contains a hash tag.
+	_, err := store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, coll := range allData {
+			baseKey := store.featuresKey(coll.Kind)
+			pipe.Del(ctx, baseKey)
+			totalCount += len(coll.Items)
+			for _, keyedItem := range coll.Items {
+				pipe.HSet(ctx, baseKey, keyedItem.Key, keyedItem.Item.SerializedItem)
+			}
+		}
+		pipe.Set(ctx, store.initedKey(), "", 0)
+		return nil
+	})
+	if err == nil {
+		store.loggers.Infof("Initialized with %d items", totalCount)
+	}
+	return err
+}
+
+func (store *redisUniversalDataStoreImpl) Get(
+	kind ldstoretypes.DataKind,
+	key string,
+) (ldstoretypes.SerializedItemDescriptor, error) {
+	return store.get(store.client, kind, key)
+}
+
+func (store *redisUniversalDataStoreImpl) get(
+	cmd redis.Cmdable,
+	kind ldstoretypes.DataKind,
+	key string,
+) (ldstoretypes.SerializedItemDescriptor, error) {
+	jsonStr, err := cmd.HGet(context.Background(), store.featuresKey(kind), key).Result()
+	if err == redis.Nil {
+		if store.loggers.IsDebugEnabled() {
+			store.loggers.Debugf("Key: %s not found in \"%s\"", key, kind.GetName())
+		}
+		return ldstoretypes.SerializedItemDescriptor{}.NotFound(), nil
+	}
+	if err != nil {
+		return ldstoretypes.SerializedItemDescriptor{}.NotFound(), err
+	}
+	return ldstoretypes.SerializedItemDescriptor{Version: 0, SerializedItem: []byte(jsonStr)}, nil
+}
+
+func (store *redisUniversalDataStoreImpl) GetAll(
+	kind ldstoretypes.DataKind,
+) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	values, err := store.client.HGetAll(context.Background(), store.featuresKey(kind)).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	results := make([]ldstoretypes.KeyedSerializedItemDescriptor, 0, len(values))
+	for k, v := range values {
+		results = append(results, ldstoretypes.KeyedSerializedItemDescriptor{
+			Key:  k,
+			Item: ldstoretypes.SerializedItemDescriptor{Version: 0, SerializedItem: []byte(v)},
+		})
+	}
+	return results, nil
+}
+
+func (store *redisUniversalDataStoreImpl) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	newItem ldstoretypes.SerializedItemDescriptor,
+) (bool, error) {
+	ctx := context.Background()
+	baseKey := store.featuresKey(kind)
+	for {
+		updated := false
+		err := store.client.Watch(ctx, func(tx *redis.Tx) error {
+			oldItem, err := store.get(tx, kind, key)
+			if err != nil {
+				return err
+			}
+			// As in the SDK's Redis integration, we have to parse the existing item in order to determine
+			// its version.
+			oldVersion := oldItem.Version
+			if oldItem.SerializedItem != nil {
+				parsed, _ := kind.Deserialize(oldItem.SerializedItem)
+				oldVersion = parsed.Version
+			}
+			if oldVersion >= newItem.Version {
+				if store.loggers.IsDebugEnabled() {
+					store.loggers.Debugf(`Attempted to update key: %s version: %d in "%s" with a version that is the same or older: %d`,
+						key, oldVersion, kind, newItem.Version)
+				}
+				return nil
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.HSet(ctx, baseKey, key, newItem.SerializedItem)
+				return nil
+			})
+			updated = err == nil
+			return err
+		}, baseKey)
+		if err == redis.TxFailedErr {
+			// the watched key was modified by someone else, so we should retry
+			if store.loggers.IsDebugEnabled() {
+				store.loggers.Debug("Concurrent modification detected, retrying")
+			}
+			continue
+		}
+		return updated, err
+	}
+}
+
+func (store *redisUniversalDataStoreImpl) IsInitialized() bool {
+	count, _ := store.client.Exists(context.Background(), store.initedKey()).Result()
+	return count > 0
+}
+
+func (store *redisUniversalDataStoreImpl) IsStoreAvailable() bool {
+	_, err := store.client.Exists(context.Background(), store.initedKey()).Result()
+	return err == nil
+}
+
+func (store *redisUniversalDataStoreImpl) Close() error {
+	if store.untrack != nil {
+		store.untrack()
+	}
+	return store.client.Close()
+}
+
+func (store *redisUniversalDataStoreImpl) featuresKey(kind ldstoretypes.DataKind) string {
+	return store.prefix + ":" + kind.GetName()
+}
+
+func (store *redisUniversalDataStoreImpl) initedKey() string {
+	return store.prefix + ":" + redisInitedKey
+}
+
+// redisUniversalBigSegmentStoreImpl is the SDK-side implementation of the BigSegmentStore interface for
+// a Redis Cluster or a Sentinel-managed primary.
+type redisUniversalBigSegmentStoreImpl struct {
+	client  redis.UniversalClient
+	prefix  string
+	untrack func()
+}
+
+func (store *redisUniversalBigSegmentStoreImpl) GetMetadata() (interfaces.BigSegmentStoreMetadata, error) {
+	valueStr, err := store.client.Get(context.Background(), store.prefix+":big_segments_synchronized_on").Result()
+	if err != nil {
+		return interfaces.BigSegmentStoreMetadata{}, err
+	}
+	value, err := strconv.ParseUint(valueStr, 10, 64)
+	if err != nil {
+		return interfaces.BigSegmentStoreMetadata{}, err
+	}
+	return interfaces.BigSegmentStoreMetadata{
+		LastUpToDate: ldtime.UnixMillisecondTime(value),
+	}, nil
+}
+
+func (store *redisUniversalBigSegmentStoreImpl) GetUserMembership(
+	userHashKey string,
+) (interfaces.BigSegmentMembership, error) {
+	ctx := context.Background()
+	includedRefs, err := store.client.SMembers(ctx, redisBigSegmentsIncludeKey(store.prefix, userHashKey)).Result()
+	if err != nil {
+		return nil, err
+	}
+	excludedRefs, err := store.client.SMembers(ctx, redisBigSegmentsExcludeKey(store.prefix, userHashKey)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return membership.FromSegmentRefs(includedRefs, excludedRefs), nil
+}
+
+// GetUserMemberships implements BigSegmentBatchStore. The membership data is stored in sets, which MGET
+// cannot read, so the SMEMBERS queries for all of the users are sent in a single pipeline instead.
+func (store *redisUniversalBigSegmentStoreImpl) GetUserMemberships(
+	userHashKeys []string,
+) ([]interfaces.BigSegmentMembership, error) {
+	ctx := context.Background()
+	pipe := store.client.Pipeline()
+	cmds := make([]*redis.StringSliceCmd, 0, len(userHashKeys)*2)
+	for _, userHashKey := range userHashKeys {
+		cmds = append(cmds,
+			pipe.SMembers(ctx, redisBigSegmentsIncludeKey(store.prefix, userHashKey)),
+			pipe.SMembers(ctx, redisBigSegmentsExcludeKey(store.prefix, userHashKey)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	ret := make([]interfaces.BigSegmentMembership, 0, len(userHashKeys))
+	for i := 0; i < len(cmds); i += 2 {
+		ret = append(ret, membership.FromSegmentRefs(cmds[i].Val(), cmds[i+1].Val()))
+	}
+	return ret, nil
+}
+
+func (store *redisUniversalBigSegmentStoreImpl) Close() error {
+	if store.untrack != nil {
+		store.untrack()
+	}
+	return store.client.Close()
+}
+
+func redisBigSegmentsIncludeKey(prefix, userHashKey string) string {
+	return prefix + ":big_segment_include:" + userHashKey
+}
+
+func redisBigSegmentsExcludeKey(prefix, userHashKey string) string {
+	return prefix + ":big_segment_exclude:" + userHashKey
+}
@@ -0,0 +1,144 @@
+//go:build redis_unit_tests
+// +build redis_unit_tests
+
+package sdks
+
+// These tests require a Redis server on localhost. The Redis Cluster and Sentinel implementations are
+// tested against a single server, since they only differ from it in how go-redis finds the servers.
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/testhelpers"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/testhelpers/storetest"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRedisUniversalStoreFactory struct {
+	prefix string
+}
+
+func newTestRedisClient() *redis.Client {
+	return redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+}
+
+func (f testRedisUniversalStoreFactory) effectivePrefix() string {
+	if f.prefix == "" {
+		return "launchdarkly"
+	}
+	return f.prefix
+}
+
+func (f testRedisUniversalStoreFactory) CreatePersistentDataStore(
+	context interfaces.ClientContext,
+) (interfaces.PersistentDataStore, error) {
+	return &redisUniversalDataStoreImpl{
+		client:  newTestRedisClient(),
+		prefix:  f.effectivePrefix(),
+		loggers: context.GetLogging().GetLoggers(),
+	}, nil
+}
+
+func (f testRedisUniversalStoreFactory) CreateBigSegmentStore(
+	context interfaces.ClientContext,
+) (interfaces.BigSegmentStore, error) {
+	return &redisUniversalBigSegmentStoreImpl{client: newTestRedisClient(), prefix: f.effectivePrefix()}, nil
+}
+
+func clearRedisTestData(prefix string) error {
+	client := newTestRedisClient()
+	defer client.Close() //nolint:errcheck
+	ctx := context.Background()
+	keys, err := client.Keys(ctx, testRedisUniversalStoreFactory{prefix}.effectivePrefix()+":*").Result()
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	return client.Del(ctx, keys...).Err()
+}
+
+func TestRedisUniversalDataStore(t *testing.T) {
+	storetest.NewPersistentDataStoreTestSuite(
+		func(prefix string) interfaces.PersistentDataStoreFactory {
+			return testRedisUniversalStoreFactory{prefix}
+		},
+		clearRedisTestData,
+	).Run(t)
+}
+
+func TestRedisUniversalBigSegmentStore(t *testing.T) {
+	setMetadata := func(prefix string, metadata interfaces.BigSegmentStoreMetadata) error {
+		client := newTestRedisClient()
+		defer client.Close() //nolint:errcheck
+		return client.Set(context.Background(), testRedisUniversalStoreFactory{prefix}.effectivePrefix()+
+			":big_segments_synchronized_on", strconv.FormatUint(uint64(metadata.LastUpToDate), 10), 0).Err()
+	}
+	setSegments := func(prefix, userHashKey string, included, excluded []string) error {
+		client := newTestRedisClient()
+		defer client.Close() //nolint:errcheck
+		ctx := context.Background()
+		p := testRedisUniversalStoreFactory{prefix}.effectivePrefix()
+		for _, ref := range included {
+			if err := client.SAdd(ctx, p+":big_segment_include:"+userHashKey, ref).Err(); err != nil {
+				return err
+			}
+		}
+		for _, ref := range excluded {
+			if err := client.SAdd(ctx, p+":big_segment_exclude:"+userHashKey, ref).Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	storetest.NewBigSegmentStoreTestSuite(
+		func(prefix string) interfaces.BigSegmentStoreFactory {
+			return testRedisUniversalStoreFactory{prefix}
+		},
+		clearRedisTestData,
+		setMetadata,
+		setSegments,
+	).Run(t)
+}
+
+func TestRedisBigSegmentStoresGetUserMemberships(t *testing.T) {
+	prefix := "batchtest"
+	require.NoError(t, clearRedisTestData(prefix))
+	client := newTestRedisClient()
+	defer client.Close() //nolint:errcheck
+	ctx := context.Background()
+	require.NoError(t, client.SAdd(ctx, prefix+":big_segment_include:u1", "seg1").Err())
+	require.NoError(t, client.SAdd(ctx, prefix+":big_segment_exclude:u2", "seg2").Err())
+
+	verify := func(t *testing.T, store BigSegmentBatchStore) {
+		memberships, err := store.GetUserMemberships([]string{"u1", "u2", "u3"})
+		require.NoError(t, err)
+		require.Len(t, memberships, 3)
+		assert.Equal(t, ldvalue.NewOptionalBool(true), memberships[0].CheckMembership("seg1"))
+		assert.Equal(t, ldvalue.NewOptionalBool(false), memberships[1].CheckMembership("seg2"))
+		assert.Equal(t, ldvalue.OptionalBool{}, memberships[2].CheckMembership("seg1"))
+	}
+
+	t.Run("Redis Cluster or Sentinel", func(t *testing.T) {
+		verify(t, &redisUniversalBigSegmentStoreImpl{client: newTestRedisClient(), prefix: prefix})
+	})
+
+	t.Run("single Redis server", func(t *testing.T) {
+		var c config.Config
+		c.Redis.URL, _ = configtypes.NewOptURLAbsoluteFromString("redis://127.0.0.1:6379")
+		factory, _, err := makeRedisDataStoreBuilder(c, config.EnvConfig{Prefix: prefix}, newDatabasePools(), DatabasePoolBigSegments)
+		require.NoError(t, err)
+		store, err := factory.CreateBigSegmentStore(testhelpers.NewSimpleClientContext(""))
+		require.NoError(t, err)
+		defer store.Close() //nolint:errcheck
+		verify(t, store.(BigSegmentBatchStore))
+	})
+}
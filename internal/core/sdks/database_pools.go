@@ -0,0 +1,334 @@
+package sdks
+
+import (
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ldredis "github.com/launchdarkly/go-server-sdk-redis-redigo"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+
+	"github.com/go-redis/redis/v8"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	// DatabasePoolDataStore is the DatabasePoolStats.Pool value for the connections used by an
+	// environment's SDK data store.
+	DatabasePoolDataStore = "dataStore"
+
+	// DatabasePoolBigSegments is the DatabasePoolStats.Pool value for the connections used by an
+	// environment's SDK big segment store.
+	DatabasePoolBigSegments = "bigSegments"
+
+	// These are the same defaults that the Go SDK's Redis integration uses for its own pool.
+	defaultRedigoMaxIdle     = 20
+	defaultRedigoMaxActive   = 16
+	defaultRedigoIdleTimeout = 300 * time.Second
+)
+
+// DatabasePoolStats is a snapshot of the utilization of the database connections that Relay is using
+// for an environment, for one of the DatabasePool kinds.
+type DatabasePoolStats struct {
+	// Pool is DatabasePoolDataStore or DatabasePoolBigSegments.
+	Pool string
+
+	// InUse is the number of connections that are currently being used. For DynamoDB, this is the
+	// number of requests in progress.
+	InUse int
+
+	// Idle is the number of open connections that are not being used. This is not reported for
+	// DynamoDB.
+	Idle int
+
+	// MaxOpen is the maximum number of connections, or 0 if there is no limit.
+	MaxOpen int
+
+	// WaitCount is the total number of times that a caller had to wait for a connection because the
+	// pool was at its maximum size, and WaitDuration is the total time spent waiting. These are only
+	// reported for a single Redis server.
+	WaitCount    int64
+	WaitDuration time.Duration
+}
+
+// DatabasePools keeps track of the connection pools that Relay has created for an environment's
+// databases, so that their utilization can be reported by the internal metrics endpoint. If there is
+// more than one pool of the same kind, such as during an SDK key rotation, their stats are added
+// together.
+//
+// ConfigureDataStore only creates a DatabasePools if the internal metrics endpoint is enabled; a nil
+// *DatabasePools is valid and does not track anything.
+type DatabasePools struct {
+	sources map[*databasePoolSource]struct{}
+	lock    sync.Mutex
+}
+
+type databasePoolSource struct {
+	pool     string
+	getStats func() DatabasePoolStats
+}
+
+func newDatabasePools() *DatabasePools {
+	return &DatabasePools{sources: make(map[*databasePoolSource]struct{})}
+}
+
+// Stats returns the current stats for each kind of pool, sorted by kind.
+func (p *DatabasePools) Stats() []DatabasePoolStats {
+	if p == nil {
+		return nil
+	}
+	p.lock.Lock()
+	sources := make([]*databasePoolSource, 0, len(p.sources))
+	for s := range p.sources {
+		sources = append(sources, s)
+	}
+	p.lock.Unlock()
+
+	totals := make(map[string]DatabasePoolStats)
+	for _, s := range sources {
+		stats := s.getStats()
+		total := totals[s.pool]
+		total.Pool = s.pool
+		total.InUse += stats.InUse
+		total.Idle += stats.Idle
+		total.MaxOpen += stats.MaxOpen
+		total.WaitCount += stats.WaitCount
+		total.WaitDuration += stats.WaitDuration
+		totals[s.pool] = total
+	}
+	ret := make([]DatabasePoolStats, 0, len(totals))
+	for _, stats := range totals {
+		ret = append(ret, stats)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Pool < ret[j].Pool })
+	return ret
+}
+
+// add starts tracking a pool, and returns a function for removing it when the pool is closed.
+func (p *DatabasePools) add(pool string, getStats func() DatabasePoolStats) func() {
+	if p == nil {
+		return func() {}
+	}
+	s := &databasePoolSource{pool: pool, getStats: getStats}
+	p.lock.Lock()
+	p.sources[s] = struct{}{}
+	p.lock.Unlock()
+	return func() {
+		p.lock.Lock()
+		delete(p.sources, s)
+		p.lock.Unlock()
+	}
+}
+
+func hasRedisPoolOptions(dbConfig config.RedisConfig) bool {
+	return dbConfig.PoolSize.IsDefined() || dbConfig.MinIdleConns.IsDefined() || dbConfig.MaxConnLifetime.IsDefined()
+}
+
+func makeRedisTimeoutDialOptions(dbConfig config.RedisConfig) []redigo.DialOption {
+	var ret []redigo.DialOption
+	if dbConfig.DialTimeout.IsDefined() {
+		ret = append(ret, redigo.DialConnectTimeout(dbConfig.DialTimeout.GetOrElse(0)))
+	}
+	if dbConfig.ReadTimeout.IsDefined() {
+		ret = append(ret, redigo.DialReadTimeout(dbConfig.ReadTimeout.GetOrElse(0)))
+	}
+	return ret
+}
+
+// applyRedisPoolOptions sets the pool and timeout options from the configuration for a go-redis
+// client. Zero values mean that go-redis uses its own defaults.
+func applyRedisPoolOptions(opts *redis.UniversalOptions, dbConfig config.RedisConfig) {
+	opts.PoolSize = dbConfig.PoolSize.GetOrElse(0)
+	opts.MinIdleConns = dbConfig.MinIdleConns.GetOrElse(0)
+	opts.MaxConnAge = dbConfig.MaxConnLifetime.GetOrElse(0)
+	opts.DialTimeout = dbConfig.DialTimeout.GetOrElse(0)
+	opts.ReadTimeout = dbConfig.ReadTimeout.GetOrElse(0)
+}
+
+// trackedRedigoPool is a Redigo connection pool that Relay creates for the SDK's Redis integration,
+// instead of letting the SDK create its own, when the configuration has pool options or the pool
+// needs to be tracked in a DatabasePools.
+type trackedRedigoPool struct {
+	*redigo.Pool
+	remove func()
+}
+
+func newTrackedRedigoPool(
+	redisURL string,
+	dialOptions []redigo.DialOption,
+	dbConfig config.RedisConfig,
+	pools *DatabasePools,
+	poolKind string,
+) *trackedRedigoPool {
+	minIdle := dbConfig.MinIdleConns.GetOrElse(0)
+	maxIdle := defaultRedigoMaxIdle
+	if minIdle > maxIdle {
+		maxIdle = minIdle
+	}
+	pool := &redigo.Pool{
+		MaxIdle:         maxIdle,
+		MaxActive:       dbConfig.PoolSize.GetOrElse(defaultRedigoMaxActive),
+		Wait:            true,
+		IdleTimeout:     defaultRedigoIdleTimeout,
+		MaxConnLifetime: dbConfig.MaxConnLifetime.GetOrElse(0),
+		Dial: func() (redigo.Conn, error) {
+			return redigo.DialURL(redisURL, dialOptions...)
+		},
+		TestOnBorrow: func(c redigo.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+	if minIdle > 0 {
+		// Redigo has no minimum number of idle connections, so we open that many connections now;
+		// they can still be closed later by the idle timeout or the maximum lifetime.
+		conns := make([]redigo.Conn, 0, minIdle)
+		for i := 0; i < minIdle && i < pool.MaxActive; i++ {
+			conns = append(conns, pool.Get())
+		}
+		for _, c := range conns {
+			_ = c.Close()
+		}
+	}
+	p := &trackedRedigoPool{Pool: pool}
+	p.remove = pools.add(poolKind, func() DatabasePoolStats {
+		stats := pool.Stats()
+		return DatabasePoolStats{
+			InUse:        stats.ActiveCount - stats.IdleCount,
+			Idle:         stats.IdleCount,
+			MaxOpen:      pool.MaxActive,
+			WaitCount:    stats.WaitCount,
+			WaitDuration: stats.WaitDuration,
+		}
+	})
+	return p
+}
+
+func (p *trackedRedigoPool) Close() error {
+	p.remove()
+	return p.Pool.Close()
+}
+
+// trackGoRedisClient starts tracking the connection pool of a go-redis client, and returns a function
+// for removing it when the client is closed.
+func trackGoRedisClient(
+	client redis.UniversalClient,
+	dbConfig config.RedisConfig,
+	pools *DatabasePools,
+	poolKind string,
+) func() {
+	maxOpen := dbConfig.PoolSize.GetOrElse(10 * runtime.GOMAXPROCS(0)) // the go-redis default
+	return pools.add(poolKind, func() DatabasePoolStats {
+		stats := client.PoolStats()
+		return DatabasePoolStats{
+			InUse:   int(stats.TotalConns) - int(stats.IdleConns),
+			Idle:    int(stats.IdleConns),
+			MaxOpen: maxOpen,
+		}
+	})
+}
+
+// GetDynamoDBHTTPClient returns the HTTP client for DynamoDB requests with the pool size and timeouts
+// specified in the configuration, or nil if none were specified, in which case the AWS SDK uses its
+// default client. Like GetDynamoDBBasicProperties, this is used for both the SDK configuration and
+// the internal big segment store.
+func GetDynamoDBHTTPClient(dbConfig config.DynamoDBConfig) *http.Client {
+	if !dbConfig.PoolSize.IsDefined() && !dbConfig.DialTimeout.IsDefined() && !dbConfig.ReadTimeout.IsDefined() {
+		return nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if dbConfig.PoolSize.IsDefined() {
+		transport.MaxConnsPerHost = dbConfig.PoolSize.GetOrElse(0)
+		transport.MaxIdleConnsPerHost = dbConfig.PoolSize.GetOrElse(0)
+	}
+	if dbConfig.DialTimeout.IsDefined() {
+		dialer := &net.Dialer{Timeout: dbConfig.DialTimeout.GetOrElse(0), KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+	}
+	transport.ResponseHeaderTimeout = dbConfig.ReadTimeout.GetOrElse(0)
+	return &http.Client{Transport: transport}
+}
+
+// makeTrackedDynamoDBHTTPClient returns the HTTP client for DynamoDB requests, counting the requests
+// that are in progress if there is a DatabasePools. It returns nil if the AWS SDK's default client
+// should be used.
+func makeTrackedDynamoDBHTTPClient(
+	dbConfig config.DynamoDBConfig,
+	pools *DatabasePools,
+	poolKind string,
+) *http.Client {
+	client := GetDynamoDBHTTPClient(dbConfig)
+	if pools == nil {
+		return client
+	}
+	if client == nil {
+		client = &http.Client{Transport: http.DefaultTransport}
+	}
+	transport := &inFlightCountingTransport{base: client.Transport}
+	client.Transport = transport
+	maxOpen := dbConfig.PoolSize.GetOrElse(0)
+	pools.add(poolKind, func() DatabasePoolStats {
+		return DatabasePoolStats{InUse: int(atomic.LoadInt64(&transport.inFlight)), MaxOpen: maxOpen}
+	})
+	return client
+}
+
+type inFlightCountingTransport struct {
+	base     http.RoundTripper
+	inFlight int64
+}
+
+func (t *inFlightCountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&t.inFlight, 1)
+	defer atomic.AddInt64(&t.inFlight, -1)
+	return t.base.RoundTrip(req)
+}
+
+// trackedRedigoStoreFactory is the SDK data store factory and big segment store factory for a single
+// Redis server when Relay creates the Redigo pools. Each store gets its own pool, since the store
+// closes the pool when it is closed.
+type trackedRedigoStoreFactory struct {
+	builder     *ldredis.DataStoreBuilder
+	redisURL    string
+	dialOptions []redigo.DialOption
+	dbConfig    config.RedisConfig
+	pools       *DatabasePools
+	poolKind    string
+	prefix      string
+}
+
+func (f trackedRedigoStoreFactory) builderWithNewPool() (*ldredis.DataStoreBuilder, ldredis.Pool) {
+	b := *f.builder
+	pool := newTrackedRedigoPool(f.redisURL, f.dialOptions, f.dbConfig, f.pools, f.poolKind)
+	return b.PoolInterface(pool), pool
+}
+
+func (f trackedRedigoStoreFactory) CreatePersistentDataStore(
+	context interfaces.ClientContext,
+) (interfaces.PersistentDataStore, error) {
+	b, _ := f.builderWithNewPool()
+	return b.CreatePersistentDataStore(context)
+}
+
+func (f trackedRedigoStoreFactory) CreateBigSegmentStore(
+	context interfaces.ClientContext,
+) (interfaces.BigSegmentStore, error) {
+	b, pool := f.builderWithNewPool()
+	store, err := b.CreateBigSegmentStore(context)
+	if err != nil {
+		return nil, err
+	}
+	return &redigoBigSegmentStore{store: store, pool: pool, prefix: f.prefix}, nil
+}
+
+// DescribeConfiguration is used internally by the SDK to inspect the configuration.
+func (f trackedRedigoStoreFactory) DescribeConfiguration() ldvalue.Value {
+	return f.builder.DescribeConfiguration()
+}
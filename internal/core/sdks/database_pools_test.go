@@ -0,0 +1,150 @@
+package sdks
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/launchdarkly/go-configtypes"
+	ldredis "github.com/launchdarkly/go-server-sdk-redis-redigo"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func makeRedisPoolTestConfig() config.RedisConfig {
+	url, _ := configtypes.NewOptURLAbsoluteFromString("redis://redishost:3000")
+	poolSize, _ := configtypes.NewOptIntGreaterThanZero(50)
+	return config.RedisConfig{
+		URL:             url,
+		PoolSize:        poolSize,
+		MaxConnLifetime: configtypes.NewOptDuration(time.Minute),
+		DialTimeout:     configtypes.NewOptDuration(2 * time.Second),
+		ReadTimeout:     configtypes.NewOptDuration(time.Second),
+	}
+}
+
+func TestDatabasePoolsStats(t *testing.T) {
+	pools := newDatabasePools()
+	pools.add(DatabasePoolDataStore, func() DatabasePoolStats {
+		return DatabasePoolStats{InUse: 1, Idle: 2, MaxOpen: 10, WaitCount: 3, WaitDuration: time.Second}
+	})
+	remove := pools.add(DatabasePoolDataStore, func() DatabasePoolStats {
+		return DatabasePoolStats{InUse: 4, Idle: 5, MaxOpen: 10}
+	})
+	pools.add(DatabasePoolBigSegments, func() DatabasePoolStats {
+		return DatabasePoolStats{InUse: 6, MaxOpen: 20}
+	})
+
+	assert.Equal(t, []DatabasePoolStats{
+		{Pool: DatabasePoolBigSegments, InUse: 6, MaxOpen: 20},
+		{Pool: DatabasePoolDataStore, InUse: 5, Idle: 7, MaxOpen: 20, WaitCount: 3, WaitDuration: time.Second},
+	}, pools.Stats())
+
+	remove()
+	assert.Equal(t, []DatabasePoolStats{
+		{Pool: DatabasePoolBigSegments, InUse: 6, MaxOpen: 20},
+		{Pool: DatabasePoolDataStore, InUse: 1, Idle: 2, MaxOpen: 10, WaitCount: 3, WaitDuration: time.Second},
+	}, pools.Stats())
+}
+
+func TestNilDatabasePoolsDoesNotTrackAnything(t *testing.T) {
+	var pools *DatabasePools
+	remove := pools.add(DatabasePoolDataStore, func() DatabasePoolStats { return DatabasePoolStats{InUse: 1} })
+	remove()
+	assert.Nil(t, pools.Stats())
+}
+
+func TestConfigureDataStoreTracksPoolsOnlyIfInternalMetricsEnabled(t *testing.T) {
+	c := config.Config{Redis: makeRedisPoolTestConfig()}
+	_, info, err := ConfigureDataStore(c, config.EnvConfig{}, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	assert.Nil(t, info.Pools)
+
+	c.InternalMetrics.Port, _ = configtypes.NewOptIntGreaterThanZero(8050)
+	_, info, err = ConfigureDataStore(c, config.EnvConfig{}, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	assert.NotNil(t, info.Pools)
+}
+
+func TestRedisDataStoreWithPoolOptionsUsesRelayPool(t *testing.T) {
+	c := config.Config{Redis: makeRedisPoolTestConfig()}
+	factory, _, err := makeRedisDataStoreBuilder(c, config.EnvConfig{}, nil, DatabasePoolDataStore)
+	require.NoError(t, err)
+	assert.IsType(t, trackedRedigoStoreFactory{}, factory)
+
+	c.Redis = config.RedisConfig{URL: c.Redis.URL, DialTimeout: c.Redis.DialTimeout}
+	factory, _, err = makeRedisDataStoreBuilder(c, config.EnvConfig{}, nil, DatabasePoolDataStore)
+	require.NoError(t, err)
+	assert.IsType(t, &ldredis.DataStoreBuilder{}, factory) // timeouts alone are set with dial options
+}
+
+func TestTrackedRedigoPool(t *testing.T) {
+	dbConfig := makeRedisPoolTestConfig()
+	pools := newDatabasePools()
+	pool := newTrackedRedigoPool("redis://redishost:3000", nil, dbConfig, pools, DatabasePoolDataStore)
+
+	assert.Equal(t, 50, pool.MaxActive)
+	assert.Equal(t, defaultRedigoMaxIdle, pool.MaxIdle)
+	assert.Equal(t, time.Minute, pool.MaxConnLifetime)
+	assert.True(t, pool.Wait)
+	assert.Equal(t, []DatabasePoolStats{{Pool: DatabasePoolDataStore, MaxOpen: 50}}, pools.Stats())
+
+	require.NoError(t, pool.Close())
+	assert.Len(t, pools.Stats(), 0)
+}
+
+func TestGetRedisClientOptionsWithPoolOptions(t *testing.T) {
+	dbConfig := makeRedisPoolTestConfig()
+	dbConfig.MinIdleConns, _ = configtypes.NewOptIntGreaterThanZero(5)
+	opts, _, err := GetRedisClientOptions(dbConfig, config.EnvConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, 50, opts.PoolSize)
+	assert.Equal(t, 5, opts.MinIdleConns)
+	assert.Equal(t, time.Minute, opts.MaxConnAge)
+	assert.Equal(t, 2*time.Second, opts.DialTimeout)
+	assert.Equal(t, time.Second, opts.ReadTimeout)
+}
+
+func TestGetDynamoDBHTTPClient(t *testing.T) {
+	assert.Nil(t, GetDynamoDBHTTPClient(config.DynamoDBConfig{Enabled: true}))
+
+	poolSize, _ := configtypes.NewOptIntGreaterThanZero(30)
+	client := GetDynamoDBHTTPClient(config.DynamoDBConfig{
+		Enabled:     true,
+		PoolSize:    poolSize,
+		ReadTimeout: configtypes.NewOptDuration(3 * time.Second),
+	})
+	require.NotNil(t, client)
+	transport := client.Transport.(*http.Transport)
+	assert.Equal(t, 30, transport.MaxConnsPerHost)
+	assert.Equal(t, 30, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 3*time.Second, transport.ResponseHeaderTimeout)
+}
+
+func TestTrackedDynamoDBHTTPClientCountsRequestsInProgress(t *testing.T) {
+	assert.Nil(t, makeTrackedDynamoDBHTTPClient(config.DynamoDBConfig{Enabled: true}, nil, DatabasePoolDataStore))
+
+	pools := newDatabasePools()
+	client := makeTrackedDynamoDBHTTPClient(config.DynamoDBConfig{Enabled: true}, pools, DatabasePoolDataStore)
+	require.NotNil(t, client)
+	transport := client.Transport.(*inFlightCountingTransport)
+	var statsDuringRequest []DatabasePoolStats
+	transport.base = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		statsDuringRequest = pools.Stats()
+		return &http.Response{StatusCode: 200, Body: http.NoBody, Request: req}, nil
+	})
+
+	resp, err := client.Get("http://localhost/")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, []DatabasePoolStats{{Pool: DatabasePoolDataStore, InUse: 1}}, statsDuringRequest)
+	assert.Equal(t, []DatabasePoolStats{{Pool: DatabasePoolDataStore}}, pools.Stats())
+}
@@ -0,0 +1,99 @@
+package sdks
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	lddynamodb "github.com/launchdarkly/go-server-sdk-dynamodb"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// DAXClientFactory creates a client for a DynamoDB Accelerator (DAX) cluster. The endpoint is the
+// DAXEndpoint configuration property, and sess is the AWS session that Relay would otherwise use for
+// DynamoDB, from which the factory can take the region and credentials.
+//
+// DAX clusters do not speak the DynamoDB HTTP protocol, so the client must come from the DAX client
+// library (github.com/aws/aws-dax-go), which implements the same dynamodbiface.DynamoDBAPI interface.
+type DAXClientFactory func(endpoint string, sess *session.Session) (dynamodbiface.DynamoDBAPI, error)
+
+var (
+	registeredDAXClientFactory     DAXClientFactory //nolint:gochecknoglobals
+	registeredDAXClientFactoryLock sync.RWMutex     //nolint:gochecknoglobals
+)
+
+var errDAXClientNotRegistered = errors.New(
+	"DynamoDB DAX endpoint is configured, but no DAX client has been registered with relay.RegisterDAXClient")
+
+// RegisterDAXClientFactory sets the function that creates DAX clients when the DynamoDB DAXEndpoint
+// configuration property is set. Application code does this with relay.RegisterDAXClient.
+//
+// Like RegisterBigSegmentStoreProvider, it panics if factory is nil or if it was already called.
+func RegisterDAXClientFactory(factory DAXClientFactory) {
+	registeredDAXClientFactoryLock.Lock()
+	defer registeredDAXClientFactoryLock.Unlock()
+	if factory == nil {
+		panic("sdks: RegisterDAXClientFactory factory is nil")
+	}
+	if registeredDAXClientFactory != nil {
+		panic("sdks: RegisterDAXClientFactory called twice")
+	}
+	registeredDAXClientFactory = factory
+}
+
+func getRegisteredDAXClientFactory() DAXClientFactory {
+	registeredDAXClientFactoryLock.RLock()
+	defer registeredDAXClientFactoryLock.RUnlock()
+	return registeredDAXClientFactory
+}
+
+// NewDynamoDBClient returns the client that Relay uses for a DynamoDB configuration: a DAX client if
+// DAXEndpoint is set, or otherwise a regular DynamoDB client for the session. This is exported so that
+// the SDK's data store and big segment store and Relay's own big segment store all go through the same
+// client; DAX caches items that are written through it, so reads and writes must not be split between
+// DAX and DynamoDB.
+func NewDynamoDBClient(dbConfig config.DynamoDBConfig, sess *session.Session) (dynamodbiface.DynamoDBAPI, error) {
+	if dbConfig.DAXEndpoint == "" {
+		return dynamodb.New(sess), nil
+	}
+	factory := getRegisteredDAXClientFactory()
+	if factory == nil {
+		return nil, errDAXClientNotRegistered
+	}
+	return factory(dbConfig.DAXEndpoint, sess)
+}
+
+// daxDataStoreFactory creates the SDK's DynamoDB data store with a DAX client. The client is created
+// when the store is, rather than when Relay reads its configuration, like the client that the SDK
+// would otherwise create itself.
+type daxDataStoreFactory struct {
+	builder        *lddynamodb.DataStoreBuilder
+	dbConfig       config.DynamoDBConfig
+	sessionOptions session.Options
+}
+
+func (f daxDataStoreFactory) CreatePersistentDataStore(
+	context interfaces.ClientContext,
+) (interfaces.PersistentDataStore, error) {
+	sess, err := session.NewSessionWithOptions(f.sessionOptions)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewDynamoDBClient(f.dbConfig, sess)
+	if err != nil {
+		return nil, err
+	}
+	b := *f.builder
+	return b.DynamoClient(client).CreatePersistentDataStore(context)
+}
+
+// DescribeConfiguration is used internally by the SDK to inspect the configuration.
+func (f daxDataStoreFactory) DescribeConfiguration() ldvalue.Value {
+	return f.builder.DescribeConfiguration()
+}
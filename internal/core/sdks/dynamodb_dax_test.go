@@ -0,0 +1,105 @@
+package sdks
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	lddynamodb "github.com/launchdarkly/go-server-sdk-dynamodb"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/testhelpers"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDAXClient struct {
+	dynamodbiface.DynamoDBAPI
+	endpoint string
+}
+
+func withDAXClientFactory(factory DAXClientFactory, action func()) {
+	registeredDAXClientFactoryLock.Lock()
+	registeredDAXClientFactory = factory
+	registeredDAXClientFactoryLock.Unlock()
+	defer func() {
+		registeredDAXClientFactoryLock.Lock()
+		registeredDAXClientFactory = nil
+		registeredDAXClientFactoryLock.Unlock()
+	}()
+	action()
+}
+
+func makeFakeDAXClient(endpoint string, sess *session.Session) (dynamodbiface.DynamoDBAPI, error) {
+	return &fakeDAXClient{endpoint: endpoint}, nil
+}
+
+func TestNewDynamoDBClient(t *testing.T) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String("us-east-1")})
+	require.NoError(t, err)
+
+	t.Run("no DAX endpoint", func(t *testing.T) {
+		withDAXClientFactory(makeFakeDAXClient, func() {
+			client, err := NewDynamoDBClient(config.DynamoDBConfig{}, sess)
+			require.NoError(t, err)
+			assert.IsType(t, &dynamodb.DynamoDB{}, client)
+		})
+	})
+
+	t.Run("DAX endpoint", func(t *testing.T) {
+		withDAXClientFactory(makeFakeDAXClient, func() {
+			client, err := NewDynamoDBClient(config.DynamoDBConfig{DAXEndpoint: "my-cluster:8111"}, sess)
+			require.NoError(t, err)
+			assert.Equal(t, &fakeDAXClient{endpoint: "my-cluster:8111"}, client)
+		})
+	})
+
+	t.Run("DAX endpoint without a registered client", func(t *testing.T) {
+		_, err := NewDynamoDBClient(config.DynamoDBConfig{DAXEndpoint: "my-cluster:8111"}, sess)
+		assert.Equal(t, errDAXClientNotRegistered, err)
+	})
+}
+
+func TestRegisterDAXClientFactoryPanicsIfCalledTwice(t *testing.T) {
+	withDAXClientFactory(makeFakeDAXClient, func() {
+		assert.Panics(t, func() { RegisterDAXClientFactory(makeFakeDAXClient) })
+	})
+	assert.Panics(t, func() { RegisterDAXClientFactory(nil) })
+}
+
+func TestDynamoDBBigSegmentStoreUsesDAXClientForMemberships(t *testing.T) {
+	factory := dynamoDBBigSegmentStoreFactory{
+		builder:        lddynamodb.DataStore("my-table"),
+		dbConfig:       config.DynamoDBConfig{DAXEndpoint: "my-cluster:8111"},
+		sessionOptions: session.Options{Config: aws.Config{Region: aws.String("us-east-1")}},
+		table:          "my-table",
+	}
+
+	t.Run("with DAX client", func(t *testing.T) {
+		withDAXClientFactory(makeFakeDAXClient, func() {
+			store, err := factory.CreateBigSegmentStore(testhelpers.NewSimpleClientContext(""))
+			require.NoError(t, err)
+			defer store.Close() //nolint:errcheck
+			require.IsType(t, &dynamoDBBigSegmentStore{}, store)
+			assert.Equal(t, &fakeDAXClient{endpoint: "my-cluster:8111"}, store.(*dynamoDBBigSegmentStore).client)
+		})
+	})
+
+	t.Run("without a registered DAX client", func(t *testing.T) {
+		_, err := factory.CreateBigSegmentStore(testhelpers.NewSimpleClientContext(""))
+		assert.Equal(t, errDAXClientNotRegistered, err)
+	})
+}
+
+func TestDAXDataStoreFactoryRequiresRegisteredClient(t *testing.T) {
+	factory := daxDataStoreFactory{
+		builder:        lddynamodb.DataStore("my-table"),
+		dbConfig:       config.DynamoDBConfig{DAXEndpoint: "my-cluster:8111"},
+		sessionOptions: session.Options{Config: aws.Config{Region: aws.String("us-east-1")}},
+	}
+	_, err := factory.CreatePersistentDataStore(testhelpers.NewSimpleClientContext(""))
+	assert.Equal(t, errDAXClientNotRegistered, err)
+}
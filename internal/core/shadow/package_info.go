@@ -0,0 +1,3 @@
+// Package shadow contains the traffic shadowing middleware, which copies evaluation requests to another
+// Relay instance and compares its responses with Relay's own.
+package shadow
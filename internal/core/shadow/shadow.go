@@ -0,0 +1,217 @@
+package shadow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+const (
+	// maxInFlight is the number of shadow requests that can be outstanding at once. Beyond that, requests
+	// are not shadowed, so that a slow shadow instance cannot make Relay accumulate goroutines.
+	maxInFlight = 100
+
+	// maxBodySize is the largest response body that is compared. Larger responses are not shadowed.
+	maxBodySize = 5 * 1024 * 1024
+)
+
+// Shadower copies a percentage of requests to the shadow Relay instance, after Relay has responded to
+// them, and compares the shadow's responses with Relay's. Each comparison is counted in the
+// shadow_comparisons metric, and differences are logged. The shadow's responses are never returned to
+// clients.
+type Shadower struct {
+	baseURI    string
+	percentage float64
+	client     *http.Client
+	inFlight   chan struct{}
+	random     func() float64
+	loggers    ldlog.Loggers
+	wg         sync.WaitGroup
+}
+
+type response struct {
+	status int
+	body   []byte
+}
+
+// NewShadower creates a Shadower from the configuration. It returns nil if shadowing is not enabled.
+// ValidateConfig has already checked the percentage.
+func NewShadower(c config.ShadowConfig, loggers ldlog.Loggers) *Shadower {
+	if !c.URI.IsDefined() {
+		return nil
+	}
+	return &Shadower{
+		baseURI:    strings.TrimSuffix(c.URI.String(), "/"),
+		percentage: c.Percentage.GetOrElse(config.DefaultShadowPercentage),
+		client:     &http.Client{Timeout: c.Timeout.GetOrElse(config.DefaultShadowTimeout)},
+		inFlight:   make(chan struct{}, maxInFlight),
+		random:     rand.Float64, //nolint:gosec // sampling does not need a secure random number generator
+		loggers:    loggers,
+	}
+}
+
+// Close waits for any outstanding shadow requests to finish. Each one is limited by the shadow timeout.
+func (s *Shadower) Close() {
+	s.wg.Wait()
+}
+
+// Middleware returns a middleware function that shadows the requests to its handler. It must be applied
+// after SelectEnvironmentByAuthorizationKey, and should only be used for evaluation endpoints, since the
+// shadow instance will do whatever the request asks it to do.
+func (s *Shadower) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodOptions || s.random()*100 >= s.percentage {
+			next.ServeHTTP(w, req)
+			return
+		}
+		var body []byte
+		if req.Body != nil {
+			data, err := ioutil.ReadAll(req.Body)
+			req.Body = ioutil.NopCloser(bytes.NewReader(data))
+			if err != nil {
+				next.ServeHTTP(w, req) // the handler will get the same error
+				return
+			}
+			body = data
+		}
+		recorder := &responseRecorder{writer: w}
+		next.ServeHTTP(recorder, req)
+		if recorder.tooLarge {
+			return
+		}
+		select {
+		case s.inFlight <- struct{}{}:
+		default:
+			s.loggers.Debug("Not shadowing request, because too many shadow requests are in progress")
+			return
+		}
+		shadowReq, err := s.makeRequest(req, body)
+		if err != nil {
+			<-s.inFlight
+			s.loggers.Warnf("Unable to create shadow request: %s", err)
+			return
+		}
+		metricsCtx := middleware.GetEnvContextInfo(req.Context()).Env.GetMetricsContext()
+		primary := response{status: recorder.getStatus(), body: recorder.body.Bytes()}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() { <-s.inFlight }()
+			metrics.RecordShadowComparison(metricsCtx, s.compare(shadowReq, primary))
+		}()
+	})
+}
+
+func (s *Shadower) makeRequest(req *http.Request, body []byte) (*http.Request, error) {
+	// The shadow request is not tied to the original request's context, since that is canceled as soon
+	// as Relay has finished responding.
+	shadowReq, err := http.NewRequestWithContext(context.Background(), req.Method,
+		s.baseURI+req.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	shadowReq.Header = req.Header.Clone()
+	// Our HTTP client only decompresses responses automatically if it chose the encoding itself.
+	shadowReq.Header.Del("Accept-Encoding")
+	shadowReq.Header.Del("Connection")
+	return shadowReq, nil
+}
+
+func (s *Shadower) compare(shadowReq *http.Request, primary response) metrics.ShadowResult {
+	resp, err := s.client.Do(shadowReq)
+	if err != nil {
+		s.loggers.Warnf("Shadow request for %s %s failed: %s", shadowReq.Method, shadowReq.URL.Path, err)
+		return metrics.ShadowError
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+	if err != nil {
+		s.loggers.Warnf("Shadow request for %s %s failed: %s", shadowReq.Method, shadowReq.URL.Path, err)
+		return metrics.ShadowError
+	}
+
+	if resp.StatusCode != primary.status {
+		s.loggers.Warnf("Shadow response for %s %s differs: status was %d, but Relay's was %d",
+			shadowReq.Method, shadowReq.URL.Path, resp.StatusCode, primary.status)
+		return metrics.ShadowMismatch
+	}
+	if !bodiesAreEquivalent(primary.body, body) {
+		s.loggers.Warnf("Shadow response for %s %s differs: body was %s, but Relay's was %s",
+			shadowReq.Method, shadowReq.URL.Path, truncate(body), truncate(primary.body))
+		return metrics.ShadowMismatch
+	}
+	return metrics.ShadowMatch
+}
+
+// bodiesAreEquivalent compares JSON bodies by value, so that the order of properties does not matter;
+// it compares other bodies byte for byte.
+func bodiesAreEquivalent(a, b []byte) bool {
+	var aValue, bValue interface{}
+	if json.Unmarshal(a, &aValue) == nil && json.Unmarshal(b, &bValue) == nil {
+		return reflect.DeepEqual(aValue, bValue)
+	}
+	return bytes.Equal(a, b)
+}
+
+func truncate(body []byte) string {
+	const maxLoggedBodySize = 500
+	if len(body) > maxLoggedBodySize {
+		return string(body[:maxLoggedBodySize]) + "..."
+	}
+	return string(body)
+}
+
+// responseRecorder passes the response through to the client, keeping a copy of it for comparison
+// unless it is larger than maxBodySize.
+type responseRecorder struct {
+	writer     http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+	tooLarge   bool
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.writer.Header()
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	if !r.tooLarge {
+		if r.body.Len()+len(data) > maxBodySize {
+			r.tooLarge = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(data)
+		}
+	}
+	return r.writer.Write(data)
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 {
+		r.statusCode = statusCode
+	}
+	r.writer.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) getStatus() int {
+	if r.statusCode == 0 {
+		return http.StatusOK // the handler didn't write anything, so net/http sends a 200
+	}
+	return r.statusCode
+}
@@ -0,0 +1,162 @@
+package shadow
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/middleware"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testenv"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedRequest struct {
+	method string
+	uri    string
+	header http.Header
+	body   string
+}
+
+func startShadowServer(status int, body string) (*httptest.Server, <-chan receivedRequest) {
+	requestsCh := make(chan receivedRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, _ := ioutil.ReadAll(req.Body)
+		requestsCh <- receivedRequest{method: req.Method, uri: req.URL.RequestURI(), header: req.Header, body: string(data)}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	return server, requestsCh
+}
+
+func makeShadower(t *testing.T, uri string, percentage float64, loggers ldlog.Loggers) *Shadower {
+	u, err := ct.NewOptURLAbsoluteFromString(uri)
+	require.NoError(t, err)
+	s := NewShadower(config.ShadowConfig{URI: u, Percentage: ct.NewOptFloat64(percentage)}, loggers)
+	require.NotNil(t, s)
+	return s
+}
+
+func serveWithEnv(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	env := testenv.NewTestEnvContext("env", true, nil)
+	defer env.Close()
+	req = req.WithContext(middleware.WithEnvContextInfo(req.Context(), middleware.EnvContextInfo{Env: env}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func primaryHandler(status int, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = ioutil.ReadAll(req.Body)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	})
+}
+
+func TestNewShadowerReturnsNilIfNotEnabled(t *testing.T) {
+	assert.Nil(t, NewShadower(config.ShadowConfig{}, ldlog.NewDisabledLoggers()))
+}
+
+func TestRequestIsCopiedToShadow(t *testing.T) {
+	server, requestsCh := startShadowServer(200, `{"b":2,"a":1}`)
+	defer server.Close()
+	s := makeShadower(t, server.URL, 100, ldlog.NewDisabledLoggers())
+
+	req := httptest.NewRequest("REPORT", "/sdk/evalx/user?withReasons=true", bytes.NewBufferString(`{"key":"me"}`))
+	req.Header.Set("Authorization", "sdk-key")
+	req.Header.Set("Content-Type", "application/json")
+	w := serveWithEnv(s.Middleware(primaryHandler(200, `{"a":1,"b":2}`)), req)
+	s.Close()
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `{"a":1,"b":2}`, w.Body.String())
+	select {
+	case r := <-requestsCh:
+		assert.Equal(t, "REPORT", r.method)
+		assert.Equal(t, "/sdk/evalx/user?withReasons=true", r.uri)
+		assert.Equal(t, "sdk-key", r.header.Get("Authorization"))
+		assert.Equal(t, "application/json", r.header.Get("Content-Type"))
+		assert.Equal(t, `{"key":"me"}`, r.body)
+	case <-time.After(time.Second):
+		require.Fail(t, "timed out waiting for shadow request")
+	}
+}
+
+func TestRequestIsNotCopiedIfNotSampled(t *testing.T) {
+	server, requestsCh := startShadowServer(200, "")
+	defer server.Close()
+	s := makeShadower(t, server.URL, 10, ldlog.NewDisabledLoggers())
+	s.random = func() float64 { return 0.5 }
+
+	w := serveWithEnv(s.Middleware(primaryHandler(200, "ok")), httptest.NewRequest("GET", "/sdk/evalx/users/x", nil))
+	s.Close()
+
+	assert.Equal(t, "ok", w.Body.String())
+	assert.Len(t, requestsCh, 0)
+}
+
+func TestOptionsRequestIsNotCopied(t *testing.T) {
+	server, requestsCh := startShadowServer(200, "")
+	defer server.Close()
+	s := makeShadower(t, server.URL, 100, ldlog.NewDisabledLoggers())
+
+	serveWithEnv(s.Middleware(primaryHandler(200, "")), httptest.NewRequest("OPTIONS", "/sdk/evalx/env/users/x", nil))
+	s.Close()
+
+	assert.Len(t, requestsCh, 0)
+}
+
+func TestCompare(t *testing.T) {
+	compare := func(t *testing.T, shadowStatus int, shadowBody string, primary response) (metrics.ShadowResult, *ldlogtest.MockLog) {
+		server, _ := startShadowServer(shadowStatus, shadowBody)
+		defer server.Close()
+		mockLog := ldlogtest.NewMockLog()
+		s := makeShadower(t, server.URL, 100, mockLog.Loggers)
+		req, err := http.NewRequest("GET", server.URL+"/sdk/eval/users/x", nil)
+		require.NoError(t, err)
+		return s.compare(req, primary), mockLog
+	}
+
+	t.Run("equivalent JSON", func(t *testing.T) {
+		result, _ := compare(t, 200, `{"flag": {"value": true, "version": 2}}`,
+			response{status: 200, body: []byte(`{"flag":{"version":2,"value":true}}`)})
+		assert.Equal(t, metrics.ShadowMatch, result)
+	})
+
+	t.Run("same non-JSON body", func(t *testing.T) {
+		result, _ := compare(t, 404, "not found", response{status: 404, body: []byte("not found")})
+		assert.Equal(t, metrics.ShadowMatch, result)
+	})
+
+	t.Run("different status", func(t *testing.T) {
+		result, mockLog := compare(t, 503, `{}`, response{status: 200, body: []byte(`{}`)})
+		assert.Equal(t, metrics.ShadowMismatch, result)
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "status was 503, but Relay's was 200")
+	})
+
+	t.Run("different body", func(t *testing.T) {
+		result, mockLog := compare(t, 200, `{"flag":false}`, response{status: 200, body: []byte(`{"flag":true}`)})
+		assert.Equal(t, metrics.ShadowMismatch, result)
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, `body was {"flag":false}, but Relay's was {"flag":true}`)
+	})
+
+	t.Run("request failed", func(t *testing.T) {
+		server, _ := startShadowServer(200, "")
+		server.Close()
+		s := makeShadower(t, server.URL, 100, ldlog.NewDisabledLoggers())
+		req, err := http.NewRequest("GET", server.URL+"/sdk/eval/users/x", nil)
+		require.NoError(t, err)
+		assert.Equal(t, metrics.ShadowError, s.compare(req, response{status: 200}))
+	})
+}
@@ -44,8 +44,10 @@ type TestMetricsRow struct {
 
 // NewTestMetricsExporter creates a TestMetricsExporter.
 func NewTestMetricsExporter() *TestMetricsExporter {
+	// The data channel must have room for a report of every view, since OpenCensus can't unregister the
+	// exporter while it is blocked sending one.
 	return &TestMetricsExporter{
-		dataCh:   make(chan TestMetricsData, 10),
+		dataCh:   make(chan TestMetricsData, 100),
 		spansCh:  make(chan *trace.SpanData, 10),
 		lastData: make(TestMetricsData),
 	}
@@ -87,6 +89,12 @@ func (e *TestMetricsExporter) ExportView(viewData *view.Data) {
 		if countData, ok := vr.Data.(*view.CountData); ok {
 			tr.Count = countData.Value
 		}
+		if distributionData, ok := vr.Data.(*view.DistributionData); ok {
+			tr.Count = distributionData.Count
+		}
+		if lastValueData, ok := vr.Data.(*view.LastValueData); ok {
+			tr.Sum = lastValueData.Value
+		}
 		rows = append(rows, tr)
 	}
 
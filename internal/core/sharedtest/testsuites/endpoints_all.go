@@ -9,7 +9,11 @@ func DoAllCoreEndpointTests(t *testing.T, constructor TestConstructor) {
 	constructor.RunTest(t, "stream endpoints", DoStreamEndpointsTests)
 	constructor.RunTest(t, "browser CORS", DoJSClientCORSBehaviorTests)
 	constructor.RunTest(t, "PHP polling", DoPHPPollingEndpointsTests)
+	constructor.RunTest(t, "PHP polling cache settings", DoPHPPollingCacheSettingsTest)
 	constructor.RunTest(t, "event forwarding", DoEventProxyTests)
 	constructor.RunTest(t, "goals", DoJSClientGoalsEndpointTest)
 	constructor.RunTest(t, "status", DoStatusEndpointTests)
+	constructor.RunTest(t, "readiness", DoReadinessEndpointTests)
+	constructor.RunTest(t, "health", DoHealthEndpointsTests)
+	constructor.RunTest(t, "archive", DoArchiveEndpointTest)
 }
@@ -9,6 +9,8 @@ import (
 	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
 
+	ct "github.com/launchdarkly/go-configtypes"
+
 	"gopkg.in/launchdarkly/go-sdk-common.v2/lduser"
 
 	"github.com/stretchr/testify/assert"
@@ -18,6 +20,7 @@ func DoEvalEndpointsTests(t *testing.T, constructor TestConstructor) {
 	constructor.RunTest(t, "server-side", DoServerSideEvalRoutesTest)
 	constructor.RunTest(t, "mobile", DoMobileEvalRoutesTest)
 	constructor.RunTest(t, "JS client", DoJSClientEvalRoutesTest)
+	constructor.RunTest(t, "client-side flag filter", DoClientSideFlagFilterTest)
 }
 
 func DoServerSideEvalRoutesTest(t *testing.T, constructor TestConstructor) {
@@ -233,3 +236,40 @@ func DoJSClientEvalRoutesTest(t *testing.T, constructor TestConstructor) {
 		}
 	})
 }
+
+func DoClientSideFlagFilterTest(t *testing.T, constructor TestConstructor) {
+	userJSON := []byte(`{"key":"me"}`)
+	jsEnv, mobileEnv, serverEnv := st.EnvClientSide, st.EnvMobile, st.EnvMain
+	for _, e := range []*st.TestEnv{&jsEnv, &mobileEnv, &serverEnv} {
+		e.Config.ClientSideFlagInclude = ct.NewOptStringList([]string{"client-*", "mobile-*", st.Flag5ClientSide.Flag.Key})
+		e.Config.ClientSideFlagExclude = ct.NewOptStringList([]string{"mobile-flag-key"})
+	}
+	expectedJSBody := st.ExpectJSONBody(st.MakeEvalBody([]st.TestFlag{st.Flag4ClientSide, st.Flag5ClientSide}, false, false))
+	expectedMobileBody := st.ExpectJSONBody(st.MakeEvalBody([]st.TestFlag{st.Flag4ClientSide, st.Flag5ClientSide}, false, false))
+	expectedServerBody := st.ExpectJSONBody(st.MakeEvalBody(st.AllFlags, false, false))
+
+	specs := []endpointTestParams{
+		{"JS client", "REPORT", "/sdk/eval/$ENV/user", userJSON, jsEnv.Config.EnvID,
+			http.StatusOK, expectedJSBody},
+		{"mobile", "REPORT", "/msdk/eval/user", userJSON, mobileEnv.Config.MobileKey,
+			http.StatusOK, expectedMobileBody},
+		{"server-side is not filtered", "REPORT", "/sdk/eval/user", userJSON, serverEnv.Config.SDKKey,
+			http.StatusOK, expectedServerBody},
+	}
+
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(jsEnv, mobileEnv, serverEnv)
+
+	DoTest(t, config, constructor, func(p TestParams) {
+		for _, spec := range specs {
+			s := spec
+			t.Run(s.name, func(t *testing.T) {
+				result, body := st.DoRequest(s.request(), p.Handler)
+
+				if assert.Equal(t, s.expectedStatus, result.StatusCode) {
+					s.bodyMatcher(t, body)
+				}
+			})
+		}
+	})
+}
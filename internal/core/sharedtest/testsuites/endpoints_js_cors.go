@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	c "github.com/launchdarkly/ld-relay/v6/config"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/internal/browser"
@@ -97,4 +98,31 @@ func DoJSClientCORSBehaviorTests(t *testing.T, constructor TestConstructor) {
 			}
 		})
 	})
+
+	t.Run("Access-Control-Allow-Origin with pattern that matches the request origin", func(t *testing.T) {
+		actualOrigin := "https://app.example.com"
+		env := st.EnvClientSide
+		env.Config.AllowedOrigin = configtypes.NewOptStringList([]string{"http://non-matching-origin", "https://*.example.com"})
+		config := c.Config{Environment: st.MakeEnvConfigs(env)}
+		DoTest(t, config, constructor, func(p TestParams) {
+			req := endpoint.request()
+			req.Header.Set("Origin", actualOrigin)
+			result, _ := st.DoRequest(req, p.Handler)
+			if assert.Equal(t, endpoint.expectedStatus, result.StatusCode) {
+				assert.Equal(t, actualOrigin, result.Header.Get("Access-Control-Allow-Origin"))
+			}
+		})
+	})
+
+	t.Run("Access-Control-Max-Age with custom value", func(t *testing.T) {
+		env := st.EnvClientSide
+		env.Config.CORSMaxAge = configtypes.NewOptDuration(time.Hour)
+		config := c.Config{Environment: st.MakeEnvConfigs(env)}
+		DoTest(t, config, constructor, func(p TestParams) {
+			result, _ := st.DoRequest(endpoint.request(), p.Handler)
+			if assert.Equal(t, endpoint.expectedStatus, result.StatusCode) {
+				assert.Equal(t, "3600", result.Header.Get("Access-Control-Max-Age"))
+			}
+		})
+	})
 }
@@ -5,15 +5,20 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 
 	c "github.com/launchdarkly/ld-relay/v6/config"
 	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/filedata"
 
 	ct "github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func DoJSClientGoalsEndpointTest(t *testing.T, constructor TestConstructor) {
@@ -56,3 +61,61 @@ func DoJSClientGoalsEndpointTest(t *testing.T, constructor TestConstructor) {
 		})
 	})
 }
+
+type archiveEnvironments struct {
+	added chan filedata.ArchiveEnvironment
+}
+
+func (a archiveEnvironments) AddEnvironment(env filedata.ArchiveEnvironment)    { a.added <- env }
+func (a archiveEnvironments) UpdateEnvironment(env filedata.ArchiveEnvironment) {}
+func (a archiveEnvironments) EnvironmentFailed(id c.EnvironmentID, err error)   {}
+func (a archiveEnvironments) DeleteEnvironment(id c.EnvironmentID)              {}
+
+func DoArchiveEndpointTest(t *testing.T, constructor TestConstructor) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvClientSide)
+
+	DoTest(t, config, constructor, func(p TestParams) {
+		t.Run("archive can be read by file data source", func(t *testing.T) {
+			r := st.BuildRequestWithAuth("GET", "http://localhost/archive", st.EnvClientSide.Config.SDKKey, nil)
+			result, body := st.DoRequest(r, p.Handler)
+			require.Equal(t, http.StatusOK, result.StatusCode)
+			assert.Equal(t, filedata.ArchiveContentType, result.Header.Get("Content-Type"))
+
+			filePath := filepath.Join(t.TempDir(), "archive.tar.gz")
+			require.NoError(t, ioutil.WriteFile(filePath, body, 0600))
+			handler := archiveEnvironments{added: make(chan filedata.ArchiveEnvironment, 10)}
+			am, err := filedata.NewArchiveManager(filePath, handler, 0, ldlog.NewDisabledLoggers())
+			require.NoError(t, err)
+			defer am.Close()
+
+			require.Len(t, handler.added, 1)
+			env := <-handler.added
+			assert.Equal(t, st.EnvClientSide.Config.EnvID, env.Params.EnvID)
+			assert.Equal(t, st.EnvClientSide.Config.SDKKey, env.Params.SDKKey)
+			flagKeys := make(map[string]bool)
+			for _, coll := range env.SDKData {
+				if coll.Kind.GetName() == ldstoreimpl.Features().GetName() {
+					for _, item := range coll.Items {
+						flagKeys[item.Key] = true
+					}
+				}
+			}
+			for _, f := range st.AllFlags {
+				assert.True(t, flagKeys[f.Flag.Key], f.Flag.Key)
+			}
+		})
+
+		t.Run("environment without environment ID", func(t *testing.T) {
+			r := st.BuildRequestWithAuth("GET", "http://localhost/archive", st.EnvMain.Config.SDKKey, nil)
+			result, _ := st.DoRequest(r, p.Handler)
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		})
+
+		t.Run("unknown SDK key", func(t *testing.T) {
+			r := st.BuildRequestWithAuth("GET", "http://localhost/archive", st.UndefinedSDKKey, nil)
+			result, _ := st.DoRequest(r, p.Handler)
+			assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+		})
+	})
+}
@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	c "github.com/launchdarkly/ld-relay/v6/config"
 	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
 
+	ct "github.com/launchdarkly/go-configtypes"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func DoPHPPollingEndpointsTests(t *testing.T, constructor TestConstructor) {
@@ -46,8 +49,10 @@ func DoPHPPollingEndpointsTests(t *testing.T, constructor TestConstructor) {
 							if s.bodyMatcher != nil {
 								s.bodyMatcher(t, body)
 							}
-							etag := result.Header.Get("Etag")
-							assert.NotEqual(t, "", etag)
+							etag = result.Header.Get("Etag")
+							assert.Regexp(t, `^"relay-.+"$`, etag)
+							assert.Equal(t, "public, no-cache", result.Header.Get("Cache-Control"))
+							assert.NotEqual(t, "", result.Header.Get("Surrogate-Key"))
 						}
 					})
 
@@ -58,6 +63,7 @@ func DoPHPPollingEndpointsTests(t *testing.T, constructor TestConstructor) {
 
 						if assert.Equal(t, s.expectedStatus, result.StatusCode) {
 							assert.NotEqual(t, "", result.Header.Get("Expires"))
+							assert.Equal(t, "public", result.Header.Get("Cache-Control"))
 						}
 					})
 
@@ -68,9 +74,10 @@ func DoPHPPollingEndpointsTests(t *testing.T, constructor TestConstructor) {
 							result, _ := st.DoRequest(r, p.Handler)
 
 							assert.Equal(t, http.StatusNotModified, result.StatusCode)
+							assert.Equal(t, etag, result.Header.Get("Etag"))
 						})
 
-						t.Run("query with different ETag is cached", func(t *testing.T) {
+						t.Run("query with different ETag is not cached", func(t *testing.T) {
 							r := s.request()
 							r.Header.Set("If-None-Match", "different-from-"+etag)
 							result, _ := st.DoRequest(r, p.Handler)
@@ -83,3 +90,45 @@ func DoPHPPollingEndpointsTests(t *testing.T, constructor TestConstructor) {
 		}
 	})
 }
+
+func DoPHPPollingCacheSettingsTest(t *testing.T, constructor TestConstructor) {
+	env := st.EnvWithTTL // default TTL is 10 minutes
+	env.Config.AllFlagsTTL = ct.NewOptDuration(time.Minute)
+	env.Config.SegmentTTL = ct.NewOptDuration(0)
+	env.Config.StaleWhileRevalidate = ct.NewOptDuration(time.Second * 30)
+
+	specs := []struct {
+		name                 string
+		path                 string
+		expectedTTL          time.Duration
+		expectedCacheControl string
+	}{
+		{"all flags", "/sdk/flags", time.Minute, "public, stale-while-revalidate=30"},
+		{"flag", "/sdk/flags/" + st.Flag1ServerSide.Flag.Key, time.Minute * 10, "public, stale-while-revalidate=30"},
+		{"segment", "/sdk/segments/" + st.Segment1.Key, 0, "public, no-cache"},
+	}
+
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(env)
+
+	DoTest(t, config, constructor, func(p TestParams) {
+		for _, spec := range specs {
+			s := spec
+			t.Run(s.name, func(t *testing.T) {
+				for i := 0; i < 2; i++ { // the second response may come from Relay's cache
+					req := st.BuildRequestWithAuth("GET", "http://localhost"+s.path, env.Config.SDKKey, nil)
+					result, _ := st.DoRequest(req, p.Handler)
+					require.Equal(t, http.StatusOK, result.StatusCode)
+					assert.Equal(t, s.expectedCacheControl, result.Header.Get("Cache-Control"))
+					if s.expectedTTL == 0 {
+						assert.Equal(t, "", result.Header.Get("Expires"))
+						continue
+					}
+					expires, err := http.ParseTime(result.Header.Get("Expires"))
+					require.NoError(t, err)
+					assert.WithinDuration(t, time.Now().Add(s.expectedTTL), expires, time.Second*5)
+				}
+			})
+		}
+	})
+}
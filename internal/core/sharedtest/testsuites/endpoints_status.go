@@ -127,4 +127,62 @@ func DoStatusEndpointTests(t *testing.T, constructor TestConstructor) {
 			st.AssertJSONPathMatch(t, "degraded", status, "status")
 		})
 	})
+
+	t.Run("big segments status", func(t *testing.T) {
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvClientSide)
+
+		DoTest(t, config, constructor, func(p TestParams) {
+			envID := string(st.EnvClientSide.Config.EnvID)
+			specs := []endpointTestParams{
+				{"no credential", "GET", "/bigsegments/status/" + envID, nil, nil, http.StatusUnauthorized, nil},
+				{"environment ID of a different environment", "GET", "/bigsegments/status/" + envID, nil,
+					st.EnvMain.Config.SDKKey, http.StatusNotFound, nil},
+				{"big segments not in use", "GET", "/bigsegments/status/" + envID, nil,
+					st.EnvClientSide.Config.SDKKey, http.StatusNotFound, nil},
+			}
+			for _, s := range specs {
+				t.Run(s.name, func(t *testing.T) {
+					result, _ := st.DoRequest(s.request(), p.Handler)
+					assert.Equal(t, s.expectedStatus, result.StatusCode)
+				})
+			}
+		})
+	})
+}
+
+func DoReadinessEndpointTests(t *testing.T, constructor TestConstructor) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvMobile)
+
+	DoTest(t, config, constructor, func(p TestParams) {
+		r, _ := http.NewRequest("GET", "http://localhost/ready", nil)
+		result, body := st.DoRequest(r, p.Handler)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.JSONEq(t, `{"ready": true}`, string(body))
+	})
+}
+
+// DoHealthEndpointsTests verifies the /healthz/live and /healthz/ready endpoints.
+func DoHealthEndpointsTests(t *testing.T, constructor TestConstructor) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvMobile)
+
+	DoTest(t, config, constructor, func(p TestParams) {
+		t.Run("live", func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://localhost/healthz/live", nil)
+			result, body := st.DoRequest(r, p.Handler)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			assert.JSONEq(t, `{"live": true}`, string(body))
+		})
+
+		t.Run("ready", func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://localhost/healthz/ready", nil)
+			result, body := st.DoRequest(r, p.Handler)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			envHealth := `{"ready": true, "checks": {"started": true, "upstream": true}}`
+			assert.JSONEq(t, `{"ready": true, "environments": {"`+st.EnvMain.Name+`": `+envHealth+
+				`, "`+st.EnvMobile.Name+`": `+envHealth+`}}`, string(body))
+		})
+	})
 }
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,6 +20,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
 )
 
 func DoStreamEndpointsTests(t *testing.T, constructor TestConstructor) {
@@ -167,6 +170,22 @@ func (s streamEndpointTestParams) assertStreamClosesAutomatically(
 	})
 }
 
+func (s streamEndpointTestParams) assertWebSocketReceivesEvent(t *testing.T, handler http.Handler) {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	url := strings.Replace(s.localURL(), "http://localhost", server.URL, 1)
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(url, "http"), "", server.URL)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.NoError(t, ws.SetDeadline(time.Now().Add(time.Second*3)))
+
+	var message struct {
+		Event string `json:"event"`
+	}
+	require.NoError(t, websocket.JSON.Receive(ws, &message))
+	assert.Equal(t, s.expectedEvent, message.Event)
+}
+
 func doStreamRequestExpectingError(req *http.Request, handler http.Handler) *http.Response {
 	w, bodyReader := st.NewStreamRecorder()
 	handler.ServeHTTP(w, req)
@@ -276,6 +295,12 @@ func DoJSClientStreamsTest(t *testing.T, constructor TestConstructor) {
 				t.Run("options", func(t *testing.T) {
 					st.AssertEndpointSupportsOptionsRequest(t, p.Handler, s.localURL(), s.method)
 				})
+
+				if s.method == "GET" {
+					t.Run("WebSocket", func(t *testing.T) {
+						s.assertWebSocketReceivesEvent(t, p.Handler)
+					})
+				}
 			})
 		}
 	})
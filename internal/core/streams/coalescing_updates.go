@@ -0,0 +1,114 @@
+package streams
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+)
+
+// CoalescingEnvStreamUpdates is an EnvStreamUpdates decorator that holds single-item updates for a short
+// window before passing them on, so that if the same flag or segment changes several times in quick
+// succession, as it can during a bulk change or a big rollout, connected SDKs receive only the latest
+// version of it.
+//
+// The window starts with the first update that arrives while nothing is pending, so no update is delayed
+// for longer than that. A full data update replaces any pending updates, since it already includes them.
+type CoalescingEnvStreamUpdates struct {
+	target   EnvStreamUpdates
+	window   time.Duration
+	pending  []deltaItemUpdate
+	indexes  map[ldstoretypes.DataKind]map[string]int
+	timer    *time.Timer
+	closed   bool
+	lock     sync.Mutex
+	sendLock sync.Mutex // ensures that held updates cannot be sent after a later full data update
+}
+
+// NewCoalescingEnvStreamUpdates creates a CoalescingEnvStreamUpdates that passes updates on to target,
+// holding single-item updates for the specified window.
+func NewCoalescingEnvStreamUpdates(target EnvStreamUpdates, window time.Duration) *CoalescingEnvStreamUpdates {
+	return &CoalescingEnvStreamUpdates{
+		target:  target,
+		window:  window,
+		indexes: make(map[ldstoretypes.DataKind]map[string]int),
+	}
+}
+
+// SendAllDataUpdate discards any pending single-item updates and passes the full data update on
+// immediately.
+func (c *CoalescingEnvStreamUpdates) SendAllDataUpdate(allData []ldstoretypes.Collection) {
+	c.sendLock.Lock()
+	defer c.sendLock.Unlock()
+	c.lock.Lock()
+	c.takePending()
+	c.lock.Unlock()
+	c.target.SendAllDataUpdate(allData)
+}
+
+// SendSingleItemUpdate holds the update until the current window ends. If there is already a pending
+// update for the same item, it is replaced, unless it has a higher version.
+func (c *CoalescingEnvStreamUpdates) SendSingleItemUpdate(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.ItemDescriptor,
+) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return
+	}
+	keys := c.indexes[kind]
+	if keys == nil {
+		keys = make(map[string]int)
+		c.indexes[kind] = keys
+	}
+	if i, ok := keys[key]; ok {
+		if c.pending[i].item.Version <= item.Version {
+			c.pending[i].item = item
+		}
+		return
+	}
+	keys[key] = len(c.pending)
+	c.pending = append(c.pending, deltaItemUpdate{kind: kind, key: key, item: item})
+	if c.timer == nil {
+		c.timer = time.AfterFunc(c.window, c.flush)
+	}
+}
+
+// InvalidateClientSideState is passed on immediately.
+func (c *CoalescingEnvStreamUpdates) InvalidateClientSideState() {
+	c.target.InvalidateClientSideState()
+}
+
+// Close discards any pending updates and stops the timer.
+func (c *CoalescingEnvStreamUpdates) Close() {
+	c.lock.Lock()
+	c.closed = true
+	c.takePending()
+	c.lock.Unlock()
+}
+
+func (c *CoalescingEnvStreamUpdates) flush() {
+	c.sendLock.Lock()
+	defer c.sendLock.Unlock()
+	c.lock.Lock()
+	updates := c.takePending()
+	c.lock.Unlock()
+	for _, u := range updates {
+		c.target.SendSingleItemUpdate(u.kind, u.key, u.item)
+	}
+}
+
+// takePending must be called while holding the lock. It returns the pending updates in the order that
+// they first arrived, and clears them.
+func (c *CoalescingEnvStreamUpdates) takePending() []deltaItemUpdate {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	ret := c.pending
+	c.pending = nil
+	c.indexes = make(map[ldstoretypes.DataKind]map[string]int)
+	return ret
+}
@@ -0,0 +1,99 @@
+package streams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// channelEnvStreamUpdates reports single-item updates on a channel, since CoalescingEnvStreamUpdates
+// sends them from another goroutine.
+type channelEnvStreamUpdates struct {
+	recordingEnvStreamUpdates
+	itemCh chan sharedtest.ReceivedItemUpdate
+}
+
+func (c *channelEnvStreamUpdates) SendSingleItemUpdate(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.ItemDescriptor,
+) {
+	c.itemCh <- sharedtest.ReceivedItemUpdate{Kind: kind, Key: key, Item: item}
+}
+
+func TestCoalescingUpdatesSendsOnlyLatestVersionOfEachItem(t *testing.T) {
+	target := &recordingEnvStreamUpdates{}
+	c := NewCoalescingEnvStreamUpdates(target, time.Hour)
+	defer c.Close()
+
+	flag1v2 := ldbuilders.NewFlagBuilder(testFlag1.Key).Version(2).Build()
+	flag1v3 := ldbuilders.NewFlagBuilder(testFlag1.Key).Version(3).Build()
+	c.SendSingleItemUpdate(ldstoreimpl.Features(), testFlag1.Key, sharedtest.FlagDesc(flag1v2))
+	c.SendSingleItemUpdate(ldstoreimpl.Segments(), testSegment1.Key, sharedtest.SegmentDesc(testSegment1))
+	c.SendSingleItemUpdate(ldstoreimpl.Features(), testFlag1.Key, sharedtest.FlagDesc(flag1v3))
+	c.SendSingleItemUpdate(ldstoreimpl.Features(), testFlag1.Key, sharedtest.FlagDesc(testFlag1)) // older version is ignored
+	assert.Len(t, target.itemUpdates, 0)
+
+	c.flush()
+	assert.Equal(t, []sharedtest.ReceivedItemUpdate{
+		{Kind: ldstoreimpl.Features(), Key: testFlag1.Key, Item: sharedtest.FlagDesc(flag1v3)},
+		{Kind: ldstoreimpl.Segments(), Key: testSegment1.Key, Item: sharedtest.SegmentDesc(testSegment1)},
+	}, target.itemUpdates)
+}
+
+func TestCoalescingUpdatesSendsPendingUpdatesAfterWindow(t *testing.T) {
+	target := &channelEnvStreamUpdates{itemCh: make(chan sharedtest.ReceivedItemUpdate, 10)}
+	c := NewCoalescingEnvStreamUpdates(target, time.Millisecond*50)
+	defer c.Close()
+
+	c.SendSingleItemUpdate(ldstoreimpl.Features(), testFlag1.Key, sharedtest.FlagDesc(testFlag1))
+	c.SendSingleItemUpdate(ldstoreimpl.Features(), testFlag2.Key, sharedtest.FlagDesc(testFlag2))
+	for _, expected := range []string{testFlag1.Key, testFlag2.Key} {
+		select {
+		case u := <-target.itemCh:
+			assert.Equal(t, expected, u.Key)
+		case <-time.After(time.Second * 5):
+			require.Fail(t, "timed out waiting for update")
+		}
+	}
+}
+
+func TestCoalescingUpdatesDiscardsPendingUpdatesForFullDataUpdate(t *testing.T) {
+	target := &recordingEnvStreamUpdates{}
+	c := NewCoalescingEnvStreamUpdates(target, time.Hour)
+	defer c.Close()
+
+	c.SendSingleItemUpdate(ldstoreimpl.Features(), testFlag1.Key, sharedtest.FlagDesc(testFlag1))
+	c.SendAllDataUpdate(allData)
+	c.flush()
+	assert.Equal(t, [][]ldstoretypes.Collection{allData}, target.allDataUpdates)
+	assert.Len(t, target.itemUpdates, 0)
+}
+
+func TestCoalescingUpdatesPassesClientSideInvalidationThrough(t *testing.T) {
+	target := &recordingEnvStreamUpdates{}
+	c := NewCoalescingEnvStreamUpdates(target, time.Hour)
+	defer c.Close()
+
+	c.InvalidateClientSideState()
+	assert.Equal(t, 1, target.clientSideUps)
+}
+
+func TestCoalescingUpdatesDiscardsUpdatesAfterClose(t *testing.T) {
+	target := &recordingEnvStreamUpdates{}
+	c := NewCoalescingEnvStreamUpdates(target, time.Hour)
+
+	c.SendSingleItemUpdate(ldstoreimpl.Features(), testFlag1.Key, sharedtest.FlagDesc(testFlag1))
+	c.Close()
+	c.SendSingleItemUpdate(ldstoreimpl.Features(), testFlag2.Key, sharedtest.FlagDesc(testFlag2))
+	c.flush()
+	assert.Len(t, target.itemUpdates, 0)
+}
@@ -0,0 +1,122 @@
+package streams
+
+import (
+	"sync"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+)
+
+// deltaEnvStreamUpdates is an EnvStreamUpdates decorator that turns full data updates into the
+// smallest equivalent set of single-item updates, after the first one.
+//
+// This is used when Relay's upstream is another Relay instance rather than LaunchDarkly. Whenever
+// the upstream connection is restarted, the SDK receives a new "put" event with all of the data,
+// which usually is almost the same as what it already had; rebroadcasting that to every connected
+// SDK would be wasteful, especially since a chain of Relay instances would multiply the effect.
+//
+// Only version numbers are compared, since a flag or segment with the same version number always
+// has the same properties. An item that is missing from the new data is treated as deleted, with a
+// version number one higher than the last one we saw.
+type deltaEnvStreamUpdates struct {
+	target      EnvStreamUpdates
+	versions    map[ldstoretypes.DataKind]map[string]deltaItemState
+	initialized bool
+	lock        sync.Mutex
+}
+
+type deltaItemState struct {
+	version int
+	deleted bool
+}
+
+type deltaItemUpdate struct {
+	kind ldstoretypes.DataKind
+	key  string
+	item ldstoretypes.ItemDescriptor
+}
+
+// NewDeltaEnvStreamUpdates creates an EnvStreamUpdates that passes updates on to target, but
+// replaces every full data update after the first one with only the items that have changed.
+func NewDeltaEnvStreamUpdates(target EnvStreamUpdates) EnvStreamUpdates {
+	return &deltaEnvStreamUpdates{
+		target:   target,
+		versions: make(map[ldstoretypes.DataKind]map[string]deltaItemState),
+	}
+}
+
+func (d *deltaEnvStreamUpdates) SendAllDataUpdate(allData []ldstoretypes.Collection) {
+	d.lock.Lock()
+	if !d.initialized {
+		d.initialized = true
+		for _, coll := range allData {
+			for _, item := range coll.Items {
+				d.setItemState(coll.Kind, item.Key, item.Item)
+			}
+		}
+		d.lock.Unlock()
+		d.target.SendAllDataUpdate(allData)
+		return
+	}
+	changes := d.computeChanges(allData)
+	d.lock.Unlock()
+
+	for _, c := range changes {
+		d.target.SendSingleItemUpdate(c.kind, c.key, c.item)
+	}
+}
+
+func (d *deltaEnvStreamUpdates) SendSingleItemUpdate(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.ItemDescriptor,
+) {
+	d.lock.Lock()
+	d.setItemState(kind, key, item)
+	d.lock.Unlock()
+	d.target.SendSingleItemUpdate(kind, key, item)
+}
+
+func (d *deltaEnvStreamUpdates) InvalidateClientSideState() {
+	d.target.InvalidateClientSideState()
+}
+
+// computeChanges must be called while holding the lock. It updates the known item versions to
+// match allData, and returns the single-item updates that would produce the same state.
+func (d *deltaEnvStreamUpdates) computeChanges(allData []ldstoretypes.Collection) []deltaItemUpdate {
+	var changes []deltaItemUpdate
+	for _, coll := range allData {
+		previous := d.versions[coll.Kind]
+		seen := make(map[string]struct{}, len(coll.Items))
+		for _, item := range coll.Items {
+			seen[item.Key] = struct{}{}
+			state, found := previous[item.Key]
+			if found && state.version == item.Item.Version && state.deleted == (item.Item.Item == nil) {
+				continue
+			}
+			changes = append(changes, deltaItemUpdate{kind: coll.Kind, key: item.Key, item: item.Item})
+		}
+		for key, state := range previous {
+			if _, ok := seen[key]; ok || state.deleted {
+				continue
+			}
+			changes = append(changes, deltaItemUpdate{
+				kind: coll.Kind,
+				key:  key,
+				item: ldstoretypes.ItemDescriptor{Version: state.version + 1, Item: nil},
+			})
+		}
+	}
+	for _, c := range changes {
+		d.setItemState(c.kind, c.key, c.item)
+	}
+	return changes
+}
+
+func (d *deltaEnvStreamUpdates) setItemState(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
+	items := d.versions[kind]
+	if items == nil {
+		items = make(map[string]deltaItemState)
+		d.versions[kind] = items
+	}
+	items[key] = deltaItemState{version: item.Version, deleted: item.Item == nil}
+}
@@ -0,0 +1,126 @@
+package streams
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingEnvStreamUpdates struct {
+	allDataUpdates [][]ldstoretypes.Collection
+	itemUpdates    []sharedtest.ReceivedItemUpdate
+	clientSideUps  int
+}
+
+func (r *recordingEnvStreamUpdates) SendAllDataUpdate(allData []ldstoretypes.Collection) {
+	r.allDataUpdates = append(r.allDataUpdates, allData)
+}
+
+func (r *recordingEnvStreamUpdates) SendSingleItemUpdate(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.ItemDescriptor,
+) {
+	r.itemUpdates = append(r.itemUpdates, sharedtest.ReceivedItemUpdate{Kind: kind, Key: key, Item: item})
+}
+
+func (r *recordingEnvStreamUpdates) InvalidateClientSideState() {
+	r.clientSideUps++
+}
+
+func makeDeltaTestData(flags ...ldstoretypes.KeyedItemDescriptor) []ldstoretypes.Collection {
+	return []ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: flags},
+		{Kind: ldstoreimpl.Segments(), Items: []ldstoretypes.KeyedItemDescriptor{
+			{Key: testSegment1.Key, Item: sharedtest.SegmentDesc(testSegment1)},
+		}},
+	}
+}
+
+func TestDeltaUpdatesPassesFirstAllDataUpdateThrough(t *testing.T) {
+	target := &recordingEnvStreamUpdates{}
+	d := NewDeltaEnvStreamUpdates(target)
+
+	d.SendAllDataUpdate(allData)
+	assert.Equal(t, [][]ldstoretypes.Collection{allData}, target.allDataUpdates)
+	assert.Len(t, target.itemUpdates, 0)
+}
+
+func TestDeltaUpdatesSendsNothingForUnchangedData(t *testing.T) {
+	target := &recordingEnvStreamUpdates{}
+	d := NewDeltaEnvStreamUpdates(target)
+
+	d.SendAllDataUpdate(allData)
+	d.SendAllDataUpdate(allData)
+	assert.Len(t, target.allDataUpdates, 1)
+	assert.Len(t, target.itemUpdates, 0)
+}
+
+func TestDeltaUpdatesSendsOnlyChangedItems(t *testing.T) {
+	target := &recordingEnvStreamUpdates{}
+	d := NewDeltaEnvStreamUpdates(target)
+
+	flag1v2 := ldbuilders.NewFlagBuilder(testFlag1.Key).Version(2).Build()
+	d.SendAllDataUpdate(makeDeltaTestData(
+		ldstoretypes.KeyedItemDescriptor{Key: testFlag1.Key, Item: sharedtest.FlagDesc(testFlag1)},
+	))
+	d.SendAllDataUpdate(makeDeltaTestData(
+		ldstoretypes.KeyedItemDescriptor{Key: testFlag1.Key, Item: sharedtest.FlagDesc(flag1v2)},
+		ldstoretypes.KeyedItemDescriptor{Key: testFlag2.Key, Item: sharedtest.FlagDesc(testFlag2)},
+	))
+
+	assert.Len(t, target.allDataUpdates, 1)
+	assert.ElementsMatch(t, []sharedtest.ReceivedItemUpdate{
+		{Kind: ldstoreimpl.Features(), Key: testFlag1.Key, Item: sharedtest.FlagDesc(flag1v2)},
+		{Kind: ldstoreimpl.Features(), Key: testFlag2.Key, Item: sharedtest.FlagDesc(testFlag2)},
+	}, target.itemUpdates)
+}
+
+func TestDeltaUpdatesDeletesMissingItems(t *testing.T) {
+	target := &recordingEnvStreamUpdates{}
+	d := NewDeltaEnvStreamUpdates(target)
+
+	d.SendAllDataUpdate(makeDeltaTestData(
+		ldstoretypes.KeyedItemDescriptor{Key: testFlag1.Key, Item: sharedtest.FlagDesc(testFlag1)},
+		ldstoretypes.KeyedItemDescriptor{Key: testFlag2.Key, Item: sharedtest.FlagDesc(testFlag2)},
+	))
+	d.SendAllDataUpdate(makeDeltaTestData(
+		ldstoretypes.KeyedItemDescriptor{Key: testFlag1.Key, Item: sharedtest.FlagDesc(testFlag1)},
+	))
+	assert.Equal(t, []sharedtest.ReceivedItemUpdate{
+		{Kind: ldstoreimpl.Features(), Key: testFlag2.Key,
+			Item: ldstoretypes.ItemDescriptor{Version: testFlag2.Version + 1, Item: nil}},
+	}, target.itemUpdates)
+
+	// The deleted item is not deleted again
+	d.SendAllDataUpdate(makeDeltaTestData(
+		ldstoretypes.KeyedItemDescriptor{Key: testFlag1.Key, Item: sharedtest.FlagDesc(testFlag1)},
+	))
+	assert.Len(t, target.itemUpdates, 1)
+}
+
+func TestDeltaUpdatesTracksSingleItemUpdates(t *testing.T) {
+	target := &recordingEnvStreamUpdates{}
+	d := NewDeltaEnvStreamUpdates(target)
+
+	flag1v2 := ldbuilders.NewFlagBuilder(testFlag1.Key).Version(2).Build()
+	d.SendAllDataUpdate(makeDeltaTestData(
+		ldstoretypes.KeyedItemDescriptor{Key: testFlag1.Key, Item: sharedtest.FlagDesc(testFlag1)},
+	))
+	d.SendSingleItemUpdate(ldstoreimpl.Features(), testFlag1.Key, sharedtest.FlagDesc(flag1v2))
+	assert.Len(t, target.itemUpdates, 1)
+
+	d.SendAllDataUpdate(makeDeltaTestData(
+		ldstoretypes.KeyedItemDescriptor{Key: testFlag1.Key, Item: sharedtest.FlagDesc(flag1v2)},
+	))
+	assert.Len(t, target.itemUpdates, 1)
+
+	d.InvalidateClientSideState()
+	assert.Equal(t, 1, target.clientSideUps)
+}
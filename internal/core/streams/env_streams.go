@@ -33,6 +33,12 @@ type EnvStreamUpdates interface {
 	InvalidateClientSideState()
 }
 
+// userStateInvalidator is an optional interface for an EnvStreamProvider whose streams can be opened for
+// a specific user (see WithUserHashKey).
+type userStateInvalidator interface {
+	InvalidateClientSideStateForUsers(userHashKeys []string)
+}
+
 // EnvStreams encapsulates streaming behavior for a specific environment.
 //
 // EnvStreams itself does not know anything about what kind of streams are available; those are
@@ -161,6 +167,20 @@ func (es *EnvStreams) InvalidateClientSideState() {
 	}
 }
 
+// InvalidateClientSideStateForUsers is like InvalidateClientSideState, but only for the specified users,
+// as identified by their big segment user hash keys. This is used when big segment memberships change.
+// Client-side streams that were opened for other users are not notified; all others are notified as
+// they would be for InvalidateClientSideState.
+func (es *EnvStreams) InvalidateClientSideStateForUsers(userHashKeys []string) {
+	for _, esp := range es.getEnvStreamProviders() {
+		if inv, ok := esp.(userStateInvalidator); ok {
+			inv.InvalidateClientSideStateForUsers(userHashKeys)
+		} else {
+			esp.InvalidateClientSideState()
+		}
+	}
+}
+
 // Close shuts down all currently active streams for this environment and releases its resources.
 func (es *EnvStreams) Close() error {
 	close(es.closeCh)
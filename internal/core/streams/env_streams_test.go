@@ -238,6 +238,21 @@ func TestInvalidateClientSideStateGoesToAllStreams(t *testing.T) {
 	assert.Equal(t, 1, esp3.clientSideUps)
 }
 
+func TestInvalidateClientSideStateForUsersFallsBackToInvalidatingAllUsers(t *testing.T) {
+	sp := &mockStreamProvider{credentialOfDesiredType: config.SDKKey("")}
+
+	store := makeMockStore(nil, nil)
+	es := NewEnvStreams([]StreamProvider{sp}, store, 0, ldlog.NewDisabledLoggers())
+	defer es.Close()
+
+	es.AddCredential(config.SDKKey("sdk-key1"))
+	require.Len(t, sp.createdStreams, 1)
+
+	es.InvalidateClientSideStateForUsers([]string{"user1"})
+
+	assert.Equal(t, 1, sp.createdStreams[0].clientSideUps)
+}
+
 func TestHeartbeatsGoToAllStreams(t *testing.T) {
 	heartbeatInterval := time.Millisecond * 20
 
@@ -12,6 +12,10 @@ import (
 	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
 )
 
+// streamClosingComment is sent as an SSE comment to streaming clients right before Relay closes their
+// connections, as it does when shutting down or when an environment is removed.
+const streamClosingComment = "stream closing; please reconnect"
+
 // StreamProvider is an abstraction of a specific kind of SSE event stream, such as the server-side SDK
 // "/all" stream. The streams package provides default implementations of this interface for the streams
 // that are supported by the standard Relay Proxy.
@@ -78,6 +82,19 @@ func newSSEServer(maxConnTime time.Duration) *eventsource.Server {
 	return s
 }
 
+// closeSSEChannels disconnects all clients of the specified channels. Before disconnecting them, it sends
+// a comment telling them that the stream is closing, so that anyone watching the stream can tell this
+// apart from a network problem; the SDKs will reconnect as they would after any other disconnection.
+func closeSSEChannels(server *eventsource.Server, channels []string) {
+	server.PublishComment(channels, streamClosingComment)
+	// Each Server method is handled asynchronously, so without this there would be no guarantee that the
+	// comment is published before the subscriptions are closed.
+	<-server.PublishWithAcknowledgment(nil, nil)
+	for _, key := range channels {
+		server.Unregister(key, true)
+	}
+}
+
 func removeDeleted(items []ldstoretypes.KeyedItemDescriptor) []ldstoretypes.KeyedItemDescriptor {
 	var ret []ldstoretypes.KeyedItemDescriptor
 	for i, keyedItem := range items {
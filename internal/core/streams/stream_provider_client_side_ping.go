@@ -1,6 +1,7 @@
 package streams
 
 import (
+	"context"
 	"net/http"
 	"sync"
 
@@ -14,18 +15,33 @@ import (
 // This is the standard implementation of a stream for client-side/mobile SDKs that sends only "ping" events,
 // and does not do flag evaluations for specific users. The behavior of this stream is that it sends one "ping"
 // event on initial connection, and another "ping" every time there is a data update of any kind.
+//
+// If the request context has a user hash key (see WithUserHashKey), the stream is subscribed to a channel
+// for that user, which receives everything that the environment's channel does, and also the "ping" events
+// from InvalidateClientSideStateForUsers for that user. This lets us notify only the users whose big
+// segment memberships have changed.
 
 type clientSidePingStreamProvider struct {
 	server     *eventsource.Server
 	isJSClient bool
+	envStreams map[string]*clientSidePingEnvStreamProvider
+	lock       sync.Mutex
 	closeOnce  sync.Once
 }
 
 type clientSidePingEnvStreamProvider struct {
-	server   *eventsource.Server
-	channels []string
+	server       *eventsource.Server
+	channels     []string
+	repo         *clientSidePingEnvStreamRepository
+	userChannels map[string]int // number of connected streams for each user's channel
+	onClose      func()
+	lock         sync.Mutex
 }
 
+type userHashKeyContextKeyType struct{}
+
+var userHashKeyContextKey userHashKeyContextKeyType //nolint:gochecknoglobals
+
 type clientSidePingEnvStreamRepository struct {
 	store EnvStoreQueries
 }
@@ -45,7 +61,19 @@ func (s *clientSidePingStreamProvider) validateCredential(credential config.SDKC
 
 func (s *clientSidePingStreamProvider) Handler(credential config.SDKCredential) http.HandlerFunc {
 	if key := s.validateCredential(credential); key != "" {
-		return s.server.Handler(key)
+		envHandler := s.server.Handler(key)
+		return func(w http.ResponseWriter, req *http.Request) {
+			if userHashKey, ok := req.Context().Value(userHashKeyContextKey).(string); ok {
+				s.lock.Lock()
+				envStream := s.envStreams[key]
+				s.lock.Unlock()
+				if envStream != nil {
+					envStream.serveUserStream(w, req, userHashKey)
+					return
+				}
+			}
+			envHandler(w, req)
+		}
 	}
 	return nil
 }
@@ -58,7 +86,25 @@ func (s *clientSidePingStreamProvider) Register(
 	if key := s.validateCredential(credential); key != "" {
 		repo := &clientSidePingEnvStreamRepository{store: store}
 		s.server.Register(key, repo)
-		envStream := &clientSidePingEnvStreamProvider{server: s.server, channels: []string{key}}
+		envStream := &clientSidePingEnvStreamProvider{
+			server:       s.server,
+			channels:     []string{key},
+			repo:         repo,
+			userChannels: make(map[string]int),
+		}
+		envStream.onClose = func() {
+			s.lock.Lock()
+			if s.envStreams[key] == envStream {
+				delete(s.envStreams, key)
+			}
+			s.lock.Unlock()
+		}
+		s.lock.Lock()
+		if s.envStreams == nil {
+			s.envStreams = make(map[string]*clientSidePingEnvStreamProvider)
+		}
+		s.envStreams[key] = envStream
+		s.lock.Unlock()
 		return envStream
 	}
 	return nil
@@ -71,25 +117,79 @@ func (s *clientSidePingStreamProvider) Close() {
 }
 
 func (e *clientSidePingEnvStreamProvider) SendAllDataUpdate(allData []ldstoretypes.Collection) {
-	e.server.Publish(e.channels, MakePingEvent())
+	e.server.Publish(e.allChannels(), MakePingEvent())
 }
 
 func (e *clientSidePingEnvStreamProvider) SendSingleItemUpdate(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
-	e.server.Publish(e.channels, MakePingEvent())
+	e.server.Publish(e.allChannels(), MakePingEvent())
 }
 
 func (e *clientSidePingEnvStreamProvider) InvalidateClientSideState() {
-	e.server.Publish(e.channels, MakePingEvent())
+	e.server.Publish(e.allChannels(), MakePingEvent())
+}
+
+// InvalidateClientSideStateForUsers sends a "ping" event to the streams that were opened for the specified
+// users. Streams that were opened without a user also receive it, since they could be for any user.
+func (e *clientSidePingEnvStreamProvider) InvalidateClientSideStateForUsers(userHashKeys []string) {
+	channels := append([]string(nil), e.channels...)
+	e.lock.Lock()
+	for _, userHashKey := range userHashKeys {
+		if channel := e.userChannel(userHashKey); e.userChannels[channel] > 0 {
+			channels = append(channels, channel)
+		}
+	}
+	e.lock.Unlock()
+	e.server.Publish(channels, MakePingEvent())
 }
 
 func (e *clientSidePingEnvStreamProvider) SendHeartbeat() {
-	e.server.PublishComment(e.channels, "")
+	e.server.PublishComment(e.allChannels(), "")
 }
 
 func (e *clientSidePingEnvStreamProvider) Close() {
-	for _, key := range e.channels {
-		e.server.Unregister(key, true)
+	e.onClose()
+	closeSSEChannels(e.server, e.allChannels())
+}
+
+func (e *clientSidePingEnvStreamProvider) serveUserStream(w http.ResponseWriter, req *http.Request, userHashKey string) {
+	channel := e.userChannel(userHashKey)
+	e.lock.Lock()
+	if e.userChannels[channel] == 0 {
+		e.server.Register(channel, e.repo) // so that the stream gets the initial "ping" event
+	}
+	e.userChannels[channel]++
+	e.lock.Unlock()
+
+	defer func() {
+		e.lock.Lock()
+		e.userChannels[channel]--
+		if e.userChannels[channel] == 0 {
+			delete(e.userChannels, channel)
+			e.server.Unregister(channel, false)
+		}
+		e.lock.Unlock()
+	}()
+	e.server.Handler(channel)(w, req)
+}
+
+// userChannel returns the eventsource channel name for a user's streams. The environment's own channel
+// name is a credential, which cannot contain a space, so this cannot be the same as any of those.
+func (e *clientSidePingEnvStreamProvider) userChannel(userHashKey string) string {
+	return e.channels[0] + " " + userHashKey
+}
+
+func (e *clientSidePingEnvStreamProvider) allChannels() []string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if len(e.userChannels) == 0 {
+		return e.channels
+	}
+	ret := make([]string, 0, len(e.channels)+len(e.userChannels))
+	ret = append(ret, e.channels...)
+	for channel := range e.userChannels {
+		ret = append(ret, channel)
 	}
+	return ret
 }
 
 func (r *clientSidePingEnvStreamRepository) Replay(channel, id string) chan eventsource.Event {
@@ -100,3 +200,10 @@ func (r *clientSidePingEnvStreamRepository) Replay(channel, id string) chan even
 	close(out)
 	return out
 }
+
+// WithUserHashKey returns a copy of the context for a client-side stream request that identifies the user
+// that the stream is for, by the same hash key that is used in big segment stores. This allows
+// EnvStreams.InvalidateClientSideStateForUsers to notify that stream.
+func WithUserHashKey(ctx context.Context, userHashKey string) context.Context {
+	return context.WithValue(ctx, userHashKeyContextKey, userHashKey)
+}
@@ -1,12 +1,14 @@
 package streams
 
 import (
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/launchdarkly/ld-relay/v6/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
 
+	"github.com/launchdarkly/eventsource"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
 	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
@@ -200,4 +202,89 @@ func TestStreamProviderAllClientSidePing(t *testing.T) {
 			verifyHandlerHeartbeat(t, sp, esp, validCredential)
 		})
 	})
+
+	t.Run("Close", func(t *testing.T) {
+		store := makeMockStore(nil, nil)
+
+		withStreamProvider(t, 0, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+
+			verifyHandlerClose(t, sp, esp, validCredential)
+		})
+	})
+
+	withUserStream := func(t *testing.T, sp StreamProvider, userHashKey string, action func(<-chan eventsource.Event)) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			sp.Handler(validCredential)(w, req.WithContext(WithUserHashKey(req.Context(), userHashKey)))
+		})
+		req, _ := http.NewRequest("GET", "", nil)
+		sharedtest.WithStreamRequest(t, req, handler, func(eventCh <-chan eventsource.Event) {
+			initEvent := sharedtest.ExpectStreamChEvent(t, eventCh, time.Second)
+			assert.Equal(t, "ping", initEvent.Event())
+			action(eventCh)
+		})
+	}
+
+	t.Run("user stream receives updates for the environment", func(t *testing.T) {
+		store := makeMockStore(nil, nil)
+
+		withStreamProvider(t, 0, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			withUserStream(t, sp, "user1", func(eventCh <-chan eventsource.Event) {
+				esp.InvalidateClientSideState()
+				assert.Equal(t, "ping", sharedtest.ExpectStreamChEvent(t, eventCh, time.Second).Event())
+
+				esp.SendSingleItemUpdate(ldstoreimpl.Features(), testFlag1.Key, sharedtest.FlagDesc(testFlag1))
+				assert.Equal(t, "ping", sharedtest.ExpectStreamChEvent(t, eventCh, time.Second).Event())
+			})
+		})
+	})
+
+	t.Run("InvalidateClientSideStateForUsers", func(t *testing.T) {
+		store := makeMockStore(nil, nil)
+
+		withStreamProvider(t, 0, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+			inv := esp.(userStateInvalidator)
+
+			withUserStream(t, sp, "user1", func(eventCh <-chan eventsource.Event) {
+				inv.InvalidateClientSideStateForUsers([]string{"user2"})
+				sharedtest.ExpectNoStreamChEvent(t, eventCh, time.Millisecond*50)
+
+				inv.InvalidateClientSideStateForUsers([]string{"user2", "user1"})
+				assert.Equal(t, "ping", sharedtest.ExpectStreamChEvent(t, eventCh, time.Second).Event())
+			})
+
+			// a stream without a user could be for any user
+			verifyHandlerUpdateEvent(t, sp, validCredential, MakePingEvent(),
+				func() {
+					inv.InvalidateClientSideStateForUsers([]string{"user2"})
+				},
+				MakePingEvent(),
+			)
+		})
+	})
+
+	t.Run("user channel is released when stream closes", func(t *testing.T) {
+		store := makeMockStore(nil, nil)
+
+		withStreamProvider(t, 0, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+			defer esp.Close()
+
+			withUserStream(t, sp, "user1", func(eventCh <-chan eventsource.Event) {
+				assert.Len(t, esp.(*clientSidePingEnvStreamProvider).allChannels(), 2)
+			})
+			assert.Eventually(t, func() bool {
+				return len(esp.(*clientSidePingEnvStreamProvider).allChannels()) == 1
+			}, time.Second, time.Millisecond*10)
+		})
+	})
 }
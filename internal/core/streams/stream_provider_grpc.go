@@ -0,0 +1,366 @@
+package streams
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/streams/streampb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gopkg.in/launchdarkly/go-jsonstream.v1/jwriter"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+// This is the implementation of the gRPC FlagStream service, which provides the same data as the
+// server-side /all stream.
+
+const (
+	// grpcAuthorizationMetadataKey is the request metadata key for the SDK key. gRPC metadata keys
+	// are always lowercase.
+	grpcAuthorizationMetadataKey = "authorization"
+
+	// grpcSubscriberBufferSize is the number of events that can be queued for a subscriber that is
+	// not reading them fast enough. If the queue fills up, the queued events are discarded and the
+	// subscriber gets a new "put" event instead, so a slow client cannot hold up the others.
+	grpcSubscriberBufferSize = 100
+
+	grpcPongBufferSize = 10
+)
+
+var (
+	errGRPCUnknownSDKKey = status.Error(codes.Unauthenticated, "SDK key is missing or not recognized") //nolint:gochecknoglobals
+	errGRPCEnvClosed     = status.Error(codes.Unavailable, "environment is no longer available")       //nolint:gochecknoglobals
+	errGRPCServerClosed  = status.Error(codes.Unavailable, "server is shutting down")                  //nolint:gochecknoglobals
+)
+
+// GRPCAdmission decides whether a new Subscribe call can go ahead, once its SDK key has been recognized.
+// This is where the limits that the HTTP streams get from middleware are applied. If it returns an error,
+// which should be a gRPC status error, the call ends with that error; otherwise, release is called when
+// the call ends.
+type GRPCAdmission func(ctx context.Context, sdkKey config.SDKKey) (release func(), err error)
+
+// GRPCStreamProvider is the StreamProvider for Relay's gRPC streaming protocol, which is defined in
+// the streampb package. Unlike the other StreamProviders, it does not provide HTTP handlers; instead,
+// call RegisterService to add it to a gRPC server.
+type GRPCStreamProvider struct {
+	streampb.UnimplementedFlagStreamServer
+	maxConnTime time.Duration
+	admission   GRPCAdmission
+	envs        map[config.SDKKey]*grpcEnvStreamProvider
+	lock        sync.RWMutex
+	closeCh     chan struct{}
+	closeOnce   sync.Once
+}
+
+type grpcEnvStreamProvider struct {
+	owner       *GRPCStreamProvider
+	sdkKey      config.SDKKey
+	store       EnvStoreQueries
+	loggers     ldlog.Loggers
+	subscribers map[*grpcSubscriber]struct{}
+	lock        sync.Mutex
+}
+
+type grpcSubscriber struct {
+	eventsCh  chan *streampb.StreamEvent
+	pongsCh   chan uint64
+	resyncCh  chan struct{}
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewGRPCStreamProvider creates a GRPCStreamProvider. If maxConnTime is nonzero, each Subscribe call
+// is ended after that amount of time, so that the client will reconnect. If admission is not nil, it is
+// called for each Subscribe call.
+func NewGRPCStreamProvider(maxConnTime time.Duration, admission GRPCAdmission) *GRPCStreamProvider {
+	return &GRPCStreamProvider{
+		maxConnTime: maxConnTime,
+		admission:   admission,
+		envs:        make(map[config.SDKKey]*grpcEnvStreamProvider),
+		closeCh:     make(chan struct{}),
+	}
+}
+
+// RegisterService adds the FlagStream service to a gRPC server.
+func (s *GRPCStreamProvider) RegisterService(registrar grpc.ServiceRegistrar) {
+	streampb.RegisterFlagStreamServer(registrar, s)
+}
+
+// Handler always returns nil, because the gRPC service is not served by Relay's HTTP router.
+func (s *GRPCStreamProvider) Handler(credential config.SDKCredential) http.HandlerFunc {
+	return nil
+}
+
+func (s *GRPCStreamProvider) Register(
+	credential config.SDKCredential,
+	store EnvStoreQueries,
+	loggers ldlog.Loggers,
+) EnvStreamProvider {
+	key, ok := credential.(config.SDKKey)
+	if !ok {
+		return nil
+	}
+	envStream := &grpcEnvStreamProvider{
+		owner:       s,
+		sdkKey:      key,
+		store:       store,
+		loggers:     loggers,
+		subscribers: make(map[*grpcSubscriber]struct{}),
+	}
+	s.lock.Lock()
+	previous := s.envs[key]
+	s.envs[key] = envStream
+	s.lock.Unlock()
+	if previous != nil {
+		previous.closeSubscribers()
+	}
+	return envStream
+}
+
+func (s *GRPCStreamProvider) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+	})
+}
+
+// Subscribe implements the FlagStream service.
+func (s *GRPCStreamProvider) Subscribe(stream streampb.FlagStream_SubscribeServer) error {
+	sdkKey := sdkKeyFromMetadata(stream)
+	env := s.getEnv(sdkKey)
+	if env == nil {
+		return errGRPCUnknownSDKKey
+	}
+	if s.admission != nil {
+		release, err := s.admission(stream.Context(), sdkKey)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	sub := env.addSubscriber()
+	defer env.removeSubscriber(sub)
+
+	go sub.readRequests(stream)
+
+	var maxConnTimer <-chan time.Time
+	if s.maxConnTime > 0 {
+		timer := time.NewTimer(s.maxConnTime)
+		defer timer.Stop()
+		maxConnTimer = timer.C
+	}
+
+	// If the data store has never been populated, we don't send an initial event; see
+	// serverSideEnvStreamRepository.Replay.
+	if env.store.IsInitialized() {
+		sub.requestResync()
+	}
+
+	for {
+		var event *streampb.StreamEvent
+		select {
+		case event = <-sub.eventsCh:
+		case sequence := <-sub.pongsCh:
+			event = &streampb.StreamEvent{Event: &streampb.StreamEvent_Pong{Pong: &streampb.Pong{Sequence: sequence}}}
+		case <-sub.resyncCh:
+			sub.discardEvents()
+			allData, err := queryAllData(env.store, env.loggers)
+			if err != nil {
+				return status.Error(codes.Unavailable, "unable to read flag data")
+			}
+			event = makeGRPCPutEvent(allData)
+		case <-maxConnTimer:
+			return nil
+		case <-sub.closeCh:
+			return errGRPCEnvClosed
+		case <-s.closeCh:
+			return errGRPCServerClosed
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *GRPCStreamProvider) getEnv(key config.SDKKey) *grpcEnvStreamProvider {
+	if key == "" {
+		return nil
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.envs[key]
+}
+
+func sdkKeyFromMetadata(stream grpc.ServerStream) config.SDKKey {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if values := md.Get(grpcAuthorizationMetadataKey); len(values) > 0 {
+		return config.SDKKey(values[0])
+	}
+	return ""
+}
+
+func (e *grpcEnvStreamProvider) SendAllDataUpdate(allData []ldstoretypes.Collection) {
+	e.broadcast(makeGRPCPutEvent(allData))
+}
+
+func (e *grpcEnvStreamProvider) SendSingleItemUpdate(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.ItemDescriptor,
+) {
+	if item.Item == nil {
+		e.broadcast(&streampb.StreamEvent{Event: &streampb.StreamEvent_Delete{Delete: &streampb.DeleteEvent{
+			Kind:    grpcDataKind(kind),
+			Key:     key,
+			Version: int32(item.Version),
+		}}})
+	} else {
+		e.broadcast(&streampb.StreamEvent{Event: &streampb.StreamEvent_Patch{Patch: &streampb.PatchEvent{
+			Kind: grpcDataKind(kind),
+			Item: makeGRPCItem(kind, key, item),
+		}}})
+	}
+}
+
+func (e *grpcEnvStreamProvider) InvalidateClientSideState() {}
+
+func (e *grpcEnvStreamProvider) SendHeartbeat() {
+	e.broadcast(&streampb.StreamEvent{Event: &streampb.StreamEvent_Heartbeat{Heartbeat: &streampb.Heartbeat{}}})
+}
+
+func (e *grpcEnvStreamProvider) Close() {
+	e.owner.lock.Lock()
+	if e.owner.envs[e.sdkKey] == e {
+		delete(e.owner.envs, e.sdkKey)
+	}
+	e.owner.lock.Unlock()
+	e.closeSubscribers()
+}
+
+func (e *grpcEnvStreamProvider) addSubscriber() *grpcSubscriber {
+	sub := &grpcSubscriber{
+		eventsCh: make(chan *streampb.StreamEvent, grpcSubscriberBufferSize),
+		pongsCh:  make(chan uint64, grpcPongBufferSize),
+		resyncCh: make(chan struct{}, 1),
+		closeCh:  make(chan struct{}),
+	}
+	e.lock.Lock()
+	e.subscribers[sub] = struct{}{}
+	e.lock.Unlock()
+	return sub
+}
+
+func (e *grpcEnvStreamProvider) removeSubscriber(sub *grpcSubscriber) {
+	e.lock.Lock()
+	delete(e.subscribers, sub)
+	e.lock.Unlock()
+}
+
+func (e *grpcEnvStreamProvider) broadcast(event *streampb.StreamEvent) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for sub := range e.subscribers {
+		sub.offer(event)
+	}
+}
+
+func (e *grpcEnvStreamProvider) closeSubscribers() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for sub := range e.subscribers {
+		sub.close()
+	}
+}
+
+// offer queues an event for the subscriber without blocking. If the queue is full, the subscriber
+// will get a "put" event instead of the events that it missed.
+func (sub *grpcSubscriber) offer(event *streampb.StreamEvent) {
+	select {
+	case sub.eventsCh <- event:
+	default:
+		sub.requestResync()
+	}
+}
+
+func (sub *grpcSubscriber) requestResync() {
+	select {
+	case sub.resyncCh <- struct{}{}:
+	default: // a resync is already pending
+	}
+}
+
+func (sub *grpcSubscriber) discardEvents() {
+	for {
+		select {
+		case <-sub.eventsCh:
+		default:
+			return
+		}
+	}
+}
+
+// readRequests answers pings from the client until the client stops sending, or the call ends.
+func (sub *grpcSubscriber) readRequests(stream streampb.FlagStream_SubscribeServer) {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return
+		}
+		if ping := req.GetPing(); ping != nil {
+			select {
+			case sub.pongsCh <- ping.Sequence:
+			default: // the client is sending pings faster than we can answer them
+			}
+		}
+	}
+}
+
+func (sub *grpcSubscriber) close() {
+	sub.closeOnce.Do(func() {
+		close(sub.closeCh)
+	})
+}
+
+func makeGRPCPutEvent(allData []ldstoretypes.Collection) *streampb.StreamEvent {
+	put := &streampb.PutEvent{}
+	for _, coll := range allData {
+		items := make([]*streampb.Item, 0, len(coll.Items))
+		for _, item := range coll.Items {
+			items = append(items, makeGRPCItem(coll.Kind, item.Key, item.Item))
+		}
+		switch coll.Kind {
+		case ldstoreimpl.Features():
+			put.Flags = items
+		case ldstoreimpl.Segments():
+			put.Segments = items
+		}
+	}
+	return &streampb.StreamEvent{Event: &streampb.StreamEvent_Put{Put: put}}
+}
+
+func makeGRPCItem(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) *streampb.Item {
+	w := jwriter.NewWriter()
+	serializeItem(kind, item, &w)
+	return &streampb.Item{Key: key, Version: int32(item.Version), Data: w.Bytes()}
+}
+
+func grpcDataKind(kind ldstoretypes.DataKind) streampb.DataKind {
+	switch kind {
+	case ldstoreimpl.Features():
+		return streampb.DataKind_DATA_KIND_FLAG
+	case ldstoreimpl.Segments():
+		return streampb.DataKind_DATA_KIND_SEGMENT
+	default:
+		return streampb.DataKind_DATA_KIND_UNSPECIFIED
+	}
+}
@@ -0,0 +1,243 @@
+package streams
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/streams/streampb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const grpcTestTimeout = time.Second * 5
+
+func withGRPCStreamProvider(t *testing.T, maxConnTime time.Duration, action func(*GRPCStreamProvider, streampb.FlagStreamClient)) {
+	sp := NewGRPCStreamProvider(maxConnTime, nil)
+	defer sp.Close()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	sp.RegisterService(server)
+	go server.Serve(listener) //nolint:errcheck
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	action(sp, streampb.NewFlagStreamClient(conn))
+}
+
+func subscribeGRPC(t *testing.T, client streampb.FlagStreamClient, key config.SDKKey) streampb.FlagStream_SubscribeClient {
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTestTimeout)
+	t.Cleanup(cancel)
+	ctx = metadata.AppendToOutgoingContext(ctx, grpcAuthorizationMetadataKey, string(key))
+	stream, err := client.Subscribe(ctx)
+	require.NoError(t, err)
+	return stream
+}
+
+func requireGRPCEvent(t *testing.T, stream streampb.FlagStream_SubscribeClient) *streampb.StreamEvent {
+	event, err := stream.Recv()
+	require.NoError(t, err)
+	return event
+}
+
+func grpcTestItem(key string, version int, data []byte) *streampb.Item {
+	return &streampb.Item{Key: key, Version: int32(version), Data: data}
+}
+
+func assertGRPCItemsEqual(t *testing.T, expected, actual []*streampb.Item) {
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		assert.Equal(t, expected[i].Key, actual[i].Key)
+		assert.Equal(t, expected[i].Version, actual[i].Version)
+		assert.JSONEq(t, string(expected[i].Data), string(actual[i].Data))
+	}
+}
+
+func TestStreamProviderGRPC(t *testing.T) {
+	store := makeMockStore([]ldmodel.FeatureFlag{testFlag1, testFlag2}, []ldmodel.Segment{testSegment1})
+
+	t.Run("Handler", func(t *testing.T) {
+		sp := NewGRPCStreamProvider(0, nil)
+		defer sp.Close()
+		assert.Nil(t, sp.Handler(testSDKKey))
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		sp := NewGRPCStreamProvider(0, nil)
+		defer sp.Close()
+		assert.Nil(t, sp.Register(testMobileKey, store, ldlog.NewDisabledLoggers()))
+		assert.Nil(t, sp.Register(testEnvID, store, ldlog.NewDisabledLoggers()))
+
+		esp := sp.Register(testSDKKey, store, ldlog.NewDisabledLoggers())
+		require.NotNil(t, esp)
+		require.IsType(t, &grpcEnvStreamProvider{}, esp)
+		assert.NotNil(t, sp.getEnv(testSDKKey))
+
+		esp.Close()
+		assert.Nil(t, sp.getEnv(testSDKKey))
+	})
+
+	t.Run("unknown SDK key", func(t *testing.T) {
+		withGRPCStreamProvider(t, 0, func(sp *GRPCStreamProvider, client streampb.FlagStreamClient) {
+			esp := sp.Register(testSDKKey, store, ldlog.NewDisabledLoggers())
+			defer esp.Close()
+
+			stream := subscribeGRPC(t, client, "other-key")
+			_, err := stream.Recv()
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		})
+	})
+
+	t.Run("initial event", func(t *testing.T) {
+		withGRPCStreamProvider(t, 0, func(sp *GRPCStreamProvider, client streampb.FlagStreamClient) {
+			esp := sp.Register(testSDKKey, store, ldlog.NewDisabledLoggers())
+			defer esp.Close()
+
+			stream := subscribeGRPC(t, client, testSDKKey)
+			put := requireGRPCEvent(t, stream).GetPut()
+			require.NotNil(t, put)
+			assertGRPCItemsEqual(t, []*streampb.Item{
+				grpcTestItem(testFlag1.Key, testFlag1.Version, testFlag1JSON),
+				grpcTestItem(testFlag2.Key, testFlag2.Version, testFlag2JSON),
+			}, put.Flags)
+			assertGRPCItemsEqual(t, []*streampb.Item{
+				grpcTestItem(testSegment1.Key, testSegment1.Version, testSegment1JSON),
+			}, put.Segments)
+		})
+	})
+
+	t.Run("no initial event if store is not initialized", func(t *testing.T) {
+		uninitializedStore := newMockStoreQueries()
+		uninitializedStore.setupIsInitialized(false)
+		withGRPCStreamProvider(t, 0, func(sp *GRPCStreamProvider, client streampb.FlagStreamClient) {
+			esp := sp.Register(testSDKKey, uninitializedStore, ldlog.NewDisabledLoggers())
+			defer esp.Close()
+
+			stream := subscribeGRPC(t, client, testSDKKey)
+			require.NoError(t, stream.Send(&streampb.SubscribeRequest{
+				Request: &streampb.SubscribeRequest_Ping{Ping: &streampb.Ping{Sequence: 1}}}))
+			assert.NotNil(t, requireGRPCEvent(t, stream).GetPong()) // i.e. there was no put before this
+		})
+	})
+
+	t.Run("updates", func(t *testing.T) {
+		withGRPCStreamProvider(t, 0, func(sp *GRPCStreamProvider, client streampb.FlagStreamClient) {
+			esp := sp.Register(testSDKKey, store, ldlog.NewDisabledLoggers())
+			defer esp.Close()
+
+			stream := subscribeGRPC(t, client, testSDKKey)
+			require.NotNil(t, requireGRPCEvent(t, stream).GetPut())
+
+			esp.SendSingleItemUpdate(ldstoreimpl.Features(), testFlag1.Key, sharedtest.FlagDesc(testFlag1))
+			patch := requireGRPCEvent(t, stream).GetPatch()
+			require.NotNil(t, patch)
+			assert.Equal(t, streampb.DataKind_DATA_KIND_FLAG, patch.Kind)
+			assertGRPCItemsEqual(t, []*streampb.Item{
+				grpcTestItem(testFlag1.Key, testFlag1.Version, testFlag1JSON),
+			}, []*streampb.Item{patch.Item})
+
+			esp.SendSingleItemUpdate(ldstoreimpl.Segments(), testSegment1.Key,
+				ldstoretypes.ItemDescriptor{Version: 2, Item: nil})
+			assert.Equal(t, &streampb.DeleteEvent{Kind: streampb.DataKind_DATA_KIND_SEGMENT, Key: testSegment1.Key,
+				Version: 2}, unwrapDelete(requireGRPCEvent(t, stream)))
+
+			esp.SendAllDataUpdate(allData)
+			put := requireGRPCEvent(t, stream).GetPut()
+			require.NotNil(t, put)
+			assertGRPCItemsEqual(t, []*streampb.Item{
+				grpcTestItem(testFlag1.Key, testFlag1.Version, testFlag1JSON),
+			}, put.Flags)
+
+			esp.InvalidateClientSideState() // no effect
+			esp.SendHeartbeat()
+			assert.NotNil(t, requireGRPCEvent(t, stream).GetHeartbeat())
+		})
+	})
+
+	t.Run("ping", func(t *testing.T) {
+		withGRPCStreamProvider(t, 0, func(sp *GRPCStreamProvider, client streampb.FlagStreamClient) {
+			esp := sp.Register(testSDKKey, store, ldlog.NewDisabledLoggers())
+			defer esp.Close()
+
+			stream := subscribeGRPC(t, client, testSDKKey)
+			require.NotNil(t, requireGRPCEvent(t, stream).GetPut())
+
+			require.NoError(t, stream.Send(&streampb.SubscribeRequest{
+				Request: &streampb.SubscribeRequest_Ping{Ping: &streampb.Ping{Sequence: 42}}}))
+			pong := requireGRPCEvent(t, stream).GetPong()
+			require.NotNil(t, pong)
+			assert.Equal(t, uint64(42), pong.Sequence)
+		})
+	})
+
+	t.Run("environment closed", func(t *testing.T) {
+		withGRPCStreamProvider(t, 0, func(sp *GRPCStreamProvider, client streampb.FlagStreamClient) {
+			esp := sp.Register(testSDKKey, store, ldlog.NewDisabledLoggers())
+
+			stream := subscribeGRPC(t, client, testSDKKey)
+			require.NotNil(t, requireGRPCEvent(t, stream).GetPut())
+
+			esp.Close()
+			_, err := stream.Recv()
+			assert.Equal(t, codes.Unavailable, status.Code(err))
+		})
+	})
+
+	t.Run("max connection time", func(t *testing.T) {
+		withGRPCStreamProvider(t, time.Millisecond*50, func(sp *GRPCStreamProvider, client streampb.FlagStreamClient) {
+			esp := sp.Register(testSDKKey, store, ldlog.NewDisabledLoggers())
+			defer esp.Close()
+
+			stream := subscribeGRPC(t, client, testSDKKey)
+			require.NotNil(t, requireGRPCEvent(t, stream).GetPut())
+
+			_, err := stream.Recv()
+			assert.Equal(t, io.EOF, err) // the server ended the call normally
+		})
+	})
+}
+
+func TestGRPCSubscriberResyncsWhenQueueIsFull(t *testing.T) {
+	store := makeMockStore([]ldmodel.FeatureFlag{testFlag1}, nil)
+	esp := NewGRPCStreamProvider(0, nil).Register(testSDKKey, store, ldlog.NewDisabledLoggers()).(*grpcEnvStreamProvider)
+	sub := esp.addSubscriber()
+
+	for i := 0; i < grpcSubscriberBufferSize; i++ {
+		esp.SendHeartbeat()
+	}
+	assert.Len(t, sub.resyncCh, 0)
+
+	esp.SendHeartbeat()
+	assert.Len(t, sub.resyncCh, 1)
+
+	sub.discardEvents()
+	assert.Len(t, sub.eventsCh, 0)
+}
+
+func unwrapDelete(event *streampb.StreamEvent) *streampb.DeleteEvent {
+	d := event.GetDelete()
+	if d == nil {
+		return nil
+	}
+	return &streampb.DeleteEvent{Kind: d.Kind, Key: d.Key, Version: d.Version}
+}
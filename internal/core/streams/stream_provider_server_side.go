@@ -78,9 +78,7 @@ func (e *serverSideEnvStreamProvider) SendHeartbeat() {
 }
 
 func (e *serverSideEnvStreamProvider) Close() {
-	for _, key := range e.channels {
-		e.server.Unregister(key, true)
-	}
+	closeSSEChannels(e.server, e.channels)
 }
 
 func (r *serverSideEnvStreamRepository) Replay(channel, id string) chan eventsource.Event {
@@ -107,23 +105,11 @@ func (r *serverSideEnvStreamRepository) Replay(channel, id string) chan eventsou
 // getReplayEvent will return a ServerSidePutEvent with all the data needed for a Replay.
 func (r *serverSideEnvStreamRepository) getReplayEvent() (eventsource.Event, error) {
 	data, err, _ := r.flightGroup.Do("getReplayEvent", func() (interface{}, error) {
-		flags, err := r.store.GetAll(ldstoreimpl.Features())
-
-		if err != nil {
-			r.loggers.Errorf("Error getting all flags: %s\n", err.Error())
-			return nil, err
-		}
-		segments, err := r.store.GetAll(ldstoreimpl.Segments())
+		allData, err := queryAllData(r.store, r.loggers)
 		if err != nil {
-			r.loggers.Errorf("Error getting all segments: %s\n", err.Error())
 			return nil, err
 		}
 
-		allData := []ldstoretypes.Collection{
-			{Kind: ldstoreimpl.Features(), Items: removeDeleted(flags)},
-			{Kind: ldstoreimpl.Segments(), Items: removeDeleted(segments)},
-		}
-
 		event := MakeServerSidePutEvent(allData)
 		return event, nil
 	})
@@ -136,3 +122,21 @@ func (r *serverSideEnvStreamRepository) getReplayEvent() (eventsource.Event, err
 	event := data.(eventsource.Event)
 	return event, nil
 }
+
+// queryAllData gets all of the non-deleted flags and segments from the store, for a "put" event.
+func queryAllData(store EnvStoreQueries, loggers ldlog.Loggers) ([]ldstoretypes.Collection, error) {
+	flags, err := store.GetAll(ldstoreimpl.Features())
+	if err != nil {
+		loggers.Errorf("Error getting all flags: %s\n", err.Error())
+		return nil, err
+	}
+	segments, err := store.GetAll(ldstoreimpl.Segments())
+	if err != nil {
+		loggers.Errorf("Error getting all segments: %s\n", err.Error())
+		return nil, err
+	}
+	return []ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: removeDeleted(flags)},
+		{Kind: ldstoreimpl.Segments(), Items: removeDeleted(segments)},
+	}, nil
+}
@@ -81,9 +81,7 @@ func (e *serverSideFlagsOnlyEnvStreamProvider) SendHeartbeat() {
 }
 
 func (e *serverSideFlagsOnlyEnvStreamProvider) Close() {
-	for _, key := range e.channels {
-		e.server.Unregister(key, true)
-	}
+	closeSSEChannels(e.server, e.channels)
 }
 
 func (r *serverSideFlagsOnlyEnvStreamRepository) Replay(channel, id string) chan eventsource.Event {
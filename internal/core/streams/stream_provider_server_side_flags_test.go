@@ -190,4 +190,15 @@ func TestStreamProviderServerSideFlagsOnly(t *testing.T) {
 			verifyHandlerHeartbeat(t, sp, esp, validCredential)
 		})
 	})
+
+	t.Run("Close", func(t *testing.T) {
+		store := makeMockStore(nil, nil)
+
+		withStreamProvider(t, 0, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+
+			verifyHandlerClose(t, sp, esp, validCredential)
+		})
+	})
 }
@@ -217,6 +217,17 @@ func TestStreamProviderServerSide(t *testing.T) {
 		})
 	})
 
+	t.Run("Close", func(t *testing.T) {
+		store := makeMockStore(nil, nil)
+
+		withStreamProvider(t, 0, func(sp StreamProvider) {
+			esp := sp.Register(validCredential, store, ldlog.NewDisabledLoggers())
+			require.NotNil(t, esp)
+
+			verifyHandlerClose(t, sp, esp, validCredential)
+		})
+	})
+
 	t.Run("Replay", func(t *testing.T) {
 		const flagKey = "flagkey"
 
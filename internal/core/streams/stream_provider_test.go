@@ -168,3 +168,53 @@ func verifyHandlerHeartbeat(
 		}
 	})
 }
+
+func verifyHandlerClose(
+	t *testing.T,
+	sp StreamProvider,
+	esp EnvStreamProvider,
+	credential config.SDKCredential,
+) {
+	handler := sp.Handler(credential)
+	require.NotNil(t, handler)
+
+	req, _ := http.NewRequest("GET", "", nil)
+	sharedtest.WithStreamRequestLines(t, req, handler, func(linesCh <-chan string) {
+	ReadInitialEvent:
+		for {
+			select {
+			case line := <-linesCh:
+				if line == "\n" {
+					break ReadInitialEvent
+				}
+			case <-time.After(time.Second):
+				assert.Fail(t, "timed out waiting for initial event")
+				return
+			}
+		}
+
+		esp.Close()
+
+		select {
+		case line := <-linesCh:
+			assert.Equal(t, ":"+streamClosingComment+"\n", line)
+		case <-time.After(time.Second):
+			assert.Fail(t, "timed out waiting for closing comment")
+			return
+		}
+		for {
+			select {
+			case line := <-linesCh:
+				if line == "" {
+					return // the handler has returned
+				}
+				if line != "\n" {
+					assert.Fail(t, "received unexpected data after closing comment", line)
+				}
+			case <-time.After(time.Second):
+				assert.Fail(t, "timed out waiting for stream to be closed")
+				return
+			}
+		}
+	})
+}
@@ -116,6 +116,7 @@ func (s simpleMockStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.Keye
 func makeMockStore(flags []ldmodel.FeatureFlag, segments []ldmodel.Segment) simpleMockStore {
 	ret := simpleMockStore{initialized: true}
 	for _, f := range flags {
+		f := f
 		var item interface{} = &f
 		if f.Deleted {
 			item = nil
@@ -125,6 +126,7 @@ func makeMockStore(flags []ldmodel.FeatureFlag, segments []ldmodel.Segment) simp
 		})
 	}
 	for _, s := range segments {
+		s := s
 		var item interface{} = &s
 		if s.Deleted {
 			item = nil
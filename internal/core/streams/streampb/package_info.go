@@ -0,0 +1,3 @@
+// Package streampb contains the protocol buffer and gRPC definitions for Relay's gRPC streaming
+// protocol, which is described in stream.proto. The server implementation is in the streams package.
+package streampb
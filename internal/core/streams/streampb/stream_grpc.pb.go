@@ -0,0 +1,144 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package streampb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion7
+
+// FlagStreamClient is the client API for FlagStream service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FlagStreamClient interface {
+	// Subscribe opens a stream of data updates for the environment whose SDK key is passed in the
+	// "authorization" request metadata. If Relay has data for the environment, the first event is a
+	// PutEvent with the full data set.
+	//
+	// The client may send a Ping at any time, and the server answers each one with a Pong; the server
+	// also sends a Heartbeat periodically. Either side can use these to detect a dead connection.
+	Subscribe(ctx context.Context, opts ...grpc.CallOption) (FlagStream_SubscribeClient, error)
+}
+
+type flagStreamClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFlagStreamClient(cc grpc.ClientConnInterface) FlagStreamClient {
+	return &flagStreamClient{cc}
+}
+
+func (c *flagStreamClient) Subscribe(ctx context.Context, opts ...grpc.CallOption) (FlagStream_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FlagStream_ServiceDesc.Streams[0], "/launchdarkly.relay.stream.v1.FlagStream/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &flagStreamSubscribeClient{stream}
+	return x, nil
+}
+
+type FlagStream_SubscribeClient interface {
+	Send(*SubscribeRequest) error
+	Recv() (*StreamEvent, error)
+	grpc.ClientStream
+}
+
+type flagStreamSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *flagStreamSubscribeClient) Send(m *SubscribeRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *flagStreamSubscribeClient) Recv() (*StreamEvent, error) {
+	m := new(StreamEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FlagStreamServer is the server API for FlagStream service.
+// All implementations must embed UnimplementedFlagStreamServer
+// for forward compatibility
+type FlagStreamServer interface {
+	// Subscribe opens a stream of data updates for the environment whose SDK key is passed in the
+	// "authorization" request metadata. If Relay has data for the environment, the first event is a
+	// PutEvent with the full data set.
+	//
+	// The client may send a Ping at any time, and the server answers each one with a Pong; the server
+	// also sends a Heartbeat periodically. Either side can use these to detect a dead connection.
+	Subscribe(FlagStream_SubscribeServer) error
+	mustEmbedUnimplementedFlagStreamServer()
+}
+
+// UnimplementedFlagStreamServer must be embedded to have forward compatible implementations.
+type UnimplementedFlagStreamServer struct {
+}
+
+func (UnimplementedFlagStreamServer) Subscribe(FlagStream_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedFlagStreamServer) mustEmbedUnimplementedFlagStreamServer() {}
+
+// UnsafeFlagStreamServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FlagStreamServer will
+// result in compilation errors.
+type UnsafeFlagStreamServer interface {
+	mustEmbedUnimplementedFlagStreamServer()
+}
+
+func RegisterFlagStreamServer(s grpc.ServiceRegistrar, srv FlagStreamServer) {
+	s.RegisterService(&FlagStream_ServiceDesc, srv)
+}
+
+func _FlagStream_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FlagStreamServer).Subscribe(&flagStreamSubscribeServer{stream})
+}
+
+type FlagStream_SubscribeServer interface {
+	Send(*StreamEvent) error
+	Recv() (*SubscribeRequest, error)
+	grpc.ServerStream
+}
+
+type flagStreamSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *flagStreamSubscribeServer) Send(m *StreamEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *flagStreamSubscribeServer) Recv() (*SubscribeRequest, error) {
+	m := new(SubscribeRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FlagStream_ServiceDesc is the grpc.ServiceDesc for FlagStream service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FlagStream_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "launchdarkly.relay.stream.v1.FlagStream",
+	HandlerType: (*FlagStreamServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _FlagStream_Subscribe_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "stream.proto",
+}
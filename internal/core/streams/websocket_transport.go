@@ -0,0 +1,161 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// This is an alternative transport for the SSE streams, for clients that are behind proxies that do not handle
+// long-lived SSE responses well. The stream is produced by the same handler as usual, and then each SSE event
+// is sent to the client as a WebSocket text message; see webSocketEventMessage. SSE comments, which Relay uses
+// as heartbeats, are sent as WebSocket ping frames.
+
+var errWebSocketStreamClosed = errors.New("stream was closed") //nolint:gochecknoglobals
+
+// webSocketEventMessage is the JSON representation of an SSE event in a WebSocket message. The event name and
+// data are exactly the same as they would be in the SSE stream.
+type webSocketEventMessage struct {
+	Event string `json:"event"`
+	Data  string `json:"data"`
+	ID    string `json:"id,omitempty"`
+}
+
+// WithWebSocketTransport decorates an SSE stream handler so that, if the request has an "Upgrade: websocket"
+// header, the same stream is delivered over a WebSocket connection instead. Requests without that header are
+// passed to the SSE handler unchanged.
+func WithWebSocketTransport(sseHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+			sseHandler.ServeHTTP(w, req)
+			return
+		}
+		if _, ok := w.(http.Hijacker); !ok {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		server := websocket.Server{
+			// Unlike websocket.Handler, this does not require an Origin header. The streams that use this
+			// transport either are not restricted by origin, or are restricted by our CORS middleware.
+			Handshake: func(*websocket.Config, *http.Request) error { return nil },
+			Handler: func(ws *websocket.Conn) {
+				serveStreamOverWebSocket(sseHandler, ws, req)
+			},
+		}
+		server.ServeHTTP(w, req)
+	})
+}
+
+func serveStreamOverWebSocket(sseHandler http.Handler, ws *websocket.Conn, req *http.Request) {
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+
+	// The client isn't expected to send us anything, but we have to keep reading in order to find out when
+	// it closes the connection, so that the SSE handler will stop.
+	go func() {
+		var discard []byte
+		for {
+			if err := websocket.Message.Receive(ws, &discard); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	sseHandler.ServeHTTP(&webSocketEventWriter{ws: ws, header: make(http.Header)}, req.WithContext(ctx))
+}
+
+// webSocketEventWriter is the http.ResponseWriter that the SSE handler writes to. It parses the SSE output
+// into events, which is simple because it is always in the format produced by eventsource.Encoder.
+type webSocketEventWriter struct {
+	ws      *websocket.Conn
+	header  http.Header
+	status  int
+	buf     []byte
+	current webSocketEventMessage
+	hasData bool
+}
+
+func (w *webSocketEventWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *webSocketEventWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+// Flush is a no-op, since each message is sent as soon as it is complete; it is needed because the SSE
+// handler requires an http.Flusher.
+func (w *webSocketEventWriter) Flush() {}
+
+func (w *webSocketEventWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status != http.StatusOK {
+		// The stream handler is returning an error response instead of a stream. There is no way to
+		// report that after the WebSocket handshake, so we just end the connection.
+		return 0, errWebSocketStreamClosed
+	}
+	w.buf = append(w.buf, data...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(data), nil
+		}
+		line := string(w.buf[:i])
+		w.buf = w.buf[i+1:]
+		if err := w.processLine(line); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (w *webSocketEventWriter) processLine(line string) error {
+	if line == "" {
+		if w.current.Event == "" && !w.hasData {
+			return nil
+		}
+		message := w.current
+		w.current, w.hasData = webSocketEventMessage{}, false
+		jsonData, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		return websocket.Message.Send(w.ws, string(jsonData))
+	}
+	if strings.HasPrefix(line, ":") {
+		return w.sendPing()
+	}
+	name, value := line, ""
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		name, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+	}
+	switch name {
+	case "event":
+		w.current.Event = value
+	case "data":
+		if w.hasData {
+			w.current.Data += "\n"
+		}
+		w.current.Data += value
+		w.hasData = true
+	case "id":
+		w.current.ID = value
+	}
+	return nil
+}
+
+func (w *webSocketEventWriter) sendPing() error {
+	w.ws.PayloadType = websocket.PingFrame
+	_, err := w.ws.Write(nil)
+	w.ws.PayloadType = websocket.TextFrame
+	return err
+}
@@ -0,0 +1,101 @@
+package streams
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/eventsource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func withWebSocketTestServer(t *testing.T, sseHandler http.Handler, action func(url string)) {
+	server := httptest.NewServer(WithWebSocketTransport(sseHandler))
+	defer server.Close()
+	action(server.URL)
+}
+
+func dialWebSocket(t *testing.T, url string) *websocket.Conn {
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(url, "http"), "", url)
+	require.NoError(t, err)
+	require.NoError(t, ws.SetDeadline(time.Now().Add(time.Second*5)))
+	return ws
+}
+
+func receiveWebSocketEvent(t *testing.T, ws *websocket.Conn) webSocketEventMessage {
+	var data string
+	require.NoError(t, websocket.Message.Receive(ws, &data))
+	var message webSocketEventMessage
+	require.NoError(t, json.Unmarshal([]byte(data), &message))
+	return message
+}
+
+func TestWebSocketTransportSendsSSEEventsAsMessages(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		enc := eventsource.NewEncoder(w, false)
+		_ = enc.Encode(MakePingEvent())
+		_, _ = w.Write([]byte(":\n"))
+		_, _ = w.Write([]byte("id: 1\nevent: put\ndata: line1\nda"))
+		_, _ = w.Write([]byte("ta: line2\n\n"))
+		<-req.Context().Done()
+	})
+	withWebSocketTestServer(t, handler, func(url string) {
+		ws := dialWebSocket(t, url)
+		defer ws.Close()
+
+		assert.Equal(t, webSocketEventMessage{Event: "ping", Data: " "}, receiveWebSocketEvent(t, ws))
+		// the comment is sent as a ping frame, which the client handles automatically
+		assert.Equal(t, webSocketEventMessage{Event: "put", Data: "line1\nline2", ID: "1"}, receiveWebSocketEvent(t, ws))
+	})
+}
+
+func TestWebSocketTransportEndsStreamWhenClientCloses(t *testing.T) {
+	handlerDone := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_ = eventsource.NewEncoder(w, false).Encode(MakePingEvent())
+		<-req.Context().Done()
+		close(handlerDone)
+	})
+	withWebSocketTestServer(t, handler, func(url string) {
+		ws := dialWebSocket(t, url)
+		receiveWebSocketEvent(t, ws)
+		require.NoError(t, ws.Close())
+		select {
+		case <-handlerDone:
+		case <-time.After(time.Second * 5):
+			assert.Fail(t, "timed out waiting for stream handler to end")
+		}
+	})
+}
+
+func TestWebSocketTransportClosesConnectionForErrorResponse(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("not ready"))
+	})
+	withWebSocketTestServer(t, handler, func(url string) {
+		ws := dialWebSocket(t, url)
+		defer ws.Close()
+		var data string
+		assert.Error(t, websocket.Message.Receive(ws, &data))
+	})
+}
+
+func TestWebSocketTransportPassesNonUpgradeRequestsToSSEHandler(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	withWebSocketTestServer(t, handler, func(url string) {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	})
+}
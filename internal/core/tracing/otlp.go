@@ -0,0 +1,176 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/launchdarkly/ld-relay/v6/relay/version"
+)
+
+const (
+	otlpTracesPath = "/v1/traces"
+
+	instrumentationScopeName = "github.com/launchdarkly/ld-relay"
+
+	otlpStatusCodeError = 2
+)
+
+// otlpExporter sends spans to an OTLP/HTTP endpoint, using the JSON encoding of the OTLP protobuf
+// messages. In that encoding, trace and span IDs are hex strings, and 64-bit integers are strings.
+type otlpExporter struct {
+	url         string
+	headers     http.Header
+	serviceName string
+	client      *http.Client
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func newOTLPExporter(url string, headers http.Header, serviceName string, client *http.Client) *otlpExporter {
+	return &otlpExporter{url: url, headers: headers, serviceName: serviceName, client: client}
+}
+
+func (e *otlpExporter) export(spans []*Span) error {
+	body, err := json.Marshal(e.makeRequest(spans))
+	if err != nil {
+		return err // COVERAGE: can't happen in unit tests
+	}
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range e.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errHTTPStatus(resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *otlpExporter) makeRequest(spans []*Span) otlpTracesRequest {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		otlpSpans = append(otlpSpans, makeOTLPSpan(s))
+	}
+	return otlpTracesRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpKeyValue{
+					makeOTLPKeyValue(String("service.name", e.serviceName)),
+					makeOTLPKeyValue(String("service.version", version.Version)),
+				},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: instrumentationScopeName, Version: version.Version},
+				Spans: otlpSpans,
+			}},
+		}},
+	}
+}
+
+func makeOTLPSpan(s *Span) otlpSpan {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret := otlpSpan{
+		TraceID:           s.spanContext.TraceID.String(),
+		SpanID:            s.spanContext.SpanID.String(),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.startTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.endTime.UnixNano(), 10),
+	}
+	if s.parentSpanID != (SpanID{}) {
+		ret.ParentSpanID = s.parentSpanID.String()
+	}
+	for _, a := range s.attributes {
+		ret.Attributes = append(ret.Attributes, makeOTLPKeyValue(a))
+	}
+	if s.hasError {
+		ret.Status = &otlpStatus{Code: otlpStatusCodeError, Message: s.errorMessage}
+	}
+	return ret
+}
+
+func makeOTLPKeyValue(a Attribute) otlpKeyValue {
+	var v otlpAnyValue
+	switch value := a.Value.(type) {
+	case string:
+		v.StringValue = &value
+	case bool:
+		v.BoolValue = &value
+	case int:
+		s := strconv.Itoa(value)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(value, 10)
+		v.IntValue = &s
+	case float64:
+		v.DoubleValue = &value
+	default:
+		s := ""
+		v.StringValue = &s
+	}
+	return otlpKeyValue{Key: a.Key, Value: v}
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/relay/version"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-test-helpers/v2/httphelpers"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTLPExporterSendsSpans(t *testing.T) {
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(200))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		endpoint, err := ct.NewOptURLAbsoluteFromString(server.URL + "/")
+		require.NoError(t, err)
+		tracer := NewTracer(config.OpenTelemetryConfig{
+			Enabled:     true,
+			Endpoint:    endpoint,
+			Header:      ct.NewOptStringList([]string{"Authorization = my-token"}),
+			ServiceName: "my-relay",
+		}, ldlog.NewDisabledLoggers())
+		SetTracer(tracer)
+		defer SetTracer(nil)
+
+		startTime := time.Now()
+		ctx, parent := StartSpan(context.Background(), "parent", SpanKindServer)
+		_, child := StartSpan(ctx, "child", SpanKindClient, String("s", "x"), Int("i", 2), Bool("b", true))
+		child.SetError(errors.New("sorry"))
+		child.End()
+		parent.End()
+		tracer.Close()
+
+		r := <-requestsCh
+		assert.Equal(t, "POST", r.Request.Method)
+		assert.Equal(t, otlpTracesPath, r.Request.URL.Path)
+		assert.Equal(t, "application/json", r.Request.Header.Get("Content-Type"))
+		assert.Equal(t, "my-token", r.Request.Header.Get("Authorization"))
+
+		ptc, pid := parent.SpanContext().TraceID.String(), parent.SpanContext().SpanID.String()
+		cid := child.SpanContext().SpanID.String()
+		start, end := child.startTime.UnixNano(), child.endTime.UnixNano()
+		assert.True(t, start >= startTime.UnixNano())
+		assert.JSONEq(t, `{"resourceSpans": [{
+			"resource": {"attributes": [
+				{"key": "service.name", "value": {"stringValue": "my-relay"}},
+				{"key": "service.version", "value": {"stringValue": "`+version.Version+`"}}
+			]},
+			"scopeSpans": [{
+				"scope": {"name": "`+instrumentationScopeName+`", "version": "`+version.Version+`"},
+				"spans": [
+					{
+						"traceId": "`+ptc+`", "spanId": "`+cid+`", "parentSpanId": "`+pid+`",
+						"name": "child", "kind": 3,
+						"startTimeUnixNano": "`+strconv.FormatInt(start, 10)+`", "endTimeUnixNano": "`+strconv.FormatInt(end, 10)+`",
+						"attributes": [
+							{"key": "s", "value": {"stringValue": "x"}},
+							{"key": "i", "value": {"intValue": "2"}},
+							{"key": "b", "value": {"boolValue": true}}
+						],
+						"status": {"code": 2, "message": "sorry"}
+					},
+					{
+						"traceId": "`+ptc+`", "spanId": "`+pid+`",
+						"name": "parent", "kind": 2,
+						"startTimeUnixNano": "`+strconv.FormatInt(parent.startTime.UnixNano(), 10)+`",
+						"endTimeUnixNano": "`+strconv.FormatInt(parent.endTime.UnixNano(), 10)+`"
+					}
+				]
+			}]
+		}]}`, string(r.Body))
+	})
+}
+
+func TestOTLPExporterReturnsErrorForHTTPStatus(t *testing.T) {
+	handler := httphelpers.HandlerWithStatus(503)
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		e := newOTLPExporter(server.URL+otlpTracesPath, nil, DefaultServiceName, http.DefaultClient)
+		err := e.export([]*Span{{name: "x", startTime: time.Now(), endTime: time.Now()}})
+		assert.Equal(t, errHTTPStatus(503), err)
+	})
+}
@@ -0,0 +1,9 @@
+// Package tracing provides Relay's OpenTelemetry tracing: spans for request handling, big segment
+// store queries, event forwarding, and upstream LaunchDarkly calls, which are exported to an OTLP
+// endpoint. It is configured with config.OpenTelemetryConfig.
+//
+// This is a minimal implementation of the parts of the OpenTelemetry specification that Relay needs:
+// spans are exported with the OTLP/HTTP protocol using JSON encoding, and trace context is propagated
+// with the W3C traceparent header. Tracing does nothing unless a Tracer has been installed with
+// SetTracer, so instrumented code does not need to check whether it is enabled.
+package tracing
@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// TraceParentHeader is the W3C Trace Context header that carries the trace ID, the parent span ID,
+// and the sampling decision between services.
+const TraceParentHeader = "traceparent"
+
+const (
+	traceParentVersion = "00"
+	sampledFlag        = "01"
+	notSampledFlag     = "00"
+)
+
+// ContextWithRemoteParent returns a context whose parent span is the one described by the traceparent
+// header of an incoming request, if that header is present and valid; otherwise it returns the
+// original context, and spans started from it will begin a new trace.
+func ContextWithRemoteParent(ctx context.Context, header http.Header) context.Context {
+	sc, ok := parseTraceParent(header.Get(TraceParentHeader))
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// InjectTraceParent sets the traceparent header for an outgoing request to describe the current span
+// in the context, if any.
+func InjectTraceParent(ctx context.Context, header http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		header.Set(TraceParentHeader, formatTraceParent(sc))
+	}
+}
+
+func formatTraceParent(sc SpanContext) string {
+	flags := notSampledFlag
+	if sc.Sampled {
+		flags = sampledFlag
+	}
+	return traceParentVersion + "-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+func parseTraceParent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	// Later versions may add more fields, but must keep these ones in the same places
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == traceParentVersion && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	var flags [1]byte
+	if !decodeHex(parts[1], sc.TraceID[:]) || !decodeHex(parts[2], sc.SpanID[:]) || !decodeHex(parts[3], flags[:]) {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 != 0
+	return sc, sc.IsValid()
+}
+
+func decodeHex(s string, into []byte) bool {
+	if len(s) != hex.EncodedLen(len(into)) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(into, []byte(s))
+	return err == nil
+}
+
+// Transport wraps an HTTP transport so that each request is traced with a client span, and carries
+// the traceparent header so that the receiving service can continue the trace. If base is nil,
+// http.DefaultTransport is used.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if _, ok := base.(tracingTransport); ok {
+		return base
+	}
+	return tracingTransport{base: base}
+}
+
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := StartSpan(req.Context(), "HTTP "+req.Method, SpanKindClient,
+		String("http.method", req.Method),
+		String("http.url", redactedURL(req)),
+		String("net.peer.name", req.URL.Hostname()),
+	)
+	if span == nil {
+		return t.base.RoundTrip(req)
+	}
+	defer span.End()
+
+	// A RoundTripper must not modify the original request
+	req = req.Clone(ctx)
+	InjectTraceParent(ctx, req.Header)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttributes(Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetError(errHTTPStatus(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// redactedURL returns the request URL without the query string, which could contain user data, and
+// without any credentials in the user info.
+func redactedURL(req *http.Request) string {
+	u := *req.URL
+	u.RawQuery = ""
+	u.User = nil
+	return u.String()
+}
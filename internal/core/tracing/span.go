@@ -0,0 +1,168 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// SpanKind describes the relationship of a span to its callers and callees, as defined by OpenTelemetry.
+type SpanKind int
+
+const (
+	// SpanKindInternal is for an operation within Relay.
+	SpanKindInternal SpanKind = 1
+
+	// SpanKindServer is for handling a request from an SDK.
+	SpanKindServer SpanKind = 2
+
+	// SpanKindClient is for a request to an external service, such as LaunchDarkly or a database.
+	SpanKindClient SpanKind = 3
+)
+
+// TraceID is the identifier of a trace.
+type TraceID [16]byte
+
+// SpanID is the identifier of a span within a trace.
+type SpanID [8]byte
+
+// String returns the ID as lowercase hex, as in an OTLP payload or a traceparent header.
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// String returns the ID as lowercase hex, as in an OTLP payload or a traceparent header.
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// SpanContext is the part of a span that is propagated to child spans and to other services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid returns true if the SpanContext has a trace ID and span ID.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Attribute is a key-value pair that describes a span. The value can be a string, bool, int, int64,
+// or float64.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String creates a string Attribute.
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int creates an integer Attribute.
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+// Bool creates a boolean Attribute.
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// Span is a single traced operation. A nil *Span is valid and does nothing, so callers do not need to
+// check whether tracing is enabled.
+//
+// A Span that has not been sampled is not exported, but still has a SpanContext so that the sampling
+// decision is propagated to child spans and to other services.
+type Span struct {
+	tracer       *Tracer
+	name         string
+	kind         SpanKind
+	spanContext  SpanContext
+	parentSpanID SpanID
+	startTime    time.Time
+	endTime      time.Time
+	attributes   []Attribute
+	errorMessage string
+	hasError     bool
+	ended        bool
+	lock         sync.Mutex
+}
+
+type spanContextKey struct{}
+
+// StartSpan starts a span as a child of the span in the context, if any, and returns a context that
+// contains the new span. The caller must call End on the span.
+//
+// If no Tracer has been installed with SetTracer, it returns the original context and a nil Span.
+func StartSpan(ctx context.Context, name string, kind SpanKind, attributes ...Attribute) (context.Context, *Span) {
+	t := getTracer()
+	if t == nil {
+		return ctx, nil
+	}
+	parent := SpanContextFromContext(ctx)
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		startTime:  time.Now(),
+		attributes: attributes,
+	}
+	if parent.IsValid() {
+		span.spanContext = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+		span.parentSpanID = parent.SpanID
+	} else {
+		span.spanContext.TraceID = t.newTraceID()
+		span.spanContext.Sampled = t.shouldSample(span.spanContext.TraceID)
+	}
+	span.spanContext.SpanID = t.newSpanID()
+	return context.WithValue(ctx, spanContextKey{}, span.spanContext), span
+}
+
+// SpanContextFromContext returns the SpanContext of the current span in the context, which may have
+// come from another service (see ContextWithRemoteParent). It returns an invalid SpanContext if there
+// is none.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// SpanContext returns the span's SpanContext.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.spanContext
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil || !s.spanContext.Sampled {
+		return
+	}
+	s.lock.Lock()
+	s.attributes = append(s.attributes, attributes...)
+	s.lock.Unlock()
+}
+
+// SetError marks the span as failed, if err is not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil || !s.spanContext.Sampled {
+		return
+	}
+	s.lock.Lock()
+	s.hasError = true
+	s.errorMessage = err.Error()
+	s.lock.Unlock()
+}
+
+// End completes the span. If it was sampled, it is queued to be exported. Calling End more than once
+// has no effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.endTime = time.Now()
+	s.lock.Unlock()
+	if s.spanContext.Sampled {
+		s.tracer.enqueue(s)
+	}
+}
@@ -0,0 +1,239 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+const (
+	// DefaultEndpoint is the OTLP/HTTP endpoint that is used if config.OpenTelemetryConfig.Endpoint is
+	// not set. This is where an OpenTelemetry Collector running on the same host listens by default.
+	DefaultEndpoint = "http://localhost:4318"
+
+	// DefaultServiceName is the service name reported for Relay's spans if
+	// config.OpenTelemetryConfig.ServiceName is not set.
+	DefaultServiceName = "ld-relay"
+
+	defaultQueueSize      = 2048
+	defaultMaxBatchSize   = 512
+	defaultExportInterval = time.Second * 5
+	exportTimeout         = time.Second * 10
+)
+
+func errHTTPStatus(status int) error {
+	return fmt.Errorf("HTTP status %d", status)
+}
+
+// Tracer creates spans and exports the sampled ones, in batches, to an OTLP endpoint.
+type Tracer struct {
+	exporter       spanExporter
+	samplingRatio  float64
+	queue          chan *Span
+	flushCh        chan chan struct{}
+	closer         chan struct{}
+	closeOnce      sync.Once
+	done           sync.WaitGroup
+	maxBatchSize   int
+	exportInterval time.Duration
+	overflowed     bool
+	exportFailing  bool
+	overflowLock   sync.Mutex
+	loggers        ldlog.Loggers
+}
+
+type spanExporter interface {
+	export(spans []*Span) error
+}
+
+type tracerHolder struct {
+	tracer *Tracer
+	lock   sync.RWMutex
+}
+
+// Spans are created all over Relay, by code that mostly has no other way to reach the configuration,
+// so the current Tracer is global in the same way as OpenCensus's trace exporters.
+var currentTracer tracerHolder //nolint:gochecknoglobals
+
+// SetTracer installs the Tracer that StartSpan uses, or removes it if t is nil.
+func SetTracer(t *Tracer) {
+	currentTracer.lock.Lock()
+	currentTracer.tracer = t
+	currentTracer.lock.Unlock()
+}
+
+func getTracer() *Tracer {
+	currentTracer.lock.RLock()
+	defer currentTracer.lock.RUnlock()
+	return currentTracer.tracer
+}
+
+// NewTracer creates a Tracer from the configuration, and starts the goroutine that exports spans.
+// ValidateConfig has already checked the sampling ratio and headers. The Tracer is not used by
+// StartSpan until it is installed with SetTracer.
+//
+// The Tracer uses its own HTTP client, rather than one that is wrapped with Transport, so that
+// exporting spans does not create more spans.
+func NewTracer(c config.OpenTelemetryConfig, loggers ldlog.Loggers) *Tracer {
+	endpoint := DefaultEndpoint
+	if c.Endpoint.IsDefined() {
+		endpoint = c.Endpoint.String()
+	}
+	serviceName := c.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	headers := make(http.Header)
+	for _, h := range c.Header.Values() {
+		parts := strings.SplitN(h, "=", 2)
+		headers.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	exporter := newOTLPExporter(strings.TrimSuffix(endpoint, "/")+otlpTracesPath, headers, serviceName,
+		&http.Client{Timeout: exportTimeout})
+	return newTracerInternal(exporter, c.SamplingRatio.GetOrElse(1), defaultExportInterval, loggers)
+}
+
+func newTracerInternal(exporter spanExporter, samplingRatio float64, exportInterval time.Duration, loggers ldlog.Loggers) *Tracer {
+	t := &Tracer{
+		exporter:       exporter,
+		samplingRatio:  samplingRatio,
+		queue:          make(chan *Span, defaultQueueSize),
+		flushCh:        make(chan chan struct{}),
+		closer:         make(chan struct{}),
+		maxBatchSize:   defaultMaxBatchSize,
+		exportInterval: exportInterval,
+		loggers:        loggers,
+	}
+	t.done.Add(1)
+	go t.run()
+	return t
+}
+
+// Flush exports all of the spans that have ended so far, and waits until that is done.
+func (t *Tracer) Flush() {
+	doneCh := make(chan struct{})
+	select {
+	case t.flushCh <- doneCh:
+		<-doneCh
+	case <-t.closer:
+	}
+}
+
+// Close exports any remaining spans and stops the Tracer. Spans that end afterward are discarded.
+func (t *Tracer) Close() {
+	t.closeOnce.Do(func() {
+		close(t.closer)
+		t.done.Wait()
+	})
+}
+
+func (t *Tracer) enqueue(s *Span) {
+	select {
+	case <-t.closer:
+		return
+	default:
+	}
+	select {
+	case t.queue <- s:
+	default:
+		t.overflowLock.Lock()
+		if !t.overflowed {
+			t.loggers.Warnf("OpenTelemetry span queue is full; spans will be dropped until it has been exported")
+			t.overflowed = true
+		}
+		t.overflowLock.Unlock()
+	}
+}
+
+func (t *Tracer) run() {
+	defer t.done.Done()
+	ticker := time.NewTicker(t.exportInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, t.maxBatchSize)
+	export := func() {
+		// Take whatever else is already queued, so a flush includes every span that has ended
+		for len(t.queue) > 0 {
+			batch = append(batch, <-t.queue)
+			if len(batch) >= t.maxBatchSize {
+				t.export(batch)
+				batch = batch[:0]
+			}
+		}
+		t.export(batch)
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) >= t.maxBatchSize {
+				t.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			export()
+		case doneCh := <-t.flushCh:
+			export()
+			close(doneCh)
+		case <-t.closer:
+			export()
+			return
+		}
+	}
+}
+
+func (t *Tracer) export(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	// Only the first of a series of failures is logged, so an unavailable collector doesn't cause a
+	// warning every few seconds
+	if err := t.exporter.export(batch); err != nil {
+		if !t.exportFailing {
+			t.loggers.Warnf("Failed to export OpenTelemetry spans: %s", err)
+			t.exportFailing = true
+		}
+		return
+	}
+	if t.exportFailing {
+		t.loggers.Info("Resumed exporting OpenTelemetry spans")
+		t.exportFailing = false
+	}
+	t.overflowLock.Lock()
+	t.overflowed = false
+	t.overflowLock.Unlock()
+}
+
+// shouldSample makes the sampling decision for a new trace. As in OpenTelemetry's TraceIdRatioBased
+// sampler, the decision depends only on the trace ID, so every Relay instance with the same ratio
+// makes the same decision for a trace. Spans with a parent use the parent's decision instead.
+func (t *Tracer) shouldSample(traceID TraceID) bool {
+	switch {
+	case t.samplingRatio >= 1:
+		return true
+	case t.samplingRatio <= 0:
+		return false
+	}
+	bound := uint64(t.samplingRatio * (1 << 63))
+	return binary.BigEndian.Uint64(traceID[8:])>>1 < bound
+}
+
+func (t *Tracer) newTraceID() TraceID {
+	var id TraceID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func (t *Tracer) newSpanID() SpanID {
+	var id SpanID
+	_, _ = rand.Read(id[:])
+	return id
+}
@@ -0,0 +1,172 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testExporter struct {
+	spans []*Span
+	err   error
+	lock  sync.Mutex
+}
+
+func (e *testExporter) export(spans []*Span) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *testExporter) getSpans() []*Span {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+func withTestTracer(t *testing.T, samplingRatio float64, action func(*Tracer, *testExporter)) {
+	exporter := &testExporter{}
+	tracer := newTracerInternal(exporter, samplingRatio, time.Hour, ldlog.NewDisabledLoggers())
+	SetTracer(tracer)
+	defer func() {
+		SetTracer(nil)
+		tracer.Close()
+	}()
+	action(tracer, exporter)
+}
+
+func TestStartSpanDoesNothingWithoutTracer(t *testing.T) {
+	ctx := context.Background()
+	newCtx, span := StartSpan(ctx, "test", SpanKindInternal)
+	assert.Nil(t, span)
+	assert.Equal(t, ctx, newCtx)
+
+	// all methods are safe to call on a nil span
+	span.SetAttributes(String("a", "b"))
+	span.SetError(errors.New("sorry"))
+	span.End()
+	assert.False(t, span.SpanContext().IsValid())
+}
+
+func TestSpansAreExported(t *testing.T) {
+	withTestTracer(t, 1, func(tracer *Tracer, exporter *testExporter) {
+		ctx, parent := StartSpan(context.Background(), "parent", SpanKindServer, String("a", "b"))
+		_, child := StartSpan(ctx, "child", SpanKindClient)
+		child.SetError(errors.New("sorry"))
+		child.End()
+		parent.SetAttributes(Int("count", 2))
+		parent.End()
+		parent.End() // ending twice has no effect
+
+		tracer.Flush()
+		spans := exporter.getSpans()
+		require.Len(t, spans, 2)
+
+		assert.Equal(t, "child", spans[0].name)
+		assert.Equal(t, SpanKindClient, spans[0].kind)
+		assert.True(t, spans[0].hasError)
+		assert.Equal(t, "sorry", spans[0].errorMessage)
+		assert.Equal(t, parent.SpanContext().TraceID, spans[0].spanContext.TraceID)
+		assert.Equal(t, parent.SpanContext().SpanID, spans[0].parentSpanID)
+
+		assert.Equal(t, "parent", spans[1].name)
+		assert.Equal(t, SpanID{}, spans[1].parentSpanID)
+		assert.Equal(t, []Attribute{String("a", "b"), Int("count", 2)}, spans[1].attributes)
+		assert.False(t, spans[1].endTime.Before(spans[1].startTime))
+	})
+}
+
+func TestSpansAreExportedOnClose(t *testing.T) {
+	exporter := &testExporter{}
+	tracer := newTracerInternal(exporter, 1, time.Hour, ldlog.NewDisabledLoggers())
+	SetTracer(tracer)
+	_, span := StartSpan(context.Background(), "test", SpanKindInternal)
+	span.End()
+	SetTracer(nil)
+	tracer.Close()
+
+	assert.Len(t, exporter.getSpans(), 1)
+}
+
+func TestSamplingRatio(t *testing.T) {
+	t.Run("zero", func(t *testing.T) {
+		withTestTracer(t, 0, func(tracer *Tracer, exporter *testExporter) {
+			ctx, parent := StartSpan(context.Background(), "parent", SpanKindServer)
+			_, child := StartSpan(ctx, "child", SpanKindInternal)
+			child.End()
+			parent.End()
+
+			assert.True(t, parent.SpanContext().IsValid(), "unsampled span still has a context to propagate")
+			assert.False(t, parent.SpanContext().Sampled)
+			assert.False(t, child.SpanContext().Sampled)
+			tracer.Flush()
+			assert.Len(t, exporter.getSpans(), 0)
+		})
+	})
+
+	t.Run("fraction", func(t *testing.T) {
+		withTestTracer(t, 0.25, func(tracer *Tracer, exporter *testExporter) {
+			sampled := 0
+			for i := 0; i < 4000; i++ {
+				if tracer.shouldSample(tracer.newTraceID()) {
+					sampled++
+				}
+			}
+			assert.InDelta(t, 1000, sampled, 200)
+		})
+	})
+
+	t.Run("remote parent decision is used", func(t *testing.T) {
+		withTestTracer(t, 0, func(tracer *Tracer, exporter *testExporter) {
+			parent := SpanContext{TraceID: TraceID{1}, SpanID: SpanID{2}, Sampled: true}
+			ctx := context.WithValue(context.Background(), spanContextKey{}, parent)
+			_, span := StartSpan(ctx, "test", SpanKindServer)
+			span.End()
+
+			tracer.Flush()
+			spans := exporter.getSpans()
+			require.Len(t, spans, 1)
+			assert.Equal(t, parent.TraceID, spans[0].spanContext.TraceID)
+			assert.Equal(t, parent.SpanID, spans[0].parentSpanID)
+		})
+	})
+}
+
+func TestExportFailureIsLoggedOnce(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	exporter := &testExporter{err: errors.New("no collector")}
+	tracer := newTracerInternal(exporter, 1, time.Hour, mockLog.Loggers)
+	SetTracer(tracer)
+	defer func() {
+		SetTracer(nil)
+		tracer.Close()
+	}()
+
+	for i := 0; i < 2; i++ {
+		_, span := StartSpan(context.Background(), "test", SpanKindInternal)
+		span.End()
+		tracer.Flush()
+	}
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 1)
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "no collector")
+
+	exporter.lock.Lock()
+	exporter.err = nil
+	exporter.lock.Unlock()
+	_, span := StartSpan(context.Background(), "test", SpanKindInternal)
+	span.End()
+	tracer.Flush()
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "Resumed exporting")
+}
@@ -24,6 +24,7 @@ type EnvironmentRep struct {
 	SDKKey     SDKKeyRep            `json:"sdkKey"`
 	DefaultTTL int                  `json:"defaultTtl"`
 	SecureMode bool                 `json:"secureMode"`
+	Tags       []string             `json:"tags"`
 	Version    int                  `json:"version"`
 }
 
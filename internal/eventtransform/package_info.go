@@ -0,0 +1,4 @@
+// Package eventtransform contains the hooks that can filter or modify analytics events before Relay
+// forwards them to LaunchDarkly: Go functions that are registered by an application that embeds Relay,
+// and an optional webhook. Both are configured for each environment.
+package eventtransform
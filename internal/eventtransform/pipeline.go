@@ -0,0 +1,174 @@
+package eventtransform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+const webhookTimeout = time.Second * 5
+
+func errWebhookStatus(status int) error {
+	return fmt.Errorf("HTTP status %d", status)
+}
+
+// Pipeline applies an environment's configured transformers to each payload of analytics events: first
+// the removal or hashing of its private attributes, then the registered Transformers, in the order they
+// were listed, and then the webhook. Private attributes are handled first so that they are never passed
+// to a Transformer or the webhook.
+//
+// The webhook receives a POST request with a JSON object like {"envName": "...", "envId": "...",
+// "sdkKind": "server", "events": [...]}, and must respond with a JSON object like {"events": [...]}
+// containing the events to forward. If the webhook can't be reached or gives an invalid response, the
+// payload is discarded rather than forwarded untransformed, since the webhook may be responsible for
+// removing data that must not leave the network.
+type Pipeline struct {
+	envName      string
+	envID        string
+	redactor     *redactor
+	transformers []Transformer
+	webhookURL   string
+	client       *http.Client
+	loggers      ldlog.Loggers
+	failing      bool
+	lock         sync.Mutex
+}
+
+type webhookRequest struct {
+	EnvName string            `json:"envName"`
+	EnvID   string            `json:"envId,omitempty"`
+	SDKKind string            `json:"sdkKind"`
+	Events  []json.RawMessage `json:"events"`
+}
+
+type webhookResponse struct {
+	Events []json.RawMessage `json:"events"`
+}
+
+// NewPipeline creates a Pipeline for an environment, or returns nil if the environment has no private
+// attributes or transformers configured. It returns an error if any of the transformer names have not been
+// registered.
+func NewPipeline(
+	envConfig config.EnvConfig,
+	envName string,
+	httpConfig httpconfig.HTTPConfig,
+	loggers ldlog.Loggers,
+) (*Pipeline, error) {
+	names := envConfig.EventTransformers.Values()
+	redactor := newRedactor(envConfig.PrivateAttributes.Values(), envConfig.HashPrivateAttributes)
+	if len(names) == 0 && !envConfig.EventTransformWebhook.IsDefined() && redactor == nil {
+		return nil, nil
+	}
+	p := &Pipeline{
+		envName:  envName,
+		envID:    string(envConfig.EnvID),
+		redactor: redactor,
+		loggers:  loggers,
+	}
+	for _, name := range names {
+		t, ok := lookup(name)
+		if !ok {
+			return nil, errUnknownTransformer(name)
+		}
+		p.transformers = append(p.transformers, t)
+	}
+	if envConfig.EventTransformWebhook.IsDefined() {
+		p.webhookURL = envConfig.EventTransformWebhook.String()
+		p.client = httpConfig.Client()
+		p.client.Timeout = webhookTimeout
+	}
+	return p, nil
+}
+
+// Apply transforms a payload of events that was received from an SDK of the specified kind, and returns
+// the events to forward.
+func (p *Pipeline) Apply(sdkKind string, events []json.RawMessage) []json.RawMessage {
+	if p.redactor != nil {
+		redacted := make([]json.RawMessage, 0, len(events))
+		for _, event := range events {
+			redacted = append(redacted, p.redactor.apply(event))
+		}
+		events = redacted
+	}
+	if len(p.transformers) != 0 {
+		ctx := Context{EnvName: p.envName, EnvID: p.envID, SDKKind: sdkKind}
+		transformed := make([]json.RawMessage, 0, len(events))
+		for _, event := range events {
+			for _, t := range p.transformers {
+				if event = t(ctx, event); event == nil {
+					break
+				}
+			}
+			if event != nil {
+				transformed = append(transformed, event)
+			}
+		}
+		events = transformed
+	}
+	if p.webhookURL != "" && len(events) != 0 {
+		transformed, err := p.callWebhook(sdkKind, events)
+		p.handleWebhookResult(err, len(events))
+		if err != nil {
+			return nil
+		}
+		events = transformed
+	}
+	return events
+}
+
+func (p *Pipeline) callWebhook(sdkKind string, events []json.RawMessage) ([]json.RawMessage, error) {
+	data, err := json.Marshal(webhookRequest{EnvName: p.envName, EnvID: p.envID, SDKKind: sdkKind, Events: events})
+	if err != nil { // COVERAGE: can't happen in unit tests
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", p.webhookURL, bytes.NewReader(data))
+	if err != nil { // COVERAGE: can't happen in unit tests
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, errWebhookStatus(resp.StatusCode)
+	}
+	var result webhookResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	return result.Events, nil
+}
+
+// handleWebhookResult logs the first of a series of webhook failures, and the recovery after it, so that
+// an unavailable webhook doesn't cause an error for every payload.
+func (p *Pipeline) handleWebhookResult(err error, count int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err != nil {
+		if !p.failing {
+			p.loggers.Errorf("Event transformation webhook failed; discarding %d event(s), and any others until it succeeds: %s",
+				count, err)
+			p.failing = true
+		}
+		return
+	}
+	if p.failing {
+		p.loggers.Info("Event transformation webhook succeeded; resumed forwarding events")
+		p.failing = false
+	}
+}
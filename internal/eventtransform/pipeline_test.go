@@ -0,0 +1,144 @@
+package eventtransform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() { //nolint:gochecknoinits
+	Register("test-drop-custom", func(ctx Context, event json.RawMessage) json.RawMessage {
+		var fields struct{ Kind string }
+		_ = json.Unmarshal(event, &fields)
+		if fields.Kind == "custom" {
+			return nil
+		}
+		return event
+	})
+	Register("test-add-context", func(ctx Context, event json.RawMessage) json.RawMessage {
+		var fields map[string]interface{}
+		_ = json.Unmarshal(event, &fields)
+		fields["ctx"] = ctx.EnvName + "/" + ctx.EnvID + "/" + ctx.SDKKind
+		data, _ := json.Marshal(fields)
+		return data
+	})
+}
+
+func makeEvents(jsonStrings ...string) []json.RawMessage {
+	ret := make([]json.RawMessage, 0, len(jsonStrings))
+	for _, s := range jsonStrings {
+		ret = append(ret, json.RawMessage(s))
+	}
+	return ret
+}
+
+func makePipeline(t *testing.T, envConfig config.EnvConfig, loggers ldlog.Loggers) *Pipeline {
+	httpConfig, err := httpconfig.NewHTTPConfig(config.ProxyConfig{}, nil, "", loggers)
+	require.NoError(t, err)
+	p, err := NewPipeline(envConfig, "my-env", httpConfig, loggers)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	return p
+}
+
+func TestNewPipelineReturnsNilIfNotConfigured(t *testing.T) {
+	p, err := NewPipeline(config.EnvConfig{}, "my-env", httpconfig.HTTPConfig{}, ldlog.NewDisabledLoggers())
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+}
+
+func TestNewPipelineReturnsErrorForUnknownTransformer(t *testing.T) {
+	envConfig := config.EnvConfig{EventTransformers: configtypes.NewOptStringList([]string{"test-drop-custom", "nope"})}
+	_, err := NewPipeline(envConfig, "my-env", httpconfig.HTTPConfig{}, ldlog.NewDisabledLoggers())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"nope"`)
+	assert.Contains(t, err.Error(), "test-drop-custom")
+}
+
+func TestRegisterPanicsForDuplicateName(t *testing.T) {
+	assert.Panics(t, func() {
+		Register("test-drop-custom", func(ctx Context, event json.RawMessage) json.RawMessage { return event })
+	})
+}
+
+func TestPipelineAppliesTransformersInOrder(t *testing.T) {
+	envConfig := config.EnvConfig{
+		EnvID:             config.EnvironmentID("env-id"),
+		EventTransformers: configtypes.NewOptStringList([]string{"test-drop-custom", "test-add-context"}),
+	}
+	p := makePipeline(t, envConfig, ldlog.NewDisabledLoggers())
+
+	result := p.Apply("mobile", makeEvents(`{"kind":"feature"}`, `{"kind":"custom"}`, `{"kind":"identify"}`))
+	require.Len(t, result, 2)
+	assert.JSONEq(t, `{"kind":"feature","ctx":"my-env/env-id/mobile"}`, string(result[0]))
+	assert.JSONEq(t, `{"kind":"identify","ctx":"my-env/env-id/mobile"}`, string(result[1]))
+}
+
+func TestPipelineRemovesPrivateAttributesBeforeTransformers(t *testing.T) {
+	envConfig := config.EnvConfig{
+		EventTransformers: configtypes.NewOptStringList([]string{"test-add-context"}),
+		PrivateAttributes: configtypes.NewOptStringList([]string{"email"}),
+	}
+	p := makePipeline(t, envConfig, ldlog.NewDisabledLoggers())
+
+	result := p.Apply("server", makeEvents(`{"kind":"identify","user":{"key":"u1","email":"a@b.c"}}`))
+	require.Len(t, result, 1)
+	assert.JSONEq(t, `{"kind":"identify","user":{"key":"u1","privateAttrs":["email"]},"ctx":"my-env//server"}`,
+		string(result[0]))
+}
+
+func TestPipelineCallsWebhookAfterTransformers(t *testing.T) {
+	var received webhookRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		require.NoError(t, json.Unmarshal(body, &received))
+		_, _ = w.Write([]byte(`{"events":[{"kind":"feature","redacted":true}]}`))
+	}))
+	defer server.Close()
+
+	envConfig := config.EnvConfig{EventTransformers: configtypes.NewOptStringList([]string{"test-drop-custom"})}
+	envConfig.EventTransformWebhook, _ = configtypes.NewOptURLAbsoluteFromString(server.URL)
+	p := makePipeline(t, envConfig, ldlog.NewDisabledLoggers())
+
+	result := p.Apply("server", makeEvents(`{"kind":"feature"}`, `{"kind":"custom"}`))
+	assert.Equal(t, "my-env", received.EnvName)
+	assert.Equal(t, "server", received.SDKKind)
+	assert.Equal(t, makeEvents(`{"kind":"feature"}`), received.Events)
+	assert.Equal(t, makeEvents(`{"kind":"feature","redacted":true}`), result)
+}
+
+func TestPipelineDiscardsEventsIfWebhookFails(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"events":[]}`))
+	}))
+	defer server.Close()
+
+	mockLog := ldlogtest.NewMockLog()
+	envConfig := config.EnvConfig{}
+	envConfig.EventTransformWebhook, _ = configtypes.NewOptURLAbsoluteFromString(server.URL)
+	p := makePipeline(t, envConfig, mockLog.Loggers)
+
+	assert.Len(t, p.Apply("server", makeEvents(`{"kind":"feature"}`)), 0)
+	assert.Len(t, p.Apply("server", makeEvents(`{"kind":"feature"}`)), 0)
+	assert.Len(t, mockLog.GetOutput(ldlog.Error), 1)
+	mockLog.AssertMessageMatch(t, true, ldlog.Error, "HTTP status 503")
+
+	status = http.StatusOK
+	assert.Len(t, p.Apply("server", makeEvents(`{"kind":"feature"}`)), 0)
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "resumed forwarding events")
+}
@@ -0,0 +1,126 @@
+package eventtransform
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+// builtInPrivateAttributes are the top-level user properties that SDKs allow to be private. The key,
+// secondary key, and anonymous properties cannot be private.
+var builtInPrivateAttributes = map[string]bool{ //nolint:gochecknoglobals
+	"ip": true, "country": true, "email": true, "firstName": true, "lastName": true, "avatar": true, "name": true,
+}
+
+// redactor removes or hashes the environment's private attributes in the user of each analytics event.
+//
+// When an attribute is removed, its name is added to the user's "privateAttrs" list, just as an SDK does
+// for its own private attributes, so that LaunchDarkly shows that the attribute was there. When it is
+// hashed, its value is replaced by the hex-encoded SHA-256 hash of the value: for a string, the hash of
+// the string itself, so that it can be compared with hashes computed elsewhere, and otherwise the hash of
+// the JSON value.
+type redactor struct {
+	attributes map[string]bool
+	hash       bool
+}
+
+func newRedactor(attributes []string, hash bool) *redactor {
+	if len(attributes) == 0 {
+		return nil
+	}
+	r := &redactor{attributes: make(map[string]bool, len(attributes)), hash: hash}
+	for _, a := range attributes {
+		r.attributes[a] = true
+	}
+	return r
+}
+
+// apply returns the event with its user's private attributes redacted. Events that have no user, or
+// that cannot be parsed, are returned unchanged.
+func (r *redactor) apply(event json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(event, &fields); err != nil || fields["user"] == nil {
+		return event
+	}
+	var user map[string]json.RawMessage
+	if err := json.Unmarshal(fields["user"], &user); err != nil || user == nil {
+		return event
+	}
+	var custom map[string]json.RawMessage
+	if user["custom"] != nil {
+		_ = json.Unmarshal(user["custom"], &custom)
+	}
+	var redacted []string
+	for name := range r.attributes {
+		props := custom
+		if builtInPrivateAttributes[name] {
+			props = user
+		}
+		value, ok := props[name]
+		if !ok || isJSONNull(value) {
+			continue
+		}
+		if r.hash {
+			props[name] = hashJSONValue(value)
+		} else {
+			delete(props, name)
+		}
+		redacted = append(redacted, name)
+	}
+	if len(redacted) == 0 {
+		return event
+	}
+	if custom != nil {
+		user["custom"], _ = json.Marshal(custom)
+	}
+	if !r.hash {
+		user["privateAttrs"] = addPrivateAttrNames(user["privateAttrs"], redacted)
+	}
+	fields["user"], _ = json.Marshal(user)
+	data, err := json.Marshal(fields)
+	if err != nil { // COVERAGE: can't happen in unit tests
+		return event
+	}
+	return data
+}
+
+func isJSONNull(value json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+}
+
+func hashJSONValue(value json.RawMessage) json.RawMessage {
+	var s string
+	data := []byte(value)
+	if err := json.Unmarshal(value, &s); err == nil {
+		data = []byte(s)
+	} else {
+		var buf bytes.Buffer
+		if json.Compact(&buf, value) == nil {
+			data = buf.Bytes()
+		}
+	}
+	sum := sha256.Sum256(data)
+	ret, _ := json.Marshal(hex.EncodeToString(sum[:]))
+	return ret
+}
+
+func addPrivateAttrNames(existing json.RawMessage, names []string) json.RawMessage {
+	var all []string
+	if existing != nil {
+		_ = json.Unmarshal(existing, &all)
+	}
+	seen := make(map[string]bool, len(all))
+	for _, name := range all {
+		seen[name] = true
+	}
+	for _, name := range names {
+		if !seen[name] {
+			all = append(all, name)
+		}
+	}
+	sort.Strings(all)
+	ret, _ := json.Marshal(all)
+	return ret
+}
@@ -0,0 +1,41 @@
+package eventtransform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactorIsNilWithoutAttributes(t *testing.T) {
+	assert.Nil(t, newRedactor(nil, false))
+}
+
+func TestRedactorRemovesAttributes(t *testing.T) {
+	r := newRedactor([]string{"email", "name", "ssn", "missing"}, false)
+	event := json.RawMessage(`{"kind":"identify","user":{"key":"u1","email":"a@b.c","name":"Ann","ip":"1.2.3.4",` +
+		`"custom":{"ssn":"123","plan":"gold"},"privateAttrs":["ip","name"]}}`)
+	assert.JSONEq(t, `{"kind":"identify","user":{"key":"u1","ip":"1.2.3.4","custom":{"plan":"gold"},`+
+		`"privateAttrs":["email","ip","name","ssn"]}}`, string(r.apply(event)))
+}
+
+func TestRedactorHashesAttributes(t *testing.T) {
+	r := newRedactor([]string{"email", "age"}, true)
+	event := json.RawMessage(`{"kind":"custom","key":"e","user":{"key":"u1","email":"a@b.c","custom":{"age": 30}}}`)
+	assert.JSONEq(t, `{"kind":"custom","key":"e","user":{"key":"u1",`+
+		`"email":"d648b243a3e817eaa3309e00e183483f2867baadf522099f0c2121770536b25a",`+
+		`"custom":{"age":"624b60c58c9d8bfb6ff1886c2fd605d2adeb6ea4da576068201b6c6958ce93f4"}}}`,
+		string(r.apply(event)))
+}
+
+func TestRedactorLeavesEventsWithoutAttributesUnchanged(t *testing.T) {
+	r := newRedactor([]string{"email"}, false)
+	for _, s := range []string{
+		`{"kind":"feature","key":"flag1","userKey":"u1"}`,
+		`{"kind":"identify","user":{"key":"u1","name":"Ann"}}`,
+		`{"kind":"identify","user":{"key":"u1","email":null}}`,
+		`not json`,
+	} {
+		assert.Equal(t, s, string(r.apply(json.RawMessage(s))))
+	}
+}
@@ -0,0 +1,67 @@
+package eventtransform
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Transformer is a function that can modify or remove an analytics event before Relay forwards it. The
+// event is the JSON object that an SDK sent, such as a "feature" or "custom" event. Transformer returns
+// the event to forward, which can be the same one unchanged, or nil to discard it.
+//
+// Transformers are called concurrently, from the goroutines that handle SDK requests.
+type Transformer func(ctx Context, event json.RawMessage) json.RawMessage
+
+// Context describes where an event came from.
+type Context struct {
+	// EnvName is the name of the environment in the Relay configuration.
+	EnvName string
+	// EnvID is the environment's client-side ID, if it is configured.
+	EnvID string
+	// SDKKind is "server", "mobile", or "js", depending on which kind of SDK sent the event.
+	SDKKind string
+}
+
+var (
+	registry     = make(map[string]Transformer) //nolint:gochecknoglobals
+	registryLock sync.RWMutex                   //nolint:gochecknoglobals
+)
+
+func errUnknownTransformer(name string) error {
+	return fmt.Errorf("event transformer %q has not been registered (registered: %v)", name, registeredNames())
+}
+
+// Register makes a Transformer available for use in the eventTransformers setting of an environment.
+// It panics if name is already registered or if transformer is nil, since these are programming errors
+// in the application that embeds Relay.
+func Register(name string, transformer Transformer) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if transformer == nil {
+		panic("eventtransform: Register transformer is nil")
+	}
+	if _, exists := registry[name]; exists {
+		panic("eventtransform: Register called twice for transformer " + name)
+	}
+	registry[name] = transformer
+}
+
+func lookup(name string) (Transformer, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	t, ok := registry[name]
+	return t, ok
+}
+
+func registeredNames() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
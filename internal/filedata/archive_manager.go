@@ -2,7 +2,10 @@ package filedata
 
 import (
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +20,8 @@ const (
 	maxRetryDuration     = time.Second * 2
 )
 
+var archiveFileSuffixes = []string{".tar.gz", ".tgz", ".tar"} //nolint:gochecknoglobals
+
 // ArchiveManager manages the file data source.
 //
 // That includes reading and unarchiving the data file, watching for changes to the file, and maintaining the
@@ -24,8 +29,12 @@ const (
 //
 // Relay provides an implementation of the UpdateHandler interface which will be called for all changes that
 // it needs to know about.
+//
+// The file data source can also be a directory, in which case ArchiveManager reads the most recently
+// modified archive file in it, and switches to any newer archive file that is added to the directory.
 type ArchiveManager struct {
-	filePath      string
+	filePath      string // in directory mode, this is the archive file that we are currently using
+	dirPath       string // non-empty if we are in directory mode
 	handler       UpdateHandler
 	retryInterval time.Duration
 	lastKnownEnvs map[config.EnvironmentID]environmentMetadata
@@ -44,7 +53,8 @@ type ArchiveManagerInterface interface {
 // NewArchiveManager creates the ArchiveManager instance and attempts to read the initial file data.
 //
 // If successful, it calls handler.AddEnvironment() for each environment configured in the file, and also
-// starts a file watcher to detect updates to the file.
+// starts a file watcher to detect updates to the file. If filePath is a directory, the watcher detects
+// updates to any file in the directory.
 func NewArchiveManager(
 	filePath string,
 	handler UpdateHandler,
@@ -69,7 +79,17 @@ func NewArchiveManager(
 	}
 	am.loggers.SetPrefix("[FileDataSource]")
 
-	ar, err := newArchiveReader(filePath)
+	if fileInfo.IsDir() {
+		archivePath, archiveInfo, ok := findNewestArchiveFile(filePath)
+		if !ok {
+			return nil, errNoArchiveFileInDirectory(filePath)
+		}
+		am.dirPath = filePath
+		am.filePath, fileInfo = archivePath, archiveInfo
+		am.loggers.Infof(logMsgUsingArchiveFile, archivePath)
+	}
+
+	ar, err := newArchiveReader(am.filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -124,14 +144,25 @@ func (am *ArchiveManager) monitorForChanges(originalFileInfo os.FileInfo) {
 	}
 
 	maybeReload := func() {
+		if am.dirPath != "" {
+			if newestPath, _, ok := findNewestArchiveFile(am.dirPath); ok && newestPath != am.filePath {
+				// A newer archive file has been added to the directory; we'll read it even if it happens to
+				// have the same mod time and size as the previous one.
+				am.loggers.Infof(logMsgUsingArchiveFile, newestPath)
+				am.filePath = newestPath
+				lastFileInfo = nil
+			}
+		}
 		curFileInfo, err := os.Stat(am.filePath)
 		if err == nil {
-			if fileMayHaveChanged(curFileInfo, lastFileInfo) {
+			if lastFileInfo == nil || fileMayHaveChanged(curFileInfo, lastFileInfo) {
 				// If the file's mod time or size has changed, we will always try to reload.
 				firstRetryTime = time.Time{}
 				lastError = nil
-				am.loggers.Debugf("File info changed: old (size=%d, mtime=%s), new(size=%d, mtime=%s)",
-					lastFileInfo.Size(), lastFileInfo.ModTime(), curFileInfo.Size(), curFileInfo.ModTime())
+				if lastFileInfo != nil {
+					am.loggers.Debugf("File info changed: old (size=%d, mtime=%s), new(size=%d, mtime=%s)",
+						lastFileInfo.Size(), lastFileInfo.ModTime(), curFileInfo.Size(), curFileInfo.ModTime())
+				}
 				lastFileInfo = curFileInfo
 				ar, err := newArchiveReader(am.filePath)
 				if err != nil {
@@ -262,3 +293,38 @@ func (am *ArchiveManager) updatedArchive(ar *archiveReader) {
 func fileMayHaveChanged(oldInfo, newInfo os.FileInfo) bool {
 	return oldInfo.ModTime() != newInfo.ModTime() || oldInfo.Size() != newInfo.Size()
 }
+
+// findNewestArchiveFile returns the most recently modified archive file in a directory, if any. Files
+// whose names begin with "." are ignored, since those are often temporary files that are still being
+// written. If several files have the same mod time, the one whose name sorts last is used.
+func findNewestArchiveFile(dirPath string) (string, os.FileInfo, bool) {
+	files, err := ioutil.ReadDir(dirPath) // sorted by name
+	if err != nil {
+		return "", nil, false
+	}
+	var newest os.FileInfo
+	for _, f := range files {
+		if f.IsDir() || !isArchiveFileName(f.Name()) {
+			continue
+		}
+		if newest == nil || !f.ModTime().Before(newest.ModTime()) {
+			newest = f
+		}
+	}
+	if newest == nil {
+		return "", nil, false
+	}
+	return filepath.Join(dirPath, newest.Name()), newest, true
+}
+
+func isArchiveFileName(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	for _, suffix := range archiveFileSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -252,3 +254,77 @@ func requireLogMessage(t *testing.T, mockLog *ldlogtest.MockLog, level ldlog.Log
 		return false
 	}, time.Second*5, time.Millisecond*100, "wanted log message (%s) containing %q", level, expectedSubstring)
 }
+
+func writeArchiveInDir(t *testing.T, dirPath, name string, modTime time.Time, envs ...testEnv) string {
+	filePath := filepath.Join(dirPath, name)
+	writeArchive(t, filePath, strings.HasSuffix(name, "gz"), nil, envs...)
+	require.NoError(t, os.Chtimes(filePath, modTime, modTime))
+	return filePath
+}
+
+func TestStartWithDirectoryUsesNewestArchiveFile(t *testing.T) {
+	now := time.Now()
+	archiveManagerDirTest(t, func(dirPath string) string {
+		writeArchiveInDir(t, dirPath, "old.tar.gz", now.Add(-time.Hour), testEnv1)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dirPath, "notes.txt"), []byte("x"), 0600))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dirPath, ".partial.tar.gz"), []byte("x"), 0600))
+		return writeArchiveInDir(t, dirPath, "new.tar", now.Add(-time.Minute), testEnv1, testEnv2)
+	}, func(p archiveManagerTestParams) {
+		require.NoError(t, p.archiveManagerError)
+
+		p.expectEnvironmentsAdded(testEnv1, testEnv2)
+		p.mockLog.AssertMessageMatch(t, true, ldlog.Info, regexp.QuoteMeta("Using data file "+p.filePath))
+	})
+}
+
+func TestStartWithDirectoryWithNoArchiveFiles(t *testing.T) {
+	archiveManagerDirTest(t, func(dirPath string) string {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dirPath, "notes.txt"), []byte("x"), 0600))
+		return ""
+	}, func(p archiveManagerTestParams) {
+		require.Error(t, p.archiveManagerError)
+		assert.Contains(t, p.archiveManagerError.Error(), "does not contain any")
+	})
+}
+
+func TestDirectoryWithNewArchiveFile(t *testing.T) {
+	now := time.Now()
+	var dir string
+	archiveManagerDirTest(t, func(dirPath string) string {
+		dir = dirPath
+		return writeArchiveInDir(t, dirPath, "data-1.tar.gz", now.Add(-time.Hour), testEnv1, testEnv2)
+	}, func(p archiveManagerTestParams) {
+		require.NoError(t, p.archiveManagerError)
+
+		p.expectEnvironmentsAdded(testEnv1, testEnv2)
+
+		testEnv1a := testEnv1.withSDKDataChange()
+		p.filePath = writeArchiveInDir(t, dir, "data-2.tar.gz", now, testEnv1a)
+
+		messages := []testMessage{p.requireMessage(), p.requireMessage()}
+		p.requireNoMoreMessages()
+		messages = sortMessages(messages)
+		require.NotNil(t, messages[0].update)
+		verifyEnvironmentData(t, testEnv1a, *messages[0].update)
+		require.NotNil(t, messages[1].delete)
+		assert.Equal(t, testEnv2.id(), *messages[1].delete)
+		p.expectReloaded()
+	})
+}
+
+func TestDirectoryIgnoresOlderArchiveFile(t *testing.T) {
+	now := time.Now()
+	var dir string
+	archiveManagerDirTest(t, func(dirPath string) string {
+		dir = dirPath
+		return writeArchiveInDir(t, dirPath, "data-2.tar.gz", now, testEnv1)
+	}, func(p archiveManagerTestParams) {
+		require.NoError(t, p.archiveManagerError)
+
+		p.expectEnvironmentsAdded(testEnv1)
+
+		writeArchiveInDir(t, dir, "data-1.tar.gz", now.Add(-time.Hour), testEnv1, testEnv2)
+
+		p.requireNoMoreMessages()
+	})
+}
@@ -70,6 +70,32 @@ func archiveManagerTest(t *testing.T, setupFile func(filePath string), action fu
 	})
 }
 
+// archiveManagerDirTest is like archiveManagerTest, but the file data source is a directory. The
+// filePath in the test parameters is the archive file that is expected to be read at startup.
+func archiveManagerDirTest(t *testing.T, setupDir func(dirPath string) string, action func(p archiveManagerTestParams)) {
+	dirPath := t.TempDir()
+	filePath := setupDir(dirPath)
+
+	mockLog := ldlogtest.NewMockLog()
+	mockLog.Loggers.SetMinLevel(ldlog.Debug)
+	defer mockLog.DumpIfTestFailed(t)
+
+	messageHandler := newTestMessageHandler()
+
+	archiveManager, err := NewArchiveManager(
+		dirPath,
+		messageHandler,
+		testRetryInterval,
+		mockLog.Loggers,
+	)
+	if archiveManager != nil {
+		defer archiveManager.Close()
+	}
+
+	params := archiveManagerTestParams{t, filePath, archiveManager, err, messageHandler, mockLog}
+	action(params)
+}
+
 func (m testMessage) String() string {
 	if m.add != nil {
 		return fmt.Sprintf("add(%+v)", *m.add)
@@ -0,0 +1,162 @@
+package filedata
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/md5" //nolint:gosec // we're not using this weak algorithm for authentication, only for detecting file changes
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	"github.com/launchdarkly/ld-relay/v6/internal/envfactory"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+// ArchiveContentType is the MIME type of the archives that are written by WriteArchive.
+const ArchiveContentType = "application/gzip"
+
+// EnvironmentSnapshot is the properties and data of an environment, for writing with WriteArchive.
+type EnvironmentSnapshot struct {
+	Rep     envfactory.EnvironmentRep
+	SDKData []ldstoretypes.Collection
+}
+
+// MakeEnvironmentSnapshot gets the current properties and flag/segment data of an environment. It
+// returns an error if the environment does not have an environment ID, since that is how environments
+// are identified in an archive, or if its data store has not been initialized yet.
+func MakeEnvironmentSnapshot(env relayenv.EnvContext) (EnvironmentSnapshot, error) {
+	var rep envfactory.EnvironmentRep
+	for _, c := range env.GetCredentials() {
+		switch c := c.(type) {
+		case config.SDKKey:
+			rep.SDKKey.Value = c
+		case config.MobileKey:
+			rep.MobKey = c
+		case config.EnvironmentID:
+			rep.EnvID = c
+		}
+	}
+	if rep.EnvID == "" {
+		return EnvironmentSnapshot{}, ErrSnapshotWithoutEnvID
+	}
+	ids := env.GetIdentifiers()
+	rep.EnvKey, rep.EnvName, rep.ProjKey, rep.ProjName = ids.EnvKey, ids.EnvName, ids.ProjKey, ids.ProjName
+	rep.DefaultTTL = int(env.GetTTL() / time.Minute)
+	rep.SecureMode = env.IsSecureMode()
+
+	store := env.GetStore()
+	if store == nil || !store.IsInitialized() {
+		return EnvironmentSnapshot{}, ErrSnapshotNotInitialized
+	}
+	snapshot := EnvironmentSnapshot{Rep: rep}
+	for _, kind := range []ldstoretypes.DataKind{ldstoreimpl.Features(), ldstoreimpl.Segments()} {
+		items, err := store.GetAll(kind)
+		if err != nil {
+			return EnvironmentSnapshot{}, err
+		}
+		snapshot.SDKData = append(snapshot.SDKData, ldstoretypes.Collection{Kind: kind, Items: items})
+	}
+	return snapshot, nil
+}
+
+// WriteArchive writes a gzip-compressed archive of the environments, in the format that ArchiveManager
+// reads, so that the data from a Relay instance that is connected to LaunchDarkly can be used by one
+// that is in offline mode.
+//
+// The archive does not say what version of the environment properties it contains, so the version is
+// computed from the properties; a Relay instance that reads a newer archive with the same version can
+// skip updating those properties.
+func WriteArchive(w io.Writer, envs []EnvironmentSnapshot) error {
+	files := make(map[string][]byte)
+	for _, env := range envs {
+		data, err := json.Marshal(makeSDKDataRep(env.SDKData))
+		if err != nil {
+			return err // COVERAGE: can't cause this condition in unit tests
+		}
+		dataHash := md5.Sum(data) //nolint:gosec // see above
+		rep := env.Rep
+		rep.Version = 0
+		repJSON, err := json.Marshal(rep)
+		if err != nil {
+			return err // COVERAGE: can't cause this condition in unit tests
+		}
+		versionHash := fnv.New32a()
+		_, _ = versionHash.Write(repJSON)
+		rep.Version = int(versionHash.Sum32() & 0x7fffffff)
+		metadata, err := json.Marshal(archiveEnvironmentRep{Env: rep, DataID: hex.EncodeToString(dataHash[:])})
+		if err != nil {
+			return err // COVERAGE: can't cause this condition in unit tests
+		}
+		files[filepath.Base(envMetadataFilePath("", rep.EnvID))] = metadata
+		files[filepath.Base(envSDKDataFilePath("", rep.EnvID))] = data
+	}
+
+	// The checksum is computed over the environment files in order of their names, as in
+	// computeEnvironmentsChecksum.
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := md5.New() //nolint:gosec // see above
+	for _, name := range names {
+		_, _ = h.Write(files[name])
+	}
+	files[environmentsChecksumFileName] = h.Sum(nil)
+	names = append(names, environmentsChecksumFileName)
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	modTime := time.Now()
+	for _, name := range names {
+		content := files[name]
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0600,
+			Size:     int64(len(content)),
+			ModTime:  modTime,
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// makeSDKDataRep converts the data to the same JSON format that is used by the "/all" stream, omitting
+// any deleted items.
+func makeSDKDataRep(data []ldstoretypes.Collection) map[string]map[string]json.RawMessage {
+	ret := map[string]map[string]json.RawMessage{"flags": {}, "segments": {}}
+	for _, coll := range data {
+		var kindName string
+		switch coll.Kind.GetName() {
+		case ldstoreimpl.Features().GetName():
+			kindName = "flags"
+		case ldstoreimpl.Segments().GetName():
+			kindName = "segments"
+		default:
+			continue
+		}
+		for _, item := range coll.Items {
+			if item.Item.Item == nil {
+				continue
+			}
+			ret[kindName][item.Key] = coll.Kind.Serialize(item.Item)
+		}
+	}
+	return ret
+}
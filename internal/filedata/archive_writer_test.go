@@ -0,0 +1,110 @@
+package filedata
+
+import (
+	"os"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/envfactory"
+
+	helpers "github.com/launchdarkly/go-test-helpers/v2"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeTestSnapshot(envID config.EnvironmentID, envName string) EnvironmentSnapshot {
+	flag := ldbuilders.NewFlagBuilder("flag1").Version(2).On(true).Build()
+	segment := ldbuilders.NewSegmentBuilder("segment1").Version(3).Build()
+	return EnvironmentSnapshot{
+		Rep: envfactory.EnvironmentRep{
+			EnvID:      envID,
+			EnvKey:     "env-key",
+			EnvName:    envName,
+			ProjKey:    "proj-key",
+			ProjName:   "proj-name",
+			SDKKey:     envfactory.SDKKeyRep{Value: config.SDKKey("sdk-key-" + string(envID))},
+			DefaultTTL: 2,
+		},
+		SDKData: []ldstoretypes.Collection{
+			{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedItemDescriptor{
+				{Key: "flag1", Item: ldstoretypes.ItemDescriptor{Version: 2, Item: &flag}},
+				{Key: "deleted-flag", Item: ldstoretypes.ItemDescriptor{Version: 5, Item: nil}},
+			}},
+			{Kind: ldstoreimpl.Segments(), Items: []ldstoretypes.KeyedItemDescriptor{
+				{Key: "segment1", Item: ldstoretypes.ItemDescriptor{Version: 3, Item: &segment}},
+			}},
+		},
+	}
+}
+
+func writeAndReadArchive(t *testing.T, envs ...EnvironmentSnapshot) map[config.EnvironmentID]environmentMetadata {
+	var ret map[config.EnvironmentID]environmentMetadata
+	helpers.WithTempFile(func(filePath string) {
+		f, err := os.Create(filePath)
+		require.NoError(t, err)
+		require.NoError(t, WriteArchive(f, envs))
+		require.NoError(t, f.Close())
+
+		ar, err := newArchiveReader(filePath)
+		require.NoError(t, err)
+		defer ar.Close()
+
+		ret = make(map[config.EnvironmentID]environmentMetadata)
+		for _, envID := range ar.GetEnvironmentIDs() {
+			metadata, err := ar.GetEnvironmentMetadata(envID)
+			require.NoError(t, err)
+			ret[envID] = metadata
+			data, err := ar.GetEnvironmentSDKData(envID)
+			require.NoError(t, err)
+			keys := make(map[string]int)
+			for _, coll := range data {
+				for _, item := range coll.Items {
+					keys[item.Key] = item.Item.Version
+				}
+			}
+			assert.Equal(t, map[string]int{"flag1": 2, "segment1": 3}, keys)
+		}
+	})
+	return ret
+}
+
+func TestWriteArchiveCanBeRead(t *testing.T) {
+	env1, env2 := makeTestSnapshot("env1", "first"), makeTestSnapshot("env2", "second")
+	envs := writeAndReadArchive(t, env1, env2)
+	require.Len(t, envs, 2)
+	assert.Equal(t, env1.Rep.ToParams(), envs["env1"].params)
+	assert.Equal(t, env2.Rep.ToParams(), envs["env2"].params)
+	assert.NotEqual(t, "", envs["env1"].dataID)
+}
+
+func TestWriteArchiveWithNoEnvironments(t *testing.T) {
+	assert.Len(t, writeAndReadArchive(t), 0)
+}
+
+func TestWriteArchiveVersionAndDataIDOnlyChangeWithContent(t *testing.T) {
+	env := makeTestSnapshot("env1", "first")
+	first := writeAndReadArchive(t, env)["env1"]
+	assert.Equal(t, first, writeAndReadArchive(t, env)["env1"])
+
+	renamed := env
+	renamed.Rep.EnvName = "renamed"
+	second := writeAndReadArchive(t, renamed)["env1"]
+	assert.NotEqual(t, first.version, second.version)
+	assert.Equal(t, first.dataID, second.dataID)
+
+	changed := env
+	flag := ldbuilders.NewFlagBuilder("flag1").Version(2).On(false).Build()
+	changed.SDKData = []ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedItemDescriptor{
+			{Key: "flag1", Item: ldstoretypes.ItemDescriptor{Version: 2, Item: &flag}},
+		}},
+		env.SDKData[1],
+	}
+	third := writeAndReadArchive(t, changed)["env1"]
+	assert.Equal(t, first.version, third.version)
+	assert.NotEqual(t, first.dataID, third.dataID)
+}
@@ -1,6 +1,9 @@
 package filedata
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // All log messages, error singletons, and error constructors for this package should be collected here,
 // except for debug logging.
@@ -17,6 +20,15 @@ const (
 	logMsgReloadUnchangedRetry         = "Data file has not changed since last failure, will wait in case it is still being copied"
 	logMsgReloadUnchangedNoMoreRetries = "Data file reload failed, and no further changes were detected; giving up until next change (error: %s)"
 	logMsgReloadWillRetry              = "Will retry in %s"
+	logMsgUsingArchiveFile             = "Using data file %s"
+)
+
+var (
+	// ErrSnapshotWithoutEnvID is returned by MakeEnvironmentSnapshot if the environment has no environment ID.
+	ErrSnapshotWithoutEnvID = errors.New("environment must have an environment ID to be written to an archive")
+
+	// ErrSnapshotNotInitialized is returned by MakeEnvironmentSnapshot if the environment has no data yet.
+	ErrSnapshotNotInitialized = errors.New("environment data has not been received from LaunchDarkly yet")
 )
 
 func errBadItemJSON(key, namespace string) error {
@@ -27,6 +39,10 @@ func errCannotOpenArchiveFile(filePath string, err error) error {
 	return fmt.Errorf("unable to read file data source %s: %w", filePath, err)
 }
 
+func errNoArchiveFileInDirectory(dirPath string) error {
+	return fmt.Errorf("file data source directory %s does not contain any .tar.gz, .tgz, or .tar files", dirPath)
+}
+
 func errCreateArchiveManagerFailed(filePath string, err error) error { // COVERAGE: can't cause this condition in unit tests
 	return fmt.Errorf("unable to initialize archive manager for %q: %w", filePath, err)
 }
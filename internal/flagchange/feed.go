@@ -0,0 +1,105 @@
+package flagchange
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/eventsource"
+)
+
+const (
+	feedChannel   = "changes"
+	feedEventName = "change"
+)
+
+// FeedChange is the data of each "change" event in the change feed. Unlike the SDK streams, it does not
+// include the flag or segment itself, so consumers of the feed do not need to be trusted with flag rules.
+type FeedChange struct {
+	Kind    string `json:"kind"`
+	Key     string `json:"key"`
+	Version int    `json:"version"`
+	Action  Action `json:"action"`
+}
+
+// changeFeed is the server-sent event stream of an environment's changes. Each request must have an
+// Authorization header with the configured token, as a bearer token.
+type changeFeed struct {
+	server    *eventsource.Server
+	token     []byte
+	closer    chan struct{}
+	closeOnce sync.Once
+}
+
+type feedEvent struct {
+	data string
+}
+
+func newChangeFeed(token string, heartbeatInterval time.Duration) *changeFeed {
+	server := eventsource.NewServer()
+	server.Gzip = false
+	f := &changeFeed{server: server, token: []byte(token), closer: make(chan struct{})}
+	if heartbeatInterval > 0 {
+		go f.sendHeartbeats(heartbeatInterval)
+	}
+	return f
+}
+
+func (f *changeFeed) publish(nt Notification) {
+	data, err := json.Marshal(FeedChange{Kind: nt.Kind, Key: nt.Key, Version: nt.Version, Action: nt.Action})
+	if err != nil { // COVERAGE: can't happen in unit tests
+		return
+	}
+	f.server.Publish([]string{feedChannel}, feedEvent{data: string(data)})
+}
+
+func (f *changeFeed) handler(streamMiddleware func(http.Handler) http.Handler) http.Handler {
+	var stream http.Handler = f.server.Handler(feedChannel)
+	if streamMiddleware != nil {
+		stream = streamMiddleware(stream)
+	}
+	return RequireFeedToken(string(f.token), stream)
+}
+
+// RequireFeedToken returns a handler that rejects a request with a 401 error unless it has the change
+// feed token as a bearer token, before passing it to h. The change feed's own handler already does this;
+// it is exported so that a lazily initialized environment can check the token before it is activated.
+func RequireFeedToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+func (f *changeFeed) sendHeartbeats(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.server.PublishComment([]string{feedChannel}, "")
+		case <-f.closer:
+			return
+		}
+	}
+}
+
+// close disconnects all of the clients.
+func (f *changeFeed) close() {
+	f.closeOnce.Do(func() {
+		close(f.closer)
+		f.server.Close()
+	})
+}
+
+func (e feedEvent) Event() string { return feedEventName }
+func (e feedEvent) Id() string    { return "" } //nolint:golint,stylecheck
+func (e feedEvent) Data() string  { return e.data }
@@ -0,0 +1,139 @@
+package flagchange
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testFeedToken = "feed-token"
+
+func makeTestFeedNotifier(t *testing.T, heartbeatInterval time.Duration) *Notifier {
+	envConfig := config.EnvConfig{EnvID: "env-id", ChangeFeedToken: testFeedToken}
+	n := NewNotifier(envConfig, "my-env", "", httpconfig.HTTPConfig{}, heartbeatInterval, ldlog.NewDisabledLoggers())
+	require.NotNil(t, n)
+	return n
+}
+
+func subscribeToFeed(t *testing.T, server *httptest.Server, auth string) *http.Response {
+	req, err := http.NewRequest("GET", server.URL, nil)
+	require.NoError(t, err)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+// readFeedLines reads the stream in the background, so that a test can't hang if the expected
+// line never arrives.
+func readFeedLines(resp *http.Response) <-chan string {
+	linesCh := make(chan string, 100)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			linesCh <- scanner.Text()
+		}
+		close(linesCh)
+	}()
+	return linesCh
+}
+
+func expectFeedLine(t *testing.T, linesCh <-chan string, prefix string) string {
+	deadline := time.After(time.Second * 5)
+	for {
+		select {
+		case line, ok := <-linesCh:
+			require.True(t, ok, "stream closed while waiting for %q", prefix)
+			if strings.HasPrefix(line, prefix) {
+				return strings.TrimPrefix(line, prefix)
+			}
+		case <-deadline:
+			require.Fail(t, "timed out waiting for stream line", prefix)
+			return ""
+		}
+	}
+}
+
+func TestChangeFeedHandlerIsNilIfNotConfigured(t *testing.T) {
+	var n *Notifier
+	assert.Nil(t, n.ChangeFeedHandler(nil))
+
+	n = makeTestNotifier(t, "http://localhost")
+	defer n.Close()
+	assert.Nil(t, n.ChangeFeedHandler(nil))
+}
+
+func TestChangeFeedRejectsRequestsWithoutToken(t *testing.T) {
+	n := makeTestFeedNotifier(t, 0)
+	defer n.Close()
+	server := httptest.NewServer(n.ChangeFeedHandler(nil))
+	defer server.Close()
+
+	for _, auth := range []string{"", "Bearer wrong-token", testFeedToken, "Basic " + testFeedToken} {
+		t.Run(auth, func(t *testing.T) {
+			resp := subscribeToFeed(t, server, auth)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+	}
+}
+
+func TestChangeFeedSendsChangesWithoutItemProperties(t *testing.T) {
+	n := makeTestFeedNotifier(t, 0)
+	server := httptest.NewServer(n.ChangeFeedHandler(nil))
+	defer server.Close()
+	updates := n.WrapUpdates(&recordingUpdates{})
+	updates.SendAllDataUpdate(allData(ldbuilders.NewFlagBuilder("a").Version(1)))
+
+	resp := subscribeToFeed(t, server, "Bearer "+testFeedToken)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	linesCh := readFeedLines(resp)
+	defer n.Close()
+
+	// the stream's response headers are sent before the subscription is registered, so keep sending
+	// updates until one of them arrives
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for version := 2; ; version++ {
+			updates.SendSingleItemUpdate(ldstoreimpl.Features(), "a", flagItem(ldbuilders.NewFlagBuilder("a").
+				Version(version).On(true).AddRule(ldbuilders.NewRuleBuilder().ID("r").Variation(0))))
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond * 20):
+			}
+		}
+	}()
+
+	assert.Equal(t, "change", expectFeedLine(t, linesCh, "event: "))
+	data := expectFeedLine(t, linesCh, "data: ")
+	assert.Regexp(t, `^{"kind":"flag","key":"a","version":\d+,"action":"updated"}$`, data)
+}
+
+func TestChangeFeedSendsHeartbeats(t *testing.T) {
+	n := makeTestFeedNotifier(t, time.Millisecond*10)
+	defer n.Close()
+	server := httptest.NewServer(n.ChangeFeedHandler(nil))
+	defer server.Close()
+
+	resp := subscribeToFeed(t, server, "Bearer "+testFeedToken)
+	defer resp.Body.Close()
+	expectFeedLine(t, readFeedLines(resp), ":")
+}
@@ -0,0 +1,334 @@
+package flagchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/streams"
+	"github.com/launchdarkly/ld-relay/v6/internal/surrogatekeys"
+	"github.com/launchdarkly/ld-relay/v6/internal/webhook"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+// Action is the kind of change that a Notification describes.
+type Action string
+
+const (
+	// ActionCreated means that a flag or segment was added.
+	ActionCreated Action = "created"
+
+	// ActionUpdated means that a flag or segment was changed.
+	ActionUpdated Action = "updated"
+
+	// ActionDeleted means that a flag or segment was deleted.
+	ActionDeleted Action = "deleted"
+
+	kindFlag    = "flag"
+	kindSegment = "segment"
+
+	webhookMaxAttempts = 5
+)
+
+// Notification is the JSON body of each webhook request.
+//
+// Changes is the diff summary: the names of the top-level properties whose values are different from
+// the previous version, such as "on", "rules", or "fallthrough" for a flag, or "included" for a segment.
+// It is empty for ActionCreated and ActionDeleted.
+//
+// SurrogateKeys are the surrogate keys of the Relay responses that may have changed, which can be purged
+// from a CDN or other HTTP cache in front of Relay.
+type Notification struct {
+	Time            string   `json:"time"`
+	Env             string   `json:"env"`
+	EnvID           string   `json:"envId,omitempty"`
+	Kind            string   `json:"kind"`
+	Key             string   `json:"key"`
+	Action          Action   `json:"action"`
+	Version         int      `json:"version"`
+	PreviousVersion int      `json:"previousVersion,omitempty"`
+	Changes         []string `json:"changes,omitempty"`
+	SurrogateKeys   []string `json:"surrogateKeys,omitempty"`
+}
+
+// Notifier calls an environment's flag change webhooks, and publishes its change feed. It is an
+// EnvStreamUpdates decorator (see WrapUpdates), so it sees the same updates that Relay broadcasts to
+// connected SDKs.
+//
+// The first full data set that Relay receives only establishes what the flags and segments were, since
+// it is not a change; Relay does not remember them when it restarts. After that, a full data set, such
+// as when the stream to LaunchDarkly reconnects, causes a notification for each item whose version is
+// different. A single-item update causes a notification only if its version is higher than the last
+// one that we saw, so duplicate and out-of-order updates are ignored.
+//
+// All of its methods can be called on a nil *Notifier, so callers do not need to check whether any
+// webhooks or a change feed are configured.
+type Notifier struct {
+	envName     string
+	envID       string
+	envKey      string // for surrogate keys
+	webhooks    []*webhook.Sender
+	feed        *changeFeed
+	items       map[ldstoretypes.DataKind]map[string]itemState
+	initialized bool
+	now         func() time.Time
+	loggers     ldlog.Loggers
+	lock        sync.Mutex
+}
+
+type itemState struct {
+	version    int
+	properties map[string]json.RawMessage // nil if the item is deleted
+}
+
+type notifyingUpdates struct {
+	target   streams.EnvStreamUpdates
+	notifier *Notifier
+}
+
+// NewNotifier creates a Notifier for an environment, or returns nil if the environment has neither flag
+// change webhooks nor a change feed token configured. Comments are sent on the change feed at
+// heartbeatInterval to keep its connections alive, if it is greater than zero.
+func NewNotifier(
+	envConfig config.EnvConfig,
+	envName string,
+	userAgent string,
+	httpConfig httpconfig.HTTPConfig,
+	heartbeatInterval time.Duration,
+	loggers ldlog.Loggers,
+) *Notifier {
+	urls := envConfig.FlagChangeWebhook.Values()
+	if len(urls) == 0 && envConfig.ChangeFeedToken == "" {
+		return nil
+	}
+	n := newNotifierInternal(envName, string(envConfig.EnvID), loggers)
+	n.envKey = surrogatekeys.EnvKey(envConfig.EnvID, envConfig.SDKKey)
+	if len(urls) != 0 {
+		options := webhook.Options{
+			Description: "flag change notifications",
+			Headers:     make(http.Header),
+			Secret:      envConfig.FlagChangeWebhookSecret,
+			Client:      httpConfig.Client(),
+			MaxAttempts: webhookMaxAttempts,
+		}
+		options.Client.Timeout = webhook.DefaultTimeout
+		options.Headers.Set("User-Agent", userAgent)
+		for _, u := range urls {
+			n.webhooks = append(n.webhooks, webhook.NewSender(u, options, loggers))
+		}
+	}
+	if envConfig.ChangeFeedToken != "" {
+		n.feed = newChangeFeed(envConfig.ChangeFeedToken, heartbeatInterval)
+	}
+	return n
+}
+
+func newNotifierInternal(envName, envID string, loggers ldlog.Loggers) *Notifier {
+	return &Notifier{
+		envName: envName,
+		envID:   envID,
+		envKey:  surrogatekeys.EnvKey(config.EnvironmentID(envID), ""),
+		items:   make(map[ldstoretypes.DataKind]map[string]itemState),
+		now:     time.Now,
+		loggers: loggers,
+	}
+}
+
+// WrapUpdates returns an EnvStreamUpdates that passes all updates on to target, and then notifies the
+// webhooks of any changes. If n is nil, it returns target unchanged.
+func (n *Notifier) WrapUpdates(target streams.EnvStreamUpdates) streams.EnvStreamUpdates {
+	if n == nil {
+		return target
+	}
+	return &notifyingUpdates{target: target, notifier: n}
+}
+
+// ChangeFeedHandler returns the HTTP handler for the environment's change feed, or nil if it does not
+// have one. If streamMiddleware is not nil, it is applied to the stream once the request's token has
+// been checked.
+func (n *Notifier) ChangeFeedHandler(streamMiddleware func(http.Handler) http.Handler) http.Handler {
+	if n == nil || n.feed == nil {
+		return nil
+	}
+	return n.feed.handler(streamMiddleware)
+}
+
+// Close sends any notifications that are waiting, without retrying failures, and disconnects the
+// clients of the change feed.
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+	for _, w := range n.webhooks {
+		w.Close()
+	}
+	if n.feed != nil {
+		n.feed.close()
+	}
+}
+
+func (u *notifyingUpdates) SendAllDataUpdate(allData []ldstoretypes.Collection) {
+	u.target.SendAllDataUpdate(allData)
+	u.notifier.allDataUpdated(allData)
+}
+
+func (u *notifyingUpdates) SendSingleItemUpdate(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
+	u.target.SendSingleItemUpdate(kind, key, item)
+	u.notifier.itemUpdated(kind, key, item)
+}
+
+func (u *notifyingUpdates) InvalidateClientSideState() {
+	u.target.InvalidateClientSideState()
+}
+
+func (n *Notifier) allDataUpdated(allData []ldstoretypes.Collection) {
+	n.lock.Lock()
+	var notifications []Notification
+	notify := n.initialized
+	n.initialized = true
+	for _, coll := range allData {
+		seen := make(map[string]struct{}, len(coll.Items))
+		for _, item := range coll.Items {
+			seen[item.Key] = struct{}{}
+			if nt, ok := n.setItem(coll.Kind, item.Key, item.Item, false); ok && notify {
+				notifications = append(notifications, nt)
+			}
+		}
+		for key, state := range n.items[coll.Kind] {
+			if _, ok := seen[key]; ok || state.properties == nil {
+				continue
+			}
+			deleted := ldstoretypes.ItemDescriptor{Version: state.version + 1, Item: nil}
+			if nt, ok := n.setItem(coll.Kind, key, deleted, false); ok && notify {
+				notifications = append(notifications, nt)
+			}
+		}
+	}
+	n.lock.Unlock()
+	n.send(notifications)
+}
+
+func (n *Notifier) itemUpdated(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
+	n.lock.Lock()
+	nt, ok := n.setItem(kind, key, item, true)
+	n.lock.Unlock()
+	if ok {
+		n.send([]Notification{nt})
+	}
+}
+
+// setItem must be called while holding the lock. It records the new state of an item, and returns the
+// notification for the change, if there was one. If newerOnly is true, the item is ignored unless its
+// version is higher than the one we have; otherwise, any different version is a change.
+func (n *Notifier) setItem(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.ItemDescriptor,
+	newerOnly bool,
+) (Notification, bool) {
+	kindName, ok := kindNames(kind)
+	if !ok {
+		return Notification{}, false
+	}
+	items := n.items[kind]
+	if items == nil {
+		items = make(map[string]itemState)
+		n.items[kind] = items
+	}
+	previous, found := items[key]
+	if found && (item.Version == previous.version || (newerOnly && item.Version < previous.version)) {
+		return Notification{}, false
+	}
+	state := itemState{version: item.Version}
+	if item.Item != nil {
+		state.properties = itemProperties(kind, item)
+	}
+	items[key] = state
+
+	nt := Notification{Kind: kindName, Key: key, Version: item.Version}
+	switch {
+	case state.properties == nil:
+		if !found || previous.properties == nil {
+			return Notification{}, false // deleting something that already didn't exist is not a change
+		}
+		nt.Action = ActionDeleted
+		nt.PreviousVersion = previous.version
+	case !found || previous.properties == nil:
+		nt.Action = ActionCreated
+	default:
+		nt.Action = ActionUpdated
+		nt.PreviousVersion = previous.version
+		nt.Changes = changedProperties(previous.properties, state.properties)
+	}
+	return nt, true
+}
+
+func (n *Notifier) send(notifications []Notification) {
+	if len(notifications) == 0 {
+		return
+	}
+	now := n.now().UTC().Format(time.RFC3339Nano)
+	for _, nt := range notifications {
+		nt.Time, nt.Env, nt.EnvID = now, n.envName, n.envID
+		if nt.Kind == kindFlag {
+			nt.SurrogateKeys = surrogatekeys.ForFlagChange(n.envKey, nt.Key)
+		} else {
+			nt.SurrogateKeys = surrogatekeys.ForSegmentChange(n.envKey, nt.Key)
+		}
+		data, err := json.Marshal(nt)
+		if err != nil { // COVERAGE: can't happen in unit tests
+			n.loggers.Errorf("Unable to serialize flag change notification: %s", err)
+			continue
+		}
+		for _, w := range n.webhooks {
+			w.Enqueue(data)
+		}
+		if n.feed != nil {
+			n.feed.publish(nt)
+		}
+	}
+}
+
+func kindNames(kind ldstoretypes.DataKind) (string, bool) {
+	switch kind {
+	case ldstoreimpl.Features():
+		return kindFlag, true
+	case ldstoreimpl.Segments():
+		return kindSegment, true
+	default:
+		return "", false
+	}
+}
+
+func itemProperties(kind ldstoretypes.DataKind, item ldstoretypes.ItemDescriptor) map[string]json.RawMessage {
+	var properties map[string]json.RawMessage
+	if err := json.Unmarshal(kind.Serialize(item), &properties); err != nil || properties == nil {
+		return map[string]json.RawMessage{} // COVERAGE: can't happen in unit tests
+	}
+	delete(properties, "version")
+	return properties
+}
+
+func changedProperties(before, after map[string]json.RawMessage) []string {
+	var ret []string
+	for name, value := range after {
+		if !bytes.Equal(value, before[name]) {
+			ret = append(ret, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
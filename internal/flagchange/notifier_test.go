@@ -0,0 +1,233 @@
+package flagchange
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+type recordingUpdates struct {
+	allData int
+	items   []string
+}
+
+func (r *recordingUpdates) SendAllDataUpdate([]ldstoretypes.Collection) { r.allData++ }
+
+func (r *recordingUpdates) SendSingleItemUpdate(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
+	r.items = append(r.items, key)
+}
+
+func (r *recordingUpdates) InvalidateClientSideState() {}
+
+func startWebhookServer(status int) (*httptest.Server, <-chan webhookRequest) {
+	requestsCh := make(chan webhookRequest, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requestsCh <- webhookRequest{header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	return server, requestsCh
+}
+
+func makeTestNotifier(t *testing.T, urls ...string) *Notifier {
+	httpConfig, err := httpconfig.NewHTTPConfig(config.ProxyConfig{}, nil, "", ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	envConfig := config.EnvConfig{EnvID: "env-id", FlagChangeWebhook: configtypes.NewOptStringList(urls)}
+	n := NewNotifier(envConfig, "my-env", "Relay/1.0", httpConfig, 0, ldlog.NewDisabledLoggers())
+	require.NotNil(t, n)
+	n.now = func() time.Time { return time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC) }
+	return n
+}
+
+func expectNotification(t *testing.T, requestsCh <-chan webhookRequest) Notification {
+	select {
+	case r := <-requestsCh:
+		var nt Notification
+		require.NoError(t, json.Unmarshal(r.body, &nt))
+		return nt
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "timed out waiting for webhook request")
+		return Notification{}
+	}
+}
+
+func expectNoNotification(t *testing.T, requestsCh <-chan webhookRequest) {
+	select {
+	case r := <-requestsCh:
+		require.Fail(t, "unexpected webhook request", "%s", r.body)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+func flagItem(flag *ldbuilders.FlagBuilder) ldstoretypes.ItemDescriptor {
+	f := flag.Build()
+	return ldstoretypes.ItemDescriptor{Version: f.Version, Item: &f}
+}
+
+func segmentPtr(segment *ldbuilders.SegmentBuilder) interface{} {
+	s := segment.Build()
+	return &s
+}
+
+func ldvalueBools() []ldvalue.Value {
+	return []ldvalue.Value{ldvalue.Bool(true), ldvalue.Bool(false)}
+}
+
+func allData(flags ...*ldbuilders.FlagBuilder) []ldstoretypes.Collection {
+	var items []ldstoretypes.KeyedItemDescriptor
+	for _, f := range flags {
+		item := flagItem(f)
+		items = append(items, ldstoretypes.KeyedItemDescriptor{Key: f.Build().Key, Item: item})
+	}
+	return []ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: items},
+		{Kind: ldstoreimpl.Segments(), Items: nil},
+	}
+}
+
+func TestNewNotifierReturnsNilIfNotConfigured(t *testing.T) {
+	n := NewNotifier(config.EnvConfig{}, "my-env", "", httpconfig.HTTPConfig{}, 0, ldlog.NewDisabledLoggers())
+	assert.Nil(t, n)
+	target := &recordingUpdates{}
+	assert.Equal(t, target, n.WrapUpdates(target))
+	n.Close()
+}
+
+func TestNotifierPassesUpdatesToTarget(t *testing.T) {
+	server, _ := startWebhookServer(http.StatusOK)
+	defer server.Close()
+	n := makeTestNotifier(t, server.URL)
+	defer n.Close()
+	target := &recordingUpdates{}
+	updates := n.WrapUpdates(target)
+
+	updates.SendAllDataUpdate(allData(ldbuilders.NewFlagBuilder("a").Version(1)))
+	updates.SendSingleItemUpdate(ldstoreimpl.Features(), "a", flagItem(ldbuilders.NewFlagBuilder("a").Version(1)))
+	assert.Equal(t, 1, target.allData)
+	assert.Equal(t, []string{"a"}, target.items)
+}
+
+func TestNotifierDoesNotNotifyForInitialData(t *testing.T) {
+	server, requestsCh := startWebhookServer(http.StatusOK)
+	defer server.Close()
+	n := makeTestNotifier(t, server.URL)
+	defer n.Close()
+	updates := n.WrapUpdates(&recordingUpdates{})
+
+	updates.SendAllDataUpdate(allData(ldbuilders.NewFlagBuilder("a").Version(1)))
+	expectNoNotification(t, requestsCh)
+}
+
+func TestNotifierNotifiesForSingleItemUpdates(t *testing.T) {
+	server, requestsCh := startWebhookServer(http.StatusOK)
+	defer server.Close()
+	n := makeTestNotifier(t, server.URL)
+	defer n.Close()
+	updates := n.WrapUpdates(&recordingUpdates{})
+	updates.SendAllDataUpdate(allData(ldbuilders.NewFlagBuilder("a").Version(1).On(false).Variations(ldvalueBools()...)))
+
+	t.Run("updated", func(t *testing.T) {
+		updates.SendSingleItemUpdate(ldstoreimpl.Features(), "a",
+			flagItem(ldbuilders.NewFlagBuilder("a").Version(2).On(true).Variations(ldvalueBools()...)))
+		assert.Equal(t, Notification{
+			Time: "2021-06-07T08:09:10Z", Env: "my-env", EnvID: "env-id", Kind: "flag", Key: "a",
+			Action: ActionUpdated, Version: 2, PreviousVersion: 1, Changes: []string{"on"},
+			SurrogateKeys: []string{"ld-env-id-flag-a", "ld-env-id-flags", "ld-env-id-eval"},
+		}, expectNotification(t, requestsCh))
+	})
+
+	t.Run("duplicate or out-of-order versions are ignored", func(t *testing.T) {
+		updates.SendSingleItemUpdate(ldstoreimpl.Features(), "a", flagItem(ldbuilders.NewFlagBuilder("a").Version(2)))
+		updates.SendSingleItemUpdate(ldstoreimpl.Features(), "a", flagItem(ldbuilders.NewFlagBuilder("a").Version(1)))
+		expectNoNotification(t, requestsCh)
+	})
+
+	t.Run("created", func(t *testing.T) {
+		updates.SendSingleItemUpdate(ldstoreimpl.Segments(), "s",
+			ldstoretypes.ItemDescriptor{Version: 1, Item: segmentPtr(ldbuilders.NewSegmentBuilder("s").Version(1))})
+		nt := expectNotification(t, requestsCh)
+		assert.Equal(t, "segment", nt.Kind)
+		assert.Equal(t, ActionCreated, nt.Action)
+		assert.Equal(t, []string{"ld-env-id-segment-s", "ld-env-id-eval"}, nt.SurrogateKeys)
+		assert.Equal(t, 1, nt.Version)
+		assert.Len(t, nt.Changes, 0)
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		updates.SendSingleItemUpdate(ldstoreimpl.Features(), "a", ldstoretypes.ItemDescriptor{Version: 3, Item: nil})
+		nt := expectNotification(t, requestsCh)
+		assert.Equal(t, ActionDeleted, nt.Action)
+		assert.Equal(t, 3, nt.Version)
+		assert.Equal(t, 2, nt.PreviousVersion)
+
+		updates.SendSingleItemUpdate(ldstoreimpl.Features(), "b", ldstoretypes.ItemDescriptor{Version: 1, Item: nil})
+		expectNoNotification(t, requestsCh)
+	})
+}
+
+func TestNotifierNotifiesForChangesInLaterFullData(t *testing.T) {
+	server, requestsCh := startWebhookServer(http.StatusOK)
+	defer server.Close()
+	n := makeTestNotifier(t, server.URL)
+	defer n.Close()
+	updates := n.WrapUpdates(&recordingUpdates{})
+
+	updates.SendAllDataUpdate(allData(
+		ldbuilders.NewFlagBuilder("a").Version(1),
+		ldbuilders.NewFlagBuilder("b").Version(1),
+		ldbuilders.NewFlagBuilder("c").Version(1).Salt("x"),
+	))
+	updates.SendAllDataUpdate(allData(
+		ldbuilders.NewFlagBuilder("a").Version(1),
+		ldbuilders.NewFlagBuilder("c").Version(2).Salt("y").OffVariation(0),
+		ldbuilders.NewFlagBuilder("d").Version(1),
+	))
+
+	byKey := make(map[string]Notification)
+	for i := 0; i < 3; i++ {
+		nt := expectNotification(t, requestsCh)
+		byKey[nt.Key] = nt
+	}
+	expectNoNotification(t, requestsCh)
+	assert.Equal(t, ActionDeleted, byKey["b"].Action)
+	assert.Equal(t, 2, byKey["b"].Version)
+	assert.Equal(t, ActionUpdated, byKey["c"].Action)
+	assert.Equal(t, []string{"offVariation", "salt"}, byKey["c"].Changes)
+	assert.Equal(t, ActionCreated, byKey["d"].Action)
+}
+
+func TestNotifierSendsToEachWebhook(t *testing.T) {
+	server1, requestsCh1 := startWebhookServer(http.StatusOK)
+	defer server1.Close()
+	server2, requestsCh2 := startWebhookServer(http.StatusOK)
+	defer server2.Close()
+	n := makeTestNotifier(t, server1.URL, server2.URL)
+	updates := n.WrapUpdates(&recordingUpdates{})
+
+	updates.SendSingleItemUpdate(ldstoreimpl.Features(), "a", flagItem(ldbuilders.NewFlagBuilder("a").Version(1)))
+	n.Close()
+
+	assert.Equal(t, "a", expectNotification(t, requestsCh1).Key)
+	assert.Equal(t, "a", expectNotification(t, requestsCh2).Key)
+}
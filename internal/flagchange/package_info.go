@@ -0,0 +1,5 @@
+// Package flagchange contains the webhooks that Relay calls when it receives a change to a flag or
+// segment from LaunchDarkly, and the change feed stream that reports the same changes, so that operators
+// can invalidate caches or trigger deployments when flags change. Both are configured for each
+// environment.
+package flagchange
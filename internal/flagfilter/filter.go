@@ -0,0 +1,67 @@
+package flagfilter
+
+import "strings"
+
+// Filter determines which flags are kept, based on the FlagFilterInclude and FlagFilterExclude options
+// in EnvConfig. Each pattern is either a flag key, or a key prefix followed by "*".
+//
+// A flag is kept if it matches any of the include patterns, or if there are none, and it does not match
+// any of the exclude patterns. The zero value keeps every flag.
+type Filter struct {
+	include patterns
+	exclude patterns
+}
+
+type patterns struct {
+	keys     map[string]bool
+	prefixes []string
+}
+
+// NewFilter creates a Filter from lists of include and exclude patterns.
+func NewFilter(include, exclude []string) Filter {
+	return Filter{include: makePatterns(include), exclude: makePatterns(exclude)}
+}
+
+// IsEmpty returns true if the filter keeps every flag.
+func (f Filter) IsEmpty() bool {
+	return f.include.isEmpty() && f.exclude.isEmpty()
+}
+
+// Matches returns true if the flag with this key should be kept.
+func (f Filter) Matches(key string) bool {
+	if !f.include.isEmpty() && !f.include.matches(key) {
+		return false
+	}
+	return !f.exclude.matches(key)
+}
+
+func makePatterns(values []string) patterns {
+	var p patterns
+	for _, v := range values {
+		if strings.HasSuffix(v, "*") {
+			p.prefixes = append(p.prefixes, strings.TrimSuffix(v, "*"))
+			continue
+		}
+		if p.keys == nil {
+			p.keys = make(map[string]bool)
+		}
+		p.keys[v] = true
+	}
+	return p
+}
+
+func (p patterns) isEmpty() bool {
+	return p.keys == nil && p.prefixes == nil
+}
+
+func (p patterns) matches(key string) bool {
+	if p.keys[key] {
+		return true
+	}
+	for _, prefix := range p.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
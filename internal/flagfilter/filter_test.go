@@ -0,0 +1,39 @@
+package flagfilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmptyFilterMatchesEveryFlag(t *testing.T) {
+	f := NewFilter(nil, nil)
+	assert.True(t, f.IsEmpty())
+	assert.True(t, f.Matches("flag1"))
+	assert.True(t, Filter{}.Matches("flag1"))
+}
+
+func TestFilterWithIncludePatterns(t *testing.T) {
+	f := NewFilter([]string{"flag1", "app-*"}, nil)
+	assert.False(t, f.IsEmpty())
+	assert.True(t, f.Matches("flag1"))
+	assert.True(t, f.Matches("app-"))
+	assert.True(t, f.Matches("app-flag"))
+	assert.False(t, f.Matches("flag2"))
+	assert.False(t, f.Matches("flag1-suffix"))
+}
+
+func TestFilterWithExcludePatterns(t *testing.T) {
+	f := NewFilter(nil, []string{"flag1", "internal-*"})
+	assert.False(t, f.IsEmpty())
+	assert.False(t, f.Matches("flag1"))
+	assert.False(t, f.Matches("internal-flag"))
+	assert.True(t, f.Matches("flag2"))
+}
+
+func TestFilterExcludeTakesPrecedenceOverInclude(t *testing.T) {
+	f := NewFilter([]string{"app-*"}, []string{"app-internal-*"})
+	assert.True(t, f.Matches("app-flag"))
+	assert.False(t, f.Matches("app-internal-flag"))
+	assert.False(t, f.Matches("other-flag"))
+}
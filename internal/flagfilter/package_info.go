@@ -0,0 +1,4 @@
+// Package flagfilter implements the flag filter for an environment, which limits the flags that Relay
+// stores and serves to a subset of the project's flags, so that Relay instances that serve a single
+// application do not have to receive and store every flag.
+package flagfilter
@@ -0,0 +1,88 @@
+package flagfilter
+
+import (
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+// StoreFactory is a DataStoreFactory that wraps another one, so that the SDK only writes the flags that
+// match a Filter to the wrapped store. Since Relay serves whatever is in its store, connected SDKs and
+// downstream Relay instances only receive those flags, and updates to any other flag are not broadcast.
+//
+// Segments are not filtered, since the flags that are kept may refer to any of them. Flags that are kept
+// should not have prerequisites that are filtered out, since those would be evaluated as missing flags.
+type StoreFactory struct {
+	wrappedFactory interfaces.DataStoreFactory
+	filter         Filter
+}
+
+type filterStore struct {
+	wrapped interfaces.DataStore
+	filter  Filter
+}
+
+// NewStoreFactory creates a StoreFactory.
+func NewStoreFactory(wrappedFactory interfaces.DataStoreFactory, filter Filter) *StoreFactory {
+	return &StoreFactory{wrappedFactory: wrappedFactory, filter: filter}
+}
+
+// CreateDataStore is called by the SDK when the LDClient is being created.
+func (f *StoreFactory) CreateDataStore(
+	context interfaces.ClientContext,
+	dataStoreUpdates interfaces.DataStoreUpdates,
+) (interfaces.DataStore, error) {
+	wrapped, err := f.wrappedFactory.CreateDataStore(context, dataStoreUpdates)
+	if err != nil {
+		return nil, err
+	}
+	return &filterStore{wrapped: wrapped, filter: f.filter}, nil
+}
+
+func (s *filterStore) Init(allData []ldstoretypes.Collection) error {
+	filtered := make([]ldstoretypes.Collection, 0, len(allData))
+	for _, coll := range allData {
+		if coll.Kind == ldstoreimpl.Features() {
+			items := make([]ldstoretypes.KeyedItemDescriptor, 0, len(coll.Items))
+			for _, item := range coll.Items {
+				if s.filter.Matches(item.Key) {
+					items = append(items, item)
+				}
+			}
+			coll = ldstoretypes.Collection{Kind: coll.Kind, Items: items}
+		}
+		filtered = append(filtered, coll)
+	}
+	return s.wrapped.Init(filtered)
+}
+
+func (s *filterStore) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.ItemDescriptor,
+) (bool, error) {
+	if kind == ldstoreimpl.Features() && !s.filter.Matches(key) {
+		return false, nil
+	}
+	return s.wrapped.Upsert(kind, key, item)
+}
+
+func (s *filterStore) Get(kind ldstoretypes.DataKind, key string) (ldstoretypes.ItemDescriptor, error) {
+	return s.wrapped.Get(kind, key)
+}
+
+func (s *filterStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedItemDescriptor, error) {
+	return s.wrapped.GetAll(kind)
+}
+
+func (s *filterStore) IsInitialized() bool {
+	return s.wrapped.IsInitialized()
+}
+
+func (s *filterStore) IsStatusMonitoringEnabled() bool {
+	return s.wrapped.IsStatusMonitoringEnabled()
+}
+
+func (s *filterStore) Close() error {
+	return s.wrapped.Close()
+}
@@ -0,0 +1,78 @@
+package flagfilter
+
+import (
+	"testing"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testFlag1   = ldbuilders.NewFlagBuilder("app-flag1").Version(1).Build()
+	testFlag2   = ldbuilders.NewFlagBuilder("other-flag2").Version(1).Build()
+	testSegment = ldbuilders.NewSegmentBuilder("segment1").Version(1).Build()
+)
+
+func storeTest(t *testing.T, filter Filter, action func(store, wrapped interfaces.DataStore)) {
+	wrapped := st.NewInMemoryStore()
+	factory := NewStoreFactory(st.ExistingDataStoreFactory{Instance: wrapped}, filter)
+	store, err := factory.CreateDataStore(st.SDKContextImpl{}, nil)
+	require.NoError(t, err)
+	action(store, wrapped)
+}
+
+func getKeys(t *testing.T, store interfaces.DataStore, kind ldstoretypes.DataKind) []string {
+	items, err := store.GetAll(kind)
+	require.NoError(t, err)
+	var keys []string
+	for _, item := range items {
+		if item.Item.Item != nil {
+			keys = append(keys, item.Key)
+		}
+	}
+	return keys
+}
+
+func TestInitStoresOnlyMatchingFlags(t *testing.T) {
+	storeTest(t, NewFilter([]string{"app-*"}, nil), func(store, wrapped interfaces.DataStore) {
+		require.NoError(t, store.Init([]ldstoretypes.Collection{
+			{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedItemDescriptor{
+				{Key: testFlag1.Key, Item: st.FlagDesc(testFlag1)},
+				{Key: testFlag2.Key, Item: st.FlagDesc(testFlag2)},
+			}},
+			{Kind: ldstoreimpl.Segments(), Items: []ldstoretypes.KeyedItemDescriptor{
+				{Key: testSegment.Key, Item: st.SegmentDesc(testSegment)},
+			}},
+		}))
+		assert.True(t, store.IsInitialized())
+		assert.Equal(t, []string{testFlag1.Key}, getKeys(t, wrapped, ldstoreimpl.Features()))
+		assert.Equal(t, []string{testSegment.Key}, getKeys(t, wrapped, ldstoreimpl.Segments()))
+	})
+}
+
+func TestUpsertIgnoresFlagsThatDoNotMatch(t *testing.T) {
+	storeTest(t, NewFilter(nil, []string{"other-*"}), func(store, wrapped interfaces.DataStore) {
+		require.NoError(t, store.Init(nil))
+
+		updated, err := store.Upsert(ldstoreimpl.Features(), testFlag2.Key, st.FlagDesc(testFlag2))
+		require.NoError(t, err)
+		assert.False(t, updated)
+
+		updated, err = store.Upsert(ldstoreimpl.Features(), testFlag1.Key, st.FlagDesc(testFlag1))
+		require.NoError(t, err)
+		assert.True(t, updated)
+
+		updated, err = store.Upsert(ldstoreimpl.Segments(), testSegment.Key, st.SegmentDesc(testSegment))
+		require.NoError(t, err)
+		assert.True(t, updated)
+
+		assert.Equal(t, []string{testFlag1.Key}, getKeys(t, wrapped, ldstoreimpl.Features()))
+	})
+}
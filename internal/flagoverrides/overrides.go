@@ -0,0 +1,73 @@
+package flagoverrides
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
+	"gopkg.in/yaml.v2"
+)
+
+// Overrides maps flag keys to the variation index that each flag should always return.
+type Overrides map[string]int
+
+func errReadOverridesFile(path string, err error) error {
+	return fmt.Errorf("could not read flag overrides file %q: %w", path, err)
+}
+
+func errParseOverridesFile(path string, err error) error {
+	return fmt.Errorf("flag overrides file %q is not valid: %w", path, err)
+}
+
+func errNegativeVariation(path, key string) error {
+	return fmt.Errorf("flag overrides file %q has a negative variation for %q", path, key)
+}
+
+// ReadOverridesFile reads an overrides file, which is a JSON or YAML object whose property names are flag
+// keys and whose values are variation indices. Since JSON is a subset of YAML, both are parsed the same way.
+func ReadOverridesFile(path string) (Overrides, error) {
+	data, err := ioutil.ReadFile(path) //nolint:gosec // the path comes from the configuration
+	if err != nil {
+		return nil, errReadOverridesFile(path, err)
+	}
+	var ret Overrides
+	if err := yaml.UnmarshalStrict(data, &ret); err != nil {
+		return nil, errParseOverridesFile(path, err)
+	}
+	for key, variation := range ret {
+		if variation < 0 {
+			return nil, errNegativeVariation(path, key)
+		}
+	}
+	return ret, nil
+}
+
+func (o Overrides) equal(other Overrides) bool {
+	if len(o) != len(other) {
+		return false
+	}
+	for key, v := range o {
+		if ov, ok := other[key]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns a copy of the flag that is on and has no prerequisites, targets, or rules, so every user
+// gets the overridden variation as the fallthrough. The version is unchanged. It returns false if the
+// variation does not exist in this flag.
+func (o Overrides) Apply(flag *ldmodel.FeatureFlag) (*ldmodel.FeatureFlag, bool) {
+	variation, ok := o[flag.Key]
+	if !ok || variation >= len(flag.Variations) {
+		return flag, false
+	}
+	ret := *flag
+	ret.On = true
+	ret.Prerequisites = nil
+	ret.Targets = nil
+	ret.Rules = nil
+	ret.Fallthrough = ldmodel.VariationOrRollout{Variation: ldvalue.NewOptionalInt(variation)}
+	return &ret, true
+}
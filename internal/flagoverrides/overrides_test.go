@@ -0,0 +1,99 @@
+package flagoverrides
+
+import (
+	"io/ioutil"
+	"testing"
+
+	helpers "github.com/launchdarkly/go-test-helpers/v2"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withOverridesFile(t *testing.T, content string, action func(path string)) {
+	helpers.WithTempFile(func(path string) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+		action(path)
+	})
+}
+
+func TestReadOverridesFile(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		withOverridesFile(t, `{"flag1": 1, "flag2": 0}`, func(path string) {
+			o, err := ReadOverridesFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, Overrides{"flag1": 1, "flag2": 0}, o)
+		})
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		withOverridesFile(t, "flag1: 1\nflag2: 0\n", func(path string) {
+			o, err := ReadOverridesFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, Overrides{"flag1": 1, "flag2": 0}, o)
+		})
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		withOverridesFile(t, "", func(path string) {
+			o, err := ReadOverridesFile(path)
+			require.NoError(t, err)
+			assert.Len(t, o, 0)
+		})
+	})
+
+	t.Run("not a variation index", func(t *testing.T) {
+		withOverridesFile(t, `{"flag1": true}`, func(path string) {
+			_, err := ReadOverridesFile(path)
+			assert.Error(t, err)
+		})
+	})
+
+	t.Run("negative variation", func(t *testing.T) {
+		withOverridesFile(t, `{"flag1": -1}`, func(path string) {
+			_, err := ReadOverridesFile(path)
+			assert.Error(t, err)
+		})
+	})
+
+	t.Run("file not found", func(t *testing.T) {
+		_, err := ReadOverridesFile("/not/a/real/file")
+		assert.Error(t, err)
+	})
+}
+
+func TestApplyOverride(t *testing.T) {
+	flag := ldbuilders.NewFlagBuilder("flag1").Version(5).On(false).OffVariation(0).
+		Variations(ldvalue.String("a"), ldvalue.String("b")).
+		AddPrerequisite("other", 1).
+		AddTarget(0, "user-key").
+		AddRule(ldbuilders.NewRuleBuilder().ID("r").Variation(0)).
+		Build()
+
+	t.Run("overridden", func(t *testing.T) {
+		result, ok := Overrides{"flag1": 1}.Apply(&flag)
+		require.True(t, ok)
+		assert.Equal(t, flag.Version, result.Version)
+		assert.True(t, result.On)
+		assert.Len(t, result.Prerequisites, 0)
+		assert.Len(t, result.Targets, 0)
+		assert.Len(t, result.Rules, 0)
+		assert.Equal(t, ldmodel.VariationOrRollout{Variation: ldvalue.NewOptionalInt(1)}, result.Fallthrough)
+		assert.False(t, flag.On, "original flag should not be modified")
+	})
+
+	t.Run("not overridden", func(t *testing.T) {
+		result, ok := Overrides{"flag2": 1}.Apply(&flag)
+		assert.False(t, ok)
+		assert.Equal(t, &flag, result)
+	})
+
+	t.Run("variation out of range", func(t *testing.T) {
+		_, ok := Overrides{"flag1": 2}.Apply(&flag)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,4 @@
+// Package flagoverrides implements the flag overrides file, which pins flags in an environment to fixed
+// variations regardless of what LaunchDarkly sends, so that test environments can force flag states
+// without changing them in LaunchDarkly. It is configured for each environment.
+package flagoverrides
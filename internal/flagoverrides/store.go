@@ -0,0 +1,265 @@
+package flagoverrides
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/launchdarkly/ld-relay/v6/internal/core/application"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+)
+
+// StoreFactory is a DataStoreFactory that wraps another one, so that the SDK writes overridden flags to
+// the wrapped store instead of the flags that it received. Since Relay broadcasts whatever is written to
+// its store to connected SDKs, they see the overridden flags too.
+//
+// The overrides file is watched, and whenever it changes, every flag whose override was added, changed,
+// or removed is rewritten with a full data set (Init). The version number of each of those flags is
+// increased by one from then on, so that SDKs and downstream Relay instances, which ignore an update that
+// has the same version as what they already have, will see the change.
+//
+// Since the overridden flags are what gets stored, this must not be used with a persistent data store
+// that is shared with anything else; the configuration validator does not allow that.
+//
+// It should only be used for a single SDK client, like store.SSERelayDataStoreAdapter.
+type StoreFactory struct {
+	wrappedFactory interfaces.DataStoreFactory
+	filePath       string
+	overrides      Overrides
+	store          *overridesStore
+	watcher        *application.ConfigFileWatcher
+	loggers        ldlog.Loggers
+	lock           sync.Mutex
+}
+
+type overridesStore struct {
+	factory *StoreFactory
+	wrapped interfaces.DataStore
+	// upstream flags, as they were before we overrode them, for every flag that is currently overridden
+	originals map[string]ldstoretypes.ItemDescriptor
+	// how much to add to the upstream version of each flag whose override has ever changed
+	versionOffsets map[string]int
+}
+
+// NewStoreFactory reads the overrides file and starts watching it for changes. It returns an error if
+// the file cannot be read; if it later becomes unreadable, the last valid overrides are kept.
+func NewStoreFactory(
+	wrappedFactory interfaces.DataStoreFactory,
+	filePath string,
+	loggers ldlog.Loggers,
+) (*StoreFactory, error) {
+	overrides, err := ReadOverridesFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	f := &StoreFactory{
+		wrappedFactory: wrappedFactory,
+		filePath:       filePath,
+		overrides:      overrides,
+		loggers:        loggers,
+	}
+	logOverrides(overrides, loggers)
+	watcher, err := application.WatchConfigFile(filePath, f.reload, loggers)
+	if err != nil {
+		loggers.Warnf("Unable to watch flag overrides file %q for changes: %s", filePath, err)
+	}
+	f.watcher = watcher
+	return f, nil
+}
+
+// CreateDataStore is called by the SDK when the LDClient is being created.
+func (f *StoreFactory) CreateDataStore(
+	context interfaces.ClientContext,
+	dataStoreUpdates interfaces.DataStoreUpdates,
+) (interfaces.DataStore, error) {
+	wrapped, err := f.wrappedFactory.CreateDataStore(context, dataStoreUpdates)
+	if err != nil {
+		return nil, err
+	}
+	s := &overridesStore{
+		factory:        f,
+		wrapped:        wrapped,
+		originals:      make(map[string]ldstoretypes.ItemDescriptor),
+		versionOffsets: make(map[string]int),
+	}
+	f.lock.Lock()
+	f.store = s
+	f.lock.Unlock()
+	return s, nil
+}
+
+// Close stops watching the overrides file.
+func (f *StoreFactory) Close() error {
+	if f.watcher != nil {
+		return f.watcher.Close()
+	}
+	return nil
+}
+
+func (f *StoreFactory) reload() {
+	overrides, err := ReadOverridesFile(f.filePath)
+	if err != nil {
+		f.loggers.Errorf("Keeping previous flag overrides: %s", err)
+		return
+	}
+	f.setOverrides(overrides)
+}
+
+func (f *StoreFactory) setOverrides(overrides Overrides) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	previous := f.overrides
+	f.overrides = overrides
+	logOverrides(overrides, f.loggers)
+	if f.store == nil || !f.store.wrapped.IsInitialized() {
+		return // the new overrides will be applied when we get the data
+	}
+	if overrides.equal(previous) {
+		return
+	}
+
+	var allData []ldstoretypes.Collection
+	for _, kind := range ldstoreimpl.AllKinds() {
+		items, err := f.store.wrapped.GetAll(kind)
+		if err != nil {
+			f.loggers.Errorf("Unable to apply new flag overrides, because the data store could not be read: %s", err)
+			return
+		}
+		if kind == ldstoreimpl.Features() {
+			for i, item := range items {
+				items[i].Item = f.store.upstreamLocked(item.Key, item.Item) // initLocked will apply the current override, if any
+			}
+		}
+		allData = append(allData, ldstoretypes.Collection{Kind: kind, Items: items})
+	}
+	for key := range previous {
+		if v, ok := overrides[key]; !ok || v != previous[key] {
+			f.store.versionOffsets[key]++
+		}
+	}
+	for key := range overrides {
+		if _, ok := previous[key]; !ok {
+			f.store.versionOffsets[key]++
+		}
+	}
+	_ = f.store.initLocked(allData)
+}
+
+func logOverrides(overrides Overrides, loggers ldlog.Loggers) {
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	loggers.Infof("Flag overrides are in effect for: %v", keys)
+}
+
+func (s *overridesStore) Init(allData []ldstoretypes.Collection) error {
+	s.factory.lock.Lock()
+	defer s.factory.lock.Unlock()
+	return s.initLocked(allData)
+}
+
+func (s *overridesStore) initLocked(allData []ldstoretypes.Collection) error {
+	s.originals = make(map[string]ldstoretypes.ItemDescriptor)
+	transformed := make([]ldstoretypes.Collection, 0, len(allData))
+	for _, coll := range allData {
+		if coll.Kind == ldstoreimpl.Features() {
+			items := make([]ldstoretypes.KeyedItemDescriptor, 0, len(coll.Items))
+			for _, item := range coll.Items {
+				items = append(items, ldstoretypes.KeyedItemDescriptor{Key: item.Key, Item: s.applyLocked(item.Key, item.Item)})
+			}
+			coll = ldstoretypes.Collection{Kind: coll.Kind, Items: items}
+		}
+		transformed = append(transformed, coll)
+	}
+	return s.wrapped.Init(transformed)
+}
+
+func (s *overridesStore) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.ItemDescriptor,
+) (bool, error) {
+	s.factory.lock.Lock()
+	defer s.factory.lock.Unlock()
+	if kind == ldstoreimpl.Features() {
+		prev, hadOriginal := s.originals[key]
+		delete(s.originals, key)
+		item = s.applyLocked(key, item)
+		if hadOriginal && prev.Version > item.Version {
+			// The wrapped store will ignore this out-of-order update, so the original we had is still current.
+			s.originals[key] = prev
+		}
+	}
+	return s.wrapped.Upsert(kind, key, item)
+}
+
+// applyLocked returns the overridden version of a flag, if it has an override, and remembers the original.
+// The version number is adjusted by the flag's offset in either case, so that it is always consistent with
+// what is already in the wrapped store.
+func (s *overridesStore) applyLocked(key string, item ldstoretypes.ItemDescriptor) ldstoretypes.ItemDescriptor {
+	offset := s.versionOffsets[key]
+	flag, ok := item.Item.(*ldmodel.FeatureFlag)
+	if !ok {
+		return ldstoretypes.ItemDescriptor{Version: item.Version + offset, Item: item.Item} // deleted
+	}
+	newFlag := flag
+	if _, overridden := s.factory.overrides[key]; overridden {
+		if newFlag, ok = s.factory.overrides.Apply(flag); ok {
+			s.originals[key] = item
+		} else {
+			s.factory.loggers.Warnf("Not overriding flag %q, because it has no variation %d", key, s.factory.overrides[key])
+		}
+	}
+	if offset == 0 {
+		return ldstoretypes.ItemDescriptor{Version: item.Version, Item: newFlag}
+	}
+	if newFlag == flag {
+		copied := *flag
+		newFlag = &copied
+	}
+	newFlag.Version += offset
+	return ldstoretypes.ItemDescriptor{Version: item.Version + offset, Item: newFlag}
+}
+
+// upstreamLocked returns a flag from the wrapped store as it was before applyLocked changed it.
+func (s *overridesStore) upstreamLocked(key string, item ldstoretypes.ItemDescriptor) ldstoretypes.ItemDescriptor {
+	if original, ok := s.originals[key]; ok {
+		return original
+	}
+	offset := s.versionOffsets[key]
+	if offset == 0 {
+		return item
+	}
+	if flag, ok := item.Item.(*ldmodel.FeatureFlag); ok {
+		copied := *flag
+		copied.Version -= offset
+		return ldstoretypes.ItemDescriptor{Version: item.Version - offset, Item: &copied}
+	}
+	return ldstoretypes.ItemDescriptor{Version: item.Version - offset, Item: item.Item}
+}
+
+func (s *overridesStore) Get(kind ldstoretypes.DataKind, key string) (ldstoretypes.ItemDescriptor, error) {
+	return s.wrapped.Get(kind, key)
+}
+
+func (s *overridesStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedItemDescriptor, error) {
+	return s.wrapped.GetAll(kind)
+}
+
+func (s *overridesStore) IsInitialized() bool {
+	return s.wrapped.IsInitialized()
+}
+
+func (s *overridesStore) IsStatusMonitoringEnabled() bool {
+	return s.wrapped.IsStatusMonitoringEnabled()
+}
+
+func (s *overridesStore) Close() error {
+	return s.wrapped.Close()
+}
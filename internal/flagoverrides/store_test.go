@@ -0,0 +1,170 @@
+package flagoverrides
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldbuilders"
+	"gopkg.in/launchdarkly/go-server-sdk-evaluation.v1/ldmodel"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/interfaces/ldstoretypes"
+	"gopkg.in/launchdarkly/go-server-sdk.v5/ldcomponents/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testFlag1 = ldbuilders.NewFlagBuilder("flag1").Version(1).On(true).FallthroughVariation(0).
+			Variations(ldvalue.Bool(false), ldvalue.Bool(true)).Build()
+	testFlag2 = ldbuilders.NewFlagBuilder("flag2").Version(1).On(true).FallthroughVariation(0).
+			Variations(ldvalue.Bool(false), ldvalue.Bool(true)).Build()
+)
+
+type storeTestParams struct {
+	factory *StoreFactory
+	store   interfaces.DataStore
+	wrapped interfaces.DataStore
+	path    string
+}
+
+func storeTest(t *testing.T, overridesContent string, action func(p storeTestParams)) {
+	withOverridesFile(t, overridesContent, func(path string) {
+		wrapped := st.NewInMemoryStore()
+		factory, err := NewStoreFactory(st.ExistingDataStoreFactory{Instance: wrapped}, path, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		defer factory.Close()
+		store, err := factory.CreateDataStore(st.SDKContextImpl{}, nil)
+		require.NoError(t, err)
+		action(storeTestParams{factory: factory, store: store, wrapped: wrapped, path: path})
+	})
+}
+
+func initTestData(t *testing.T, store interfaces.DataStore, flags ...ldmodel.FeatureFlag) {
+	var items []ldstoretypes.KeyedItemDescriptor
+	for _, f := range flags {
+		items = append(items, ldstoretypes.KeyedItemDescriptor{Key: f.Key, Item: st.FlagDesc(f)})
+	}
+	require.NoError(t, store.Init([]ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: items},
+		{Kind: ldstoreimpl.Segments(), Items: nil},
+	}))
+}
+
+func getFlag(t *testing.T, store interfaces.DataStore, key string) *ldmodel.FeatureFlag {
+	item, err := store.Get(ldstoreimpl.Features(), key)
+	require.NoError(t, err)
+	require.NotNil(t, item.Item)
+	return item.Item.(*ldmodel.FeatureFlag)
+}
+
+func isOverridden(flag *ldmodel.FeatureFlag, variation int) bool {
+	return flag.On && len(flag.Rules) == 0 && flag.Fallthrough.Variation == ldvalue.NewOptionalInt(variation)
+}
+
+func TestNewStoreFactoryReturnsErrorForInvalidFile(t *testing.T) {
+	withOverridesFile(t, "not: [valid", func(path string) {
+		_, err := NewStoreFactory(st.ExistingDataStoreFactory{Instance: st.NewInMemoryStore()}, path,
+			ldlog.NewDisabledLoggers())
+		assert.Error(t, err)
+	})
+}
+
+func TestInitAppliesOverrides(t *testing.T) {
+	storeTest(t, `{"flag1": 1}`, func(p storeTestParams) {
+		initTestData(t, p.store, testFlag1, testFlag2)
+		assert.True(t, isOverridden(getFlag(t, p.wrapped, testFlag1.Key), 1))
+		assert.Equal(t, &testFlag2, getFlag(t, p.wrapped, testFlag2.Key))
+	})
+}
+
+func TestOverrideIsNotAppliedIfVariationDoesNotExist(t *testing.T) {
+	storeTest(t, `{"flag1": 5}`, func(p storeTestParams) {
+		initTestData(t, p.store, testFlag1)
+		assert.Equal(t, &testFlag1, getFlag(t, p.wrapped, testFlag1.Key))
+	})
+}
+
+func TestUpsertAppliesOverrides(t *testing.T) {
+	storeTest(t, `{"flag1": 1}`, func(p storeTestParams) {
+		initTestData(t, p.store)
+		updated := testFlag1
+		updated.Version = 2
+		_, err := p.store.Upsert(ldstoreimpl.Features(), updated.Key, st.FlagDesc(updated))
+		require.NoError(t, err)
+		flag := getFlag(t, p.wrapped, testFlag1.Key)
+		assert.True(t, isOverridden(flag, 1))
+		assert.Equal(t, 2, flag.Version)
+	})
+}
+
+func withVersion(flag ldmodel.FeatureFlag, version int) *ldmodel.FeatureFlag {
+	flag.Version = version
+	return &flag
+}
+
+func TestChangingOverridesRewritesFlags(t *testing.T) {
+	storeTest(t, `{"flag1": 1}`, func(p storeTestParams) {
+		initTestData(t, p.store, testFlag1, testFlag2)
+
+		p.factory.setOverrides(Overrides{"flag2": 1})
+		assert.Equal(t, withVersion(testFlag1, 2), getFlag(t, p.wrapped, testFlag1.Key))
+		assert.True(t, isOverridden(getFlag(t, p.wrapped, testFlag2.Key), 1))
+
+		p.factory.setOverrides(Overrides{"flag2": 0})
+		assert.True(t, isOverridden(getFlag(t, p.wrapped, testFlag2.Key), 0))
+
+		p.factory.setOverrides(Overrides{})
+		assert.Equal(t, withVersion(testFlag1, 2), getFlag(t, p.wrapped, testFlag1.Key))
+		assert.Equal(t, withVersion(testFlag2, 4), getFlag(t, p.wrapped, testFlag2.Key))
+	})
+}
+
+func TestChangingOverridesIncreasesVersionOfChangedFlagsOnly(t *testing.T) {
+	storeTest(t, `{"flag1": 1}`, func(p storeTestParams) {
+		initTestData(t, p.store, testFlag1, testFlag2)
+
+		p.factory.setOverrides(Overrides{"flag1": 0})
+		item, err := p.wrapped.Get(ldstoreimpl.Features(), testFlag1.Key)
+		require.NoError(t, err)
+		assert.Equal(t, 2, item.Version)
+		assert.Equal(t, 2, getFlag(t, p.wrapped, testFlag1.Key).Version)
+		assert.Equal(t, &testFlag2, getFlag(t, p.wrapped, testFlag2.Key))
+
+		// later updates from LaunchDarkly keep the same offset, so they are still newer
+		updated := testFlag1
+		updated.Version = 2
+		_, err = p.store.Upsert(ldstoreimpl.Features(), updated.Key, st.FlagDesc(updated))
+		require.NoError(t, err)
+		flag := getFlag(t, p.wrapped, testFlag1.Key)
+		assert.True(t, isOverridden(flag, 0))
+		assert.Equal(t, 3, flag.Version)
+	})
+}
+
+func TestOverridesAreReloadedWhenFileChanges(t *testing.T) {
+	storeTest(t, `{"flag1": 1}`, func(p storeTestParams) {
+		initTestData(t, p.store, testFlag1, testFlag2)
+
+		require.NoError(t, ioutil.WriteFile(p.path, []byte("flag2: 1\n"), 0600))
+		require.Eventually(t, func() bool {
+			return isOverridden(getFlag(t, p.wrapped, testFlag2.Key), 1)
+		}, time.Second*3, time.Millisecond*20)
+		assert.Equal(t, withVersion(testFlag1, 2), getFlag(t, p.wrapped, testFlag1.Key))
+	})
+}
+
+func TestInvalidFileChangeKeepsPreviousOverrides(t *testing.T) {
+	storeTest(t, `{"flag1": 1}`, func(p storeTestParams) {
+		initTestData(t, p.store, testFlag1)
+
+		require.NoError(t, ioutil.WriteFile(p.path, []byte("not: [valid"), 0600))
+		p.factory.reload()
+		assert.True(t, isOverridden(getFlag(t, p.wrapped, testFlag1.Key), 1))
+	})
+}
@@ -0,0 +1,12 @@
+// Package secrets resolves references to secrets that are stored in an external secret manager, such as
+// "vault://secret/ld/prod#sdkKey", so that credentials do not need to appear in Relay's configuration.
+//
+// The supported reference formats are:
+//
+//	vault://PATH#KEY       a key within a HashiCorp Vault secret (KV version 1 or 2)
+//	aws-sm://NAME[#KEY]    an AWS Secrets Manager secret, or a key within it if it is a JSON object
+//	gcp-sm://NAME[#KEY]    a Google Cloud Secret Manager secret, or a key within it if it is a JSON object
+//
+// For Google Cloud, NAME is "projects/PROJECT/secrets/SECRET", optionally followed by "/versions/VERSION";
+// the default version is "latest".
+package secrets
@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"google.golang.org/api/secretmanager/v1"
+)
+
+const providerRequestTimeout = time.Second * 10
+
+var (
+	errVaultNotConfigured = errors.New("Vault URL must be set in the Secrets configuration or in VAULT_ADDR") //nolint:stylecheck
+	errAWSSecretEmpty     = errors.New("AWS secret has no value")
+	errGCPSecretEmpty     = errors.New("Google Cloud secret has no value")
+)
+
+func errVaultResponse(path string, status int) error {
+	return fmt.Errorf("Vault returned HTTP status %d for %q", status, path) //nolint:stylecheck
+}
+
+// provider is the interface for retrieving secrets from one kind of secret manager.
+type provider interface {
+	// getSecret returns the value of the secret at the specified path. For secret managers that store
+	// secrets as key-value pairs, the value is a JSON object.
+	getSecret(path string) (string, error)
+}
+
+type vaultProvider struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func newVaultProvider(c config.SecretsConfig) (provider, error) {
+	baseURL := c.VaultURL.String()
+	if baseURL == "" {
+		baseURL = os.Getenv("VAULT_ADDR")
+	}
+	if baseURL == "" {
+		return nil, errVaultNotConfigured
+	}
+	token := c.VaultToken
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	return &vaultProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: providerRequestTimeout},
+	}, nil
+}
+
+func (p *vaultProvider) getSecret(path string) (string, error) {
+	req, err := http.NewRequest("GET", p.baseURL+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	if p.token != "" {
+		req.Header.Set("X-Vault-Token", p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return "", errVaultResponse(path, resp.StatusCode)
+	}
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	// In the KV version 2 secrets engine, the key-value pairs are in data.data, alongside data.metadata;
+	// in version 1, they are directly in data.
+	if inner, ok := body.Data["data"]; ok && body.Data["metadata"] != nil {
+		return string(inner), nil
+	}
+	data, err := json.Marshal(body.Data)
+	return string(data), err
+}
+
+type awsProvider struct {
+	client *secretsmanager.SecretsManager
+}
+
+func newAWSProvider(config.SecretsConfig) (provider, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, err
+	}
+	return &awsProvider{client: secretsmanager.New(sess)}, nil
+}
+
+func (p *awsProvider) getSecret(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), providerRequestTimeout)
+	defer cancel()
+	out, err := p.client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(path)})
+	if err != nil {
+		return "", err
+	}
+	if out.SecretString != nil {
+		return *out.SecretString, nil
+	}
+	if out.SecretBinary != nil {
+		return string(out.SecretBinary), nil
+	}
+	return "", errAWSSecretEmpty
+}
+
+type gcpProvider struct {
+	service *secretmanager.Service
+}
+
+func newGCPProvider(config.SecretsConfig) (provider, error) {
+	service, err := secretmanager.NewService(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &gcpProvider{service: service}, nil
+}
+
+func (p *gcpProvider) getSecret(path string) (string, error) {
+	name := path
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), providerRequestTimeout)
+	defer cancel()
+	resp, err := p.service.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	if resp.Payload == nil {
+		return "", errGCPSecretEmpty
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultProvider(t *testing.T) {
+	withVaultServer := func(t *testing.T, body string, action func(provider)) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Vault-Token") != "my-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if r.URL.Path != "/v1/secret/relay" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(body))
+		}))
+		defer server.Close()
+		vaultURL, err := ct.NewOptURLAbsoluteFromString(server.URL + "/")
+		require.NoError(t, err)
+		p, err := newVaultProvider(config.SecretsConfig{VaultURL: vaultURL, VaultToken: "my-token"})
+		require.NoError(t, err)
+		action(p)
+	}
+
+	t.Run("KV version 1", func(t *testing.T) {
+		withVaultServer(t, `{"data": {"sdkKey": "sdk-key"}}`, func(p provider) {
+			secret, err := p.getSecret("secret/relay")
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"sdkKey": "sdk-key"}`, secret)
+		})
+	})
+
+	t.Run("KV version 2", func(t *testing.T) {
+		withVaultServer(t, `{"data": {"data": {"sdkKey": "sdk-key"}, "metadata": {"version": 2}}}`, func(p provider) {
+			secret, err := p.getSecret("secret/relay")
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"sdkKey": "sdk-key"}`, secret)
+		})
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		withVaultServer(t, `{}`, func(p provider) {
+			_, err := p.getSecret("secret/other")
+			assert.Equal(t, errVaultResponse("secret/other", http.StatusNotFound), err)
+		})
+	})
+
+	t.Run("URL is required", func(t *testing.T) {
+		oldValue := os.Getenv("VAULT_ADDR")
+		defer os.Setenv("VAULT_ADDR", oldValue)
+		os.Setenv("VAULT_ADDR", "")
+		_, err := newVaultProvider(config.SecretsConfig{})
+		assert.Equal(t, errVaultNotConfigured, err)
+	})
+}
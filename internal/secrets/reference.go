@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	vaultScheme = "vault"
+	awsScheme   = "aws-sm"
+	gcpScheme   = "gcp-sm"
+)
+
+func errMalformedReference(ref string) error {
+	return fmt.Errorf("malformed secret reference %q", ref)
+}
+
+func errKeyRequired(ref string) error {
+	return fmt.Errorf("secret reference %q must specify a key after \"#\"", ref)
+}
+
+func errSecretNotObject(ref string) error {
+	return fmt.Errorf("secret for %q is not a JSON object, so it cannot contain a key", ref)
+}
+
+func errKeyNotFound(ref string) error {
+	return fmt.Errorf("secret for %q does not contain the specified key", ref)
+}
+
+// reference is a parsed secret reference.
+type reference struct {
+	scheme string
+	path   string
+	key    string
+}
+
+// IsReference returns true if the string is a secret reference that this package knows how to resolve.
+// Configuration values that are not secret references are used as-is.
+func IsReference(value string) bool {
+	for _, scheme := range []string{vaultScheme, awsScheme, gcpScheme} {
+		if strings.HasPrefix(value, scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseReference(value string) (reference, error) {
+	i := strings.Index(value, "://")
+	if i < 0 {
+		return reference{}, errMalformedReference(value)
+	}
+	ref := reference{scheme: value[:i], path: value[i+3:]}
+	if j := strings.LastIndex(ref.path, "#"); j >= 0 {
+		ref.path, ref.key = ref.path[:j], ref.path[j+1:]
+		if ref.key == "" {
+			return reference{}, errMalformedReference(value)
+		}
+	}
+	if ref.path == "" {
+		return reference{}, errMalformedReference(value)
+	}
+	if ref.scheme == vaultScheme && ref.key == "" {
+		// Vault secrets are always a set of key-value pairs
+		return reference{}, errKeyRequired(value)
+	}
+	return ref, nil
+}
+
+// extractKey returns either the entire secret, if no key was specified, or the value of the key within
+// a secret that is a JSON object.
+func (r reference) extractKey(secret string, original string) (string, error) {
+	if r.key == "" {
+		return secret, nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &values); err != nil {
+		return "", errSecretNotObject(original)
+	}
+	value, ok := values[r.key]
+	if !ok || value == nil {
+		return "", errKeyNotFound(original)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
@@ -0,0 +1,80 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsReference(t *testing.T) {
+	assert.True(t, IsReference("vault://secret/relay#sdkKey"))
+	assert.True(t, IsReference("aws-sm://relay-keys"))
+	assert.True(t, IsReference("gcp-sm://projects/p/secrets/relay-keys#sdkKey"))
+	assert.False(t, IsReference("sdk-12345"))
+	assert.False(t, IsReference("other://relay-keys"))
+	assert.False(t, IsReference(""))
+}
+
+func TestParseReference(t *testing.T) {
+	for _, p := range []struct {
+		value    string
+		expected reference
+	}{
+		{"vault://secret/relay#sdkKey", reference{scheme: vaultScheme, path: "secret/relay", key: "sdkKey"}},
+		{"aws-sm://relay-keys", reference{scheme: awsScheme, path: "relay-keys"}},
+		{"aws-sm://relay-keys#sdkKey", reference{scheme: awsScheme, path: "relay-keys", key: "sdkKey"}},
+		{"gcp-sm://projects/p/secrets/s/versions/2", reference{scheme: gcpScheme, path: "projects/p/secrets/s/versions/2"}},
+	} {
+		t.Run(p.value, func(t *testing.T) {
+			ref, err := parseReference(p.value)
+			require.NoError(t, err)
+			assert.Equal(t, p.expected, ref)
+		})
+	}
+
+	for _, p := range []struct {
+		value    string
+		expected error
+	}{
+		{"aws-sm://", errMalformedReference("aws-sm://")},
+		{"aws-sm://#sdkKey", errMalformedReference("aws-sm://#sdkKey")},
+		{"aws-sm://relay-keys#", errMalformedReference("aws-sm://relay-keys#")},
+		{"vault://secret/relay", errKeyRequired("vault://secret/relay")},
+	} {
+		t.Run(p.value, func(t *testing.T) {
+			_, err := parseReference(p.value)
+			assert.Equal(t, p.expected, err)
+		})
+	}
+}
+
+func TestExtractKey(t *testing.T) {
+	t.Run("no key", func(t *testing.T) {
+		value, err := reference{}.extractKey(`{"a":"b"}`, "ref")
+		require.NoError(t, err)
+		assert.Equal(t, `{"a":"b"}`, value)
+	})
+
+	t.Run("string value", func(t *testing.T) {
+		value, err := reference{key: "a"}.extractKey(`{"a":"b"}`, "ref")
+		require.NoError(t, err)
+		assert.Equal(t, "b", value)
+	})
+
+	t.Run("non-string value", func(t *testing.T) {
+		value, err := reference{key: "a"}.extractKey(`{"a":3}`, "ref")
+		require.NoError(t, err)
+		assert.Equal(t, "3", value)
+	})
+
+	t.Run("key not found", func(t *testing.T) {
+		_, err := reference{key: "c"}.extractKey(`{"a":"b"}`, "ref")
+		assert.Equal(t, errKeyNotFound("ref"), err)
+	})
+
+	t.Run("secret is not an object", func(t *testing.T) {
+		_, err := reference{key: "a"}.extractKey(`xyz`, "ref")
+		assert.Equal(t, errSecretNotObject("ref"), err)
+	})
+}
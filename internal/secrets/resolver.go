@@ -0,0 +1,182 @@
+package secrets
+
+import (
+	"fmt"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+)
+
+func errUnknownScheme(ref string) error {
+	return fmt.Errorf("unsupported secret manager in reference %q", ref)
+}
+
+func errResolveFailed(propName, ref string, err error) error {
+	return fmt.Errorf("unable to resolve secret reference %q for %s: %w", ref, propName, err)
+}
+
+// Resolver retrieves the values of secret references. A single Resolver can be used for any number of
+// references; each kind of secret manager client is created only when it is first needed.
+type Resolver struct {
+	config    config.SecretsConfig
+	factories map[string]func(config.SecretsConfig) (provider, error)
+	providers map[string]provider
+}
+
+// NewResolver creates a Resolver using the specified configuration.
+func NewResolver(c config.SecretsConfig) *Resolver {
+	return newResolverWithFactories(c, map[string]func(config.SecretsConfig) (provider, error){
+		vaultScheme: newVaultProvider,
+		awsScheme:   newAWSProvider,
+		gcpScheme:   newGCPProvider,
+	})
+}
+
+func newResolverWithFactories(c config.SecretsConfig, factories map[string]func(config.SecretsConfig) (provider, error)) *Resolver {
+	return &Resolver{config: c, factories: factories, providers: make(map[string]provider)}
+}
+
+// Resolve returns the value of a secret reference. If the value is not a secret reference, it is
+// returned unchanged.
+func (r *Resolver) Resolve(value string) (string, error) {
+	return r.resolve(value, nil)
+}
+
+// resolve does the work of Resolve, using a cache of secrets that were already retrieved if cache is
+// non-nil. This avoids requesting the same secret repeatedly when several properties refer to keys in it.
+func (r *Resolver) resolve(value string, cache map[reference]string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+	ref, err := parseReference(value)
+	if err != nil {
+		return "", err
+	}
+	secretRef := reference{scheme: ref.scheme, path: ref.path}
+	secret, ok := cache[secretRef]
+	if !ok {
+		p, err := r.getProvider(ref.scheme, value)
+		if err != nil {
+			return "", err
+		}
+		if secret, err = p.getSecret(ref.path); err != nil {
+			return "", err
+		}
+		if cache != nil {
+			cache[secretRef] = secret
+		}
+	}
+	return ref.extractKey(secret, value)
+}
+
+func (r *Resolver) getProvider(scheme, ref string) (provider, error) {
+	if p := r.providers[scheme]; p != nil {
+		return p, nil
+	}
+	factory := r.factories[scheme]
+	if factory == nil {
+		return nil, errUnknownScheme(ref) // COVERAGE: IsReference only accepts known schemes
+	}
+	p, err := factory(r.config)
+	if err != nil {
+		return nil, err
+	}
+	r.providers[scheme] = p
+	return p, nil
+}
+
+// HasSecretReferences returns true if any of the configuration properties that can contain secret
+// references does contain one.
+func HasSecretReferences(c config.Config) bool {
+	found := false
+	forEachSecretProperty(&c, func(_ string, value *string) {
+		found = found || IsReference(*value)
+	})
+	return found
+}
+
+// ResolveConfigSecrets replaces all secret references in the configuration with the secret values.
+//
+// The properties that can contain secret references are: the SDK key and mobile key of each environment,
+// the Redis password and Sentinel password, and the DynamoDB access key ID and secret access key, including
+// those of named databases.
+func (r *Resolver) ResolveConfigSecrets(c *config.Config) error {
+	cache := make(map[reference]string)
+	var firstErr error
+	forEachSecretProperty(c, func(propName string, value *string) {
+		if firstErr != nil {
+			return
+		}
+		resolved, err := r.resolve(*value, cache)
+		if err != nil {
+			firstErr = errResolveFailed(propName, *value, err)
+			return
+		}
+		*value = resolved
+	})
+	return firstErr
+}
+
+func forEachSecretProperty(c *config.Config, action func(propName string, value *string)) {
+	if c.Environment != nil {
+		// The environment map and its values are shared with any copies of the Config, so we replace them
+		// rather than modifying them.
+		envs := make(map[string]*config.EnvConfig, len(c.Environment))
+		for name, env := range c.Environment {
+			envCopy := *env
+			sdkKey, mobileKey := string(envCopy.SDKKey), string(envCopy.MobileKey)
+			action(fmt.Sprintf("SDK key of environment %q", name), &sdkKey)
+			action(fmt.Sprintf("mobile key of environment %q", name), &mobileKey)
+			envCopy.SDKKey, envCopy.MobileKey = config.SDKKey(sdkKey), config.MobileKey(mobileKey)
+			action(fmt.Sprintf("flag change webhook secret of environment %q", name), &envCopy.FlagChangeWebhookSecret)
+			action(fmt.Sprintf("change feed token of environment %q", name), &envCopy.ChangeFeedToken)
+			envs[name] = &envCopy
+		}
+		c.Environment = envs
+	}
+	action("Redis password", &c.Redis.Password)
+	action("Redis Sentinel password", &c.Redis.SentinelPassword)
+	action("DynamoDB access key ID", &c.DynamoDB.AccessKeyID)
+	action("DynamoDB secret access key", &c.DynamoDB.SecretAccessKey)
+	action("MongoDB password", &c.MongoDB.Password)
+	action("etcd password", &c.Etcd.Password)
+	if c.RedisDatabase != nil {
+		dbs := make(map[string]*config.RedisConfig, len(c.RedisDatabase))
+		for name, db := range c.RedisDatabase {
+			dbCopy := *db
+			action(fmt.Sprintf("Redis password of database %q", name), &dbCopy.Password)
+			action(fmt.Sprintf("Redis Sentinel password of database %q", name), &dbCopy.SentinelPassword)
+			dbs[name] = &dbCopy
+		}
+		c.RedisDatabase = dbs
+	}
+	if c.DynamoDBDatabase != nil {
+		dbs := make(map[string]*config.DynamoDBConfig, len(c.DynamoDBDatabase))
+		for name, db := range c.DynamoDBDatabase {
+			dbCopy := *db
+			action(fmt.Sprintf("DynamoDB access key ID of database %q", name), &dbCopy.AccessKeyID)
+			action(fmt.Sprintf("DynamoDB secret access key of database %q", name), &dbCopy.SecretAccessKey)
+			dbs[name] = &dbCopy
+		}
+		c.DynamoDBDatabase = dbs
+	}
+	if c.MongoDBDatabase != nil {
+		dbs := make(map[string]*config.MongoDBConfig, len(c.MongoDBDatabase))
+		for name, db := range c.MongoDBDatabase {
+			dbCopy := *db
+			action(fmt.Sprintf("MongoDB password of database %q", name), &dbCopy.Password)
+			dbs[name] = &dbCopy
+		}
+		c.MongoDBDatabase = dbs
+	}
+	if c.EtcdDatabase != nil {
+		dbs := make(map[string]*config.EtcdConfig, len(c.EtcdDatabase))
+		for name, db := range c.EtcdDatabase {
+			dbCopy := *db
+			action(fmt.Sprintf("etcd password of database %q", name), &dbCopy.Password)
+			dbs[name] = &dbCopy
+		}
+		c.EtcdDatabase = dbs
+	}
+	action("Kafka password", &c.Kafka.Password)
+	action("snapshot Azure SAS token", &c.Snapshots.AzureSASToken)
+}
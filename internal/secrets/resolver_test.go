@@ -0,0 +1,164 @@
+package secrets
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	secrets  map[string]string
+	requests []string
+}
+
+func (p *fakeProvider) getSecret(path string) (string, error) {
+	p.requests = append(p.requests, path)
+	if s, ok := p.secrets[path]; ok {
+		return s, nil
+	}
+	return "", errors.New("no such secret")
+}
+
+func makeFakeResolver(vault, aws *fakeProvider) *Resolver {
+	factory := func(p *fakeProvider) func(config.SecretsConfig) (provider, error) {
+		return func(config.SecretsConfig) (provider, error) { return p, nil }
+	}
+	return newResolverWithFactories(config.SecretsConfig{}, map[string]func(config.SecretsConfig) (provider, error){
+		vaultScheme: factory(vault),
+		awsScheme:   factory(aws),
+		gcpScheme: func(config.SecretsConfig) (provider, error) {
+			return nil, errors.New("not configured")
+		},
+	})
+}
+
+func TestResolve(t *testing.T) {
+	vault := &fakeProvider{secrets: map[string]string{"secret/relay": `{"sdkKey": "sdk-from-vault"}`}}
+	aws := &fakeProvider{secrets: map[string]string{"relay-sdk-key": "sdk-from-aws"}}
+	r := makeFakeResolver(vault, aws)
+
+	t.Run("non-reference value is unchanged", func(t *testing.T) {
+		value, err := r.Resolve("sdk-plain")
+		require.NoError(t, err)
+		assert.Equal(t, "sdk-plain", value)
+	})
+
+	t.Run("key within secret", func(t *testing.T) {
+		value, err := r.Resolve("vault://secret/relay#sdkKey")
+		require.NoError(t, err)
+		assert.Equal(t, "sdk-from-vault", value)
+	})
+
+	t.Run("entire secret", func(t *testing.T) {
+		value, err := r.Resolve("aws-sm://relay-sdk-key")
+		require.NoError(t, err)
+		assert.Equal(t, "sdk-from-aws", value)
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		_, err := r.Resolve("aws-sm://unknown")
+		assert.Error(t, err)
+	})
+
+	t.Run("provider creation error", func(t *testing.T) {
+		_, err := r.Resolve("gcp-sm://projects/p/secrets/s")
+		assert.EqualError(t, err, "not configured")
+	})
+
+	t.Run("malformed reference", func(t *testing.T) {
+		_, err := r.Resolve("vault://secret/relay")
+		assert.Equal(t, errKeyRequired("vault://secret/relay"), err)
+	})
+}
+
+func TestHasSecretReferences(t *testing.T) {
+	assert.False(t, HasSecretReferences(config.Config{}))
+	assert.False(t, HasSecretReferences(config.Config{
+		Environment: map[string]*config.EnvConfig{"a": {SDKKey: "sdk-plain"}},
+	}))
+	assert.True(t, HasSecretReferences(config.Config{
+		Environment: map[string]*config.EnvConfig{"a": {SDKKey: "sdk-plain", MobileKey: "aws-sm://keys#mobileKey"}},
+	}))
+	assert.True(t, HasSecretReferences(config.Config{Redis: config.RedisConfig{Password: "aws-sm://redis"}}))
+	assert.True(t, HasSecretReferences(config.Config{DynamoDB: config.DynamoDBConfig{SecretAccessKey: "aws-sm://ddb#secret"}}))
+}
+
+func TestResolveConfigSecrets(t *testing.T) {
+	t.Run("replaces all references", func(t *testing.T) {
+		aws := &fakeProvider{secrets: map[string]string{
+			"keys":  `{"sdkKey": "sdk-a", "mobileKey": "mob-a"}`,
+			"redis": "redis-password",
+			"ddb":   `{"id": "key-id", "secret": "key-secret"}`,
+			"hook":  "hook-secret",
+		}}
+		r := makeFakeResolver(&fakeProvider{}, aws)
+		envs := map[string]*config.EnvConfig{
+			"a": {SDKKey: "aws-sm://keys#sdkKey", MobileKey: "aws-sm://keys#mobileKey", Prefix: "a"},
+			"b": {SDKKey: "sdk-b", FlagChangeWebhookSecret: "aws-sm://hook"},
+		}
+		c := config.Config{
+			Environment: envs,
+			Redis:       config.RedisConfig{Password: "aws-sm://redis"},
+			DynamoDB:    config.DynamoDBConfig{AccessKeyID: "aws-sm://ddb#id", SecretAccessKey: "aws-sm://ddb#secret"},
+		}
+
+		require.NoError(t, r.ResolveConfigSecrets(&c))
+
+		assert.Equal(t, map[string]*config.EnvConfig{
+			"a": {SDKKey: "sdk-a", MobileKey: "mob-a", Prefix: "a"},
+			"b": {SDKKey: "sdk-b", FlagChangeWebhookSecret: "hook-secret"},
+		}, c.Environment)
+		assert.Equal(t, "redis-password", c.Redis.Password)
+		assert.Equal(t, "key-id", c.DynamoDB.AccessKeyID)
+		assert.Equal(t, "key-secret", c.DynamoDB.SecretAccessKey)
+
+		// each secret should only have been retrieved once, even if several properties refer to it
+		assert.ElementsMatch(t, []string{"keys", "redis", "ddb", "hook"}, aws.requests)
+
+		// the original environment map should not have been modified
+		assert.Equal(t, config.SDKKey("aws-sm://keys#sdkKey"), envs["a"].SDKKey)
+	})
+
+	t.Run("replaces references in named databases", func(t *testing.T) {
+		aws := &fakeProvider{secrets: map[string]string{
+			"redis": "redis-password",
+			"ddb":   `{"id": "key-id", "secret": "key-secret"}`,
+			"mongo": "mongo-password",
+			"etcd":  "etcd-password",
+		}}
+		r := makeFakeResolver(&fakeProvider{}, aws)
+		redisDBs := map[string]*config.RedisConfig{"tenant1": {Password: "aws-sm://redis"}}
+		c := config.Config{
+			RedisDatabase: redisDBs,
+			DynamoDBDatabase: map[string]*config.DynamoDBConfig{
+				"tenant2": {AccessKeyID: "aws-sm://ddb#id", SecretAccessKey: "aws-sm://ddb#secret"},
+			},
+			MongoDBDatabase: map[string]*config.MongoDBConfig{"tenant3": {Password: "aws-sm://mongo"}},
+			EtcdDatabase:    map[string]*config.EtcdConfig{"tenant4": {Password: "aws-sm://etcd"}},
+		}
+
+		require.NoError(t, r.ResolveConfigSecrets(&c))
+
+		assert.Equal(t, "redis-password", c.RedisDatabase["tenant1"].Password)
+		assert.Equal(t, "key-id", c.DynamoDBDatabase["tenant2"].AccessKeyID)
+		assert.Equal(t, "key-secret", c.DynamoDBDatabase["tenant2"].SecretAccessKey)
+		assert.Equal(t, "mongo-password", c.MongoDBDatabase["tenant3"].Password)
+		assert.Equal(t, "etcd-password", c.EtcdDatabase["tenant4"].Password)
+
+		// the original database map should not have been modified
+		assert.Equal(t, "aws-sm://redis", redisDBs["tenant1"].Password)
+	})
+
+	t.Run("returns error naming the property", func(t *testing.T) {
+		r := makeFakeResolver(&fakeProvider{}, &fakeProvider{})
+		c := config.Config{Redis: config.RedisConfig{Password: "aws-sm://redis"}}
+
+		err := r.ResolveConfigSecrets(&c)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unable to resolve secret reference "aws-sm://redis" for Redis password`)
+	})
+}
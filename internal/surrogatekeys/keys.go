@@ -0,0 +1,68 @@
+package surrogatekeys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+)
+
+const (
+	// HeaderName is the response header that contains a response's surrogate keys, separated by spaces.
+	HeaderName = "Surrogate-Key"
+
+	prefix          = "ld-"
+	allFlagsSuffix  = "-flags"
+	evalSuffix      = "-eval"
+	flagInfix       = "-flag-"
+	segmentInfix    = "-segment-"
+	hashedKeyLength = 16
+)
+
+// EnvKey returns the part of every surrogate key that identifies an environment. This is the environment
+// ID if it is known, since that is not secret. Otherwise it is a prefix of the SHA-256 hash of the SDK
+// key, so that the SDK key itself is never exposed in a response header.
+func EnvKey(envID config.EnvironmentID, sdkKey config.SDKKey) string {
+	if envID != "" {
+		return string(envID)
+	}
+	sum := sha256.Sum256([]byte(sdkKey))
+	return hex.EncodeToString(sum[:])[:hashedKeyLength]
+}
+
+// Environment returns the surrogate key that every cacheable response for the environment has.
+func Environment(envKey string) string {
+	return prefix + envKey
+}
+
+// AllFlags returns the surrogate key of responses that contain all of the environment's flags.
+func AllFlags(envKey string) string {
+	return prefix + envKey + allFlagsSuffix
+}
+
+// Evaluations returns the surrogate key of responses that contain evaluation results, which can depend
+// on any flag or segment.
+func Evaluations(envKey string) string {
+	return prefix + envKey + evalSuffix
+}
+
+// Flag returns the surrogate key of responses that contain a single flag.
+func Flag(envKey, flagKey string) string {
+	return prefix + envKey + flagInfix + flagKey
+}
+
+// Segment returns the surrogate key of responses that contain a single segment.
+func Segment(envKey, segmentKey string) string {
+	return prefix + envKey + segmentInfix + segmentKey
+}
+
+// ForFlagChange returns the surrogate keys of all responses that can be affected when a flag changes.
+func ForFlagChange(envKey, flagKey string) []string {
+	return []string{Flag(envKey, flagKey), AllFlags(envKey), Evaluations(envKey)}
+}
+
+// ForSegmentChange returns the surrogate keys of all responses that can be affected when a segment
+// changes.
+func ForSegmentChange(envKey, segmentKey string) []string {
+	return []string{Segment(envKey, segmentKey), Evaluations(envKey)}
+}
@@ -0,0 +1,24 @@
+package surrogatekeys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvKeyUsesEnvironmentID(t *testing.T) {
+	assert.Equal(t, "env-id", EnvKey("env-id", "sdk-key"))
+}
+
+func TestEnvKeyHashesSDKKeyIfThereIsNoEnvironmentID(t *testing.T) {
+	key := EnvKey("", "sdk-key")
+	assert.Len(t, key, hashedKeyLength)
+	assert.NotContains(t, key, "sdk-key")
+	assert.Equal(t, key, EnvKey("", "sdk-key"))
+	assert.NotEqual(t, key, EnvKey("", "other-sdk-key"))
+}
+
+func TestKeysForChanges(t *testing.T) {
+	assert.Equal(t, []string{"ld-env-flag-flag1", "ld-env-flags", "ld-env-eval"}, ForFlagChange("env", "flag1"))
+	assert.Equal(t, []string{"ld-env-segment-seg1", "ld-env-eval"}, ForSegmentChange("env", "seg1"))
+}
@@ -0,0 +1,4 @@
+// Package surrogatekeys defines the surrogate keys that Relay adds to cacheable responses, so that a CDN
+// or other HTTP cache in front of Relay can purge just the responses that are affected by a change to a
+// flag or segment.
+package surrogatekeys
@@ -0,0 +1,3 @@
+// Package webhook contains the client that Relay uses to post JSON notifications to webhook URLs, such as
+// flag change notifications and audit events.
+package webhook
@@ -0,0 +1,210 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+const (
+	// SignatureHeader is the request header that contains the HMAC-SHA256 signature of the request body,
+	// as "sha256=" followed by the hex-encoded signature, if a secret is configured.
+	SignatureHeader = "X-LD-Signature"
+
+	// DefaultTimeout is the HTTP client timeout that Relay uses for webhook requests.
+	DefaultTimeout = time.Second * 10
+
+	queueSize     = 1000
+	initialDelay  = time.Second
+	maxRetryDelay = time.Second * 30
+)
+
+func errStatus(status int) error {
+	return fmt.Errorf("HTTP status %d", status)
+}
+
+// Options are the parameters for NewSender.
+type Options struct {
+	// Description is what is being sent, in the plural, such as "audit events"; it is used in log messages.
+	Description string
+
+	// Headers are added to each request.
+	Headers http.Header
+
+	// Secret, if not empty, is used to sign each request; see SignatureHeader.
+	Secret string
+
+	// Client is the HTTP client to use.
+	Client *http.Client
+
+	// MaxAttempts is the number of times a request is tried before it is given up on. Zero is the same
+	// as 1, meaning there are no retries.
+	MaxAttempts int
+}
+
+// Sender posts each notification to one URL. Notifications are sent one at a time, in order, by a single
+// goroutine, so that a slow webhook does not delay whatever is sending them. If Options.MaxAttempts is
+// greater than 1, a failed request is retried with exponential backoff, unless the webhook responded with
+// a 4xx status other than 429, since repeating the same request would not help.
+type Sender struct {
+	url          string
+	displayURL   string // without any credentials or query string, for log messages
+	options      Options
+	secret       []byte
+	initialDelay time.Duration
+	queue        chan []byte
+	closer       chan struct{}
+	closeOnce    sync.Once
+	done         sync.WaitGroup
+	failing      bool
+	loggers      ldlog.Loggers
+}
+
+type result struct {
+	err       error
+	retryable bool
+}
+
+// NewSender creates a Sender and starts its goroutine.
+func NewSender(rawURL string, options Options, loggers ldlog.Loggers) *Sender {
+	displayURL := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		displayURL = (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+	}
+	if options.Client == nil {
+		options.Client = &http.Client{Timeout: DefaultTimeout}
+	}
+	w := &Sender{
+		url:          rawURL,
+		displayURL:   displayURL,
+		options:      options,
+		initialDelay: initialDelay,
+		queue:        make(chan []byte, queueSize),
+		closer:       make(chan struct{}),
+		loggers:      loggers,
+	}
+	if options.Secret != "" {
+		w.secret = []byte(options.Secret)
+	}
+	w.done.Add(1)
+	go w.run()
+	return w
+}
+
+// Enqueue adds a request body to the queue. If the queue is full, it is dropped.
+func (w *Sender) Enqueue(data []byte) {
+	select {
+	case <-w.closer:
+		return
+	default:
+	}
+	select {
+	case w.queue <- data:
+	default:
+		w.loggers.Warnf("Queue of %s for %s is full; dropping the newest one", w.options.Description, w.displayURL)
+	}
+}
+
+// Close waits until the notifications that were already queued have been sent. Failed requests are not
+// retried after Close has been called.
+func (w *Sender) Close() {
+	w.closeOnce.Do(func() {
+		close(w.closer)
+		w.done.Wait()
+	})
+}
+
+func (w *Sender) run() {
+	defer w.done.Done()
+	for {
+		select {
+		case data := <-w.queue:
+			w.send(data)
+		case <-w.closer:
+			for len(w.queue) > 0 {
+				w.send(<-w.queue)
+			}
+			return
+		}
+	}
+}
+
+func (w *Sender) send(data []byte) {
+	r := w.post(data)
+	delay := w.initialDelay
+	for attempt := 1; r.err != nil && r.retryable && attempt < w.options.MaxAttempts; attempt++ {
+		if !w.sleep(delay) {
+			break
+		}
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+		r = w.post(data)
+	}
+	// Only the first of a series of failures is logged, so an unavailable webhook doesn't cause a
+	// warning for every notification
+	if r.err != nil {
+		if !w.failing {
+			w.loggers.Warnf("Failed to send %s to %s: %s", w.options.Description, w.displayURL, r.err)
+			w.failing = true
+		}
+		return
+	}
+	if w.failing {
+		w.loggers.Infof("Resumed sending %s to %s", w.options.Description, w.displayURL)
+		w.failing = false
+	}
+}
+
+// sleep waits before a retry, and returns false if Close was called in the meantime.
+func (w *Sender) sleep(delay time.Duration) bool {
+	select {
+	case <-time.After(delay):
+		return true
+	case <-w.closer:
+		return false
+	}
+}
+
+func (w *Sender) post(data []byte) result {
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(data))
+	if err != nil { // COVERAGE: can't happen in unit tests
+		return result{err: err}
+	}
+	for name, values := range w.options.Headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != nil {
+		req.Header.Set(SignatureHeader, Sign(w.secret, data))
+	}
+	resp, err := w.options.Client.Do(req)
+	if err != nil {
+		return result{err: err, retryable: true}
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return result{err: errStatus(resp.StatusCode), retryable: retryable}
+	}
+	return result{}
+}
+
+// Sign returns the value of SignatureHeader for a request body. A webhook can verify a request by
+// computing this with the same secret, and comparing it to the header with hmac.Equal.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// startSequenceServer returns each of statuses in turn, and then 200.
+func startSequenceServer(statuses ...int) (*httptest.Server, <-chan webhookRequest) {
+	requestsCh := make(chan webhookRequest, 100)
+	var lock sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requestsCh <- webhookRequest{header: r.Header, body: body}
+		lock.Lock()
+		status := http.StatusOK
+		if len(statuses) != 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		lock.Unlock()
+		w.WriteHeader(status)
+	}))
+	return server, requestsCh
+}
+
+const testMaxAttempts = 5
+
+func makeTestWebhookSender(url, secret string, loggers ldlog.Loggers) *Sender {
+	headers := make(http.Header)
+	headers.Set("User-Agent", "Relay/1.0")
+	w := NewSender(url, Options{
+		Description: "test notifications",
+		Headers:     headers,
+		Secret:      secret,
+		Client:      http.DefaultClient,
+		MaxAttempts: testMaxAttempts,
+	}, loggers)
+	w.initialDelay = time.Millisecond
+	return w
+}
+
+func TestWebhookSenderSignsRequests(t *testing.T) {
+	server, requestsCh := startSequenceServer()
+	defer server.Close()
+	w := makeTestWebhookSender(server.URL, "shh", ldlog.NewDisabledLoggers())
+	w.Enqueue([]byte(`{"key":"a"}`))
+	w.Close()
+
+	require.Len(t, requestsCh, 1)
+	r := <-requestsCh
+	assert.Equal(t, `{"key":"a"}`, string(r.body))
+	assert.Equal(t, "application/json", r.header.Get("Content-Type"))
+	assert.Equal(t, "Relay/1.0", r.header.Get("User-Agent"))
+	signature := r.header.Get(SignatureHeader)
+	assert.True(t, hmac.Equal([]byte(Sign([]byte("shh"), r.body)), []byte(signature)))
+	assert.Equal(t, "sha256=", signature[:7])
+	assert.Len(t, signature, 7+64)
+}
+
+func TestWebhookSenderDoesNotSignRequestsWithoutSecret(t *testing.T) {
+	server, requestsCh := startSequenceServer()
+	defer server.Close()
+	w := makeTestWebhookSender(server.URL, "", ldlog.NewDisabledLoggers())
+	w.Enqueue([]byte(`{}`))
+	w.Close()
+
+	require.Len(t, requestsCh, 1)
+	assert.Equal(t, "", (<-requestsCh).header.Get(SignatureHeader))
+}
+
+func TestWebhookSenderRetriesWithBackoff(t *testing.T) {
+	server, requestsCh := startSequenceServer(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	defer server.Close()
+	mockLog := ldlogtest.NewMockLog()
+	w := makeTestWebhookSender(server.URL, "", mockLog.Loggers)
+	defer w.Close()
+	w.Enqueue([]byte(`{}`))
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-requestsCh:
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for retry")
+		}
+	}
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 0)
+}
+
+func TestWebhookSenderStopsRetryingWhenClosed(t *testing.T) {
+	server, requestsCh := startSequenceServer(http.StatusServiceUnavailable)
+	defer server.Close()
+	w := makeTestWebhookSender(server.URL, "", ldlog.NewDisabledLoggers())
+	w.initialDelay = time.Hour
+	w.Enqueue([]byte(`{}`))
+	<-requestsCh
+	w.Close()
+
+	assert.Len(t, requestsCh, 0)
+}
+
+func TestWebhookSenderDoesNotRetryClientErrors(t *testing.T) {
+	server, requestsCh := startSequenceServer(http.StatusBadRequest)
+	defer server.Close()
+	mockLog := ldlogtest.NewMockLog()
+	w := makeTestWebhookSender(server.URL, "", mockLog.Loggers)
+	w.Enqueue([]byte(`{}`))
+	w.Close()
+
+	assert.Len(t, requestsCh, 1)
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Failed to send test notifications to .*: HTTP status 400")
+}
+
+func TestWebhookSenderLogsFailuresOnce(t *testing.T) {
+	statuses := make([]int, 2*testMaxAttempts)
+	for i := range statuses {
+		statuses[i] = http.StatusInternalServerError
+	}
+	server, requestsCh := startSequenceServer(statuses...)
+	defer server.Close()
+	mockLog := ldlogtest.NewMockLog()
+	w := makeTestWebhookSender(server.URL+"/hook?token=xyz", "", mockLog.Loggers)
+	w.Enqueue([]byte(`{}`))
+	w.Enqueue([]byte(`{}`))
+	w.Enqueue([]byte(`{}`))
+	for i := 0; i < 2*testMaxAttempts+1; i++ {
+		select {
+		case <-requestsCh:
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for request")
+		}
+	}
+	w.Close()
+
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 1)
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Failed to send test notifications to "+server.URL+"/hook: HTTP status 500")
+	mockLog.AssertMessageMatch(t, false, ldlog.Warn, "token")
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "Resumed sending test notifications to "+server.URL+"/hook")
+}
@@ -1,19 +1,35 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	_ "github.com/kardianos/minwinsvc"
 
 	"github.com/launchdarkly/ld-relay/v6/config"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/application"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/logging"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/migration"
+	"github.com/launchdarkly/ld-relay/v6/internal/secrets"
 	"github.com/launchdarkly/ld-relay/v6/relay"
 	"github.com/launchdarkly/ld-relay/v6/relay/version"
+
+	"github.com/quic-go/quic-go/http3"
+	"google.golang.org/grpc"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 )
 
+// checkConnectionsTimeout is how long each check can take in the check-config command.
+const checkConnectionsTimeout = time.Second * 10
+
 func main() {
-	var c config.Config
 	loggers := logging.MakeDefaultLoggers()
 
 	opts, err := application.ReadOptions(os.Args, os.Stderr)
@@ -22,49 +38,517 @@ func main() {
 		os.Exit(1)
 	}
 
+	if opts.Command == application.CommandCheckConfig {
+		if !checkConfig(opts, loggers) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if opts.Command == application.CommandMigrateStore {
+		if !migrateStore(opts, loggers) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	loggers.Infof(
 		"Starting LaunchDarkly relay version %s with %s\n",
 		application.DescribeRelayVersion(version.Version),
 		opts.DescribeConfigSource(),
 	)
 
+	c, hasSecretReferences, ok := loadConfig(opts, loggers)
+	if !ok {
+		os.Exit(1)
+	}
+
+	if c.Main.LogFormat == config.LogFormatJSON {
+		loggers = logging.MakeJSONLoggers(logging.Fields{})
+	}
+
+	r, err := relay.NewRelay(c, loggers, nil)
+	if err != nil {
+		loggers.Errorf("Unable to create relay: %s", err)
+		os.Exit(1)
+	}
+
+	if c.Main.ExitAlways {
+		os.Exit(0)
+	}
+
+	startConfigReloading(opts, c, hasSecretReferences, r, loggers)
+	startMaintenanceModeSignal(r)
+
+	shutdownCh := make(chan os.Signal, 1)
+	signal.Notify(shutdownCh, syscall.SIGTERM, os.Interrupt)
+
+	var proxyProtocol *application.ProxyProtocolOptions
+	if c.Main.ProxyProtocolEnabled {
+		proxyProtocol = &application.ProxyProtocolOptions{AllowedSources: c.Main.ProxyProtocolAllowedSource.Values()}
+	}
+	listeners, err := application.NewListenerSource(c.Main.ReusePort, proxyProtocol, loggers)
+	if err != nil {
+		loggers.Errorf("Unable to set up listeners: %s", err)
+		os.Exit(1)
+	}
+
+	port := c.Main.Port.GetOrElse(config.DefaultPort)
+	readHeaderTimeout := c.Main.ReadHeaderTimeout.GetOrElse(config.DefaultReadHeaderTimeout)
+	maxHeaderBytes := c.Main.MaxRequestHeaderSize.GetOrElse(0)
+
+	certs, tenantCerts, err := makeCertificateSources(c, loggers)
+	if err != nil {
+		loggers.Errorf("Unable to load TLS certificate: %s", err)
+		os.Exit(1)
+	}
+
+	var httpServers []*http.Server
+	var http3Server *http3.Server
+	var http3Errs <-chan error // remains nil, and therefore never receives, if HTTP/3 is not enabled
+	var errs <-chan error      // remains nil, and therefore never receives, if only the Unix socket is enabled
+	if !c.Main.UnixSocketOnly {
+		var handler http.Handler = r
+		if c.Main.HTTP3Enabled {
+			http3Server, http3Errs = application.StartHTTP3Server(
+				port,
+				r,
+				certs,
+				c.Main.TLSClientCertRequired,
+				maxHeaderBytes,
+				loggers,
+			)
+			handler = application.AdvertiseHTTP3(http3Server, r)
+		}
+		var srv *http.Server
+		srv, errs = application.StartHTTPServer(
+			listeners,
+			port,
+			handler,
+			certs,
+			c.Main.TLSMinVersion.Get(),
+			c.Main.TLSClientCertRequired,
+			c.Main.H2CEnabled,
+			readHeaderTimeout,
+			maxHeaderBytes,
+			c.Main.TCPKeepAlive.GetOrElse(0),
+			loggers,
+		)
+		httpServers = append(httpServers, srv)
+	}
+
+	var unixSocketErrs <-chan error // remains nil, and therefore never receives, if the Unix socket is not enabled
+	if c.Main.UnixSocket != "" {
+		var unixSocketServer *http.Server
+		unixSocketServer, unixSocketErrs = application.StartHTTPServerOnUnixSocket(
+			listeners,
+			c.Main.UnixSocket,
+			c.Main.UnixSocketMode.Get(),
+			r,
+			c.Main.H2CEnabled,
+			readHeaderTimeout,
+			maxHeaderBytes,
+			loggers,
+		)
+		httpServers = append(httpServers, unixSocketServer)
+	}
+
+	var grpcServer *grpc.Server
+	var grpcErrs <-chan error // remains nil, and therefore never receives, if gRPC is not enabled
+	grpcPort := c.Main.GRPCPort.GetOrElse(0)
+	if c.Main.GRPCPort.IsDefined() {
+		grpcServer, grpcErrs = application.StartGRPCServer(
+			listeners,
+			grpcPort,
+			r.RegisterGRPCServices,
+			certs,
+			c.Main.TLSMinVersion.Get(),
+			loggers,
+		)
+	}
+
+	var adminErrs <-chan error // remains nil, and therefore never receives, if the admin API is not enabled
+	adminPort := c.Admin.Port.GetOrElse(0)
+	if c.Admin.Port.IsDefined() {
+		loggers.Info("Admin API enabled")
+		if c.Admin.EnableProfiling {
+			loggers.Warn("Profiling endpoints are enabled on the admin port")
+		}
+		var adminServer *http.Server
+		adminServer, adminErrs = application.StartHTTPServer(
+			listeners,
+			adminPort,
+			r.AdminHandler(),
+			certs,
+			c.Main.TLSMinVersion.Get(),
+			false, // the admin API is authenticated with the admin key, not client certificates
+			false,
+			readHeaderTimeout,
+			maxHeaderBytes,
+			0,
+			loggers,
+		)
+		httpServers = append(httpServers, adminServer)
+	}
+
+	tenantErrs := make(chan error, len(c.Tenant)) // receives an error if any tenant's listener fails
+	for name, tc := range c.Tenant {
+		if !tc.Port.IsDefined() {
+			continue // this tenant is only selected by host name on the main port
+		}
+		tenantPort := tc.Port.GetOrElse(0)
+		loggers.Infof("Tenant %q enabled on port %d", name, tenantPort)
+		tenantServerCerts := certs
+		if tcerts, ok := tenantCerts[name]; ok {
+			tenantServerCerts = tcerts
+		}
+		tenantServer, errs := application.StartHTTPServer(
+			listeners,
+			tenantPort,
+			r.TenantHandler(name),
+			tenantServerCerts,
+			c.Main.TLSMinVersion.Get(),
+			c.Main.TLSClientCertRequired,
+			c.Main.H2CEnabled,
+			readHeaderTimeout,
+			maxHeaderBytes,
+			tc.TCPKeepAlive.GetOrElse(c.Main.TCPKeepAlive.GetOrElse(0)),
+			loggers,
+		)
+		httpServers = append(httpServers, tenantServer)
+		go func(name string) {
+			if err, ok := <-errs; ok {
+				tenantErrs <- fmt.Errorf("tenant %q on port %d: %w", name, tenantPort, err)
+			}
+		}(name)
+	}
+
+	var acmeErrs <-chan error // remains nil, and therefore never receives, if the ACME HTTP-01 port is not enabled
+	acmeHTTPPort := c.ACME.HTTPPort.GetOrElse(0)
+	if certs != nil && certs.ACMEChallengeHandler() != nil && c.ACME.HTTPPort.IsDefined() {
+		var acmeServer *http.Server
+		acmeServer, acmeErrs = application.StartHTTPServer(
+			listeners,
+			acmeHTTPPort,
+			certs.ACMEChallengeHandler(),
+			nil, // ACME HTTP-01 challenges are always plain HTTP
+			0,
+			false,
+			false,
+			readHeaderTimeout,
+			maxHeaderBytes,
+			0,
+			loggers,
+		)
+		httpServers = append(httpServers, acmeServer)
+	}
+
+	var internalMetricsErrs <-chan error // remains nil, and therefore never receives, if internal metrics are not enabled
+	internalMetricsPort := c.InternalMetrics.Port.GetOrElse(0)
+	if c.InternalMetrics.Port.IsDefined() {
+		loggers.Info("Internal metrics endpoint enabled")
+		// Like the Prometheus integration, the internal metrics endpoint is meant to be scraped from
+		// within a private network, so it does not use TLS.
+		var internalMetricsServer *http.Server
+		internalMetricsServer, internalMetricsErrs = application.StartHTTPServer(
+			listeners,
+			internalMetricsPort,
+			r.InternalMetricsHandler(),
+			nil,
+			0,
+			false,
+			false,
+			readHeaderTimeout,
+			maxHeaderBytes,
+			0,
+			loggers,
+		)
+		httpServers = append(httpServers, internalMetricsServer)
+	}
+
+	systemd := application.NewSystemdNotifier(loggers)
+	systemd.Start(r.IsReady)
+
+	select {
+	case err := <-errs:
+		loggers.Errorf("Error starting http listener on port: %d  %s", port, err)
+	case err := <-http3Errs:
+		loggers.Errorf("Error starting HTTP/3 listener on UDP port: %d  %s", port, err)
+	case err := <-unixSocketErrs:
+		loggers.Errorf("Error starting http listener on Unix socket: %s  %s", c.Main.UnixSocket, err)
+	case err := <-grpcErrs:
+		loggers.Errorf("Error starting gRPC listener on port: %d  %s", grpcPort, err)
+	case err := <-adminErrs:
+		loggers.Errorf("Error starting admin API listener on port: %d  %s", adminPort, err)
+	case err := <-tenantErrs:
+		loggers.Errorf("Error starting http listener for %s", err)
+	case err := <-acmeErrs:
+		loggers.Errorf("Error starting ACME HTTP listener on port: %d  %s", acmeHTTPPort, err)
+	case err := <-internalMetricsErrs:
+		loggers.Errorf("Error starting internal metrics listener on port: %d  %s", internalMetricsPort, err)
+	case sig := <-shutdownCh:
+		loggers.Infof("Shutting down (received signal: %s)", sig)
+		systemd.Stopping()
+		timeout := c.Main.ShutdownTimeout.GetOrElse(config.DefaultShutdownTimeout)
+		if shutdown(timeout, httpServers, http3Server, grpcServer, r, shutdownCh, loggers) {
+			loggers.Info("Shutdown complete")
+			os.Exit(0)
+		}
+	}
+	os.Exit(1)
+}
+
+// makeCertificateSources returns the source of TLS certificates for the main server and the other
+// servers that use the same TLS settings, or nil if TLS is not enabled. It also returns a source for
+// each tenant that has its own certificate; the main source uses that certificate too, for connections
+// that request one of the tenant's host names with SNI.
+func makeCertificateSources(
+	c config.Config,
+	loggers ldlog.Loggers,
+) (*application.CertificateSource, map[string]*application.CertificateSource, error) {
+	if !c.Main.TLSEnabled {
+		return nil, nil, nil
+	}
+	var certs *application.CertificateSource
+	if domains := c.ACME.Domain.Values(); len(domains) != 0 {
+		loggers.Infof("TLS certificates will be obtained with ACME for: %s", strings.Join(domains, ", "))
+		certs = application.NewACMECertificateSource(application.ACMEOptions{
+			Domains:      domains,
+			Email:        c.ACME.Email,
+			CacheDir:     c.ACME.CacheDir,
+			DirectoryURL: c.ACME.DirectoryURL.String(),
+		})
+	} else {
+		var err error
+		if certs, err = application.NewCertificateFileSource(c.Main.TLSCert, c.Main.TLSKey, loggers); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tenantCerts := make(map[string]*application.CertificateSource)
+	byHost := make(map[string]*application.CertificateSource)
+	for name, tc := range c.Tenant {
+		if tc.TLSCert == "" {
+			continue
+		}
+		tcerts, err := application.NewCertificateFileSource(tc.TLSCert, tc.TLSKey, loggers)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tenant %q: %w", name, err)
+		}
+		tenantCerts[name] = tcerts
+		for _, host := range tc.Host.Values() {
+			byHost[host] = tcerts
+		}
+	}
+	if len(byHost) != 0 {
+		certs = application.NewSNICertificateSource(certs, byHost)
+	}
+	return certs, tenantCerts, nil
+}
+
+// shutdown stops the servers from accepting new connections and closes the Relay instance, which tells
+// streaming clients to reconnect, delivers any pending analytics events, and closes the data stores. It
+// returns false if that did not finish within the timeout, or if another signal was received meanwhile.
+func shutdown(
+	timeout time.Duration,
+	httpServers []*http.Server,
+	http3Server *http3.Server,
+	grpcServer *grpc.Server,
+	r *relay.Relay,
+	signalCh <-chan os.Signal,
+	loggers ldlog.Loggers,
+) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	run := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	// The servers close their listeners right away, but then wait for active requests to finish.
+	// Streaming requests don't finish until r.Close closes the streams, so all of these run at once.
+	for _, srv := range httpServers {
+		srv := srv
+		run(func() { _ = srv.Shutdown(ctx) })
+	}
+	if http3Server != nil {
+		// the HTTP/3 server has no graceful shutdown, so its requests are ended right away
+		run(func() { _ = http3Server.Close() })
+	}
+	if grpcServer != nil {
+		run(grpcServer.GracefulStop)
+	}
+	run(func() { _ = r.Close() })
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		loggers.Warnf("Shutdown did not finish within %s; exiting anyway", timeout)
+	case <-signalCh:
+		loggers.Warn("Received another signal during shutdown; exiting immediately")
+	}
+	return false
+}
+
+// loadConfig reads and validates the configuration, and replaces any secret references in it with the
+// secret values. It also returns true in hasSecretReferences if there were any such references.
+func loadConfig(opts application.Options, loggers ldlog.Loggers) (c config.Config, hasSecretReferences bool, ok bool) {
 	if opts.ConfigFile != "" {
 		if err := config.LoadConfigFile(&c, opts.ConfigFile, loggers); err != nil {
 			loggers.Errorf("Error loading config file: %s", err)
-			os.Exit(1)
+			return c, false, false
 		}
 	}
 	if opts.UseEnvironment {
 		if err := config.LoadConfigFromEnvironment(&c, loggers); err != nil {
 			loggers.Errorf("Configuration error: %s", err)
-			os.Exit(1)
+			return c, false, false
 		}
 	}
+	if !secrets.HasSecretReferences(c) {
+		return c, false, true
+	}
+	if c.OfflineMode.NoOutboundConnections {
+		loggers.Error("Configuration error: secret references cannot be used if outbound connections are disabled")
+		return c, true, false
+	}
+	if err := secrets.NewResolver(c.Secrets).ResolveConfigSecrets(&c); err != nil {
+		loggers.Errorf("Unable to resolve secrets: %s", err)
+		return c, true, false
+	}
+	return c, true, true
+}
 
-	r, err := relay.NewRelay(c, loggers, nil)
+// checkConfig implements the check-config command: it loads the configuration in the same way as when
+// Relay starts, and then does the checks that are not part of the usual validation. It logs each error
+// that it finds, and returns false if there were any.
+func checkConfig(opts application.Options, loggers ldlog.Loggers) bool {
+	loggers.Infof("Checking %s", opts.DescribeConfigSource())
+	c, _, ok := loadConfig(opts, loggers)
+	if !ok {
+		return false
+	}
+	errs := application.CheckCredentials(c)
+	if opts.CheckConnections {
+		errs = append(errs, application.CheckConnections(c, "LDRelay/"+version.Version, checkConnectionsTimeout, loggers)...)
+	}
+	for _, err := range errs {
+		loggers.Errorf("Configuration error: %s", err)
+	}
+	if len(errs) != 0 {
+		return false
+	}
+	loggers.Info("Configuration is valid")
+	return true
+}
+
+// migrateStore implements the migrate-store command: it copies the data for each prefix from one store to
+// another, or with --verify, compares the data in the two stores. It logs each error that it finds, and
+// returns false if there were any.
+func migrateStore(opts application.Options, loggers ldlog.Loggers) bool {
+	from, err := migration.ConfigForStoreURL(opts.MigrateFrom)
 	if err != nil {
-		loggers.Errorf("Unable to create relay: %s", err)
-		os.Exit(1)
+		loggers.Errorf("Source store: %s", err)
+		return false
+	}
+	to, err := migration.ConfigForStoreURL(opts.MigrateTo)
+	if err != nil {
+		loggers.Errorf("Target store: %s", err)
+		return false
+	}
+	prefixes := []migration.PrefixMapping{{}} // the default prefix in both stores
+	if len(opts.MigratePrefixes) != 0 {
+		prefixes = nil
+		for _, p := range opts.MigratePrefixes {
+			prefixes = append(prefixes, migration.ParsePrefixMapping(p))
+		}
 	}
 
-	if c.Main.ExitAlways {
-		os.Exit(0)
+	if opts.Verify {
+		errs := migration.Verify(from, to, prefixes, loggers)
+		for _, err := range errs {
+			loggers.Errorf("Verification error: %s", err)
+		}
+		if len(errs) != 0 {
+			return false
+		}
+		loggers.Info("The stores contain the same data")
+		return true
 	}
+	if err := migration.Migrate(from, to, prefixes, loggers); err != nil {
+		loggers.Errorf("Migration error: %s", err)
+		return false
+	}
+	loggers.Info("Migration complete")
+	return true
+}
 
-	port := c.Main.Port.GetOrElse(config.DefaultPort)
+// startConfigReloading causes the configuration to be reloaded whenever the configuration file changes,
+// or when the process receives a SIGHUP signal (which also re-reads environment variables). If the new
+// configuration is invalid, the error is logged and Relay keeps running with the previous one.
+//
+// If the configuration contains secret references, it is also reloaded at the configured secrets refresh
+// interval, so that rotated SDK keys and mobile keys take effect.
+func startConfigReloading(
+	opts application.Options,
+	c config.Config,
+	hasSecretReferences bool,
+	r *relay.Relay,
+	loggers ldlog.Loggers,
+) {
+	reloadCh := make(chan struct{}, 1)
+	requestReload := func() {
+		select {
+		case reloadCh <- struct{}{}:
+		default: // a reload is already pending
+		}
+	}
 
-	_, errs := application.StartHTTPServer(
-		port,
-		r,
-		c.Main.TLSEnabled,
-		c.Main.TLSCert,
-		c.Main.TLSKey,
-		c.Main.TLSMinVersion.Get(),
-		loggers,
-	)
+	requestReloadWithMessage := func() {
+		loggers.Infof("Reloading %s", opts.DescribeConfigSource())
+		requestReload()
+	}
 
-	for err := range errs {
-		loggers.Errorf("Error starting http listener on port: %d  %s", port, err)
-		os.Exit(1)
+	if opts.ConfigFile != "" {
+		if _, err := application.WatchConfigFile(opts.ConfigFile, requestReloadWithMessage, loggers); err != nil {
+			loggers.Warnf("Unable to watch config file for changes: %s", err)
+		}
 	}
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGHUP)
+	go func() {
+		for range signalCh {
+			requestReloadWithMessage()
+		}
+	}()
+
+	if hasSecretReferences {
+		ticker := time.NewTicker(c.Secrets.RefreshInterval.GetOrElse(config.DefaultSecretsRefreshInterval))
+		go func() {
+			for range ticker.C {
+				requestReload()
+			}
+		}()
+	}
+
+	go func() {
+		for range reloadCh {
+			if c, _, ok := loadConfig(opts, loggers); ok {
+				if err := r.ReloadConfig(c); err != nil {
+					loggers.Errorf("Unable to apply new configuration: %s", err)
+				}
+			}
+		}
+	}()
 }
@@ -0,0 +1,307 @@
+package relay
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/audit"
+	"github.com/launchdarkly/ld-relay/v6/internal/core"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	"github.com/launchdarkly/ld-relay/v6/internal/util"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+const (
+	adminEnvironmentsPath = "/admin/environments"
+	adminMaintenancePath  = "/admin/maintenance"
+)
+
+var (
+	errAdminEnvNotFound    = errors.New("environment not found")
+	errAdminEnvExists      = errors.New("an environment with that name already exists")
+	errAdminEnvNameMissing = errors.New("environment name is required")
+)
+
+var sourceAdmin = changeSource{logName: "Admin API", audit: audit.SourceAdminAPI} //nolint:gochecknoglobals
+
+// adminEnvironmentRep is the JSON representation of an environment in the admin API. The credentials
+// are obscured in the same way as in the status resource.
+type adminEnvironmentRep struct {
+	SDKKey    string `json:"sdkKey"`
+	MobileKey string `json:"mobileKey,omitempty"`
+	EnvID     string `json:"envId,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+	TableName string `json:"tableName,omitempty"`
+	Database  string `json:"database,omitempty"`
+
+	KillSwitch bool `json:"killSwitch,omitempty"`
+}
+
+// adminMaintenanceRep is the JSON representation of the maintenance mode state in the admin API.
+type adminMaintenanceRep struct {
+	Maintenance bool `json:"maintenance"`
+}
+
+func makeAdminEnvironmentRep(envConfig config.EnvConfig) adminEnvironmentRep {
+	return adminEnvironmentRep{
+		SDKKey:    core.ObscureKey(string(envConfig.SDKKey)),
+		MobileKey: core.ObscureKey(string(envConfig.MobileKey)),
+		EnvID:     string(envConfig.EnvID),
+		Prefix:    envConfig.Prefix,
+		TableName: envConfig.TableName,
+		Database:  envConfig.Database,
+	}
+}
+
+// AdminHandler returns the HTTP handler for the admin API, which allows environments to be added,
+// removed, and updated at runtime. The caller is responsible for serving it on the admin port, which is
+// set in config.AdminConfig; it should not be served on the same port as the SDK endpoints.
+//
+// Every request must provide the admin key from config.AdminConfig in the Authorization header.
+//
+// Changes made with the admin API are not saved anywhere. If the configuration is reloaded (see
+// ReloadConfig), the environments are changed to match the new configuration.
+//
+// Each environment also has a kill switch, which can be turned on and off with the admin API; see
+// relayenv.EnvContext.SetKillSwitch. Unlike other changes, this does not change the configuration.
+// Maintenance mode can be turned on and off in the same way; see ToggleMaintenanceMode.
+//
+// If EnableProfiling is set in config.AdminConfig, it also serves the runtime profiling endpoints.
+func (r *Relay) AdminHandler() http.Handler {
+	router := mux.NewRouter()
+	router.Use(adminAuthMiddleware(r.config.Admin.Key))
+	router.HandleFunc(adminEnvironmentsPath, r.adminListEnvironments).Methods("GET")
+	router.HandleFunc(adminEnvironmentsPath, r.adminAddEnvironment).Methods("POST")
+	router.HandleFunc(adminEnvironmentsPath+"/{name}", r.adminGetEnvironment).Methods("GET")
+	router.HandleFunc(adminEnvironmentsPath+"/{name}", r.adminUpdateEnvironment).Methods("PATCH")
+	router.HandleFunc(adminEnvironmentsPath+"/{name}", r.adminRemoveEnvironment).Methods("DELETE")
+	router.HandleFunc(adminEnvironmentsPath+"/{name}/killswitch", r.adminSetKillSwitch(true)).Methods("PUT")
+	router.HandleFunc(adminEnvironmentsPath+"/{name}/killswitch", r.adminSetKillSwitch(false)).Methods("DELETE")
+	router.HandleFunc(adminMaintenancePath, r.adminGetMaintenance).Methods("GET")
+	router.HandleFunc(adminMaintenancePath, r.adminSetMaintenance(true)).Methods("PUT")
+	router.HandleFunc(adminMaintenancePath, r.adminSetMaintenance(false)).Methods("DELETE")
+	if r.config.Admin.EnableProfiling {
+		addProfilingRoutes(router)
+	}
+	return router
+}
+
+func adminAuthMiddleware(adminKey string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			key := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if adminKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+func (r *Relay) adminListEnvironments(w http.ResponseWriter, req *http.Request) {
+	r.reloadLock.Lock()
+	resp := make(map[string]adminEnvironmentRep, len(r.config.Environment))
+	for name, envConfig := range r.config.Environment {
+		resp[name] = r.makeAdminEnvironmentRepWithState(*envConfig)
+	}
+	r.reloadLock.Unlock()
+	writeAdminJSON(w, http.StatusOK, resp)
+}
+
+func (r *Relay) adminGetEnvironment(w http.ResponseWriter, req *http.Request) {
+	r.reloadLock.Lock()
+	envConfig := r.config.Environment[mux.Vars(req)["name"]]
+	r.reloadLock.Unlock()
+	if envConfig == nil {
+		writeAdminError(w, errAdminEnvNotFound)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, r.makeAdminEnvironmentRepWithState(*envConfig))
+}
+
+func (r *Relay) adminAddEnvironment(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Name string `json:"name"`
+		config.EnvConfig
+	}
+	if err := decodeAdminRequestBody(req, &body); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	err := r.changeEnvironmentConfigs(func(envs map[string]*config.EnvConfig) error {
+		switch {
+		case body.Name == "":
+			return errAdminEnvNameMissing
+		case envs[body.Name] != nil:
+			return errAdminEnvExists
+		}
+		envs[body.Name] = &body.EnvConfig
+		return nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeAdminJSON(w, http.StatusCreated, makeAdminEnvironmentRep(body.EnvConfig))
+}
+
+func (r *Relay) adminUpdateEnvironment(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+	// The body is read before changeEnvironmentConfigs locks the configuration, so that a slow client
+	// cannot hold up reloads and other admin requests.
+	var patch json.RawMessage
+	if err := decodeAdminRequestBody(req, &patch); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	var updated config.EnvConfig
+	err := r.changeEnvironmentConfigs(func(envs map[string]*config.EnvConfig) error {
+		envConfig := envs[name]
+		if envConfig == nil {
+			return errAdminEnvNotFound
+		}
+		// Decoding into the existing configuration means that only the properties in the request body
+		// are changed.
+		if err := decodeAdminJSON(bytes.NewReader(patch), envConfig); err != nil {
+			return err
+		}
+		updated = *envConfig
+		return nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, makeAdminEnvironmentRep(updated))
+}
+
+func (r *Relay) adminRemoveEnvironment(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+	err := r.changeEnvironmentConfigs(func(envs map[string]*config.EnvConfig) error {
+		if envs[name] == nil {
+			return errAdminEnvNotFound
+		}
+		delete(envs, name)
+		return nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *Relay) adminSetKillSwitch(on bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		name := mux.Vars(req)["name"]
+		r.reloadLock.Lock()
+		var env relayenv.EnvContext
+		if envConfig := r.config.Environment[name]; envConfig != nil {
+			env, _ = r.core.FindEnvironment(envConfig.SDKKey)
+		}
+		r.reloadLock.Unlock()
+		if env == nil {
+			writeAdminError(w, errAdminEnvNotFound)
+			return
+		}
+		if err := env.SetKillSwitch(on); err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		action, state := audit.ActionKillSwitchOff, "off"
+		if on {
+			action, state = audit.ActionKillSwitchOn, "on"
+		}
+		r.loggers.Warnf("%s: kill switch turned %s for environment %q", sourceAdmin.logName, state, name)
+		r.audit.Log(makeEnvAuditEvent(action, sourceAdmin.audit, env))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (r *Relay) adminGetMaintenance(w http.ResponseWriter, req *http.Request) {
+	writeAdminJSON(w, http.StatusOK, adminMaintenanceRep{Maintenance: r.core.IsInMaintenanceMode()})
+}
+
+func (r *Relay) adminSetMaintenance(on bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		r.setMaintenanceMode(on, sourceAdmin)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// makeAdminEnvironmentRepWithState is the same as makeAdminEnvironmentRep, but also includes the state
+// of the environment, which is not part of its configuration. It must be called while holding reloadLock.
+func (r *Relay) makeAdminEnvironmentRepWithState(envConfig config.EnvConfig) adminEnvironmentRep {
+	rep := makeAdminEnvironmentRep(envConfig)
+	if env, _ := r.core.FindEnvironment(envConfig.SDKKey); env != nil {
+		rep.KillSwitch = env.IsKillSwitchOn()
+	}
+	return rep
+}
+
+// changeEnvironmentConfigs calls the change function with a copy of the current environment
+// configurations, which it can modify. If the modified configuration is valid, it is applied in the same
+// way as for ReloadConfig.
+func (r *Relay) changeEnvironmentConfigs(change func(map[string]*config.EnvConfig) error) error {
+	r.reloadLock.Lock()
+	defer r.reloadLock.Unlock()
+
+	newEnvConfigs := make(map[string]*config.EnvConfig, len(r.config.Environment))
+	for name, envConfig := range r.config.Environment {
+		envConfigCopy := *envConfig
+		newEnvConfigs[name] = &envConfigCopy
+	}
+	if err := change(newEnvConfigs); err != nil {
+		return err
+	}
+
+	// Validating the whole configuration, rather than just the changed environment, catches problems such
+	// as a missing database prefix when there is more than one environment.
+	newConfig := r.config
+	newConfig.Environment = newEnvConfigs
+	if err := config.ValidateConfig(&newConfig, ldlog.NewDisabledLoggers()); err != nil {
+		return err
+	}
+
+	r.applyEnvironmentConfigs(newConfig.Environment, sourceAdmin)
+	return nil
+}
+
+func decodeAdminRequestBody(req *http.Request, target interface{}) error {
+	return decodeAdminJSON(req.Body, target)
+}
+
+func decodeAdminJSON(r io.Reader, target interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(target)
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, value interface{}) {
+	data, _ := json.Marshal(value)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch err {
+	case errAdminEnvNotFound:
+		status = http.StatusNotFound
+	case errAdminEnvExists:
+		status = http.StatusConflict
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(util.ErrorJSONMsg(err.Error()))
+}
@@ -0,0 +1,375 @@
+package relay
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminKey = "admin-key"
+
+func makeAdminTestConfig(envs map[string]*c.EnvConfig) c.Config {
+	config := makeReloadTestConfig(envs)
+	config.Admin.Port, _ = configtypes.NewOptIntGreaterThanZero(8099)
+	config.Admin.Key = testAdminKey
+	return config
+}
+
+func (h relayTestHelper) adminRequest(method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", testAdminKey)
+	w := httptest.NewRecorder()
+	h.relay.AdminHandler().ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAPIRequiresAdminKey(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}})
+	reloadTest(t, config, func(h relayTestHelper, _ *ldlogtest.MockLog) {
+		for _, authHeader := range []string{"", "wrong-key", "Bearer wrong-key"} {
+			t.Run(authHeader, func(t *testing.T) {
+				req := httptest.NewRequest("GET", adminEnvironmentsPath, nil)
+				if authHeader != "" {
+					req.Header.Set("Authorization", authHeader)
+				}
+				w := httptest.NewRecorder()
+				h.relay.AdminHandler().ServeHTTP(w, req)
+				assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+			})
+		}
+
+		t.Run("bearer token", func(t *testing.T) {
+			req := httptest.NewRequest("GET", adminEnvironmentsPath, nil)
+			req.Header.Set("Authorization", "Bearer "+testAdminKey)
+			w := httptest.NewRecorder()
+			h.relay.AdminHandler().ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		})
+	})
+}
+
+func TestAdminAPIListsAndGetsEnvironments(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{
+		"a": {SDKKey: c.SDKKey("sdk-aaaaaaaa-1111"), MobileKey: c.MobileKey("mob-aaaaaaaa-2222"), EnvID: "env-a"},
+		"b": {SDKKey: c.SDKKey("sdk-bbbbbbbb-3333")},
+	})
+	reloadTest(t, config, func(h relayTestHelper, _ *ldlogtest.MockLog) {
+		w := h.adminRequest("GET", adminEnvironmentsPath, "")
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.JSONEq(t, `{
+			"a": {"sdkKey": "sdk-********-1111", "mobileKey": "mob-********-2222", "envId": "env-a"},
+			"b": {"sdkKey": "sdk-********-3333"}
+		}`, w.Body.String())
+
+		w = h.adminRequest("GET", adminEnvironmentsPath+"/b", "")
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.JSONEq(t, `{"sdkKey": "sdk-********-3333"}`, w.Body.String())
+
+		w = h.adminRequest("GET", adminEnvironmentsPath+"/c", "")
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+}
+
+func TestAdminAPIAddsEnvironment(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}})
+	reloadTest(t, config, func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		w := h.adminRequest("POST", adminEnvironmentsPath, `{"name": "b", "sdkKey": "sdk-b", "mobileKey": "mob-b"}`)
+		require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+
+		env := h.getEnv(c.SDKKey("sdk-b"))
+		require.NotNil(t, env)
+		assert.Equal(t, "b", env.GetIdentifiers().ConfiguredName)
+		assert.Equal(t, env, h.getEnv(c.MobileKey("mob-b")))
+		assert.NotNil(t, h.getEnv(c.SDKKey("sdk-a")))
+		mockLog.AssertMessageMatch(t, true, ldlog.Info, `Admin API: adding environment "b"`)
+	})
+}
+
+func TestAdminAPIAddEnvironmentErrors(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}})
+	reloadTest(t, config, func(h relayTestHelper, _ *ldlogtest.MockLog) {
+		for _, p := range []struct {
+			name   string
+			body   string
+			status int
+		}{
+			{"existing name", `{"name": "a", "sdkKey": "sdk-a2"}`, http.StatusConflict},
+			{"no name", `{"sdkKey": "sdk-b"}`, http.StatusBadRequest},
+			{"no SDK key", `{"name": "b"}`, http.StatusBadRequest},
+			{"unknown property", `{"name": "b", "sdkKey": "sdk-b", "color": "blue"}`, http.StatusBadRequest},
+			{"malformed JSON", `{"name": `, http.StatusBadRequest},
+		} {
+			t.Run(p.name, func(t *testing.T) {
+				w := h.adminRequest("POST", adminEnvironmentsPath, p.body)
+				assert.Equal(t, p.status, w.Result().StatusCode)
+				var errorRep struct {
+					Message string `json:"message"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errorRep))
+				assert.NotEqual(t, "", errorRep.Message)
+			})
+		}
+		assert.Len(t, h.relay.core.GetAllEnvironments(), 1)
+	})
+}
+
+func TestAdminAPIRotatesKeysInPlace(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{
+		"a": {SDKKey: c.SDKKey("sdk-a"), MobileKey: c.MobileKey("mob-a"), Prefix: "p"},
+	})
+	reloadTest(t, config, func(h relayTestHelper, _ *ldlogtest.MockLog) {
+		env := h.getEnv(c.SDKKey("sdk-a"))
+		require.NotNil(t, env)
+
+		w := h.adminRequest("PATCH", adminEnvironmentsPath+"/a", `{"sdkKey": "sdk-a2", "mobileKey": "mob-a2"}`)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.JSONEq(t, `{"sdkKey": "sdk-a2", "mobileKey": "mob-a2", "prefix": "p"}`, w.Body.String())
+
+		assert.Equal(t, env, h.getEnv(c.SDKKey("sdk-a2")))
+		assert.Equal(t, env, h.getEnv(c.MobileKey("mob-a2")))
+		assert.Nil(t, h.getEnv(c.SDKKey("sdk-a")))
+		assert.Nil(t, h.getEnv(c.MobileKey("mob-a")))
+	})
+}
+
+func TestAdminAPIChangesPrefixByRecreatingEnvironment(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a"), Prefix: "p1"}})
+	reloadTest(t, config, func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		env := h.getEnv(c.SDKKey("sdk-a"))
+		require.NotNil(t, env)
+
+		w := h.adminRequest("PATCH", adminEnvironmentsPath+"/a", `{"prefix": "p2"}`)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		newEnv := h.getEnv(c.SDKKey("sdk-a"))
+		require.NotNil(t, newEnv)
+		assert.NotSame(t, env, newEnv)
+		assert.Equal(t, "p2", h.relay.config.Environment["a"].Prefix)
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "recreating the environment")
+	})
+}
+
+func TestAdminAPIUpdateEnvironmentErrors(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}})
+	reloadTest(t, config, func(h relayTestHelper, _ *ldlogtest.MockLog) {
+		w := h.adminRequest("PATCH", adminEnvironmentsPath+"/b", `{"sdkKey": "sdk-b"}`)
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+
+		w = h.adminRequest("PATCH", adminEnvironmentsPath+"/a", `{"sdkKey": ""}`)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		assert.Equal(t, c.SDKKey("sdk-a"), h.relay.config.Environment["a"].SDKKey)
+		assert.NotNil(t, h.getEnv(c.SDKKey("sdk-a")))
+	})
+}
+
+func TestAdminAPIUpdateEnvironmentDoesNotLockConfigurationWhileReadingBody(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}})
+	reloadTest(t, config, func(h relayTestHelper, _ *ldlogtest.MockLog) {
+		bodyReader, bodyWriter := io.Pipe()
+		req := httptest.NewRequest("PATCH", adminEnvironmentsPath+"/a", bodyReader)
+		req.Header.Set("Authorization", testAdminKey)
+		w := httptest.NewRecorder()
+		patchDone := make(chan struct{})
+		go func() {
+			h.relay.AdminHandler().ServeHTTP(w, req)
+			close(patchDone)
+		}()
+
+		// once part of the body has been read, the PATCH request is waiting for the rest of it, but other
+		// requests are not held up
+		_, err := bodyWriter.Write([]byte(`{"mobileKey": `))
+		require.NoError(t, err)
+		listDone := make(chan int)
+		go func() { listDone <- h.adminRequest("GET", adminEnvironmentsPath, "").Result().StatusCode }()
+		select {
+		case status := <-listDone:
+			assert.Equal(t, http.StatusOK, status)
+		case <-time.After(time.Second):
+			require.Fail(t, "admin request was blocked by a request whose body was not finished")
+		}
+
+		_, err = bodyWriter.Write([]byte(`"mob-a"}`))
+		require.NoError(t, err)
+		require.NoError(t, bodyWriter.Close())
+		select {
+		case <-patchDone:
+		case <-time.After(time.Second * 5):
+			require.Fail(t, "timed out waiting for PATCH request")
+		}
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.Equal(t, c.MobileKey("mob-a"), h.relay.config.Environment["a"].MobileKey)
+	})
+}
+
+func TestAdminAPIRemovesEnvironment(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{
+		"a": {SDKKey: c.SDKKey("sdk-a")},
+		"b": {SDKKey: c.SDKKey("sdk-b")},
+	})
+	reloadTest(t, config, func(h relayTestHelper, _ *ldlogtest.MockLog) {
+		w := h.adminRequest("DELETE", adminEnvironmentsPath+"/a", "")
+		require.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+		assert.Nil(t, h.getEnv(c.SDKKey("sdk-a")))
+		assert.NotNil(t, h.getEnv(c.SDKKey("sdk-b")))
+
+		w = h.adminRequest("DELETE", adminEnvironmentsPath+"/a", "")
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+
+		// removing the last environment is allowed, since more can be added later
+		w = h.adminRequest("DELETE", adminEnvironmentsPath+"/b", "")
+		require.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+		assert.Len(t, h.relay.core.GetAllEnvironments(), 0)
+	})
+}
+
+func TestAdminAPITurnsKillSwitchOnAndOff(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{
+		"a": {SDKKey: c.SDKKey("sdk-aaaaaaaa-1111")},
+		"b": {SDKKey: c.SDKKey("sdk-bbbbbbbb-2222")},
+	})
+	config.AuditLog.File = filepath.Join(t.TempDir(), "audit.log")
+	reloadTest(t, config, func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		w := h.adminRequest("PUT", adminEnvironmentsPath+"/a/killswitch", "")
+		require.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+		assert.True(t, h.getEnv(c.SDKKey("sdk-aaaaaaaa-1111")).IsKillSwitchOn())
+		assert.False(t, h.getEnv(c.SDKKey("sdk-bbbbbbbb-2222")).IsKillSwitchOn())
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, `Admin API: kill switch turned on for environment "a"`)
+
+		w = h.adminRequest("GET", adminEnvironmentsPath+"/a", "")
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.JSONEq(t, `{"sdkKey": "sdk-********-1111", "killSwitch": true}`, w.Body.String())
+
+		w = h.adminRequest("DELETE", adminEnvironmentsPath+"/a/killswitch", "")
+		require.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+		assert.False(t, h.getEnv(c.SDKKey("sdk-aaaaaaaa-1111")).IsKillSwitchOn())
+
+		assert.Equal(t, []auditEvent{
+			{"action": "killSwitchOn", "source": "adminAPI", "env": "a"},
+			{"action": "killSwitchOff", "source": "adminAPI", "env": "a"},
+		}, readAuditEvents(t, config.AuditLog.File))
+
+		w = h.adminRequest("PUT", adminEnvironmentsPath+"/c/killswitch", "")
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+}
+
+func TestAdminAPIKillSwitchFileError(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{
+		"a": {SDKKey: c.SDKKey("sdk-a"), KillSwitchFile: "/not/a/real/file"},
+	})
+	reloadTest(t, config, func(h relayTestHelper, _ *ldlogtest.MockLog) {
+		w := h.adminRequest("PUT", adminEnvironmentsPath+"/a/killswitch", "")
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		assert.False(t, h.getEnv(c.SDKKey("sdk-a")).IsKillSwitchOn())
+	})
+}
+
+func TestAdminAPITurnsMaintenanceModeOnAndOff(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}})
+	config.AuditLog.File = filepath.Join(t.TempDir(), "audit.log")
+	reloadTest(t, config, func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		w := h.adminRequest("PUT", adminMaintenancePath, "")
+		require.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+		assert.True(t, h.relay.IsInMaintenanceMode())
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Admin API: maintenance mode turned on")
+
+		w = h.adminRequest("GET", adminMaintenancePath, "")
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.JSONEq(t, `{"maintenance": true}`, w.Body.String())
+
+		// turning it on again does nothing
+		w = h.adminRequest("PUT", adminMaintenancePath, "")
+		require.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+
+		w = h.adminRequest("DELETE", adminMaintenancePath, "")
+		require.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+		assert.False(t, h.relay.IsInMaintenanceMode())
+
+		h.relay.ToggleMaintenanceMode()
+		assert.True(t, h.relay.IsInMaintenanceMode())
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Signal: maintenance mode turned on")
+
+		assert.Equal(t, []auditEvent{
+			{"action": "maintenanceOn", "source": "adminAPI"},
+			{"action": "maintenanceOff", "source": "adminAPI"},
+			{"action": "maintenanceOn", "source": "signal"},
+		}, readAuditEvents(t, config.AuditLog.File))
+	})
+}
+
+func TestRelayCanStartWithNoEnvironmentsIfAdminAPIIsEnabled(t *testing.T) {
+	reloadTest(t, makeAdminTestConfig(nil), func(h relayTestHelper, _ *ldlogtest.MockLog) {
+		w := h.adminRequest("POST", adminEnvironmentsPath, `{"name": "a", "sdkKey": "sdk-a"}`)
+		require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+		assert.NotNil(t, h.getEnv(c.SDKKey("sdk-a")))
+	})
+}
+
+func TestAdminProfilingEndpointsAreNotServedByDefault(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}})
+	reloadTest(t, config, func(h relayTestHelper, _ *ldlogtest.MockLog) {
+		for _, path := range []string{adminProfilingPath, adminProfilingPath + "heap", adminGoroutinesPath} {
+			w := h.adminRequest("GET", path, "")
+			assert.Equal(t, http.StatusNotFound, w.Result().StatusCode, path)
+		}
+	})
+}
+
+func TestAdminProfilingEndpoints(t *testing.T) {
+	config := makeAdminTestConfig(map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}})
+	config.Admin.EnableProfiling = true
+	reloadTest(t, config, func(h relayTestHelper, _ *ldlogtest.MockLog) {
+		t.Run("requires admin key", func(t *testing.T) {
+			for _, path := range []string{adminProfilingPath, adminProfilingPath + "heap", adminGoroutinesPath} {
+				req := httptest.NewRequest("GET", path, nil)
+				w := httptest.NewRecorder()
+				h.relay.AdminHandler().ServeHTTP(w, req)
+				assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode, path)
+			}
+		})
+
+		t.Run("index", func(t *testing.T) {
+			w := h.adminRequest("GET", adminProfilingPath, "")
+			require.Equal(t, http.StatusOK, w.Result().StatusCode)
+			for _, profile := range []string{"heap", "goroutine", "block"} {
+				assert.Contains(t, w.Body.String(), profile)
+			}
+		})
+
+		for _, profile := range []string{"heap", "goroutine", "block"} {
+			t.Run(profile, func(t *testing.T) {
+				w := h.adminRequest("GET", adminProfilingPath+profile, "")
+				assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+				assert.NotEmpty(t, w.Body.Bytes())
+			})
+		}
+
+		t.Run("CPU", func(t *testing.T) {
+			w := h.adminRequest("GET", adminProfilingPath+"profile?seconds=1", "")
+			assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+			assert.NotEmpty(t, w.Body.Bytes())
+		})
+
+		t.Run("goroutine dump", func(t *testing.T) {
+			w := h.adminRequest("GET", adminGoroutinesPath, "")
+			require.Equal(t, http.StatusOK, w.Result().StatusCode)
+			assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Body.String(), "goroutine ")
+			assert.Contains(t, w.Body.String(), "adminDumpGoroutines")
+		})
+	})
+}
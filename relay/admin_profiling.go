@@ -0,0 +1,45 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	adminProfilingPath  = "/debug/pprof/"
+	adminGoroutinesPath = "/debug/goroutines"
+
+	// adminBlockProfileRate is the block profile rate used while profiling is enabled: on average, one
+	// blocking event is sampled for each millisecond that goroutines spend blocked. This is coarse enough
+	// that it has no noticeable cost, but still shows where goroutines are waiting for a long time.
+	adminBlockProfileRate = int(time.Millisecond)
+)
+
+// addProfilingRoutes adds the standard net/http/pprof endpoints, and a goroutine dump endpoint, to the
+// admin API router. Routes added here are protected by the same admin key as the rest of the admin API.
+//
+// We do not import net/http/pprof for its side effect of registering these on http.DefaultServeMux,
+// since that would expose them on any server that used the default mux.
+func addProfilingRoutes(router *mux.Router) {
+	runtime.SetBlockProfileRate(adminBlockProfileRate)
+
+	router.HandleFunc(adminProfilingPath+"cmdline", pprof.Cmdline).Methods("GET")
+	router.HandleFunc(adminProfilingPath+"profile", pprof.Profile).Methods("GET")
+	router.HandleFunc(adminProfilingPath+"symbol", pprof.Symbol).Methods("GET", "POST")
+	router.HandleFunc(adminProfilingPath+"trace", pprof.Trace).Methods("GET")
+	// pprof.Index serves the index page, and each named profile such as "heap", "goroutine", or "block"
+	router.PathPrefix(adminProfilingPath).HandlerFunc(pprof.Index).Methods("GET")
+	router.HandleFunc(adminGoroutinesPath, adminDumpGoroutines).Methods("GET")
+}
+
+// adminDumpGoroutines writes the stack traces of all current goroutines as plain text, in the same
+// format as an unrecovered panic.
+func adminDumpGoroutines(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_ = rpprof.Lookup("goroutine").WriteTo(w, 2)
+}
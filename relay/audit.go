@@ -0,0 +1,90 @@
+package relay
+
+import (
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/audit"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+)
+
+// changeSource identifies where a change to the environments came from, for log messages and audit
+// events.
+type changeSource struct {
+	logName string
+	audit   audit.Source
+}
+
+var sourceSignal = changeSource{logName: "Signal", audit: audit.SourceSignal} //nolint:gochecknoglobals
+
+// credentialProperties are the properties, in audit events for environments, that are changed by a
+// credential rotation. An environmentUpdated event does not include them, since credentialRotated and
+// credentialExpired events already describe those changes.
+var credentialProperties = []string{"SDKKey", "MobileKey", "ExpiringSDKKey"} //nolint:gochecknoglobals
+
+// autoConfigEnvRep is how an auto-configured environment is represented in audit events. Unlike
+// environments from the configuration, there is no config.EnvConfig to describe the environment as it
+// was before a change, so this is built from the environment's current state. Its properties have the
+// same names as in config.EnvConfig.
+type autoConfigEnvRep struct {
+	Identifiers    relayenv.EnvIdentifiers
+	SDKKey         config.SDKKey
+	MobileKey      config.MobileKey
+	ExpiringSDKKey config.SDKKey `json:",omitempty"`
+	TTL            string
+	SecureMode     bool
+}
+
+func makeAutoConfigEnvRep(env relayenv.EnvContext) autoConfigEnvRep {
+	rep := autoConfigEnvRep{
+		Identifiers: env.GetIdentifiers(),
+		TTL:         env.GetTTL().String(),
+		SecureMode:  env.IsSecureMode(),
+	}
+	for _, c := range env.GetCredentials() {
+		switch c := c.(type) {
+		case config.SDKKey:
+			rep.SDKKey = c
+		case config.MobileKey:
+			rep.MobileKey = c
+		}
+	}
+	for _, c := range env.GetDeprecatedCredentials() {
+		if key, ok := c.(config.SDKKey); ok {
+			rep.ExpiringSDKKey = key
+		}
+	}
+	return rep
+}
+
+// makeEnvAuditEvent creates an audit event for an environment that already exists, taking its name and
+// ID from the environment.
+func makeEnvAuditEvent(action audit.Action, source audit.Source, env relayenv.EnvContext) audit.Event {
+	e := audit.Event{Action: action, Source: source, Env: env.GetIdentifiers().GetDisplayName()}
+	for _, c := range env.GetCredentials() {
+		if id, ok := c.(config.EnvironmentID); ok {
+			e.EnvID = string(id)
+		}
+	}
+	return e
+}
+
+func (r *Relay) auditCredentialRotated(source audit.Source, env relayenv.EnvContext, oldCredential,
+	newCredential config.SDKCredential) {
+	e := makeEnvAuditEvent(audit.ActionCredentialRotated, source, env)
+	name := credentialPropertyName(newCredential)
+	e.Before = audit.Redact(map[string]config.SDKCredential{name: oldCredential})
+	e.After = audit.Redact(map[string]config.SDKCredential{name: newCredential})
+	r.audit.Log(e)
+}
+
+func (r *Relay) auditCredentialExpired(source audit.Source, env relayenv.EnvContext, oldKey config.SDKKey) {
+	e := makeEnvAuditEvent(audit.ActionCredentialExpired, source, env)
+	e.Before = audit.Redact(map[string]config.SDKKey{credentialPropertyName(oldKey): oldKey})
+	r.audit.Log(e)
+}
+
+func credentialPropertyName(credential config.SDKCredential) string {
+	if _, ok := credential.(config.MobileKey); ok {
+		return "MobileKey"
+	}
+	return "SDKKey"
+}
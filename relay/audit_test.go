@@ -0,0 +1,165 @@
+package relay
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type auditEvent map[string]interface{}
+
+func readAuditEvents(t *testing.T, path string) []auditEvent {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var events []auditEvent
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var e auditEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		delete(e, "time")
+		events = append(events, e)
+	}
+	return events
+}
+
+func keysOf(m interface{}) []string {
+	var keys []string
+	for k := range m.(map[string]interface{}) {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestReloadConfigWritesAuditLog(t *testing.T) {
+	auditLog := c.AuditLogConfig{File: filepath.Join(t.TempDir(), "audit.log")}
+	makeConfig := func(envs map[string]*c.EnvConfig) c.Config {
+		config := makeReloadTestConfig(envs)
+		config.AuditLog = auditLog
+		return config
+	}
+	config := makeConfig(map[string]*c.EnvConfig{
+		"a": {SDKKey: c.SDKKey("sdk-a-12345"), Prefix: "a"},
+		"b": {SDKKey: c.SDKKey("sdk-b-12345")},
+	})
+	reloadTest(t, config, func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		newConfig := makeConfig(map[string]*c.EnvConfig{
+			"a": {SDKKey: c.SDKKey("sdk-a-67890"), Prefix: "a"},
+			"c": {SDKKey: c.SDKKey("sdk-c-12345")},
+		})
+		newConfig.Main.LogLevel = c.NewOptLogLevel(ldlog.Info)
+		require.NoError(t, h.relay.ReloadConfig(newConfig))
+
+		events := readAuditEvents(t, auditLog.File)
+		require.Len(t, events, 4)
+		assert.Equal(t, "configReloaded", events[0]["action"])
+		assert.Equal(t, []string{"Main"}, keysOf(events[0]["before"]))
+		assert.Equal(t, "", events[0]["before"].(map[string]interface{})["Main"].(map[string]interface{})["LogLevel"])
+		assert.Equal(t, "info", events[0]["after"].(map[string]interface{})["Main"].(map[string]interface{})["LogLevel"])
+		assert.Equal(t, "environmentRemoved", events[1]["action"])
+		assert.Equal(t, "b", events[1]["env"])
+		assert.Equal(t, core.ObscureKey("sdk-b-12345"), events[1]["before"].(map[string]interface{})["SDKKey"])
+
+		// environments are updated in map order, so these can be in either order; the key rotation is
+		// recorded only as credentialRotated, since nothing else about the environment changed
+		rest := events[2:]
+		if rest[0]["env"] == "c" {
+			rest[0], rest[1] = rest[1], rest[0]
+		}
+		assert.Equal(t, auditEvent{"action": "credentialRotated", "source": "configReload", "env": "a",
+			"before": map[string]interface{}{"SDKKey": core.ObscureKey("sdk-a-12345")},
+			"after":  map[string]interface{}{"SDKKey": core.ObscureKey("sdk-a-67890")},
+		}, rest[0])
+		assert.Equal(t, "environmentAdded", rest[1]["action"])
+		assert.Equal(t, "c", rest[1]["env"])
+	})
+}
+
+func TestReloadConfigWritesAuditLogForExpiredKey(t *testing.T) {
+	auditLog := c.AuditLogConfig{File: filepath.Join(t.TempDir(), "audit.log")}
+	makeConfig := func(sdkKey c.SDKKey) c.Config {
+		config := makeReloadTestConfig(map[string]*c.EnvConfig{
+			"a": {SDKKey: sdkKey, SDKKeyGracePeriod: configtypes.NewOptDuration(time.Millisecond * 10)},
+		})
+		config.AuditLog = auditLog
+		return config
+	}
+	reloadTest(t, makeConfig("sdk-a"), func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		require.NoError(t, h.relay.ReloadConfig(makeConfig("sdk-a2")))
+
+		require.Eventually(t, func() bool { return len(readAuditEvents(t, auditLog.File)) == 3 }, time.Second,
+			time.Millisecond*10)
+		assert.Equal(t, auditEvent{"action": "credentialExpired", "source": "configReload", "env": "a",
+			"before": map[string]interface{}{"SDKKey": core.ObscureKey("sdk-a")},
+		}, readAuditEvents(t, auditLog.File)[2])
+	})
+}
+
+func TestReloadConfigWritesAuditLogForFailure(t *testing.T) {
+	auditLog := c.AuditLogConfig{File: filepath.Join(t.TempDir(), "audit.log")}
+	config := makeReloadTestConfig(map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}})
+	config.AuditLog = auditLog
+	reloadTest(t, config, func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		assert.Error(t, h.relay.ReloadConfig(c.Config{}))
+
+		assert.Equal(t, []auditEvent{{"action": "configReloadFailed", "source": "configReload",
+			"error": errNoEnvironments.Error()}}, readAuditEvents(t, auditLog.File))
+	})
+}
+
+func TestAutoConfigWritesAuditLog(t *testing.T) {
+	config := testAutoConfDefaultConfig
+	config.AuditLog.File = filepath.Join(t.TempDir(), "audit.log")
+	initialEvent := makeAutoConfPutEvent(testAutoConfEnv1)
+	autoConfTest(t, config, &initialEvent, func(p autoConfTestParams) {
+		_ = p.awaitClient()
+		_ = p.awaitEnvironment(testAutoConfEnv1.id)
+
+		modified := testAutoConfEnv1
+		modified.envName = "newenvname"
+		modified.version++
+		p.stream.Enqueue(makeAutoConfPatchEvent(modified))
+		rotated := modified
+		rotated.sdkKey = c.SDKKey("newsdkkey")
+		rotated.version++
+		p.stream.Enqueue(makeAutoConfPatchEvent(rotated))
+		p.stream.Enqueue(makeAutoConfDeleteEvent(testAutoConfEnv1.id, rotated.version+1))
+
+		var events []auditEvent
+		require.Eventually(t, func() bool {
+			events = readAuditEvents(t, config.AuditLog.File)
+			return len(events) == 4
+		}, time.Second, time.Millisecond*10)
+
+		assert.Equal(t, "environmentAdded", events[0]["action"])
+		assert.Equal(t, "autoConfig", events[0]["source"])
+		assert.Equal(t, string(testAutoConfEnv1.id), events[0]["envId"])
+		assert.Equal(t, core.ObscureKey(string(testAutoConfEnv1.sdkKey)), events[0]["after"].(map[string]interface{})["SDKKey"])
+
+		assert.Equal(t, "environmentUpdated", events[1]["action"])
+		assert.Equal(t, "projname1 newenvname", events[1]["env"])
+		assert.Equal(t, "envname1", events[1]["before"].(map[string]interface{})["Identifiers"].(map[string]interface{})["EnvName"])
+		assert.Equal(t, "newenvname", events[1]["after"].(map[string]interface{})["Identifiers"].(map[string]interface{})["EnvName"])
+
+		// the key rotation is recorded only as credentialRotated
+		assert.Equal(t, "credentialRotated", events[2]["action"])
+		assert.Equal(t, map[string]interface{}{"SDKKey": core.ObscureKey("newsdkkey")}, events[2]["after"])
+
+		assert.Equal(t, "environmentRemoved", events[3]["action"])
+		assert.Equal(t, string(testAutoConfEnv1.id), events[3]["envId"])
+	})
+}
@@ -2,6 +2,7 @@ package relay
 
 import (
 	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/audit"
 	"github.com/launchdarkly/ld-relay/v6/internal/envfactory"
 )
 
@@ -32,22 +33,28 @@ func (a *relayAutoConfigActions) AddEnvironment(params envfactory.EnvironmentPar
 	}
 
 	if params.ExpiringSDKKey != "" {
-		if foundEnvWithOldKey, _ := a.r.core.GetEnvironment(params.ExpiringSDKKey); foundEnvWithOldKey == nil {
+		if foundEnvWithOldKey, _ := a.r.core.FindEnvironment(params.ExpiringSDKKey); foundEnvWithOldKey == nil {
 			env.AddCredential(params.ExpiringSDKKey)
 			env.DeprecateCredential(params.ExpiringSDKKey)
 			a.r.core.AddedEnvironmentCredential(env, params.ExpiringSDKKey) // this updates the index we use for authenticating requests
 		}
 	}
+
+	e := makeEnvAuditEvent(audit.ActionEnvironmentAdded, audit.SourceAutoConfig, env)
+	e.After = audit.Redact(makeAutoConfigEnvRep(env))
+	a.r.audit.Log(e)
 }
 
 func (a *relayAutoConfigActions) UpdateEnvironment(params envfactory.EnvironmentParams) {
-	env, _ := a.r.core.GetEnvironment(params.EnvID)
+	env, _ := a.r.core.FindEnvironment(params.EnvID)
 	if env == nil {
 		a.r.loggers.Warnf(logMsgAutoConfUpdateUnknownEnv, params.Identifiers.GetDisplayName())
 		a.AddEnvironment(params)
 		return
 	}
 
+	before := audit.Redact(makeAutoConfigEnvRep(env))
+
 	env.SetIdentifiers(params.Identifiers)
 	env.SetTTL(params.TTL)
 	env.SetSecureMode(params.SecureMode)
@@ -72,6 +79,7 @@ func (a *relayAutoConfigActions) UpdateEnvironment(params envfactory.Environment
 			a.r.core.RemovingEnvironmentCredential(oldSDKKey)
 			env.RemoveCredential(oldSDKKey)
 		}
+		a.r.auditCredentialRotated(audit.SourceAutoConfig, env, oldSDKKey, params.SDKKey)
 	}
 
 	if params.MobileKey != oldMobileKey {
@@ -79,16 +87,30 @@ func (a *relayAutoConfigActions) UpdateEnvironment(params envfactory.Environment
 		a.r.core.AddedEnvironmentCredential(env, params.MobileKey)
 		a.r.core.RemovingEnvironmentCredential(oldMobileKey)
 		env.RemoveCredential(oldMobileKey)
+		a.r.auditCredentialRotated(audit.SourceAutoConfig, env, oldMobileKey, params.MobileKey)
+	}
+
+	// The stream sends an update whenever anything about the environment has changed, including
+	// properties that Relay doesn't use, so there may be nothing to record
+	changedBefore, changedAfter := audit.ChangedProperties(before, audit.Redact(makeAutoConfigEnvRep(env)),
+		credentialProperties...)
+	if len(changedBefore) != 0 || len(changedAfter) != 0 {
+		e := makeEnvAuditEvent(audit.ActionEnvironmentUpdated, audit.SourceAutoConfig, env)
+		e.Before, e.After = changedBefore, changedAfter
+		a.r.audit.Log(e)
 	}
 }
 
 func (a *relayAutoConfigActions) DeleteEnvironment(id config.EnvironmentID) {
-	env, _ := a.r.core.GetEnvironment(id)
+	env, _ := a.r.core.FindEnvironment(id)
 	if env == nil {
 		a.r.loggers.Warnf(logMsgAutoConfDeleteUnknownEnv, id)
 		return
 	}
+	e := makeEnvAuditEvent(audit.ActionEnvironmentRemoved, audit.SourceAutoConfig, env)
+	e.Before = audit.Redact(makeAutoConfigEnvRep(env))
 	a.r.core.RemoveEnvironment(env)
+	a.r.audit.Log(e)
 }
 
 func (a *relayAutoConfigActions) ReceivedAllEnvironments() {
@@ -97,11 +119,12 @@ func (a *relayAutoConfigActions) ReceivedAllEnvironments() {
 }
 
 func (a *relayAutoConfigActions) KeyExpired(id config.EnvironmentID, oldKey config.SDKKey) {
-	env, _ := a.r.core.GetEnvironment(id)
+	env, _ := a.r.core.FindEnvironment(id)
 	if env == nil {
 		a.r.loggers.Warnf(logMsgKeyExpiryUnknownEnv, id)
 		return
 	}
 	a.r.core.RemovingEnvironmentCredential(oldKey)
 	env.RemoveCredential(oldKey)
+	a.r.auditCredentialExpired(audit.SourceAutoConfig, env, oldKey)
 }
@@ -0,0 +1,33 @@
+package relay
+
+import (
+	"github.com/launchdarkly/ld-relay/v6/internal/core/bigsegments"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+)
+
+// BigSegmentStore is the interface for a custom big segment store, which Relay uses to write the big
+// segment data that it receives from LaunchDarkly. Each instance is specific to one environment.
+type BigSegmentStore = bigsegments.BigSegmentStore
+
+// BigSegmentPatch is an update to the membership of one big segment, as passed to
+// BigSegmentStore.ApplyPatch.
+type BigSegmentPatch = bigsegments.BigSegmentPatch
+
+// BigSegmentStoreFactory creates the BigSegmentStore for an environment.
+type BigSegmentStoreFactory = bigsegments.BigSegmentStoreFactory
+
+// BigSegmentStoreProvider creates the Go SDK big segment store that Relay's SDK instances use to read
+// the same data during evaluations.
+type BigSegmentStoreProvider = sdks.BigSegmentStoreProvider
+
+// RegisterBigSegmentStore makes a custom big segment backend available under a name, for use as the
+// type setting in the [BigSegments] configuration section. Both halves of the backend are required:
+// factory for writing the data, and provider for reading it. This must be called before NewRelay; it
+// panics if either function is nil or if the name has already been registered.
+func RegisterBigSegmentStore(storeType string, factory BigSegmentStoreFactory, provider BigSegmentStoreProvider) {
+	if factory == nil || provider == nil {
+		panic("relay: RegisterBigSegmentStore requires both a factory and a provider")
+	}
+	bigsegments.RegisterBigSegmentStoreFactory(storeType, factory)
+	sdks.RegisterBigSegmentStoreProvider(storeType, provider)
+}
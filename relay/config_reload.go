@@ -0,0 +1,294 @@
+package relay
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/audit"
+	"github.com/launchdarkly/ld-relay/v6/internal/core"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+)
+
+const (
+	logMsgAddedEnv              = "%s: adding environment %q"
+	logMsgRemovedEnv            = "%s: removing environment %q"
+	logMsgUpdatedEnv            = "%s: updating environment %q"
+	logMsgRecreatedEnv          = "%s: settings for environment %q cannot be changed in place; recreating the environment, which will disconnect its clients"
+	logMsgEnvInitError          = "%s: unable to initialize environment %q: %s"
+	logMsgReloadRestartRequired = "Configuration reload: settings other than environments have changed, but will not take effect until Relay is restarted"
+	logMsgReloadLogLevel        = "Configuration reload: log level changed to %s"
+	logMsgOldSDKKeyGracePeriod  = "Old SDK key %s for environment %q will still be accepted for %s"
+	logMsgOldSDKKeyExpired      = "Old SDK key %s for environment %q has expired"
+)
+
+var (
+	errReloadNotSupported = errors.New("configuration reloading is not supported in auto-configuration mode or offline mode") //nolint:lll
+)
+
+var sourceReload = changeSource{logName: "Configuration reload", audit: audit.SourceConfigReload} //nolint:gochecknoglobals
+
+// ReloadConfig applies a new configuration to a running Relay Proxy instance, without disconnecting
+// SDK clients that are using environments whose credentials have not changed.
+//
+// Environments in the new configuration that did not exist before are added, and environments that
+// no longer exist are removed. For an existing environment, the SDK key, mobile key, TTL, secure
+// mode setting, and log level are updated in place; if any other environment property has changed
+// (such as the client-side ID or database prefix), that environment is shut down and recreated.
+// Environments are matched by their names in the configuration. If the SDK key has changed and the
+// environment has an SDKKeyGracePeriod, the old key continues to be accepted until that time has passed.
+//
+// The global log level in the Main section is also changed in place, for Relay's own messages and for
+// environments that do not have their own log level. Changes to any other part of the configuration,
+// such as ports, database settings, or the log levels of specific subsystems, are ignored until Relay
+// is restarted; a warning is logged if there were any such changes.
+//
+// This cannot be used in auto-configuration mode or offline mode, since the list of environments
+// is not determined by the configuration in those modes.
+//
+// If the audit log is enabled, the reload and the resulting changes to environments are recorded in
+// it, as is a failure to reload.
+func (r *Relay) ReloadConfig(newConfig config.Config) error {
+	err := r.reloadConfig(newConfig)
+	if err != nil {
+		r.audit.Log(audit.Event{Action: audit.ActionConfigReloadFailed, Source: audit.SourceConfigReload, Error: err.Error()})
+	}
+	return err
+}
+
+func (r *Relay) reloadConfig(newConfig config.Config) error {
+	if err := config.ValidateConfig(&newConfig, r.loggers); err != nil {
+		return err
+	}
+
+	r.reloadLock.Lock()
+	defer r.reloadLock.Unlock()
+
+	if r.config.AutoConfig.Key != "" || r.config.OfflineMode.FileDataSource != "" ||
+		newConfig.AutoConfig.Key != "" || newConfig.OfflineMode.FileDataSource != "" {
+		return errReloadNotSupported
+	}
+	if len(newConfig.Environment) == 0 && !newConfig.Admin.Port.IsDefined() {
+		return errNoEnvironments
+	}
+
+	// r.config is the configuration as it was passed to NewRelay, so it may not have had defaults filled
+	// in yet; validating it again is harmless and makes it comparable to newConfig.
+	oldConfigWithoutEnvs, newConfigWithoutEnvs := r.config, newConfig
+	_ = config.ValidateConfig(&oldConfigWithoutEnvs, ldlog.NewDisabledLoggers())
+	oldConfigWithoutEnvs.Environment, newConfigWithoutEnvs.Environment = nil, nil
+	if newLevel := newConfig.Main.LogLevel; newLevel != oldConfigWithoutEnvs.Main.LogLevel {
+		r.loggers.Infof(logMsgReloadLogLevel, strings.ToLower(newLevel.GetOrElse(ldlog.Info).Name()))
+		r.core.SetLogLevel(newLevel)
+		r.config.Main.LogLevel = newLevel
+	}
+	if !reflect.DeepEqual(withoutUpdatableMainProps(oldConfigWithoutEnvs), withoutUpdatableMainProps(newConfigWithoutEnvs)) {
+		r.loggers.Warn(logMsgReloadRestartRequired)
+	}
+	reloadEvent := audit.Event{Action: audit.ActionConfigReloaded, Source: audit.SourceConfigReload}
+	if changedBefore, changedAfter := audit.ChangedProperties(audit.Redact(oldConfigWithoutEnvs),
+		audit.Redact(newConfigWithoutEnvs)); len(changedBefore) != 0 || len(changedAfter) != 0 {
+		reloadEvent.Before, reloadEvent.After = changedBefore, changedAfter
+	}
+	r.audit.Log(reloadEvent)
+
+	r.applyEnvironmentConfigs(newConfig.Environment, sourceReload)
+	return nil
+}
+
+// applyEnvironmentConfigs makes the running environments match the specified environment configurations,
+// as described for ReloadConfig, and then updates r.config to match. The caller must hold r.reloadLock.
+func (r *Relay) applyEnvironmentConfigs(newEnvConfigs map[string]*config.EnvConfig, source changeSource) {
+	envsByName := make(map[string]relayenv.EnvContext)
+	for _, env := range r.core.GetAllEnvironments() {
+		envsByName[env.GetIdentifiers().ConfiguredName] = env
+	}
+
+	for name, env := range envsByName {
+		if _, ok := newEnvConfigs[name]; !ok {
+			r.loggers.Infof(logMsgRemovedEnv, source.logName, name)
+			r.stopKeyExpiryTimers(env)
+			r.core.RemoveEnvironment(env)
+			r.auditEnvConfigChange(audit.ActionEnvironmentRemoved, source, name, r.config.Environment[name], nil)
+		}
+	}
+
+	for name, newEnvConfig := range newEnvConfigs {
+		env := envsByName[name]
+		oldEnvConfig := r.config.Environment[name]
+		switch {
+		case env == nil || oldEnvConfig == nil:
+			r.loggers.Infof(logMsgAddedEnv, source.logName, name)
+			r.addEnvironmentFromConfig(name, *newEnvConfig, source)
+			r.auditEnvConfigChange(audit.ActionEnvironmentAdded, source, name, nil, newEnvConfig)
+		case !canUpdateEnvironmentInPlace(*oldEnvConfig, *newEnvConfig):
+			r.loggers.Warnf(logMsgRecreatedEnv, source.logName, name)
+			r.stopKeyExpiryTimers(env)
+			r.core.RemoveEnvironment(env)
+			r.addEnvironmentFromConfig(name, *newEnvConfig, source)
+			r.auditEnvConfigChange(audit.ActionEnvironmentUpdated, source, name, oldEnvConfig, newEnvConfig)
+		case !reflect.DeepEqual(*oldEnvConfig, *newEnvConfig):
+			r.loggers.Infof(logMsgUpdatedEnv, source.logName, name)
+			r.updateEnvironmentFromConfig(env, *oldEnvConfig, *newEnvConfig, source)
+			r.auditEnvConfigChange(audit.ActionEnvironmentUpdated, source, name, oldEnvConfig, newEnvConfig,
+				credentialProperties...)
+		}
+	}
+
+	r.config.Environment = newEnvConfigs
+}
+
+func (r *Relay) addEnvironmentFromConfig(name string, envConfig config.EnvConfig, source changeSource) {
+	if _, _, err := r.core.AddEnvironment(relayenv.EnvIdentifiers{ConfiguredName: name}, envConfig, nil); err != nil {
+		r.loggers.Errorf(logMsgEnvInitError, source.logName, name, err)
+	}
+}
+
+// auditEnvConfigChange records the addition (if oldConfig is nil), removal (if newConfig is nil), or
+// update of an environment from the configuration. For an update, only the changed properties are
+// included, other than those in ignore; if there are none, nothing is recorded.
+func (r *Relay) auditEnvConfigChange(action audit.Action, source changeSource, name string,
+	oldConfig, newConfig *config.EnvConfig, ignore ...string) {
+	e := audit.Event{Action: action, Source: source.audit, Env: name}
+	switch {
+	case oldConfig == nil && newConfig == nil:
+		return
+	case oldConfig == nil:
+		e.EnvID, e.After = string(newConfig.EnvID), audit.Redact(newConfig)
+	case newConfig == nil:
+		e.EnvID, e.Before = string(oldConfig.EnvID), audit.Redact(oldConfig)
+	default:
+		e.EnvID = string(newConfig.EnvID)
+		changedBefore, changedAfter := audit.ChangedProperties(audit.Redact(oldConfig), audit.Redact(newConfig), ignore...)
+		if len(changedBefore) == 0 && len(changedAfter) == 0 {
+			return
+		}
+		e.Before, e.After = changedBefore, changedAfter
+	}
+	r.audit.Log(e)
+}
+
+func (r *Relay) updateEnvironmentFromConfig(env relayenv.EnvContext, oldConfig, newConfig config.EnvConfig,
+	source changeSource) {
+	env.SetTTL(newConfig.TTL.GetOrElse(0))
+	env.SetSecureMode(newConfig.SecureMode)
+	if newConfig.LogLevel != oldConfig.LogLevel {
+		env.SetLogLevel(newConfig.LogLevel)
+	}
+
+	if newConfig.SDKKey != oldConfig.SDKKey {
+		env.AddCredential(newConfig.SDKKey)
+		r.core.AddedEnvironmentCredential(env, newConfig.SDKKey) // this updates the index we use for authenticating requests
+		if gracePeriod := newConfig.SDKKeyGracePeriod.GetOrElse(0); gracePeriod > 0 {
+			r.deprecateSDKKey(env, oldConfig.SDKKey, gracePeriod, source)
+		} else {
+			r.core.RemovingEnvironmentCredential(oldConfig.SDKKey)
+			env.RemoveCredential(oldConfig.SDKKey)
+		}
+		r.auditCredentialRotated(source.audit, env, oldConfig.SDKKey, newConfig.SDKKey)
+	}
+
+	if newConfig.MobileKey != oldConfig.MobileKey {
+		if newConfig.MobileKey != "" {
+			env.AddCredential(newConfig.MobileKey)
+			r.core.AddedEnvironmentCredential(env, newConfig.MobileKey)
+		}
+		if oldConfig.MobileKey != "" {
+			r.core.RemovingEnvironmentCredential(oldConfig.MobileKey)
+			env.RemoveCredential(oldConfig.MobileKey)
+		}
+		r.auditCredentialRotated(source.audit, env, oldConfig.MobileKey, newConfig.MobileKey)
+	}
+}
+
+// deprecateSDKKey keeps an old SDK key working for the specified length of time after it has been
+// replaced, so that SDKs that are still using it do not all fail at once. This is the same mechanism
+// that is used for expiring keys in auto-configuration mode. If the old key has been made current again
+// by the time the grace period ends, it is not removed.
+//
+// The timer is kept in r.keyExpiryTimers, so that it can be stopped if the environment is removed or
+// recreated, or Relay is closed, before the grace period ends.
+func (r *Relay) deprecateSDKKey(env relayenv.EnvContext, oldKey config.SDKKey, gracePeriod time.Duration,
+	source changeSource) {
+	name := env.GetIdentifiers().ConfiguredName
+	r.loggers.Warnf(logMsgOldSDKKeyGracePeriod, core.ObscureKey(string(oldKey)), name, gracePeriod)
+	env.DeprecateCredential(oldKey)
+
+	// The lock is held until the timer has been stored, so even if it fires immediately, the function
+	// will find it in the map.
+	r.keyExpiryLock.Lock()
+	defer r.keyExpiryLock.Unlock()
+	var timer *time.Timer
+	timer = time.AfterFunc(gracePeriod, func() {
+		r.keyExpiryLock.Lock()
+		if r.keyExpiryTimers[env][oldKey] != timer {
+			r.keyExpiryLock.Unlock() // this timer was stopped, or replaced, after it had already fired
+			return
+		}
+		delete(r.keyExpiryTimers[env], oldKey)
+		r.keyExpiryLock.Unlock()
+
+		stillDeprecated := false
+		for _, c := range env.GetDeprecatedCredentials() {
+			stillDeprecated = stillDeprecated || c == oldKey
+		}
+		if !stillDeprecated {
+			return
+		}
+		r.loggers.Warnf(logMsgOldSDKKeyExpired, core.ObscureKey(string(oldKey)), name)
+		if foundEnv, _ := r.core.FindEnvironment(oldKey); foundEnv == env {
+			r.core.RemovingEnvironmentCredential(oldKey)
+		}
+		env.RemoveCredential(oldKey)
+		r.auditCredentialExpired(source.audit, env, oldKey)
+	})
+	if r.keyExpiryTimers == nil {
+		r.keyExpiryTimers = make(map[relayenv.EnvContext]map[config.SDKKey]*time.Timer)
+	}
+	if r.keyExpiryTimers[env] == nil {
+		r.keyExpiryTimers[env] = make(map[config.SDKKey]*time.Timer)
+	}
+	if previous := r.keyExpiryTimers[env][oldKey]; previous != nil {
+		previous.Stop() // the key was deprecated again, so the new grace period replaces the old one
+	}
+	r.keyExpiryTimers[env][oldKey] = timer
+}
+
+// stopKeyExpiryTimers cancels any pending SDK key expirations for the environment, or for all
+// environments if env is nil.
+func (r *Relay) stopKeyExpiryTimers(env relayenv.EnvContext) {
+	r.keyExpiryLock.Lock()
+	defer r.keyExpiryLock.Unlock()
+	for e, timers := range r.keyExpiryTimers {
+		if env != nil && e != env {
+			continue
+		}
+		for _, t := range timers {
+			t.Stop()
+		}
+		delete(r.keyExpiryTimers, e)
+	}
+}
+
+// canUpdateEnvironmentInPlace returns true if the only differences between the two configurations
+// are in properties that updateEnvironmentFromConfig knows how to change.
+func canUpdateEnvironmentInPlace(oldConfig, newConfig config.EnvConfig) bool {
+	withoutUpdatableProps := func(c config.EnvConfig) config.EnvConfig {
+		c.SDKKey, c.MobileKey, c.TTL, c.SecureMode = "", "", configtypes.OptDuration{}, false
+		c.SDKKeyGracePeriod, c.LogLevel = configtypes.OptDuration{}, config.OptLogLevel{}
+		return c
+	}
+	return reflect.DeepEqual(withoutUpdatableProps(oldConfig), withoutUpdatableProps(newConfig))
+}
+
+// withoutUpdatableMainProps returns the configuration without the global properties that reloadConfig
+// changes in place, so that it can tell whether any others have changed.
+func withoutUpdatableMainProps(c config.Config) config.Config {
+	c.Main.LogLevel = config.OptLogLevel{}
+	return c
+}
@@ -0,0 +1,255 @@
+package relay
+
+import (
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/relayenv"
+	st "github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest"
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sharedtest/testclient"
+
+	"github.com/launchdarkly/eventsource"
+	"github.com/launchdarkly/go-configtypes"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
+	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeReloadTestConfig(envs map[string]*c.EnvConfig) c.Config {
+	return c.Config{Environment: envs}
+}
+
+func reloadTest(t *testing.T, config c.Config, action func(relayTestHelper, *ldlogtest.MockLog)) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+
+	relay, err := newRelayInternal(config, relayInternalOptions{
+		loggers:       mockLog.Loggers,
+		clientFactory: testclient.FakeLDClientFactory(true),
+	})
+	require.NoError(t, err)
+	defer relay.Close()
+	require.NoError(t, relay.core.WaitForAllClients(time.Second))
+
+	action(relayTestHelper{t: t, relay: relay}, mockLog)
+}
+
+func (h relayTestHelper) getEnv(credential c.SDKCredential) relayenv.EnvContext {
+	env, _ := h.relay.core.GetEnvironment(credential)
+	return env
+}
+
+func TestReloadConfigAddsAndRemovesEnvironments(t *testing.T) {
+	config := makeReloadTestConfig(map[string]*c.EnvConfig{
+		"a": {SDKKey: c.SDKKey("sdk-a")},
+		"b": {SDKKey: c.SDKKey("sdk-b")},
+	})
+	reloadTest(t, config, func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		envA := h.getEnv(c.SDKKey("sdk-a"))
+		require.NotNil(t, envA)
+
+		newConfig := makeReloadTestConfig(map[string]*c.EnvConfig{
+			"a": {SDKKey: c.SDKKey("sdk-a")},
+			"c": {SDKKey: c.SDKKey("sdk-c")},
+		})
+		require.NoError(t, h.relay.ReloadConfig(newConfig))
+
+		assert.Equal(t, envA, h.getEnv(c.SDKKey("sdk-a"))) // unchanged environment was left alone
+		assert.Nil(t, h.getEnv(c.SDKKey("sdk-b")))
+		assert.NotNil(t, h.getEnv(c.SDKKey("sdk-c")))
+		assert.Len(t, h.relay.core.GetAllEnvironments(), 2)
+		mockLog.AssertMessageMatch(t, false, ldlog.Warn, "restarted")
+	})
+}
+
+func TestReloadConfigUpdatesEnvironmentInPlace(t *testing.T) {
+	config := makeReloadTestConfig(map[string]*c.EnvConfig{
+		"a": {SDKKey: c.SDKKey("sdk-a"), MobileKey: c.MobileKey("mob-a")},
+	})
+	reloadTest(t, config, func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		env := h.getEnv(c.SDKKey("sdk-a"))
+		require.NotNil(t, env)
+
+		newConfig := makeReloadTestConfig(map[string]*c.EnvConfig{
+			"a": {
+				SDKKey:     c.SDKKey("sdk-a2"),
+				MobileKey:  c.MobileKey("mob-a2"),
+				TTL:        configtypes.NewOptDuration(time.Minute),
+				SecureMode: true,
+			},
+		})
+		require.NoError(t, h.relay.ReloadConfig(newConfig))
+
+		assert.Equal(t, env, h.getEnv(c.SDKKey("sdk-a2")))
+		assert.Equal(t, env, h.getEnv(c.MobileKey("mob-a2")))
+		assert.Nil(t, h.getEnv(c.SDKKey("sdk-a")))
+		assert.Nil(t, h.getEnv(c.MobileKey("mob-a")))
+		assert.ElementsMatch(t, []c.SDKCredential{c.SDKKey("sdk-a2"), c.MobileKey("mob-a2")}, env.GetCredentials())
+		assert.Equal(t, time.Minute, env.GetTTL())
+		assert.True(t, env.IsSecureMode())
+	})
+}
+
+func TestReloadConfigRecreatesEnvironmentIfOtherPropertiesChanged(t *testing.T) {
+	config := makeReloadTestConfig(map[string]*c.EnvConfig{
+		"a": {SDKKey: c.SDKKey("sdk-a")},
+	})
+	reloadTest(t, config, func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		env := h.getEnv(c.SDKKey("sdk-a"))
+		require.NotNil(t, env)
+
+		newConfig := makeReloadTestConfig(map[string]*c.EnvConfig{
+			"a": {SDKKey: c.SDKKey("sdk-a"), EnvID: c.EnvironmentID("env-a"), LogLevel: c.NewOptLogLevel(ldlog.Debug)},
+		})
+		require.NoError(t, h.relay.ReloadConfig(newConfig))
+
+		newEnv := h.getEnv(c.SDKKey("sdk-a"))
+		require.NotNil(t, newEnv)
+		assert.NotSame(t, env, newEnv)
+		assert.Same(t, newEnv, h.getEnv(c.EnvironmentID("env-a")))
+		assert.Len(t, h.relay.core.GetAllEnvironments(), 1)
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "recreating the environment")
+	})
+}
+
+func TestReloadConfigWarnsAboutSettingsThatRequireRestart(t *testing.T) {
+	envs := map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}}
+	reloadTest(t, makeReloadTestConfig(envs), func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		newConfig := makeReloadTestConfig(envs)
+		newConfig.Main.ExitOnError = true
+		require.NoError(t, h.relay.ReloadConfig(newConfig))
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "restarted")
+	})
+}
+
+func TestReloadConfigChangesLogLevelsInPlace(t *testing.T) {
+	config := makeReloadTestConfig(map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}})
+	reloadTest(t, config, func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		env := h.getEnv(c.SDKKey("sdk-a"))
+		require.NotNil(t, env)
+		require.NoError(t, env.GetStore().Init(st.AllData))
+		req := st.BuildRequestWithAuth("GET", "http://localhost/all", c.SDKKey("sdk-a"), nil)
+
+		st.WithStreamRequest(t, req, h.relay.Handler, func(eventCh <-chan eventsource.Event) {
+			st.ExpectStreamChEvent(t, eventCh, time.Second) // the initial "put" event
+
+			newConfig := makeReloadTestConfig(map[string]*c.EnvConfig{
+				"a": {SDKKey: c.SDKKey("sdk-a"), LogLevel: c.NewOptLogLevel(ldlog.Debug)},
+			})
+			newConfig.Main.LogLevel = c.NewOptLogLevel(ldlog.Error)
+			require.NoError(t, h.relay.ReloadConfig(newConfig))
+
+			assert.Same(t, env, h.getEnv(c.SDKKey("sdk-a")))
+			flag := st.Flag1ServerSide.Flag
+			flag.Version++
+			_, err := st.UpsertFlag(env.GetStore(), flag)
+			require.NoError(t, err)
+			event := st.ExpectStreamChEvent(t, eventCh, time.Second) // the same stream is still open
+			assert.Equal(t, "patch", event.Event())
+		})
+
+		h.relay.core.Loggers.Warn("global warning")
+		mockLog.AssertMessageMatch(t, false, ldlog.Warn, "global warning")
+		mockLog.AssertMessageMatch(t, true, ldlog.Info, "log level changed to error")
+		mockLog.AssertMessageMatch(t, false, ldlog.Warn, "recreating the environment")
+		mockLog.AssertMessageMatch(t, false, ldlog.Warn, "restarted")
+	})
+}
+
+func TestReloadConfigRejectsInvalidConfig(t *testing.T) {
+	envs := map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}}
+	reloadTest(t, makeReloadTestConfig(envs), func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		assert.Equal(t, errNoEnvironments, h.relay.ReloadConfig(c.Config{}))
+
+		badConfig := makeReloadTestConfig(map[string]*c.EnvConfig{"b": {}})
+		assert.Error(t, h.relay.ReloadConfig(badConfig))
+
+		assert.NotNil(t, h.getEnv(c.SDKKey("sdk-a")))
+		assert.Len(t, h.relay.core.GetAllEnvironments(), 1)
+	})
+}
+
+func TestReloadConfigIsNotSupportedInAutoConfigMode(t *testing.T) {
+	autoConfTest(t, testAutoConfDefaultConfig, nil, func(p autoConfTestParams) {
+		newConfig := makeReloadTestConfig(map[string]*c.EnvConfig{"a": {SDKKey: c.SDKKey("sdk-a")}})
+		assert.Equal(t, errReloadNotSupported, p.relay.ReloadConfig(newConfig))
+	})
+}
+
+func TestReloadConfigKeepsOldSDKKeyDuringGracePeriod(t *testing.T) {
+	gracePeriod := time.Millisecond * 200
+	config := makeReloadTestConfig(map[string]*c.EnvConfig{
+		"a": {SDKKey: c.SDKKey("sdk-a"), SDKKeyGracePeriod: configtypes.NewOptDuration(gracePeriod)},
+	})
+	reloadTest(t, config, func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		env := h.getEnv(c.SDKKey("sdk-a"))
+		require.NotNil(t, env)
+
+		newConfig := makeReloadTestConfig(map[string]*c.EnvConfig{
+			"a": {SDKKey: c.SDKKey("sdk-a2"), SDKKeyGracePeriod: configtypes.NewOptDuration(gracePeriod)},
+		})
+		require.NoError(t, h.relay.ReloadConfig(newConfig))
+
+		assert.Equal(t, env, h.getEnv(c.SDKKey("sdk-a2")))
+		assert.Equal(t, env, h.getEnv(c.SDKKey("sdk-a")))
+		assert.Equal(t, []c.SDKCredential{c.SDKKey("sdk-a")}, env.GetDeprecatedCredentials())
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "will still be accepted")
+
+		require.Eventually(t, func() bool { return h.getEnv(c.SDKKey("sdk-a")) == nil }, time.Second, time.Millisecond*10)
+		assert.Equal(t, env, h.getEnv(c.SDKKey("sdk-a2")))
+		assert.Len(t, env.GetDeprecatedCredentials(), 0)
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "has expired")
+	})
+}
+
+func TestReloadConfigStopsGracePeriodTimerWhenEnvironmentIsRemoved(t *testing.T) {
+	gracePeriod := time.Millisecond * 100
+	config := makeReloadTestConfig(map[string]*c.EnvConfig{
+		"a": {SDKKey: c.SDKKey("sdk-a"), SDKKeyGracePeriod: configtypes.NewOptDuration(gracePeriod)},
+		"b": {SDKKey: c.SDKKey("sdk-b")},
+	})
+	reloadTest(t, config, func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		require.NoError(t, h.relay.ReloadConfig(makeReloadTestConfig(map[string]*c.EnvConfig{
+			"a": {SDKKey: c.SDKKey("sdk-a2"), SDKKeyGracePeriod: configtypes.NewOptDuration(gracePeriod)},
+			"b": {SDKKey: c.SDKKey("sdk-b")},
+		})))
+		h.relay.keyExpiryLock.Lock()
+		assert.Len(t, h.relay.keyExpiryTimers, 1)
+		h.relay.keyExpiryLock.Unlock()
+
+		require.NoError(t, h.relay.ReloadConfig(makeReloadTestConfig(map[string]*c.EnvConfig{
+			"b": {SDKKey: c.SDKKey("sdk-b")},
+		})))
+		h.relay.keyExpiryLock.Lock()
+		assert.Len(t, h.relay.keyExpiryTimers, 0)
+		h.relay.keyExpiryLock.Unlock()
+
+		<-time.After(gracePeriod * 2)
+		mockLog.AssertMessageMatch(t, false, ldlog.Warn, "has expired")
+	})
+}
+
+func TestReloadConfigCanRestoreOldSDKKeyDuringGracePeriod(t *testing.T) {
+	gracePeriod := time.Millisecond * 100
+	makeConfig := func(sdkKey c.SDKKey) c.Config {
+		return makeReloadTestConfig(map[string]*c.EnvConfig{
+			"a": {SDKKey: sdkKey, SDKKeyGracePeriod: configtypes.NewOptDuration(gracePeriod)},
+		})
+	}
+	reloadTest(t, makeConfig("sdk-a"), func(h relayTestHelper, mockLog *ldlogtest.MockLog) {
+		env := h.getEnv(c.SDKKey("sdk-a"))
+		require.NotNil(t, env)
+
+		require.NoError(t, h.relay.ReloadConfig(makeConfig("sdk-a2")))
+		require.NoError(t, h.relay.ReloadConfig(makeConfig("sdk-a")))
+
+		// Only the timer for "sdk-a2" should take effect; "sdk-a" is the current key again.
+		require.Eventually(t, func() bool { return h.getEnv(c.SDKKey("sdk-a2")) == nil }, time.Second, time.Millisecond*10)
+		<-time.After(gracePeriod)
+		assert.Equal(t, env, h.getEnv(c.SDKKey("sdk-a")))
+		assert.Equal(t, []c.SDKCredential{c.SDKKey("sdk-a")}, env.GetCredentials())
+	})
+}
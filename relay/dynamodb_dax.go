@@ -0,0 +1,17 @@
+package relay
+
+import (
+	"github.com/launchdarkly/ld-relay/v6/internal/core/sdks"
+)
+
+// DAXClientFactory creates the client for a DynamoDB Accelerator (DAX) cluster, given the DAXEndpoint
+// from the [DynamoDB] configuration section and the AWS session that Relay would otherwise use for
+// DynamoDB. It normally returns a client from github.com/aws/aws-dax-go.
+type DAXClientFactory = sdks.DAXClientFactory
+
+// RegisterDAXClient makes Relay use DAX for all of its DynamoDB reads and writes when the DAXEndpoint
+// configuration property is set. This must be called before NewRelay; it panics if factory is nil or
+// if it has already been called.
+func RegisterDAXClient(factory DAXClientFactory) {
+	sdks.RegisterDAXClientFactory(factory)
+}
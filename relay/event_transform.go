@@ -0,0 +1,24 @@
+package relay
+
+import (
+	"github.com/launchdarkly/ld-relay/v6/internal/eventtransform"
+)
+
+// EventTransformer is a function that can modify or remove an analytics event before Relay forwards it
+// to LaunchDarkly, for instance to remove personal information from user attributes. The event is the
+// JSON object that an SDK sent; the function returns the event to forward, or nil to discard it.
+//
+// To use an EventTransformer, register it with RegisterEventTransformer, and list its name in the
+// eventTransformers setting of each environment that it should apply to.
+type EventTransformer = eventtransform.Transformer
+
+// EventTransformContext describes where an event came from: the environment's name in the
+// configuration, its client-side ID if configured, and the kind of SDK ("server", "mobile", or "js").
+type EventTransformContext = eventtransform.Context
+
+// RegisterEventTransformer makes an EventTransformer available under a name, for use in the
+// eventTransformers setting of environments. This must be called before NewRelay; it panics if the
+// name has already been registered.
+func RegisterEventTransformer(name string, transformer EventTransformer) {
+	eventtransform.Register(name, transformer)
+}
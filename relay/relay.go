@@ -2,11 +2,14 @@ package relay
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/launchdarkly/ld-relay/v6/config"
+	"github.com/launchdarkly/ld-relay/v6/internal/audit"
 	"github.com/launchdarkly/ld-relay/v6/internal/autoconfig"
 	"github.com/launchdarkly/ld-relay/v6/internal/core"
 	"github.com/launchdarkly/ld-relay/v6/internal/core/httpconfig"
@@ -16,6 +19,7 @@ import (
 	"github.com/launchdarkly/ld-relay/v6/internal/util"
 	"github.com/launchdarkly/ld-relay/v6/relay/version"
 
+	"google.golang.org/grpc"
 	"gopkg.in/launchdarkly/go-sdk-common.v2/ldlog"
 	ld "gopkg.in/launchdarkly/go-server-sdk.v5"
 )
@@ -24,14 +28,22 @@ var (
 	errNoEnvironments = errors.New("you must specify at least one environment in your configuration")
 )
 
+func errNewAuditLogFailed(err error) error {
+	return fmt.Errorf("unable to open audit log: %w", err)
+}
+
 // Relay relays endpoints to and from the LaunchDarkly service
 type Relay struct {
 	http.Handler
 	core             *core.RelayCore
 	autoConfigStream *autoconfig.StreamManager
 	archiveManager   filedata.ArchiveManagerInterface
+	audit            *audit.Logger
 	config           config.Config
 	loggers          ldlog.Loggers
+	reloadLock       sync.Mutex
+	keyExpiryTimers  map[relayenv.EnvContext]map[config.SDKKey]*time.Timer // see deprecateSDKKey
+	keyExpiryLock    sync.Mutex
 }
 
 // ClientFactoryFunc is a function that can be used with NewRelay to specify custom behavior when
@@ -77,7 +89,8 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 	hasAutoConfigKey := c.AutoConfig.Key != ""
 	hasFileDataSource := c.OfflineMode.FileDataSource != ""
 
-	if !hasAutoConfigKey && !hasFileDataSource && len(c.Environment) == 0 {
+	// If the admin API is enabled, environments can be added after startup, so it's OK to have none.
+	if !hasAutoConfigKey && !hasFileDataSource && len(c.Environment) == 0 && !c.Admin.Port.IsDefined() {
 		return nil, errNoEnvironments
 	}
 
@@ -99,8 +112,15 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 	}
 	thingsToCleanUp.AddFunc(core.Close)
 
+	auditLog, err := audit.NewLogger(c.AuditLog, userAgent, core.Loggers)
+	if err != nil {
+		return nil, errNewAuditLogFailed(err)
+	}
+	thingsToCleanUp.AddCloser(auditLog)
+
 	r := &Relay{
 		core:    core,
+		audit:   auditLog,
 		config:  c,
 		loggers: options.loggers,
 	}
@@ -115,10 +135,12 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 		if err != nil {
 			return nil, err
 		}
+		httpConfig = httpConfig.WithUpstreamFailover(core.UpstreamFailover())
 		r.autoConfigStream = autoconfig.NewStreamManager(
 			c.AutoConfig.Key,
 			c.Main.StreamURI.String(),
 			&relayAutoConfigActions{r},
+			autoconfig.NewEnvironmentFilter(c.AutoConfig),
 			httpConfig,
 			0,
 			core.Loggers,
@@ -138,6 +160,9 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 	}
 
 	if hasFileDataSource {
+		if c.OfflineMode.NoOutboundConnections {
+			options.loggers.Info("Outbound connections are disabled; only the file data source will be used")
+		}
 		factory := options.archiveManagerFactory
 		if factory == nil {
 			factory = defaultArchiveManagerFactory
@@ -154,6 +179,10 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 		thingsToCleanUp.AddCloser(archiveManager)
 	}
 
+	if c.ReadReplica.Enabled {
+		options.loggers.Info("Read replica mode is enabled; flag data will be read from the data store, without connecting to LaunchDarkly")
+	}
+
 	if c.Main.ExitAlways {
 		options.loggers.Info("Running in one-shot mode - will exit immediately after initializing environments")
 		// Just wait until all clients have either started or failed, then exit without bothering
@@ -175,11 +204,68 @@ func defaultArchiveManagerFactory(filePath string, handler filedata.UpdateHandle
 	return am, err
 }
 
+// RegisterGRPCServices adds the Relay Proxy's gRPC services to a gRPC server. This does nothing unless
+// the GRPCPort property is set in the configuration; the caller is responsible for starting the
+// server on that port.
+func (r *Relay) RegisterGRPCServices(registrar grpc.ServiceRegistrar) {
+	r.core.RegisterGRPCServices(registrar)
+}
+
+// InternalMetricsHandler returns the HTTP handler for the internal metrics endpoint, which serves
+// Prometheus metrics about Relay's own operation at /metrics. It returns nil unless the Port property
+// is set in config.InternalMetricsConfig; the caller is responsible for serving it on that port.
+func (r *Relay) InternalMetricsHandler() http.Handler {
+	return r.core.InternalMetricsHandler()
+}
+
+// TenantHandler returns the HTTP handler for a tenant's own port, which serves only the environments
+// that are assigned to that tenant in config.TenantConfig. The caller is responsible for serving it on
+// that port.
+func (r *Relay) TenantHandler(tenant string) http.Handler {
+	return r.core.TenantHandler(tenant, r.Handler)
+}
+
+// IsReady returns true if the Relay Proxy has its configuration and every environment is ready to serve
+// flag data; this is the same condition that the /ready endpoint reports.
+func (r *Relay) IsReady() bool {
+	return r.core.IsReady()
+}
+
+// IsInMaintenanceMode returns true if maintenance mode is on; see ToggleMaintenanceMode.
+func (r *Relay) IsInMaintenanceMode() bool {
+	return r.core.IsInMaintenanceMode()
+}
+
+// ToggleMaintenanceMode turns maintenance mode on if it is off, or off if it is on. This is what Relay
+// does when it receives the SIGUSR2 signal; maintenance mode can also be set with the admin API.
+//
+// While maintenance mode is on, new requests other than the status and health endpoints are rejected
+// with the response described in config.MaintenanceConfig, and the readiness endpoints report that Relay
+// is not ready, so that load balancers stop sending traffic to it. Stream connections that are already
+// open are not affected, so they can drain before Relay is shut down.
+func (r *Relay) ToggleMaintenanceMode() {
+	r.setMaintenanceMode(!r.core.IsInMaintenanceMode(), sourceSignal)
+}
+
+func (r *Relay) setMaintenanceMode(on bool, source changeSource) {
+	if !r.core.SetMaintenanceMode(on) {
+		return
+	}
+	action, state := audit.ActionMaintenanceOff, "off"
+	if on {
+		action, state = audit.ActionMaintenanceOn, "on"
+	}
+	r.loggers.Warnf("%s: maintenance mode turned %s", source.logName, state)
+	r.audit.Log(audit.Event{Action: action, Source: source.audit})
+}
+
 // Close shuts down components created by the Relay Proxy.
 //
 // This includes dropping all connections to the LaunchDarkly services and to SDK clients,
 // closing database connections if any, and stopping all Relay port listeners, goroutines,
-// and OpenCensus exporters.
+// and OpenCensus exporters. Streaming clients are sent a comment telling them to reconnect
+// before they are disconnected, and Close waits until any pending analytics events have been
+// delivered.
 func (r *Relay) Close() error {
 	if r.autoConfigStream != nil {
 		r.autoConfigStream.Close()
@@ -187,6 +273,8 @@ func (r *Relay) Close() error {
 	if r.archiveManager != nil {
 		_ = r.archiveManager.Close()
 	}
+	r.stopKeyExpiryTimers(nil)
 	r.core.Close()
+	_ = r.audit.Close()
 	return nil
 }
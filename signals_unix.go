@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/launchdarkly/ld-relay/v6/relay"
+)
+
+// startMaintenanceModeSignal causes maintenance mode to be turned on or off whenever the process receives
+// a SIGUSR2 signal; see relay.Relay.ToggleMaintenanceMode.
+func startMaintenanceModeSignal(r *relay.Relay) {
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGUSR2)
+	go func() {
+		for range signalCh {
+			r.ToggleMaintenanceMode()
+		}
+	}()
+}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"github.com/launchdarkly/ld-relay/v6/relay"
+)
+
+// startMaintenanceModeSignal does nothing on Windows, which has no SIGUSR2 signal; maintenance mode can
+// still be turned on and off with the admin API.
+func startMaintenanceModeSignal(r *relay.Relay) {}